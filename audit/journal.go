@@ -0,0 +1,351 @@
+// Package audit provides an append-only journal of the write operations made to trees. The
+// journal is kept apart from the trees themselves so operator actions can be reconstructed
+// even if the tree storage is damaged or tampered with.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+)
+
+// Operations that are recorded in the journal
+const (
+	OpQueueLeaves        = "QueueLeaves"
+	OpStoreSignedLogRoot = "StoreSignedLogRoot"
+)
+
+const (
+	// Prefix of all journal file names
+	journalFilePrefix = "audit-"
+	// Suffix for journal files that are complete and can be shipped
+	journalFileSuffix = ".jsonl"
+	// Suffix for the journal file that is currently being written
+	journalActiveSuffix = ".active"
+)
+
+// Record is a single entry in the journal. Records are written before the operation they
+// describe is attempted, so a record does not mean that the operation succeeded.
+type Record struct {
+	TimestampNanos int64  `json:"timestamp_nanos"`
+	Operation      string `json:"operation"`
+	TreeID         int64  `json:"tree_id"`
+	// Caller identifies who asked for the operation, e.g. the address of an RPC client
+	Caller    string `json:"caller"`
+	RequestID string `json:"request_id,omitempty"`
+	// Checksum is the hex SHA-256 of the serialized request or root being written
+	Checksum  string `json:"checksum"`
+	LeafCount int    `json:"leaf_count,omitempty"`
+	TreeSize  int64  `json:"tree_size,omitempty"`
+	// Seq numbers the records in each journal file from zero
+	Seq int64 `json:"seq"`
+	// ChainHash is the hex SHA-256 of the previous record's ChainHash followed by this record
+	// serialized with an empty ChainHash. It chains the records of a file together so
+	// removing or altering one can be detected.
+	ChainHash string `json:"chain_hash"`
+}
+
+// ChecksumProto returns the checksum of a proto to use in a Record.
+func ChecksumProto(pb proto.Message) (string, error) {
+	data, err := proto.Marshal(pb)
+
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// chainHash computes the ChainHash for a record that follows one with prevHash.
+func chainHash(prevHash string, r Record) (string, error) {
+	r.ChainHash = ""
+	data, err := json.Marshal(r)
+
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write([]byte(prevHash))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Journal writes Records to a series of files in a directory, one JSON object per line. Each
+// record is synced to disk before Append returns. When the current file grows past a size
+// limit it is closed and a new one started. Completed files can be shipped to a blob store,
+// e.g. one backed by external storage, after which they're removed from the directory.
+type Journal struct {
+	// dir is the directory holding the journal files. It must not be shared with anything else.
+	dir string
+	// maxFileBytes is the size after which the current file is rotated
+	maxFileBytes int64
+	// timeSource is a util.TimeSource that can be injected for testing
+	timeSource util.TimeSource
+
+	// mu guards the fields below it
+	mu sync.Mutex
+	// shipper receives completed files, it's nil if they're kept in the directory
+	shipper storage.BlobStore
+	// f is the file being written, it's nil until the first record is appended after
+	// starting or rotating
+	f *os.File
+	// fileSize is the number of bytes written to f
+	fileSize int64
+	// seq is the Seq of the next record in f
+	seq int64
+	// prevHash is the ChainHash of the last record written to f
+	prevHash string
+
+	// shipMu serializes shipping so files are not shipped twice
+	shipMu sync.Mutex
+}
+
+// NewJournal creates a Journal writing files in dir, which is created if needed. Files left
+// active by a previous run are treated as complete. The last record in them may be truncated
+// if the process stopped while writing it.
+func NewJournal(dir string, maxFileBytes int64, timeSource util.TimeSource) (*Journal, error) {
+	if maxFileBytes <= 0 {
+		return nil, fmt.Errorf("audit journal max file size must be positive, got: %d", maxFileBytes)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	files, err := ioutil.ReadDir(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		if name := f.Name(); strings.HasSuffix(name, journalActiveSuffix) {
+			glog.Warningf("Audit journal completing file left by previous run: %s", name)
+
+			if err := os.Rename(filepath.Join(dir, name), filepath.Join(dir, strings.TrimSuffix(name, journalActiveSuffix))); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &Journal{dir: dir, maxFileBytes: maxFileBytes, timeSource: timeSource}, nil
+}
+
+// SetShipper makes the journal send each completed file to bs, keyed by its file name, and
+// then delete it. Passing nil keeps completed files in the journal directory.
+func (j *Journal) SetShipper(bs storage.BlobStore) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.shipper = bs
+}
+
+// Append fills in the timestamp, sequence number and chain hash of r and durably writes it
+// to the journal.
+func (j *Journal) Append(r Record) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.f == nil {
+		if err := j.openFile(); err != nil {
+			return err
+		}
+	}
+
+	r.TimestampNanos = j.timeSource.Now().UnixNano()
+	r.Seq = j.seq
+
+	var err error
+	if r.ChainHash, err = chainHash(j.prevHash, r); err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(r)
+
+	if err != nil {
+		return err
+	}
+
+	line = append(line, '\n')
+
+	if _, err := j.f.Write(line); err != nil {
+		return err
+	}
+
+	if err := j.f.Sync(); err != nil {
+		return err
+	}
+
+	j.fileSize += int64(len(line))
+	j.seq++
+	j.prevHash = r.ChainHash
+
+	if j.fileSize >= j.maxFileBytes {
+		if err := j.closeFile(); err != nil {
+			return err
+		}
+
+		if j.shipper != nil {
+			go func() {
+				if err := j.ShipCompleted(); err != nil {
+					glog.Warningf("Failed to ship audit journal files: %v", err)
+				}
+			}()
+		}
+	}
+
+	return nil
+}
+
+// openFile starts a new active file named after the current time.
+func (j *Journal) openFile() error {
+	nanos := j.timeSource.Now().UnixNano()
+
+	for {
+		name := fmt.Sprintf("%s%019d%s", journalFilePrefix, nanos, journalFileSuffix)
+
+		// Don't reuse the name of a file that completed in the same nanosecond
+		if _, err := os.Stat(filepath.Join(j.dir, name)); err == nil {
+			nanos++
+			continue
+		}
+
+		f, err := os.OpenFile(filepath.Join(j.dir, name+journalActiveSuffix), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+
+		if os.IsExist(err) {
+			nanos++
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		j.f = f
+		j.fileSize = 0
+		j.seq = 0
+		j.prevHash = ""
+		return nil
+	}
+}
+
+// closeFile closes the active file and marks it complete.
+func (j *Journal) closeFile() error {
+	activePath := j.f.Name()
+	err := j.f.Close()
+	j.f = nil
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(activePath, strings.TrimSuffix(activePath, journalActiveSuffix))
+}
+
+// Close completes the active file, if any. Completed files are not shipped.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.f == nil {
+		return nil
+	}
+
+	return j.closeFile()
+}
+
+// ShipCompleted sends every completed file in the journal directory to the shipper and
+// removes it once it has been stored. It does nothing if there is no shipper. This is
+// called after each rotation but can also be called to retry files that failed to ship.
+func (j *Journal) ShipCompleted() error {
+	j.mu.Lock()
+	shipper := j.shipper
+	j.mu.Unlock()
+
+	if shipper == nil {
+		return nil
+	}
+
+	j.shipMu.Lock()
+	defer j.shipMu.Unlock()
+
+	files, err := ioutil.ReadDir(j.dir)
+
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		name := f.Name()
+
+		if !strings.HasPrefix(name, journalFilePrefix) || !strings.HasSuffix(name, journalFileSuffix) {
+			continue
+		}
+
+		path := filepath.Join(j.dir, name)
+		data, err := ioutil.ReadFile(path)
+
+		if err != nil {
+			return err
+		}
+
+		if err := shipper.Put(name, data); err != nil {
+			return err
+		}
+
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReadRecords reads the records of a single journal file and checks that their sequence
+// numbers and chain hashes are intact. It returns the records read before any problem found.
+func ReadRecords(r io.Reader) ([]Record, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	records := make([]Record, 0)
+	prevHash := ""
+
+	for scanner.Scan() {
+		var rec Record
+
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return records, fmt.Errorf("audit record %d is corrupt: %v", len(records), err)
+		}
+
+		if got, want := rec.Seq, int64(len(records)); got != want {
+			return records, fmt.Errorf("audit record %d has sequence number %d", want, got)
+		}
+
+		want, err := chainHash(prevHash, rec)
+
+		if err != nil {
+			return records, err
+		}
+
+		if rec.ChainHash != want {
+			return records, fmt.Errorf("audit record %d has chain hash %s, expected %s", rec.Seq, rec.ChainHash, want)
+		}
+
+		records = append(records, rec)
+		prevHash = rec.ChainHash
+	}
+
+	return records, scanner.Err()
+}
@@ -0,0 +1,253 @@
+package audit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+)
+
+var fakeTime = time.Date(2016, 10, 3, 12, 0, 0, 0, time.UTC)
+
+func newTestJournal(t *testing.T, maxFileBytes int64) (*Journal, string) {
+	dir, err := ioutil.TempDir("", "audit")
+
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	j, err := NewJournal(dir, maxFileBytes, util.FakeTimeSource{FakeTime: fakeTime})
+
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("NewJournal()=%v", err)
+	}
+
+	return j, dir
+}
+
+// journalFiles returns the names of the files in dir with suffix, in name order.
+func journalFiles(t *testing.T, dir, suffix string) []string {
+	files, err := ioutil.ReadDir(dir)
+
+	if err != nil {
+		t.Fatalf("Failed to read journal dir: %v", err)
+	}
+
+	names := make([]string, 0)
+
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), suffix) {
+			names = append(names, f.Name())
+		}
+	}
+
+	return names
+}
+
+func readJournalFile(t *testing.T, path string) []Record {
+	f, err := os.Open(path)
+
+	if err != nil {
+		t.Fatalf("Failed to open journal file: %v", err)
+	}
+
+	defer f.Close()
+
+	records, err := ReadRecords(f)
+
+	if err != nil {
+		t.Fatalf("ReadRecords()=%v", err)
+	}
+
+	return records
+}
+
+func TestJournalAppendAndRead(t *testing.T) {
+	j, dir := newTestJournal(t, 1024*1024)
+	defer os.RemoveAll(dir)
+
+	if err := j.Append(Record{Operation: OpQueueLeaves, TreeID: 6, Caller: "1.2.3.4:5", RequestID: "req", Checksum: "abcd", LeafCount: 3}); err != nil {
+		t.Fatalf("Append()=%v", err)
+	}
+
+	if err := j.Append(Record{Operation: OpStoreSignedLogRoot, TreeID: 6, Caller: "sequencer", Checksum: "ef01", TreeSize: 3}); err != nil {
+		t.Fatalf("Append()=%v", err)
+	}
+
+	// Records must be on disk before Close
+	if got, want := len(journalFiles(t, dir, journalActiveSuffix)), 1; got != want {
+		t.Fatalf("got %d active files, want %d", got, want)
+	}
+
+	if err := j.Close(); err != nil {
+		t.Fatalf("Close()=%v", err)
+	}
+
+	files := journalFiles(t, dir, journalFileSuffix)
+
+	if got, want := len(files), 1; got != want {
+		t.Fatalf("got %d completed files, want %d", got, want)
+	}
+
+	records := readJournalFile(t, filepath.Join(dir, files[0]))
+
+	if got, want := len(records), 2; got != want {
+		t.Fatalf("read %d records, want %d", got, want)
+	}
+
+	if got, want := records[0].Operation, OpQueueLeaves; got != want {
+		t.Errorf("records[0].Operation=%s, want %s", got, want)
+	}
+
+	if got, want := records[0].LeafCount, 3; got != want {
+		t.Errorf("records[0].LeafCount=%d, want %d", got, want)
+	}
+
+	if got, want := records[1].TreeSize, int64(3); got != want {
+		t.Errorf("records[1].TreeSize=%d, want %d", got, want)
+	}
+
+	if got, want := records[1].TimestampNanos, fakeTime.UnixNano(); got != want {
+		t.Errorf("records[1].TimestampNanos=%d, want %d", got, want)
+	}
+}
+
+func TestReadRecordsDetectsTampering(t *testing.T) {
+	j, dir := newTestJournal(t, 1024*1024)
+	defer os.RemoveAll(dir)
+
+	for _, caller := range []string{"a", "b", "c"} {
+		if err := j.Append(Record{Operation: OpQueueLeaves, Caller: caller}); err != nil {
+			t.Fatalf("Append()=%v", err)
+		}
+	}
+
+	if err := j.Close(); err != nil {
+		t.Fatalf("Close()=%v", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, journalFiles(t, dir, journalFileSuffix)[0]))
+
+	if err != nil {
+		t.Fatalf("Failed to read journal file: %v", err)
+	}
+
+	lines := bytes.SplitAfter(data, []byte("\n"))
+
+	// Altering a record breaks the chain
+	altered := bytes.Replace(data, []byte(`"caller":"b"`), []byte(`"caller":"x"`), 1)
+
+	if records, err := ReadRecords(bytes.NewReader(altered)); err == nil || len(records) != 1 {
+		t.Errorf("ReadRecords() of altered journal=%d records, %v", len(records), err)
+	}
+
+	// So does removing one
+	removed := append(append([]byte{}, lines[0]...), lines[2]...)
+
+	if records, err := ReadRecords(bytes.NewReader(removed)); err == nil || len(records) != 1 {
+		t.Errorf("ReadRecords() of journal with removed record=%d records, %v", len(records), err)
+	}
+}
+
+func TestJournalRotatesAndShips(t *testing.T) {
+	j, dir := newTestJournal(t, 1)
+	defer os.RemoveAll(dir)
+
+	shipDir, err := ioutil.TempDir("", "auditship")
+
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	defer os.RemoveAll(shipDir)
+
+	// Every record fills a file so each one is rotated
+	for i := 0; i < 3; i++ {
+		if err := j.Append(Record{Operation: OpQueueLeaves, TreeID: int64(i)}); err != nil {
+			t.Fatalf("Append()=%v", err)
+		}
+	}
+
+	files := journalFiles(t, dir, journalFileSuffix)
+
+	if got, want := len(files), 3; got != want {
+		t.Fatalf("got %d completed files, want %d", got, want)
+	}
+
+	// The files all started at the same fake time but must have distinct names
+	for i, name := range files {
+		if records := readJournalFile(t, filepath.Join(dir, name)); len(records) != 1 || records[0].TreeID != int64(i) {
+			t.Fatalf("file %s has unexpected records: %v", name, records)
+		}
+	}
+
+	shipper, err := storage.NewFileBlobStore(shipDir)
+
+	if err != nil {
+		t.Fatalf("Failed to create blob store: %v", err)
+	}
+
+	j.SetShipper(shipper)
+
+	if err := j.ShipCompleted(); err != nil {
+		t.Fatalf("ShipCompleted()=%v", err)
+	}
+
+	if got := journalFiles(t, dir, journalFileSuffix); len(got) != 0 {
+		t.Fatalf("files left after shipping: %v", got)
+	}
+
+	for _, name := range files {
+		if _, err := shipper.Get(name); err != nil {
+			t.Errorf("shipped file %s not found: %v", name, err)
+		}
+	}
+}
+
+func TestNewJournalCompletesActiveFiles(t *testing.T) {
+	j, dir := newTestJournal(t, 1024*1024)
+	defer os.RemoveAll(dir)
+
+	if err := j.Append(Record{Operation: OpQueueLeaves}); err != nil {
+		t.Fatalf("Append()=%v", err)
+	}
+
+	// Simulate a crash by not closing the journal
+	if _, err := NewJournal(dir, 1024*1024, util.FakeTimeSource{FakeTime: fakeTime}); err != nil {
+		t.Fatalf("NewJournal()=%v", err)
+	}
+
+	if got := journalFiles(t, dir, journalActiveSuffix); len(got) != 0 {
+		t.Fatalf("active files left after restart: %v", got)
+	}
+
+	if got, want := len(journalFiles(t, dir, journalFileSuffix)), 1; got != want {
+		t.Fatalf("got %d completed files, want %d", got, want)
+	}
+}
+
+func TestChecksumProto(t *testing.T) {
+	root1, err := ChecksumProto(&trillian.SignedLogRoot{TreeSize: 1})
+
+	if err != nil {
+		t.Fatalf("ChecksumProto()=%v", err)
+	}
+
+	root2, err := ChecksumProto(&trillian.SignedLogRoot{TreeSize: 2})
+
+	if err != nil {
+		t.Fatalf("ChecksumProto()=%v", err)
+	}
+
+	if root1 == root2 || len(root1) != 64 {
+		t.Fatalf("ChecksumProto() gave bad checksums: %s %s", root1, root2)
+	}
+}
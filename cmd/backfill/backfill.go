@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/storage/mysql"
+)
+
+// backfillStep fills in one column, a batch at a time. See mysql.Backfiller.
+type backfillStep struct {
+	name  string
+	batch func(cursor string, limit int) (mysql.BackfillBatch, error)
+}
+
+// stepProgress is how far a step has got.
+type stepProgress struct {
+	Cursor   string `json:"cursor"`
+	Examined int64  `json:"examined"`
+	Updated  int64  `json:"updated"`
+	Done     bool   `json:"done"`
+}
+
+// progress is saved after every batch so that a backfill that's stopped can be resumed.
+type progress struct {
+	TreeID int64                    `json:"tree_id"`
+	Steps  map[string]*stepProgress `json:"steps"`
+}
+
+// backfillOptions controls how fast a backfill runs.
+type backfillOptions struct {
+	// batchSize is the max number of rows backfilled in one transaction
+	batchSize int
+	// pause is how long to wait between batches to limit the load on the database
+	pause time.Duration
+	// stop ends the backfill after the current batch when it's closed
+	stop <-chan struct{}
+}
+
+// loadProgress reads the progress of a backfill of a tree, or returns empty progress if the
+// file doesn't exist yet.
+func loadProgress(path string, treeID int64) (*progress, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		return &progress{TreeID: treeID, Steps: make(map[string]*stepProgress)}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var p progress
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse progress file %s: %v", path, err)
+	}
+
+	if p.TreeID != treeID {
+		return nil, fmt.Errorf("progress file %s is for tree %d not %d", path, p.TreeID, treeID)
+	}
+
+	if p.Steps == nil {
+		p.Steps = make(map[string]*stepProgress)
+	}
+
+	return &p, nil
+}
+
+// saveProgress writes p to a temporary file and renames it into place so that a crash can't
+// leave the progress file truncated.
+func saveProgress(path string, p *progress) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-progress-")
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// runBackfill runs each step in turn until it's done, starting from where p says it got to.
+// save is called after every batch. It returns early without an error if opts.stop is closed,
+// in which case running it again with the saved progress carries on from there.
+func runBackfill(steps []backfillStep, p *progress, opts backfillOptions, save func(*progress) error) error {
+	for _, step := range steps {
+		sp, ok := p.Steps[step.name]
+
+		if !ok {
+			sp = &stepProgress{}
+			p.Steps[step.name] = sp
+		}
+
+		for !sp.Done {
+			select {
+			case <-opts.stop:
+				glog.Infof("Stopping %s at cursor %q", step.name, sp.Cursor)
+				return nil
+			default:
+			}
+
+			batch, err := step.batch(sp.Cursor, opts.batchSize)
+
+			if err != nil {
+				return fmt.Errorf("%s failed at cursor %q: %v", step.name, sp.Cursor, err)
+			}
+
+			sp.Cursor = batch.Cursor
+			sp.Examined += int64(batch.Examined)
+			sp.Updated += int64(batch.Updated)
+			sp.Done = batch.Done
+
+			if err := save(p); err != nil {
+				return fmt.Errorf("failed to save progress: %v", err)
+			}
+
+			glog.V(1).Infof("%s: examined %d rows, updated %d, cursor %q", step.name, sp.Examined, sp.Updated, sp.Cursor)
+
+			if !sp.Done && opts.pause > 0 {
+				select {
+				case <-opts.stop:
+				case <-time.After(opts.pause):
+				}
+			}
+		}
+
+		glog.Infof("%s is done: examined %d rows, updated %d", step.name, sp.Examined, sp.Updated)
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/trillian/storage/mysql"
+)
+
+// fakeStep backfills rows 0 to rows-1, failing once it reaches failAt if that's positive.
+type fakeStep struct {
+	rows    int
+	failAt  int
+	cursors []string
+}
+
+func (f *fakeStep) batch(cursor string, limit int) (mysql.BackfillBatch, error) {
+	f.cursors = append(f.cursors, cursor)
+	start := 0
+
+	if len(cursor) > 0 {
+		start, _ = strconv.Atoi(cursor)
+	}
+
+	if f.failAt > 0 && start >= f.failAt {
+		return mysql.BackfillBatch{}, errors.New("STORAGE")
+	}
+
+	if start >= f.rows {
+		return mysql.BackfillBatch{Cursor: cursor, Done: true}, nil
+	}
+
+	end := start + limit
+	if end > f.rows {
+		end = f.rows
+	}
+
+	return mysql.BackfillBatch{Cursor: strconv.Itoa(end), Examined: end - start, Updated: end - start - 1}, nil
+}
+
+func TestRunBackfill(t *testing.T) {
+	first := &fakeStep{rows: 5}
+	second := &fakeStep{rows: 2}
+	p := &progress{TreeID: 1, Steps: make(map[string]*stepProgress)}
+	saves := 0
+
+	err := runBackfill([]backfillStep{{name: "first", batch: first.batch}, {name: "second", batch: second.batch}}, p, backfillOptions{batchSize: 2}, func(*progress) error {
+		saves++
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("runBackfill()=%v", err)
+	}
+
+	if got, want := first.cursors, []string{"", "2", "4", "5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("First step got cursors %v, expected %v", got, want)
+	}
+
+	if got, want := saves, 6; got != want {
+		t.Errorf("Progress saved %d times, expected %d", got, want)
+	}
+
+	want := map[string]*stepProgress{
+		"first":  {Cursor: "5", Examined: 5, Updated: 2, Done: true},
+		"second": {Cursor: "2", Examined: 2, Updated: 1, Done: true},
+	}
+
+	if !reflect.DeepEqual(p.Steps, want) {
+		t.Errorf("Got progress %+v, expected %+v", p.Steps, want)
+	}
+}
+
+func TestRunBackfillResumes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backfill")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "progress.json")
+
+	p, err := loadProgress(path, 1)
+
+	if err != nil {
+		t.Fatalf("loadProgress() of a new file=%v", err)
+	}
+
+	save := func(p *progress) error { return saveProgress(path, p) }
+	failing := &fakeStep{rows: 10, failAt: 4}
+
+	if err := runBackfill([]backfillStep{{name: "step", batch: failing.batch}}, p, backfillOptions{batchSize: 2}, save); err == nil || !strings.Contains(err.Error(), "STORAGE") {
+		t.Fatalf("runBackfill()=%v, expected the step's error", err)
+	}
+
+	if p, err = loadProgress(path, 1); err != nil {
+		t.Fatalf("loadProgress()=%v", err)
+	}
+
+	// Carries on from the last batch that succeeded
+	resumed := &fakeStep{rows: 10}
+
+	if err := runBackfill([]backfillStep{{name: "step", batch: resumed.batch}}, p, backfillOptions{batchSize: 4}, save); err != nil {
+		t.Fatalf("runBackfill() resumed=%v", err)
+	}
+
+	if got, want := resumed.cursors, []string{"4", "8", "10"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Resumed step got cursors %v, expected %v", got, want)
+	}
+
+	if got, want := *p.Steps["step"], (stepProgress{Cursor: "10", Examined: 10, Updated: 6, Done: true}); got != want {
+		t.Errorf("Got progress %+v, expected %+v", got, want)
+	}
+
+	if _, err := loadProgress(path, 2); err == nil {
+		t.Error("loadProgress() of another tree's progress succeeded")
+	}
+}
+
+func TestRunBackfillStops(t *testing.T) {
+	stop := make(chan struct{})
+	close(stop)
+	step := &fakeStep{rows: 10}
+	p := &progress{TreeID: 1, Steps: make(map[string]*stepProgress)}
+
+	if err := runBackfill([]backfillStep{{name: "step", batch: step.batch}}, p, backfillOptions{batchSize: 2, stop: stop}, func(*progress) error { return nil }); err != nil {
+		t.Fatalf("runBackfill()=%v", err)
+	}
+
+	if len(step.cursors) != 0 || p.Steps["step"].Done {
+		t.Errorf("Backfill ran after being stopped, cursors %v", step.cursors)
+	}
+}
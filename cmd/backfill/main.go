@@ -0,0 +1,116 @@
+// The backfill command fills in columns that were added to the storage schema after a log's
+// leaves were stored, so that features relying on them can be enabled for existing logs. The
+// integrate_timestamps step sets SequencedLeafData.IntegrateTimestampNanos, which
+// GetLeavesByTimestamp needs, to the timestamp of the first root that included each leaf. The
+// extra_data_blobs step moves ExtraData larger than --extra_data_blob_threshold into the blob
+// store in --extra_data_blob_dir, as the log server does for new leaves.
+//
+// Progress is saved to --progress_file after every batch. If the command is interrupted, or
+// fails, running it again with the same file carries on from the last batch. The log server can
+// keep running while the log is backfilled. New columns should get a step here when they're
+// added.
+package main
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/golang/glog"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/mysql"
+)
+
+var mysqlURIFlag = flag.String("mysql_uri", "test:zaphod@tcp(127.0.0.1:3306)/test", "URI of the MySQL database holding the log")
+var treeIDFlag = flag.Int64("tree_id", 0, "The tree ID of the log to backfill")
+var stepsFlag = flag.String("steps", "integrate_timestamps", "Comma separated list of the steps to run, in order: integrate_timestamps, extra_data_blobs")
+var progressFileFlag = flag.String("progress_file", "", "File to save progress in so that the backfill can be resumed, it's created if it doesn't exist")
+var batchSizeFlag = flag.Int("batch_size", 1000, "Max number of rows to backfill in one transaction")
+var batchPauseFlag = flag.Duration("batch_pause", 0, "Time to wait between batches, to limit the load on a database that's serving")
+var extraDataBlobDirFlag = flag.String("extra_data_blob_dir", "", "Directory of the blob store for the extra_data_blobs step, must be the one the log server uses")
+var extraDataBlobThresholdFlag = flag.Int("extra_data_blob_threshold", 4096, "ExtraData larger than this many bytes is moved to the blob store by the extra_data_blobs step")
+
+// buildSteps returns the steps named in --steps.
+func buildSteps(b *mysql.Backfiller) []backfillStep {
+	var steps []backfillStep
+
+	for _, name := range strings.Split(*stepsFlag, ",") {
+		switch name {
+		case "integrate_timestamps":
+			steps = append(steps, backfillStep{name: name, batch: b.BackfillIntegrateTimestamps})
+		case "extra_data_blobs":
+			if len(*extraDataBlobDirFlag) == 0 {
+				glog.Fatal("The extra_data_blobs step needs --extra_data_blob_dir")
+			}
+
+			blobs, err := storage.NewFileBlobStore(*extraDataBlobDirFlag)
+
+			if err != nil {
+				glog.Fatalf("Failed to create blob store: %v", err)
+			}
+
+			steps = append(steps, backfillStep{name: name, batch: func(cursor string, limit int) (mysql.BackfillBatch, error) {
+				return b.BackfillExtraDataBlobs(cursor, limit, blobs, *extraDataBlobThresholdFlag)
+			}})
+		default:
+			glog.Fatalf("Unknown step: %q", name)
+		}
+	}
+
+	return steps
+}
+
+func main() {
+	flag.Parse()
+
+	if *treeIDFlag == 0 {
+		glog.Fatal("--tree_id must be set")
+	}
+
+	if len(*progressFileFlag) == 0 {
+		glog.Fatal("--progress_file must be set")
+	}
+
+	if *batchSizeFlag <= 0 {
+		glog.Fatalf("--batch_size must be positive, got: %d", *batchSizeFlag)
+	}
+
+	p, err := loadProgress(*progressFileFlag, *treeIDFlag)
+
+	if err != nil {
+		glog.Fatalf("Failed to load progress: %v", err)
+	}
+
+	b, err := mysql.NewBackfiller(*mysqlURIFlag, *treeIDFlag)
+
+	if err != nil {
+		glog.Fatalf("Failed to open database: %v", err)
+	}
+
+	defer b.Close()
+
+	// Finish the current batch and save progress when interrupted
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+
+	opts := backfillOptions{batchSize: *batchSizeFlag, pause: *batchPauseFlag, stop: stop}
+	start := time.Now()
+
+	if err := runBackfill(buildSteps(b), p, opts, func(p *progress) error {
+		return saveProgress(*progressFileFlag, p)
+	}); err != nil {
+		b.Close()
+		glog.Fatalf("Backfill of tree %d failed, it can be resumed: %v", *treeIDFlag, err)
+	}
+
+	glog.Infof("Backfill of tree %d stopped after %v", *treeIDFlag, time.Since(start))
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	gocrypto "crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/examples/ct"
+)
+
+// logConfig describes a log to monitor.
+type logConfig struct {
+	// Name identifies the log in alerts and names its file in the state directory
+	Name string `json:"name"`
+	// URL is where the log is served, its endpoints are under URL/ct/v1/
+	URL string `json:"url"`
+	// PublicKey is a PEM file containing the log's public key
+	PublicKey string `json:"public_key"`
+	// SignatureHash and RSAPSS must match how the log signs, see ct.SignatureOptions. They're
+	// only needed for logs that don't sign as RFC 6962 clients expect.
+	SignatureHash string `json:"signature_hash"`
+	RSAPSS        bool   `json:"rsa_pss"`
+}
+
+// logNamePattern restricts names to ones that are safe to use as file names
+var logNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// loadLogConfigs reads and validates a JSON array of logConfig from a file.
+func loadLogConfigs(path string) ([]logConfig, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parseLogConfigs(data)
+}
+
+// parseLogConfigs parses and validates a JSON array of logConfig. Every log needs a unique
+// name, a URL and a public key.
+func parseLogConfigs(data []byte) ([]logConfig, error) {
+	var configs []logConfig
+
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse log configs: %v", err)
+	}
+
+	if len(configs) == 0 {
+		return nil, errors.New("no logs configured")
+	}
+
+	names := make(map[string]bool)
+
+	for i, config := range configs {
+		if !logNamePattern.MatchString(config.Name) || config.Name == "." || config.Name == ".." {
+			return nil, fmt.Errorf("log %d has an invalid name: %q", i, config.Name)
+		}
+
+		if names[config.Name] {
+			return nil, fmt.Errorf("log name %q is used more than once", config.Name)
+		}
+
+		names[config.Name] = true
+
+		if !strings.HasPrefix(config.URL, "http://") && !strings.HasPrefix(config.URL, "https://") {
+			return nil, fmt.Errorf("log %q has an invalid URL: %q", config.Name, config.URL)
+		}
+
+		if len(config.PublicKey) == 0 {
+			return nil, fmt.Errorf("log %q has no public key", config.Name)
+		}
+
+		if _, err := ct.ParseSignatureHash(config.SignatureHash); err != nil {
+			return nil, fmt.Errorf("log %q: %v", config.Name, err)
+		}
+	}
+
+	return configs, nil
+}
+
+// signatureOptions returns how the log signs its STHs.
+func (c logConfig) signatureOptions() (ct.SignatureOptions, error) {
+	hash, err := ct.ParseSignatureHash(c.SignatureHash)
+
+	if err != nil {
+		return ct.SignatureOptions{}, err
+	}
+
+	return ct.SignatureOptions{Hash: hash, RSAPSS: c.RSAPSS}, nil
+}
+
+// loadPublicKey reads the log's public key.
+func (c logConfig) loadPublicKey() (gocrypto.PublicKey, error) {
+	pemData, err := ioutil.ReadFile(c.PublicKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	km := crypto.NewPEMKeyManager()
+
+	if err := km.LoadPublicKey(string(pemData)); err != nil {
+		return nil, err
+	}
+
+	return km.GetPublicKey()
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLogConfigs(t *testing.T) {
+	for _, test := range []struct {
+		desc    string
+		json    string
+		wantErr string
+	}{
+		{desc: "valid", json: `[{"name": "pilot", "url": "https://ct.example.com/pilot", "public_key": "pilot.pem"}, {"name": "rocketeer", "url": "http://localhost:6962", "public_key": "rocketeer.pem", "signature_hash": "sha384"}]`},
+		{desc: "not JSON", json: `{`, wantErr: "failed to parse"},
+		{desc: "empty", json: `[]`, wantErr: "no logs"},
+		{desc: "no name", json: `[{"url": "https://ct.example.com", "public_key": "key.pem"}]`, wantErr: "invalid name"},
+		{desc: "path as name", json: `[{"name": "../pilot", "url": "https://ct.example.com", "public_key": "key.pem"}]`, wantErr: "invalid name"},
+		{desc: "dots as name", json: `[{"name": "..", "url": "https://ct.example.com", "public_key": "key.pem"}]`, wantErr: "invalid name"},
+		{desc: "duplicate name", json: `[{"name": "pilot", "url": "https://a.example.com", "public_key": "a.pem"}, {"name": "pilot", "url": "https://b.example.com", "public_key": "b.pem"}]`, wantErr: "more than once"},
+		{desc: "no scheme", json: `[{"name": "pilot", "url": "ct.example.com", "public_key": "key.pem"}]`, wantErr: "invalid URL"},
+		{desc: "no key", json: `[{"name": "pilot", "url": "https://ct.example.com"}]`, wantErr: "no public key"},
+		{desc: "bad hash", json: `[{"name": "pilot", "url": "https://ct.example.com", "public_key": "key.pem", "signature_hash": "md5"}]`, wantErr: "unsupported signature hash"},
+	} {
+		configs, err := parseLogConfigs([]byte(test.json))
+
+		if len(test.wantErr) == 0 {
+			if err != nil || len(configs) != 2 {
+				t.Errorf("%s: parseLogConfigs()=%v, %v, expected 2 configs", test.desc, configs, err)
+			}
+
+			continue
+		}
+
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: parseLogConfigs()=%v, expected an error containing %q", test.desc, err, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// The ctmonitor command watches CT logs, such as ones served by examples/ct, for misbehaviour.
+// It polls each log's get-sth, verifies the STH signatures with the log's key and checks with
+// get-sth-consistency that each new tree is an append only extension of the last one it saw.
+// Verified STHs are stored so that checking carries on across restarts. Violations are
+// logged, sent to a webhook if one is configured and served on /status. With --once every log
+// is checked a single time and the exit status says whether they all passed, so it can drive
+// integration tests.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/util"
+)
+
+var logsConfigFlag = flag.String("logs_config", "", "File containing a JSON array of the logs to monitor, each with a name, url, public_key and optionally signature_hash and rsa_pss")
+var stateDirFlag = flag.String("state_dir", "", "If set, the directory where the STHs seen for each log are stored, so checking carries on from them after a restart. If not set they're only kept in memory")
+var pollIntervalFlag = flag.Duration("poll_interval", time.Minute, "How often each log's STH is fetched")
+var requestTimeoutFlag = flag.Duration("request_timeout", time.Second*30, "Timeout for requests to the logs")
+var alertWebhookFlag = flag.String("alert_webhook", "", "If set, a URL that each violation is POSTed to as JSON")
+var httpEndpointFlag = flag.String("http_endpoint", "localhost:6966", "Address to serve /status on, empty to not serve it")
+var onceFlag = flag.Bool("once", false, "If true, check each log once and exit with status 1 if any of them failed or violated the checks")
+
+// webhookAlerter returns an alerter that POSTs violations to url
+func webhookAlerter(url string, client *http.Client) alerter {
+	return func(v violation) {
+		data, err := json.Marshal(v)
+
+		if err != nil {
+			glog.Warningf("Failed to marshal violation for webhook: %v", err)
+			return
+		}
+
+		resp, err := client.Post(url, "application/json", bytes.NewReader(data))
+
+		if err != nil {
+			glog.Warningf("Failed to send violation to webhook: %v", err)
+			return
+		}
+
+		resp.Body.Close()
+
+		if resp.StatusCode/100 != 2 {
+			glog.Warningf("Webhook returned %d for violation", resp.StatusCode)
+		}
+	}
+}
+
+func createMonitors(client *http.Client) ([]*logMonitor, error) {
+	configs, err := loadLogConfigs(*logsConfigFlag)
+
+	if err != nil {
+		return nil, err
+	}
+
+	alert := logAlerter
+
+	if len(*alertWebhookFlag) > 0 {
+		webhook := webhookAlerter(*alertWebhookFlag, client)
+		alert = func(v violation) {
+			logAlerter(v)
+			webhook(v)
+		}
+	}
+
+	var monitors []*logMonitor
+
+	for _, config := range configs {
+		publicKey, err := config.loadPublicKey()
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to load public key of log %q: %v", config.Name, err)
+		}
+
+		monitor, err := newLogMonitor(config, publicKey, client, sthStore{dir: *stateDirFlag}, alert, util.SystemTimeSource{})
+
+		if err != nil {
+			return nil, err
+		}
+
+		monitors = append(monitors, monitor)
+	}
+
+	return monitors, nil
+}
+
+// checkOnce polls every log and returns false if any of them couldn't be checked or
+// violated the checks.
+func checkOnce(monitors []*logMonitor) bool {
+	ok := true
+
+	for _, monitor := range monitors {
+		violations, err := monitor.poll()
+
+		if err != nil {
+			glog.Errorf("Failed to check log %q: %v", monitor.config.Name, err)
+			ok = false
+		}
+
+		if len(violations) > 0 {
+			ok = false
+		}
+	}
+
+	return ok
+}
+
+// pollForever polls a log every interval.
+func pollForever(monitor *logMonitor, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := monitor.poll(); err != nil {
+			glog.Warningf("Failed to check log %q: %v", monitor.config.Name, err)
+		}
+
+		<-ticker.C
+	}
+}
+
+// statusHandler serves the status of every log as JSON
+func statusHandler(monitors []*logMonitor) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		statuses := make([]logStatus, 0, len(monitors))
+
+		for _, monitor := range monitors {
+			statuses = append(statuses, monitor.Status())
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(statuses); err != nil {
+			glog.Warningf("Failed to write status: %v", err)
+		}
+	})
+}
+
+func main() {
+	flag.Parse()
+
+	if len(*stateDirFlag) > 0 {
+		if err := os.MkdirAll(*stateDirFlag, 0755); err != nil {
+			glog.Fatalf("Failed to create state directory: %v", err)
+		}
+	}
+
+	client := &http.Client{Timeout: *requestTimeoutFlag}
+	monitors, err := createMonitors(client)
+
+	if err != nil {
+		glog.Fatalf("Failed to set up monitors: %v", err)
+	}
+
+	if *onceFlag {
+		if !checkOnce(monitors) {
+			glog.Flush()
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	if len(*httpEndpointFlag) > 0 {
+		http.Handle("/status", statusHandler(monitors))
+
+		go func() {
+			glog.Warningf("Status server exited: %v", http.ListenAndServe(*httpEndpointFlag, nil))
+		}()
+	}
+
+	var wg sync.WaitGroup
+
+	for _, monitor := range monitors {
+		wg.Add(1)
+
+		go func(monitor *logMonitor) {
+			defer wg.Done()
+			pollForever(monitor, *pollIntervalFlag)
+		}(monitor)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	gocrypto "crypto"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/examples/ct"
+	"github.com/google/trillian/examples/ct/ctapi"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/util"
+)
+
+// The kinds of violation that the monitor alerts on. Each is evidence that the log has
+// misbehaved, or that its key or the monitor's view of it has been tampered with.
+const (
+	// violationSignature is an STH whose signature doesn't verify with the log's key
+	violationSignature = "bad_signature"
+	// violationTreeShrank is an STH for a smaller tree than one seen before
+	violationTreeShrank = "tree_shrank"
+	// violationTimestamp is an STH with an earlier timestamp than one seen before
+	violationTimestamp = "timestamp_went_backwards"
+	// violationRootChanged is an STH with the same tree size as one seen before but a
+	// different root hash
+	violationRootChanged = "root_hash_changed"
+	// violationInconsistent is an STH that the log can't prove is an append only extension
+	// of the one seen before it
+	violationInconsistent = "inconsistent"
+)
+
+// maxRecentViolations is the number of violations kept for each log's status
+const maxRecentViolations = 100
+
+// violation is an alert about a log
+type violation struct {
+	Log    string    `json:"log"`
+	Kind   string    `json:"kind"`
+	Detail string    `json:"detail"`
+	Time   time.Time `json:"time"`
+	// STH is the STH that showed the problem
+	STH ctapi.GetSTHResponse `json:"sth"`
+}
+
+// alerter is told about each violation as it's found
+type alerter func(violation)
+
+// logStatus is what the monitor knows about a log, as served on /status
+type logStatus struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Latest is the latest verified STH
+	Latest *observedSTH `json:"latest,omitempty"`
+	// LastPoll is when the log was last polled and LastError why it failed, if it did. Failing
+	// to reach the log isn't a violation.
+	LastPoll  time.Time `json:"last_poll"`
+	LastError string    `json:"last_error,omitempty"`
+	// ViolationCount is the number of violations found since the monitor started, Violations
+	// are the most recent of them
+	ViolationCount int64       `json:"violation_count"`
+	Violations     []violation `json:"violations"`
+}
+
+// logMonitor polls one log for new STHs and checks them against the ones it has seen before.
+// It is safe for concurrent use but only one poll runs at a time.
+type logMonitor struct {
+	config     logConfig
+	publicKey  gocrypto.PublicKey
+	opts       ct.SignatureOptions
+	httpClient *http.Client
+	hasher     merkle.TreeHasher
+	store      sthStore
+	alert      alerter
+	timeSource util.TimeSource
+
+	// pollMu is held while polling so polls don't overlap
+	pollMu sync.Mutex
+	// mu guards status
+	mu     sync.Mutex
+	status logStatus
+}
+
+// newLogMonitor creates a monitor for a log that carries on from the latest STH in store.
+func newLogMonitor(config logConfig, publicKey gocrypto.PublicKey, httpClient *http.Client, store sthStore, alert alerter, timeSource util.TimeSource) (*logMonitor, error) {
+	opts, err := config.signatureOptions()
+
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := store.latest(config.Name)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stored STHs of log %q: %v", config.Name, err)
+	}
+
+	return &logMonitor{
+		config:     config,
+		publicKey:  publicKey,
+		opts:       opts,
+		httpClient: httpClient,
+		hasher:     merkle.NewRFC6962TreeHasher(trillian.NewSHA256()),
+		store:      store,
+		alert:      alert,
+		timeSource: timeSource,
+		status:     logStatus{Name: config.Name, URL: config.URL, Latest: latest, Violations: []violation{}}}, nil
+}
+
+// poll fetches the log's STH and checks it. It returns the violations found, which have also
+// been alerted on, or an error if the log couldn't be checked.
+func (m *logMonitor) poll() ([]violation, error) {
+	m.pollMu.Lock()
+	defer m.pollMu.Unlock()
+
+	violations, err := m.check()
+	now := m.timeSource.Now()
+
+	m.mu.Lock()
+	m.status.LastPoll = now
+	m.status.LastError = ""
+
+	if err != nil {
+		m.status.LastError = err.Error()
+	}
+
+	m.status.ViolationCount += int64(len(violations))
+	m.status.Violations = append(m.status.Violations, violations...)
+
+	if excess := len(m.status.Violations) - maxRecentViolations; excess > 0 {
+		m.status.Violations = m.status.Violations[excess:]
+	}
+
+	m.mu.Unlock()
+
+	for _, v := range violations {
+		m.alert(v)
+	}
+
+	return violations, err
+}
+
+// check does the work of poll. A new STH is stored if it passes all the checks.
+func (m *logMonitor) check() ([]violation, error) {
+	var sth ctapi.GetSTHResponse
+
+	if err := m.getJSON("get-sth", nil, &sth); err != nil {
+		return nil, err
+	}
+
+	if err := ct.VerifySTHResponse(m.publicKey, m.opts, sth); err != nil {
+		return []violation{m.violation(violationSignature, sth, "%v", err)}, nil
+	}
+
+	m.mu.Lock()
+	latest := m.status.Latest
+	m.mu.Unlock()
+
+	if latest != nil {
+		violations, err := m.compare(latest.STH, sth)
+
+		if err != nil || len(violations) > 0 {
+			return violations, err
+		}
+
+		if latest.STH.TimestampMillis == sth.TimestampMillis {
+			// Nothing new
+			return nil, nil
+		}
+	}
+
+	observed := &observedSTH{STH: sth, Observed: m.timeSource.Now()}
+
+	if err := m.store.append(m.config.Name, *observed); err != nil {
+		return nil, fmt.Errorf("failed to store STH: %v", err)
+	}
+
+	m.mu.Lock()
+	m.status.Latest = observed
+	m.mu.Unlock()
+
+	return nil, nil
+}
+
+// compare checks a verified STH against the latest one seen before it, fetching a consistency
+// proof from the log if the tree has grown.
+func (m *logMonitor) compare(latest, sth ctapi.GetSTHResponse) ([]violation, error) {
+	var violations []violation
+
+	if sth.TimestampMillis < latest.TimestampMillis {
+		violations = append(violations, m.violation(violationTimestamp, sth, "timestamp %d is before %d", sth.TimestampMillis, latest.TimestampMillis))
+	}
+
+	switch {
+	case sth.TreeSize < latest.TreeSize:
+		violations = append(violations, m.violation(violationTreeShrank, sth, "tree size %d is smaller than %d", sth.TreeSize, latest.TreeSize))
+
+	case sth.TreeSize == latest.TreeSize:
+		if !bytes.Equal(sth.RootHash, latest.RootHash) {
+			violations = append(violations, m.violation(violationRootChanged, sth, "root hash %x for tree size %d was %x", sth.RootHash, sth.TreeSize, latest.RootHash))
+		}
+
+	case latest.TreeSize > 0:
+		params := url.Values{
+			"first":  {strconv.FormatInt(latest.TreeSize, 10)},
+			"second": {strconv.FormatInt(sth.TreeSize, 10)}}
+		var proof ctapi.GetSTHConsistencyResponse
+
+		if err := m.getJSON("get-sth-consistency", params, &proof); err != nil {
+			return violations, err
+		}
+
+		if err := merkle.VerifyConsistencyProof(m.hasher, latest.TreeSize, sth.TreeSize, latest.RootHash, sth.RootHash, proof.Consistency); err != nil {
+			violations = append(violations, m.violation(violationInconsistent, sth, "tree size %d isn't consistent with tree size %d: %v", sth.TreeSize, latest.TreeSize, err))
+		}
+	}
+
+	return violations, nil
+}
+
+func (m *logMonitor) violation(kind string, sth ctapi.GetSTHResponse, format string, args ...interface{}) violation {
+	return violation{Log: m.config.Name, Kind: kind, Detail: fmt.Sprintf(format, args...), Time: m.timeSource.Now(), STH: sth}
+}
+
+// getJSON makes a request to one of the log's endpoints and decodes the JSON response into v
+func (m *logMonitor) getJSON(endpoint string, params url.Values, v interface{}) error {
+	u := strings.TrimSuffix(m.config.URL, "/") + "/ct/v1/" + endpoint
+
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+
+	resp, err := m.httpClient.Get(u)
+
+	if err != nil {
+		return fmt.Errorf("%s failed: %v", endpoint, err)
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		return fmt.Errorf("failed to read %s response: %v", endpoint, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d: %s", endpoint, resp.StatusCode, body)
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse %s response: %v", endpoint, err)
+	}
+
+	return nil
+}
+
+// Status returns what the monitor knows about the log.
+func (m *logMonitor) Status() logStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := m.status
+	status.Violations = append([]violation{}, m.status.Violations...)
+
+	return status
+}
+
+// logAlerter is an alerter that writes violations to the error log
+func logAlerter(v violation) {
+	glog.Errorf("VIOLATION by log %q: %s: %s", v.Log, v.Kind, v.Detail)
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	gocrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency/go"
+	"github.com/google/trillian"
+	"github.com/google/trillian/examples/ct/ctapi"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/util"
+)
+
+var fakeTime = time.Date(2017, 3, 14, 15, 9, 26, 0, time.UTC)
+
+// fakeLog serves get-sth and get-sth-consistency for a tree held in memory
+type fakeLog struct {
+	t    *testing.T
+	key  *ecdsa.PrivateKey
+	tree *merkle.InMemoryMerkleTree
+	// sth is served by get-sth
+	sth ctapi.GetSTHResponse
+	// fail makes every request fail
+	fail bool
+}
+
+func newFakeLog(t *testing.T) *fakeLog {
+	return &fakeLog{t: t, key: generateKey(t), tree: merkle.NewInMemoryMerkleTree(merkle.NewRFC6962TreeHasher(trillian.NewSHA256()))}
+}
+
+func generateKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	return key
+}
+
+// grow adds leaves to the tree
+func (f *fakeLog) grow(leaves int) {
+	for i := 0; i < leaves; i++ {
+		f.tree.AddLeaf([]byte(fmt.Sprintf("leaf %d", f.tree.LeafCount())))
+	}
+}
+
+// signSTH returns an STH for a tree size and root signed with key the way examples/ct signs
+// them for an ECDSA key
+func signSTH(t *testing.T, key *ecdsa.PrivateKey, treeSize, timestamp int64, rootHash []byte) ctapi.GetSTHResponse {
+	sth := ct.SignedTreeHead{TreeSize: uint64(treeSize), Timestamp: uint64(timestamp)}
+	copy(sth.SHA256RootHash[:], rootHash)
+
+	input, err := ct.SerializeSTHSignatureInput(sth)
+
+	if err != nil {
+		t.Fatalf("Failed to serialize STH: %v", err)
+	}
+
+	digest := sha256.Sum256(input)
+	signature, err := key.Sign(rand.Reader, digest[:], gocrypto.SHA256)
+
+	if err != nil {
+		t.Fatalf("Failed to sign STH: %v", err)
+	}
+
+	return ctapi.GetSTHResponse{TreeSize: treeSize, TimestampMillis: timestamp, RootHash: rootHash, Signature: signature}
+}
+
+// publish makes get-sth serve the current tree with timestamp
+func (f *fakeLog) publish(timestamp int64) {
+	f.sth = signSTH(f.t, f.key, int64(f.tree.LeafCount()), timestamp, f.tree.CurrentRoot().Hash())
+}
+
+func (f *fakeLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.fail {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	var resp interface{}
+
+	switch r.URL.Path {
+	case "/ct/v1/get-sth":
+		resp = f.sth
+
+	case "/ct/v1/get-sth-consistency":
+		first, err1 := strconv.Atoi(r.FormValue("first"))
+		second, err2 := strconv.Atoi(r.FormValue("second"))
+
+		if err1 != nil || err2 != nil || first <= 0 || second > f.tree.LeafCount() || first > second {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		var proof ctapi.GetSTHConsistencyResponse
+
+		for _, node := range f.tree.SnapshotConsistency(first, second) {
+			proof.Consistency = append(proof.Consistency, node.Value.Hash())
+		}
+
+		resp = proof
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+// newTestMonitor returns a monitor of log that records alerts in alerts
+func newTestMonitor(t *testing.T, log *fakeLog, url string, store sthStore, alerts *[]violation) *logMonitor {
+	config := logConfig{Name: "test", URL: url}
+	alert := func(v violation) {
+		*alerts = append(*alerts, v)
+	}
+
+	monitor, err := newLogMonitor(config, log.key.Public(), &http.Client{}, store, alert, &util.FakeTimeSource{FakeTime: fakeTime})
+
+	if err != nil {
+		t.Fatalf("newLogMonitor()=%v", err)
+	}
+
+	return monitor
+}
+
+func TestMonitorFollowsGrowingLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ctmonitor")
+
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	log := newFakeLog(t)
+	server := httptest.NewServer(log)
+	defer server.Close()
+
+	var alerts []violation
+	monitor := newTestMonitor(t, log, server.URL, sthStore{dir: dir}, &alerts)
+
+	// The first STH is trusted, later growth is checked with consistency proofs
+	for i, grow := range []int{3, 4, 0, 9} {
+		log.grow(grow)
+		log.publish(int64(i+1) * 1000)
+
+		if violations, err := monitor.poll(); len(violations) > 0 || err != nil {
+			t.Fatalf("poll() after adding %d leaves=%v, %v, expected no violations", grow, violations, err)
+		}
+
+		if got, want := monitor.Status().Latest.STH, log.sth; !reflect.DeepEqual(got, want) {
+			t.Fatalf("after adding %d leaves latest STH is %+v, expected %+v", grow, got, want)
+		}
+	}
+
+	// Polling again without a new STH doesn't store anything
+	if violations, err := monitor.poll(); len(violations) > 0 || err != nil {
+		t.Fatalf("poll() of an unchanged log=%v, %v, expected no violations", violations, err)
+	}
+
+	if len(alerts) > 0 {
+		t.Errorf("Got alerts %v, expected none", alerts)
+	}
+
+	// A new monitor carries on from the stored STHs
+	restarted := newTestMonitor(t, log, server.URL, sthStore{dir: dir}, &alerts)
+
+	if got, want := restarted.Status().Latest.STH, log.sth; !reflect.DeepEqual(got, want) {
+		t.Errorf("restarted monitor's latest STH is %+v, expected %+v", got, want)
+	}
+
+	data, err := ioutil.ReadFile(sthStore{dir: dir}.path("test"))
+
+	if err != nil {
+		t.Fatalf("Failed to read stored STHs: %v", err)
+	}
+
+	if got, want := countLines(data), 4; got != want {
+		t.Errorf("Got %d stored STHs, expected %d", got, want)
+	}
+}
+
+func countLines(data []byte) int {
+	lines := 0
+
+	for _, b := range data {
+		if b == '\n' {
+			lines++
+		}
+	}
+
+	return lines
+}
+
+func TestMonitorViolations(t *testing.T) {
+	log := newFakeLog(t)
+	server := httptest.NewServer(log)
+	defer server.Close()
+
+	log.grow(7)
+	root7 := log.tree.CurrentRoot().Hash()
+	good := signSTH(t, log.key, 7, 2000, root7)
+
+	// The log can prove that it grew to 10 leaves, but not to the root of another tree
+	log.grow(3)
+
+	other := merkle.NewInMemoryMerkleTree(merkle.NewRFC6962TreeHasher(trillian.NewSHA256()))
+	for i := 0; i < 10; i++ {
+		other.AddLeaf([]byte(fmt.Sprintf("other leaf %d", i)))
+	}
+
+	for _, test := range []struct {
+		desc string
+		sth  ctapi.GetSTHResponse
+		want []string
+	}{
+		{desc: "wrong key", sth: signSTH(t, generateKey(t), 7, 3000, root7), want: []string{violationSignature}},
+		{desc: "smaller tree", sth: signSTH(t, log.key, 3, 3000, log.tree.RootAtSnapshot(3).Hash()), want: []string{violationTreeShrank}},
+		{desc: "earlier timestamp", sth: signSTH(t, log.key, 7, 1000, root7), want: []string{violationTimestamp}},
+		{desc: "different root", sth: signSTH(t, log.key, 7, 3000, other.RootAtSnapshot(7).Hash()), want: []string{violationRootChanged}},
+		{desc: "consistent", sth: signSTH(t, log.key, 10, 3000, log.tree.CurrentRoot().Hash())},
+		{desc: "inconsistent", sth: signSTH(t, log.key, 10, 3000, other.CurrentRoot().Hash()), want: []string{violationInconsistent}},
+		{desc: "smaller tree and earlier timestamp", sth: signSTH(t, log.key, 3, 1000, log.tree.RootAtSnapshot(3).Hash()), want: []string{violationTimestamp, violationTreeShrank}},
+	} {
+		var alerts []violation
+		monitor := newTestMonitor(t, log, server.URL, sthStore{}, &alerts)
+
+		log.sth = good
+		if violations, err := monitor.poll(); len(violations) > 0 || err != nil {
+			t.Fatalf("%s: poll() of the good STH=%v, %v, expected no violations", test.desc, violations, err)
+		}
+
+		log.sth = test.sth
+		violations, err := monitor.poll()
+
+		if err != nil {
+			t.Errorf("%s: poll()=%v", test.desc, err)
+			continue
+		}
+
+		var kinds []string
+		for _, v := range violations {
+			kinds = append(kinds, v.Kind)
+		}
+
+		if !reflect.DeepEqual(kinds, test.want) {
+			t.Errorf("%s: got violations %v, expected %v", test.desc, violations, test.want)
+		}
+
+		if !reflect.DeepEqual(alerts, violations) {
+			t.Errorf("%s: got alerts %v, expected %v", test.desc, alerts, violations)
+		}
+
+		// An STH that fails the checks doesn't replace the latest one
+		wantLatest := test.sth
+		if len(test.want) > 0 {
+			wantLatest = good
+		}
+
+		status := monitor.Status()
+
+		if !reflect.DeepEqual(status.Latest.STH, wantLatest) || status.ViolationCount != int64(len(test.want)) {
+			t.Errorf("%s: got status %+v, expected latest STH %+v and %d violations", test.desc, status, wantLatest, len(test.want))
+		}
+	}
+}
+
+func TestMonitorLogUnavailable(t *testing.T) {
+	log := newFakeLog(t)
+	server := httptest.NewServer(log)
+	defer server.Close()
+
+	var alerts []violation
+	monitor := newTestMonitor(t, log, server.URL, sthStore{}, &alerts)
+	log.fail = true
+
+	// Not being able to reach the log isn't evidence of misbehaviour
+	if violations, err := monitor.poll(); len(violations) > 0 || err == nil {
+		t.Fatalf("poll() of an unavailable log=%v, %v, expected an error and no violations", violations, err)
+	}
+
+	if status := monitor.Status(); len(status.LastError) == 0 || status.Latest != nil {
+		t.Errorf("Got status %+v, expected the error to be recorded", status)
+	}
+
+	log.fail = false
+	log.grow(2)
+	log.publish(1000)
+
+	if _, err := monitor.poll(); err != nil {
+		t.Fatalf("poll()=%v", err)
+	}
+
+	if status := monitor.Status(); len(status.LastError) > 0 {
+		t.Errorf("Got status %+v, expected the error to be cleared", status)
+	}
+}
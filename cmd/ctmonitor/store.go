@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/trillian/examples/ct/ctapi"
+)
+
+// observedSTH is an STH that has been verified, as written to the state directory
+type observedSTH struct {
+	STH ctapi.GetSTHResponse `json:"sth"`
+	// Observed is when the monitor first saw it
+	Observed time.Time `json:"observed"`
+}
+
+// sthStore keeps the STHs observed for each log in a directory, one file per log holding a
+// JSON object per line, so that a restarted monitor carries on checking from where it was
+// and the history of each log is available for later investigation. A store with an empty
+// directory keeps nothing.
+type sthStore struct {
+	dir string
+}
+
+func (s sthStore) path(logName string) string {
+	return filepath.Join(s.dir, logName+".sths")
+}
+
+// latest returns the last STH stored for a log, or nil if there isn't one.
+func (s sthStore) latest(logName string) (*observedSTH, error) {
+	if len(s.dir) == 0 {
+		return nil, nil
+	}
+
+	f, err := os.Open(s.path(logName))
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var last *observedSTH
+	scanner := bufio.NewScanner(f)
+
+	for line := 1; scanner.Scan(); line++ {
+		var observed observedSTH
+
+		if err := json.Unmarshal(scanner.Bytes(), &observed); err != nil {
+			return nil, fmt.Errorf("%s line %d: %v", s.path(logName), line, err)
+		}
+
+		last = &observed
+	}
+
+	return last, scanner.Err()
+}
+
+// append adds an STH to the end of a log's history.
+func (s sthStore) append(logName string, observed observedSTH) error {
+	if len(s.dir) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(observed)
+
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.path(logName), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/google/trillian/examples/ct/ctapi"
+)
+
+func TestSTHStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ctmonitor")
+
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	store := sthStore{dir: dir}
+
+	if latest, err := store.latest("pilot"); latest != nil || err != nil {
+		t.Fatalf("latest() of an unknown log=%v, %v, expected nil, nil", latest, err)
+	}
+
+	sths := []observedSTH{
+		{STH: ctapi.GetSTHResponse{TreeSize: 3, TimestampMillis: 1000, RootHash: []byte("root3"), Signature: []byte("sig3")}, Observed: fakeTime},
+		{STH: ctapi.GetSTHResponse{TreeSize: 7, TimestampMillis: 2000, RootHash: []byte("root7"), Signature: []byte("sig7")}, Observed: fakeTime.Add(1)},
+	}
+
+	for _, sth := range sths {
+		if err := store.append("pilot", sth); err != nil {
+			t.Fatalf("append()=%v", err)
+		}
+	}
+
+	latest, err := store.latest("pilot")
+
+	if err != nil || !reflect.DeepEqual(latest, &sths[1]) {
+		t.Errorf("latest()=%+v, %v, expected %+v", latest, err, sths[1])
+	}
+
+	// Logs are kept apart
+	if latest, err := store.latest("rocketeer"); latest != nil || err != nil {
+		t.Errorf("latest() of another log=%v, %v, expected nil, nil", latest, err)
+	}
+
+	if err := ioutil.WriteFile(store.path("corrupt"), []byte("{\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if _, err := store.latest("corrupt"); err == nil {
+		t.Error("latest() of a corrupt file succeeded")
+	}
+}
+
+func TestSTHStoreWithoutDir(t *testing.T) {
+	var store sthStore
+
+	if err := store.append("pilot", observedSTH{}); err != nil {
+		t.Fatalf("append()=%v", err)
+	}
+
+	if latest, err := store.latest("pilot"); latest != nil || err != nil {
+		t.Errorf("latest()=%v, %v, expected nil, nil", latest, err)
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+)
+
+// treeReader is the part of a log storage transaction that a dump is read from. It's
+// satisfied by storage.ReadOnlyLogTX.
+type treeReader interface {
+	LatestSignedLogRoot() (trillian.SignedLogRoot, error)
+	GetSignedLogRootAtRevision(treeRevision int64) (trillian.SignedLogRoot, error)
+	GetLeavesByIndex(leaves []int64) ([]trillian.LogLeaf, error)
+	GetMerkleNodes(treeRevision int64, ids []storage.NodeID) ([]storage.Node, error)
+}
+
+// dumpOptions controls what goes into a dump.
+type dumpOptions struct {
+	// revision is the tree revision to dump, or -1 for the latest root
+	revision int64
+	// leaves includes the stored leaves in the dump
+	leaves bool
+	// nodes includes the stored nodes in the dump, each checked against its recomputed hash
+	nodes bool
+	// batchSize is the max number of leaves or nodes read at a time
+	batchSize int
+	// treeDepth is the depth of the log's tree, which the IDs of its nodes depend on
+	treeDepth int
+}
+
+// leafDump is a stored leaf.
+type leafDump struct {
+	Index                   int64  `json:"index"`
+	LeafHash                []byte `json:"leaf_hash"`
+	LeafValue               []byte `json:"leaf_value,omitempty"`
+	ExtraData               []byte `json:"extra_data,omitempty"`
+	IntegrateTimestampNanos int64  `json:"integrate_timestamp_nanos"`
+}
+
+// nodeDump is a stored node along with the hash recomputed for it from the leaf hashes.
+type nodeDump struct {
+	Depth        int    `json:"depth"`
+	Index        int64  `json:"index"`
+	NodeRevision int64  `json:"node_revision"`
+	Hash         []byte `json:"hash"`
+	ComputedHash []byte `json:"computed_hash"`
+}
+
+// Matches returns true if the stored hash is the recomputed one.
+func (n nodeDump) Matches() bool {
+	return bytes.Equal(n.Hash, n.ComputedHash)
+}
+
+// treeDump is the state of a tree at a revision. The root hash is recomputed from the stored
+// leaf hashes so that it can be checked against the signed root.
+type treeDump struct {
+	TreeSize         int64      `json:"tree_size"`
+	TreeRevision     int64      `json:"tree_revision"`
+	TimestampNanos   int64      `json:"timestamp_nanos"`
+	RootHash         []byte     `json:"root_hash"`
+	ComputedRootHash []byte     `json:"computed_root_hash"`
+	Leaves           []leafDump `json:"leaves,omitempty"`
+	Nodes            []nodeDump `json:"nodes,omitempty"`
+}
+
+// Problems lists the ways in which the stored tree doesn't match the one recomputed from its
+// leaf hashes. It's empty if the tree is consistent.
+func (d treeDump) Problems() []string {
+	var problems []string
+
+	if !bytes.Equal(d.RootHash, d.ComputedRootHash) {
+		problems = append(problems, fmt.Sprintf("root hash is %x but leaves give %x", d.RootHash, d.ComputedRootHash))
+	}
+
+	for _, n := range d.Nodes {
+		if !n.Matches() {
+			problems = append(problems, fmt.Sprintf("node %d/%d has hash %x but leaves give %x", n.Depth, n.Index, n.Hash, n.ComputedHash))
+		}
+	}
+
+	return problems
+}
+
+// nodeCoords identifies a node by its depth above the leaves and its index at that depth.
+type nodeCoords struct {
+	depth int
+	index int64
+}
+
+// dumpTree reads the tree at the revision given in opts from r and recomputes its root hash
+// from the stored leaf hashes.
+func dumpTree(r treeReader, hasher merkle.TreeHasher, opts dumpOptions) (treeDump, error) {
+	if opts.batchSize <= 0 {
+		return treeDump{}, fmt.Errorf("invalid batch size: %d", opts.batchSize)
+	}
+
+	root, err := readRoot(r, opts.revision)
+
+	if err != nil {
+		return treeDump{}, err
+	}
+
+	dump := treeDump{TreeSize: root.TreeSize, TreeRevision: root.TreeRevision, TimestampNanos: root.TimestampNanos, RootHash: root.RootHash}
+
+	// The callback is given the latest hash for each node so when all the leaves have been added
+	// these are the hashes the nodes should have at this revision
+	mt := merkle.NewCompactMerkleTree(hasher)
+	computed := make(map[nodeCoords]trillian.Hash)
+	setNode := func(depth int, index int64, hash trillian.Hash) {
+		computed[nodeCoords{depth, index}] = hash
+	}
+
+	for next := int64(0); next < root.TreeSize; {
+		indices := make([]int64, 0, opts.batchSize)
+		for i := next; i < root.TreeSize && len(indices) < opts.batchSize; i++ {
+			indices = append(indices, i)
+		}
+
+		leaves, err := r.GetLeavesByIndex(indices)
+
+		if err != nil {
+			return treeDump{}, fmt.Errorf("failed to read leaves from %d: %v", next, err)
+		}
+
+		if len(leaves) != len(indices) {
+			return treeDump{}, fmt.Errorf("asked for %d leaves from %d but got %d", len(indices), next, len(leaves))
+		}
+
+		// Storage doesn't promise to return leaves in the order they were asked for
+		sort.Sort(bySequenceNumber(leaves))
+
+		for i, leaf := range leaves {
+			if leaf.SequenceNumber != indices[i] {
+				return treeDump{}, fmt.Errorf("asked for leaf %d but got %d", indices[i], leaf.SequenceNumber)
+			}
+
+			mt.AddLeafHash(leaf.LeafHash, setNode)
+
+			if opts.leaves {
+				dump.Leaves = append(dump.Leaves, leafDump{
+					Index:                   leaf.SequenceNumber,
+					LeafHash:                leaf.LeafHash,
+					LeafValue:               leaf.LeafValue,
+					ExtraData:               leaf.ExtraData,
+					IntegrateTimestampNanos: leaf.IntegrateTimestampNanos,
+				})
+			}
+		}
+
+		next += int64(len(leaves))
+	}
+
+	dump.ComputedRootHash = mt.CurrentRoot()
+
+	if opts.nodes {
+		if dump.Nodes, err = readNodes(r, root.TreeRevision, computed, opts.batchSize, opts.treeDepth); err != nil {
+			return treeDump{}, err
+		}
+	}
+
+	return dump, nil
+}
+
+// readRoot returns the root stored at revision, or the latest root if revision is negative.
+func readRoot(r treeReader, revision int64) (trillian.SignedLogRoot, error) {
+	if revision < 0 {
+		root, err := r.LatestSignedLogRoot()
+
+		if err != nil {
+			return trillian.SignedLogRoot{}, fmt.Errorf("failed to read latest root: %v", err)
+		}
+
+		return root, nil
+	}
+
+	root, err := r.GetSignedLogRootAtRevision(revision)
+
+	if err != nil {
+		return trillian.SignedLogRoot{}, fmt.Errorf("failed to read root at revision %d: %v", revision, err)
+	}
+
+	return root, nil
+}
+
+// readNodes fetches the stored nodes at a revision for every node with a computed hash.
+// They're returned ordered by depth and then index.
+func readNodes(r treeReader, revision int64, computed map[nodeCoords]trillian.Hash, batchSize, treeDepth int) ([]nodeDump, error) {
+	coords := make([]nodeCoords, 0, len(computed))
+	for c := range computed {
+		coords = append(coords, c)
+	}
+	sort.Sort(byDepthAndIndex(coords))
+
+	nodes := make([]nodeDump, 0, len(coords))
+
+	for start := 0; start < len(coords); start += batchSize {
+		end := start + batchSize
+		if end > len(coords) {
+			end = len(coords)
+		}
+
+		ids := make([]storage.NodeID, 0, end-start)
+		wanted := make(map[string]nodeCoords)
+
+		for _, c := range coords[start:end] {
+			id, err := storage.NewNodeIDForTreeCoords(int64(c.depth), c.index, treeDepth)
+
+			if err != nil {
+				return nil, err
+			}
+
+			ids = append(ids, id)
+			wanted[id.String()] = c
+		}
+
+		stored, err := r.GetMerkleNodes(revision, ids)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read nodes at revision %d: %v", revision, err)
+		}
+
+		found := make(map[nodeCoords]storage.Node)
+		for _, node := range stored {
+			if c, ok := wanted[node.NodeID.String()]; ok {
+				found[c] = node
+			}
+		}
+
+		// Nodes that are missing from storage are included with no hash so they're reported
+		for _, c := range coords[start:end] {
+			node := found[c]
+			nodes = append(nodes, nodeDump{Depth: c.depth, Index: c.index, NodeRevision: node.NodeRevision, Hash: node.Hash, ComputedHash: computed[c]})
+		}
+	}
+
+	return nodes, nil
+}
+
+// writeJSON writes the dump to w as indented JSON. Hashes and data are base64 encoded.
+func writeJSON(w io.Writer, dump treeDump) error {
+	data, err := json.MarshalIndent(dump, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// writeText writes the dump to w in a readable form, followed by any problems found.
+func writeText(w io.Writer, dump treeDump) error {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "Tree size %d at revision %d, timestamp %d\n", dump.TreeSize, dump.TreeRevision, dump.TimestampNanos)
+	fmt.Fprintf(&b, "Root hash:          %x\n", dump.RootHash)
+	fmt.Fprintf(&b, "Computed root hash: %x\n", dump.ComputedRootHash)
+
+	for _, leaf := range dump.Leaves {
+		fmt.Fprintf(&b, "Leaf %d: hash %x, %d byte value, %d bytes extra data, integrated at %d\n", leaf.Index, leaf.LeafHash, len(leaf.LeafValue), len(leaf.ExtraData), leaf.IntegrateTimestampNanos)
+	}
+
+	for _, node := range dump.Nodes {
+		status := "ok"
+		if !node.Matches() {
+			status = fmt.Sprintf("MISMATCH, leaves give %x", node.ComputedHash)
+		}
+		fmt.Fprintf(&b, "Node %d/%d @%d: %x %s\n", node.Depth, node.Index, node.NodeRevision, node.Hash, status)
+	}
+
+	problems := dump.Problems()
+
+	if len(problems) == 0 {
+		fmt.Fprintln(&b, "Tree is consistent")
+	}
+
+	for _, p := range problems {
+		fmt.Fprintf(&b, "PROBLEM: %s\n", p)
+	}
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+type bySequenceNumber []trillian.LogLeaf
+
+func (l bySequenceNumber) Len() int           { return len(l) }
+func (l bySequenceNumber) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l bySequenceNumber) Less(i, j int) bool { return l[i].SequenceNumber < l[j].SequenceNumber }
+
+type byDepthAndIndex []nodeCoords
+
+func (c byDepthAndIndex) Len() int      { return len(c) }
+func (c byDepthAndIndex) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c byDepthAndIndex) Less(i, j int) bool {
+	if c[i].depth != c[j].depth {
+		return c[i].depth < c[j].depth
+	}
+	return c[i].index < c[j].index
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+)
+
+// memoryTree is a treeReader holding a log in memory, built the way the sequencer stores it.
+type memoryTree struct {
+	root   trillian.SignedLogRoot
+	leaves []trillian.LogLeaf
+	nodes  map[string]storage.Node
+}
+
+func (m *memoryTree) LatestSignedLogRoot() (trillian.SignedLogRoot, error) {
+	return m.root, nil
+}
+
+func (m *memoryTree) GetSignedLogRootAtRevision(treeRevision int64) (trillian.SignedLogRoot, error) {
+	if treeRevision != m.root.TreeRevision {
+		return trillian.SignedLogRoot{}, fmt.Errorf("no root at revision %d", treeRevision)
+	}
+
+	return m.root, nil
+}
+
+func (m *memoryTree) GetLeavesByIndex(indices []int64) ([]trillian.LogLeaf, error) {
+	leaves := make([]trillian.LogLeaf, 0, len(indices))
+
+	// Return them backwards as storage doesn't promise any order
+	for i := len(indices) - 1; i >= 0; i-- {
+		if indices[i] >= int64(len(m.leaves)) {
+			return nil, fmt.Errorf("no leaf %d", indices[i])
+		}
+
+		leaves = append(leaves, m.leaves[indices[i]])
+	}
+
+	return leaves, nil
+}
+
+func (m *memoryTree) GetMerkleNodes(treeRevision int64, ids []storage.NodeID) ([]storage.Node, error) {
+	if treeRevision != m.root.TreeRevision {
+		return nil, errors.New("wrong revision")
+	}
+
+	nodes := make([]storage.Node, 0, len(ids))
+	for _, id := range ids {
+		if node, ok := m.nodes[id.String()]; ok {
+			nodes = append(nodes, node)
+		}
+	}
+
+	return nodes, nil
+}
+
+// newMemoryTree creates a tree of n leaves with a correct root and nodes.
+func newMemoryTree(t *testing.T, n int) *memoryTree {
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+	mt := merkle.NewCompactMerkleTree(hasher)
+	m := &memoryTree{nodes: make(map[string]storage.Node)}
+
+	for i := 0; i < n; i++ {
+		data := []byte(fmt.Sprintf("leaf %d", i))
+		leaf := trillian.LogLeaf{Leaf: trillian.Leaf{LeafHash: hasher.HashLeaf(data), LeafValue: data}, SequenceNumber: int64(i)}
+		m.leaves = append(m.leaves, leaf)
+
+		mt.AddLeafHash(leaf.LeafHash, func(depth int, index int64, hash trillian.Hash) {
+			id, err := storage.NewNodeIDForTreeCoords(int64(depth), index, storage.DefaultLogTreeDepth)
+
+			if err != nil {
+				t.Fatalf("Failed to create node ID: %v", err)
+			}
+
+			m.nodes[id.String()] = storage.Node{NodeID: id, Hash: hash, NodeRevision: 3}
+		})
+	}
+
+	m.root = trillian.SignedLogRoot{TreeSize: int64(n), TreeRevision: 3, RootHash: mt.CurrentRoot()}
+	return m
+}
+
+func TestDumpTree(t *testing.T) {
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+
+	for _, size := range []int{0, 1, 7, 8, 21} {
+		m := newMemoryTree(t, size)
+
+		dump, err := dumpTree(m, hasher, dumpOptions{revision: -1, leaves: true, nodes: true, batchSize: 4, treeDepth: storage.DefaultLogTreeDepth})
+
+		if err != nil {
+			t.Fatalf("Size %d: dumpTree()=%v", size, err)
+		}
+
+		if problems := dump.Problems(); len(problems) > 0 {
+			t.Errorf("Size %d: unexpected problems: %v", size, problems)
+		}
+
+		if got, want := len(dump.Leaves), size; got != want {
+			t.Errorf("Size %d: dumped %d leaves, expected %d", size, got, want)
+		}
+
+		for i, leaf := range dump.Leaves {
+			if got, want := leaf.Index, int64(i); got != want {
+				t.Errorf("Size %d: leaf %d has index %d", size, i, got)
+			}
+		}
+
+		if got, want := len(dump.Nodes), len(m.nodes); got != want {
+			t.Errorf("Size %d: dumped %d nodes, expected %d", size, got, want)
+		}
+	}
+}
+
+func TestDumpTreeAtRevision(t *testing.T) {
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+	m := newMemoryTree(t, 5)
+
+	if _, err := dumpTree(m, hasher, dumpOptions{revision: 3, batchSize: 10, treeDepth: storage.DefaultLogTreeDepth}); err != nil {
+		t.Errorf("dumpTree() at revision 3 failed: %v", err)
+	}
+
+	if _, err := dumpTree(m, hasher, dumpOptions{revision: 2, batchSize: 10, treeDepth: storage.DefaultLogTreeDepth}); err == nil {
+		t.Error("dumpTree() at a revision with no root succeeded")
+	}
+}
+
+func TestDumpTreeFindsProblems(t *testing.T) {
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+
+	for _, test := range []struct {
+		corrupt func(m *memoryTree)
+		problem string
+	}{
+		{
+			corrupt: func(m *memoryTree) { m.root.RootHash = []byte("bad root") },
+			problem: "root hash",
+		},
+		{
+			corrupt: func(m *memoryTree) { m.leaves[3].LeafHash = hasher.HashLeaf([]byte("tampered")) },
+			problem: "root hash",
+		},
+		{
+			corrupt: func(m *memoryTree) {
+				for id, node := range m.nodes {
+					if node.NodeID.PrefixLenBits == storage.DefaultLogTreeDepth-1 {
+						node.Hash = []byte("bad node")
+						m.nodes[id] = node
+						return
+					}
+				}
+			},
+			problem: "node 1/",
+		},
+		{
+			corrupt: func(m *memoryTree) {
+				for id, node := range m.nodes {
+					if node.NodeID.PrefixLenBits == storage.DefaultLogTreeDepth-2 {
+						delete(m.nodes, id)
+						return
+					}
+				}
+			},
+			problem: "node 2/",
+		},
+	} {
+		m := newMemoryTree(t, 9)
+		test.corrupt(m)
+
+		dump, err := dumpTree(m, hasher, dumpOptions{revision: -1, nodes: true, batchSize: 4, treeDepth: storage.DefaultLogTreeDepth})
+
+		if err != nil {
+			t.Fatalf("dumpTree()=%v", err)
+		}
+
+		problems := dump.Problems()
+
+		if len(problems) == 0 || !strings.Contains(strings.Join(problems, "\n"), test.problem) {
+			t.Errorf("Expected a problem containing %q, got %v", test.problem, problems)
+		}
+
+		var b bytes.Buffer
+		if err := writeText(&b, dump); err != nil {
+			t.Fatalf("writeText()=%v", err)
+		}
+
+		if !strings.Contains(b.String(), "PROBLEM: ") {
+			t.Errorf("Text dump doesn't report the problem: %s", b.String())
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+	dump, err := dumpTree(newMemoryTree(t, 3), hasher, dumpOptions{revision: -1, leaves: true, nodes: true, batchSize: 2, treeDepth: storage.DefaultLogTreeDepth})
+
+	if err != nil {
+		t.Fatalf("dumpTree()=%v", err)
+	}
+
+	var b bytes.Buffer
+	if err := writeJSON(&b, dump); err != nil {
+		t.Fatalf("writeJSON()=%v", err)
+	}
+
+	var got treeDump
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to parse JSON dump: %v", err)
+	}
+
+	if got.TreeSize != 3 || len(got.Leaves) != 3 || len(got.Nodes) != len(dump.Nodes) || !bytes.Equal(got.RootHash, dump.RootHash) {
+		t.Errorf("JSON round trip gave %+v, expected %+v", got, dump)
+	}
+}
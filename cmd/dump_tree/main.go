@@ -0,0 +1,86 @@
+// The dump_tree command reads a log tree directly from storage and prints its root, leaves and
+// nodes at a revision, along with the root hash recomputed from the stored leaf hashes. Every
+// stored node is checked against the hash recomputed for it. It's intended for debugging and
+// for checking the consistency of storage. The exit status is 1 if problems were found.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/golang/glog"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage/tools"
+)
+
+var revisionFlag = flag.Int64("revision", -1, "The tree revision to dump, the latest root's revision if negative")
+var leavesFlag = flag.Bool("leaves", true, "If true, the stored leaves are included in the dump")
+var nodesFlag = flag.Bool("nodes", false, "If true, the stored nodes are included in the dump and checked against the leaves")
+var jsonFlag = flag.Bool("json", false, "If true, the dump is written as JSON rather than text")
+var outputFlag = flag.String("output", "", "If set, the dump is written to this file rather than stdout")
+var batchSizeFlag = flag.Int("batch_size", 1000, "Max number of leaves or nodes to read from storage at a time")
+
+func writeDump(dump treeDump) error {
+	var w io.Writer = os.Stdout
+
+	if len(*outputFlag) > 0 {
+		f, err := os.Create(*outputFlag)
+
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+		w = f
+	}
+
+	if *jsonFlag {
+		return writeJSON(w, dump)
+	}
+
+	return writeText(w, dump)
+}
+
+func main() {
+	flag.Parse()
+
+	treeID := tools.GetLogIdFromFlagsOrDie()
+	logStorage := tools.GetStorageFromFlagsOrDie(treeID)
+	defer logStorage.Close()
+
+	hasher, err := merkle.NewTreeHasherForAlgorithm(logStorage.HashAlgorithm(), logStorage.LeafHashStrategy())
+
+	if err != nil {
+		glog.Fatalf("Failed to create tree hasher: %v", err)
+	}
+
+	tx, err := logStorage.Snapshot()
+
+	if err != nil {
+		glog.Fatalf("Failed to start snapshot: %v", err)
+	}
+
+	dump, err := dumpTree(tx, hasher, dumpOptions{revision: *revisionFlag, leaves: *leavesFlag, nodes: *nodesFlag, batchSize: *batchSizeFlag, treeDepth: logStorage.TreeDepth()})
+
+	if err != nil {
+		tx.Commit()
+		glog.Fatalf("Failed to dump tree %d: %v", treeID.TreeID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		glog.Fatalf("Failed to commit snapshot: %v", err)
+	}
+
+	if err := writeDump(dump); err != nil {
+		glog.Fatalf("Failed to write dump: %v", err)
+	}
+
+	if problems := dump.Problems(); len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "Tree %d has %d problem(s)\n", treeID.TreeID, len(problems))
+		logStorage.Close()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/google/trillian/storage/mysql"
+)
+
+// writeSchemaDoc writes a Markdown description of the live schema of a database, with each
+// table and column checked against the expected schema. Expected tables and columns that are
+// missing are included so that every problem appears next to what it's about. It returns the
+// number of problems found.
+func writeSchemaDoc(w io.Writer, expected, live []mysql.SchemaTable) (int, error) {
+	problems := mysql.CompareSchema(expected, live)
+
+	// Problems keyed by lower case table and column, like MySQL compares names
+	byName := make(map[string]string)
+
+	for _, p := range problems {
+		byName[schemaKey(p.Table, p.Column)] = p.Problem
+	}
+
+	expectedTables := make(map[string]mysql.SchemaTable)
+
+	for _, table := range expected {
+		expectedTables[strings.ToLower(table.Name)] = table
+	}
+
+	tables := append([]mysql.SchemaTable(nil), live...)
+	liveNames := make(map[string]bool)
+
+	for _, table := range live {
+		liveNames[strings.ToLower(table.Name)] = true
+	}
+
+	for _, table := range expected {
+		if !liveNames[strings.ToLower(table.Name)] {
+			tables = append(tables, mysql.SchemaTable{Name: table.Name})
+		}
+	}
+
+	sort.Sort(tablesByName(tables))
+
+	var b bytes.Buffer
+
+	fmt.Fprintln(&b, "# Storage schema")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "Checked against the schema expected by this version of Trillian, see storage/mysql/storage.sql.")
+
+	for _, table := range tables {
+		fmt.Fprintf(&b, "\n## %s\n\n", table.Name)
+
+		expectedTable, ok := expectedTables[strings.ToLower(table.Name)]
+
+		if !ok {
+			fmt.Fprintln(&b, "Not used by this version of Trillian.")
+			continue
+		}
+
+		if problem, ok := byName[schemaKey(table.Name, "")]; ok {
+			fmt.Fprintf(&b, "PROBLEM: %s\n", problem)
+			continue
+		}
+
+		fmt.Fprintln(&b, "| Column | Type | Null | Check |")
+		fmt.Fprintln(&b, "| --- | --- | --- | --- |")
+
+		expectedColumns := make(map[string]bool)
+
+		for _, column := range expectedTable.Columns {
+			expectedColumns[strings.ToLower(column.Name)] = true
+		}
+
+		liveColumns := make(map[string]bool)
+
+		for _, column := range table.Columns {
+			liveColumns[strings.ToLower(column.Name)] = true
+			check := "ok"
+
+			if problem, ok := byName[schemaKey(table.Name, column.Name)]; ok {
+				check = "PROBLEM: " + problem
+			} else if !expectedColumns[strings.ToLower(column.Name)] {
+				check = "not used"
+			}
+
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", column.Name, column.Type, nullText(column.Nullable), check)
+		}
+
+		for _, column := range expectedTable.Columns {
+			if !liveColumns[strings.ToLower(column.Name)] {
+				fmt.Fprintf(&b, "| %s | | | PROBLEM: %s |\n", column.Name, byName[schemaKey(expectedTable.Name, column.Name)])
+			}
+		}
+	}
+
+	fmt.Fprintln(&b)
+
+	if len(problems) == 0 {
+		fmt.Fprintln(&b, "The schema matches.")
+	} else {
+		fmt.Fprintf(&b, "%d problems found, see storage/mysql/storage.sql for the expected schema.\n", len(problems))
+	}
+
+	_, err := w.Write(b.Bytes())
+	return len(problems), err
+}
+
+// tablesByName sorts tables case insensitively by name
+type tablesByName []mysql.SchemaTable
+
+func (t tablesByName) Len() int      { return len(t) }
+func (t tablesByName) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
+func (t tablesByName) Less(i, j int) bool {
+	return strings.ToLower(t[i].Name) < strings.ToLower(t[j].Name)
+}
+
+func schemaKey(table, column string) string {
+	return strings.ToLower(table) + "." + strings.ToLower(column)
+}
+
+func nullText(nullable bool) string {
+	if nullable {
+		return "YES"
+	}
+
+	return "NO"
+}
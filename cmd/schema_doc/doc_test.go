@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/trillian/storage/mysql"
+)
+
+var testExpected = []mysql.SchemaTable{
+	{Name: "Trees", Columns: []mysql.SchemaColumn{
+		{Name: "TreeId", Type: "int"},
+		{Name: "KeyId", Type: "varbinary(255)"},
+	}},
+	{Name: "LeafData", Columns: []mysql.SchemaColumn{
+		{Name: "TreeId", Type: "int"},
+	}},
+}
+
+func TestWriteSchemaDocMatches(t *testing.T) {
+	var b bytes.Buffer
+	problems, err := writeSchemaDoc(&b, testExpected, testExpected)
+
+	if err != nil {
+		t.Fatalf("writeSchemaDoc()=%v", err)
+	}
+
+	if problems != 0 {
+		t.Errorf("writeSchemaDoc()=%d problems, want 0", problems)
+	}
+
+	want := `# Storage schema
+
+Checked against the schema expected by this version of Trillian, see storage/mysql/storage.sql.
+
+## LeafData
+
+| Column | Type | Null | Check |
+| --- | --- | --- | --- |
+| TreeId | int | NO | ok |
+
+## Trees
+
+| Column | Type | Null | Check |
+| --- | --- | --- | --- |
+| TreeId | int | NO | ok |
+| KeyId | varbinary(255) | NO | ok |
+
+The schema matches.
+`
+
+	if got := b.String(); got != want {
+		t.Errorf("writeSchemaDoc() wrote:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteSchemaDocProblems(t *testing.T) {
+	live := []mysql.SchemaTable{
+		{Name: "Trees", Columns: []mysql.SchemaColumn{
+			{Name: "TreeId", Type: "bigint"},
+			{Name: "Unused", Type: "blob", Nullable: true},
+		}},
+		{Name: "Other", Columns: []mysql.SchemaColumn{
+			{Name: "Id", Type: "int"},
+		}},
+	}
+
+	var b bytes.Buffer
+	problems, err := writeSchemaDoc(&b, testExpected, live)
+
+	if err != nil {
+		t.Fatalf("writeSchemaDoc()=%v", err)
+	}
+
+	if problems != 3 {
+		t.Errorf("writeSchemaDoc()=%d problems, want 3", problems)
+	}
+
+	want := `# Storage schema
+
+Checked against the schema expected by this version of Trillian, see storage/mysql/storage.sql.
+
+## LeafData
+
+PROBLEM: table is missing
+
+## Other
+
+Not used by this version of Trillian.
+
+## Trees
+
+| Column | Type | Null | Check |
+| --- | --- | --- | --- |
+| TreeId | bigint | NO | PROBLEM: column type is bigint, expected int |
+| Unused | blob | YES | not used |
+| KeyId | | | PROBLEM: column is missing, expected varbinary(255) |
+
+3 problems found, see storage/mysql/storage.sql for the expected schema.
+`
+
+	if got := b.String(); got != want {
+		t.Errorf("writeSchemaDoc() wrote:\n%s\nwant:\n%s", got, want)
+	}
+}
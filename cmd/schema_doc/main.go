@@ -0,0 +1,51 @@
+// The schema_doc command describes the tables and columns of a live MySQL storage database as
+// Markdown, and checks them against the schema that this version of Trillian expects. Servers
+// make the same check at startup, this shows the details. The exit status is 1 if problems
+// were found.
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/golang/glog"
+	"github.com/google/trillian/storage/mysql"
+)
+
+var mysqlURIFlag = flag.String("mysql_uri", "test:zaphod@tcp(127.0.0.1:3306)/test", "uri of the mysql storage database to describe")
+var outputFlag = flag.String("output", "", "If set, the description is written to this file rather than stdout")
+
+func main() {
+	flag.Parse()
+
+	live, err := mysql.ReadSchema(*mysqlURIFlag)
+
+	if err != nil {
+		glog.Fatalf("Failed to read schema: %v", err)
+	}
+
+	var w io.Writer = os.Stdout
+
+	if len(*outputFlag) > 0 {
+		f, err := os.Create(*outputFlag)
+
+		if err != nil {
+			glog.Fatalf("Failed to create output file: %v", err)
+		}
+
+		defer f.Close()
+		w = f
+	}
+
+	problems, err := writeSchemaDoc(w, mysql.ExpectedSchema(), live)
+
+	if err != nil {
+		glog.Fatalf("Failed to write description: %v", err)
+	}
+
+	if problems > 0 {
+		os.Exit(1)
+	}
+}
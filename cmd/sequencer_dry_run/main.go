@@ -0,0 +1,87 @@
+// The sequencer_dry_run command sequences a batch of a log's queued leaves the way the log
+// signer would, dequeuing them, building the tree, computing the updated nodes and writing
+// them and a signed root, but rolls back the transaction instead of committing. It reports
+// how long each stage took and how many rows were written, along with the throughput that
+// batches of that size project to. The log is left unchanged, so it can be used to benchmark
+// storage capacity with production data. The batch is run several times to smooth out noise.
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"flag"
+	"os"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/golang/glog"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage/tools"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+var batchSizeFlag = flag.Int("batch_size", 50, "Max number of leaves to sequence in the batch, as for the log signer")
+var runsFlag = flag.Int("runs", 5, "Number of times to sequence the batch")
+var nodeFlushSizeFlag = flag.Int("node_flush_size", 0, "If set, updated tree nodes are written whenever this many are pending, as for the log signer")
+var batchMemoryBudgetFlag = flag.Int64("batch_memory_budget", 0, "If set, the batch is sequenced in chunks expected to fit in this many bytes, as for the log signer")
+var privateKeyFileFlag = flag.String("private_key_file", "", "If set, a file containing a PEM encoded private key to sign roots with. Otherwise a throwaway key is generated, the roots are never kept")
+var privateKeyPasswordFlag = flag.String("private_key_password", "", "Password for the private key")
+
+// loadKeyManager returns the key to sign roots with
+func loadKeyManager() (crypto.KeyManager, error) {
+	if len(*privateKeyFileFlag) > 0 {
+		return crypto.LoadPasswordProtectedPrivateKey(*privateKeyFileFlag, *privateKeyPasswordFlag)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return crypto.NewPEMKeyManager().NewPEMKeyManager(key), nil
+}
+
+func main() {
+	flag.Parse()
+
+	treeID := tools.GetLogIdFromFlagsOrDie()
+	logStorage := tools.GetStorageFromFlagsOrDie(treeID)
+	defer logStorage.Close()
+
+	hasher, err := merkle.NewTreeHasherForAlgorithm(logStorage.HashAlgorithm(), logStorage.LeafHashStrategy())
+
+	if err != nil {
+		glog.Fatalf("Failed to create tree hasher: %v", err)
+	}
+
+	keyManager, err := loadKeyManager()
+
+	if err != nil {
+		glog.Fatalf("Failed to load key: %v", err)
+	}
+
+	sequencer := log.NewSequencer(hasher, util.SystemTimeSource{}, logStorage, keyManager)
+	sequencer.SetTreeDepth(logStorage.TreeDepth())
+	sequencer.SetNodeFlushSize(*nodeFlushSizeFlag)
+	sequencer.SetBatchMemoryBudget(*batchMemoryBudgetFlag)
+
+	var reports []log.DryRunReport
+
+	for i := 0; i < *runsFlag; i++ {
+		report, err := sequencer.DryRunBatch(context.Background(), *batchSizeFlag)
+
+		if err != nil {
+			glog.Fatalf("Dry run %d of tree %d failed: %v", i+1, treeID.TreeID, err)
+		}
+
+		reports = append(reports, report)
+	}
+
+	if err := writeReport(os.Stdout, reports); err != nil {
+		glog.Fatalf("Failed to write report: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/trillian/log"
+)
+
+// summary projects the cost of sequencing from a series of dry runs of the same batch.
+type summary struct {
+	// Runs is the number of dry runs
+	Runs int
+	// Leaves, LeafRows, NodeRows and RootRows are the counts for one batch
+	Leaves   int
+	LeafRows int
+	NodeRows int
+	RootRows int
+	// MeanTime and MaxTime are the mean and slowest time taken by the batch
+	MeanTime time.Duration
+	MaxTime  time.Duration
+	// LeavesPerSecond is the projected rate at which leaves can be sequenced with batches of
+	// this size, based on the mean time
+	LeavesPerSecond float64
+}
+
+// summarize returns the summary of reports, which must be for the same batch.
+func summarize(reports []log.DryRunReport) summary {
+	s := summary{Runs: len(reports)}
+
+	if len(reports) == 0 {
+		return s
+	}
+
+	var total time.Duration
+	for _, r := range reports {
+		total += r.TotalTime
+		if r.TotalTime > s.MaxTime {
+			s.MaxTime = r.TotalTime
+		}
+	}
+
+	last := reports[len(reports)-1]
+	s.Leaves, s.LeafRows, s.NodeRows, s.RootRows = last.Leaves, last.LeafRows, last.NodeRows, last.RootRows
+	s.MeanTime = total / time.Duration(len(reports))
+
+	if s.MeanTime > 0 {
+		s.LeavesPerSecond = float64(s.Leaves) / s.MeanTime.Seconds()
+	}
+
+	return s
+}
+
+// writeReport writes a line for each dry run followed by the summary.
+func writeReport(w io.Writer, reports []log.DryRunReport) error {
+	var b bytes.Buffer
+
+	for i, r := range reports {
+		fmt.Fprintf(&b, "Run %d: %d leaves to tree size %d, %d leaf rows, %d node rows, %d root rows, dequeue %v, compute %v, write %v, total %v\n", i+1, r.Leaves, r.TreeSize, r.LeafRows, r.NodeRows, r.RootRows, r.DequeueTime, r.ComputeTime, r.WriteTime, r.TotalTime)
+	}
+
+	s := summarize(reports)
+
+	if s.Leaves == 0 {
+		fmt.Fprintln(&b, "No leaves are queued, nothing was measured")
+	} else {
+		fmt.Fprintf(&b, "Batch of %d leaves writes %d rows (%d leaf, %d node, %d root), mean time %v, max %v over %d runs\n", s.Leaves, s.LeafRows+s.NodeRows+s.RootRows, s.LeafRows, s.NodeRows, s.RootRows, s.MeanTime, s.MaxTime, s.Runs)
+		fmt.Fprintf(&b, "Projected throughput: %.1f leaves/s, %.0f leaves/hour\n", s.LeavesPerSecond, s.LeavesPerSecond*3600)
+	}
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/trillian/log"
+)
+
+func TestSummarize(t *testing.T) {
+	reports := []log.DryRunReport{
+		{Leaves: 100, TreeSize: 1100, LeafRows: 100, NodeRows: 210, RootRows: 2, TotalTime: time.Second},
+		{Leaves: 100, TreeSize: 1100, LeafRows: 100, NodeRows: 210, RootRows: 2, TotalTime: 3 * time.Second},
+	}
+
+	want := summary{Runs: 2, Leaves: 100, LeafRows: 100, NodeRows: 210, RootRows: 2, MeanTime: 2 * time.Second, MaxTime: 3 * time.Second, LeavesPerSecond: 50}
+
+	if got := summarize(reports); got != want {
+		t.Errorf("summarize()=%+v, expected %+v", got, want)
+	}
+
+	if got, want := summarize(nil), (summary{}); got != want {
+		t.Errorf("summarize(nil)=%+v, expected %+v", got, want)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	for _, test := range []struct {
+		reports []log.DryRunReport
+		want    []string
+	}{
+		{
+			reports: []log.DryRunReport{{Leaves: 10, TreeSize: 20, LeafRows: 10, NodeRows: 25, RootRows: 2, TotalTime: time.Second}},
+			want:    []string{"Run 1: 10 leaves to tree size 20", "writes 37 rows", "10.0 leaves/s"},
+		},
+		{
+			reports: []log.DryRunReport{{TreeSize: 20}},
+			want:    []string{"No leaves are queued"},
+		},
+	} {
+		var b bytes.Buffer
+
+		if err := writeReport(&b, test.reports); err != nil {
+			t.Fatalf("writeReport()=%v", err)
+		}
+
+		for _, want := range test.want {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("Report %q doesn't contain %q", b.String(), want)
+			}
+		}
+	}
+}
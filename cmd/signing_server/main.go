@@ -0,0 +1,92 @@
+// The signing_server command serves the SigningService, holding private keys in a process of
+// their own so that the log servers and CT frontends that sign with them, configured with
+// --signing_server, never load them. It should run on a host, or at least as a user, that the
+// rest of the system can't otherwise access, with its port reachable only by those servers.
+// Keys are read from encrypted PEM files given as key_id=file pairs and all have the same
+// password.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/crypto"
+	"google.golang.org/grpc"
+)
+
+var portFlag = flag.Int("port", 8094, "Port to serve signing requests on")
+var keysFlag = flag.String("keys", "", "Comma separated list of key_id=file pairs, each naming a PEM encoded private key file that's served under the key ID")
+var keyPasswordFlag = flag.String("key_password", "", "Password of the private key files")
+var deterministicSignaturesFlag = flag.Bool("deterministic_signatures", false, "If true, ECDSA keys sign with RFC 6979 deterministic nonces rather than ones from the random number source")
+
+// loadKeys adds the keys listed in --keys to server.
+func loadKeys(server *crypto.SigningServer) error {
+	for _, pair := range strings.Split(*keysFlag, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+
+		if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+			return fmt.Errorf("invalid key_id=file pair: %s", pair)
+		}
+
+		km, err := crypto.LoadPasswordProtectedPrivateKey(parts[1], *keyPasswordFlag)
+
+		if err != nil {
+			return fmt.Errorf("failed to load key %s: %v", parts[0], err)
+		}
+
+		if *deterministicSignaturesFlag {
+			km = crypto.NewDeterministicKeyManager(km)
+		}
+
+		if err := server.AddKey(parts[0], km); err != nil {
+			return fmt.Errorf("failed to add key %s: %v", parts[0], err)
+		}
+
+		glog.Infof("Serving key %s from %s", parts[0], parts[1])
+	}
+
+	return nil
+}
+
+func main() {
+	flag.Parse()
+
+	if len(*keysFlag) == 0 {
+		glog.Fatal("--keys must be set")
+	}
+
+	signingServer := crypto.NewSigningServer()
+
+	if err := loadKeys(signingServer); err != nil {
+		glog.Fatalf("Failed to load keys: %v", err)
+	}
+
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", *portFlag))
+
+	if err != nil {
+		glog.Fatalf("Failed to listen on port %d: %v", *portFlag, err)
+	}
+
+	grpcServer := grpc.NewServer()
+	crypto.RegisterSigningServiceServer(grpcServer, signingServer)
+
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		glog.Infof("Signal received: %v", <-sigs)
+		grpcServer.GracefulStop()
+	}()
+
+	glog.Infof("Serving signing requests on port %d", *portFlag)
+
+	if err := grpcServer.Serve(lis); err != nil {
+		glog.Errorf("Signing server exited: %v", err)
+		os.Exit(1)
+	}
+}
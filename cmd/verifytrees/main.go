@@ -0,0 +1,130 @@
+// The verifytrees command checks that two copies of a log, such as a primary deployment and its
+// disaster recovery replica, hold the same leaves and that each one's signed root hash matches
+// its leaves. One copy can be an archive previously written by this tool.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+var logIDFlag = flag.Int64("log_id", 1, "The log id (tree id) to verify")
+var leftServerFlag = flag.String("left_server", "localhost:8090", "Log RPC server for the first copy of the log")
+var rightServerFlag = flag.String("right_server", "", "Log RPC server for the second copy of the log")
+var rightArchiveFlag = flag.String("right_archive", "", "Archive file holding the second copy of the log, instead of a server")
+var exportArchiveFlag = flag.String("export_archive", "", "If set, write the log on the left server to this archive file instead of verifying")
+var batchSizeFlag = flag.Int("batch_size", 100, "Max number of leaves to fetch in one request")
+
+func dialLogSource(server string) (rpcLeafSource, *grpc.ClientConn, error) {
+	conn, err := grpc.Dial(server, grpc.WithInsecure(), grpc.WithBlock())
+
+	if err != nil {
+		return rpcLeafSource{}, nil, err
+	}
+
+	return rpcLeafSource{client: trillian.NewTrillianLogClient(conn), logID: *logIDFlag}, conn, nil
+}
+
+func exportArchive(ctx context.Context, left leafSource) error {
+	f, err := os.Create(*exportArchiveFlag)
+
+	if err != nil {
+		return err
+	}
+
+	if err := writeArchive(ctx, f, left, *batchSizeFlag); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func openRightSource() (leafSource, func(), error) {
+	switch {
+	case len(*rightServerFlag) > 0 && len(*rightArchiveFlag) > 0:
+		return nil, nil, errors.New("only one of --right_server and --right_archive can be set")
+	case len(*rightServerFlag) > 0:
+		src, conn, err := dialLogSource(*rightServerFlag)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return src, func() { conn.Close() }, nil
+	case len(*rightArchiveFlag) > 0:
+		f, err := os.Open(*rightArchiveFlag)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		src, err := newArchiveLeafSource(f)
+
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+
+		return src, func() { f.Close() }, nil
+	}
+
+	return nil, nil, errors.New("one of --right_server or --right_archive must be set")
+}
+
+func main() {
+	flag.Parse()
+	ctx := context.Background()
+
+	left, conn, err := dialLogSource(*leftServerFlag)
+
+	if err != nil {
+		glog.Fatalf("Failed to connect to left server: %v", err)
+	}
+
+	defer conn.Close()
+
+	if len(*exportArchiveFlag) > 0 {
+		if err := exportArchive(ctx, left); err != nil {
+			glog.Fatalf("Failed to export archive: %v", err)
+		}
+
+		return
+	}
+
+	right, closeRight, err := openRightSource()
+
+	if err != nil {
+		glog.Fatalf("Failed to open right copy of the log: %v", err)
+	}
+
+	defer closeRight()
+
+	// The right copy is only checked against the tree the left one was created as, as an
+	// archive doesn't record how its tree is hashed
+	hasher, err := left.TreeHasher(ctx)
+
+	if err != nil {
+		glog.Fatalf("Failed to get the log's tree hasher: %v", err)
+	}
+
+	size, err := verifyTrees(ctx, left, right, hasher, *batchSizeFlag)
+
+	if d, ok := err.(Divergence); ok {
+		fmt.Printf("Log %d: %v\n", *logIDFlag, d)
+		closeRight()
+		conn.Close()
+		os.Exit(1)
+	} else if err != nil {
+		glog.Fatalf("Failed to verify trees: %v", err)
+	}
+
+	fmt.Printf("Log %d: both copies agree up to tree size %d\n", *logIDFlag, size)
+}
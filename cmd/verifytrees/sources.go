@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+)
+
+// maxArchiveRecordSize bounds the size of a single archive record so a corrupt length can't
+// make us allocate an unreasonable amount of memory.
+const maxArchiveRecordSize = 64 * 1024 * 1024
+
+// leafSource is a copy of a log that can be walked in leaf index order.
+type leafSource interface {
+	// LatestRoot returns the most recent signed root for the log.
+	LatestRoot(ctx context.Context) (trillian.SignedLogRoot, error)
+	// Leaves returns count leaves starting at index start, in index order.
+	Leaves(ctx context.Context, start int64, count int) ([]*trillian.LeafProto, error)
+}
+
+// rpcLeafSource reads a log from a running log server.
+type rpcLeafSource struct {
+	client trillian.TrillianLogClient
+	logID  int64
+}
+
+func (r rpcLeafSource) LatestRoot(ctx context.Context) (trillian.SignedLogRoot, error) {
+	resp, err := r.client.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: r.logID})
+
+	if err != nil {
+		return trillian.SignedLogRoot{}, err
+	}
+
+	if !rpcStatusOK(resp.GetStatus()) {
+		return trillian.SignedLogRoot{}, fmt.Errorf("GetLatestSignedLogRoot failed: %v", resp.GetStatus())
+	}
+
+	if resp.SignedLogRoot == nil {
+		return trillian.SignedLogRoot{}, errors.New("GetLatestSignedLogRoot returned no root")
+	}
+
+	return *resp.SignedLogRoot, nil
+}
+
+func (r rpcLeafSource) Leaves(ctx context.Context, start int64, count int) ([]*trillian.LeafProto, error) {
+	indices := make([]int64, 0, count)
+
+	for i := 0; i < count; i++ {
+		indices = append(indices, start+int64(i))
+	}
+
+	resp, err := r.client.GetLeavesByIndex(ctx, &trillian.GetLeavesByIndexRequest{LogId: r.logID, LeafIndex: indices})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !rpcStatusOK(resp.GetStatus()) {
+		return nil, fmt.Errorf("GetLeavesByIndex failed: %v", resp.GetStatus())
+	}
+
+	// The server doesn't promise to return leaves in the order they were asked for
+	sort.Sort(byLeafIndex(resp.Leaves))
+
+	return resp.Leaves, nil
+}
+
+// TreeHasher returns the hasher the log was created with.
+func (r rpcLeafSource) TreeHasher(ctx context.Context) (merkle.TreeHasher, error) {
+	resp, err := r.client.GetTreeMetadata(ctx, &trillian.GetTreeMetadataRequest{LogId: r.logID})
+
+	if err != nil {
+		return merkle.TreeHasher{}, err
+	}
+
+	if !rpcStatusOK(resp.GetStatus()) {
+		return merkle.TreeHasher{}, fmt.Errorf("GetTreeMetadata failed: %v", resp.GetStatus())
+	}
+
+	return merkle.NewTreeHasherForAlgorithm(resp.HashAlgorithm, resp.LeafHashStrategy)
+}
+
+func rpcStatusOK(status *trillian.TrillianApiStatus) bool {
+	return status != nil && status.StatusCode == trillian.TrillianApiStatusCode_OK
+}
+
+type byLeafIndex []*trillian.LeafProto
+
+func (l byLeafIndex) Len() int           { return len(l) }
+func (l byLeafIndex) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l byLeafIndex) Less(i, j int) bool { return l[i].LeafIndex < l[j].LeafIndex }
+
+// An archive is a sequence of records, each a varint length followed by a serialized proto.
+// The first record is the SignedLogRoot the archive was taken at and the rest are the leaves
+// of the tree at that root, as LeafProtos in index order.
+
+// archiveLeafSource reads a log from an archive. Leaves can only be read in order.
+type archiveLeafSource struct {
+	r    *bufio.Reader
+	root trillian.SignedLogRoot
+	next int64
+}
+
+func newArchiveLeafSource(r io.Reader) (*archiveLeafSource, error) {
+	a := &archiveLeafSource{r: bufio.NewReader(r)}
+
+	if err := a.readRecord(&a.root); err != nil {
+		return nil, fmt.Errorf("failed to read archive root: %v", err)
+	}
+
+	return a, nil
+}
+
+func (a *archiveLeafSource) readRecord(pb proto.Message) error {
+	size, err := binary.ReadUvarint(a.r)
+
+	if err != nil {
+		return err
+	}
+
+	if size > maxArchiveRecordSize {
+		return fmt.Errorf("archive record too large: %d bytes", size)
+	}
+
+	buf := make([]byte, size)
+
+	if _, err := io.ReadFull(a.r, buf); err != nil {
+		return err
+	}
+
+	return proto.Unmarshal(buf, pb)
+}
+
+func (a *archiveLeafSource) LatestRoot(ctx context.Context) (trillian.SignedLogRoot, error) {
+	return a.root, nil
+}
+
+func (a *archiveLeafSource) Leaves(ctx context.Context, start int64, count int) ([]*trillian.LeafProto, error) {
+	if start != a.next {
+		return nil, fmt.Errorf("archive must be read in order, want leaf %d but asked for %d", a.next, start)
+	}
+
+	leaves := make([]*trillian.LeafProto, 0, count)
+
+	for i := 0; i < count; i++ {
+		leaf := &trillian.LeafProto{}
+
+		if err := a.readRecord(leaf); err == io.EOF {
+			return nil, fmt.Errorf("archive ends after %d leaves", a.next)
+		} else if err != nil {
+			return nil, err
+		}
+
+		leaves = append(leaves, leaf)
+		a.next++
+	}
+
+	return leaves, nil
+}
+
+// writeArchive writes the log in src, up to its latest root, to w in the archive format.
+func writeArchive(ctx context.Context, w io.Writer, src leafSource, batchSize int) error {
+	root, err := src.LatestRoot(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+
+	if err := writeRecord(bw, &root); err != nil {
+		return err
+	}
+
+	for next := int64(0); next < root.TreeSize; {
+		count := batchSize
+
+		if remaining := root.TreeSize - next; remaining < int64(count) {
+			count = int(remaining)
+		}
+
+		leaves, err := src.Leaves(ctx, next, count)
+
+		if err != nil {
+			return err
+		}
+
+		if len(leaves) != count {
+			return fmt.Errorf("asked for %d leaves from %d but got %d", count, next, len(leaves))
+		}
+
+		for _, leaf := range leaves {
+			if err := writeRecord(bw, leaf); err != nil {
+				return err
+			}
+		}
+
+		next += int64(count)
+	}
+
+	return bw.Flush()
+}
+
+func writeRecord(w io.Writer, pb proto.Message) error {
+	buf, err := proto.Marshal(pb)
+
+	if err != nil {
+		return err
+	}
+
+	var size [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(size[:], uint64(len(buf)))
+
+	if _, err := w.Write(size[:n]); err != nil {
+		return err
+	}
+
+	_, err = w.Write(buf)
+	return err
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// fakeLogServer serves a memorySource over gRPC. Only the RPCs used by rpcLeafSource are
+// implemented.
+type fakeLogServer struct {
+	trillian.TrillianLogServer
+	src           *memorySource
+	hashAlgorithm trillian.HashAlgorithm
+}
+
+func (f *fakeLogServer) GetLatestSignedLogRoot(ctx context.Context, req *trillian.GetLatestSignedLogRootRequest) (*trillian.GetLatestSignedLogRootResponse, error) {
+	root := f.src.root
+	return &trillian.GetLatestSignedLogRootResponse{Status: okStatus(), SignedLogRoot: &root}, nil
+}
+
+func (f *fakeLogServer) GetLeavesByIndex(ctx context.Context, req *trillian.GetLeavesByIndexRequest) (*trillian.GetLeavesByIndexResponse, error) {
+	resp := &trillian.GetLeavesByIndexResponse{Status: okStatus()}
+
+	for _, index := range req.LeafIndex {
+		resp.Leaves = append(resp.Leaves, f.src.leaves[index])
+	}
+
+	return resp, nil
+}
+
+func (f *fakeLogServer) GetTreeMetadata(ctx context.Context, req *trillian.GetTreeMetadataRequest) (*trillian.GetTreeMetadataResponse, error) {
+	return &trillian.GetTreeMetadataResponse{Status: okStatus(), Metadata: &trillian.TreeMetadata{}, HashAlgorithm: f.hashAlgorithm}, nil
+}
+
+func okStatus() *trillian.TrillianApiStatus {
+	return &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}
+}
+
+// serveLog starts a gRPC server for f and returns a source reading from it and a function
+// that stops it.
+func serveLog(t *testing.T, f *fakeLogServer) (rpcLeafSource, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	server := grpc.NewServer()
+	trillian.RegisterTrillianLogServer(server, f)
+	go server.Serve(lis)
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+
+	if err != nil {
+		server.Stop()
+		t.Fatalf("Failed to dial: %v", err)
+	}
+
+	return rpcLeafSource{client: trillian.NewTrillianLogClient(conn), logID: 1}, func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+func TestVerifyTreesOverRPCUsesTreeHasher(t *testing.T) {
+	hasher, err := merkle.NewTreeHasherForAlgorithm(trillian.HashAlgorithm_SHA512_256, trillian.LeafHashStrategy_RFC6962_LEAF_HASH)
+
+	if err != nil {
+		t.Fatalf("Failed to create tree hasher: %v", err)
+	}
+
+	left, stopLeft := serveLog(t, &fakeLogServer{src: newMemorySourceWithHasher(7, hasher), hashAlgorithm: trillian.HashAlgorithm_SHA512_256})
+	defer stopLeft()
+	right, stopRight := serveLog(t, &fakeLogServer{src: newMemorySourceWithHasher(7, hasher), hashAlgorithm: trillian.HashAlgorithm_SHA512_256})
+	defer stopRight()
+
+	ctx := context.Background()
+	treeHasher, err := left.TreeHasher(ctx)
+
+	if err != nil {
+		t.Fatalf("TreeHasher()=%v", err)
+	}
+
+	size, err := verifyTrees(ctx, left, right, treeHasher, 3)
+
+	if err != nil {
+		t.Fatalf("verifyTrees()=%v", err)
+	}
+
+	if got, want := size, int64(7); got != want {
+		t.Fatalf("verifyTrees()=%d, want %d", got, want)
+	}
+}
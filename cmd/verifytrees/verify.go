@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+)
+
+// Divergence describes the first point at which two copies of a log were found to differ.
+type Divergence struct {
+	// Index is the index of the leaf that differs, or the tree size for a root mismatch
+	Index  int64
+	Reason string
+}
+
+func (d Divergence) Error() string {
+	return fmt.Sprintf("trees diverge at %d: %s", d.Index, d.Reason)
+}
+
+// verifyTrees checks that left and right hold the same leaves up to the smaller of their tree
+// sizes. It also checks each leaf's hash against its data and recomputes the root hash of each
+// from its leaves, using the log's hasher, and checks it against the latest signed root. The first difference found is returned as a
+// Divergence. Otherwise it returns the tree size up to which the copies agree.
+func verifyTrees(ctx context.Context, left, right leafSource, hasher merkle.TreeHasher, batchSize int) (int64, error) {
+	if batchSize <= 0 {
+		return 0, fmt.Errorf("invalid batch size: %d", batchSize)
+	}
+
+	leftRoot, err := left.LatestRoot(ctx)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to get left root: %v", err)
+	}
+
+	rightRoot, err := right.LatestRoot(ctx)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to get right root: %v", err)
+	}
+
+	aligned, maxSize := leftRoot.TreeSize, rightRoot.TreeSize
+
+	if aligned > maxSize {
+		aligned, maxSize = maxSize, aligned
+	}
+
+	// Once past the aligned size leaves are only read from the larger tree, so its root can be
+	// checked too
+	mt := merkle.NewCompactMerkleTree(hasher)
+
+	for next := int64(0); next < maxSize; {
+		end := maxSize
+
+		if next < aligned {
+			end = aligned
+		}
+
+		count := batchSize
+
+		if remaining := end - next; remaining < int64(count) {
+			count = int(remaining)
+		}
+
+		leftLeaves, err := fetchLeaves(ctx, "left", left, leftRoot.TreeSize, next, count)
+
+		if err != nil {
+			return 0, err
+		}
+
+		rightLeaves, err := fetchLeaves(ctx, "right", right, rightRoot.TreeSize, next, count)
+
+		if err != nil {
+			return 0, err
+		}
+
+		for i := 0; i < count; i++ {
+			index := next + int64(i)
+			var leaf *trillian.LeafProto
+
+			switch {
+			case leftLeaves != nil && rightLeaves != nil:
+				if reason := compareLeaves(leftLeaves[i], rightLeaves[i]); len(reason) > 0 {
+					return 0, Divergence{Index: index, Reason: reason}
+				}
+				leaf = leftLeaves[i]
+			case leftLeaves != nil:
+				leaf = leftLeaves[i]
+			default:
+				leaf = rightLeaves[i]
+			}
+
+			// Where both copies have the leaf they're the same, so only one needs checking
+			name := "left"
+
+			if leftLeaves == nil {
+				name = "right"
+			}
+
+			if err := checkLeafHash(name, hasher, leaf); err != nil {
+				return 0, err
+			}
+
+			mt.AddLeafHash(leaf.LeafHash, func(int, int64, trillian.Hash) {})
+
+			if err := checkRoot("left", leftRoot, mt); err != nil {
+				return 0, err
+			}
+
+			if err := checkRoot("right", rightRoot, mt); err != nil {
+				return 0, err
+			}
+		}
+
+		next += int64(count)
+	}
+
+	return aligned, nil
+}
+
+// fetchLeaves reads leaves from src, or returns nil if they're beyond its tree size.
+func fetchLeaves(ctx context.Context, name string, src leafSource, treeSize, start int64, count int) ([]*trillian.LeafProto, error) {
+	if start >= treeSize {
+		return nil, nil
+	}
+
+	leaves, err := src.Leaves(ctx, start, count)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s leaves from %d: %v", name, start, err)
+	}
+
+	if len(leaves) != count {
+		return nil, fmt.Errorf("asked for %d %s leaves from %d but got %d", count, name, start, len(leaves))
+	}
+
+	for i, leaf := range leaves {
+		if got, want := leaf.LeafIndex, start+int64(i); got != want {
+			return nil, fmt.Errorf("asked for %s leaf %d but got %d", name, want, got)
+		}
+	}
+
+	return leaves, nil
+}
+
+// compareLeaves returns a description of how two leaves differ, or an empty string if they're
+// the same. Integration times are not compared as they are specific to each copy.
+func compareLeaves(l, r *trillian.LeafProto) string {
+	switch {
+	case !bytes.Equal(l.LeafHash, r.LeafHash):
+		return fmt.Sprintf("leaf hash %x != %x", l.LeafHash, r.LeafHash)
+	case !bytes.Equal(l.LeafData, r.LeafData):
+		return "leaf data differs"
+	case !bytes.Equal(l.ExtraData, r.ExtraData):
+		return "extra data differs"
+	}
+
+	return ""
+}
+
+// checkLeafHash checks that a leaf's hash is the hash of its data, so that a copy whose leaf
+// data was altered can't pass because its hashes were left alone.
+func checkLeafHash(name string, hasher merkle.TreeHasher, leaf *trillian.LeafProto) error {
+	if got, want := leaf.LeafHash, hasher.HashLeaf(leaf.LeafData); !bytes.Equal(got, want) {
+		return Divergence{
+			Index:  leaf.LeafIndex,
+			Reason: fmt.Sprintf("%s leaf hash is %x but its data hashes to %x", name, got, want),
+		}
+	}
+
+	return nil
+}
+
+// checkRoot compares the root hash computed from the leaves with the signed root when the
+// tree has reached the signed root's size.
+func checkRoot(name string, root trillian.SignedLogRoot, mt *merkle.CompactMerkleTree) error {
+	if mt.Size() != root.TreeSize {
+		return nil
+	}
+
+	if got, want := mt.CurrentRoot(), root.RootHash; !bytes.Equal(got, want) {
+		return Divergence{
+			Index:  root.TreeSize,
+			Reason: fmt.Sprintf("%s signed root hash is %x but its leaves give %x", name, want, got),
+		}
+	}
+
+	return nil
+}
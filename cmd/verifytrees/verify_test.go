@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+)
+
+// memorySource is a leafSource holding a log in memory.
+type memorySource struct {
+	root   trillian.SignedLogRoot
+	leaves []*trillian.LeafProto
+}
+
+func (m *memorySource) LatestRoot(ctx context.Context) (trillian.SignedLogRoot, error) {
+	return m.root, nil
+}
+
+func (m *memorySource) Leaves(ctx context.Context, start int64, count int) ([]*trillian.LeafProto, error) {
+	if start+int64(count) > int64(len(m.leaves)) {
+		return nil, fmt.Errorf("no leaves beyond %d", len(m.leaves))
+	}
+
+	return m.leaves[start : start+int64(count)], nil
+}
+
+var testHasher = merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+
+// newMemorySource creates a log of n leaves with a correct root hash.
+func newMemorySource(n int) *memorySource {
+	return newMemorySourceWithHasher(n, testHasher)
+}
+
+// newMemorySourceWithHasher creates a log of n leaves hashed with hasher.
+func newMemorySourceWithHasher(n int, hasher merkle.TreeHasher) *memorySource {
+	mt := merkle.NewCompactMerkleTree(hasher)
+	m := &memorySource{}
+
+	for i := 0; i < n; i++ {
+		data := []byte(fmt.Sprintf("leaf %d", i))
+		leaf := &trillian.LeafProto{LeafIndex: int64(i), LeafHash: hasher.HashLeaf(data), LeafData: data, ExtraData: []byte("extra")}
+		m.leaves = append(m.leaves, leaf)
+		mt.AddLeafHash(leaf.LeafHash, func(int, int64, trillian.Hash) {})
+	}
+
+	m.root = trillian.SignedLogRoot{TreeSize: int64(n), RootHash: mt.CurrentRoot()}
+	return m
+}
+
+func TestVerifyTreesAgree(t *testing.T) {
+	for _, sizes := range [][2]int{{0, 0}, {1, 1}, {10, 10}, {7, 12}, {12, 7}, {0, 5}} {
+		size, err := verifyTrees(context.Background(), newMemorySource(sizes[0]), newMemorySource(sizes[1]), testHasher, 3)
+
+		if err != nil {
+			t.Fatalf("%v: verifyTrees()=%v", sizes, err)
+		}
+
+		want := sizes[0]
+		if sizes[1] < want {
+			want = sizes[1]
+		}
+
+		if got := size; got != int64(want) {
+			t.Fatalf("%v: verifyTrees()=%d, want %d", sizes, got, want)
+		}
+	}
+}
+
+func TestVerifyTreesLeafDivergence(t *testing.T) {
+	right := newMemorySource(10)
+	right.leaves[6] = &trillian.LeafProto{LeafIndex: 6, LeafHash: right.leaves[6].LeafHash, LeafData: []byte("other")}
+
+	_, err := verifyTrees(context.Background(), newMemorySource(10), right, testHasher, 4)
+	d, ok := err.(Divergence)
+
+	if !ok {
+		t.Fatalf("verifyTrees()=%v, want a Divergence", err)
+	}
+
+	if got, want := d.Index, int64(6); got != want {
+		t.Fatalf("divergence at %d, want %d", got, want)
+	}
+
+	if !strings.Contains(d.Reason, "leaf data") {
+		t.Fatalf("unexpected divergence reason: %s", d.Reason)
+	}
+}
+
+func TestVerifyTreesLeafHashMismatch(t *testing.T) {
+	// The data of leaf 6 is changed in both copies but its hash isn't, so the copies agree
+	// and the root still matches
+	left, right := newMemorySource(10), newMemorySource(10)
+
+	for _, src := range []*memorySource{left, right} {
+		leaf := *src.leaves[6]
+		leaf.LeafData = []byte("altered")
+		src.leaves[6] = &leaf
+	}
+
+	_, err := verifyTrees(context.Background(), left, right, testHasher, 4)
+	d, ok := err.(Divergence)
+
+	if !ok {
+		t.Fatalf("verifyTrees()=%v, want a Divergence", err)
+	}
+
+	if d.Index != 6 || !strings.Contains(d.Reason, "hashes to") {
+		t.Fatalf("verifyTrees()=%v, want a leaf hash mismatch at 6", d)
+	}
+}
+
+func TestVerifyTreesRootDivergence(t *testing.T) {
+	// The larger tree's root is checked against leaves that the other copy doesn't have
+	right := newMemorySource(12)
+	right.root.RootHash = []byte("bad root")
+
+	_, err := verifyTrees(context.Background(), newMemorySource(8), right, testHasher, 5)
+	d, ok := err.(Divergence)
+
+	if !ok {
+		t.Fatalf("verifyTrees()=%v, want a Divergence", err)
+	}
+
+	if got, want := d.Index, int64(12); got != want {
+		t.Fatalf("divergence at %d, want %d", got, want)
+	}
+}
+
+func TestVerifyTreesUsesTreeHasher(t *testing.T) {
+	hasher, err := merkle.NewTreeHasherForAlgorithm(trillian.HashAlgorithm_SHA512_256, trillian.LeafHashStrategy_RFC6962_LEAF_HASH)
+
+	if err != nil {
+		t.Fatalf("Failed to create tree hasher: %v", err)
+	}
+
+	if _, err := verifyTrees(context.Background(), newMemorySourceWithHasher(5, hasher), newMemorySourceWithHasher(5, hasher), hasher, 2); err != nil {
+		t.Fatalf("verifyTrees() with the log's hasher=%v", err)
+	}
+
+	if _, err := verifyTrees(context.Background(), newMemorySourceWithHasher(5, hasher), newMemorySourceWithHasher(5, hasher), testHasher, 2); err == nil {
+		t.Fatal("verifyTrees() with a different hasher to the log's returned no error")
+	}
+}
+
+func TestVerifyTreesBadLeafIndex(t *testing.T) {
+	right := newMemorySource(4)
+	right.leaves[2], right.leaves[3] = right.leaves[3], right.leaves[2]
+
+	if _, err := verifyTrees(context.Background(), newMemorySource(4), right, testHasher, 4); err == nil {
+		t.Fatal("verifyTrees() with out of order leaves returned no error")
+	}
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	src := newMemorySource(11)
+	var buf bytes.Buffer
+
+	if err := writeArchive(context.Background(), &buf, src, 4); err != nil {
+		t.Fatalf("writeArchive()=%v", err)
+	}
+
+	archive, err := newArchiveLeafSource(&buf)
+
+	if err != nil {
+		t.Fatalf("newArchiveLeafSource()=%v", err)
+	}
+
+	size, err := verifyTrees(context.Background(), src, archive, testHasher, 4)
+
+	if err != nil {
+		t.Fatalf("verifyTrees()=%v", err)
+	}
+
+	if got, want := size, int64(11); got != want {
+		t.Fatalf("verifyTrees()=%d, want %d", got, want)
+	}
+}
+
+func TestArchiveTruncated(t *testing.T) {
+	src := newMemorySource(5)
+	var buf bytes.Buffer
+
+	if err := writeArchive(context.Background(), &buf, src, 5); err != nil {
+		t.Fatalf("writeArchive()=%v", err)
+	}
+
+	// Drop the last leaf record
+	archive, err := newArchiveLeafSource(bytes.NewReader(buf.Bytes()[:buf.Len()-5]))
+
+	if err != nil {
+		t.Fatalf("newArchiveLeafSource()=%v", err)
+	}
+
+	if _, err := verifyTrees(context.Background(), src, archive, testHasher, 5); err == nil {
+		t.Fatal("verifyTrees() with truncated archive returned no error")
+	}
+}
+
+func TestArchiveMustBeReadInOrder(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := writeArchive(context.Background(), &buf, newMemorySource(5), 5); err != nil {
+		t.Fatalf("writeArchive()=%v", err)
+	}
+
+	archive, err := newArchiveLeafSource(&buf)
+
+	if err != nil {
+		t.Fatalf("newArchiveLeafSource()=%v", err)
+	}
+
+	if _, err := archive.Leaves(context.Background(), 2, 1); err == nil {
+		t.Fatal("Leaves() out of order returned no error")
+	}
+}
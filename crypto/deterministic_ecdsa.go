@@ -0,0 +1,217 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// DeterministicECDSASigner is a crypto.Signer that produces ECDSA signatures using nonces
+// derived from the private key and the digest as described in RFC 6979. Signing the same
+// digest twice gives the same signature, and the security of the key doesn't depend on the
+// quality of the random number source available when signing.
+type DeterministicECDSASigner struct {
+	key *ecdsa.PrivateKey
+}
+
+// NewDeterministicECDSASigner creates a DeterministicECDSASigner for an ECDSA private key.
+func NewDeterministicECDSASigner(key *ecdsa.PrivateKey) *DeterministicECDSASigner {
+	return &DeterministicECDSASigner{key}
+}
+
+// Public returns the public key corresponding to the private key.
+func (s DeterministicECDSASigner) Public() crypto.PublicKey {
+	return &s.key.PublicKey
+}
+
+// Sign signs a digest that was produced by the hash function given in opts. The random source
+// is not used. The signature is ASN.1 encoded in the same way as for ecdsa.PrivateKey.
+func (s DeterministicECDSASigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	hash := opts.HashFunc()
+
+	if !hash.Available() {
+		return nil, fmt.Errorf("hash function %v is not available for nonce generation", hash)
+	}
+
+	r, sig, err := signRFC6979(s.key, digest, hash)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(ecdsaSignature{r, sig})
+}
+
+// ecdsaSignature is the ASN.1 structure of an ECDSA signature
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// signRFC6979 signs a digest using successive nonces from the RFC 6979 generator until one
+// gives a valid signature.
+func signRFC6979(key *ecdsa.PrivateKey, digest []byte, hash crypto.Hash) (*big.Int, *big.Int, error) {
+	n := key.Curve.Params().N
+
+	if n.Sign() == 0 {
+		return nil, nil, errors.New("curve has zero order")
+	}
+
+	e := bits2int(digest, n.BitLen())
+	nonces := newNonceGenerator(key.D, digest, n, hash)
+
+	for {
+		k := nonces.next()
+
+		r, _ := key.Curve.ScalarBaseMult(k.Bytes())
+		r.Mod(r, n)
+
+		if r.Sign() == 0 {
+			continue
+		}
+
+		// s = k^-1 * (e + d*r) mod n
+		s := new(big.Int).Mul(key.D, r)
+		s.Add(s, e)
+		s.Mul(s, new(big.Int).ModInverse(k, n))
+		s.Mod(s, n)
+
+		if s.Sign() != 0 {
+			return r, s, nil
+		}
+	}
+}
+
+// nonceGenerator produces the sequence of candidate nonces defined in section 3.2 of RFC 6979.
+type nonceGenerator struct {
+	n    *big.Int
+	hash crypto.Hash
+	k    []byte
+	v    []byte
+	// started is set after the first nonce has been returned, as later ones need K and V to be
+	// updated first
+	started bool
+}
+
+func newNonceGenerator(x *big.Int, digest []byte, n *big.Int, hash crypto.Hash) *nonceGenerator {
+	g := &nonceGenerator{n: n, hash: hash}
+	rlen := (n.BitLen() + 7) / 8
+
+	// Steps b and c
+	g.v = make([]byte, hash.Size())
+	g.k = make([]byte, hash.Size())
+
+	for i := range g.v {
+		g.v[i] = 0x01
+	}
+
+	// The digest reduced mod n, as in bits2octets
+	h := bits2int(digest, n.BitLen())
+
+	if h.Cmp(n) >= 0 {
+		h.Sub(h, n)
+	}
+
+	seed := append(int2octets(x, rlen), int2octets(h, rlen)...)
+
+	// Steps d to g
+	g.k = g.mac(g.v, []byte{0x00}, seed)
+	g.v = g.mac(g.v)
+	g.k = g.mac(g.v, []byte{0x01}, seed)
+	g.v = g.mac(g.v)
+
+	return g
+}
+
+func (g *nonceGenerator) mac(data ...[]byte) []byte {
+	m := hmac.New(g.hash.New, g.k)
+
+	for _, d := range data {
+		m.Write(d)
+	}
+
+	return m.Sum(nil)
+}
+
+// next returns the next candidate nonce in the range [1, n-1].
+func (g *nonceGenerator) next() *big.Int {
+	rlen := (g.n.BitLen() + 7) / 8
+
+	for {
+		if g.started {
+			g.k = g.mac(g.v, []byte{0x00})
+			g.v = g.mac(g.v)
+		}
+
+		g.started = true
+
+		// Step h
+		t := make([]byte, 0, rlen)
+
+		for len(t) < rlen {
+			g.v = g.mac(g.v)
+			t = append(t, g.v...)
+		}
+
+		k := bits2int(t, g.n.BitLen())
+
+		if k.Sign() > 0 && k.Cmp(g.n) < 0 {
+			return k
+		}
+	}
+}
+
+// bits2int converts a byte string to an integer using its leftmost qlen bits.
+func bits2int(b []byte, qlen int) *big.Int {
+	i := new(big.Int).SetBytes(b)
+
+	if excess := len(b)*8 - qlen; excess > 0 {
+		i.Rsh(i, uint(excess))
+	}
+
+	return i
+}
+
+// int2octets returns x as a big endian byte string of length rlen.
+func int2octets(x *big.Int, rlen int) []byte {
+	b := x.Bytes()
+
+	if len(b) >= rlen {
+		return b[len(b)-rlen:]
+	}
+
+	return append(make([]byte, rlen-len(b)), b...)
+}
+
+// deterministicKeyManager wraps a KeyManager so that signers for ECDSA keys use deterministic
+// nonces.
+type deterministicKeyManager struct {
+	KeyManager
+}
+
+// NewDeterministicKeyManager returns a KeyManager for the same keys as km whose Signer produces
+// RFC 6979 deterministic signatures if the private key is an ECDSA key. Signers for other key
+// types are returned unchanged.
+func NewDeterministicKeyManager(km KeyManager) KeyManager {
+	return deterministicKeyManager{km}
+}
+
+// Signer returns a deterministic signer for ECDSA keys or the wrapped KeyManager's signer for
+// other keys.
+func (k deterministicKeyManager) Signer() (crypto.Signer, error) {
+	signer, err := k.KeyManager.Signer()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if key, ok := signer.(*ecdsa.PrivateKey); ok {
+		return NewDeterministicECDSASigner(key), nil
+	}
+
+	return signer, nil
+}
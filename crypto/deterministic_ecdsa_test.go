@@ -0,0 +1,173 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/testonly"
+)
+
+// rfc6979P256Key is the P-256 key from appendix A.2.5 of RFC 6979
+func rfc6979P256Key(t *testing.T) *ecdsa.PrivateKey {
+	key := &ecdsa.PrivateKey{D: mustHexInt(t, "C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721")}
+	key.Curve = elliptic.P256()
+	key.X, key.Y = key.Curve.ScalarBaseMult(key.D.Bytes())
+
+	if got, want := key.X, mustHexInt(t, "60FED4BA255A9D31C961EB74C6356D68C049B8923B61FA6CE669622E60F29FB6"); got.Cmp(want) != 0 {
+		t.Fatalf("Test key has public X=%x, want %x", got, want)
+	}
+
+	return key
+}
+
+func mustHexInt(t *testing.T, s string) *big.Int {
+	i, ok := new(big.Int).SetString(s, 16)
+
+	if !ok {
+		t.Fatalf("Invalid hex in test: %s", s)
+	}
+
+	return i
+}
+
+func TestDeterministicECDSATestVectors(t *testing.T) {
+	key := rfc6979P256Key(t)
+
+	for _, test := range []struct {
+		hash    crypto.Hash
+		message string
+		k       string
+		r       string
+		s       string
+	}{
+		{
+			hash:    crypto.SHA256,
+			message: "sample",
+			k:       "A6E3C57DD01ABE90086538398355DD4C3B17AA873382B0F24D6129493D8AAD60",
+			r:       "EFD48B2AACB6A8FD1140DD9CD45E81D69D2C877B56AAF991C34D0EA84EAF3716",
+			s:       "F7CB1C942D657C41D436C7A1B6E29F65F3E900DBB9AFF4064DC4AB2F843ACDA8",
+		},
+		{
+			hash:    crypto.SHA256,
+			message: "test",
+			k:       "D16B6AE827F17175E040871A1C7EC3500192C4C92677336EC2537ACAEE0008E0",
+			r:       "F1ABB023518351CD71D881567B1EA663ED3EFCF6C5132B354F28D3B0B7D38367",
+			s:       "019F4113742A2B14BD25926B49C649155F267E60D3814B4C0CC84250E46F0083",
+		},
+	} {
+		h := test.hash.New()
+		h.Write([]byte(test.message))
+		digest := h.Sum(nil)
+
+		if got, want := newNonceGenerator(key.D, digest, key.Params().N, test.hash).next(), mustHexInt(t, test.k); got.Cmp(want) != 0 {
+			t.Errorf("%v(%q): got nonce %X, want %X", test.hash, test.message, got, want)
+		}
+
+		signed, err := NewDeterministicECDSASigner(key).Sign(nil, digest, test.hash)
+
+		if err != nil {
+			t.Fatalf("%v(%q): failed to sign: %v", test.hash, test.message, err)
+		}
+
+		var sig ecdsaSignature
+		if _, err := asn1.Unmarshal(signed, &sig); err != nil {
+			t.Fatalf("%v(%q): failed to unmarshal signature: %v", test.hash, test.message, err)
+		}
+
+		if got, want := sig.R, mustHexInt(t, test.r); got.Cmp(want) != 0 {
+			t.Errorf("%v(%q): got r=%X, want %X", test.hash, test.message, got, want)
+		}
+
+		if got, want := sig.S, mustHexInt(t, test.s); got.Cmp(want) != 0 {
+			t.Errorf("%v(%q): got s=%X, want %X", test.hash, test.message, got, want)
+		}
+
+		if !ecdsa.Verify(&key.PublicKey, digest, sig.R, sig.S) {
+			t.Errorf("%v(%q): signature did not verify", test.hash, test.message)
+		}
+	}
+}
+
+func TestDeterministicECDSAIsDeterministic(t *testing.T) {
+	km := NewPEMKeyManager()
+
+	if err := km.LoadPrivateKey(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass); err != nil {
+		t.Fatalf("Failed to load key: %v", err)
+	}
+
+	signer, err := NewDeterministicKeyManager(km).Signer()
+
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+
+	if _, ok := signer.(*DeterministicECDSASigner); !ok {
+		t.Fatalf("Expected a deterministic signer for an ECDSA key but got: %T", signer)
+	}
+
+	trillianSigner := NewTrillianSigner(trillian.NewSHA256(), trillian.SignatureAlgorithm_ECDSA, signer)
+
+	sig1, err := trillianSigner.Sign([]byte("hello"))
+
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+
+	sig2, err := trillianSigner.Sign([]byte("hello"))
+
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+
+	if got, want := string(sig2.Signature), string(sig1.Signature); got != want {
+		t.Fatalf("Signatures of the same data differ: %x, %x", got, want)
+	}
+
+	sig3, err := trillianSigner.Sign([]byte("goodbye"))
+
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+
+	if string(sig3.Signature) == string(sig1.Signature) {
+		t.Fatal("Signatures of different data are the same")
+	}
+}
+
+func TestDeterministicKeyManagerPassesThroughOtherSigners(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockSigner := NewMockSigner(ctrl)
+	mockKeyManager := NewMockKeyManager(ctrl)
+	mockKeyManager.EXPECT().Signer().Return(mockSigner, nil)
+
+	signer, err := NewDeterministicKeyManager(mockKeyManager).Signer()
+
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+
+	if signer != mockSigner {
+		t.Fatalf("Expected the wrapped signer to be returned but got: %v", signer)
+	}
+}
+
+func TestDeterministicECDSARejectsUnavailableHash(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	if _, err := NewDeterministicECDSASigner(key).Sign(nil, []byte("digest"), crypto.Hash(0)); err == nil {
+		t.Fatal("Expected signing with no hash function to fail")
+	}
+}
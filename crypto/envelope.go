@@ -0,0 +1,120 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DataKeySize is the size in bytes of the keys used to encrypt tree data. They are AES-256 keys.
+const DataKeySize = 32
+
+// KeyWrapper protects the data keys used to encrypt tree data with a key that is held
+// elsewhere, typically by a key management service. Only wrapped data keys are stored with
+// the data. Implementations must be safe for concurrent use.
+type KeyWrapper interface {
+	// WrapKey encrypts the data key for a tree so that it can be stored.
+	WrapKey(treeID int64, key []byte) ([]byte, error)
+	// UnwrapKey recovers a data key that was wrapped by WrapKey for the same tree.
+	UnwrapKey(treeID int64, wrapped []byte) ([]byte, error)
+}
+
+// NewDataKey returns a new random data key.
+func NewDataKey() ([]byte, error) {
+	key := make([]byte, DataKeySize)
+
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// DataCipher encrypts and decrypts data with a single key using AES-GCM. The output of
+// Encrypt is the random nonce followed by the sealed data. It is safe for concurrent use.
+type DataCipher struct {
+	aead cipher.AEAD
+}
+
+// NewDataCipher creates a DataCipher for a key, which must be DataKeySize bytes.
+func NewDataCipher(key []byte) (*DataCipher, error) {
+	if len(key) != DataKeySize {
+		return nil, fmt.Errorf("data key must be %d bytes, got %d", DataKeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &DataCipher{aead: aead}, nil
+}
+
+// Encrypt seals plaintext. The additionalData is authenticated but not encrypted and the same
+// value must be passed to Decrypt. Use it to bind the ciphertext to where it is stored so it
+// can't be moved elsewhere.
+func (d *DataCipher) Encrypt(plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, d.aead.NonceSize())
+
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return d.aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Decrypt opens ciphertext produced by Encrypt, returning an error if it has been modified.
+func (d *DataCipher) Decrypt(ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < d.aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+
+	nonce := ciphertext[:d.aead.NonceSize()]
+
+	return d.aead.Open(nil, nonce, ciphertext[d.aead.NonceSize():], additionalData)
+}
+
+// AESKeyWrapper is a KeyWrapper that wraps data keys with a master key held in memory. It's
+// suitable for testing and for deployments that don't have a key management service.
+type AESKeyWrapper struct {
+	master *DataCipher
+}
+
+// NewAESKeyWrapper creates an AESKeyWrapper using masterKey, which must be DataKeySize bytes.
+func NewAESKeyWrapper(masterKey []byte) (*AESKeyWrapper, error) {
+	master, err := NewDataCipher(masterKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &AESKeyWrapper{master: master}, nil
+}
+
+// WrapKey implements KeyWrapper. The tree ID is authenticated so a wrapped key can only be
+// used with the tree it was created for.
+func (a *AESKeyWrapper) WrapKey(treeID int64, key []byte) ([]byte, error) {
+	return a.master.Encrypt(key, treeIDBytes(treeID))
+}
+
+// UnwrapKey implements KeyWrapper.
+func (a *AESKeyWrapper) UnwrapKey(treeID int64, wrapped []byte) ([]byte, error) {
+	return a.master.Decrypt(wrapped, treeIDBytes(treeID))
+}
+
+func treeIDBytes(treeID int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(treeID))
+	return b
+}
@@ -0,0 +1,124 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func newDataCipherOrDie(t *testing.T) *DataCipher {
+	key, err := NewDataKey()
+
+	if err != nil {
+		t.Fatalf("Failed to create data key: %v", err)
+	}
+
+	c, err := NewDataCipher(key)
+
+	if err != nil {
+		t.Fatalf("Failed to create data cipher: %v", err)
+	}
+
+	return c
+}
+
+func TestNewDataCipherBadKeySize(t *testing.T) {
+	if _, err := NewDataCipher(make([]byte, 16)); err == nil {
+		t.Fatal("Created data cipher with a short key")
+	}
+}
+
+func TestDataCipherRoundTrip(t *testing.T) {
+	c := newDataCipherOrDie(t)
+	plaintext := []byte("some leaf data")
+
+	ciphertext, err := c.Encrypt(plaintext, []byte("ad"))
+
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	if bytes.Contains(ciphertext, plaintext) {
+		t.Fatalf("Ciphertext contains the plaintext: %v", ciphertext)
+	}
+
+	got, err := c.Decrypt(ciphertext, []byte("ad"))
+
+	if err != nil {
+		t.Fatalf("Failed to decrypt: %v", err)
+	}
+
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("Got %v after decryption, expected %v", got, plaintext)
+	}
+
+	// The nonce is random so the same data shouldn't encrypt the same way twice
+	ciphertext2, err := c.Encrypt(plaintext, []byte("ad"))
+
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	if bytes.Equal(ciphertext, ciphertext2) {
+		t.Fatal("Encrypting twice gave the same ciphertext")
+	}
+}
+
+func TestDataCipherRejectsTampering(t *testing.T) {
+	c := newDataCipherOrDie(t)
+
+	ciphertext, err := c.Encrypt([]byte("some leaf data"), []byte("ad"))
+
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+
+	if _, err := c.Decrypt(ciphertext, []byte("other ad")); err == nil {
+		t.Fatal("Decrypted with the wrong additional data")
+	}
+
+	ciphertext[len(ciphertext)-1] ^= 1
+
+	if _, err := c.Decrypt(ciphertext, []byte("ad")); err == nil {
+		t.Fatal("Decrypted modified ciphertext")
+	}
+
+	if _, err := c.Decrypt([]byte("short"), []byte("ad")); err == nil {
+		t.Fatal("Decrypted truncated ciphertext")
+	}
+}
+
+func TestAESKeyWrapper(t *testing.T) {
+	master, err := NewDataKey()
+
+	if err != nil {
+		t.Fatalf("Failed to create master key: %v", err)
+	}
+
+	w, err := NewAESKeyWrapper(master)
+
+	if err != nil {
+		t.Fatalf("Failed to create key wrapper: %v", err)
+	}
+
+	key := bytes.Repeat([]byte{0x42}, DataKeySize)
+	wrapped, err := w.WrapKey(7, key)
+
+	if err != nil {
+		t.Fatalf("Failed to wrap key: %v", err)
+	}
+
+	got, err := w.UnwrapKey(7, wrapped)
+
+	if err != nil {
+		t.Fatalf("Failed to unwrap key: %v", err)
+	}
+
+	if !bytes.Equal(got, key) {
+		t.Fatalf("Got key %v after unwrapping, expected %v", got, key)
+	}
+
+	// A wrapped key can't be used for a different tree
+	if _, err := w.UnwrapKey(8, wrapped); err == nil {
+		t.Fatal("Unwrapped key for the wrong tree")
+	}
+}
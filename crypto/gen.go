@@ -1,4 +1,6 @@
 package crypto
 
+//go:generate sh -c "cd $GOPATH/src && protoc --go_out=plugins=grpc:. github.com/google/trillian/crypto/*.proto"
+
 //go:generate mockgen -package crypto -destination mock_signer.go crypto Signer
 //go:generate mockgen -self_package github.com/google/trillian/crypto -package crypto -destination mock_key_manager.go github.com/google/trillian/crypto KeyManager
@@ -0,0 +1,199 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// RemoteKeyManager is a KeyManager for a key held by a SigningService in another process, so
+// that servers that sign, such as the log server and the CT frontend, never load the private
+// key and a compromise of them doesn't leak it. Only digests are sent to the service. The
+// public key is fetched once, when the manager is created.
+type RemoteKeyManager struct {
+	client       SigningServiceClient
+	keyID        string
+	timeout      time.Duration
+	publicKey    crypto.PublicKey
+	rawPublicKey []byte
+}
+
+// NewRemoteKeyManager creates a RemoteKeyManager for the key named keyID held by the signing
+// service that client is connected to. Each request to the service fails if it takes longer
+// than timeout.
+func NewRemoteKeyManager(client SigningServiceClient, keyID string, timeout time.Duration) (*RemoteKeyManager, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	resp, err := client.GetPublicKey(ctx, &GetPublicKeyRequest{KeyId: keyID})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get public key %s from the signing service: %v", keyID, err)
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(resp.PublicKeyDer)
+
+	if err != nil {
+		return nil, fmt.Errorf("signing service returned an invalid public key for %s: %v", keyID, err)
+	}
+
+	return &RemoteKeyManager{client: client, keyID: keyID, timeout: timeout, publicKey: publicKey, rawPublicKey: resp.PublicKeyDer}, nil
+}
+
+// Signer returns a signer that asks the signing service for signatures.
+func (k *RemoteKeyManager) Signer() (crypto.Signer, error) {
+	return remoteSigner{k}, nil
+}
+
+// GetPublicKey returns the public key of the remote key.
+func (k *RemoteKeyManager) GetPublicKey() (crypto.PublicKey, error) {
+	return k.publicKey, nil
+}
+
+// GetRawPublicKey returns the DER encoded public key of the remote key.
+func (k *RemoteKeyManager) GetRawPublicKey() ([]byte, error) {
+	return k.rawPublicKey, nil
+}
+
+// remoteSigner signs with the key of a RemoteKeyManager
+type remoteSigner struct {
+	km *RemoteKeyManager
+}
+
+func (s remoteSigner) Public() crypto.PublicKey {
+	return s.km.publicKey
+}
+
+// Sign asks the signing service to sign digest. rand is ignored, the service uses its own
+// randomness. RSA keys sign with PSS if opts are rsa.PSSOptions.
+func (s remoteSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	req := &SignRequest{KeyId: s.km.keyID, Digest: digest, Hash: uint32(opts.HashFunc())}
+
+	if pss, ok := opts.(*rsa.PSSOptions); ok {
+		req.RsaPss = true
+		req.PssSaltLength = int32(pss.SaltLength)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.km.timeout)
+	defer cancel()
+
+	resp, err := s.km.client.Sign(ctx, req)
+
+	if err != nil {
+		return nil, fmt.Errorf("signing service failed to sign with %s: %v", s.km.keyID, err)
+	}
+
+	return resp.Signature, nil
+}
+
+// signingKey is a key held by a SigningServer
+type signingKey struct {
+	signer       crypto.Signer
+	publicKeyDER []byte
+}
+
+// SigningServer is the reference SigningServiceServer. It signs with keys loaded into its
+// process, which cmd/signing_server reads from encrypted PEM files, and should be run on a
+// host that only the servers that sign can reach. Only SHA-256, SHA-384 and SHA-512 digests
+// are signed. It is safe for concurrent use.
+type SigningServer struct {
+	// mu guards keys
+	mu sync.RWMutex
+	// keys maps key IDs to the keys
+	keys map[string]signingKey
+}
+
+// NewSigningServer creates a SigningServer without any keys.
+func NewSigningServer() *SigningServer {
+	return &SigningServer{keys: make(map[string]signingKey)}
+}
+
+// AddKey makes the private key of km available as keyID, replacing any key with that ID.
+func (s *SigningServer) AddKey(keyID string, km KeyManager) error {
+	signer, err := km.Signer()
+
+	if err != nil {
+		return err
+	}
+
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(signer.Public())
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal public key %s: %v", keyID, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[keyID] = signingKey{signer: signer, publicKeyDER: publicKeyDER}
+
+	return nil
+}
+
+func (s *SigningServer) key(keyID string) (signingKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.keys[keyID]
+
+	if !ok {
+		return signingKey{}, grpc.Errorf(codes.NotFound, "unknown key: %s", keyID)
+	}
+
+	return key, nil
+}
+
+// Sign signs a digest with one of the server's keys.
+func (s *SigningServer) Sign(ctx context.Context, req *SignRequest) (*SignResponse, error) {
+	key, err := s.key(req.KeyId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	hash := crypto.Hash(req.Hash)
+
+	switch hash {
+	case crypto.SHA256, crypto.SHA384, crypto.SHA512:
+	default:
+		return nil, grpc.Errorf(codes.InvalidArgument, "unsupported hash: %d", req.Hash)
+	}
+
+	if len(req.Digest) != hash.Size() {
+		return nil, grpc.Errorf(codes.InvalidArgument, "digest has %d bytes, expected %d", len(req.Digest), hash.Size())
+	}
+
+	var opts crypto.SignerOpts = hash
+
+	if req.RsaPss {
+		opts = &rsa.PSSOptions{SaltLength: int(req.PssSaltLength), Hash: hash}
+	}
+
+	signature, err := key.signer.Sign(rand.Reader, req.Digest, opts)
+
+	if err != nil {
+		return nil, grpc.Errorf(codes.Internal, "failed to sign with %s: %v", req.KeyId, err)
+	}
+
+	return &SignResponse{Signature: signature}, nil
+}
+
+// GetPublicKey returns the public key of one of the server's keys.
+func (s *SigningServer) GetPublicKey(ctx context.Context, req *GetPublicKeyRequest) (*GetPublicKeyResponse, error) {
+	key, err := s.key(req.KeyId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &GetPublicKeyResponse{PublicKeyDer: key.publicKeyDER}, nil
+}
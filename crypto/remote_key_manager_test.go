@@ -0,0 +1,155 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"testing"
+	"time"
+
+	"github.com/google/trillian/testonly"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// localSigningClient calls a SigningServiceServer directly rather than over a connection
+type localSigningClient struct {
+	server SigningServiceServer
+}
+
+func (c localSigningClient) Sign(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (*SignResponse, error) {
+	return c.server.Sign(ctx, in)
+}
+
+func (c localSigningClient) GetPublicKey(ctx context.Context, in *GetPublicKeyRequest, opts ...grpc.CallOption) (*GetPublicKeyResponse, error) {
+	return c.server.GetPublicKey(ctx, in)
+}
+
+func newTestSigningServer(t *testing.T) *SigningServer {
+	km := NewPEMKeyManager()
+
+	if err := km.LoadPrivateKey(testonly.DemoPrivateKey, testonly.DemoPrivateKeyPass); err != nil {
+		t.Fatalf("Failed to load key: %v", err)
+	}
+
+	server := NewSigningServer()
+
+	if err := server.AddKey("demo", km); err != nil {
+		t.Fatalf("Failed to add key: %v", err)
+	}
+
+	return server
+}
+
+func TestRemoteKeyManagerSignsECDSA(t *testing.T) {
+	km, err := NewRemoteKeyManager(localSigningClient{newTestSigningServer(t)}, "demo", time.Second)
+
+	if err != nil {
+		t.Fatalf("Failed to create remote key manager: %v", err)
+	}
+
+	// The public key comes from the service, it isn't configured locally
+	publicKey, err := km.GetPublicKey()
+
+	if err != nil {
+		t.Fatalf("Failed to get public key: %v", err)
+	}
+
+	ecdsaKey, ok := publicKey.(*ecdsa.PublicKey)
+
+	if !ok {
+		t.Fatalf("Got public key of type %T, expected ECDSA", publicKey)
+	}
+
+	signer, err := km.Signer()
+
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+
+	digest := sha256.Sum256([]byte("data to sign"))
+	signature, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+
+	var sig ecdsaSig
+	if _, err := asn1.Unmarshal(signature, &sig); err != nil {
+		t.Fatalf("Failed to unmarshal signature: %v", err)
+	}
+
+	if !ecdsa.Verify(ecdsaKey, digest[:], sig.R, sig.S) {
+		t.Error("Signature from the signing service doesn't verify with its public key")
+	}
+}
+
+func TestRemoteKeyManagerSignsRSAPSS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	server := NewSigningServer()
+
+	if err := server.AddKey("rsa", PEMKeyManager{}.NewPEMKeyManager(key)); err != nil {
+		t.Fatalf("Failed to add key: %v", err)
+	}
+
+	km, err := NewRemoteKeyManager(localSigningClient{server}, "rsa", time.Second)
+
+	if err != nil {
+		t.Fatalf("Failed to create remote key manager: %v", err)
+	}
+
+	signer, err := km.Signer()
+
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+
+	digest := sha256.Sum256([]byte("data to sign"))
+	opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+	signature, err := signer.Sign(rand.Reader, digest[:], opts)
+
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+
+	if err := rsa.VerifyPSS(&key.PublicKey, crypto.SHA256, digest[:], signature, opts); err != nil {
+		t.Errorf("PSS signature from the signing service doesn't verify: %v", err)
+	}
+
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err == nil {
+		t.Error("PSS signature verified as PKCS #1 v1.5")
+	}
+}
+
+func TestSigningServerRejectsBadRequests(t *testing.T) {
+	server := newTestSigningServer(t)
+	digest := sha256.Sum256([]byte("data to sign"))
+
+	for _, test := range []struct {
+		desc string
+		req  *SignRequest
+		code codes.Code
+	}{
+		{"unknown key", &SignRequest{KeyId: "other", Digest: digest[:], Hash: uint32(crypto.SHA256)}, codes.NotFound},
+		{"unsupported hash", &SignRequest{KeyId: "demo", Digest: digest[:16], Hash: uint32(crypto.MD5)}, codes.InvalidArgument},
+		{"no hash", &SignRequest{KeyId: "demo", Digest: digest[:]}, codes.InvalidArgument},
+		{"wrong digest length", &SignRequest{KeyId: "demo", Digest: digest[:20], Hash: uint32(crypto.SHA256)}, codes.InvalidArgument},
+	} {
+		if _, err := server.Sign(context.Background(), test.req); grpc.Code(err) != test.code {
+			t.Errorf("%s: got error %v, expected code %v", test.desc, err, test.code)
+		}
+	}
+
+	if _, err := NewRemoteKeyManager(localSigningClient{server}, "other", time.Second); err == nil {
+		t.Error("Created a remote key manager for an unknown key")
+	}
+}
@@ -0,0 +1,231 @@
+// Code generated by protoc-gen-go.
+// source: github.com/google/trillian/crypto/signing_service.proto
+// DO NOT EDIT!
+
+/*
+Package crypto is a generated protocol buffer package.
+
+It is generated from these files:
+	github.com/google/trillian/crypto/signing_service.proto
+
+It has these top-level messages:
+	SignRequest
+	SignResponse
+	GetPublicKeyRequest
+	GetPublicKeyResponse
+*/
+package crypto
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+// SignRequest asks for a digest to be signed with one of the service's keys.
+type SignRequest struct {
+	// The name of the key, a service may hold several
+	KeyId string `protobuf:"bytes,1,opt,name=key_id,json=keyId" json:"key_id,omitempty"`
+	// The hash of the data to sign
+	Digest []byte `protobuf:"bytes,2,opt,name=digest,proto3" json:"digest,omitempty"`
+	// The hash function the digest was computed with, as the value of the Go crypto.Hash
+	Hash uint32 `protobuf:"varint,3,opt,name=hash" json:"hash,omitempty"`
+	// If set an RSA key signs with RSASSA-PSS rather than PKCS #1 v1.5
+	RsaPss bool `protobuf:"varint,4,opt,name=rsa_pss,json=rsaPss" json:"rsa_pss,omitempty"`
+	// The PSS salt length, as in the Go rsa.PSSOptions. Ignored unless rsa_pss is set.
+	PssSaltLength int32 `protobuf:"varint,5,opt,name=pss_salt_length,json=pssSaltLength" json:"pss_salt_length,omitempty"`
+}
+
+func (m *SignRequest) Reset()                    { *m = SignRequest{} }
+func (m *SignRequest) String() string            { return proto.CompactTextString(m) }
+func (*SignRequest) ProtoMessage()               {}
+func (*SignRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+type SignResponse struct {
+	// The signature in the format the Go crypto.Signer for the key returns, ASN.1 for ECDSA
+	Signature []byte `protobuf:"bytes,1,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *SignResponse) Reset()                    { *m = SignResponse{} }
+func (m *SignResponse) String() string            { return proto.CompactTextString(m) }
+func (*SignResponse) ProtoMessage()               {}
+func (*SignResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+// GetPublicKeyRequest asks for the public key of one of the service's keys.
+type GetPublicKeyRequest struct {
+	KeyId string `protobuf:"bytes,1,opt,name=key_id,json=keyId" json:"key_id,omitempty"`
+}
+
+func (m *GetPublicKeyRequest) Reset()                    { *m = GetPublicKeyRequest{} }
+func (m *GetPublicKeyRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetPublicKeyRequest) ProtoMessage()               {}
+func (*GetPublicKeyRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+type GetPublicKeyResponse struct {
+	// The DER encoded PKIX public key
+	PublicKeyDer []byte `protobuf:"bytes,1,opt,name=public_key_der,json=publicKeyDer,proto3" json:"public_key_der,omitempty"`
+}
+
+func (m *GetPublicKeyResponse) Reset()                    { *m = GetPublicKeyResponse{} }
+func (m *GetPublicKeyResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetPublicKeyResponse) ProtoMessage()               {}
+func (*GetPublicKeyResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func init() {
+	proto.RegisterType((*SignRequest)(nil), "crypto.SignRequest")
+	proto.RegisterType((*SignResponse)(nil), "crypto.SignResponse")
+	proto.RegisterType((*GetPublicKeyRequest)(nil), "crypto.GetPublicKeyRequest")
+	proto.RegisterType((*GetPublicKeyResponse)(nil), "crypto.GetPublicKeyResponse")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion3
+
+// Client API for SigningService service
+
+type SigningServiceClient interface {
+	// Signs a digest
+	Sign(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (*SignResponse, error)
+	// Returns the public key that verifies a key's signatures
+	GetPublicKey(ctx context.Context, in *GetPublicKeyRequest, opts ...grpc.CallOption) (*GetPublicKeyResponse, error)
+}
+
+type signingServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewSigningServiceClient(cc *grpc.ClientConn) SigningServiceClient {
+	return &signingServiceClient{cc}
+}
+
+func (c *signingServiceClient) Sign(ctx context.Context, in *SignRequest, opts ...grpc.CallOption) (*SignResponse, error) {
+	out := new(SignResponse)
+	err := grpc.Invoke(ctx, "/crypto.SigningService/Sign", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *signingServiceClient) GetPublicKey(ctx context.Context, in *GetPublicKeyRequest, opts ...grpc.CallOption) (*GetPublicKeyResponse, error) {
+	out := new(GetPublicKeyResponse)
+	err := grpc.Invoke(ctx, "/crypto.SigningService/GetPublicKey", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for SigningService service
+
+type SigningServiceServer interface {
+	// Signs a digest
+	Sign(context.Context, *SignRequest) (*SignResponse, error)
+	// Returns the public key that verifies a key's signatures
+	GetPublicKey(context.Context, *GetPublicKeyRequest) (*GetPublicKeyResponse, error)
+}
+
+func RegisterSigningServiceServer(s *grpc.Server, srv SigningServiceServer) {
+	s.RegisterService(&_SigningService_serviceDesc, srv)
+}
+
+func _SigningService_Sign_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SigningServiceServer).Sign(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/crypto.SigningService/Sign",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SigningServiceServer).Sign(ctx, req.(*SignRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SigningService_GetPublicKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPublicKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SigningServiceServer).GetPublicKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/crypto.SigningService/GetPublicKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SigningServiceServer).GetPublicKey(ctx, req.(*GetPublicKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _SigningService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "crypto.SigningService",
+	HandlerType: (*SigningServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Sign",
+			Handler:    _SigningService_Sign_Handler,
+		},
+		{
+			MethodName: "GetPublicKey",
+			Handler:    _SigningService_GetPublicKey_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: fileDescriptor0,
+}
+
+func init() {
+	proto.RegisterFile("github.com/google/trillian/crypto/signing_service.proto", fileDescriptor0)
+}
+
+var fileDescriptor0 = []byte{
+	// 322 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7d, 0x91, 0xc1, 0x4a, 0xc3, 0x40,
+	0x10, 0x86, 0x8d, 0xa6, 0xd1, 0x8e, 0x69, 0x85, 0x6d, 0xd5, 0x50, 0x7b, 0x90, 0x20, 0xe2, 0x41,
+	0x12, 0xb0, 0x07, 0x2f, 0x1e, 0x05, 0x91, 0x7a, 0x28, 0x9b, 0x07, 0x08, 0x69, 0x32, 0x6c, 0x16,
+	0x63, 0x36, 0xee, 0x6e, 0x84, 0x3e, 0x86, 0x3e, 0xb1, 0xdb, 0x24, 0xc5, 0x16, 0x8a, 0xb7, 0x9d,
+	0x6f, 0xfe, 0xdd, 0x7f, 0xe6, 0x5f, 0x78, 0x64, 0x5c, 0xe7, 0xf5, 0x32, 0x48, 0xc5, 0x47, 0xc8,
+	0x84, 0x60, 0x05, 0x86, 0x5a, 0xf2, 0xa2, 0xe0, 0x49, 0x19, 0xa6, 0x72, 0x55, 0x69, 0x11, 0x2a,
+	0xce, 0x4a, 0x5e, 0xb2, 0x58, 0xa1, 0xfc, 0xe2, 0x29, 0x06, 0x95, 0x14, 0x5a, 0x10, 0xa7, 0xed,
+	0xfa, 0xdf, 0x16, 0x9c, 0x46, 0x46, 0x41, 0xf1, 0xb3, 0x46, 0xa5, 0xc9, 0x39, 0x38, 0xef, 0xb8,
+	0x8a, 0x79, 0xe6, 0x59, 0xd7, 0xd6, 0x5d, 0x9f, 0xf6, 0x4c, 0xf5, 0x9a, 0x91, 0x0b, 0x70, 0x32,
+	0xce, 0x8c, 0xc0, 0x3b, 0x34, 0xd8, 0xa5, 0x5d, 0x45, 0x08, 0xd8, 0x79, 0xa2, 0x72, 0xef, 0xc8,
+	0xd0, 0x01, 0x6d, 0xce, 0xe4, 0x12, 0x8e, 0xa5, 0x4a, 0xe2, 0x4a, 0x29, 0xcf, 0x36, 0xf8, 0x84,
+	0x3a, 0xa6, 0x5c, 0x28, 0x45, 0x6e, 0xe1, 0xcc, 0xc0, 0x58, 0x25, 0x85, 0x8e, 0x0b, 0x2c, 0x99,
+	0xce, 0xbd, 0x9e, 0x11, 0xf4, 0xe8, 0xc0, 0xe0, 0xc8, 0xd0, 0xb7, 0x06, 0xfa, 0xf7, 0xe0, 0xb6,
+	0x23, 0xa9, 0x4a, 0x94, 0x0a, 0xc9, 0x14, 0xfa, 0xeb, 0x25, 0x12, 0x5d, 0x4b, 0x6c, 0xc6, 0x72,
+	0xe9, 0x1f, 0x30, 0xea, 0xd1, 0x0b, 0xea, 0x45, 0xbd, 0x2c, 0x78, 0x3a, 0xc7, 0xd5, 0xff, 0x8b,
+	0xf8, 0x4f, 0x30, 0xde, 0x55, 0x77, 0x1e, 0x37, 0x30, 0xac, 0x1a, 0x18, 0xaf, 0x6f, 0x65, 0x28,
+	0x3b, 0x23, 0xb7, 0xda, 0x48, 0x9f, 0x51, 0x3e, 0xfc, 0x58, 0x30, 0x8c, 0xda, 0x3c, 0xa3, 0x36,
+	0x4e, 0x32, 0x03, 0x7b, 0x4d, 0xc8, 0x28, 0x68, 0x13, 0x0d, 0xb6, 0xd2, 0x9c, 0x8c, 0x77, 0x61,
+	0xeb, 0xe5, 0x1f, 0x90, 0x39, 0xb8, 0xdb, 0x53, 0x90, 0xab, 0x8d, 0x6e, 0xcf, 0x26, 0x93, 0xe9,
+	0xfe, 0xe6, 0xe6, 0xb1, 0xa5, 0xd3, 0xfc, 0xe8, 0xec, 0x17, 0x14, 0x4d, 0x41, 0xfe, 0x0c, 0x02,
+	0x00, 0x00,
+}
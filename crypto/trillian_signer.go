@@ -2,8 +2,12 @@ package crypto
 
 import (
 	"crypto"
+	"crypto/ecdsa"
 	"crypto/rand"
+	"crypto/rsa"
+	"encoding/asn1"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"strconv"
 
@@ -18,6 +22,7 @@ const (
 	mapKeyRootHash       string = "RootHash"
 	mapKeyTimestampNanos string = "TimestampNanos"
 	mapKeyTreeSize       string = "TreeSize"
+	mapKeyMetadata       string = "Metadata"
 )
 
 // TrillianSigner is responsible for signing log-related data and producing the appropriate
@@ -57,6 +62,10 @@ func (s TrillianSigner) Sign(data []byte) (trillian.DigitallySigned, error) {
 }
 
 func (s TrillianSigner) hashRoot(root trillian.SignedLogRoot) []byte {
+	return hashLogRoot(root)
+}
+
+func hashLogRoot(root trillian.SignedLogRoot) []byte {
 	rootMap := make(map[string]interface{})
 
 	// Pull out the fields we want to hash. Caution: use string format for int64 values as they
@@ -66,6 +75,12 @@ func (s TrillianSigner) hashRoot(root trillian.SignedLogRoot) []byte {
 	rootMap[mapKeyTimestampNanos] = strconv.FormatInt(root.TimestampNanos, 10)
 	rootMap[mapKeyTreeSize] = strconv.FormatInt(root.TreeSize, 10)
 
+	// Metadata is only included if the personality supplied some. This keeps the hash of roots
+	// without metadata the same as it was before the field existed.
+	if len(root.Metadata) > 0 {
+		rootMap[mapKeyMetadata] = base64.StdEncoding.EncodeToString(root.Metadata)
+	}
+
 	hash := objecthash.ObjectHash(rootMap)
 
 	return hash[:]
@@ -84,3 +99,54 @@ func (s TrillianSigner) SignLogRoot(root trillian.SignedLogRoot) (trillian.Digit
 
 	return signature, nil
 }
+
+// VerifyLogRoot checks the signature on a root made by SignLogRoot using the public key of the
+// signer. The hash algorithm recorded in the signature must match hasher. ECDSA and RSA keys
+// are supported.
+func VerifyLogRoot(hasher trillian.Hasher, publicKey crypto.PublicKey, root trillian.SignedLogRoot) error {
+	if root.Signature == nil {
+		return errors.New("root is not signed")
+	}
+
+	return VerifySignature(hasher, publicKey, hashLogRoot(root), *root.Signature)
+}
+
+// VerifySignature checks that signature is a valid signature over data by the private key
+// matching publicKey, as produced by a TrillianSigner using the same hasher.
+func VerifySignature(hasher trillian.Hasher, publicKey crypto.PublicKey, data []byte, signature trillian.DigitallySigned) error {
+	if got, want := signature.HashAlgorithm, hasher.HashAlgorithm(); got != want {
+		return fmt.Errorf("signed with hash algorithm %v, expected %v", got, want)
+	}
+
+	digest := hasher.Digest(data)
+
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		var sig ecdsaSignature
+		rest, err := asn1.Unmarshal(signature.Signature, &sig)
+
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal ECDSA signature: %v", err)
+		}
+
+		if len(rest) > 0 {
+			return fmt.Errorf("%d bytes of trailing data after ECDSA signature", len(rest))
+		}
+
+		if !ecdsa.Verify(key, digest, sig.R, sig.S) {
+			return errors.New("ECDSA signature did not verify")
+		}
+
+		return nil
+
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, hasher.Hash, digest, signature.Signature); err != nil {
+			return fmt.Errorf("RSA signature did not verify: %v", err)
+		}
+
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported public key type: %T", publicKey)
+	}
+}
@@ -3,6 +3,10 @@ package crypto
 import (
 	"bytes"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
 	"errors"
 	"reflect"
 	"testing"
@@ -141,3 +145,132 @@ func createTestSigner(t *testing.T, mock *MockSigner) *TrillianSigner {
 
 	return NewTrillianSigner(hasher, trillian.SignatureAlgorithm_RSA, mock)
 }
+
+func TestHashRootMetadata(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	logSigner := createTestSigner(t, NewMockSigner(ctrl))
+
+	root := trillian.SignedLogRoot{TimestampNanos: 2267709, RootHash: []byte("Islington"), TreeSize: 2}
+	rootEmptyMetadata := root
+	rootEmptyMetadata.Metadata = []byte{}
+	rootWithMetadata := root
+	rootWithMetadata.Metadata = []byte("shard1")
+	rootOtherMetadata := root
+	rootOtherMetadata.Metadata = []byte("shard2")
+
+	// Empty metadata must not change the hash, otherwise existing roots would not verify
+	if got, want := logSigner.hashRoot(rootEmptyMetadata), logSigner.hashRoot(root); !bytes.Equal(got, want) {
+		t.Fatalf("Empty metadata changed root hash: got %v, want %v", got, want)
+	}
+	if got, other := logSigner.hashRoot(rootWithMetadata), logSigner.hashRoot(root); bytes.Equal(got, other) {
+		t.Fatalf("Metadata not covered by root hash: got %v for both", got)
+	}
+	if got, other := logSigner.hashRoot(rootWithMetadata), logSigner.hashRoot(rootOtherMetadata); bytes.Equal(got, other) {
+		t.Fatalf("Different metadata produced the same root hash: %v", got)
+	}
+}
+
+func TestVerifyLogRoot(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Failed to generate ECDSA key: %v", err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+
+	for _, test := range []struct {
+		signer    crypto.Signer
+		algorithm trillian.SignatureAlgorithm
+	}{
+		{signer: ecdsaKey, algorithm: trillian.SignatureAlgorithm_ECDSA},
+		{signer: rsaKey, algorithm: trillian.SignatureAlgorithm_RSA},
+	} {
+		hasher := trillian.NewSHA256()
+		root := trillian.SignedLogRoot{TimestampNanos: 2267709, RootHash: []byte("Islington"), TreeSize: 2, Metadata: []byte("shard1")}
+		signature, err := NewTrillianSigner(hasher, test.algorithm, test.signer).SignLogRoot(root)
+
+		if err != nil {
+			t.Fatalf("%v: failed to sign root: %v", test.algorithm, err)
+		}
+
+		root.Signature = &signature
+
+		if err := VerifyLogRoot(hasher, test.signer.Public(), root); err != nil {
+			t.Errorf("%v: failed to verify signed root: %v", test.algorithm, err)
+		}
+
+		// Every signed field must be covered
+		for _, tamper := range []func(*trillian.SignedLogRoot){
+			func(r *trillian.SignedLogRoot) { r.TreeSize++ },
+			func(r *trillian.SignedLogRoot) { r.TimestampNanos++ },
+			func(r *trillian.SignedLogRoot) { r.RootHash = []byte("Highbury") },
+			func(r *trillian.SignedLogRoot) { r.Metadata = nil },
+		} {
+			tampered := root
+			tamper(&tampered)
+
+			if err := VerifyLogRoot(hasher, test.signer.Public(), tampered); err == nil {
+				t.Errorf("%v: verified tampered root: %v", test.algorithm, tampered)
+			}
+		}
+
+		unsigned := root
+		unsigned.Signature = nil
+		testonly.EnsureErrorContains(t, VerifyLogRoot(hasher, test.signer.Public(), unsigned), "not signed")
+
+		// SHA256 is the only algorithm defined so use one that doesn't exist
+		wrongHash := root
+		wrongHash.Signature = &trillian.DigitallySigned{SignatureAlgorithm: signature.SignatureAlgorithm, HashAlgorithm: trillian.HashAlgorithm(99), Signature: signature.Signature}
+		testonly.EnsureErrorContains(t, VerifyLogRoot(hasher, test.signer.Public(), wrongHash), "hash algorithm")
+	}
+
+	// The root must be verified with the signer's key
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Failed to generate ECDSA key: %v", err)
+	}
+
+	root := trillian.SignedLogRoot{TimestampNanos: 2267709, RootHash: []byte("Islington"), TreeSize: 2}
+	signature, err := NewTrillianSigner(trillian.NewSHA256(), trillian.SignatureAlgorithm_ECDSA, ecdsaKey).SignLogRoot(root)
+
+	if err != nil {
+		t.Fatalf("Failed to sign root: %v", err)
+	}
+
+	root.Signature = &signature
+
+	if err := VerifyLogRoot(trillian.NewSHA256(), otherKey.Public(), root); err == nil {
+		t.Error("Verified root with the wrong key")
+	}
+
+	testonly.EnsureErrorContains(t, VerifyLogRoot(trillian.NewSHA256(), "not a key", root), "unsupported")
+}
+
+func TestVerifySignature(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Failed to generate ECDSA key: %v", err)
+	}
+
+	hasher := trillian.NewSHA256()
+	signature, err := NewTrillianSigner(hasher, trillian.SignatureAlgorithm_ECDSA, key).Sign([]byte("Highbury"))
+
+	if err != nil {
+		t.Fatalf("Failed to sign data: %v", err)
+	}
+
+	if err := VerifySignature(hasher, key.Public(), []byte("Highbury"), signature); err != nil {
+		t.Errorf("Failed to verify signature: %v", err)
+	}
+
+	testonly.EnsureErrorContains(t, VerifySignature(hasher, key.Public(), []byte("Islington"), signature), "did not verify")
+}
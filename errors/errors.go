@@ -0,0 +1,145 @@
+// Package errors defines the categories of error shared by storage, the servers and
+// personalities such as the CT frontend, and how each category is reported over gRPC and
+// HTTP. Callers decide what to do about an error from its category rather than by matching
+// its message.
+//
+// Errors keep their category across an RPC: servers return them with the gRPC code of their
+// category, see ToGRPC, and CodeOf recovers the category from the code on the client side.
+package errors
+
+import (
+	"fmt"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// Code is the category of an error.
+type Code int
+
+const (
+	// Unknown is the category of errors that haven't been given one
+	Unknown Code = iota
+	// InvalidRange means the request asked for something that can't exist, such as a
+	// negative leaf index or a range that ends before it starts
+	InvalidRange
+	// NotFound means the request was valid but what it asked for doesn't exist, such as a
+	// tree size that has no signed root
+	NotFound
+	// Backend means a service or database that was needed failed or couldn't be reached,
+	// trying again later may work
+	Backend
+	// Integrity means data failed a check that it's consistent, such as a proof or root
+	// signature that doesn't verify. It's a bug or tampering, trying again won't help
+	Integrity
+	// Quota means the caller has used up an allowance, such as a rate limit
+	Quota
+)
+
+var codeNames = map[Code]string{
+	Unknown:      "Unknown",
+	InvalidRange: "InvalidRange",
+	NotFound:     "NotFound",
+	Backend:      "Backend",
+	Integrity:    "Integrity",
+	Quota:        "Quota",
+}
+
+func (c Code) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Code(%d)", int(c))
+}
+
+// grpcCodes is the gRPC code that each category is reported with
+var grpcCodes = map[Code]codes.Code{
+	Unknown:      codes.Unknown,
+	InvalidRange: codes.OutOfRange,
+	NotFound:     codes.NotFound,
+	Backend:      codes.Unavailable,
+	Integrity:    codes.DataLoss,
+	Quota:        codes.ResourceExhausted,
+}
+
+// grpcCategories is the category of errors received with each gRPC code. It's the inverse
+// of grpcCodes, plus the codes that other gRPC code reports similar errors with.
+var grpcCategories = map[codes.Code]Code{
+	codes.OutOfRange:        InvalidRange,
+	codes.InvalidArgument:   InvalidRange,
+	codes.NotFound:          NotFound,
+	codes.Unavailable:       Backend,
+	codes.DeadlineExceeded:  Backend,
+	codes.DataLoss:          Integrity,
+	codes.ResourceExhausted: Quota,
+}
+
+// httpStatuses is the HTTP status that each category is reported with
+var httpStatuses = map[Code]int{
+	Unknown:      http.StatusInternalServerError,
+	InvalidRange: http.StatusBadRequest,
+	NotFound:     http.StatusNotFound,
+	Backend:      http.StatusServiceUnavailable,
+	Integrity:    http.StatusInternalServerError,
+	Quota:        http.StatusTooManyRequests,
+}
+
+// Error is an error with a category.
+type Error struct {
+	Code    Code
+	Message string
+}
+
+func (e Error) Error() string {
+	return e.Message
+}
+
+// New returns an error with category code.
+func New(code Code, message string) error {
+	return Error{Code: code, Message: message}
+}
+
+// Errorf returns an error with category code and a formatted message.
+func Errorf(code Code, format string, a ...interface{}) error {
+	return Error{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+// CodeOf returns the category of err. Errors from gRPC calls get the category of their gRPC
+// code. It's Unknown for nil and errors without a category.
+func CodeOf(err error) Code {
+	if err == nil {
+		return Unknown
+	}
+
+	if e, ok := err.(Error); ok {
+		return e.Code
+	}
+
+	if code, ok := grpcCategories[grpc.Code(err)]; ok {
+		return code
+	}
+
+	return Unknown
+}
+
+// GRPCCode returns the gRPC code that err should be reported with.
+func GRPCCode(err error) codes.Code {
+	return grpcCodes[CodeOf(err)]
+}
+
+// HTTPStatus returns the HTTP status that err should be reported with.
+func HTTPStatus(err error) int {
+	return httpStatuses[CodeOf(err)]
+}
+
+// ToGRPC returns err in the form that an RPC handler should return it, with the gRPC code of
+// its category. Errors that already have a gRPC code are returned unchanged.
+func ToGRPC(err error) error {
+	if err == nil || grpc.Code(err) != codes.Unknown {
+		return err
+	}
+
+	return grpc.Errorf(GRPCCode(err), "%s", err.Error())
+}
@@ -0,0 +1,100 @@
+package errors
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestCodeOf(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		want Code
+	}{
+		{err: nil, want: Unknown},
+		{err: errors.New("plain"), want: Unknown},
+		{err: New(NotFound, "missing"), want: NotFound},
+		{err: Errorf(Integrity, "bad proof at %d", 3), want: Integrity},
+		{err: grpc.Errorf(codes.OutOfRange, "range"), want: InvalidRange},
+		{err: grpc.Errorf(codes.InvalidArgument, "argument"), want: InvalidRange},
+		{err: grpc.Errorf(codes.DeadlineExceeded, "slow"), want: Backend},
+		{err: grpc.Errorf(codes.ResourceExhausted, "limited"), want: Quota},
+		{err: grpc.Errorf(codes.Internal, "internal"), want: Unknown},
+	} {
+		if got := CodeOf(test.err); got != test.want {
+			t.Errorf("CodeOf(%v)=%v, want %v", test.err, got, test.want)
+		}
+	}
+}
+
+func TestMappings(t *testing.T) {
+	for _, test := range []struct {
+		code       Code
+		wantGRPC   codes.Code
+		wantStatus int
+	}{
+		{code: Unknown, wantGRPC: codes.Unknown, wantStatus: http.StatusInternalServerError},
+		{code: InvalidRange, wantGRPC: codes.OutOfRange, wantStatus: http.StatusBadRequest},
+		{code: NotFound, wantGRPC: codes.NotFound, wantStatus: http.StatusNotFound},
+		{code: Backend, wantGRPC: codes.Unavailable, wantStatus: http.StatusServiceUnavailable},
+		{code: Integrity, wantGRPC: codes.DataLoss, wantStatus: http.StatusInternalServerError},
+		{code: Quota, wantGRPC: codes.ResourceExhausted, wantStatus: http.StatusTooManyRequests},
+	} {
+		err := New(test.code, "test")
+
+		if got := GRPCCode(err); got != test.wantGRPC {
+			t.Errorf("GRPCCode(%v)=%v, want %v", test.code, got, test.wantGRPC)
+		}
+
+		if got := HTTPStatus(err); got != test.wantStatus {
+			t.Errorf("HTTPStatus(%v)=%d, want %d", test.code, got, test.wantStatus)
+		}
+
+		// The category must survive being sent over gRPC
+		if test.code != Unknown {
+			if got := CodeOf(ToGRPC(err)); got != test.code {
+				t.Errorf("CodeOf(ToGRPC(%v))=%v, want %v", test.code, got, test.code)
+			}
+		}
+	}
+}
+
+func TestToGRPC(t *testing.T) {
+	if err := ToGRPC(nil); err != nil {
+		t.Errorf("ToGRPC(nil)=%v, want nil", err)
+	}
+
+	err := ToGRPC(Errorf(NotFound, "no root at size %d", 10))
+
+	if got, want := grpc.Code(err), codes.NotFound; got != want {
+		t.Errorf("ToGRPC() code=%v, want %v", got, want)
+	}
+
+	if got, want := grpc.ErrorDesc(err), "no root at size 10"; got != want {
+		t.Errorf("ToGRPC() desc=%q, want %q", got, want)
+	}
+
+	// Errors that already have a code keep it
+	unimplemented := grpc.Errorf(codes.Unimplemented, "not here")
+
+	if got := ToGRPC(unimplemented); got != unimplemented {
+		t.Errorf("ToGRPC(%v)=%v, want it unchanged", unimplemented, got)
+	}
+
+	if got, want := grpc.Code(ToGRPC(errors.New("plain"))), codes.Unknown; got != want {
+		t.Errorf("ToGRPC() of plain error code=%v, want %v", got, want)
+	}
+}
+
+func TestCodeString(t *testing.T) {
+	if got, want := InvalidRange.String(), "InvalidRange"; got != want {
+		t.Errorf("String()=%q, want %q", got, want)
+	}
+
+	if got, want := Code(99).String(), "Code(99)"; got != want {
+		t.Errorf("String()=%q, want %q", got, want)
+	}
+}
@@ -0,0 +1,116 @@
+package ct
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIHandler serves a request to an endpoint of an APIVersion for a log. It returns the status
+// and, for anything other than 200, an error, which is sent to the client as a JSON error like
+// those of the RFC 6962 endpoints. c is the log's handlers, whose SubmitChain and
+// SignedTreeHead share the validation, quotas and signing of the RFC 6962 endpoints.
+type APIHandler func(c CTRequestHandlers, w http.ResponseWriter, r *http.Request) (int, error)
+
+// APIEndpoint is one endpoint of an APIVersion.
+type APIEndpoint struct {
+	// Name is the last segment of the endpoint's path, e.g. "add-chain"
+	Name string
+	// Submission is set if the endpoint accepts chains, so that it's only served to known
+	// submitters if submitters are configured, see WithSubmitters
+	Submission bool
+	// Handler serves the endpoint's requests
+	Handler APIHandler
+}
+
+// APIVersion is a version of the CT API that is served for a log alongside RFC 6962, e.g.
+// under /ct/v2/, see WithAPIVersion. Its endpoints are served under the base path and the
+// log's path prefix like the RFC 6962 ones, and are tracked by SLO tracking and request
+// metrics and gated by readiness in the same way, named by the version and endpoint, e.g.
+// "v2/add-chain".
+type APIVersion struct {
+	// Name identifies the version in metrics, e.g. "v2". It must be a single path segment.
+	Name string
+	// BasePath is the path the endpoints are served under, relative to the log's prefix, e.g.
+	// "/ct/v2/". It must start and end with '/' and can't be the RFC 6962 path /ct/v1/.
+	BasePath  string
+	Endpoints []APIEndpoint
+}
+
+// Validate checks that the version can be served alongside RFC 6962 and that its endpoints
+// have distinct names.
+func (v APIVersion) Validate() error {
+	if len(v.Name) == 0 || strings.Contains(v.Name, "/") {
+		return fmt.Errorf("API version name must be a single path segment: %q", v.Name)
+	}
+
+	if len(v.BasePath) < 2 || !strings.HasPrefix(v.BasePath, "/") || !strings.HasSuffix(v.BasePath, "/") {
+		return fmt.Errorf("API version %s base path must start and end with '/': %q", v.Name, v.BasePath)
+	}
+
+	if err := validatePath(strings.Trim(v.BasePath, "/")); err != nil {
+		return fmt.Errorf("invalid API version %s base path: %v", v.Name, err)
+	}
+
+	if v.BasePath == ctV1BasePath {
+		return fmt.Errorf("API version %s can't be served under %s", v.Name, ctV1BasePath)
+	}
+
+	if len(v.Endpoints) == 0 {
+		return fmt.Errorf("API version %s has no endpoints", v.Name)
+	}
+
+	names := make(map[string]bool)
+
+	for _, endpoint := range v.Endpoints {
+		if err := validateEndpoint(endpoint); err != nil {
+			return fmt.Errorf("API version %s: %v", v.Name, err)
+		}
+
+		if names[endpoint.Name] {
+			return fmt.Errorf("API version %s has more than one %s endpoint", v.Name, endpoint.Name)
+		}
+
+		names[endpoint.Name] = true
+	}
+
+	return nil
+}
+
+func validateEndpoint(endpoint APIEndpoint) error {
+	if len(endpoint.Name) == 0 || strings.ContainsAny(endpoint.Name, "/?#% ") {
+		return fmt.Errorf("endpoint name must be a single path segment: %q", endpoint.Name)
+	}
+
+	if endpoint.Handler == nil {
+		return errors.New("endpoint has no handler")
+	}
+
+	return nil
+}
+
+// registerAPIVersion registers the endpoints of v on mux. It panics if v isn't valid, like
+// http.ServeMux does when a path is registered twice.
+func (c CTRequestHandlers) registerAPIVersion(mux *http.ServeMux, v APIVersion) {
+	if err := v.Validate(); err != nil {
+		panic(err)
+	}
+
+	for _, endpoint := range v.Endpoints {
+		handler := http.Handler(apiHandler(c, endpoint.Handler))
+
+		if endpoint.Submission {
+			handler = c.authenticated(handler)
+		}
+
+		c.handleAt(mux, v.Name+"/"+endpoint.Name, v.BasePath+endpoint.Name, handler)
+	}
+}
+
+// apiHandler binds an APIHandler to a log's handlers.
+func apiHandler(c CTRequestHandlers, handler APIHandler) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		return handler(c, w, r)
+	}
+}
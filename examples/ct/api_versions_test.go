@@ -0,0 +1,210 @@
+package ct
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/certificate-transparency/go/x509"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/examples/ct/testonly"
+)
+
+// testAPIVersion serves a submission endpoint that passes a chain to SubmitChain and a read
+// endpoint that writes the path it was served on
+func testAPIVersion() APIVersion {
+	return APIVersion{
+		Name:     "v2",
+		BasePath: "/ct/v2/",
+		Endpoints: []APIEndpoint{
+			{Name: "submit-entry", Submission: true, Handler: func(c CTRequestHandlers, w http.ResponseWriter, r *http.Request) (int, error) {
+				var chain []string
+
+				for _, cert := range loadCertsIntoPool(testonly.LeafSignedByFakeIntermediateCertPem, testonly.FakeIntermediateCertPem).RawCertificates() {
+					chain = append(chain, base64.StdEncoding.EncodeToString(cert.Raw))
+				}
+
+				if _, status, err := c.SubmitChain(r, chain, false); err != nil {
+					return status, err
+				}
+
+				return http.StatusOK, nil
+			}},
+			{Name: "get-path", Handler: func(c CTRequestHandlers, w http.ResponseWriter, r *http.Request) (int, error) {
+				fmt.Fprint(w, r.URL.Path)
+				return http.StatusOK, nil
+			}},
+		},
+	}
+}
+
+// loadCertsIntoPool is loadCertsIntoPoolOrDie for handlers, which can't fail the test
+func loadCertsIntoPool(certs ...string) *PEMCertPool {
+	pool := NewPEMCertPool()
+
+	for _, cert := range certs {
+		pool.AppendCertsFromPEM([]byte(cert))
+	}
+
+	return pool
+}
+
+func TestAPIVersionValidate(t *testing.T) {
+	handler := testAPIVersion().Endpoints[0].Handler
+
+	if err := testAPIVersion().Validate(); err != nil {
+		t.Fatalf("Validate() of a valid version failed: %v", err)
+	}
+
+	for _, test := range []struct {
+		version     APIVersion
+		explanation string
+	}{
+		{APIVersion{BasePath: "/ct/v2/", Endpoints: []APIEndpoint{{Name: "a", Handler: handler}}}, "no name"},
+		{APIVersion{Name: "v/2", BasePath: "/ct/v2/", Endpoints: []APIEndpoint{{Name: "a", Handler: handler}}}, "name with slash"},
+		{APIVersion{Name: "v2", BasePath: "ct/v2/", Endpoints: []APIEndpoint{{Name: "a", Handler: handler}}}, "relative base path"},
+		{APIVersion{Name: "v2", BasePath: "/ct/v2", Endpoints: []APIEndpoint{{Name: "a", Handler: handler}}}, "base path without trailing slash"},
+		{APIVersion{Name: "v2", BasePath: "/", Endpoints: []APIEndpoint{{Name: "a", Handler: handler}}}, "root base path"},
+		{APIVersion{Name: "v2", BasePath: "/ct/../v2/", Endpoints: []APIEndpoint{{Name: "a", Handler: handler}}}, "relative segment in base path"},
+		{APIVersion{Name: "v1", BasePath: ctV1BasePath, Endpoints: []APIEndpoint{{Name: "a", Handler: handler}}}, "RFC 6962 base path"},
+		{APIVersion{Name: "v2", BasePath: "/ct/v2/"}, "no endpoints"},
+		{APIVersion{Name: "v2", BasePath: "/ct/v2/", Endpoints: []APIEndpoint{{Name: "a/b", Handler: handler}}}, "endpoint name with slash"},
+		{APIVersion{Name: "v2", BasePath: "/ct/v2/", Endpoints: []APIEndpoint{{Name: "a"}}}, "endpoint without handler"},
+		{APIVersion{Name: "v2", BasePath: "/ct/v2/", Endpoints: []APIEndpoint{{Name: "a", Handler: handler}, {Name: "a", Handler: handler}}}, "duplicate endpoint"},
+	} {
+		if err := test.version.Validate(); err == nil {
+			t.Errorf("Accepted invalid API version (%s): %+v", test.explanation, test.version)
+		}
+	}
+}
+
+func TestAPIVersionServedAlongsideV1(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	km := crypto.NewMockKeyManager(mockCtrl)
+	roots := loadCertsIntoPoolOrDie(t, []string{testonly.FakeCACertPem})
+	metrics := NewRequestMetrics(fakeTimeSource)
+
+	reqHandlers := NewCTRequestHandlers(0x42, roots, client, km, WithTimeSource(fakeTimeSource), WithPathPrefix("pilot"), WithRequestMetrics(metrics), WithAPIVersion(testAPIVersion()))
+	mux := http.NewServeMux()
+	reqHandlers.RegisterHandlers(mux)
+
+	for _, path := range []string{"/pilot/ct/v1/get-roots", "/pilot/ct/v2/get-path"} {
+		req, err := http.NewRequest(httpMethodGet, "http://example.com"+path, nil)
+
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Errorf("Got status %d for %s, expected %d. Body: %v", got, path, want, w.Body)
+		}
+	}
+
+	counts := make(map[string]*endpointRequests)
+	metrics.snapshot(counts)
+
+	for _, endpoint := range []string{"get-roots", "v2/get-path"} {
+		if _, ok := counts[endpoint]; !ok {
+			t.Errorf("No request metrics for %s, got %v", endpoint, counts)
+		}
+	}
+}
+
+func TestAPIVersionSubmissionSharesPolicies(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// The chain is rejected by the log's policy before an SCT is signed or the backend is
+	// called, as it would be by add-chain
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	km := crypto.NewMockKeyManager(mockCtrl)
+	roots := loadCertsIntoPoolOrDie(t, []string{testonly.FakeCACertPem})
+	reject := func(chain []*x509.Certificate, isPrecert bool) error {
+		return errors.New("issuer not allowed")
+	}
+
+	reqHandlers := NewCTRequestHandlers(0x42, roots, client, km, WithTimeSource(fakeTimeSource), WithSubmissionPolicy(reject), WithAPIVersion(testAPIVersion()))
+	mux := http.NewServeMux()
+	reqHandlers.RegisterHandlers(mux)
+
+	req, err := http.NewRequest(httpMethodPost, "http://example.com/ct/v2/submit-entry", nil)
+
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if got, want := w.Code, http.StatusBadRequest; got != want {
+		t.Fatalf("Got status %d for submission rejected by policy, expected %d. Body: %v", got, want, w.Body)
+	}
+
+	if !strings.Contains(w.Body.String(), "issuer not allowed") {
+		t.Errorf("Policy error not returned: %v", w.Body)
+	}
+}
+
+func TestAPIVersionSubmissionAuthenticated(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	km := crypto.NewMockKeyManager(mockCtrl)
+	roots := loadCertsIntoPoolOrDie(t, []string{testonly.FakeCACertPem})
+	submitters, err := NewSubmitters(testSubmitterConfigs(), fakeTimeSource)
+
+	if err != nil {
+		t.Fatalf("NewSubmitters()=%v", err)
+	}
+
+	reqHandlers := NewCTRequestHandlers(0x42, roots, client, km, WithTimeSource(fakeTimeSource), WithSubmitters(submitters), WithAPIVersion(testAPIVersion()))
+	mux := http.NewServeMux()
+	reqHandlers.RegisterHandlers(mux)
+
+	// Only the submission endpoint needs a submitter
+	for _, test := range []struct {
+		path string
+		want int
+	}{
+		{"/ct/v2/submit-entry", http.StatusUnauthorized},
+		{"/ct/v2/get-path", http.StatusOK},
+	} {
+		req, err := http.NewRequest(httpMethodPost, "http://example.com"+test.path, nil)
+
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		if got := w.Code; got != test.want {
+			t.Errorf("Got status %d for %s without a submitter, expected %d", got, test.path, test.want)
+		}
+	}
+}
+
+func TestSubmitChainEmpty(t *testing.T) {
+	req, err := http.NewRequest(httpMethodPost, "http://example.com/ct/v2/submit-entry", nil)
+
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	if _, status, err := (CTRequestHandlers{}).SubmitChain(req, nil, false); status != http.StatusBadRequest || err != errEmptyChain {
+		t.Errorf("SubmitChain() of an empty chain = %d, %v, expected %d, %v", status, err, http.StatusBadRequest, errEmptyChain)
+	}
+}
@@ -0,0 +1,198 @@
+package ct
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+// BackendDialer connects to a log RPC server and returns a client for it along with a closer
+// for the underlying connection.
+type BackendDialer func(address string) (trillian.TrillianLogClient, io.Closer, error)
+
+// backendConn is an open connection to a backend
+type backendConn struct {
+	client trillian.TrillianLogClient
+	closer io.Closer
+}
+
+// BackendHealth is the result of the most recent health check of a log's backend.
+type BackendHealth struct {
+	LogID   int64  `json:"log_id"`
+	Address string `json:"address"`
+	Healthy bool   `json:"healthy"`
+	// LastCheck is zero if the backend hasn't been checked yet
+	LastCheck time.Time `json:"last_check"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// BackendPool holds the RPC clients used by the logs served by a frontend, which can be
+// routed to different backends. Logs using the same address share a connection. The pool can
+// check the health of each log's backend periodically. It is safe for concurrent use.
+type BackendPool struct {
+	dial       BackendDialer
+	timeSource util.TimeSource
+
+	// mu guards the fields below it
+	mu sync.Mutex
+	// conns holds the open connections keyed by address
+	conns map[string]backendConn
+	// health holds the state of each log, keyed by log ID
+	health map[int64]BackendHealth
+}
+
+// NewBackendPool creates an empty pool that connects to backends using dial.
+func NewBackendPool(dial BackendDialer, timeSource util.TimeSource) *BackendPool {
+	return &BackendPool{dial: dial, timeSource: timeSource, conns: make(map[string]backendConn), health: make(map[int64]BackendHealth)}
+}
+
+// AddLog returns the client to use for a log served by the backend at address, connecting
+// to it if no other log already uses that address.
+func (b *BackendPool) AddLog(logID int64, address string) (trillian.TrillianLogClient, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.health[logID]; ok {
+		return nil, fmt.Errorf("log %d is already in the pool", logID)
+	}
+
+	conn, ok := b.conns[address]
+
+	if !ok {
+		client, closer, err := b.dial(address)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to backend %s for log %d: %v", address, logID, err)
+		}
+
+		conn = backendConn{client: client, closer: closer}
+		b.conns[address] = conn
+	}
+
+	b.health[logID] = BackendHealth{LogID: logID, Address: address}
+
+	return conn.client, nil
+}
+
+// CheckHealth asks each log's backend for its latest root and records whether it answered
+// successfully within deadline.
+func (b *BackendPool) CheckHealth(deadline time.Duration) {
+	for _, health := range b.Health() {
+		err := b.checkLog(health.LogID, b.clientFor(health.Address), deadline)
+		updated := BackendHealth{LogID: health.LogID, Address: health.Address, Healthy: err == nil, LastCheck: b.timeSource.Now()}
+
+		if err != nil {
+			updated.Error = err.Error()
+
+			if health.Healthy || health.LastCheck.IsZero() {
+				glog.Warningf("Backend %s for log %d is unhealthy: %v", health.Address, health.LogID, err)
+			}
+		} else if !health.Healthy && !health.LastCheck.IsZero() {
+			glog.Infof("Backend %s for log %d is healthy again", health.Address, health.LogID)
+		}
+
+		b.mu.Lock()
+		if _, ok := b.health[health.LogID]; ok {
+			b.health[health.LogID] = updated
+		}
+		b.mu.Unlock()
+	}
+}
+
+func (b *BackendPool) clientFor(address string) trillian.TrillianLogClient {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.conns[address].client
+}
+
+func (b *BackendPool) checkLog(logID int64, client trillian.TrillianLogClient, deadline time.Duration) error {
+	if client == nil {
+		return fmt.Errorf("no connection for log %d", logID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), deadline)
+	defer cancel()
+
+	response, err := client.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: logID})
+
+	if err != nil {
+		return err
+	}
+
+	if !rpcStatusOK(response.GetStatus()) {
+		return fmt.Errorf("backend returned status: %v", response.GetStatus())
+	}
+
+	return nil
+}
+
+// RunHealthChecks checks the health of the backends immediately and then every interval
+// until done is closed.
+func (b *BackendPool) RunHealthChecks(done <-chan struct{}, interval, deadline time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		b.CheckHealth(deadline)
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Health returns the latest health of every log's backend, ordered by log ID.
+func (b *BackendPool) Health() []BackendHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	health := make([]BackendHealth, 0, len(b.health))
+
+	for _, h := range b.health {
+		health = append(health, h)
+	}
+
+	sort.Sort(byLogID(health))
+
+	return health
+}
+
+// Close closes all the connections in the pool and removes all logs from it. Returns the
+// first error from closing a connection, if any.
+func (b *BackendPool) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var firstErr error
+
+	for address, conn := range b.conns {
+		if err := conn.closer.Close(); err != nil {
+			glog.Warningf("Failed to close connection to backend %s: %v", address, err)
+
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	b.conns = make(map[string]backendConn)
+	b.health = make(map[int64]BackendHealth)
+
+	return firstErr
+}
+
+type byLogID []BackendHealth
+
+func (h byLogID) Len() int           { return len(h) }
+func (h byLogID) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h byLogID) Less(i, j int) bool { return h[i].LogID < h[j].LogID }
@@ -0,0 +1,151 @@
+package ct
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/util"
+)
+
+// fakeCloser counts how many times it has been closed
+type fakeCloser struct {
+	closed int
+	err    error
+}
+
+func (f *fakeCloser) Close() error {
+	f.closed++
+	return f.err
+}
+
+// fakeDialer returns preset clients by address and records the addresses dialled
+type fakeDialer struct {
+	clients map[string]trillian.TrillianLogClient
+	closers map[string]*fakeCloser
+	dialled []string
+}
+
+func (f *fakeDialer) dial(address string) (trillian.TrillianLogClient, io.Closer, error) {
+	f.dialled = append(f.dialled, address)
+	client, ok := f.clients[address]
+
+	if !ok {
+		return nil, nil, errors.New("connection refused")
+	}
+
+	return client, f.closers[address], nil
+}
+
+func TestBackendPoolAddLogSharesConnections(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client1 := trillian.NewMockTrillianLogClient(mockCtrl)
+	client2 := trillian.NewMockTrillianLogClient(mockCtrl)
+	dialer := &fakeDialer{
+		clients: map[string]trillian.TrillianLogClient{"shard1": client1, "shard2": client2},
+		closers: map[string]*fakeCloser{"shard1": {}, "shard2": {}},
+	}
+	pool := NewBackendPool(dialer.dial, &util.FakeTimeSource{FakeTime: fakeTime})
+
+	for _, test := range []struct {
+		logID   int64
+		address string
+		want    trillian.TrillianLogClient
+	}{
+		{logID: 1, address: "shard1", want: client1},
+		{logID: 2, address: "shard2", want: client2},
+		{logID: 3, address: "shard1", want: client1},
+	} {
+		client, err := pool.AddLog(test.logID, test.address)
+
+		if err != nil {
+			t.Fatalf("Failed to add log %d: %v", test.logID, err)
+		}
+
+		if client != test.want {
+			t.Errorf("Got wrong client for log %d on %s", test.logID, test.address)
+		}
+	}
+
+	if got, want := len(dialer.dialled), 2; got != want {
+		t.Errorf("Dialled %d times, expected %d: %v", got, want, dialer.dialled)
+	}
+
+	if _, err := pool.AddLog(1, "shard2"); err == nil {
+		t.Error("Added the same log twice")
+	}
+
+	if _, err := pool.AddLog(4, "shard3"); err == nil {
+		t.Error("Added a log whose backend can't be reached")
+	}
+
+	if err := pool.Close(); err != nil {
+		t.Fatalf("Failed to close pool: %v", err)
+	}
+
+	for address, closer := range dialer.closers {
+		if got, want := closer.closed, 1; got != want {
+			t.Errorf("Connection to %s closed %d times, expected %d", address, got, want)
+		}
+	}
+
+	if got, want := len(pool.Health()), 0; got != want {
+		t.Errorf("Got %d logs after close, expected %d", got, want)
+	}
+}
+
+func TestBackendPoolCheckHealth(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client1 := trillian.NewMockTrillianLogClient(mockCtrl)
+	client2 := trillian.NewMockTrillianLogClient(mockCtrl)
+	dialer := &fakeDialer{
+		clients: map[string]trillian.TrillianLogClient{"shard1": client1, "shard2": client2},
+		closers: map[string]*fakeCloser{"shard1": {}, "shard2": {}},
+	}
+	pool := NewBackendPool(dialer.dial, &util.FakeTimeSource{FakeTime: fakeTime})
+
+	for logID, address := range map[int64]string{1: "shard1", 2: "shard2", 3: "shard2"} {
+		if _, err := pool.AddLog(logID, address); err != nil {
+			t.Fatalf("Failed to add log %d: %v", logID, err)
+		}
+	}
+
+	for _, health := range pool.Health() {
+		if health.Healthy || !health.LastCheck.IsZero() {
+			t.Errorf("Log %d has health %+v before being checked", health.LogID, health)
+		}
+	}
+
+	client1.EXPECT().GetLatestSignedLogRoot(gomock.Any(), &trillian.GetLatestSignedLogRootRequest{LogId: 1}).Return(&trillian.GetLatestSignedLogRootResponse{Status: okStatus}, nil)
+	client2.EXPECT().GetLatestSignedLogRoot(gomock.Any(), &trillian.GetLatestSignedLogRootRequest{LogId: 2}).Return(nil, errors.New("unavailable"))
+	client2.EXPECT().GetLatestSignedLogRoot(gomock.Any(), &trillian.GetLatestSignedLogRootRequest{LogId: 3}).Return(&trillian.GetLatestSignedLogRootResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_ERROR}}, nil)
+
+	pool.CheckHealth(time.Second)
+
+	health := pool.Health()
+
+	if got, want := len(health), 3; got != want {
+		t.Fatalf("Got %d logs, expected %d", got, want)
+	}
+
+	for i, want := range []bool{true, false, false} {
+		if got := health[i].Healthy; got != want {
+			t.Errorf("Log %d healthy=%v, expected %v: %+v", health[i].LogID, got, want, health[i])
+		}
+
+		if got, want := health[i].LastCheck, fakeTime; got != want {
+			t.Errorf("Log %d last checked at %v, expected %v", health[i].LogID, got, want)
+		}
+	}
+
+	if got, want := health[1].Error, "unavailable"; got != want {
+		t.Errorf("Got error %q for log 2, expected %q", got, want)
+	}
+}
@@ -0,0 +1,320 @@
+package ct
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	terrors "github.com/google/trillian/errors"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// errCircuitOpen is returned instead of making an RPC while a circuit breaker is open. It's a
+// backend error so the handlers answer 503.
+var errCircuitOpen = terrors.New(terrors.Backend, "backend is failing, circuit breaker is open")
+
+// ChainUnaryClientInterceptors combines interceptors into one, as a connection can only be
+// given a single interceptor. The first one is outermost and sees each RPC first.
+func ChainUnaryClientInterceptors(interceptors ...grpc.UnaryClientInterceptor) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		// Wrap the invoker from the innermost interceptor outwards
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			invoker = bindClientInterceptor(interceptors[i], invoker)
+		}
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func bindClientInterceptor(interceptor grpc.UnaryClientInterceptor, invoker grpc.UnaryInvoker) grpc.UnaryInvoker {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return interceptor(ctx, method, req, reply, cc, invoker, opts...)
+	}
+}
+
+// BackendRPCStats counts the RPCs made with one method. Errors are counted by their category,
+// see the errors package.
+type BackendRPCStats struct {
+	Count          int64            `json:"count"`
+	Errors         map[string]int64 `json:"errors"`
+	LatencySeconds float64          `json:"latency_seconds"`
+	// LatencyBuckets counts the RPCs taking up to each bound in latencyBucketsSeconds, with an
+	// extra bucket at the end for slower ones
+	LatencyBuckets []int64 `json:"latency_buckets"`
+}
+
+// BackendRPCMetrics counts the RPCs made to a backend by method, along with their errors and
+// the total time taken, so that a slow or failing backend can be told apart from a slow
+// frontend. It is safe for concurrent use.
+type BackendRPCMetrics struct {
+	timeSource util.TimeSource
+
+	// mu guards the fields below it
+	mu sync.Mutex
+	// methods maps the full names of the methods to their stats
+	methods map[string]*BackendRPCStats
+}
+
+// NewBackendRPCMetrics creates a BackendRPCMetrics with no RPCs, timing them with timeSource.
+func NewBackendRPCMetrics(timeSource util.TimeSource) *BackendRPCMetrics {
+	return &BackendRPCMetrics{timeSource: timeSource, methods: make(map[string]*BackendRPCStats)}
+}
+
+// Interceptor returns a gRPC client interceptor that records every RPC in the metrics.
+func (m *BackendRPCMetrics) Interceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := m.timeSource.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		m.record(method, err, m.timeSource.Now().Sub(start))
+
+		return err
+	}
+}
+
+func (m *BackendRPCMetrics) record(method string, err error, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.methods[method]
+
+	if !ok {
+		stats = &BackendRPCStats{Errors: make(map[string]int64), LatencyBuckets: make([]int64, len(latencyBucketsSeconds)+1)}
+		m.methods[method] = stats
+	}
+
+	stats.Count++
+	stats.LatencySeconds += latency.Seconds()
+	stats.LatencyBuckets[sort.SearchFloat64s(latencyBucketsSeconds, latency.Seconds())]++
+
+	if err != nil {
+		stats.Errors[terrors.CodeOf(err).String()]++
+	}
+}
+
+// Stats returns a copy of the stats of each method that has been called.
+func (m *BackendRPCMetrics) Stats() map[string]BackendRPCStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[string]BackendRPCStats)
+
+	for method, stats := range m.methods {
+		copied := *stats
+		copied.Errors = make(map[string]int64)
+
+		for code, count := range stats.Errors {
+			copied.Errors[code] = count
+		}
+
+		copied.LatencyBuckets = append([]int64(nil), stats.LatencyBuckets...)
+		result[method] = copied
+	}
+
+	return result
+}
+
+// WriteText writes the metrics in the Prometheus text format, labelled with the backend's
+// address.
+func (m *BackendRPCMetrics) WriteText(w io.Writer, backend string) {
+	writeBackendRPCMetrics(w, map[string]map[string]BackendRPCStats{backend: m.Stats()})
+}
+
+// writeBackendRPCMetrics writes the stats of each method of the backends keyed by address. It
+// writes nothing if there are no backends.
+func writeBackendRPCMetrics(w io.Writer, backends map[string]map[string]BackendRPCStats) {
+	if len(backends) == 0 {
+		return
+	}
+
+	addresses := make([]string, 0, len(backends))
+
+	for address := range backends {
+		addresses = append(addresses, address)
+	}
+
+	sort.Strings(addresses)
+
+	// The series of each family are written together, so each pass goes through every method
+	labels := func(address, method string) string {
+		return fmt.Sprintf("backend=\"%s\",method=\"%s\"", escapeLabelValue(address), escapeLabelValue(method))
+	}
+
+	fmt.Fprintln(w, "# HELP ct_backend_rpcs_total RPCs made to each log backend by method.")
+	fmt.Fprintln(w, "# TYPE ct_backend_rpcs_total counter")
+
+	for _, address := range addresses {
+		for _, method := range sortedMethods(backends[address]) {
+			fmt.Fprintf(w, "ct_backend_rpcs_total{%s} %d\n", labels(address, method), backends[address][method].Count)
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP ct_backend_rpc_errors_total Failed RPCs to each log backend by method and error category.")
+	fmt.Fprintln(w, "# TYPE ct_backend_rpc_errors_total counter")
+
+	for _, address := range addresses {
+		for _, method := range sortedMethods(backends[address]) {
+			errs := backends[address][method].Errors
+			categories := make([]string, 0, len(errs))
+
+			for category := range errs {
+				categories = append(categories, category)
+			}
+
+			sort.Strings(categories)
+
+			for _, category := range categories {
+				fmt.Fprintf(w, "ct_backend_rpc_errors_total{%s,category=\"%s\"} %d\n", labels(address, method), escapeLabelValue(category), errs[category])
+			}
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP ct_backend_rpc_duration_seconds Time taken by RPCs to each log backend by method.")
+	fmt.Fprintln(w, "# TYPE ct_backend_rpc_duration_seconds histogram")
+
+	for _, address := range addresses {
+		for _, method := range sortedMethods(backends[address]) {
+			stats := backends[address][method]
+			writeLatencyHistogram(w, "ct_backend_rpc_duration_seconds", labels(address, method), stats.LatencyBuckets, stats.LatencySeconds, stats.Count)
+		}
+	}
+}
+
+func sortedMethods(methods map[string]BackendRPCStats) []string {
+	names := make([]string, 0, len(methods))
+
+	for name := range methods {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Circuit breaker states
+const (
+	// circuitClosed lets RPCs through
+	circuitClosed = "closed"
+	// circuitOpen fails RPCs without making them
+	circuitOpen = "open"
+	// circuitHalfOpen lets one probe RPC through to find out if the backend has recovered
+	circuitHalfOpen = "half_open"
+)
+
+// CircuitBreaker stops RPCs being made to a backend that keeps failing, so that requests fail
+// straight away with a 503 rather than each holding a handler until its deadline passes.
+// After failureThreshold consecutive RPCs fail with backend errors the circuit opens and RPCs
+// fail immediately. Once openDuration has passed one probe RPC is let through: if it succeeds
+// the circuit closes again, otherwise it stays open for another openDuration. Errors that
+// aren't the backend's fault, such as requests for leaves that don't exist, don't count as
+// failures. It is safe for concurrent use.
+type CircuitBreaker struct {
+	failureThreshold int
+	openDuration     time.Duration
+	timeSource       util.TimeSource
+
+	// mu guards the fields below it
+	mu sync.Mutex
+	// state is one of the circuit breaker states
+	state string
+	// failures is the number of consecutive failures while closed
+	failures int
+	// openedAt is when the circuit last opened
+	openedAt time.Time
+	// rejected counts the RPCs that failed because the circuit was open
+	rejected int64
+}
+
+// NewCircuitBreaker creates a closed CircuitBreaker that opens after failureThreshold
+// consecutive failures and probes the backend every openDuration while it's open.
+func NewCircuitBreaker(failureThreshold int, openDuration time.Duration, timeSource util.TimeSource) *CircuitBreaker {
+	return &CircuitBreaker{failureThreshold: failureThreshold, openDuration: openDuration, timeSource: timeSource, state: circuitClosed}
+}
+
+// allow returns whether an RPC may be made, and whether it's the probe of a half open circuit.
+func (b *CircuitBreaker) allow() (bool, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitClosed:
+		return true, false
+	case circuitOpen:
+		if b.timeSource.Now().Sub(b.openedAt) >= b.openDuration {
+			b.state = circuitHalfOpen
+			return true, true
+		}
+	}
+
+	// Open, or half open with the probe still in flight
+	b.rejected++
+	return false, false
+}
+
+// record updates the state with the result of an RPC that was allowed.
+func (b *CircuitBreaker) record(err error, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	failed := err != nil && terrors.CodeOf(err) == terrors.Backend
+
+	if probe {
+		if failed {
+			b.state = circuitOpen
+			b.openedAt = b.timeSource.Now()
+			return
+		}
+
+		glog.Infof("Backend recovered, closing circuit breaker")
+		b.state = circuitClosed
+		b.failures = 0
+		return
+	}
+
+	if b.state != circuitClosed {
+		return
+	}
+
+	if !failed {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+
+	if b.failures >= b.failureThreshold {
+		glog.Warningf("Backend failed %d RPCs in a row, opening circuit breaker: %v", b.failures, err)
+		b.state = circuitOpen
+		b.openedAt = b.timeSource.Now()
+	}
+}
+
+// Interceptor returns a gRPC client interceptor that fails RPCs while the circuit is open.
+func (b *CircuitBreaker) Interceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		allowed, probe := b.allow()
+
+		if !allowed {
+			return errCircuitOpen
+		}
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		b.record(err, probe)
+
+		return err
+	}
+}
+
+// Stats returns the state of the circuit and the number of RPCs that failed because it was
+// open.
+func (b *CircuitBreaker) Stats() (state string, rejected int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state, b.rejected
+}
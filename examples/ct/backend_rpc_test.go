@@ -0,0 +1,170 @@
+package ct
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	terrors "github.com/google/trillian/errors"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// fakeInvoker returns the errors it's given in turn, advancing ts by latency for each call
+type fakeInvoker struct {
+	errs    []error
+	calls   int
+	ts      *util.FakeTimeSource
+	latency time.Duration
+}
+
+func (f *fakeInvoker) invoke(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+	err := f.errs[f.calls%len(f.errs)]
+	f.calls++
+
+	if f.ts != nil {
+		f.ts.FakeTime = f.ts.FakeTime.Add(f.latency)
+	}
+
+	return err
+}
+
+func TestChainUnaryClientInterceptors(t *testing.T) {
+	var order []string
+
+	interceptor := func(name string) grpc.UnaryClientInterceptor {
+		return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			order = append(order, name)
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+	}
+
+	invoker := &fakeInvoker{errs: []error{nil}}
+	chain := ChainUnaryClientInterceptors(interceptor("first"), interceptor("second"))
+
+	if err := chain(context.Background(), "/trillian.TrillianLog/QueueLeaves", nil, nil, nil, invoker.invoke); err != nil {
+		t.Fatalf("Chained RPC failed: %v", err)
+	}
+
+	if got, want := order, []string{"first", "second"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Interceptors ran in order %v, expected %v", got, want)
+	}
+
+	if invoker.calls != 1 {
+		t.Errorf("Got %d calls to the invoker, expected 1", invoker.calls)
+	}
+}
+
+func TestBackendRPCMetrics(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+	metrics := NewBackendRPCMetrics(ts)
+	invoker := &fakeInvoker{errs: []error{nil, terrors.New(terrors.Backend, "down"), terrors.New(terrors.NotFound, "no leaf")}, ts: ts, latency: time.Millisecond * 100}
+	interceptor := metrics.Interceptor()
+
+	for i := 0; i < 3; i++ {
+		interceptor(context.Background(), "/trillian.TrillianLog/GetLeavesByHash", nil, nil, nil, invoker.invoke)
+	}
+
+	interceptor(context.Background(), "/trillian.TrillianLog/QueueLeaves", nil, nil, nil, invoker.invoke)
+
+	want := map[string]BackendRPCStats{
+		"/trillian.TrillianLog/GetLeavesByHash": {Count: 3, Errors: map[string]int64{terrors.Backend.String(): 1, terrors.NotFound.String(): 1}, LatencySeconds: 0.3, LatencyBuckets: []int64{0, 0, 3, 0, 0, 0, 0}},
+		"/trillian.TrillianLog/QueueLeaves":     {Count: 1, Errors: map[string]int64{}, LatencySeconds: 0.1, LatencyBuckets: []int64{0, 0, 1, 0, 0, 0, 0}},
+	}
+
+	got := metrics.Stats()
+
+	// Float sums aren't exact
+	for method, stats := range got {
+		if diff := stats.LatencySeconds - want[method].LatencySeconds; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("Got latency %v for %s, expected %v", stats.LatencySeconds, method, want[method].LatencySeconds)
+		}
+
+		stats.LatencySeconds = want[method].LatencySeconds
+		got[method] = stats
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got stats %+v, expected %+v", got, want)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+	breaker := NewCircuitBreaker(3, time.Second*10, ts)
+	interceptor := breaker.Interceptor()
+	backendErr := terrors.New(terrors.Backend, "down")
+
+	call := func(err error) error {
+		invoker := &fakeInvoker{errs: []error{err}}
+		return interceptor(context.Background(), "/trillian.TrillianLog/QueueLeaves", nil, nil, nil, invoker.invoke)
+	}
+
+	// Errors that aren't the backend's fault and successes don't open the circuit
+	call(backendErr)
+	call(backendErr)
+	call(terrors.New(terrors.NotFound, "no leaf"))
+	call(backendErr)
+	call(backendErr)
+	call(nil)
+
+	if state, _ := breaker.Stats(); state != circuitClosed {
+		t.Fatalf("Circuit is %s after failures that weren't consecutive, expected it to be closed", state)
+	}
+
+	for i := 0; i < 3; i++ {
+		call(backendErr)
+	}
+
+	if err := call(nil); err != errCircuitOpen {
+		t.Fatalf("Got %v from RPC with open circuit, expected %v", err, errCircuitOpen)
+	}
+
+	// The probe fails so the circuit stays open for another period
+	ts.FakeTime = ts.FakeTime.Add(time.Second * 10)
+
+	if err := call(backendErr); err != backendErr {
+		t.Fatalf("Got %v from probe, expected it to be made", err)
+	}
+
+	if err := call(nil); err != errCircuitOpen {
+		t.Fatalf("Got %v after failed probe, expected %v", err, errCircuitOpen)
+	}
+
+	// A successful probe closes it
+	ts.FakeTime = ts.FakeTime.Add(time.Second * 10)
+
+	for i := 0; i < 2; i++ {
+		if err := call(nil); err != nil {
+			t.Fatalf("RPC %d after recovery failed: %v", i, err)
+		}
+	}
+
+	if state, rejected := breaker.Stats(); state != circuitClosed || rejected != 2 {
+		t.Errorf("Got state %s with %d rejected RPCs, expected %s with 2", state, rejected, circuitClosed)
+	}
+}
+
+func TestCircuitBreakerHalfOpenAllowsOneProbe(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+	breaker := NewCircuitBreaker(1, time.Second, ts)
+	breaker.record(errors.New("unknown errors don't count"), false)
+
+	if state, _ := breaker.Stats(); state != circuitClosed {
+		t.Fatalf("Circuit is %s after an unknown error, expected it to be closed", state)
+	}
+
+	breaker.record(terrors.New(terrors.Backend, "down"), false)
+	ts.FakeTime = ts.FakeTime.Add(time.Second)
+
+	if allowed, probe := breaker.allow(); !allowed || !probe {
+		t.Fatalf("Got allow()=%v, %v after the open duration, expected a probe", allowed, probe)
+	}
+
+	// Other RPCs fail while the probe is in flight
+	if allowed, _ := breaker.allow(); allowed {
+		t.Error("Allowed a second RPC while the probe was in flight")
+	}
+}
@@ -10,27 +10,56 @@ import (
 	"github.com/google/certificate-transparency/go/x509"
 )
 
-// OID of the non-critical extension used to mark pre-certificates, defined in RFC 6962
+// OID of the critical extension used to mark pre-certificates, defined in RFC 6962
 var ctPoisonExtensionOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}
 
 // Byte representation of ASN.1 NULL.
 var asn1NullBytes = []byte{0x05, 0x00}
 
-// IsPrecertificate tests if a certificate is a pre-certificate as defined in CT.
-// An error is returned if the CT extension is present but is not ASN.1 NULL as defined
-// by the spec.
+// PoisonExtensionError is returned if a certificate has the CT poison extension but it isn't
+// exactly as RFC 6962 section 3.1 requires. Such a certificate is neither a valid certificate
+// nor a valid pre-certificate and must be rejected by both add-chain and add-pre-chain.
+type PoisonExtensionError struct {
+	Reason string
+}
+
+func (e PoisonExtensionError) Error() string {
+	return fmt.Sprintf("malformed CT poison extension: %s", e.Reason)
+}
+
+// IsPrecertificate tests if a certificate is a pre-certificate as defined in CT. Only an
+// extension with exactly the poison OID counts. If it's present it must appear once, be
+// critical and have a value of exactly ASN.1 NULL, otherwise a PoisonExtensionError is
+// returned.
 func IsPrecertificate(cert *x509.Certificate) (bool, error) {
+	found := false
+
 	for _, ext := range cert.Extensions {
-		if ctPoisonExtensionOID.Equal(ext.Id) {
-			if !ext.Critical || !bytes.Equal(asn1NullBytes, ext.Value) {
-				return false, fmt.Errorf("CT poison ext is not critical or invalid: %v", ext)
-			}
+		if !ctPoisonExtensionOID.Equal(ext.Id) {
+			continue
+		}
+
+		if found {
+			return false, PoisonExtensionError{Reason: "extension appears more than once"}
+		}
+
+		found = true
 
-			return true, nil
+		if !ext.Critical {
+			return false, PoisonExtensionError{Reason: "extension is not critical"}
+		}
+
+		if len(ext.Value) == 0 {
+			return false, PoisonExtensionError{Reason: "extension value is empty, expected ASN.1 NULL"}
+		}
+
+		// Other encodings of NULL, e.g. with a long form length, and trailing data are refused
+		if !bytes.Equal(asn1NullBytes, ext.Value) {
+			return false, PoisonExtensionError{Reason: fmt.Sprintf("extension value is %x, expected ASN.1 NULL", ext.Value)}
 		}
 	}
 
-	return false, nil
+	return found, nil
 }
 
 // ValidateChain takes the certificate chain as it was parsed from a JSON request. Ensures all
@@ -39,11 +68,30 @@ func IsPrecertificate(cert *x509.Certificate) (bool, error) {
 // supplied in the chain. Then applies the RFC requirement that the path must involve all
 // the submitted chain in the order of submission.
 func ValidateChain(jsonChain []string, trustedRoots PEMCertPool) ([]*x509.Certificate, error) {
-	// First decode the base 64 certs and make sure they parse as X.509
+	return ValidateChainWithLimits(jsonChain, trustedRoots, nil)
+}
+
+// ValidateChainWithLimits is ValidateChain but refuses chains that would take more work to
+// verify than limits allow with a ChainLimitError. There are no limits if limits is nil.
+func ValidateChainWithLimits(jsonChain []string, trustedRoots PEMCertPool, limits *ChainLimits) ([]*x509.Certificate, error) {
+	chain, err := parseChain(jsonChain)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := limits.check(chain, trustedRoots); err != nil {
+		return nil, err
+	}
+
+	return verifyChain(chain, trustedRoots)
+}
+
+// parseChain decodes the base 64 certs in a submitted chain and makes sure they parse as X.509
+func parseChain(jsonChain []string) ([]*x509.Certificate, error) {
 	chain := make([]*x509.Certificate, 0, len(jsonChain))
-	intermediatePool := NewPEMCertPool()
 
-	for i, certB64 := range jsonChain {
+	for _, certB64 := range jsonChain {
 		certBytes, err := base64.StdEncoding.DecodeString(certB64)
 
 		if err != nil {
@@ -61,11 +109,23 @@ func ValidateChain(jsonChain []string, trustedRoots PEMCertPool) ([]*x509.Certif
 		}
 
 		chain = append(chain, cert)
+	}
 
-		// All but the first cert form part of the intermediate pool
-		if i > 0 {
-			intermediatePool.AddCert(cert)
-		}
+	return chain, nil
+}
+
+// verifyChain checks that there is an RFC compliant path from the first cert in a parsed chain
+// to a trusted root, see ValidateChain.
+func verifyChain(chain []*x509.Certificate, trustedRoots PEMCertPool) ([]*x509.Certificate, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("no certificates in chain")
+	}
+
+	// All but the first cert form part of the intermediate pool
+	intermediatePool := NewPEMCertPool()
+
+	for _, cert := range chain[1:] {
+		intermediatePool.AddCert(cert)
 	}
 
 	// We can now do the verify
@@ -3,8 +3,10 @@ package ct
 import (
 	"encoding/base64"
 	"encoding/pem"
+	"strings"
 	"testing"
 
+	"github.com/google/certificate-transparency/go/asn1"
 	"github.com/google/certificate-transparency/go/x509"
 	"github.com/google/certificate-transparency/go/x509/pkix"
 	"github.com/google/trillian/examples/ct/testonly"
@@ -71,6 +73,59 @@ func TestIsPrecertificateInvalidBytesInExtension(t *testing.T) {
 	}
 }
 
+func TestIsPrecertificateMalformedPoison(t *testing.T) {
+	valid := pkix.Extension{Id: ctPoisonExtensionOID, Critical: true, Value: asn1NullBytes}
+
+	for _, test := range []struct {
+		desc       string
+		extensions []pkix.Extension
+		reason     string
+	}{
+		{"not critical", []pkix.Extension{{Id: ctPoisonExtensionOID, Value: asn1NullBytes}}, "not critical"},
+		{"empty value", []pkix.Extension{{Id: ctPoisonExtensionOID, Critical: true}}, "empty"},
+		{"not NULL", []pkix.Extension{{Id: ctPoisonExtensionOID, Critical: true, Value: []byte{0x04, 0x00}}}, "0400"},
+		{"trailing data", []pkix.Extension{{Id: ctPoisonExtensionOID, Critical: true, Value: []byte{0x05, 0x00, 0x00}}}, "050000"},
+		{"long form NULL", []pkix.Extension{{Id: ctPoisonExtensionOID, Critical: true, Value: []byte{0x05, 0x81, 0x00}}}, "058100"},
+		{"NULL with contents", []pkix.Extension{{Id: ctPoisonExtensionOID, Critical: true, Value: []byte{0x05, 0x01, 0x00}}}, "050100"},
+		{"duplicated", []pkix.Extension{valid, valid}, "more than once"},
+	} {
+		cert := pemToCert(t, testonly.PrecertPEMValid)
+		cert.Extensions = test.extensions
+
+		isPrecert, err := IsPrecertificate(cert)
+		poisonErr, ok := err.(PoisonExtensionError)
+
+		if !ok {
+			t.Errorf("%s: IsPrecertificate()=%v, %v, expected a PoisonExtensionError", test.desc, isPrecert, err)
+			continue
+		}
+
+		if isPrecert || !strings.Contains(poisonErr.Reason, test.reason) {
+			t.Errorf("%s: IsPrecertificate()=%v, %v, expected false and a reason containing %q", test.desc, isPrecert, err, test.reason)
+		}
+	}
+}
+
+func TestIsPrecertificateExactOID(t *testing.T) {
+	// Extensions with OIDs close to the poison OID don't make a certificate a precert, even if
+	// they look like poison
+	for _, oid := range []asn1.ObjectIdentifier{
+		{1, 3, 6, 1, 4, 1, 11129, 2, 4},
+		{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3, 1},
+		{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2},
+		{1, 3, 6, 1, 4, 1, 11129, 2, 5, 3},
+	} {
+		cert := pemToCert(t, testonly.PrecertPEMValid)
+		cert.Extensions = []pkix.Extension{{Id: oid, Critical: true, Value: asn1NullBytes}}
+
+		isPrecert, err := IsPrecertificate(cert)
+
+		if err != nil || isPrecert {
+			t.Errorf("IsPrecertificate() with extension %v=%v, %v, expected false, nil", oid, isPrecert, err)
+		}
+	}
+}
+
 func TestCertCheckerInvalidChainAccepted(t *testing.T) {
 	// This shouldn't validate as it's missing the intermediate cert
 	chainPem := []string{testonly.LeafSignedByFakeIntermediateCertPem}
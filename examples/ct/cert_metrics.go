@@ -0,0 +1,216 @@
+package ct
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/certificate-transparency/go/x509"
+)
+
+const (
+	// contentTypePrometheus is the version of the Prometheus text format that /metrics serves
+	contentTypePrometheus string = "text/plain; version=0.0.4"
+	// maxTrackedIssuers bounds the memory used for counting issuers. Issuers first seen after
+	// this many are only included in the totals.
+	maxTrackedIssuers int = 10000
+	// unknownIssuer is the label used for certificates whose issuer has no common name
+	unknownIssuer string = "unknown"
+)
+
+// validityBucketsDays are the upper bounds of the validity period histogram buckets, chosen
+// around the maximum lifetimes that have been allowed for certificates.
+var validityBucketsDays = []float64{30, 90, 180, 398, 825, 1185}
+
+// CertMetrics summarizes the certificates submitted to add-chain and add-pre-chain for
+// monitoring the ecosystem and serves them on /metrics in the Prometheus text format. It
+// counts submissions by type, key algorithm and issuer common name, and records a histogram
+// of validity periods. Only the most frequent issuers are exported so the number of series
+// stays bounded. It is safe for concurrent use.
+type CertMetrics struct {
+	// topIssuers is the number of issuers that are exported
+	topIssuers int
+
+	// mu guards the fields below it
+	mu sync.Mutex
+	// byType counts submissions by "cert" or "precert"
+	byType map[string]int64
+	// byKeyAlgorithm counts submissions by the algorithm of the subject public key
+	byKeyAlgorithm map[string]int64
+	// byIssuer counts submissions by issuer common name, for up to maxTrackedIssuers issuers
+	byIssuer map[string]int64
+	// validityBuckets counts validity periods up to each bound in validityBucketsDays, with an
+	// extra bucket at the end for longer periods. They are made cumulative when exported.
+	validityBuckets []int64
+	// validitySumDays is the total validity period of all submissions
+	validitySumDays float64
+	// count is the total number of submissions
+	count int64
+}
+
+// NewCertMetrics creates a CertMetrics that exports counts for the topIssuers most frequent
+// issuers.
+func NewCertMetrics(topIssuers int) (*CertMetrics, error) {
+	if topIssuers <= 0 {
+		return nil, errors.New("number of issuers to export must be positive")
+	}
+
+	return &CertMetrics{
+		topIssuers:      topIssuers,
+		byType:          make(map[string]int64),
+		byKeyAlgorithm:  make(map[string]int64),
+		byIssuer:        make(map[string]int64),
+		validityBuckets: make([]int64, len(validityBucketsDays)+1),
+	}, nil
+}
+
+// Record adds a submitted end entity certificate or precertificate to the metrics.
+func (m *CertMetrics) Record(cert *x509.Certificate, isPrecert bool) {
+	entryType := "cert"
+	if isPrecert {
+		entryType = "precert"
+	}
+
+	issuer := cert.Issuer.CommonName
+	if len(issuer) == 0 {
+		issuer = unknownIssuer
+	}
+
+	validityDays := cert.NotAfter.Sub(cert.NotBefore).Hours() / 24
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.count++
+	m.byType[entryType]++
+	m.byKeyAlgorithm[keyAlgorithmName(cert.PublicKeyAlgorithm)]++
+
+	if _, ok := m.byIssuer[issuer]; ok || len(m.byIssuer) < maxTrackedIssuers {
+		m.byIssuer[issuer]++
+	}
+
+	m.validityBuckets[sort.SearchFloat64s(validityBucketsDays, validityDays)]++
+	m.validitySumDays += validityDays
+}
+
+func keyAlgorithmName(algorithm x509.PublicKeyAlgorithm) string {
+	switch algorithm {
+	case x509.RSA:
+		return "RSA"
+	case x509.DSA:
+		return "DSA"
+	case x509.ECDSA:
+		return "ECDSA"
+	default:
+		return "unknown"
+	}
+}
+
+// WriteText writes the metrics in the Prometheus text format.
+func (m *CertMetrics) WriteText(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	writeCounter(w, "ct_submissions_total", "Certificates submitted to add-chain and add-pre-chain by type.", "type", m.byType)
+	writeCounter(w, "ct_submissions_by_key_algorithm_total", "Certificates submitted by the algorithm of their public key.", "algorithm", m.byKeyAlgorithm)
+	writeCounter(w, "ct_submissions_by_issuer_total", fmt.Sprintf("Certificates submitted by issuer common name, for the %d most frequent issuers.", m.topIssuers), "issuer", m.topIssuerCounts())
+
+	fmt.Fprintln(w, "# HELP ct_submission_validity_days Validity period of submitted certificates in days.")
+	fmt.Fprintln(w, "# TYPE ct_submission_validity_days histogram")
+
+	cumulative := int64(0)
+	for i, bound := range validityBucketsDays {
+		cumulative += m.validityBuckets[i]
+		fmt.Fprintf(w, "ct_submission_validity_days_bucket{le=\"%g\"} %d\n", bound, cumulative)
+	}
+
+	fmt.Fprintf(w, "ct_submission_validity_days_bucket{le=\"+Inf\"} %d\n", m.count)
+	fmt.Fprintf(w, "ct_submission_validity_days_sum %g\n", m.validitySumDays)
+	fmt.Fprintf(w, "ct_submission_validity_days_count %d\n", m.count)
+}
+
+// topIssuerCounts returns the counts for the most frequent issuers. Must be called with mu held.
+func (m *CertMetrics) topIssuerCounts() map[string]int64 {
+	issuers := make([]string, 0, len(m.byIssuer))
+	for issuer := range m.byIssuer {
+		issuers = append(issuers, issuer)
+	}
+
+	// Most frequent first, ties broken by name so the result is stable
+	sort.Sort(byCount{issuers, m.byIssuer})
+
+	if len(issuers) > m.topIssuers {
+		issuers = issuers[:m.topIssuers]
+	}
+
+	top := make(map[string]int64, len(issuers))
+	for _, issuer := range issuers {
+		top[issuer] = m.byIssuer[issuer]
+	}
+
+	return top
+}
+
+type byCount struct {
+	names  []string
+	counts map[string]int64
+}
+
+func (b byCount) Len() int      { return len(b.names) }
+func (b byCount) Swap(i, j int) { b.names[i], b.names[j] = b.names[j], b.names[i] }
+func (b byCount) Less(i, j int) bool {
+	ci, cj := b.counts[b.names[i]], b.counts[b.names[j]]
+	if ci != cj {
+		return ci > cj
+	}
+	return b.names[i] < b.names[j]
+}
+
+// writeCounter writes a counter with one label, with the series sorted by label value
+func writeCounter(w io.Writer, name, help, label string, values map[string]int64) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s counter\n", name)
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s{%s=\"%s\"} %d\n", name, label, escapeLabelValue(key), values[key])
+	}
+}
+
+// labelEscaper escapes label values as required by the Prometheus text format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// wrappedGetMetricsHandler serves the metrics written by each of writers in turn in the
+// Prometheus text format
+func wrappedGetMetricsHandler(writers ...func(io.Writer)) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		if !enforceMethod(w, r, httpMethodGet) {
+			return http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method)
+		}
+
+		var buf bytes.Buffer
+
+		for _, write := range writers {
+			write(&buf)
+		}
+
+		w.Header().Set(contentTypeHeader, contentTypePrometheus)
+		w.Write(buf.Bytes())
+
+		return http.StatusOK, nil
+	}
+}
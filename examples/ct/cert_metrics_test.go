@@ -0,0 +1,113 @@
+package ct
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/trillian/examples/ct/testonly"
+)
+
+func TestNewCertMetricsRejectsBadConfig(t *testing.T) {
+	for _, topIssuers := range []int{0, -1} {
+		if _, err := NewCertMetrics(topIssuers); err == nil {
+			t.Errorf("Created metrics exporting %d issuers", topIssuers)
+		}
+	}
+}
+
+func TestCertMetricsRecord(t *testing.T) {
+	metrics, err := NewCertMetrics(2)
+
+	if err != nil {
+		t.Fatalf("Failed to create metrics: %v", err)
+	}
+
+	// The precert's issuer has no CN and it is valid for 3652 days. The others are valid for
+	// 1155 days, the leaf has an ECDSA key and the intermediate an RSA key.
+	metrics.Record(pemToCert(t, testonly.PrecertPEMValid), true)
+	metrics.Record(pemToCert(t, testonly.LeafSignedByFakeIntermediateCertPem), false)
+	metrics.Record(pemToCert(t, testonly.LeafSignedByFakeIntermediateCertPem), false)
+	metrics.Record(pemToCert(t, testonly.FakeIntermediateCertPem), false)
+
+	var buf bytes.Buffer
+	metrics.WriteText(&buf)
+	text := buf.String()
+
+	for _, want := range []string{
+		"# TYPE ct_submissions_total counter\n",
+		"ct_submissions_total{type=\"cert\"} 3\n",
+		"ct_submissions_total{type=\"precert\"} 1\n",
+		"ct_submissions_by_key_algorithm_total{algorithm=\"ECDSA\"} 2\n",
+		"ct_submissions_by_key_algorithm_total{algorithm=\"RSA\"} 2\n",
+		"ct_submissions_by_issuer_total{issuer=\"FakeIntermediateAuthority\"} 2\n",
+		// Ties are broken by name so this is exported rather than the precert's issuer
+		"ct_submissions_by_issuer_total{issuer=\"FakeCertificateAuthority\"} 1\n",
+		"# TYPE ct_submission_validity_days histogram\n",
+		"ct_submission_validity_days_bucket{le=\"825\"} 0\n",
+		"ct_submission_validity_days_bucket{le=\"1185\"} 3\n",
+		"ct_submission_validity_days_bucket{le=\"+Inf\"} 4\n",
+		"ct_submission_validity_days_sum 7117\n",
+		"ct_submission_validity_days_count 4\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Metrics missing %q, got:\n%s", want, text)
+		}
+	}
+
+	if strings.Contains(text, unknownIssuer) {
+		t.Errorf("Issuer outside the top 2 was exported:\n%s", text)
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	if got, want := escapeLabelValue("a\\b\"c\nd"), `a\\b\"c\nd`; got != want {
+		t.Errorf("Got escaped value %q, expected %q", got, want)
+	}
+}
+
+func TestGetMetricsHandler(t *testing.T) {
+	metrics, err := NewCertMetrics(1)
+
+	if err != nil {
+		t.Fatalf("Failed to create metrics: %v", err)
+	}
+
+	metrics.Record(pemToCert(t, testonly.LeafSignedByFakeIntermediateCertPem), false)
+
+	req, err := http.NewRequest("GET", "http://example.com/metrics", nil)
+
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	wrappedGetMetricsHandler(metrics.WriteText).ServeHTTP(w, req)
+
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Fatalf("Got status %d from /metrics, expected %d", got, want)
+	}
+
+	if got, want := w.Header().Get(contentTypeHeader), contentTypePrometheus; got != want {
+		t.Errorf("Got content type %q, expected %q", got, want)
+	}
+
+	if got, want := w.Body.String(), "ct_submissions_total{type=\"cert\"} 1\n"; !strings.Contains(got, want) {
+		t.Errorf("Metrics missing %q, got:\n%s", want, got)
+	}
+
+	req, err = http.NewRequest("POST", "http://example.com/metrics", nil)
+
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	w = httptest.NewRecorder()
+	wrappedGetMetricsHandler(metrics.WriteText).ServeHTTP(w, req)
+
+	if got, want := w.Code, http.StatusMethodNotAllowed; got != want {
+		t.Errorf("Got status %d for POST, expected %d", got, want)
+	}
+}
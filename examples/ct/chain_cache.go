@@ -0,0 +1,207 @@
+package ct
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/certificate-transparency/go/x509"
+	"github.com/google/trillian/util"
+)
+
+// chainFingerprint identifies the intermediates of a submitted chain
+type chainFingerprint [sha256.Size]byte
+
+// chainCacheEntry is the value stored in the LRU list
+type chainCacheEntry struct {
+	fingerprint chainFingerprint
+	expires     time.Time
+}
+
+// ChainCache remembers the intermediates of chains that have been verified to a trusted root
+// so that resubmissions of the same intermediates, which are common as CAs use the same ones
+// for every certificate, only need the leaf verified against the first of them. The cache is keyed by a
+// hash of the submitted chain excluding the leaf, so the intermediates must be identical and
+// in the same order. Entries expire after a TTL and the least recently used are evicted when
+// the cache is full. A cache must only be used with one set of trusted roots, it's cleared when
+// a log's live roots change. It is safe for concurrent use.
+type ChainCache struct {
+	// capacity is the maximum number of chains that will be held
+	capacity int
+	// ttl is how long a verified chain is trusted for
+	ttl        time.Duration
+	timeSource util.TimeSource
+
+	// mu guards the fields below it
+	mu sync.Mutex
+	// entries maps fingerprints to their element in lru
+	entries map[chainFingerprint]*list.Element
+	// lru holds chainCacheEntry values, most recently used at the front
+	lru *list.List
+	// hits and misses count lookups for monitoring
+	hits   int64
+	misses int64
+}
+
+// NewChainCache creates a ChainCache that holds at most capacity chains for up to ttl.
+func NewChainCache(capacity int, ttl time.Duration, timeSource util.TimeSource) (*ChainCache, error) {
+	if capacity <= 0 {
+		return nil, errors.New("chain cache capacity must be positive")
+	}
+
+	if ttl <= 0 {
+		return nil, errors.New("chain cache TTL must be positive")
+	}
+
+	return &ChainCache{capacity: capacity, ttl: ttl, timeSource: timeSource, entries: make(map[chainFingerprint]*list.Element), lru: list.New()}, nil
+}
+
+// ValidateChain has the same result as ValidateChainWithLimits but skips verifying the
+// intermediates if they are in the cache. Chains without intermediates are always verified
+// in full. Chains that exceed limits are refused even if their intermediates are cached.
+func (c *ChainCache) ValidateChain(jsonChain []string, trustedRoots PEMCertPool, limits *ChainLimits) ([]*x509.Certificate, error) {
+	chain, err := parseChain(jsonChain)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := limits.check(chain, trustedRoots); err != nil {
+		return nil, err
+	}
+
+	if len(chain) < 2 {
+		return verifyChain(chain, trustedRoots)
+	}
+
+	fingerprint := fingerprintIntermediates(chain[1:])
+
+	// The intermediates are known to form a compliant path to a root so the leaf only has to
+	// verify with the first of them as its root. If it doesn't, full verification reports why.
+	if c.get(fingerprint) && verifyLeaf(chain[0], chain[1]) == nil {
+		return chain, nil
+	}
+
+	validPath, err := verifyChain(chain, trustedRoots)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.put(fingerprint)
+
+	return validPath, nil
+}
+
+// verifyLeaf checks that leaf verifies with the same options as verifyChain, treating issuer
+// as a trusted root so that only the leaf itself is checked and no path is built.
+func verifyLeaf(leaf, issuer *x509.Certificate) error {
+	issuerPool := NewPEMCertPool()
+	issuerPool.AddCert(issuer)
+
+	verifyOpts := x509.VerifyOptions{
+		Roots:             issuerPool.CertPool(),
+		DisableTimeChecks: true,
+		KeyUsages:         []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}
+
+	_, err := leaf.Verify(verifyOpts)
+
+	return err
+}
+
+// fingerprintIntermediates hashes the DER of each cert along with its length so that different
+// splits of the same bytes don't collide.
+func fingerprintIntermediates(certs []*x509.Certificate) chainFingerprint {
+	h := sha256.New()
+
+	for _, cert := range certs {
+		var length [4]byte
+		binary.BigEndian.PutUint32(length[:], uint32(len(cert.Raw)))
+		h.Write(length[:])
+		h.Write(cert.Raw)
+	}
+
+	var fingerprint chainFingerprint
+	copy(fingerprint[:], h.Sum(nil))
+
+	return fingerprint
+}
+
+// get returns true if the fingerprint is cached and hasn't expired.
+func (c *ChainCache) get(fingerprint chainFingerprint) bool {
+	now := c.timeSource.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[fingerprint]
+
+	if ok && now.After(elem.Value.(chainCacheEntry).expires) {
+		c.removeElementLocked(elem)
+		ok = false
+	}
+
+	if !ok {
+		c.misses++
+		return false
+	}
+
+	c.hits++
+	c.lru.MoveToFront(elem)
+	return true
+}
+
+// put adds a fingerprint to the cache, or refreshes its expiry if it's already present, and
+// evicts the least recently used entries if the cache is full.
+func (c *ChainCache) put(fingerprint chainFingerprint) {
+	entry := chainCacheEntry{fingerprint: fingerprint, expires: c.timeSource.Now().Add(c.ttl)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[fingerprint]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	c.entries[fingerprint] = c.lru.PushFront(entry)
+
+	for c.lru.Len() > c.capacity {
+		c.removeElementLocked(c.lru.Back())
+	}
+}
+
+func (c *ChainCache) removeElementLocked(elem *list.Element) {
+	delete(c.entries, elem.Value.(chainCacheEntry).fingerprint)
+	c.lru.Remove(elem)
+}
+
+// clear forgets all the cached chains, e.g. when the trusted roots change.
+func (c *ChainCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[chainFingerprint]*list.Element)
+	c.lru.Init()
+}
+
+// Len returns the number of chains currently cached, which may include expired ones that
+// haven't been looked up since.
+func (c *ChainCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len()
+}
+
+// Stats returns the number of cache hits and misses so far.
+func (c *ChainCache) Stats() (hits, misses int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.hits, c.misses
+}
@@ -0,0 +1,160 @@
+package ct
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/certificate-transparency/go/x509"
+	"github.com/google/trillian/examples/ct/testonly"
+	"github.com/google/trillian/util"
+)
+
+func newChainCacheOrDie(t *testing.T, capacity int, ttl time.Duration, ts util.TimeSource) *ChainCache {
+	cache, err := NewChainCache(capacity, ttl, ts)
+
+	if err != nil {
+		t.Fatalf("Failed to create chain cache: %v", err)
+	}
+
+	return cache
+}
+
+func fakeRootPool(t *testing.T) PEMCertPool {
+	trustedRoots := NewPEMCertPool()
+
+	if !trustedRoots.AppendCertsFromPEM([]byte(testonly.FakeCACertPem)) {
+		t.Fatal("failed to load fake root")
+	}
+
+	return *trustedRoots
+}
+
+func TestNewChainCacheRejectsBadConfig(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+
+	if _, err := NewChainCache(0, time.Hour, ts); err == nil {
+		t.Error("Created chain cache with zero capacity")
+	}
+
+	if _, err := NewChainCache(10, 0, ts); err == nil {
+		t.Error("Created chain cache with zero TTL")
+	}
+}
+
+func TestChainCacheValidChain(t *testing.T) {
+	cache := newChainCacheOrDie(t, 10, time.Hour, &util.FakeTimeSource{FakeTime: fakeTime})
+	jsonChain := pemsToJsonChain(t, []string{testonly.LeafSignedByFakeIntermediateCertPem, testonly.FakeIntermediateCertPem})
+	trustedRoots := fakeRootPool(t)
+
+	for i := 0; i < 2; i++ {
+		validPath, err := cache.ValidateChain(jsonChain, trustedRoots, nil)
+
+		if err != nil {
+			t.Fatalf("unexpected error verifying valid chain %v", err)
+		}
+		if got, want := len(validPath), 2; got != want {
+			t.Fatalf("got path of len %d, but expected length %d", got, want)
+		}
+	}
+
+	hits, misses := cache.Stats()
+
+	if got, want := hits, int64(1); got != want {
+		t.Errorf("Got %d hits, expected %d", got, want)
+	}
+	if got, want := misses, int64(1); got != want {
+		t.Errorf("Got %d misses, expected %d", got, want)
+	}
+}
+
+func TestChainCacheChecksLeafOnHit(t *testing.T) {
+	cache := newChainCacheOrDie(t, 10, time.Hour, &util.FakeTimeSource{FakeTime: fakeTime})
+	trustedRoots := fakeRootPool(t)
+
+	if _, err := cache.ValidateChain(pemsToJsonChain(t, []string{testonly.LeafSignedByFakeIntermediateCertPem, testonly.FakeIntermediateCertPem}), trustedRoots, nil); err != nil {
+		t.Fatalf("unexpected error verifying valid chain %v", err)
+	}
+
+	// The intermediate is cached but this leaf wasn't issued by it
+	if _, err := cache.ValidateChain(pemsToJsonChain(t, []string{testonly.TestCertPEM, testonly.FakeIntermediateCertPem}), trustedRoots, nil); err == nil {
+		t.Fatal("verification accepted a leaf not issued by a cached intermediate")
+	}
+}
+
+func TestVerifyLeaf(t *testing.T) {
+	intermediate := pemToCert(t, testonly.FakeIntermediateCertPem)
+
+	if err := verifyLeaf(pemToCert(t, testonly.LeafSignedByFakeIntermediateCertPem), intermediate); err != nil {
+		t.Fatalf("verifyLeaf() of a leaf issued by the intermediate failed: %v", err)
+	}
+
+	if err := verifyLeaf(pemToCert(t, testonly.TestCertPEM), intermediate); err == nil {
+		t.Fatal("verifyLeaf() accepted a leaf not issued by the intermediate")
+	}
+}
+
+func TestChainCacheInvalidChainNotCached(t *testing.T) {
+	cache := newChainCacheOrDie(t, 10, time.Hour, &util.FakeTimeSource{FakeTime: fakeTime})
+	jsonChain := pemsToJsonChain(t, []string{testonly.LeafSignedByFakeIntermediateCertPem, testonly.FakeIntermediateCertPem, testonly.TestCertPEM})
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.ValidateChain(jsonChain, fakeRootPool(t), nil); err == nil {
+			t.Fatal("verification accepted an invalid chain (unrelated at end)")
+		}
+	}
+
+	if got, want := cache.Len(), 0; got != want {
+		t.Fatalf("Got %d cached chains, expected %d", got, want)
+	}
+}
+
+func TestChainCacheExpiry(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+	cache := newChainCacheOrDie(t, 10, time.Hour, ts)
+	fingerprint := chainFingerprint{1}
+
+	cache.put(fingerprint)
+	ts.FakeTime = ts.FakeTime.Add(time.Hour)
+
+	if !cache.get(fingerprint) {
+		t.Fatal("Chain expired before its TTL")
+	}
+
+	ts.FakeTime = ts.FakeTime.Add(time.Second)
+
+	if cache.get(fingerprint) {
+		t.Fatal("Got chain after its TTL")
+	}
+	if got, want := cache.Len(), 0; got != want {
+		t.Fatalf("Got %d cached chains, expected %d", got, want)
+	}
+}
+
+func TestChainCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newChainCacheOrDie(t, 2, time.Hour, &util.FakeTimeSource{FakeTime: fakeTime})
+
+	cache.put(chainFingerprint{1})
+	cache.put(chainFingerprint{2})
+	// Use 1 so that 2 is evicted next
+	cache.get(chainFingerprint{1})
+	cache.put(chainFingerprint{3})
+
+	if got, want := cache.Len(), 2; got != want {
+		t.Fatalf("Got %d cached chains, expected %d", got, want)
+	}
+
+	for fingerprint, want := range map[chainFingerprint]bool{{1}: true, {2}: false, {3}: true} {
+		if got := cache.get(fingerprint); got != want {
+			t.Errorf("get(%x)=%v, expected %v", fingerprint[0], got, want)
+		}
+	}
+}
+
+func TestFingerprintIntermediatesOrder(t *testing.T) {
+	a := pemToCert(t, testonly.FakeIntermediateCertPem)
+	b := pemToCert(t, testonly.CACertPEM)
+
+	if fingerprintIntermediates([]*x509.Certificate{a, b}) == fingerprintIntermediates([]*x509.Certificate{b, a}) {
+		t.Fatal("Fingerprint doesn't depend on the order of the intermediates")
+	}
+}
@@ -0,0 +1,184 @@
+package ct
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/google/certificate-transparency/go/x509"
+)
+
+// ChainLimitError is returned when verifying a submitted chain would take more work than
+// ChainLimits allow. It's returned by add-chain and add-pre-chain as is so that submitters can
+// see which limit they hit.
+type ChainLimitError struct {
+	// Limit is the name of the limit that was exceeded
+	Limit string
+	// Max is the value of the limit
+	Max int
+}
+
+func (e ChainLimitError) Error() string {
+	return fmt.Sprintf("chain exceeds the limit of %d %s", e.Max, e.Limit)
+}
+
+// ChainLimits bounds the work done verifying a submitted chain. Certificates that have been
+// cross-signed appear more than once under the same name, and path building tries every
+// combination of them, so a long chain of cross-signed intermediates can take exponential
+// time. Chains with more certificates than the maximum depth are refused, as are chains
+// whose paths to a root would need more than the maximum number of signature checks. The
+// checks are counted, without verifying anything, by following the candidate issuers that
+// path building would try. Submissions that come within a quarter of either limit are counted
+// as near the limit, so the limits can be tuned before they start rejecting chains. It is
+// safe for concurrent use.
+type ChainLimits struct {
+	// maxDepth is the largest number of certificates a submitted chain may have, or 0
+	maxDepth int
+	// maxSignatureChecks is the largest number of signature checks a chain may need, or 0
+	maxSignatureChecks int
+
+	// mu guards the fields below it
+	mu sync.Mutex
+	// checked counts the chains that have been checked
+	checked int64
+	// nearLimit counts the accepted chains that came within a quarter of a limit
+	nearLimit int64
+	// rejected counts the chains that exceeded a limit
+	rejected int64
+}
+
+// NewChainLimits creates ChainLimits that refuse chains of more than maxDepth certificates or
+// that need more than maxSignatureChecks signature checks. Zero disables either limit.
+func NewChainLimits(maxDepth, maxSignatureChecks int) (*ChainLimits, error) {
+	if maxDepth < 0 || maxSignatureChecks < 0 {
+		return nil, errors.New("chain limits must not be negative")
+	}
+
+	return &ChainLimits{maxDepth: maxDepth, maxSignatureChecks: maxSignatureChecks}, nil
+}
+
+// Stats returns the number of chains that have been checked, the number that were accepted
+// but came near a limit and the number that were rejected.
+func (l *ChainLimits) Stats() (checked, nearLimit, rejected int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.checked, l.nearLimit, l.rejected
+}
+
+// check returns a ChainLimitError if verifying chain against trustedRoots would exceed the
+// limits. It does nothing if l is nil.
+func (l *ChainLimits) check(chain []*x509.Certificate, trustedRoots PEMCertPool) error {
+	if l == nil {
+		return nil
+	}
+
+	var err error
+	checks := 0
+
+	if l.maxDepth > 0 && len(chain) > l.maxDepth {
+		err = ChainLimitError{Limit: "certificates", Max: l.maxDepth}
+	} else if checks = l.signatureChecks(chain, trustedRoots); l.maxSignatureChecks > 0 && checks > l.maxSignatureChecks {
+		err = ChainLimitError{Limit: "signature checks", Max: l.maxSignatureChecks}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.checked++
+
+	if err != nil {
+		l.rejected++
+	} else if near(len(chain), l.maxDepth) || near(checks, l.maxSignatureChecks) {
+		l.nearLimit++
+	}
+
+	return err
+}
+
+// near returns true if value is within a quarter of limit, which is disabled if it's 0
+func near(value, limit int) bool {
+	return limit > 0 && value*4 >= limit*3
+}
+
+// signatureChecks returns the number of signature checks building paths from the first
+// cert of chain to trustedRoots could take, using the rest of chain as intermediates. It
+// stops counting once the maximum is exceeded.
+func (l *ChainLimits) signatureChecks(chain []*x509.Certificate, trustedRoots PEMCertPool) int {
+	if len(chain) == 0 || l.maxSignatureChecks == 0 {
+		return 0
+	}
+
+	counter := signatureCounter{roots: trustedRoots.RawCertificates(), intermediates: chain[1:], budget: l.maxSignatureChecks + 1}
+	counter.count(chain[0], []*x509.Certificate{chain[0]})
+
+	return l.maxSignatureChecks + 1 - counter.budget
+}
+
+// signatureCounter follows the candidate issuers that x509 path building tries, counting one
+// signature check for each and assuming that every signature is valid, which is the worst
+// case.
+type signatureCounter struct {
+	roots         []*x509.Certificate
+	intermediates []*x509.Certificate
+	// budget is the number of checks left before counting stops
+	budget int
+}
+
+func (s *signatureCounter) count(cert *x509.Certificate, path []*x509.Certificate) {
+	if roots := len(candidateIssuers(cert, s.roots)); roots < s.budget {
+		s.budget -= roots
+	} else {
+		s.budget = 0
+		return
+	}
+
+	for _, intermediate := range candidateIssuers(cert, s.intermediates) {
+		if s.budget == 0 {
+			return
+		}
+
+		// Path building doesn't use a certificate twice in the same path
+		if inPath(intermediate, path) {
+			continue
+		}
+
+		s.budget--
+		s.count(intermediate, append(path[:len(path):len(path)], intermediate))
+	}
+}
+
+// candidateIssuers returns the certs that could have issued cert, matching its authority key
+// ID if it has one and otherwise its issuer name, as x509.CertPool does.
+func candidateIssuers(cert *x509.Certificate, certs []*x509.Certificate) []*x509.Certificate {
+	var candidates []*x509.Certificate
+
+	if len(cert.AuthorityKeyId) > 0 {
+		for _, c := range certs {
+			if bytes.Equal(c.SubjectKeyId, cert.AuthorityKeyId) {
+				candidates = append(candidates, c)
+			}
+		}
+	}
+
+	if len(candidates) == 0 {
+		for _, c := range certs {
+			if bytes.Equal(c.RawSubject, cert.RawIssuer) {
+				candidates = append(candidates, c)
+			}
+		}
+	}
+
+	return candidates
+}
+
+func inPath(cert *x509.Certificate, path []*x509.Certificate) bool {
+	for _, c := range path {
+		if bytes.Equal(c.Raw, cert.Raw) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,109 @@
+package ct
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/certificate-transparency/go/x509"
+	"github.com/google/trillian/examples/ct/testonly"
+)
+
+func newChainLimitsOrDie(t *testing.T, maxDepth, maxSignatureChecks int) *ChainLimits {
+	limits, err := NewChainLimits(maxDepth, maxSignatureChecks)
+
+	if err != nil {
+		t.Fatalf("Failed to create chain limits: %v", err)
+	}
+
+	return limits
+}
+
+func TestNewChainLimitsRejectsNegative(t *testing.T) {
+	if _, err := NewChainLimits(-1, 10); err == nil {
+		t.Error("Created chain limits with negative depth")
+	}
+
+	if _, err := NewChainLimits(10, -1); err == nil {
+		t.Error("Created chain limits with negative signature checks")
+	}
+}
+
+func TestValidateChainWithLimits(t *testing.T) {
+	jsonChain := pemsToJsonChain(t, []string{testonly.LeafSignedByFakeIntermediateCertPem, testonly.FakeIntermediateCertPem})
+	trustedRoots := fakeRootPool(t)
+
+	for _, test := range []struct {
+		desc               string
+		maxDepth           int
+		maxSignatureChecks int
+		wantLimit          string
+		wantNear           bool
+	}{
+		{desc: "unlimited"},
+		{desc: "well within", maxDepth: 10, maxSignatureChecks: 100},
+		{desc: "depth at limit", maxDepth: 2, wantNear: true},
+		{desc: "too deep", maxDepth: 1, wantLimit: "certificates"},
+		{desc: "signatures at limit", maxSignatureChecks: 2, wantNear: true},
+		{desc: "too many signatures", maxSignatureChecks: 1, wantLimit: "signature checks"},
+	} {
+		limits := newChainLimitsOrDie(t, test.maxDepth, test.maxSignatureChecks)
+		_, err := ValidateChainWithLimits(jsonChain, trustedRoots, limits)
+
+		if limitErr, ok := err.(ChainLimitError); len(test.wantLimit) > 0 {
+			if !ok || limitErr.Limit != test.wantLimit {
+				t.Errorf("%s: got %v, expected a ChainLimitError for %s", test.desc, err, test.wantLimit)
+			}
+		} else if err != nil {
+			t.Errorf("%s: unexpected error verifying valid chain: %v", test.desc, err)
+		}
+
+		checked, nearLimit, rejected := limits.Stats()
+
+		if checked != 1 || (nearLimit == 1) != test.wantNear || (rejected == 1) != (len(test.wantLimit) > 0) {
+			t.Errorf("%s: got stats %d, %d, %d", test.desc, checked, nearLimit, rejected)
+		}
+	}
+}
+
+// crossSignedChain returns a leaf issued by a chain of levels names, with copies certificates
+// at each level all issued by the next, and a root issuing the last level.
+func crossSignedChain(levels, copies int) ([]*x509.Certificate, *x509.Certificate) {
+	name := func(level int) []byte {
+		return []byte(fmt.Sprintf("level %d", level))
+	}
+
+	chain := []*x509.Certificate{{Raw: []byte("leaf"), RawIssuer: name(0)}}
+
+	for level := 0; level < levels; level++ {
+		for c := 0; c < copies; c++ {
+			chain = append(chain, &x509.Certificate{Raw: []byte(fmt.Sprintf("%d/%d", level, c)), RawSubject: name(level), RawIssuer: name(level + 1)})
+		}
+	}
+
+	return chain, &x509.Certificate{Raw: []byte("root"), RawSubject: name(levels)}
+}
+
+func TestChainLimitsCountsCrossSignedPaths(t *testing.T) {
+	for _, test := range []struct {
+		levels, copies int
+		want           int
+	}{
+		{levels: 1, copies: 1, want: 2},
+		{levels: 3, copies: 1, want: 4},
+		// Each copy at a level can be followed by each at the next
+		{levels: 2, copies: 2, want: 10},
+		{levels: 3, copies: 2, want: 22},
+		// Counting stops once the limit is exceeded
+		{levels: 20, copies: 2, want: 1001},
+	} {
+		chain, root := crossSignedChain(test.levels, test.copies)
+		roots := NewPEMCertPool()
+		roots.AddCert(root)
+
+		limits := newChainLimitsOrDie(t, 0, 1000)
+
+		if got := limits.signatureChecks(chain, *roots); got != test.want {
+			t.Errorf("Got %d signature checks for %d levels of %d copies, expected %d", got, test.levels, test.copies, test.want)
+		}
+	}
+}
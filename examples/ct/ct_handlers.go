@@ -7,9 +7,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang/glog"
@@ -17,6 +20,9 @@ import (
 	"github.com/google/certificate-transparency/go/x509"
 	"github.com/google/trillian"
 	"github.com/google/trillian/crypto"
+	terrors "github.com/google/trillian/errors"
+	"github.com/google/trillian/examples/ct/ctapi"
+	"github.com/google/trillian/merkle"
 	"github.com/google/trillian/util"
 	"golang.org/x/net/context"
 )
@@ -34,8 +40,8 @@ const (
 	contentTypeHeader string = "Content-Type"
 	// MIME content type for JSON
 	contentTypeJSON string = "application/json"
-	// The name of the JSON response map key in get-roots responses
-	jsonMapKeyCertificates string = "certificates"
+	// HTTP header that holds the request ID, see appHandler.ServeHTTP
+	requestIDHeader string = "X-Request-ID"
 	// Logging level for debug verbose logs
 	logVerboseLevel glog.Level = 2
 	// Max number of entries we allow in a get-entries request
@@ -44,10 +50,16 @@ const (
 	getEntriesParamStart = "start"
 	// The name of the get-entries end parameter
 	getEntriesParamEnd = "end"
+	// The name of the get-entries parameter that asks for entries without their extra data.
+	// This is a non standard extension, see WithOmitExtraData
+	getEntriesParamOmitExtraData = "omit_extra_data"
 	// The name of the get-proof-by-hash parameter
 	getProofParamHash = "hash"
 	// The name of the get-proof-by-hash tree size parameter
 	getProofParamTreeSize = "tree_size"
+	// The name of the get-proof-by-hash parameter that asks for the proofs of all matching
+	// leaves. This is a non standard extension, see EnableAllProofs
+	getProofParamAll = "all"
 	// The name of the get-sth-consistency first snapshot param
 	getSTHConsistencyParamFirst = "first"
 	// The name of the get-sth-consistency second snapshot param
@@ -56,6 +68,9 @@ const (
 	getEntryAndProofParamLeafIndex = "leaf_index"
 	// The name of the get-entry-and-proof tree size paramter
 	getEntryAndProofParamTreeSize = "tree_size"
+	// The name of the get-sth-and-entries parameter giving the number of entries. This is a
+	// non standard extension, see wrappedGetSTHAndEntriesHandler
+	getSTHAndEntriesParamCount = "count"
 )
 
 // appHandler is a type for simplifying and centralizing error handling from http handlers
@@ -63,15 +78,20 @@ type appHandler func(http.ResponseWriter, *http.Request) (int, error)
 
 // ServeHTTP is an adapter from appHandler to the http framework
 func (fn appHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Tag the request so it can be traced through to the backend. The ID is also returned
+	// to the client so they can quote it when reporting a problem. Anything the client
+	// sent in the header is overwritten.
+	requestID := util.NewRequestID()
+	r.Header.Set(requestIDHeader, requestID)
+	w.Header().Set(requestIDHeader, requestID)
+
 	status, err := fn(w, r)
 
 	if err != nil {
-		glog.Warningf("handler error: %v", err)
+		glog.Warningf("handler error, request ID: %s: %v", requestID, err)
 		sendHttpError(w, status, err)
-	}
-
-	// Additional check, for consistency the handler must return an error for non 200 status
-	if status != http.StatusOK {
+	} else if status != http.StatusOK {
+		// Additional check, for consistency the handler must return an error for non 200 status
 		glog.Warningf("handler non 200 without error: %d %v", status, err)
 		sendHttpError(w, http.StatusInternalServerError, fmt.Errorf("http handler misbehaved, status: %d", status))
 	}
@@ -89,94 +109,175 @@ type CTRequestHandlers struct {
 	rpcClient trillian.TrillianLogClient
 	// logKeyManager holds the keys this log needs to sign objects
 	logKeyManager crypto.KeyManager
+	// signatureOptions controls the hash and padding used when signing SCTs and STHs
+	signatureOptions SignatureOptions
+	// hashAlgorithm is the hash function of the log's tree, SHA-256 unless WithHashAlgorithm
+	// is given
+	hashAlgorithm trillian.HashAlgorithm
 	// rpcDeadline is the deadline that will be set on all backend RPC requests
 	rpcDeadline time.Duration
 	// timeSource is a util.TimeSource that can be injected for testing
 	timeSource util.TimeSource
+	// sctTimeSource follows timeSource but never goes backwards or behind the latest STH that
+	// has been served, so SCTs can't be issued with a timestamp before an STH that might not
+	// include them. It's set up by NewCTRequestHandlers, if not set timeSource is used.
+	sctTimeSource *util.MonotonicTimeSource
+	// leafJournal is set if fast SCT mode is enabled, submissions are journalled locally and
+	// flushed to the backend asynchronously instead of waiting for the backend
+	leafJournal *LeafJournal
+	// proofCache is set if get-proof-by-hash responses should be cached
+	proofCache *ProofCache
+	// sthCache is set if get-entries should reject requests beyond the end of the tree
+	sthCache *STHCache
+	// cachedSTH is set if get-sth should serve the root in sthCache while it's fresh rather
+	// than fetching it from the backend every time
+	cachedSTH bool
+	// allProofs is set if get-proof-by-hash accepts the non standard all parameter
+	allProofs bool
+	// omitExtraData is set if get-entries accepts the non standard omit_extra_data parameter
+	omitExtraData bool
+	// sloTracker is set if the latency and errors of each endpoint should be tracked
+	sloTracker *SLOTracker
+	// chainCache is set if verified intermediates should be remembered by add-chain
+	chainCache *ChainCache
+	// chainLimits is set if the work of verifying submitted chains should be bounded
+	chainLimits *ChainLimits
+	// certMetrics is set if the characteristics of submitted certificates should be exported
+	certMetrics *CertMetrics
+	// requestMetrics is set if the requests to each endpoint should be counted
+	requestMetrics *RequestMetrics
+	// backendMetrics is set if the RPCs to the log's backend at backendAddress should be
+	// exported with the log's metrics
+	backendMetrics *BackendRPCMetrics
+	backendAddress string
+	// debugMux is set if the debug endpoints are served on it rather than with the others
+	debugMux *http.ServeMux
+	// submissionDedup is set if chains that are submitted again should get the SCT they were
+	// issued the first time rather than a new leaf being queued
+	submissionDedup *SubmissionDedup
+	// sthRefresher is set if get-sth should serve the STH signed in the background by
+	// RefreshSTH rather than fetching and signing one for every request
+	sthRefresher *STHRefresher
+	// readiness is set if the endpoints should fail until the log has served a verified STH
+	readiness *LogReadiness
+	// liveRoots is set if the roots can change while the server is running, by admin requests
+	// or reloads, it replaces trustedRoots
+	liveRoots *TrustedRoots
+	// rootsAdmin is set if roots can be added and removed at runtime by admin requests
+	rootsAdmin *TrustedRoots
+	// rootsAdminToken must be presented by roots admin requests
+	rootsAdminToken string
+	// denylist is set if submissions are checked against a denylist that can be managed with
+	// admin requests
+	denylist *Denylist
+	// denylistAdminToken must be presented by denylist admin requests
+	denylistAdminToken string
+	// sthGuard is set if roots fetched from the backend should be checked against the last STH
+	// served before they're served
+	sthGuard *STHGuard
+	// sthGuardAdminToken must be presented by requests to reset the guard, if it's empty they
+	// aren't served
+	sthGuardAdminToken string
+	// submitters is set if add-chain and add-pre-chain only accept authenticated submitters
+	submitters *Submitters
+	// submissionPolicies can reject verified chains before an SCT is issued
+	submissionPolicies []SubmissionPolicy
+	// precertLinks is set if precertificates and certificates are linked and resubmissions
+	// within its window get the SCT timestamp of the first submission
+	precertLinks *PrecertLinks
+	// extraDataCommitment is set if leaves commit to their chains, see ExtraDataCommitment
+	extraDataCommitment bool
+	// gossip is set if clients can send the STHs and SCTs they observed to be checked against
+	// the log's history
+	gossip *Gossip
+	// domainIndex is set if the entries for a domain can be looked up
+	domainIndex *DomainIndex
+	// apiVersions are served alongside RFC 6962, see WithAPIVersion
+	apiVersions []APIVersion
+	// features is set if fast SCTs and the proof and chain caches can be switched off per log
+	features *util.Features
+	// basePath is prepended to the paths of all the endpoints, before pathPrefix, if set. It
+	// starts with a '/' and doesn't end with one.
+	basePath string
+	// pathPrefix is prepended to the paths of all the endpoints if set
+	pathPrefix string
 }
 
-// NewCTRequestHandlers creates a new instance of CTRequestHandlers. They must still
-// be registered by calling RegisterCTHandlers()
-func NewCTRequestHandlers(logID int64, trustedRoots *PEMCertPool, rpcClient trillian.TrillianLogClient, km crypto.KeyManager, rpcDeadline time.Duration, timeSource util.TimeSource) *CTRequestHandlers {
-	return &CTRequestHandlers{logID, trustedRoots, rpcClient, km, rpcDeadline, timeSource}
-}
+// NewCTRequestHandlers creates a new instance of CTRequestHandlers for the log with the
+// given roots, backend and keys, configured by opts. They must still be registered by calling
+// RegisterHandlers().
+func NewCTRequestHandlers(logID int64, trustedRoots *PEMCertPool, rpcClient trillian.TrillianLogClient, km crypto.KeyManager, opts ...HandlerOption) *CTRequestHandlers {
+	c := &CTRequestHandlers{logID: logID, trustedRoots: trustedRoots, rpcClient: rpcClient, logKeyManager: km, rpcDeadline: defaultRPCDeadline, timeSource: util.SystemTimeSource{}}
 
-func pathFor(req string) string {
-	return ctV1BasePath + req
-}
+	for _, opt := range opts {
+		opt(c)
+	}
 
-// addChainRequest is a struct for parsing JSON add-chain requests. See RFC 6962 Sections 4.1 and 4.2
-type addChainRequest struct {
-	Chain []string
-}
+	c.sctTimeSource = util.NewMonotonicTimeSource(c.timeSource)
 
-// addChainResponse is a struct for marshalling add-chain responses. See RFC 6962 Sections 4.1 and 4.2
-type addChainResponse struct {
-	SctVersion int    `json:"sct_version"`
-	ID         string `json:"id"`
-	Timestamp  uint64 `json:"timestamp"`
-	Extensions string `json:"extensions"`
-	Signature  string `json:"signature"`
-}
+	// Cached chains were verified to the old roots and may not lead to the new ones
+	if c.liveRoots != nil && c.chainCache != nil {
+		c.liveRoots.onChange(c.chainCache.clear)
+	}
 
-// getEntriesEntry is a struct that represents one element in a get-entries response
-type getEntriesEntry struct {
-	LeafInput []byte `json:"leaf_input"`
-	ExtraData []byte `json:"extra_data"`
+	return c
 }
 
-// getEntriesResponse is a struct for marshalling get-entries respsonses. See RFC6962 Section 4.6
-type getEntriesResponse struct {
-	Entries []getEntriesEntry `json:"entries"`
+// currentRoots returns the roots the log accepts right now
+func (c CTRequestHandlers) currentRoots() *PEMCertPool {
+	if c.liveRoots != nil {
+		return c.liveRoots.Pool()
+	}
+
+	return c.trustedRoots
 }
 
-// getSTHResponse is a struct for marshalling get-sth responses. See RFC 6962 Section 4.3
-type getSTHResponse struct {
-	TreeSize        int64  `json:"tree_size"`
-	TimestampMillis int64  `json:"timestamp"`
-	RootHash        []byte `json:"sha256_root_hash"`
-	Signature       []byte `json:"tree_head_signature"`
+// sctTime returns the timestamp for a new SCT.
+func (c CTRequestHandlers) sctTime() time.Time {
+	if c.sctTimeSource != nil {
+		return c.sctTimeSource.Now()
+	}
+
+	return c.timeSource.Now()
 }
 
-// getProofByHashResponse is a struct for marshalling get-proof-by-hash responses. See RFC 6962
-// section 4.5
-type getProofByHashResponse struct {
-	LeafIndex int64    `json:"leaf_index"`
-	AuditPath [][]byte `json:"audit_path"`
+// featureEnabled returns whether a feature flagged component that has been configured should
+// be used for this log. Without a feature registry everything configured is used.
+func (c CTRequestHandlers) featureEnabled(name string) bool {
+	return c.features == nil || c.features.Enabled(name, c.logID)
 }
 
-// getSTHConsistencyResponse is a struct for mashalling get-sth-consistency responses. See
-// RFC 6962 section 4.4
-type getSTHConsistencyResponse struct {
-	Consistency [][]byte `json:"consistency"`
+// requestContext returns the context to use for backend RPCs made while handling r. It
+// passes the request ID and priority to the backend, which may reject low priority requests
+// when it is overloaded.
+func requestContext(r *http.Request, priority util.RequestPriority) context.Context {
+	return util.WithRequestPriority(util.WithRequestID(context.Background(), r.Header.Get(requestIDHeader)), priority)
 }
 
-// getEntryAndProofResponse is a struct for marshalling get-entry-and-proof responses. See
-// RFC 6962 Section 4.8
-type getEntryAndProofResponse struct {
-	LeafInput []byte   `json:"leaf_input"`
-	ExtraData []byte   `json:"extra_data"`
-	AuditPath [][]byte `json:"audit_path"`
+func pathFor(req string) string {
+	return ctV1BasePath + req
 }
 
-func parseBodyAsJSONChain(w http.ResponseWriter, r *http.Request) (addChainRequest, error) {
+// parseBodyAsJSONChain strictly decodes an add-chain or add-pre-chain request body, see
+// requestSchema.
+func parseBodyAsJSONChain(w http.ResponseWriter, r *http.Request, endpoint string) (ctapi.AddChainRequest, error) {
 	body, err := ioutil.ReadAll(r.Body)
 
 	if err != nil {
 		glog.V(logVerboseLevel).Infof("Failed to read request body: %v", err)
-		return addChainRequest{}, err
+		return ctapi.AddChainRequest{}, err
 	}
 
-	var req addChainRequest
-	if err := json.Unmarshal(body, &req); err != nil {
+	var req ctapi.AddChainRequest
+	if err := requestSchemas[endpoint].decode(body, &req); err != nil {
 		glog.V(logVerboseLevel).Infof("Failed to parse request body: %v", err)
-		return addChainRequest{}, err
+		return ctapi.AddChainRequest{}, err
 	}
 
 	// The cert chain is not allowed to be empty. We'll defer other validation for later
 	if len(req.Chain) == 0 {
 		glog.V(logVerboseLevel).Infof("Request chain is empty: %s", body)
-		return addChainRequest{}, errors.New("cert chain was empty")
+		return ctapi.AddChainRequest{}, errors.New("cert chain was empty")
 	}
 
 	return req, nil
@@ -211,18 +312,68 @@ func addChainInternal(w http.ResponseWriter, r *http.Request, c CTRequestHandler
 		return http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method)
 	}
 
-	addChainRequest, err := parseBodyAsJSONChain(w, r)
+	endpoint := "add-chain"
+	if isPrecert {
+		endpoint = "add-pre-chain"
+	}
+
+	addChainRequest, err := parseBodyAsJSONChain(w, r, endpoint)
 
 	if err != nil {
 		return http.StatusBadRequest, err
 	}
 
-	// We already checked that the chain is not empty so can move on to verification
-	validPath, err := verifyAddChain(addChainRequest, w, *c.trustedRoots, isPrecert)
+	sct, status, err := c.SubmitChain(r, addChainRequest.Chain, isPrecert)
+
+	if err != nil {
+		return status, err
+	}
+
+	// Success. We can now build and marshal the JSON response and write it out
+	err = marshalAndWriteAddChainResponse(sct, c.logKeyManager, w)
+
+	if err != nil {
+		// reason is logged and http status is already set
+		// TODO(Martin2112): Record failure for monitoring when it's implemented
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusOK, nil
+}
+
+// SubmitChain verifies a chain of base64 encoded DER certificates submitted to r, applies the
+// log's submission policies, issues an SCT for it and queues its leaf, in the same way for
+// every API version. If it fails the status the request should be answered with is returned
+// along with the error.
+func (c CTRequestHandlers) SubmitChain(r *http.Request, chain []string, isPrecert bool) (ct.SignedCertificateTimestamp, int, error) {
+	if len(chain) == 0 {
+		return ct.SignedCertificateTimestamp{}, http.StatusBadRequest, errEmptyChain
+	}
+
+	addChainRequest := ctapi.AddChainRequest{Chain: chain}
+
+	// The chain isn't empty so can move on to verification
+	chainCache := c.chainCache
+
+	if !c.featureEnabled(FeatureChainCache) {
+		chainCache = nil
+	}
+
+	validPath, err := verifyAddChain(addChainRequest, *c.currentRoots(), chainCache, c.chainLimits, isPrecert)
 
 	if err != nil {
 		// Chain rejected by verify.
-		return http.StatusBadRequest, err
+		return ct.SignedCertificateTimestamp{}, http.StatusBadRequest, err
+	}
+
+	for _, policy := range c.submissionPolicies {
+		if err := policy(validPath, isPrecert); err != nil {
+			return ct.SignedCertificateTimestamp{}, http.StatusBadRequest, fmt.Errorf("chain rejected by policy: %v", err)
+		}
+	}
+
+	if c.certMetrics != nil {
+		c.certMetrics.Record(validPath[0], isPrecert)
 	}
 
 	// Build up the SCT and MerkleTreeLeaf. The SCT will be returned to the client and
@@ -230,45 +381,117 @@ func addChainInternal(w http.ResponseWriter, r *http.Request, c CTRequestHandler
 	var merkleTreeLeaf ct.MerkleTreeLeaf
 	var sct ct.SignedCertificateTimestamp
 
+	sctTime, replayed, err := sctTimeForSubmission(requestContext(r, util.PrioritySCT), c, validPath, isPrecert)
+
+	if err != nil {
+		return ct.SignedCertificateTimestamp{}, http.StatusBadRequest, err
+	}
+
+	var extensions ct.CTExtensions
+
+	if c.extraDataCommitment {
+		var chain []ct.ASN1Cert
+
+		for _, cert := range validPath {
+			chain = append(chain, cert.Raw)
+		}
+
+		extensions = ExtraDataCommitment(chain)
+	}
+
 	if isPrecert {
-		merkleTreeLeaf, sct, err = signV1SCTForPrecertificate(c.logKeyManager, validPath[0], c.timeSource.Now())
+		merkleTreeLeaf, sct, err = signV1SCTForPrecertificate(c.logKeyManager, c.signatureOptions, validPath[0], sctTime, extensions)
 	} else {
-		merkleTreeLeaf, sct, err = signV1SCTForCertificate(c.logKeyManager, validPath[0], c.timeSource.Now())
+		merkleTreeLeaf, sct, err = signV1SCTForCertificate(c.logKeyManager, c.signatureOptions, validPath[0], sctTime, extensions)
 	}
 
 	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("failed to create / serialize SCT or Merkle leaf: %v %v", sct, err)
+		return ct.SignedCertificateTimestamp{}, http.StatusInternalServerError, fmt.Errorf("failed to create / serialize SCT or Merkle leaf: %v %v", sct, err)
 	}
 
 	// Inputs validated, pass the request on to the back end after hashing and serializing
 	// the data for the request
-	leafProto, err := buildLeafProtoForAddChain(merkleTreeLeaf, validPath)
+	leafProto, err := buildLeafProtoForAddChain(merkleTreeLeaf, validPath, c.hashAlgorithm)
 
 	if err != nil {
 		// Failure reason already logged
-		return http.StatusInternalServerError, err
+		return ct.SignedCertificateTimestamp{}, http.StatusInternalServerError, err
 	}
 
-	request := trillian.QueueLeavesRequest{LogId: c.logID, Leaves: []*trillian.LeafProto{&leafProto}}
+	// A replay has the same leaf as the first submission, which has already been queued
+	if !replayed {
+		if status, err := queueLeaf(requestContext(r, util.PrioritySCT), c, leafProto); status != http.StatusOK {
+			return ct.SignedCertificateTimestamp{}, status, err
+		}
 
-	ctx, _ := context.WithDeadline(context.Background(), getRPCDeadlineTime(c))
+		if c.precertLinks != nil {
+			c.precertLinks.record(validPath, isPrecert, sct.Timestamp, leafProto.LeafHash)
+		}
 
-	response, err := c.rpcClient.QueueLeaves(ctx, &request)
+		if c.submissionDedup != nil {
+			c.submissionDedup.record(newSubmissionID(validPath, isPrecert), sct.Timestamp, leafProto.LeafHash)
+		}
+	}
 
-	if err != nil || !rpcStatusOK(response.GetStatus()) {
-		// TODO(Martin2112): Possibly cases where the request we sent to the backend is invalid
-		// which isn't really an internal server error.
-		// Request failed on backend
-		return http.StatusInternalServerError, err
+	return sct, http.StatusOK, nil
+}
+
+// sctTimeForSubmission returns the timestamp for the SCT of a verified chain, which is the
+// timestamp of the SCT issued for an earlier submission of the same chain if submission dedup
+// remembers it, or of the same certificate or precertificate if it's a replay that precert
+// links remember. ctx is the parent of any backend RPC context.
+func sctTimeForSubmission(ctx context.Context, c CTRequestHandlers, chain []*x509.Certificate, isPrecert bool) (time.Time, bool, error) {
+	if c.submissionDedup != nil {
+		if timestamp, ok := c.previousSubmission(ctx, chain, isPrecert); ok {
+			return time.Unix(0, int64(timestamp)*millisPerNano), true, nil
+		}
 	}
 
-	// Success. We can now build and marshal the JSON response and write it out
-	err = marshalAndWriteAddChainResponse(sct, c.logKeyManager, w)
+	if c.precertLinks == nil {
+		return c.sctTime(), false, nil
+	}
+
+	timestamp, replayed, err := c.precertLinks.previousTimestamp(chain, isPrecert)
 
 	if err != nil {
-		// reason is logged and http status is already set
-		// TODO(Martin2112): Record failure for monitoring when it's implemented
-		return http.StatusInternalServerError, err
+		return time.Time{}, false, fmt.Errorf("chain rejected by precert links: %v", err)
+	}
+
+	if replayed {
+		return time.Unix(0, int64(timestamp)*millisPerNano), true, nil
+	}
+
+	return c.sctTime(), false, nil
+}
+
+// queueLeaf makes sure a leaf will be sequenced by the backend. In fast SCT mode it is only
+// journalled locally, unless the journal cannot take it, in which case it is sent directly
+// with ctx as the parent of the RPC context.
+func queueLeaf(ctx context.Context, c CTRequestHandlers, leafProto trillian.LeafProto) (int, error) {
+	if c.leafJournal != nil && c.featureEnabled(FeatureFastSCT) {
+		err := c.leafJournal.Append(leafProto)
+
+		if err == nil {
+			return http.StatusOK, nil
+		}
+
+		glog.Warningf("Failed to journal leaf, sending to backend: %v", err)
+	}
+
+	request := trillian.QueueLeavesRequest{LogId: c.logID, Leaves: []*trillian.LeafProto{&leafProto}}
+
+	ctx, _ = context.WithDeadline(ctx, getRPCDeadlineTime(c))
+
+	response, err := c.rpcClient.QueueLeaves(ctx, &request)
+
+	if err != nil {
+		return errorStatus(backendError("QueueLeaves", err, nil))
+	}
+
+	if !rpcStatusOK(response.GetStatus()) {
+		// TODO(Martin2112): Possibly cases where the request we sent to the backend is invalid
+		// which isn't really an internal server error.
+		return http.StatusInternalServerError, fmt.Errorf("backend rejected leaf: %v", response.GetStatus())
 	}
 
 	return http.StatusOK, nil
@@ -289,43 +512,147 @@ func wrappedAddPreChainHandler(c CTRequestHandlers) appHandler {
 	}
 }
 
-func wrappedGetSTHHandler(c CTRequestHandlers) appHandler {
-	return func(w http.ResponseWriter, r *http.Request) (int, error) {
-		if !enforceMethod(w, r, httpMethodGet) {
-			return http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method)
+// latestRoot returns the latest root, from the STH cache if get-sth is served from it and the
+// cached root is fresh and otherwise from the backend. Roots fetched from the backend are
+// added to the cache.
+func latestRoot(ctx context.Context, c CTRequestHandlers) (*trillian.SignedLogRoot, error) {
+	if c.cachedSTH && c.sthCache != nil {
+		if root, ok := c.sthCache.getRoot(); ok {
+			return root, nil
 		}
+	}
 
-		request := trillian.GetLatestSignedLogRootRequest{LogId: c.logID}
-		ctx, _ := context.WithDeadline(context.Background(), getRPCDeadlineTime(c))
-		response, err := c.rpcClient.GetLatestSignedLogRoot(ctx, &request)
+	request := trillian.GetLatestSignedLogRootRequest{LogId: c.logID}
+	response, err := c.rpcClient.GetLatestSignedLogRoot(ctx, &request)
 
-		if err != nil || !rpcStatusOK(response.GetStatus()) {
-			return http.StatusInternalServerError, errors.New("backend rpc failed")
-		}
+	if err != nil || !rpcStatusOK(response.GetStatus()) {
+		return nil, backendError("GetLatestSignedLogRoot", err, response.GetStatus())
+	}
 
-		if treeSize := response.GetSignedLogRoot().TreeSize; treeSize < 0 {
-			return http.StatusInternalServerError, fmt.Errorf("bad tree size from backend: %d", treeSize)
-		}
+	if response.GetSignedLogRoot() == nil {
+		return nil, terrors.New(terrors.Backend, "backend GetLatestSignedLogRoot returned no root")
+	}
+
+	if c.sthCache != nil {
+		c.sthCache.updateRoot(response.GetSignedLogRoot())
+	}
+
+	return response.GetSignedLogRoot(), nil
+}
+
+// getSignedTreeHead fetches the latest root, checks that it looks reasonable and returns it
+// as an STH signed with the log's key.
+func getSignedTreeHead(ctx context.Context, c CTRequestHandlers) (ct.SignedTreeHead, error) {
+	root, err := latestRoot(ctx, c)
+
+	if err != nil {
+		return ct.SignedTreeHead{}, err
+	}
+
+	return signTreeHead(c, root)
+}
+
+// signTreeHead checks that a root from the backend looks reasonable and returns it as an STH
+// signed with the log's key.
+func signTreeHead(c CTRequestHandlers, root *trillian.SignedLogRoot) (ct.SignedTreeHead, error) {
+	if treeSize := root.TreeSize; treeSize < 0 {
+		return ct.SignedTreeHead{}, terrors.Errorf(terrors.Integrity, "bad tree size from backend: %d", treeSize)
+	}
 
-		if hashSize := len(response.GetSignedLogRoot().RootHash); hashSize != sha256.Size {
-			return http.StatusInternalServerError, fmt.Errorf("bad hash size from backend expecting: %d got %d", sha256.Size, hashSize)
+	hasher, err := trillian.NewHasher(c.hashAlgorithm)
+
+	if err != nil {
+		return ct.SignedTreeHead{}, err
+	}
+
+	// The root must be the size the tree's hash function produces, and the STH only has room
+	// for a 32 byte root, which LogConfig checks the configured hash function produces
+	if hashSize := len(root.RootHash); hashSize != hasher.Size() || hashSize != sha256.Size {
+		return ct.SignedTreeHead{}, terrors.Errorf(terrors.Integrity, "bad hash size from backend expecting: %d got %d", hasher.Size(), hashSize)
+	}
+
+	// Jump through Go hoops because we're mixing arrays and slices, we checked the size above
+	// so it should exactly fit what we copy into it
+	var hashArray [sha256.Size]byte
+	copy(hashArray[:], root.RootHash)
+
+	// Build the CT STH object ready for signing
+	sth := ct.SignedTreeHead{TreeSize: uint64(root.TreeSize),
+		Timestamp:      uint64(root.TimestampNanos / 1000 / 1000),
+		SHA256RootHash: hashArray}
+
+	// Serialize and sign the STH and make sure this succeeds
+	err = signV1TreeHead(c.logKeyManager, c.signatureOptions, &sth)
+
+	if err != nil || len(sth.TreeHeadSignature.Signature) == 0 {
+		return ct.SignedTreeHead{}, fmt.Errorf("invalid tree size in get sth: %v", err)
+	}
+
+	if c.sthGuard != nil {
+		if err := c.sthGuard.check(root); err != nil {
+			return ct.SignedTreeHead{}, err
 		}
+	}
 
-		// Jump through Go hoops because we're mixing arrays and slices, we checked the size above
-		// so it should exactly fit what we copy into it
-		var hashArray [sha256.Size]byte
-		copy(hashArray[:], response.GetSignedLogRoot().RootHash)
+	// The backend's clock may be ahead of ours, later SCTs must not be timestamped before
+	// this STH
+	if c.sctTimeSource != nil {
+		c.sctTimeSource.NotBefore(time.Unix(0, root.TimestampNanos))
+	}
 
-		// Build the CT STH object ready for signing
-		sth := ct.SignedTreeHead{TreeSize: uint64(response.GetSignedLogRoot().TreeSize),
-			Timestamp:      uint64(response.GetSignedLogRoot().TimestampNanos / 1000 / 1000),
-			SHA256RootHash: hashArray}
+	return sth, nil
+}
 
-		// Serialize and sign the STH and make sure this succeeds
-		err = signV1TreeHead(c.logKeyManager, &sth)
+// servedSignedTreeHead returns the STH for get-sth, which is the one signed in the background
+// if there's an STH refresher and its STH is fresh. Otherwise it's fetched and signed now.
+func servedSignedTreeHead(ctx context.Context, c CTRequestHandlers) (ct.SignedTreeHead, error) {
+	if c.sthRefresher == nil {
+		return getSignedTreeHead(ctx, c)
+	}
+
+	if sth, ok := c.sthRefresher.get(); ok {
+		return sth, nil
+	}
+
+	sth, err := getSignedTreeHead(ctx, c)
+
+	if err != nil {
+		return ct.SignedTreeHead{}, err
+	}
+
+	c.sthRefresher.update(sth)
+
+	return sth, nil
+}
+
+// SignedTreeHead returns the STH served by get-sth, so that every API version serves the same
+// tree heads.
+func (c CTRequestHandlers) SignedTreeHead(ctx context.Context) (ct.SignedTreeHead, error) {
+	sth, err := servedSignedTreeHead(ctx, c)
+
+	if err != nil {
+		return ct.SignedTreeHead{}, err
+	}
 
-		if err != nil || len(sth.TreeHeadSignature.Signature) == 0 {
-			return http.StatusInternalServerError, fmt.Errorf("invalid tree size in get sth: %v", err)
+	// Proofs against older tree sizes are unlikely to be requested again
+	if c.proofCache != nil {
+		c.proofCache.advanceTreeSize(int64(sth.TreeSize))
+	}
+
+	return sth, nil
+}
+
+func wrappedGetSTHHandler(c CTRequestHandlers) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		if !enforceMethod(w, r, httpMethodGet) {
+			return http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method)
+		}
+
+		ctx, _ := context.WithDeadline(requestContext(r, util.PriorityProof), getRPCDeadlineTime(c))
+		sth, err := c.SignedTreeHead(ctx)
+
+		if err != nil {
+			return errorStatus(err)
 		}
 
 		// Now build the final result object that will be marshalled to JSON
@@ -362,20 +689,20 @@ func wrappedGetSTHConsistencyHandler(c CTRequestHandlers) appHandler {
 		}
 
 		request := trillian.GetConsistencyProofRequest{LogId: c.logID, FirstTreeSize: first, SecondTreeSize: second}
-		ctx, _ := context.WithDeadline(context.Background(), getRPCDeadlineTime(c))
+		ctx, _ := context.WithDeadline(requestContext(r, util.PriorityProof), getRPCDeadlineTime(c))
 		response, err := c.rpcClient.GetConsistencyProof(ctx, &request)
 
 		if err != nil || !rpcStatusOK(response.GetStatus()) {
-			return http.StatusInternalServerError, err
+			return errorStatus(backendError("GetConsistencyProof", err, response.GetStatus()))
 		}
 
 		// Additional sanity checks, none of the hashes in the returned path should be empty
 		if !checkAuditPath(response.Proof.ProofNode) {
-			return http.StatusInternalServerError, fmt.Errorf("backend returned invalid proof: %v", response.Proof)
+			return errorStatus(terrors.Errorf(terrors.Integrity, "backend returned invalid proof: %v", response.Proof))
 		}
 
 		// We got a valid response from the server. Marshall it as JSON and return it to the client
-		jsonResponse := getSTHConsistencyResponse{Consistency: auditPathFromProto(response.Proof.ProofNode)}
+		jsonResponse := ctapi.GetSTHConsistencyResponse{Consistency: auditPathFromProto(response.Proof.ProofNode)}
 
 		w.Header().Set(contentTypeHeader, contentTypeJSON)
 		jsonData, err := json.Marshal(&jsonResponse)
@@ -420,45 +747,118 @@ func wrappedGetProofByHashHandler(c CTRequestHandlers) appHandler {
 			return http.StatusBadRequest, fmt.Errorf("get-proof-by-hash: missing or invalid tree_size: %v", r.FormValue(getProofParamTreeSize))
 		}
 
-		// Per RFC 6962 section 4.5 the API returns a single proof. This should be the lowest leaf index
-		// Because we request order by sequence and we only passed one hash then the first result is
-		// the correct proof to return
-		rpcRequest := trillian.GetInclusionProofByHashRequest{LogId: c.logID,
-			LeafHash:        leafHash,
-			TreeSize:        treeSize,
-			OrderBySequence: true}
-		ctx, _ := context.WithDeadline(context.Background(), getRPCDeadlineTime(c))
-		response, err := c.rpcClient.GetInclusionProofByHash(ctx, &rpcRequest)
+		all := false
 
-		if err != nil || !rpcStatusOK(response.GetStatus()) {
-			return http.StatusInternalServerError, fmt.Errorf("get-proof-by-hash: RPC failed, possible extra info: %v", err)
+		if allParam := r.FormValue(getProofParamAll); len(allParam) > 0 {
+			if !c.allProofs {
+				return http.StatusBadRequest, errors.New("get-proof-by-hash: all parameter is not supported by this log")
+			}
+
+			if all, err = strconv.ParseBool(allParam); err != nil {
+				return http.StatusBadRequest, fmt.Errorf("get-proof-by-hash: invalid all parameter: %s", allParam)
+			}
 		}
 
-		// Additional sanity checks, none of the hashes in the returned path should be empty
-		if !checkAuditPath(response.Proof[0].ProofNode) {
-			return http.StatusInternalServerError, fmt.Errorf("get-proof-by-hash: backend returned invalid proof: %v", response.Proof[0])
+		if all {
+			proofs, status, err := getProofsByHash(r, c, leafHash, treeSize)
+
+			if err != nil {
+				return status, err
+			}
+
+			return writeProofByHashResponse(w, ctapi.GetAllProofsByHashResponse{Proofs: proofs})
 		}
 
-		// All checks complete, marshall and return the response
-		proofResponse := getProofByHashResponse{LeafIndex: response.Proof[0].LeafIndex, AuditPath: auditPathFromProto(response.Proof[0].ProofNode)}
+		var proofResponse ctapi.GetProofByHashResponse
+		cached := false
+		useCache := c.proofCache != nil && c.featureEnabled(FeatureProofCache)
 
-		w.Header().Set(contentTypeHeader, contentTypeJSON)
-		jsonData, err := json.Marshal(&proofResponse)
+		if useCache {
+			proofResponse, cached = c.proofCache.get(leafHash, treeSize)
+		}
 
-		if err != nil {
-			glog.Warningf("Failed to marshal get-proof-by-hash resp: %v", proofResponse)
-			return http.StatusInternalServerError, fmt.Errorf("failed to marshal get-proof-by-hash resp: %v, error: %v", proofResponse, err)
+		if !cached {
+			proofs, status, err := getProofsByHash(r, c, leafHash, treeSize)
+
+			if err != nil {
+				return status, err
+			}
+
+			// Per RFC 6962 section 4.5 the API returns a single proof. If the hash appears more than
+			// once in the tree this is always the one with the lowest leaf index.
+			proofResponse = proofs[0]
+
+			if useCache {
+				c.proofCache.put(leafHash, treeSize, proofResponse)
+			}
 		}
 
-		_, err = w.Write(jsonData)
+		return writeProofByHashResponse(w, proofResponse)
+	}
+}
 
-		if err != nil {
-			// Probably too late for this as headers might have been written but we don't know for sure
-			return http.StatusInternalServerError, fmt.Errorf("failed to write get-proof-by-hash resp: %v", proofResponse)
+// getProofsByHash gets the proofs for all the leaves with a hash from the backend, ordered by
+// leaf index. It's an error if there are none.
+func getProofsByHash(r *http.Request, c CTRequestHandlers, leafHash []byte, treeSize int64) ([]ctapi.GetProofByHashResponse, int, error) {
+	rpcRequest := trillian.GetInclusionProofByHashRequest{LogId: c.logID,
+		LeafHash:        leafHash,
+		TreeSize:        treeSize,
+		OrderBySequence: true}
+	ctx, _ := context.WithDeadline(requestContext(r, util.PriorityProof), getRPCDeadlineTime(c))
+	response, err := c.rpcClient.GetInclusionProofByHash(ctx, &rpcRequest)
+
+	if err != nil || !rpcStatusOK(response.GetStatus()) {
+		err = backendError("GetInclusionProofByHash", err, response.GetStatus())
+		return nil, terrors.HTTPStatus(err), err
+	}
+
+	if len(response.Proof) == 0 {
+		err = terrors.Errorf(terrors.NotFound, "get-proof-by-hash: no leaf with hash %x in tree of size %d", leafHash, treeSize)
+		return nil, terrors.HTTPStatus(err), err
+	}
+
+	proofs := make([]ctapi.GetProofByHashResponse, 0, len(response.Proof))
+
+	for _, proof := range response.Proof {
+		// Additional sanity checks, none of the hashes in the returned path should be empty
+		if !checkAuditPath(proof.ProofNode) {
+			err = terrors.Errorf(terrors.Integrity, "get-proof-by-hash: backend returned invalid proof: %v", proof)
+			return nil, terrors.HTTPStatus(err), err
 		}
 
-		return http.StatusOK, nil
+		proofs = append(proofs, ctapi.GetProofByHashResponse{LeafIndex: proof.LeafIndex, AuditPath: auditPathFromProto(proof.ProofNode)})
+	}
+
+	// Don't rely on the backend honouring the requested order
+	sort.Stable(byProofLeafIndex(proofs))
+
+	return proofs, http.StatusOK, nil
+}
+
+type byProofLeafIndex []ctapi.GetProofByHashResponse
+
+func (p byProofLeafIndex) Len() int           { return len(p) }
+func (p byProofLeafIndex) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p byProofLeafIndex) Less(i, j int) bool { return p[i].LeafIndex < p[j].LeafIndex }
+
+// writeProofByHashResponse writes either form of get-proof-by-hash response as JSON.
+func writeProofByHashResponse(w http.ResponseWriter, proofResponse interface{}) (int, error) {
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	jsonData, err := json.Marshal(proofResponse)
+
+	if err != nil {
+		glog.Warningf("Failed to marshal get-proof-by-hash resp: %v", proofResponse)
+		return http.StatusInternalServerError, fmt.Errorf("failed to marshal get-proof-by-hash resp: %v, error: %v", proofResponse, err)
 	}
+
+	_, err = w.Write(jsonData)
+
+	if err != nil {
+		// Probably too late for this as headers might have been written but we don't know for sure
+		return http.StatusInternalServerError, fmt.Errorf("failed to write get-proof-by-hash resp: %v", proofResponse)
+	}
+
+	return http.StatusOK, nil
 }
 
 func wrappedGetEntriesHandler(c CTRequestHandlers) appHandler {
@@ -468,24 +868,55 @@ func wrappedGetEntriesHandler(c CTRequestHandlers) appHandler {
 		}
 
 		// The first job is to parse the params and make sure they're sensible. We just make
-		// sure the range is valid. We don't do an extra roundtrip to get the current tree
-		// size and prefer to let the backend handle this case
+		// sure the range is valid. Unless the STH cache is enabled we don't check the start
+		// against the current tree size and prefer to let the backend handle this case
 		startIndex, endIndex, err := parseAndValidateGetEntriesRange(r, maxGetEntriesAllowed)
 
 		if err != nil {
 			return http.StatusBadRequest, fmt.Errorf("bad range on get-entries request: %v", err)
 		}
 
+		omitExtraData := false
+
+		if omitParam := r.FormValue(getEntriesParamOmitExtraData); len(omitParam) > 0 {
+			if !c.omitExtraData {
+				return http.StatusBadRequest, errors.New("get-entries: omit_extra_data parameter is not supported by this log")
+			}
+
+			if omitExtraData, err = strconv.ParseBool(omitParam); err != nil {
+				return http.StatusBadRequest, fmt.Errorf("get-entries: invalid omit_extra_data parameter: %s", omitParam)
+			}
+		}
+
+		if c.sthCache != nil {
+			treeSize, ok := c.sthCache.get()
+
+			// The cached size may be behind the backend's, so it's only trusted to let a
+			// request through. A request is never rejected without confirming the size.
+			if !ok || startIndex >= treeSize {
+				if treeSize, err = latestTreeSize(r, c); err != nil {
+					return errorStatus(err)
+				}
+			}
+
+			// The end of the range is allowed to be past the end of the tree, the backend
+			// truncates the response, but there must be at least one entry to return
+			if startIndex >= treeSize {
+				return http.StatusBadRequest, ctapi.Error{ErrorCode: ctapi.ErrBadRequest, TreeSize: &treeSize,
+					Message: fmt.Sprintf("get-entries start %d is beyond the tree size %d", startIndex, treeSize)}
+			}
+		}
+
 		// Now make a request to the backend to get the relevant leaves
 		requestIndices := buildIndicesForRange(startIndex, endIndex)
-		request := trillian.GetLeavesByIndexRequest{LogId: c.logID, LeafIndex: requestIndices}
+		request := trillian.GetLeavesByIndexRequest{LogId: c.logID, LeafIndex: requestIndices, OmitExtraData: omitExtraData}
 
-		ctx, _ := context.WithDeadline(context.Background(), getRPCDeadlineTime(c))
+		ctx, _ := context.WithDeadline(requestContext(r, util.PriorityBulk), getRPCDeadlineTime(c))
 
 		response, err := c.rpcClient.GetLeavesByIndex(ctx, &request)
 
 		if err != nil || !rpcStatusOK(response.GetStatus()) {
-			return http.StatusInternalServerError, fmt.Errorf("get-entries: RPC failed, possible extra info: %v", err)
+			return errorStatus(backendError("GetLeavesByIndex", err, response.GetStatus()))
 		}
 
 		// Apply additional checks on the response to make sure we got a contiguous leaf range.
@@ -493,24 +924,30 @@ func wrappedGetEntriesHandler(c CTRequestHandlers) appHandler {
 		// range exceeds the tree size etc. so we could get fewer leaves than we requested but
 		// never more and never anything outside the requested range.
 		if expected, got := len(requestIndices), len(response.Leaves); got > expected {
-			return http.StatusInternalServerError, fmt.Errorf("backend returned too many leaves: %d v %d", got, expected)
+			return errorStatus(terrors.Errorf(terrors.Integrity, "backend returned too many leaves: %d v %d", got, expected))
 		}
 
 		if err := isResponseContiguousRange(response, startIndex, endIndex); err != nil {
-			return http.StatusInternalServerError, fmt.Errorf("backend get-entries range received from backend non contiguous: %v", err)
+			return errorStatus(terrors.Errorf(terrors.Integrity, "backend get-entries range received from backend non contiguous: %v", err))
 		}
 
 		// Now we've checked the response and it seems to be valid we need to serialize the
 		// leaves in JSON format. Doing a round trip via the leaf deserializer gives us another
 		// chance to prevent bad / corrupt data from reaching the client.
-		jsonResponse, err := marshalGetEntriesResponse(response)
+		entries, err := marshalGetEntriesResponse(response)
 
 		if err != nil {
 			return http.StatusInternalServerError, fmt.Errorf("failed to process leaves returned from backend: %v", err)
 		}
 
+		var jsonResponse interface{} = entries
+
+		if omitExtraData {
+			jsonResponse = removeExtraData(entries)
+		}
+
 		w.Header().Set(contentTypeHeader, contentTypeJSON)
-		jsonData, err := json.Marshal(&jsonResponse)
+		jsonData, err := json.Marshal(jsonResponse)
 
 		if err != nil {
 			return http.StatusInternalServerError, fmt.Errorf("failed to marshal get-entries resp: %v because: %v", jsonResponse, err)
@@ -528,24 +965,43 @@ func wrappedGetEntriesHandler(c CTRequestHandlers) appHandler {
 	}
 }
 
-func wrappedGetRootsHandler(trustedRoots *PEMCertPool) appHandler {
+// latestTreeSize fetches the latest tree head from the backend, records it in the STH cache and
+// returns its size.
+func latestTreeSize(r *http.Request, c CTRequestHandlers) (int64, error) {
+	request := trillian.GetLatestSignedLogRootRequest{LogId: c.logID}
+	ctx, _ := context.WithDeadline(requestContext(r, util.PriorityProof), getRPCDeadlineTime(c))
+	response, err := c.rpcClient.GetLatestSignedLogRoot(ctx, &request)
+
+	if err != nil || !rpcStatusOK(response.GetStatus()) {
+		return 0, backendError("GetLatestSignedLogRoot", err, response.GetStatus())
+	}
+
+	if response.GetSignedLogRoot() == nil {
+		return 0, terrors.New(terrors.Backend, "backend GetLatestSignedLogRoot returned no root")
+	}
+
+	c.sthCache.updateRoot(response.GetSignedLogRoot())
+
+	return response.GetSignedLogRoot().TreeSize, nil
+}
+
+func wrappedGetRootsHandler(c CTRequestHandlers) appHandler {
 	return func(w http.ResponseWriter, r *http.Request) (int, error) {
 		if !enforceMethod(w, r, httpMethodGet) {
 			return http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method)
 		}
 
-		jsonMap := make(map[string]interface{})
+		trustedRoots := c.currentRoots()
 
-		rawCerts := make([][]byte, 0, len(trustedRoots.RawCertificates()))
+		jsonResponse := ctapi.GetRootsResponse{Certificates: make([][]byte, 0, len(trustedRoots.RawCertificates()))}
 
 		// Pull out the raw certificates from the parsed versions
 		for _, cert := range trustedRoots.RawCertificates() {
-			rawCerts = append(rawCerts, cert.Raw)
+			jsonResponse.Certificates = append(jsonResponse.Certificates, cert.Raw)
 		}
 
-		jsonMap[jsonMapKeyCertificates] = rawCerts
 		enc := json.NewEncoder(w)
-		err := enc.Encode(jsonMap)
+		err := enc.Encode(jsonResponse)
 
 		if err != nil {
 			glog.Warningf("get_roots failed: %v", err)
@@ -572,23 +1028,34 @@ func wrappedGetEntryAndProofHandler(c CTRequestHandlers) appHandler {
 		}
 
 		getEntryAndProofRequest := trillian.GetEntryAndProofRequest{LogId: c.logID, LeafIndex: leafIndex, TreeSize: treeSize}
-		ctx, _ := context.WithDeadline(context.Background(), getRPCDeadlineTime(c))
+		ctx, _ := context.WithDeadline(requestContext(r, util.PriorityProof), getRPCDeadlineTime(c))
 		response, err := c.rpcClient.GetEntryAndProof(ctx, &getEntryAndProofRequest)
+		leaf, proof := response.GetLeaf(), response.GetProof()
 
-		if err != nil || !rpcStatusOK(response.GetStatus()) {
-			return http.StatusInternalServerError, fmt.Errorf("get-entry-and-proof: RPC failed, possible extra info: %v", err)
+		if err == nil && !rpcStatusOK(response.GetStatus()) {
+			err = fmt.Errorf("backend returned status: %v", response.GetStatus())
+		} else if err == nil && !isEntryAndProofComplete(leaf, proof) {
+			// Apply some checks that we got reasonable data from the backend
+			err = fmt.Errorf("got RPC bad response, possible extra info: %v", response)
 		}
 
-		// Apply some checks that we got reasonable data from the backend
-		if response.Proof == nil || response.Leaf == nil || len(response.Proof.ProofNode) == 0 || len(response.Leaf.LeafData) == 0 {
-			return http.StatusInternalServerError, fmt.Errorf("got RPC bad response, possible extra info: %v", response)
+		if err != nil {
+			// The backend might not support the combined RPC yet, or be partly upgraded, so
+			// try to build the response from the RPCs that get-entries and get-proof-by-hash use
+			glog.Warningf("get-entry-and-proof: combined RPC failed, falling back to separate RPCs: %v", err)
+			var fallbackErr error
+			leaf, proof, fallbackErr = getEntryAndProofFromSeparateRPCs(r, c, leafIndex, treeSize)
+
+			if fallbackErr != nil {
+				return http.StatusInternalServerError, fmt.Errorf("get-entry-and-proof: RPC failed: %v, fallback to separate RPCs also failed: %v", err, fallbackErr)
+			}
 		}
 
 		// Build and marshall the response to the client
-		jsonResponse := getEntryAndProofResponse{
-			LeafInput: response.Leaf.LeafData,
-			ExtraData: response.Leaf.ExtraData,
-			AuditPath: auditPathFromProto(response.Proof.ProofNode)}
+		jsonResponse := ctapi.GetEntryAndProofResponse{
+			LeafInput: leaf.LeafData,
+			ExtraData: leaf.ExtraData,
+			AuditPath: auditPathFromProto(proof.ProofNode)}
 
 		w.Header().Set(contentTypeHeader, contentTypeJSON)
 		jsonData, err := json.Marshal(&jsonResponse)
@@ -609,23 +1076,350 @@ func wrappedGetEntryAndProofHandler(c CTRequestHandlers) appHandler {
 	}
 }
 
-// RegisterCTHandlers registers a HandleFunc for all of the RFC6962 defined methods.
-// TODO(Martin2112): This registers on default ServeMux, might need more flexibility?
-func (c CTRequestHandlers) RegisterCTHandlers() {
-	http.Handle(pathFor("add-chain"), wrappedAddChainHandler(c))
-	http.Handle(pathFor("add-pre-chain"), wrappedAddPreChainHandler(c))
-	http.Handle(pathFor("get-sth"), wrappedGetSTHHandler(c))
-	http.Handle(pathFor("get-sth-consistency"), wrappedGetSTHConsistencyHandler(c))
-	http.Handle(pathFor("get-proof-by-hash"), wrappedGetProofByHashHandler(c))
-	http.Handle(pathFor("get-entries"), wrappedGetEntriesHandler(c))
-	http.Handle(pathFor("get-roots"), wrappedGetRootsHandler(c.trustedRoots))
-	http.Handle(pathFor("get-entry-and-proof"), wrappedGetEntryAndProofHandler(c))
+// getEntryAndProofFromSeparateRPCs fetches the leaf at leafIndex and its inclusion proof
+// for treeSize with a request each. They have their own deadline as the combined RPC may have
+// used up the first one.
+func getEntryAndProofFromSeparateRPCs(r *http.Request, c CTRequestHandlers, leafIndex, treeSize int64) (*trillian.LeafProto, *trillian.ProofProto, error) {
+	ctx, cancel := context.WithDeadline(requestContext(r, util.PriorityProof), getRPCDeadlineTime(c))
+	defer cancel()
+
+	leavesResponse, err := c.rpcClient.GetLeavesByIndex(ctx, &trillian.GetLeavesByIndexRequest{LogId: c.logID, LeafIndex: []int64{leafIndex}})
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("GetLeavesByIndex failed: %v", err)
+	}
+
+	if !rpcStatusOK(leavesResponse.GetStatus()) {
+		return nil, nil, fmt.Errorf("GetLeavesByIndex returned status: %v", leavesResponse.GetStatus())
+	}
+
+	if got := len(leavesResponse.Leaves); got != 1 {
+		return nil, nil, fmt.Errorf("GetLeavesByIndex returned %d leaves, expected 1", got)
+	}
+
+	leaf := leavesResponse.Leaves[0]
+
+	if leaf.LeafIndex != leafIndex {
+		return nil, nil, fmt.Errorf("GetLeavesByIndex returned leaf %d, expected %d", leaf.LeafIndex, leafIndex)
+	}
+
+	proofResponse, err := c.rpcClient.GetInclusionProof(ctx, &trillian.GetInclusionProofRequest{LogId: c.logID, LeafIndex: leafIndex, TreeSize: treeSize})
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("GetInclusionProof failed: %v", err)
+	}
+
+	if !rpcStatusOK(proofResponse.GetStatus()) {
+		return nil, nil, fmt.Errorf("GetInclusionProof returned status: %v", proofResponse.GetStatus())
+	}
+
+	if !isEntryAndProofComplete(leaf, proofResponse.Proof) {
+		return nil, nil, fmt.Errorf("got incomplete leaf or proof: %v %v", leaf, proofResponse.Proof)
+	}
+
+	return leaf, proofResponse.Proof, nil
+}
+
+// isEntryAndProofComplete checks that the backend returned leaf data and a non empty proof
+func isEntryAndProofComplete(leaf *trillian.LeafProto, proof *trillian.ProofProto) bool {
+	return proof != nil && leaf != nil && len(proof.ProofNode) > 0 && len(leaf.LeafData) > 0
+}
+
+// wrappedGetSTHAndEntriesHandler serves the latest STH along with the last entries of the tree
+// it signs, for monitors that poll for new entries. The backend reads both from one snapshot
+// so, unlike get-sth followed by get-entries, the entries always end at the STH's tree size
+// however fast the log is growing. This is not part of RFC 6962.
+func wrappedGetSTHAndEntriesHandler(c CTRequestHandlers) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		if !enforceMethod(w, r, httpMethodGet) {
+			return http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method)
+		}
+
+		count, err := parseAndValidateGetSTHAndEntriesCount(r, maxGetEntriesAllowed)
+
+		if err != nil {
+			return http.StatusBadRequest, fmt.Errorf("bad count on get-sth-and-entries request: %v", err)
+		}
+
+		request := trillian.GetLatestSignedLogRootAndLeavesRequest{LogId: c.logID, MaxLeaves: count}
+		ctx, _ := context.WithDeadline(requestContext(r, util.PriorityBulk), getRPCDeadlineTime(c))
+		response, err := c.rpcClient.GetLatestSignedLogRootAndLeaves(ctx, &request)
+
+		if err != nil || !rpcStatusOK(response.GetStatus()) {
+			return errorStatus(backendError("GetLatestSignedLogRootAndLeaves", err, response.GetStatus()))
+		}
+
+		root := response.GetSignedLogRoot()
+
+		if root == nil {
+			return errorStatus(terrors.New(terrors.Backend, "backend GetLatestSignedLogRootAndLeaves returned no root"))
+		}
+
+		if err := checkLatestLeaves(response.Leaves, root.TreeSize, count); err != nil {
+			return errorStatus(terrors.Errorf(terrors.Integrity, "backend returned the wrong leaves for tree size %d: %v", root.TreeSize, err))
+		}
+
+		sth, err := signTreeHead(c, root)
+
+		if err != nil {
+			return errorStatus(err)
+		}
+
+		// The root is as fresh as one fetched for get-sth so the caches can move on to it too
+		if c.sthCache != nil {
+			c.sthCache.updateRoot(root)
+		}
+
+		if c.proofCache != nil {
+			c.proofCache.advanceTreeSize(root.TreeSize)
+		}
+
+		entries, err := marshalGetEntriesResponse(&trillian.GetLeavesByIndexResponse{Leaves: response.Leaves})
+
+		if err != nil {
+			return http.StatusInternalServerError, fmt.Errorf("failed to process leaves returned from backend: %v", err)
+		}
+
+		jsonResponse := ctapi.GetSTHAndEntriesResponse{STH: convertSTHForClientResponse(sth), Entries: entries.Entries}
+
+		w.Header().Set(contentTypeHeader, contentTypeJSON)
+		jsonData, err := json.Marshal(&jsonResponse)
+
+		if err != nil {
+			return http.StatusInternalServerError, fmt.Errorf("failed to marshal get-sth-and-entries resp: %v because: %v", jsonResponse, err)
+		}
+
+		_, err = w.Write(jsonData)
+
+		if err != nil {
+			// Probably too late for this as headers might have been written but we don't know for sure
+			return http.StatusInternalServerError, fmt.Errorf("failed to write get-sth-and-entries resp: %v because: %v", jsonResponse, err)
+		}
+
+		return http.StatusOK, nil
+	}
+}
+
+// wrappedGetLogMetadataHandler serves the human readable metadata that the log's operators
+// have set for it. This is not part of RFC 6962.
+func wrappedGetLogMetadataHandler(c CTRequestHandlers) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		if !enforceMethod(w, r, httpMethodGet) {
+			return http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method)
+		}
+
+		ctx, _ := context.WithDeadline(requestContext(r, util.PriorityBulk), getRPCDeadlineTime(c))
+		response, err := c.rpcClient.GetTreeMetadata(ctx, &trillian.GetTreeMetadataRequest{LogId: c.logID})
+
+		if err != nil || !rpcStatusOK(response.GetStatus()) {
+			return http.StatusInternalServerError, fmt.Errorf("backend GetTreeMetadata request failed: %v %v", response.GetStatus(), err)
+		}
+
+		metadata := response.GetMetadata()
+
+		if metadata == nil {
+			return http.StatusInternalServerError, errors.New("backend returned no tree metadata")
+		}
+
+		jsonResponse := ctapi.GetLogMetadataResponse{
+			DisplayName:      metadata.DisplayName,
+			Description:      metadata.Description,
+			OwnerContact:     metadata.OwnerContact,
+			CreateTimeMillis: metadata.CreateTimeNanos / int64(time.Millisecond),
+		}
+
+		w.Header().Set(contentTypeHeader, contentTypeJSON)
+		jsonData, err := json.Marshal(&jsonResponse)
+
+		if err != nil {
+			return http.StatusInternalServerError, fmt.Errorf("failed to marshall response: %v %v", jsonResponse, err)
+		}
+
+		if _, err := w.Write(jsonData); err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		return http.StatusOK, nil
+	}
 }
 
-// Generates a custom error page to give more information on why something didn't work
+// wrappedGetOpenAPIHandler serves the OpenAPI description of the endpoints under basePath. It
+// is generated once as it can't change while the server is running.
+func wrappedGetOpenAPIHandler(basePath string) appHandler {
+	spec, specErr := ctapi.OpenAPISpec(basePath)
+
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		if !enforceMethod(w, r, httpMethodGet) {
+			return http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method)
+		}
+
+		if specErr != nil {
+			return http.StatusInternalServerError, fmt.Errorf("failed to generate OpenAPI spec: %v", specErr)
+		}
+
+		w.Header().Set(contentTypeHeader, contentTypeJSON)
+
+		if _, err := w.Write(spec); err != nil {
+			return http.StatusInternalServerError, fmt.Errorf("failed to write OpenAPI spec: %v", err)
+		}
+
+		return http.StatusOK, nil
+	}
+}
+
+// RegisterHandlers registers handlers on mux for all of the RFC6962 defined methods and the
+// log's debug and admin endpoints.
+func (c CTRequestHandlers) RegisterHandlers(mux *http.ServeMux) {
+	c.handle(mux, "add-chain", c.authenticated(wrappedAddChainHandler(c)))
+	c.handle(mux, "add-pre-chain", c.authenticated(wrappedAddPreChainHandler(c)))
+	c.handle(mux, "get-sth", wrappedGetSTHHandler(c))
+	c.handle(mux, "get-sth-consistency", wrappedGetSTHConsistencyHandler(c))
+	c.handle(mux, "get-proof-by-hash", wrappedGetProofByHashHandler(c))
+	c.handle(mux, "get-entries", wrappedGetEntriesHandler(c))
+	c.handle(mux, "get-roots", wrappedGetRootsHandler(c))
+	// The root bundles aren't JSON so they aren't in ctapi.Endpoints
+	c.handle(mux, "get-roots.pem", wrappedGetRootsPEMHandler(c))
+	c.handle(mux, "get-roots.p7c", wrappedGetRootsPKCS7Handler(c))
+	c.handle(mux, "get-entry-and-proof", wrappedGetEntryAndProofHandler(c))
+	c.handle(mux, "get-log-metadata", wrappedGetLogMetadataHandler(c))
+	c.handle(mux, "get-sth-and-entries", wrappedGetSTHAndEntriesHandler(c))
+	c.handle(mux, "openapi.json", wrappedGetOpenAPIHandler(c.prefixed(strings.TrimSuffix(ctV1BasePath, "/"))))
+
+	debugMux := mux
+
+	if c.debugMux != nil {
+		debugMux = c.debugMux
+	}
+
+	if c.sloTracker != nil {
+		debugMux.Handle(c.prefixed("/debug/slo"), wrappedGetSLOReportHandler(c.sloTracker))
+	}
+
+	if writers := c.metricsWriters(); len(writers) > 0 {
+		debugMux.Handle(c.prefixed("/metrics"), wrappedGetMetricsHandler(writers...))
+	}
+
+	if c.readiness != nil {
+		mux.Handle(c.prefixed("/ready"), wrappedGetReadyHandler(c.readiness))
+	}
+
+	if c.rootsAdmin != nil {
+		mux.Handle(c.prefixed("/admin/add-root"), adminHandler{token: c.rootsAdminToken, handler: wrappedAddRootHandler(c.rootsAdmin)})
+		mux.Handle(c.prefixed("/admin/remove-root"), adminHandler{token: c.rootsAdminToken, handler: wrappedRemoveRootHandler(c.rootsAdmin)})
+	}
+
+	if c.denylist != nil {
+		mux.Handle(c.prefixed("/admin/denylist"), adminHandler{token: c.denylistAdminToken, handler: wrappedGetDenylistHandler(c.denylist)})
+		mux.Handle(c.prefixed("/admin/denylist-add"), adminHandler{token: c.denylistAdminToken, handler: wrappedDenylistAddHandler(c.denylist)})
+		mux.Handle(c.prefixed("/admin/denylist-remove"), adminHandler{token: c.denylistAdminToken, handler: wrappedDenylistRemoveHandler(c.denylist)})
+	}
+
+	// Other API versions are served alongside RFC 6962 for the same log
+	for _, version := range c.apiVersions {
+		c.registerAPIVersion(mux, version)
+	}
+
+	// Optional so they aren't in ctapi.Endpoints
+	if c.precertLinks != nil {
+		c.handle(mux, "get-precert-link", wrappedGetPrecertLinkHandler(c.precertLinks))
+	}
+
+	if c.gossip != nil {
+		c.handle(mux, "gossip", wrappedGossipHandler(c, c.gossip))
+	}
+
+	if c.domainIndex != nil {
+		c.handle(mux, "get-entries-by-domain", wrappedGetEntriesByDomainHandler(c.domainIndex))
+	}
+
+	if c.sthGuard != nil && len(c.sthGuardAdminToken) > 0 {
+		mux.Handle(c.prefixed("/admin/reset-sth-guard"), adminHandler{token: c.sthGuardAdminToken, handler: wrappedResetSTHGuardHandler(c.sthGuard)})
+	}
+}
+
+// metricsWriters returns the functions that write the log's metrics served on /metrics, those
+// of the submitted certificates, the requests to the log's endpoints and the RPCs to its
+// backend, for the ones that are enabled.
+func (c CTRequestHandlers) metricsWriters() []func(io.Writer) {
+	var writers []func(io.Writer)
+
+	if c.certMetrics != nil {
+		writers = append(writers, c.certMetrics.WriteText)
+	}
+
+	if c.requestMetrics != nil {
+		writers = append(writers, c.requestMetrics.WriteText)
+	}
+
+	if c.backendMetrics != nil {
+		writers = append(writers, func(w io.Writer) {
+			c.backendMetrics.WriteText(w, c.backendAddress)
+		})
+	}
+
+	return writers
+}
+
+// handle registers the handler for a CT endpoint on mux, tracking its requests if SLO tracking
+// or request metrics are enabled and rejecting them until the log is ready if readiness gating is enabled.
+func (c CTRequestHandlers) handle(mux *http.ServeMux, endpoint string, handler http.Handler) {
+	c.handleAt(mux, endpoint, pathFor(endpoint), handler)
+}
+
+// handleAt registers the handler for an endpoint like handle, at path under the log's prefix
+// and tracked as endpoint.
+func (c CTRequestHandlers) handleAt(mux *http.ServeMux, endpoint, path string, handler http.Handler) {
+	if c.sloTracker != nil {
+		handler = sloHandler{endpoint: endpoint, tracker: c.sloTracker, handler: handler}
+	}
+
+	if c.requestMetrics != nil {
+		handler = requestMetricsHandler{endpoint: endpoint, metrics: c.requestMetrics, handler: handler}
+	}
+
+	// Requests rejected because the log isn't ready yet don't count against the SLO
+	if c.readiness != nil {
+		handler = readinessHandler{readiness: c.readiness, handler: handler}
+	}
+
+	mux.Handle(c.prefixed(path), handler)
+}
+
+// authenticated wraps the handler for a write endpoint so that it only serves known
+// submitters, if submitter authentication is enabled
+func (c CTRequestHandlers) authenticated(handler http.Handler) http.Handler {
+	if c.submitters == nil {
+		return handler
+	}
+
+	return submitterAuthHandler{submitters: c.submitters, handler: handler}
+}
+
+// prefixed returns path under the base path and the log's path prefix, if they're set
+func (c CTRequestHandlers) prefixed(path string) string {
+	if len(c.pathPrefix) == 0 {
+		return c.basePath + path
+	}
+
+	return c.basePath + "/" + c.pathPrefix + path
+}
+
+// Sends a JSON ctapi.Error to give more information on why something didn't work
 // TODO(Martin2112): Not sure if we want to expose any detail or not
 func sendHttpError(w http.ResponseWriter, statusCode int, err error) {
-	http.Error(w, fmt.Sprintf("%s\n%v", http.StatusText(statusCode), err), statusCode)
+	httpErr := ctapi.NewError(statusCode, err)
+	httpErr.RequestID = w.Header().Get(requestIDHeader)
+	jsonData, jsonErr := json.Marshal(httpErr)
+
+	if jsonErr != nil {
+		// Shouldn't happen but fall back to plain text so the client gets something
+		http.Error(w, fmt.Sprintf("%s\n%v", http.StatusText(statusCode), err), statusCode)
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	w.WriteHeader(statusCode)
+	w.Write(jsonData)
 }
 
 // getRPCDeadlineTime calculates the future time an RPC should expire based on our config
@@ -637,13 +1431,51 @@ func rpcStatusOK(status *trillian.TrillianApiStatus) bool {
 	return status != nil && status.StatusCode == trillian.TrillianApiStatusCode_OK
 }
 
+// backendError returns the error for a backend RPC that failed with err, or if err is nil
+// returned status. Errors that the backend sent with the code of a category keep it so, for
+// example, a tree size the backend doesn't know about is reported as not found. Anything else
+// is a Backend failure.
+func backendError(rpc string, err error, status *trillian.TrillianApiStatus) error {
+	if err == nil {
+		return terrors.Errorf(terrors.Backend, "backend %s failed with status: %v", rpc, status)
+	}
+
+	code := terrors.CodeOf(err)
+
+	if code == terrors.Unknown {
+		code = terrors.Backend
+	}
+
+	return terrors.Errorf(code, "backend %s failed: %v", rpc, err)
+}
+
+// errorStatus returns the HTTP status for the category of err along with err, in the form
+// that appHandlers return them.
+func errorStatus(err error) (int, error) {
+	return terrors.HTTPStatus(err), err
+}
+
 // verifyAddChain is used by add-chain and add-pre-chain. It does the checks that the supplied
-// cert is of the correct type and chains to a trusted root.
+// cert is of the correct type and chains to a trusted root. If cache is not nil it is used to
+// avoid verifying intermediates again. If limits is not nil chains that exceed them are refused.
 // TODO(Martin2112): This may not implement all the RFC requirements. Check what is provided
 // by fixchain (called by this code) plus the ones here to make sure that it is compliant.
-func verifyAddChain(req addChainRequest, w http.ResponseWriter, trustedRoots PEMCertPool, expectingPrecert bool) ([]*x509.Certificate, error) {
+func verifyAddChain(req ctapi.AddChainRequest, trustedRoots PEMCertPool, cache *ChainCache, limits *ChainLimits, expectingPrecert bool) ([]*x509.Certificate, error) {
 	// We already checked that the chain is not empty so can move on to verification
-	validPath, err := ValidateChain(req.Chain, trustedRoots)
+	var validPath []*x509.Certificate
+	var err error
+
+	if cache != nil {
+		validPath, err = cache.ValidateChain(req.Chain, trustedRoots, limits)
+	} else {
+		validPath, err = ValidateChainWithLimits(req.Chain, trustedRoots, limits)
+	}
+
+	if _, ok := err.(ChainLimitError); ok {
+		// Returned as is so the submitter can see which limit the chain exceeded
+		glog.Warningf("Chain exceeding limits submitted: %v", err)
+		return nil, err
+	}
 
 	if err != nil {
 		// We rejected it because the cert failed checks or we could not find a path to a root etc.
@@ -654,7 +1486,9 @@ func verifyAddChain(req addChainRequest, w http.ResponseWriter, trustedRoots PEM
 	isPrecert, err := IsPrecertificate(validPath[0])
 
 	if err != nil {
-		return nil, fmt.Errorf("precert test failed: %v", err)
+		// This is a PoisonExtensionError, which is returned as is so that the reason is clear
+		glog.Warningf("Leaf with malformed poison extension submitted: %v", err)
+		return nil, err
 	}
 
 	// The type of the leaf must match the one the handler expects
@@ -690,7 +1524,7 @@ func marshalLogIDAndSignatureForResponse(sct ct.SignedCertificateTimestamp, km c
 
 // buildLeafProtoForAddChain is also used by add-pre-chain and does the hashing to build a
 // LeafProto that will be sent to the backend
-func buildLeafProtoForAddChain(merkleLeaf ct.MerkleTreeLeaf, certChain []*x509.Certificate) (trillian.LeafProto, error) {
+func buildLeafProtoForAddChain(merkleLeaf ct.MerkleTreeLeaf, certChain []*x509.Certificate, alg trillian.HashAlgorithm) (trillian.LeafProto, error) {
 	var leafBuffer bytes.Buffer
 	if err := writeMerkleTreeLeaf(&leafBuffer, merkleLeaf); err != nil {
 		glog.Warningf("Failed to serialize merkle leaf: %v", err)
@@ -704,11 +1538,18 @@ func buildLeafProtoForAddChain(merkleLeaf ct.MerkleTreeLeaf, certChain []*x509.C
 		return trillian.LeafProto{}, err
 	}
 
+	hasher, err := trillian.NewHasher(alg)
+
+	if err != nil {
+		return trillian.LeafProto{}, err
+	}
+
 	// leafHash is a crosscheck on the data we're sending in the leaf buffer. The backend
-	// does the tree hashing.
-	leafHash := sha256.Sum256(leafBuffer.Bytes())
+	// checks it against the leaf hash strategy of the tree, which must be RFC 6962 for CT
+	// so that the hashes clients use in get-proof-by-hash match.
+	leafHash := merkle.NewRFC6962TreeHasher(hasher).HashLeaf(leafBuffer.Bytes())
 
-	return trillian.LeafProto{LeafHash: leafHash[:], LeafData: leafBuffer.Bytes(), ExtraData: logEntryBuffer.Bytes()}, nil
+	return trillian.LeafProto{LeafHash: leafHash, LeafData: leafBuffer.Bytes(), ExtraData: logEntryBuffer.Bytes()}, nil
 }
 
 // marshalAndWriteAddChainResponse is used by add-chain and add-pre-chain to create and write
@@ -720,11 +1561,11 @@ func marshalAndWriteAddChainResponse(sct ct.SignedCertificateTimestamp, km crypt
 		return fmt.Errorf("failed to marshal for response: %v", err)
 	}
 
-	resp := addChainResponse{
+	resp := ctapi.AddChainResponse{
 		SctVersion: int(sct.SCTVersion),
 		Timestamp:  sct.Timestamp,
 		ID:         base64.StdEncoding.EncodeToString(logID[:]),
-		Extensions: "",
+		Extensions: base64.StdEncoding.EncodeToString(sct.Extensions),
 		Signature:  signature}
 
 	w.Header().Set(contentTypeHeader, contentTypeJSON)
@@ -787,6 +1628,20 @@ func parseAndValidateGetEntryAndProofParams(r *http.Request) (int64, int64, erro
 	return leafIndex, treeSize, nil
 }
 
+func parseAndValidateGetSTHAndEntriesCount(r *http.Request, maxAllowed int64) (int64, error) {
+	count, err := strconv.ParseInt(r.FormValue(getSTHAndEntriesParamCount), 10, 64)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if count <= 0 || count > maxAllowed {
+		return 0, fmt.Errorf("count must be between 1 and %d, got: %d", maxAllowed, count)
+	}
+
+	return count, nil
+}
+
 // validateStartAndEnd applies validation to the range params for get-entries. Either returns
 // the parameters to be used (which could be a subset of the request input though it
 // currently never is) or an error that describes why the parameters are not acceptable.
@@ -861,10 +1716,31 @@ func isResponseContiguousRange(response *trillian.GetLeavesByIndexResponse, star
 	return nil
 }
 
+// checkLatestLeaves checks that the backend returned the last count leaves of a tree of
+// treeSize, or all of them if it's smaller, in order. This is additional protection against
+// backend bugs. Returns nil if the leaves look valid.
+func checkLatestLeaves(leaves []*trillian.LeafProto, treeSize, count int64) error {
+	if treeSize < count {
+		count = treeSize
+	}
+
+	if got := int64(len(leaves)); got != count {
+		return fmt.Errorf("got %d leaves, expected %d", got, count)
+	}
+
+	for i, leaf := range leaves {
+		if want := treeSize - count + int64(i); leaf.LeafIndex != want {
+			return fmt.Errorf("got leaf %d at position %d, expected leaf %d", leaf.LeafIndex, i, want)
+		}
+	}
+
+	return nil
+}
+
 // marshalGetEntriesResponse does the conversion from the backend response to the one we need for
 // an RFC compliant JSON response to the client.
-func marshalGetEntriesResponse(rpcResponse *trillian.GetLeavesByIndexResponse) (getEntriesResponse, error) {
-	jsonResponse := getEntriesResponse{}
+func marshalGetEntriesResponse(rpcResponse *trillian.GetLeavesByIndexResponse) (ctapi.GetEntriesResponse, error) {
+	jsonResponse := ctapi.GetEntriesResponse{}
 
 	for _, leaf := range rpcResponse.Leaves {
 		// We're only deserializing it to ensure it's valid, don't need the result. We still
@@ -876,7 +1752,7 @@ func marshalGetEntriesResponse(rpcResponse *trillian.GetLeavesByIndexResponse) (
 			glog.Warningf("Failed to deserialize merkle leaf from backend: %d", leaf.LeafIndex)
 		}
 
-		jsonResponse.Entries = append(jsonResponse.Entries, getEntriesEntry{
+		jsonResponse.Entries = append(jsonResponse.Entries, ctapi.GetEntriesEntry{
 			LeafInput: leaf.LeafData,
 			ExtraData: leaf.ExtraData})
 	}
@@ -884,11 +1760,23 @@ func marshalGetEntriesResponse(rpcResponse *trillian.GetLeavesByIndexResponse) (
 	return jsonResponse, nil
 }
 
+// removeExtraData converts a get-entries response to one for a client that asked for the
+// entries without their extra data.
+func removeExtraData(resp ctapi.GetEntriesResponse) ctapi.GetEntriesWithoutExtraDataResponse {
+	jsonResponse := ctapi.GetEntriesWithoutExtraDataResponse{}
+
+	for _, entry := range resp.Entries {
+		jsonResponse.Entries = append(jsonResponse.Entries, ctapi.GetEntriesWithoutExtraDataEntry{LeafInput: entry.LeafInput})
+	}
+
+	return jsonResponse
+}
+
 // convertSTHForClientResponse does some simple marshalling from a properly signed CT object
 // to the object we'll use to create the JSON response to a client with the correct RFC
 // field names.
-func convertSTHForClientResponse(sth ct.SignedTreeHead) getSTHResponse {
-	return getSTHResponse{
+func convertSTHForClientResponse(sth ct.SignedTreeHead) ctapi.GetSTHResponse {
+	return ctapi.GetSTHResponse{
 		TreeSize:        int64(sth.TreeSize),
 		RootHash:        sth.SHA256RootHash[:],
 		TimestampMillis: int64(sth.Timestamp),
@@ -4,16 +4,16 @@ package ct
 // uses dummy objects. Real tests might be better done as integration tests on the log operation.
 
 import (
-	"bufio"
 	"bytes"
-	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"reflect"
 	"strings"
 	"testing"
@@ -26,9 +26,15 @@ import (
 	"github.com/google/certificate-transparency/go/x509"
 	"github.com/google/trillian"
 	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/examples/ct/ctapi"
 	"github.com/google/trillian/examples/ct/testonly"
+	"github.com/google/trillian/merkle"
+	ttestonly "github.com/google/trillian/testonly"
 	"github.com/google/trillian/util"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 )
 
 // Arbitrary time for use in tests
@@ -39,10 +45,6 @@ var fakeDeadlineTime = time.Date(2016, 7, 22, 11, 01, 13, 500*1000*1000, time.UT
 var fakeTimeSource = util.FakeTimeSource{fakeTime}
 var okStatus = &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}
 
-type jsonChain struct {
-	Chain []string `json:chain`
-}
-
 type getEntriesRangeTestCase struct {
 	start          int64
 	end            int64
@@ -56,8 +58,8 @@ var getEntriesRangeTestCases = []getEntriesRangeTestCase{
 	{0, -1, http.StatusBadRequest, "-ve end value not allowed", false},
 	{20, 10, http.StatusBadRequest, "invalid range end>start", false},
 	{3000, -50, http.StatusBadRequest, "invalid range, -ve end", false},
-	{10, 20, http.StatusInternalServerError, "valid range", true},
-	{10, 10, http.StatusInternalServerError, "valid range, one entry", true},
+	{10, 20, http.StatusServiceUnavailable, "valid range", true},
+	{10, 10, http.StatusServiceUnavailable, "valid range, one entry", true},
 	{10, 9, http.StatusBadRequest, "invalid range, edge case", false},
 	{1000, 50000, http.StatusBadRequest, "range too large to be accepted", false}}
 
@@ -68,7 +70,9 @@ var getEntryAndProofBadRequests = []string{
 	"leaf_index=10&tree_size=5", "leaf_index=tree_size"}
 
 // A list of requests that should result in a bad request status
-var getProofByHashBadRequests = []string{"", "hash=&tree_size=1", "hash=''&tree_size=1", "hash=notbase64data&tree_size=1", "tree_size=-1&hash=aGkK"}
+var getProofByHashBadRequests = []string{"", "hash=&tree_size=1", "hash=''&tree_size=1", "hash=notbase64data&tree_size=1", "tree_size=-1&hash=aGkK",
+	// The all parameter is only accepted if it has been enabled
+	"?tree_size=7&hash=YWhhc2g=&all=true"}
 
 // A list of requests for get-sth-consistency that should result in a bad request status
 var getSTHConsistencyBadRequests = []string{"", "first=apple&second=orange", "first=1&second=a",
@@ -76,12 +80,12 @@ var getSTHConsistencyBadRequests = []string{"", "first=apple&second=orange", "fi
 	"first=998&second=997", "first=1000&second=200", "first=10", "second=20"}
 
 // The result we expect after a roundtrip in the successful get proof by hash test
-var expectedInclusionProofByHash = getProofByHashResponse{
+var expectedInclusionProofByHash = ctapi.GetProofByHashResponse{
 	LeafIndex: 2,
 	AuditPath: [][]byte{[]byte("abcdef"), []byte("ghijkl"), []byte("mnopqr")}}
 
 // The result we expect after a roundtrip in the successful get sth consistency test
-var expectedSTHConsistencyProofByHash = getSTHConsistencyResponse{Consistency: [][]byte{[]byte("abcdef"), []byte("ghijkl"), []byte("mnopqr")}}
+var expectedSTHConsistencyProofByHash = ctapi.GetSTHConsistencyResponse{Consistency: [][]byte{[]byte("abcdef"), []byte("ghijkl"), []byte("mnopqr")}}
 
 const caCertB64 string = `MIIC0DCCAjmgAwIBAgIBADANBgkqhkiG9w0BAQUFADBVMQswCQYDVQQGEwJHQjEk
 MCIGA1UEChMbQ2VydGlmaWNhdGUgVHJhbnNwYXJlbmN5IENBMQ4wDAYDVQQIEwVX
@@ -134,8 +138,10 @@ func allGetHandlersForTest(trustedRoots *PEMCertPool, c CTRequestHandlers) []han
 		{"get-sth-consistency", wrappedGetSTHConsistencyHandler(c)},
 		{"get-proof-by-hash", wrappedGetProofByHashHandler(c)},
 		{"get-entries", wrappedGetEntriesHandler(c)},
-		{"get-roots", wrappedGetRootsHandler(trustedRoots)},
-		{"get-entry-and-proof", wrappedGetEntryAndProofHandler(c)}}
+		{"get-roots", wrappedGetRootsHandler(CTRequestHandlers{trustedRoots: trustedRoots})},
+		{"get-entry-and-proof", wrappedGetEntryAndProofHandler(c)},
+		{"get-log-metadata", wrappedGetLogMetadataHandler(c)},
+		{"get-sth-and-entries", wrappedGetSTHAndEntriesHandler(c)}}
 }
 
 func allPostHandlersForTest(client trillian.TrillianLogClient) []handlerAndPath {
@@ -306,7 +312,7 @@ func TestGetRoots(t *testing.T) {
 	defer mockCtrl.Finish()
 
 	roots := loadCertsIntoPoolOrDie(t, []string{caAndIntermediateCertsPEM})
-	handler := wrappedGetRootsHandler(roots)
+	handler := wrappedGetRootsHandler(CTRequestHandlers{trustedRoots: roots})
 
 	req, err := http.NewRequest("GET", "http://example.com/ct/v1/get-roots", nil)
 	if err != nil {
@@ -327,7 +333,7 @@ func TestGetRoots(t *testing.T) {
 	if expected, got := 1, len(parsedJson); expected != got {
 		t.Fatalf("Expected %v entry(s) in json map, got %v", expected, got)
 	}
-	certs := parsedJson[jsonMapKeyCertificates]
+	certs := parsedJson["certificates"]
 	if expected, got := 2, len(certs); expected != got {
 		t.Fatalf("Expected %v root certs got %v: %v", expected, got, certs)
 	}
@@ -349,10 +355,10 @@ func TestAddChainMissingIntermediate(t *testing.T) {
 	km := crypto.NewMockKeyManager(mockCtrl)
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.FakeCACertPem})
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	reqHandlers := CTRequestHandlers{logID: 0x42, trustedRoots: roots, rpcClient: client, logKeyManager: km, rpcDeadline: time.Millisecond * 500, timeSource: fakeTimeSource}
 
 	pool := loadCertsIntoPoolOrDie(t, []string{testonly.LeafSignedByFakeIntermediateCertPem})
-	chain := createJsonChain(t, *pool)
+	chain := testonly.AddChainBody(pool.RawCertificates())
 
 	recorder := makeAddChainRequest(t, reqHandlers, chain)
 
@@ -361,6 +367,73 @@ func TestAddChainMissingIntermediate(t *testing.T) {
 	}
 }
 
+// A submission policy sees the verified chain and can reject it before an SCT is issued
+func TestAddChainRejectedByPolicy(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	km := crypto.NewMockKeyManager(mockCtrl)
+
+	var seen []*x509.Certificate
+	reject := func(chain []*x509.Certificate, isPrecert bool) error {
+		seen = chain
+		return errors.New("issuer not allowed")
+	}
+
+	roots := loadCertsIntoPoolOrDie(t, []string{testonly.FakeCACertPem})
+	reqHandlers := NewCTRequestHandlers(0x42, roots, client, km, WithTimeSource(fakeTimeSource), WithSubmissionPolicy(reject))
+
+	pool := loadCertsIntoPoolOrDie(t, []string{testonly.LeafSignedByFakeIntermediateCertPem, testonly.FakeIntermediateCertPem})
+	chain := testonly.AddChainBody(pool.RawCertificates())
+
+	recorder := makeAddChainRequest(t, *reqHandlers, chain)
+
+	if got, want := recorder.Code, http.StatusBadRequest; got != want {
+		t.Fatalf("Expected %v for add-chain rejected by policy got %v. Body: %v", want, got, recorder.Body)
+	}
+
+	if !strings.Contains(recorder.Body.String(), "issuer not allowed") {
+		t.Errorf("Policy error not returned: %v", recorder.Body)
+	}
+
+	// The chain is the verified path without the root
+	if len(seen) != 2 {
+		t.Errorf("Policy saw a chain of %d certs, expected 2", len(seen))
+	}
+}
+
+// Field names must match RFC 6962 exactly, the backend must not be called for a request that
+// only decodes leniently
+func TestAddChainRejectsWrongFieldCase(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	km := crypto.NewMockKeyManager(mockCtrl)
+
+	roots := loadCertsIntoPoolOrDie(t, []string{testonly.FakeCACertPem})
+	reqHandlers := CTRequestHandlers{logID: 0x42, trustedRoots: roots, rpcClient: client, logKeyManager: km, rpcDeadline: time.Millisecond * 500, timeSource: fakeTimeSource}
+
+	pool := loadCertsIntoPoolOrDie(t, []string{testonly.LeafSignedByFakeIntermediateCertPem, testonly.FakeIntermediateCertPem})
+	chain := testonly.AddChainBody(pool.RawCertificates())
+	body, err := ioutil.ReadAll(chain)
+
+	if err != nil {
+		t.Fatalf("Failed to read chain: %v", err)
+	}
+
+	recorder := makeAddChainRequest(t, reqHandlers, strings.NewReader(strings.Replace(string(body), `"chain"`, `"Chain"`, 1)))
+
+	if got, want := recorder.Code, http.StatusBadRequest; got != want {
+		t.Fatalf("Expected %v for add-chain with wrong field case got %v. Body: %v", want, got, recorder.Body)
+	}
+
+	if !strings.Contains(recorder.Body.String(), "unknown fields") {
+		t.Fatalf("Did not get expected error for wrong field case: %v", recorder.Body)
+	}
+}
+
 // This uses a fake CA as trusted root and submits a chain of just a precert leaf which should be
 // rejected
 func TestAddChainPrecert(t *testing.T) {
@@ -371,7 +444,7 @@ func TestAddChainPrecert(t *testing.T) {
 	km := crypto.NewMockKeyManager(mockCtrl)
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.CACertPEM})
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	reqHandlers := CTRequestHandlers{logID: 0x42, trustedRoots: roots, rpcClient: client, logKeyManager: km, rpcDeadline: time.Millisecond * 500, timeSource: fakeTimeSource}
 
 	// TODO(Martin2112): I don't think CT should return NonFatalError for something we expect
 	// to happen - seeing a precert extension. If this is fixed upstream remove all references from
@@ -383,7 +456,7 @@ func TestAddChainPrecert(t *testing.T) {
 	}
 	pool := NewPEMCertPool()
 	pool.AddCert(precert)
-	chain := createJsonChain(t, *pool)
+	chain := testonly.AddChainBody(pool.RawCertificates())
 
 	recorder := makeAddChainRequest(t, reqHandlers, chain)
 
@@ -403,13 +476,13 @@ func TestAddChainRPCFails(t *testing.T) {
 	km := setupMockKeyManager(mockCtrl, toSign)
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.FakeCACertPem})
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	reqHandlers := CTRequestHandlers{logID: 0x42, trustedRoots: roots, rpcClient: client, logKeyManager: km, rpcDeadline: time.Millisecond * 500, timeSource: fakeTimeSource}
 
 	pool := loadCertsIntoPoolOrDie(t, []string{testonly.LeafSignedByFakeIntermediateCertPem, testonly.FakeIntermediateCertPem})
-	chain := createJsonChain(t, *pool)
+	chain := testonly.AddChainBody(pool.RawCertificates())
 
 	// Ignore returned SCT. That's sent to the client and we're testing frontend -> backend interaction
-	merkleLeaf, _, err := signV1SCTForCertificate(km, pool.RawCertificates()[0], fakeTime)
+	merkleLeaf, _, err := signV1SCTForCertificate(km, SignatureOptions{}, pool.RawCertificates()[0], fakeTime, nil)
 
 	if err != nil {
 		t.Fatal(err)
@@ -417,7 +490,7 @@ func TestAddChainRPCFails(t *testing.T) {
 
 	leaves := leafProtosForCert(t, km, pool.RawCertificates(), merkleLeaf)
 
-	client.EXPECT().QueueLeaves(deadlineMatcher(), &trillian.QueueLeavesRequest{LogId: 0x42, Leaves: leaves}).Return(&trillian.QueueLeavesResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode(trillian.TrillianApiStatusCode_ERROR)}}, nil)
+	client.EXPECT().QueueLeaves(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.QueueLeavesRequest{LogId: 0x42, Leaves: leaves}).Return(&trillian.QueueLeavesResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode(trillian.TrillianApiStatusCode_ERROR)}}, nil)
 
 	recorder := makeAddChainRequest(t, reqHandlers, chain)
 
@@ -437,13 +510,13 @@ func TestAddChain(t *testing.T) {
 	km := setupMockKeyManager(mockCtrl, toSign)
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.FakeCACertPem})
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	reqHandlers := CTRequestHandlers{logID: 0x42, trustedRoots: roots, rpcClient: client, logKeyManager: km, rpcDeadline: time.Millisecond * 500, timeSource: fakeTimeSource}
 
 	pool := loadCertsIntoPoolOrDie(t, []string{testonly.LeafSignedByFakeIntermediateCertPem, testonly.FakeIntermediateCertPem})
-	chain := createJsonChain(t, *pool)
+	chain := testonly.AddChainBody(pool.RawCertificates())
 
 	// Ignore returned SCT. That's sent to the client and we're testing frontend -> backend interaction
-	merkleLeaf, _, err := signV1SCTForCertificate(km, pool.RawCertificates()[0], fakeTime)
+	merkleLeaf, _, err := signV1SCTForCertificate(km, SignatureOptions{}, pool.RawCertificates()[0], fakeTime, nil)
 
 	if err != nil {
 		t.Fatal(err)
@@ -451,7 +524,7 @@ func TestAddChain(t *testing.T) {
 
 	leaves := leafProtosForCert(t, km, pool.RawCertificates(), merkleLeaf)
 
-	client.EXPECT().QueueLeaves(deadlineMatcher(), &trillian.QueueLeavesRequest{LogId: 0x42, Leaves: leaves}).Return(&trillian.QueueLeavesResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}}, nil)
+	client.EXPECT().QueueLeaves(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.QueueLeavesRequest{LogId: 0x42, Leaves: leaves}).Return(&trillian.QueueLeavesResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}}, nil)
 
 	recorder := makeAddChainRequest(t, reqHandlers, chain)
 
@@ -460,7 +533,7 @@ func TestAddChain(t *testing.T) {
 	}
 
 	// Roundtrip the response and make sure it's sensible
-	var resp addChainResponse
+	var resp ctapi.AddChainResponse
 	if err = json.NewDecoder(recorder.Body).Decode(&resp); err != nil {
 		t.Fatalf("failed to unmarshal json: %v, body: %v", err, recorder.Body.Bytes())
 	}
@@ -479,6 +552,49 @@ func TestAddChain(t *testing.T) {
 	}
 }
 
+// In fast SCT mode the leaf should be journalled and the SCT issued without calling the backend
+func TestAddChainFastSCT(t *testing.T) {
+	toSign := []byte{0x7a, 0xc4, 0xd9, 0xca, 0x5f, 0x2e, 0x23, 0x82, 0xfe, 0xef, 0x5e, 0x95, 0x64, 0x7b, 0x31, 0x11, 0xf, 0x2a, 0x9b, 0x78, 0xa8, 0x3, 0x30, 0x8d, 0xfc, 0x8b, 0x78, 0x6, 0x61, 0xe7, 0x58, 0x44}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	dir := makeTempJournalDir(t)
+	defer os.RemoveAll(dir)
+
+	// No backend calls are expected
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	km := setupMockKeyManager(mockCtrl, toSign)
+
+	roots := loadCertsIntoPoolOrDie(t, []string{testonly.FakeCACertPem})
+	reqHandlers := CTRequestHandlers{logID: 0x42, trustedRoots: roots, rpcClient: client, logKeyManager: km, rpcDeadline: time.Millisecond * 500, timeSource: fakeTimeSource}
+	journal := openJournalOrDie(t, dir, fakeTimeSource)
+	WithFastSCT(journal)(&reqHandlers)
+
+	pool := loadCertsIntoPoolOrDie(t, []string{testonly.LeafSignedByFakeIntermediateCertPem, testonly.FakeIntermediateCertPem})
+	chain := testonly.AddChainBody(pool.RawCertificates())
+
+	merkleLeaf, _, err := signV1SCTForCertificate(km, SignatureOptions{}, pool.RawCertificates()[0], fakeTime, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaves := leafProtosForCert(t, km, pool.RawCertificates(), merkleLeaf)
+
+	recorder := makeAddChainRequest(t, reqHandlers, chain)
+
+	if got, want := recorder.Code, http.StatusOK; got != want {
+		t.Fatalf("expected %v for valid add-chain, got %v. Body: %v", want, got, recorder.Body)
+	}
+
+	if got, want := journal.Pending(), 1; got != want {
+		t.Fatalf("Got %d journalled leaves, expected %d", got, want)
+	}
+	if got, want := journal.pending[0].leaf, *leaves[0]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Journalled leaf: got %v, expected %v", got, want)
+	}
+}
+
 // Submit a chain with a valid precert but not signed by next cert in chain. Should be rejected.
 func TestAddPrecertChainInvalidPath(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
@@ -488,7 +604,7 @@ func TestAddPrecertChainInvalidPath(t *testing.T) {
 	km := crypto.NewMockKeyManager(mockCtrl)
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.CACertPEM})
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	reqHandlers := CTRequestHandlers{logID: 0x42, trustedRoots: roots, rpcClient: client, logKeyManager: km, rpcDeadline: time.Millisecond * 500, timeSource: fakeTimeSource}
 
 	cert, err := fixchain.CertificateFromPEM(testonly.PrecertPEMValid)
 	_, ok := err.(x509.NonFatalErrors)
@@ -508,7 +624,7 @@ func TestAddPrecertChainInvalidPath(t *testing.T) {
 
 	pool.AddCert(cert)
 
-	chain := createJsonChain(t, *pool)
+	chain := testonly.AddChainBody(pool.RawCertificates())
 
 	recorder := makeAddPrechainRequest(t, reqHandlers, chain)
 
@@ -526,7 +642,7 @@ func TestAddPrecertChainCert(t *testing.T) {
 	km := crypto.NewMockKeyManager(mockCtrl)
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.CACertPEM})
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	reqHandlers := CTRequestHandlers{logID: 0x42, trustedRoots: roots, rpcClient: client, logKeyManager: km, rpcDeadline: time.Millisecond * 500, timeSource: fakeTimeSource}
 
 	cert, err := fixchain.CertificateFromPEM(testonly.TestCertPEM)
 
@@ -536,7 +652,7 @@ func TestAddPrecertChainCert(t *testing.T) {
 
 	pool := NewPEMCertPool()
 	pool.AddCert(cert)
-	chain := createJsonChain(t, *pool)
+	chain := testonly.AddChainBody(pool.RawCertificates())
 
 	recorder := makeAddPrechainRequest(t, reqHandlers, chain)
 
@@ -556,7 +672,7 @@ func TestAddPrecertChainRPCFails(t *testing.T) {
 	km := setupMockKeyManager(mockCtrl, toSign)
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.CACertPEM})
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	reqHandlers := CTRequestHandlers{logID: 0x42, trustedRoots: roots, rpcClient: client, logKeyManager: km, rpcDeadline: time.Millisecond * 500, timeSource: fakeTimeSource}
 
 	cert, err := fixchain.CertificateFromPEM(testonly.PrecertPEMValid)
 	_, ok := err.(x509.NonFatalErrors)
@@ -567,10 +683,10 @@ func TestAddPrecertChainRPCFails(t *testing.T) {
 
 	pool := NewPEMCertPool()
 	pool.AddCert(cert)
-	chain := createJsonChain(t, *pool)
+	chain := testonly.AddChainBody(pool.RawCertificates())
 
 	// Ignore returned SCT. That's sent to the client and we're testing frontend -> backend interaction
-	merkleLeaf, _, err := signV1SCTForPrecertificate(km, pool.RawCertificates()[0], fakeTime)
+	merkleLeaf, _, err := signV1SCTForPrecertificate(km, SignatureOptions{}, pool.RawCertificates()[0], fakeTime, nil)
 
 	if err != nil {
 		t.Fatal(err)
@@ -578,7 +694,7 @@ func TestAddPrecertChainRPCFails(t *testing.T) {
 
 	leaves := leafProtosForCert(t, km, pool.RawCertificates(), merkleLeaf)
 
-	client.EXPECT().QueueLeaves(deadlineMatcher(), &trillian.QueueLeavesRequest{LogId: 0x42, Leaves: leaves}).Return(&trillian.QueueLeavesResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode(trillian.TrillianApiStatusCode_ERROR)}}, nil)
+	client.EXPECT().QueueLeaves(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.QueueLeavesRequest{LogId: 0x42, Leaves: leaves}).Return(&trillian.QueueLeavesResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode(trillian.TrillianApiStatusCode_ERROR)}}, nil)
 
 	recorder := makeAddPrechainRequest(t, reqHandlers, chain)
 
@@ -597,7 +713,7 @@ func TestAddPrecertChain(t *testing.T) {
 	km := setupMockKeyManager(mockCtrl, toSign)
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.CACertPEM})
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	reqHandlers := CTRequestHandlers{logID: 0x42, trustedRoots: roots, rpcClient: client, logKeyManager: km, rpcDeadline: time.Millisecond * 500, timeSource: fakeTimeSource}
 
 	cert, err := fixchain.CertificateFromPEM(testonly.PrecertPEMValid)
 	_, ok := err.(x509.NonFatalErrors)
@@ -608,10 +724,10 @@ func TestAddPrecertChain(t *testing.T) {
 
 	pool := NewPEMCertPool()
 	pool.AddCert(cert)
-	chain := createJsonChain(t, *pool)
+	chain := testonly.AddChainBody(pool.RawCertificates())
 
 	// Ignore returned SCT. That's sent to the client and we're testing frontend -> backend interaction
-	merkleLeaf, _, err := signV1SCTForPrecertificate(km, pool.RawCertificates()[0], fakeTime)
+	merkleLeaf, _, err := signV1SCTForPrecertificate(km, SignatureOptions{}, pool.RawCertificates()[0], fakeTime, nil)
 
 	if err != nil {
 		t.Fatal(err)
@@ -619,7 +735,7 @@ func TestAddPrecertChain(t *testing.T) {
 
 	leaves := leafProtosForCert(t, km, pool.RawCertificates(), merkleLeaf)
 
-	client.EXPECT().QueueLeaves(deadlineMatcher(), &trillian.QueueLeavesRequest{LogId: 0x42, Leaves: leaves}).Return(&trillian.QueueLeavesResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}}, nil)
+	client.EXPECT().QueueLeaves(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.QueueLeavesRequest{LogId: 0x42, Leaves: leaves}).Return(&trillian.QueueLeavesResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}}, nil)
 
 	recorder := makeAddPrechainRequest(t, reqHandlers, chain)
 
@@ -628,7 +744,7 @@ func TestAddPrecertChain(t *testing.T) {
 	}
 
 	// Roundtrip the response and make sure it's sensible
-	var resp addChainResponse
+	var resp ctapi.AddChainResponse
 	if err = json.NewDecoder(recorder.Body).Decode(&resp); err != nil {
 		t.Fatalf("failed to unmarshal json: %v, body: %v", err, recorder.Body.Bytes())
 	}
@@ -655,8 +771,8 @@ func TestGetSTHBackendErrorFails(t *testing.T) {
 	km := crypto.NewMockKeyManager(mockCtrl)
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.CACertPEM})
-	client.EXPECT().GetLatestSignedLogRoot(deadlineMatcher(), &trillian.GetLatestSignedLogRootRequest{LogId: 0x42}).Return(nil, errors.New("backendfailure"))
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	client.EXPECT().GetLatestSignedLogRoot(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLatestSignedLogRootRequest{LogId: 0x42}).Return(nil, errors.New("backendfailure"))
+	reqHandlers := CTRequestHandlers{logID: 0x42, trustedRoots: roots, rpcClient: client, logKeyManager: km, rpcDeadline: time.Millisecond * 500, timeSource: fakeTimeSource}
 	handler := wrappedGetSTHHandler(reqHandlers)
 
 	req, err := http.NewRequest("GET", "http://example.com/ct/v1/get-sth", nil)
@@ -667,12 +783,9 @@ func TestGetSTHBackendErrorFails(t *testing.T) {
 	w := httptest.NewRecorder()
 	handler.ServeHTTP(w, req)
 
-	if got, want := w.Code, http.StatusInternalServerError; got != want {
+	if got, want := w.Code, http.StatusServiceUnavailable; got != want {
 		t.Fatalf("Expected %v, got %v", want, got)
 	}
-	if want, in := "rpc failed", w.Body.String(); !strings.Contains(in, want) {
-		t.Fatalf("Expected to find %s within %s", want, in)
-	}
 }
 
 func TestGetSTHInvalidBackendTreeSizeFails(t *testing.T) {
@@ -685,8 +798,8 @@ func TestGetSTHInvalidBackendTreeSizeFails(t *testing.T) {
 	km := crypto.NewMockKeyManager(mockCtrl)
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.CACertPEM})
-	client.EXPECT().GetLatestSignedLogRoot(deadlineMatcher(), &trillian.GetLatestSignedLogRootRequest{LogId: 0x42}).Return(makeGetRootResponseForTest(12345, -50, []byte("abcdabcdabcdabcdabcdabcdabcdabcd")), nil)
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	client.EXPECT().GetLatestSignedLogRoot(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLatestSignedLogRootRequest{LogId: 0x42}).Return(ttestonly.GetRootResponse(12345, -50, []byte("abcdabcdabcdabcdabcdabcdabcdabcd")), nil)
+	reqHandlers := CTRequestHandlers{logID: 0x42, trustedRoots: roots, rpcClient: client, logKeyManager: km, rpcDeadline: time.Millisecond * 500, timeSource: fakeTimeSource}
 	handler := wrappedGetSTHHandler(reqHandlers)
 
 	req, err := http.NewRequest("GET", "http://example.com/ct/v1/get-sth", nil)
@@ -714,8 +827,8 @@ func TestGetSTHMissingRootHashFails(t *testing.T) {
 	km := crypto.NewMockKeyManager(mockCtrl)
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.CACertPEM})
-	client.EXPECT().GetLatestSignedLogRoot(deadlineMatcher(), &trillian.GetLatestSignedLogRootRequest{LogId: 0x42}).Return(makeGetRootResponseForTest(12345, 25, []byte("thisisnot32byteslong")), nil)
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	client.EXPECT().GetLatestSignedLogRoot(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLatestSignedLogRootRequest{LogId: 0x42}).Return(ttestonly.GetRootResponse(12345, 25, []byte("thisisnot32byteslong")), nil)
+	reqHandlers := CTRequestHandlers{logID: 0x42, trustedRoots: roots, rpcClient: client, logKeyManager: km, rpcDeadline: time.Millisecond * 500, timeSource: fakeTimeSource}
 	handler := wrappedGetSTHHandler(reqHandlers)
 
 	req, err := http.NewRequest("GET", "http://example.com/ct/v1/get-sth", nil)
@@ -743,12 +856,13 @@ func TestGetSTHSigningFails(t *testing.T) {
 	km := crypto.NewMockKeyManager(mockCtrl)
 
 	signer := crypto.NewMockSigner(mockCtrl)
+	signer.EXPECT().Public().AnyTimes().Return(testRSAPublicKey)
 	signer.EXPECT().Sign(gomock.Any(), gomock.Any(), gomock.Any()).Return([]byte{}, errors.New("signerfails"))
 	km.EXPECT().Signer().Return(signer, nil)
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.CACertPEM})
-	client.EXPECT().GetLatestSignedLogRoot(deadlineMatcher(), &trillian.GetLatestSignedLogRootRequest{LogId: 0x42}).Return(makeGetRootResponseForTest(12345, 25, []byte("abcdabcdabcdabcdabcdabcdabcdabcd")), nil)
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	client.EXPECT().GetLatestSignedLogRoot(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLatestSignedLogRootRequest{LogId: 0x42}).Return(ttestonly.GetRootResponse(12345, 25, []byte("abcdabcdabcdabcdabcdabcdabcdabcd")), nil)
+	reqHandlers := CTRequestHandlers{logID: 0x42, trustedRoots: roots, rpcClient: client, logKeyManager: km, rpcDeadline: time.Millisecond * 500, timeSource: fakeTimeSource}
 	handler := wrappedGetSTHHandler(reqHandlers)
 
 	req, err := http.NewRequest("GET", "http://example.com/ct/v1/get-sth", nil)
@@ -776,8 +890,8 @@ func TestGetSTH(t *testing.T) {
 	km := setupMockKeyManagerForSth(mockCtrl, toSign)
 
 	roots := loadCertsIntoPoolOrDie(t, []string{testonly.CACertPEM})
-	client.EXPECT().GetLatestSignedLogRoot(deadlineMatcher(), &trillian.GetLatestSignedLogRootRequest{LogId: 0x42}).Return(makeGetRootResponseForTest(12345000000, 25, []byte("abcdabcdabcdabcdabcdabcdabcdabcd")), nil)
-	reqHandlers := CTRequestHandlers{0x42, roots, client, km, time.Millisecond * 500, fakeTimeSource}
+	client.EXPECT().GetLatestSignedLogRoot(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLatestSignedLogRootRequest{LogId: 0x42}).Return(ttestonly.GetRootResponse(12345000000, 25, []byte("abcdabcdabcdabcdabcdabcdabcdabcd")), nil)
+	reqHandlers := CTRequestHandlers{logID: 0x42, trustedRoots: roots, rpcClient: client, logKeyManager: km, rpcDeadline: time.Millisecond * 500, timeSource: fakeTimeSource}
 	handler := wrappedGetSTHHandler(reqHandlers)
 
 	req, err := http.NewRequest("GET", "http://example.com/ct/v1/get-sth", nil)
@@ -793,7 +907,7 @@ func TestGetSTH(t *testing.T) {
 	}
 
 	// Now roundtrip the response and check we got the expected data
-	var parsedJson getSTHResponse
+	var parsedJson ctapi.GetSTHResponse
 	if err := json.Unmarshal(w.Body.Bytes(), &parsedJson); err != nil {
 		t.Fatalf("Failed to unmarshal json response: %s", w.Body.Bytes())
 	}
@@ -812,6 +926,100 @@ func TestGetSTH(t *testing.T) {
 	}
 }
 
+func TestGetSTHWithFakeLogClient(t *testing.T) {
+	toSign := []byte{0x1e, 0x88, 0x54, 0x6f, 0x51, 0x57, 0xbf, 0xaf, 0x77, 0xca, 0x24, 0x54, 0x69, 0xb, 0x60, 0x26, 0x31, 0xfe, 0xda, 0xe9, 0x25, 0xbb, 0xe7, 0xcf, 0x70, 0x8e, 0xa2, 0x75, 0x97, 0x5b, 0xfe, 0x74}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client := ttestonly.NewFakeLogClient().
+		Script("GetLatestSignedLogRoot", nil, errors.New("backendfailure")).
+		Script("GetLatestSignedLogRoot", ttestonly.GetRootResponse(12345000000, 25, []byte("abcdabcdabcdabcdabcdabcdabcdabcd")), nil)
+	km := setupMockKeyManagerForSth(mockCtrl, toSign)
+
+	roots := loadCertsIntoPoolOrDie(t, []string{testonly.CACertPEM})
+	reqHandlers := CTRequestHandlers{logID: 0x42, trustedRoots: roots, rpcClient: client, logKeyManager: km, rpcDeadline: time.Millisecond * 500, timeSource: fakeTimeSource}
+	handler := wrappedGetSTHHandler(reqHandlers)
+
+	for _, want := range []int{http.StatusInternalServerError, http.StatusOK} {
+		req, err := testonly.NewGetRequest("http://example.com", "get-sth", "")
+		if err != nil {
+			t.Fatalf("get-sth test request setup failed: %v", err)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if got := w.Code; got != want {
+			t.Fatalf("Got %v expected %v. Body: %v", got, want, w.Body)
+		}
+	}
+
+	if err := client.Unused(); err != nil {
+		t.Error(err)
+	}
+
+	calls := client.CallsTo("GetLatestSignedLogRoot")
+
+	if got, want := len(calls), 2; got != want {
+		t.Fatalf("Got %d calls to the backend, expected %d", got, want)
+	}
+
+	for _, call := range calls {
+		if !call.Deadline.Equal(fakeDeadlineTime) {
+			t.Errorf("Got deadline %v, expected %v", call.Deadline, fakeDeadlineTime)
+		}
+
+		if got, want := call.Request, (&trillian.GetLatestSignedLogRootRequest{LogId: 0x42}); !reflect.DeepEqual(got, want) {
+			t.Errorf("Got request %v, expected %v", got, want)
+		}
+	}
+}
+
+func TestSCTTimeNotBeforeSTH(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// The STH timestamp changes what's signed so accept any input
+	km := crypto.NewMockKeyManager(mockCtrl)
+	signer := crypto.NewMockSigner(mockCtrl)
+	signer.EXPECT().Public().AnyTimes().Return(testRSAPublicKey)
+	signer.EXPECT().Sign(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return([]byte("signed"), nil)
+	km.EXPECT().Signer().AnyTimes().Return(signer, nil)
+
+	// The backend's clock is ahead of ours
+	sthTime := fakeTime.Add(time.Minute)
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	client.EXPECT().GetLatestSignedLogRoot(gomock.Any(), &trillian.GetLatestSignedLogRootRequest{LogId: 0x42}).Return(ttestonly.GetRootResponse(sthTime.UnixNano(), 25, []byte("abcdabcdabcdabcdabcdabcdabcdabcd")), nil)
+
+	clock := &util.FakeTimeSource{FakeTime: fakeTime}
+	c := NewCTRequestHandlers(0x42, nil, client, km, WithTimeSource(clock))
+
+	if got, want := c.sctTime(), fakeTime; !got.Equal(want) {
+		t.Fatalf("Got SCT time %v before any STH, expected %v", got, want)
+	}
+
+	if _, err := getSignedTreeHead(context.Background(), *c); err != nil {
+		t.Fatalf("Failed to get STH: %v", err)
+	}
+
+	for _, test := range []struct {
+		desc  string
+		clock time.Time
+		want  time.Time
+	}{
+		{desc: "clock behind STH", clock: fakeTime.Add(time.Second), want: sthTime},
+		{desc: "clock stepped back", clock: fakeTime.Add(-time.Hour), want: sthTime},
+		{desc: "clock caught up", clock: sthTime.Add(time.Second), want: sthTime.Add(time.Second)},
+		{desc: "clock stepped back again", clock: sthTime, want: sthTime.Add(time.Second)},
+	} {
+		clock.FakeTime = test.clock
+
+		if got := c.sctTime(); !got.Equal(test.want) {
+			t.Errorf("%s: got SCT time %v, expected %v", test.desc, got, test.want)
+		}
+	}
+}
+
 func loadCertsIntoPoolOrDie(t *testing.T, certs []string) *PEMCertPool {
 	pool := NewPEMCertPool()
 
@@ -839,6 +1047,11 @@ func TestGetEntriesRejectsMissingParams(t *testing.T) {
 	getEntriesTestHelper(t, "", http.StatusBadRequest, "both missing")
 }
 
+func TestGetEntriesRejectsOmitExtraDataWhenNotEnabled(t *testing.T) {
+	getEntriesTestHelper(t, "start=1&end=2&omit_extra_data=true", http.StatusBadRequest, "omit_extra_data not enabled")
+	getEntriesTestHelper(t, "start=1&end=2&omit_extra_data=false", http.StatusBadRequest, "omit_extra_data not enabled")
+}
+
 func TestGetEntriesRanges(t *testing.T) {
 	// This tests that only valid ranges make it to the backend for get-entries.
 	// We're testing request handling up to the point where we make the RPC so arrange for
@@ -849,7 +1062,7 @@ func TestGetEntriesRanges(t *testing.T) {
 		client := trillian.NewMockTrillianLogClient(mockCtrl)
 
 		if testCase.rpcExpected {
-			client.EXPECT().GetLeavesByIndex(deadlineMatcher(), &trillian.GetLeavesByIndexRequest{LeafIndex: buildIndicesForRange(testCase.start, testCase.end)}).Return(nil, errors.New("RPCMADE"))
+			client.EXPECT().GetLeavesByIndex(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLeavesByIndexRequest{LeafIndex: buildIndicesForRange(testCase.start, testCase.end)}).Return(nil, errors.New("RPCMADE"))
 		}
 
 		c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
@@ -871,7 +1084,7 @@ func TestGetEntriesRanges(t *testing.T) {
 
 		// Additionally check that we saw our expected backend error and didn't get the result by
 		// chance
-		if testCase.expectedStatus == http.StatusInternalServerError {
+		if testCase.rpcExpected {
 			if !strings.Contains(w.Body.String(), "RPCMADE") {
 				t.Fatalf("Did not get expected backend error: %s\n%s", testCase.explanation, w.Body)
 			}
@@ -886,7 +1099,7 @@ func TestGetEntriesErrorFromBackend(t *testing.T) {
 
 	client := trillian.NewMockTrillianLogClient(mockCtrl)
 
-	client.EXPECT().GetLeavesByIndex(deadlineMatcher(), &trillian.GetLeavesByIndexRequest{LeafIndex: []int64{1, 2}}).Return(nil, errors.New("Bang!"))
+	client.EXPECT().GetLeavesByIndex(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLeavesByIndexRequest{LeafIndex: []int64{1, 2}}).Return(nil, errors.New("Bang!"))
 
 	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
 	handler := wrappedGetEntriesHandler(c)
@@ -900,7 +1113,7 @@ func TestGetEntriesErrorFromBackend(t *testing.T) {
 	w := httptest.NewRecorder()
 	handler.ServeHTTP(w, req)
 
-	if got, want := w.Code, http.StatusInternalServerError; got != want {
+	if got, want := w.Code, http.StatusServiceUnavailable; got != want {
 		t.Fatalf("Expected %v for backend error, got %v. Body: %v", want, got, w.Body)
 	}
 	if want, in := "Bang!", w.Body.String(); !strings.Contains(in, want) {
@@ -908,6 +1121,135 @@ func TestGetEntriesErrorFromBackend(t *testing.T) {
 	}
 }
 
+func TestGetEntriesStartBeyondTreeSize(t *testing.T) {
+	var tests = []struct {
+		treeSize   int64
+		start, end int64
+		// latestTreeSize is the size the backend reports if the cached size is checked,
+		// the cached one may be out of date
+		latestTreeSize int64
+		// forwarded means the request should reach the backend, which fails it
+		forwarded bool
+	}{
+		{0, 0, 0, 0, false},
+		{5, 5, 6, 5, false},
+		{5, 6, 7, 5, false},
+		{5, 4, 4, -1, true},
+		// The end may be beyond the tree, the backend truncates the range
+		{5, 4, 6, -1, true},
+		// The tree has grown since its size was cached
+		{5, 5, 6, 6, true},
+		{5, 6, 7, 6, false},
+	}
+
+	for _, test := range tests {
+		mockCtrl := gomock.NewController(t)
+		client := trillian.NewMockTrillianLogClient(mockCtrl)
+
+		// Requests within the cached tree size are passed on without checking it
+		if test.latestTreeSize >= 0 {
+			client.EXPECT().GetLatestSignedLogRoot(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLatestSignedLogRootRequest{}).Return(&trillian.GetLatestSignedLogRootResponse{Status: okStatus, SignedLogRoot: &trillian.SignedLogRoot{TreeSize: test.latestTreeSize}}, nil)
+		}
+
+		if test.forwarded {
+			client.EXPECT().GetLeavesByIndex(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLeavesByIndexRequest{LeafIndex: buildIndicesForRange(test.start, test.end)}).Return(nil, errors.New("Bang!"))
+		}
+
+		cache := NewSTHCache(time.Minute, fakeTimeSource)
+		cache.update(test.treeSize)
+		c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500, sthCache: cache}
+		handler := wrappedGetEntriesHandler(c)
+
+		req, err := http.NewRequest("GET", fmt.Sprintf("/ct/v1/get-entries?start=%d&end=%d", test.start, test.end), nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if test.forwarded {
+			if got, want := w.Code, http.StatusServiceUnavailable; got != want {
+				t.Fatalf("Expected %v for forwarded request %v, got %v. Body: %v", want, test, got, w.Body)
+			}
+		} else {
+			checkBeyondTreeSizeResponse(t, w, test.latestTreeSize)
+		}
+
+		mockCtrl.Finish()
+	}
+}
+
+func TestGetEntriesFetchesTreeSize(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+
+	// Nothing is cached yet so the tree size must be fetched. It's fetched again for the second
+	// request as a request is never rejected on the cached size alone.
+	client.EXPECT().GetLatestSignedLogRoot(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLatestSignedLogRootRequest{}).Return(&trillian.GetLatestSignedLogRootResponse{Status: okStatus, SignedLogRoot: &trillian.SignedLogRoot{TreeSize: 10}}, nil).Times(2)
+
+	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500, sthCache: NewSTHCache(time.Minute, fakeTimeSource)}
+	handler := wrappedGetEntriesHandler(c)
+
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest("GET", "/ct/v1/get-entries?start=12&end=13", nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		checkBeyondTreeSizeResponse(t, w, 10)
+	}
+}
+
+func TestGetEntriesFetchTreeSizeFails(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+
+	client.EXPECT().GetLatestSignedLogRoot(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLatestSignedLogRootRequest{}).Return(nil, errors.New("backendfailure"))
+
+	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500, sthCache: NewSTHCache(time.Minute, fakeTimeSource)}
+	handler := wrappedGetEntriesHandler(c)
+
+	req, err := http.NewRequest("GET", "/ct/v1/get-entries?start=1&end=2", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if got, want := w.Code, http.StatusServiceUnavailable; got != want {
+		t.Fatalf("Expected %v for backend error, got %v. Body: %v", want, got, w.Body)
+	}
+}
+
+// checkBeyondTreeSizeResponse checks that a get-entries request was rejected because it
+// started beyond the end of the tree, and that the client was told the tree size.
+func checkBeyondTreeSizeResponse(t *testing.T, w *httptest.ResponseRecorder, treeSize int64) {
+	if got, want := w.Code, http.StatusBadRequest; got != want {
+		t.Fatalf("Expected %v for start beyond tree size %d, got %v. Body: %v", want, treeSize, got, w.Body)
+	}
+
+	var httpErr ctapi.Error
+	if err := json.Unmarshal(w.Body.Bytes(), &httpErr); err != nil {
+		t.Fatalf("Failed to unmarshal json error: %s", w.Body.Bytes())
+	}
+
+	if httpErr.TreeSize == nil || *httpErr.TreeSize != treeSize {
+		t.Fatalf("Expected tree size %d in error, got: %s", treeSize, w.Body.Bytes())
+	}
+}
+
 func TestGetEntriesBackendReturnedExtraLeaves(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
@@ -915,7 +1257,7 @@ func TestGetEntriesBackendReturnedExtraLeaves(t *testing.T) {
 	client := trillian.NewMockTrillianLogClient(mockCtrl)
 
 	rpcLeaves := []*trillian.LeafProto{{LeafIndex: 1}, {LeafIndex: 2}, {LeafIndex: 3}}
-	client.EXPECT().GetLeavesByIndex(deadlineMatcher(), &trillian.GetLeavesByIndexRequest{LeafIndex: []int64{1, 2}}).Return(&trillian.GetLeavesByIndexResponse{Status: okStatus, Leaves: rpcLeaves}, nil)
+	client.EXPECT().GetLeavesByIndex(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLeavesByIndexRequest{LeafIndex: []int64{1, 2}}).Return(&trillian.GetLeavesByIndexResponse{Status: okStatus, Leaves: rpcLeaves}, nil)
 
 	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
 	handler := wrappedGetEntriesHandler(c)
@@ -944,7 +1286,7 @@ func TestGetEntriesBackendReturnedNonContiguousRange(t *testing.T) {
 	client := trillian.NewMockTrillianLogClient(mockCtrl)
 
 	rpcLeaves := []*trillian.LeafProto{{LeafIndex: 1}, {LeafIndex: 3}}
-	client.EXPECT().GetLeavesByIndex(deadlineMatcher(), &trillian.GetLeavesByIndexRequest{LeafIndex: []int64{1, 2}}).Return(&trillian.GetLeavesByIndexResponse{Status: okStatus, Leaves: rpcLeaves}, nil)
+	client.EXPECT().GetLeavesByIndex(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLeavesByIndexRequest{LeafIndex: []int64{1, 2}}).Return(&trillian.GetLeavesByIndexResponse{Status: okStatus, Leaves: rpcLeaves}, nil)
 
 	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
 	handler := wrappedGetEntriesHandler(c)
@@ -973,7 +1315,7 @@ func TestGetEntriesLeafCorrupt(t *testing.T) {
 	client := trillian.NewMockTrillianLogClient(mockCtrl)
 
 	rpcLeaves := []*trillian.LeafProto{{LeafIndex: 1, LeafHash: []byte("hash"), LeafData: []byte(invalidLeafString)}, {LeafIndex: 2, LeafHash: []byte("hash"), LeafData: []byte(invalidLeafString)}}
-	client.EXPECT().GetLeavesByIndex(deadlineMatcher(), &trillian.GetLeavesByIndexRequest{LeafIndex: []int64{1, 2}}).Return(&trillian.GetLeavesByIndexResponse{Status: okStatus, Leaves: rpcLeaves}, nil)
+	client.EXPECT().GetLeavesByIndex(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLeavesByIndexRequest{LeafIndex: []int64{1, 2}}).Return(&trillian.GetLeavesByIndexResponse{Status: okStatus, Leaves: rpcLeaves}, nil)
 
 	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
 	handler := wrappedGetEntriesHandler(c)
@@ -992,7 +1334,7 @@ func TestGetEntriesLeafCorrupt(t *testing.T) {
 		t.Fatalf("expected %v for invalid merkle leaf result, got %v. Body: %v", want, got, w.Body)
 	}
 
-	var jsonMap map[string][]getEntriesEntry
+	var jsonMap map[string][]ctapi.GetEntriesEntry
 	if err := json.Unmarshal(w.Body.Bytes(), &jsonMap); err != nil {
 		t.Fatalf("Failed to unmarshal json response: %s", w.Body.Bytes())
 	}
@@ -1039,7 +1381,7 @@ func TestGetEntries(t *testing.T) {
 	}
 
 	rpcLeaves := []*trillian.LeafProto{{LeafIndex: 1, LeafHash: []byte("hash"), LeafData: merkleBytes1, ExtraData: []byte("extra1")}, {LeafIndex: 2, LeafHash: []byte("hash"), LeafData: merkleBytes2, ExtraData: []byte("extra2")}}
-	client.EXPECT().GetLeavesByIndex(deadlineMatcher(), &trillian.GetLeavesByIndexRequest{LeafIndex: []int64{1, 2}}).Return(&trillian.GetLeavesByIndexResponse{Status: okStatus, Leaves: rpcLeaves}, nil)
+	client.EXPECT().GetLeavesByIndex(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLeavesByIndexRequest{LeafIndex: []int64{1, 2}}).Return(&trillian.GetLeavesByIndexResponse{Status: okStatus, Leaves: rpcLeaves}, nil)
 
 	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
 	handler := wrappedGetEntriesHandler(c)
@@ -1057,7 +1399,7 @@ func TestGetEntries(t *testing.T) {
 		t.Fatalf("Expected  %v for valid get-entries result, got %v. Body: %v", want, got, w.Body)
 	}
 
-	var jsonMap map[string][]getEntriesEntry
+	var jsonMap map[string][]ctapi.GetEntriesEntry
 	if err := json.Unmarshal(w.Body.Bytes(), &jsonMap); err != nil {
 		t.Fatalf("Failed to unmarshal json response: %s", w.Body.Bytes())
 	}
@@ -1091,41 +1433,32 @@ func TestGetEntries(t *testing.T) {
 	}
 }
 
-func TestGetProofByHashBadRequests(t *testing.T) {
+func TestGetEntriesOmitExtraData(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
-	// This is OK because the requests shouldn't get to the point where any RPCs are made on the mock
 	defer mockCtrl.Finish()
 
 	client := trillian.NewMockTrillianLogClient(mockCtrl)
-	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
-	handler := wrappedGetProofByHashHandler(c)
-
-	for _, requestParamString := range getProofByHashBadRequests {
-		req, err := http.NewRequest("GET", fmt.Sprintf("/ct/v1/proof-by-hash%s", requestParamString), nil)
 
-		if err != nil {
-			t.Fatal(err)
-		}
+	merkleLeaf := ct.MerkleTreeLeaf{
+		Version:          ct.V1,
+		LeafType:         ct.TimestampedEntryLeafType,
+		TimestampedEntry: ct.TimestampedEntry{Timestamp: 12345, EntryType: ct.X509LogEntryType, X509Entry: []byte("certdatacertdata"), Extensions: ct.CTExtensions{}}}
 
-		w := httptest.NewRecorder()
-		handler.ServeHTTP(w, req)
+	merkleBytes, err := leafToBytes(merkleLeaf)
 
-		if got, want := w.Code, http.StatusBadRequest; got != want {
-			t.Fatalf("Expected %v for get-proof-by-hash with params [%s], got %v. Body: %v", want, requestParamString, got, w.Body)
-		}
+	if err != nil {
+		t.Fatalf("error in test setup for get-entries: %v", err)
 	}
-}
 
-func TestGetProofByHashBackendFails(t *testing.T) {
-	mockCtrl := gomock.NewController(t)
-	defer mockCtrl.Finish()
+	// The backend is asked to leave out the extra data
+	rpcLeaves := []*trillian.LeafProto{{LeafIndex: 1, LeafHash: []byte("hash"), LeafData: merkleBytes}}
+	client.EXPECT().GetLeavesByIndex(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLeavesByIndexRequest{LeafIndex: []int64{1}, OmitExtraData: true}).Return(&trillian.GetLeavesByIndexResponse{Status: okStatus, Leaves: rpcLeaves}, nil)
 
-	client := trillian.NewMockTrillianLogClient(mockCtrl)
-	client.EXPECT().GetInclusionProofByHash(deadlineMatcher(), &trillian.GetInclusionProofByHashRequest{LeafHash: []byte("ahash"), TreeSize: 6, OrderBySequence: true}).Return(nil, errors.New("RPCFAIL"))
 	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
-	handler := wrappedGetProofByHashHandler(c)
+	WithOmitExtraData()(&c)
+	handler := wrappedGetEntriesHandler(c)
 
-	req, err := http.NewRequest("GET", "/ct/v1/proof-by-hash?tree_size=6&hash=YWhhc2g=", nil)
+	req, err := http.NewRequest("GET", "/ct/v1/get-entries?start=1&end=1&omit_extra_data=true", nil)
 
 	if err != nil {
 		t.Fatal(err)
@@ -1134,28 +1467,39 @@ func TestGetProofByHashBackendFails(t *testing.T) {
 	w := httptest.NewRecorder()
 	handler.ServeHTTP(w, req)
 
-	if got, want := w.Code, http.StatusInternalServerError; got != want {
-		t.Fatalf("Expected %v for get-proof-by-hash when backend fails, got %v. Body: %v", want, got, w.Body)
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Fatalf("Expected %v for get-entries with omit_extra_data, got %v. Body: %v", want, got, w.Body)
 	}
 
-	if !strings.Contains(w.Body.String(), "RPCFAIL") {
-		t.Fatalf("Did not get expected backend error: %s\n%s", "RPCFAIL", w.Body)
+	var jsonMap map[string][]map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &jsonMap); err != nil {
+		t.Fatalf("Failed to unmarshal json response: %s", w.Body.Bytes())
+	}
+
+	entries := jsonMap["entries"]
+	if got, want := len(entries), 1; got != want {
+		t.Fatalf("Expected %d entries in json response, got %d", want, got)
+	}
+
+	if _, ok := entries[0]["extra_data"]; ok {
+		t.Fatalf("Entry has extra_data when omit_extra_data was given: %v", entries[0])
+	}
+
+	if got, want := entries[0]["leaf_input"], base64.StdEncoding.EncodeToString(merkleBytes); got != want {
+		t.Fatalf("Got leaf_input %v, expected %v", got, want)
 	}
 }
 
-func TestGetProofByHashBackendMultipleProofs(t *testing.T) {
+func TestGetEntriesOmitExtraDataBadParam(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
-	proof1 := trillian.ProofProto{LeafIndex: 2, ProofNode: []*trillian.NodeProto{{NodeHash: []byte("abcdef")}, {NodeHash: []byte("ghijkl")}, {NodeHash: []byte("mnopqr")}}}
-	proof2 := trillian.ProofProto{LeafIndex: 2, ProofNode: []*trillian.NodeProto{{NodeHash: []byte("ghijkl")}}}
-	response := trillian.GetInclusionProofByHashResponse{Status: okStatus, Proof: []*trillian.ProofProto{&proof1, &proof2}}
 	client := trillian.NewMockTrillianLogClient(mockCtrl)
-	client.EXPECT().GetInclusionProofByHash(deadlineMatcher(), &trillian.GetInclusionProofByHashRequest{LeafHash: []byte("ahash"), TreeSize: 7, OrderBySequence: true}).Return(&response, nil)
 	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
-	handler := wrappedGetProofByHashHandler(c)
+	WithOmitExtraData()(&c)
+	handler := wrappedGetEntriesHandler(c)
 
-	req, err := http.NewRequest("GET", "/ct/v1/proof-by-hash?tree_size=7&hash=YWhhc2g=", nil)
+	req, err := http.NewRequest("GET", "/ct/v1/get-entries?start=1&end=2&omit_extra_data=maybe", nil)
 
 	if err != nil {
 		t.Fatal(err)
@@ -1164,15 +1508,93 @@ func TestGetProofByHashBackendMultipleProofs(t *testing.T) {
 	w := httptest.NewRecorder()
 	handler.ServeHTTP(w, req)
 
-	// Should be OK if backend returns multiple proofs and we should get the first one
-	if got, want := w.Code, http.StatusOK; got != want {
-		t.Fatalf("Expected %v for get-proof-by-hash (multiple), got %v. Body: %v", want, got, w.Body)
-	}
-
-	// Roundtrip the response and make sure it matches the expected one
-	var resp getProofByHashResponse
-	if err = json.NewDecoder(w.Body).Decode(&resp); err != nil {
-		t.Fatalf("failed to unmarshal json: %v, body: %v", err, w.Body.Bytes())
+	if got, want := w.Code, http.StatusBadRequest; got != want {
+		t.Fatalf("Expected %v for get-entries with invalid omit_extra_data, got %v. Body: %v", want, got, w.Body)
+	}
+}
+
+func TestGetProofByHashBadRequests(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	// This is OK because the requests shouldn't get to the point where any RPCs are made on the mock
+	defer mockCtrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
+	handler := wrappedGetProofByHashHandler(c)
+
+	for _, requestParamString := range getProofByHashBadRequests {
+		req, err := http.NewRequest("GET", fmt.Sprintf("/ct/v1/proof-by-hash%s", requestParamString), nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if got, want := w.Code, http.StatusBadRequest; got != want {
+			t.Fatalf("Expected %v for get-proof-by-hash with params [%s], got %v. Body: %v", want, requestParamString, got, w.Body)
+		}
+	}
+}
+
+func TestGetProofByHashBackendFails(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	client.EXPECT().GetInclusionProofByHash(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetInclusionProofByHashRequest{LeafHash: []byte("ahash"), TreeSize: 6, OrderBySequence: true}).Return(nil, errors.New("RPCFAIL"))
+	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
+	handler := wrappedGetProofByHashHandler(c)
+
+	req, err := http.NewRequest("GET", "/ct/v1/proof-by-hash?tree_size=6&hash=YWhhc2g=", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if got, want := w.Code, http.StatusServiceUnavailable; got != want {
+		t.Fatalf("Expected %v for get-proof-by-hash when backend fails, got %v. Body: %v", want, got, w.Body)
+	}
+
+	if !strings.Contains(w.Body.String(), "RPCFAIL") {
+		t.Fatalf("Did not get expected backend error: %s\n%s", "RPCFAIL", w.Body)
+	}
+}
+
+func TestGetProofByHashBackendMultipleProofs(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	proof1 := trillian.ProofProto{LeafIndex: 2, ProofNode: []*trillian.NodeProto{{NodeHash: []byte("abcdef")}, {NodeHash: []byte("ghijkl")}, {NodeHash: []byte("mnopqr")}}}
+	proof2 := trillian.ProofProto{LeafIndex: 2, ProofNode: []*trillian.NodeProto{{NodeHash: []byte("ghijkl")}}}
+	response := trillian.GetInclusionProofByHashResponse{Status: okStatus, Proof: []*trillian.ProofProto{&proof1, &proof2}}
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	client.EXPECT().GetInclusionProofByHash(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetInclusionProofByHashRequest{LeafHash: []byte("ahash"), TreeSize: 7, OrderBySequence: true}).Return(&response, nil)
+	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
+	handler := wrappedGetProofByHashHandler(c)
+
+	req, err := http.NewRequest("GET", "/ct/v1/proof-by-hash?tree_size=7&hash=YWhhc2g=", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// Should be OK if backend returns multiple proofs and we should get the first one
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Fatalf("Expected %v for get-proof-by-hash (multiple), got %v. Body: %v", want, got, w.Body)
+	}
+
+	// Roundtrip the response and make sure it matches the expected one
+	var resp ctapi.GetProofByHashResponse
+	if err = json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to unmarshal json: %v, body: %v", err, w.Body.Bytes())
 	}
 
 	if got, want := resp, expectedInclusionProofByHash; !reflect.DeepEqual(got, want) {
@@ -1180,18 +1602,378 @@ func TestGetProofByHashBackendMultipleProofs(t *testing.T) {
 	}
 }
 
-func TestGetProofByHashBackendReturnsMissingHash(t *testing.T) {
+func TestGetProofByHashBackendReturnsMissingHash(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	proof := trillian.ProofProto{LeafIndex: 2, ProofNode: []*trillian.NodeProto{{NodeHash: []byte("abcdef")}, {NodeHash: []byte{}}, {NodeHash: []byte("ghijkl")}}}
+	response := trillian.GetInclusionProofByHashResponse{Status: okStatus, Proof: []*trillian.ProofProto{&proof}}
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	client.EXPECT().GetInclusionProofByHash(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetInclusionProofByHashRequest{LeafHash: []byte("ahash"), TreeSize: 9, OrderBySequence: true}).Return(&response, nil)
+	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
+	handler := wrappedGetProofByHashHandler(c)
+
+	req, err := http.NewRequest("GET", "/ct/v1/proof-by-hash?tree_size=9&hash=YWhhc2g=", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if got, want := w.Code, http.StatusInternalServerError; got != want {
+		t.Fatalf("Expected %v for get-proof-by-hash when backend returns missing hash, got %v. Body: %v", want, got, w.Body)
+	}
+
+	if !strings.Contains(w.Body.String(), "invalid proof") {
+		t.Fatalf("Did not get expected backend error for invalid proof:\n%s", w.Body)
+	}
+}
+
+func TestGetProofByHash(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	proof := trillian.ProofProto{LeafIndex: 2, ProofNode: []*trillian.NodeProto{{NodeHash: []byte("abcdef")}, {NodeHash: []byte("ghijkl")}, {NodeHash: []byte("mnopqr")}}}
+	response := trillian.GetInclusionProofByHashResponse{Status: okStatus, Proof: []*trillian.ProofProto{&proof}}
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	client.EXPECT().GetInclusionProofByHash(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetInclusionProofByHashRequest{LeafHash: []byte("ahash"), TreeSize: 7, OrderBySequence: true}).Return(&response, nil)
+	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
+	handler := wrappedGetProofByHashHandler(c)
+
+	req, err := http.NewRequest("GET", "/ct/v1/proof-by-hash?tree_size=7&hash=YWhhc2g=", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Fatalf("Expected %v for get-proof-by-hash, got %v. Body: %v", want, got, w.Body)
+	}
+
+	// Roundtrip the response and make sure it matches the expected one
+	var resp ctapi.GetProofByHashResponse
+	if err = json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to unmarshal json: %v, body: %v", err, w.Body.Bytes())
+	}
+
+	if got, want := resp, expectedInclusionProofByHash; !reflect.DeepEqual(got, want) {
+		t.Fatalf("mismatched json response: expected %v got %v", want, got)
+	}
+}
+
+func TestGetProofByHashCached(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	proof := trillian.ProofProto{LeafIndex: 2, ProofNode: []*trillian.NodeProto{{NodeHash: []byte("abcdef")}, {NodeHash: []byte("ghijkl")}, {NodeHash: []byte("mnopqr")}}}
+	response := trillian.GetInclusionProofByHashResponse{Status: okStatus, Proof: []*trillian.ProofProto{&proof}}
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	// Only the first request should reach the backend
+	client.EXPECT().GetInclusionProofByHash(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetInclusionProofByHashRequest{LeafHash: []byte("ahash"), TreeSize: 7, OrderBySequence: true}).Return(&response, nil)
+	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
+	cache, err := NewProofCache(10)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	WithProofCache(cache)(&c)
+	handler := wrappedGetProofByHashHandler(c)
+
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest("GET", "/ct/v1/proof-by-hash?tree_size=7&hash=YWhhc2g=", nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Fatalf("Expected %v for get-proof-by-hash, got %v. Body: %v", want, got, w.Body)
+		}
+
+		var resp ctapi.GetProofByHashResponse
+		if err = json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to unmarshal json: %v, body: %v", err, w.Body.Bytes())
+		}
+
+		if got, want := resp, expectedInclusionProofByHash; !reflect.DeepEqual(got, want) {
+			t.Fatalf("mismatched json response: expected %v got %v", want, got)
+		}
+	}
+
+	if hits, misses := cache.Stats(); hits != 1 || misses != 1 {
+		t.Fatalf("Got %d hits %d misses, expected 1 and 1", hits, misses)
+	}
+}
+
+func TestGetProofByHashCacheFeatureOff(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	proof := trillian.ProofProto{LeafIndex: 2, ProofNode: []*trillian.NodeProto{{NodeHash: []byte("abcdef")}, {NodeHash: []byte("ghijkl")}, {NodeHash: []byte("mnopqr")}}}
+	response := trillian.GetInclusionProofByHashResponse{Status: okStatus, Proof: []*trillian.ProofProto{&proof}}
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	// With the feature off for the log every request reaches the backend
+	client.EXPECT().GetInclusionProofByHash(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetInclusionProofByHashRequest{LogId: 3, LeafHash: []byte("ahash"), TreeSize: 7, OrderBySequence: true}).Return(&response, nil).Times(2)
+	c := CTRequestHandlers{logID: 3, rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
+	cache, err := NewProofCache(10)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	features := util.NewFeatures(HandlerFeatures...)
+	features.Set(FeatureProofCache, 0, true)
+	features.Set(FeatureProofCache, 3, false)
+	WithProofCache(cache)(&c)
+	WithFeatures(features)(&c)
+	handler := wrappedGetProofByHashHandler(c)
+
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest("GET", "/ct/v1/proof-by-hash?tree_size=7&hash=YWhhc2g=", nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Fatalf("Expected %v for get-proof-by-hash, got %v. Body: %v", want, got, w.Body)
+		}
+	}
+
+	if hits, misses := cache.Stats(); hits != 0 || misses != 0 {
+		t.Fatalf("Got %d hits %d misses, expected the cache not to be used", hits, misses)
+	}
+}
+
+func TestGetProofByHashLowestIndex(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	proof1 := trillian.ProofProto{LeafIndex: 5, ProofNode: []*trillian.NodeProto{{NodeHash: []byte("ghijkl")}}}
+	proof2 := trillian.ProofProto{LeafIndex: 2, ProofNode: []*trillian.NodeProto{{NodeHash: []byte("abcdef")}, {NodeHash: []byte("ghijkl")}, {NodeHash: []byte("mnopqr")}}}
+	response := trillian.GetInclusionProofByHashResponse{Status: okStatus, Proof: []*trillian.ProofProto{&proof1, &proof2}}
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	client.EXPECT().GetInclusionProofByHash(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetInclusionProofByHashRequest{LeafHash: []byte("ahash"), TreeSize: 7, OrderBySequence: true}).Return(&response, nil)
+	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
+	handler := wrappedGetProofByHashHandler(c)
+
+	req, err := http.NewRequest("GET", "/ct/v1/proof-by-hash?tree_size=7&hash=YWhhc2g=", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Fatalf("Expected %v for get-proof-by-hash, got %v. Body: %v", want, got, w.Body)
+	}
+
+	// The proof for the lowest index should be returned even if the backend returns it last
+	var resp ctapi.GetProofByHashResponse
+	if err = json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to unmarshal json: %v, body: %v", err, w.Body.Bytes())
+	}
+
+	if got, want := resp, expectedInclusionProofByHash; !reflect.DeepEqual(got, want) {
+		t.Fatalf("mismatched json response: expected %v got %v", want, got)
+	}
+}
+
+func TestGetProofByHashNoProofs(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	response := trillian.GetInclusionProofByHashResponse{Status: okStatus}
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	client.EXPECT().GetInclusionProofByHash(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetInclusionProofByHashRequest{LeafHash: []byte("ahash"), TreeSize: 7, OrderBySequence: true}).Return(&response, nil)
+	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
+	handler := wrappedGetProofByHashHandler(c)
+
+	req, err := http.NewRequest("GET", "/ct/v1/proof-by-hash?tree_size=7&hash=YWhhc2g=", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if got, want := w.Code, http.StatusNotFound; got != want {
+		t.Fatalf("Expected %v for get-proof-by-hash with no matching leaf, got %v. Body: %v", want, got, w.Body)
+	}
+}
+
+func TestGetProofByHashAllProofs(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	proof1 := trillian.ProofProto{LeafIndex: 5, ProofNode: []*trillian.NodeProto{{NodeHash: []byte("ghijkl")}}}
+	proof2 := trillian.ProofProto{LeafIndex: 2, ProofNode: []*trillian.NodeProto{{NodeHash: []byte("abcdef")}, {NodeHash: []byte("ghijkl")}, {NodeHash: []byte("mnopqr")}}}
+	response := trillian.GetInclusionProofByHashResponse{Status: okStatus, Proof: []*trillian.ProofProto{&proof1, &proof2}}
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	client.EXPECT().GetInclusionProofByHash(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetInclusionProofByHashRequest{LeafHash: []byte("ahash"), TreeSize: 7, OrderBySequence: true}).Return(&response, nil)
+	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
+	WithAllProofs()(&c)
+	handler := wrappedGetProofByHashHandler(c)
+
+	req, err := http.NewRequest("GET", "/ct/v1/proof-by-hash?tree_size=7&hash=YWhhc2g=&all=true", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Fatalf("Expected %v for get-proof-by-hash with all, got %v. Body: %v", want, got, w.Body)
+	}
+
+	var resp ctapi.GetAllProofsByHashResponse
+	if err = json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to unmarshal json: %v, body: %v", err, w.Body.Bytes())
+	}
+
+	expected := ctapi.GetAllProofsByHashResponse{Proofs: []ctapi.GetProofByHashResponse{
+		expectedInclusionProofByHash,
+		{LeafIndex: 5, AuditPath: [][]byte{[]byte("ghijkl")}}}}
+
+	if got, want := resp, expected; !reflect.DeepEqual(got, want) {
+		t.Fatalf("mismatched json response: expected %v got %v", want, got)
+	}
+}
+
+func TestGetProofByHashAllProofsBadParam(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
+	WithAllProofs()(&c)
+	handler := wrappedGetProofByHashHandler(c)
+
+	req, err := http.NewRequest("GET", "/ct/v1/proof-by-hash?tree_size=7&hash=YWhhc2g=&all=maybe", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if got, want := w.Code, http.StatusBadRequest; got != want {
+		t.Fatalf("Expected %v for get-proof-by-hash with invalid all, got %v. Body: %v", want, got, w.Body)
+	}
+}
+
+func TestErrorResponseIsJSON(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
+	handler := wrappedGetProofByHashHandler(c)
+
+	req, err := http.NewRequest("GET", "/ct/v1/proof-by-hash?tree_size=7&hash=", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if got, want := w.Code, http.StatusBadRequest; got != want {
+		t.Fatalf("Expected %v for get-proof-by-hash, got %v. Body: %v", want, got, w.Body)
+	}
+	if got, want := w.Header().Get(contentTypeHeader), contentTypeJSON; got != want {
+		t.Fatalf("Got content type %s for error, expected %s", got, want)
+	}
+
+	var resp ctapi.Error
+	if err = json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to unmarshal json: %v, body: %v", err, w.Body.Bytes())
+	}
+
+	if got, want := resp.ErrorCode, ctapi.ErrBadRequest; got != want {
+		t.Fatalf("Got error code %v, expected %v", got, want)
+	}
+	if want, in := "missing / empty hash", resp.Message; !strings.Contains(in, want) {
+		t.Fatalf("Expected to find %s within %s", want, in)
+	}
+	if got, want := resp.RequestID, w.Header().Get(requestIDHeader); len(got) == 0 || got != want {
+		t.Fatalf("Got request ID %q in error, expected %q from header", got, want)
+	}
+}
+
+func TestRequestIDSentToBackend(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
-	proof := trillian.ProofProto{LeafIndex: 2, ProofNode: []*trillian.NodeProto{{NodeHash: []byte("abcdef")}, {NodeHash: []byte{}}, {NodeHash: []byte("ghijkl")}}}
-	response := trillian.GetInclusionProofByHashResponse{Status: okStatus, Proof: []*trillian.ProofProto{&proof}}
 	client := trillian.NewMockTrillianLogClient(mockCtrl)
-	client.EXPECT().GetInclusionProofByHash(deadlineMatcher(), &trillian.GetInclusionProofByHashRequest{LeafHash: []byte("ahash"), TreeSize: 9, OrderBySequence: true}).Return(&response, nil)
-	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
-	handler := wrappedGetProofByHashHandler(c)
 
-	req, err := http.NewRequest("GET", "/ct/v1/proof-by-hash?tree_size=9&hash=YWhhc2g=", nil)
+	var sentRequestID, sentPriority string
+	client.EXPECT().GetLatestSignedLogRoot(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLatestSignedLogRootRequest{LogId: 0x42}).Do(func(ctx context.Context, req *trillian.GetLatestSignedLogRootRequest) {
+		if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md[util.RequestIDMetadataKey]) == 1 {
+			sentRequestID = md[util.RequestIDMetadataKey][0]
+		}
+		if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md[util.RequestPriorityMetadataKey]) == 1 {
+			sentPriority = md[util.RequestPriorityMetadataKey][0]
+		}
+	}).Return(nil, errors.New("backendfailure"))
+	c := CTRequestHandlers{logID: 0x42, rpcClient: client, rpcDeadline: time.Millisecond * 500, timeSource: fakeTimeSource}
+	handler := wrappedGetSTHHandler(c)
+
+	req, err := http.NewRequest("GET", "http://example.com/ct/v1/get-sth", nil)
+	if err != nil {
+		t.Fatalf("get-sth test request setup failed: %v", err)
+	}
+
+	// A client supplied ID must not be used
+	req.Header.Set(requestIDHeader, "fromclient")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	requestID := w.Header().Get(requestIDHeader)
+
+	if len(requestID) == 0 || requestID == "fromclient" {
+		t.Fatalf("Got request ID %q in response header, expected a new one", requestID)
+	}
+	if got, want := sentRequestID, requestID; got != want {
+		t.Fatalf("Got request ID %q sent to backend, expected %q", got, want)
+	}
+	// The priority must be sent along with the ID
+	if got, want := sentPriority, util.PriorityProof.String(); got != want {
+		t.Fatalf("Got priority %q sent to backend, expected %q", got, want)
+	}
+}
+
+func TestGetLogMetadata(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	metadata := trillian.TreeMetadata{DisplayName: "Test log", Description: "A log for testing", OwnerContact: "owner@example.com", CreateTimeNanos: 1469185273000000000}
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	client.EXPECT().GetTreeMetadata(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetTreeMetadataRequest{LogId: 7}).Return(&trillian.GetTreeMetadataResponse{Status: okStatus, Metadata: &metadata}, nil)
+	c := CTRequestHandlers{logID: 7, rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
+	handler := wrappedGetLogMetadataHandler(c)
+
+	req, err := http.NewRequest("GET", "/ct/v1/get-log-metadata", nil)
 
 	if err != nil {
 		t.Fatal(err)
@@ -1200,27 +1982,75 @@ func TestGetProofByHashBackendReturnsMissingHash(t *testing.T) {
 	w := httptest.NewRecorder()
 	handler.ServeHTTP(w, req)
 
-	if got, want := w.Code, http.StatusInternalServerError; got != want {
-		t.Fatalf("Expected %v for get-proof-by-hash when backend returns missing hash, got %v. Body: %v", want, got, w.Body)
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Fatalf("Expected %v for get-log-metadata, got %v. Body: %v", want, got, w.Body)
 	}
 
-	if !strings.Contains(w.Body.String(), "invalid proof") {
-		t.Fatalf("Did not get expected backend error for invalid proof:\n%s", w.Body)
+	var resp ctapi.GetLogMetadataResponse
+	if err = json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to unmarshal json: %v, body: %v", err, w.Body.Bytes())
+	}
+
+	want := ctapi.GetLogMetadataResponse{DisplayName: "Test log", Description: "A log for testing", OwnerContact: "owner@example.com", CreateTimeMillis: 1469185273000}
+
+	if !reflect.DeepEqual(resp, want) {
+		t.Fatalf("Got get-log-metadata response %+v, expected %+v", resp, want)
 	}
 }
 
-func TestGetProofByHash(t *testing.T) {
+func TestGetLogMetadataBackendFails(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
-	proof := trillian.ProofProto{LeafIndex: 2, ProofNode: []*trillian.NodeProto{{NodeHash: []byte("abcdef")}, {NodeHash: []byte("ghijkl")}, {NodeHash: []byte("mnopqr")}}}
-	response := trillian.GetInclusionProofByHashResponse{Status: okStatus, Proof: []*trillian.ProofProto{&proof}}
 	client := trillian.NewMockTrillianLogClient(mockCtrl)
-	client.EXPECT().GetInclusionProofByHash(deadlineMatcher(), &trillian.GetInclusionProofByHashRequest{LeafHash: []byte("ahash"), TreeSize: 7, OrderBySequence: true}).Return(&response, nil)
+	client.EXPECT().GetTreeMetadata(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetTreeMetadataRequest{}).Return(nil, errors.New("RPCFAIL"))
+	client.EXPECT().GetTreeMetadata(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetTreeMetadataRequest{}).Return(&trillian.GetTreeMetadataResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_ERROR}}, nil)
+	client.EXPECT().GetTreeMetadata(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetTreeMetadataRequest{}).Return(&trillian.GetTreeMetadataResponse{Status: okStatus}, nil)
 	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
-	handler := wrappedGetProofByHashHandler(c)
+	handler := wrappedGetLogMetadataHandler(c)
 
-	req, err := http.NewRequest("GET", "/ct/v1/proof-by-hash?tree_size=7&hash=YWhhc2g=", nil)
+	for i := 0; i < 3; i++ {
+		req, err := http.NewRequest("GET", "/ct/v1/get-log-metadata", nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if got, want := w.Code, http.StatusInternalServerError; got != want {
+			t.Fatalf("Expected %v for get-log-metadata when backend fails, got %v. Body: %v", want, got, w.Body)
+		}
+	}
+}
+
+func TestGetSTHAndEntries(t *testing.T) {
+	toSign := []byte{0x1e, 0x88, 0x54, 0x6f, 0x51, 0x57, 0xbf, 0xaf, 0x77, 0xca, 0x24, 0x54, 0x69, 0xb, 0x60, 0x26, 0x31, 0xfe, 0xda, 0xe9, 0x25, 0xbb, 0xe7, 0xcf, 0x70, 0x8e, 0xa2, 0x75, 0x97, 0x5b, 0xfe, 0x74}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	merkleLeaf := ct.MerkleTreeLeaf{
+		Version:          ct.V1,
+		LeafType:         ct.TimestampedEntryLeafType,
+		TimestampedEntry: ct.TimestampedEntry{Timestamp: 12345, EntryType: ct.X509LogEntryType, X509Entry: []byte("certdatacertdata"), Extensions: ct.CTExtensions{}}}
+	merkleBytes, err := leafToBytes(merkleLeaf)
+
+	if err != nil {
+		t.Fatalf("error in test setup for get-sth-and-entries: %v", err)
+	}
+
+	// The tree has 25 leaves so the last two are 23 and 24
+	rpcLeaves := []*trillian.LeafProto{{LeafIndex: 23, LeafHash: []byte("hash"), LeafData: merkleBytes, ExtraData: []byte("extra23")}, {LeafIndex: 24, LeafHash: []byte("hash"), LeafData: merkleBytes, ExtraData: []byte("extra24")}}
+	rootResponse := ttestonly.GetRootResponse(12345000000, 25, []byte("abcdabcdabcdabcdabcdabcdabcdabcd"))
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	client.EXPECT().GetLatestSignedLogRootAndLeaves(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLatestSignedLogRootAndLeavesRequest{LogId: 0x42, MaxLeaves: 2}).Return(&trillian.GetLatestSignedLogRootAndLeavesResponse{Status: okStatus, SignedLogRoot: rootResponse.SignedLogRoot, Leaves: rpcLeaves}, nil)
+	km := setupMockKeyManagerForSth(mockCtrl, toSign)
+
+	c := CTRequestHandlers{logID: 0x42, rpcClient: client, logKeyManager: km, rpcDeadline: time.Millisecond * 500, timeSource: fakeTimeSource}
+	handler := wrappedGetSTHAndEntriesHandler(c)
+
+	req, err := http.NewRequest("GET", "/ct/v1/get-sth-and-entries?count=2", nil)
 
 	if err != nil {
 		t.Fatal(err)
@@ -1230,17 +2060,95 @@ func TestGetProofByHash(t *testing.T) {
 	handler.ServeHTTP(w, req)
 
 	if got, want := w.Code, http.StatusOK; got != want {
-		t.Fatalf("Expected %v for get-proof-by-hash, got %v. Body: %v", want, got, w.Body)
+		t.Fatalf("Expected %v for get-sth-and-entries, got %v. Body: %v", want, got, w.Body)
 	}
 
-	// Roundtrip the response and make sure it matches the expected one
-	var resp getProofByHashResponse
+	var resp ctapi.GetSTHAndEntriesResponse
 	if err = json.NewDecoder(w.Body).Decode(&resp); err != nil {
 		t.Fatalf("failed to unmarshal json: %v, body: %v", err, w.Body.Bytes())
 	}
 
-	if got, want := resp, expectedInclusionProofByHash; !reflect.DeepEqual(got, want) {
-		t.Fatalf("mismatched json response: expected %v got %v", want, got)
+	if got, want := resp.STH.TreeSize, int64(25); got != want {
+		t.Fatalf("Got treesize %d, expected %d", got, want)
+	}
+	if got, want := base64.StdEncoding.EncodeToString(resp.STH.Signature), "c2lnbmVk"; got != want {
+		t.Fatalf("Got signature %s, expected %s", got, want)
+	}
+	if got, want := len(resp.Entries), 2; got != want {
+		t.Fatalf("Got %d entries, expected %d", got, want)
+	}
+
+	for i, entry := range resp.Entries {
+		if !bytes.Equal(entry.LeafInput, merkleBytes) || !bytes.Equal(entry.ExtraData, rpcLeaves[i].ExtraData) {
+			t.Errorf("Got entry %d %v, expected leaf %v", i, entry, rpcLeaves[i])
+		}
+	}
+}
+
+func TestGetSTHAndEntriesBadRequestsAndResponses(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	rootResponse := ttestonly.GetRootResponse(12345000000, 25, []byte("abcdabcdabcdabcdabcdabcdabcdabcd"))
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	c := CTRequestHandlers{logID: 0x42, rpcClient: client, rpcDeadline: time.Millisecond * 500, timeSource: fakeTimeSource}
+	handler := wrappedGetSTHAndEntriesHandler(c)
+
+	for _, test := range []struct {
+		desc   string
+		query  string
+		leaves []*trillian.LeafProto
+		want   int
+	}{
+		{"no count", "", nil, http.StatusBadRequest},
+		{"zero count", "count=0", nil, http.StatusBadRequest},
+		{"too many", fmt.Sprintf("count=%d", maxGetEntriesAllowed+1), nil, http.StatusBadRequest},
+		{"too few leaves", "count=2", []*trillian.LeafProto{{LeafIndex: 24}}, http.StatusInternalServerError},
+		{"leaves not at the end of the tree", "count=2", []*trillian.LeafProto{{LeafIndex: 22}, {LeafIndex: 23}}, http.StatusInternalServerError},
+		{"leaves out of order", "count=2", []*trillian.LeafProto{{LeafIndex: 24}, {LeafIndex: 23}}, http.StatusInternalServerError},
+	} {
+		if test.want != http.StatusBadRequest {
+			client.EXPECT().GetLatestSignedLogRootAndLeaves(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLatestSignedLogRootAndLeavesRequest{LogId: 0x42, MaxLeaves: 2}).Return(&trillian.GetLatestSignedLogRootAndLeavesResponse{Status: okStatus, SignedLogRoot: rootResponse.SignedLogRoot, Leaves: test.leaves}, nil)
+		}
+
+		req, err := http.NewRequest("GET", "/ct/v1/get-sth-and-entries?"+test.query, nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if got := w.Code; got != test.want {
+			t.Errorf("%s: expected %v for get-sth-and-entries, got %v. Body: %v", test.desc, test.want, got, w.Body)
+		}
+	}
+}
+
+func TestGetOpenAPISpec(t *testing.T) {
+	handler := wrappedGetOpenAPIHandler("/ct/v1")
+
+	req, err := http.NewRequest("GET", "/ct/v1/openapi.json", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Fatalf("Expected %v for openapi.json, got %v. Body: %v", want, got, w.Body)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("failed to unmarshal json: %v, body: %v", err, w.Body.Bytes())
+	}
+
+	if got, want := doc["basePath"], "/ct/v1"; got != want {
+		t.Fatalf("Got base path %v, expected %v", got, want)
 	}
 }
 
@@ -1299,7 +2207,7 @@ func TestGetSTHConsistencyBackendRPCFails(t *testing.T) {
 	defer mockCtrl.Finish()
 
 	client := trillian.NewMockTrillianLogClient(mockCtrl)
-	client.EXPECT().GetConsistencyProof(deadlineMatcher(), &trillian.GetConsistencyProofRequest{FirstTreeSize: 10, SecondTreeSize: 20}).Return(nil, errors.New("RPCFAIL"))
+	client.EXPECT().GetConsistencyProof(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetConsistencyProofRequest{FirstTreeSize: 10, SecondTreeSize: 20}).Return(nil, errors.New("RPCFAIL"))
 	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
 	handler := wrappedGetSTHConsistencyHandler(c)
 
@@ -1312,7 +2220,7 @@ func TestGetSTHConsistencyBackendRPCFails(t *testing.T) {
 	w := httptest.NewRecorder()
 	handler.ServeHTTP(w, req)
 
-	if got, want := w.Code, http.StatusInternalServerError; got != want {
+	if got, want := w.Code, http.StatusServiceUnavailable; got != want {
 		t.Fatalf("Expected %v for get-sth-consistency when backend fails, got %v. Body: %v", want, got, w.Body)
 	}
 
@@ -1326,7 +2234,8 @@ func TestGetEntryAndProofBackendFails(t *testing.T) {
 	defer mockCtrl.Finish()
 
 	client := trillian.NewMockTrillianLogClient(mockCtrl)
-	client.EXPECT().GetEntryAndProof(deadlineMatcher(), &trillian.GetEntryAndProofRequest{LeafIndex: 1, TreeSize: 3}).Return(nil, errors.New("RPCFAIL"))
+	client.EXPECT().GetEntryAndProof(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetEntryAndProofRequest{LeafIndex: 1, TreeSize: 3}).Return(nil, errors.New("RPCFAIL"))
+	client.EXPECT().GetLeavesByIndex(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLeavesByIndexRequest{LeafIndex: []int64{1}}).Return(nil, errors.New("FALLBACKFAIL"))
 	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
 	handler := wrappedGetEntryAndProofHandler(c)
 
@@ -1343,8 +2252,10 @@ func TestGetEntryAndProofBackendFails(t *testing.T) {
 		t.Fatalf("Expected %v for get-entry-and-proof when backend fails, got %v. Body: %v", want, got, w.Body)
 	}
 
-	if !strings.Contains(w.Body.String(), "RPCFAIL") {
-		t.Fatalf("Did not get expected backend error: %s\n%s", "RPCFAIL", w.Body)
+	for _, want := range []string{"RPCFAIL", "FALLBACKFAIL"} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Fatalf("Did not get expected backend error: %s\n%s", want, w.Body)
+		}
 	}
 }
 
@@ -1355,7 +2266,7 @@ func TestGetSTHConsistencyBackendReturnsInvalidProof(t *testing.T) {
 	proof := trillian.ProofProto{LeafIndex: 2, ProofNode: []*trillian.NodeProto{{NodeHash: []byte("abcdef")}, {NodeHash: []byte{}}, {NodeHash: []byte("ghijkl")}}}
 	response := trillian.GetConsistencyProofResponse{Status: okStatus, Proof: &proof}
 	client := trillian.NewMockTrillianLogClient(mockCtrl)
-	client.EXPECT().GetConsistencyProof(deadlineMatcher(), &trillian.GetConsistencyProofRequest{FirstTreeSize: 10, SecondTreeSize: 20}).Return(&response, nil)
+	client.EXPECT().GetConsistencyProof(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetConsistencyProofRequest{FirstTreeSize: 10, SecondTreeSize: 20}).Return(&response, nil)
 	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
 	handler := wrappedGetSTHConsistencyHandler(c)
 
@@ -1384,7 +2295,8 @@ func TestGetEntryAndProofBackendBadResponse(t *testing.T) {
 	// Omit the result data from the backend response, should cause the request to fail
 	response := trillian.GetEntryAndProofResponse{Status: okStatus}
 	client := trillian.NewMockTrillianLogClient(mockCtrl)
-	client.EXPECT().GetEntryAndProof(deadlineMatcher(), &trillian.GetEntryAndProofRequest{LeafIndex: 1, TreeSize: 3}).Return(&response, nil)
+	client.EXPECT().GetEntryAndProof(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetEntryAndProofRequest{LeafIndex: 1, TreeSize: 3}).Return(&response, nil)
+	client.EXPECT().GetLeavesByIndex(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLeavesByIndexRequest{LeafIndex: []int64{1}}).Return(&trillian.GetLeavesByIndexResponse{Status: okStatus}, nil)
 	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
 	handler := wrappedGetEntryAndProofHandler(c)
 
@@ -1409,7 +2321,7 @@ func TestGetSTHConsistency(t *testing.T) {
 	proof := trillian.ProofProto{LeafIndex: 2, ProofNode: []*trillian.NodeProto{{NodeHash: []byte("abcdef")}, {NodeHash: []byte("ghijkl")}, {NodeHash: []byte("mnopqr")}}}
 	response := trillian.GetConsistencyProofResponse{Status: okStatus, Proof: &proof}
 	client := trillian.NewMockTrillianLogClient(mockCtrl)
-	client.EXPECT().GetConsistencyProof(deadlineMatcher(), &trillian.GetConsistencyProofRequest{FirstTreeSize: 10, SecondTreeSize: 20}).Return(&response, nil)
+	client.EXPECT().GetConsistencyProof(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetConsistencyProofRequest{FirstTreeSize: 10, SecondTreeSize: 20}).Return(&response, nil)
 	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
 	handler := wrappedGetSTHConsistencyHandler(c)
 
@@ -1427,7 +2339,7 @@ func TestGetSTHConsistency(t *testing.T) {
 	}
 
 	// Roundtrip the response and make sure it matches
-	var resp getSTHConsistencyResponse
+	var resp ctapi.GetSTHConsistencyResponse
 
 	if err = json.NewDecoder(w.Body).Decode(&resp); err != nil {
 		t.Fatalf("failed to unmarshal json: %v, body: %v", err, w.Body.Bytes())
@@ -1457,7 +2369,7 @@ func TestGetEntryAndProof(t *testing.T) {
 	leafProto := trillian.LeafProto{LeafData: leafBytes, LeafHash: []byte("ahash"), ExtraData: []byte("extra")}
 	response := trillian.GetEntryAndProofResponse{Status: okStatus, Proof: &proof, Leaf: &leafProto}
 	client := trillian.NewMockTrillianLogClient(mockCtrl)
-	client.EXPECT().GetEntryAndProof(deadlineMatcher(), &trillian.GetEntryAndProofRequest{LeafIndex: 1, TreeSize: 3}).Return(&response, nil)
+	client.EXPECT().GetEntryAndProof(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetEntryAndProofRequest{LeafIndex: 1, TreeSize: 3}).Return(&response, nil)
 	c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
 	handler := wrappedGetEntryAndProofHandler(c)
 
@@ -1475,13 +2387,13 @@ func TestGetEntryAndProof(t *testing.T) {
 	}
 
 	// Roundtrip the response and make sure it matches what we expect
-	var resp getEntryAndProofResponse
+	var resp ctapi.GetEntryAndProofResponse
 	if err = json.NewDecoder(w.Body).Decode(&resp); err != nil {
 		t.Fatalf("failed to unmarshal json: %v, body: %v", err, w.Body.Bytes())
 	}
 
 	// The result we expect after a roundtrip in the successful get entry and proof test
-	expectedGetEntryAndProofResponse := getEntryAndProofResponse{
+	expectedGetEntryAndProofResponse := ctapi.GetEntryAndProofResponse{
 		LeafInput: leafBytes,
 		ExtraData: []byte("extra"),
 		AuditPath: [][]byte{[]byte("abcdef"), []byte("ghijkl"), []byte("mnopqr")}}
@@ -1491,25 +2403,110 @@ func TestGetEntryAndProof(t *testing.T) {
 	}
 }
 
-func createJsonChain(t *testing.T, p PEMCertPool) io.Reader {
-	var chain jsonChain
+func TestGetEntryAndProofFallback(t *testing.T) {
+	proof := trillian.ProofProto{LeafIndex: 1, ProofNode: []*trillian.NodeProto{{NodeHash: []byte("abcdef")}, {NodeHash: []byte("ghijkl")}}}
+	leafProto := trillian.LeafProto{LeafIndex: 1, LeafData: []byte("leafdata"), LeafHash: []byte("ahash"), ExtraData: []byte("extra")}
+	proofResponse := trillian.GetInclusionProofResponse{Status: okStatus, Proof: &proof}
+	leavesResponse := trillian.GetLeavesByIndexResponse{Status: okStatus, Leaves: []*trillian.LeafProto{&leafProto}}
+
+	for _, test := range []struct {
+		description string
+		response    *trillian.GetEntryAndProofResponse
+		err         error
+	}{
+		{"combined RPC fails", nil, errors.New("unimplemented")},
+		{"combined RPC status not OK", &trillian.GetEntryAndProofResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_ERROR}}, nil},
+		{"combined RPC has no proof", &trillian.GetEntryAndProofResponse{Status: okStatus, Leaf: &leafProto}, nil},
+	} {
+		mockCtrl := gomock.NewController(t)
 
-	for _, rawCert := range p.RawCertificates() {
-		b64 := base64.StdEncoding.EncodeToString(rawCert.Raw)
-		chain.Chain = append(chain.Chain, b64)
-	}
+		client := trillian.NewMockTrillianLogClient(mockCtrl)
+		client.EXPECT().GetEntryAndProof(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetEntryAndProofRequest{LeafIndex: 1, TreeSize: 3}).Return(test.response, test.err)
+		client.EXPECT().GetLeavesByIndex(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLeavesByIndexRequest{LeafIndex: []int64{1}}).Return(&leavesResponse, nil)
+		client.EXPECT().GetInclusionProof(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetInclusionProofRequest{LeafIndex: 1, TreeSize: 3}).Return(&proofResponse, nil)
+		c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
+		handler := wrappedGetEntryAndProofHandler(c)
 
-	var buffer bytes.Buffer
-	// It's tempting to avoid creating and flushing the intermediate writer but it doesn't work
-	writer := bufio.NewWriter(&buffer)
-	err := json.NewEncoder(writer).Encode(&chain)
-	writer.Flush()
+		req, err := http.NewRequest("GET", "/ct/v1/get-entry-and-proof?leaf_index=1&tree_size=3", nil)
 
-	if err != nil {
-		t.Fatalf("Failed to create test json: %v", err)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Fatalf("%s: expected %v for get-entry-and-proof, got %v. Body: %v", test.description, want, got, w.Body)
+		}
+
+		var resp ctapi.GetEntryAndProofResponse
+		if err = json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("%s: failed to unmarshal json: %v, body: %v", test.description, err, w.Body.Bytes())
+		}
+
+		expected := ctapi.GetEntryAndProofResponse{
+			LeafInput: []byte("leafdata"),
+			ExtraData: []byte("extra"),
+			AuditPath: [][]byte{[]byte("abcdef"), []byte("ghijkl")}}
+
+		if got, want := resp, expected; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: mismatched json response: expected %v got %v", test.description, want, got)
+		}
+
+		mockCtrl.Finish()
 	}
+}
+
+func TestGetEntryAndProofFallbackFails(t *testing.T) {
+	proof := trillian.ProofProto{LeafIndex: 1, ProofNode: []*trillian.NodeProto{{NodeHash: []byte("abcdef")}}}
+	leafProto := trillian.LeafProto{LeafIndex: 1, LeafData: []byte("leafdata")}
+	wrongLeafProto := trillian.LeafProto{LeafIndex: 2, LeafData: []byte("leafdata")}
+
+	for _, test := range []struct {
+		description   string
+		leaves        *trillian.GetLeavesByIndexResponse
+		proof         *trillian.GetInclusionProofResponse
+		proofErr      error
+		errorContains string
+	}{
+		{description: "wrong leaf", leaves: &trillian.GetLeavesByIndexResponse{Status: okStatus, Leaves: []*trillian.LeafProto{&wrongLeafProto}}, errorContains: "returned leaf 2"},
+		{description: "proof RPC fails", leaves: &trillian.GetLeavesByIndexResponse{Status: okStatus, Leaves: []*trillian.LeafProto{&leafProto}}, proofErr: errors.New("PROOFFAIL"), errorContains: "PROOFFAIL"},
+		{description: "no proof", leaves: &trillian.GetLeavesByIndexResponse{Status: okStatus, Leaves: []*trillian.LeafProto{&leafProto}}, proof: &trillian.GetInclusionProofResponse{Status: okStatus}, errorContains: "incomplete"},
+		{description: "proof has wrong status", leaves: &trillian.GetLeavesByIndexResponse{Status: okStatus, Leaves: []*trillian.LeafProto{&leafProto}}, proof: &trillian.GetInclusionProofResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_ERROR}, Proof: &proof}, errorContains: "GetInclusionProof returned status"},
+	} {
+		mockCtrl := gomock.NewController(t)
+
+		client := trillian.NewMockTrillianLogClient(mockCtrl)
+		client.EXPECT().GetEntryAndProof(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetEntryAndProofRequest{LeafIndex: 1, TreeSize: 3}).Return(nil, errors.New("RPCFAIL"))
+		client.EXPECT().GetLeavesByIndex(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLeavesByIndexRequest{LeafIndex: []int64{1}}).Return(test.leaves, nil)
+
+		if test.proof != nil || test.proofErr != nil {
+			client.EXPECT().GetInclusionProof(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetInclusionProofRequest{LeafIndex: 1, TreeSize: 3}).Return(test.proof, test.proofErr)
+		}
+
+		c := CTRequestHandlers{rpcClient: client, timeSource: fakeTimeSource, rpcDeadline: time.Millisecond * 500}
+		handler := wrappedGetEntryAndProofHandler(c)
+
+		req, err := http.NewRequest("GET", "/ct/v1/get-entry-and-proof?leaf_index=1&tree_size=3", nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if got, want := w.Code, http.StatusInternalServerError; got != want {
+			t.Errorf("%s: expected %v for get-entry-and-proof, got %v. Body: %v", test.description, want, got, w.Body)
+		}
+
+		if !strings.Contains(w.Body.String(), test.errorContains) {
+			t.Errorf("%s: did not get expected error: %s\n%s", test.description, test.errorContains, w.Body)
+		}
 
-	return bufio.NewReader(&buffer)
+		mockCtrl.Finish()
+	}
 }
 
 func leafProtosForCert(t *testing.T, km crypto.KeyManager, certs []*x509.Certificate, merkleLeaf ct.MerkleTreeLeaf) []*trillian.LeafProto {
@@ -1518,8 +2515,8 @@ func leafProtosForCert(t *testing.T, km crypto.KeyManager, certs []*x509.Certifi
 		t.Fatalf("failed to serialize leaf: %v", err)
 	}
 
-	// This is a hash of the leaf data, not the the Merkle hash as defined in the RFC.
-	leafHash := sha256.Sum256(b.Bytes())
+	// This is the Merkle leaf hash as defined in the RFC.
+	leafHash := merkle.NewRFC6962TreeHasher(trillian.NewSHA256()).HashLeaf(b.Bytes())
 	logEntry := NewCTLogEntry(merkleLeaf, certs)
 
 	var b2 bytes.Buffer
@@ -1527,33 +2524,7 @@ func leafProtosForCert(t *testing.T, km crypto.KeyManager, certs []*x509.Certifi
 		t.Fatalf("failed to serialize log entry: %v", err)
 	}
 
-	return []*trillian.LeafProto{{LeafHash: leafHash[:], LeafData: b.Bytes(), ExtraData: b2.Bytes()}}
-}
-
-type dlMatcher struct {
-}
-
-func deadlineMatcher() gomock.Matcher {
-	return dlMatcher{}
-}
-
-func (d dlMatcher) Matches(x interface{}) bool {
-	ctx, ok := x.(context.Context)
-	if !ok {
-		return false
-	}
-
-	deadlineTime, ok := ctx.Deadline()
-
-	if !ok {
-		return false // we never make RPC calls without a deadline set
-	}
-
-	return deadlineTime == fakeDeadlineTime
-}
-
-func (d dlMatcher) String() string {
-	return fmt.Sprintf("deadline is %v", fakeDeadlineTime)
+	return []*trillian.LeafProto{{LeafHash: leafHash, LeafData: b.Bytes(), ExtraData: b2.Bytes()}}
 }
 
 func makeAddPrechainRequest(t *testing.T, reqHandlers CTRequestHandlers, body io.Reader) *httptest.ResponseRecorder {
@@ -1619,10 +2590,138 @@ func bytesToLeaf(leafBytes []byte) (*ct.MerkleTreeLeaf, error) {
 	return ct.ReadMerkleTreeLeaf(buf)
 }
 
-func makeGetRootResponseForTest(stamp, treeSize int64, hash []byte) *trillian.GetLatestSignedLogRootResponse {
-	return &trillian.GetLatestSignedLogRootResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK},
-		SignedLogRoot: &trillian.SignedLogRoot{
-			TimestampNanos: stamp,
-			TreeSize:       treeSize,
-			RootHash:       hash}}
+func TestNewCTRequestHandlersOptions(t *testing.T) {
+	c := NewCTRequestHandlers(0x42, nil, nil, nil)
+
+	if got, want := c.rpcDeadline, defaultRPCDeadline; got != want {
+		t.Errorf("Got default deadline %v, expected %v", got, want)
+	}
+
+	if c.timeSource == nil || c.allProofs || len(c.pathPrefix) > 0 {
+		t.Errorf("Unexpected defaults: %+v", c)
+	}
+
+	cache := NewSTHCache(time.Minute, fakeTimeSource)
+	c = NewCTRequestHandlers(0x42, nil, nil, nil, WithRPCDeadline(time.Second), WithTimeSource(fakeTimeSource), WithAllProofs(), WithPathPrefix("pilot"), WithSTHCache(cache))
+
+	if c.rpcDeadline != time.Second || c.timeSource != fakeTimeSource || !c.allProofs || c.pathPrefix != "pilot" || c.sthCache != cache {
+		t.Errorf("Options not applied: %+v", c)
+	}
+}
+
+func TestRegisterHandlers(t *testing.T) {
+	mux := http.NewServeMux()
+	NewCTRequestHandlers(0x42, nil, nil, nil, WithPathPrefix("pilot"), WithReadinessGating(NewLogReadiness())).RegisterHandlers(mux)
+
+	for _, path := range []string{"/pilot/ct/v1/add-chain", "/pilot/ct/v1/get-sth", "/pilot/ct/v1/get-entry-and-proof", "/pilot/ct/v1/get-roots.pem", "/pilot/ct/v1/get-roots.p7c", "/pilot/ready"} {
+		req, err := http.NewRequest(httpMethodGet, "http://example.com"+path, nil)
+
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+
+		if _, pattern := mux.Handler(req); pattern != path {
+			t.Errorf("Path %s is handled by pattern %q", path, pattern)
+		}
+	}
+
+	// Nothing is registered outside the prefix or on the default mux
+	for _, m := range []*http.ServeMux{mux, http.DefaultServeMux} {
+		req, _ := http.NewRequest(httpMethodGet, "http://example.com/ct/v1/get-sth", nil)
+
+		if _, pattern := m.Handler(req); len(pattern) > 0 {
+			t.Errorf("Unprefixed path is handled by pattern %q", pattern)
+		}
+	}
+}
+
+func TestPathPrefix(t *testing.T) {
+	c := CTRequestHandlers{}
+
+	if got, want := c.prefixed(pathFor("get-sth")), "/ct/v1/get-sth"; got != want {
+		t.Errorf("Got path %s without a prefix, expected %s", got, want)
+	}
+
+	WithPathPrefix("pilot")(&c)
+
+	if got, want := c.prefixed(pathFor("get-sth")), "/pilot/ct/v1/get-sth"; got != want {
+		t.Errorf("Got path %s with a prefix, expected %s", got, want)
+	}
+
+	for _, base := range []string{"logs/eu", "/logs/eu", "/logs/eu/"} {
+		WithBasePath(base)(&c)
+
+		if got, want := c.prefixed(pathFor("get-sth")), "/logs/eu/pilot/ct/v1/get-sth"; got != want {
+			t.Errorf("Got path %s with base path %q, expected %s", got, want, base)
+		}
+	}
+
+	WithPathPrefix("")(&c)
+
+	if got, want := c.prefixed(pathFor("get-sth")), "/logs/eu/ct/v1/get-sth"; got != want {
+		t.Errorf("Got path %s with only a base path, expected %s", got, want)
+	}
+
+	WithBasePath("/")(&c)
+
+	if got, want := c.prefixed(pathFor("get-sth")), "/ct/v1/get-sth"; got != want {
+		t.Errorf("Got path %s with a root base path, expected %s", got, want)
+	}
+}
+
+func TestRegisterHandlersBasePath(t *testing.T) {
+	mux := http.NewServeMux()
+	NewCTRequestHandlers(0x42, nil, nil, nil, WithBasePath("/logs"), WithPathPrefix("2017/pilot"), WithReadinessGating(NewLogReadiness())).RegisterHandlers(mux)
+
+	for _, path := range []string{"/logs/2017/pilot/ct/v1/add-chain", "/logs/2017/pilot/ct/v1/get-sth", "/logs/2017/pilot/ready"} {
+		req, err := http.NewRequest(httpMethodGet, "http://example.com"+path, nil)
+
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+
+		if _, pattern := mux.Handler(req); pattern != path {
+			t.Errorf("Path %s is handled by pattern %q", path, pattern)
+		}
+	}
+
+	// The OpenAPI spec must describe the paths the endpoints are really served on
+	req, _ := http.NewRequest(httpMethodGet, "http://example.com/logs/2017/pilot/ct/v1/openapi.json", nil)
+	handler, _ := mux.Handler(req)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("failed to unmarshal json: %v, body: %v", err, w.Body.Bytes())
+	}
+
+	if got, want := doc["basePath"], "/logs/2017/pilot/ct/v1"; got != want {
+		t.Errorf("Got base path %v, expected %v", got, want)
+	}
+}
+
+func TestBackendError(t *testing.T) {
+	for _, test := range []struct {
+		err        error
+		status     *trillian.TrillianApiStatus
+		wantStatus int
+	}{
+		{err: errors.New("connection refused"), wantStatus: http.StatusServiceUnavailable},
+		{status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_ERROR}, wantStatus: http.StatusServiceUnavailable},
+		// Categories the backend gave its error are kept
+		{err: grpc.Errorf(codes.NotFound, "no tree head stored at size: 10"), wantStatus: http.StatusNotFound},
+		{err: grpc.Errorf(codes.OutOfRange, "invalid tree size: 0"), wantStatus: http.StatusBadRequest},
+		{err: grpc.Errorf(codes.DataLoss, "expected 3 nodes in proof but got 2"), wantStatus: http.StatusInternalServerError},
+	} {
+		status, err := errorStatus(backendError("GetConsistencyProof", test.err, test.status))
+
+		if status != test.wantStatus {
+			t.Errorf("backendError(%v, %v) has status %d, want %d", test.err, test.status, status, test.wantStatus)
+		}
+
+		if !strings.Contains(err.Error(), "GetConsistencyProof") {
+			t.Errorf("backendError(%v, %v)=%v, want it to name the RPC", test.err, test.status, err)
+		}
+	}
 }
@@ -0,0 +1,119 @@
+// The ct_log_list command writes the logs served by a CT frontend as a CT log list in the
+// version 3 JSON schema, so that the operator's entries in published log lists can be
+// generated from the same --log_config file as ct_server rather than kept up to date by hand.
+// Each log's public key is read from its public_key file, including for logs whose private
+// key is held by a signing server.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/examples/ct"
+)
+
+var logConfigFlag = flag.String("log_config", "", "File containing a JSON array of the logs served by the frontend, as used by ct_server")
+var operatorNameFlag = flag.String("operator_name", "", "The name of the log operator")
+var operatorEmailFlag = flag.String("operator_email", "", "Comma separated contact email addresses of the log operator")
+var baseURLFlag = flag.String("base_url", "", "The public address of the frontend, e.g. https://ct.example.com/, which each log's prefix is added to unless its log_list url is set")
+var versionFlag = flag.String("version", "", "If set, the version of the log list")
+var outputFlag = flag.String("output", "", "If set, the log list is written to this file rather than stdout")
+
+// loadPublicKey returns the DER encoding of a log's public key
+func loadPublicKey(config ct.LogConfig) ([]byte, error) {
+	if len(config.PublicKey) == 0 {
+		return nil, fmt.Errorf("log %d has no public_key file", config.LogID)
+	}
+
+	publicKeyPEM, err := ioutil.ReadFile(config.PublicKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	km := crypto.NewPEMKeyManager()
+
+	if err := km.LoadPublicKey(string(publicKeyPEM)); err != nil {
+		return nil, fmt.Errorf("failed to load public key of log %d: %v", config.LogID, err)
+	}
+
+	return km.GetRawPublicKey()
+}
+
+// buildLogList returns the log list for an operator's logs
+func buildLogList(configs []ct.LogConfig, now time.Time) (ct.LogList, error) {
+	operator := ct.LogListOperator{Name: *operatorNameFlag, Email: []string{}}
+
+	if len(*operatorEmailFlag) > 0 {
+		operator.Email = strings.Split(*operatorEmailFlag, ",")
+	}
+
+	for _, config := range configs {
+		publicKey, err := loadPublicKey(config)
+
+		if err != nil {
+			return ct.LogList{}, err
+		}
+
+		log, err := ct.NewLogListLog(config, publicKey, *baseURLFlag)
+
+		if err != nil {
+			return ct.LogList{}, err
+		}
+
+		operator.Logs = append(operator.Logs, log)
+	}
+
+	return ct.LogList{Version: *versionFlag, LogListTimestamp: now.UTC().Format(time.RFC3339), Operators: []ct.LogListOperator{operator}}, nil
+}
+
+func main() {
+	flag.Parse()
+
+	if len(*logConfigFlag) == 0 || len(*operatorNameFlag) == 0 {
+		glog.Fatal("--log_config and --operator_name must be set")
+	}
+
+	configs, err := ct.LoadLogConfigs(*logConfigFlag)
+
+	if err != nil {
+		glog.Fatalf("Failed to load log config: %v", err)
+	}
+
+	logList, err := buildLogList(configs, time.Now())
+
+	if err != nil {
+		glog.Fatalf("Failed to build log list: %v", err)
+	}
+
+	var w io.Writer = os.Stdout
+
+	if len(*outputFlag) > 0 {
+		f, err := os.Create(*outputFlag)
+
+		if err != nil {
+			glog.Fatalf("Failed to create output file: %v", err)
+		}
+
+		defer f.Close()
+		w = f
+	}
+
+	data, err := json.MarshalIndent(logList, "", "  ")
+
+	if err != nil {
+		glog.Fatalf("Failed to marshal log list: %v", err)
+	}
+
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		glog.Fatalf("Failed to write log list: %v", err)
+	}
+}
@@ -1,12 +1,24 @@
 package main
 
 import (
+	"crypto/tls"
+	"database/sql"
 	"errors"
+	"expvar"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 
+	"github.com/garyburd/redigo/redis"
+	_ "github.com/go-sql-driver/mysql"
 	"github.com/golang/glog"
 	"github.com/google/trillian"
 	"github.com/google/trillian/crypto"
@@ -19,23 +31,92 @@ import (
 // TODO(Martin2112): We still have the treeid / log ID thing to think about + security etc.
 var logIDFlag = flag.Int64("log_id", 1, "The log id (tree id) to send to the backend")
 var rpcBackendFlag = flag.String("log_rpc_backend", "localhost:8090", "Backend Log RPC server to use")
+var rpcCompressionFlag = flag.String("log_rpc_compression", util.RPCCompressionNone, "Compression of backend RPCs: none, gzip, gzip-fast or snappy. It must use the same encoding as the backend's --rpc_compression, gzip-fast is compatible with gzip")
+var logConfigFlag = flag.String("log_config", "", "If set, a JSON file listing the logs to serve, each with its own path prefix, backend, roots and keys. Replaces --log_id, --log_rpc_backend, --log_rpc_compression, --trusted_roots and the key flags")
+var backendHealthCheckIntervalFlag = flag.Duration("backend_health_check_interval", time.Minute, "How often the backend of each log is checked, the results are served on /debug/vars")
 var rpcDeadlineFlag = flag.Duration("rpc_deadline", time.Second*10, "Deadline for backend RPC requests")
+var circuitBreakerFailuresFlag = flag.Int("circuit_breaker_failures", 0, "If non zero, after this many consecutive backend RPCs fail with backend errors further RPCs to that backend fail immediately with a 503 until a probe RPC succeeds")
+var circuitBreakerOpenDurationFlag = flag.Duration("circuit_breaker_open_duration", time.Second*10, "How long RPCs to a failing backend fail immediately before a probe RPC is let through")
 var serverPortFlag = flag.Int("port", 8091, "Port to serve CT log requests on")
 var trustedRootPEMFlag = flag.String("trusted_roots", "", "File containing one or more concatenated trusted root certs in PEM format")
 var privateKeyPasswordFlag = flag.String("private_key_password", "", "Password for log private key")
 var privateKeyPEMFlag = flag.String("private_key", "", "PEM file containing log private key")
 var publicKeyPEMFlag = flag.String("public_key", "", "PEM file containing log public key")
+var signingServerFlag = flag.String("signing_server", "", "If set, the address of a signing service, such as one run by cmd/signing_server, that holds the log's private key. The key flags are then ignored")
+var signingKeyIDFlag = flag.String("signing_key_id", "default", "ID of the log's key in --signing_server")
+var signingTimeoutFlag = flag.Duration("signing_timeout", time.Second*5, "Max time to wait for a signing server to answer a request")
+var deterministicSignaturesFlag = flag.Bool("deterministic_signatures", false, "If true and the private key is an ECDSA key, SCTs and STHs are signed with RFC 6979 deterministic nonces rather than ones from the random number source")
+var signatureHashFlag = flag.String("signature_hash", "", "If set, the hash function signed in SCTs and STHs, e.g. sha384. By default it's chosen to suit the private key")
+var rsaPSSFlag = flag.Bool("rsa_pss", false, "If true and the private key is an RSA key, SCTs and STHs are signed with RSASSA-PSS. RFC 6962 clients expect PKCS #1 v1.5 so only use this for clients configured to expect PSS")
+var hashAlgorithmFlag = flag.String("hash_algorithm", "", "If set, the hash function the log's tree was created with, e.g. SHA512_256. By default it's SHA256, which RFC 6962 clients expect")
+var extraDataCommitmentFlag = flag.Bool("extra_data_commitment", false, "If true, every leaf and SCT includes an extension with the hash of the submitted chain, so the extra_data served by get-entries can be checked against the tree. This is not part of RFC 6962, only use it for clients that accept SCT extensions")
+var sthCacheMaxAgeFlag = flag.Duration("sth_cache_max_age", time.Second*10, "How long get-entries trusts a tree size before refreshing it. Requests starting beyond the tree size the backend reports are rejected. Zero disables the check")
+var sthCacheTreeEventsFlag = flag.Bool("sth_cache_tree_events", true, "If true, the tree size used by get-entries is updated as soon as the backend signs a new root, using its tree event stream, rather than when it expires")
+var sthCacheServeSTHFlag = flag.Bool("sth_cache_serve_sth", false, "If true, get-sth serves the latest root from the STH cache until it's older than --sth_cache_max_age rather than asking the backend for every request. With --sth_cache_tree_events every frontend switches to a new root within seconds of the backend signing it")
+var treeEventsRetryIntervalFlag = flag.Duration("tree_events_retry_interval", time.Second*30, "How long to wait before subscribing to a backend's tree events again after the subscription fails")
+var proofCacheSizeFlag = flag.Int("proof_cache_size", 0, "If non zero, the number of get-proof-by-hash responses to cache")
+var chainCacheSizeFlag = flag.Int("chain_cache_size", 0, "If non zero, the number of verified add-chain intermediate sets to remember so that resubmissions only need the leaf checked")
+var chainCacheTTLFlag = flag.Duration("chain_cache_ttl", time.Hour, "How long a verified set of intermediates is remembered for")
+var submissionDedupSizeFlag = flag.Int("submission_dedup_size", 0, "If non zero, the number of submitted chains to remember so that a chain submitted again gets an SCT with the original timestamp rather than another leaf being queued")
+var submissionDedupPendingWindowFlag = flag.Duration("submission_dedup_pending_window", time.Hour*24, "How long the leaf of a remembered chain may take to be sequenced, after which a resubmission checks that it's in the tree. It should be the log's maximum merge delay")
+var maxChainDepthFlag = flag.Int("max_chain_depth", 10, "Max number of certificates in a chain submitted to add-chain or add-pre-chain, longer chains are refused. Zero disables the limit")
+var maxChainSignatureChecksFlag = flag.Int("max_chain_signature_checks", 100, "Max number of signature checks verifying a submitted chain may need, chains of cross-signed intermediates that need more are refused. Zero disables the limit")
+var precertLinkWindowFlag = flag.Duration("precert_link_window", 0, "If non zero, precertificates and the certificates issued from them are linked by issuer and serial number when submitted within this window of each other and served on get-precert-link. Resubmissions within the window get an SCT with the timestamp of the first and aren't logged again")
+var precertLinkRejectConflictsFlag = flag.Bool("precert_link_reject_conflicts", false, "If true, with --precert_link_window a certificate or precertificate with the same issuer and serial number as a different one submitted within the window is rejected")
+var gossipDirFlag = flag.String("gossip_dir", "", "If set, enables the gossip endpoint, where clients can post the STHs and SCTs they observed for a log. STHs signed by the log that aren't consistent with its history are written to this directory as evidence of a split view. This is not part of RFC 6962")
+var domainIndexDBFlag = flag.String("domain_index_db", "", "If set, the MySQL data source name of a database that the subject common names and DNS names of each log's entries are indexed in, served on get-entries-by-domain. Frontends can share the database. This is not part of RFC 6962")
+var domainIndexIntervalFlag = flag.Duration("domain_index_interval", time.Second*30, "How often each log's new entries are indexed with --domain_index_db")
+var domainIndexBatchSizeFlag = flag.Int("domain_index_batch_size", 256, "Max number of entries fetched from the backend and indexed in one transaction")
+var certMetricsFlag = flag.Bool("enable_cert_metrics", false, "If true, the type, key algorithm, validity period and issuer of submitted certificates are served on /metrics in the Prometheus text format")
+var certMetricsTopIssuersFlag = flag.Int("cert_metrics_top_issuers", 20, "The number of most frequent issuers that /metrics reports submissions for")
+var sthRefreshIntervalFlag = flag.Duration("sth_refresh_interval", 0, "If non zero, each log's STH is fetched from the backend and signed in the background this often, and get-sth serves it from memory rather than fetching and signing one for every request")
+var sthRefreshMaxAgeFlag = flag.Duration("sth_refresh_max_age", time.Minute, "How long get-sth serves an STH signed in the background with --sth_refresh_interval, if refreshing it fails for longer the STH is fetched and signed for each request. It should be several times the interval")
+var readinessGatingFlag = flag.Bool("readiness_gating", true, "If true, each log's endpoints return 503 until an STH has been fetched from its backend and signed and verified with its keys. Readiness is served on /ready")
+var readinessCheckIntervalFlag = flag.Duration("readiness_check_interval", time.Second*5, "How often a log that isn't ready yet is checked again")
+var allProofsFlag = flag.Bool("enable_all_proofs", false, "If true, get-proof-by-hash accepts all=true to return proofs for every leaf with the hash. This is not part of RFC 6962")
+var omitExtraDataFlag = flag.Bool("enable_omit_extra_data", false, "If true, get-entries accepts omit_extra_data=true to return entries without their extra_data. This is not part of RFC 6962")
+var sloWindowsFlag = flag.String("slo_windows", "", "If set, a comma separated list of windows, e.g. 1m,10m,1h, over which /debug/slo reports latency percentiles and error rates for each endpoint")
+var sloLatencyBudgetFlag = flag.Duration("slo_latency_budget", time.Second, "Latency that requests are measured against in the /debug/slo report")
+var sloMaxSamplesFlag = flag.Int("slo_max_samples", 100000, "Max number of recent requests kept for each endpoint for the /debug/slo report")
+var fastSCTJournalDirFlag = flag.String("fast_sct_journal_dir", "", "If set, enables fast SCT mode using this directory to journal leaves before they reach the backend")
+var fastSCTMaxAgeFlag = flag.Duration("fast_sct_max_age", time.Hour, "Max time a journalled leaf can wait for the backend before fast SCTs stop, must be well within the MMD")
+var fastSCTFlushIntervalFlag = flag.Duration("fast_sct_flush_interval", time.Second, "How often journalled leaves are sent to the backend")
+var sthGuardFlag = flag.String("sth_guard", "alert", "How roots from the backend that are inconsistent with the last STH served, e.g. with a smaller tree size or earlier timestamp, are handled: off, alert to log and count them or enforce to also refuse to serve them")
+var sthGuardAdminTokenFileFlag = flag.String("sth_guard_admin_token_file", "", "If set, a file holding a token that enables /admin/reset-sth-guard for each log, which makes the next root from the backend be served whatever the last STH was. Requests must send it as a bearer token")
+var denylistAdminTokenFileFlag = flag.String("denylist_admin_token_file", "", "If set, a file holding a token that enables a denylist of leaf certificate and issuer key hashes for each log, managed with /admin/denylist-add, /admin/denylist-remove and /admin/denylist. Requests must send it as a bearer token. Entries are held in memory")
+var rootsAdminTokenFileFlag = flag.String("roots_admin_token_file", "", "If set, a file holding a token that enables /admin/add-root and /admin/remove-root for each log. Requests must send it as a bearer token and changes are written back to the log's roots file")
+var rootsReloadIntervalFlag = flag.Duration("roots_reload_interval", 0, "If non zero, how often each log's roots file is checked for changes, which are then accepted without a restart. Roots are also reloaded when the server gets SIGHUP")
+var submittersFileFlag = flag.String("submitters_file", "", "If set, a JSON file listing the submitters allowed to use add-chain and add-pre-chain, each with its API keys or client certificate fingerprints and its quota")
+var redisQuotaAddressFlag = flag.String("redis_quota_address", "", "If set, the host:port of a Redis server that submitter quotas are kept in, so that they're shared by every frontend using it rather than each having its own. Submitters' fail_open decides what happens when it can't be reached")
+var redisQuotaTimeoutFlag = flag.Duration("redis_quota_timeout", time.Millisecond*100, "Timeout for connecting to, reading from and writing to --redis_quota_address")
+var redisQuotaKeyPrefixFlag = flag.String("redis_quota_key_prefix", "ctfe/quota/", "Prefix of the Redis keys of submitter quotas, followed by the log ID and submitter name. Frontends only share quotas if they use the same prefix")
+var tlsCertFileFlag = flag.String("tls_cert_file", "", "If set with --tls_key_file, requests are served over TLS with this PEM certificate. Client certificates are requested so that submitters can authenticate with them")
+var tlsKeyFileFlag = flag.String("tls_key_file", "", "PEM file containing the private key for --tls_cert_file")
+var fastSCTFlushBatchSizeFlag = flag.Int("fast_sct_flush_batch_size", 100, "Max number of journalled leaves sent to the backend in one request")
+var featuresFileFlag = flag.String("features_file", "", "If set, a JSON file of feature flags (fast_sct, proof_cache, chain_cache) deciding which logs use those components, reloaded when it changes. If not set every configured component is used")
+var featuresReloadIntervalFlag = flag.Duration("features_reload_interval", time.Minute, "How often to check whether the features file has changed")
+var basePathFlag = flag.String("base_path", "", "If set, the path all the logs' endpoints are served under, e.g. /logs serves /logs/pilot/ct/v1/add-chain, for reverse proxies that don't strip it from requests")
+var healthPathFlag = flag.String("health_path", "/healthz", "If set, the path that serves the liveness of the server, outside --base_path")
+var metricsPortFlag = flag.Int("metrics_port", 0, "If non zero, a port on localhost serving the requests to each log's endpoints by status and their latency in the Prometheus text format on /request-metrics, along with the RPCs to each backend, labelled by log or with view=aggregate summed across logs, or for one log with log=<prefix>, or its ID if it has none. /debug/slo and each log's /metrics are moved to it from --port")
+var validateConfigFlag = flag.Bool("validate_config", false, "If true, check the flags and each log's config, roots and keys, that its backend serves its log ID with an STH that verifies with its keys and that Redis and the domain index database can be reached, write a JSON report to stdout and exit with status 0 if everything is OK or 1 if not, without serving")
+var readyPathFlag = flag.String("ready_path", "/readyz", "If set, the path that serves whether every log is ready, outside --base_path. With --readiness_gating off the logs are always ready")
+var httpReadTimeoutFlag = flag.Duration("http_read_timeout", time.Second*30, "Max time to read a request, including its body. HTTP/1.1 connections waiting for their next request are closed after it too")
+var httpWriteTimeoutFlag = flag.Duration("http_write_timeout", time.Minute, "Max time from reading a request's headers to writing its response, it must allow for slow backend requests and large get-entries responses")
+var httpIdleTimeoutFlag = flag.Duration("http_idle_timeout", time.Minute*2, "How long an HTTP/2 connection can be idle before it's closed")
+var httpMaxHeaderBytesFlag = flag.Int("http_max_header_bytes", 64*1024, "Max size of a request's headers")
+var http2Flag = flag.Bool("http2", true, "If true, HTTP/2 is offered to clients connecting over TLS")
+var http2MaxConcurrentStreamsFlag = flag.Uint("http2_max_concurrent_streams", 250, "Max number of requests an HTTP/2 client can have outstanding on one connection")
 
-func loadTrustedRoots() (*ct.PEMCertPool, error) {
-	if len(*trustedRootPEMFlag) == 0 {
-		return nil, errors.New("the --trusted_roots flag must be set to reference a valid PEM file")
+func loadTrustedRoots(path string) (*ct.PEMCertPool, error) {
+	if len(path) == 0 {
+		return nil, errors.New("the trusted roots must be set to reference a valid PEM file")
 	}
 
 	trustedRoots := ct.NewPEMCertPool()
 
 	// The set of root data should never be particularly large and we have to keep it in memory
 	// anyway to validate submissions
-	rootData, err := ioutil.ReadFile(*trustedRootPEMFlag)
+	rootData, err := ioutil.ReadFile(path)
 
 	if err != nil {
 		return nil, err
@@ -52,22 +133,43 @@ func loadTrustedRoots() (*ct.PEMCertPool, error) {
 	return trustedRoots, nil
 }
 
-func loadLogKeys() (crypto.KeyManager, error) {
+// loadAdminToken reads an admin token from path, ignoring surrounding whitespace
+func loadAdminToken(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return "", err
+	}
+
+	token := strings.TrimSpace(string(data))
+
+	if len(token) == 0 {
+		return "", errors.New("admin token file is empty")
+	}
+
+	return token, nil
+}
+
+func loadLogKeys(config ct.LogConfig) (crypto.KeyManager, error) {
+	if len(config.SigningServer) > 0 {
+		return loadRemoteLogKeys(config)
+	}
+
 	logKeyManager := crypto.NewPEMKeyManager()
 
-	privateKeyPEM, err := ioutil.ReadFile(*privateKeyPEMFlag)
+	privateKeyPEM, err := ioutil.ReadFile(config.PrivateKey)
 
 	if err != nil {
 		return nil, err
 	}
 
-	err = logKeyManager.LoadPrivateKey(string(privateKeyPEM), *privateKeyPasswordFlag)
+	err = logKeyManager.LoadPrivateKey(string(privateKeyPEM), config.PrivateKeyPassword)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to load private key: %v", err)
 	}
 
-	publicKeyPEM, err := ioutil.ReadFile(*publicKeyPEMFlag)
+	publicKeyPEM, err := ioutil.ReadFile(config.PublicKey)
 
 	if err != nil {
 		return nil, err
@@ -79,41 +181,738 @@ func loadLogKeys() (crypto.KeyManager, error) {
 		return nil, fmt.Errorf("failed to load public key: %v", err)
 	}
 
+	if *deterministicSignaturesFlag {
+		return crypto.NewDeterministicKeyManager(logKeyManager), nil
+	}
+
 	return logKeyManager, nil
 }
 
-func main() {
-	flag.Parse()
+// loadRemoteLogKeys returns a key manager for a log whose key is held by a signing server
+func loadRemoteLogKeys(config ct.LogConfig) (crypto.KeyManager, error) {
+	keyID := config.SigningKeyID
+
+	if len(keyID) == 0 {
+		keyID = "default"
+	}
+
+	conn, err := grpc.Dial(config.SigningServer, grpc.WithInsecure())
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to signing server %s: %v", config.SigningServer, err)
+	}
+
+	return crypto.NewRemoteKeyManager(crypto.NewSigningServiceClient(conn), keyID, *signingTimeoutFlag)
+}
+
+// parseDurations parses a comma separated list of durations
+func parseDurations(list string) ([]time.Duration, error) {
+	durations := make([]time.Duration, 0)
+
+	for _, s := range strings.Split(list, ",") {
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+
+		if err != nil {
+			return nil, err
+		}
+
+		durations = append(durations, d)
+	}
+
+	return durations, nil
+}
+
+// loadLogConfigs returns the logs to serve, either from --log_config or the single log described
+// by the other flags
+func loadLogConfigs() ([]ct.LogConfig, error) {
+	if len(*logConfigFlag) > 0 {
+		return ct.LoadLogConfigs(*logConfigFlag)
+	}
+
+	return []ct.LogConfig{{
+		LogID:               *logIDFlag,
+		RPCBackend:          *rpcBackendFlag,
+		RPCCompression:      *rpcCompressionFlag,
+		TrustedRoots:        *trustedRootPEMFlag,
+		PrivateKey:          *privateKeyPEMFlag,
+		PrivateKeyPassword:  *privateKeyPasswordFlag,
+		PublicKey:           *publicKeyPEMFlag,
+		SigningServer:       *signingServerFlag,
+		SigningKeyID:        *signingKeyIDFlag,
+		SignatureHash:       *signatureHashFlag,
+		RSAPSS:              *rsaPSSFlag,
+		HashAlgorithm:       *hashAlgorithmFlag,
+		ExtraDataCommitment: *extraDataCommitmentFlag,
+		Submitters:          *submittersFileFlag,
+	}}, nil
+}
+
+// newBackendDialer returns a function that connects to a log RPC server, compressing RPCs as
+// configured for the logs on it. If timeout isn't zero connecting fails after it, otherwise
+// it waits until the server is up. If intercept isn't nil the RPCs to each address go through
+// the interceptor it returns for the address.
+// TODO(Martin2112): Support TLS and other stuff for RPC client and http server, this is just to
+// get started. Uses a blocking connection so we don't start serving before we're connected
+// to backend.
+func newBackendDialer(configs []ct.LogConfig, timeout time.Duration, intercept func(address string) grpc.UnaryClientInterceptor) (ct.BackendDialer, error) {
+	compression := make(map[string]string)
+
+	for _, config := range configs {
+		// Logs sharing a backend have compatible settings, see ct.ParseLogConfigs
+		if _, ok := compression[config.RPCBackend]; ok {
+			continue
+		}
+
+		if _, err := util.RPCCompressionEncoding(config.RPCCompression); err != nil {
+			return nil, err
+		}
+
+		compression[config.RPCBackend] = config.RPCCompression
+	}
+
+	return func(address string) (trillian.TrillianLogClient, io.Closer, error) {
+		opts := []grpc.DialOption{grpc.WithInsecure(), grpc.WithBlock()}
+
+		if timeout > 0 {
+			opts = append(opts, grpc.WithTimeout(timeout))
+		}
+
+		compressor, decompressor, err := util.RPCCompression(compression[address])
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if compressor != nil {
+			opts = append(opts, grpc.WithCompressor(compressor), grpc.WithDecompressor(decompressor))
+		}
+
+		if intercept != nil {
+			opts = append(opts, grpc.WithUnaryInterceptor(intercept(address)))
+		}
+
+		conn, err := grpc.Dial(address, opts...)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return trillian.NewTrillianLogClient(conn), conn, nil
+	}, nil
+}
+
+// backendInterceptor returns the interceptor for RPCs to a backend, which counts them in
+// metrics and, if it's enabled, stops making them while the backend keeps failing. The RPC
+// metrics and the state of the circuit breaker are served on /debug/vars.
+func backendInterceptor(address string, metrics *ct.BackendRPCMetrics) grpc.UnaryClientInterceptor {
+	interceptors := []grpc.UnaryClientInterceptor{metrics.Interceptor()}
+	var breaker *ct.CircuitBreaker
+
+	if *circuitBreakerFailuresFlag > 0 {
+		breaker = ct.NewCircuitBreaker(*circuitBreakerFailuresFlag, *circuitBreakerOpenDurationFlag, new(util.SystemTimeSource))
+		interceptors = append(interceptors, breaker.Interceptor())
+	}
+
+	expvar.Publish("backend/"+address, expvar.Func(func() interface{} {
+		result := map[string]interface{}{"rpcs": metrics.Stats()}
+
+		if breaker != nil {
+			state, rejected := breaker.Stats()
+			result["circuit_breaker"] = map[string]interface{}{"state": state, "rejected": rejected}
+		}
+
+		return result
+	}))
+
+	return ct.ChainUnaryClientInterceptors(interceptors...)
+}
+
+// metricsName returns the name of a log in its metrics, its prefix or if it has none its ID
+func metricsName(config ct.LogConfig) string {
+	if len(config.Prefix) == 0 {
+		return strconv.FormatInt(config.LogID, 10)
+	}
+
+	return config.Prefix
+}
+
+// varName returns the name to publish a log's variable under. When several logs are served
+// the names are qualified by the log's prefix so they don't clash.
+func varName(name string, config ct.LogConfig) string {
+	if len(config.Prefix) == 0 {
+		return name
+	}
+
+	return name + "/" + config.Prefix
+}
 
+// registerLog creates the handlers for a log, using client to talk to its backend, and
+// registers them. features is shared by all the logs and may be nil, the log's readiness is
+// added to health. If redisPool isn't nil the log's submitter quotas are kept in Redis. If
+// indexDB isn't nil the log's entries are indexed by domain in it. The log's requests and the
+// RPCs to its backend, rpcMetrics, are served on its /metrics. If metrics isn't nil the log's
+// requests are also added to it and its debug endpoints are registered on metricsMux. The
+// log's roots are returned so that they can be reloaded.
+func registerLog(config ct.LogConfig, client trillian.TrillianLogClient, rpcMetrics *ct.BackendRPCMetrics, features *util.Features, health *ct.ServerHealth, redisPool *redis.Pool, indexDB *sql.DB, metrics *ct.MetricsServer, metricsMux *http.ServeMux) *ct.TrustedRoots {
 	// Load the set of trusted root certs before bringing up any servers
-	trustedRoots, err := loadTrustedRoots()
+	trustedRoots, err := loadTrustedRoots(config.TrustedRoots)
 
 	if err != nil {
-		glog.Fatalf("Failed to read trusted roots: %v", err)
+		glog.Fatalf("Failed to read trusted roots for log %d: %v", config.LogID, err)
 	}
 
 	// And load our keys
-	logKeyManager, err := loadLogKeys()
+	logKeyManager, err := loadLogKeys(config)
+
+	if err != nil {
+		glog.Fatalf("Failed to load keys for log %d: %v", config.LogID, err)
+	}
+
+	signatureOptions, err := config.SignatureOptions()
 
 	if err != nil {
-		glog.Fatalf("Failed to load keys for log: %v", err)
+		glog.Fatalf("Invalid signature options for log %d: %v", config.LogID, err)
 	}
 
-	// TODO(Martin2112): Support TLS and other stuff for RPC client and http server, this is just to
-	// get started. Uses a blocking connection so we don't start serving before we're connected
-	// to backend.
-	conn, err := grpc.Dial(*rpcBackendFlag, grpc.WithInsecure(), grpc.WithBlock())
+	hashAlgorithm, err := config.TreeHashAlgorithm()
 
 	if err != nil {
-		glog.Fatalf("Could not connect to rpc server: %v", err)
+		glog.Fatalf("Invalid hash algorithm for log %d: %v", config.LogID, err)
+	}
+
+	opts := []ct.HandlerOption{ct.WithRPCDeadline(*rpcDeadlineFlag), ct.WithBasePath(*basePathFlag), ct.WithPathPrefix(config.Prefix), ct.WithSignatureOptions(signatureOptions), ct.WithHashAlgorithm(hashAlgorithm)}
+
+	if features != nil {
+		opts = append(opts, ct.WithFeatures(features))
+	}
+
+	if *proofCacheSizeFlag > 0 {
+		cache, err := ct.NewProofCache(*proofCacheSizeFlag)
+
+		if err != nil {
+			glog.Fatalf("Failed to create proof cache: %v", err)
+		}
+
+		// Served on /debug/vars by expvar
+		expvar.Publish(varName("proof_cache", config), expvar.Func(func() interface{} {
+			hits, misses := cache.Stats()
+			return map[string]interface{}{"hits": hits, "misses": misses, "hit_rate": cache.HitRate(), "size": cache.Len()}
+		}))
+		opts = append(opts, ct.WithProofCache(cache))
+	}
+
+	if *chainCacheSizeFlag > 0 {
+		cache, err := ct.NewChainCache(*chainCacheSizeFlag, *chainCacheTTLFlag, new(util.SystemTimeSource))
+
+		if err != nil {
+			glog.Fatalf("Failed to create chain cache: %v", err)
+		}
+
+		expvar.Publish(varName("chain_cache", config), expvar.Func(func() interface{} {
+			hits, misses := cache.Stats()
+			return map[string]interface{}{"hits": hits, "misses": misses, "size": cache.Len()}
+		}))
+		opts = append(opts, ct.WithChainCache(cache))
+	}
+
+	if *submissionDedupSizeFlag > 0 {
+		dedup, err := ct.NewSubmissionDedup(*submissionDedupSizeFlag, *submissionDedupPendingWindowFlag, new(util.SystemTimeSource))
+
+		if err != nil {
+			glog.Fatalf("Failed to create submission dedup: %v", err)
+		}
+
+		expvar.Publish(varName("submission_dedup", config), expvar.Func(func() interface{} {
+			size, duplicates, lost := dedup.Stats()
+			return map[string]interface{}{"size": size, "duplicates": duplicates, "lost": lost}
+		}))
+		opts = append(opts, ct.WithSubmissionDedup(dedup))
+	}
+
+	if *maxChainDepthFlag > 0 || *maxChainSignatureChecksFlag > 0 {
+		limits, err := ct.NewChainLimits(*maxChainDepthFlag, *maxChainSignatureChecksFlag)
+
+		if err != nil {
+			glog.Fatalf("Invalid chain limits: %v", err)
+		}
+
+		// Chains near the limits show whether they can be lowered or need raising
+		expvar.Publish(varName("chain_limits", config), expvar.Func(func() interface{} {
+			checked, nearLimit, rejected := limits.Stats()
+			return map[string]interface{}{"checked": checked, "near_limit": nearLimit, "rejected": rejected}
+		}))
+		opts = append(opts, ct.WithChainLimits(limits))
+	}
+
+	notAfterWindow, err := config.NotAfterWindow()
+
+	if err != nil {
+		glog.Fatalf("Invalid NotAfter window for log %d: %v", config.LogID, err)
+	}
+
+	if notAfterWindow != nil {
+		glog.Infof("Log %d only accepts certificates expiring in %s", config.LogID, notAfterWindow)
+		opts = append(opts, ct.WithNotAfterWindow(*notAfterWindow))
+	}
+
+	if config.ExtraDataCommitment {
+		if *precertLinkWindowFlag > 0 {
+			glog.Fatalf("Log %d: extra data commitments can't be used with --precert_link_window", config.LogID)
+		}
+
+		opts = append(opts, ct.WithExtraDataCommitment())
+	}
+
+	if *precertLinkWindowFlag > 0 {
+		links, err := ct.NewPrecertLinks(*precertLinkWindowFlag, *precertLinkRejectConflictsFlag, new(util.SystemTimeSource))
+
+		if err != nil {
+			glog.Fatalf("Failed to create precert links: %v", err)
+		}
+
+		expvar.Publish(varName("precert_links", config), expvar.Func(func() interface{} {
+			issuances, linked, replays, conflicts := links.Stats()
+			return map[string]interface{}{"issuances": issuances, "linked": linked, "replays": replays, "conflicts": conflicts}
+		}))
+		opts = append(opts, ct.WithPrecertLinks(links))
+	}
+
+	if len(*gossipDirFlag) > 0 {
+		dir := *gossipDirFlag
+
+		// Each log needs its own evidence
+		if len(*logConfigFlag) > 0 {
+			dir = filepath.Join(dir, strconv.FormatInt(config.LogID, 10))
+		}
+
+		gossip, err := ct.NewGossip(dir, new(util.SystemTimeSource))
+
+		if err != nil {
+			glog.Fatalf("Failed to create gossip: %v", err)
+		}
+
+		expvar.Publish(varName("gossip", config), expvar.Func(func() interface{} {
+			verified, invalid, splitViews := gossip.Stats()
+			return map[string]interface{}{"verified": verified, "invalid": invalid, "split_views": splitViews}
+		}))
+		opts = append(opts, ct.WithGossip(gossip))
+	}
+
+	if indexDB != nil {
+		index, err := ct.NewDomainIndex(indexDB, config.LogID)
+
+		if err != nil {
+			glog.Fatalf("Failed to create domain index: %v", err)
+		}
+
+		// The indexer runs for the life of the server
+		go index.Run(make(chan struct{}), client, *domainIndexIntervalFlag, *rpcDeadlineFlag, *domainIndexBatchSizeFlag)
+
+		expvar.Publish(varName("domain_index", config), expvar.Func(func() interface{} {
+			indexed, names, unparseable := index.Stats()
+			return map[string]interface{}{"indexed": indexed, "names": names, "unparseable": unparseable}
+		}))
+		opts = append(opts, ct.WithDomainIndex(index))
+	}
+
+	if *certMetricsFlag {
+		certMetrics, err := ct.NewCertMetrics(*certMetricsTopIssuersFlag)
+
+		if err != nil {
+			glog.Fatalf("Failed to create certificate metrics: %v", err)
+		}
+
+		opts = append(opts, ct.WithCertMetrics(certMetrics))
+	}
+
+	requestMetrics := ct.NewRequestMetrics(new(util.SystemTimeSource))
+	opts = append(opts, ct.WithRequestMetrics(requestMetrics), ct.WithBackendRPCMetrics(rpcMetrics, config.RPCBackend))
+
+	if metrics != nil {
+		if err := metrics.AddLog(metricsName(config), requestMetrics); err != nil {
+			glog.Fatalf("Failed to add request metrics: %v", err)
+		}
+
+		opts = append(opts, ct.WithDebugMux(metricsMux))
+	}
+
+	if *allProofsFlag {
+		opts = append(opts, ct.WithAllProofs())
+	}
+
+	if *omitExtraDataFlag {
+		opts = append(opts, ct.WithOmitExtraData())
 	}
 
-	defer conn.Close()
-	client := trillian.NewTrillianLogClient(conn)
+	if len(*sloWindowsFlag) > 0 {
+		windows, err := parseDurations(*sloWindowsFlag)
 
-	// Create and register the handlers using the RPC client we just set up
-	handlers := ct.NewCTRequestHandlers(*logIDFlag, trustedRoots, client, logKeyManager, *rpcDeadlineFlag, new(util.SystemTimeSource))
-	handlers.RegisterCTHandlers()
+		if err != nil {
+			glog.Fatalf("Invalid --slo_windows: %v", err)
+		}
 
-	glog.Warningf("Server exited: %v", http.ListenAndServe(fmt.Sprintf("localhost:%d", *serverPortFlag), nil))
+		tracker, err := ct.NewSLOTracker(windows, *sloLatencyBudgetFlag, *sloMaxSamplesFlag, new(util.SystemTimeSource))
+
+		if err != nil {
+			glog.Fatalf("Failed to create SLO tracker: %v", err)
+		}
+
+		opts = append(opts, ct.WithSLOTracker(tracker))
+	}
+
+	if *sthCacheMaxAgeFlag > 0 {
+		sthCache := ct.NewSTHCache(*sthCacheMaxAgeFlag, new(util.SystemTimeSource))
+
+		if *sthCacheTreeEventsFlag {
+			// The subscription runs for the life of the server
+			go sthCache.Watch(make(chan struct{}), client, config.LogID, *treeEventsRetryIntervalFlag)
+		}
+
+		// Served on /debug/vars by expvar, frontends whose tree sizes differ for long aren't
+		// getting new roots
+		expvar.Publish(varName("sth_cache", config), expvar.Func(func() interface{} {
+			treeSize, age, events := sthCache.Stats()
+			return map[string]interface{}{"tree_size": treeSize, "age_seconds": age.Seconds(), "tree_events": events}
+		}))
+		opts = append(opts, ct.WithSTHCache(sthCache))
+
+		if *sthCacheServeSTHFlag {
+			opts = append(opts, ct.WithCachedSTH())
+		}
+	}
+
+	var sthRefresher *ct.STHRefresher
+
+	if *sthRefreshIntervalFlag > 0 {
+		sthRefresher = ct.NewSTHRefresher(*sthRefreshMaxAgeFlag, new(util.SystemTimeSource))
+
+		// Served on /debug/vars by expvar, an age above the refresh interval means refreshes
+		// are failing
+		expvar.Publish(varName("sth_refresher", config), expvar.Func(func() interface{} {
+			treeSize, age, failures := sthRefresher.Stats()
+			return map[string]interface{}{"tree_size": treeSize, "age_seconds": age.Seconds(), "failures": failures}
+		}))
+		opts = append(opts, ct.WithSTHRefresher(sthRefresher))
+	}
+
+	if len(*fastSCTJournalDirFlag) > 0 {
+		dir := *fastSCTJournalDirFlag
+
+		// Each log needs its own journal
+		if len(*logConfigFlag) > 0 {
+			dir = filepath.Join(dir, strconv.FormatInt(config.LogID, 10))
+		}
+
+		journal, err := ct.NewLeafJournal(dir, *fastSCTMaxAgeFlag, new(util.SystemTimeSource))
+
+		if err != nil {
+			glog.Fatalf("Failed to open leaf journal: %v", err)
+		}
+
+		// The flusher runs for the life of the server
+		go journal.RunFlusher(make(chan struct{}), client, config.LogID, *fastSCTFlushIntervalFlag, *rpcDeadlineFlag, *fastSCTFlushBatchSizeFlag)
+		opts = append(opts, ct.WithFastSCT(journal))
+	}
+
+	// The roots can be reloaded from the file, and changed by admin requests if enabled
+	roots := ct.NewTrustedRoots(trustedRoots, config.TrustedRoots)
+	expvar.Publish(varName("trusted_roots", config), expvar.Func(func() interface{} {
+		count, reloads, failures := roots.Stats()
+		return map[string]interface{}{"roots": count, "reloads": reloads, "reload_failures": failures}
+	}))
+
+	if *rootsReloadIntervalFlag > 0 {
+		go roots.ReloadOnChange(make(chan struct{}), *rootsReloadIntervalFlag)
+	}
+
+	if len(*rootsAdminTokenFileFlag) > 0 {
+		token, err := loadAdminToken(*rootsAdminTokenFileFlag)
+
+		if err != nil {
+			glog.Fatalf("Failed to load roots admin token: %v", err)
+		}
+
+		opts = append(opts, ct.WithRootsAdmin(roots, token))
+	} else {
+		opts = append(opts, ct.WithLiveRoots(roots))
+	}
+
+	if len(*denylistAdminTokenFileFlag) > 0 {
+		token, err := loadAdminToken(*denylistAdminTokenFileFlag)
+
+		if err != nil {
+			glog.Fatalf("Failed to load denylist admin token: %v", err)
+		}
+
+		denylist := ct.NewDenylist(new(util.SystemTimeSource))
+		expvar.Publish(varName("denylist", config), expvar.Func(func() interface{} {
+			entries, rejected := denylist.Stats()
+			return map[string]interface{}{"entries": entries, "rejected": rejected}
+		}))
+		opts = append(opts, ct.WithDenylist(denylist, token))
+	}
+
+	if *sthGuardFlag != "off" {
+		var adminToken string
+
+		if len(*sthGuardAdminTokenFileFlag) > 0 {
+			token, err := loadAdminToken(*sthGuardAdminTokenFileFlag)
+
+			if err != nil {
+				glog.Fatalf("Failed to load STH guard admin token: %v", err)
+			}
+
+			adminToken = token
+		}
+
+		guard := ct.NewSTHGuard(*sthGuardFlag == "alert")
+		expvar.Publish(varName("sth_guard", config), expvar.Func(func() interface{} {
+			regressions, treeSize := guard.Stats()
+			return map[string]interface{}{"regressions": regressions, "tree_size": treeSize}
+		}))
+		opts = append(opts, ct.WithSTHGuard(guard, adminToken))
+	}
+
+	if len(config.Submitters) > 0 {
+		submitterConfigs, err := ct.LoadSubmitterConfigs(config.Submitters)
+
+		if err != nil {
+			glog.Fatalf("Failed to load submitters for log %d: %v", config.LogID, err)
+		}
+
+		submitters, err := ct.NewSubmitters(submitterConfigs, new(util.SystemTimeSource))
+
+		if err != nil {
+			glog.Fatalf("Failed to create submitters for log %d: %v", config.LogID, err)
+		}
+
+		if redisPool != nil {
+			submitters.SetRedisQuotas(redisPool, fmt.Sprintf("%s%d/", *redisQuotaKeyPrefixFlag, config.LogID))
+		}
+
+		expvar.Publish(varName("submitters", config), expvar.Func(func() interface{} {
+			return submitters.Stats()
+		}))
+		opts = append(opts, ct.WithSubmitters(submitters))
+	}
+
+	if *readinessGatingFlag {
+		readiness := ct.NewLogReadiness()
+		health.AddLog("/"+config.Prefix, readiness)
+		opts = append(opts, ct.WithReadinessGating(readiness))
+	}
+
+	// Create and register the handlers using the RPC client for the log's backend. They share
+	// the default mux with expvar's /debug/vars.
+	handlers := ct.NewCTRequestHandlers(config.LogID, trustedRoots, client, logKeyManager, opts...)
+
+	if *readinessGatingFlag {
+		go handlers.WaitUntilReady(make(chan struct{}), *readinessCheckIntervalFlag)
+	}
+
+	if sthRefresher != nil {
+		// Refreshing runs for the life of the server
+		go handlers.RefreshSTH(make(chan struct{}), *sthRefreshIntervalFlag)
+	}
+
+	handlers.RegisterHandlers(http.DefaultServeMux)
+
+	return roots
+}
+
+// reloadRootsOnSignal reloads the roots of each log, keyed by log ID, whenever the server gets
+// SIGHUP. Failures are logged and the log keeps its current roots.
+func reloadRootsOnSignal(roots map[int64]*ct.TrustedRoots) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	for range signals {
+		for logID, logRoots := range roots {
+			changed, err := logRoots.Reload()
+
+			if err != nil {
+				glog.Warningf("Failed to reload roots for log %d, keeping the current roots: %v", logID, err)
+				continue
+			}
+
+			if changed {
+				count, _, _ := logRoots.Stats()
+				glog.Infof("Reloaded %d roots for log %d", count, logID)
+			}
+		}
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	if *validateConfigFlag {
+		report := validateConfig()
+
+		if err := report.Write(os.Stdout); err != nil {
+			glog.Errorf("Failed to write config report: %v", err)
+		}
+
+		os.Exit(report.ExitCode())
+	}
+
+	configs, err := loadLogConfigs()
+
+	if err != nil {
+		glog.Fatalf("Failed to load log config: %v", err)
+	}
+
+	if err := ct.ValidateBasePath(*basePathFlag); err != nil {
+		glog.Fatalf("Invalid --base_path: %v", err)
+	}
+
+	switch *sthGuardFlag {
+	case "off", "alert", "enforce":
+	default:
+		glog.Fatalf("Invalid --sth_guard: %s, must be off, alert or enforce", *sthGuardFlag)
+	}
+
+	var features *util.Features
+
+	if len(*featuresFileFlag) > 0 {
+		featureConfigs, err := util.LoadFeatureConfigs(*featuresFileFlag)
+
+		if err != nil {
+			glog.Fatalf("Failed to load features: %v", err)
+		}
+
+		features = util.NewFeatures(ct.HandlerFeatures...)
+
+		if err := features.Apply(featureConfigs); err != nil {
+			glog.Fatalf("Failed to apply features: %v", err)
+		}
+
+		// Served on /debug/vars by expvar
+		expvar.Publish("features", expvar.Func(func() interface{} {
+			return features.Configs()
+		}))
+		go features.ReloadOnChange(make(chan struct{}), *featuresFileFlag, *featuresReloadIntervalFlag)
+	}
+
+	// The RPCs to each backend are counted for all the logs on it
+	rpcMetrics := make(map[string]*ct.BackendRPCMetrics)
+
+	for _, config := range configs {
+		if _, ok := rpcMetrics[config.RPCBackend]; !ok {
+			rpcMetrics[config.RPCBackend] = ct.NewBackendRPCMetrics(new(util.SystemTimeSource))
+		}
+	}
+
+	// Logs are routed to their own backends, logs on the same backend share a connection
+	dialBackend, err := newBackendDialer(configs, 0, func(address string) grpc.UnaryClientInterceptor {
+		return backendInterceptor(address, rpcMetrics[address])
+	})
+
+	if err != nil {
+		glog.Fatalf("Invalid backend compression: %v", err)
+	}
+
+	backends := ct.NewBackendPool(dialBackend, new(util.SystemTimeSource))
+	defer backends.Close()
+
+	health := ct.NewServerHealth()
+
+	// Submitter quotas are shared through Redis if it's configured
+	var redisPool *redis.Pool
+
+	if len(*redisQuotaAddressFlag) > 0 {
+		redisPool = ct.NewRedisPool(*redisQuotaAddressFlag, *redisQuotaTimeoutFlag)
+		defer redisPool.Close()
+	}
+
+	// Every log is indexed in the same database, keyed by log ID
+	var indexDB *sql.DB
+
+	if len(*domainIndexDBFlag) > 0 {
+		if indexDB, err = sql.Open("mysql", *domainIndexDBFlag); err != nil {
+			glog.Fatalf("Failed to open domain index database: %v", err)
+		}
+
+		defer indexDB.Close()
+	}
+
+	// Per log metrics are served on an internal port if it's configured
+	var metrics *ct.MetricsServer
+	var metricsMux *http.ServeMux
+
+	if *metricsPortFlag > 0 {
+		metrics = ct.NewMetricsServer()
+		metricsMux = http.NewServeMux()
+		metrics.RegisterHandlers(metricsMux, "/request-metrics")
+
+		for address, backendMetrics := range rpcMetrics {
+			if err := metrics.AddBackend(address, backendMetrics); err != nil {
+				glog.Fatalf("Failed to add backend RPC metrics: %v", err)
+			}
+		}
+	}
+
+	roots := make(map[int64]*ct.TrustedRoots)
+
+	for _, config := range configs {
+		client, err := backends.AddLog(config.LogID, config.RPCBackend)
+
+		if err != nil {
+			glog.Fatalf("Could not connect to rpc server: %v", err)
+		}
+
+		roots[config.LogID] = registerLog(config, client, rpcMetrics[config.RPCBackend], features, health, redisPool, indexDB, metrics, metricsMux)
+	}
+
+	go reloadRootsOnSignal(roots)
+
+	// Served on /debug/vars by expvar
+	expvar.Publish("backends", expvar.Func(func() interface{} {
+		return backends.Health()
+	}))
+	go backends.RunHealthChecks(make(chan struct{}), *backendHealthCheckIntervalFlag, *rpcDeadlineFlag)
+
+	// The health endpoints aren't under the base path so that they can be checked at fixed
+	// paths however the logs are routed
+	health.RegisterHandlers(http.DefaultServeMux, *healthPathFlag, *readyPathFlag)
+
+	if metricsMux != nil {
+		go func() {
+			glog.Warningf("Metrics server exited: %v", http.ListenAndServe(fmt.Sprintf("localhost:%d", *metricsPortFlag), metricsMux))
+		}()
+	}
+
+	address := fmt.Sprintf("localhost:%d", *serverPortFlag)
+
+	if len(*tlsCertFileFlag) > 0 || len(*tlsKeyFileFlag) > 0 {
+		// Client certificates are checked against each log's submitters rather than a CA
+		server, err := ct.NewHTTPServer(address, nil, &tls.Config{ClientAuth: tls.RequestClientCert}, httpServerConfig())
+
+		if err != nil {
+			glog.Fatalf("Invalid HTTP server config: %v", err)
+		}
+
+		glog.Warningf("Server exited: %v", server.ListenAndServeTLS(*tlsCertFileFlag, *tlsKeyFileFlag))
+		return
+	}
+
+	server, err := ct.NewHTTPServer(address, nil, nil, httpServerConfig())
+
+	if err != nil {
+		glog.Fatalf("Invalid HTTP server config: %v", err)
+	}
+
+	glog.Warningf("Server exited: %v", server.ListenAndServe())
+}
+
+// httpServerConfig returns the limits of the HTTP server set by the flags.
+func httpServerConfig() ct.HTTPServerConfig {
+	return ct.HTTPServerConfig{
+		ReadTimeout:               *httpReadTimeoutFlag,
+		WriteTimeout:              *httpWriteTimeoutFlag,
+		IdleTimeout:               *httpIdleTimeoutFlag,
+		MaxHeaderBytes:            *httpMaxHeaderBytesFlag,
+		DisableHTTP2:              !*http2Flag,
+		HTTP2MaxConcurrentStreams: uint32(*http2MaxConcurrentStreamsFlag),
+	}
 }
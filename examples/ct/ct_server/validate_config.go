@@ -0,0 +1,215 @@
+package main
+
+import (
+	"crypto/tls"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/examples/ct"
+	"github.com/google/trillian/util"
+)
+
+// validateConfig checks the flags and everything they refer to without serving: each log's
+// roots, keys and submitters can be loaded, its backend serves its log ID with an STH that
+// verifies once signed with its keys, and the files, Redis and database other components use
+// can be reached.
+func validateConfig() *util.ConfigReport {
+	report := util.NewConfigReport("ct_server")
+
+	var configs []ct.LogConfig
+	configsOK := report.Check("log_config", func() error {
+		var err error
+		configs, err = loadLogConfigs()
+		return err
+	})
+
+	report.Check("base_path", func() error {
+		return ct.ValidateBasePath(*basePathFlag)
+	})
+
+	report.Check("sth_guard", func() error {
+		switch *sthGuardFlag {
+		case "off", "alert", "enforce":
+			return nil
+		default:
+			return fmt.Errorf("invalid --sth_guard: %s, must be off, alert or enforce", *sthGuardFlag)
+		}
+	})
+
+	report.Check("http_server", func() error {
+		return httpServerConfig().Validate()
+	})
+
+	report.Check("chain_limits", func() error {
+		_, err := ct.NewChainLimits(*maxChainDepthFlag, *maxChainSignatureChecksFlag)
+		return err
+	})
+
+	if configsOK {
+		var dialBackend ct.BackendDialer
+		dialerOK := report.Check("backend_compression", func() error {
+			var err error
+			dialBackend, err = newBackendDialer(configs, *rpcDeadlineFlag, nil)
+			return err
+		})
+
+		for _, config := range configs {
+			validateLogConfig(report, config, dialBackend, dialerOK)
+		}
+	}
+
+	if len(*featuresFileFlag) > 0 {
+		report.Check("features_file", func() error {
+			configs, err := util.LoadFeatureConfigs(*featuresFileFlag)
+
+			if err != nil {
+				return err
+			}
+
+			return util.NewFeatures(ct.HandlerFeatures...).Apply(configs)
+		})
+	}
+
+	for _, file := range []struct{ name, path string }{
+		{"roots_admin_token_file", *rootsAdminTokenFileFlag},
+		{"denylist_admin_token_file", *denylistAdminTokenFileFlag},
+		{"sth_guard_admin_token_file", *sthGuardAdminTokenFileFlag},
+	} {
+		if len(file.path) > 0 {
+			report.Check(file.name, func() error {
+				_, err := loadAdminToken(file.path)
+				return err
+			})
+		}
+	}
+
+	for _, dir := range []struct{ name, path string }{
+		{"gossip_dir", *gossipDirFlag},
+		{"fast_sct_journal_dir", *fastSCTJournalDirFlag},
+	} {
+		if len(dir.path) > 0 {
+			report.Check(dir.name, func() error {
+				return util.CheckWritableDir(dir.path)
+			})
+		}
+	}
+
+	if len(*redisQuotaAddressFlag) > 0 {
+		report.Check("redis_quota", func() error {
+			pool := ct.NewRedisPool(*redisQuotaAddressFlag, *redisQuotaTimeoutFlag)
+			defer pool.Close()
+
+			conn := pool.Get()
+			defer conn.Close()
+
+			_, err := conn.Do("PING")
+			return err
+		})
+	}
+
+	if len(*domainIndexDBFlag) > 0 {
+		report.Check("domain_index_db", func() error {
+			db, err := sql.Open("mysql", *domainIndexDBFlag)
+
+			if err != nil {
+				return err
+			}
+
+			defer db.Close()
+
+			return db.Ping()
+		})
+	}
+
+	if len(*tlsCertFileFlag) > 0 || len(*tlsKeyFileFlag) > 0 {
+		report.Check("tls", func() error {
+			_, err := tls.LoadX509KeyPair(*tlsCertFileFlag, *tlsKeyFileFlag)
+			return err
+		})
+	}
+
+	return report
+}
+
+// validateLogConfig adds the checks of one log to report, named after its ID. The backend
+// isn't contacted if dialerOK is false.
+func validateLogConfig(report *util.ConfigReport, config ct.LogConfig, dialBackend ct.BackendDialer, dialerOK bool) {
+	name := func(check string) string {
+		return fmt.Sprintf("log_%d/%s", config.LogID, check)
+	}
+
+	var trustedRoots *ct.PEMCertPool
+	report.Check(name("trusted_roots"), func() error {
+		var err error
+		trustedRoots, err = loadTrustedRoots(config.TrustedRoots)
+		return err
+	})
+
+	var keyManager crypto.KeyManager
+	keysOK := report.Check(name("keys"), func() error {
+		var err error
+		keyManager, err = loadLogKeys(config)
+		return err
+	})
+
+	var signatureOptions ct.SignatureOptions
+	keysOK = report.Check(name("signature_options"), func() error {
+		var err error
+		signatureOptions, err = config.SignatureOptions()
+		return err
+	}) && keysOK
+
+	report.Check(name("hash_algorithm"), func() error {
+		_, err := config.TreeHashAlgorithm()
+		return err
+	})
+
+	if config.ExtraDataCommitment {
+		report.Check(name("extra_data_commitment"), func() error {
+			if *precertLinkWindowFlag > 0 {
+				return errors.New("extra data commitments can't be used with --precert_link_window")
+			}
+
+			return nil
+		})
+	}
+
+	if len(config.Submitters) > 0 {
+		report.Check(name("submitters"), func() error {
+			submitterConfigs, err := ct.LoadSubmitterConfigs(config.Submitters)
+
+			if err != nil {
+				return err
+			}
+
+			_, err = ct.NewSubmitters(submitterConfigs, new(util.SystemTimeSource))
+			return err
+		})
+	}
+
+	if !dialerOK {
+		report.Skip(name("backend"), "backend compression is invalid")
+		report.Skip(name("sth"), "backend compression is invalid")
+		return
+	}
+
+	var client trillian.TrillianLogClient
+	backendOK := report.Check(name("backend"), func() error {
+		var err error
+		client, _, err = dialBackend(config.RPCBackend)
+		return err
+	})
+
+	if !backendOK || !keysOK {
+		report.Skip(name("sth"), "backend or keys aren't available")
+		return
+	}
+
+	report.Check(name("sth"), func() error {
+		handlers := ct.NewCTRequestHandlers(config.LogID, trustedRoots, client, keyManager, ct.WithRPCDeadline(*rpcDeadlineFlag), ct.WithSignatureOptions(signatureOptions))
+		return handlers.CheckBackendAndKeys()
+	})
+}
@@ -0,0 +1,72 @@
+package ctapi
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ErrorCode classifies why a request failed so that clients do not have to parse the
+// message to decide what to do.
+type ErrorCode string
+
+const (
+	// ErrBadRequest means the request was malformed or had invalid parameters. Retrying
+	// the same request will not help.
+	ErrBadRequest ErrorCode = "bad_request"
+	// ErrMethodNotAllowed means the wrong HTTP method was used for the endpoint
+	ErrMethodNotAllowed ErrorCode = "method_not_allowed"
+	// ErrBackendUnavailable means the log backend could not be reached or failed. The
+	// request may succeed if retried later.
+	ErrBackendUnavailable ErrorCode = "backend_unavailable"
+	// ErrInternal means something went wrong in the log server itself
+	ErrInternal ErrorCode = "internal_error"
+)
+
+// Error is the JSON body sent to clients when a request fails.
+type Error struct {
+	// Code is the HTTP status of the response, it is not included in the body
+	Code int `json:"-"`
+	// ErrorCode classifies the error
+	ErrorCode ErrorCode `json:"error_code"`
+	// Message describes the error, it's intended for humans and its format is not stable
+	Message string `json:"error_message"`
+	// RequestID identifies the request in the server logs, if it has one
+	RequestID string `json:"request_id,omitempty"`
+	// TreeSize is the current size of the tree, set when a request was rejected because
+	// it was beyond the end of the tree
+	TreeSize *int64 `json:"tree_size,omitempty"`
+}
+
+// Error implements the error interface.
+func (e Error) Error() string {
+	return fmt.Sprintf("%s (%d): %s", e.ErrorCode, e.Code, e.Message)
+}
+
+// NewError creates an Error for an HTTP status code, picking the ErrorCode that matches it.
+// If err is already an Error it is returned with the status code updated.
+func NewError(statusCode int, err error) Error {
+	if e, ok := err.(Error); ok {
+		e.Code = statusCode
+		return e
+	}
+
+	msg := http.StatusText(statusCode)
+	if err != nil {
+		msg = err.Error()
+	}
+
+	return Error{Code: statusCode, ErrorCode: errorCodeForStatus(statusCode), Message: msg}
+}
+
+func errorCodeForStatus(statusCode int) ErrorCode {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return ErrBadRequest
+	case http.StatusMethodNotAllowed:
+		return ErrMethodNotAllowed
+	case http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return ErrBackendUnavailable
+	default:
+		return ErrInternal
+	}
+}
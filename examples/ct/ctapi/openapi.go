@@ -0,0 +1,245 @@
+package ctapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Param describes a query parameter accepted by an endpoint.
+type Param struct {
+	Name        string
+	Description string
+	// Type is the OpenAPI type of the parameter, e.g. "integer" or "string"
+	Type     string
+	Required bool
+}
+
+// Endpoint describes one of the CT v1 HTTP API methods.
+type Endpoint struct {
+	// Name is the last path component, e.g. "get-sth"
+	Name        string
+	Method      string
+	Description string
+	Params      []Param
+	// Request is an example of the JSON request body type for POST methods, nil otherwise
+	Request interface{}
+	// Response is an example of the JSON response type
+	Response interface{}
+}
+
+// Endpoints lists the methods served by the example CT log. The OpenAPI description is
+// generated from this so it must be kept in step with the handler registrations.
+var Endpoints = []Endpoint{
+	{Name: "add-chain", Method: http.MethodPost, Description: "Add a certificate chain to the log. See RFC 6962 Section 4.1.",
+		Request: AddChainRequest{}, Response: AddChainResponse{}},
+	{Name: "add-pre-chain", Method: http.MethodPost, Description: "Add a precertificate chain to the log. See RFC 6962 Section 4.2.",
+		Request: AddChainRequest{}, Response: AddChainResponse{}},
+	{Name: "get-sth", Method: http.MethodGet, Description: "Retrieve the latest signed tree head. See RFC 6962 Section 4.3.",
+		Response: GetSTHResponse{}},
+	{Name: "get-sth-consistency", Method: http.MethodGet, Description: "Retrieve a consistency proof between two tree sizes. See RFC 6962 Section 4.4.",
+		Params: []Param{
+			{Name: "first", Description: "The tree size of the older tree", Type: "integer", Required: true},
+			{Name: "second", Description: "The tree size of the newer tree", Type: "integer", Required: true}},
+		Response: GetSTHConsistencyResponse{}},
+	{Name: "get-proof-by-hash", Method: http.MethodGet, Description: "Retrieve an inclusion proof for a leaf hash. See RFC 6962 Section 4.5.",
+		Params: []Param{
+			{Name: "hash", Description: "Base64 encoded leaf hash", Type: "string", Required: true},
+			{Name: "tree_size", Description: "The tree size to prove inclusion in", Type: "integer", Required: true}},
+		Response: GetProofByHashResponse{}},
+	{Name: "get-entries", Method: http.MethodGet, Description: "Retrieve a range of entries from the log. See RFC 6962 Section 4.6.",
+		Params: []Param{
+			{Name: "start", Description: "Index of the first entry to retrieve", Type: "integer", Required: true},
+			{Name: "end", Description: "Index of the last entry to retrieve, inclusive", Type: "integer", Required: true}},
+		Response: GetEntriesResponse{}},
+	{Name: "get-roots", Method: http.MethodGet, Description: "Retrieve the accepted root certificates. See RFC 6962 Section 4.7.",
+		Response: GetRootsResponse{}},
+	{Name: "get-entry-and-proof", Method: http.MethodGet, Description: "Retrieve an entry and its inclusion proof. See RFC 6962 Section 4.8.",
+		Params: []Param{
+			{Name: "leaf_index", Description: "Index of the entry to retrieve", Type: "integer", Required: true},
+			{Name: "tree_size", Description: "The tree size to prove inclusion in", Type: "integer", Required: true}},
+		Response: GetEntryAndProofResponse{}},
+	{Name: "get-log-metadata", Method: http.MethodGet, Description: "Retrieve the name and description of the log and how to contact its owner. This is not part of RFC 6962.",
+		Response: GetLogMetadataResponse{}},
+	{Name: "get-sth-and-entries", Method: http.MethodGet, Description: "Retrieve the latest signed tree head and the last entries of its tree, read together so they're consistent. This is not part of RFC 6962.",
+		Params: []Param{
+			{Name: "count", Description: "The number of entries to retrieve, counting back from the end of the tree", Type: "integer", Required: true}},
+		Response: GetSTHAndEntriesResponse{}},
+}
+
+// The structures below are the subset of OpenAPI 2.0 that we need to describe the API.
+
+type openAPIDoc struct {
+	Swagger     string                          `json:"swagger"`
+	Info        openAPIInfo                     `json:"info"`
+	BasePath    string                          `json:"basePath"`
+	Consumes    []string                        `json:"consumes"`
+	Produces    []string                        `json:"produces"`
+	Paths       map[string]map[string]openAPIOp `json:"paths"`
+	Definitions map[string]*openAPISchema       `json:"definitions"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIOp struct {
+	OperationID string                     `json:"operationId"`
+	Description string                     `json:"description"`
+	Parameters  []openAPIParam             `json:"parameters,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParam struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required"`
+	Type        string         `json:"type,omitempty"`
+	Schema      *openAPISchema `json:"schema,omitempty"`
+}
+
+type openAPIResponse struct {
+	Description string         `json:"description"`
+	Schema      *openAPISchema `json:"schema,omitempty"`
+}
+
+type openAPISchema struct {
+	Ref        string                    `json:"$ref,omitempty"`
+	Type       string                    `json:"type,omitempty"`
+	Format     string                    `json:"format,omitempty"`
+	Items      *openAPISchema            `json:"items,omitempty"`
+	Properties map[string]*openAPISchema `json:"properties,omitempty"`
+	Required   []string                  `json:"required,omitempty"`
+}
+
+// OpenAPISpec returns an OpenAPI 2.0 description of the endpoints served under basePath,
+// e.g. "/ct/v1". Request, response and error schemas are generated from the Go types so
+// they always match what the server actually sends.
+func OpenAPISpec(basePath string) ([]byte, error) {
+	doc := openAPIDoc{
+		Swagger:     "2.0",
+		Info:        openAPIInfo{Title: "Certificate Transparency v1 API", Version: "1.0"},
+		BasePath:    basePath,
+		Consumes:    []string{"application/json"},
+		Produces:    []string{"application/json"},
+		Paths:       make(map[string]map[string]openAPIOp),
+		Definitions: make(map[string]*openAPISchema),
+	}
+
+	errorSchema, err := schemaFor(reflect.TypeOf(Error{}), doc.Definitions)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range Endpoints {
+		op := openAPIOp{
+			OperationID: e.Name,
+			Description: e.Description,
+			Responses: map[string]openAPIResponse{
+				"default": {Description: "The request failed", Schema: errorSchema},
+			},
+		}
+
+		for _, p := range e.Params {
+			op.Parameters = append(op.Parameters, openAPIParam{Name: p.Name, In: "query", Description: p.Description, Required: p.Required, Type: p.Type})
+		}
+
+		if e.Request != nil {
+			schema, err := schemaFor(reflect.TypeOf(e.Request), doc.Definitions)
+
+			if err != nil {
+				return nil, err
+			}
+
+			op.Parameters = append(op.Parameters, openAPIParam{Name: "body", In: "body", Required: true, Schema: schema})
+		}
+
+		schema, err := schemaFor(reflect.TypeOf(e.Response), doc.Definitions)
+
+		if err != nil {
+			return nil, err
+		}
+
+		op.Responses["200"] = openAPIResponse{Description: "Success", Schema: schema}
+		doc.Paths["/"+e.Name] = map[string]openAPIOp{strings.ToLower(e.Method): op}
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// schemaFor builds the schema for a Go type. Structs are added to defs and referred to by
+// name so each one is only described once.
+func schemaFor(t reflect.Type, defs map[string]*openAPISchema) (*openAPISchema, error) {
+	switch t.Kind() {
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int32, reflect.Uint32:
+		return &openAPISchema{Type: "integer", Format: "int32"}, nil
+	case reflect.Int64, reflect.Uint64:
+		return &openAPISchema{Type: "integer", Format: "int64"}, nil
+	case reflect.String:
+		return &openAPISchema{Type: "string"}, nil
+	case reflect.Ptr:
+		// Pointers are only used for optional fields, the JSON is the same as the value
+		return schemaFor(t.Elem(), defs)
+	case reflect.Slice:
+		// encoding/json sends byte slices as base64 strings
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &openAPISchema{Type: "string", Format: "byte"}, nil
+		}
+
+		items, err := schemaFor(t.Elem(), defs)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &openAPISchema{Type: "array", Items: items}, nil
+	case reflect.Struct:
+		ref := &openAPISchema{Ref: "#/definitions/" + t.Name()}
+
+		if _, ok := defs[t.Name()]; ok {
+			return ref, nil
+		}
+
+		schema := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+		// Add it before processing fields in case a type refers to itself
+		defs[t.Name()] = schema
+
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag := strings.Split(field.Tag.Get("json"), ",")
+			name := tag[0]
+
+			if name == "-" || field.PkgPath != "" {
+				continue
+			}
+
+			if len(name) == 0 {
+				name = field.Name
+			}
+
+			fieldSchema, err := schemaFor(field.Type, defs)
+
+			if err != nil {
+				return nil, fmt.Errorf("field %s of %s: %v", field.Name, t.Name(), err)
+			}
+
+			schema.Properties[name] = fieldSchema
+
+			// Fields that are left out when empty can't be required
+			if len(tag) < 2 || tag[1] != "omitempty" {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+
+		return ref, nil
+	default:
+		return nil, fmt.Errorf("no OpenAPI schema for type: %v", t)
+	}
+}
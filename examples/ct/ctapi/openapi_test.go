@@ -0,0 +1,112 @@
+package ctapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	spec, err := OpenAPISpec("/ct/v1")
+
+	if err != nil {
+		t.Fatalf("Failed to generate spec: %v", err)
+	}
+
+	var doc openAPIDoc
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		t.Fatalf("Generated spec is not valid JSON: %v", err)
+	}
+
+	if got, want := doc.BasePath, "/ct/v1"; got != want {
+		t.Fatalf("Got base path %s, expected %s", got, want)
+	}
+	if got, want := len(doc.Paths), len(Endpoints); got != want {
+		t.Fatalf("Got %d paths, expected %d", got, want)
+	}
+
+	op, ok := doc.Paths["/get-proof-by-hash"]["get"]
+	if !ok {
+		t.Fatalf("No get operation for get-proof-by-hash in: %v", doc.Paths)
+	}
+	if got, want := len(op.Parameters), 2; got != want {
+		t.Fatalf("Got %d params for get-proof-by-hash, expected %d", got, want)
+	}
+	if got, want := op.Responses["200"].Schema.Ref, "#/definitions/GetProofByHashResponse"; got != want {
+		t.Fatalf("Got response schema %s, expected %s", got, want)
+	}
+	if got, want := op.Responses["default"].Schema.Ref, "#/definitions/Error"; got != want {
+		t.Fatalf("Got error schema %s, expected %s", got, want)
+	}
+
+	// POST methods take the request as the body
+	addChain, ok := doc.Paths["/add-chain"]["post"]
+	if !ok {
+		t.Fatalf("No post operation for add-chain in: %v", doc.Paths)
+	}
+	if got, want := addChain.Parameters[0].Schema.Ref, "#/definitions/AddChainRequest"; got != want {
+		t.Fatalf("Got request schema %s, expected %s", got, want)
+	}
+}
+
+func TestOpenAPISchemaMatchesJSON(t *testing.T) {
+	defs := make(map[string]*openAPISchema)
+
+	if _, err := schemaFor(reflect.TypeOf(GetEntriesResponse{}), defs); err != nil {
+		t.Fatalf("Failed to build schema: %v", err)
+	}
+
+	entry, ok := defs["GetEntriesEntry"]
+	if !ok {
+		t.Fatalf("Nested struct was not added to definitions: %v", defs)
+	}
+
+	// Property names must match the JSON field names and byte slices are base64 strings
+	want := &openAPISchema{Type: "string", Format: "byte"}
+	if got := entry.Properties["leaf_input"]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got schema %v for leaf_input, expected %v", got, want)
+	}
+
+	// Fields not sent in the JSON must not appear
+	if _, err := schemaFor(reflect.TypeOf(Error{}), defs); err != nil {
+		t.Fatalf("Failed to build schema: %v", err)
+	}
+	if _, ok := defs["Error"].Properties["Code"]; ok {
+		t.Fatal("Error schema includes field not sent in JSON")
+	}
+
+	// Fields that may be left out must not be required
+	for _, name := range defs["Error"].Required {
+		if name == "request_id" || name == "tree_size" {
+			t.Fatal("Error schema requires an omitempty field")
+		}
+	}
+
+	// Optional fields are described by the type they point to
+	want = &openAPISchema{Type: "integer", Format: "int64"}
+	if got := defs["Error"].Properties["tree_size"]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got schema %v for tree_size, expected %v", got, want)
+	}
+}
+
+func TestNewError(t *testing.T) {
+	var tests = []struct {
+		status int
+		err    error
+		want   Error
+	}{
+		{http.StatusBadRequest, errors.New("bad start"), Error{Code: http.StatusBadRequest, ErrorCode: ErrBadRequest, Message: "bad start"}},
+		{http.StatusMethodNotAllowed, nil, Error{Code: http.StatusMethodNotAllowed, ErrorCode: ErrMethodNotAllowed, Message: "Method Not Allowed"}},
+		{http.StatusServiceUnavailable, errors.New("rpc"), Error{Code: http.StatusServiceUnavailable, ErrorCode: ErrBackendUnavailable, Message: "rpc"}},
+		{http.StatusInternalServerError, errors.New("oops"), Error{Code: http.StatusInternalServerError, ErrorCode: ErrInternal, Message: "oops"}},
+		{http.StatusBadRequest, Error{ErrorCode: ErrBadRequest, Message: "typed"}, Error{Code: http.StatusBadRequest, ErrorCode: ErrBadRequest, Message: "typed"}},
+	}
+
+	for _, test := range tests {
+		if got := NewError(test.status, test.err); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("NewError(%d, %v)=%v, want %v", test.status, test.err, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Package ctapi contains the request and response structures used by the CT v1 HTTP API
+served by the example CT log, along with the typed errors it returns and a generated
+OpenAPI description of the endpoints. It has no dependencies on the server so clients
+and tools can share the same definitions.
+*/
+package ctapi
+
+// AddChainRequest is a struct for parsing JSON add-chain requests. See RFC 6962 Sections 4.1 and 4.2
+type AddChainRequest struct {
+	Chain []string `json:"chain"`
+}
+
+// AddChainResponse is a struct for marshalling add-chain responses. See RFC 6962 Sections 4.1 and 4.2
+type AddChainResponse struct {
+	SctVersion int    `json:"sct_version"`
+	ID         string `json:"id"`
+	Timestamp  uint64 `json:"timestamp"`
+	Extensions string `json:"extensions"`
+	Signature  string `json:"signature"`
+}
+
+// GetEntriesEntry is a struct that represents one element in a get-entries response
+type GetEntriesEntry struct {
+	LeafInput []byte `json:"leaf_input"`
+	ExtraData []byte `json:"extra_data"`
+}
+
+// GetEntriesResponse is a struct for marshalling get-entries respsonses. See RFC6962 Section 4.6
+type GetEntriesResponse struct {
+	Entries []GetEntriesEntry `json:"entries"`
+}
+
+// GetEntriesWithoutExtraDataEntry is a struct that represents one element in a get-entries
+// response when omit_extra_data=true is given. This is not part of RFC 6962.
+type GetEntriesWithoutExtraDataEntry struct {
+	LeafInput []byte `json:"leaf_input"`
+}
+
+// GetEntriesWithoutExtraDataResponse is a struct for marshalling get-entries responses when
+// omit_extra_data=true is given. This is not part of RFC 6962.
+type GetEntriesWithoutExtraDataResponse struct {
+	Entries []GetEntriesWithoutExtraDataEntry `json:"entries"`
+}
+
+// GetSTHResponse is a struct for marshalling get-sth responses. See RFC 6962 Section 4.3
+type GetSTHResponse struct {
+	TreeSize        int64  `json:"tree_size"`
+	TimestampMillis int64  `json:"timestamp"`
+	RootHash        []byte `json:"sha256_root_hash"`
+	Signature       []byte `json:"tree_head_signature"`
+}
+
+// GetSTHAndEntriesResponse is a struct for marshalling get-sth-and-entries responses. This is
+// not part of RFC 6962. The entries are the last ones in the tree of the STH, in order, so the
+// last has index tree_size - 1.
+type GetSTHAndEntriesResponse struct {
+	STH     GetSTHResponse    `json:"sth"`
+	Entries []GetEntriesEntry `json:"entries"`
+}
+
+// GetProofByHashResponse is a struct for marshalling get-proof-by-hash responses. See RFC 6962
+// section 4.5
+type GetProofByHashResponse struct {
+	LeafIndex int64    `json:"leaf_index"`
+	AuditPath [][]byte `json:"audit_path"`
+}
+
+// GetAllProofsByHashResponse is a struct for marshalling get-proof-by-hash responses when
+// all=true is given. This is not part of RFC 6962. There is a proof for every leaf with the
+// requested hash, in leaf index order.
+type GetAllProofsByHashResponse struct {
+	Proofs []GetProofByHashResponse `json:"proofs"`
+}
+
+// GetSTHConsistencyResponse is a struct for mashalling get-sth-consistency responses. See
+// RFC 6962 section 4.4
+type GetSTHConsistencyResponse struct {
+	Consistency [][]byte `json:"consistency"`
+}
+
+// GetRootsResponse is a struct for marshalling get-roots responses. See RFC 6962 Section 4.7
+type GetRootsResponse struct {
+	Certificates [][]byte `json:"certificates"`
+}
+
+// GetEntryAndProofResponse is a struct for marshalling get-entry-and-proof responses. See
+// RFC 6962 Section 4.8
+type GetEntryAndProofResponse struct {
+	LeafInput []byte   `json:"leaf_input"`
+	ExtraData []byte   `json:"extra_data"`
+	AuditPath [][]byte `json:"audit_path"`
+}
+
+// GetLogMetadataResponse is a struct for marshalling get-log-metadata responses. This is not
+// part of RFC 6962, it tells people what the log is and who runs it.
+type GetLogMetadataResponse struct {
+	DisplayName  string `json:"display_name"`
+	Description  string `json:"description"`
+	OwnerContact string `json:"owner_contact"`
+	// CreateTimeMillis is when the log was created, in milliseconds since the epoch
+	CreateTimeMillis int64 `json:"create_time"`
+}
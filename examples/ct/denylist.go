@@ -0,0 +1,322 @@
+package ct
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/certificate-transparency/go/x509"
+	"github.com/google/trillian/util"
+)
+
+// DenylistKind says what the hash in a denylist entry is of.
+type DenylistKind string
+
+const (
+	// DenyLeafHash entries match the SHA-256 hash of a submitted end entity certificate or
+	// precertificate. The Merkle leaf hash can't be used as it includes the SCT timestamp.
+	DenyLeafHash DenylistKind = "leaf_hash"
+	// DenyIssuerSPKIHash entries match the SHA-256 hash of the subject public key info of any
+	// issuer in a submitted chain, so they stop everything issued under a key.
+	DenyIssuerSPKIHash DenylistKind = "issuer_spki_hash"
+)
+
+// errEmptyChain is returned by Check if it's given no certificates, which verification
+// should have prevented
+var errEmptyChain = errors.New("empty chain")
+
+// DenylistEntry describes an entry in a Denylist.
+type DenylistEntry struct {
+	// Kind is what the hash is of
+	Kind DenylistKind `json:"kind"`
+	// SHA256 is the hex encoded hash
+	SHA256 string `json:"sha256"`
+	// Expires is when the entry stops applying in RFC 3339 format, if it ever does
+	Expires string `json:"expires,omitempty"`
+}
+
+// denylistAddRequest is the body of a denylist-add admin request
+type denylistAddRequest struct {
+	Kind   DenylistKind `json:"kind"`
+	SHA256 string       `json:"sha256"`
+	// TTLSeconds is how long the entry applies for, zero means until it's removed
+	TTLSeconds int64 `json:"ttl_seconds"`
+}
+
+// denylistRemoveRequest is the body of a denylist-remove admin request
+type denylistRemoveRequest struct {
+	Kind   DenylistKind `json:"kind"`
+	SHA256 string       `json:"sha256"`
+}
+
+// denylistAdminResponse is the body of the response to a denylist-add or denylist-remove
+// admin request
+type denylistAdminResponse struct {
+	// Changed is false if the request made no difference, e.g. the entry wasn't there
+	Changed bool `json:"changed"`
+	// EntryCount is the number of entries that apply after the request
+	EntryCount int `json:"entry_count"`
+}
+
+// denylistResponse is the body of the response to a denylist admin request
+type denylistResponse struct {
+	Entries []DenylistEntry `json:"entries"`
+}
+
+type denylistKey struct {
+	kind DenylistKind
+	hash [sha256.Size]byte
+}
+
+// Denylist rejects submissions that match known junk, so that operators can stop a spam
+// campaign from filling a log without a code change or restart. Its Check method is a
+// SubmissionPolicy, so it runs before an SCT is issued or the leaf is queued. Entries can
+// expire, and are managed with the admin endpoints registered by WithDenylist. They're only
+// held in memory, so each frontend has its own. It is safe for concurrent use.
+type Denylist struct {
+	timeSource util.TimeSource
+
+	// mu guards the fields below it
+	mu sync.Mutex
+	// entries maps each entry to when it expires, zero if it doesn't
+	entries map[denylistKey]time.Time
+	// rejected counts the submissions rejected
+	rejected int64
+}
+
+// NewDenylist creates an empty Denylist, with entry expiry measured by timeSource.
+func NewDenylist(timeSource util.TimeSource) *Denylist {
+	return &Denylist{timeSource: timeSource, entries: make(map[denylistKey]time.Time)}
+}
+
+// Add adds an entry that applies for ttl, or until it's removed if ttl is zero. Adding an entry
+// that's already there changes when it expires. It returns false if the entry was already
+// there.
+func (d *Denylist) Add(kind DenylistKind, hash [sha256.Size]byte, ttl time.Duration) (bool, error) {
+	if kind != DenyLeafHash && kind != DenyIssuerSPKIHash {
+		return false, fmt.Errorf("unknown denylist entry kind: %q", kind)
+	}
+
+	if ttl < 0 {
+		return false, fmt.Errorf("negative denylist entry TTL: %v", ttl)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.removeExpired()
+
+	var expires time.Time
+	if ttl > 0 {
+		expires = d.timeSource.Now().Add(ttl)
+	}
+
+	key := denylistKey{kind: kind, hash: hash}
+	_, exists := d.entries[key]
+	d.entries[key] = expires
+
+	return !exists, nil
+}
+
+// Remove removes an entry and returns false if it wasn't there.
+func (d *Denylist) Remove(kind DenylistKind, hash [sha256.Size]byte) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.removeExpired()
+
+	key := denylistKey{kind: kind, hash: hash}
+	_, exists := d.entries[key]
+	delete(d.entries, key)
+
+	return exists
+}
+
+// Entries returns the entries that currently apply, sorted by kind and hash.
+func (d *Denylist) Entries() []DenylistEntry {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.removeExpired()
+
+	entries := make([]DenylistEntry, 0, len(d.entries))
+	for key, expires := range d.entries {
+		entry := DenylistEntry{Kind: key.kind, SHA256: hex.EncodeToString(key.hash[:])}
+
+		if !expires.IsZero() {
+			entry.Expires = expires.UTC().Format(time.RFC3339)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	sort.Sort(denylistEntriesByKey(entries))
+	return entries
+}
+
+// Stats returns the number of entries that currently apply and the number of submissions
+// that have been rejected.
+func (d *Denylist) Stats() (entries int, rejected int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.removeExpired()
+
+	return len(d.entries), d.rejected
+}
+
+// Check is a SubmissionPolicy that rejects chains whose leaf or any of whose issuers match an
+// entry.
+func (d *Denylist) Check(chain []*x509.Certificate, isPrecert bool) error {
+	if len(chain) == 0 {
+		return errEmptyChain
+	}
+
+	keys := []denylistKey{{kind: DenyLeafHash, hash: sha256.Sum256(chain[0].Raw)}}
+
+	for _, issuer := range chain[1:] {
+		keys = append(keys, denylistKey{kind: DenyIssuerSPKIHash, hash: sha256.Sum256(issuer.RawSubjectPublicKeyInfo)})
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.timeSource.Now()
+
+	for _, key := range keys {
+		if expires, ok := d.entries[key]; ok && (expires.IsZero() || now.Before(expires)) {
+			d.rejected++
+			glog.V(logVerboseLevel).Infof("Rejected submission matching denylist entry %s %x", key.kind, key.hash)
+
+			return fmt.Errorf("submission matches denylist entry %s %x", key.kind, key.hash)
+		}
+	}
+
+	return nil
+}
+
+// removeExpired deletes the entries that have expired. Must be called with mu held.
+func (d *Denylist) removeExpired() {
+	now := d.timeSource.Now()
+
+	for key, expires := range d.entries {
+		if !expires.IsZero() && !now.Before(expires) {
+			delete(d.entries, key)
+		}
+	}
+}
+
+type denylistEntriesByKey []DenylistEntry
+
+func (d denylistEntriesByKey) Len() int      { return len(d) }
+func (d denylistEntriesByKey) Swap(i, j int) { d[i], d[j] = d[j], d[i] }
+func (d denylistEntriesByKey) Less(i, j int) bool {
+	if d[i].Kind != d[j].Kind {
+		return d[i].Kind < d[j].Kind
+	}
+	return d[i].SHA256 < d[j].SHA256
+}
+
+// parseDenylistHash decodes the hex encoded hash in a denylist admin request
+func parseDenylistHash(s string) ([sha256.Size]byte, error) {
+	var hash [sha256.Size]byte
+	decoded, err := hex.DecodeString(s)
+
+	if err != nil || len(decoded) != len(hash) {
+		return hash, fmt.Errorf("invalid SHA-256 hash: %q", s)
+	}
+
+	copy(hash[:], decoded)
+	return hash, nil
+}
+
+// writeDenylistAdminResponse writes the outcome of a change to the denylist
+func writeDenylistAdminResponse(w http.ResponseWriter, denylist *Denylist, changed bool) (int, error) {
+	entries, _ := denylist.Stats()
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+
+	if err := json.NewEncoder(w).Encode(denylistAdminResponse{Changed: changed, EntryCount: entries}); err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("failed to write admin response: %v", err)
+	}
+
+	return http.StatusOK, nil
+}
+
+// wrappedDenylistAddHandler adds the entry in the request to the denylist
+func wrappedDenylistAddHandler(denylist *Denylist) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		if !enforceMethod(w, r, httpMethodPost) {
+			return http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method)
+		}
+
+		var req denylistAddRequest
+		if err := decodeAdminRequest(r, "denylist-add", &req); err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		hash, err := parseDenylistHash(req.SHA256)
+
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		changed, err := denylist.Add(req.Kind, hash, time.Duration(req.TTLSeconds)*time.Second)
+
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		glog.Infof("Denylist admin added %s %x for %ds: %v", req.Kind, hash, req.TTLSeconds, changed)
+
+		return writeDenylistAdminResponse(w, denylist, changed)
+	}
+}
+
+// wrappedDenylistRemoveHandler removes the entry in the request from the denylist
+func wrappedDenylistRemoveHandler(denylist *Denylist) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		if !enforceMethod(w, r, httpMethodPost) {
+			return http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method)
+		}
+
+		var req denylistRemoveRequest
+		if err := decodeAdminRequest(r, "denylist-remove", &req); err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		hash, err := parseDenylistHash(req.SHA256)
+
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		changed := denylist.Remove(req.Kind, hash)
+		glog.Infof("Denylist admin removed %s %x: %v", req.Kind, hash, changed)
+
+		return writeDenylistAdminResponse(w, denylist, changed)
+	}
+}
+
+// wrappedGetDenylistHandler lists the entries that currently apply
+func wrappedGetDenylistHandler(denylist *Denylist) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		if !enforceMethod(w, r, httpMethodGet) {
+			return http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method)
+		}
+
+		w.Header().Set(contentTypeHeader, contentTypeJSON)
+
+		if err := json.NewEncoder(w).Encode(denylistResponse{Entries: denylist.Entries()}); err != nil {
+			return http.StatusInternalServerError, fmt.Errorf("failed to write denylist: %v", err)
+		}
+
+		return http.StatusOK, nil
+	}
+}
@@ -0,0 +1,166 @@
+package ct
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/certificate-transparency/go/x509"
+	"github.com/google/trillian/examples/ct/testonly"
+	"github.com/google/trillian/util"
+)
+
+func TestDenylistCheck(t *testing.T) {
+	leaf := parsePEMCertOrDie(t, testonly.LeafSignedByFakeIntermediateCertPem)
+	intermediate := parsePEMCertOrDie(t, testonly.FakeIntermediateCertPem)
+	ca := parsePEMCertOrDie(t, testonly.FakeCACertPem)
+	chain := []*x509.Certificate{leaf, intermediate}
+
+	for _, test := range []struct {
+		desc string
+		kind DenylistKind
+		hash [sha256.Size]byte
+		ok   bool
+	}{
+		{"leaf hash", DenyLeafHash, sha256.Sum256(leaf.Raw), false},
+		{"issuer key", DenyIssuerSPKIHash, sha256.Sum256(intermediate.RawSubjectPublicKeyInfo), false},
+		// The leaf's own key isn't an issuer
+		{"leaf key", DenyIssuerSPKIHash, sha256.Sum256(leaf.RawSubjectPublicKeyInfo), true},
+		// The kinds don't match each other's hashes
+		{"issuer hash as leaf", DenyLeafHash, sha256.Sum256(intermediate.RawSubjectPublicKeyInfo), true},
+		// The root isn't part of the chain that's checked
+		{"root key", DenyIssuerSPKIHash, sha256.Sum256(ca.RawSubjectPublicKeyInfo), true},
+	} {
+		denylist := NewDenylist(fakeTimeSource)
+
+		if _, err := denylist.Add(test.kind, test.hash, 0); err != nil {
+			t.Fatalf("%s: Add()=%v", test.desc, err)
+		}
+
+		if err := denylist.Check(chain, false); (err == nil) != test.ok {
+			t.Errorf("%s: Check()=%v, expected ok=%v", test.desc, err, test.ok)
+		}
+	}
+}
+
+func TestDenylistEntries(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+	denylist := NewDenylist(ts)
+	leaf := parsePEMCertOrDie(t, testonly.LeafSignedByFakeIntermediateCertPem)
+	leafHash := sha256.Sum256(leaf.Raw)
+	otherHash := sha256.Sum256([]byte("other"))
+
+	if _, err := denylist.Add("cert_hash", leafHash, 0); err == nil {
+		t.Error("Add() of an unknown kind succeeded")
+	}
+
+	if _, err := denylist.Add(DenyLeafHash, leafHash, -time.Second); err == nil {
+		t.Error("Add() with a negative TTL succeeded")
+	}
+
+	if added, err := denylist.Add(DenyLeafHash, leafHash, time.Hour); !added || err != nil {
+		t.Fatalf("Add()=%v, %v, expected true, nil", added, err)
+	}
+
+	if added, err := denylist.Add(DenyIssuerSPKIHash, otherHash, 0); !added || err != nil {
+		t.Fatalf("Add()=%v, %v, expected true, nil", added, err)
+	}
+
+	want := []DenylistEntry{
+		{Kind: DenyIssuerSPKIHash, SHA256: fmt.Sprintf("%x", otherHash)},
+		{Kind: DenyLeafHash, SHA256: fmt.Sprintf("%x", leafHash), Expires: "2016-07-22T12:01:13Z"},
+	}
+
+	if got := denylist.Entries(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Entries()=%v, expected %v", got, want)
+	}
+
+	// Once it has expired the leaf is accepted again
+	ts.FakeTime = fakeTime.Add(time.Hour)
+
+	if err := denylist.Check([]*x509.Certificate{leaf}, false); err != nil {
+		t.Errorf("Check()=%v after the entry expired", err)
+	}
+
+	if entries, rejected := denylist.Stats(); entries != 1 || rejected != 0 {
+		t.Errorf("Stats()=%d, %d, expected 1, 0", entries, rejected)
+	}
+
+	if !denylist.Remove(DenyIssuerSPKIHash, otherHash) {
+		t.Error("Remove() returned false for an entry that was there")
+	}
+
+	if denylist.Remove(DenyIssuerSPKIHash, otherHash) {
+		t.Error("Remove() returned true for an entry that wasn't there")
+	}
+}
+
+func TestDenylistAdminHandlers(t *testing.T) {
+	denylist := NewDenylist(fakeTimeSource)
+	c := NewCTRequestHandlers(0x42, nil, nil, nil, WithDenylist(denylist, "secret"))
+	mux := http.NewServeMux()
+	c.RegisterHandlers(mux)
+
+	leaf := parsePEMCertOrDie(t, testonly.LeafSignedByFakeIntermediateCertPem)
+	leafHash := fmt.Sprintf("%x", sha256.Sum256(leaf.Raw))
+
+	for _, test := range []struct {
+		desc   string
+		method string
+		path   string
+		auth   string
+		body   string
+		status int
+		want   interface{}
+	}{
+		{"no token", "POST", "/admin/denylist-add", "", fmt.Sprintf(`{"kind":"leaf_hash","sha256":"%s"}`, leafHash), http.StatusUnauthorized, nil},
+		{"bad hash", "POST", "/admin/denylist-add", "Bearer secret", `{"kind":"leaf_hash","sha256":"abcd"}`, http.StatusBadRequest, nil},
+		{"bad kind", "POST", "/admin/denylist-add", "Bearer secret", fmt.Sprintf(`{"kind":"leaf","sha256":"%s"}`, leafHash), http.StatusBadRequest, nil},
+		{"unknown field", "POST", "/admin/denylist-add", "Bearer secret", fmt.Sprintf(`{"kind":"leaf_hash","sha256":"%s","ttl":1}`, leafHash), http.StatusBadRequest, nil},
+		{"add", "POST", "/admin/denylist-add", "Bearer secret", fmt.Sprintf(`{"kind":"leaf_hash","sha256":"%s","ttl_seconds":60}`, leafHash), http.StatusOK,
+			&denylistAdminResponse{Changed: true, EntryCount: 1}},
+		{"list", "GET", "/admin/denylist", "Bearer secret", "", http.StatusOK,
+			&denylistResponse{Entries: []DenylistEntry{{Kind: DenyLeafHash, SHA256: leafHash, Expires: "2016-07-22T11:02:13Z"}}}},
+		{"remove", "POST", "/admin/denylist-remove", "Bearer secret", fmt.Sprintf(`{"kind":"leaf_hash","sha256":"%s"}`, leafHash), http.StatusOK,
+			&denylistAdminResponse{Changed: true, EntryCount: 0}},
+		{"remove again", "POST", "/admin/denylist-remove", "Bearer secret", fmt.Sprintf(`{"kind":"leaf_hash","sha256":"%s"}`, leafHash), http.StatusOK,
+			&denylistAdminResponse{Changed: false, EntryCount: 0}},
+	} {
+		req, err := http.NewRequest(test.method, "http://example.com"+test.path, bytes.NewBufferString(test.body))
+
+		if err != nil {
+			t.Fatalf("%s: failed to create request: %v", test.desc, err)
+		}
+
+		if len(test.auth) > 0 {
+			req.Header.Set(authorizationHeader, test.auth)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		if got, want := w.Code, test.status; got != want {
+			t.Errorf("%s: got status %d, expected %d: %s", test.desc, got, want, w.Body.String())
+			continue
+		}
+
+		if test.want == nil {
+			continue
+		}
+
+		got := reflect.New(reflect.TypeOf(test.want).Elem()).Interface()
+		if err := json.Unmarshal(w.Body.Bytes(), got); err != nil {
+			t.Fatalf("%s: failed to unmarshal response: %v", test.desc, err)
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got response %+v, expected %+v", test.desc, got, test.want)
+		}
+	}
+}
@@ -6,6 +6,12 @@ IMPORTANT: Only code rooted within this part of the tree should refer to the CT
 Github repository. Other parts of the system must not assume that the data they're
 processing is X.509 or CT related.
 
+A CTRequestHandlers serves one log. A frontend serves several logs by creating handlers for
+each LogConfig, usually loaded with LoadLogConfigs, which gives each log its own tree ID,
+backend, keys and trusted roots, and passing the log's Prefix to WithPathPrefix so that its
+endpoints are registered under their own path, e.g. /pilot/ct/v1/add-chain. ct_server does
+this for every log in its --log_config file.
+
 The CT repository can be found at: https://github.com/google/certificate-transparency
 */
 package ct
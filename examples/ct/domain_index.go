@@ -0,0 +1,537 @@
+package ct
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	ct "github.com/google/certificate-transparency/go"
+	"github.com/google/certificate-transparency/go/x509"
+	"github.com/google/trillian"
+	terrors "github.com/google/trillian/errors"
+	"golang.org/x/net/context"
+)
+
+const (
+	// The name of the get-entries-by-domain parameter holding the domain to search for
+	domainIndexParamDomain = "domain"
+	// The name of the get-entries-by-domain parameter holding the leaf index to start from,
+	// the next value of the previous page
+	domainIndexParamStart = "start"
+	// The name of the get-entries-by-domain parameter holding the max number of entries
+	domainIndexParamLimit = "limit"
+	// The name of the get-entries-by-domain parameter that includes subdomains if true
+	domainIndexParamSubdomains = "include_subdomains"
+	// defaultDomainIndexLimit is the number of entries returned if no limit is given
+	defaultDomainIndexLimit = 100
+	// maxDomainIndexLimit is the most entries returned by one request
+	maxDomainIndexLimit = 1000
+	// maxDomainLength is the longest name that can be indexed, see RFC 1035 section 2.3.4
+	maxDomainLength = 253
+)
+
+// errNoDomainIndexDB is returned when a domain index is created without a database
+var errNoDomainIndexDB = errors.New("domain index needs a database")
+
+// domainIndexSchema creates the tables of the domain index if they don't exist. The
+// statements work with MySQL and SQLite. Domains are stored with their labels reversed, e.g.
+// com.example.www, so that the subdomains of a name sort together after it.
+var domainIndexSchema = []string{
+	`CREATE TABLE IF NOT EXISTS DomainIndex(
+		LogId                BIGINT NOT NULL,
+		Domain               VARCHAR(255) NOT NULL,
+		LeafIndex            BIGINT NOT NULL,
+		IsPrecert            BOOLEAN NOT NULL,
+		PRIMARY KEY(LogId, Domain, LeafIndex)
+	)`,
+	`CREATE TABLE IF NOT EXISTS DomainIndexProgress(
+		LogId                BIGINT NOT NULL,
+		NextLeafIndex        BIGINT NOT NULL,
+		PRIMARY KEY(LogId)
+	)`,
+}
+
+const selectDomainIndexProgressSql = "SELECT NextLeafIndex FROM DomainIndexProgress WHERE LogId=?"
+const insertDomainIndexProgressSql = "INSERT INTO DomainIndexProgress(LogId, NextLeafIndex) VALUES(?, 0)"
+const updateDomainIndexProgressSql = "UPDATE DomainIndexProgress SET NextLeafIndex=? WHERE LogId=? AND NextLeafIndex=?"
+const insertDomainIndexSql = "INSERT INTO DomainIndex(LogId, Domain, LeafIndex, IsPrecert) VALUES(?, ?, ?, ?)"
+const selectDomainIndexSql = `SELECT DISTINCT LeafIndex, IsPrecert FROM DomainIndex
+		 WHERE LogId=? AND Domain=? AND LeafIndex>=? ORDER BY LeafIndex LIMIT ?`
+const selectDomainIndexSubdomainsSql = `SELECT DISTINCT LeafIndex, IsPrecert FROM DomainIndex
+		 WHERE LogId=? AND (Domain=? OR (Domain>? AND Domain<?)) AND LeafIndex>=? ORDER BY LeafIndex LIMIT ?`
+
+// DomainIndexEntry is a log entry in a get-entries-by-domain response
+type DomainIndexEntry struct {
+	LeafIndex int64 `json:"leaf_index"`
+	// Precert is set if the entry is a precertificate
+	Precert bool `json:"precert"`
+}
+
+// DomainIndexResponse is the body of a get-entries-by-domain response.
+type DomainIndexResponse struct {
+	// Entries are the entries for the domain in index order, they can be fetched with
+	// get-entries
+	Entries []DomainIndexEntry `json:"entries"`
+	// Next is the start of the next page, it's zero if there are no more entries
+	Next int64 `json:"next,omitempty"`
+	// IndexedSize is the number of entries of the log that have been indexed, later entries
+	// aren't found yet
+	IndexedSize int64 `json:"indexed_size"`
+}
+
+// domainIndexRow is a name that appears in a log entry
+type domainIndexRow struct {
+	domain    string
+	leafIndex int64
+	isPrecert bool
+}
+
+// DomainIndex records the names in the certificates and precertificates of a log, their
+// subject common names and DNS subject alternative names, in a SQL database so that the
+// entries for a domain can be found without fetching the whole log. An indexer, see Run,
+// fetches integrated leaves from the backend in order and records their names along with
+// how far it has got in one transaction, so it can be restarted at any time. Several
+// frontends can share a database, each batch is only recorded by one of them. The index is
+// served on get-entries-by-domain, see WithDomainIndex. It is safe for concurrent use.
+type DomainIndex struct {
+	db    *sql.DB
+	logID int64
+
+	// mu guards the fields below it
+	mu sync.Mutex
+	// indexed is the number of entries that had been indexed when last checked
+	indexed int64
+	// names counts the names recorded by this indexer
+	names int64
+	// unparseable counts the entries whose certificate couldn't be parsed, they're skipped
+	unparseable int64
+}
+
+// NewDomainIndex creates a DomainIndex for a log in db, creating its tables if they don't
+// exist. db can be MySQL or SQLite.
+func NewDomainIndex(db *sql.DB, logID int64) (*DomainIndex, error) {
+	if db == nil {
+		return nil, errNoDomainIndexDB
+	}
+
+	for _, stmt := range domainIndexSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, fmt.Errorf("failed to create domain index tables: %v", err)
+		}
+	}
+
+	index := &DomainIndex{db: db, logID: logID}
+	indexed, err := index.progress()
+
+	if err == sql.ErrNoRows {
+		// Another frontend could create the row first, in which case it's there to be read
+		if _, err := db.Exec(insertDomainIndexProgressSql, logID); err != nil {
+			glog.Warningf("Failed to create domain index progress for log %d, checking if it exists: %v", logID, err)
+		}
+
+		indexed, err = index.progress()
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read domain index progress: %v", err)
+	}
+
+	index.indexed = indexed
+
+	return index, nil
+}
+
+// progress reads the number of entries that have been indexed
+func (d *DomainIndex) progress() (int64, error) {
+	var next int64
+	err := d.db.QueryRow(selectDomainIndexProgressSql, d.logID).Scan(&next)
+	return next, err
+}
+
+// Run indexes the log every interval until done is closed. Each pass indexes batches of up
+// to batchSize entries until it catches up with the latest tree size of the backend, with
+// each RPC limited by rpcDeadline.
+func (d *DomainIndex) Run(done <-chan struct{}, client trillian.TrillianLogClient, interval, rpcDeadline time.Duration, batchSize int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), rpcDeadline)
+			indexed, err := d.IndexBatch(ctx, client, batchSize)
+			cancel()
+
+			if err != nil {
+				glog.Warningf("Failed to index domains of log %d: %v", d.logID, err)
+				break
+			}
+
+			if indexed < batchSize {
+				break
+			}
+		}
+	}
+}
+
+// IndexBatch indexes up to batchSize entries following those already indexed, as far as the
+// latest tree size of the backend, and returns how many it indexed. It returns 0 if another
+// indexer recorded the same entries first.
+func (d *DomainIndex) IndexBatch(ctx context.Context, client trillian.TrillianLogClient, batchSize int) (int, error) {
+	if batchSize <= 0 {
+		return 0, fmt.Errorf("invalid domain index batch size: %d", batchSize)
+	}
+
+	start, err := d.progress()
+
+	if err != nil {
+		return 0, err
+	}
+
+	d.setIndexed(start)
+
+	rootResponse, err := client.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: d.logID})
+
+	if err != nil || !rpcStatusOK(rootResponse.GetStatus()) {
+		return 0, backendError("GetLatestSignedLogRoot", err, rootResponse.GetStatus())
+	}
+
+	if rootResponse.GetSignedLogRoot() == nil {
+		return 0, terrors.New(terrors.Backend, "backend GetLatestSignedLogRoot returned no root")
+	}
+
+	end := rootResponse.GetSignedLogRoot().TreeSize
+
+	if end > start+int64(batchSize) {
+		end = start + int64(batchSize)
+	}
+
+	if end <= start {
+		return 0, nil
+	}
+
+	request := trillian.GetLeavesByIndexRequest{LogId: d.logID, LeafIndex: buildIndicesForRange(start, end-1), OmitExtraData: true}
+	response, err := client.GetLeavesByIndex(ctx, &request)
+
+	if err != nil || !rpcStatusOK(response.GetStatus()) {
+		return 0, backendError("GetLeavesByIndex", err, response.GetStatus())
+	}
+
+	if expected, got := end-start, int64(len(response.Leaves)); got != expected {
+		return 0, terrors.Errorf(terrors.Integrity, "backend returned %d leaves, expected %d", got, expected)
+	}
+
+	if err := isResponseContiguousRange(response, start, end-1); err != nil {
+		return 0, terrors.Errorf(terrors.Integrity, "backend leaves for domain index: %v", err)
+	}
+
+	var rows []domainIndexRow
+	unparseable := int64(0)
+
+	for _, leaf := range response.Leaves {
+		domains, isPrecert, err := leafDomains(leaf.LeafData)
+
+		if err != nil {
+			glog.Warningf("Failed to get domains of leaf %d of log %d, skipping it: %v", leaf.LeafIndex, d.logID, err)
+			unparseable++
+			continue
+		}
+
+		for _, domain := range domains {
+			rows = append(rows, domainIndexRow{domain: reverseDomain(domain), leafIndex: leaf.LeafIndex, isPrecert: isPrecert})
+		}
+	}
+
+	recorded, err := d.record(rows, start, end)
+
+	if err != nil || !recorded {
+		return 0, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.indexed = end
+	d.names += int64(len(rows))
+	d.unparseable += unparseable
+
+	return int(end - start), nil
+}
+
+// record inserts rows and moves the progress of the index from start to end in one
+// transaction. It returns false without inserting anything if the progress isn't at start,
+// because another indexer has already recorded the entries.
+func (d *DomainIndex) record(rows []domainIndexRow, start, end int64) (bool, error) {
+	tx, err := d.db.Begin()
+
+	if err != nil {
+		return false, err
+	}
+
+	// Moving the progress first makes concurrent indexers wait for each other here
+	result, err := tx.Exec(updateDomainIndexProgressSql, end, d.logID, start)
+
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if updated, err := result.RowsAffected(); err != nil || updated != 1 {
+		tx.Rollback()
+		return false, err
+	}
+
+	for _, row := range rows {
+		if _, err := tx.Exec(insertDomainIndexSql, d.logID, row.domain, row.leafIndex, row.isPrecert); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (d *DomainIndex) setIndexed(indexed int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.indexed = indexed
+}
+
+// Lookup returns up to limit entries for domain, in index order, starting at the entry with
+// index start. If includeSubdomains is set, entries for names under the domain are included
+// too, including wildcards. The response says where the next page starts.
+func (d *DomainIndex) Lookup(domain string, includeSubdomains bool, start int64, limit int) (DomainIndexResponse, error) {
+	name, ok := normalizeDomain(domain)
+
+	if !ok {
+		return DomainIndexResponse{}, terrors.Errorf(terrors.InvalidRange, "invalid domain: %q", domain)
+	}
+
+	indexed, err := d.progress()
+
+	if err != nil {
+		return DomainIndexResponse{}, terrors.Errorf(terrors.Backend, "failed to read domain index progress: %v", err)
+	}
+
+	reversed := reverseDomain(name)
+	var rows *sql.Rows
+
+	// One more than the limit is fetched to see if there's another page. Subdomains sort
+	// between the reversed name followed by '.' and by '/', the next character.
+	if includeSubdomains {
+		rows, err = d.db.Query(selectDomainIndexSubdomainsSql, d.logID, reversed, reversed+".", reversed+"/", start, limit+1)
+	} else {
+		rows, err = d.db.Query(selectDomainIndexSql, d.logID, reversed, start, limit+1)
+	}
+
+	if err != nil {
+		return DomainIndexResponse{}, terrors.Errorf(terrors.Backend, "failed to query domain index: %v", err)
+	}
+
+	defer rows.Close()
+
+	response := DomainIndexResponse{Entries: []DomainIndexEntry{}, IndexedSize: indexed}
+
+	for rows.Next() {
+		var entry DomainIndexEntry
+
+		if err := rows.Scan(&entry.LeafIndex, &entry.Precert); err != nil {
+			return DomainIndexResponse{}, terrors.Errorf(terrors.Backend, "failed to read domain index: %v", err)
+		}
+
+		if len(response.Entries) == limit {
+			response.Next = entry.LeafIndex
+			break
+		}
+
+		response.Entries = append(response.Entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return DomainIndexResponse{}, terrors.Errorf(terrors.Backend, "failed to read domain index: %v", err)
+	}
+
+	return response, nil
+}
+
+// Stats returns the number of entries indexed, the number of names this indexer has recorded
+// and the number of entries it skipped because they couldn't be parsed.
+func (d *DomainIndex) Stats() (indexed, names, unparseable int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.indexed, d.names, d.unparseable
+}
+
+// leafDomains returns the distinct names in the certificate or precertificate of a
+// serialized MerkleTreeLeaf and whether it's a precertificate.
+func leafDomains(leafData []byte) ([]string, bool, error) {
+	leaf, err := ct.ReadMerkleTreeLeaf(bytes.NewReader(leafData))
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	var cert *x509.Certificate
+	isPrecert := false
+
+	switch entry := leaf.TimestampedEntry; entry.EntryType {
+	case ct.X509LogEntryType:
+		cert, err = x509.ParseCertificate(entry.X509Entry)
+	case ct.PrecertLogEntryType:
+		cert, err = x509.ParseTBSCertificate(entry.PrecertEntry.TBSCertificate)
+		isPrecert = true
+	default:
+		return nil, false, fmt.Errorf("unknown entry type: %v", entry.EntryType)
+	}
+
+	// Non fatal errors don't stop the names being read
+	if _, ok := err.(x509.NonFatalErrors); err != nil && (!ok || cert == nil) {
+		return nil, false, err
+	}
+
+	return certDomains(cert), isPrecert, nil
+}
+
+// certDomains returns the distinct names in the subject common name and DNS subject
+// alternative names of cert, normalized and sorted. Common names that aren't domain names
+// are left out.
+func certDomains(cert *x509.Certificate) []string {
+	seen := make(map[string]bool)
+	var domains []string
+
+	for _, name := range append([]string{cert.Subject.CommonName}, cert.DNSNames...) {
+		if domain, ok := normalizeDomain(name); ok && !seen[domain] {
+			seen[domain] = true
+			domains = append(domains, domain)
+		}
+	}
+
+	sort.Strings(domains)
+
+	return domains
+}
+
+// normalizeDomain returns name in lower case without a trailing dot. It returns false if
+// name isn't a domain name, or a wildcard one, that can be indexed.
+func normalizeDomain(name string) (string, bool) {
+	domain := strings.TrimSuffix(strings.ToLower(name), ".")
+
+	if len(domain) == 0 || len(domain) > maxDomainLength {
+		return "", false
+	}
+
+	for _, label := range strings.Split(domain, ".") {
+		if len(label) == 0 {
+			return "", false
+		}
+
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '*') {
+				return "", false
+			}
+		}
+	}
+
+	return domain, true
+}
+
+// reverseDomain reverses the labels of a domain name, www.example.com becomes
+// com.example.www.
+func reverseDomain(domain string) string {
+	labels := strings.Split(domain, ".")
+
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+
+	return strings.Join(labels, ".")
+}
+
+// wrappedGetEntriesByDomainHandler serves the entries of the log for a domain, a page at a
+// time
+func wrappedGetEntriesByDomainHandler(index *DomainIndex) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		if !enforceMethod(w, r, httpMethodGet) {
+			return http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method)
+		}
+
+		domain, includeSubdomains, start, limit, err := parseGetEntriesByDomainParams(r)
+
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		response, err := index.Lookup(domain, includeSubdomains, start, limit)
+
+		if err != nil {
+			return errorStatus(err)
+		}
+
+		w.Header().Set(contentTypeHeader, contentTypeJSON)
+
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			return http.StatusInternalServerError, fmt.Errorf("failed to write entries by domain: %v", err)
+		}
+
+		return http.StatusOK, nil
+	}
+}
+
+// parseGetEntriesByDomainParams returns the parameters of a get-entries-by-domain request,
+// with defaults for the optional ones.
+func parseGetEntriesByDomainParams(r *http.Request) (string, bool, int64, int, error) {
+	domain := r.FormValue(domainIndexParamDomain)
+
+	if _, ok := normalizeDomain(domain); !ok {
+		return "", false, 0, 0, fmt.Errorf("invalid domain: %q", domain)
+	}
+
+	includeSubdomains := false
+	start := int64(0)
+	limit := defaultDomainIndexLimit
+	var err error
+
+	if param := r.FormValue(domainIndexParamSubdomains); len(param) > 0 {
+		if includeSubdomains, err = strconv.ParseBool(param); err != nil {
+			return "", false, 0, 0, fmt.Errorf("invalid %s: %v", domainIndexParamSubdomains, err)
+		}
+	}
+
+	if param := r.FormValue(domainIndexParamStart); len(param) > 0 {
+		if start, err = strconv.ParseInt(param, 10, 64); err != nil || start < 0 {
+			return "", false, 0, 0, fmt.Errorf("invalid %s: %q", domainIndexParamStart, param)
+		}
+	}
+
+	if param := r.FormValue(domainIndexParamLimit); len(param) > 0 {
+		if limit, err = strconv.Atoi(param); err != nil || limit <= 0 {
+			return "", false, 0, 0, fmt.Errorf("invalid %s: %q", domainIndexParamLimit, param)
+		}
+	}
+
+	if limit > maxDomainIndexLimit {
+		limit = maxDomainIndexLimit
+	}
+
+	return domain, includeSubdomains, start, limit, nil
+}
@@ -0,0 +1,160 @@
+package ct
+
+import (
+	"bytes"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	ct "github.com/google/certificate-transparency/go"
+	"github.com/google/trillian"
+	"github.com/google/trillian/examples/ct/testonly"
+	_ "github.com/mattn/go-sqlite3"
+	"golang.org/x/net/context"
+)
+
+const testDomainIndexLogID = 1
+
+func openDomainIndexOrDie(t *testing.T) *DomainIndex {
+	db, err := sql.Open("sqlite3", "file::memory:")
+
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	// Every connection to an in memory database gets its own one
+	db.SetMaxOpenConns(1)
+
+	index, err := NewDomainIndex(db, testDomainIndexLogID)
+
+	if err != nil {
+		t.Fatalf("NewDomainIndex()=%v", err)
+	}
+
+	return index
+}
+
+func domainIndexTestLeaf(t *testing.T, index int64, entry ct.TimestampedEntry) *trillian.LeafProto {
+	var buf bytes.Buffer
+
+	if err := writeMerkleTreeLeaf(&buf, ct.MerkleTreeLeaf{Version: ct.V1, LeafType: ct.TimestampedEntryLeafType, TimestampedEntry: entry}); err != nil {
+		t.Fatalf("Failed to serialize leaf: %v", err)
+	}
+
+	return &trillian.LeafProto{LeafIndex: index, LeafData: buf.Bytes()}
+}
+
+func TestNormalizeDomain(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{name: "www.Example.COM", want: "www.example.com", ok: true},
+		{name: "example.com.", want: "example.com", ok: true},
+		{name: "*.example.com", want: "*.example.com", ok: true},
+		{name: "_dmarc.example.com", want: "_dmarc.example.com", ok: true},
+		{name: ""},
+		{name: "Erw Wen"},
+		{name: "example..com"},
+		{name: "example.com/path"},
+	} {
+		if got, ok := normalizeDomain(test.name); got != test.want || ok != test.ok {
+			t.Errorf("normalizeDomain(%q)=%q, %v, expected %q, %v", test.name, got, ok, test.want, test.ok)
+		}
+	}
+
+	if got, want := reverseDomain("www.example.com"), "com.example.www"; got != want {
+		t.Errorf("reverseDomain()=%s, expected %s", got, want)
+	}
+}
+
+func TestDomainIndex(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	cert := pemToCert(t, testonly.LeafSignedByFakeIntermediateCertPem)
+	leaves := []*trillian.LeafProto{
+		domainIndexTestLeaf(t, 0, ct.TimestampedEntry{EntryType: ct.X509LogEntryType, X509Entry: cert.Raw}),
+		domainIndexTestLeaf(t, 1, ct.TimestampedEntry{EntryType: ct.PrecertLogEntryType, PrecertEntry: ct.PreCert{TBSCertificate: cert.RawTBSCertificate}}),
+		{LeafIndex: 2, LeafData: []byte("not a leaf")},
+	}
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	rootResponse := &trillian.GetLatestSignedLogRootResponse{Status: okStatus, SignedLogRoot: &trillian.SignedLogRoot{TreeSize: 3}}
+	client.EXPECT().GetLatestSignedLogRoot(gomock.Any(), &trillian.GetLatestSignedLogRootRequest{LogId: testDomainIndexLogID}).Return(rootResponse, nil).Times(2)
+	client.EXPECT().GetLeavesByIndex(gomock.Any(), &trillian.GetLeavesByIndexRequest{LogId: testDomainIndexLogID, LeafIndex: []int64{0, 1, 2}, OmitExtraData: true}).Return(&trillian.GetLeavesByIndexResponse{Status: okStatus, Leaves: leaves}, nil)
+
+	index := openDomainIndexOrDie(t)
+
+	if got, err := index.IndexBatch(context.Background(), client, 10); err != nil || got != 3 {
+		t.Fatalf("IndexBatch()=%d, %v, expected 3 entries indexed", got, err)
+	}
+
+	// Caught up with the tree size so no leaves are fetched
+	if got, err := index.IndexBatch(context.Background(), client, 10); err != nil || got != 0 {
+		t.Fatalf("IndexBatch()=%d, %v, expected none indexed", got, err)
+	}
+
+	if indexed, _, unparseable := index.Stats(); indexed != 3 || unparseable != 1 {
+		t.Errorf("Stats()=%d indexed, %d unparseable, expected 3 and 1", indexed, unparseable)
+	}
+
+	both := []DomainIndexEntry{{LeafIndex: 0}, {LeafIndex: 1, Precert: true}}
+
+	for _, test := range []struct {
+		domain     string
+		subdomains bool
+		start      int64
+		limit      int
+		want       DomainIndexResponse
+	}{
+		{domain: "google.com", limit: 10, want: DomainIndexResponse{Entries: both, IndexedSize: 3}},
+		{domain: "Google.COM", limit: 1, want: DomainIndexResponse{Entries: both[:1], Next: 1, IndexedSize: 3}},
+		{domain: "google.com", start: 1, limit: 1, want: DomainIndexResponse{Entries: both[1:], IndexedSize: 3}},
+		{domain: "*.google.com", limit: 10, want: DomainIndexResponse{Entries: both, IndexedSize: 3}},
+		// Only a wildcard is under cloud.google.com
+		{domain: "cloud.google.com", limit: 10, want: DomainIndexResponse{Entries: []DomainIndexEntry{}, IndexedSize: 3}},
+		{domain: "cloud.google.com", subdomains: true, limit: 10, want: DomainIndexResponse{Entries: both, IndexedSize: 3}},
+		{domain: "example.com", subdomains: true, limit: 10, want: DomainIndexResponse{Entries: []DomainIndexEntry{}, IndexedSize: 3}},
+	} {
+		got, err := index.Lookup(test.domain, test.subdomains, test.start, test.limit)
+
+		if err != nil {
+			t.Errorf("Lookup(%s, %v, %d, %d)=%v", test.domain, test.subdomains, test.start, test.limit, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Lookup(%s, %v, %d, %d)=%+v, expected %+v", test.domain, test.subdomains, test.start, test.limit, got, test.want)
+		}
+	}
+}
+
+func TestGetEntriesByDomainBadParams(t *testing.T) {
+	handler := wrappedGetEntriesByDomainHandler(openDomainIndexOrDie(t))
+
+	for _, params := range []string{
+		"",
+		"domain=not%20a%20domain",
+		"domain=example.com&start=-1",
+		"domain=example.com&start=x",
+		"domain=example.com&limit=0",
+		"domain=example.com&include_subdomains=maybe",
+	} {
+		req, err := http.NewRequest("GET", "http://example.com/ct/v1/get-entries-by-domain?"+params, nil)
+
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+
+		w := httptest.NewRecorder()
+
+		if got, _ := handler(w, req); got != http.StatusBadRequest {
+			t.Errorf("Got status %d for %q, expected %d", got, params, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package ct
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	ct "github.com/google/certificate-transparency/go"
+)
+
+// ExtraDataHashExtensionType is the type of the CT extension that commits a log entry to the
+// chain it was submitted with. This is not part of RFC 6962, the value is one that the
+// RFC 6962-bis extension registry leaves for private use.
+const ExtraDataHashExtensionType = 0xe000
+
+// Extensions are encoded as in RFC 6962-bis: a two byte type followed by the data, with a two
+// byte length
+const extensionHeaderLength = 4
+
+// ExtraDataCommitment returns the CT extensions that bind a log entry to the chain it was
+// submitted with. The extension's data is the SHA-256 hash of every certificate in the chain,
+// from the end entity or precertificate up, each preceded by its length in 3 bytes as in the
+// RFC 6962 certificate_chain. The extensions are part of the Merkle tree leaf and are signed
+// in the SCT, so once the entry is in the tree the extra_data served by get-entries can't be
+// changed without it being detected, see VerifyExtraDataCommitment.
+func ExtraDataCommitment(chain []ct.ASN1Cert) ct.CTExtensions {
+	hash := hashChain(chain)
+
+	var extensions bytes.Buffer
+	binary.Write(&extensions, binary.BigEndian, uint16(ExtraDataHashExtensionType))
+	binary.Write(&extensions, binary.BigEndian, uint16(len(hash)))
+	extensions.Write(hash[:])
+
+	return ct.CTExtensions(extensions.Bytes())
+}
+
+// VerifyExtraDataCommitment checks that the leaf_input and extra_data of an entry returned by
+// get-entries are bound together by an ExtraDataCommitment. It fails if the leaf has no
+// commitment, so it should only be used for logs that are configured to add them.
+func VerifyExtraDataCommitment(leafInput, extraData []byte) error {
+	leaf, err := ct.ReadMerkleTreeLeaf(bytes.NewReader(leafInput))
+
+	if err != nil {
+		return fmt.Errorf("failed to parse leaf_input: %v", err)
+	}
+
+	var entry CTLogEntry
+
+	if err := entry.Deserialize(bytes.NewReader(extraData)); err != nil {
+		return fmt.Errorf("failed to parse extra_data: %v", err)
+	}
+
+	committed, err := findExtension(leaf.TimestampedEntry.Extensions, ExtraDataHashExtensionType)
+
+	if err != nil {
+		return err
+	}
+
+	if hash := hashChain(entry.Chain); !bytes.Equal(committed, hash[:]) {
+		return errors.New("extra_data chain doesn't match the hash committed to in the leaf")
+	}
+
+	return nil
+}
+
+// hashChain returns the hash that ExtraDataCommitment commits to.
+func hashChain(chain []ct.ASN1Cert) [sha256.Size]byte {
+	h := sha256.New()
+
+	for _, cert := range chain {
+		h.Write([]byte{byte(len(cert) >> 16), byte(len(cert) >> 8), byte(len(cert))})
+		h.Write(cert)
+	}
+
+	var hash [sha256.Size]byte
+	copy(hash[:], h.Sum(nil))
+
+	return hash
+}
+
+// findExtension returns the data of the extension of type extensionType in extensions.
+func findExtension(extensions ct.CTExtensions, extensionType uint16) ([]byte, error) {
+	for len(extensions) > 0 {
+		if len(extensions) < extensionHeaderLength {
+			return nil, errors.New("truncated extension header")
+		}
+
+		t := binary.BigEndian.Uint16(extensions)
+		length := int(binary.BigEndian.Uint16(extensions[2:]))
+		extensions = extensions[extensionHeaderLength:]
+
+		if len(extensions) < length {
+			return nil, fmt.Errorf("truncated data of extension %d", t)
+		}
+
+		if t == extensionType {
+			return extensions[:length], nil
+		}
+
+		extensions = extensions[length:]
+	}
+
+	return nil, fmt.Errorf("no extension of type %d", extensionType)
+}
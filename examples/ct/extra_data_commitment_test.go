@@ -0,0 +1,109 @@
+package ct
+
+import (
+	"bytes"
+	"testing"
+
+	ct "github.com/google/certificate-transparency/go"
+	"github.com/google/certificate-transparency/go/fixchain"
+	"github.com/google/certificate-transparency/go/x509"
+	"github.com/google/trillian/examples/ct/testonly"
+)
+
+// commitmentTestChain returns the chain of a leaf signed by the fake intermediate
+func commitmentTestChain(t *testing.T) []*x509.Certificate {
+	var chain []*x509.Certificate
+
+	for _, pem := range []string{testonly.LeafSignedByFakeIntermediateCertPem, testonly.FakeIntermediateCertPem, testonly.FakeCACertPem} {
+		cert, err := fixchain.CertificateFromPEM(pem)
+
+		if err != nil {
+			t.Fatalf("failed to set up test cert: %v", err)
+		}
+
+		chain = append(chain, cert)
+	}
+
+	return chain
+}
+
+// serializeEntry returns the leaf_input and extra_data that get-entries would serve for a leaf
+// and chain
+func serializeEntry(t *testing.T, leaf ct.MerkleTreeLeaf, chain []*x509.Certificate) ([]byte, []byte) {
+	var leafInput, extraData bytes.Buffer
+
+	if err := writeMerkleTreeLeaf(&leafInput, leaf); err != nil {
+		t.Fatalf("failed to serialize leaf: %v", err)
+	}
+
+	if err := NewCTLogEntry(leaf, chain).Serialize(&extraData); err != nil {
+		t.Fatalf("failed to serialize log entry: %v", err)
+	}
+
+	return leafInput.Bytes(), extraData.Bytes()
+}
+
+// commitmentTestLeaf returns an X.509 leaf for the first certificate in chain
+func commitmentTestLeaf(chain []*x509.Certificate, extensions ct.CTExtensions) ct.MerkleTreeLeaf {
+	return ct.MerkleTreeLeaf{
+		Version:  ct.V1,
+		LeafType: ct.TimestampedEntryLeafType,
+		TimestampedEntry: ct.TimestampedEntry{
+			Timestamp:  1504786523000000,
+			EntryType:  ct.X509LogEntryType,
+			X509Entry:  chain[0].Raw,
+			Extensions: extensions}}
+}
+
+func TestVerifyExtraDataCommitment(t *testing.T) {
+	chain := commitmentTestChain(t)
+	leaf := commitmentTestLeaf(chain, ExtraDataCommitment(NewCTLogEntry(ct.MerkleTreeLeaf{}, chain).Chain))
+	leafInput, extraData := serializeEntry(t, leaf, chain)
+
+	if err := VerifyExtraDataCommitment(leafInput, extraData); err != nil {
+		t.Fatalf("VerifyExtraDataCommitment()=%v for a matching chain", err)
+	}
+
+	// The same leaf with the intermediate left out of its chain
+	_, extraData = serializeEntry(t, leaf, chain[:1])
+
+	if err := VerifyExtraDataCommitment(leafInput, extraData); err == nil {
+		t.Error("VerifyExtraDataCommitment() accepted a changed chain")
+	}
+
+	// The order of the chain is committed to as well
+	_, extraData = serializeEntry(t, leaf, []*x509.Certificate{chain[0], chain[2], chain[1]})
+
+	if err := VerifyExtraDataCommitment(leafInput, extraData); err == nil {
+		t.Error("VerifyExtraDataCommitment() accepted a reordered chain")
+	}
+}
+
+func TestVerifyExtraDataCommitmentBadExtensions(t *testing.T) {
+	chain := commitmentTestChain(t)
+	commitment := ExtraDataCommitment(NewCTLogEntry(ct.MerkleTreeLeaf{}, chain).Chain)
+
+	// An unrelated extension ahead of the commitment is skipped
+	other := ct.CTExtensions{0x00, 0x01, 0x00, 0x02, 0xaa, 0xbb}
+	leafInput, extraData := serializeEntry(t, commitmentTestLeaf(chain, append(other, commitment...)), chain)
+
+	if err := VerifyExtraDataCommitment(leafInput, extraData); err != nil {
+		t.Errorf("VerifyExtraDataCommitment()=%v with another extension", err)
+	}
+
+	for _, test := range []struct {
+		desc       string
+		extensions ct.CTExtensions
+	}{
+		{"no extensions", nil},
+		{"other extension only", other},
+		{"truncated header", commitment[:extensionHeaderLength-1]},
+		{"truncated data", commitment[:len(commitment)-1]},
+	} {
+		leafInput, extraData := serializeEntry(t, commitmentTestLeaf(chain, test.extensions), chain)
+
+		if err := VerifyExtraDataCommitment(leafInput, extraData); err == nil {
+			t.Errorf("%s: VerifyExtraDataCommitment() accepted the entry", test.desc)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package ct
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	ct "github.com/google/certificate-transparency/go"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	terrors "github.com/google/trillian/errors"
+	"github.com/google/trillian/examples/ct/ctapi"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/util"
+)
+
+// maxGossipItems is the most STHs and SCTs that one gossip request can hold, as each STH costs
+// backend requests to check
+const maxGossipItems = 100
+
+// Evidence files are named after the hash of the STH so the same one is only stored once
+const (
+	gossipEvidenceSuffix = ".json"
+	gossipTempSuffix     = ".tmp"
+)
+
+// GossipSCT is an SCT that a client observed, along with the leaf_input of the log entry it
+// was issued for, which is needed to check its signature.
+type GossipSCT struct {
+	SCT       ctapi.AddChainResponse `json:"sct"`
+	LeafInput []byte                 `json:"leaf_input"`
+}
+
+// GossipRequest is the body of a gossip request, the STHs and SCTs that a client observed
+// for the log.
+type GossipRequest struct {
+	STHs []ctapi.GetSTHResponse `json:"sths"`
+	SCTs []GossipSCT            `json:"scts"`
+}
+
+// GossipResponse is the body of a gossip response. It counts the STHs and SCTs in the request
+// that were signed by the log, those that weren't and the STHs that aren't consistent with
+// the log's history.
+type GossipResponse struct {
+	Verified   int `json:"verified"`
+	Invalid    int `json:"invalid"`
+	SplitViews int `json:"split_views"`
+}
+
+// GossipEvidence is stored for each STH that was signed by the log but isn't consistent with
+// its history, for the operator to review.
+type GossipEvidence struct {
+	// STH is the STH as the client sent it
+	STH ctapi.GetSTHResponse `json:"sth"`
+	// Reason says how it differs from the log's history
+	Reason string `json:"reason"`
+	// LogTreeSize and LogRootHash are the log's latest root when it was checked
+	LogTreeSize int64  `json:"log_tree_size"`
+	LogRootHash []byte `json:"log_root_hash"`
+	// ReceivedMillis is when the STH was received
+	ReceivedMillis int64 `json:"received_timestamp"`
+}
+
+// Gossip records the STHs and SCTs that clients observed for a log, sent to the gossip
+// endpoint. Their signatures are checked with the log's key and each signed STH is checked
+// against the log's own history, an STH that the log signed but that isn't consistent with
+// the tree held by its backend is evidence that it has presented a split view, or that its
+// key is being used elsewhere. That evidence is written to files in a directory for the
+// operator to review. SCTs are only counted. It is safe for concurrent use.
+type Gossip struct {
+	// dir holds a file of GossipEvidence for each split view STH
+	dir        string
+	timeSource util.TimeSource
+
+	// mu guards the fields below it
+	mu sync.Mutex
+	// verified and invalid count the STHs and SCTs received, by whether they were signed by
+	// the log
+	verified int64
+	invalid  int64
+	// splitViews counts the evidence files in dir
+	splitViews int64
+}
+
+// NewGossip creates a Gossip that writes evidence to files in dir, which is created if
+// needed. Evidence left from previous runs is counted.
+func NewGossip(dir string, timeSource util.TimeSource) (*Gossip, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	files, err := ioutil.ReadDir(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Gossip{dir: dir, timeSource: timeSource}
+
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), gossipEvidenceSuffix) {
+			g.splitViews++
+		}
+	}
+
+	if g.splitViews > 0 {
+		glog.Warningf("Gossip directory %s holds evidence of %d split view STHs", dir, g.splitViews)
+	}
+
+	return g, nil
+}
+
+// Stats returns the number of STHs and SCTs received that were signed by the log, the number
+// that weren't and the number of STHs stored as evidence of a split view.
+func (g *Gossip) Stats() (verified, invalid, splitViews int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.verified, g.invalid, g.splitViews
+}
+
+// count adds to the numbers of STHs and SCTs received
+func (g *Gossip) count(verified, invalid int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.verified += int64(verified)
+	g.invalid += int64(invalid)
+}
+
+// record stores evidence of a split view. An STH that was already stored isn't stored again.
+func (g *Gossip) record(evidence GossipEvidence) error {
+	data, err := json.Marshal(evidence.STH)
+
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256(data)
+	name := hex.EncodeToString(hash[:])
+	path := filepath.Join(g.dir, name+gossipEvidenceSuffix)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	if data, err = json.MarshalIndent(evidence, "", "  "); err != nil {
+		return err
+	}
+
+	if err := writeFileDurably(filepath.Join(g.dir, name+gossipTempSuffix), path, data); err != nil {
+		return err
+	}
+
+	g.splitViews++
+	return nil
+}
+
+// verifyGossipSCT checks that an SCT was issued by the log with the key and options given for
+// the entry in leafInput.
+func verifyGossipSCT(km crypto.KeyManager, opts SignatureOptions, sct GossipSCT) error {
+	logID, err := GetCTLogID(km)
+
+	if err != nil {
+		return err
+	}
+
+	if got, want := sct.SCT.ID, base64.StdEncoding.EncodeToString(logID[:]); got != want {
+		return fmt.Errorf("SCT has log ID %s, expected %s", got, want)
+	}
+
+	leaf, err := ct.ReadMerkleTreeLeaf(bytes.NewReader(sct.LeafInput))
+
+	if err != nil {
+		return fmt.Errorf("failed to read leaf_input: %v", err)
+	}
+
+	if got, want := leaf.TimestampedEntry.Timestamp, sct.SCT.Timestamp; got != want {
+		return fmt.Errorf("leaf_input has timestamp %d, SCT has %d", got, want)
+	}
+
+	extensions, err := base64.StdEncoding.DecodeString(sct.SCT.Extensions)
+
+	if err != nil {
+		return fmt.Errorf("failed to decode SCT extensions: %v", err)
+	}
+
+	var signature ct.DigitallySigned
+	if err := signature.FromBase64String(sct.SCT.Signature); err != nil {
+		return fmt.Errorf("failed to decode SCT signature: %v", err)
+	}
+
+	input, err := ct.SerializeSCTSignatureInput(ct.SignedCertificateTimestamp{SCTVersion: ct.Version(sct.SCT.SctVersion), Timestamp: sct.SCT.Timestamp, Extensions: ct.CTExtensions(extensions)}, ct.LogEntry{Leaf: *leaf})
+
+	if err != nil {
+		return err
+	}
+
+	publicKey, err := km.GetPublicKey()
+
+	if err != nil {
+		return err
+	}
+
+	return verifyDigitallySigned(publicKey, opts, input, signature)
+}
+
+// checkGossipSTH returns why an STH signed by the log isn't consistent with root, the log's
+// latest root, or an empty string if it is. An STH is consistent if root is an append only
+// extension of its tree.
+func checkGossipSTH(ctx context.Context, c CTRequestHandlers, sth ctapi.GetSTHResponse, root *trillian.SignedLogRoot) (string, error) {
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+
+	switch {
+	case sth.TreeSize > root.TreeSize:
+		return fmt.Sprintf("tree size %d is beyond the log's latest tree size %d", sth.TreeSize, root.TreeSize), nil
+	case sth.TreeSize == 0:
+		if !bytes.Equal(sth.RootHash, hasher.HashEmpty()) {
+			return fmt.Sprintf("root hash %x of the empty tree is wrong", sth.RootHash), nil
+		}
+
+		return "", nil
+	case sth.TreeSize == root.TreeSize:
+		if !bytes.Equal(sth.RootHash, root.RootHash) {
+			return fmt.Sprintf("root hash %x differs from the log's %x for tree size %d", sth.RootHash, root.RootHash, sth.TreeSize), nil
+		}
+
+		return "", nil
+	}
+
+	request := trillian.GetConsistencyProofRequest{LogId: c.logID, FirstTreeSize: sth.TreeSize, SecondTreeSize: root.TreeSize}
+	response, err := c.rpcClient.GetConsistencyProof(ctx, &request)
+
+	if err != nil || !rpcStatusOK(response.GetStatus()) {
+		err = backendError("GetConsistencyProof", err, response.GetStatus())
+
+		// The log only signs tree heads that the backend stored
+		if terrors.CodeOf(err) == terrors.NotFound {
+			return fmt.Sprintf("the log has no tree head for tree size %d", sth.TreeSize), nil
+		}
+
+		return "", err
+	}
+
+	if err := merkle.VerifyConsistencyProof(hasher, sth.TreeSize, root.TreeSize, sth.RootHash, root.RootHash, auditPathFromProto(response.GetProof().GetProofNode())); err != nil {
+		return fmt.Sprintf("not consistent with the log's tree of size %d: %v", root.TreeSize, err), nil
+	}
+
+	return "", nil
+}
+
+// wrappedGossipHandler accepts STHs and SCTs observed by clients, see Gossip
+func wrappedGossipHandler(c CTRequestHandlers, gossip *Gossip) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		if !enforceMethod(w, r, httpMethodPost) {
+			return http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method)
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+
+		if err != nil {
+			return http.StatusBadRequest, fmt.Errorf("failed to read gossip request: %v", err)
+		}
+
+		var req GossipRequest
+		if err := requestSchemas["gossip"].decode(body, &req); err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		if items := len(req.STHs) + len(req.SCTs); items > maxGossipItems {
+			return http.StatusBadRequest, fmt.Errorf("gossip request has %d STHs and SCTs, the limit is %d", items, maxGossipItems)
+		}
+
+		publicKey, err := c.logKeyManager.GetPublicKey()
+
+		if err != nil {
+			return http.StatusInternalServerError, fmt.Errorf("failed to get log public key: %v", err)
+		}
+
+		var resp GossipResponse
+		var signed []ctapi.GetSTHResponse
+
+		for _, sth := range req.STHs {
+			if err := VerifySTHResponse(publicKey, c.signatureOptions, sth); err != nil {
+				glog.V(logVerboseLevel).Infof("Gossiped STH for tree size %d not signed by the log: %v", sth.TreeSize, err)
+				resp.Invalid++
+				continue
+			}
+
+			resp.Verified++
+			signed = append(signed, sth)
+		}
+
+		for _, sct := range req.SCTs {
+			if err := verifyGossipSCT(c.logKeyManager, c.signatureOptions, sct); err != nil {
+				glog.V(logVerboseLevel).Infof("Gossiped SCT with timestamp %d not signed by the log: %v", sct.SCT.Timestamp, err)
+				resp.Invalid++
+				continue
+			}
+
+			resp.Verified++
+		}
+
+		gossip.count(resp.Verified, resp.Invalid)
+
+		if len(signed) > 0 {
+			// The latest root comes straight from the backend, a cached one could be older
+			// than an STH served by another frontend
+			ctx, _ := context.WithDeadline(requestContext(r, util.PriorityBulk), getRPCDeadlineTime(c))
+			rootResp, err := c.rpcClient.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: c.logID})
+
+			if err != nil || !rpcStatusOK(rootResp.GetStatus()) {
+				return errorStatus(backendError("GetLatestSignedLogRoot", err, rootResp.GetStatus()))
+			}
+
+			root := rootResp.GetSignedLogRoot()
+
+			if root == nil {
+				return errorStatus(terrors.New(terrors.Backend, "backend GetLatestSignedLogRoot returned no root"))
+			}
+
+			for _, sth := range signed {
+				reason, err := checkGossipSTH(ctx, c, sth, root)
+
+				if err != nil {
+					return errorStatus(err)
+				}
+
+				if len(reason) == 0 {
+					continue
+				}
+
+				glog.Errorf("Gossiped STH for tree size %d is evidence of a split view: %s", sth.TreeSize, reason)
+				resp.SplitViews++
+
+				evidence := GossipEvidence{STH: sth, Reason: reason, LogTreeSize: root.TreeSize, LogRootHash: root.RootHash, ReceivedMillis: gossip.timeSource.Now().UnixNano() / int64(time.Millisecond)}
+
+				if err := gossip.record(evidence); err != nil {
+					return http.StatusInternalServerError, fmt.Errorf("failed to store split view evidence: %v", err)
+				}
+			}
+		}
+
+		w.Header().Set(contentTypeHeader, contentTypeJSON)
+		jsonData, err := json.Marshal(&resp)
+
+		if err != nil {
+			return http.StatusInternalServerError, fmt.Errorf("failed to marshal gossip response: %v", err)
+		}
+
+		if _, err := w.Write(jsonData); err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		return http.StatusOK, nil
+	}
+}
@@ -0,0 +1,252 @@
+package ct
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	ct "github.com/google/certificate-transparency/go"
+	"github.com/google/certificate-transparency/go/fixchain"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/examples/ct/ctapi"
+	"github.com/google/trillian/examples/ct/testonly"
+	"github.com/google/trillian/merkle"
+	ttestonly "github.com/google/trillian/testonly"
+)
+
+// signedGossipSTH returns a get-sth response for the tree signed by km
+func signedGossipSTH(t *testing.T, km crypto.KeyManager, treeSize int64, rootHash []byte) ctapi.GetSTHResponse {
+	sth := ct.SignedTreeHead{TreeSize: uint64(treeSize), Timestamp: uint64(treeSize) * 1000}
+	copy(sth.SHA256RootHash[:], rootHash)
+
+	if err := signV1TreeHead(km, SignatureOptions{}, &sth); err != nil {
+		t.Fatalf("Failed to sign STH: %v", err)
+	}
+
+	return convertSTHForClientResponse(sth)
+}
+
+// signedGossipSCT returns an SCT signed by km, as a client would send it
+func signedGossipSCT(t *testing.T, km crypto.KeyManager) GossipSCT {
+	cert, err := fixchain.CertificateFromPEM(testonly.LeafSignedByFakeIntermediateCertPem)
+
+	if err != nil {
+		t.Fatalf("Failed to set up test cert: %v", err)
+	}
+
+	leaf, sct, err := signV1SCTForCertificate(km, SignatureOptions{}, cert, fakeTime, nil)
+
+	if err != nil {
+		t.Fatalf("Failed to sign SCT: %v", err)
+	}
+
+	var leafInput bytes.Buffer
+	if err := writeMerkleTreeLeaf(&leafInput, leaf); err != nil {
+		t.Fatalf("Failed to serialize leaf: %v", err)
+	}
+
+	logID, signature, err := marshalLogIDAndSignatureForResponse(sct, km)
+
+	if err != nil {
+		t.Fatalf("Failed to marshal SCT: %v", err)
+	}
+
+	resp := ctapi.AddChainResponse{SctVersion: int(sct.SCTVersion), ID: base64.StdEncoding.EncodeToString(logID[:]), Timestamp: sct.Timestamp, Signature: signature}
+	return GossipSCT{SCT: resp, LeafInput: leafInput.Bytes()}
+}
+
+func makeGossipRequest(t *testing.T, handler appHandler, req GossipRequest) *httptest.ResponseRecorder {
+	body, err := json.Marshal(req)
+
+	if err != nil {
+		t.Fatalf("Failed to marshal gossip request: %v", err)
+	}
+
+	r, err := http.NewRequest("POST", "http://example.com/ct/v1/gossip", bytes.NewReader(body))
+
+	if err != nil {
+		t.Fatalf("Test request setup failed: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestGossip(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	dir, err := ioutil.TempDir("", "gossip")
+
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	logKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	km := setupRealKeyManager(t, mockCtrl, logKey)
+	otherKM := setupRealKeyManager(t, mockCtrl, otherKey)
+
+	// The log holds a tree of 4 leaves
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+	h := make([][]byte, 4)
+	for i := range h {
+		h[i] = hasher.HashLeaf([]byte{byte(i)})
+	}
+	root2 := hasher.HashChildren(h[0], h[1])
+	right := hasher.HashChildren(h[2], h[3])
+	root4 := hasher.HashChildren(root2, right)
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	client.EXPECT().GetLatestSignedLogRoot(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLatestSignedLogRootRequest{LogId: 0x42}).Times(2).Return(&trillian.GetLatestSignedLogRootResponse{Status: okStatus, SignedLogRoot: &trillian.SignedLogRoot{TreeSize: 4, RootHash: root4}}, nil)
+	proof := trillian.GetConsistencyProofResponse{Status: okStatus, Proof: &trillian.ProofProto{ProofNode: []*trillian.NodeProto{{NodeHash: right}}}}
+	client.EXPECT().GetConsistencyProof(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetConsistencyProofRequest{LogId: 0x42, FirstTreeSize: 2, SecondTreeSize: 4}).Times(4).Return(&proof, nil)
+
+	gossip, err := NewGossip(dir, fakeTimeSource)
+
+	if err != nil {
+		t.Fatalf("NewGossip()=%v", err)
+	}
+
+	c := CTRequestHandlers{logID: 0x42, rpcClient: client, logKeyManager: km, rpcDeadline: time.Millisecond * 500, timeSource: fakeTimeSource}
+	handler := wrappedGossipHandler(c, gossip)
+
+	badSCT := signedGossipSCT(t, km)
+	badSCT.SCT.Timestamp++
+
+	req := GossipRequest{
+		STHs: []ctapi.GetSTHResponse{
+			signedGossipSTH(t, km, 4, root4),
+			signedGossipSTH(t, km, 2, root2),
+			signedGossipSTH(t, km, 0, hasher.HashEmpty()),
+			// Signed by the log but not in its history
+			signedGossipSTH(t, km, 2, right),
+			signedGossipSTH(t, km, 5, root4),
+			// Not signed by the log
+			signedGossipSTH(t, otherKM, 3, root4),
+		},
+		SCTs: []GossipSCT{signedGossipSCT(t, km), badSCT, signedGossipSCT(t, otherKM)},
+	}
+
+	// The same evidence sent twice is only stored once
+	for i := 0; i < 2; i++ {
+		w := makeGossipRequest(t, handler, req)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Fatalf("gossip returned %d, expected %d. Body: %s", got, want, w.Body)
+		}
+
+		var resp GossipResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to unmarshal gossip response: %v", err)
+		}
+
+		if got, want := resp, (GossipResponse{Verified: 6, Invalid: 3, SplitViews: 2}); got != want {
+			t.Errorf("gossip returned %+v, expected %+v", got, want)
+		}
+	}
+
+	if verified, invalid, splitViews := gossip.Stats(); verified != 12 || invalid != 6 || splitViews != 2 {
+		t.Errorf("Stats()=%d, %d, %d, expected 12, 6, 2", verified, invalid, splitViews)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+gossipEvidenceSuffix))
+
+	if err != nil || len(files) != 2 {
+		t.Fatalf("Got evidence files %v (%v), expected 2", files, err)
+	}
+
+	data, err := ioutil.ReadFile(files[0])
+
+	if err != nil {
+		t.Fatalf("Failed to read evidence: %v", err)
+	}
+
+	var evidence GossipEvidence
+	if err := json.Unmarshal(data, &evidence); err != nil {
+		t.Fatalf("Failed to unmarshal evidence: %v", err)
+	}
+
+	if evidence.LogTreeSize != 4 || !bytes.Equal(evidence.LogRootHash, root4) || len(evidence.Reason) == 0 {
+		t.Errorf("Got evidence %+v, expected the log's root and a reason", evidence)
+	}
+
+	// Evidence is still counted after a restart
+	gossip, err = NewGossip(dir, fakeTimeSource)
+
+	if err != nil {
+		t.Fatalf("NewGossip()=%v after restart", err)
+	}
+
+	if _, _, splitViews := gossip.Stats(); splitViews != 2 {
+		t.Errorf("Got %d split views after restart, expected 2", splitViews)
+	}
+}
+
+func TestGossipRejectsBadRequests(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	dir, err := ioutil.TempDir("", "gossip")
+
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	gossip, err := NewGossip(dir, fakeTimeSource)
+
+	if err != nil {
+		t.Fatalf("NewGossip()=%v", err)
+	}
+
+	// Nothing reaches the backend
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	c := CTRequestHandlers{logID: 0x42, rpcClient: client, rpcDeadline: time.Millisecond * 500, timeSource: fakeTimeSource}
+	handler := wrappedGossipHandler(c, gossip)
+
+	w := makeGossipRequest(t, handler, GossipRequest{STHs: make([]ctapi.GetSTHResponse, maxGossipItems+1)})
+
+	if got, want := w.Code, http.StatusBadRequest; got != want {
+		t.Errorf("gossip with too many STHs returned %d, expected %d", got, want)
+	}
+
+	r, err := http.NewRequest("POST", "http://example.com/ct/v1/gossip", bytes.NewReader([]byte(`{"STHs": []}`)))
+
+	if err != nil {
+		t.Fatalf("Test request setup failed: %v", err)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if got, want := w.Code, http.StatusBadRequest; got != want {
+		t.Errorf("gossip with a misnamed field returned %d, expected %d", got, want)
+	}
+}
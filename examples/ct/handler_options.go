@@ -0,0 +1,364 @@
+package ct
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/certificate-transparency/go/x509"
+	"github.com/google/trillian"
+	"github.com/google/trillian/util"
+)
+
+// defaultRPCDeadline is used for backend RPCs unless WithRPCDeadline is given
+const defaultRPCDeadline = time.Second * 10
+
+// Names of the features that can be switched off per log with WithFeatures. They only have
+// an effect if the component they guard has also been configured.
+const (
+	// FeatureFastSCT guards WithFastSCT
+	FeatureFastSCT = "fast_sct"
+	// FeatureProofCache guards WithProofCache
+	FeatureProofCache = "proof_cache"
+	// FeatureChainCache guards WithChainCache
+	FeatureChainCache = "chain_cache"
+)
+
+// HandlerFeatures lists all the features known to CTRequestHandlers.
+var HandlerFeatures = []string{FeatureFastSCT, FeatureProofCache, FeatureChainCache}
+
+// HandlerOption configures optional behaviour of CTRequestHandlers, see NewCTRequestHandlers.
+type HandlerOption func(*CTRequestHandlers)
+
+// SubmissionPolicy is a hook that can reject a chain submitted to add-chain or add-pre-chain
+// after it has been verified and before an SCT is issued for it. chain is the verified chain
+// as submitted, starting with the leaf and not including the root. An error rejects the
+// submission with a 400 response.
+type SubmissionPolicy func(chain []*x509.Certificate, isPrecert bool) error
+
+// WithRPCDeadline sets the deadline for backend RPCs made while handling a request.
+func WithRPCDeadline(deadline time.Duration) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.rpcDeadline = deadline
+	}
+}
+
+// WithTimeSource replaces the system clock, e.g. in tests.
+func WithTimeSource(timeSource util.TimeSource) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.timeSource = timeSource
+	}
+}
+
+// WithSignatureOptions changes the hash function and RSA padding used to sign SCTs and STHs.
+// By default they're chosen to suit the log's key as RFC 6962 clients expect.
+func WithSignatureOptions(opts SignatureOptions) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.signatureOptions = opts
+	}
+}
+
+// WithHashAlgorithm sets the hash function of the log's tree, which must match the one it was
+// created with. RFC 6962 clients expect SHA-256, the default, so other hash functions are only
+// for clients that know the log uses them.
+func WithHashAlgorithm(alg trillian.HashAlgorithm) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.hashAlgorithm = alg
+	}
+}
+
+// WithPathPrefix serves the log's endpoints under /prefix/ct/v1/ rather than /ct/v1/ so that
+// one frontend can serve several logs.
+func WithPathPrefix(prefix string) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.pathPrefix = prefix
+	}
+}
+
+// WithBasePath serves the log's endpoints under base, e.g. /base/prefix/ct/v1/ rather than
+// /prefix/ct/v1/, for frontends behind a reverse proxy or ingress that routes a path to them
+// without rewriting it. The base should have been checked with ValidateBasePath. Leading and
+// trailing slashes are ignored, and an empty base serves the endpoints at the root.
+func WithBasePath(base string) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		if base = strings.Trim(base, "/"); len(base) > 0 {
+			c.basePath = "/" + base
+		} else {
+			c.basePath = ""
+		}
+	}
+}
+
+// WithProofCache makes get-proof-by-hash use the cache before asking the backend for a proof.
+func WithProofCache(cache *ProofCache) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.proofCache = cache
+	}
+}
+
+// WithAllProofs makes get-proof-by-hash accept an all=true parameter, which returns a proof
+// for every leaf in the tree with the hash instead of only the one with the lowest index. This
+// is not part of RFC 6962.
+func WithAllProofs() HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.allProofs = true
+	}
+}
+
+// WithOmitExtraData makes get-entries accept an omit_extra_data=true parameter, which returns
+// entries with only their leaf_input. The backend doesn't read the extra data from storage,
+// which makes the responses much smaller for monitors that don't need the chains. This is not
+// part of RFC 6962.
+func WithOmitExtraData() HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.omitExtraData = true
+	}
+}
+
+// WithSTHCache makes get-entries reject requests that start beyond the end of the tree
+// without asking the backend for the entries. Requests that start within the tree size held
+// by the cache, which is updated by get-sth, are passed on without checking further. Others
+// are only rejected once the latest tree size has been fetched from the backend, as the cached
+// one may be out of date.
+func WithSTHCache(cache *STHCache) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.sthCache = cache
+	}
+}
+
+// WithCachedSTH makes get-sth serve the root held by the STH cache, which must also be set
+// with WithSTHCache, until it's too old rather than fetching the latest root from the backend
+// for every request. If the cache is kept up to date with STHCache.Watch every frontend
+// serving the log switches to a new root within seconds of the backend signing it, so
+// clients see the same STH whichever frontend they reach.
+func WithCachedSTH() HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.cachedSTH = true
+	}
+}
+
+// WithChainCache makes add-chain and add-pre-chain skip verifying intermediates that were
+// verified recently, see ChainCache.
+func WithChainCache(cache *ChainCache) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.chainCache = cache
+	}
+}
+
+// WithChainLimits makes add-chain and add-pre-chain refuse chains that would take more work
+// to verify than limits allow, see ChainLimits.
+func WithChainLimits(limits *ChainLimits) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.chainLimits = limits
+	}
+}
+
+// WithFastSCT makes add-chain and add-pre-chain issue SCTs as soon as the leaf has been
+// written to the journal, without a round trip to the backend. The caller is responsible for
+// running the journal's flusher.
+func WithFastSCT(journal *LeafJournal) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.leafJournal = journal
+	}
+}
+
+// WithSLOTracker records the latency and outcome of requests to every endpoint in the tracker
+// and serves its report on /debug/slo.
+func WithSLOTracker(tracker *SLOTracker) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.sloTracker = tracker
+	}
+}
+
+// WithRequestMetrics counts the requests to every endpoint by the status of their responses
+// and times them. The metrics are served on the log's /metrics, and by a MetricsServer that
+// they're added to.
+func WithRequestMetrics(metrics *RequestMetrics) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.requestMetrics = metrics
+	}
+}
+
+// WithBackendRPCMetrics serves the metrics of the RPCs to the log's backend, whose address is
+// used as their label, on the log's /metrics along with its other metrics.
+func WithBackendRPCMetrics(metrics *BackendRPCMetrics, address string) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.backendMetrics = metrics
+		c.backendAddress = address
+	}
+}
+
+// WithDebugMux registers /debug/slo and /metrics on mux rather than the mux the other
+// endpoints are registered on, so that they can be served on an internal port.
+func WithDebugMux(mux *http.ServeMux) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.debugMux = mux
+	}
+}
+
+// WithCertMetrics records the type, key algorithm, validity period and issuer of every
+// certificate accepted by add-chain and add-pre-chain and serves them on /metrics in the
+// Prometheus text format.
+func WithCertMetrics(metrics *CertMetrics) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.certMetrics = metrics
+	}
+}
+
+// WithSubmissionDedup makes add-chain and add-pre-chain answer a chain that has been submitted
+// before with an SCT that has the timestamp of the one issued the first time, and not queue
+// another leaf for it, as long as dedup remembers the chain.
+func WithSubmissionDedup(dedup *SubmissionDedup) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.submissionDedup = dedup
+	}
+}
+
+// WithSTHRefresher makes get-sth serve the STH held by refresher while it's fresh rather than
+// fetching and signing one for every request. CTRequestHandlers.RefreshSTH must be run to
+// keep it up to date.
+func WithSTHRefresher(refresher *STHRefresher) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.sthRefresher = refresher
+	}
+}
+
+// WithReadinessGating makes all the log's endpoints return 503 until readiness says the log
+// is ready and serves its state on /ready. The caller is responsible for running
+// WaitUntilReady.
+func WithReadinessGating(readiness *LogReadiness) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.readiness = readiness
+	}
+}
+
+// WithLiveRoots makes the log accept the current roots of roots, which can be reloaded while
+// the server is running, see TrustedRoots.Reload. roots replaces the pool passed to
+// NewCTRequestHandlers and each request uses the roots that were current when it started.
+func WithLiveRoots(roots *TrustedRoots) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.liveRoots = roots
+	}
+}
+
+// WithRootsAdmin serves /admin/add-root and /admin/remove-root, which change the roots the
+// log accepts without a restart. Requests must carry token, which must not be empty, as a
+// bearer token in the Authorization header; with an empty token every request is rejected.
+// roots replaces the pool passed to NewCTRequestHandlers, as with WithLiveRoots.
+func WithRootsAdmin(roots *TrustedRoots, token string) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.liveRoots = roots
+		c.rootsAdmin = roots
+		c.rootsAdminToken = token
+	}
+}
+
+// WithDenylist makes add-chain and add-pre-chain reject chains that match an entry in the
+// denylist, checked like a SubmissionPolicy in the order the options are given. The denylist
+// is managed with /admin/denylist-add and /admin/denylist-remove and listed by
+// /admin/denylist. Requests must carry adminToken, which must not be empty, as a bearer token
+// in the Authorization header.
+func WithDenylist(denylist *Denylist, adminToken string) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.denylist = denylist
+		c.denylistAdminToken = adminToken
+		c.submissionPolicies = append(c.submissionPolicies, denylist.Check)
+	}
+}
+
+// WithSTHGuard makes get-sth check each root fetched from the backend with guard before
+// serving it, so that a backend that has been rolled back or is presenting a split view is
+// noticed. If adminToken isn't empty /admin/reset-sth-guard clears the guard's state, requests
+// must carry the token as a bearer token in the Authorization header.
+func WithSTHGuard(guard *STHGuard, adminToken string) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.sthGuard = guard
+		c.sthGuardAdminToken = adminToken
+	}
+}
+
+// WithSubmitters makes add-chain and add-pre-chain reject requests that don't come from one of
+// the submitters, or that are over the submitter's quota, before the chain is looked at.
+func WithSubmitters(submitters *Submitters) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.submitters = submitters
+	}
+}
+
+// WithSubmissionPolicy adds a hook that can reject chains submitted to add-chain and
+// add-pre-chain. Policies run in the order they were given and the first error rejects the
+// submission.
+func WithSubmissionPolicy(policy SubmissionPolicy) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.submissionPolicies = append(c.submissionPolicies, policy)
+	}
+}
+
+// WithNotAfterWindow makes add-chain and add-pre-chain reject certificates and
+// precertificates that expire outside window, so that the log can be run as a temporal shard.
+// It's checked like a SubmissionPolicy in the order the options are given.
+func WithNotAfterWindow(window NotAfterWindow) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.submissionPolicies = append(c.submissionPolicies, window.Check)
+	}
+}
+
+// WithAPIVersion serves another version of the CT API for the log alongside RFC 6962, such as
+// one under /ct/v2/. It must be valid, see APIVersion.Validate, and its base path must not be
+// used by another version, or RegisterHandlers panics.
+func WithAPIVersion(version APIVersion) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.apiVersions = append(c.apiVersions, version)
+	}
+}
+
+// WithPrecertLinks links precertificates to the certificates issued from them and gives
+// resubmissions within the window the SCT timestamp of the first submission, see PrecertLinks.
+// The links are served on get-precert-link, which takes the hex encoded SHA-256 hash of a
+// certificate or precertificate. This is not part of RFC 6962.
+func WithPrecertLinks(links *PrecertLinks) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.precertLinks = links
+	}
+}
+
+// WithExtraDataCommitment makes every leaf and SCT include a CT extension holding the hash of
+// the submitted chain, see ExtraDataCommitment, so that the extra_data served by get-entries
+// is bound to the entry in the tree. Clients that check SCTs must accept the extension and
+// monitors can check entries with VerifyExtraDataCommitment. It can't be used with
+// WithPrecertLinks, whose replayed SCTs assume that the leaf doesn't depend on the chain. This
+// is not part of RFC 6962.
+func WithExtraDataCommitment() HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.extraDataCommitment = true
+	}
+}
+
+// WithGossip serves the gossip endpoint, where clients can POST a GossipRequest holding the
+// STHs and SCTs they observed for the log. Signed STHs that aren't consistent with the log's
+// history are stored by gossip as evidence of a split view, see Gossip. This is not part of
+// RFC 6962.
+func WithGossip(gossip *Gossip) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.gossip = gossip
+	}
+}
+
+// WithDomainIndex serves get-entries-by-domain, which returns the indices of the entries
+// whose certificate or precertificate names a domain, and optionally its subdomains, a page
+// at a time. Entries are only found once the index has caught up with them, see DomainIndex.
+// This is not part of RFC 6962.
+func WithDomainIndex(index *DomainIndex) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.domainIndex = index
+	}
+}
+
+// WithFeatures makes the handlers check features before using fast SCTs, the proof cache or
+// the chain cache for this log, so they can be switched off at runtime if they cause problems.
+// The registry should know HandlerFeatures.
+func WithFeatures(features *util.Features) HandlerOption {
+	return func(c *CTRequestHandlers) {
+		c.features = features
+	}
+}
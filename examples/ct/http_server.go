@@ -0,0 +1,99 @@
+package ct
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// HTTPServerConfig holds the limits of the HTTP server that serves the logs. The zero values
+// of http.Server have no timeouts at all, so a client that opens connections and sends
+// requests slowly, or never reads the responses, can hold a goroutine and a connection
+// forever. See DefaultHTTPServerConfig for safe settings.
+type HTTPServerConfig struct {
+	// ReadTimeout is the max time to read a request, including its body. Go's server also
+	// applies it to HTTP/1.1 connections waiting for their next request, so it's their idle
+	// timeout too.
+	ReadTimeout time.Duration
+	// WriteTimeout is the max time from the end of reading a request's headers to the end of
+	// writing its response. It must allow for the slowest backend requests and the largest
+	// get-entries responses.
+	WriteTimeout time.Duration
+	// IdleTimeout is how long an HTTP/2 connection can go without any requests before it's
+	// closed
+	IdleTimeout time.Duration
+	// MaxHeaderBytes is the max size of a request's headers, including the request line
+	MaxHeaderBytes int
+	// DisableHTTP2 turns off HTTP/2, which is otherwise negotiated with clients over TLS
+	DisableHTTP2 bool
+	// HTTP2MaxConcurrentStreams is the max number of requests an HTTP/2 client can have
+	// outstanding on one connection
+	HTTP2MaxConcurrentStreams uint32
+}
+
+// DefaultHTTPServerConfig returns limits that are generous for well behaved CT clients but
+// stop slow ones pinning resources.
+func DefaultHTTPServerConfig() HTTPServerConfig {
+	return HTTPServerConfig{
+		ReadTimeout:               time.Second * 30,
+		WriteTimeout:              time.Minute,
+		IdleTimeout:               time.Minute * 2,
+		MaxHeaderBytes:            64 * 1024,
+		HTTP2MaxConcurrentStreams: 250,
+	}
+}
+
+// Validate checks that the limits are all set. Unlimited timeouts are exactly what the config
+// is meant to prevent, so zero isn't allowed.
+func (c HTTPServerConfig) Validate() error {
+	if c.ReadTimeout <= 0 || c.WriteTimeout <= 0 || c.IdleTimeout <= 0 {
+		return errors.New("HTTP server timeouts must be > 0")
+	}
+
+	if c.MaxHeaderBytes <= 0 {
+		return errors.New("HTTP server max header bytes must be > 0")
+	}
+
+	if !c.DisableHTTP2 && c.HTTP2MaxConcurrentStreams == 0 {
+		return errors.New("HTTP/2 max concurrent streams must be > 0")
+	}
+
+	return nil
+}
+
+// NewHTTPServer returns a server for handler on address with the limits in config. If
+// tlsConfig is not nil it's used when the server is started with ListenAndServeTLS, and
+// HTTP/2 is offered unless config disables it.
+func NewHTTPServer(address string, handler http.Handler, tlsConfig *tls.Config, config HTTPServerConfig) (*http.Server, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	server := &http.Server{
+		Addr:           address,
+		Handler:        handler,
+		TLSConfig:      tlsConfig,
+		ReadTimeout:    config.ReadTimeout,
+		WriteTimeout:   config.WriteTimeout,
+		MaxHeaderBytes: config.MaxHeaderBytes,
+	}
+
+	if config.DisableHTTP2 {
+		// A non nil map stops net/http configuring HTTP/2 itself
+		server.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+		return server, nil
+	}
+
+	if tlsConfig != nil {
+		h2 := &http2.Server{MaxConcurrentStreams: config.HTTP2MaxConcurrentStreams, IdleTimeout: config.IdleTimeout}
+
+		if err := http2.ConfigureServer(server, h2); err != nil {
+			return nil, err
+		}
+	}
+
+	return server, nil
+}
@@ -0,0 +1,108 @@
+package ct
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHTTPServerConfigValidate(t *testing.T) {
+	if err := DefaultHTTPServerConfig().Validate(); err != nil {
+		t.Fatalf("Default config is invalid: %v", err)
+	}
+
+	for _, test := range []struct {
+		desc   string
+		modify func(*HTTPServerConfig)
+		ok     bool
+	}{
+		{"no read timeout", func(c *HTTPServerConfig) { c.ReadTimeout = 0 }, false},
+		{"negative write timeout", func(c *HTTPServerConfig) { c.WriteTimeout = -time.Second }, false},
+		{"no idle timeout", func(c *HTTPServerConfig) { c.IdleTimeout = 0 }, false},
+		{"no max header bytes", func(c *HTTPServerConfig) { c.MaxHeaderBytes = 0 }, false},
+		{"no HTTP/2 streams", func(c *HTTPServerConfig) { c.HTTP2MaxConcurrentStreams = 0 }, false},
+		{"no HTTP/2 streams with HTTP/2 disabled", func(c *HTTPServerConfig) {
+			c.HTTP2MaxConcurrentStreams = 0
+			c.DisableHTTP2 = true
+		}, true},
+	} {
+		config := DefaultHTTPServerConfig()
+		test.modify(&config)
+
+		if err := config.Validate(); (err == nil) != test.ok {
+			t.Errorf("%s: Validate()=%v, expected ok=%v", test.desc, err, test.ok)
+		}
+
+		if _, err := NewHTTPServer("localhost:0", http.NotFoundHandler(), nil, config); (err == nil) != test.ok {
+			t.Errorf("%s: NewHTTPServer()=%v, expected ok=%v", test.desc, err, test.ok)
+		}
+	}
+}
+
+func TestNewHTTPServerHTTP2(t *testing.T) {
+	config := DefaultHTTPServerConfig()
+	server, err := NewHTTPServer("localhost:0", http.NotFoundHandler(), &tls.Config{}, config)
+
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	if server.ReadTimeout != config.ReadTimeout || server.WriteTimeout != config.WriteTimeout || server.MaxHeaderBytes != config.MaxHeaderBytes {
+		t.Errorf("Server has limits %v, %v, %d, expected %+v", server.ReadTimeout, server.WriteTimeout, server.MaxHeaderBytes, config)
+	}
+
+	if _, ok := server.TLSNextProto["h2"]; !ok {
+		t.Error("HTTP/2 isn't configured for a TLS server")
+	}
+
+	config.DisableHTTP2 = true
+
+	if server, err = NewHTTPServer("localhost:0", http.NotFoundHandler(), &tls.Config{}, config); err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	if server.TLSNextProto == nil || len(server.TLSNextProto) > 0 {
+		t.Errorf("Server with HTTP/2 disabled has TLSNextProto %v, expected it empty", server.TLSNextProto)
+	}
+}
+
+func TestNewHTTPServerClosesSlowConnections(t *testing.T) {
+	config := DefaultHTTPServerConfig()
+	config.ReadTimeout = time.Millisecond * 100
+	server, err := NewHTTPServer("localhost:0", http.NotFoundHandler(), nil, config)
+
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "localhost:0")
+
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	defer listener.Close()
+	go server.Serve(listener)
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+
+	defer conn.Close()
+
+	// Send part of a request and never finish it, the server should give up on it
+	if _, err := conn.Write([]byte("GET /ct/v1/get-sth HTTP/1.1\r\nHost: localhost\r\n")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+
+	if _, err := ioutil.ReadAll(conn); err != nil {
+		t.Errorf("Connection wasn't closed by the server: %v", err)
+	}
+}
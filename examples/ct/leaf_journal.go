@@ -0,0 +1,382 @@
+package ct
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+const (
+	// Suffix for journal entries that have been completely written to disk
+	journalEntrySuffix = ".leaf"
+	// Prefix of the idempotency keys of flush requests, see journalIdempotencyKey
+	journalIdempotencyKeyPrefix = "ctfe-journal-"
+	// Suffix for journal entries that are still being written. These are discarded on startup
+	// as the SCT for them cannot have been issued.
+	journalTempSuffix = ".tmp"
+	// Size of the timestamp that prefixes each journal entry
+	journalTimestampBytes = 8
+)
+
+// errJournalBehind is returned when the journal holds a leaf that has not been sent to
+// the backend within the allowed age. No more SCTs should be issued from the journal until
+// the backlog clears.
+var errJournalBehind = errors.New("leaf journal: oldest unflushed leaf exceeds max age")
+
+// journalEntry is a leaf that has been issued an SCT but has not yet been accepted by the
+// log backend.
+type journalEntry struct {
+	id     int64
+	queued time.Time
+	leaf   trillian.LeafProto
+}
+
+// LeafJournal is a crash safe local store for leaves that have been issued an SCT before
+// they were sent to the log backend ("fast SCT" mode). Each leaf is written to its own file
+// and synced to disk before Append returns, so a leaf can only be lost if the disk is. A
+// flusher sends journalled leaves to the backend, retrying until they are accepted.
+//
+// Issuing an SCT is a promise to incorporate the leaf within the MMD. To keep that promise
+// the journal refuses new leaves once any unflushed leaf is older than maxAge, which must be
+// set comfortably below the MMD of the log.
+type LeafJournal struct {
+	// dir is the directory holding the journal files. It must not be shared with anything else.
+	dir string
+	// maxAge is the longest a leaf may stay unflushed before new leaves are refused
+	maxAge time.Duration
+	// timeSource is a util.TimeSource that can be injected for testing
+	timeSource util.TimeSource
+
+	// mu guards the fields below it
+	mu sync.Mutex
+	// nextID is the id that will be given to the next appended leaf
+	nextID int64
+	// pending holds the unflushed leaves ordered by id, which is also the order they were added
+	pending []journalEntry
+}
+
+// NewLeafJournal creates a LeafJournal using files in dir, which is created if needed. Any
+// leaves left over from a previous run are loaded so they will be flushed.
+func NewLeafJournal(dir string, maxAge time.Duration, timeSource util.TimeSource) (*LeafJournal, error) {
+	if maxAge <= 0 {
+		return nil, fmt.Errorf("leaf journal max age must be positive, got: %v", maxAge)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	j := &LeafJournal{dir: dir, maxAge: maxAge, timeSource: timeSource}
+
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+
+	if len(j.pending) > 0 {
+		glog.Warningf("Leaf journal recovered %d unflushed leaves from: %s", len(j.pending), dir)
+	}
+
+	return j, nil
+}
+
+// load reads the journal files in the directory. Partially written entries are removed.
+func (j *LeafJournal) load() error {
+	files, err := ioutil.ReadDir(j.dir)
+
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		name := f.Name()
+
+		if strings.HasSuffix(name, journalTempSuffix) {
+			if err := os.Remove(filepath.Join(j.dir, name)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !strings.HasSuffix(name, journalEntrySuffix) {
+			continue
+		}
+
+		id, err := strconv.ParseInt(strings.TrimSuffix(name, journalEntrySuffix), 10, 64)
+
+		if err != nil {
+			return fmt.Errorf("leaf journal has unexpected file: %s", name)
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(j.dir, name))
+
+		if err != nil {
+			return err
+		}
+
+		entry, err := decodeJournalEntry(data)
+
+		if err != nil {
+			return fmt.Errorf("leaf journal entry %s is corrupt: %v", name, err)
+		}
+
+		entry.id = id
+		j.pending = append(j.pending, entry)
+
+		if id >= j.nextID {
+			j.nextID = id + 1
+		}
+	}
+
+	sort.Sort(byJournalID(j.pending))
+	return nil
+}
+
+// Append durably records a leaf. When it returns without error it is safe to issue an SCT
+// for the leaf. If the journal has fallen too far behind errJournalBehind is returned and
+// the caller must send the leaf to the backend itself.
+func (j *LeafJournal) Append(leaf trillian.LeafProto) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := j.timeSource.Now()
+
+	if len(j.pending) > 0 && now.Sub(j.pending[0].queued) > j.maxAge {
+		return errJournalBehind
+	}
+
+	entry := journalEntry{id: j.nextID, queued: now, leaf: leaf}
+	data, err := encodeJournalEntry(entry)
+
+	if err != nil {
+		return err
+	}
+
+	if err := j.writeEntryFile(entry.id, data); err != nil {
+		return err
+	}
+
+	j.nextID++
+	j.pending = append(j.pending, entry)
+	return nil
+}
+
+// writeEntryFile writes the journal entry for id
+func (j *LeafJournal) writeEntryFile(id int64, data []byte) error {
+	return writeFileDurably(filepath.Join(j.dir, strconv.FormatInt(id, 10)+journalTempSuffix), j.entryPath(id), data)
+}
+
+// writeFileDurably writes data to a temporary file at tmpPath, syncs it and then renames it to
+// path so that a crash can never leave a partially written file that looks complete.
+func writeFileDurably(tmpPath, path string, data []byte) error {
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return syncDir(filepath.Dir(path))
+}
+
+func (j *LeafJournal) entryPath(id int64) string {
+	return filepath.Join(j.dir, strconv.FormatInt(id, 10)+journalEntrySuffix)
+}
+
+// Pending returns the number of leaves that have not yet been accepted by the backend.
+func (j *LeafJournal) Pending() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return len(j.pending)
+}
+
+// OldestAge returns how long the oldest unflushed leaf has been waiting, or zero if there
+// are none.
+func (j *LeafJournal) OldestAge() time.Duration {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if len(j.pending) == 0 {
+		return 0
+	}
+
+	return j.timeSource.Now().Sub(j.pending[0].queued)
+}
+
+// Flush sends up to batchSize of the oldest unflushed leaves to the backend in a single
+// request. Leaves are only removed from the journal once the backend has accepted them so
+// a failed flush can simply be retried. The request carries an idempotency key derived from
+// the leaves, so if the backend queued them but the response was lost the retry doesn't
+// queue them again. Returns the number of leaves flushed. Flush must not
+// be called concurrently with itself, normally only RunFlusher calls it.
+func (j *LeafJournal) Flush(ctx context.Context, client trillian.TrillianLogClient, logID int64, batchSize int) (int, error) {
+	j.mu.Lock()
+	batch := make([]journalEntry, 0, batchSize)
+	for i := 0; i < len(j.pending) && i < batchSize; i++ {
+		batch = append(batch, j.pending[i])
+	}
+	j.mu.Unlock()
+
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	leaves := make([]*trillian.LeafProto, 0, len(batch))
+	for i := range batch {
+		leaves = append(leaves, &batch[i].leaf)
+	}
+
+	response, err := client.QueueLeaves(ctx, &trillian.QueueLeavesRequest{LogId: logID, Leaves: leaves, IdempotencyKey: journalIdempotencyKey(leaves)})
+
+	if err != nil {
+		return 0, err
+	}
+
+	if !rpcStatusOK(response.GetStatus()) {
+		return 0, fmt.Errorf("backend rejected journal flush: %v", response.GetStatus())
+	}
+
+	// Only this method removes entries and new ones are added at the end, so the batch is
+	// still at the start of the pending list.
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, entry := range batch {
+		if err := os.Remove(j.entryPath(entry.id)); err != nil && !os.IsNotExist(err) {
+			// The backend has the leaf so leaving the file around only risks a duplicate
+			// submission after a restart, which the backend tolerates.
+			glog.Warningf("Failed to remove flushed journal entry %d: %v", entry.id, err)
+		}
+	}
+
+	j.pending = j.pending[len(batch):]
+	return len(batch), nil
+}
+
+// journalIdempotencyKey returns the idempotency key of a request to queue leaves. It depends
+// only on the leaves, so a retry of the same batch has the same key even after a restart.
+func journalIdempotencyKey(leaves []*trillian.LeafProto) string {
+	h := sha256.New()
+
+	for _, leaf := range leaves {
+		// Each leaf's data is hashed separately so the boundaries between them count
+		leafHash := sha256.Sum256(leaf.LeafData)
+		h.Write(leafHash[:])
+	}
+
+	return journalIdempotencyKeyPrefix + hex.EncodeToString(h.Sum(nil))
+}
+
+// RunFlusher flushes the journal to the backend every interval until done is closed. A
+// failed flush is retried on the next pass. Any backlog is drained in consecutive batches.
+func (j *LeafJournal) RunFlusher(done <-chan struct{}, client trillian.TrillianLogClient, logID int64, interval, rpcDeadline time.Duration, batchSize int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), rpcDeadline)
+			flushed, err := j.Flush(ctx, client, logID, batchSize)
+			cancel()
+
+			if err != nil {
+				glog.Warningf("Failed to flush leaf journal, %d pending, oldest %v: %v", j.Pending(), j.OldestAge(), err)
+				break
+			}
+
+			if flushed < batchSize {
+				break
+			}
+		}
+	}
+}
+
+// encodeJournalEntry serializes an entry as the queue timestamp followed by the leaf proto.
+func encodeJournalEntry(entry journalEntry) ([]byte, error) {
+	leafBytes, err := proto.Marshal(&entry.leaf)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, journalTimestampBytes, journalTimestampBytes+len(leafBytes))
+	binary.BigEndian.PutUint64(data, uint64(entry.queued.UnixNano()))
+	return append(data, leafBytes...), nil
+}
+
+func decodeJournalEntry(data []byte) (journalEntry, error) {
+	if len(data) < journalTimestampBytes {
+		return journalEntry{}, fmt.Errorf("entry too short: %d bytes", len(data))
+	}
+
+	var entry journalEntry
+	entry.queued = time.Unix(0, int64(binary.BigEndian.Uint64(data)))
+
+	if err := proto.Unmarshal(data[journalTimestampBytes:], &entry.leaf); err != nil {
+		return journalEntry{}, err
+	}
+
+	return entry, nil
+}
+
+// syncDir makes a rename in a directory durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+
+	if err != nil {
+		return err
+	}
+
+	defer d.Close()
+	return d.Sync()
+}
+
+// byJournalID allows sorting of journal entries by id
+type byJournalID []journalEntry
+
+func (e byJournalID) Len() int           { return len(e) }
+func (e byJournalID) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e byJournalID) Less(i, j int) bool { return e[i].id < e[j].id }
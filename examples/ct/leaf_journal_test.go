@@ -0,0 +1,226 @@
+package ct
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+const testJournalMaxAge = time.Minute
+
+func journalTestLeaf(b byte) trillian.LeafProto {
+	return trillian.LeafProto{LeafHash: []byte{b}, LeafData: []byte{b, b}, ExtraData: []byte{b, b, b}}
+}
+
+func makeTempJournalDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "leafjournal")
+
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	return dir
+}
+
+func openJournalOrDie(t *testing.T, dir string, ts util.TimeSource) *LeafJournal {
+	j, err := NewLeafJournal(dir, testJournalMaxAge, ts)
+
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+
+	return j
+}
+
+func TestLeafJournalRejectsBadMaxAge(t *testing.T) {
+	dir := makeTempJournalDir(t)
+	defer os.RemoveAll(dir)
+
+	if _, err := NewLeafJournal(dir, 0, fakeTimeSource); err == nil {
+		t.Fatal("Created journal with zero max age")
+	}
+}
+
+func TestLeafJournalRecoversAfterRestart(t *testing.T) {
+	dir := makeTempJournalDir(t)
+	defer os.RemoveAll(dir)
+
+	j := openJournalOrDie(t, dir, fakeTimeSource)
+
+	for i := byte(0); i < 3; i++ {
+		if err := j.Append(journalTestLeaf(i)); err != nil {
+			t.Fatalf("Failed to append leaf: %v", err)
+		}
+	}
+
+	// A partially written entry from a crash during Append should be discarded
+	if err := ioutil.WriteFile(filepath.Join(dir, "3"+journalTempSuffix), []byte("junk"), 0600); err != nil {
+		t.Fatalf("Failed to write temp file: %v", err)
+	}
+
+	j2 := openJournalOrDie(t, dir, fakeTimeSource)
+
+	if got, want := j2.Pending(), 3; got != want {
+		t.Fatalf("Got %d pending leaves after restart, expected %d", got, want)
+	}
+
+	for i, entry := range j2.pending {
+		if got, want := entry.leaf, journalTestLeaf(byte(i)); !reflect.DeepEqual(got, want) {
+			t.Fatalf("Recovered leaf %d: got %v, expected %v", i, got, want)
+		}
+		if !entry.queued.Equal(fakeTime) {
+			t.Fatalf("Recovered leaf %d: got queue time %v, expected %v", i, entry.queued, fakeTime)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "3"+journalTempSuffix)); !os.IsNotExist(err) {
+		t.Fatalf("Partial journal entry was not removed: %v", err)
+	}
+
+	// New entries must not reuse the ids of recovered ones
+	if err := j2.Append(journalTestLeaf(3)); err != nil {
+		t.Fatalf("Failed to append leaf: %v", err)
+	}
+
+	if got, want := j2.pending[3].id, int64(3); got != want {
+		t.Fatalf("Got id %d for new leaf, expected %d", got, want)
+	}
+}
+
+func TestLeafJournalRefusesWhenBehind(t *testing.T) {
+	dir := makeTempJournalDir(t)
+	defer os.RemoveAll(dir)
+
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+	j := openJournalOrDie(t, dir, ts)
+
+	if err := j.Append(journalTestLeaf(0)); err != nil {
+		t.Fatalf("Failed to append leaf: %v", err)
+	}
+
+	ts.FakeTime = fakeTime.Add(testJournalMaxAge)
+
+	if err := j.Append(journalTestLeaf(1)); err != nil {
+		t.Fatalf("Failed to append leaf at max age: %v", err)
+	}
+
+	if got, want := j.OldestAge(), testJournalMaxAge; got != want {
+		t.Fatalf("Got oldest age %v, expected %v", got, want)
+	}
+
+	ts.FakeTime = fakeTime.Add(testJournalMaxAge + time.Nanosecond)
+
+	if err := j.Append(journalTestLeaf(2)); err != errJournalBehind {
+		t.Fatalf("Got %v appending to a journal that is behind, expected %v", err, errJournalBehind)
+	}
+
+	if got, want := j.Pending(), 2; got != want {
+		t.Fatalf("Got %d pending leaves, expected %d", got, want)
+	}
+}
+
+func TestLeafJournalFlush(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	dir := makeTempJournalDir(t)
+	defer os.RemoveAll(dir)
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	j := openJournalOrDie(t, dir, fakeTimeSource)
+
+	for i := byte(0); i < 3; i++ {
+		if err := j.Append(journalTestLeaf(i)); err != nil {
+			t.Fatalf("Failed to append leaf: %v", err)
+		}
+	}
+
+	leaf0, leaf1, leaf2 := journalTestLeaf(0), journalTestLeaf(1), journalTestLeaf(2)
+	batch0, batch1 := []*trillian.LeafProto{&leaf0, &leaf1}, []*trillian.LeafProto{&leaf2}
+	client.EXPECT().QueueLeaves(gomock.Any(), &trillian.QueueLeavesRequest{LogId: 0x42, Leaves: batch0, IdempotencyKey: journalIdempotencyKey(batch0)}).Return(&trillian.QueueLeavesResponse{Status: okStatus}, nil)
+	client.EXPECT().QueueLeaves(gomock.Any(), &trillian.QueueLeavesRequest{LogId: 0x42, Leaves: batch1, IdempotencyKey: journalIdempotencyKey(batch1)}).Return(&trillian.QueueLeavesResponse{Status: okStatus}, nil)
+
+	if flushed, err := j.Flush(context.Background(), client, 0x42, 2); err != nil || flushed != 2 {
+		t.Fatalf("Got %d, %v from first flush, expected 2, nil", flushed, err)
+	}
+
+	if flushed, err := j.Flush(context.Background(), client, 0x42, 2); err != nil || flushed != 1 {
+		t.Fatalf("Got %d, %v from second flush, expected 1, nil", flushed, err)
+	}
+
+	// Nothing left so there should be no backend request
+	if flushed, err := j.Flush(context.Background(), client, 0x42, 2); err != nil || flushed != 0 {
+		t.Fatalf("Got %d, %v from empty flush, expected 0, nil", flushed, err)
+	}
+
+	// And nothing should be recovered after a restart
+	if got := openJournalOrDie(t, dir, fakeTimeSource).Pending(); got != 0 {
+		t.Fatalf("Got %d pending leaves after flush and restart, expected 0", got)
+	}
+}
+
+func TestLeafJournalFlushFailureRetains(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	dir := makeTempJournalDir(t)
+	defer os.RemoveAll(dir)
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	j := openJournalOrDie(t, dir, fakeTimeSource)
+
+	if err := j.Append(journalTestLeaf(0)); err != nil {
+		t.Fatalf("Failed to append leaf: %v", err)
+	}
+
+	// The retry must have the same idempotency key in case the first request was queued
+	leaf0 := journalTestLeaf(0)
+	batch := []*trillian.LeafProto{&leaf0}
+	request := &trillian.QueueLeavesRequest{LogId: 0x42, Leaves: batch, IdempotencyKey: journalIdempotencyKey(batch)}
+	errorStatus := &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_ERROR}
+	client.EXPECT().QueueLeaves(gomock.Any(), request).Return(nil, errors.New("rpc"))
+	client.EXPECT().QueueLeaves(gomock.Any(), request).Return(&trillian.QueueLeavesResponse{Status: errorStatus}, nil)
+
+	if _, err := j.Flush(context.Background(), client, 0x42, 10); err == nil {
+		t.Fatal("Flush ignored rpc error")
+	}
+
+	if _, err := j.Flush(context.Background(), client, 0x42, 10); err == nil {
+		t.Fatal("Flush ignored error status")
+	}
+
+	if got, want := j.Pending(), 1; got != want {
+		t.Fatalf("Got %d pending leaves after failed flush, expected %d", got, want)
+	}
+
+	if got, want := openJournalOrDie(t, dir, fakeTimeSource).Pending(), 1; got != want {
+		t.Fatalf("Got %d pending leaves after failed flush and restart, expected %d", got, want)
+	}
+}
+
+func TestJournalIdempotencyKey(t *testing.T) {
+	leaf0, leaf1 := journalTestLeaf(0), journalTestLeaf(1)
+	joined := trillian.LeafProto{LeafData: append(append([]byte{}, leaf0.LeafData...), leaf1.LeafData...)}
+
+	key := journalIdempotencyKey([]*trillian.LeafProto{&leaf0, &leaf1})
+
+	if !strings.HasPrefix(key, journalIdempotencyKeyPrefix) {
+		t.Errorf("Key %s doesn't start with %s", key, journalIdempotencyKeyPrefix)
+	}
+
+	for _, other := range [][]*trillian.LeafProto{{&leaf1, &leaf0}, {&leaf0}, {&joined}} {
+		if journalIdempotencyKey(other) == key {
+			t.Errorf("Batch %v has the same key as [leaf0, leaf1]", other)
+		}
+	}
+}
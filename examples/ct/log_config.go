@@ -0,0 +1,262 @@
+package ct
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/util"
+)
+
+// LogConfig describes one log served by a frontend and the backend that serves it. Logs
+// can use different backends, e.g. when trees are sharded across clusters.
+type LogConfig struct {
+	// LogID is the tree ID of the log in the backend
+	LogID int64 `json:"log_id"`
+	// Prefix is the path the log's endpoints are served under, e.g. "pilot" serves
+	// /pilot/ct/v1/add-chain and "2017/pilot" serves /2017/pilot/ct/v1/add-chain. At most one
+	// log can have an empty prefix, which serves /ct/v1/.
+	Prefix string `json:"prefix"`
+	// RPCBackend is the address of the log RPC server that holds the tree
+	RPCBackend string `json:"rpc_backend"`
+	// RPCCompression is how requests to the backend are compressed, one of none, gzip,
+	// gzip-fast or snappy. It must use the same encoding as the backend's --rpc_compression
+	// and logs sharing a backend must agree on it. Compression mostly helps get-entries, whose
+	// responses hold whole chains.
+	RPCCompression string `json:"rpc_compression"`
+	// TrustedRoots is a file containing the PEM encoded roots the log accepts
+	TrustedRoots string `json:"trusted_roots"`
+	// PrivateKey and PublicKey are PEM files containing the log's keys
+	PrivateKey         string `json:"private_key"`
+	PrivateKeyPassword string `json:"private_key_password"`
+	PublicKey          string `json:"public_key"`
+	// SigningServer is the address of a signing service, such as one run by cmd/signing_server,
+	// that holds the log's private key. If it's set the key files aren't used and the public
+	// key is fetched from the service.
+	SigningServer string `json:"signing_server"`
+	// SigningKeyID is the ID of the log's key in the signing service, "default" if empty
+	SigningKeyID string `json:"signing_key_id"`
+	// SignatureHash is the hash function signed in SCTs and STHs, e.g. "sha384". If it's empty
+	// the hash is chosen to suit the key.
+	SignatureHash string `json:"signature_hash"`
+	// RSAPSS makes an RSA key sign with RSASSA-PSS, see SignatureOptions
+	RSAPSS bool `json:"rsa_pss"`
+	// HashAlgorithm is the hash function the log's tree was created with, e.g. "SHA512_256",
+	// see WithHashAlgorithm. If it's empty the tree uses SHA-256.
+	HashAlgorithm string `json:"hash_algorithm"`
+	// ExtraDataCommitment makes the log add the hash of each submitted chain to its leaf and
+	// SCT as an extension, see WithExtraDataCommitment. Leaves and SCTs then aren't the plain
+	// RFC 6962 ones, so it can't be changed once the log has entries.
+	ExtraDataCommitment bool `json:"extra_data_commitment"`
+	// Submitters is a file containing a JSON array of SubmitterConfig. If it's set only those
+	// submitters can use add-chain and add-pre-chain.
+	Submitters string `json:"submitters"`
+	// NotAfterStart and NotAfterLimit are set if the log is a temporal shard that only accepts
+	// certificates expiring in [start, limit), in RFC 3339 format, see WithNotAfterWindow.
+	// Either can be left empty to leave that end of the window open.
+	NotAfterStart string `json:"not_after_start"`
+	NotAfterLimit string `json:"not_after_limit"`
+	// LogList is how the log is described in the operator's CT log list
+	LogList LogListConfig `json:"log_list"`
+}
+
+// LoadLogConfigs reads and validates a JSON array of LogConfig from a file.
+func LoadLogConfigs(path string) ([]LogConfig, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseLogConfigs(data)
+}
+
+// ParseLogConfigs parses and validates a JSON array of LogConfig. Every log must have a
+// backend, roots and either keys or a signing server, the log IDs and prefixes must be unique and logs on the same
+// backend must use compatible compression.
+func ParseLogConfigs(data []byte) ([]LogConfig, error) {
+	var configs []LogConfig
+
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse log config: %v", err)
+	}
+
+	if len(configs) == 0 {
+		return nil, errors.New("log config contains no logs")
+	}
+
+	logIDs := make(map[int64]bool)
+	prefixes := make(map[string]bool)
+	// Logs on the same backend share a connection, so they can only have one encoding
+	encodings := make(map[string]string)
+
+	for _, config := range configs {
+		if err := config.validate(); err != nil {
+			return nil, fmt.Errorf("invalid config for log %d: %v", config.LogID, err)
+		}
+
+		if logIDs[config.LogID] {
+			return nil, fmt.Errorf("log %d is configured more than once", config.LogID)
+		}
+
+		if prefixes[config.Prefix] {
+			return nil, fmt.Errorf("prefix %q is used by more than one log", config.Prefix)
+		}
+
+		// Already checked by validate()
+		encoding, _ := util.RPCCompressionEncoding(config.RPCCompression)
+
+		if other, ok := encodings[config.RPCBackend]; ok && other != encoding {
+			return nil, fmt.Errorf("log %d uses a different rpc_compression to other logs on backend %s", config.LogID, config.RPCBackend)
+		}
+
+		logIDs[config.LogID] = true
+		prefixes[config.Prefix] = true
+		encodings[config.RPCBackend] = encoding
+	}
+
+	return configs, nil
+}
+
+// ValidateBasePath checks that base can be given to WithBasePath. It's one or more path
+// segments separated by '/', and can be empty.
+func ValidateBasePath(base string) error {
+	return validatePath(strings.Trim(base, "/"))
+}
+
+// validatePath checks that path is empty or is segments separated by single slashes, none of
+// which would be changed or misinterpreted when it's used in a URL.
+func validatePath(path string) error {
+	if len(path) == 0 {
+		return nil
+	}
+
+	for _, segment := range strings.Split(path, "/") {
+		switch {
+		case len(segment) == 0:
+			return fmt.Errorf("path has an empty segment: %q", path)
+		case segment == "." || segment == "..":
+			return fmt.Errorf("path has a relative segment: %q", path)
+		case strings.ContainsAny(segment, "?#% "):
+			return fmt.Errorf("path segment %q contains a character that must be escaped", segment)
+		}
+	}
+
+	return nil
+}
+
+func (l LogConfig) validate() error {
+	if strings.HasPrefix(l.Prefix, "/") || strings.HasSuffix(l.Prefix, "/") {
+		return fmt.Errorf("prefix must not start or end with '/': %q", l.Prefix)
+	}
+
+	if err := validatePath(l.Prefix); err != nil {
+		return fmt.Errorf("invalid prefix: %v", err)
+	}
+
+	required := map[string]string{"rpc_backend": l.RPCBackend, "trusted_roots": l.TrustedRoots}
+
+	if len(l.SigningServer) == 0 {
+		required["private_key"] = l.PrivateKey
+		required["public_key"] = l.PublicKey
+	}
+
+	for name, value := range required {
+		if len(value) == 0 {
+			return fmt.Errorf("%s must be set", name)
+		}
+	}
+
+	if _, err := ParseSignatureHash(l.SignatureHash); err != nil {
+		return err
+	}
+
+	if _, err := util.RPCCompressionEncoding(l.RPCCompression); err != nil {
+		return err
+	}
+
+	if _, err := l.TreeHashAlgorithm(); err != nil {
+		return err
+	}
+
+	if _, err := l.NotAfterWindow(); err != nil {
+		return err
+	}
+
+	if err := l.LogList.validate(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// NotAfterWindow returns the expiry times the log accepts, or nil if it isn't a temporal shard.
+func (l LogConfig) NotAfterWindow() (*NotAfterWindow, error) {
+	if len(l.NotAfterStart) == 0 && len(l.NotAfterLimit) == 0 {
+		return nil, nil
+	}
+
+	var window NotAfterWindow
+	var err error
+
+	if len(l.NotAfterStart) > 0 {
+		if window.Start, err = time.Parse(time.RFC3339, l.NotAfterStart); err != nil {
+			return nil, fmt.Errorf("invalid not_after_start: %v", err)
+		}
+	}
+
+	if len(l.NotAfterLimit) > 0 {
+		if window.Limit, err = time.Parse(time.RFC3339, l.NotAfterLimit); err != nil {
+			return nil, fmt.Errorf("invalid not_after_limit: %v", err)
+		}
+	}
+
+	if !window.Start.IsZero() && !window.Limit.IsZero() && !window.Start.Before(window.Limit) {
+		return nil, fmt.Errorf("NotAfter window is empty: [%s, %s)", l.NotAfterStart, l.NotAfterLimit)
+	}
+
+	return &window, nil
+}
+
+// TreeHashAlgorithm returns the hash function of the log's tree. It must produce 32 byte
+// hashes because that's the size of the root hash in an STH.
+func (l LogConfig) TreeHashAlgorithm() (trillian.HashAlgorithm, error) {
+	if len(l.HashAlgorithm) == 0 {
+		return trillian.HashAlgorithm_SHA256, nil
+	}
+
+	value, ok := trillian.HashAlgorithm_value[l.HashAlgorithm]
+
+	if !ok {
+		return trillian.HashAlgorithm_SHA256, fmt.Errorf("unknown hash_algorithm: %q", l.HashAlgorithm)
+	}
+
+	alg := trillian.HashAlgorithm(value)
+	hasher, err := trillian.NewHasher(alg)
+
+	if err != nil {
+		return trillian.HashAlgorithm_SHA256, err
+	}
+
+	if hasher.Size() != sha256.Size {
+		return trillian.HashAlgorithm_SHA256, fmt.Errorf("hash_algorithm %s produces %d byte hashes, STHs need %d", l.HashAlgorithm, hasher.Size(), sha256.Size)
+	}
+
+	return alg, nil
+}
+
+// SignatureOptions returns the options the log's SCTs and STHs should be signed with.
+func (l LogConfig) SignatureOptions() (SignatureOptions, error) {
+	hash, err := ParseSignatureHash(l.SignatureHash)
+
+	if err != nil {
+		return SignatureOptions{}, err
+	}
+
+	return SignatureOptions{Hash: hash, RSAPSS: l.RSAPSS}, nil
+}
@@ -0,0 +1,168 @@
+package ct
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+)
+
+func TestParseLogConfigs(t *testing.T) {
+	configs, err := ParseLogConfigs([]byte(`[
+		{"log_id": 1, "rpc_backend": "shard1:8090", "trusted_roots": "roots.pem", "private_key": "priv.pem", "public_key": "pub.pem"},
+		{"log_id": 2, "prefix": "pilot", "rpc_backend": "shard2:8090", "rpc_compression": "snappy", "trusted_roots": "roots.pem", "private_key": "priv2.pem", "private_key_password": "towel", "public_key": "pub2.pem", "signature_hash": "sha384", "rsa_pss": true},
+		{"log_id": 3, "prefix": "remote", "rpc_backend": "shard1:8090", "trusted_roots": "roots.pem", "signing_server": "signer:8094", "signing_key_id": "log3"}
+	]`))
+
+	if err != nil {
+		t.Fatalf("Failed to parse valid config: %v", err)
+	}
+
+	if got, want := len(configs), 3; got != want {
+		t.Fatalf("Got %d logs, expected %d", got, want)
+	}
+
+	want := LogConfig{LogID: 2, Prefix: "pilot", RPCBackend: "shard2:8090", RPCCompression: "snappy", TrustedRoots: "roots.pem", PrivateKey: "priv2.pem", PrivateKeyPassword: "towel", PublicKey: "pub2.pem", SignatureHash: "sha384", RSAPSS: true}
+
+	if got := configs[1]; got != want {
+		t.Fatalf("Got config %+v, expected %+v", got, want)
+	}
+
+	// Logs whose key is held by a signing server don't need key files
+	want = LogConfig{LogID: 3, Prefix: "remote", RPCBackend: "shard1:8090", TrustedRoots: "roots.pem", SigningServer: "signer:8094", SigningKeyID: "log3"}
+
+	if got := configs[2]; got != want {
+		t.Fatalf("Got config %+v, expected %+v", got, want)
+	}
+}
+
+func TestParseLogConfigsRejectsInvalid(t *testing.T) {
+	for _, test := range []struct {
+		config      string
+		explanation string
+	}{
+		{`{"log_id": 1}`, "not an array"},
+		{`[]`, "no logs"},
+		{`[{"log_id": 1, "trusted_roots": "r", "private_key": "k", "public_key": "p"}]`, "no backend"},
+		{`[{"log_id": 1, "rpc_backend": "b", "private_key": "k", "public_key": "p"}]`, "no roots"},
+		{`[{"log_id": 1, "rpc_backend": "b", "trusted_roots": "r", "public_key": "p"}]`, "no private key"},
+		{`[{"log_id": 1, "rpc_backend": "b", "trusted_roots": "r", "private_key": "k"}]`, "no public key"},
+		{`[{"log_id": 1, "prefix": "/a", "rpc_backend": "b", "trusted_roots": "r", "private_key": "k", "public_key": "p"}]`, "leading slash in prefix"},
+		{`[{"log_id": 1, "prefix": "a/", "rpc_backend": "b", "trusted_roots": "r", "private_key": "k", "public_key": "p"}]`, "trailing slash in prefix"},
+		{`[{"log_id": 1, "prefix": "a//b", "rpc_backend": "b", "trusted_roots": "r", "private_key": "k", "public_key": "p"}]`, "empty segment in prefix"},
+		{`[{"log_id": 1, "prefix": "a/../b", "rpc_backend": "b", "trusted_roots": "r", "private_key": "k", "public_key": "p"}]`, "relative segment in prefix"},
+		{`[{"log_id": 1, "rpc_backend": "b", "trusted_roots": "r", "private_key": "k", "public_key": "p", "signature_hash": "md5"}]`, "unsupported hash"},
+		{`[{"log_id": 1, "prefix": "a", "rpc_backend": "b", "trusted_roots": "r", "private_key": "k", "public_key": "p"},
+		   {"log_id": 1, "prefix": "b", "rpc_backend": "b", "trusted_roots": "r", "private_key": "k", "public_key": "p"}]`, "duplicate log ID"},
+		{`[{"log_id": 1, "rpc_backend": "b", "trusted_roots": "r", "private_key": "k", "public_key": "p"},
+		   {"log_id": 2, "rpc_backend": "b", "trusted_roots": "r", "private_key": "k", "public_key": "p"}]`, "duplicate empty prefix"},
+		{`[{"log_id": 1, "rpc_backend": "b", "trusted_roots": "r", "private_key": "k", "public_key": "p", "hash_algorithm": "MD5"}]`, "unknown tree hash"},
+		{`[{"log_id": 1, "rpc_backend": "b", "trusted_roots": "r", "private_key": "k", "public_key": "p", "log_list": {"state": "frozen"}}]`, "unknown log list state"},
+		{`[{"log_id": 1, "rpc_backend": "b", "trusted_roots": "r", "private_key": "k", "public_key": "p", "not_after_start": "2018"}]`, "invalid NotAfter start"},
+		{`[{"log_id": 1, "rpc_backend": "b", "trusted_roots": "r", "private_key": "k", "public_key": "p", "not_after_start": "2019-01-01T00:00:00Z", "not_after_limit": "2018-01-01T00:00:00Z"}]`, "empty NotAfter window"},
+		{`[{"log_id": 1, "rpc_backend": "b", "rpc_compression": "zstd", "trusted_roots": "r", "private_key": "k", "public_key": "p"}]`, "unknown compression"},
+		{`[{"log_id": 1, "prefix": "a", "rpc_backend": "b", "rpc_compression": "gzip", "trusted_roots": "r", "private_key": "k", "public_key": "p"},
+		   {"log_id": 2, "prefix": "b", "rpc_backend": "b", "trusted_roots": "r", "private_key": "k", "public_key": "p"}]`, "different compression on the same backend"},
+	} {
+		if _, err := ParseLogConfigs([]byte(test.config)); err == nil {
+			t.Errorf("Accepted invalid config (%s): %s", test.explanation, test.config)
+		}
+	}
+}
+
+func TestParseLogConfigsNestedPrefix(t *testing.T) {
+	configs, err := ParseLogConfigs([]byte(`[
+		{"log_id": 1, "prefix": "2017/pilot", "rpc_backend": "b", "trusted_roots": "r", "private_key": "k", "public_key": "p"},
+		{"log_id": 2, "prefix": "2018/pilot", "rpc_backend": "b", "trusted_roots": "r", "private_key": "k", "public_key": "p"}
+	]`))
+
+	if err != nil {
+		t.Fatalf("Failed to parse config with nested prefixes: %v", err)
+	}
+
+	if got, want := configs[0].Prefix, "2017/pilot"; got != want {
+		t.Errorf("Got prefix %q, expected %q", got, want)
+	}
+}
+
+func TestParseLogConfigsCompatibleCompression(t *testing.T) {
+	// gzip and gzip-fast are the same encoding, and logs on different backends can differ
+	if _, err := ParseLogConfigs([]byte(`[
+		{"log_id": 1, "prefix": "a", "rpc_backend": "b", "rpc_compression": "gzip", "trusted_roots": "r", "private_key": "k", "public_key": "p"},
+		{"log_id": 2, "prefix": "b", "rpc_backend": "b", "rpc_compression": "gzip-fast", "trusted_roots": "r", "private_key": "k", "public_key": "p"},
+		{"log_id": 3, "prefix": "c", "rpc_backend": "c", "rpc_compression": "snappy", "trusted_roots": "r", "private_key": "k", "public_key": "p"}
+	]`)); err != nil {
+		t.Errorf("Failed to parse config with compatible compression: %v", err)
+	}
+}
+
+func TestLogConfigTreeHashAlgorithm(t *testing.T) {
+	for _, test := range []struct {
+		hashAlgorithm string
+		want          trillian.HashAlgorithm
+	}{
+		{"", trillian.HashAlgorithm_SHA256},
+		{"SHA256", trillian.HashAlgorithm_SHA256},
+		{"SHA512_256", trillian.HashAlgorithm_SHA512_256},
+		{"SHA3_256", trillian.HashAlgorithm_SHA3_256},
+	} {
+		got, err := LogConfig{HashAlgorithm: test.hashAlgorithm}.TreeHashAlgorithm()
+
+		if err != nil {
+			t.Errorf("TreeHashAlgorithm() for %q failed: %v", test.hashAlgorithm, err)
+			continue
+		}
+
+		if got != test.want {
+			t.Errorf("TreeHashAlgorithm() for %q = %v, expected %v", test.hashAlgorithm, got, test.want)
+		}
+	}
+}
+
+func TestLogConfigNotAfterWindow(t *testing.T) {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	limit := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, test := range []struct {
+		config LogConfig
+		want   *NotAfterWindow
+	}{
+		{LogConfig{}, nil},
+		{LogConfig{NotAfterStart: "2018-01-01T00:00:00Z", NotAfterLimit: "2019-01-01T00:00:00Z"}, &NotAfterWindow{Start: start, Limit: limit}},
+		{LogConfig{NotAfterStart: "2018-01-01T00:00:00Z"}, &NotAfterWindow{Start: start}},
+		{LogConfig{NotAfterLimit: "2019-01-01T00:00:00Z"}, &NotAfterWindow{Limit: limit}},
+	} {
+		got, err := test.config.NotAfterWindow()
+
+		if err != nil {
+			t.Errorf("NotAfterWindow() for %+v failed: %v", test.config, err)
+			continue
+		}
+
+		if (got == nil) != (test.want == nil) || (got != nil && (!got.Start.Equal(test.want.Start) || !got.Limit.Equal(test.want.Limit))) {
+			t.Errorf("NotAfterWindow() for %+v = %v, expected %v", test.config, got, test.want)
+		}
+	}
+}
+
+func TestValidateBasePath(t *testing.T) {
+	for _, test := range []struct {
+		base string
+		ok   bool
+	}{
+		{"", true},
+		{"/", true},
+		{"logs", true},
+		{"/logs/eu/", true},
+		{"logs//eu", false},
+		{"/logs/../admin", false},
+		{"./logs", false},
+		{"logs?x=1", false},
+		{"logs%2F", false},
+		{"my logs", false},
+	} {
+		if err := ValidateBasePath(test.base); (err == nil) != test.ok {
+			t.Errorf("ValidateBasePath(%q)=%v, expected success %v", test.base, err, test.ok)
+		}
+	}
+}
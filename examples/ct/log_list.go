@@ -0,0 +1,158 @@
+package ct
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// defaultMMD is the maximum merge delay published for a log unless its LogListConfig has one.
+// It's the usual 24 hours.
+const defaultMMD = 86400
+
+// logListStates are the states a log can be in in a log list
+var logListStates = map[string]bool{"pending": true, "qualified": true, "usable": true, "readonly": true, "retired": true, "rejected": true}
+
+// LogListConfig describes how a log is published in a CT log list, see NewLogListLog. None of
+// it affects how the log is served.
+type LogListConfig struct {
+	// Description is the human readable name of the log, e.g. "Example 'Pilot' log"
+	Description string `json:"description"`
+	// URL is the public address of the log, ending with "/", under which clients find
+	// ct/v1/. If it's empty it's made from the operator's base URL and the log's prefix.
+	URL string `json:"url"`
+	// MMD is the log's maximum merge delay in seconds, 86400 if it's zero
+	MMD int `json:"mmd"`
+	// State is the state of the log in the log list, e.g. "usable", and StateTimestamp is
+	// when it entered that state in RFC 3339 format. No state is published if State is empty.
+	State          string `json:"state"`
+	StateTimestamp string `json:"state_timestamp"`
+	// TemporalIntervalStart and TemporalIntervalEnd are set if the log is a shard that only
+	// accepts certificates expiring in [start, end), in RFC 3339 format. If they're empty the
+	// log's NotAfter window is published, if it has both ends.
+	TemporalIntervalStart string `json:"temporal_interval_start"`
+	TemporalIntervalEnd   string `json:"temporal_interval_end"`
+}
+
+// validate checks the fields that are published as they are.
+func (l LogListConfig) validate() error {
+	if l.MMD < 0 {
+		return fmt.Errorf("log_list mmd must not be negative: %d", l.MMD)
+	}
+
+	if len(l.URL) > 0 && !strings.HasSuffix(l.URL, "/") {
+		return fmt.Errorf("log_list url must end with '/': %q", l.URL)
+	}
+
+	if len(l.State) > 0 {
+		if !logListStates[l.State] {
+			return fmt.Errorf("unknown log_list state: %q", l.State)
+		}
+
+		if _, err := time.Parse(time.RFC3339, l.StateTimestamp); err != nil {
+			return fmt.Errorf("invalid log_list state_timestamp: %v", err)
+		}
+	}
+
+	if len(l.TemporalIntervalStart) > 0 || len(l.TemporalIntervalEnd) > 0 {
+		start, err := time.Parse(time.RFC3339, l.TemporalIntervalStart)
+
+		if err != nil {
+			return fmt.Errorf("invalid log_list temporal_interval_start: %v", err)
+		}
+
+		end, err := time.Parse(time.RFC3339, l.TemporalIntervalEnd)
+
+		if err != nil {
+			return fmt.Errorf("invalid log_list temporal_interval_end: %v", err)
+		}
+
+		if !start.Before(end) {
+			return fmt.Errorf("log_list temporal interval is empty: [%s, %s)", l.TemporalIntervalStart, l.TemporalIntervalEnd)
+		}
+	}
+
+	return nil
+}
+
+// LogList is a CT log list in the version 3 JSON schema published by browser vendors, holding
+// the logs of one or more operators.
+type LogList struct {
+	Version          string            `json:"version,omitempty"`
+	LogListTimestamp string            `json:"log_list_timestamp"`
+	Operators        []LogListOperator `json:"operators"`
+}
+
+// LogListOperator is an operator and its logs in a LogList.
+type LogListOperator struct {
+	Name  string       `json:"name"`
+	Email []string     `json:"email"`
+	Logs  []LogListLog `json:"logs"`
+}
+
+// LogListLog is a log in a LogList. The log ID and key are base64 encoded in JSON.
+type LogListLog struct {
+	Description      string                  `json:"description"`
+	LogID            []byte                  `json:"log_id"`
+	Key              []byte                  `json:"key"`
+	URL              string                  `json:"url"`
+	MMD              int                     `json:"mmd"`
+	State            map[string]LogListState `json:"state,omitempty"`
+	TemporalInterval *LogListInterval        `json:"temporal_interval,omitempty"`
+}
+
+// LogListState is when a log entered the state it's keyed by in LogListLog.State.
+type LogListState struct {
+	Timestamp string `json:"timestamp"`
+}
+
+// LogListInterval is the range of certificate expiry times accepted by a log shard.
+type LogListInterval struct {
+	StartInclusive string `json:"start_inclusive"`
+	EndExclusive   string `json:"end_exclusive"`
+}
+
+// NewLogListLog returns the log list entry for a log served by a frontend, given the DER
+// encoding of the log's public key. The log's URL is baseURL followed by its prefix unless
+// its LogListConfig sets one.
+func NewLogListLog(config LogConfig, publicKeyDER []byte, baseURL string) (LogListLog, error) {
+	if err := config.LogList.validate(); err != nil {
+		return LogListLog{}, err
+	}
+
+	url := config.LogList.URL
+
+	if len(url) == 0 {
+		if len(baseURL) == 0 {
+			return LogListLog{}, fmt.Errorf("log %d has no log_list url and there's no base URL", config.LogID)
+		}
+
+		url = strings.TrimSuffix(baseURL, "/") + "/"
+
+		if len(config.Prefix) > 0 {
+			url += config.Prefix + "/"
+		}
+	}
+
+	mmd := config.LogList.MMD
+
+	if mmd == 0 {
+		mmd = defaultMMD
+	}
+
+	logID := sha256.Sum256(publicKeyDER)
+	log := LogListLog{Description: config.LogList.Description, LogID: logID[:], Key: publicKeyDER, URL: url, MMD: mmd}
+
+	if len(config.LogList.State) > 0 {
+		log.State = map[string]LogListState{config.LogList.State: {Timestamp: config.LogList.StateTimestamp}}
+	}
+
+	if len(config.LogList.TemporalIntervalStart) > 0 {
+		log.TemporalInterval = &LogListInterval{StartInclusive: config.LogList.TemporalIntervalStart, EndExclusive: config.LogList.TemporalIntervalEnd}
+	} else if len(config.NotAfterStart) > 0 && len(config.NotAfterLimit) > 0 {
+		log.TemporalInterval = &LogListInterval{StartInclusive: config.NotAfterStart, EndExclusive: config.NotAfterLimit}
+	}
+
+	return log, nil
+}
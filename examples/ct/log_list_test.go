@@ -0,0 +1,144 @@
+package ct
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/examples/ct/testonly"
+)
+
+func TestNewLogListLog(t *testing.T) {
+	km := crypto.NewPEMKeyManager()
+
+	if err := km.LoadPublicKey(testonly.CTLogPublicKeyPEM); err != nil {
+		t.Fatalf("Failed to load public key: %v", err)
+	}
+
+	key, err := km.GetRawPublicKey()
+
+	if err != nil {
+		t.Fatalf("Failed to get public key: %v", err)
+	}
+
+	config := LogConfig{LogID: 1, Prefix: "2018/pilot", LogList: LogListConfig{
+		Description:           "Example 'Pilot' 2018 log",
+		State:                 "usable",
+		StateTimestamp:        "2017-06-01T00:00:00Z",
+		TemporalIntervalStart: "2018-01-01T00:00:00Z",
+		TemporalIntervalEnd:   "2019-01-01T00:00:00Z",
+	}}
+
+	log, err := NewLogListLog(config, key, "https://ct.example.com/")
+
+	if err != nil {
+		t.Fatalf("Failed to create log list entry: %v", err)
+	}
+
+	want := LogListLog{
+		Description:      "Example 'Pilot' 2018 log",
+		LogID:            log.LogID,
+		Key:              key,
+		URL:              "https://ct.example.com/2018/pilot/",
+		MMD:              86400,
+		State:            map[string]LogListState{"usable": {Timestamp: "2017-06-01T00:00:00Z"}},
+		TemporalInterval: &LogListInterval{StartInclusive: "2018-01-01T00:00:00Z", EndExclusive: "2019-01-01T00:00:00Z"},
+	}
+
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("Got log list entry %+v, expected %+v", log, want)
+	}
+
+	if got, want := base64.StdEncoding.EncodeToString(log.LogID), testonly.CTLogIDBase64; got != want {
+		t.Errorf("Got log ID %s, expected %s", got, want)
+	}
+
+	// The log ID and key are base64 in the log list, as []byte is in JSON
+	data, err := json.Marshal(log)
+
+	if err != nil {
+		t.Fatalf("Failed to marshal log list entry: %v", err)
+	}
+
+	var fields map[string]interface{}
+
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("Failed to unmarshal log list entry: %v", err)
+	}
+
+	if got, want := fields["log_id"], testonly.CTLogIDBase64; got != want {
+		t.Errorf("Got log_id %v in JSON, expected %s", got, want)
+	}
+}
+
+func TestNewLogListLogURL(t *testing.T) {
+	for _, test := range []struct {
+		prefix  string
+		url     string
+		baseURL string
+		want    string
+	}{
+		{"", "", "https://ct.example.com", "https://ct.example.com/"},
+		{"pilot", "", "https://ct.example.com/logs/", "https://ct.example.com/logs/pilot/"},
+		{"pilot", "https://pilot.example.com/", "https://ct.example.com/", "https://pilot.example.com/"},
+		{"pilot", "https://pilot.example.com/", "", "https://pilot.example.com/"},
+	} {
+		config := LogConfig{Prefix: test.prefix, LogList: LogListConfig{URL: test.url}}
+		log, err := NewLogListLog(config, []byte("key"), test.baseURL)
+
+		if err != nil {
+			t.Errorf("NewLogListLog() for %+v failed: %v", test, err)
+			continue
+		}
+
+		if got := log.URL; got != test.want {
+			t.Errorf("Got URL %s for %+v, expected %s", got, test, test.want)
+		}
+	}
+}
+
+func TestNewLogListLogNotAfterWindow(t *testing.T) {
+	for _, test := range []struct {
+		config LogConfig
+		want   *LogListInterval
+	}{
+		{LogConfig{NotAfterStart: "2018-01-01T00:00:00Z", NotAfterLimit: "2019-01-01T00:00:00Z"}, &LogListInterval{StartInclusive: "2018-01-01T00:00:00Z", EndExclusive: "2019-01-01T00:00:00Z"}},
+		// An open window can't be published as a temporal interval
+		{LogConfig{NotAfterStart: "2018-01-01T00:00:00Z"}, nil},
+		// The log list config wins
+		{LogConfig{NotAfterStart: "2018-01-01T00:00:00Z", NotAfterLimit: "2019-01-01T00:00:00Z", LogList: LogListConfig{TemporalIntervalStart: "2018-02-01T00:00:00Z", TemporalIntervalEnd: "2019-02-01T00:00:00Z"}}, &LogListInterval{StartInclusive: "2018-02-01T00:00:00Z", EndExclusive: "2019-02-01T00:00:00Z"}},
+	} {
+		log, err := NewLogListLog(test.config, []byte("key"), "https://ct.example.com/")
+
+		if err != nil {
+			t.Errorf("NewLogListLog() for %+v failed: %v", test.config, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(log.TemporalInterval, test.want) {
+			t.Errorf("Got temporal interval %+v for %+v, expected %+v", log.TemporalInterval, test.config, test.want)
+		}
+	}
+}
+
+func TestNewLogListLogRejectsInvalid(t *testing.T) {
+	for _, test := range []struct {
+		config      LogListConfig
+		baseURL     string
+		explanation string
+	}{
+		{LogListConfig{}, "", "no URL"},
+		{LogListConfig{URL: "https://ct.example.com"}, "", "URL without trailing slash"},
+		{LogListConfig{MMD: -1}, "https://ct.example.com/", "negative MMD"},
+		{LogListConfig{State: "frozen", StateTimestamp: "2017-06-01T00:00:00Z"}, "https://ct.example.com/", "unknown state"},
+		{LogListConfig{State: "usable"}, "https://ct.example.com/", "state without timestamp"},
+		{LogListConfig{TemporalIntervalStart: "2018-01-01T00:00:00Z"}, "https://ct.example.com/", "interval without end"},
+		{LogListConfig{TemporalIntervalStart: "2019-01-01T00:00:00Z", TemporalIntervalEnd: "2018-01-01T00:00:00Z"}, "https://ct.example.com/", "empty interval"},
+	} {
+		if _, err := NewLogListLog(LogConfig{LogList: test.config}, []byte("key"), test.baseURL); err == nil {
+			t.Errorf("Accepted invalid log list config (%s): %+v", test.explanation, test.config)
+		}
+	}
+}
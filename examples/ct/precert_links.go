@@ -0,0 +1,300 @@
+package ct
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/certificate-transparency/go/asn1"
+	"github.com/google/certificate-transparency/go/x509"
+	"github.com/google/trillian/util"
+)
+
+// precertSigningCertOID is the extended key usage of a Precertificate Signing Certificate,
+// which a CA can use to sign precertificates instead of its own key. See RFC 6962 section 3.1.
+var precertSigningCertOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 4}
+
+// The name of the get-precert-link parameter holding the hash of a certificate or precertificate
+const precertLinkParamHash = "hash"
+
+// precertLinkKey identifies the precertificate and final certificate of one issuance. RFC 6962
+// requires them to have the same issuer and serial number.
+type precertLinkKey struct {
+	issuer string
+	serial string
+}
+
+// linkedSubmission is a certificate or precertificate that has been logged
+type linkedSubmission struct {
+	// hash is the SHA-256 hash of the submitted certificate or precertificate
+	hash [sha256.Size]byte
+	// timestamp is the timestamp of the SCT that was issued for it
+	timestamp uint64
+	// leafHash is the Merkle leaf hash of the entry that was queued for it
+	leafHash []byte
+}
+
+// precertLinkEntry holds the submissions of one issuance
+type precertLinkEntry struct {
+	precert *linkedSubmission
+	cert    *linkedSubmission
+	// expires is when the entry is forgotten, a window after the latest submission
+	expires time.Time
+}
+
+// side returns the submission of the given type
+func (e *precertLinkEntry) side(isPrecert bool) **linkedSubmission {
+	if isPrecert {
+		return &e.precert
+	}
+
+	return &e.cert
+}
+
+// LinkedSubmission describes a certificate or precertificate in a get-precert-link response.
+type LinkedSubmission struct {
+	// SHA256 is the hex encoded hash of the certificate or precertificate
+	SHA256 string `json:"sha256"`
+	// Timestamp is the timestamp of the SCT the log issued for it
+	Timestamp uint64 `json:"timestamp"`
+	// LeafHash is the Merkle leaf hash of its log entry, for get-proof-by-hash
+	LeafHash []byte `json:"leaf_hash"`
+}
+
+// PrecertLinkResponse is the body of a get-precert-link response. Either side is nil if it
+// hasn't been submitted within the window.
+type PrecertLinkResponse struct {
+	Precert *LinkedSubmission `json:"precert,omitempty"`
+	Cert    *LinkedSubmission `json:"cert,omitempty"`
+}
+
+// PrecertLinks links precertificates to the final certificates issued from them, by issuer
+// and serial number, when both are submitted within a window of each other. Resubmissions of
+// a certificate or precertificate within the window are replays: they get an SCT with the
+// same timestamp as the first submission and aren't queued again, so a log never holds two
+// entries with conflicting timestamps for one submission. Optionally, a different certificate
+// or precertificate with the same issuer and serial number as one in the window is rejected,
+// as a CA must not issue two. The links are served to auditors on get-precert-link, see
+// WithPrecertLinks. They're only held in memory, so each frontend has its own. It is safe for
+// concurrent use.
+type PrecertLinks struct {
+	// window is how long a submission is remembered for after the latest one of its issuance
+	window time.Duration
+	// rejectConflicts is set if a submission that conflicts with one in the window is rejected
+	rejectConflicts bool
+	timeSource      util.TimeSource
+
+	// mu guards the fields below it
+	mu sync.Mutex
+	// entries maps issuances to their submissions
+	entries map[precertLinkKey]*precertLinkEntry
+	// byHash maps the hash of each submission to its issuance
+	byHash map[[sha256.Size]byte]precertLinkKey
+	// replays and conflicts count the submissions that matched one in the window
+	replays   int64
+	conflicts int64
+}
+
+// NewPrecertLinks creates a PrecertLinks that remembers issuances for window after their
+// latest submission.
+func NewPrecertLinks(window time.Duration, rejectConflicts bool, timeSource util.TimeSource) (*PrecertLinks, error) {
+	if window <= 0 {
+		return nil, errors.New("precert link window must be positive")
+	}
+
+	return &PrecertLinks{window: window, rejectConflicts: rejectConflicts, timeSource: timeSource, entries: make(map[precertLinkKey]*precertLinkEntry), byHash: make(map[[sha256.Size]byte]precertLinkKey)}, nil
+}
+
+// linkKeyFor returns the key of the issuance of a verified chain. A precertificate signed by
+// a Precertificate Signing Certificate names that as its issuer, the CA is the signing
+// certificate's issuer.
+func linkKeyFor(chain []*x509.Certificate, isPrecert bool) precertLinkKey {
+	issuer := chain[0].RawIssuer
+
+	if isPrecert && len(chain) > 1 && isPrecertSigningCert(chain[1]) {
+		issuer = chain[1].RawIssuer
+	}
+
+	return precertLinkKey{issuer: string(issuer), serial: chain[0].SerialNumber.String()}
+}
+
+func isPrecertSigningCert(cert *x509.Certificate) bool {
+	for _, oid := range cert.UnknownExtKeyUsage {
+		if oid.Equal(precertSigningCertOID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// previousTimestamp returns the SCT timestamp issued for an earlier submission of the same
+// certificate or precertificate within the window. It returns an error if a different one
+// with the same issuer and serial number was submitted within the window and conflicts are
+// rejected.
+func (l *PrecertLinks) previousTimestamp(chain []*x509.Certificate, isPrecert bool) (uint64, bool, error) {
+	if len(chain) == 0 {
+		return 0, false, errEmptyChain
+	}
+
+	key := linkKeyFor(chain, isPrecert)
+	hash := sha256.Sum256(chain[0].Raw)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.removeExpired()
+
+	entry, ok := l.entries[key]
+
+	if !ok || *entry.side(isPrecert) == nil {
+		return 0, false, nil
+	}
+
+	previous := *entry.side(isPrecert)
+
+	if previous.hash == hash {
+		l.replays++
+		return previous.timestamp, true, nil
+	}
+
+	l.conflicts++
+	glog.Warningf("Submission %x has the same issuer and serial number as %x", hash, previous.hash)
+
+	if l.rejectConflicts {
+		return 0, false, fmt.Errorf("submission has the same issuer and serial number as %x", previous.hash)
+	}
+
+	return 0, false, nil
+}
+
+// record remembers a submission that has been logged with an SCT with timestamp. If another
+// submission of the same type is remembered for its issuance it's replaced.
+func (l *PrecertLinks) record(chain []*x509.Certificate, isPrecert bool, timestamp uint64, leafHash []byte) {
+	if len(chain) == 0 {
+		return
+	}
+
+	key := linkKeyFor(chain, isPrecert)
+	submission := &linkedSubmission{hash: sha256.Sum256(chain[0].Raw), timestamp: timestamp, leafHash: leafHash}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.removeExpired()
+
+	entry, ok := l.entries[key]
+
+	if !ok {
+		entry = &precertLinkEntry{}
+		l.entries[key] = entry
+	}
+
+	if previous := *entry.side(isPrecert); previous != nil {
+		delete(l.byHash, previous.hash)
+	}
+
+	*entry.side(isPrecert) = submission
+	entry.expires = l.timeSource.Now().Add(l.window)
+	l.byHash[submission.hash] = key
+}
+
+// Lookup returns the issuance of the certificate or precertificate with the given SHA-256
+// hash, if it was submitted within the window.
+func (l *PrecertLinks) Lookup(hash [sha256.Size]byte) (PrecertLinkResponse, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.removeExpired()
+
+	key, ok := l.byHash[hash]
+
+	if !ok {
+		return PrecertLinkResponse{}, false
+	}
+
+	entry := l.entries[key]
+
+	return PrecertLinkResponse{Precert: describeSubmission(entry.precert), Cert: describeSubmission(entry.cert)}, true
+}
+
+func describeSubmission(s *linkedSubmission) *LinkedSubmission {
+	if s == nil {
+		return nil
+	}
+
+	return &LinkedSubmission{SHA256: hex.EncodeToString(s.hash[:]), Timestamp: s.timestamp, LeafHash: s.leafHash}
+}
+
+// Stats returns the number of issuances remembered, how many of them have both a
+// precertificate and a certificate and the number of replayed and conflicting submissions
+// seen so far.
+func (l *PrecertLinks) Stats() (issuances, linked int, replays, conflicts int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.removeExpired()
+
+	for _, entry := range l.entries {
+		if entry.precert != nil && entry.cert != nil {
+			linked++
+		}
+	}
+
+	return len(l.entries), linked, l.replays, l.conflicts
+}
+
+// removeExpired forgets the issuances whose window has passed. Must be called with mu held.
+func (l *PrecertLinks) removeExpired() {
+	now := l.timeSource.Now()
+
+	for key, entry := range l.entries {
+		if now.Before(entry.expires) {
+			continue
+		}
+
+		for _, s := range []*linkedSubmission{entry.precert, entry.cert} {
+			if s != nil {
+				delete(l.byHash, s.hash)
+			}
+		}
+
+		delete(l.entries, key)
+	}
+}
+
+// wrappedGetPrecertLinkHandler serves the issuance of the certificate or precertificate whose
+// hex encoded SHA-256 hash is given
+func wrappedGetPrecertLinkHandler(links *PrecertLinks) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		if !enforceMethod(w, r, httpMethodGet) {
+			return http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method)
+		}
+
+		hash, err := parseDenylistHash(r.FormValue(precertLinkParamHash))
+
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		response, ok := links.Lookup(hash)
+
+		if !ok {
+			return http.StatusNotFound, fmt.Errorf("no submission with hash %x in the window", hash)
+		}
+
+		w.Header().Set(contentTypeHeader, contentTypeJSON)
+
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			return http.StatusInternalServerError, fmt.Errorf("failed to write precert link: %v", err)
+		}
+
+		return http.StatusOK, nil
+	}
+}
@@ -0,0 +1,269 @@
+package ct
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/certificate-transparency/go/asn1"
+	"github.com/google/certificate-transparency/go/fixchain"
+	"github.com/google/certificate-transparency/go/x509"
+	"github.com/google/trillian"
+	"github.com/google/trillian/examples/ct/ctapi"
+	"github.com/google/trillian/examples/ct/testonly"
+	ttestonly "github.com/google/trillian/testonly"
+	"github.com/google/trillian/util"
+)
+
+// linkTestChain returns a chain whose leaf has the given DER, issuer and serial number, which
+// is all that PrecertLinks looks at
+func linkTestChain(der, issuer string, serial int64, issuers ...*x509.Certificate) []*x509.Certificate {
+	leaf := &x509.Certificate{Raw: []byte(der), RawIssuer: []byte(issuer), SerialNumber: big.NewInt(serial)}
+	return append([]*x509.Certificate{leaf}, issuers...)
+}
+
+func TestNewPrecertLinksRejectsBadWindow(t *testing.T) {
+	for _, window := range []time.Duration{0, -time.Second} {
+		if _, err := NewPrecertLinks(window, false, fakeTimeSource); err == nil {
+			t.Errorf("NewPrecertLinks(%v) succeeded, expected an error", window)
+		}
+	}
+}
+
+func TestPrecertLinksReplays(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+	links, err := NewPrecertLinks(time.Hour, false, ts)
+
+	if err != nil {
+		t.Fatalf("NewPrecertLinks()=%v", err)
+	}
+
+	precert := linkTestChain("precert", "CA", 1)
+	cert := linkTestChain("cert", "CA", 1)
+
+	if _, replayed, err := links.previousTimestamp(precert, true); replayed || err != nil {
+		t.Fatalf("previousTimestamp() of a new precert=%v, %v, expected false, nil", replayed, err)
+	}
+
+	links.record(precert, true, 1000, []byte("precert leaf"))
+
+	// The certificate issued from the precert isn't a replay of it
+	if _, replayed, err := links.previousTimestamp(cert, false); replayed || err != nil {
+		t.Fatalf("previousTimestamp() of the cert=%v, %v, expected false, nil", replayed, err)
+	}
+
+	ts.FakeTime = fakeTime.Add(time.Minute)
+
+	timestamp, replayed, err := links.previousTimestamp(precert, true)
+
+	if !replayed || err != nil || timestamp != 1000 {
+		t.Fatalf("previousTimestamp() of a resubmitted precert=%d, %v, %v, expected 1000, true, nil", timestamp, replayed, err)
+	}
+
+	// Submitting the cert refreshes the window of the issuance
+	links.record(cert, false, 2000, []byte("cert leaf"))
+	ts.FakeTime = fakeTime.Add(time.Hour + time.Second)
+
+	if timestamp, replayed, _ := links.previousTimestamp(precert, true); !replayed || timestamp != 1000 {
+		t.Fatalf("previousTimestamp() within the window of the cert=%d, %v, expected 1000, true", timestamp, replayed)
+	}
+
+	ts.FakeTime = fakeTime.Add(2 * time.Hour)
+
+	if _, replayed, _ := links.previousTimestamp(precert, true); replayed {
+		t.Fatal("previousTimestamp() after the window found a replay")
+	}
+
+	if issuances, linked, replays, conflicts := links.Stats(); issuances != 0 || linked != 0 || replays != 2 || conflicts != 0 {
+		t.Errorf("Stats()=%d, %d, %d, %d, expected 0, 0, 2, 0", issuances, linked, replays, conflicts)
+	}
+}
+
+func TestPrecertLinksConflicts(t *testing.T) {
+	for _, rejectConflicts := range []bool{false, true} {
+		links, err := NewPrecertLinks(time.Hour, rejectConflicts, fakeTimeSource)
+
+		if err != nil {
+			t.Fatalf("NewPrecertLinks()=%v", err)
+		}
+
+		links.record(linkTestChain("precert", "CA", 1), true, 1000, []byte("leaf"))
+
+		for _, test := range []struct {
+			desc     string
+			chain    []*x509.Certificate
+			conflict bool
+		}{
+			{"same serial", linkTestChain("other precert", "CA", 1), true},
+			{"other serial", linkTestChain("other precert", "CA", 2), false},
+			{"other issuer", linkTestChain("other precert", "Other CA", 1), false},
+		} {
+			_, replayed, err := links.previousTimestamp(test.chain, true)
+
+			if replayed || (err != nil) != (test.conflict && rejectConflicts) {
+				t.Errorf("%s, reject=%v: previousTimestamp()=%v, %v, expected a conflict=%v", test.desc, rejectConflicts, replayed, err, test.conflict)
+			}
+		}
+
+		if _, _, _, conflicts := links.Stats(); conflicts != 1 {
+			t.Errorf("reject=%v: got %d conflicts, expected 1", rejectConflicts, conflicts)
+		}
+	}
+}
+
+func TestPrecertLinksLookup(t *testing.T) {
+	links, err := NewPrecertLinks(time.Hour, false, fakeTimeSource)
+
+	if err != nil {
+		t.Fatalf("NewPrecertLinks()=%v", err)
+	}
+
+	// The precert is signed by a Precertificate Signing Certificate, so it names that as its
+	// issuer rather than the CA
+	signingCert := &x509.Certificate{RawIssuer: []byte("CA"), UnknownExtKeyUsage: []asn1.ObjectIdentifier{precertSigningCertOID}}
+	links.record(linkTestChain("precert", "Signing cert", 1, signingCert), true, 1000, []byte("precert leaf"))
+	links.record(linkTestChain("old precert", "CA", 2), true, 1000, []byte("old leaf"))
+
+	precertHash := sha256.Sum256([]byte("precert"))
+	want := PrecertLinkResponse{Precert: &LinkedSubmission{SHA256: fmt.Sprintf("%x", precertHash), Timestamp: 1000, LeafHash: []byte("precert leaf")}}
+
+	if got, ok := links.Lookup(precertHash); !ok || !reflect.DeepEqual(got, want) {
+		t.Fatalf("Lookup() before the cert=%+v, %v, expected %+v", got, ok, want)
+	}
+
+	links.record(linkTestChain("cert", "CA", 1), false, 2000, []byte("cert leaf"))
+	certHash := sha256.Sum256([]byte("cert"))
+	want.Cert = &LinkedSubmission{SHA256: fmt.Sprintf("%x", certHash), Timestamp: 2000, LeafHash: []byte("cert leaf")}
+
+	for _, hash := range [][sha256.Size]byte{precertHash, certHash} {
+		if got, ok := links.Lookup(hash); !ok || !reflect.DeepEqual(got, want) {
+			t.Errorf("Lookup(%x)=%+v, %v, expected %+v", hash, got, ok, want)
+		}
+	}
+
+	if _, ok := links.Lookup(sha256.Sum256([]byte("unknown"))); ok {
+		t.Error("Lookup() of an unknown hash succeeded")
+	}
+
+	if issuances, linked, _, _ := links.Stats(); issuances != 2 || linked != 1 {
+		t.Errorf("Stats()=%d, %d, expected 2 issuances with 1 linked", issuances, linked)
+	}
+}
+
+func TestGetPrecertLinkHandler(t *testing.T) {
+	links, err := NewPrecertLinks(time.Hour, false, fakeTimeSource)
+
+	if err != nil {
+		t.Fatalf("NewPrecertLinks()=%v", err)
+	}
+
+	links.record(linkTestChain("cert", "CA", 1), false, 2000, []byte("cert leaf"))
+	handler := wrappedGetPrecertLinkHandler(links)
+
+	for _, test := range []struct {
+		hash string
+		want int
+	}{
+		{fmt.Sprintf("%x", sha256.Sum256([]byte("cert"))), http.StatusOK},
+		{fmt.Sprintf("%x", sha256.Sum256([]byte("unknown"))), http.StatusNotFound},
+		{"not hex", http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest("GET", "http://example.com/ct/v1/get-precert-link?hash="+test.hash, nil)
+
+		if err != nil {
+			t.Fatalf("Test request setup failed: %v", err)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if got := w.Code; got != test.want {
+			t.Errorf("get-precert-link of %s returned %d, expected %d", test.hash, got, test.want)
+			continue
+		}
+
+		if test.want != http.StatusOK {
+			continue
+		}
+
+		var resp PrecertLinkResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+
+		if resp.Precert != nil || resp.Cert == nil || resp.Cert.Timestamp != 2000 {
+			t.Errorf("get-precert-link of %s returned %+v, expected only the cert", test.hash, resp)
+		}
+	}
+}
+
+func TestAddPrecertChainReplay(t *testing.T) {
+	toSign := []byte{0xe4, 0x58, 0xf3, 0x6f, 0xbd, 0xed, 0x2e, 0x62, 0x53, 0x30, 0xb3, 0x4, 0x73, 0x10, 0xb4, 0xe2, 0xe1, 0xa7, 0x44, 0x9e, 0x1f, 0x16, 0x6f, 0x78, 0x61, 0x98, 0x32, 0xe5, 0x43, 0x5a, 0x21, 0xff}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	km := setupMockKeyManager(mockCtrl, toSign)
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+
+	links, err := NewPrecertLinks(time.Hour, false, ts)
+
+	if err != nil {
+		t.Fatalf("NewPrecertLinks()=%v", err)
+	}
+
+	roots := loadCertsIntoPoolOrDie(t, []string{testonly.CACertPEM})
+	reqHandlers := CTRequestHandlers{logID: 0x42, trustedRoots: roots, rpcClient: client, logKeyManager: km, rpcDeadline: time.Millisecond * 500, timeSource: ts, precertLinks: links}
+
+	cert, err := fixchain.CertificateFromPEM(testonly.PrecertPEMValid)
+	_, ok := err.(x509.NonFatalErrors)
+
+	if err != nil && !ok {
+		t.Fatal(err)
+	}
+
+	pool := NewPEMCertPool()
+	pool.AddCert(cert)
+
+	merkleLeaf, _, err := signV1SCTForPrecertificate(km, SignatureOptions{}, pool.RawCertificates()[0], fakeTime, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaves := leafProtosForCert(t, km, pool.RawCertificates(), merkleLeaf)
+
+	// The leaf is only queued by the first submission
+	client.EXPECT().QueueLeaves(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.QueueLeavesRequest{LogId: 0x42, Leaves: leaves}).Return(&trillian.QueueLeavesResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}}, nil)
+
+	for i := 0; i < 2; i++ {
+		recorder := makeAddPrechainRequest(t, reqHandlers, testonly.AddChainBody(pool.RawCertificates()))
+
+		if got, want := recorder.Code, http.StatusOK; got != want {
+			t.Fatalf("submission %d: expected %v for valid add-pre-chain, got %v. Body: %v", i, want, got, recorder.Body)
+		}
+
+		var resp ctapi.AddChainResponse
+		if err = json.NewDecoder(recorder.Body).Decode(&resp); err != nil {
+			t.Fatalf("submission %d: failed to unmarshal json: %v, body: %v", i, err, recorder.Body.Bytes())
+		}
+
+		// The replay gets the timestamp of the first submission, not the current time
+		if got, want := resp.Timestamp, uint64(1469185273000000); got != want {
+			t.Fatalf("submission %d: got timestamp %d, expected %d", i, got, want)
+		}
+
+		ts.FakeTime = ts.FakeTime.Add(time.Minute)
+	}
+
+	if _, _, replays, _ := links.Stats(); replays != 1 {
+		t.Errorf("Got %d replays, expected 1", replays)
+	}
+}
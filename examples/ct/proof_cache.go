@@ -0,0 +1,154 @@
+package ct
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+
+	"github.com/google/trillian/examples/ct/ctapi"
+)
+
+// proofCacheKey identifies a cached inclusion proof. The leaf hash is held as a string so
+// the key can be used in a map.
+type proofCacheKey struct {
+	leafHash string
+	treeSize int64
+}
+
+// proofCacheEntry is the value stored in the LRU list
+type proofCacheEntry struct {
+	key   proofCacheKey
+	proof ctapi.GetProofByHashResponse
+}
+
+// ProofCache is an LRU cache of get-proof-by-hash responses keyed by leaf hash and tree size.
+// Monitors tend to request proofs for the same recent entries against the current tree size
+// repeatedly, so most requests can be answered without a backend round trip. When the tree
+// size advances all proofs against older tree sizes are dropped as clients will move on to
+// the new size. It is safe for concurrent use.
+type ProofCache struct {
+	// capacity is the maximum number of proofs that will be held
+	capacity int
+
+	// mu guards the fields below it
+	mu sync.Mutex
+	// treeSize is the largest tree size seen so far
+	treeSize int64
+	// entries maps keys to their element in lru
+	entries map[proofCacheKey]*list.Element
+	// lru holds proofCacheEntry values, most recently used at the front
+	lru *list.List
+	// hits and misses count lookups for monitoring
+	hits   int64
+	misses int64
+}
+
+// NewProofCache creates a ProofCache that holds at most capacity proofs.
+func NewProofCache(capacity int) (*ProofCache, error) {
+	if capacity <= 0 {
+		return nil, errors.New("proof cache capacity must be positive")
+	}
+
+	return &ProofCache{capacity: capacity, entries: make(map[proofCacheKey]*list.Element), lru: list.New()}, nil
+}
+
+// get returns the cached proof for a leaf hash at a tree size, if there is one.
+func (p *ProofCache) get(leafHash []byte, treeSize int64) (ctapi.GetProofByHashResponse, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	elem, ok := p.entries[proofCacheKey{string(leafHash), treeSize}]
+
+	if !ok {
+		p.misses++
+		return ctapi.GetProofByHashResponse{}, false
+	}
+
+	p.hits++
+	p.lru.MoveToFront(elem)
+	return elem.Value.(proofCacheEntry).proof, true
+}
+
+// put adds a proof to the cache, evicting the least recently used proof if it is full.
+func (p *ProofCache) put(leafHash []byte, treeSize int64, proof ctapi.GetProofByHashResponse) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.advanceTreeSizeLocked(treeSize)
+
+	// Don't cache proofs for trees older than ones we've dropped already
+	if treeSize < p.treeSize {
+		return
+	}
+
+	key := proofCacheKey{string(leafHash), treeSize}
+
+	if elem, ok := p.entries[key]; ok {
+		p.lru.MoveToFront(elem)
+		return
+	}
+
+	p.entries[key] = p.lru.PushFront(proofCacheEntry{key: key, proof: proof})
+
+	for p.lru.Len() > p.capacity {
+		p.removeElementLocked(p.lru.Back())
+	}
+}
+
+// advanceTreeSize tells the cache about a new tree size, e.g. from an STH. If it is larger
+// than any seen before then proofs against older tree sizes are dropped.
+func (p *ProofCache) advanceTreeSize(treeSize int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.advanceTreeSizeLocked(treeSize)
+}
+
+func (p *ProofCache) advanceTreeSizeLocked(treeSize int64) {
+	if treeSize <= p.treeSize {
+		return
+	}
+
+	p.treeSize = treeSize
+
+	for elem := p.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if elem.Value.(proofCacheEntry).key.treeSize < treeSize {
+			p.removeElementLocked(elem)
+		}
+		elem = next
+	}
+}
+
+func (p *ProofCache) removeElementLocked(elem *list.Element) {
+	delete(p.entries, elem.Value.(proofCacheEntry).key)
+	p.lru.Remove(elem)
+}
+
+// Len returns the number of proofs currently cached.
+func (p *ProofCache) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.lru.Len()
+}
+
+// Stats returns the number of cache hits and misses so far.
+func (p *ProofCache) Stats() (hits, misses int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.hits, p.misses
+}
+
+// HitRate returns the fraction of lookups that were answered from the cache, or zero if
+// there have been none.
+func (p *ProofCache) HitRate() float64 {
+	hits, misses := p.Stats()
+
+	if hits+misses == 0 {
+		return 0
+	}
+
+	return float64(hits) / float64(hits+misses)
+}
@@ -0,0 +1,118 @@
+package ct
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/trillian/examples/ct/ctapi"
+)
+
+func testProof(index int64) ctapi.GetProofByHashResponse {
+	return ctapi.GetProofByHashResponse{LeafIndex: index, AuditPath: [][]byte{[]byte("abcdef"), []byte{byte(index)}}}
+}
+
+func newProofCacheOrDie(t *testing.T, capacity int) *ProofCache {
+	cache, err := NewProofCache(capacity)
+
+	if err != nil {
+		t.Fatalf("Failed to create proof cache: %v", err)
+	}
+
+	return cache
+}
+
+func TestNewProofCacheBadCapacity(t *testing.T) {
+	if _, err := NewProofCache(0); err == nil {
+		t.Fatal("Created proof cache with zero capacity")
+	}
+}
+
+func TestProofCacheGetPut(t *testing.T) {
+	cache := newProofCacheOrDie(t, 10)
+
+	if _, ok := cache.get([]byte("hash1"), 10); ok {
+		t.Fatal("Got proof from empty cache")
+	}
+
+	cache.put([]byte("hash1"), 10, testProof(1))
+
+	proof, ok := cache.get([]byte("hash1"), 10)
+
+	if !ok {
+		t.Fatal("Cached proof not found")
+	}
+	if got, want := proof, testProof(1); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got proof %v, expected %v", got, want)
+	}
+
+	// Same hash at a different tree size is a different proof
+	if _, ok := cache.get([]byte("hash1"), 11); ok {
+		t.Fatal("Got proof for wrong tree size")
+	}
+
+	if hits, misses := cache.Stats(); hits != 1 || misses != 2 {
+		t.Fatalf("Got %d hits %d misses, expected 1 and 2", hits, misses)
+	}
+	if got, want := cache.HitRate(), 1.0/3.0; got != want {
+		t.Fatalf("Got hit rate %v, expected %v", got, want)
+	}
+}
+
+func TestProofCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newProofCacheOrDie(t, 2)
+
+	cache.put([]byte("hash1"), 10, testProof(1))
+	cache.put([]byte("hash2"), 10, testProof(2))
+
+	// Make hash1 the most recently used so hash2 gets evicted
+	if _, ok := cache.get([]byte("hash1"), 10); !ok {
+		t.Fatal("Cached proof not found")
+	}
+
+	cache.put([]byte("hash3"), 10, testProof(3))
+
+	if got, want := cache.Len(), 2; got != want {
+		t.Fatalf("Got cache size %d, expected %d", got, want)
+	}
+	if _, ok := cache.get([]byte("hash2"), 10); ok {
+		t.Fatal("Least recently used proof was not evicted")
+	}
+	for _, hash := range []string{"hash1", "hash3"} {
+		if _, ok := cache.get([]byte(hash), 10); !ok {
+			t.Fatalf("Proof for %s was evicted", hash)
+		}
+	}
+}
+
+func TestProofCacheTreeSizeAdvance(t *testing.T) {
+	cache := newProofCacheOrDie(t, 10)
+
+	cache.put([]byte("hash1"), 10, testProof(1))
+	cache.put([]byte("hash2"), 11, testProof(2))
+
+	// Putting at size 11 should have dropped everything older
+	if _, ok := cache.get([]byte("hash1"), 10); ok {
+		t.Fatal("Proof for old tree size was not dropped")
+	}
+
+	cache.advanceTreeSize(12)
+
+	if got, want := cache.Len(), 0; got != want {
+		t.Fatalf("Got cache size %d after tree size advanced, expected %d", got, want)
+	}
+
+	// Proofs for tree sizes older than the current one are not worth caching
+	cache.put([]byte("hash1"), 11, testProof(1))
+
+	if got, want := cache.Len(), 0; got != want {
+		t.Fatalf("Got cache size %d after putting old proof, expected %d", got, want)
+	}
+
+	// A smaller tree size must not drop anything
+	cache.put([]byte("hash3"), 12, testProof(3))
+	cache.advanceTreeSize(5)
+
+	if _, ok := cache.get([]byte("hash3"), 12); !ok {
+		t.Fatal("Proof dropped when tree size went backwards")
+	}
+}
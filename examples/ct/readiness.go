@@ -0,0 +1,214 @@
+package ct
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	terrors "github.com/google/trillian/errors"
+	"golang.org/x/net/context"
+)
+
+// errNotChecked is the readiness error before the first check has finished
+var errNotChecked = errors.New("initial STH has not been checked yet")
+
+// LogReadiness records whether a log has shown that its backend and keys work, by fetching an
+// STH and verifying the signature made with the log's key. Until then its endpoints return
+// 503 so that load balancers don't route traffic to a misconfigured log. A log stays ready
+// once it has been ready, later backend failures are handled by the endpoints as usual. It is
+// safe for concurrent use.
+type LogReadiness struct {
+	// mu guards the fields below it
+	mu sync.Mutex
+	// ready is set once an STH has been verified
+	ready bool
+	// lastErr is why the most recent check failed, if the log isn't ready
+	lastErr error
+}
+
+// NewLogReadiness creates a LogReadiness for a log that isn't ready yet.
+func NewLogReadiness() *LogReadiness {
+	return &LogReadiness{lastErr: errNotChecked}
+}
+
+// Ready returns whether the log is ready, and if not why not.
+func (l *LogReadiness) Ready() (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.ready, l.lastErr
+}
+
+func (l *LogReadiness) update(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.ready {
+		return
+	}
+
+	l.ready = err == nil
+	l.lastErr = err
+}
+
+// checkReadiness checks the log's backend and keys, marking the log ready if they work.
+func (c CTRequestHandlers) checkReadiness() error {
+	err := c.CheckBackendAndKeys()
+	c.readiness.update(err)
+
+	return err
+}
+
+// CheckBackendAndKeys fetches an STH for the log from its backend, signs it and verifies the
+// signature with the log's public key. It fails if the backend doesn't serve the log ID or if
+// the log's private and public keys don't match.
+func (c CTRequestHandlers) CheckBackendAndKeys() error {
+	ctx, cancel := context.WithDeadline(context.Background(), getRPCDeadlineTime(c))
+	defer cancel()
+
+	sth, err := getSignedTreeHead(ctx, c)
+
+	if err != nil {
+		return err
+	}
+
+	if err := verifyV1TreeHead(c.logKeyManager, c.signatureOptions, sth); err != nil {
+		return terrors.Errorf(terrors.Integrity, "STH signature did not verify with the log's public key: %v", err)
+	}
+
+	return nil
+}
+
+// WaitUntilReady checks the log's readiness immediately and then every interval until it's
+// ready or done is closed. The handlers must have been created WithReadinessGating.
+func (c CTRequestHandlers) WaitUntilReady(done <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := c.checkReadiness()
+
+		if err == nil {
+			glog.Infof("Log %d is ready", c.logID)
+			return
+		}
+
+		glog.Warningf("Log %d is not ready: %v", c.logID, err)
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// readinessHandler returns 503 for all requests until the log is ready
+type readinessHandler struct {
+	readiness *LogReadiness
+	handler   http.Handler
+}
+
+func (h readinessHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if ready, err := h.readiness.Ready(); !ready {
+		sendHttpError(w, http.StatusServiceUnavailable, fmt.Errorf("log is not ready: %v", err))
+		return
+	}
+
+	h.handler.ServeHTTP(w, r)
+}
+
+// wrappedGetReadyHandler serves the readiness of the log for load balancer health checks
+func wrappedGetReadyHandler(readiness *LogReadiness) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		if !enforceMethod(w, r, httpMethodGet) {
+			return http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method)
+		}
+
+		if ready, err := readiness.Ready(); !ready {
+			return http.StatusServiceUnavailable, fmt.Errorf("log is not ready: %v", err)
+		}
+
+		w.Write([]byte("ok\n"))
+
+		return http.StatusOK, nil
+	}
+}
+
+// ServerHealth reports the health of a frontend serving several logs on endpoints that are
+// outside all of the logs' paths, for orchestrators and ingress controllers that check one
+// fixed path per server. It is safe for concurrent use.
+type ServerHealth struct {
+	// mu guards logs
+	mu sync.Mutex
+	// logs is the readiness of each log by name, e.g. its prefix
+	logs map[string]*LogReadiness
+}
+
+// NewServerHealth creates a ServerHealth with no logs, which is always ready.
+func NewServerHealth() *ServerHealth {
+	return &ServerHealth{logs: make(map[string]*LogReadiness)}
+}
+
+// AddLog includes the readiness of a log in the server's readiness.
+func (s *ServerHealth) AddLog(name string, readiness *LogReadiness) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.logs[name] = readiness
+}
+
+// notReady returns the names of the logs that aren't ready, sorted, and why they aren't.
+func (s *ServerHealth) notReady() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var reasons []string
+
+	for name, readiness := range s.logs {
+		if ready, err := readiness.Ready(); !ready {
+			reasons = append(reasons, fmt.Sprintf("%q: %v", name, err))
+		}
+	}
+
+	sort.Strings(reasons)
+	return reasons
+}
+
+// RegisterHandlers registers handlers on mux for the server's liveness at healthPath, which
+// succeeds while the server is serving, and its readiness at readyPath, which fails until all
+// of its logs are ready. Either path can be empty to not register it.
+func (s *ServerHealth) RegisterHandlers(mux *http.ServeMux, healthPath, readyPath string) {
+	if len(healthPath) > 0 {
+		mux.Handle(healthPath, appHandler(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			if !enforceMethod(w, r, httpMethodGet) {
+				return http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method)
+			}
+
+			w.Write([]byte("ok\n"))
+
+			return http.StatusOK, nil
+		}))
+	}
+
+	if len(readyPath) > 0 {
+		mux.Handle(readyPath, appHandler(func(w http.ResponseWriter, r *http.Request) (int, error) {
+			if !enforceMethod(w, r, httpMethodGet) {
+				return http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method)
+			}
+
+			if reasons := s.notReady(); len(reasons) > 0 {
+				return http.StatusServiceUnavailable, fmt.Errorf("logs are not ready: %s", strings.Join(reasons, ", "))
+			}
+
+			w.Write([]byte("ok\n"))
+
+			return http.StatusOK, nil
+		}))
+	}
+}
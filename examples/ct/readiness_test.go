@@ -0,0 +1,204 @@
+package ct
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	terrors "github.com/google/trillian/errors"
+)
+
+var readinessRoot = trillian.SignedLogRoot{TreeSize: 5, TimestampNanos: 1469185273000000, RootHash: make([]byte, 32)}
+
+// readinessTestHandlers returns handlers for a log with an ECDSA key, whose public key is
+// publicKey if set. The backend returns the results in order.
+func readinessTestHandlers(t *testing.T, mockCtrl *gomock.Controller, publicKey interface{}, results ...error) CTRequestHandlers {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	if publicKey == nil {
+		publicKey = key.Public()
+	}
+
+	km := crypto.NewMockKeyManager(mockCtrl)
+	km.EXPECT().Signer().AnyTimes().Return(key, nil)
+	km.EXPECT().GetPublicKey().AnyTimes().Return(publicKey, nil)
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+
+	for _, result := range results {
+		if result != nil {
+			client.EXPECT().GetLatestSignedLogRoot(gomock.Any(), &trillian.GetLatestSignedLogRootRequest{LogId: 0x42}).Return(nil, result)
+		} else {
+			client.EXPECT().GetLatestSignedLogRoot(gomock.Any(), &trillian.GetLatestSignedLogRootRequest{LogId: 0x42}).Return(&trillian.GetLatestSignedLogRootResponse{Status: okStatus, SignedLogRoot: &readinessRoot}, nil)
+		}
+	}
+
+	return *NewCTRequestHandlers(0x42, nil, client, km, WithRPCDeadline(time.Millisecond*500), WithTimeSource(fakeTimeSource), WithReadinessGating(NewLogReadiness()))
+}
+
+// serveGated makes a request to an endpoint behind the readiness gate and to /ready and
+// returns their status codes
+func serveGated(t *testing.T, c CTRequestHandlers) (int, int) {
+	gated := readinessHandler{readiness: c.readiness, handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+
+	req, err := http.NewRequest("GET", "http://example.com/ct/v1/get-sth", nil)
+
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	gated.ServeHTTP(w, req)
+
+	req, err = http.NewRequest("GET", "http://example.com/ready", nil)
+
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	ready := httptest.NewRecorder()
+	wrappedGetReadyHandler(c.readiness).ServeHTTP(ready, req)
+
+	return w.Code, ready.Code
+}
+
+func TestReadinessGating(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// The backend fails at first, and again after the log became ready
+	c := readinessTestHandlers(t, mockCtrl, nil, errors.New("unavailable"), nil, errors.New("unavailable"))
+
+	if gated, ready := serveGated(t, c); gated != http.StatusServiceUnavailable || ready != http.StatusServiceUnavailable {
+		t.Errorf("Got status %d from endpoint and %d from /ready before checking, expected %d", gated, ready, http.StatusServiceUnavailable)
+	}
+
+	for _, test := range []struct {
+		wantErr    bool
+		wantStatus int
+	}{
+		{wantErr: true, wantStatus: http.StatusServiceUnavailable},
+		{wantStatus: http.StatusOK},
+		// Once ready the log stays ready
+		{wantErr: true, wantStatus: http.StatusOK},
+	} {
+		err := c.checkReadiness()
+
+		if test.wantErr {
+			if terrors.CodeOf(err) != terrors.Backend {
+				t.Errorf("Got readiness error %v, expected a Backend error", err)
+			}
+		} else if err != nil {
+			t.Errorf("Readiness check failed: %v", err)
+		}
+
+		if gated, ready := serveGated(t, c); gated != test.wantStatus || ready != test.wantStatus {
+			t.Errorf("Got status %d from endpoint and %d from /ready, expected %d", gated, ready, test.wantStatus)
+		}
+	}
+}
+
+func TestReadinessRejectsMismatchedKeys(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	c := readinessTestHandlers(t, mockCtrl, otherKey.Public(), nil)
+
+	if err := c.checkReadiness(); terrors.CodeOf(err) != terrors.Integrity {
+		t.Errorf("Got readiness error %v with mismatched keys, expected an Integrity error", err)
+	}
+
+	if ready, err := c.readiness.Ready(); ready || err == nil {
+		t.Errorf("Log with mismatched keys is ready=%v, err=%v", ready, err)
+	}
+}
+
+func TestWaitUntilReady(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// Returns as soon as the log becomes ready
+	c := readinessTestHandlers(t, mockCtrl, nil, errors.New("unavailable"), nil)
+	c.WaitUntilReady(make(chan struct{}), time.Millisecond)
+
+	if ready, err := c.readiness.Ready(); !ready {
+		t.Errorf("Log not ready after waiting: %v", err)
+	}
+
+	// Or when it's told to stop
+	c = readinessTestHandlers(t, mockCtrl, nil, errors.New("unavailable"))
+	done := make(chan struct{})
+	close(done)
+	c.WaitUntilReady(done, time.Hour)
+
+	if ready, _ := c.readiness.Ready(); ready {
+		t.Error("Log ready although its backend failed")
+	}
+}
+
+func TestServerHealth(t *testing.T) {
+	health := NewServerHealth()
+	mux := http.NewServeMux()
+	health.RegisterHandlers(mux, "/healthz", "/readyz")
+
+	serve := func(path string) (int, string) {
+		req, err := http.NewRequest(httpMethodGet, "http://example.com"+path, nil)
+
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w.Code, w.Body.String()
+	}
+
+	if got, _ := serve("/readyz"); got != http.StatusOK {
+		t.Errorf("Got %d from /readyz with no logs, expected %d", got, http.StatusOK)
+	}
+
+	pilot, rocketeer := NewLogReadiness(), NewLogReadiness()
+	health.AddLog("/pilot", pilot)
+	health.AddLog("/rocketeer", rocketeer)
+	pilot.update(nil)
+	rocketeer.update(errors.New("backend unavailable"))
+
+	if got, _ := serve("/healthz"); got != http.StatusOK {
+		t.Errorf("Got %d from /healthz, expected %d", got, http.StatusOK)
+	}
+
+	got, body := serve("/readyz")
+
+	if got != http.StatusServiceUnavailable {
+		t.Errorf("Got %d from /readyz with a log not ready, expected %d", got, http.StatusServiceUnavailable)
+	}
+
+	if !strings.Contains(body, "rocketeer") || strings.Contains(body, "pilot") {
+		t.Errorf("Got /readyz body %q, expected only the log that isn't ready", body)
+	}
+
+	rocketeer.update(nil)
+
+	if got, _ := serve("/readyz"); got != http.StatusOK {
+		t.Errorf("Got %d from /readyz with all logs ready, expected %d", got, http.StatusOK)
+	}
+}
@@ -0,0 +1,107 @@
+package ct
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/golang/glog"
+)
+
+// tokenBucketScript takes one token from the bucket in KEYS[1], refilling it at ARGV[1] tokens
+// a second up to ARGV[2] as of the time ARGV[3], in seconds. An absent bucket is full. The
+// bucket expires after ARGV[4] seconds, by when it would be full again. It returns 1 if a
+// token was taken and 0 if there were none left. Running it as a script makes the update
+// atomic across every replica using the bucket.
+var tokenBucketScript = redis.NewScript(1, `
+local qps = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local tokens = tonumber(redis.call('HGET', KEYS[1], 'tokens'))
+local refilled = tonumber(redis.call('HGET', KEYS[1], 'refilled'))
+
+if tokens == nil or refilled == nil then
+	tokens = burst
+	refilled = now
+end
+
+if now > refilled then
+	tokens = math.min(burst, tokens + (now - refilled) * qps)
+	refilled = now
+end
+
+local taken = 0
+
+if tokens >= 1 then
+	tokens = tokens - 1
+	taken = 1
+end
+
+redis.call('HMSET', KEYS[1], 'tokens', tokens, 'refilled', refilled)
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+return taken
+`)
+
+// NewRedisPool returns a pool of connections to the Redis server at address, host:port, for
+// SetRedisQuotas. Connecting, reading and writing each time out after timeout, so that a
+// Redis outage doesn't hold up submissions for long.
+func NewRedisPool(address string, timeout time.Duration) *redis.Pool {
+	return &redis.Pool{
+		MaxIdle:     10,
+		IdleTimeout: time.Minute,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", address, redis.DialConnectTimeout(timeout), redis.DialReadTimeout(timeout), redis.DialWriteTimeout(timeout))
+		},
+	}
+}
+
+// redisQuota is a submitter's token bucket kept in Redis, so that every frontend replica
+// takes from the same quota. Replicas pass their own clock to the script that updates it,
+// so their clocks must be roughly in step.
+type redisQuota struct {
+	pool  *redis.Pool
+	key   string
+	qps   float64
+	burst float64
+	// failOpen allows submissions when Redis can't be reached, otherwise they're throttled
+	failOpen bool
+	// errors counts the times Redis couldn't be reached, it's accessed atomically
+	errors int64
+}
+
+// take uses one submission from the quota in Redis. If Redis can't be reached it returns
+// failOpen.
+func (q *redisQuota) take(now time.Time) bool {
+	conn := q.pool.Get()
+	defer conn.Close()
+
+	// An idle bucket is full after burst/qps seconds, so it needn't be kept any longer
+	expiry := int64(math.Ceil(q.burst/q.qps)) + 1
+	seconds := float64(now.UnixNano()) / float64(time.Second)
+	taken, err := redis.Int(tokenBucketScript.Do(conn, q.key, q.qps, q.burst, seconds, expiry))
+
+	if err != nil {
+		atomic.AddInt64(&q.errors, 1)
+		glog.Warningf("Failed to take from quota %s in Redis, fail open: %v: %v", q.key, q.failOpen, err)
+		return q.failOpen
+	}
+
+	return taken == 1
+}
+
+// errorCount returns the number of times Redis couldn't be reached
+func (q *redisQuota) errorCount() int64 {
+	return atomic.LoadInt64(&q.errors)
+}
+
+// SetRedisQuotas moves the quotas of the submitters to Redis so that they're shared by every
+// frontend replica using the same pool and keyPrefix. Each quota starts full unless another
+// replica has used it. It must be called before the submitters are used.
+func (s *Submitters) SetRedisQuotas(pool *redis.Pool, keyPrefix string) {
+	for _, sub := range s.all {
+		if q, ok := sub.quota.(*submitterQuota); ok {
+			sub.quota = &redisQuota{pool: pool, key: keyPrefix + sub.name, qps: q.qps, burst: q.burst, failOpen: sub.failOpen}
+		}
+	}
+}
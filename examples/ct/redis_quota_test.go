@@ -0,0 +1,136 @@
+package ct
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/google/trillian/util"
+)
+
+// fakeRedisConn answers EVALSHA with reply and records its arguments. Other commands do
+// nothing.
+type fakeRedisConn struct {
+	reply interface{}
+	args  []interface{}
+}
+
+func (c *fakeRedisConn) Close() error {
+	return nil
+}
+
+func (c *fakeRedisConn) Err() error {
+	return nil
+}
+
+func (c *fakeRedisConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	if cmd != "EVALSHA" {
+		return nil, nil
+	}
+
+	c.args = args
+	return c.reply, nil
+}
+
+func (c *fakeRedisConn) Send(cmd string, args ...interface{}) error {
+	return nil
+}
+
+func (c *fakeRedisConn) Flush() error {
+	return nil
+}
+
+func (c *fakeRedisConn) Receive() (interface{}, error) {
+	return nil, nil
+}
+
+// fakeRedisPool returns a pool whose connections are conn, or if it's nil fail to dial
+func fakeRedisPool(conn redis.Conn) *redis.Pool {
+	return &redis.Pool{Dial: func() (redis.Conn, error) {
+		if conn == nil {
+			return nil, errors.New("connection refused")
+		}
+
+		return conn, nil
+	}}
+}
+
+func TestRedisQuotaTake(t *testing.T) {
+	for _, test := range []struct {
+		reply int64
+		want  bool
+	}{
+		{reply: 1, want: true},
+		{reply: 0, want: false},
+	} {
+		conn := &fakeRedisConn{reply: test.reply}
+		q := redisQuota{pool: fakeRedisPool(conn), key: "ctfe/quota/1/limited", qps: 1, burst: 2}
+
+		if got := q.take(fakeTime); got != test.want {
+			t.Errorf("take() with script returning %d=%v, expected %v", test.reply, got, test.want)
+		}
+
+		// The arguments are the script hash, the number of keys, the key and then the script's
+		// arguments, ending with the expiry
+		if len(conn.args) != 7 || conn.args[2] != q.key || conn.args[6] != int64(3) {
+			t.Errorf("Script called with %v, expected key %s and expiry 3", conn.args, q.key)
+		}
+
+		if q.errorCount() != 0 {
+			t.Errorf("errorCount()=%d, expected 0", q.errorCount())
+		}
+	}
+}
+
+func TestRedisQuotaUnreachable(t *testing.T) {
+	for _, failOpen := range []bool{true, false} {
+		q := redisQuota{pool: fakeRedisPool(nil), key: "ctfe/quota/1/limited", qps: 1, burst: 2, failOpen: failOpen}
+
+		if got := q.take(fakeTime); got != failOpen {
+			t.Errorf("take() with Redis unreachable and failOpen %v=%v", failOpen, got)
+		}
+
+		if q.errorCount() != 1 {
+			t.Errorf("errorCount()=%d, expected 1", q.errorCount())
+		}
+	}
+}
+
+func TestSetRedisQuotas(t *testing.T) {
+	configs := testSubmitterConfigs()
+	configs[0].FailOpen = true
+	submitters, err := NewSubmitters(configs, &util.FakeTimeSource{FakeTime: fakeTime})
+
+	if err != nil {
+		t.Fatalf("NewSubmitters()=%v", err)
+	}
+
+	submitters.SetRedisQuotas(fakeRedisPool(nil), "ctfe/quota/1/")
+
+	for _, sub := range submitters.all {
+		q, ok := sub.quota.(*redisQuota)
+
+		switch sub.name {
+		case "limited":
+			if !ok || q.key != "ctfe/quota/1/limited" || q.qps != 1 || q.burst != 2 || !q.failOpen {
+				t.Errorf("Submitter limited has quota %+v, expected one in Redis", sub.quota)
+			}
+		case "unlimited":
+			if sub.quota != nil {
+				t.Errorf("Submitter unlimited has quota %+v, expected none", sub.quota)
+			}
+		}
+	}
+
+	handler := submitterAuthHandler{submitters: submitters, handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+
+	// Redis can't be reached, the submitter is allowed as it fails open
+	if got := submitterRequest(t, handler, "key1"); got != http.StatusOK {
+		t.Errorf("Got status %d with Redis unreachable, expected %d", got, http.StatusOK)
+	}
+
+	if got, want := submitters.Stats()["limited"], (SubmitterStats{Accepted: 1, QuotaErrors: 1}); got != want {
+		t.Errorf("Got stats %+v for limited, expected %+v", got, want)
+	}
+}
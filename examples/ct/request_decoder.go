@@ -0,0 +1,114 @@
+package ct
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/google/trillian/examples/ct/ctapi"
+)
+
+// requestSchema describes the JSON object that a POST endpoint accepts. encoding/json matches
+// field names case insensitively, ignores fields it doesn't know and stops at the end of the
+// first value, so a client with a typo or a truncated request can get a confusing error or
+// have part of its request silently dropped. The schema rejects these before decoding.
+type requestSchema struct {
+	// endpoint is the name of the endpoint, used in errors
+	endpoint string
+	// fields maps the exact names of the fields that are allowed to whether they're required
+	fields map[string]bool
+}
+
+// requestSchemas holds the schema for each endpoint that takes a JSON request body
+var requestSchemas = map[string]requestSchema{
+	"add-chain":       newRequestSchema("add-chain", ctapi.AddChainRequest{}, "chain"),
+	"add-pre-chain":   newRequestSchema("add-pre-chain", ctapi.AddChainRequest{}, "chain"),
+	"add-root":        newRequestSchema("add-root", addRootRequest{}, "certificate"),
+	"remove-root":     newRequestSchema("remove-root", removeRootRequest{}, "sha256_fingerprint"),
+	"denylist-add":    newRequestSchema("denylist-add", denylistAddRequest{}, "kind", "sha256"),
+	"denylist-remove": newRequestSchema("denylist-remove", denylistRemoveRequest{}, "kind", "sha256"),
+	"gossip":          newRequestSchema("gossip", GossipRequest{}),
+}
+
+// newRequestSchema creates a schema for request, which must be a struct. The field names
+// come from its json tags so they always match what the request decodes into. The fields in
+// required must be present, any others are optional.
+func newRequestSchema(endpoint string, request interface{}, required ...string) requestSchema {
+	t := reflect.TypeOf(request)
+	fields := make(map[string]bool)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+
+		if name == "-" || field.PkgPath != "" {
+			continue
+		}
+
+		if len(name) == 0 {
+			// Mirrors the encoding/json default, though request types should always be tagged
+			name = field.Name
+		}
+
+		fields[name] = false
+	}
+
+	for _, name := range required {
+		if _, ok := fields[name]; !ok {
+			panic(fmt.Sprintf("required field %s is not in the %s request", name, endpoint))
+		}
+
+		fields[name] = true
+	}
+
+	return requestSchema{endpoint: endpoint, fields: fields}
+}
+
+// decode checks that body is a single JSON object whose fields match the schema exactly and
+// then decodes it into v.
+func (s requestSchema) decode(body []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	var object map[string]json.RawMessage
+
+	if err := decoder.Decode(&object); err != nil {
+		return fmt.Errorf("%s request is not a JSON object: %v", s.endpoint, err)
+	}
+
+	if object == nil {
+		return fmt.Errorf("%s request is not a JSON object: null", s.endpoint)
+	}
+
+	// Only whitespace is allowed after the object
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("%s request has trailing data after the JSON object", s.endpoint)
+	}
+
+	var unknown []string
+
+	for name := range object {
+		if _, ok := s.fields[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("%s request has unknown fields (names are case sensitive): %s", s.endpoint, strings.Join(unknown, ", "))
+	}
+
+	for name, required := range s.fields {
+		if _, ok := object[name]; required && !ok {
+			return fmt.Errorf("%s request is missing required field: %s", s.endpoint, name)
+		}
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to decode %s request: %v", s.endpoint, err)
+	}
+
+	return nil
+}
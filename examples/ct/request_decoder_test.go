@@ -0,0 +1,95 @@
+package ct
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/trillian/examples/ct/ctapi"
+)
+
+func TestRequestSchemaAcceptsStandardClients(t *testing.T) {
+	schema := requestSchemas["add-chain"]
+
+	// Marshalled the way the certificate-transparency Go client does it, with the trailing
+	// newline from json.Encoder
+	encoded, err := json.Marshal(struct {
+		Chain []string `json:"chain"`
+	}{Chain: []string{"MIIB", "MIIC"}})
+
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+
+	for _, body := range []string{
+		string(encoded) + "\n",
+		`{"chain":["MIIB","MIIC"]}`,
+		// Pretty printed, e.g. written by hand for curl
+		"{\n  \"chain\": [\n    \"MIIB\",\n    \"MIIC\"\n  ]\n}\n",
+	} {
+		var req ctapi.AddChainRequest
+
+		if err := schema.decode([]byte(body), &req); err != nil {
+			t.Errorf("Failed to decode valid request %q: %v", body, err)
+			continue
+		}
+
+		if got, want := req.Chain, []string{"MIIB", "MIIC"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Got chain %v from %q, expected %v", got, body, want)
+		}
+	}
+}
+
+func TestRequestSchemaRejectsInvalid(t *testing.T) {
+	schema := requestSchemas["add-pre-chain"]
+
+	for _, test := range []struct {
+		body          string
+		errorContains string
+	}{
+		{`{"Chain":["MIIB"]}`, "unknown fields"},
+		{`{"CHAIN":["MIIB"]}`, "unknown fields"},
+		{`{"chain":["MIIB"],"extra":1}`, "case sensitive): extra"},
+		{`{}`, "missing required field: chain"},
+		{`{"chain":["MIIB"]}{"chain":["MIIC"]}`, "trailing data"},
+		{`{"chain":["MIIB"]} garbage`, "trailing data"},
+		{`{"chain":["MIIB"]`, "not a JSON object"},
+		{`["MIIB"]`, "not a JSON object"},
+		{`null`, "not a JSON object"},
+		{``, "not a JSON object"},
+		{`{"chain":"MIIB"}`, "failed to decode"},
+	} {
+		var req ctapi.AddChainRequest
+		err := schema.decode([]byte(test.body), &req)
+
+		if err == nil {
+			t.Errorf("Decoded invalid request %q", test.body)
+			continue
+		}
+
+		if !strings.Contains(err.Error(), test.errorContains) {
+			t.Errorf("Got error %q for %q, expected it to contain %q", err, test.body, test.errorContains)
+		}
+
+		if !strings.Contains(err.Error(), "add-pre-chain") {
+			t.Errorf("Error %q doesn't name the endpoint", err)
+		}
+	}
+}
+
+func TestNewRequestSchemaFieldNames(t *testing.T) {
+	type request struct {
+		Tagged    string `json:"tagged"`
+		Options   string `json:"opts,omitempty"`
+		Untagged  string
+		Forbidden string `json:"-"`
+	}
+
+	schema := newRequestSchema("test", request{}, "tagged")
+	want := map[string]bool{"tagged": true, "opts": false, "Untagged": false}
+
+	if got := schema.fields; !reflect.DeepEqual(got, want) {
+		t.Errorf("Got fields %v, expected %v", got, want)
+	}
+}
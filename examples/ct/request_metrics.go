@@ -0,0 +1,339 @@
+package ct
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/trillian/util"
+)
+
+const (
+	// The name of the metrics parameter selecting the log to report
+	metricsParamLog = "log"
+	// The name of the metrics parameter selecting the view, see metricsViewAggregate
+	metricsParamView = "view"
+	// metricsViewAggregate sums the metrics of all the logs rather than labelling each log's
+	metricsViewAggregate = "aggregate"
+)
+
+// latencyBucketsSeconds are the upper bounds of the latency histogram buckets of requests and
+// backend RPCs, from reads answered from caches to submissions held up by a slow backend.
+var latencyBucketsSeconds = []float64{0.01, 0.05, 0.1, 0.5, 1, 5}
+
+// endpointRequests counts the requests to one endpoint
+type endpointRequests struct {
+	// byStatus counts requests by the HTTP status of their response
+	byStatus map[int]int64
+	// count is the total number of requests
+	count int64
+	// latencySum is the total time taken to serve them in seconds
+	latencySum float64
+	// latencyBuckets counts latencies up to each bound in latencyBucketsSeconds, with an extra
+	// bucket at the end for slower requests. They are made cumulative when exported.
+	latencyBuckets []int64
+}
+
+func newEndpointRequests() *endpointRequests {
+	return &endpointRequests{byStatus: make(map[int]int64), latencyBuckets: make([]int64, len(latencyBucketsSeconds)+1)}
+}
+
+func (e *endpointRequests) add(other endpointRequests) {
+	for status, count := range other.byStatus {
+		e.byStatus[status] += count
+	}
+
+	for i, count := range other.latencyBuckets {
+		e.latencyBuckets[i] += count
+	}
+
+	e.count += other.count
+	e.latencySum += other.latencySum
+}
+
+// RequestMetrics counts the requests to each of a log's endpoints by the status of their
+// responses and the time taken to serve them. They're served by a MetricsServer, normally on
+// an internal port. It is safe for concurrent use.
+type RequestMetrics struct {
+	timeSource util.TimeSource
+
+	// mu guards the fields below it
+	mu sync.Mutex
+	// endpoints maps the endpoint names to their requests
+	endpoints map[string]*endpointRequests
+}
+
+// NewRequestMetrics creates a RequestMetrics with no requests, timing them with timeSource.
+func NewRequestMetrics(timeSource util.TimeSource) *RequestMetrics {
+	return &RequestMetrics{timeSource: timeSource, endpoints: make(map[string]*endpointRequests)}
+}
+
+// Record counts a request to endpoint that was answered with status after latency.
+func (m *RequestMetrics) Record(endpoint string, status int, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.endpoints[endpoint]
+
+	if !ok {
+		e = newEndpointRequests()
+		m.endpoints[endpoint] = e
+	}
+
+	e.byStatus[status]++
+	e.count++
+	e.latencySum += latency.Seconds()
+	e.latencyBuckets[sort.SearchFloat64s(latencyBucketsSeconds, latency.Seconds())]++
+}
+
+// snapshot adds a copy of the counts of each endpoint to counts
+func (m *RequestMetrics) snapshot(counts map[string]*endpointRequests) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for endpoint, e := range m.endpoints {
+		total, ok := counts[endpoint]
+
+		if !ok {
+			total = newEndpointRequests()
+			counts[endpoint] = total
+		}
+
+		total.add(*e)
+	}
+}
+
+// requestMetricsHandler records every request to an endpoint in metrics
+type requestMetricsHandler struct {
+	endpoint string
+	metrics  *RequestMetrics
+	handler  http.Handler
+}
+
+func (h requestMetricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := h.metrics.timeSource.Now()
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+	h.handler.ServeHTTP(recorder, r)
+
+	h.metrics.Record(h.endpoint, recorder.status, h.metrics.timeSource.Now().Sub(start))
+}
+
+// WriteText writes the log's metrics in the Prometheus text format, without a log label.
+func (m *RequestMetrics) WriteText(w io.Writer) {
+	counts := make(map[string]*endpointRequests)
+	m.snapshot(counts)
+
+	writeRequestMetrics(w, map[string]map[string]*endpointRequests{"": counts}, []string{""})
+}
+
+// MetricsServer serves the request metrics of several logs in the Prometheus text format, so
+// that operators of a frontend serving many logs can build a dashboard for each of them. By
+// default each log's series are labelled with its name. A request can ask for one log with
+// the log parameter, or for the totals across all logs with view=aggregate. The RPCs to the
+// logs' backends are included, labelled with the backend, unless one log is asked for. It is
+// safe for concurrent use.
+type MetricsServer struct {
+	// mu guards the fields below it
+	mu sync.Mutex
+	// logs maps the names of the logs to their metrics
+	logs map[string]*RequestMetrics
+	// backends maps the addresses of the backends to their RPC metrics
+	backends map[string]*BackendRPCMetrics
+}
+
+// NewMetricsServer creates a MetricsServer with no logs.
+func NewMetricsServer() *MetricsServer {
+	return &MetricsServer{logs: make(map[string]*RequestMetrics), backends: make(map[string]*BackendRPCMetrics)}
+}
+
+// AddLog adds the metrics of a log, labelled with name, which must be unique.
+func (s *MetricsServer) AddLog(name string, metrics *RequestMetrics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.logs[name]; ok {
+		return fmt.Errorf("metrics already added for log: %s", name)
+	}
+
+	s.logs[name] = metrics
+
+	return nil
+}
+
+// AddBackend adds the metrics of the RPCs to a backend, labelled with its address, which must
+// be unique.
+func (s *MetricsServer) AddBackend(address string, metrics *BackendRPCMetrics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.backends[address]; ok {
+		return fmt.Errorf("metrics already added for backend: %s", address)
+	}
+
+	s.backends[address] = metrics
+
+	return nil
+}
+
+// WriteText writes the metrics of the log called name, or of every log if name is empty, in
+// the Prometheus text format. If aggregate is set the logs' metrics are summed and written
+// without a log label. It returns false if there's no log called name.
+func (s *MetricsServer) WriteText(w io.Writer, name string, aggregate bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var names []string
+
+	if len(name) > 0 {
+		if _, ok := s.logs[name]; !ok {
+			return false
+		}
+
+		names = []string{name}
+	} else {
+		for name := range s.logs {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+	}
+
+	// Each log's series are written with labels starting with the log's, or with none for the
+	// aggregate
+	views := make(map[string]map[string]*endpointRequests)
+	var labels []string
+
+	for _, name := range names {
+		label := fmt.Sprintf("log=\"%s\",", escapeLabelValue(name))
+
+		if aggregate {
+			label = ""
+		}
+
+		if _, ok := views[label]; !ok {
+			views[label] = make(map[string]*endpointRequests)
+			labels = append(labels, label)
+		}
+
+		s.logs[name].snapshot(views[label])
+	}
+
+	writeRequestMetrics(w, views, labels)
+
+	// Backends are shared between logs so they're left out of a single log's metrics
+	if len(name) == 0 {
+		backends := make(map[string]map[string]BackendRPCStats)
+
+		for address, metrics := range s.backends {
+			backends[address] = metrics.Stats()
+		}
+
+		writeBackendRPCMetrics(w, backends)
+	}
+
+	return true
+}
+
+// writeRequestMetrics writes the requests to each endpoint in views, whose keys are the labels
+// the endpoint labels follow, in the order of labels.
+func writeRequestMetrics(w io.Writer, views map[string]map[string]*endpointRequests, labels []string) {
+	fmt.Fprintln(w, "# HELP ct_http_requests_total Requests to each endpoint by HTTP status.")
+	fmt.Fprintln(w, "# TYPE ct_http_requests_total counter")
+
+	for _, label := range labels {
+		for _, endpoint := range sortedEndpoints(views[label]) {
+			e := views[label][endpoint]
+			statuses := make([]int, 0, len(e.byStatus))
+
+			for status := range e.byStatus {
+				statuses = append(statuses, status)
+			}
+
+			sort.Ints(statuses)
+
+			for _, status := range statuses {
+				fmt.Fprintf(w, "ct_http_requests_total{%sendpoint=\"%s\",code=\"%d\"} %d\n", label, escapeLabelValue(endpoint), status, e.byStatus[status])
+			}
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP ct_http_request_duration_seconds Time taken to serve requests to each endpoint.")
+	fmt.Fprintln(w, "# TYPE ct_http_request_duration_seconds histogram")
+
+	for _, label := range labels {
+		for _, endpoint := range sortedEndpoints(views[label]) {
+			e := views[label][endpoint]
+			writeLatencyHistogram(w, "ct_http_request_duration_seconds", fmt.Sprintf("%sendpoint=\"%s\"", label, escapeLabelValue(endpoint)), e.latencyBuckets, e.latencySum, e.count)
+		}
+	}
+}
+
+// writeLatencyHistogram writes the series of one histogram with the bounds in
+// latencyBucketsSeconds, labelled with labels. buckets holds the count of each bucket, not the
+// cumulative counts.
+func writeLatencyHistogram(w io.Writer, name, labels string, buckets []int64, sum float64, count int64) {
+	cumulative := int64(0)
+
+	for i, bound := range latencyBucketsSeconds {
+		cumulative += buckets[i]
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"%g\"} %d\n", name, labels, bound, cumulative)
+	}
+
+	fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, count)
+	fmt.Fprintf(w, "%s_sum{%s} %g\n", name, labels, sum)
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, count)
+}
+
+func sortedEndpoints(endpoints map[string]*endpointRequests) []string {
+	names := make([]string, 0, len(endpoints))
+
+	for name := range endpoints {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// RegisterHandlers registers the metrics handler on mux at path. The mux should only be
+// reachable by operators.
+func (s *MetricsServer) RegisterHandlers(mux *http.ServeMux, path string) {
+	mux.Handle(path, wrappedGetRequestMetricsHandler(s))
+}
+
+// wrappedGetRequestMetricsHandler serves the request metrics in the Prometheus text format
+func wrappedGetRequestMetricsHandler(s *MetricsServer) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		if !enforceMethod(w, r, httpMethodGet) {
+			return http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method)
+		}
+
+		aggregate := false
+
+		switch view := r.FormValue(metricsParamView); view {
+		case "":
+		case metricsViewAggregate:
+			aggregate = true
+		default:
+			return http.StatusBadRequest, fmt.Errorf("invalid %s: %s", metricsParamView, view)
+		}
+
+		var buf bytes.Buffer
+		name := r.FormValue(metricsParamLog)
+
+		if !s.WriteText(&buf, name, aggregate) {
+			return http.StatusNotFound, fmt.Errorf("no metrics for log: %s", name)
+		}
+
+		w.Header().Set(contentTypeHeader, contentTypePrometheus)
+		w.Write(buf.Bytes())
+
+		return http.StatusOK, nil
+	}
+}
@@ -0,0 +1,254 @@
+package ct
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/examples/ct/testonly"
+	"github.com/google/trillian/util"
+)
+
+func TestRequestMetricsHandler(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+	metrics := NewRequestMetrics(ts)
+	handler := requestMetricsHandler{endpoint: "get-sth", metrics: metrics, handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts.FakeTime = ts.FakeTime.Add(250 * time.Millisecond)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})}
+
+	req, err := http.NewRequest("GET", "http://example.com/ct/v1/get-sth", nil)
+
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	counts := make(map[string]*endpointRequests)
+	metrics.snapshot(counts)
+
+	if got := counts["get-sth"]; got == nil || got.count != 1 || got.byStatus[http.StatusServiceUnavailable] != 1 || got.latencySum != 0.25 || got.latencyBuckets[3] != 1 {
+		t.Errorf("Recorded %+v for get-sth, expected one 503 taking 0.25s", got)
+	}
+}
+
+func TestMetricsServer(t *testing.T) {
+	pilot := NewRequestMetrics(util.SystemTimeSource{})
+	pilot.Record("get-sth", http.StatusOK, time.Second)
+	pilot.Record("get-sth", http.StatusOK, time.Second)
+	rocketeer := NewRequestMetrics(util.SystemTimeSource{})
+	rocketeer.Record("get-sth", http.StatusOK, time.Second)
+	rocketeer.Record("add-chain", http.StatusBadRequest, 2*time.Second)
+
+	server := NewMetricsServer()
+
+	for name, metrics := range map[string]*RequestMetrics{"pilot": pilot, "rocketeer": rocketeer} {
+		if err := server.AddLog(name, metrics); err != nil {
+			t.Fatalf("AddLog(%s)=%v", name, err)
+		}
+	}
+
+	if err := server.AddLog("pilot", pilot); err == nil {
+		t.Error("AddLog() succeeded for a log that was already added")
+	}
+
+	backend := NewBackendRPCMetrics(util.SystemTimeSource{})
+	backend.record("/trillian.TrillianLog/QueueLeaves", nil, 100*time.Millisecond)
+
+	if err := server.AddBackend("localhost:8090", backend); err != nil {
+		t.Fatalf("AddBackend()=%v", err)
+	}
+
+	if err := server.AddBackend("localhost:8090", backend); err == nil {
+		t.Error("AddBackend() succeeded for a backend that was already added")
+	}
+
+	mux := http.NewServeMux()
+	server.RegisterHandlers(mux, "/request-metrics")
+
+	for _, test := range []struct {
+		query  string
+		status int
+		want   string
+	}{
+		{query: "", status: http.StatusOK, want: `# HELP ct_http_requests_total Requests to each endpoint by HTTP status.
+# TYPE ct_http_requests_total counter
+ct_http_requests_total{log="pilot",endpoint="get-sth",code="200"} 2
+ct_http_requests_total{log="rocketeer",endpoint="add-chain",code="400"} 1
+ct_http_requests_total{log="rocketeer",endpoint="get-sth",code="200"} 1
+# HELP ct_http_request_duration_seconds Time taken to serve requests to each endpoint.
+# TYPE ct_http_request_duration_seconds histogram
+ct_http_request_duration_seconds_bucket{log="pilot",endpoint="get-sth",le="0.01"} 0
+ct_http_request_duration_seconds_bucket{log="pilot",endpoint="get-sth",le="0.05"} 0
+ct_http_request_duration_seconds_bucket{log="pilot",endpoint="get-sth",le="0.1"} 0
+ct_http_request_duration_seconds_bucket{log="pilot",endpoint="get-sth",le="0.5"} 0
+ct_http_request_duration_seconds_bucket{log="pilot",endpoint="get-sth",le="1"} 2
+ct_http_request_duration_seconds_bucket{log="pilot",endpoint="get-sth",le="5"} 2
+ct_http_request_duration_seconds_bucket{log="pilot",endpoint="get-sth",le="+Inf"} 2
+ct_http_request_duration_seconds_sum{log="pilot",endpoint="get-sth"} 2
+ct_http_request_duration_seconds_count{log="pilot",endpoint="get-sth"} 2
+ct_http_request_duration_seconds_bucket{log="rocketeer",endpoint="add-chain",le="0.01"} 0
+ct_http_request_duration_seconds_bucket{log="rocketeer",endpoint="add-chain",le="0.05"} 0
+ct_http_request_duration_seconds_bucket{log="rocketeer",endpoint="add-chain",le="0.1"} 0
+ct_http_request_duration_seconds_bucket{log="rocketeer",endpoint="add-chain",le="0.5"} 0
+ct_http_request_duration_seconds_bucket{log="rocketeer",endpoint="add-chain",le="1"} 0
+ct_http_request_duration_seconds_bucket{log="rocketeer",endpoint="add-chain",le="5"} 1
+ct_http_request_duration_seconds_bucket{log="rocketeer",endpoint="add-chain",le="+Inf"} 1
+ct_http_request_duration_seconds_sum{log="rocketeer",endpoint="add-chain"} 2
+ct_http_request_duration_seconds_count{log="rocketeer",endpoint="add-chain"} 1
+ct_http_request_duration_seconds_bucket{log="rocketeer",endpoint="get-sth",le="0.01"} 0
+ct_http_request_duration_seconds_bucket{log="rocketeer",endpoint="get-sth",le="0.05"} 0
+ct_http_request_duration_seconds_bucket{log="rocketeer",endpoint="get-sth",le="0.1"} 0
+ct_http_request_duration_seconds_bucket{log="rocketeer",endpoint="get-sth",le="0.5"} 0
+ct_http_request_duration_seconds_bucket{log="rocketeer",endpoint="get-sth",le="1"} 1
+ct_http_request_duration_seconds_bucket{log="rocketeer",endpoint="get-sth",le="5"} 1
+ct_http_request_duration_seconds_bucket{log="rocketeer",endpoint="get-sth",le="+Inf"} 1
+ct_http_request_duration_seconds_sum{log="rocketeer",endpoint="get-sth"} 1
+ct_http_request_duration_seconds_count{log="rocketeer",endpoint="get-sth"} 1
+# HELP ct_backend_rpcs_total RPCs made to each log backend by method.
+# TYPE ct_backend_rpcs_total counter
+ct_backend_rpcs_total{backend="localhost:8090",method="/trillian.TrillianLog/QueueLeaves"} 1
+# HELP ct_backend_rpc_errors_total Failed RPCs to each log backend by method and error category.
+# TYPE ct_backend_rpc_errors_total counter
+# HELP ct_backend_rpc_duration_seconds Time taken by RPCs to each log backend by method.
+# TYPE ct_backend_rpc_duration_seconds histogram
+ct_backend_rpc_duration_seconds_bucket{backend="localhost:8090",method="/trillian.TrillianLog/QueueLeaves",le="0.01"} 0
+ct_backend_rpc_duration_seconds_bucket{backend="localhost:8090",method="/trillian.TrillianLog/QueueLeaves",le="0.05"} 0
+ct_backend_rpc_duration_seconds_bucket{backend="localhost:8090",method="/trillian.TrillianLog/QueueLeaves",le="0.1"} 1
+ct_backend_rpc_duration_seconds_bucket{backend="localhost:8090",method="/trillian.TrillianLog/QueueLeaves",le="0.5"} 1
+ct_backend_rpc_duration_seconds_bucket{backend="localhost:8090",method="/trillian.TrillianLog/QueueLeaves",le="1"} 1
+ct_backend_rpc_duration_seconds_bucket{backend="localhost:8090",method="/trillian.TrillianLog/QueueLeaves",le="5"} 1
+ct_backend_rpc_duration_seconds_bucket{backend="localhost:8090",method="/trillian.TrillianLog/QueueLeaves",le="+Inf"} 1
+ct_backend_rpc_duration_seconds_sum{backend="localhost:8090",method="/trillian.TrillianLog/QueueLeaves"} 0.1
+ct_backend_rpc_duration_seconds_count{backend="localhost:8090",method="/trillian.TrillianLog/QueueLeaves"} 1
+`},
+		{query: "?view=aggregate", status: http.StatusOK, want: `# HELP ct_http_requests_total Requests to each endpoint by HTTP status.
+# TYPE ct_http_requests_total counter
+ct_http_requests_total{endpoint="add-chain",code="400"} 1
+ct_http_requests_total{endpoint="get-sth",code="200"} 3
+# HELP ct_http_request_duration_seconds Time taken to serve requests to each endpoint.
+# TYPE ct_http_request_duration_seconds histogram
+ct_http_request_duration_seconds_bucket{endpoint="add-chain",le="0.01"} 0
+ct_http_request_duration_seconds_bucket{endpoint="add-chain",le="0.05"} 0
+ct_http_request_duration_seconds_bucket{endpoint="add-chain",le="0.1"} 0
+ct_http_request_duration_seconds_bucket{endpoint="add-chain",le="0.5"} 0
+ct_http_request_duration_seconds_bucket{endpoint="add-chain",le="1"} 0
+ct_http_request_duration_seconds_bucket{endpoint="add-chain",le="5"} 1
+ct_http_request_duration_seconds_bucket{endpoint="add-chain",le="+Inf"} 1
+ct_http_request_duration_seconds_sum{endpoint="add-chain"} 2
+ct_http_request_duration_seconds_count{endpoint="add-chain"} 1
+ct_http_request_duration_seconds_bucket{endpoint="get-sth",le="0.01"} 0
+ct_http_request_duration_seconds_bucket{endpoint="get-sth",le="0.05"} 0
+ct_http_request_duration_seconds_bucket{endpoint="get-sth",le="0.1"} 0
+ct_http_request_duration_seconds_bucket{endpoint="get-sth",le="0.5"} 0
+ct_http_request_duration_seconds_bucket{endpoint="get-sth",le="1"} 3
+ct_http_request_duration_seconds_bucket{endpoint="get-sth",le="5"} 3
+ct_http_request_duration_seconds_bucket{endpoint="get-sth",le="+Inf"} 3
+ct_http_request_duration_seconds_sum{endpoint="get-sth"} 3
+ct_http_request_duration_seconds_count{endpoint="get-sth"} 3
+# HELP ct_backend_rpcs_total RPCs made to each log backend by method.
+# TYPE ct_backend_rpcs_total counter
+ct_backend_rpcs_total{backend="localhost:8090",method="/trillian.TrillianLog/QueueLeaves"} 1
+# HELP ct_backend_rpc_errors_total Failed RPCs to each log backend by method and error category.
+# TYPE ct_backend_rpc_errors_total counter
+# HELP ct_backend_rpc_duration_seconds Time taken by RPCs to each log backend by method.
+# TYPE ct_backend_rpc_duration_seconds histogram
+ct_backend_rpc_duration_seconds_bucket{backend="localhost:8090",method="/trillian.TrillianLog/QueueLeaves",le="0.01"} 0
+ct_backend_rpc_duration_seconds_bucket{backend="localhost:8090",method="/trillian.TrillianLog/QueueLeaves",le="0.05"} 0
+ct_backend_rpc_duration_seconds_bucket{backend="localhost:8090",method="/trillian.TrillianLog/QueueLeaves",le="0.1"} 1
+ct_backend_rpc_duration_seconds_bucket{backend="localhost:8090",method="/trillian.TrillianLog/QueueLeaves",le="0.5"} 1
+ct_backend_rpc_duration_seconds_bucket{backend="localhost:8090",method="/trillian.TrillianLog/QueueLeaves",le="1"} 1
+ct_backend_rpc_duration_seconds_bucket{backend="localhost:8090",method="/trillian.TrillianLog/QueueLeaves",le="5"} 1
+ct_backend_rpc_duration_seconds_bucket{backend="localhost:8090",method="/trillian.TrillianLog/QueueLeaves",le="+Inf"} 1
+ct_backend_rpc_duration_seconds_sum{backend="localhost:8090",method="/trillian.TrillianLog/QueueLeaves"} 0.1
+ct_backend_rpc_duration_seconds_count{backend="localhost:8090",method="/trillian.TrillianLog/QueueLeaves"} 1
+`},
+		{query: "?log=pilot", status: http.StatusOK, want: `# HELP ct_http_requests_total Requests to each endpoint by HTTP status.
+# TYPE ct_http_requests_total counter
+ct_http_requests_total{log="pilot",endpoint="get-sth",code="200"} 2
+# HELP ct_http_request_duration_seconds Time taken to serve requests to each endpoint.
+# TYPE ct_http_request_duration_seconds histogram
+ct_http_request_duration_seconds_bucket{log="pilot",endpoint="get-sth",le="0.01"} 0
+ct_http_request_duration_seconds_bucket{log="pilot",endpoint="get-sth",le="0.05"} 0
+ct_http_request_duration_seconds_bucket{log="pilot",endpoint="get-sth",le="0.1"} 0
+ct_http_request_duration_seconds_bucket{log="pilot",endpoint="get-sth",le="0.5"} 0
+ct_http_request_duration_seconds_bucket{log="pilot",endpoint="get-sth",le="1"} 2
+ct_http_request_duration_seconds_bucket{log="pilot",endpoint="get-sth",le="5"} 2
+ct_http_request_duration_seconds_bucket{log="pilot",endpoint="get-sth",le="+Inf"} 2
+ct_http_request_duration_seconds_sum{log="pilot",endpoint="get-sth"} 2
+ct_http_request_duration_seconds_count{log="pilot",endpoint="get-sth"} 2
+`},
+		{query: "?log=unknown", status: http.StatusNotFound},
+		{query: "?view=sideways", status: http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest("GET", "http://example.com/request-metrics"+test.query, nil)
+
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		if w.Code != test.status {
+			t.Errorf("Got status %d for %q, expected %d", w.Code, test.query, test.status)
+			continue
+		}
+
+		if test.status == http.StatusOK && w.Body.String() != test.want {
+			t.Errorf("Got metrics for %q:\n%s\nexpected:\n%s", test.query, w.Body.String(), test.want)
+		}
+	}
+}
+
+func TestLogMetricsEndpoint(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	km := crypto.NewMockKeyManager(mockCtrl)
+	roots := loadCertsIntoPoolOrDie(t, []string{testonly.FakeCACertPem})
+	backend := NewBackendRPCMetrics(fakeTimeSource)
+	backend.record("/trillian.TrillianLog/QueueLeaves", nil, 100*time.Millisecond)
+
+	reqHandlers := NewCTRequestHandlers(0x42, roots, client, km, WithTimeSource(fakeTimeSource), WithPathPrefix("pilot"), WithRequestMetrics(NewRequestMetrics(fakeTimeSource)), WithBackendRPCMetrics(backend, "localhost:8090"))
+	mux := http.NewServeMux()
+	reqHandlers.RegisterHandlers(mux)
+
+	for _, path := range []string{"/pilot/ct/v1/get-roots", "/pilot/metrics"} {
+		req, err := http.NewRequest(httpMethodGet, "http://example.com"+path, nil)
+
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Fatalf("Got status %d for %s, expected %d. Body: %v", got, path, want, w.Body)
+		}
+
+		if path != "/pilot/metrics" {
+			continue
+		}
+
+		// The log's own series aren't labelled with it, the backend's are labelled with its address
+		for _, want := range []string{
+			`ct_http_requests_total{endpoint="get-roots",code="200"} 1`,
+			`ct_http_request_duration_seconds_count{endpoint="get-roots"} 1`,
+			`ct_backend_rpcs_total{backend="localhost:8090",method="/trillian.TrillianLog/QueueLeaves"} 1`,
+			`ct_backend_rpc_duration_seconds_bucket{backend="localhost:8090",method="/trillian.TrillianLog/QueueLeaves",le="0.1"} 1`,
+		} {
+			if !strings.Contains(w.Body.String(), want) {
+				t.Errorf("Metrics don't contain %s:\n%s", want, w.Body)
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package ct
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+
+	"github.com/golang/glog"
+	"github.com/google/certificate-transparency/go/x509"
+)
+
+const (
+	// MIME content type of a bundle of PEM encoded certificates
+	contentTypePEM string = "application/x-pem-file"
+	// MIME content type of a certs-only PKCS#7 bundle, see RFC 5751 Section 3.2.2
+	contentTypePKCS7 string = "application/pkcs7-mime; smime-type=certs-only"
+	// HTTP header that suggests a file name for a download
+	contentDispositionHeader string = "Content-Disposition"
+)
+
+var (
+	oidPKCS7Data       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidPKCS7SignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+)
+
+// pkcs7ContentInfo is the ContentInfo of RFC 2315 Section 7
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+// pkcs7SignedData is the SignedData of RFC 2315 Section 9.1, with nothing signed so it's
+// only a container for certificates.
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      struct {
+		ContentType asn1.ObjectIdentifier
+	}
+	Certificates asn1.RawValue
+	SignerInfos  asn1.RawValue
+}
+
+// pemBundle returns certs PEM encoded one after the other, the usual format of a CA bundle file.
+func pemBundle(certs []*x509.Certificate) []byte {
+	var b bytes.Buffer
+
+	for _, cert := range certs {
+		// Writing to a bytes.Buffer can't fail
+		pem.Encode(&b, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+
+	return b.Bytes()
+}
+
+// pkcs7Bundle returns certs as a DER encoded, degenerate certs-only PKCS#7 SignedData, the
+// format of .p7c files and of the bundles that Windows and Java tools import.
+func pkcs7Bundle(certs []*x509.Certificate) ([]byte, error) {
+	var raw []byte
+
+	for _, cert := range certs {
+		raw = append(raw, cert.Raw...)
+	}
+
+	emptySet := asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true}
+
+	signedData := pkcs7SignedData{
+		Version:          1,
+		DigestAlgorithms: emptySet,
+		// certificates is [0] IMPLICIT SET OF Certificate
+		Certificates: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: raw},
+		SignerInfos:  emptySet,
+	}
+	signedData.ContentInfo.ContentType = oidPKCS7Data
+
+	content, err := asn1.Marshal(signedData)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// content is [0] EXPLICIT
+	return asn1.Marshal(pkcs7ContentInfo{
+		ContentType: oidPKCS7SignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: content},
+	})
+}
+
+// wrappedGetRootsBundleHandler serves the roots the log currently accepts as a file in the
+// format produced by bundle, for tools that want a CA bundle rather than the base64 JSON of
+// get-roots. This is not part of RFC 6962.
+func wrappedGetRootsBundleHandler(c CTRequestHandlers, contentType, fileName string, bundle func([]*x509.Certificate) ([]byte, error)) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		if !enforceMethod(w, r, httpMethodGet) {
+			return http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method)
+		}
+
+		body, err := bundle(c.currentRoots().RawCertificates())
+
+		if err != nil {
+			glog.Warningf("Failed to create root bundle %s: %v", fileName, err)
+			return http.StatusInternalServerError, fmt.Errorf("failed to create root bundle: %v", err)
+		}
+
+		w.Header().Set(contentTypeHeader, contentType)
+		w.Header().Set(contentDispositionHeader, fmt.Sprintf("attachment; filename=%q", fileName))
+
+		if _, err := w.Write(body); err != nil {
+			return http.StatusInternalServerError, fmt.Errorf("failed to write root bundle: %v", err)
+		}
+
+		return http.StatusOK, nil
+	}
+}
+
+// wrappedGetRootsPEMHandler serves the accepted roots as a PEM bundle.
+func wrappedGetRootsPEMHandler(c CTRequestHandlers) appHandler {
+	return wrappedGetRootsBundleHandler(c, contentTypePEM, "roots.pem", func(certs []*x509.Certificate) ([]byte, error) {
+		return pemBundle(certs), nil
+	})
+}
+
+// wrappedGetRootsPKCS7Handler serves the accepted roots as a certs-only PKCS#7 bundle.
+func wrappedGetRootsPKCS7Handler(c CTRequestHandlers) appHandler {
+	return wrappedGetRootsBundleHandler(c, contentTypePKCS7, "roots.p7c", pkcs7Bundle)
+}
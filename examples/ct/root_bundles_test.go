@@ -0,0 +1,126 @@
+package ct
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetRootsPEM(t *testing.T) {
+	roots := loadCertsIntoPoolOrDie(t, []string{caAndIntermediateCertsPEM})
+	handler := wrappedGetRootsPEMHandler(CTRequestHandlers{trustedRoots: roots})
+
+	req, err := http.NewRequest("GET", "http://example.com/ct/v1/get-roots.pem", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Fatalf("Got status %v for get-roots.pem, expected %v. Body: %v", got, want, w.Body)
+	}
+	if got, want := w.Header().Get(contentTypeHeader), contentTypePEM; got != want {
+		t.Errorf("Got content type %s, expected %s", got, want)
+	}
+	if got, want := w.Header().Get(contentDispositionHeader), `attachment; filename="roots.pem"`; got != want {
+		t.Errorf("Got content disposition %s, expected %s", got, want)
+	}
+
+	rest := w.Body.Bytes()
+
+	for i, cert := range roots.RawCertificates() {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+
+		if block == nil {
+			t.Fatalf("Bundle ended after %d certs, expected %d", i, len(roots.RawCertificates()))
+		}
+		if got, want := block.Type, "CERTIFICATE"; got != want {
+			t.Errorf("Got block type %s, expected %s", got, want)
+		}
+		if !bytes.Equal(block.Bytes, cert.Raw) {
+			t.Errorf("Cert %d of the bundle doesn't match the pool", i)
+		}
+	}
+
+	if len(bytes.TrimSpace(rest)) > 0 {
+		t.Errorf("Unexpected data after the certs: %s", rest)
+	}
+}
+
+func TestGetRootsPKCS7(t *testing.T) {
+	roots := loadCertsIntoPoolOrDie(t, []string{caAndIntermediateCertsPEM})
+	handler := wrappedGetRootsPKCS7Handler(CTRequestHandlers{trustedRoots: roots})
+
+	req, err := http.NewRequest("GET", "http://example.com/ct/v1/get-roots.p7c", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Fatalf("Got status %v for get-roots.p7c, expected %v. Body: %v", got, want, w.Body)
+	}
+	if got, want := w.Header().Get(contentTypeHeader), contentTypePKCS7; got != want {
+		t.Errorf("Got content type %s, expected %s", got, want)
+	}
+
+	var contentInfo pkcs7ContentInfo
+	if rest, err := asn1.Unmarshal(w.Body.Bytes(), &contentInfo); err != nil || len(rest) > 0 {
+		t.Fatalf("Failed to parse the ContentInfo: %v, %d trailing bytes", err, len(rest))
+	}
+	if !contentInfo.ContentType.Equal(oidPKCS7SignedData) {
+		t.Fatalf("Got content type %v, expected SignedData", contentInfo.ContentType)
+	}
+
+	var signedData pkcs7SignedData
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil {
+		t.Fatalf("Failed to parse the SignedData: %v", err)
+	}
+	if got, want := signedData.Certificates.Tag, 0; got != want {
+		t.Errorf("Got certificates tag %d, expected %d", got, want)
+	}
+
+	rest := signedData.Certificates.Bytes
+
+	for i, cert := range roots.RawCertificates() {
+		var raw asn1.RawValue
+		var err error
+
+		if rest, err = asn1.Unmarshal(rest, &raw); err != nil {
+			t.Fatalf("Failed to parse cert %d of the bundle: %v", i, err)
+		}
+		if !bytes.Equal(raw.FullBytes, cert.Raw) {
+			t.Errorf("Cert %d of the bundle doesn't match the pool", i)
+		}
+	}
+
+	if len(rest) > 0 {
+		t.Errorf("Got %d bytes after the certs", len(rest))
+	}
+}
+
+func TestGetRootsBundleOnlyAcceptsGet(t *testing.T) {
+	roots := loadCertsIntoPoolOrDie(t, []string{caAndIntermediateCertsPEM})
+
+	for _, handler := range []appHandler{wrappedGetRootsPEMHandler(CTRequestHandlers{trustedRoots: roots}), wrappedGetRootsPKCS7Handler(CTRequestHandlers{trustedRoots: roots})} {
+		req, err := http.NewRequest("POST", "http://example.com/ct/v1/get-roots.pem", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if got, want := w.Code, http.StatusMethodNotAllowed; got != want {
+			t.Errorf("Got status %v for POST, expected %v", got, want)
+		}
+	}
+}
@@ -10,13 +10,12 @@ import (
 
 	ct "github.com/google/certificate-transparency/go"
 	"github.com/google/certificate-transparency/go/x509"
-	"github.com/google/trillian"
 	"github.com/google/trillian/crypto"
 )
 
 // SignV1TreeHead signs a tree head for CT. The input STH should have been built from a
 // backend response and already checked for validity.
-func signV1TreeHead(km crypto.KeyManager, sth *ct.SignedTreeHead) error {
+func signV1TreeHead(km crypto.KeyManager, opts SignatureOptions, sth *ct.SignedTreeHead) error {
 	signer, err := km.Signer()
 
 	if err != nil {
@@ -29,41 +28,55 @@ func signV1TreeHead(km crypto.KeyManager, sth *ct.SignedTreeHead) error {
 		return err
 	}
 
-	// TODO(Martin2112): Algorithms shouldn't be hardcoded here, needs more work in key manager
-	trillianSigner := crypto.NewTrillianSigner(trillian.NewSHA256(), trillian.SignatureAlgorithm_RSA, signer)
-
-	signature, err := trillianSigner.Sign(sthBytes)
+	signature, err := signDigitallySigned(signer, opts, sthBytes)
 
 	if err != nil {
 		return err
 	}
 
-	sth.TreeHeadSignature = ct.DigitallySigned{
-		HashAlgorithm:      ct.SHA256,
-		SignatureAlgorithm: ct.RSA,
-		Signature:          signature.Signature}
+	sth.TreeHeadSignature = signature
 
 	return nil
 }
 
+// verifyV1TreeHead checks the signature on an STH made by signV1TreeHead against the log's
+// public key. It fails if the key manager's private and public keys don't match.
+func verifyV1TreeHead(km crypto.KeyManager, opts SignatureOptions, sth ct.SignedTreeHead) error {
+	publicKey, err := km.GetPublicKey()
+
+	if err != nil {
+		return err
+	}
+
+	sthBytes, err := ct.SerializeSTHSignatureInput(sth)
+
+	if err != nil {
+		return err
+	}
+
+	return verifyDigitallySigned(publicKey, opts, sthBytes, sth.TreeHeadSignature)
+}
+
 // SignV1SCTForCertificate creates a MerkleTreeLeaf and builds and signs a V1 CT SCT for a certificate
-// using the key held by a key manager.
-func signV1SCTForCertificate(km crypto.KeyManager, cert *x509.Certificate, t time.Time) (ct.MerkleTreeLeaf, ct.SignedCertificateTimestamp, error) {
+// using the key held by a key manager. The signature algorithm is the one for the type of key.
+// The extensions, which may be nil, are included in both the leaf and the SCT.
+func signV1SCTForCertificate(km crypto.KeyManager, opts SignatureOptions, cert *x509.Certificate, t time.Time, extensions ct.CTExtensions) (ct.MerkleTreeLeaf, ct.SignedCertificateTimestamp, error) {
 	// Temp SCT for input to the serializer
-	sctInput := getSCTForSignatureInput(t)
+	sctInput := getSCTForSignatureInput(t, extensions)
 
 	// Build up a MerkleTreeLeaf for the cert
-	timestampedEntry := ct.TimestampedEntry{Timestamp: sctInput.Timestamp, EntryType: ct.X509LogEntryType, X509Entry: cert.Raw}
+	timestampedEntry := ct.TimestampedEntry{Timestamp: sctInput.Timestamp, EntryType: ct.X509LogEntryType, X509Entry: cert.Raw, Extensions: sctInput.Extensions}
 	leaf := ct.MerkleTreeLeaf{Version: ct.V1, LeafType: ct.TimestampedEntryLeafType, TimestampedEntry: timestampedEntry}
 
-	return serializeAndSignSCT(km, leaf, sctInput, t)
+	return serializeAndSignSCT(km, opts, leaf, sctInput, t)
 }
 
 // SignV1SCTForPrecertificate builds and signs a V1 CT SCT for a pre-certificate using the key
-// held by a key manager.
-func signV1SCTForPrecertificate(km crypto.KeyManager, cert *x509.Certificate, t time.Time) (ct.MerkleTreeLeaf, ct.SignedCertificateTimestamp, error) {
+// held by a key manager. The extensions, which may be nil, are included in both the leaf and
+// the SCT.
+func signV1SCTForPrecertificate(km crypto.KeyManager, opts SignatureOptions, cert *x509.Certificate, t time.Time, extensions ct.CTExtensions) (ct.MerkleTreeLeaf, ct.SignedCertificateTimestamp, error) {
 	// Temp SCT for input to the serializer
-	sctInput := getSCTForSignatureInput(t)
+	sctInput := getSCTForSignatureInput(t, extensions)
 
 	// Build up a LogEntry for the precert
 	// For precerts we need to extract the relevant data from the Certificate container.
@@ -71,13 +84,13 @@ func signV1SCTForPrecertificate(km crypto.KeyManager, cert *x509.Certificate, t
 	keyHash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
 	precert := ct.PreCert{IssuerKeyHash: keyHash, TBSCertificate: cert.RawTBSCertificate}
 
-	timestampedEntry := ct.TimestampedEntry{Timestamp: sctInput.Timestamp, EntryType: ct.PrecertLogEntryType, PrecertEntry: precert}
+	timestampedEntry := ct.TimestampedEntry{Timestamp: sctInput.Timestamp, EntryType: ct.PrecertLogEntryType, PrecertEntry: precert, Extensions: sctInput.Extensions}
 	leaf := ct.MerkleTreeLeaf{Version: ct.V1, LeafType: ct.TimestampedEntryLeafType, TimestampedEntry: timestampedEntry}
 
-	return serializeAndSignSCT(km, leaf, sctInput, t)
+	return serializeAndSignSCT(km, opts, leaf, sctInput, t)
 }
 
-func serializeAndSignSCT(km crypto.KeyManager, leaf ct.MerkleTreeLeaf, sctInput ct.SignedCertificateTimestamp, t time.Time) (ct.MerkleTreeLeaf, ct.SignedCertificateTimestamp, error) {
+func serializeAndSignSCT(km crypto.KeyManager, opts SignatureOptions, leaf ct.MerkleTreeLeaf, sctInput ct.SignedCertificateTimestamp, t time.Time) (ct.MerkleTreeLeaf, ct.SignedCertificateTimestamp, error) {
 	// Serialize SCT signature input to get the bytes that need to be signed
 	res, err := ct.SerializeSCTSignatureInput(sctInput, ct.LogEntry{Leaf: leaf})
 
@@ -86,31 +99,23 @@ func serializeAndSignSCT(km crypto.KeyManager, leaf ct.MerkleTreeLeaf, sctInput
 	}
 
 	// Create a complete SCT including signature
-	sct, err := signSCT(km, t, res)
+	sct, err := signSCT(km, opts, t, sctInput.Extensions, res)
 
 	return leaf, sct, err
 }
 
-func signSCT(km crypto.KeyManager, t time.Time, sctData []byte) (ct.SignedCertificateTimestamp, error) {
+func signSCT(km crypto.KeyManager, opts SignatureOptions, t time.Time, extensions ct.CTExtensions, sctData []byte) (ct.SignedCertificateTimestamp, error) {
 	signer, err := km.Signer()
 	if err != nil {
 		return ct.SignedCertificateTimestamp{}, err
 	}
 
-	// TODO(Martin2112): Algorithms shouldn't be hardcoded here, needs more work in key manager
-	trillianSigner := crypto.NewTrillianSigner(trillian.NewSHA256(), trillian.SignatureAlgorithm_RSA, signer)
-
-	signature, err := trillianSigner.Sign(sctData)
+	digitallySigned, err := signDigitallySigned(signer, opts, sctData)
 
 	if err != nil {
 		return ct.SignedCertificateTimestamp{}, err
 	}
 
-	digitallySigned := ct.DigitallySigned{
-		HashAlgorithm:      ct.SHA256,
-		SignatureAlgorithm: ct.RSA,
-		Signature:          signature.Signature}
-
 	logID, err := GetCTLogID(km)
 
 	if err != nil {
@@ -121,15 +126,15 @@ func signSCT(km crypto.KeyManager, t time.Time, sctData []byte) (ct.SignedCertif
 		SCTVersion: ct.V1,
 		LogID:      logID,
 		Timestamp:  uint64(t.UnixNano() / millisPerNano), // spec uses millisecond timestamps
-		Extensions: ct.CTExtensions{},
+		Extensions: extensions,
 		Signature:  digitallySigned}, nil
 }
 
-func getSCTForSignatureInput(t time.Time) ct.SignedCertificateTimestamp {
+func getSCTForSignatureInput(t time.Time, extensions ct.CTExtensions) ct.SignedCertificateTimestamp {
 	return ct.SignedCertificateTimestamp{
 		SCTVersion: ct.V1,
 		Timestamp:  uint64(t.UnixNano() / millisPerNano), // spec uses millisecond timestamps
-		Extensions: ct.CTExtensions{}}
+		Extensions: append(ct.CTExtensions{}, extensions...)}
 }
 
 // WriteTimestampedEntry writes out a TimestampedEntry structure in the binary format defined
@@ -27,7 +27,7 @@ func TestSignV1SCTForCertificate(t *testing.T) {
 
 	km := setupMockKeyManager(mockCtrl, []byte{0x5, 0x62, 0x4f, 0xb4, 0x9e, 0x32, 0x14, 0xb6, 0xc, 0xb8, 0x51, 0x28, 0x23, 0x93, 0x2c, 0x7a, 0x3d, 0x80, 0x93, 0x5f, 0xcd, 0x76, 0xef, 0x91, 0x6a, 0xaf, 0x1b, 0x8c, 0xe8, 0xb5, 0x2, 0xb5})
 
-	leaf, got, err := signV1SCTForCertificate(km, cert, fixedTime)
+	leaf, got, err := signV1SCTForCertificate(km, SignatureOptions{}, cert, fixedTime, nil)
 
 	if err != nil {
 		t.Fatalf("create sct for cert failed", err)
@@ -86,7 +86,7 @@ func TestSignV1SCTForPrecertificate(t *testing.T) {
 
 	km := setupMockKeyManager(mockCtrl, []byte{0x77, 0xf3, 0x5c, 0xc6, 0xad, 0x85, 0xfd, 0xe0, 0x38, 0xfd, 0x36, 0x34, 0x5c, 0x1e, 0x45, 0x58, 0x60, 0x95, 0xb1, 0x7c, 0x28, 0xaa, 0xa5, 0xa5, 0x84, 0x96, 0x37, 0x4b, 0xf8, 0xbb, 0xd9, 0x8})
 
-	leaf, got, err := signV1SCTForPrecertificate(km, cert, fixedTime)
+	leaf, got, err := signV1SCTForPrecertificate(km, SignatureOptions{}, cert, fixedTime, nil)
 
 	if err != nil {
 		t.Fatalf("create sct for precert failed", err)
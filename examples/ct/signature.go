@@ -0,0 +1,220 @@
+package ct
+
+import (
+	gocrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	ct "github.com/google/certificate-transparency/go"
+	"github.com/google/trillian/examples/ct/ctapi"
+)
+
+// SignatureOptions configures how a log signs its SCTs and STHs. The signature algorithm
+// recorded in the DigitallySigned structures always follows the type of the log's key.
+type SignatureOptions struct {
+	// Hash is the hash function that's signed. If it's zero SHA-384 is used for P-384 keys
+	// and SHA-256 for everything else, which is what RFC 6962 clients expect.
+	Hash gocrypto.Hash
+	// RSAPSS makes RSA keys produce RSASSA-PSS signatures rather than RSASSA-PKCS1-v1_5.
+	// TLS 1.2 has no code point for PSS so the signatures are labelled as RSA and can only be
+	// verified by clients that have been configured to expect PSS for the log.
+	RSAPSS bool
+}
+
+// ParseSignatureHash converts a hash name from a log config into a crypto.Hash. An empty name
+// gives zero, which picks the default for the key.
+func ParseSignatureHash(name string) (gocrypto.Hash, error) {
+	switch strings.ToLower(name) {
+	case "":
+		return 0, nil
+	case "sha256", "sha-256":
+		return gocrypto.SHA256, nil
+	case "sha384", "sha-384":
+		return gocrypto.SHA384, nil
+	case "sha512", "sha-512":
+		return gocrypto.SHA512, nil
+	}
+
+	return 0, fmt.Errorf("unsupported signature hash: %q", name)
+}
+
+// signatureScheme is the complete description of how a particular key signs
+type signatureScheme struct {
+	hash               gocrypto.Hash
+	hashAlgorithm      ct.HashAlgorithm
+	signatureAlgorithm ct.SignatureAlgorithm
+	pss                bool
+}
+
+// ctHashAlgorithms maps the hashes that can be used to their TLS code points
+var ctHashAlgorithms = map[gocrypto.Hash]ct.HashAlgorithm{
+	gocrypto.SHA256: ct.SHA256,
+	gocrypto.SHA384: ct.SHA384,
+	gocrypto.SHA512: ct.SHA512,
+}
+
+// schemeFor works out how the private key matching publicKey should sign.
+func (o SignatureOptions) schemeFor(publicKey gocrypto.PublicKey) (signatureScheme, error) {
+	var scheme signatureScheme
+
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		if o.RSAPSS {
+			return signatureScheme{}, errors.New("RSA-PSS was requested but the log has an ECDSA key")
+		}
+
+		scheme.signatureAlgorithm = ct.ECDSA
+		scheme.hash = gocrypto.SHA256
+		if key.Curve == elliptic.P384() {
+			scheme.hash = gocrypto.SHA384
+		}
+
+	case *rsa.PublicKey:
+		scheme.signatureAlgorithm = ct.RSA
+		scheme.hash = gocrypto.SHA256
+		scheme.pss = o.RSAPSS
+
+	default:
+		return signatureScheme{}, fmt.Errorf("unsupported log key type: %T", publicKey)
+	}
+
+	if o.Hash != 0 {
+		scheme.hash = o.Hash
+	}
+
+	hashAlgorithm, ok := ctHashAlgorithms[scheme.hash]
+
+	if !ok || !scheme.hash.Available() {
+		return signatureScheme{}, fmt.Errorf("unsupported signature hash: %v", scheme.hash)
+	}
+
+	scheme.hashAlgorithm = hashAlgorithm
+	return scheme, nil
+}
+
+// signerOpts returns the options to pass to crypto.Signer.Sign for the scheme
+func (s signatureScheme) signerOpts() gocrypto.SignerOpts {
+	if s.pss {
+		return &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: s.hash}
+	}
+
+	return s.hash
+}
+
+// digest hashes data with the scheme's hash function
+func (s signatureScheme) digest(data []byte) []byte {
+	h := s.hash.New()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// signDigitallySigned signs data with signer and returns it as a TLS DigitallySigned with
+// the hash and signature algorithms filled in to match.
+func signDigitallySigned(signer gocrypto.Signer, opts SignatureOptions, data []byte) (ct.DigitallySigned, error) {
+	scheme, err := opts.schemeFor(signer.Public())
+
+	if err != nil {
+		return ct.DigitallySigned{}, err
+	}
+
+	signature, err := signer.Sign(rand.Reader, scheme.digest(data), scheme.signerOpts())
+
+	if err != nil {
+		return ct.DigitallySigned{}, err
+	}
+
+	return ct.DigitallySigned{
+		HashAlgorithm:      scheme.hashAlgorithm,
+		SignatureAlgorithm: scheme.signatureAlgorithm,
+		Signature:          signature}, nil
+}
+
+// verifyDigitallySigned checks a signature made by signDigitallySigned with the same options.
+// The algorithms recorded in the signature must be the ones the options give for the key.
+func verifyDigitallySigned(publicKey gocrypto.PublicKey, opts SignatureOptions, data []byte, signature ct.DigitallySigned) error {
+	scheme, err := opts.schemeFor(publicKey)
+
+	if err != nil {
+		return err
+	}
+
+	if got, want := signature.HashAlgorithm, scheme.hashAlgorithm; got != want {
+		return fmt.Errorf("signed with hash algorithm %v, expected %v", got, want)
+	}
+
+	if got, want := signature.SignatureAlgorithm, scheme.signatureAlgorithm; got != want {
+		return fmt.Errorf("signed with signature algorithm %v, expected %v", got, want)
+	}
+
+	digest := scheme.digest(data)
+
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		var sig struct {
+			R, S *big.Int
+		}
+
+		if rest, err := asn1.Unmarshal(signature.Signature, &sig); err != nil || len(rest) > 0 {
+			return errors.New("failed to unmarshal ECDSA signature")
+		}
+
+		if !ecdsa.Verify(key, digest, sig.R, sig.S) {
+			return errors.New("ECDSA signature did not verify")
+		}
+
+	case *rsa.PublicKey:
+		if scheme.pss {
+			err = rsa.VerifyPSS(key, scheme.hash, digest, signature.Signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			err = rsa.VerifyPKCS1v15(key, scheme.hash, digest, signature.Signature)
+		}
+
+		if err != nil {
+			return fmt.Errorf("RSA signature did not verify: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// VerifySTHResponse checks the signature on a get-sth response from a log with the log's
+// public key and signature options, for monitors and other clients of the log. The response
+// only holds the signature itself so it must have been made with the algorithms that the
+// options give for the key.
+func VerifySTHResponse(publicKey gocrypto.PublicKey, opts SignatureOptions, resp ctapi.GetSTHResponse) error {
+	if got, want := len(resp.RootHash), sha256.Size; got != want {
+		return fmt.Errorf("STH root hash has %d bytes, expected %d", got, want)
+	}
+
+	if resp.TreeSize < 0 || resp.TimestampMillis < 0 {
+		return fmt.Errorf("STH has a negative tree size or timestamp: %d, %d", resp.TreeSize, resp.TimestampMillis)
+	}
+
+	scheme, err := opts.schemeFor(publicKey)
+
+	if err != nil {
+		return err
+	}
+
+	sth := ct.SignedTreeHead{TreeSize: uint64(resp.TreeSize), Timestamp: uint64(resp.TimestampMillis)}
+	copy(sth.SHA256RootHash[:], resp.RootHash)
+
+	sthBytes, err := ct.SerializeSTHSignatureInput(sth)
+
+	if err != nil {
+		return err
+	}
+
+	signature := ct.DigitallySigned{HashAlgorithm: scheme.hashAlgorithm, SignatureAlgorithm: scheme.signatureAlgorithm, Signature: resp.Signature}
+
+	return verifyDigitallySigned(publicKey, opts, sthBytes, signature)
+}
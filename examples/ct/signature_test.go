@@ -0,0 +1,226 @@
+package ct
+
+import (
+	gocrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"math/big"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	ct "github.com/google/certificate-transparency/go"
+	"github.com/google/certificate-transparency/go/fixchain"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/examples/ct/testonly"
+)
+
+// testRSAPublicKey is returned by mock signers so that they sign as an RSA key. Only its type
+// matters.
+var testRSAPublicKey = &rsa.PublicKey{N: big.NewInt(0xc0ffee), E: 65537}
+
+// setupRealKeyManager returns a key manager that signs with key
+func setupRealKeyManager(t *testing.T, ctrl *gomock.Controller, key gocrypto.Signer) crypto.KeyManager {
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+
+	if err != nil {
+		t.Fatalf("Failed to marshal public key: %v", err)
+	}
+
+	km := crypto.NewMockKeyManager(ctrl)
+	km.EXPECT().Signer().AnyTimes().Return(key, nil)
+	km.EXPECT().GetPublicKey().AnyTimes().Return(key.Public(), nil)
+	km.EXPECT().GetRawPublicKey().AnyTimes().Return(der, nil)
+
+	return km
+}
+
+func generateSignatureTestKeys(t *testing.T) (*ecdsa.PrivateKey, *ecdsa.PrivateKey, *rsa.PrivateKey) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Failed to generate P-256 key: %v", err)
+	}
+
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Failed to generate P-384 key: %v", err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+
+	return p256, p384, rsaKey
+}
+
+func TestSignV1SCTAlgorithms(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	cert, err := fixchain.CertificateFromPEM(testonly.LeafSignedByFakeIntermediateCertPem)
+
+	if err != nil {
+		t.Fatalf("failed to set up test cert: %v", err)
+	}
+
+	p256, p384, rsaKey := generateSignatureTestKeys(t)
+
+	for _, test := range []struct {
+		name          string
+		key           gocrypto.Signer
+		opts          SignatureOptions
+		hashAlgorithm ct.HashAlgorithm
+		sigAlgorithm  ct.SignatureAlgorithm
+		// ctVerifies is set if the CT client library should accept the SCT
+		ctVerifies bool
+	}{
+		{name: "P-256", key: p256, hashAlgorithm: ct.SHA256, sigAlgorithm: ct.ECDSA, ctVerifies: true},
+		{name: "P-384", key: p384, hashAlgorithm: ct.SHA384, sigAlgorithm: ct.ECDSA},
+		{name: "P-256 SHA-384", key: p256, opts: SignatureOptions{Hash: gocrypto.SHA384}, hashAlgorithm: ct.SHA384, sigAlgorithm: ct.ECDSA},
+		{name: "RSA", key: rsaKey, hashAlgorithm: ct.SHA256, sigAlgorithm: ct.RSA, ctVerifies: true},
+		{name: "RSA-PSS", key: rsaKey, opts: SignatureOptions{RSAPSS: true}, hashAlgorithm: ct.SHA256, sigAlgorithm: ct.RSA},
+	} {
+		km := setupRealKeyManager(t, mockCtrl, test.key)
+
+		leaf, sct, err := signV1SCTForCertificate(km, test.opts, cert, fixedTime, nil)
+
+		if err != nil {
+			t.Errorf("%s: failed to sign SCT: %v", test.name, err)
+			continue
+		}
+
+		if got, want := sct.Signature.HashAlgorithm, test.hashAlgorithm; got != want {
+			t.Errorf("%s: got hash algorithm %v, expected %v", test.name, got, want)
+		}
+
+		if got, want := sct.Signature.SignatureAlgorithm, test.sigAlgorithm; got != want {
+			t.Errorf("%s: got signature algorithm %v, expected %v", test.name, got, want)
+		}
+
+		input, err := ct.SerializeSCTSignatureInput(sct, ct.LogEntry{Leaf: leaf})
+
+		if err != nil {
+			t.Fatalf("%s: failed to serialize SCT: %v", test.name, err)
+		}
+
+		if err := verifyDigitallySigned(test.key.Public(), test.opts, input, sct.Signature); err != nil {
+			t.Errorf("%s: SCT signature did not verify: %v", test.name, err)
+		}
+
+		// Verifying with the wrong options must fail
+		wrongOpts := test.opts
+		wrongOpts.RSAPSS = !wrongOpts.RSAPSS
+		if err := verifyDigitallySigned(test.key.Public(), wrongOpts, input, sct.Signature); err == nil {
+			t.Errorf("%s: SCT signature verified with options %+v", test.name, wrongOpts)
+		}
+
+		if !test.ctVerifies {
+			continue
+		}
+
+		verifier, err := ct.NewSignatureVerifier(test.key.Public())
+
+		if err != nil {
+			t.Fatalf("%s: failed to create CT signature verifier: %v", test.name, err)
+		}
+
+		if err := verifier.VerifySCTSignature(sct, ct.LogEntry{Leaf: leaf}); err != nil {
+			t.Errorf("%s: CT client did not verify SCT: %v", test.name, err)
+		}
+	}
+}
+
+func TestSignV1TreeHeadAlgorithms(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	p256, p384, rsaKey := generateSignatureTestKeys(t)
+
+	for _, test := range []struct {
+		key  gocrypto.Signer
+		opts SignatureOptions
+	}{
+		{key: p256},
+		{key: p384},
+		{key: rsaKey},
+		{key: rsaKey, opts: SignatureOptions{RSAPSS: true, Hash: gocrypto.SHA512}},
+	} {
+		km := setupRealKeyManager(t, mockCtrl, test.key)
+		sth := ct.SignedTreeHead{TreeSize: 25, Timestamp: 12345, SHA256RootHash: ct.SHA256Hash{1, 2, 3}}
+
+		if err := signV1TreeHead(km, test.opts, &sth); err != nil {
+			t.Errorf("%T %+v: failed to sign STH: %v", test.key, test.opts, err)
+			continue
+		}
+
+		if err := verifyV1TreeHead(km, test.opts, sth); err != nil {
+			t.Errorf("%T %+v: STH did not verify: %v", test.key, test.opts, err)
+		}
+
+		// Clients only get the signature itself in get-sth responses
+		resp := convertSTHForClientResponse(sth)
+
+		if err := VerifySTHResponse(test.key.Public(), test.opts, resp); err != nil {
+			t.Errorf("%T %+v: get-sth response did not verify: %v", test.key, test.opts, err)
+		}
+
+		resp.TimestampMillis++
+
+		if err := VerifySTHResponse(test.key.Public(), test.opts, resp); err == nil {
+			t.Errorf("%T %+v: modified get-sth response verified", test.key, test.opts)
+		}
+
+		sth.TreeSize++
+
+		if err := verifyV1TreeHead(km, test.opts, sth); err == nil {
+			t.Errorf("%T %+v: modified STH verified", test.key, test.opts)
+		}
+	}
+}
+
+func TestSignatureOptionsRejected(t *testing.T) {
+	p256, _, _ := generateSignatureTestKeys(t)
+
+	for _, test := range []struct {
+		key  gocrypto.PublicKey
+		opts SignatureOptions
+	}{
+		{key: p256.Public(), opts: SignatureOptions{RSAPSS: true}},
+		{key: p256.Public(), opts: SignatureOptions{Hash: gocrypto.MD5}},
+		{key: []byte("not a key")},
+	} {
+		if _, err := test.opts.schemeFor(test.key); err == nil {
+			t.Errorf("Accepted options %+v for %T", test.opts, test.key)
+		}
+	}
+}
+
+func TestParseSignatureHash(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		want gocrypto.Hash
+		ok   bool
+	}{
+		{"", 0, true},
+		{"sha256", gocrypto.SHA256, true},
+		{"SHA-384", gocrypto.SHA384, true},
+		{"sha512", gocrypto.SHA512, true},
+		{"md5", 0, false},
+	} {
+		got, err := ParseSignatureHash(test.name)
+
+		if (err == nil) != test.ok {
+			t.Errorf("ParseSignatureHash(%q): got err %v, expected ok %v", test.name, err, test.ok)
+		}
+
+		if got != test.want {
+			t.Errorf("ParseSignatureHash(%q): got %v, expected %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package ct
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/trillian/util"
+)
+
+// budgetBuckets are the upper bounds of the latency histogram buckets as multiples of the
+// latency budget. Requests slower than the last bound are counted in a final overflow bucket.
+var budgetBuckets = []float64{0.25, 0.5, 1, 2, 4}
+
+// sloSample is the outcome of one request
+type sloSample struct {
+	at      time.Time
+	latency time.Duration
+	isError bool
+}
+
+// SLOTracker records the latency and outcome of requests to each CT endpoint and reports
+// rolling latency percentiles and error rates over a set of windows. Everything is computed
+// in process so it's available without an external monitoring system. Only the most recent
+// samples within the longest window are kept, up to a limit per endpoint. It is safe for
+// concurrent use.
+type SLOTracker struct {
+	windows    []time.Duration
+	budget     time.Duration
+	maxSamples int
+	timeSource util.TimeSource
+
+	// mu guards samples
+	mu sync.Mutex
+	// samples holds the recent requests for each endpoint in the order they finished
+	samples map[string][]sloSample
+}
+
+// SLOWindowReport describes the requests to an endpoint that finished within a window.
+type SLOWindowReport struct {
+	Window string `json:"window"`
+	Count  int    `json:"count"`
+	// ErrorRate is the fraction of requests that failed with a server error
+	ErrorRate float64 `json:"error_rate"`
+	P50Millis float64 `json:"p50_ms"`
+	P95Millis float64 `json:"p95_ms"`
+	P99Millis float64 `json:"p99_ms"`
+	// WithinBudget is the fraction of requests that took no longer than the latency budget
+	WithinBudget float64 `json:"within_budget"`
+	// BudgetHistogram counts requests by latency as a multiple of the budget. The keys are
+	// the bucket upper bounds, e.g. "0.5x", with "+Inf" for requests over the last bound.
+	BudgetHistogram map[string]int `json:"budget_histogram"`
+}
+
+// SLOReport is the report for all endpoints, keyed by endpoint name.
+type SLOReport struct {
+	BudgetMillis float64                      `json:"budget_ms"`
+	Endpoints    map[string][]SLOWindowReport `json:"endpoints"`
+}
+
+// NewSLOTracker creates an SLOTracker that reports over each of the windows and measures
+// latency against budget. At most maxSamples requests are kept for each endpoint.
+func NewSLOTracker(windows []time.Duration, budget time.Duration, maxSamples int, timeSource util.TimeSource) (*SLOTracker, error) {
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("at least one SLO window is required")
+	}
+
+	for _, w := range windows {
+		if w <= 0 {
+			return nil, fmt.Errorf("SLO windows must be positive, got: %v", w)
+		}
+	}
+
+	if budget <= 0 {
+		return nil, fmt.Errorf("latency budget must be positive, got: %v", budget)
+	}
+
+	if maxSamples <= 0 {
+		return nil, fmt.Errorf("max samples must be positive, got: %d", maxSamples)
+	}
+
+	sorted := append([]time.Duration{}, windows...)
+	sort.Sort(byDuration(sorted))
+
+	return &SLOTracker{windows: sorted, budget: budget, maxSamples: maxSamples, timeSource: timeSource, samples: make(map[string][]sloSample)}, nil
+}
+
+// Record notes that a request to endpoint finished now after taking latency.
+func (s *SLOTracker) Record(endpoint string, latency time.Duration, isError bool) {
+	now := s.timeSource.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	samples := s.prune(append(s.samples[endpoint], sloSample{at: now, latency: latency, isError: isError}), now)
+
+	if len(samples) > s.maxSamples {
+		samples = samples[len(samples)-s.maxSamples:]
+	}
+
+	s.samples[endpoint] = samples
+}
+
+// prune drops the samples that are older than the longest window. Must be called with mu held.
+func (s *SLOTracker) prune(samples []sloSample, now time.Time) []sloSample {
+	cutoff := now.Add(-s.windows[len(s.windows)-1])
+	first := sort.Search(len(samples), func(i int) bool { return samples[i].at.After(cutoff) })
+
+	if first == 0 {
+		return samples
+	}
+
+	// Copy so the dropped samples can be garbage collected
+	return append([]sloSample{}, samples[first:]...)
+}
+
+// Report computes the current report for every endpoint that has been called.
+func (s *SLOTracker) Report() SLOReport {
+	now := s.timeSource.Now()
+	report := SLOReport{BudgetMillis: millis(s.budget), Endpoints: make(map[string][]SLOWindowReport)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for endpoint, samples := range s.samples {
+		samples = s.prune(samples, now)
+		s.samples[endpoint] = samples
+
+		windowReports := make([]SLOWindowReport, 0, len(s.windows))
+
+		for _, window := range s.windows {
+			cutoff := now.Add(-window)
+			first := sort.Search(len(samples), func(i int) bool { return samples[i].at.After(cutoff) })
+			windowReports = append(windowReports, s.windowReport(window, samples[first:]))
+		}
+
+		report.Endpoints[endpoint] = windowReports
+	}
+
+	return report
+}
+
+func (s *SLOTracker) windowReport(window time.Duration, samples []sloSample) SLOWindowReport {
+	report := SLOWindowReport{Window: window.String(), Count: len(samples), BudgetHistogram: make(map[string]int)}
+
+	for _, bound := range budgetBuckets {
+		report.BudgetHistogram[bucketName(bound)] = 0
+	}
+
+	report.BudgetHistogram["+Inf"] = 0
+
+	if len(samples) == 0 {
+		return report
+	}
+
+	latencies := make([]time.Duration, 0, len(samples))
+	errors, withinBudget := 0, 0
+
+	for _, sample := range samples {
+		latencies = append(latencies, sample.latency)
+
+		if sample.isError {
+			errors++
+		}
+
+		if sample.latency <= s.budget {
+			withinBudget++
+		}
+
+		report.BudgetHistogram[s.bucketFor(sample.latency)]++
+	}
+
+	sort.Sort(byDuration(latencies))
+
+	report.ErrorRate = float64(errors) / float64(len(samples))
+	report.WithinBudget = float64(withinBudget) / float64(len(samples))
+	report.P50Millis = millis(percentile(latencies, 50))
+	report.P95Millis = millis(percentile(latencies, 95))
+	report.P99Millis = millis(percentile(latencies, 99))
+
+	return report
+}
+
+// bucketFor returns the name of the histogram bucket that a latency falls into
+func (s *SLOTracker) bucketFor(latency time.Duration) string {
+	ratio := float64(latency) / float64(s.budget)
+
+	for _, bound := range budgetBuckets {
+		if ratio <= bound {
+			return bucketName(bound)
+		}
+	}
+
+	return "+Inf"
+}
+
+func bucketName(bound float64) string {
+	return fmt.Sprintf("%gx", bound)
+}
+
+// percentile returns the nearest rank percentile p of the sorted latencies, which must not
+// be empty.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+type byDuration []time.Duration
+
+func (d byDuration) Len() int           { return len(d) }
+func (d byDuration) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d byDuration) Less(i, j int) bool { return d[i] < d[j] }
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// sloHandler records the latency and outcome of every request to an endpoint. Responses with
+// a 5xx status count as errors. Client errors don't, as they don't reflect on the log.
+type sloHandler struct {
+	endpoint string
+	tracker  *SLOTracker
+	handler  http.Handler
+}
+
+func (h sloHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := h.tracker.timeSource.Now()
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+	h.handler.ServeHTTP(recorder, r)
+
+	h.tracker.Record(h.endpoint, h.tracker.timeSource.Now().Sub(start), recorder.status >= http.StatusInternalServerError)
+}
+
+// wrappedGetSLOReportHandler serves the tracker's report as JSON
+func wrappedGetSLOReportHandler(tracker *SLOTracker) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		if !enforceMethod(w, r, httpMethodGet) {
+			return http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method)
+		}
+
+		jsonData, err := json.Marshal(tracker.Report())
+
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		w.Header().Set(contentTypeHeader, contentTypeJSON)
+		w.Write(jsonData)
+
+		return http.StatusOK, nil
+	}
+}
@@ -0,0 +1,187 @@
+package ct
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/trillian/util"
+)
+
+func TestNewSLOTrackerRejectsBadConfig(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+
+	for _, test := range []struct {
+		windows    []time.Duration
+		budget     time.Duration
+		maxSamples int
+	}{
+		{windows: nil, budget: time.Second, maxSamples: 10},
+		{windows: []time.Duration{time.Minute, 0}, budget: time.Second, maxSamples: 10},
+		{windows: []time.Duration{time.Minute}, budget: 0, maxSamples: 10},
+		{windows: []time.Duration{time.Minute}, budget: time.Second, maxSamples: 0},
+	} {
+		if _, err := NewSLOTracker(test.windows, test.budget, test.maxSamples, ts); err == nil {
+			t.Errorf("NewSLOTracker(%v, %v, %d) succeeded, expected an error", test.windows, test.budget, test.maxSamples)
+		}
+	}
+}
+
+func TestSLOTrackerReport(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+	tracker, err := NewSLOTracker([]time.Duration{time.Hour, time.Minute}, 100*time.Millisecond, 1000, ts)
+
+	if err != nil {
+		t.Fatalf("Failed to create tracker: %v", err)
+	}
+
+	// 100 old requests that are only in the hour window, all slow errors
+	for i := 0; i < 100; i++ {
+		tracker.Record("get-sth", time.Second, true)
+	}
+
+	ts.FakeTime = ts.FakeTime.Add(10 * time.Minute)
+
+	// Then 1ms to 100ms, one of which is an error
+	for i := 1; i <= 100; i++ {
+		tracker.Record("get-sth", time.Duration(i)*time.Millisecond, i == 50)
+	}
+
+	report := tracker.Report()
+
+	if got, want := report.BudgetMillis, 100.0; got != want {
+		t.Errorf("Got budget %v, expected %v", got, want)
+	}
+
+	windows := report.Endpoints["get-sth"]
+
+	if got, want := len(windows), 2; got != want {
+		t.Fatalf("Got %d windows, expected %d", got, want)
+	}
+
+	// Windows are reported shortest first
+	minute := windows[0]
+	want := SLOWindowReport{
+		Window:       "1m0s",
+		Count:        100,
+		ErrorRate:    0.01,
+		P50Millis:    50,
+		P95Millis:    95,
+		P99Millis:    99,
+		WithinBudget: 1,
+	}
+
+	if minute.Window != want.Window || minute.Count != want.Count || minute.ErrorRate != want.ErrorRate ||
+		minute.P50Millis != want.P50Millis || minute.P95Millis != want.P95Millis || minute.P99Millis != want.P99Millis ||
+		minute.WithinBudget != want.WithinBudget {
+		t.Errorf("Got minute window %+v, expected %+v", minute, want)
+	}
+
+	for bucket, want := range map[string]int{"0.25x": 25, "0.5x": 25, "1x": 50, "2x": 0, "4x": 0, "+Inf": 0} {
+		if got := minute.BudgetHistogram[bucket]; got != want {
+			t.Errorf("Got %d in minute bucket %s, expected %d", got, bucket, want)
+		}
+	}
+
+	hour := windows[1]
+
+	if got, want := hour.Count, 200; got != want {
+		t.Errorf("Got %d requests in the hour window, expected %d", got, want)
+	}
+
+	if got, want := hour.ErrorRate, 101.0/200.0; got != want {
+		t.Errorf("Got hour error rate %v, expected %v", got, want)
+	}
+
+	if got, want := hour.P99Millis, 1000.0; got != want {
+		t.Errorf("Got hour p99 %v, expected %v", got, want)
+	}
+
+	if got, want := hour.BudgetHistogram["+Inf"], 100; got != want {
+		t.Errorf("Got %d in hour bucket +Inf, expected %d", got, want)
+	}
+
+	// Once the old requests are beyond the longest window they are dropped
+	ts.FakeTime = ts.FakeTime.Add(55 * time.Minute)
+
+	if got, want := tracker.Report().Endpoints["get-sth"][1].Count, 100; got != want {
+		t.Errorf("Got %d requests in the hour window after expiry, expected %d", got, want)
+	}
+}
+
+func TestSLOTrackerMaxSamples(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+	tracker, err := NewSLOTracker([]time.Duration{time.Hour}, time.Second, 10, ts)
+
+	if err != nil {
+		t.Fatalf("Failed to create tracker: %v", err)
+	}
+
+	for i := 0; i < 25; i++ {
+		tracker.Record("get-entries", time.Millisecond, false)
+	}
+
+	if got, want := tracker.Report().Endpoints["get-entries"][0].Count, 10; got != want {
+		t.Fatalf("Got %d requests, expected %d", got, want)
+	}
+}
+
+func TestSLOHandler(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+	tracker, err := NewSLOTracker([]time.Duration{time.Minute}, time.Second, 100, ts)
+
+	if err != nil {
+		t.Fatalf("Failed to create tracker: %v", err)
+	}
+
+	for _, status := range []int{http.StatusOK, http.StatusBadRequest, http.StatusInternalServerError, http.StatusServiceUnavailable} {
+		status := status
+		handler := sloHandler{endpoint: "add-chain", tracker: tracker, handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ts.FakeTime = ts.FakeTime.Add(10 * time.Millisecond)
+			w.WriteHeader(status)
+		})}
+
+		req, err := http.NewRequest("POST", "http://example.com/ct/v1/add-chain", nil)
+
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	req, err := http.NewRequest("GET", "http://example.com/debug/slo", nil)
+
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	wrappedGetSLOReportHandler(tracker).ServeHTTP(w, req)
+
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Fatalf("Got status %d from /debug/slo, expected %d", got, want)
+	}
+
+	var report SLOReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to unmarshal report: %v", err)
+	}
+
+	window := report.Endpoints["add-chain"][0]
+
+	if got, want := window.Count, 4; got != want {
+		t.Errorf("Got %d requests, expected %d", got, want)
+	}
+
+	// Only server errors count against the SLO
+	if got, want := window.ErrorRate, 0.5; got != want {
+		t.Errorf("Got error rate %v, expected %v", got, want)
+	}
+
+	if got, want := window.P50Millis, 10.0; got != want {
+		t.Errorf("Got p50 %v, expected %v", got, want)
+	}
+}
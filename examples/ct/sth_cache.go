@@ -0,0 +1,193 @@
+package ct
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+// STHCache remembers the size of the latest tree head fetched from the backend so that
+// get-entries requests within the tree can be passed on without fetching its size each time. It also keeps the latest root itself so that get-sth can be served from it, see
+// WithCachedSTH. A tree size or root is only trusted for maxAge, after which it must be
+// refreshed. Tree sizes never go down so the cache ignores any update smaller than what it
+// has. It is safe for concurrent use.
+//
+// When several frontends serve a log each has its own cache. Watch keeps them coherent by
+// updating each one from the backend's tree event stream as soon as a new root is signed, so
+// they all serve it within seconds rather than whenever their cached copies expire.
+type STHCache struct {
+	maxAge     time.Duration
+	timeSource util.TimeSource
+
+	// mu guards the fields below it
+	mu sync.Mutex
+	// treeSize is the largest tree size seen, only valid if fetched is set
+	treeSize int64
+	// root is the latest root seen, nil if only its size was. It's only valid if its size
+	// matches treeSize.
+	root *trillian.SignedLogRoot
+	// fetched is when treeSize was last confirmed by the backend
+	fetched time.Time
+	// events counts the roots received from the backend's tree event stream
+	events int64
+}
+
+// NewSTHCache creates an STHCache that trusts tree sizes for maxAge.
+func NewSTHCache(maxAge time.Duration, timeSource util.TimeSource) *STHCache {
+	return &STHCache{maxAge: maxAge, timeSource: timeSource}
+}
+
+// update records a tree size fetched from the backend.
+func (s *STHCache) update(treeSize int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if treeSize >= s.treeSize {
+		s.treeSize = treeSize
+		s.fetched = s.timeSource.Now()
+	}
+}
+
+// updateRoot records a root fetched from the backend. A root for the same tree size replaces
+// the cached one if it's at least as new, as the backend may sign the tree again without it
+// growing.
+func (s *STHCache) updateRoot(root *trillian.SignedLogRoot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if root.TreeSize < s.treeSize {
+		return
+	}
+
+	if s.root != nil && root.TreeSize == s.root.TreeSize && root.TimestampNanos < s.root.TimestampNanos {
+		return
+	}
+
+	s.treeSize = root.TreeSize
+	s.root = root
+	s.fetched = s.timeSource.Now()
+}
+
+// get returns the cached tree size. The second result is false if there isn't one or it's
+// too old to be used.
+func (s *STHCache) get() (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fetched.IsZero() || s.timeSource.Now().Sub(s.fetched) > s.maxAge {
+		return 0, false
+	}
+
+	return s.treeSize, true
+}
+
+// getRoot returns the cached root. The second result is false if there isn't one, it's too
+// old to be used or a larger tree size has been seen since.
+func (s *STHCache) getRoot() (*trillian.SignedLogRoot, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.root == nil || s.root.TreeSize != s.treeSize || s.fetched.IsZero() || s.timeSource.Now().Sub(s.fetched) > s.maxAge {
+		return nil, false
+	}
+
+	return s.root, true
+}
+
+// Stats returns the cached tree size, how long ago it was confirmed by the backend and the
+// number of roots received from the tree event stream. The age is negative if there's no
+// tree size cached. Comparing tree sizes across frontends shows whether they agree.
+func (s *STHCache) Stats() (treeSize int64, age time.Duration, events int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fetched.IsZero() {
+		return s.treeSize, -1, s.events
+	}
+
+	return s.treeSize, s.timeSource.Now().Sub(s.fetched), s.events
+}
+
+// invalidate forgets the cached tree size so that the next request refreshes it.
+func (s *STHCache) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fetched = time.Time{}
+}
+
+// Watch subscribes to the backend's events for a log and updates the cache with each new root
+// as soon as it's signed, so get-entries requests for new entries are passed on without
+// fetching the tree size. If the subscription fails the cache is invalidated, as roots may have
+// been missed, and it's retried after retryInterval. Watch returns when done is closed.
+func (s *STHCache) Watch(done <-chan struct{}, client trillian.TrillianLogClient, logID int64, retryInterval time.Duration) {
+	for {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		// End the subscription when we're told to stop
+		go func() {
+			select {
+			case <-done:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		err := s.watchEvents(ctx, client, logID)
+		cancel()
+		s.invalidate()
+
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		glog.Warningf("Tree event subscription for log %d failed, retrying in %v: %v", logID, retryInterval, err)
+
+		select {
+		case <-done:
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// countEvent counts a root received from the tree event stream.
+func (s *STHCache) countEvent() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events++
+}
+
+// watchEvents updates the cache from a log's events until the subscription fails.
+func (s *STHCache) watchEvents(ctx context.Context, client trillian.TrillianLogClient, logID int64) error {
+	stream, err := client.SubscribeTreeEvents(ctx, &trillian.SubscribeTreeEventsRequest{LogId: logID})
+
+	if err != nil {
+		return err
+	}
+
+	for {
+		event, err := stream.Recv()
+
+		if err != nil {
+			return err
+		}
+
+		switch event.EventType {
+		case trillian.TreeEventType_NEW_SIGNED_ROOT, trillian.TreeEventType_TREE_FROZEN:
+			if event.SignedLogRoot != nil {
+				s.countEvent()
+				s.updateRoot(event.SignedLogRoot)
+			}
+		case trillian.TreeEventType_SEQUENCING_ERROR:
+			glog.Warningf("Backend failed to sequence log %d: %s", logID, event.Error)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package ct
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/examples/ct/ctapi"
+	ttestonly "github.com/google/trillian/testonly"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+func TestSTHCache(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+	cache := NewSTHCache(time.Minute, ts)
+
+	if _, ok := cache.get(); ok {
+		t.Fatal("Got tree size from empty cache")
+	}
+
+	cache.update(10)
+
+	if got, ok := cache.get(); !ok || got != 10 {
+		t.Fatalf("Got tree size %d (%v), expected 10", got, ok)
+	}
+
+	// A smaller size must be from an older STH so is ignored
+	cache.update(5)
+
+	if got, ok := cache.get(); !ok || got != 10 {
+		t.Fatalf("Got tree size %d (%v) after smaller update, expected 10", got, ok)
+	}
+
+	ts.FakeTime = ts.FakeTime.Add(time.Minute + time.Second)
+
+	if _, ok := cache.get(); ok {
+		t.Fatal("Got tree size after it expired")
+	}
+
+	cache.update(12)
+
+	if got, ok := cache.get(); !ok || got != 12 {
+		t.Fatalf("Got tree size %d (%v) after refresh, expected 12", got, ok)
+	}
+}
+
+func TestSTHCacheRoot(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+	cache := NewSTHCache(time.Minute, ts)
+
+	if _, ok := cache.getRoot(); ok {
+		t.Fatal("Got root from empty cache")
+	}
+
+	if _, age, _ := cache.Stats(); age >= 0 {
+		t.Errorf("Got age %v from empty cache, expected it to be negative", age)
+	}
+
+	cache.updateRoot(&trillian.SignedLogRoot{TreeSize: 10, TimestampNanos: 2000})
+
+	if root, ok := cache.getRoot(); !ok || root.TreeSize != 10 {
+		t.Fatalf("Got root %v (%v), expected tree size 10", root, ok)
+	}
+
+	if got, ok := cache.get(); !ok || got != 10 {
+		t.Fatalf("Got tree size %d (%v) after root update, expected 10", got, ok)
+	}
+
+	// An older signature of the same tree is ignored but a newer one replaces it
+	cache.updateRoot(&trillian.SignedLogRoot{TreeSize: 10, TimestampNanos: 1000})
+
+	if root, _ := cache.getRoot(); root.TimestampNanos != 2000 {
+		t.Errorf("Got root timestamp %d after older update, expected 2000", root.TimestampNanos)
+	}
+
+	cache.updateRoot(&trillian.SignedLogRoot{TreeSize: 10, TimestampNanos: 3000})
+
+	if root, _ := cache.getRoot(); root.TimestampNanos != 3000 {
+		t.Errorf("Got root timestamp %d after newer update, expected 3000", root.TimestampNanos)
+	}
+
+	ts.FakeTime = ts.FakeTime.Add(time.Second)
+
+	if treeSize, age, _ := cache.Stats(); treeSize != 10 || age != time.Second {
+		t.Errorf("Stats()=%d, %v, expected 10, 1s", treeSize, age)
+	}
+
+	// Once a larger tree has been seen the cached root is out of date
+	cache.update(12)
+
+	if root, ok := cache.getRoot(); ok {
+		t.Errorf("Got root %v after a larger tree size was seen", root)
+	}
+
+	cache.updateRoot(&trillian.SignedLogRoot{TreeSize: 12, TimestampNanos: 4000})
+	ts.FakeTime = ts.FakeTime.Add(time.Minute + time.Second)
+
+	if _, ok := cache.getRoot(); ok {
+		t.Fatal("Got root after it expired")
+	}
+}
+
+// fakeTreeEventStream returns its events and then err
+type fakeTreeEventStream struct {
+	grpc.ClientStream
+	events []*trillian.TreeEvent
+	err    error
+}
+
+func (f *fakeTreeEventStream) Recv() (*trillian.TreeEvent, error) {
+	if len(f.events) == 0 {
+		return nil, f.err
+	}
+
+	event := f.events[0]
+	f.events = f.events[1:]
+	return event, nil
+}
+
+func TestSTHCacheWatchEvents(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+	cache := NewSTHCache(time.Minute, ts)
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	stream := &fakeTreeEventStream{
+		events: []*trillian.TreeEvent{
+			{EventType: trillian.TreeEventType_NEW_SIGNED_ROOT, LogId: 0x42, SignedLogRoot: &trillian.SignedLogRoot{TreeSize: 20}},
+			{EventType: trillian.TreeEventType_SEQUENCING_ERROR, LogId: 0x42, Error: "failed"},
+			{EventType: trillian.TreeEventType_NEW_SIGNED_ROOT, LogId: 0x42, SignedLogRoot: &trillian.SignedLogRoot{TreeSize: 25}},
+		},
+		err: io.EOF,
+	}
+	client.EXPECT().SubscribeTreeEvents(gomock.Any(), &trillian.SubscribeTreeEventsRequest{LogId: 0x42}).Return(stream, nil)
+
+	if err := cache.watchEvents(context.Background(), client, 0x42); err != io.EOF {
+		t.Fatalf("watchEvents()=%v, expected %v", err, io.EOF)
+	}
+
+	if got, ok := cache.get(); !ok || got != 25 {
+		t.Fatalf("Got tree size %d (%v) after events, expected 25", got, ok)
+	}
+
+	if root, ok := cache.getRoot(); !ok || root.TreeSize != 25 {
+		t.Fatalf("Got root %v (%v) after events, expected tree size 25", root, ok)
+	}
+
+	if _, _, events := cache.Stats(); events != 2 {
+		t.Errorf("Got %d events counted, expected 2", events)
+	}
+
+	// Roots may be missed while there's no subscription
+	cache.invalidate()
+
+	if _, ok := cache.get(); ok {
+		t.Fatal("Got tree size after the cache was invalidated")
+	}
+}
+
+func TestSTHCacheWatchStops(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	cache := NewSTHCache(time.Minute, &util.FakeTimeSource{FakeTime: fakeTime})
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	client.EXPECT().SubscribeTreeEvents(gomock.Any(), gomock.Any()).AnyTimes().Return(nil, errors.New("unavailable"))
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		cache.Watch(done, client, 0x42, time.Millisecond)
+		close(stopped)
+	}()
+
+	close(done)
+
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Watch() didn't return after done was closed")
+	}
+}
+
+func TestGetSTHServedFromCache(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	km := crypto.NewMockKeyManager(mockCtrl)
+	signer := crypto.NewMockSigner(mockCtrl)
+	signer.EXPECT().Public().AnyTimes().Return(testRSAPublicKey)
+	signer.EXPECT().Sign(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return([]byte("signed"), nil)
+	km.EXPECT().Signer().AnyTimes().Return(signer, nil)
+
+	// Only the first request goes to the backend, the others are served from the cache
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	client.EXPECT().GetLatestSignedLogRoot(gomock.Any(), gomock.Any()).Return(ttestonly.GetRootResponse(2000000, 25, []byte("abcdabcdabcdabcdabcdabcdabcdabcd")), nil)
+
+	cache := NewSTHCache(time.Minute, fakeTimeSource)
+	c := NewCTRequestHandlers(0x42, nil, client, km, WithTimeSource(fakeTimeSource), WithSTHCache(cache), WithCachedSTH())
+	mux := http.NewServeMux()
+	c.RegisterHandlers(mux)
+
+	for _, test := range []struct {
+		desc     string
+		event    *trillian.SignedLogRoot
+		treeSize int64
+	}{
+		{"from backend", nil, 25},
+		{"from cache", nil, 25},
+		// As if another frontend's backend had published a new root
+		{"after event", &trillian.SignedLogRoot{TimestampNanos: 3000000, TreeSize: 30, RootHash: []byte("efghefghefghefghefghefghefghefgh")}, 30},
+	} {
+		if test.event != nil {
+			cache.updateRoot(test.event)
+		}
+
+		req, err := http.NewRequest("GET", "http://example.com/ct/v1/get-sth", nil)
+
+		if err != nil {
+			t.Fatalf("%s: failed to create request: %v", test.desc, err)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Fatalf("%s: got status %d, expected %d: %s", test.desc, got, want, w.Body.String())
+		}
+
+		var resp ctapi.GetSTHResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: failed to unmarshal response: %v", test.desc, err)
+		}
+
+		if got, want := resp.TreeSize, test.treeSize; got != want {
+			t.Errorf("%s: got tree size %d, expected %d", test.desc, got, want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package ct
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	terrors "github.com/google/trillian/errors"
+)
+
+// STHGuard remembers the latest root that a log served as an STH and checks each newly fetched
+// root against it. The tree must not shrink, time must not go backwards and the root hash must
+// not change without the tree growing. A root that fails the check means the backend has been
+// rolled back or is presenting a split view. It is logged and counted and, unless the guard
+// only alerts, it isn't served. Once the cause is understood, e.g. the backend was restored
+// from a backup on purpose, Reset clears the state so the next root is accepted. It is safe
+// for concurrent use.
+type STHGuard struct {
+	// alertOnly is set if roots that fail the check are still served
+	alertOnly bool
+
+	// mu guards the fields below it
+	mu sync.Mutex
+	// last is the latest root that passed the check, nil if none has been seen since the
+	// guard was created or reset
+	last *trillian.SignedLogRoot
+	// regressions counts roots that failed the check
+	regressions int64
+}
+
+// NewSTHGuard creates an STHGuard. If alertOnly is set roots that fail the check are logged
+// and counted but still served.
+func NewSTHGuard(alertOnly bool) *STHGuard {
+	return &STHGuard{alertOnly: alertOnly}
+}
+
+// check returns an error if root should not be served because it's inconsistent with the
+// latest root that was.
+func (g *STHGuard) check(root *trillian.SignedLogRoot) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var err error
+
+	switch {
+	case g.last == nil:
+	case root.TreeSize < g.last.TreeSize:
+		err = fmt.Errorf("tree size %d is smaller than %d served before", root.TreeSize, g.last.TreeSize)
+	case root.TimestampNanos < g.last.TimestampNanos:
+		err = fmt.Errorf("timestamp %d is earlier than %d served before", root.TimestampNanos, g.last.TimestampNanos)
+	case root.TreeSize == g.last.TreeSize && !bytes.Equal(root.RootHash, g.last.RootHash):
+		err = fmt.Errorf("root hash %x differs from %x served before for tree size %d", root.RootHash, g.last.RootHash, root.TreeSize)
+	}
+
+	if err == nil {
+		g.last = root
+		return nil
+	}
+
+	g.regressions++
+	glog.Warningf("Backend root is inconsistent with the last STH served, the backend may have been rolled back or be presenting a split view: %v", err)
+
+	if g.alertOnly {
+		return nil
+	}
+
+	return terrors.Errorf(terrors.Integrity, "backend root is inconsistent with the last STH served: %v", err)
+}
+
+// Reset forgets the latest root served, so the next root fetched is accepted whatever it is.
+// The count of regressions is kept.
+func (g *STHGuard) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	glog.Warningf("STH guard reset, last root served was: %v", g.last)
+	g.last = nil
+}
+
+// Stats returns the number of roots that failed the check and the size of the latest tree
+// served, or -1 if there isn't one.
+func (g *STHGuard) Stats() (regressions, treeSize int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.last == nil {
+		return g.regressions, -1
+	}
+
+	return g.regressions, g.last.TreeSize
+}
+
+// wrappedResetSTHGuardHandler clears the guard's state so that a root that fails the check
+// can be served, for use once the operator has dealt with it.
+func wrappedResetSTHGuardHandler(guard *STHGuard) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		if !enforceMethod(w, r, httpMethodPost) {
+			return http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method)
+		}
+
+		guard.Reset()
+
+		return http.StatusOK, nil
+	}
+}
@@ -0,0 +1,147 @@
+package ct
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	ttestonly "github.com/google/trillian/testonly"
+)
+
+func rootForTest(timestamp, treeSize int64, hash string) *trillian.SignedLogRoot {
+	return &trillian.SignedLogRoot{TimestampNanos: timestamp, TreeSize: treeSize, RootHash: []byte(hash)}
+}
+
+func TestSTHGuard(t *testing.T) {
+	guard := NewSTHGuard(false)
+
+	for _, test := range []struct {
+		desc string
+		root *trillian.SignedLogRoot
+		ok   bool
+	}{
+		{"first root", rootForTest(1000, 10, "a"), true},
+		{"same root again", rootForTest(1000, 10, "a"), true},
+		{"newer timestamp same tree", rootForTest(2000, 10, "a"), true},
+		{"tree grows", rootForTest(3000, 12, "b"), true},
+		{"tree shrinks", rootForTest(4000, 11, "c"), false},
+		{"earlier timestamp", rootForTest(2500, 12, "b"), false},
+		{"different hash same size", rootForTest(5000, 12, "x"), false},
+		// Rejected roots don't replace the last one served
+		{"tree grows after rejections", rootForTest(6000, 13, "d"), true},
+	} {
+		if err := guard.check(test.root); (err == nil) != test.ok {
+			t.Errorf("%s: check()=%v, expected ok=%v", test.desc, err, test.ok)
+		}
+	}
+
+	if regressions, treeSize := guard.Stats(); regressions != 3 || treeSize != 13 {
+		t.Errorf("Stats()=%d, %d, expected 3, 13", regressions, treeSize)
+	}
+
+	// After a reset a rolled back root is served
+	guard.Reset()
+
+	if regressions, treeSize := guard.Stats(); regressions != 3 || treeSize != -1 {
+		t.Errorf("Stats()=%d, %d after reset, expected 3, -1", regressions, treeSize)
+	}
+
+	if err := guard.check(rootForTest(1000, 5, "e")); err != nil {
+		t.Errorf("check() after reset=%v, expected ok", err)
+	}
+}
+
+func TestSTHGuardAlertOnly(t *testing.T) {
+	guard := NewSTHGuard(true)
+
+	if err := guard.check(rootForTest(2000, 10, "a")); err != nil {
+		t.Fatalf("check()=%v for first root", err)
+	}
+
+	// Inconsistent roots are served but counted, and every one is counted until the backend
+	// catches up
+	for i := 0; i < 2; i++ {
+		if err := guard.check(rootForTest(3000, 9, "b")); err != nil {
+			t.Errorf("check()=%v for rolled back root when only alerting", err)
+		}
+	}
+
+	if regressions, treeSize := guard.Stats(); regressions != 2 || treeSize != 10 {
+		t.Errorf("Stats()=%d, %d, expected 2, 10", regressions, treeSize)
+	}
+}
+
+func TestGetSTHRefusesRolledBackRoot(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	km := crypto.NewMockKeyManager(mockCtrl)
+	signer := crypto.NewMockSigner(mockCtrl)
+	signer.EXPECT().Public().AnyTimes().Return(testRSAPublicKey)
+	signer.EXPECT().Sign(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return([]byte("signed"), nil)
+	km.EXPECT().Signer().AnyTimes().Return(signer, nil)
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	gomock.InOrder(
+		client.EXPECT().GetLatestSignedLogRoot(gomock.Any(), gomock.Any()).Return(ttestonly.GetRootResponse(2000000, 25, []byte("abcdabcdabcdabcdabcdabcdabcdabcd")), nil),
+		client.EXPECT().GetLatestSignedLogRoot(gomock.Any(), gomock.Any()).Times(2).Return(ttestonly.GetRootResponse(3000000, 20, []byte("efghefghefghefghefghefghefghefgh")), nil),
+	)
+
+	c := NewCTRequestHandlers(0x42, nil, client, km, WithTimeSource(fakeTimeSource), WithSTHGuard(NewSTHGuard(false), "secret"))
+	mux := http.NewServeMux()
+	c.RegisterHandlers(mux)
+
+	for _, test := range []struct {
+		desc   string
+		method string
+		path   string
+		auth   string
+		status int
+	}{
+		{"first STH", "GET", "/ct/v1/get-sth", "", http.StatusOK},
+		{"rolled back STH", "GET", "/ct/v1/get-sth", "", http.StatusInternalServerError},
+		{"reset without token", "POST", "/admin/reset-sth-guard", "", http.StatusUnauthorized},
+		{"reset with GET", "GET", "/admin/reset-sth-guard", "Bearer secret", http.StatusMethodNotAllowed},
+		{"reset", "POST", "/admin/reset-sth-guard", "Bearer secret", http.StatusOK},
+		{"rolled back STH after reset", "GET", "/ct/v1/get-sth", "", http.StatusOK},
+	} {
+		req, err := http.NewRequest(test.method, "http://example.com"+test.path, nil)
+
+		if err != nil {
+			t.Fatalf("%s: failed to create request: %v", test.desc, err)
+		}
+
+		if len(test.auth) > 0 {
+			req.Header.Set(authorizationHeader, test.auth)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		if got, want := w.Code, test.status; got != want {
+			t.Errorf("%s: got status %d, expected %d: %s", test.desc, got, want, w.Body.String())
+		}
+	}
+}
+
+func TestResetSTHGuardNotServedWithoutToken(t *testing.T) {
+	c := NewCTRequestHandlers(0x42, nil, nil, nil, WithSTHGuard(NewSTHGuard(false), ""))
+	mux := http.NewServeMux()
+	c.RegisterHandlers(mux)
+
+	req, err := http.NewRequest("POST", "http://example.com/admin/reset-sth-guard", nil)
+
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if got, want := w.Code, http.StatusNotFound; got != want {
+		t.Errorf("Got status %d for reset without an admin token configured, expected %d", got, want)
+	}
+}
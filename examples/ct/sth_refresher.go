@@ -0,0 +1,122 @@
+package ct
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	ct "github.com/google/certificate-transparency/go"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+// STHRefresher holds a log's latest signed tree head, which is fetched from the backend and
+// signed in the background by CTRequestHandlers.RefreshSTH, so that get-sth can be served
+// without a backend round trip or a signature for every request. An STH is only served for
+// maxAge after it was signed, if refreshing fails for longer get-sth falls back to fetching
+// and signing the STH itself. Tree sizes never go down so older STHs are ignored. It is safe
+// for concurrent use.
+type STHRefresher struct {
+	maxAge     time.Duration
+	timeSource util.TimeSource
+
+	// mu guards the fields below it
+	mu sync.Mutex
+	// sth is the latest STH, only valid if refreshed is set
+	sth ct.SignedTreeHead
+	// refreshed is when sth was signed
+	refreshed time.Time
+	// failures counts the refreshes that failed
+	failures int64
+}
+
+// NewSTHRefresher creates an STHRefresher that serves STHs for maxAge after they're signed.
+func NewSTHRefresher(maxAge time.Duration, timeSource util.TimeSource) *STHRefresher {
+	return &STHRefresher{maxAge: maxAge, timeSource: timeSource}
+}
+
+// update records a newly signed STH. An STH for a smaller tree, or an older one for the same
+// tree, is ignored.
+func (r *STHRefresher) update(sth ct.SignedTreeHead) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.refreshed.IsZero() && (sth.TreeSize < r.sth.TreeSize || sth.TreeSize == r.sth.TreeSize && sth.Timestamp < r.sth.Timestamp) {
+		return
+	}
+
+	r.sth = sth
+	r.refreshed = r.timeSource.Now()
+}
+
+// fail counts a refresh that failed.
+func (r *STHRefresher) fail() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.failures++
+}
+
+// get returns the latest STH. The second result is false if there isn't one or it's too old
+// to be served.
+func (r *STHRefresher) get() (ct.SignedTreeHead, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.refreshed.IsZero() || r.timeSource.Now().Sub(r.refreshed) > r.maxAge {
+		return ct.SignedTreeHead{}, false
+	}
+
+	return r.sth, true
+}
+
+// Stats returns the tree size of the latest STH, how long ago it was signed and the number of
+// refreshes that failed. The age is negative if there's no STH yet.
+func (r *STHRefresher) Stats() (treeSize int64, age time.Duration, failures int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.refreshed.IsZero() {
+		return 0, -1, r.failures
+	}
+
+	return int64(r.sth.TreeSize), r.timeSource.Now().Sub(r.refreshed), r.failures
+}
+
+// refreshSTH fetches and signs the latest STH and hands it to the refresher.
+func (c CTRequestHandlers) refreshSTH() error {
+	ctx, cancel := context.WithDeadline(context.Background(), getRPCDeadlineTime(c))
+	defer cancel()
+
+	sth, err := getSignedTreeHead(ctx, c)
+
+	if err != nil {
+		c.sthRefresher.fail()
+		return err
+	}
+
+	c.sthRefresher.update(sth)
+
+	return nil
+}
+
+// RefreshSTH fetches and signs the log's STH immediately and then every interval until done
+// is closed, so that get-sth serves it from memory. The handlers must have been created
+// WithSTHRefresher, and interval should be well under the refresher's max age so that a
+// failed refresh can be retried before the STH is too old to serve.
+func (c CTRequestHandlers) RefreshSTH(done <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.refreshSTH(); err != nil {
+			glog.Warningf("Failed to refresh STH for log %d: %v", c.logID, err)
+		}
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package ct
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	ct "github.com/google/certificate-transparency/go"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/examples/ct/ctapi"
+	ttestonly "github.com/google/trillian/testonly"
+	"github.com/google/trillian/util"
+)
+
+func TestSTHRefresher(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+	refresher := NewSTHRefresher(time.Minute, ts)
+
+	if _, ok := refresher.get(); ok {
+		t.Fatal("Got STH from empty refresher")
+	}
+
+	if _, age, _ := refresher.Stats(); age >= 0 {
+		t.Errorf("Got age %v from empty refresher, expected it to be negative", age)
+	}
+
+	refresher.update(ct.SignedTreeHead{TreeSize: 10, Timestamp: 2000})
+
+	if sth, ok := refresher.get(); !ok || sth.TreeSize != 10 {
+		t.Fatalf("Got STH %v (%v), expected tree size 10", sth, ok)
+	}
+
+	// Older STHs are ignored but a newer one for the same tree replaces it
+	refresher.update(ct.SignedTreeHead{TreeSize: 5, Timestamp: 3000})
+	refresher.update(ct.SignedTreeHead{TreeSize: 10, Timestamp: 1000})
+
+	if sth, ok := refresher.get(); !ok || sth.TreeSize != 10 || sth.Timestamp != 2000 {
+		t.Fatalf("Got STH %v (%v) after older updates, expected the original", sth, ok)
+	}
+
+	refresher.update(ct.SignedTreeHead{TreeSize: 10, Timestamp: 4000})
+
+	if sth, ok := refresher.get(); !ok || sth.Timestamp != 4000 {
+		t.Fatalf("Got STH %v (%v) after newer update, expected timestamp 4000", sth, ok)
+	}
+
+	ts.FakeTime = ts.FakeTime.Add(time.Minute + time.Second)
+
+	if _, ok := refresher.get(); ok {
+		t.Fatal("Got STH after it expired")
+	}
+
+	refresher.fail()
+
+	if treeSize, age, failures := refresher.Stats(); treeSize != 10 || age != time.Minute+time.Second || failures != 1 {
+		t.Errorf("Got stats %d, %v, %d, expected 10, %v, 1", treeSize, age, failures, time.Minute+time.Second)
+	}
+}
+
+func TestGetSTHServedByRefresher(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// The STH is only fetched and signed by the refresh, not by each request
+	km := crypto.NewMockKeyManager(mockCtrl)
+	signer := crypto.NewMockSigner(mockCtrl)
+	signer.EXPECT().Public().AnyTimes().Return(testRSAPublicKey)
+	signer.EXPECT().Sign(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return([]byte("signed"), nil)
+	km.EXPECT().Signer().AnyTimes().Return(signer, nil)
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	client.EXPECT().GetLatestSignedLogRoot(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLatestSignedLogRootRequest{LogId: 0x42}).Times(1).Return(ttestonly.GetRootResponse(12345000000, 25, []byte("abcdabcdabcdabcdabcdabcdabcdabcd")), nil)
+
+	refresher := NewSTHRefresher(time.Minute, fakeTimeSource)
+	c := CTRequestHandlers{logID: 0x42, rpcClient: client, logKeyManager: km, rpcDeadline: time.Millisecond * 500, timeSource: fakeTimeSource, sthRefresher: refresher}
+
+	if err := c.refreshSTH(); err != nil {
+		t.Fatalf("Failed to refresh STH: %v", err)
+	}
+
+	handler := wrappedGetSTHHandler(c)
+
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest("GET", "/ct/v1/get-sth", nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Fatalf("Got %v expected %v. Body: %v", got, want, w.Body)
+		}
+
+		var resp ctapi.GetSTHResponse
+
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to unmarshal json response: %s", w.Body.Bytes())
+		}
+
+		if got, want := resp.TreeSize, int64(25); got != want {
+			t.Errorf("Got tree size %d, expected %d", got, want)
+		}
+	}
+}
+
+func TestRefreshSTHFails(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	client.EXPECT().GetLatestSignedLogRoot(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.GetLatestSignedLogRootRequest{LogId: 0x42}).Return(nil, errors.New("backendfailure"))
+
+	refresher := NewSTHRefresher(time.Minute, fakeTimeSource)
+	c := CTRequestHandlers{logID: 0x42, rpcClient: client, rpcDeadline: time.Millisecond * 500, timeSource: fakeTimeSource, sthRefresher: refresher}
+
+	if err := c.refreshSTH(); err == nil {
+		t.Fatal("Refreshing STH succeeded when the backend failed")
+	}
+
+	if _, ok := refresher.get(); ok {
+		t.Error("Got STH after failed refresh")
+	}
+
+	if _, _, failures := refresher.Stats(); failures != 1 {
+		t.Errorf("Got %d failures, expected 1", failures)
+	}
+}
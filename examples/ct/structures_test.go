@@ -108,6 +108,7 @@ func setupMockKeyManager(ctrl *gomock.Controller, toSign []byte) *crypto.MockKey
 func setupMockKeyManagerForSth(ctrl *gomock.Controller, toSign []byte) *crypto.MockKeyManager {
 	mockKeyManager := crypto.NewMockKeyManager(ctrl)
 	mockSigner := crypto.NewMockSigner(ctrl)
+	mockSigner.EXPECT().Public().AnyTimes().Return(testRSAPublicKey)
 	mockSigner.EXPECT().Sign(gomock.Any(), toSign, gomock.Any()).AnyTimes().Return([]byte("signed"), nil)
 	mockKeyManager.EXPECT().Signer().AnyTimes().Return(mockSigner, nil)
 
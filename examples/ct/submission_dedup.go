@@ -0,0 +1,207 @@
+package ct
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/certificate-transparency/go/x509"
+	"github.com/google/trillian"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+// submissionID identifies a submitted chain and whether it was for add-pre-chain
+type submissionID [sha256.Size]byte
+
+// dedupEntry is the value stored in the LRU list for a chain that was issued an SCT
+type dedupEntry struct {
+	id submissionID
+	// timestamp is the timestamp of the SCT, in milliseconds
+	timestamp uint64
+	// leafHash is the hash of the leaf that was queued for the chain
+	leafHash []byte
+	// recorded is when the leaf was queued
+	recorded time.Time
+	// confirmed is set once the leaf has been found in the tree
+	confirmed bool
+}
+
+// SubmissionDedup remembers the chains that SCTs have been issued for so that a chain that is
+// submitted again gets an SCT with the original timestamp, for the leaf that is already in
+// the log, rather than a second leaf being queued. Chains are identified by all their
+// certificates, as the leaf and SCT can depend on the whole chain. The least recently used
+// chains are forgotten when it's full, after which they're treated as new.
+//
+// A chain's leaf may still be queued, so it's only looked up in the backend by its hash once
+// pendingWindow has passed since it was queued, and if it isn't found by then it's assumed to
+// have been lost, e.g. by a fast SCT journal, and the chain is treated as new. It is safe for
+// concurrent use.
+type SubmissionDedup struct {
+	// capacity is the maximum number of chains that will be remembered
+	capacity int
+	// pendingWindow is how long a leaf may be queued before it should be in the tree
+	pendingWindow time.Duration
+	timeSource    util.TimeSource
+
+	// mu guards the fields below it
+	mu sync.Mutex
+	// entries maps submission IDs to their element in lru
+	entries map[submissionID]*list.Element
+	// lru holds *dedupEntry values, most recently used at the front
+	lru *list.List
+	// duplicates counts the submissions answered with an earlier SCT
+	duplicates int64
+	// lost counts the leaves that weren't found in the tree after pendingWindow
+	lost int64
+}
+
+// NewSubmissionDedup creates a SubmissionDedup that remembers at most capacity chains and
+// expects their leaves to be in the tree within pendingWindow, which should be the log's
+// maximum merge delay.
+func NewSubmissionDedup(capacity int, pendingWindow time.Duration, timeSource util.TimeSource) (*SubmissionDedup, error) {
+	if capacity <= 0 {
+		return nil, errors.New("submission dedup capacity must be positive")
+	}
+
+	if pendingWindow <= 0 {
+		return nil, errors.New("submission dedup pending window must be positive")
+	}
+
+	return &SubmissionDedup{capacity: capacity, pendingWindow: pendingWindow, timeSource: timeSource, entries: make(map[submissionID]*list.Element), lru: list.New()}, nil
+}
+
+// newSubmissionID hashes whether a verified chain is for a precertificate along with the
+// fingerprint of all its certs.
+func newSubmissionID(chain []*x509.Certificate, isPrecert bool) submissionID {
+	var precert byte
+
+	if isPrecert {
+		precert = 1
+	}
+
+	h := sha256.New()
+	h.Write([]byte{precert})
+	fingerprint := fingerprintIntermediates(chain)
+	h.Write(fingerprint[:])
+
+	var id submissionID
+	copy(id[:], h.Sum(nil))
+
+	return id
+}
+
+// lookup returns a copy of the entry for a chain, if it's remembered.
+func (d *SubmissionDedup) lookup(id submissionID) (dedupEntry, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	elem, ok := d.entries[id]
+
+	if !ok {
+		return dedupEntry{}, false
+	}
+
+	d.lru.MoveToFront(elem)
+	return *elem.Value.(*dedupEntry), true
+}
+
+// record remembers that an SCT with timestamp was issued for a chain and its leaf queued, and
+// evicts the least recently used entries if it's full.
+func (d *SubmissionDedup) record(id submissionID, timestamp uint64, leafHash []byte) {
+	entry := &dedupEntry{id: id, timestamp: timestamp, leafHash: leafHash, recorded: d.timeSource.Now()}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if elem, ok := d.entries[id]; ok {
+		elem.Value = entry
+		d.lru.MoveToFront(elem)
+		return
+	}
+
+	d.entries[id] = d.lru.PushFront(entry)
+
+	for d.lru.Len() > d.capacity {
+		elem := d.lru.Back()
+		delete(d.entries, elem.Value.(*dedupEntry).id)
+		d.lru.Remove(elem)
+	}
+}
+
+// duplicate counts a submission answered with an earlier SCT, and records that its leaf is in
+// the tree if confirmed is set.
+func (d *SubmissionDedup) duplicate(id submissionID, confirmed bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.duplicates++
+
+	if elem, ok := d.entries[id]; ok && confirmed {
+		elem.Value.(*dedupEntry).confirmed = true
+	}
+}
+
+// forget removes a chain whose leaf was lost, so that it's treated as new.
+func (d *SubmissionDedup) forget(id submissionID) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.lost++
+
+	if elem, ok := d.entries[id]; ok {
+		delete(d.entries, id)
+		d.lru.Remove(elem)
+	}
+}
+
+// Stats returns the number of chains remembered, the number of submissions that were answered
+// with an earlier SCT and the number of leaves that were found to be lost.
+func (d *SubmissionDedup) Stats() (size int, duplicates, lost int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.lru.Len(), d.duplicates, d.lost
+}
+
+// previousSubmission returns the timestamp of the SCT issued for an earlier submission of a
+// verified chain, if there was one and its leaf hasn't been lost.
+func (c CTRequestHandlers) previousSubmission(ctx context.Context, chain []*x509.Certificate, isPrecert bool) (uint64, bool) {
+	id := newSubmissionID(chain, isPrecert)
+	entry, ok := c.submissionDedup.lookup(id)
+
+	if !ok {
+		return 0, false
+	}
+
+	// The leaf may not have been sequenced yet so it's only worth looking for later
+	if entry.confirmed || c.submissionDedup.timeSource.Now().Sub(entry.recorded) < c.submissionDedup.pendingWindow {
+		c.submissionDedup.duplicate(id, false)
+		return entry.timestamp, true
+	}
+
+	ctx, cancel := context.WithDeadline(ctx, getRPCDeadlineTime(c))
+	defer cancel()
+
+	request := trillian.GetLeavesByHashRequest{LogId: c.logID, LeafHash: [][]byte{entry.leafHash}}
+	response, err := c.rpcClient.GetLeavesByHash(ctx, &request)
+
+	if err != nil || !rpcStatusOK(response.GetStatus()) {
+		// The SCT was issued so it's safer to answer with it than to queue another leaf
+		glog.Warningf("Failed to look up the leaf of a duplicate submission to log %d: %v", c.logID, backendError("GetLeavesByHash", err, response.GetStatus()))
+		c.submissionDedup.duplicate(id, false)
+		return entry.timestamp, true
+	}
+
+	if len(response.Leaves) == 0 {
+		glog.Warningf("Leaf %x of an earlier submission to log %d isn't in the tree, treating the chain as new", entry.leafHash, c.logID)
+		c.submissionDedup.forget(id)
+		return 0, false
+	}
+
+	c.submissionDedup.duplicate(id, true)
+	return entry.timestamp, true
+}
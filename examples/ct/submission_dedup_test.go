@@ -0,0 +1,184 @@
+package ct
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/examples/ct/ctapi"
+	"github.com/google/trillian/examples/ct/testonly"
+	ttestonly "github.com/google/trillian/testonly"
+	"github.com/google/trillian/util"
+)
+
+func TestNewSubmissionDedupRejectsInvalid(t *testing.T) {
+	if _, err := NewSubmissionDedup(0, time.Hour, fakeTimeSource); err == nil {
+		t.Error("NewSubmissionDedup() with no capacity succeeded, expected an error")
+	}
+
+	if _, err := NewSubmissionDedup(10, 0, fakeTimeSource); err == nil {
+		t.Error("NewSubmissionDedup() with no pending window succeeded, expected an error")
+	}
+}
+
+func TestSubmissionDedupEvictsLeastRecentlyUsed(t *testing.T) {
+	dedup, err := NewSubmissionDedup(2, time.Hour, fakeTimeSource)
+
+	if err != nil {
+		t.Fatalf("NewSubmissionDedup()=%v", err)
+	}
+
+	ids := []submissionID{{1}, {2}, {3}}
+	dedup.record(ids[0], 1000, []byte("leaf0"))
+	dedup.record(ids[1], 2000, []byte("leaf1"))
+
+	// Using the first makes the second the least recently used
+	if entry, ok := dedup.lookup(ids[0]); !ok || entry.timestamp != 1000 {
+		t.Fatalf("Got entry %+v (%v), expected timestamp 1000", entry, ok)
+	}
+
+	dedup.record(ids[2], 3000, []byte("leaf2"))
+
+	if _, ok := dedup.lookup(ids[1]); ok {
+		t.Error("Got evicted entry")
+	}
+
+	for _, id := range []submissionID{ids[0], ids[2]} {
+		if _, ok := dedup.lookup(id); !ok {
+			t.Errorf("Entry %x was evicted, expected it to be kept", id[:1])
+		}
+	}
+
+	if size, _, _ := dedup.Stats(); size != 2 {
+		t.Errorf("Got size %d, expected 2", size)
+	}
+}
+
+func TestSubmissionIDDependsOnEndpoint(t *testing.T) {
+	pool := loadCertsIntoPoolOrDie(t, []string{testonly.LeafSignedByFakeIntermediateCertPem, testonly.FakeIntermediateCertPem})
+	chain := pool.RawCertificates()
+
+	if newSubmissionID(chain, false) == newSubmissionID(chain, true) {
+		t.Error("Got the same ID for add-chain and add-pre-chain submissions")
+	}
+
+	if newSubmissionID(chain, false) == newSubmissionID(chain[:1], false) {
+		t.Error("Got the same ID for different chains")
+	}
+}
+
+func TestAddChainDuplicate(t *testing.T) {
+	toSign := []byte{0x7a, 0xc4, 0xd9, 0xca, 0x5f, 0x2e, 0x23, 0x82, 0xfe, 0xef, 0x5e, 0x95, 0x64, 0x7b, 0x31, 0x11, 0xf, 0x2a, 0x9b, 0x78, 0xa8, 0x3, 0x30, 0x8d, 0xfc, 0x8b, 0x78, 0x6, 0x61, 0xe7, 0x58, 0x44}
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	km := setupMockKeyManager(mockCtrl, toSign)
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+
+	dedup, err := NewSubmissionDedup(10, time.Hour, ts)
+
+	if err != nil {
+		t.Fatalf("NewSubmissionDedup()=%v", err)
+	}
+
+	roots := loadCertsIntoPoolOrDie(t, []string{testonly.FakeCACertPem})
+	reqHandlers := CTRequestHandlers{logID: 0x42, trustedRoots: roots, rpcClient: client, logKeyManager: km, rpcDeadline: time.Millisecond * 500, timeSource: ts, submissionDedup: dedup}
+
+	pool := loadCertsIntoPoolOrDie(t, []string{testonly.LeafSignedByFakeIntermediateCertPem, testonly.FakeIntermediateCertPem})
+	merkleLeaf, _, err := signV1SCTForCertificate(km, SignatureOptions{}, pool.RawCertificates()[0], fakeTime, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaves := leafProtosForCert(t, km, pool.RawCertificates(), merkleLeaf)
+
+	// The leaf is only queued by the first submission. Within the pending window the backend
+	// isn't asked about it, afterwards it's looked up once and found.
+	client.EXPECT().QueueLeaves(ttestonly.DeadlineMatcher(fakeDeadlineTime), &trillian.QueueLeavesRequest{LogId: 0x42, Leaves: leaves}).Return(&trillian.QueueLeavesResponse{Status: okStatus}, nil)
+	client.EXPECT().GetLeavesByHash(gomock.Any(), &trillian.GetLeavesByHashRequest{LogId: 0x42, LeafHash: [][]byte{leaves[0].LeafHash}}).Return(&trillian.GetLeavesByHashResponse{Status: okStatus, Leaves: leaves}, nil)
+
+	for i, elapsed := range []time.Duration{0, time.Minute, time.Hour, time.Minute} {
+		ts.FakeTime = ts.FakeTime.Add(elapsed)
+		recorder := makeAddChainRequest(t, reqHandlers, testonly.AddChainBody(pool.RawCertificates()))
+
+		if got, want := recorder.Code, http.StatusOK; got != want {
+			t.Fatalf("submission %d: expected %v for valid add-chain, got %v. Body: %v", i, want, got, recorder.Body)
+		}
+
+		var resp ctapi.AddChainResponse
+		if err = json.NewDecoder(recorder.Body).Decode(&resp); err != nil {
+			t.Fatalf("submission %d: failed to unmarshal json: %v, body: %v", i, err, recorder.Body.Bytes())
+		}
+
+		// Duplicates get the timestamp of the first submission, not the current time
+		if got, want := resp.Timestamp, uint64(1469185273000000); got != want {
+			t.Fatalf("submission %d: got timestamp %d, expected %d", i, got, want)
+		}
+	}
+
+	if _, duplicates, lost := dedup.Stats(); duplicates != 3 || lost != 0 {
+		t.Errorf("Got %d duplicates and %d lost leaves, expected 3 and 0", duplicates, lost)
+	}
+}
+
+func TestAddChainDuplicateOfLostLeaf(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// The chain gets a new SCT, with a different timestamp, so accept any input
+	km := crypto.NewMockKeyManager(mockCtrl)
+	signer := crypto.NewMockSigner(mockCtrl)
+	signer.EXPECT().Public().AnyTimes().Return(testRSAPublicKey)
+	signer.EXPECT().Sign(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return([]byte("signed"), nil)
+	km.EXPECT().Signer().AnyTimes().Return(signer, nil)
+	km.EXPECT().GetRawPublicKey().AnyTimes().Return([]byte("key"), nil)
+
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	ts := &util.FakeTimeSource{FakeTime: fakeTime}
+
+	dedup, err := NewSubmissionDedup(10, time.Hour, ts)
+
+	if err != nil {
+		t.Fatalf("NewSubmissionDedup()=%v", err)
+	}
+
+	roots := loadCertsIntoPoolOrDie(t, []string{testonly.FakeCACertPem})
+	reqHandlers := CTRequestHandlers{logID: 0x42, trustedRoots: roots, rpcClient: client, logKeyManager: km, rpcDeadline: time.Millisecond * 500, timeSource: ts, submissionDedup: dedup}
+	pool := loadCertsIntoPoolOrDie(t, []string{testonly.LeafSignedByFakeIntermediateCertPem, testonly.FakeIntermediateCertPem})
+
+	// The first leaf never makes it into the tree so the chain is queued again
+	client.EXPECT().QueueLeaves(gomock.Any(), gomock.Any()).Times(2).Return(&trillian.QueueLeavesResponse{Status: okStatus}, nil)
+	client.EXPECT().GetLeavesByHash(gomock.Any(), gomock.Any()).Return(&trillian.GetLeavesByHashResponse{Status: okStatus}, nil)
+
+	var timestamps []uint64
+
+	for i := 0; i < 2; i++ {
+		recorder := makeAddChainRequest(t, reqHandlers, testonly.AddChainBody(pool.RawCertificates()))
+
+		if got, want := recorder.Code, http.StatusOK; got != want {
+			t.Fatalf("submission %d: expected %v for valid add-chain, got %v. Body: %v", i, want, got, recorder.Body)
+		}
+
+		var resp ctapi.AddChainResponse
+		if err = json.NewDecoder(recorder.Body).Decode(&resp); err != nil {
+			t.Fatalf("submission %d: failed to unmarshal json: %v, body: %v", i, err, recorder.Body.Bytes())
+		}
+
+		timestamps = append(timestamps, resp.Timestamp)
+		ts.FakeTime = ts.FakeTime.Add(time.Hour + time.Minute)
+	}
+
+	if timestamps[0] == timestamps[1] {
+		t.Errorf("Got timestamp %d again after the leaf was lost, expected a new one", timestamps[1])
+	}
+
+	if _, duplicates, lost := dedup.Stats(); duplicates != 0 || lost != 1 {
+		t.Errorf("Got %d duplicates and %d lost leaves, expected 0 and 1", duplicates, lost)
+	}
+}
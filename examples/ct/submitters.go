@@ -0,0 +1,298 @@
+package ct
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/util"
+)
+
+// SubmitterConfig describes a client that's allowed to submit to a log that only accepts
+// authenticated submissions. A submitter authenticates with any one of its API keys, sent as
+// a bearer token in the Authorization header, or with a TLS client certificate whose SHA-256
+// fingerprint is listed.
+type SubmitterConfig struct {
+	// Name identifies the submitter in logs and stats
+	Name string `json:"name"`
+	// APIKeys are the bearer tokens the submitter can authenticate with
+	APIKeys []string `json:"api_keys"`
+	// ClientCertSHA256 are the hex encoded SHA-256 fingerprints of the DER encoded client
+	// certificates the submitter can authenticate with
+	ClientCertSHA256 []string `json:"client_cert_sha256"`
+	// QPS is the rate at which the submitter's quota refills. Zero means the submitter has no
+	// quota.
+	QPS float64 `json:"qps"`
+	// Burst is the most submissions that can be made at once after the quota has refilled.
+	// It must be at least 1 if QPS is set.
+	Burst int `json:"burst"`
+	// FailOpen allows the submitter's requests if its quota is shared through Redis and Redis
+	// can't be reached. Otherwise they're throttled until it can be.
+	FailOpen bool `json:"fail_open"`
+}
+
+// LoadSubmitterConfigs reads and validates a JSON array of SubmitterConfig from a file.
+func LoadSubmitterConfigs(path string) ([]SubmitterConfig, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSubmitterConfigs(data)
+}
+
+// ParseSubmitterConfigs parses and validates a JSON array of SubmitterConfig. Names, API keys
+// and certificate fingerprints must be unique across the submitters.
+func ParseSubmitterConfigs(data []byte) ([]SubmitterConfig, error) {
+	var configs []SubmitterConfig
+
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse submitters: %v", err)
+	}
+
+	if len(configs) == 0 {
+		return nil, errors.New("no submitters configured")
+	}
+
+	// Building the lookup tables checks for duplicates
+	if _, err := NewSubmitters(configs, util.SystemTimeSource{}); err != nil {
+		return nil, err
+	}
+
+	return configs, nil
+}
+
+// quota limits how often a submitter can add chains
+type quota interface {
+	// take uses one submission from the quota. It returns false if there's none left.
+	take(now time.Time) bool
+}
+
+// submitterQuota is a token bucket that limits how often a submitter can add chains. It's held
+// in memory, so each frontend replica has its own.
+type submitterQuota struct {
+	qps   float64
+	burst float64
+
+	// mu guards the fields below it
+	mu sync.Mutex
+	// tokens is the number of submissions allowed as of refilled
+	tokens   float64
+	refilled time.Time
+}
+
+// take uses one submission from the quota. It returns false if there's none left.
+func (q *submitterQuota) take(now time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if elapsed := now.Sub(q.refilled); elapsed > 0 {
+		q.tokens = math.Min(q.burst, q.tokens+elapsed.Seconds()*q.qps)
+		q.refilled = now
+	}
+
+	if q.tokens < 1 {
+		return false
+	}
+
+	q.tokens--
+	return true
+}
+
+// submitter is an authenticated client of a log along with its quota and stats
+type submitter struct {
+	name string
+	// quota is nil if the submitter is unlimited
+	quota quota
+	// failOpen is from the submitter's config, for when its quota is moved to Redis
+	failOpen bool
+
+	// mu guards the fields below it
+	mu        sync.Mutex
+	accepted  int64
+	throttled int64
+}
+
+// allow checks the submitter's quota and records the outcome
+func (s *submitter) allow(now time.Time) bool {
+	ok := s.quota == nil || s.quota.take(now)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ok {
+		s.accepted++
+	} else {
+		s.throttled++
+	}
+
+	return ok
+}
+
+// SubmitterStats counts the requests from a submitter that got past authentication
+type SubmitterStats struct {
+	Accepted  int64 `json:"accepted"`
+	Throttled int64 `json:"throttled"`
+	// QuotaErrors is the number of requests whose quota couldn't be checked in Redis, which
+	// were accepted or throttled as the submitter's FailOpen says
+	QuotaErrors int64 `json:"quota_errors,omitempty"`
+}
+
+// Submitters are the clients allowed to submit to a log, each with its own quota. It is safe
+// for concurrent use.
+type Submitters struct {
+	timeSource util.TimeSource
+	// byKey and byCert are keyed by the SHA-256 hash of an API key and the fingerprint of a
+	// client certificate. API keys are looked up by hash so that the time a lookup takes
+	// doesn't reveal how much of a key was right.
+	byKey  map[[sha256.Size]byte]*submitter
+	byCert map[[sha256.Size]byte]*submitter
+	all    []*submitter
+}
+
+// NewSubmitters creates Submitters from their configs, each starting with a full quota.
+func NewSubmitters(configs []SubmitterConfig, timeSource util.TimeSource) (*Submitters, error) {
+	s := &Submitters{
+		timeSource: timeSource,
+		byKey:      make(map[[sha256.Size]byte]*submitter),
+		byCert:     make(map[[sha256.Size]byte]*submitter),
+	}
+	names := make(map[string]bool)
+
+	for _, config := range configs {
+		if len(config.Name) == 0 {
+			return nil, errors.New("submitter has no name")
+		}
+
+		if names[config.Name] {
+			return nil, fmt.Errorf("submitter %q is configured more than once", config.Name)
+		}
+
+		if len(config.APIKeys) == 0 && len(config.ClientCertSHA256) == 0 {
+			return nil, fmt.Errorf("submitter %q has no API keys or client certificates", config.Name)
+		}
+
+		if config.QPS < 0 || config.Burst < 0 || (config.QPS > 0 && config.Burst < 1) {
+			return nil, fmt.Errorf("submitter %q has an invalid quota: qps %v, burst %d", config.Name, config.QPS, config.Burst)
+		}
+
+		names[config.Name] = true
+		sub := &submitter{name: config.Name, failOpen: config.FailOpen}
+
+		if config.QPS > 0 {
+			sub.quota = &submitterQuota{qps: config.QPS, burst: float64(config.Burst), tokens: float64(config.Burst), refilled: timeSource.Now()}
+		}
+
+		for _, key := range config.APIKeys {
+			if len(key) == 0 {
+				return nil, fmt.Errorf("submitter %q has an empty API key", config.Name)
+			}
+
+			hash := sha256.Sum256([]byte(key))
+
+			if _, ok := s.byKey[hash]; ok {
+				return nil, fmt.Errorf("submitter %q has an API key that's already in use", config.Name)
+			}
+
+			s.byKey[hash] = sub
+		}
+
+		for _, fp := range config.ClientCertSHA256 {
+			var fingerprint [sha256.Size]byte
+			decoded, err := hex.DecodeString(strings.Replace(fp, ":", "", -1))
+
+			if err != nil || len(decoded) != len(fingerprint) {
+				return nil, fmt.Errorf("submitter %q has an invalid client certificate fingerprint: %q", config.Name, fp)
+			}
+
+			copy(fingerprint[:], decoded)
+
+			if _, ok := s.byCert[fingerprint]; ok {
+				return nil, fmt.Errorf("submitter %q has a client certificate that's already in use", config.Name)
+			}
+
+			s.byCert[fingerprint] = sub
+		}
+
+		s.all = append(s.all, sub)
+	}
+
+	return s, nil
+}
+
+// authenticate returns the submitter that sent r. A client certificate is checked before the
+// Authorization header. Client certificates are only seen if the server requests them during
+// the TLS handshake, which proves that the client holds the certificate's private key.
+func (s *Submitters) authenticate(r *http.Request) (*submitter, error) {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		if sub, ok := s.byCert[sha256.Sum256(r.TLS.PeerCertificates[0].Raw)]; ok {
+			return sub, nil
+		}
+	}
+
+	auth := r.Header.Get(authorizationHeader)
+
+	if !strings.HasPrefix(auth, bearerPrefix) {
+		return nil, errors.New("missing API key or client certificate")
+	}
+
+	if sub, ok := s.byKey[sha256.Sum256([]byte(strings.TrimPrefix(auth, bearerPrefix)))]; ok {
+		return sub, nil
+	}
+
+	return nil, errors.New("unknown API key")
+}
+
+// Stats returns the number of accepted and throttled requests for each submitter by name.
+func (s *Submitters) Stats() map[string]SubmitterStats {
+	stats := make(map[string]SubmitterStats)
+
+	for _, sub := range s.all {
+		sub.mu.Lock()
+		stats[sub.name] = SubmitterStats{Accepted: sub.accepted, Throttled: sub.throttled}
+		sub.mu.Unlock()
+
+		if q, ok := sub.quota.(*redisQuota); ok {
+			stat := stats[sub.name]
+			stat.QuotaErrors = q.errorCount()
+			stats[sub.name] = stat
+		}
+	}
+
+	return stats
+}
+
+// submitterAuthHandler only passes on requests from known submitters that have quota left,
+// before anything is done with the request body
+type submitterAuthHandler struct {
+	submitters *Submitters
+	handler    http.Handler
+}
+
+func (h submitterAuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sub, err := h.submitters.authenticate(r)
+
+	if err != nil {
+		glog.Warningf("Rejected unauthenticated submission from %s: %v", r.RemoteAddr, err)
+		sendHttpError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	if !sub.allow(h.submitters.timeSource.Now()) {
+		glog.V(logVerboseLevel).Infof("Submitter %s is over quota", sub.name)
+		sendHttpError(w, http.StatusTooManyRequests, fmt.Errorf("submitter %s is over quota", sub.name))
+		return
+	}
+
+	h.handler.ServeHTTP(w, r)
+}
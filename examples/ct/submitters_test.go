@@ -0,0 +1,155 @@
+package ct
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/trillian/util"
+)
+
+var testClientCert = []byte("not really a certificate")
+
+func testSubmitterConfigs() []SubmitterConfig {
+	fingerprint := sha256.Sum256(testClientCert)
+
+	return []SubmitterConfig{
+		{Name: "limited", APIKeys: []string{"key1", "key2"}, QPS: 1, Burst: 2},
+		{Name: "unlimited", APIKeys: []string{"key3"}, ClientCertSHA256: []string{hex.EncodeToString(fingerprint[:])}},
+	}
+}
+
+// submitterRequest sends a request with the bearer token, if any, through the handler and
+// returns the status code
+func submitterRequest(t *testing.T, handler http.Handler, token string, certs ...*x509.Certificate) int {
+	req, err := http.NewRequest(httpMethodPost, "http://example.com/ct/v1/add-chain", nil)
+
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	if len(token) > 0 {
+		req.Header.Set(authorizationHeader, bearerPrefix+token)
+	}
+
+	if len(certs) > 0 {
+		req.TLS = &tls.ConnectionState{PeerCertificates: certs}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	return w.Code
+}
+
+func TestSubmitterAuthHandler(t *testing.T) {
+	timeSource := &util.FakeTimeSource{FakeTime: fakeTime}
+	submitters, err := NewSubmitters(testSubmitterConfigs(), timeSource)
+
+	if err != nil {
+		t.Fatalf("NewSubmitters()=%v", err)
+	}
+
+	served := 0
+	handler := submitterAuthHandler{submitters: submitters, handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+	})}
+
+	for _, test := range []struct {
+		token string
+		certs []*x509.Certificate
+		want  int
+	}{
+		{want: http.StatusUnauthorized},
+		{token: "wrong", want: http.StatusUnauthorized},
+		{certs: []*x509.Certificate{{Raw: []byte("unknown certificate")}}, want: http.StatusUnauthorized},
+		// Both of limited's keys share its quota of 2
+		{token: "key1", want: http.StatusOK},
+		{token: "key2", want: http.StatusOK},
+		{token: "key1", want: http.StatusTooManyRequests},
+		// Other submitters have their own quotas
+		{token: "key3", want: http.StatusOK},
+		{certs: []*x509.Certificate{{Raw: testClientCert}}, want: http.StatusOK},
+	} {
+		if got := submitterRequest(t, handler, test.token, test.certs...); got != test.want {
+			t.Errorf("Token %q, %d certs: got status %d, expected %d", test.token, len(test.certs), got, test.want)
+		}
+	}
+
+	if served != 4 {
+		t.Errorf("Handler served %d requests, expected 4", served)
+	}
+
+	// A second later the limited submitter can make one more request
+	timeSource.FakeTime = fakeTime.Add(time.Second)
+
+	if got := submitterRequest(t, handler, "key2"); got != http.StatusOK {
+		t.Errorf("Got status %d after quota refilled, expected %d", got, http.StatusOK)
+	}
+
+	if got := submitterRequest(t, handler, "key2"); got != http.StatusTooManyRequests {
+		t.Errorf("Got status %d with quota used, expected %d", got, http.StatusTooManyRequests)
+	}
+
+	stats := submitters.Stats()
+
+	if got, want := stats["limited"], (SubmitterStats{Accepted: 3, Throttled: 2}); got != want {
+		t.Errorf("Got stats %+v for limited, expected %+v", got, want)
+	}
+
+	if got, want := stats["unlimited"], (SubmitterStats{Accepted: 2}); got != want {
+		t.Errorf("Got stats %+v for unlimited, expected %+v", got, want)
+	}
+}
+
+func TestSubmitterQuotaCapsAtBurst(t *testing.T) {
+	q := submitterQuota{qps: 10, burst: 3, tokens: 3, refilled: fakeTime}
+
+	// An hour idle doesn't give more than the burst
+	now := fakeTime.Add(time.Hour)
+
+	for i := 0; i < 3; i++ {
+		if !q.take(now) {
+			t.Fatalf("take() %d failed with quota left", i)
+		}
+	}
+
+	if q.take(now) {
+		t.Error("take() succeeded beyond the burst")
+	}
+
+	if !q.take(now.Add(100 * time.Millisecond)) {
+		t.Error("take() failed after the quota refilled")
+	}
+}
+
+func TestParseSubmitterConfigs(t *testing.T) {
+	for _, test := range []struct {
+		json string
+		ok   bool
+	}{
+		{`[{"name": "a", "api_keys": ["k"]}]`, true},
+		{`[{"name": "a", "client_cert_sha256": ["` + hex.EncodeToString(make([]byte, sha256.Size)) + `"], "qps": 5, "burst": 10}]`, true},
+		{`[]`, false},
+		{`not json`, false},
+		{`[{"api_keys": ["k"]}]`, false},
+		{`[{"name": "a"}]`, false},
+		{`[{"name": "a", "api_keys": [""]}]`, false},
+		{`[{"name": "a", "api_keys": ["k"]}, {"name": "a", "api_keys": ["j"]}]`, false},
+		{`[{"name": "a", "api_keys": ["k"]}, {"name": "b", "api_keys": ["k"]}]`, false},
+		{`[{"name": "a", "client_cert_sha256": ["abcd"]}]`, false},
+		{`[{"name": "a", "api_keys": ["k"], "qps": 5}]`, false},
+		{`[{"name": "a", "api_keys": ["k"], "qps": -1, "burst": 1}]`, false},
+	} {
+		_, err := ParseSubmitterConfigs([]byte(test.json))
+
+		if (err == nil) != test.ok {
+			t.Errorf("ParseSubmitterConfigs(%s): got err %v, expected ok %v", test.json, err, test.ok)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package ct
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/certificate-transparency/go/x509"
+)
+
+// NotAfterWindow is the range of expiry times accepted by a log that is a temporal shard, such
+// as one of a set of logs that each take the certificates expiring in one year. A certificate
+// or precertificate is accepted if its NotAfter is in [Start, Limit). A zero Start or Limit
+// leaves that end of the window open.
+type NotAfterWindow struct {
+	Start time.Time
+	Limit time.Time
+}
+
+// Contains returns whether a certificate expiring at notAfter is in the window.
+func (w NotAfterWindow) Contains(notAfter time.Time) bool {
+	if !w.Start.IsZero() && notAfter.Before(w.Start) {
+		return false
+	}
+
+	if !w.Limit.IsZero() && !notAfter.Before(w.Limit) {
+		return false
+	}
+
+	return true
+}
+
+// Check is a SubmissionPolicy that rejects chains whose leaf expires outside the window.
+func (w NotAfterWindow) Check(chain []*x509.Certificate, isPrecert bool) error {
+	if len(chain) == 0 {
+		return errEmptyChain
+	}
+
+	notAfter := chain[0].NotAfter
+
+	if !w.Contains(notAfter) {
+		glog.V(logVerboseLevel).Infof("Rejected submission expiring at %v outside the log's NotAfter window", notAfter)
+		return fmt.Errorf("certificate expires at %s, outside the log's NotAfter window %s", notAfter.Format(time.RFC3339), w)
+	}
+
+	return nil
+}
+
+// String formats the window as an interval of RFC 3339 times, with open ends left empty.
+func (w NotAfterWindow) String() string {
+	var start, limit string
+
+	if !w.Start.IsZero() {
+		start = w.Start.Format(time.RFC3339)
+	}
+
+	if !w.Limit.IsZero() {
+		limit = w.Limit.Format(time.RFC3339)
+	}
+
+	return fmt.Sprintf("[%s, %s)", start, limit)
+}
@@ -0,0 +1,83 @@
+package ct
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/certificate-transparency/go/x509"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/examples/ct/testonly"
+)
+
+func TestNotAfterWindowContains(t *testing.T) {
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	limit := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, test := range []struct {
+		window   NotAfterWindow
+		notAfter time.Time
+		want     bool
+	}{
+		{NotAfterWindow{Start: start, Limit: limit}, start, true},
+		{NotAfterWindow{Start: start, Limit: limit}, limit.Add(-time.Second), true},
+		{NotAfterWindow{Start: start, Limit: limit}, start.Add(-time.Second), false},
+		{NotAfterWindow{Start: start, Limit: limit}, limit, false},
+		{NotAfterWindow{Start: start}, limit.AddDate(10, 0, 0), true},
+		{NotAfterWindow{Start: start}, start.Add(-time.Second), false},
+		{NotAfterWindow{Limit: limit}, start.AddDate(-10, 0, 0), true},
+		{NotAfterWindow{Limit: limit}, limit, false},
+		{NotAfterWindow{}, start, true},
+	} {
+		if got := test.window.Contains(test.notAfter); got != test.want {
+			t.Errorf("%s.Contains(%v)=%v, expected %v", test.window, test.notAfter, got, test.want)
+		}
+	}
+}
+
+func TestNotAfterWindowCheck(t *testing.T) {
+	pool := loadCertsIntoPoolOrDie(t, []string{testonly.LeafSignedByFakeIntermediateCertPem, testonly.FakeIntermediateCertPem})
+	chain := pool.RawCertificates()
+	notAfter := chain[0].NotAfter
+
+	// Only the leaf's expiry matters, not the intermediate's
+	if err := (NotAfterWindow{Start: notAfter, Limit: notAfter.Add(time.Second)}).Check(chain, false); err != nil {
+		t.Errorf("Check() rejected a leaf expiring at the start of the window: %v", err)
+	}
+
+	if err := (NotAfterWindow{Limit: notAfter}).Check(chain, false); err == nil {
+		t.Error("Check() accepted a leaf expiring at the limit of the window")
+	}
+
+	if err := (NotAfterWindow{}).Check([]*x509.Certificate{}, false); err != errEmptyChain {
+		t.Errorf("Check() of an empty chain = %v, expected %v", err, errEmptyChain)
+	}
+}
+
+func TestAddChainOutsideNotAfterWindow(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// The chain is rejected before an SCT is signed or the backend is called
+	client := trillian.NewMockTrillianLogClient(mockCtrl)
+	km := crypto.NewMockKeyManager(mockCtrl)
+
+	pool := loadCertsIntoPoolOrDie(t, []string{testonly.LeafSignedByFakeIntermediateCertPem, testonly.FakeIntermediateCertPem})
+	window := NotAfterWindow{Start: pool.RawCertificates()[0].NotAfter.Add(time.Second)}
+
+	roots := loadCertsIntoPoolOrDie(t, []string{testonly.FakeCACertPem})
+	reqHandlers := NewCTRequestHandlers(0x42, roots, client, km, WithTimeSource(fakeTimeSource), WithNotAfterWindow(window))
+
+	recorder := makeAddChainRequest(t, *reqHandlers, testonly.AddChainBody(pool.RawCertificates()))
+
+	if got, want := recorder.Code, http.StatusBadRequest; got != want {
+		t.Fatalf("Expected %v for add-chain outside the NotAfter window, got %v. Body: %v", want, got, recorder.Body)
+	}
+
+	if !strings.Contains(recorder.Body.String(), "NotAfter window") {
+		t.Errorf("NotAfter window error not returned: %v", recorder.Body)
+	}
+}
@@ -0,0 +1,54 @@
+package testonly
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/google/certificate-transparency/go/x509"
+)
+
+// AddChainBody returns the body of an add-chain or add-pre-chain request submitting chain,
+// which must start with the end entity or precert.
+func AddChainBody(chain []*x509.Certificate) io.Reader {
+	var req struct {
+		Chain []string `json:"chain"`
+	}
+
+	for _, cert := range chain {
+		req.Chain = append(req.Chain, base64.StdEncoding.EncodeToString(cert.Raw))
+	}
+
+	// Encoding a list of strings can't fail
+	body, _ := json.Marshal(&req)
+
+	return bytes.NewReader(body)
+}
+
+// NewAddChainRequest returns a request submitting chain to the add-chain or add-pre-chain
+// endpoint of the log at logURL, which is the URL that the ct/v1/ paths are under.
+func NewAddChainRequest(logURL, endpoint string, chain []*x509.Certificate) (*http.Request, error) {
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/ct/v1/%s", logURL, endpoint), AddChainBody(chain))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	return req, nil
+}
+
+// NewGetRequest returns a GET request for endpoint of the log at logURL with params as its
+// query string, for example "start=0&end=10".
+func NewGetRequest(logURL, endpoint, params string) (*http.Request, error) {
+	url := fmt.Sprintf("%s/ct/v1/%s", logURL, endpoint)
+
+	if len(params) > 0 {
+		url += "?" + params
+	}
+
+	return http.NewRequest("GET", url, nil)
+}
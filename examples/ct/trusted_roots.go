@@ -0,0 +1,431 @@
+package ct
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/certificate-transparency/go/x509"
+)
+
+const (
+	// HTTP header that carries the admin token, as "Bearer <token>"
+	authorizationHeader string = "Authorization"
+	// Prefix of the authorization header value before the token
+	bearerPrefix string = "Bearer "
+)
+
+// addRootRequest is the body of an add-root admin request
+type addRootRequest struct {
+	// Certificate is the DER encoded root, base64 encoded in the JSON
+	Certificate []byte `json:"certificate"`
+}
+
+// removeRootRequest is the body of a remove-root admin request
+type removeRootRequest struct {
+	// SHA256Fingerprint is the hex encoded SHA-256 hash of the DER encoded root
+	SHA256Fingerprint string `json:"sha256_fingerprint"`
+}
+
+// rootsAdminResponse is the body of the response to an admin request
+type rootsAdminResponse struct {
+	// Changed is false if the request made no difference, e.g. the root was already trusted
+	Changed bool `json:"changed"`
+	// RootCount is the number of roots trusted after the request
+	RootCount int `json:"root_count"`
+}
+
+// TrustedRoots holds the roots a log accepts and allows them to be changed while the server is
+// running, by admin requests or by reloading the roots file after it has been edited. Each
+// change made by a request is written to the roots file before the pool in memory is
+// replaced, so the log accepts the same roots after a restart. Pools returned by Pool are
+// never modified, a change installs a new one, so a request that has got the pool sees the
+// same roots throughout. It is safe for concurrent use.
+type TrustedRoots struct {
+	// path is the file the roots were loaded from, changes aren't persisted if it's empty
+	path string
+	// mu guards the fields below it and serializes changes so that the file and pool agree
+	mu   sync.Mutex
+	pool *PEMCertPool
+	// listeners are called with mu held whenever a new pool is installed
+	listeners []func()
+	// reloads and reloadFailures count the reloads that changed the roots and those that
+	// failed
+	reloads        int64
+	reloadFailures int64
+}
+
+// NewTrustedRoots creates a TrustedRoots starting with the roots in pool, which was loaded from
+// path. The caller must not modify pool afterwards.
+func NewTrustedRoots(pool *PEMCertPool, path string) *TrustedRoots {
+	return &TrustedRoots{path: path, pool: pool}
+}
+
+// Pool returns the current roots.
+func (t *TrustedRoots) Pool() *PEMCertPool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.pool
+}
+
+// Add makes cert a trusted root. It returns false if it already was.
+func (t *TrustedRoots) Add(cert *x509.Certificate) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fingerprint := sha256.Sum256(cert.Raw)
+	if _, ok := t.pool.fingerprintToCertMap[fingerprint]; ok {
+		return false, nil
+	}
+
+	certs := append(append([]*x509.Certificate(nil), t.pool.RawCertificates()...), cert)
+
+	return true, t.replace(certs)
+}
+
+// Remove stops the root with the SHA-256 fingerprint from being trusted. It returns false if
+// there's no such root. The last root can't be removed as the log would then reject every
+// submission.
+func (t *TrustedRoots) Remove(fingerprint [sha256.Size]byte) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.pool.fingerprintToCertMap[fingerprint]; !ok {
+		return false, nil
+	}
+
+	certs := make([]*x509.Certificate, 0, len(t.pool.RawCertificates()))
+
+	for _, cert := range t.pool.RawCertificates() {
+		if sha256.Sum256(cert.Raw) != fingerprint {
+			certs = append(certs, cert)
+		}
+	}
+
+	if len(certs) == 0 {
+		return false, errors.New("can't remove the only trusted root")
+	}
+
+	return true, t.replace(certs)
+}
+
+// replace persists certs as the roots and then installs a pool containing them. Must be
+// called with mu held.
+func (t *TrustedRoots) replace(certs []*x509.Certificate) error {
+	pool := NewPEMCertPool()
+	var pemData bytes.Buffer
+
+	for _, cert := range certs {
+		pool.AddCert(cert)
+
+		if err := pem.Encode(&pemData, &pem.Block{Type: pemCertificateBlockType, Bytes: cert.Raw}); err != nil {
+			return err
+		}
+	}
+
+	if len(t.path) > 0 {
+		if err := writeFileAtomically(t.path, pemData.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	t.install(pool)
+
+	return nil
+}
+
+// install makes pool the current roots and tells the listeners. Must be called with mu held.
+func (t *TrustedRoots) install(pool *PEMCertPool) {
+	t.pool = pool
+
+	for _, listener := range t.listeners {
+		listener()
+	}
+}
+
+// onChange adds a function that is called whenever the roots change, e.g. to drop state that
+// depends on them. It's called with the roots locked so it must not use them.
+func (t *TrustedRoots) onChange(listener func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.listeners = append(t.listeners, listener)
+}
+
+// Reload replaces the roots with those in the roots file, e.g. after it has been edited. The
+// current roots are kept if the file can't be read or doesn't hold any roots. It returns false
+// if the file holds the roots that are already trusted.
+func (t *TrustedRoots) Reload() (bool, error) {
+	if len(t.path) == 0 {
+		return false, errors.New("trusted roots weren't loaded from a file")
+	}
+
+	data, err := ioutil.ReadFile(t.path)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err != nil {
+		t.reloadFailures++
+		return false, err
+	}
+
+	pool := NewPEMCertPool()
+
+	if !pool.AppendCertsFromPEM(data) || len(pool.RawCertificates()) == 0 {
+		t.reloadFailures++
+		return false, fmt.Errorf("no roots found in %s", t.path)
+	}
+
+	if sameRoots(pool, t.pool) {
+		return false, nil
+	}
+
+	t.reloads++
+	t.install(pool)
+
+	return true, nil
+}
+
+// sameRoots returns whether two pools hold the same roots, in any order.
+func sameRoots(a, b *PEMCertPool) bool {
+	if len(a.fingerprintToCertMap) != len(b.fingerprintToCertMap) {
+		return false
+	}
+
+	for fingerprint := range a.fingerprintToCertMap {
+		if _, ok := b.fingerprintToCertMap[fingerprint]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ReloadOnChange reloads the roots whenever the modification time of the roots file changes,
+// checking every interval, until done is closed. Failures are logged and the current roots are
+// kept.
+func (t *TrustedRoots) ReloadOnChange(done <-chan struct{}, interval time.Duration) {
+	var modified time.Time
+
+	if info, err := os.Stat(t.path); err == nil {
+		modified = info.ModTime()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(t.path)
+
+		if err != nil {
+			glog.Warningf("Failed to check roots file %s: %v", t.path, err)
+			continue
+		}
+
+		if info.ModTime().Equal(modified) {
+			continue
+		}
+
+		modified = info.ModTime()
+		t.logReload()
+	}
+}
+
+// logReload reloads the roots and logs the outcome.
+func (t *TrustedRoots) logReload() {
+	changed, err := t.Reload()
+
+	if err != nil {
+		glog.Warningf("Failed to reload roots from %s, keeping the current roots: %v", t.path, err)
+		return
+	}
+
+	if changed {
+		glog.Infof("Reloaded %d roots from %s", len(t.Pool().RawCertificates()), t.path)
+	}
+}
+
+// Stats returns the number of roots, the number of reloads that changed them and the number of
+// reloads that failed.
+func (t *TrustedRoots) Stats() (roots int, reloads, reloadFailures int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.pool.RawCertificates()), t.reloads, t.reloadFailures
+}
+
+// writeFileAtomically replaces the contents of path with data so that readers see either the
+// old or the new contents, never a partial file.
+func writeFileAtomically(path string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+
+	if err != nil {
+		return err
+	}
+
+	// Keep the permissions of the file being replaced rather than those of a new temp file
+	if info, err := os.Stat(path); err == nil {
+		if err := f.Chmod(info.Mode()); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return err
+		}
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	// Flush the new contents to disk first so a crash can't leave path renamed to an empty or
+	// partial file
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return nil
+}
+
+// adminHandler only passes on requests to an admin endpoint that present the admin token. If
+// the token is empty every request is rejected, rather than letting anyone in.
+type adminHandler struct {
+	token   string
+	handler http.Handler
+}
+
+func (h adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get(authorizationHeader)
+
+	if h.token == "" || !strings.HasPrefix(auth, bearerPrefix) || subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, bearerPrefix)), []byte(h.token)) != 1 {
+		glog.Warningf("Rejected unauthenticated admin request for %s from %s", r.URL.Path, r.RemoteAddr)
+		sendHttpError(w, http.StatusUnauthorized, errors.New("missing or incorrect admin token"))
+		return
+	}
+
+	h.handler.ServeHTTP(w, r)
+}
+
+// decodeAdminRequest strictly decodes the body of an admin request to endpoint into req, see
+// requestSchema
+func decodeAdminRequest(r *http.Request, endpoint string, req interface{}) error {
+	body, err := ioutil.ReadAll(r.Body)
+
+	if err != nil {
+		return fmt.Errorf("failed to read request body: %v", err)
+	}
+
+	return requestSchemas[endpoint].decode(body, req)
+}
+
+// writeAdminResponse writes the outcome of a change to roots
+func writeAdminResponse(w http.ResponseWriter, roots *TrustedRoots, changed bool) (int, error) {
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	resp := rootsAdminResponse{Changed: changed, RootCount: len(roots.Pool().RawCertificates())}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("failed to write admin response: %v", err)
+	}
+
+	return http.StatusOK, nil
+}
+
+// wrappedAddRootHandler adds the root in the request to the log's trusted roots
+func wrappedAddRootHandler(roots *TrustedRoots) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		if !enforceMethod(w, r, httpMethodPost) {
+			return http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method)
+		}
+
+		var req addRootRequest
+		if err := decodeAdminRequest(r, "add-root", &req); err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		cert, err := x509.ParseCertificate(req.Certificate)
+
+		if err != nil {
+			return http.StatusBadRequest, fmt.Errorf("failed to parse certificate: %v", err)
+		}
+
+		if !cert.IsCA {
+			return http.StatusBadRequest, errors.New("certificate is not a CA certificate")
+		}
+
+		changed, err := roots.Add(cert)
+
+		if err != nil {
+			return http.StatusInternalServerError, fmt.Errorf("failed to add root: %v", err)
+		}
+
+		glog.Infof("Roots admin added root %x: %v", sha256.Sum256(cert.Raw), changed)
+
+		return writeAdminResponse(w, roots, changed)
+	}
+}
+
+// wrappedRemoveRootHandler removes the root with the fingerprint in the request from the log's
+// trusted roots
+func wrappedRemoveRootHandler(roots *TrustedRoots) appHandler {
+	return func(w http.ResponseWriter, r *http.Request) (int, error) {
+		if !enforceMethod(w, r, httpMethodPost) {
+			return http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method)
+		}
+
+		var req removeRootRequest
+		if err := decodeAdminRequest(r, "remove-root", &req); err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		var fingerprint [sha256.Size]byte
+		decoded, err := hex.DecodeString(req.SHA256Fingerprint)
+
+		if err != nil || len(decoded) != len(fingerprint) {
+			return http.StatusBadRequest, fmt.Errorf("invalid SHA-256 fingerprint: %q", req.SHA256Fingerprint)
+		}
+
+		copy(fingerprint[:], decoded)
+		changed, err := roots.Remove(fingerprint)
+
+		if err != nil {
+			return http.StatusBadRequest, fmt.Errorf("failed to remove root: %v", err)
+		}
+
+		glog.Infof("Roots admin removed root %x: %v", fingerprint, changed)
+
+		return writeAdminResponse(w, roots, changed)
+	}
+}
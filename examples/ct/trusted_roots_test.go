@@ -0,0 +1,260 @@
+package ct
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/certificate-transparency/go/x509"
+	"github.com/google/trillian/examples/ct/testonly"
+)
+
+// parsePEMCertOrDie returns the first certificate in pemData
+func parsePEMCertOrDie(t *testing.T, pemData string) *x509.Certificate {
+	block, _ := pem.Decode([]byte(pemData))
+
+	if block == nil {
+		t.Fatalf("No PEM block in: %s", pemData)
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+
+	return cert
+}
+
+// trustedRootsForTest returns TrustedRoots holding the fake CA cert, saved in a roots file
+// under dir
+func trustedRootsForTest(t *testing.T, dir string) (*TrustedRoots, string) {
+	path := filepath.Join(dir, "roots.pem")
+
+	if err := ioutil.WriteFile(path, []byte(testonly.FakeCACertPem), 0644); err != nil {
+		t.Fatalf("Failed to write roots file: %v", err)
+	}
+
+	return NewTrustedRoots(loadCertsIntoPoolOrDie(t, []string{testonly.FakeCACertPem}), path), path
+}
+
+func TestTrustedRootsAddAndRemove(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trustedroots")
+
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	roots, path := trustedRootsForTest(t, dir)
+	ca := parsePEMCertOrDie(t, testonly.FakeCACertPem)
+	intermediate := parsePEMCertOrDie(t, testonly.FakeIntermediateCertPem)
+	before := roots.Pool()
+
+	// checkRoots checks the pool in memory and the one that would be loaded from the roots
+	// file hold want
+	checkRoots := func(want ...*x509.Certificate) {
+		data, err := ioutil.ReadFile(path)
+
+		if err != nil {
+			t.Fatalf("Failed to read roots file: %v", err)
+		}
+
+		for _, pool := range []*PEMCertPool{roots.Pool(), loadCertsIntoPoolOrDie(t, []string{string(data)})} {
+			got := pool.RawCertificates()
+
+			if len(got) != len(want) {
+				t.Fatalf("Got %d roots, expected %d", len(got), len(want))
+			}
+
+			for i := range want {
+				if !bytes.Equal(got[i].Raw, want[i].Raw) {
+					t.Fatalf("Root %d mismatched, got %s expected %s", i, got[i].Subject.CommonName, want[i].Subject.CommonName)
+				}
+			}
+		}
+	}
+
+	if added, err := roots.Add(intermediate); !added || err != nil {
+		t.Fatalf("Add()=%v,%v, expected true,nil", added, err)
+	}
+
+	checkRoots(ca, intermediate)
+
+	// Pools that were handed out aren't changed
+	if got, want := len(before.RawCertificates()), 1; got != want {
+		t.Fatalf("Earlier pool changed to hold %d roots, expected %d", got, want)
+	}
+
+	if added, err := roots.Add(intermediate); added || err != nil {
+		t.Fatalf("Add() of existing root=%v,%v, expected false,nil", added, err)
+	}
+
+	if removed, err := roots.Remove(sha256.Sum256(ca.Raw)); !removed || err != nil {
+		t.Fatalf("Remove()=%v,%v, expected true,nil", removed, err)
+	}
+
+	checkRoots(intermediate)
+
+	if removed, err := roots.Remove(sha256.Sum256(ca.Raw)); removed || err != nil {
+		t.Fatalf("Remove() of unknown root=%v,%v, expected false,nil", removed, err)
+	}
+
+	if _, err := roots.Remove(sha256.Sum256(intermediate.Raw)); err == nil {
+		t.Fatal("Removed the only root")
+	}
+
+	checkRoots(intermediate)
+}
+
+func TestRootsAdminHandlers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trustedroots")
+
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	roots, _ := trustedRootsForTest(t, dir)
+	ca := parsePEMCertOrDie(t, testonly.FakeCACertPem)
+	intermediate := parsePEMCertOrDie(t, testonly.FakeIntermediateCertPem)
+	leaf := parsePEMCertOrDie(t, testonly.LeafSignedByFakeIntermediateCertPem)
+	addRoot := adminHandler{token: "secret", handler: wrappedAddRootHandler(roots)}
+	removeRoot := adminHandler{token: "secret", handler: wrappedRemoveRootHandler(roots)}
+	noTokenAddRoot := adminHandler{token: "", handler: wrappedAddRootHandler(roots)}
+
+	var tests = []struct {
+		name    string
+		handler http.Handler
+		method  string
+		auth    string
+		body    string
+		status  int
+		roots   int
+	}{
+		{"NoToken", addRoot, "POST", "", fmt.Sprintf(`{"certificate":"%s"}`, base64.StdEncoding.EncodeToString(intermediate.Raw)), http.StatusUnauthorized, 1},
+		{"EmptyAdminToken", noTokenAddRoot, "POST", "Bearer ", fmt.Sprintf(`{"certificate":"%s"}`, base64.StdEncoding.EncodeToString(intermediate.Raw)), http.StatusUnauthorized, 1},
+		{"WrongToken", addRoot, "POST", "Bearer guess", fmt.Sprintf(`{"certificate":"%s"}`, base64.StdEncoding.EncodeToString(intermediate.Raw)), http.StatusUnauthorized, 1},
+		{"WrongMethod", addRoot, "GET", "Bearer secret", "", http.StatusMethodNotAllowed, 1},
+		{"NotCA", addRoot, "POST", "Bearer secret", fmt.Sprintf(`{"certificate":"%s"}`, base64.StdEncoding.EncodeToString(leaf.Raw)), http.StatusBadRequest, 1},
+		{"UnknownField", addRoot, "POST", "Bearer secret", `{"cert":""}`, http.StatusBadRequest, 1},
+		{"Add", addRoot, "POST", "Bearer secret", fmt.Sprintf(`{"certificate":"%s"}`, base64.StdEncoding.EncodeToString(intermediate.Raw)), http.StatusOK, 2},
+		{"BadFingerprint", removeRoot, "POST", "Bearer secret", `{"sha256_fingerprint":"abcd"}`, http.StatusBadRequest, 2},
+		{"Remove", removeRoot, "POST", "Bearer secret", fmt.Sprintf(`{"sha256_fingerprint":"%x"}`, sha256.Sum256(ca.Raw)), http.StatusOK, 1},
+		{"RemoveLast", removeRoot, "POST", "Bearer secret", fmt.Sprintf(`{"sha256_fingerprint":"%x"}`, sha256.Sum256(intermediate.Raw)), http.StatusBadRequest, 1},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest(test.method, "http://example.com/admin/root", bytes.NewBufferString(test.body))
+
+		if err != nil {
+			t.Fatalf("%s: failed to create request: %v", test.name, err)
+		}
+
+		if len(test.auth) > 0 {
+			req.Header.Set(authorizationHeader, test.auth)
+		}
+
+		w := httptest.NewRecorder()
+		test.handler.ServeHTTP(w, req)
+
+		if got, want := w.Code, test.status; got != want {
+			t.Errorf("%s: got status %d, expected %d: %s", test.name, got, want, w.Body.String())
+		}
+
+		if got, want := len(roots.Pool().RawCertificates()), test.roots; got != want {
+			t.Errorf("%s: got %d roots, expected %d", test.name, got, want)
+		}
+	}
+}
+
+func TestTrustedRootsReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trustedroots")
+
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	roots, path := trustedRootsForTest(t, dir)
+	before := roots.Pool()
+
+	if changed, err := roots.Reload(); changed || err != nil {
+		t.Fatalf("Reload() of an unchanged file = %v, %v, expected false, nil", changed, err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(testonly.FakeCACertPem+testonly.FakeIntermediateCertPem), 0644); err != nil {
+		t.Fatalf("Failed to write roots file: %v", err)
+	}
+
+	if changed, err := roots.Reload(); !changed || err != nil {
+		t.Fatalf("Reload() of an edited file = %v, %v, expected true, nil", changed, err)
+	}
+
+	if got := len(roots.Pool().RawCertificates()); got != 2 {
+		t.Errorf("Got %d roots after reload, expected 2", got)
+	}
+
+	// A request that got the pool before the reload keeps seeing the same roots
+	if got := len(before.RawCertificates()); got != 1 {
+		t.Errorf("Earlier pool has %d roots after reload, expected 1", got)
+	}
+
+	// A broken file leaves the roots as they were
+	if err := ioutil.WriteFile(path, []byte("not PEM"), 0644); err != nil {
+		t.Fatalf("Failed to write roots file: %v", err)
+	}
+
+	if _, err := roots.Reload(); err == nil {
+		t.Error("Reload() of a file without roots succeeded")
+	}
+
+	if count, reloads, failures := roots.Stats(); count != 2 || reloads != 1 || failures != 1 {
+		t.Errorf("Got %d roots, %d reloads and %d failures, expected 2, 1 and 1", count, reloads, failures)
+	}
+}
+
+func TestTrustedRootsReloadClearsChainCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trustedroots")
+
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	roots, path := trustedRootsForTest(t, dir)
+	cache, err := NewChainCache(10, time.Hour, fakeTimeSource)
+
+	if err != nil {
+		t.Fatalf("NewChainCache()=%v", err)
+	}
+
+	NewCTRequestHandlers(0x42, nil, nil, nil, WithChainCache(cache), WithLiveRoots(roots))
+	cache.put(chainFingerprint{1})
+
+	if err := ioutil.WriteFile(path, []byte(testonly.FakeIntermediateCertPem), 0644); err != nil {
+		t.Fatalf("Failed to write roots file: %v", err)
+	}
+
+	if _, err := roots.Reload(); err != nil {
+		t.Fatalf("Reload()=%v", err)
+	}
+
+	// Chains verified to the old roots must be verified again
+	if got := cache.Len(); got != 0 {
+		t.Errorf("Chain cache holds %d chains after the roots changed, expected 0", got)
+	}
+}
@@ -0,0 +1,159 @@
+package tsa
+
+import (
+	gocrypto "crypto"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"time"
+)
+
+// The ASN.1 structures of RFC 3161 and the parts of CMS (RFC 5652) needed to sign and read
+// time stamp tokens. Fields that this package never uses are left out where the encoding
+// allows it.
+
+var (
+	oidSignedData      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidContentType     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningCertV2   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	oidSHA256          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+)
+
+// messageImprintHashes are the hashes of the data being timestamped that requests can use
+var messageImprintHashes = map[string]gocrypto.Hash{
+	oidSHA256.String(): gocrypto.SHA256,
+	oidSHA384.String(): gocrypto.SHA384,
+	oidSHA512.String(): gocrypto.SHA512,
+}
+
+// PKIStatus values of RFC 3161 section 2.4.2
+const (
+	statusGranted  = 0
+	statusRejected = 2
+)
+
+// PKIFailureInfo bits of RFC 3161 section 2.4.2
+const (
+	failBadAlg              = 0
+	failBadRequest          = 2
+	failBadDataFormat       = 5
+	failUnacceptedPolicy    = 15
+	failUnacceptedExtension = 16
+	failSystemFailure       = 25
+)
+
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional"`
+	Extensions     []pkix.Extension      `asn1:"optional,tag:0"`
+}
+
+type pkiStatusInfo struct {
+	Status       int
+	StatusString []string       `asn1:"optional"`
+	FailInfo     asn1.BitString `asn1:"optional"`
+}
+
+type timeStampResp struct {
+	Status pkiStatusInfo
+	// TimeStampToken is a ContentInfo, and is only present if the status is granted
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+	Accuracy       accuracy  `asn1:"optional"`
+	Ordering       bool      `asn1:"optional"`
+	Nonce          *big.Int  `asn1:"optional"`
+}
+
+// contentInfo holds its content in an explicit [0] tag, which is built and removed by hand
+// as encoding/asn1 ignores tags on RawValues when marshalling.
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+// encapsulatedContentInfo holds eContent, an OCTET STRING, in an explicit [0] tag like
+// contentInfo.
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     asn1.RawValue
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapsulatedContentInfo
+	Certificates     []asn1.RawValue `asn1:"optional,tag:0"`
+	SignerInfos      []signerInfo    `asn1:"set"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type signerInfo struct {
+	Version         int
+	SID             issuerAndSerialNumber
+	DigestAlgorithm pkix.AlgorithmIdentifier
+	// SignedAttrs is the implicitly tagged [0] SET of attributes. What's signed is the same
+	// SET with its universal tag.
+	SignedAttrs        asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+// essCertIDv2 identifies the signing certificate, its hash algorithm is the default SHA-256
+// so it's left out.
+type essCertIDv2 struct {
+	CertHash []byte
+}
+
+type signingCertificateV2 struct {
+	Certs []essCertIDv2
+}
+
+// explicitTag wraps DER in an explicit context specific tag.
+func explicitTag(tag int, der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: tag, IsCompound: true, Bytes: der}
+}
+
+// failureInfo returns a PKIFailureInfo with one bit set.
+func failureInfo(bit int) asn1.BitString {
+	bytes := make([]byte, bit/8+1)
+	bytes[bit/8] = 0x80 >> uint(bit%8)
+	return asn1.BitString{Bytes: bytes, BitLength: bit + 1}
+}
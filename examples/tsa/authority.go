@@ -0,0 +1,312 @@
+package tsa
+
+import (
+	gocrypto "crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+// ErrNotIntegrated is returned for inclusion proofs of tokens that the log hasn't integrated
+// yet. Clients should try again after the log's maximum merge delay.
+var ErrNotIntegrated = errors.New("token is not in the log yet")
+
+// invalidTokenError is returned for inclusion proofs of tokens that weren't issued by the
+// authority, as opposed to failures to get the proof.
+type invalidTokenError struct {
+	err error
+}
+
+func (e invalidTokenError) Error() string {
+	return e.err.Error()
+}
+
+// maxSerialNumber bounds the random token serial numbers, RFC 3161 allows up to 160 bits
+var maxSerialNumber = new(big.Int).Lsh(big.NewInt(1), 128)
+
+// SignedTreeHead is a root of an authority's log, signed with the authority's key so clients
+// that trust its certificate can check proofs against it.
+type SignedTreeHead struct {
+	TreeSize       int64  `json:"tree_size"`
+	TimestampNanos int64  `json:"timestamp_nanos"`
+	RootHash       []byte `json:"root_hash"`
+	// Signature is over the SHA-256 digest of the DER encoding of the other fields, as an
+	// ASN.1 SEQUENCE in the same order
+	Signature []byte `json:"signature"`
+}
+
+type treeHeadTBS struct {
+	TreeSize       int64
+	TimestampNanos int64
+	RootHash       []byte
+}
+
+// InclusionProof shows that a token is in an authority's log.
+type InclusionProof struct {
+	LeafIndex int64          `json:"leaf_index"`
+	AuditPath [][]byte       `json:"audit_path"`
+	TreeHead  SignedTreeHead `json:"tree_head"`
+}
+
+// Authority issues RFC 3161 time stamp tokens and records each of them in a Trillian log.
+type Authority struct {
+	logID       int64
+	rpcClient   trillian.TrillianLogClient
+	signer      gocrypto.Signer
+	cert        *x509.Certificate
+	policy      asn1.ObjectIdentifier
+	timeSource  util.TimeSource
+	rpcDeadline time.Duration
+}
+
+// NewAuthority creates an Authority that queues the tokens it issues in the log logID via
+// client. Tokens are signed with signer, whose certificate cert must be for time stamping,
+// and are issued under policy.
+func NewAuthority(logID int64, client trillian.TrillianLogClient, signer gocrypto.Signer, cert *x509.Certificate, policy asn1.ObjectIdentifier, timeSource util.TimeSource, rpcDeadline time.Duration) (*Authority, error) {
+	if !reflect.DeepEqual(signer.Public(), cert.PublicKey) {
+		return nil, errors.New("certificate is not for the signing key")
+	}
+
+	if _, _, err := signatureAlgorithm(cert.PublicKey); err != nil {
+		return nil, err
+	}
+
+	// RFC 3161 section 2.3 requires the key to be used only for time stamping
+	if len(cert.ExtKeyUsage) != 1 || cert.ExtKeyUsage[0] != x509.ExtKeyUsageTimeStamping {
+		return nil, errors.New("certificate's extended key usage must be time stamping only")
+	}
+
+	if len(policy) == 0 {
+		return nil, errors.New("a policy must be given")
+	}
+
+	return &Authority{logID: logID, rpcClient: client, signer: signer, cert: cert, policy: policy, timeSource: timeSource, rpcDeadline: rpcDeadline}, nil
+}
+
+// Timestamp handles the DER encoded TimeStampReq request and returns the DER of the
+// TimeStampResp. Requests that can't be granted get a response with a rejection status
+// rather than an error, tokens are only granted once they've been queued in the log.
+func (a *Authority) Timestamp(ctx context.Context, request []byte) ([]byte, error) {
+	var req timeStampReq
+
+	if err := unmarshalAll(request, &req); err != nil {
+		return rejection(failBadDataFormat, fmt.Sprintf("invalid request: %v", err))
+	}
+
+	if req.Version != 1 {
+		return rejection(failBadRequest, fmt.Sprintf("unsupported request version: %d", req.Version))
+	}
+
+	hash, ok := messageImprintHashes[req.MessageImprint.HashAlgorithm.Algorithm.String()]
+
+	if !ok {
+		return rejection(failBadAlg, fmt.Sprintf("unsupported hash algorithm: %v", req.MessageImprint.HashAlgorithm.Algorithm))
+	}
+
+	if got, want := len(req.MessageImprint.HashedMessage), hash.Size(); got != want {
+		return rejection(failBadDataFormat, fmt.Sprintf("hashed message is %d bytes, expected %d", got, want))
+	}
+
+	if len(req.ReqPolicy) > 0 && !req.ReqPolicy.Equal(a.policy) {
+		return rejection(failUnacceptedPolicy, fmt.Sprintf("unsupported policy: %v", req.ReqPolicy))
+	}
+
+	if len(req.Extensions) > 0 {
+		return rejection(failUnacceptedExtension, "extensions are not supported")
+	}
+
+	serial, err := rand.Int(rand.Reader, maxSerialNumber)
+
+	if err != nil {
+		glog.Warningf("Failed to generate serial number: %v", err)
+		return rejection(failSystemFailure, "")
+	}
+
+	// GeneralizedTime is encoded to the second, the log's integration time is more precise
+	info, err := asn1.Marshal(tstInfo{
+		Version:        1,
+		Policy:         a.policy,
+		MessageImprint: req.MessageImprint,
+		SerialNumber:   serial,
+		GenTime:        a.timeSource.Now().UTC(),
+		Accuracy:       accuracy{Seconds: 1},
+		Nonce:          req.Nonce,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := signToken(info, a.signer, a.cert, req.CertReq)
+
+	if err != nil {
+		glog.Warningf("Failed to sign token: %v", err)
+		return rejection(failSystemFailure, "")
+	}
+
+	if err := a.queueToken(ctx, info, token); err != nil {
+		glog.Warningf("Failed to queue token %v: %v", serial, err)
+		return rejection(failSystemFailure, "the token could not be logged")
+	}
+
+	return asn1.Marshal(timeStampResp{Status: pkiStatusInfo{Status: statusGranted}, TimeStampToken: asn1.RawValue{FullBytes: token}})
+}
+
+// queueToken queues a token's TSTInfo as a leaf of the log, with the whole token as its
+// extra data.
+func (a *Authority) queueToken(ctx context.Context, info, token []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, a.rpcDeadline)
+	defer cancel()
+
+	leaf := trillian.LeafProto{LeafHash: leafHash(info), LeafData: info, ExtraData: token}
+	resp, err := a.rpcClient.QueueLeaves(ctx, &trillian.QueueLeavesRequest{LogId: a.logID, Leaves: []*trillian.LeafProto{&leaf}})
+
+	if err != nil {
+		return err
+	}
+
+	if !rpcStatusOK(resp.GetStatus()) {
+		return fmt.Errorf("backend rejected leaf: %v", resp.GetStatus())
+	}
+
+	return nil
+}
+
+// InclusionProof returns a proof that a token issued by the authority is in the tree of its
+// log's latest root. ErrNotIntegrated is returned if the log hasn't integrated it yet.
+func (a *Authority) InclusionProof(ctx context.Context, token []byte) (InclusionProof, error) {
+	info, err := VerifyToken(token, a.cert)
+
+	if err != nil {
+		return InclusionProof{}, invalidTokenError{err}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, a.rpcDeadline)
+	defer cancel()
+
+	rootResp, err := a.rpcClient.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: a.logID})
+
+	if err != nil {
+		return InclusionProof{}, err
+	}
+
+	root := rootResp.GetSignedLogRoot()
+
+	if !rpcStatusOK(rootResp.GetStatus()) || root == nil {
+		return InclusionProof{}, errors.New("backend failed to get the latest root")
+	}
+
+	if root.TreeSize == 0 {
+		return InclusionProof{}, ErrNotIntegrated
+	}
+
+	proofResp, err := a.rpcClient.GetInclusionProofByHash(ctx, &trillian.GetInclusionProofByHashRequest{LogId: a.logID, LeafHash: leafHash(info), TreeSize: root.TreeSize, OrderBySequence: true})
+
+	if err != nil {
+		return InclusionProof{}, err
+	}
+
+	if !rpcStatusOK(proofResp.GetStatus()) {
+		return InclusionProof{}, errors.New("backend failed to get the proof")
+	}
+
+	// Serial numbers make every TSTInfo different, so there's at most one proof
+	if len(proofResp.Proof) == 0 {
+		return InclusionProof{}, ErrNotIntegrated
+	}
+
+	proof := InclusionProof{LeafIndex: proofResp.Proof[0].LeafIndex}
+
+	for _, node := range proofResp.Proof[0].ProofNode {
+		proof.AuditPath = append(proof.AuditPath, node.NodeHash)
+	}
+
+	if proof.TreeHead, err = a.signTreeHead(root); err != nil {
+		return InclusionProof{}, err
+	}
+
+	return proof, nil
+}
+
+// signTreeHead signs a root of the log with the authority's key.
+func (a *Authority) signTreeHead(root *trillian.SignedLogRoot) (SignedTreeHead, error) {
+	tbs, err := asn1.Marshal(treeHeadTBS{TreeSize: root.TreeSize, TimestampNanos: root.TimestampNanos, RootHash: root.RootHash})
+
+	if err != nil {
+		return SignedTreeHead{}, err
+	}
+
+	digest := sha256.Sum256(tbs)
+	signature, err := a.signer.Sign(rand.Reader, digest[:], gocrypto.SHA256)
+
+	if err != nil {
+		return SignedTreeHead{}, fmt.Errorf("failed to sign tree head: %v", err)
+	}
+
+	return SignedTreeHead{TreeSize: root.TreeSize, TimestampNanos: root.TimestampNanos, RootHash: root.RootHash, Signature: signature}, nil
+}
+
+// VerifyInclusion checks that a token and the tree head of proof were signed by the authority
+// with cert, and that the proof shows the token is in the tree.
+func VerifyInclusion(token []byte, proof InclusionProof, cert *x509.Certificate) error {
+	info, err := VerifyToken(token, cert)
+
+	if err != nil {
+		return err
+	}
+
+	th := proof.TreeHead
+	tbs, err := asn1.Marshal(treeHeadTBS{TreeSize: th.TreeSize, TimestampNanos: th.TimestampNanos, RootHash: th.RootHash})
+
+	if err != nil {
+		return err
+	}
+
+	_, sigAlg, err := signatureAlgorithm(cert.PublicKey)
+
+	if err != nil {
+		return err
+	}
+
+	if err := cert.CheckSignature(sigAlg, tbs, th.Signature); err != nil {
+		return fmt.Errorf("invalid tree head signature: %v", err)
+	}
+
+	return merkle.VerifyInclusionProof(treeHasher, proof.LeafIndex, th.TreeSize, proof.AuditPath, th.RootHash, leafHash(info))
+}
+
+// treeHasher hashes leaves and nodes the way the log must
+var treeHasher = merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+
+// leafHash returns the hash of a token's leaf in the log.
+func leafHash(info []byte) []byte {
+	return treeHasher.HashLeaf(info)
+}
+
+func rpcStatusOK(status *trillian.TrillianApiStatus) bool {
+	return status != nil && status.StatusCode == trillian.TrillianApiStatusCode_OK
+}
+
+// rejection returns the DER of a response rejecting a request, with reason as its status
+// string if it's set.
+func rejection(failure int, reason string) ([]byte, error) {
+	status := pkiStatusInfo{Status: statusRejected, FailInfo: failureInfo(failure)}
+
+	if len(reason) > 0 {
+		status.StatusString = []string{reason}
+	}
+
+	return asn1.Marshal(timeStampResp{Status: status})
+}
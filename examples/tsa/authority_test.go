@@ -0,0 +1,345 @@
+package tsa
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+var testPolicy = asn1.ObjectIdentifier{1, 2, 3, 4}
+var testTime = time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestKey returns a new key with a self signed certificate for time stamping, or for
+// usage if it's given.
+func newTestKey(t *testing.T, usage ...x509.ExtKeyUsage) (*ecdsa.PrivateKey, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	if len(usage) == 0 {
+		usage = []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping}
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Test TSA"},
+		NotBefore:    testTime.Add(-time.Hour),
+		NotAfter:     testTime.Add(time.Hour),
+		ExtKeyUsage:  usage,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, key.Public(), key)
+
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+
+	return key, cert
+}
+
+func newTestAuthority(t *testing.T, client trillian.TrillianLogClient) (*Authority, *x509.Certificate) {
+	key, cert := newTestKey(t)
+	a, err := NewAuthority(0x42, client, key, cert, testPolicy, util.FakeTimeSource{FakeTime: testTime}, time.Second)
+
+	if err != nil {
+		t.Fatalf("Failed to create authority: %v", err)
+	}
+
+	return a, cert
+}
+
+func newTestRequest(t *testing.T, req timeStampReq) []byte {
+	der, err := asn1.Marshal(req)
+
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+
+	return der
+}
+
+func sha256Request(data string) timeStampReq {
+	digest := sha256.Sum256([]byte(data))
+	return timeStampReq{Version: 1, MessageImprint: messageImprint{HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256}, HashedMessage: digest[:]}}
+}
+
+func parseResponse(t *testing.T, der []byte) timeStampResp {
+	var resp timeStampResp
+
+	if err := unmarshalAll(der, &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	return resp
+}
+
+// issueToken gets a token from a for a request, with the queued leaf captured in leaf.
+func issueToken(t *testing.T, a *Authority, client *trillian.MockTrillianLogClient, req timeStampReq, leaf **trillian.LeafProto) []byte {
+	client.EXPECT().QueueLeaves(gomock.Any(), gomock.Any()).Do(func(ctx context.Context, r *trillian.QueueLeavesRequest, opts ...grpc.CallOption) {
+		*leaf = r.Leaves[0]
+	}).Return(&trillian.QueueLeavesResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}}, nil)
+
+	der, err := a.Timestamp(context.Background(), newTestRequest(t, req))
+
+	if err != nil {
+		t.Fatalf("Timestamp()=%v", err)
+	}
+
+	resp := parseResponse(t, der)
+
+	if resp.Status.Status != statusGranted {
+		t.Fatalf("Token not granted: %+v", resp.Status)
+	}
+
+	return resp.TimeStampToken.FullBytes
+}
+
+func TestNewAuthorityChecksCertificate(t *testing.T) {
+	key, cert := newTestKey(t)
+	otherKey, _ := newTestKey(t)
+	_, serverCert := newTestKey(t, x509.ExtKeyUsageServerAuth)
+
+	for _, test := range []struct {
+		desc   string
+		signer *ecdsa.PrivateKey
+		cert   *x509.Certificate
+		policy asn1.ObjectIdentifier
+	}{
+		{"wrong key", otherKey, cert, testPolicy},
+		{"not for time stamping", key, serverCert, testPolicy},
+		{"no policy", key, cert, nil},
+	} {
+		if _, err := NewAuthority(0x42, nil, test.signer, test.cert, test.policy, util.SystemTimeSource{}, time.Second); err == nil {
+			t.Errorf("%s: NewAuthority() succeeded", test.desc)
+		}
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(ctrl)
+	a, cert := newTestAuthority(t, client)
+
+	for _, certReq := range []bool{false, true} {
+		req := sha256Request("document")
+		req.Nonce = big.NewInt(12345)
+		req.ReqPolicy = testPolicy
+		req.CertReq = certReq
+
+		var leaf *trillian.LeafProto
+		token := issueToken(t, a, client, req, &leaf)
+
+		// The leaf is the TSTInfo, with the whole token alongside it
+		info, err := VerifyToken(token, cert)
+
+		if err != nil {
+			t.Fatalf("VerifyToken()=%v", err)
+		}
+
+		if !bytes.Equal(leaf.LeafData, info) || !bytes.Equal(leaf.ExtraData, token) || !bytes.Equal(leaf.LeafHash, leafHash(info)) {
+			t.Errorf("Queued leaf %v, expected TSTInfo %x and token %x", leaf, info, token)
+		}
+
+		var tst tstInfo
+
+		if err := unmarshalAll(info, &tst); err != nil {
+			t.Fatalf("Failed to parse TSTInfo: %v", err)
+		}
+
+		if !tst.Policy.Equal(testPolicy) || !tst.GenTime.Equal(testTime) || tst.Nonce.Cmp(req.Nonce) != 0 || !bytes.Equal(tst.MessageImprint.HashedMessage, req.MessageImprint.HashedMessage) {
+			t.Errorf("Got TSTInfo %+v for request %+v", tst, req)
+		}
+
+		var ci contentInfo
+		var sd signedData
+
+		if err := unmarshalAll(token, &ci); err != nil {
+			t.Fatalf("Failed to parse token: %v", err)
+		}
+
+		if err := unmarshalAll(ci.Content.Bytes, &sd); err != nil {
+			t.Fatalf("Failed to parse signed data: %v", err)
+		}
+
+		if got, want := len(sd.Certificates) == 1, certReq; got != want {
+			t.Errorf("Certificate included: %v, expected %v", got, want)
+		}
+	}
+}
+
+func TestTimestampRejected(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// No leaves should be queued
+	client := trillian.NewMockTrillianLogClient(ctrl)
+	a, _ := newTestAuthority(t, client)
+
+	badVersion := sha256Request("document")
+	badVersion.Version = 2
+	badAlg := sha256Request("document")
+	badAlg.MessageImprint.HashAlgorithm.Algorithm = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	badLength := sha256Request("document")
+	badLength.MessageImprint.HashedMessage = badLength.MessageImprint.HashedMessage[1:]
+	badPolicy := sha256Request("document")
+	badPolicy.ReqPolicy = asn1.ObjectIdentifier{1, 2, 3, 5}
+	extension := sha256Request("document")
+	extension.Extensions = []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 2, 3, 6}, Value: []byte{5, 0}}}
+
+	for _, test := range []struct {
+		desc    string
+		request []byte
+		failure int
+	}{
+		{"not DER", []byte("request"), failBadDataFormat},
+		{"version", newTestRequest(t, badVersion), failBadRequest},
+		{"hash algorithm", newTestRequest(t, badAlg), failBadAlg},
+		{"hash length", newTestRequest(t, badLength), failBadDataFormat},
+		{"policy", newTestRequest(t, badPolicy), failUnacceptedPolicy},
+		{"extension", newTestRequest(t, extension), failUnacceptedExtension},
+	} {
+		der, err := a.Timestamp(context.Background(), test.request)
+
+		if err != nil {
+			t.Fatalf("%s: Timestamp()=%v", test.desc, err)
+		}
+
+		resp := parseResponse(t, der)
+
+		if resp.Status.Status != statusRejected || resp.Status.FailInfo.At(test.failure) != 1 || len(resp.TimeStampToken.FullBytes) > 0 {
+			t.Errorf("%s: got response %+v, expected rejection with failure %d", test.desc, resp, test.failure)
+		}
+	}
+}
+
+func TestTimestampQueueFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(ctrl)
+	a, _ := newTestAuthority(t, client)
+
+	// Tokens must not be handed out unless they've been logged
+	client.EXPECT().QueueLeaves(gomock.Any(), gomock.Any()).Return(nil, errors.New("RPC"))
+	der, err := a.Timestamp(context.Background(), newTestRequest(t, sha256Request("document")))
+
+	if err != nil {
+		t.Fatalf("Timestamp()=%v", err)
+	}
+
+	if resp := parseResponse(t, der); resp.Status.Status != statusRejected || resp.Status.FailInfo.At(failSystemFailure) != 1 {
+		t.Errorf("Got response %+v, expected system failure", resp)
+	}
+}
+
+func TestInclusionProof(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(ctrl)
+	a, cert := newTestAuthority(t, client)
+
+	var leaf *trillian.LeafProto
+	token := issueToken(t, a, client, sha256Request("document"), &leaf)
+
+	// The token is the first of two leaves in the tree
+	other := treeHasher.HashLeaf([]byte("other"))
+	root := trillian.SignedLogRoot{TreeSize: 2, TimestampNanos: testTime.UnixNano(), RootHash: treeHasher.HashChildren(leaf.LeafHash, other)}
+
+	client.EXPECT().GetLatestSignedLogRoot(gomock.Any(), &trillian.GetLatestSignedLogRootRequest{LogId: 0x42}).Return(&trillian.GetLatestSignedLogRootResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}, SignedLogRoot: &root}, nil)
+	client.EXPECT().GetInclusionProofByHash(gomock.Any(), &trillian.GetInclusionProofByHashRequest{LogId: 0x42, LeafHash: leaf.LeafHash, TreeSize: 2, OrderBySequence: true}).Return(&trillian.GetInclusionProofByHashResponse{
+		Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK},
+		Proof:  []*trillian.ProofProto{{LeafIndex: 0, ProofNode: []*trillian.NodeProto{{NodeHash: other}}}},
+	}, nil)
+
+	proof, err := a.InclusionProof(context.Background(), token)
+
+	if err != nil {
+		t.Fatalf("InclusionProof()=%v", err)
+	}
+
+	if err := VerifyInclusion(token, proof, cert); err != nil {
+		t.Errorf("VerifyInclusion()=%v", err)
+	}
+
+	tampered := proof
+	tampered.TreeHead.RootHash = other
+
+	if err := VerifyInclusion(token, tampered, cert); err == nil {
+		t.Error("VerifyInclusion() accepted a tree head with a changed root")
+	}
+
+	_, otherCert := newTestKey(t)
+
+	if err := VerifyInclusion(token, proof, otherCert); err == nil {
+		t.Error("VerifyInclusion() accepted a token from another authority")
+	}
+}
+
+func TestInclusionProofNotIntegrated(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(ctrl)
+	a, _ := newTestAuthority(t, client)
+
+	var leaf *trillian.LeafProto
+	token := issueToken(t, a, client, sha256Request("document"), &leaf)
+
+	root := trillian.SignedLogRoot{TreeSize: 5, RootHash: []byte("root")}
+	client.EXPECT().GetLatestSignedLogRoot(gomock.Any(), gomock.Any()).Return(&trillian.GetLatestSignedLogRootResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}, SignedLogRoot: &root}, nil)
+	client.EXPECT().GetInclusionProofByHash(gomock.Any(), gomock.Any()).Return(&trillian.GetInclusionProofByHashResponse{Status: &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}}, nil)
+
+	if _, err := a.InclusionProof(context.Background(), token); err != ErrNotIntegrated {
+		t.Errorf("InclusionProof()=%v, expected %v", err, ErrNotIntegrated)
+	}
+}
+
+func TestVerifyTokenRejectsTampering(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	client := trillian.NewMockTrillianLogClient(ctrl)
+	a, cert := newTestAuthority(t, client)
+
+	var leaf *trillian.LeafProto
+	token := issueToken(t, a, client, sha256Request("document"), &leaf)
+
+	// Change a byte of the hashed message, which is in the TSTInfo near the start
+	i := bytes.Index(token, sha256Request("document").MessageImprint.HashedMessage)
+
+	if i < 0 {
+		t.Fatal("Hashed message not found in token")
+	}
+
+	tampered := append([]byte(nil), token...)
+	tampered[i] ^= 1
+
+	if _, err := VerifyToken(tampered, cert); err == nil {
+		t.Error("VerifyToken() accepted a token with a changed message imprint")
+	}
+}
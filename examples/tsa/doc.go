@@ -0,0 +1,15 @@
+/*
+Package tsa is a usage example that implements an RFC 3161 time stamping authority on top of
+a Trillian log server. Every token the authority issues is queued in the log before it's
+returned, so a client can later get an inclusion proof showing the token is in the log and
+can't have been backdated or issued without anyone being able to see it.
+
+The TSTInfo of each token is the log leaf and the whole token is kept as its extra data. The
+log must hash leaves as RFC 6962 does. Tree heads are signed with the authority's own key, so
+clients only need its certificate to check a token, its tree head and the proof that links
+them, see VerifyInclusion.
+
+IMPORTANT: Only code rooted within this part of the tree should refer to RFC 3161. Other parts
+of the system must not assume that the data they're processing is a timestamp.
+*/
+package tsa
@@ -0,0 +1,112 @@
+package tsa
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+const (
+	// TimestampPath serves RFC 3161 requests over HTTP as in section 3.4 of the RFC
+	TimestampPath = "/timestamp"
+	// InclusionProofPath takes a token in the request body and returns an InclusionProof as
+	// JSON, or 404 if the token isn't in the log yet
+	InclusionProofPath = "/inclusion-proof"
+
+	contentTypeHeader         = "Content-Type"
+	contentTypeTimestampQuery = "application/timestamp-query"
+	contentTypeTimestampReply = "application/timestamp-reply"
+	contentTypeJSON           = "application/json"
+
+	// maxRequestBytes bounds request bodies, tokens are a few KB with a certificate
+	maxRequestBytes = 64 * 1024
+)
+
+// RegisterHandlers registers the authority's HTTP endpoints with mux.
+func (a *Authority) RegisterHandlers(mux *http.ServeMux) {
+	mux.HandleFunc(TimestampPath, a.handleTimestamp)
+	mux.HandleFunc(InclusionProofPath, a.handleInclusionProof)
+}
+
+func (a *Authority) handleTimestamp(w http.ResponseWriter, r *http.Request) {
+	body, ok := readPostBody(w, r)
+
+	if !ok {
+		return
+	}
+
+	if r.Header.Get(contentTypeHeader) != contentTypeTimestampQuery {
+		http.Error(w, fmt.Sprintf("content type must be %s", contentTypeTimestampQuery), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	resp, err := a.Timestamp(util.WithRequestPriority(context.Background(), util.PrioritySCT), body)
+
+	if err != nil {
+		glog.Warningf("Failed to build timestamp response: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, contentTypeTimestampReply)
+	w.Write(resp)
+}
+
+func (a *Authority) handleInclusionProof(w http.ResponseWriter, r *http.Request) {
+	body, ok := readPostBody(w, r)
+
+	if !ok {
+		return
+	}
+
+	proof, err := a.InclusionProof(util.WithRequestPriority(context.Background(), util.PriorityProof), body)
+
+	_, invalid := err.(invalidTokenError)
+
+	switch {
+	case invalid:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err == ErrNotIntegrated:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		glog.Warningf("Failed to get inclusion proof: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(proof)
+
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	w.Write(data)
+}
+
+// readPostBody returns the body of a POST request. If the request isn't a POST or the body
+// can't be read an error is sent and ok is false.
+func readPostBody(w http.ResponseWriter, r *http.Request) (body []byte, ok bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return nil, false
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read request: %v", err), http.StatusBadRequest)
+		return nil, false
+	}
+
+	return body, true
+}
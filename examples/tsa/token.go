@@ -0,0 +1,221 @@
+package tsa
+
+import (
+	"bytes"
+	gocrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// signatureAlgorithm returns the CMS signature algorithm used for a key, which is always
+// over a SHA-256 digest.
+func signatureAlgorithm(key gocrypto.PublicKey) (asn1.ObjectIdentifier, x509.SignatureAlgorithm, error) {
+	switch key.(type) {
+	case *ecdsa.PublicKey:
+		return oidECDSAWithSHA256, x509.ECDSAWithSHA256, nil
+	case *rsa.PublicKey:
+		return oidSHA256WithRSA, x509.SHA256WithRSA, nil
+	}
+
+	return nil, x509.UnknownSignatureAlgorithm, fmt.Errorf("unsupported key type: %T", key)
+}
+
+// signToken wraps the DER of a TSTInfo in a CMS SignedData signed by signer, whose
+// certificate is cert, and returns the DER of the ContentInfo, which is the time stamp
+// token. The certificate is included in the token if includeCert is set.
+func signToken(info []byte, signer gocrypto.Signer, cert *x509.Certificate, includeCert bool) ([]byte, error) {
+	sigOID, _, err := signatureAlgorithm(signer.Public())
+
+	if err != nil {
+		return nil, err
+	}
+
+	signedAttrs, err := tokenSignedAttrs(info, cert)
+
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(signedAttrs.FullBytes)
+	signature, err := signer.Sign(rand.Reader, digest[:], gocrypto.SHA256)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign token: %v", err)
+	}
+
+	eContent, err := asn1.Marshal(info)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sha256ID := pkix.AlgorithmIdentifier{Algorithm: oidSHA256}
+	sd := signedData{
+		// Version 3 as the content isn't id-data
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{sha256ID},
+		EncapContentInfo: encapsulatedContentInfo{EContentType: oidTSTInfo, EContent: explicitTag(0, eContent)},
+		SignerInfos: []signerInfo{{
+			Version:         1,
+			SID:             issuerAndSerialNumber{Issuer: asn1.RawValue{FullBytes: cert.RawIssuer}, SerialNumber: cert.SerialNumber},
+			DigestAlgorithm: sha256ID,
+			// The attributes are signed as a SET but sent as [0]
+			SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedAttrs.Bytes},
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: sigOID},
+			Signature:          signature,
+		}},
+	}
+
+	if includeCert {
+		sd.Certificates = []asn1.RawValue{{FullBytes: cert.Raw}}
+	}
+
+	sdDER, err := asn1.Marshal(sd)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(contentInfo{ContentType: oidSignedData, Content: explicitTag(0, sdDER)})
+}
+
+// tokenSignedAttrs returns the SET of attributes signed in a token for info: its content
+// type, its digest and the hash of the signing certificate.
+func tokenSignedAttrs(info []byte, cert *x509.Certificate) (asn1.RawValue, error) {
+	contentType, err := asn1.Marshal(oidTSTInfo)
+
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+
+	infoDigest := sha256.Sum256(info)
+	messageDigest, err := asn1.Marshal(infoDigest[:])
+
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+
+	certHash := sha256.Sum256(cert.Raw)
+	signingCert, err := asn1.Marshal(signingCertificateV2{Certs: []essCertIDv2{{CertHash: certHash[:]}}})
+
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+
+	var attrs [][]byte
+
+	for _, a := range []attribute{
+		{Type: oidContentType, Values: []asn1.RawValue{{FullBytes: contentType}}},
+		{Type: oidMessageDigest, Values: []asn1.RawValue{{FullBytes: messageDigest}}},
+		{Type: oidSigningCertV2, Values: []asn1.RawValue{{FullBytes: signingCert}}},
+	} {
+		der, err := asn1.Marshal(a)
+
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+
+		attrs = append(attrs, der)
+	}
+
+	// DER orders the members of a SET OF by their encodings
+	sort.Sort(byEncoding(attrs))
+	der, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: bytes.Join(attrs, nil)})
+
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+
+	var set asn1.RawValue
+	_, err = asn1.Unmarshal(der, &set)
+	return set, err
+}
+
+type byEncoding [][]byte
+
+func (b byEncoding) Len() int           { return len(b) }
+func (b byEncoding) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byEncoding) Less(i, j int) bool { return bytes.Compare(b[i], b[j]) < 0 }
+
+// VerifyToken checks that a time stamp token was signed with cert's key, as it would be
+// by an Authority using cert, and returns the DER of its TSTInfo, which is the token's leaf
+// in the log.
+func VerifyToken(token []byte, cert *x509.Certificate) ([]byte, error) {
+	var ci contentInfo
+
+	if err := unmarshalAll(token, &ci); err != nil {
+		return nil, fmt.Errorf("invalid token: %v", err)
+	}
+
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("token has content type %v, expected signed data", ci.ContentType)
+	}
+
+	var sd signedData
+
+	if err := unmarshalAll(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("invalid signed data: %v", err)
+	}
+
+	if !sd.EncapContentInfo.EContentType.Equal(oidTSTInfo) {
+		return nil, fmt.Errorf("token has content type %v, expected TSTInfo", sd.EncapContentInfo.EContentType)
+	}
+
+	var info []byte
+
+	if err := unmarshalAll(sd.EncapContentInfo.EContent.Bytes, &info); err != nil {
+		return nil, fmt.Errorf("invalid TSTInfo: %v", err)
+	}
+
+	if len(sd.SignerInfos) != 1 {
+		return nil, fmt.Errorf("token has %d signers, expected 1", len(sd.SignerInfos))
+	}
+
+	si := sd.SignerInfos[0]
+	_, sigAlg, err := signatureAlgorithm(cert.PublicKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The attributes must be the ones that would be signed for this TSTInfo and certificate,
+	// which means the signature covers both
+	want, err := tokenSignedAttrs(info, cert)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(si.SignedAttrs.Bytes, want.Bytes) {
+		return nil, errors.New("token's signed attributes don't match its content and certificate")
+	}
+
+	if err := cert.CheckSignature(sigAlg, want.FullBytes, si.Signature); err != nil {
+		return nil, fmt.Errorf("invalid token signature: %v", err)
+	}
+
+	return info, nil
+}
+
+// unmarshalAll parses DER into val, which must use all of it.
+func unmarshalAll(der []byte, val interface{}) error {
+	rest, err := asn1.Unmarshal(der, val)
+
+	if err != nil {
+		return err
+	}
+
+	if len(rest) > 0 {
+		return fmt.Errorf("%d bytes of trailing data", len(rest))
+	}
+
+	return nil
+}
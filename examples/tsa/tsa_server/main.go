@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/examples/tsa"
+	"github.com/google/trillian/util"
+	"google.golang.org/grpc"
+)
+
+var logIDFlag = flag.Int64("log_id", 1, "The log id (tree id) that tokens are recorded in. The log must use RFC 6962 leaf hashes")
+var rpcBackendFlag = flag.String("log_rpc_backend", "localhost:8090", "Backend Log RPC server to use")
+var rpcDeadlineFlag = flag.Duration("rpc_deadline", time.Second*10, "Deadline for backend RPC requests")
+var serverPortFlag = flag.Int("port", 8092, "Port to serve time stamp requests on")
+var privateKeyPEMFlag = flag.String("private_key", "", "PEM file containing the authority's private key")
+var privateKeyPasswordFlag = flag.String("private_key_password", "", "Password for the authority's private key")
+var certificatePEMFlag = flag.String("certificate", "", "PEM file containing the authority's certificate, which must be for time stamping only")
+var policyFlag = flag.String("policy", "", "The OID of the policy tokens are issued under, in dotted form, e.g. 1.2.3.4")
+
+// parseOID parses a dotted object identifier.
+func parseOID(dotted string) (asn1.ObjectIdentifier, error) {
+	var oid asn1.ObjectIdentifier
+
+	for _, s := range strings.Split(dotted, ".") {
+		n, err := strconv.Atoi(s)
+
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid OID: %q", dotted)
+		}
+
+		oid = append(oid, n)
+	}
+
+	if len(oid) < 2 {
+		return nil, fmt.Errorf("invalid OID: %q", dotted)
+	}
+
+	return oid, nil
+}
+
+func loadCertificate(path string) (*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no PEM certificate found")
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func main() {
+	flag.Parse()
+
+	policy, err := parseOID(*policyFlag)
+
+	if err != nil {
+		glog.Fatalf("Invalid --policy: %v", err)
+	}
+
+	keyManager, err := crypto.LoadPasswordProtectedPrivateKey(*privateKeyPEMFlag, *privateKeyPasswordFlag)
+
+	if err != nil {
+		glog.Fatalf("Failed to load private key: %v", err)
+	}
+
+	signer, err := keyManager.Signer()
+
+	if err != nil {
+		glog.Fatalf("Failed to get signer: %v", err)
+	}
+
+	cert, err := loadCertificate(*certificatePEMFlag)
+
+	if err != nil {
+		glog.Fatalf("Failed to load certificate: %v", err)
+	}
+
+	// Uses a blocking connection so we don't start serving before we're connected to backend
+	conn, err := grpc.Dial(*rpcBackendFlag, grpc.WithInsecure(), grpc.WithBlock())
+
+	if err != nil {
+		glog.Fatalf("Could not connect to rpc server: %v", err)
+	}
+
+	defer conn.Close()
+
+	authority, err := tsa.NewAuthority(*logIDFlag, trillian.NewTrillianLogClient(conn), signer, cert, policy, util.SystemTimeSource{}, *rpcDeadlineFlag)
+
+	if err != nil {
+		glog.Fatalf("Invalid authority configuration: %v", err)
+	}
+
+	authority.RegisterHandlers(http.DefaultServeMux)
+
+	// Bring up the HTTP server and serve until we get a signal not to.
+	glog.Warningf("Server exited: %v", http.ListenAndServe(fmt.Sprintf("localhost:%d", *serverPortFlag), nil))
+}
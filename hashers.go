@@ -3,7 +3,10 @@ package trillian
 import (
 	"crypto"
 	_ "crypto/sha256"
+	_ "crypto/sha512"
 	"fmt"
+
+	_ "golang.org/x/crypto/sha3"
 )
 
 // Hasher is the interface which must be implemented by hashers.
@@ -12,10 +15,16 @@ type Hasher struct {
 	alg HashAlgorithm
 }
 
+// NewHasher returns a Hasher for alg, which can be any of the algorithms a tree can be created
+// with.
 func NewHasher(alg HashAlgorithm) (Hasher, error) {
 	switch alg {
 	case HashAlgorithm_SHA256:
 		return Hasher{crypto.SHA256, alg}, nil
+	case HashAlgorithm_SHA512_256:
+		return Hasher{crypto.SHA512_256, alg}, nil
+	case HashAlgorithm_SHA3_256:
+		return Hasher{crypto.SHA3_256, alg}, nil
 	}
 	return Hasher{}, fmt.Errorf("unsupported hash algorithm %v", alg)
 }
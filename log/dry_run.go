@@ -0,0 +1,124 @@
+package log
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+// DryRunReport describes a batch sequenced by DryRunBatch. Nothing it counts was committed.
+type DryRunReport struct {
+	// Leaves is the number of leaves dequeued and given sequence numbers
+	Leaves int
+	// TreeSize is the size the tree would have grown to
+	TreeSize int64
+	// LeafRows, NodeRows and RootRows are the number of rows that would have been written for
+	// the sequenced leaves, the tree nodes and the new root and compact tree state
+	LeafRows int
+	NodeRows int
+	RootRows int
+	// DequeueTime is the time spent dequeuing leaves
+	DequeueTime time.Duration
+	// ComputeTime is the rest of the time, spent building the tree, computing nodes, signing
+	// the root and starting and rolling back the transaction
+	ComputeTime time.Duration
+	// WriteTime is the time spent writing leaves, nodes and the root to the transaction
+	WriteTime time.Duration
+	// TotalTime is the time from the start of the transaction to the end of the rollback
+	TotalTime time.Duration
+}
+
+// dryRunTX passes everything to a storage.LogTX but rolls it back when it would be committed.
+// It counts the rows written and times the dequeues and writes for a DryRunReport.
+type dryRunTX struct {
+	storage.LogTX
+	timeSource util.TimeSource
+	report     *DryRunReport
+}
+
+// since returns the time elapsed since start
+func (t dryRunTX) since(start time.Time) time.Duration {
+	return t.timeSource.Now().Sub(start)
+}
+
+func (t dryRunTX) LatestSignedLogRoot() (trillian.SignedLogRoot, error) {
+	root, err := t.LogTX.LatestSignedLogRoot()
+	t.report.TreeSize = root.TreeSize
+	return root, err
+}
+
+func (t dryRunTX) DequeueLeaves(limit int) ([]trillian.LogLeaf, error) {
+	start := t.timeSource.Now()
+	leaves, err := t.LogTX.DequeueLeaves(limit)
+	t.report.DequeueTime += t.since(start)
+	return leaves, err
+}
+
+func (t dryRunTX) UpdateSequencedLeaves(leaves []trillian.LogLeaf) error {
+	start := t.timeSource.Now()
+	err := t.LogTX.UpdateSequencedLeaves(leaves)
+	t.report.WriteTime += t.since(start)
+	t.report.Leaves += len(leaves)
+	t.report.LeafRows += len(leaves)
+	return err
+}
+
+func (t dryRunTX) SetMerkleNodes(nodes []storage.Node) error {
+	start := t.timeSource.Now()
+	err := t.LogTX.SetMerkleNodes(nodes)
+	t.report.WriteTime += t.since(start)
+	t.report.NodeRows += len(nodes)
+	return err
+}
+
+func (t dryRunTX) StoreCompactTree(state storage.CompactTreeProto) error {
+	start := t.timeSource.Now()
+	err := t.LogTX.StoreCompactTree(state)
+	t.report.WriteTime += t.since(start)
+	t.report.RootRows++
+	return err
+}
+
+func (t dryRunTX) StoreSignedLogRoot(root trillian.SignedLogRoot) error {
+	start := t.timeSource.Now()
+	err := t.LogTX.StoreSignedLogRoot(root)
+	t.report.WriteTime += t.since(start)
+	t.report.RootRows++
+	t.report.TreeSize = root.TreeSize
+	return err
+}
+
+// Commit rolls back instead, the batch must not be kept
+func (t dryRunTX) Commit() error {
+	return t.LogTX.Rollback()
+}
+
+// DryRunBatch does everything that SequenceBatch does for a batch of up to limit leaves,
+// dequeuing them, building the tree, computing and writing the updated nodes and signing and
+// writing a new root, but rolls back the transaction instead of committing it. The root hooks
+// aren't called. The leaves stay queued, so repeated dry runs measure the same batch. It
+// reports how long each stage took and how many rows the batch would have written, which
+// lets operators benchmark storage on real data without changing the log.
+func (s Sequencer) DryRunBatch(ctx context.Context, limit int) (DryRunReport, error) {
+	var report DryRunReport
+	start := s.timeSource.Now()
+	tx, err := s.logStorage.Begin()
+
+	if err != nil {
+		glog.Warningf("Sequencer failed to start dry run tx: %s", err)
+		return DryRunReport{}, err
+	}
+
+	if _, err := s.sequenceBatch(ctx, dryRunTX{LogTX: tx, timeSource: s.timeSource, report: &report}, limit, true); err != nil {
+		return DryRunReport{}, err
+	}
+
+	report.TotalTime = s.timeSource.Now().Sub(start)
+	report.ComputeTime = report.TotalTime - report.DequeueTime - report.WriteTime
+
+	return report, nil
+}
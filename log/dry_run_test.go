@@ -0,0 +1,61 @@
+package log
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+)
+
+func TestDryRunBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	leaves := []trillian.LogLeaf{getLeaf42()}
+	updatedLeaves := []trillian.LogLeaf{testLeaf16Integrated}
+	// Everything is written but the batch is rolled back rather than committed
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, dequeueLimit: 1, shouldRollback: true,
+		dequeuedLeaves: leaves, latestSignedRoot: &testRoot16,
+		updatedLeaves: &updatedLeaves, merkleNodesSet: &updatedNodes,
+		storeSignedRoot: &expectedSignedRoot, setupSigner: true,
+		dataToSign:    []byte{0x4f, 0x21, 0x7d, 0x10, 0xe2, 0x6, 0x9f, 0x10, 0x4d, 0x7e, 0x42, 0x75, 0x24, 0x3b, 0xb3, 0x5b, 0x63, 0xa6, 0x7, 0x8d, 0x6c, 0x97, 0x23, 0x4, 0x8, 0x5e, 0x3b, 0xe2, 0xc4, 0xb8, 0x7a, 0xa2},
+		signingResult: []byte("signed")}
+	c := createTestContext(ctrl, params)
+	c.sequencer.SetRootAudit(func(root trillian.SignedLogRoot) error {
+		t.Errorf("Dry run audited root: %v", root)
+		return nil
+	})
+	c.sequencer.SetRootStored(func(root trillian.SignedLogRoot) {
+		t.Errorf("Dry run stored root: %v", root)
+	})
+
+	report, err := c.sequencer.DryRunBatch(context.Background(), 1)
+
+	if err != nil {
+		t.Fatalf("DryRunBatch()=%v", err)
+	}
+
+	want := DryRunReport{Leaves: 1, TreeSize: 17, LeafRows: 1, NodeRows: len(updatedNodes), RootRows: 2}
+	if report != want {
+		t.Errorf("DryRunBatch()=%+v, expected %+v", report, want)
+	}
+}
+
+func TestDryRunBatchNothingQueued(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{dequeueLimit: 1, shouldRollback: true, latestSignedRoot: &testRoot16, dequeuedLeaves: []trillian.LogLeaf{}, skipStoreSignedRoot: true}
+	c := createTestContext(ctrl, params)
+
+	report, err := c.sequencer.DryRunBatch(context.Background(), 1)
+
+	if err != nil {
+		t.Fatalf("DryRunBatch()=%v", err)
+	}
+
+	if want := (DryRunReport{TreeSize: 16}); report != want {
+		t.Errorf("DryRunBatch()=%+v, expected %+v", report, want)
+	}
+}
@@ -2,13 +2,20 @@
 package log
 
 import (
+	"bytes"
+	"crypto/rand"
+	"errors"
 	"fmt"
+	"sort"
+	"time"
+
 	"github.com/golang/glog"
 	"github.com/google/trillian"
 	"github.com/google/trillian/crypto"
 	"github.com/google/trillian/merkle"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
 )
 
 // Sequencer instances are responsible for integrating new leaves into a log.
@@ -20,28 +27,209 @@ type Sequencer struct {
 	timeSource util.TimeSource
 	logStorage storage.LogStorage
 	keyManager crypto.KeyManager
+
+	// rootMetadata is optional, if set it supplies metadata for each new root
+	rootMetadata RootMetadataFunc
+	// rootAudit is optional, if set it's given each signed root before it's stored
+	rootAudit RootAuditFunc
+	// rootStored is optional, if set it's given each signed root once it has been committed
+	rootStored RootStoredFunc
+	// signEveryNLeaves is optional, if positive batches end at tree sizes that are multiples
+	// of it so there is always a root at those sizes
+	signEveryNLeaves int64
+	// verifyRoots is optional, if set the latest stored root must be correctly signed and
+	// match the tree before anything is built on it
+	verifyRoots bool
+	// nodeFlushSize is optional, if positive updated nodes are written to storage whenever
+	// this many are pending rather than all at the end of the batch
+	nodeFlushSize int
+	// batchMemoryBudget is optional, if positive batches are dequeued and sequenced in chunks
+	// that are expected to fit in this many bytes
+	batchMemoryBudget int64
+	// treeDepth is optional, if positive it's the depth of the log's tree rather than the
+	// default depth
+	treeDepth int
+	// timings is optional, if set the phases of every batch that's committed are timed and
+	// recorded under timingsLogID
+	timings      *SequencerTimings
+	timingsLogID int64
+}
+
+// ErrTreeFull is returned by SequenceBatch if the log's tree already has as many leaves as its
+// depth allows. Leaves that are still queued will never be sequenced.
+var ErrTreeFull = errors.New("log tree is full")
+
+// ErrSequencingPaused is returned by SequenceBatch and SignRoot if sequencing of the log has
+// been paused in storage, see storage.SequencingController. Queued leaves wait until it's
+// resumed.
+var ErrSequencingPaused = errors.New("sequencing of the log is paused")
+
+// ErrCommitUnknown is returned by SequenceBatch and SignRoot if committing a batch or root
+// failed and it couldn't be read back whether it was committed. Storage is left consistent
+// either way, as the batch or root was committed or rolled back as a whole, and the next batch
+// carries on from whatever was committed. Only the stored hook may have missed a root.
+var ErrCommitUnknown = errors.New("outcome of commit is unknown")
+
+// commitTokenSize is the length of the random tokens stored with each batch and root, see
+// storage.CommitTokenStore
+const commitTokenSize = 16
+
+// estimatedBytesPerLeaf is a rough upper bound on the memory needed to sequence a leaf, including
+// its data and its share of the updated tree nodes. It's used to size chunks of a batch so they
+// fit in the memory budget.
+const estimatedBytesPerLeaf = 4096
+
+// RootMetadataFunc is given a new log root before it is signed and returns opaque metadata
+// to be included in it, e.g. a shard identifier or epoch number. The metadata is covered by
+// the root signature. An error prevents the root from being created.
+type RootMetadataFunc func(root trillian.SignedLogRoot) ([]byte, error)
+
+// RootVerificationError is returned if the latest stored root has a bad signature or doesn't
+// match the tree in storage. The log won't be extended until this has been investigated as
+// it may indicate corrupted or tampered storage.
+type RootVerificationError struct {
+	Root   trillian.SignedLogRoot
+	Reason string
+}
+
+func (e RootVerificationError) Error() string {
+	return fmt.Sprintf("stored root for tree size %d revision %d failed verification: %s", e.Root.TreeSize, e.Root.TreeRevision, e.Reason)
 }
 
-// maxTreeDepth sets an upper limit on the size of Log trees.
-// TODO(al): We actually can't go beyond 2^63 entries becuase we use int64s,
-//           but we need to calculate tree depths from a multiple of 8 due to
-//           the subtrees.
-const maxTreeDepth = 64
+// RootAuditFunc is given each signed log root before it is stored, e.g. to record it in an
+// audit journal. An error prevents the root from being stored.
+type RootAuditFunc func(root trillian.SignedLogRoot) error
 
-// CurrentRootExpiredFunc examines a signed log root and decides if it has expired with respect
-// to a max age duration and a given time source
-// TODO(Martin2112): This is all likely to go away when we switch to application STHs
-type CurrentRootExpiredFunc func(trillian.SignedLogRoot) bool
+// RootStoredFunc is given each signed log root after the transaction that stored it has
+// committed, e.g. to tell subscribers about it.
+type RootStoredFunc func(root trillian.SignedLogRoot)
 
 func NewSequencer(hasher merkle.TreeHasher, timeSource util.TimeSource, logStorage storage.LogStorage, km crypto.KeyManager) *Sequencer {
-	return &Sequencer{hasher, timeSource, logStorage, km}
+	return &Sequencer{hasher: hasher, timeSource: timeSource, logStorage: logStorage, keyManager: km}
+}
+
+// SetRootMetadata installs a hook that will be called to populate the metadata of every
+// root created by this sequencer. Passing nil removes any existing hook.
+func (s *Sequencer) SetRootMetadata(f RootMetadataFunc) {
+	s.rootMetadata = f
+}
+
+// SetRootAudit installs a hook that will be called with every signed root before this
+// sequencer stores it. Passing nil removes any existing hook.
+func (s *Sequencer) SetRootAudit(f RootAuditFunc) {
+	s.rootAudit = f
+}
+
+// SetRootStored installs a hook that will be called with every signed root once this
+// sequencer has committed it. Passing nil removes any existing hook.
+func (s *Sequencer) SetRootStored(f RootStoredFunc) {
+	s.rootStored = f
+}
+
+// SetSignEveryNLeaves makes the sequencer store a signed root at every tree size that is a
+// multiple of n, in addition to the roots created for each batch and when the current root
+// expires. This gives monitors a predictable series of roots to check consistency between.
+// Batches are cut short where necessary so they don't cross one of these sizes. Passing zero
+// disables this.
+func (s *Sequencer) SetSignEveryNLeaves(n int64) {
+	s.signEveryNLeaves = n
+}
+
+// SetVerifyRoots makes the sequencer check the signature on the latest stored root with the
+// public key of its signer, and that the root matches the tree in storage, before it
+// sequences leaves or signs a new root. If either check fails it returns a
+// RootVerificationError and does nothing.
+func (s *Sequencer) SetVerifyRoots(verify bool) {
+	s.verifyRoots = verify
+}
+
+// SetNodeFlushSize makes the sequencer write the tree nodes updated by a batch to storage
+// whenever n of them are pending, instead of collecting every node for the batch in memory
+// before writing them. Nodes are still written in the batch's transaction. Passing zero
+// disables this.
+func (s *Sequencer) SetNodeFlushSize(n int) {
+	s.nodeFlushSize = n
+}
+
+// SetBatchMemoryBudget limits the memory used to sequence a batch to roughly the given number
+// of bytes. Batches that might need more are dequeued and sequenced in chunks within the
+// same transaction, with the nodes for each chunk written before the next is dequeued, so the
+// batch still results in a single new root. Passing zero disables this.
+func (s *Sequencer) SetBatchMemoryBudget(bytes int64) {
+	s.batchMemoryBudget = bytes
+}
+
+// SetTreeDepth tells the sequencer the depth of the log's tree, which is fixed when the tree is
+// created, see storage.LogStorage. It determines the IDs of the tree's nodes and how many
+// leaves the tree can hold. Passing zero means the tree has the default depth.
+func (s *Sequencer) SetTreeDepth(depth int) {
+	s.treeDepth = depth
+}
+
+// SetTimings makes the sequencer record how long each phase of the batches it commits takes
+// in t, which can be shared with the sequencers of other logs, identifying slow batches by
+// logID. Passing nil disables this.
+func (s *Sequencer) SetTimings(t *SequencerTimings, logID int64) {
+	s.timings = t
+	s.timingsLogID = logID
+}
+
+// depth returns the depth of the log's tree.
+func (s Sequencer) depth() int {
+	if s.treeDepth > 0 {
+		return s.treeDepth
+	}
+
+	return storage.DefaultLogTreeDepth
+}
+
+// chunkSize returns the number of leaves to dequeue at a time for a batch of up to limit
+// leaves so that the memory budget isn't exceeded.
+func (s Sequencer) chunkSize(limit int) int {
+	if s.batchMemoryBudget <= 0 {
+		return limit
+	}
+
+	chunk := s.batchMemoryBudget / estimatedBytesPerLeaf
+	if chunk < 1 {
+		chunk = 1
+	}
+
+	if chunk < int64(limit) {
+		return int(chunk)
+	}
+
+	return limit
+}
+
+// batchLimit returns the number of leaves that can be sequenced into a tree of treeSize
+// without passing the next size that must have a root, or the capacity of the tree.
+func (s Sequencer) batchLimit(limit int, treeSize int64) int {
+	if remaining := storage.LogTreeCapacity(s.depth()) - treeSize; remaining < int64(limit) {
+		limit = int(remaining)
+	}
+
+	if s.signEveryNLeaves <= 0 {
+		return limit
+	}
+
+	if toNextRoot := s.signEveryNLeaves - treeSize%s.signEveryNLeaves; toNextRoot < int64(limit) {
+		return int(toNextRoot)
+	}
+
+	return limit
 }
 
 // TODO: This currently doesn't use the batch api for fetching the required nodes. This
 // would be more efficient but requires refactoring.
 func (s Sequencer) buildMerkleTreeFromStorageAtRoot(root trillian.SignedLogRoot, tx storage.TreeTX) (*merkle.CompactMerkleTree, error) {
-	mt, err := merkle.NewCompactMerkleTreeWithState(s.hasher, root.TreeSize, func(depth int, index int64) (trillian.Hash, error) {
-		nodeId, err := storage.NewNodeIDForTreeCoords(int64(depth), index, maxTreeDepth)
+	return merkle.NewCompactMerkleTreeWithState(s.hasher, root.TreeSize, s.getNodeAtRoot(root, tx), root.RootHash)
+}
+
+// getNodeAtRoot returns a function that fetches nodes of the tree as it was at root
+func (s Sequencer) getNodeAtRoot(root trillian.SignedLogRoot, tx storage.TreeTX) merkle.GetNodeFunc {
+	return func(depth int, index int64) (trillian.Hash, error) {
+		nodeId, err := storage.NewNodeIDForTreeCoords(int64(depth), index, s.depth())
 		if err != nil {
 			glog.Warningf("Failed to create nodeID: %v", err)
 			return nil, err
@@ -59,62 +247,299 @@ func (s Sequencer) buildMerkleTreeFromStorageAtRoot(root trillian.SignedLogRoot,
 		}
 
 		return nodes[0].Hash, nil
-	}, root.RootHash)
+	}
+}
 
-	return mt, err
+// bufferedNode is a node updated by a batch along with the size the tree must reach for the
+// node's hash to be final.
+type bufferedNode struct {
+	node storage.Node
+	end  int64
 }
 
-func (s Sequencer) buildNodesFromNodeMap(nodeMap map[string]storage.Node, newVersion int64) ([]storage.Node, error) {
-	targetNodes := make([]storage.Node, len(nodeMap), len(nodeMap))
-	i := 0
-	for _, node := range nodeMap {
-		node.NodeRevision = newVersion
-		targetNodes[i] = node
-		i++
+// nodeBuffer collects the nodes updated while sequencing a batch. Because nodes are deduped by
+// ID each node is written at most once for the new tree revision, so writes can't conflict.
+type nodeBuffer struct {
+	tx        storage.TreeTX
+	revision  int64
+	flushSize int
+	nodes     map[string]bufferedNode
+}
+
+func newNodeBuffer(tx storage.TreeTX, revision int64, flushSize int) *nodeBuffer {
+	return &nodeBuffer{tx: tx, revision: revision, flushSize: flushSize, nodes: make(map[string]bufferedNode)}
+}
+
+func (b *nodeBuffer) set(nodeID storage.NodeID, depth int, index int64, hash trillian.Hash) {
+	b.nodes[nodeID.String()] = bufferedNode{
+		node: storage.Node{NodeID: nodeID, Hash: hash, NodeRevision: b.revision},
+		end:  (index + 1) << uint(depth),
 	}
-	return targetNodes, nil
 }
 
-func (s Sequencer) sequenceLeaves(mt *merkle.CompactMerkleTree, leaves []trillian.LogLeaf) (map[string]storage.Node, []int64, error) {
-	nodeMap := make(map[string]storage.Node)
+// full returns true if the buffer has reached its flush size.
+func (b *nodeBuffer) full() bool {
+	return b.flushSize > 0 && len(b.nodes) >= b.flushSize
+}
+
+// flushComplete writes the nodes whose hashes can't change as the tree grows past treeSize.
+// Nodes on the right edge of the tree are kept as they may be updated again by later leaves.
+func (b *nodeBuffer) flushComplete(treeSize int64) error {
+	nodes := make([]storage.Node, 0, len(b.nodes))
+	for id, n := range b.nodes {
+		if n.end <= treeSize {
+			nodes = append(nodes, n.node)
+			delete(b.nodes, id)
+		}
+	}
+
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	return b.tx.SetMerkleNodes(nodes)
+}
+
+// flush writes all the pending nodes, it's called when the batch is complete.
+func (b *nodeBuffer) flush() error {
+	nodes := make([]storage.Node, 0, len(b.nodes))
+	for _, n := range b.nodes {
+		nodes = append(nodes, n.node)
+	}
+	b.nodes = make(map[string]bufferedNode)
+
+	return b.tx.SetMerkleNodes(nodes)
+}
+
+func (s Sequencer) sequenceLeaves(mt *merkle.CompactMerkleTree, leaves []trillian.LogLeaf, nodes *nodeBuffer) ([]int64, error) {
 	sequenceNumbers := make([]int64, 0, len(leaves))
+	treeDepth := s.depth()
 
 	// Update the tree state and sequence the leaves, tracking the node updates that need to be
 	// made and assign sequence numbers to the new leaves
 	for _, leaf := range leaves {
+		// A node that doesn't fit in the tree would otherwise be silently left out of storage
+		var nodeErr error
 		seq := mt.AddLeafHash(leaf.LeafHash, func(depth int, index int64, hash trillian.Hash) {
-			nodeId, err := storage.NewNodeIDForTreeCoords(int64(depth), index, maxTreeDepth)
+			nodeId, err := storage.NewNodeIDForTreeCoords(int64(depth), index, treeDepth)
 			if err != nil {
+				nodeErr = err
 				return
 			}
-			nodeMap[nodeId.String()] = storage.Node{
-				NodeID: nodeId,
-				Hash:   hash,
-			}
+			nodes.set(nodeId, depth, index, hash)
 		})
+		if nodeErr != nil {
+			return nil, nodeErr
+		}
 		// store leaf hash in the merkle tree too:
-		leafNodeID, err := storage.NewNodeIDForTreeCoords(0, seq, maxTreeDepth)
+		leafNodeID, err := storage.NewNodeIDForTreeCoords(0, seq, treeDepth)
 		if err != nil {
-			return nil, nil, err
-		}
-		nodeMap[leafNodeID.String()] = storage.Node{
-			NodeID: leafNodeID,
-			Hash:   leaf.LeafHash,
+			return nil, err
 		}
+		nodes.set(leafNodeID, 0, seq, leaf.LeafHash)
 
 		sequenceNumbers = append(sequenceNumbers, seq)
+
+		if nodes.full() {
+			if err := nodes.flushComplete(mt.Size()); err != nil {
+				glog.Warningf("Sequencer failed to set merkle nodes: %s", err)
+				return nil, err
+			}
+		}
 	}
 
-	return nodeMap, sequenceNumbers, nil
+	return sequenceNumbers, nil
 }
 
+// byPriority sorts leaves so the highest priority ones are first
+type byPriority []trillian.LogLeaf
+
+func (b byPriority) Len() int           { return len(b) }
+func (b byPriority) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byPriority) Less(i, j int) bool { return b[i].Priority > b[j].Priority }
+
 func (s Sequencer) initMerkleTreeFromStorage(currentRoot trillian.SignedLogRoot, tx storage.LogTX) (*merkle.CompactMerkleTree, error) {
+	if s.verifyRoots {
+		if err := s.verifyRootSignature(currentRoot); err != nil {
+			return nil, err
+		}
+	}
+
 	if currentRoot.TreeSize == 0 {
 		return merkle.NewCompactMerkleTree(s.hasher), nil
 	}
 
+	// Restoring the stored compact tree state needs a single read rather than one for each
+	// level of the tree
+	mt, err := s.loadCompactTree(currentRoot, tx)
+
+	if err != nil || mt != nil {
+		return mt, err
+	}
+
 	// Initialize the compact tree state to match the latest root in the database
-	return s.buildMerkleTreeFromStorageAtRoot(currentRoot, tx)
+	mt, err = s.buildMerkleTreeFromStorageAtRoot(currentRoot, tx)
+
+	if err != nil {
+		if mismatch, ok := err.(merkle.RootHashMismatchError); ok && s.verifyRoots {
+			return nil, s.rootVerificationFailed(currentRoot, fmt.Sprintf("tree has root hash %x", mismatch.ActualHash))
+		}
+		return nil, err
+	}
+
+	if s.verifyRoots {
+		if err := s.verifyPerfectTreeRoot(currentRoot, tx); err != nil {
+			return nil, err
+		}
+	}
+
+	return mt, nil
+}
+
+// verifyRootSignature checks that a stored root was signed by this sequencer's key. The empty
+// root that a new log starts with has never been signed and is accepted.
+func (s Sequencer) verifyRootSignature(root trillian.SignedLogRoot) error {
+	if root.Signature == nil && root.TreeSize == 0 && root.TreeRevision == 0 {
+		return nil
+	}
+
+	signer, err := s.keyManager.Signer()
+
+	if err != nil {
+		glog.Warningf("key manager failed to create crypto.Signer: %v", err)
+		return err
+	}
+
+	if err := crypto.VerifyLogRoot(s.hasher.Hasher, signer.Public(), root); err != nil {
+		return s.rootVerificationFailed(root, err.Error())
+	}
+
+	return nil
+}
+
+// verifyPerfectTreeRoot checks the root hash of a perfect tree against the node at the top of
+// the tree. Other trees are checked when the compact tree is built, but for a perfect tree
+// that node is the only one needed and it's taken from the root.
+func (s Sequencer) verifyPerfectTreeRoot(root trillian.SignedLogRoot, tx storage.TreeTX) error {
+	if root.TreeSize&(root.TreeSize-1) != 0 {
+		return nil
+	}
+
+	depth := 0
+	for size := root.TreeSize; size > 1; size >>= 1 {
+		depth++
+	}
+
+	hash, err := s.getNodeAtRoot(root, tx)(depth, 0)
+
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(hash, root.RootHash) {
+		return s.rootVerificationFailed(root, fmt.Sprintf("tree has root hash %x", hash))
+	}
+
+	return nil
+}
+
+// rootVerificationFailed raises the alarm about a stored root that failed verification and
+// returns the error to stop the sequencer building on it.
+func (s Sequencer) rootVerificationFailed(root trillian.SignedLogRoot, reason string) error {
+	err := RootVerificationError{Root: root, Reason: reason}
+	glog.Errorf("Refusing to sequence log %x: %v", root.LogId, err)
+	return err
+}
+
+// loadCompactTree restores the compact tree from the state stored after the last batch was
+// sequenced. It returns nil if there isn't a stored state that matches the latest root, for
+// example if it was stored by a version of the sequencer that didn't keep it up to date.
+func (s Sequencer) loadCompactTree(currentRoot trillian.SignedLogRoot, tx storage.LogTX) (*merkle.CompactMerkleTree, error) {
+	state, err := tx.LatestCompactTree()
+
+	if err != nil {
+		glog.Warningf("Sequencer failed to get compact tree: %s", err)
+		return nil, err
+	}
+
+	if state.TreeSize != currentRoot.TreeSize {
+		if state.TreeSize != 0 {
+			glog.Warningf("Stored compact tree is for size %d but latest root is for size %d, fetching nodes", state.TreeSize, currentRoot.TreeSize)
+		}
+		return nil, nil
+	}
+
+	nodes := make([]trillian.Hash, 0, len(state.Nodes))
+	for _, node := range state.Nodes {
+		nodes = append(nodes, node)
+	}
+
+	mt, err := merkle.NewCompactMerkleTreeFromNodes(s.hasher, state.TreeSize, nodes, currentRoot.RootHash)
+
+	if err != nil {
+		glog.Warningf("Stored compact tree does not match latest root, fetching nodes: %v", err)
+		return nil, nil
+	}
+
+	return mt, nil
+}
+
+// storeCompactTree saves the state of the compact tree so the next batch can restore it with
+// loadCompactTree.
+func (s Sequencer) storeCompactTree(mt *merkle.CompactMerkleTree, tx storage.LogTX) error {
+	state := storage.CompactTreeProto{TreeSize: mt.Size()}
+	for _, node := range mt.Nodes() {
+		state.Nodes = append(state.Nodes, node)
+	}
+
+	return tx.StoreCompactTree(state)
+}
+
+// addRootMetadata fills in the metadata for a new root if a hook has been set. It must be
+// called before the root is signed.
+func (s Sequencer) addRootMetadata(root *trillian.SignedLogRoot) error {
+	if s.rootMetadata == nil {
+		return nil
+	}
+
+	metadata, err := s.rootMetadata(*root)
+
+	if err != nil {
+		return err
+	}
+
+	root.Metadata = metadata
+	return nil
+}
+
+// storeSignedLogRoot passes the root to the audit hook, if there is one, and then stores it.
+func (s Sequencer) storeSignedLogRoot(tx storage.LogTX, root trillian.SignedLogRoot) error {
+	if s.rootAudit != nil {
+		if err := s.rootAudit(root); err != nil {
+			glog.Warningf("failed to audit new root: %v", err)
+			return err
+		}
+	}
+
+	return tx.StoreSignedLogRoot(root)
+}
+
+// rootCommitted passes a root that has been committed to the stored hook, if there is one.
+func (s Sequencer) rootCommitted(root trillian.SignedLogRoot) {
+	if s.rootStored != nil {
+		s.rootStored(root)
+	}
+}
+
+// checkCancelled returns ctx's error if it has been cancelled, so that a batch or root can be
+// rolled back between phases rather than carrying on with work that will be thrown away.
+func checkCancelled(ctx context.Context, phase string) error {
+	if err := ctx.Err(); err != nil {
+		glog.Warningf("Sequencer cancelled %s: %v", phase, err)
+		return err
+	}
+
+	return nil
 }
 
 func (s Sequencer) signRoot(root trillian.SignedLogRoot) (trillian.DigitallySigned, error) {
@@ -139,11 +564,13 @@ func (s Sequencer) signRoot(root trillian.SignedLogRoot) (trillian.DigitallySign
 }
 
 // SequenceBatch wraps up all the operations needed to take a batch of queued leaves
-// and integrate them into the tree.
+// and integrate them into the tree. If ctx is cancelled the batch is rolled back at the end of
+// the phase in progress and ctx's error is returned, once the batch is being committed it
+// can't be cancelled.
 // TODO(Martin2112): Can possibly improve by deferring a function that attempts to rollback,
 // which will fail if the tx was committed. Should only do this if we can hide the details of
 // the underlying storage transactions and it doesn't create other problems.
-func (s Sequencer) SequenceBatch(limit int, expiryFunc CurrentRootExpiredFunc) (int, error) {
+func (s Sequencer) SequenceBatch(ctx context.Context, limit int) (int, error) {
 	tx, err := s.logStorage.Begin()
 
 	if err != nil {
@@ -151,10 +578,43 @@ func (s Sequencer) SequenceBatch(limit int, expiryFunc CurrentRootExpiredFunc) (
 		return 0, err
 	}
 
-	leaves, err := tx.DequeueLeaves(limit)
+	if err := checkSequencingEnabled(tx); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return s.sequenceBatch(ctx, tx, limit, false)
+}
+
+// checkSequencingEnabled returns ErrSequencingPaused if sequencing of the log has been paused.
+// Storage that can't pause logs always sequences them.
+func checkSequencingEnabled(tx storage.LogTX) error {
+	controller, ok := tx.(storage.SequencingController)
+
+	if !ok {
+		return nil
+	}
+
+	enabled, err := controller.SequencingEnabled()
 
 	if err != nil {
-		glog.Warningf("Sequencer failed to dequeue leaves: %s", err)
+		glog.Warningf("Sequencer failed to check whether sequencing is paused: %s", err)
+		return err
+	}
+
+	if !enabled {
+		return ErrSequencingPaused
+	}
+
+	return nil
+}
+
+// sequenceBatch sequences a batch of up to limit leaves in tx and commits it. If dryRun is set
+// the root hooks aren't called, so nothing outside tx sees the batch.
+func (s Sequencer) sequenceBatch(ctx context.Context, tx storage.LogTX, limit int, dryRun bool) (int, error) {
+	timer := newBatchTimer(s.timeSource)
+
+	if err := checkCancelled(ctx, "before dequeuing leaves"); err != nil {
 		tx.Rollback()
 		return 0, err
 	}
@@ -168,125 +628,205 @@ func (s Sequencer) SequenceBatch(limit int, expiryFunc CurrentRootExpiredFunc) (
 		return 0, err
 	}
 
+	if currentRoot.TreeSize >= storage.LogTreeCapacity(s.depth()) {
+		glog.Warningf("Sequencer can't add leaves to tree of size %d with depth %d", currentRoot.TreeSize, s.depth())
+		tx.Rollback()
+		return 0, ErrTreeFull
+	}
+
+	limit = s.batchLimit(limit, currentRoot.TreeSize)
+	chunkSize := s.chunkSize(limit)
+	leaves, err := tx.DequeueLeaves(chunkSize)
+
+	if err != nil {
+		glog.Warningf("Sequencer failed to dequeue leaves: %s", err)
+		tx.Rollback()
+		return 0, err
+	}
+
 	// TODO(al): Have a better detection mechanism for there being no stored root.
 	if currentRoot.RootHash == nil {
 		glog.Warning("Fresh log - no previous TreeHeads exist.")
 	}
 
-	// There might be no work to be done. But we possibly still need to create an STH if the
-	// current one is too old. If there's work to be done then we'll be creating a root anyway.
+	// There might be no work to be done, in which case no root is created. Keeping the
+	// current root fresh is up to SignRootIfOlderThan.
 	if len(leaves) == 0 {
-		// We have nothing to integrate into the tree
 		tx.Commit()
-		if expiryFunc(currentRoot) {
-			// Current root is too old, sign one. Will use a new TX, safe as we have no writes
-			// pending in this one.
-			return 0, s.SignRoot()
-		}
 		return 0, nil
 	}
 
-	merkleTree, err := s.initMerkleTreeFromStorage(currentRoot, tx)
-
-	if err != nil {
+	if err := checkCancelled(ctx, "after dequeuing leaves"); err != nil {
 		tx.Rollback()
 		return 0, err
 	}
 
-	// We've done all the reads, can now do the updates.
-	// TODO: This relies on us being the only process updating the map, which isn't enforced yet
-	// though the schema should now prevent multiple STHs being inserted with the same revision
-	// number so it should not be possible for colliding updates to commit.
-	newVersion := tx.WriteRevision()
-	if got, want := newVersion, currentRoot.TreeRevision+int64(1); got != want {
-		tx.Rollback()
-		return 0, fmt.Errorf("got writeRevision of %d, but expected %d", got, want)
-	}
+	timer.enter(PhaseTreeInit)
+	merkleTree, newVersion, err := s.loadTree(currentRoot, tx)
 
-	// Assign leaf sequence numbers and collate node updates
-	nodeMap, sequenceNumbers, err := s.sequenceLeaves(merkleTree, leaves)
 	if err != nil {
 		tx.Rollback()
 		return 0, err
 	}
 
-	if len(sequenceNumbers) != len(leaves) {
-		panic(fmt.Sprintf("Sequencer returned %d sequence numbers for %d leaves", len(sequenceNumbers),
-			len(leaves)))
-	}
+	nodes := newNodeBuffer(tx, newVersion, s.nodeFlushSize)
 
-	for index, _ := range sequenceNumbers {
-		leaves[index].SequenceNumber = sequenceNumbers[index]
-	}
+	// All the leaves in a batch are integrated at the same time
+	integrateTimestampNanos := s.timeSource.Now().UnixNano()
+	sequenced := 0
 
-	// Write the new sequence numbers to the leaves in the DB
-	err = tx.UpdateSequencedLeaves(leaves)
+	for {
+		timer.enter(PhaseSequence)
 
-	if err != nil {
-		glog.Warningf("Sequencer failed to update sequenced leaves: %s", err)
+		// Storage picks the highest priority leaves for the batch, make sure they also get the
+		// lowest sequence numbers within it so live submissions aren't queued behind backfills
+		sort.Stable(byPriority(leaves))
+
+		// Assign leaf sequence numbers and collate node updates
+		sequenceNumbers, err := s.sequenceLeaves(merkleTree, leaves, nodes)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+
+		if len(sequenceNumbers) != len(leaves) {
+			panic(fmt.Sprintf("Sequencer returned %d sequence numbers for %d leaves", len(sequenceNumbers),
+				len(leaves)))
+		}
+
+		for index, _ := range sequenceNumbers {
+			leaves[index].SequenceNumber = sequenceNumbers[index]
+			leaves[index].IntegrateTimestampNanos = integrateTimestampNanos
+		}
+
+		// Write the new sequence numbers to the leaves in the DB
+		err = tx.UpdateSequencedLeaves(leaves)
+
+		if err != nil {
+			glog.Warningf("Sequencer failed to update sequenced leaves: %s", err)
+			tx.Rollback()
+			return 0, err
+		}
+
+		sequenced += len(leaves)
+
+		// A short chunk means the queue is empty
+		if len(leaves) < chunkSize || sequenced >= limit {
+			break
+		}
+
+		if err := checkCancelled(ctx, "between chunks"); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+
+		// Write out the nodes for this chunk so they don't accumulate across the batch
+		timer.enter(PhaseNodeWrite)
+
+		if err := nodes.flushComplete(merkleTree.Size()); err != nil {
+			glog.Warningf("Sequencer failed to set merkle nodes: %s", err)
+			tx.Rollback()
+			return 0, err
+		}
+
+		next := chunkSize
+		if limit-sequenced < next {
+			next = limit - sequenced
+		}
+
+		timer.enter(PhaseDequeue)
+		leaves, err = tx.DequeueLeaves(next)
+
+		if err != nil {
+			glog.Warningf("Sequencer failed to dequeue leaves: %s", err)
+			tx.Rollback()
+			return 0, err
+		}
+
+		if len(leaves) == 0 {
+			break
+		}
+	}
+
+	if err := checkCancelled(ctx, "before writing nodes"); err != nil {
 		tx.Rollback()
 		return 0, err
 	}
 
-	// Build objects for the nodes to be updated. Because we deduped via the map each
-	// node can only be created / updated once in each tree revision and they cannot
-	// conflict when we do the storage update.
-	targetNodes, err := s.buildNodesFromNodeMap(nodeMap, newVersion)
+	// Now insert or update the remaining nodes affected by the above, at the new tree version
+	timer.enter(PhaseNodeWrite)
+	err = nodes.flush()
 
 	if err != nil {
-		// probably an internal error with map building, unexpected
-		glog.Warningf("Failed to build target nodes in sequencer: %s", err)
+		glog.Warningf("Sequencer failed to set merkle nodes: %s", err)
 		tx.Rollback()
 		return 0, err
 	}
 
-	// Now insert or update the nodes affected by the above, at the new tree version
-	err = tx.SetMerkleNodes(targetNodes)
-
-	if err != nil {
-		glog.Warningf("Sequencer failed to set merkle nodes: %s", err)
+	if err := s.storeCompactTree(merkleTree, tx); err != nil {
+		glog.Warningf("Sequencer failed to store compact tree: %s", err)
 		tx.Rollback()
 		return 0, err
 	}
 
-	// Create the log root ready for signing
-	newLogRoot := trillian.SignedLogRoot{
-		RootHash:       merkleTree.CurrentRoot(),
-		TimestampNanos: s.timeSource.Now().UnixNano(),
-		TreeSize:       merkleTree.Size(),
-		LogId:          currentRoot.LogId,
-		TreeRevision:   newVersion,
+	if err := s.integrateRoot(ctx, tx, currentRoot, merkleTree, newVersion, timer, dryRun); err != nil {
+		return 0, err
 	}
 
-	// Hash and sign the root, update it with the signature
-	signature, err := s.signRoot(newLogRoot)
+	if !dryRun && s.timings != nil {
+		s.timings.record(s.timingsLogID, sequenced, timer)
+	}
+
+	return sequenced, nil
+}
+
+// SignRootIfOlderThan signs a new root if the latest stored root is older than maxAge, so that
+// a log has a recent root even when no leaves are being added. It returns the time the latest
+// root was created, or when a new one was signed, which is no later than the new root's
+// timestamp. Cancelling ctx stops a new root being signed, see SignRoot.
+func (s Sequencer) SignRootIfOlderThan(ctx context.Context, maxAge time.Duration) (time.Time, error) {
+	tx, err := s.logStorage.Begin()
 
 	if err != nil {
-		glog.Warningf("signer failed to sign root: %v", err)
-		tx.Rollback()
-		return 0, err
+		glog.Warningf("signer failed to start tx: %s", err)
+		return time.Time{}, err
 	}
 
-	newLogRoot.Signature = &signature
-
-	err = tx.StoreSignedLogRoot(newLogRoot)
+	currentRoot, err := tx.LatestSignedLogRoot()
 
 	if err != nil {
-		glog.Warningf("failed to write updated tree root: %s", err)
+		glog.Warningf("signer failed to get latest root: %s", err)
 		tx.Rollback()
-		return 0, err
+		return time.Time{}, err
 	}
 
-	// The batch is now fully sequenced and we're done
-	if err := tx.Commit(); err != nil {
-		return 0, err
+	// Nothing was written so committing can't fail in a way that matters
+	tx.Commit()
+
+	now := s.timeSource.Now()
+	rootTime := time.Unix(0, currentRoot.TimestampNanos)
+
+	if now.Sub(rootTime) <= maxAge {
+		return rootTime, nil
+	}
+
+	// SignRoot uses a new TX and reads the latest root again, it's up to the caller to
+	// make sure no other root is stored in between
+	if err := s.SignRoot(ctx); err != nil {
+		return time.Time{}, err
 	}
 
-	return len(leaves), nil
+	return now, nil
 }
 
-// SignRoot wraps up all the operations for creating a new log signed root.
-func (s Sequencer) SignRoot() error {
+// SignRoot wraps up all the operations for creating a new log signed root. The root is built,
+// signed and stored in the same way as at the end of SequenceBatch, for the tree as it is. If
+// ctx is cancelled before the root is stored nothing is written and ctx's error is returned.
+func (s Sequencer) SignRoot(ctx context.Context) error {
+	if err := checkCancelled(ctx, "before signing root"); err != nil {
+		return err
+	}
+
 	tx, err := s.logStorage.Begin()
 
 	if err != nil {
@@ -294,6 +834,11 @@ func (s Sequencer) SignRoot() error {
 		return err
 	}
 
+	if err := checkSequencingEnabled(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	// Get the latest known root from storage
 	currentRoot, err := tx.LatestSignedLogRoot()
 
@@ -305,23 +850,74 @@ func (s Sequencer) SignRoot() error {
 
 	// Initialize a Merkle Tree from the state in storage. This should fail if the tree is
 	// in a corrupt state.
-	merkleTree, err := s.initMerkleTreeFromStorage(currentRoot, tx)
+	merkleTree, newVersion, err := s.loadTree(currentRoot, tx)
 
 	if err != nil {
 		tx.Rollback()
 		return err
 	}
 
-	// Build the updated root, ready for signing
+	// Keeping the compact tree in step with every root also repairs one that is missing or
+	// stale, e.g. after an upgrade, when only a root is signed
+	if err := s.storeCompactTree(merkleTree, tx); err != nil {
+		glog.Warningf("signer failed to store compact tree: %s", err)
+		tx.Rollback()
+		return err
+	}
+
+	return s.integrateRoot(ctx, tx, currentRoot, merkleTree, newVersion, nil, false)
+}
+
+// loadTree initializes the compact tree for currentRoot and returns it with the revision tx
+// will write at, which must be the one after currentRoot's. It's the start of the integration
+// shared by SequenceBatch and SignRoot.
+func (s Sequencer) loadTree(currentRoot trillian.SignedLogRoot, tx storage.LogTX) (*merkle.CompactMerkleTree, int64, error) {
+	merkleTree, err := s.initMerkleTreeFromStorage(currentRoot, tx)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// We've done all the reads, can now do the updates.
+	// TODO: This relies on us being the only process updating the map, which isn't enforced yet
+	// though the schema should now prevent multiple STHs being inserted with the same revision
+	// number so it should not be possible for colliding updates to commit.
+	newVersion := tx.WriteRevision()
+	if got, want := newVersion, currentRoot.TreeRevision+int64(1); got != want {
+		return nil, 0, fmt.Errorf("got writeRevision of %d, but expected %d", got, want)
+	}
+
+	return merkleTree, newVersion, nil
+}
+
+// integrateRoot builds the root of merkleTree at newVersion, then signs, stores and commits it
+// in tx. It's the end of the integration shared by SequenceBatch and SignRoot, so the roots
+// they create differ only in the tree they're for. tx is rolled back if it fails before
+// committing. If dryRun is set the root hooks aren't called. timer may be nil if the phases
+// aren't being timed.
+func (s Sequencer) integrateRoot(ctx context.Context, tx storage.LogTX, currentRoot trillian.SignedLogRoot, merkleTree *merkle.CompactMerkleTree, newVersion int64, timer *batchTimer, dryRun bool) error {
+	// Create the log root ready for signing
+	timer.enter(PhaseSign)
 	newLogRoot := trillian.SignedLogRoot{
 		RootHash:       merkleTree.CurrentRoot(),
 		TimestampNanos: s.timeSource.Now().UnixNano(),
 		TreeSize:       merkleTree.Size(),
 		LogId:          currentRoot.LogId,
-		TreeRevision:   currentRoot.TreeRevision + 1,
+		TreeRevision:   newVersion,
+	}
+
+	if err := s.addRootMetadata(&newLogRoot); err != nil {
+		glog.Warningf("failed to get root metadata: %v", err)
+		tx.Rollback()
+		return err
+	}
+
+	if err := checkCancelled(ctx, "before signing root"); err != nil {
+		tx.Rollback()
+		return err
 	}
 
-	// Hash and sign the root
+	// Hash and sign the root, update it with the signature
 	signature, err := s.signRoot(newLogRoot)
 
 	if err != nil {
@@ -332,12 +928,101 @@ func (s Sequencer) SignRoot() error {
 
 	newLogRoot.Signature = &signature
 
-	// Store the new root and we're done
-	if err := tx.StoreSignedLogRoot(newLogRoot); err != nil {
-		glog.Warningf("signer failed to write updated root: %v", err)
+	if dryRun {
+		err = tx.StoreSignedLogRoot(newLogRoot)
+	} else {
+		err = s.storeSignedLogRoot(tx, newLogRoot)
+	}
+
+	if err != nil {
+		glog.Warningf("failed to write updated tree root: %s", err)
+		tx.Rollback()
+		return err
+	}
+
+	timer.enter(PhaseCommit)
+
+	if err := s.commit(tx); err != nil {
+		return err
+	}
+
+	if !dryRun {
+		s.rootCommitted(newLogRoot)
+	}
+
+	return nil
+}
+
+// commit commits tx. If the storage can record commit tokens one is stored in tx first, so
+// that if Commit fails the token can be read back to find out whether tx was committed anyway,
+// in which case nil is returned. Otherwise a root that was stored could be missed by the
+// stored hook, or reported as failed to the caller. ErrCommitUnknown is returned if it can't be
+// told whether tx was committed.
+func (s Sequencer) commit(tx storage.LogTX) error {
+	tokenStore, ok := tx.(storage.CommitTokenStore)
+
+	if !ok {
+		return tx.Commit()
+	}
+
+	token := make([]byte, commitTokenSize)
+
+	if _, err := rand.Read(token); err != nil {
+		glog.Warningf("Sequencer failed to create commit token: %v", err)
 		tx.Rollback()
 		return err
 	}
 
-	return tx.Commit()
+	if err := tokenStore.StoreCommitToken(token); err != nil {
+		glog.Warningf("Sequencer failed to store commit token: %v", err)
+		tx.Rollback()
+		return err
+	}
+
+	commitErr := tx.Commit()
+
+	if commitErr == nil {
+		return nil
+	}
+
+	committed, err := s.committedToken(token)
+
+	if err != nil {
+		glog.Errorf("Sequencer commit failed: %v, and reading back the commit token failed: %v", commitErr, err)
+		return ErrCommitUnknown
+	}
+
+	if committed {
+		glog.Warningf("Sequencer commit failed: %v, but the transaction was committed", commitErr)
+		return nil
+	}
+
+	return commitErr
+}
+
+// committedToken returns true if token is the latest commit token stored for the log.
+func (s Sequencer) committedToken(token []byte) (bool, error) {
+	tx, err := s.logStorage.Begin()
+
+	if err != nil {
+		return false, err
+	}
+
+	tokenStore, ok := tx.(storage.CommitTokenStore)
+
+	if !ok {
+		tx.Rollback()
+		return false, errors.New("storage can't read commit tokens")
+	}
+
+	latest, err := tokenStore.LatestCommitToken()
+
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	// Nothing was written so committing can't fail in a way that matters
+	tx.Commit()
+	return bytes.Equal(latest, token), nil
 }
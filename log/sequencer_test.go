@@ -1,34 +1,38 @@
 package log
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"errors"
 	"fmt"
+	"math"
 	"testing"
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
 	"github.com/google/trillian/crypto"
 	"github.com/google/trillian/merkle"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/testonly"
 	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
 )
 
 // Long duration to prevent root signing kicking in for tests where we're only testing
 // sequencing
 const tenYears time.Duration = time.Hour * 24 * 365 * 10
 
-// Func that says root never expires, so we can be sure tests will only sign / sequence when we
-// expect them to
-func rootNeverExpiresFunc(trillian.SignedLogRoot) bool {
-	return false
-}
-
 // These can be shared between tests as they're never modified
 var testLeaf16Hash = trillian.Hash{0, 1, 2, 3, 4, 5}
 var testLeaf16 = trillian.LogLeaf{Leaf: trillian.Leaf{LeafHash: testLeaf16Hash, LeafValue: nil, ExtraData: nil}, SequenceNumber: 16}
 
+// testLeaf16 as it will be after sequencing at fakeTimeForTest
+var testLeaf16Integrated = trillian.LogLeaf{Leaf: trillian.Leaf{LeafHash: testLeaf16Hash, LeafValue: nil, ExtraData: nil}, SequenceNumber: 16, IntegrateTimestampNanos: fakeTimeForTest.UnixNano()}
+
 // RootHash can't be nil because that's how the sequencer currently detects that there was no stored tree head.
 var testRoot16 = trillian.SignedLogRoot{TreeSize: 16, TreeRevision: 5, RootHash: []byte{}}
 
@@ -62,6 +66,17 @@ var expectedSignedRoot16 = trillian.SignedLogRoot{
 	Signature:      &trillian.DigitallySigned{Signature: []byte("signed")},
 }
 
+// expectedSignedRoot16WithMetadata is expectedSignedRoot16 with metadata supplied by a hook
+var expectedSignedRoot16WithMetadata = trillian.SignedLogRoot{
+	TimestampNanos: fakeTimeForTest.UnixNano(),
+	TreeRevision:   6,
+	TreeSize:       16,
+	RootHash:       testRoot16.RootHash,
+	LogId:          []uint8(nil),
+	Signature:      &trillian.DigitallySigned{Signature: []byte("signed")},
+	Metadata:       []byte("epoch 7"),
+}
+
 // expectedSignedRoot0 is a root for an empty tree
 var expectedSignedRoot0 = trillian.SignedLogRoot{
 	RootHash:       []byte{0xe3, 0xb0, 0xc4, 0x42, 0x98, 0xfc, 0x1c, 0x14, 0x9a, 0xfb, 0xf4, 0xc8, 0x99, 0x6f, 0xb9, 0x24, 0x27, 0xae, 0x41, 0xe4, 0x64, 0x9b, 0x93, 0x4c, 0xa4, 0x95, 0x99, 0x1b, 0x78, 0x52, 0xb8, 0x55},
@@ -100,6 +115,10 @@ type testParameters struct {
 	merkleNodesSet      *[]storage.Node
 	merkleNodesSetError error
 
+	latestCompactTree     *storage.CompactTreeProto
+	storeCompactTree      *storage.CompactTreeProto
+	storeCompactTreeError error
+
 	skipStoreSignedRoot  bool
 	storeSignedRoot      *trillian.SignedLogRoot
 	storeSignedRootError error
@@ -181,6 +200,19 @@ func createTestContext(ctrl *gomock.Controller, params testParameters) testConte
 		mockTx.EXPECT().SetMerkleNodes(testonly.NodeSet(*params.merkleNodesSet)).AnyTimes().Return(params.merkleNodesSetError)
 	}
 
+	if params.latestCompactTree != nil {
+		mockTx.EXPECT().LatestCompactTree().AnyTimes().Return(*params.latestCompactTree, nil)
+	} else {
+		// Nothing stored, the tree is loaded from its nodes
+		mockTx.EXPECT().LatestCompactTree().AnyTimes().Return(storage.CompactTreeProto{}, nil)
+	}
+
+	if params.storeCompactTree != nil {
+		mockTx.EXPECT().StoreCompactTree(*params.storeCompactTree).AnyTimes().Return(params.storeCompactTreeError)
+	} else {
+		mockTx.EXPECT().StoreCompactTree(gomock.Any()).AnyTimes().Return(params.storeCompactTreeError)
+	}
+
 	if !params.skipStoreSignedRoot {
 		if params.storeSignedRoot != nil {
 			mockTx.EXPECT().StoreSignedLogRoot(*params.storeSignedRoot).AnyTimes().Return(params.storeSignedRootError)
@@ -214,7 +246,7 @@ func TestBeginTXFails(t *testing.T) {
 	params := testParameters{beginFails: true, skipDequeue: true, skipStoreSignedRoot: true}
 	c := createTestContext(ctrl, params)
 
-	leaves, err := c.sequencer.SequenceBatch(1, rootNeverExpiresFunc)
+	leaves, err := c.sequencer.SequenceBatch(context.Background(), 1)
 	if leaves != 0 {
 		t.Fatalf("Unexpectedly sequenced %d leaves on error", leaves)
 	}
@@ -229,7 +261,7 @@ func TestSequenceWithNothingQueued(t *testing.T) {
 
 	c := createTestContext(ctrl, params)
 
-	leaves, err := c.sequencer.SequenceBatch(1, rootNeverExpiresFunc)
+	leaves, err := c.sequencer.SequenceBatch(context.Background(), 1)
 	if leaves != 0 {
 		t.Fatalf("Unexpectedly sequenced %d leaves on error", leaves)
 	}
@@ -243,16 +275,139 @@ func TestDequeueError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
-	params := testParameters{dequeueLimit: 1, shouldRollback: true, dequeuedError: errors.New("dequeue")}
+	params := testParameters{dequeueLimit: 1, shouldRollback: true, dequeuedError: errors.New("dequeue"),
+		latestSignedRoot: &testRoot16}
 	c := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(1, rootNeverExpiresFunc)
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1)
 	testonly.EnsureErrorContains(t, err, "dequeue")
 	if leafCount != 0 {
 		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
 	}
 }
 
+func TestBatchLimit(t *testing.T) {
+	var tests = []struct {
+		signEveryNLeaves int64
+		limit            int
+		treeSize         int64
+		want             int
+	}{
+		{0, 50, 16, 50},
+		{100, 50, 16, 50},
+		{20, 50, 16, 4},
+		{16, 50, 16, 16},
+		{10, 3, 16, 3},
+		{10, 50, 0, 10},
+	}
+
+	for _, test := range tests {
+		s := Sequencer{}
+		s.SetSignEveryNLeaves(test.signEveryNLeaves)
+
+		if got := s.batchLimit(test.limit, test.treeSize); got != test.want {
+			t.Errorf("batchLimit(%d, %d) with n=%d: got %d, want %d", test.limit, test.treeSize, test.signEveryNLeaves, got, test.want)
+		}
+	}
+}
+
+func TestBatchLimitAtTreeCapacity(t *testing.T) {
+	var tests = []struct {
+		treeDepth        int
+		signEveryNLeaves int64
+		limit            int
+		treeSize         int64
+		want             int
+	}{
+		{8, 0, 50, 200, 50},
+		{8, 0, 50, 240, 16},
+		{8, 0, 50, 255, 1},
+		{8, 10, 50, 250, 6},
+		{8, 5, 50, 252, 3},
+		{64, 0, 50, math.MaxInt64 - 10, 10},
+	}
+
+	for _, test := range tests {
+		s := Sequencer{}
+		s.SetTreeDepth(test.treeDepth)
+		s.SetSignEveryNLeaves(test.signEveryNLeaves)
+
+		if got := s.batchLimit(test.limit, test.treeSize); got != test.want {
+			t.Errorf("batchLimit(%d, %d) with depth=%d n=%d: got %d, want %d", test.limit, test.treeSize, test.treeDepth, test.signEveryNLeaves, got, test.want)
+		}
+	}
+}
+
+func TestSequenceBatchTreeFull(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// A tree of depth 4 can only hold 16 leaves
+	params := testParameters{skipDequeue: true, shouldRollback: true, latestSignedRoot: &testRoot16, skipStoreSignedRoot: true}
+	c := createTestContext(ctrl, params)
+	c.sequencer.SetTreeDepth(4)
+
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 50)
+	if err != ErrTreeFull {
+		t.Fatalf("SequenceBatch()=%v, want ErrTreeFull", err)
+	}
+	if leafCount != 0 {
+		t.Fatalf("Unexpectedly sequenced %d leaves", leafCount)
+	}
+}
+
+// pausedTX is a mock transaction for a log whose sequencing has been paused
+type pausedTX struct {
+	*storage.MockLogTX
+}
+
+func (pausedTX) SequencingEnabled() (bool, error) {
+	return false, nil
+}
+
+func (pausedTX) SetSequencingEnabled(enabled bool) error {
+	return nil
+}
+
+func TestSequencingPaused(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Nothing is read from a paused log and no root is signed
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().Begin().Times(2).Return(pausedTX{mockTx}, nil)
+	mockTx.EXPECT().Rollback().Times(2).Return(nil)
+
+	sequencer := NewSequencer(merkle.NewRFC6962TreeHasher(trillian.NewSHA256()), util.FakeTimeSource{fakeTimeForTest}, mockStorage, crypto.NewMockKeyManager(ctrl))
+
+	if leafCount, err := sequencer.SequenceBatch(context.Background(), 50); err != ErrSequencingPaused || leafCount != 0 {
+		t.Errorf("SequenceBatch()=%d, %v, want 0, ErrSequencingPaused", leafCount, err)
+	}
+
+	if err := sequencer.SignRoot(context.Background()); err != ErrSequencingPaused {
+		t.Errorf("SignRoot()=%v, want ErrSequencingPaused", err)
+	}
+}
+
+func TestSequenceBatchStopsAtSignEveryNLeaves(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The tree has 16 leaves so only 4 can be added before the root needed at 20
+	params := testParameters{dequeueLimit: 4, dequeuedLeaves: []trillian.LogLeaf{}, latestSignedRoot: &testRoot16, shouldCommit: true}
+	c := createTestContext(ctrl, params)
+	c.sequencer.SetSignEveryNLeaves(20)
+
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 50)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if leafCount != 0 {
+		t.Fatalf("Unexpectedly sequenced %d leaves", leafCount)
+	}
+}
+
 func TestLatestRootError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -262,7 +417,7 @@ func TestLatestRootError(t *testing.T) {
 		latestSignedRoot: &testRoot16, latestSignedRootError: errors.New("root")}
 	c := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(1, rootNeverExpiresFunc)
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1)
 	if leafCount != 0 {
 		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
 	}
@@ -274,31 +429,55 @@ func TestUpdateSequencedLeavesError(t *testing.T) {
 	defer ctrl.Finish()
 
 	leaves := []trillian.LogLeaf{getLeaf42()}
-	updatedLeaves := []trillian.LogLeaf{testLeaf16}
+	updatedLeaves := []trillian.LogLeaf{testLeaf16Integrated}
 	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, dequeueLimit: 1, shouldRollback: true, dequeuedLeaves: leaves,
 		latestSignedRoot: &testRoot16, updatedLeaves: &updatedLeaves,
 		updatedLeavesError: errors.New("unsequenced")}
 	c := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(1, rootNeverExpiresFunc)
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1)
 	if leafCount != 0 {
 		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
 	}
 	testonly.EnsureErrorContains(t, err, "unsequenced")
 }
 
+func TestSequenceBatchOrdersByPriority(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	backfill := trillian.LogLeaf{Leaf: trillian.Leaf{LeafHash: trillian.Hash{1}}, Priority: trillian.LeafPriorityLow}
+	live := trillian.LogLeaf{Leaf: trillian.Leaf{LeafHash: trillian.Hash{2}}, Priority: trillian.LeafPriorityNormal}
+	leaves := []trillian.LogLeaf{backfill, live}
+
+	// The live leaf goes first even though it was dequeued second
+	integrated := fakeTimeForTest.UnixNano()
+	live.SequenceNumber, live.IntegrateTimestampNanos = 16, integrated
+	backfill.SequenceNumber, backfill.IntegrateTimestampNanos = 17, integrated
+	updatedLeaves := []trillian.LogLeaf{live, backfill}
+
+	// Stop at the update, the ordering is all that matters here
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, dequeueLimit: 2, shouldRollback: true, dequeuedLeaves: leaves,
+		latestSignedRoot: &testRoot16, updatedLeaves: &updatedLeaves,
+		updatedLeavesError: errors.New("stop")}
+	c := createTestContext(ctrl, params)
+
+	_, err := c.sequencer.SequenceBatch(context.Background(), 2)
+	testonly.EnsureErrorContains(t, err, "stop")
+}
+
 func TestSetMerkleNodesError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	leaves := []trillian.LogLeaf{getLeaf42()}
-	updatedLeaves := []trillian.LogLeaf{testLeaf16}
+	updatedLeaves := []trillian.LogLeaf{testLeaf16Integrated}
 	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, dequeueLimit: 1, shouldRollback: true, dequeuedLeaves: leaves,
 		latestSignedRoot: &testRoot16, updatedLeaves: &updatedLeaves, merkleNodesSet: &updatedNodes,
 		merkleNodesSetError: errors.New("setmerklenodes")}
 	c := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(1, rootNeverExpiresFunc)
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1)
 	if leafCount != 0 {
 		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
 	}
@@ -310,7 +489,7 @@ func TestStoreSignedRootError(t *testing.T) {
 	defer ctrl.Finish()
 
 	leaves := []trillian.LogLeaf{getLeaf42()}
-	updatedLeaves := []trillian.LogLeaf{testLeaf16}
+	updatedLeaves := []trillian.LogLeaf{testLeaf16Integrated}
 	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, dequeueLimit: 1, shouldRollback: true, dequeuedLeaves: leaves,
 		latestSignedRoot: &testRoot16, updatedLeaves: &updatedLeaves, merkleNodesSet: &updatedNodes,
 		storeSignedRoot:      nil,
@@ -319,7 +498,7 @@ func TestStoreSignedRootError(t *testing.T) {
 		signingResult: []byte("signed")}
 	c := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(1, rootNeverExpiresFunc)
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1)
 	if leafCount != 0 {
 		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
 	}
@@ -331,7 +510,7 @@ func TestStoreSignedRootKeyManagerFails(t *testing.T) {
 	defer ctrl.Finish()
 
 	leaves := []trillian.LogLeaf{getLeaf42()}
-	updatedLeaves := []trillian.LogLeaf{testLeaf16}
+	updatedLeaves := []trillian.LogLeaf{testLeaf16Integrated}
 	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, dequeueLimit: 1, shouldRollback: true, dequeuedLeaves: leaves,
 		latestSignedRoot: &testRoot16, updatedLeaves: &updatedLeaves, merkleNodesSet: &updatedNodes,
 		storeSignedRoot: nil,
@@ -339,7 +518,7 @@ func TestStoreSignedRootKeyManagerFails(t *testing.T) {
 		keyManagerError: errors.New("keymanagerfailed")}
 	c := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(1, rootNeverExpiresFunc)
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1)
 	if leafCount != 0 {
 		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
 	}
@@ -351,7 +530,7 @@ func TestStoreSignedRootSignerFails(t *testing.T) {
 	defer ctrl.Finish()
 
 	leaves := []trillian.LogLeaf{getLeaf42()}
-	updatedLeaves := []trillian.LogLeaf{testLeaf16}
+	updatedLeaves := []trillian.LogLeaf{testLeaf16Integrated}
 	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, dequeueLimit: 1, shouldRollback: true, dequeuedLeaves: leaves,
 		latestSignedRoot: &testRoot16, updatedLeaves: &updatedLeaves, merkleNodesSet: &updatedNodes,
 		storeSignedRoot: nil,
@@ -360,7 +539,7 @@ func TestStoreSignedRootSignerFails(t *testing.T) {
 		signingError:    errors.New("signerfailed")}
 	c := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(1, rootNeverExpiresFunc)
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1)
 	if leafCount != 0 {
 		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
 	}
@@ -372,7 +551,7 @@ func TestCommitFails(t *testing.T) {
 	defer ctrl.Finish()
 
 	leaves := []trillian.LogLeaf{getLeaf42()}
-	updatedLeaves := []trillian.LogLeaf{testLeaf16}
+	updatedLeaves := []trillian.LogLeaf{testLeaf16Integrated}
 
 	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, dequeueLimit: 1, shouldCommit: true, commitFails: true,
 		commitError: errors.New("commit"), dequeuedLeaves: leaves,
@@ -382,7 +561,7 @@ func TestCommitFails(t *testing.T) {
 		signingResult: []byte("signed")}
 	c := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(1, rootNeverExpiresFunc)
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1)
 	if leafCount != 0 {
 		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
 	}
@@ -396,7 +575,7 @@ func TestSequenceBatch(t *testing.T) {
 	defer ctrl.Finish()
 
 	leaves := []trillian.LogLeaf{getLeaf42()}
-	updatedLeaves := []trillian.LogLeaf{testLeaf16}
+	updatedLeaves := []trillian.LogLeaf{testLeaf16Integrated}
 	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, dequeueLimit: 1, shouldCommit: true,
 		dequeuedLeaves: leaves, latestSignedRoot: &testRoot16,
 		updatedLeaves: &updatedLeaves, merkleNodesSet: &updatedNodes,
@@ -405,7 +584,7 @@ func TestSequenceBatch(t *testing.T) {
 		signingResult: []byte("signed")}
 	c := createTestContext(ctrl, params)
 
-	leafCount, err := c.sequencer.SequenceBatch(1, rootNeverExpiresFunc)
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("Expected sequencing to succeed, but got err: %v", err)
 	}
@@ -414,6 +593,125 @@ func TestSequenceBatch(t *testing.T) {
 	}
 }
 
+func TestSequenceBatchStoresCompactTree(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	leaves := []trillian.LogLeaf{getLeaf42()}
+	updatedLeaves := []trillian.LogLeaf{testLeaf16Integrated}
+	// At size 17 there's the new leaf and the root of the first 16
+	compactTree17 := storage.CompactTreeProto{TreeSize: 17, Nodes: [][]byte{testLeaf16Hash, nil, nil, nil, testRoot16.RootHash}}
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, dequeueLimit: 1, shouldCommit: true,
+		dequeuedLeaves: leaves, latestSignedRoot: &testRoot16,
+		updatedLeaves: &updatedLeaves, merkleNodesSet: &updatedNodes,
+		storeCompactTree: &compactTree17,
+		storeSignedRoot:  &expectedSignedRoot, setupSigner: true,
+		dataToSign:    []byte{0x4f, 0x21, 0x7d, 0x10, 0xe2, 0x6, 0x9f, 0x10, 0x4d, 0x7e, 0x42, 0x75, 0x24, 0x3b, 0xb3, 0x5b, 0x63, 0xa6, 0x7, 0x8d, 0x6c, 0x97, 0x23, 0x4, 0x8, 0x5e, 0x3b, 0xe2, 0xc4, 0xb8, 0x7a, 0xa2},
+		signingResult: []byte("signed")}
+	c := createTestContext(ctrl, params)
+
+	if _, err := c.sequencer.SequenceBatch(context.Background(), 1); err != nil {
+		t.Fatalf("Expected sequencing to succeed, but got err: %v", err)
+	}
+}
+
+func TestStoreCompactTreeError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	leaves := []trillian.LogLeaf{getLeaf42()}
+	updatedLeaves := []trillian.LogLeaf{testLeaf16Integrated}
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, dequeueLimit: 1, shouldRollback: true,
+		dequeuedLeaves: leaves, latestSignedRoot: &testRoot16,
+		updatedLeaves: &updatedLeaves, merkleNodesSet: &updatedNodes,
+		storeCompactTreeError: errors.New("compact"), skipStoreSignedRoot: true}
+	c := createTestContext(ctrl, params)
+
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1)
+	if leafCount != 0 {
+		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
+	}
+	testonly.EnsureErrorContains(t, err, "compact")
+}
+
+func TestSequenceBatchUsesStoredCompactTree(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+	mt := merkle.NewCompactMerkleTree(hasher)
+
+	for i := 0; i < 5; i++ {
+		mt.AddLeaf([]byte(fmt.Sprintf("leaf %d", i)), func(int, int64, trillian.Hash) {})
+	}
+
+	root5 := trillian.SignedLogRoot{TreeSize: 5, TreeRevision: 5, RootHash: mt.CurrentRoot()}
+	compactTree5 := storage.CompactTreeProto{TreeSize: 5}
+	for _, node := range mt.Nodes() {
+		compactTree5.Nodes = append(compactTree5.Nodes, node)
+	}
+
+	leaf := getLeaf42()
+	mt.AddLeafHash(leaf.LeafHash, func(int, int64, trillian.Hash) {})
+
+	compactTree6 := storage.CompactTreeProto{TreeSize: 6}
+	for _, node := range mt.Nodes() {
+		compactTree6.Nodes = append(compactTree6.Nodes, node)
+	}
+
+	// The tree isn't perfect so loading it from nodes would need GetMerkleNodes, which the
+	// mock doesn't allow
+	params := testParameters{writeRevision: root5.TreeRevision + 1, dequeueLimit: 1, shouldCommit: true,
+		dequeuedLeaves: []trillian.LogLeaf{leaf}, latestSignedRoot: &root5,
+		latestCompactTree: &compactTree5, storeCompactTree: &compactTree6, skipStoreSignedRoot: true}
+	c := createTestContext(ctrl, params)
+
+	mockSigner := crypto.NewMockSigner(ctrl)
+	mockSigner.EXPECT().Sign(gomock.Any(), gomock.Any(), gomock.Any()).Return([]byte("signed"), nil)
+	c.mockKeyManager.EXPECT().Signer().Return(mockSigner, nil)
+	c.mockTx.EXPECT().UpdateSequencedLeaves(gomock.Any()).Return(nil)
+	c.mockTx.EXPECT().SetMerkleNodes(gomock.Any()).Return(nil)
+	c.mockTx.EXPECT().StoreSignedLogRoot(gomock.Any()).Do(func(root trillian.SignedLogRoot) {
+		if got, want := root.RootHash, mt.CurrentRoot(); !bytes.Equal(got, want) {
+			t.Errorf("Stored root hash %x, expected %x", got, want)
+		}
+	}).Return(nil)
+
+	if _, err := c.sequencer.SequenceBatch(context.Background(), 1); err != nil {
+		t.Fatalf("Expected sequencing to succeed, but got err: %v", err)
+	}
+}
+
+func TestSequenceBatchIgnoresMismatchedCompactTree(t *testing.T) {
+	for _, compactTree := range []storage.CompactTreeProto{
+		// Stored for an older root
+		{TreeSize: 8, Nodes: [][]byte{nil, nil, nil, []byte("old root")}},
+		// Right size but doesn't give the latest root
+		{TreeSize: 16, Nodes: [][]byte{nil, nil, nil, nil, []byte("corrupt")}},
+	} {
+		func() {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			compactTree := compactTree
+			leaves := []trillian.LogLeaf{getLeaf42()}
+			updatedLeaves := []trillian.LogLeaf{testLeaf16Integrated}
+			// The tree of size 16 is perfect so it's rebuilt from the root without fetching nodes
+			params := testParameters{writeRevision: testRoot16.TreeRevision + 1, dequeueLimit: 1, shouldCommit: true,
+				dequeuedLeaves: leaves, latestSignedRoot: &testRoot16, latestCompactTree: &compactTree,
+				updatedLeaves: &updatedLeaves, merkleNodesSet: &updatedNodes,
+				storeSignedRoot: &expectedSignedRoot, setupSigner: true,
+				dataToSign:    []byte{0x4f, 0x21, 0x7d, 0x10, 0xe2, 0x6, 0x9f, 0x10, 0x4d, 0x7e, 0x42, 0x75, 0x24, 0x3b, 0xb3, 0x5b, 0x63, 0xa6, 0x7, 0x8d, 0x6c, 0x97, 0x23, 0x4, 0x8, 0x5e, 0x3b, 0xe2, 0xc4, 0xb8, 0x7a, 0xa2},
+				signingResult: []byte("signed")}
+			c := createTestContext(ctrl, params)
+
+			if _, err := c.sequencer.SequenceBatch(context.Background(), 1); err != nil {
+				t.Fatalf("Expected sequencing with compact tree %v to succeed, but got err: %v", compactTree, err)
+			}
+		}()
+	}
+}
+
 func TestSignBeginTxFails(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -421,7 +719,7 @@ func TestSignBeginTxFails(t *testing.T) {
 	params := testParameters{beginFails: true}
 	c := createTestContext(ctrl, params)
 
-	err := c.sequencer.SignRoot()
+	err := c.sequencer.SignRoot(context.Background())
 	testonly.EnsureErrorContains(t, err, "TX")
 }
 
@@ -434,7 +732,7 @@ func TestSignLatestRootFails(t *testing.T) {
 		latestSignedRoot: &testRoot16, latestSignedRootError: errors.New("root")}
 	c := createTestContext(ctrl, params)
 
-	err := c.sequencer.SignRoot()
+	err := c.sequencer.SignRoot(context.Background())
 	testonly.EnsureErrorContains(t, err, "root")
 }
 
@@ -449,7 +747,7 @@ func TestSignedRootKeyManagerFails(t *testing.T) {
 		setupSigner:      true, keyManagerError: errors.New("keymanagerfailed")}
 	c := createTestContext(ctrl, params)
 
-	err := c.sequencer.SignRoot()
+	err := c.sequencer.SignRoot(context.Background())
 	testonly.EnsureErrorContains(t, err, "keymanager")
 }
 
@@ -465,7 +763,7 @@ func TestSignRootSignerFails(t *testing.T) {
 		signingError: errors.New("signerfailed")}
 	c := createTestContext(ctrl, params)
 
-	err := c.sequencer.SignRoot()
+	err := c.sequencer.SignRoot(context.Background())
 	testonly.EnsureErrorContains(t, err, "signer")
 }
 
@@ -482,7 +780,7 @@ func TestSignRootStoreSignedRootFails(t *testing.T) {
 		signingResult: []byte("signed")}
 	c := createTestContext(ctrl, params)
 
-	err := c.sequencer.SignRoot()
+	err := c.sequencer.SignRoot(context.Background())
 	testonly.EnsureErrorContains(t, err, "storesignedroot")
 }
 
@@ -499,7 +797,7 @@ func TestSignRootCommitFails(t *testing.T) {
 		signingResult: []byte("signed")}
 	c := createTestContext(ctrl, params)
 
-	err := c.sequencer.SignRoot()
+	err := c.sequencer.SignRoot(context.Background())
 	testonly.EnsureErrorContains(t, err, "commit")
 }
 
@@ -516,16 +814,124 @@ func TestSignRoot(t *testing.T) {
 		signingResult:    []byte("signed"), shouldCommit: true}
 	c := createTestContext(ctrl, params)
 
-	if err := c.sequencer.SignRoot(); err != nil {
+	if err := c.sequencer.SignRoot(context.Background()); err != nil {
 		t.Fatalf("Expected signing to succeed, but got err: %v", err)
 	}
 }
 
-func TestSignRootNoExistingRoot(t *testing.T) {
+func TestSignRootStoresCompactTree(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// SignRoot stores the compact tree like SequenceBatch, it's the same as before at size 16
+	compactTree16 := storage.CompactTreeProto{TreeSize: 16, Nodes: [][]byte{nil, nil, nil, nil, testRoot16.RootHash}}
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1,
+		shouldRollback:   true,
+		latestSignedRoot: &testRoot16,
+		storeCompactTree: &compactTree16,
+		storeSignedRoot:  &expectedSignedRoot16,
+		setupSigner:      true,
+		dataToSign:       []byte{0x95, 0x46, 0xdc, 0x25, 0xfb, 0x74, 0x41, 0x4b, 0x50, 0x2e, 0xb0, 0x93, 0x99, 0xbb, 0x5e, 0xf6, 0x57, 0x58, 0xb9, 0x7a, 0x3a, 0x8f, 0xae, 0x35, 0xe1, 0xf6, 0xcd, 0x6c, 0x2a, 0xe6, 0x27, 0xbe},
+		signingResult:    []byte("signed"), shouldCommit: true}
+	c := createTestContext(ctrl, params)
+
+	if err := c.sequencer.SignRoot(context.Background()); err != nil {
+		t.Fatalf("Expected signing to succeed, but got err: %v", err)
+	}
+}
+
+func TestSignRootWriteRevisionMismatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The new root must be at the revision after the latest one, as for SequenceBatch
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 2,
+		shouldRollback: true, latestSignedRoot: &testRoot16, skipStoreSignedRoot: true}
+	c := createTestContext(ctrl, params)
+
+	err := c.sequencer.SignRoot(context.Background())
+	testonly.EnsureErrorContains(t, err, "writeRevision")
+}
+
+func TestSequenceBatchWriteRevisionMismatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 2, dequeueLimit: 1, shouldRollback: true,
+		dequeuedLeaves: []trillian.LogLeaf{getLeaf42()}, latestSignedRoot: &testRoot16, skipStoreSignedRoot: true}
+	c := createTestContext(ctrl, params)
+
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1)
+	if leafCount != 0 {
+		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
+	}
+	testonly.EnsureErrorContains(t, err, "writeRevision")
+}
+
+func TestSignRootIfOlderThan(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	params := testParameters{writeRevision: testRoot16.TreeRevision + 1,
+		shouldRollback:   true,
+		latestSignedRoot: &testRoot16,
+		storeSignedRoot:  &expectedSignedRoot16,
+		setupSigner:      true,
+		dataToSign:       []byte{0x95, 0x46, 0xdc, 0x25, 0xfb, 0x74, 0x41, 0x4b, 0x50, 0x2e, 0xb0, 0x93, 0x99, 0xbb, 0x5e, 0xf6, 0x57, 0x58, 0xb9, 0x7a, 0x3a, 0x8f, 0xae, 0x35, 0xe1, 0xf6, 0xcd, 0x6c, 0x2a, 0xe6, 0x27, 0xbe},
+		signingResult:    []byte("signed"), shouldCommit: true}
+	c := createTestContext(ctrl, params)
+
+	// testRoot16 was created at the epoch so it has expired
+	rootTime, err := c.sequencer.SignRootIfOlderThan(context.Background(), time.Hour)
+
+	if err != nil {
+		t.Fatalf("Expected signing to succeed, but got err: %v", err)
+	}
+
+	if !rootTime.Equal(fakeTimeForTest) {
+		t.Errorf("Got root time %v, expected the time the new root was signed %v", rootTime, fakeTimeForTest)
+	}
+}
+
+func TestSignRootIfOlderThanFreshRoot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	freshRoot := testRoot16
+	freshRoot.TimestampNanos = fakeTimeForTest.Add(-time.Minute).UnixNano()
+
+	// Nothing must be signed or stored
+	params := testParameters{skipDequeue: true, skipStoreSignedRoot: true, shouldCommit: true, latestSignedRoot: &freshRoot}
+	c := createTestContext(ctrl, params)
+
+	rootTime, err := c.sequencer.SignRootIfOlderThan(context.Background(), time.Hour)
+
+	if err != nil {
+		t.Fatalf("SignRootIfOlderThan()=%v", err)
+	}
+
+	if got, want := rootTime.UnixNano(), freshRoot.TimestampNanos; got != want {
+		t.Errorf("Got root time %d, expected the existing root's %d", got, want)
+	}
+}
+
+func TestSignRootIfOlderThanLatestRootFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{skipDequeue: true, skipStoreSignedRoot: true, shouldRollback: true,
+		latestSignedRoot: &testRoot16, latestSignedRootError: errors.New("root")}
+	c := createTestContext(ctrl, params)
+
+	_, err := c.sequencer.SignRootIfOlderThan(context.Background(), time.Hour)
+	testonly.EnsureErrorContains(t, err, "root")
+}
+
+func TestSignRootNoExistingRoot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{writeRevision: 1,
 		shouldRollback:   true,
 		latestSignedRoot: &trillian.SignedLogRoot{},
 		storeSignedRoot:  &expectedSignedRoot0,
@@ -534,7 +940,598 @@ func TestSignRootNoExistingRoot(t *testing.T) {
 		signingResult:    []byte("signed"), shouldCommit: true}
 	c := createTestContext(ctrl, params)
 
-	if err := c.sequencer.SignRoot(); err != nil {
+	if err := c.sequencer.SignRoot(context.Background()); err != nil {
+		t.Fatalf("Expected signing to succeed, but got err: %v", err)
+	}
+}
+
+func TestSignRootWithMetadata(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1,
+		shouldRollback:   true,
+		latestSignedRoot: &testRoot16,
+		storeSignedRoot:  &expectedSignedRoot16WithMetadata,
+		setupSigner:      true,
+		dataToSign:       []byte{0xb1, 0xa6, 0x4e, 0x8d, 0xc2, 0x42, 0xc, 0x6d, 0x33, 0x23, 0x3d, 0xe7, 0xaf, 0x7d, 0x40, 0x27, 0xc0, 0x2b, 0x41, 0x0, 0x7f, 0xf7, 0xe3, 0x8c, 0xf5, 0x9c, 0xc6, 0x2e, 0xf6, 0x1a, 0x8b, 0xca},
+		signingResult:    []byte("signed"), shouldCommit: true}
+	c := createTestContext(ctrl, params)
+
+	c.sequencer.SetRootMetadata(func(root trillian.SignedLogRoot) ([]byte, error) {
+		if got, want := root.TreeRevision, expectedSignedRoot16.TreeRevision; got != want {
+			t.Errorf("Metadata hook got root with revision %d, expected %d", got, want)
+		}
+		return []byte("epoch 7"), nil
+	})
+
+	if err := c.sequencer.SignRoot(context.Background()); err != nil {
+		t.Fatalf("Expected signing to succeed, but got err: %v", err)
+	}
+}
+
+func TestSignRootMetadataFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1,
+		shouldRollback:      true,
+		latestSignedRoot:    &testRoot16,
+		skipStoreSignedRoot: true}
+	c := createTestContext(ctrl, params)
+
+	c.sequencer.SetRootMetadata(func(trillian.SignedLogRoot) ([]byte, error) {
+		return nil, errors.New("metadata")
+	})
+
+	err := c.sequencer.SignRoot(context.Background())
+	testonly.EnsureErrorContains(t, err, "metadata")
+}
+
+func TestSignRootAudited(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1,
+		shouldRollback:   true,
+		latestSignedRoot: &testRoot16,
+		storeSignedRoot:  &expectedSignedRoot16,
+		setupSigner:      true,
+		dataToSign:       []byte{0x95, 0x46, 0xdc, 0x25, 0xfb, 0x74, 0x41, 0x4b, 0x50, 0x2e, 0xb0, 0x93, 0x99, 0xbb, 0x5e, 0xf6, 0x57, 0x58, 0xb9, 0x7a, 0x3a, 0x8f, 0xae, 0x35, 0xe1, 0xf6, 0xcd, 0x6c, 0x2a, 0xe6, 0x27, 0xbe},
+		signingResult:    []byte("signed"), shouldCommit: true}
+	c := createTestContext(ctrl, params)
+
+	audited := 0
+	c.sequencer.SetRootAudit(func(root trillian.SignedLogRoot) error {
+		if !proto.Equal(&root, &expectedSignedRoot16) {
+			t.Errorf("Audit hook got root %v, expected %v", root, expectedSignedRoot16)
+		}
+		audited++
+		return nil
+	})
+
+	if err := c.sequencer.SignRoot(context.Background()); err != nil {
+		t.Fatalf("Expected signing to succeed, but got err: %v", err)
+	}
+
+	if got, want := audited, 1; got != want {
+		t.Fatalf("Audit hook called %d times, expected %d", got, want)
+	}
+}
+
+func TestSignRootAuditFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The root must not be stored if it can't be audited
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1,
+		shouldRollback:      true,
+		latestSignedRoot:    &testRoot16,
+		skipStoreSignedRoot: true,
+		setupSigner:         true,
+		dataToSign:          []byte{0x95, 0x46, 0xdc, 0x25, 0xfb, 0x74, 0x41, 0x4b, 0x50, 0x2e, 0xb0, 0x93, 0x99, 0xbb, 0x5e, 0xf6, 0x57, 0x58, 0xb9, 0x7a, 0x3a, 0x8f, 0xae, 0x35, 0xe1, 0xf6, 0xcd, 0x6c, 0x2a, 0xe6, 0x27, 0xbe},
+		signingResult:       []byte("signed")}
+	c := createTestContext(ctrl, params)
+
+	c.sequencer.SetRootAudit(func(trillian.SignedLogRoot) error {
+		return errors.New("audit")
+	})
+
+	err := c.sequencer.SignRoot(context.Background())
+	testonly.EnsureErrorContains(t, err, "audit")
+}
+
+func TestSignRootStoredHook(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1,
+		shouldRollback:   true,
+		latestSignedRoot: &testRoot16,
+		storeSignedRoot:  &expectedSignedRoot16,
+		setupSigner:      true,
+		dataToSign:       []byte{0x95, 0x46, 0xdc, 0x25, 0xfb, 0x74, 0x41, 0x4b, 0x50, 0x2e, 0xb0, 0x93, 0x99, 0xbb, 0x5e, 0xf6, 0x57, 0x58, 0xb9, 0x7a, 0x3a, 0x8f, 0xae, 0x35, 0xe1, 0xf6, 0xcd, 0x6c, 0x2a, 0xe6, 0x27, 0xbe},
+		signingResult:    []byte("signed"), shouldCommit: true}
+	c := createTestContext(ctrl, params)
+
+	var stored []trillian.SignedLogRoot
+	c.sequencer.SetRootStored(func(root trillian.SignedLogRoot) {
+		stored = append(stored, root)
+	})
+
+	if err := c.sequencer.SignRoot(context.Background()); err != nil {
+		t.Fatalf("Expected signing to succeed, but got err: %v", err)
+	}
+
+	if len(stored) != 1 || !proto.Equal(&stored[0], &expectedSignedRoot16) {
+		t.Fatalf("Stored hook got roots %v, expected %v", stored, expectedSignedRoot16)
+	}
+}
+
+func TestSignRootCommitFailsNotStored(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1,
+		shouldCommit: true, commitFails: true,
+		commitError:      errors.New("commit"),
+		latestSignedRoot: &testRoot16,
+		storeSignedRoot:  nil, setupSigner: true,
+		dataToSign:    []byte{0x95, 0x46, 0xdc, 0x25, 0xfb, 0x74, 0x41, 0x4b, 0x50, 0x2e, 0xb0, 0x93, 0x99, 0xbb, 0x5e, 0xf6, 0x57, 0x58, 0xb9, 0x7a, 0x3a, 0x8f, 0xae, 0x35, 0xe1, 0xf6, 0xcd, 0x6c, 0x2a, 0xe6, 0x27, 0xbe},
+		signingResult: []byte("signed")}
+	c := createTestContext(ctrl, params)
+
+	// A root that wasn't committed must not be reported
+	c.sequencer.SetRootStored(func(root trillian.SignedLogRoot) {
+		t.Errorf("Stored hook called with uncommitted root %v", root)
+	})
+
+	err := c.sequencer.SignRoot(context.Background())
+	testonly.EnsureErrorContains(t, err, "commit")
+}
+
+// commitTokens holds the commit tokens of a tokenStorage
+type commitTokens struct {
+	pending, latest []byte
+	// ackLost makes commits that fail take effect anyway, as if only the acknowledgment was lost
+	ackLost bool
+	// readErr is returned by LatestCommitToken
+	readErr error
+}
+
+// tokenTX is a mock transaction for storage that records commit tokens
+type tokenTX struct {
+	*storage.MockLogTX
+	tokens *commitTokens
+}
+
+func (t tokenTX) StoreCommitToken(token []byte) error {
+	t.tokens.pending = token
+	return nil
+}
+
+func (t tokenTX) LatestCommitToken() ([]byte, error) {
+	return t.tokens.latest, t.tokens.readErr
+}
+
+func (t tokenTX) Commit() error {
+	err := t.MockLogTX.Commit()
+
+	if err == nil || t.tokens.ackLost {
+		t.tokens.latest = t.tokens.pending
+	}
+
+	return err
+}
+
+// tokenStorage begins tokenTXs
+type tokenStorage struct {
+	*storage.MockLogStorage
+	tx tokenTX
+}
+
+func (s tokenStorage) Begin() (storage.LogTX, error) {
+	return s.tx, nil
+}
+
+func TestSignRootCommitFailsWithCommitTokens(t *testing.T) {
+	for _, test := range []struct {
+		desc    string
+		tokens  commitTokens
+		wantErr string
+	}{
+		{desc: "ack lost", tokens: commitTokens{ackLost: true}},
+		{desc: "not committed", wantErr: "commit"},
+		{desc: "token unreadable", tokens: commitTokens{ackLost: true, readErr: errors.New("read")}, wantErr: ErrCommitUnknown.Error()},
+	} {
+		ctrl := gomock.NewController(t)
+
+		params := testParameters{writeRevision: testRoot16.TreeRevision + 1,
+			shouldCommit: true, commitFails: true, shouldRollback: true,
+			commitError:      errors.New("commit"),
+			latestSignedRoot: &testRoot16,
+			storeSignedRoot:  &expectedSignedRoot16, setupSigner: true,
+			dataToSign:    []byte{0x95, 0x46, 0xdc, 0x25, 0xfb, 0x74, 0x41, 0x4b, 0x50, 0x2e, 0xb0, 0x93, 0x99, 0xbb, 0x5e, 0xf6, 0x57, 0x58, 0xb9, 0x7a, 0x3a, 0x8f, 0xae, 0x35, 0xe1, 0xf6, 0xcd, 0x6c, 0x2a, 0xe6, 0x27, 0xbe},
+			signingResult: []byte("signed")}
+		c := createTestContext(ctrl, params)
+		tokens := test.tokens
+		logStorage := tokenStorage{c.mockStorage, tokenTX{c.mockTx, &tokens}}
+		sequencer := NewSequencer(merkle.NewRFC6962TreeHasher(trillian.NewSHA256()), util.FakeTimeSource{fakeTimeForTest}, logStorage, c.mockKeyManager)
+
+		// The root is only reported if it was committed
+		var stored []trillian.SignedLogRoot
+		sequencer.SetRootStored(func(root trillian.SignedLogRoot) {
+			stored = append(stored, root)
+		})
+
+		err := sequencer.SignRoot(context.Background())
+
+		if len(test.wantErr) == 0 {
+			if err != nil {
+				t.Errorf("%s: SignRoot()=%v, want nil", test.desc, err)
+			}
+
+			if len(stored) != 1 {
+				t.Errorf("%s: Stored hook got %d roots, expected 1", test.desc, len(stored))
+			}
+		} else {
+			testonly.EnsureErrorContains(t, err, test.wantErr)
+
+			if len(stored) != 0 {
+				t.Errorf("%s: Stored hook got uncommitted roots %v", test.desc, stored)
+			}
+		}
+
+		if len(tokens.pending) != commitTokenSize {
+			t.Errorf("%s: Stored commit token %x, expected %d bytes", test.desc, tokens.pending, commitTokenSize)
+		}
+
+		ctrl.Finish()
+	}
+}
+
+func TestSequenceBatchMetadataFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	leaves := []trillian.LogLeaf{getLeaf42()}
+	updatedLeaves := []trillian.LogLeaf{testLeaf16Integrated}
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, dequeueLimit: 1, shouldRollback: true,
+		dequeuedLeaves: leaves, latestSignedRoot: &testRoot16,
+		updatedLeaves: &updatedLeaves, merkleNodesSet: &updatedNodes,
+		skipStoreSignedRoot: true}
+	c := createTestContext(ctrl, params)
+
+	c.sequencer.SetRootMetadata(func(trillian.SignedLogRoot) ([]byte, error) {
+		return nil, errors.New("metadata")
+	})
+
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1)
+	if leafCount != 0 {
+		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
+	}
+	testonly.EnsureErrorContains(t, err, "metadata")
+}
+
+// signTestRoot signs a root with key in the same way as the sequencer
+func signTestRoot(t *testing.T, key *ecdsa.PrivateKey, root trillian.SignedLogRoot) *trillian.SignedLogRoot {
+	signature, err := crypto.NewTrillianSigner(trillian.NewSHA256(), trillian.SignatureAlgorithm_ECDSA, key).SignLogRoot(root)
+
+	if err != nil {
+		t.Fatalf("Failed to sign test root: %v", err)
+	}
+
+	root.Signature = &signature
+	return &root
+}
+
+func generateTestKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	return key
+}
+
+// perfectTestTree returns the root of a tree of 4 leaves and the node at the top of the tree,
+// which must have the same hash
+func perfectTestTree(t *testing.T, key *ecdsa.PrivateKey) (*trillian.SignedLogRoot, storage.Node) {
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+	mt := merkle.NewCompactMerkleTree(hasher)
+
+	for i := 0; i < 4; i++ {
+		mt.AddLeaf([]byte(fmt.Sprintf("leaf %d", i)), func(int, int64, trillian.Hash) {})
+	}
+
+	root := signTestRoot(t, key, trillian.SignedLogRoot{TreeSize: 4, TreeRevision: 3, RootHash: mt.CurrentRoot(), TimestampNanos: 12345})
+	return root, storage.Node{Hash: mt.CurrentRoot()}
+}
+
+func TestSignRootVerifiesStoredRoot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	key := generateTestKey(t)
+	root4, topNode := perfectTestTree(t, key)
+	params := testParameters{writeRevision: root4.TreeRevision + 1, latestSignedRoot: root4, shouldCommit: true}
+	c := createTestContext(ctrl, params)
+	c.sequencer.SetVerifyRoots(true)
+
+	c.mockKeyManager.EXPECT().Signer().AnyTimes().Return(key, nil)
+	c.mockTx.EXPECT().GetMerkleNodes(root4.TreeRevision, gomock.Any()).Return([]storage.Node{topNode}, nil)
+
+	if err := c.sequencer.SignRoot(context.Background()); err != nil {
+		t.Fatalf("Expected signing to succeed, but got err: %v", err)
+	}
+}
+
+func TestSignRootVerifiesNewLogRoot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// A new log's root has never been signed
+	params := testParameters{writeRevision: 1, latestSignedRoot: &trillian.SignedLogRoot{}, shouldCommit: true}
+	c := createTestContext(ctrl, params)
+	c.sequencer.SetVerifyRoots(true)
+
+	c.mockKeyManager.EXPECT().Signer().AnyTimes().Return(generateTestKey(t), nil)
+
+	if err := c.sequencer.SignRoot(context.Background()); err != nil {
 		t.Fatalf("Expected signing to succeed, but got err: %v", err)
 	}
 }
+
+func TestSignRootRejectsBadStoredRoot(t *testing.T) {
+	key := generateTestKey(t)
+	root4, topNode := perfectTestTree(t, key)
+
+	unsigned := *root4
+	unsigned.Signature = nil
+
+	tampered := *root4
+	tampered.TimestampNanos++
+
+	// Correctly signed but not the root of the tree in storage
+	wrongHash := signTestRoot(t, key, trillian.SignedLogRoot{TreeSize: 4, TreeRevision: 3, RootHash: []byte("not the root"), TimestampNanos: 12345})
+
+	for _, test := range []struct {
+		root        *trillian.SignedLogRoot
+		signer      *ecdsa.PrivateKey
+		fetchesNode bool
+		reason      string
+	}{
+		{root: root4, signer: generateTestKey(t), reason: "signature did not verify"},
+		{root: &unsigned, signer: key, reason: "not signed"},
+		{root: &tampered, signer: key, reason: "signature did not verify"},
+		{root: wrongHash, signer: key, fetchesNode: true, reason: "tree has root hash"},
+	} {
+		func() {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// Nothing must be stored
+			params := testParameters{writeRevision: test.root.TreeRevision + 1, latestSignedRoot: test.root, shouldRollback: true, skipStoreSignedRoot: true}
+			c := createTestContext(ctrl, params)
+			c.sequencer.SetVerifyRoots(true)
+
+			c.mockKeyManager.EXPECT().Signer().AnyTimes().Return(test.signer, nil)
+
+			if test.fetchesNode {
+				c.mockTx.EXPECT().GetMerkleNodes(test.root.TreeRevision, gomock.Any()).Return([]storage.Node{topNode}, nil)
+			}
+
+			err := c.sequencer.SignRoot(context.Background())
+
+			if _, ok := err.(RootVerificationError); !ok {
+				t.Fatalf("Expected a RootVerificationError for %v, but got: %v", test.root, err)
+			}
+
+			testonly.EnsureErrorContains(t, err, test.reason)
+		}()
+	}
+}
+
+func TestSequenceBatchRejectsBadStoredRoot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	root4, _ := perfectTestTree(t, generateTestKey(t))
+
+	// No leaves or nodes must be updated
+	params := testParameters{writeRevision: root4.TreeRevision + 1, dequeueLimit: 1, shouldRollback: true,
+		dequeuedLeaves: []trillian.LogLeaf{getLeaf42()}, latestSignedRoot: root4, skipStoreSignedRoot: true}
+	c := createTestContext(ctrl, params)
+	c.sequencer.SetVerifyRoots(true)
+
+	c.mockKeyManager.EXPECT().Signer().AnyTimes().Return(generateTestKey(t), nil)
+
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 1)
+
+	if leafCount != 0 {
+		t.Fatalf("Unexpectedly sequenced %d leaves on error", leafCount)
+	}
+
+	if _, ok := err.(RootVerificationError); !ok {
+		t.Fatalf("Expected a RootVerificationError, but got: %v", err)
+	}
+}
+
+func TestChunkSize(t *testing.T) {
+	var tests = []struct {
+		budget int64
+		limit  int
+		want   int
+	}{
+		{0, 10000, 10000},
+		{100 * estimatedBytesPerLeaf, 10000, 100},
+		{100 * estimatedBytesPerLeaf, 50, 50},
+		{1, 10000, 1},
+	}
+
+	for _, test := range tests {
+		s := Sequencer{}
+		s.SetBatchMemoryBudget(test.budget)
+
+		if got := s.chunkSize(test.limit); got != test.want {
+			t.Errorf("chunkSize(%d) with budget %d: got %d, want %d", test.limit, test.budget, got, test.want)
+		}
+	}
+}
+
+// chunkedTestLeaves returns n leaves with distinct hashes
+func chunkedTestLeaves(n int) []trillian.LogLeaf {
+	leaves := make([]trillian.LogLeaf, 0, n)
+	for i := 0; i < n; i++ {
+		leaves = append(leaves, trillian.LogLeaf{Leaf: trillian.Leaf{LeafHash: trillian.Hash{byte(i)}}})
+	}
+	return leaves
+}
+
+// sequenceInChunks sequences 11 leaves into an empty tree, dequeueing them chunk at a time and
+// writing nodes whenever flushSize are pending. It returns the nodes written and the root.
+func sequenceInChunks(t *testing.T, chunk int, flushSize int) (map[string]storage.Node, trillian.SignedLogRoot) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	params := testParameters{writeRevision: 1, latestSignedRoot: &trillian.SignedLogRoot{}, shouldCommit: true,
+		skipDequeue: true, skipStoreSignedRoot: true}
+	c := createTestContext(ctrl, params)
+	c.sequencer.SetBatchMemoryBudget(int64(chunk) * estimatedBytesPerLeaf)
+	c.sequencer.SetNodeFlushSize(flushSize)
+
+	leaves := chunkedTestLeaves(11)
+	var dequeues []*gomock.Call
+	chunks := 0
+	for start := 0; start < len(leaves); start += chunk {
+		end := start + chunk
+		if end > len(leaves) {
+			end = len(leaves)
+		}
+		dequeues = append(dequeues, c.mockTx.EXPECT().DequeueLeaves(chunk).Return(leaves[start:end], nil))
+		chunks++
+	}
+	// A full last chunk doesn't show that the queue is empty so there's another dequeue
+	if len(leaves)%chunk == 0 {
+		dequeues = append(dequeues, c.mockTx.EXPECT().DequeueLeaves(chunk).Return(nil, nil))
+	}
+	gomock.InOrder(dequeues...)
+
+	nodes := make(map[string]storage.Node)
+	c.mockTx.EXPECT().SetMerkleNodes(gomock.Any()).AnyTimes().Do(func(set []storage.Node) {
+		for _, node := range set {
+			if _, ok := nodes[node.NodeID.String()]; ok {
+				t.Errorf("Node %s written more than once", node.NodeID.String())
+			}
+			nodes[node.NodeID.String()] = node
+		}
+	}).Return(nil)
+	c.mockTx.EXPECT().UpdateSequencedLeaves(gomock.Any()).Times(chunks).Return(nil)
+
+	mockSigner := crypto.NewMockSigner(ctrl)
+	mockSigner.EXPECT().Sign(gomock.Any(), gomock.Any(), gomock.Any()).Return([]byte("signed"), nil)
+	c.mockKeyManager.EXPECT().Signer().Return(mockSigner, nil)
+
+	var root trillian.SignedLogRoot
+	c.mockTx.EXPECT().StoreSignedLogRoot(gomock.Any()).Do(func(r trillian.SignedLogRoot) {
+		root = r
+	}).Return(nil)
+
+	leafCount, err := c.sequencer.SequenceBatch(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("Expected sequencing to succeed, but got err: %v", err)
+	}
+	if got, want := leafCount, len(leaves); got != want {
+		t.Fatalf("Sequenced %d leaves, expected %d", got, want)
+	}
+
+	return nodes, root
+}
+
+func TestSequenceBatchInChunks(t *testing.T) {
+	wantNodes, wantRoot := sequenceInChunks(t, 100, 0)
+
+	for _, test := range []struct {
+		chunk     int
+		flushSize int
+	}{
+		{chunk: 3, flushSize: 0},
+		{chunk: 11, flushSize: 1},
+		{chunk: 4, flushSize: 5},
+		{chunk: 1, flushSize: 2},
+	} {
+		nodes, root := sequenceInChunks(t, test.chunk, test.flushSize)
+
+		if !bytes.Equal(root.RootHash, wantRoot.RootHash) || root.TreeSize != wantRoot.TreeSize {
+			t.Errorf("Chunks of %d flushing %d: got root %x size %d, want %x size %d", test.chunk, test.flushSize, root.RootHash, root.TreeSize, wantRoot.RootHash, wantRoot.TreeSize)
+		}
+
+		if got, want := len(nodes), len(wantNodes); got != want {
+			t.Errorf("Chunks of %d flushing %d: wrote %d nodes, want %d", test.chunk, test.flushSize, got, want)
+		}
+
+		for id, want := range wantNodes {
+			if got, ok := nodes[id]; !ok || !bytes.Equal(got.Hash, want.Hash) || got.NodeRevision != want.NodeRevision {
+				t.Errorf("Chunks of %d flushing %d: got node %s = %v, want %v", test.chunk, test.flushSize, id, got, want)
+			}
+		}
+	}
+}
+
+func TestSequenceBatchCancelled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Nothing is read once the context has been cancelled
+	params := testParameters{shouldRollback: true, skipDequeue: true, skipStoreSignedRoot: true}
+	c := createTestContext(ctrl, params)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	leafCount, err := c.sequencer.SequenceBatch(ctx, 1)
+	if leafCount != 0 {
+		t.Fatalf("Unexpectedly sequenced %d leaves when cancelled", leafCount)
+	}
+	if err != context.Canceled {
+		t.Fatalf("SequenceBatch()=%v, expected %v", err, context.Canceled)
+	}
+}
+
+func TestSequenceBatchCancelledAfterDequeue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The leaves are dequeued but the batch is rolled back before anything is written
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, shouldRollback: true, skipDequeue: true,
+		latestSignedRoot: &testRoot16, skipStoreSignedRoot: true}
+	c := createTestContext(ctrl, params)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.mockTx.EXPECT().DequeueLeaves(1).Do(func(int) { cancel() }).Return([]trillian.LogLeaf{getLeaf42()}, nil)
+
+	leafCount, err := c.sequencer.SequenceBatch(ctx, 1)
+	if leafCount != 0 {
+		t.Fatalf("Unexpectedly sequenced %d leaves when cancelled", leafCount)
+	}
+	if err != context.Canceled {
+		t.Fatalf("SequenceBatch()=%v, expected %v", err, context.Canceled)
+	}
+}
+
+func TestSignRootCancelled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// No transaction is started once the context has been cancelled
+	params := testParameters{skipDequeue: true, skipStoreSignedRoot: true}
+	c := createTestContext(ctrl, params)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := c.sequencer.SignRoot(ctx); err != context.Canceled {
+		t.Fatalf("SignRoot()=%v, expected %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,190 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/util"
+)
+
+// SequencerPhase is one of the steps of sequencing a batch, see SequencerTimings.
+type SequencerPhase int
+
+const (
+	// PhaseDequeue reads the latest root and dequeues the leaves of the batch
+	PhaseDequeue SequencerPhase = iota
+	// PhaseTreeInit loads the compact Merkle tree of the latest root
+	PhaseTreeInit
+	// PhaseSequence assigns sequence numbers to the leaves and writes them
+	PhaseSequence
+	// PhaseNodeWrite writes the updated tree nodes and the compact tree
+	PhaseNodeWrite
+	// PhaseSign creates, signs and stores the new root
+	PhaseSign
+	// PhaseCommit commits the batch's transaction
+	PhaseCommit
+
+	numSequencerPhases
+)
+
+var sequencerPhaseNames = [numSequencerPhases]string{"dequeue", "tree_init", "sequence", "node_write", "sign", "commit"}
+
+func (p SequencerPhase) String() string {
+	return sequencerPhaseNames[p]
+}
+
+// phaseBucketsMillis are the upper bounds of the phase latency histogram buckets. Phases slower
+// than the last bound are counted in a final overflow bucket.
+var phaseBucketsMillis = []float64{1, 5, 10, 50, 100, 500, 1000, 5000}
+
+// SequencerTimings records how long each phase of sequencing a batch takes, as histograms that
+// can be published on /debug/vars, so that operators can see which phase is responsible when
+// sequencing slows down. Batches that take longer than a threshold are also logged with their
+// breakdown. Only batches that sequence leaves and commit are recorded. It is safe for
+// concurrent use by the sequencers of several logs.
+type SequencerTimings struct {
+	// slowBatch is the duration above which a batch is logged, zero to never log them
+	slowBatch time.Duration
+	// mu guards the fields below it
+	mu sync.Mutex
+	// batches is the number of batches recorded
+	batches int64
+	// phases holds the histogram of each phase
+	phases [numSequencerPhases]phaseHistogram
+}
+
+// phaseHistogram holds the durations of one phase across batches
+type phaseHistogram struct {
+	// buckets counts durations up to each bound in phaseBucketsMillis, with an extra bucket
+	// at the end for slower ones
+	buckets []int64
+	total   time.Duration
+	max     time.Duration
+}
+
+// PhaseStats describes the durations of one phase of the batches a SequencerTimings recorded.
+type PhaseStats struct {
+	TotalMillis float64 `json:"total_millis"`
+	MaxMillis   float64 `json:"max_millis"`
+	// Histogram counts batches by the duration of the phase. The keys are the bucket upper
+	// bounds, e.g. "50ms", with "+Inf" for durations over the last bound.
+	Histogram map[string]int64 `json:"histogram"`
+}
+
+// SequencerTimingStats is a snapshot of a SequencerTimings, keyed by phase name.
+type SequencerTimingStats struct {
+	Batches int64                 `json:"batches"`
+	Phases  map[string]PhaseStats `json:"phases"`
+}
+
+// NewSequencerTimings creates a SequencerTimings that logs batches that take longer than
+// slowBatch. Passing zero disables the logging.
+func NewSequencerTimings(slowBatch time.Duration) *SequencerTimings {
+	t := &SequencerTimings{slowBatch: slowBatch}
+
+	for i := range t.phases {
+		t.phases[i].buckets = make([]int64, len(phaseBucketsMillis)+1)
+	}
+
+	return t
+}
+
+// Stats returns the histograms of the batches recorded so far.
+func (t *SequencerTimings) Stats() SequencerTimingStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := SequencerTimingStats{Batches: t.batches, Phases: make(map[string]PhaseStats)}
+
+	for phase, h := range t.phases {
+		ps := PhaseStats{TotalMillis: millis(h.total), MaxMillis: millis(h.max), Histogram: make(map[string]int64)}
+
+		for i, bound := range phaseBucketsMillis {
+			ps.Histogram[fmt.Sprintf("%gms", bound)] = h.buckets[i]
+		}
+
+		ps.Histogram["+Inf"] = h.buckets[len(phaseBucketsMillis)]
+		stats.Phases[SequencerPhase(phase).String()] = ps
+	}
+
+	return stats
+}
+
+// record adds the phase durations of a batch that sequenced leaves into the tree of logID,
+// logging them if the batch was slow.
+func (t *SequencerTimings) record(logID int64, leaves int, timer *batchTimer) {
+	durations, total := timer.finish()
+
+	t.mu.Lock()
+	t.batches++
+
+	for phase, d := range durations {
+		h := &t.phases[phase]
+		h.buckets[sort.SearchFloat64s(phaseBucketsMillis, millis(d))]++
+		h.total += d
+
+		if d > h.max {
+			h.max = d
+		}
+	}
+
+	t.mu.Unlock()
+
+	if t.slowBatch > 0 && total > t.slowBatch {
+		var breakdown bytes.Buffer
+
+		for phase, d := range durations {
+			fmt.Fprintf(&breakdown, " %s=%v", SequencerPhase(phase), d)
+		}
+
+		glog.Infof("Slow sequencer batch for log %d: %d leaves in %v:%s", logID, leaves, total, breakdown.String())
+	}
+}
+
+// millis returns d in fractional milliseconds
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// batchTimer measures the phases of one batch. Phases can be entered more than once, e.g. when
+// a batch is sequenced in chunks, and their durations add up.
+type batchTimer struct {
+	timeSource util.TimeSource
+	phase      SequencerPhase
+	phaseStart time.Time
+	durations  [numSequencerPhases]time.Duration
+}
+
+// newBatchTimer returns a timer that is in PhaseDequeue from now.
+func newBatchTimer(timeSource util.TimeSource) *batchTimer {
+	return &batchTimer{timeSource: timeSource, phase: PhaseDequeue, phaseStart: timeSource.Now()}
+}
+
+// enter ends the current phase and starts phase. It does nothing if b is nil.
+func (b *batchTimer) enter(phase SequencerPhase) {
+	if b == nil {
+		return
+	}
+
+	now := b.timeSource.Now()
+	b.durations[b.phase] += now.Sub(b.phaseStart)
+	b.phase = phase
+	b.phaseStart = now
+}
+
+// finish ends the current phase and returns the duration of each phase and their total.
+func (b *batchTimer) finish() ([numSequencerPhases]time.Duration, time.Duration) {
+	b.enter(b.phase)
+
+	var total time.Duration
+
+	for _, d := range b.durations {
+		total += d
+	}
+
+	return b.durations, total
+}
@@ -0,0 +1,128 @@
+package log
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+func TestBatchTimerAddsUpPhases(t *testing.T) {
+	timeSource := &util.FakeTimeSource{FakeTime: fakeTimeForTest}
+	timer := newBatchTimer(timeSource)
+
+	// A batch sequenced in two chunks goes back to dequeuing and sequencing
+	for _, step := range []struct {
+		phase   SequencerPhase
+		elapsed time.Duration
+	}{
+		{PhaseDequeue, time.Millisecond * 3},
+		{PhaseTreeInit, time.Millisecond * 5},
+		{PhaseSequence, time.Millisecond * 20},
+		{PhaseNodeWrite, time.Millisecond},
+		{PhaseDequeue, time.Millisecond * 2},
+		{PhaseSequence, time.Millisecond * 40},
+		{PhaseNodeWrite, time.Millisecond * 4},
+		{PhaseSign, time.Millisecond * 7},
+		{PhaseCommit, time.Millisecond * 8},
+	} {
+		timer.enter(step.phase)
+		timeSource.FakeTime = timeSource.FakeTime.Add(step.elapsed)
+	}
+
+	durations, total := timer.finish()
+
+	want := [numSequencerPhases]time.Duration{
+		PhaseDequeue:   time.Millisecond * 5,
+		PhaseTreeInit:  time.Millisecond * 5,
+		PhaseSequence:  time.Millisecond * 60,
+		PhaseNodeWrite: time.Millisecond * 5,
+		PhaseSign:      time.Millisecond * 7,
+		PhaseCommit:    time.Millisecond * 8,
+	}
+
+	if durations != want {
+		t.Errorf("Got phase durations %v, expected %v", durations, want)
+	}
+
+	if got, want := total, time.Millisecond*90; got != want {
+		t.Errorf("Got total %v, expected %v", got, want)
+	}
+}
+
+func TestSequencerTimingsStats(t *testing.T) {
+	timings := NewSequencerTimings(time.Millisecond)
+	timeSource := &util.FakeTimeSource{FakeTime: fakeTimeForTest}
+
+	// One batch spends 30ms and one 6s writing nodes, everything else takes no time
+	for _, nodeWrite := range []time.Duration{time.Millisecond * 30, time.Second * 6} {
+		timer := newBatchTimer(timeSource)
+		timer.enter(PhaseNodeWrite)
+		timeSource.FakeTime = timeSource.FakeTime.Add(nodeWrite)
+		timer.enter(PhaseSign)
+		timings.record(1, 10, timer)
+	}
+
+	stats := timings.Stats()
+
+	if got, want := stats.Batches, int64(2); got != want {
+		t.Fatalf("Got %d batches, expected %d", got, want)
+	}
+
+	if got, want := len(stats.Phases), int(numSequencerPhases); got != want {
+		t.Fatalf("Got stats for %d phases, expected %d", got, want)
+	}
+
+	nodeWrite := stats.Phases["node_write"]
+
+	if nodeWrite.TotalMillis != 6030 || nodeWrite.MaxMillis != 6000 {
+		t.Errorf("Got node_write total %vms max %vms, expected 6030ms and 6000ms", nodeWrite.TotalMillis, nodeWrite.MaxMillis)
+	}
+
+	for bucket, want := range map[string]int64{"1ms": 0, "10ms": 0, "50ms": 1, "5000ms": 0, "+Inf": 1} {
+		if got := nodeWrite.Histogram[bucket]; got != want {
+			t.Errorf("Got %d node_write batches in bucket %s, expected %d", got, bucket, want)
+		}
+	}
+
+	if got, want := stats.Phases["commit"].Histogram["1ms"], int64(2); got != want {
+		t.Errorf("Got %d instant commits, expected %d", got, want)
+	}
+}
+
+func TestSequenceBatchRecordsTimings(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	leaves := []trillian.LogLeaf{getLeaf42()}
+	updatedLeaves := []trillian.LogLeaf{testLeaf16Integrated}
+	params := testParameters{writeRevision: testRoot16.TreeRevision + 1, dequeueLimit: 1, shouldCommit: true,
+		dequeuedLeaves: leaves, latestSignedRoot: &testRoot16,
+		updatedLeaves: &updatedLeaves, merkleNodesSet: &updatedNodes,
+		storeSignedRoot: &expectedSignedRoot, setupSigner: true,
+		dataToSign:    []byte{0x4f, 0x21, 0x7d, 0x10, 0xe2, 0x6, 0x9f, 0x10, 0x4d, 0x7e, 0x42, 0x75, 0x24, 0x3b, 0xb3, 0x5b, 0x63, 0xa6, 0x7, 0x8d, 0x6c, 0x97, 0x23, 0x4, 0x8, 0x5e, 0x3b, 0xe2, 0xc4, 0xb8, 0x7a, 0xa2},
+		signingResult: []byte("signed")}
+	c := createTestContext(ctrl, params)
+	timings := NewSequencerTimings(0)
+	c.sequencer.SetTimings(timings, 42)
+
+	if _, err := c.sequencer.SequenceBatch(context.Background(), 1); err != nil {
+		t.Fatalf("Expected sequencing to succeed, but got err: %v", err)
+	}
+
+	stats := timings.Stats()
+
+	if got, want := stats.Batches, int64(1); got != want {
+		t.Fatalf("Got %d batches, expected %d", got, want)
+	}
+
+	// The fake time doesn't move, so every phase took no time
+	for phase, ps := range stats.Phases {
+		if got, want := ps.Histogram["1ms"], int64(1); got != want {
+			t.Errorf("Got %d batches in the first %s bucket, expected %d", got, phase, want)
+		}
+	}
+}
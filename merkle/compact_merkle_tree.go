@@ -91,6 +91,40 @@ func NewCompactMerkleTreeWithState(hasher TreeHasher, size int64, f GetNodeFunc,
 	return &r, nil
 }
 
+// NewCompactMerkleTreeFromNodes restores a CompactMerkleTree of |size| from the |nodes| returned
+// by Nodes(). This avoids fetching the nodes individually as NewCompactMerkleTreeWithState does.
+// It fails if the nodes don't match the size or don't produce |expectedRoot|.
+func NewCompactMerkleTreeFromNodes(hasher TreeHasher, size int64, nodes []trillian.Hash, expectedRoot trillian.Hash) (*CompactMerkleTree, error) {
+	if got, want := len(nodes), bitLen(size); got != want {
+		return nil, fmt.Errorf("got %d levels of nodes for tree size %d, expected %d", got, size, want)
+	}
+
+	r := CompactMerkleTree{
+		hasher: hasher,
+		nodes:  make([]trillian.Hash, len(nodes)),
+		root:   hasher.HashEmpty(),
+		size:   size,
+	}
+
+	for level, hash := range nodes {
+		if size>>uint(level)&1 == 0 {
+			continue
+		}
+		if len(hash) == 0 {
+			return nil, fmt.Errorf("missing node at level %d for tree size %d", level, size)
+		}
+		r.nodes[level] = append(make(trillian.Hash, 0, len(hash)), hash...)
+	}
+
+	r.recalculateRoot(func(depth int, index int64, hash trillian.Hash) {})
+
+	if !bytes.Equal(r.root, expectedRoot) {
+		log.Warningf("Corrupt state, expected root %s, got %s", hex.EncodeToString(expectedRoot[:]), hex.EncodeToString(r.root[:]))
+		return nil, RootHashMismatchError{ActualHash: r.root, ExpectedHash: expectedRoot}
+	}
+	return &r, nil
+}
+
 // NewCompactMerkleTree creates a new CompactMerkleTree with size zero. This always succeeds.
 func NewCompactMerkleTree(hasher TreeHasher) *CompactMerkleTree {
 	emptyHash := hasher.Digest([]byte{})
@@ -220,6 +254,19 @@ func (c *CompactMerkleTree) AddLeafHash(leafHash trillian.Hash, f setNodeFunc) (
 	return
 }
 
+// Nodes returns the hashes of the nodes that make up the compact tree, indexed by level with
+// the leaf level first. Levels that have no node at the current size are nil. Together with the
+// size this is the complete state of the tree, see NewCompactMerkleTreeFromNodes.
+func (c CompactMerkleTree) Nodes() []trillian.Hash {
+	nodes := make([]trillian.Hash, bitLen(c.size))
+	for level := range nodes {
+		if c.size>>uint(level)&1 == 1 {
+			nodes[level] = append(make(trillian.Hash, 0, len(c.nodes[level])), c.nodes[level]...)
+		}
+	}
+	return nodes
+}
+
 // Size returns the current size of the tree, that is, the number of leaves ever added to the tree.
 func (c CompactMerkleTree) Size() int64 {
 	return c.size
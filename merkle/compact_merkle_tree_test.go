@@ -197,3 +197,66 @@ func TestCompactVsFullTree(t *testing.T) {
 
 	}
 }
+
+func TestCompactTreeNodesRoundTrip(t *testing.T) {
+	hasher := NewRFC6962TreeHasher(trillian.NewSHA256())
+	tree := getTree()
+
+	for i := 0; i < 70; i++ {
+		tree.AddLeaf([]byte(fmt.Sprintf("Leaf %d", i)), func(int, int64, trillian.Hash) {})
+
+		nodes := tree.Nodes()
+		restored, err := NewCompactMerkleTreeFromNodes(hasher, tree.Size(), nodes, tree.CurrentRoot())
+
+		if err != nil {
+			t.Fatalf("size %d: failed to restore tree from nodes: %v", tree.Size(), err)
+		}
+
+		// The restored tree must carry on exactly as the original does
+		next := []byte(fmt.Sprintf("Next %d", i))
+		want := *tree
+		want.nodes = append([]trillian.Hash{}, tree.nodes...)
+		want.AddLeaf(next, func(int, int64, trillian.Hash) {})
+		restored.AddLeaf(next, func(int, int64, trillian.Hash) {})
+
+		if got, want := restored.CurrentRoot(), want.CurrentRoot(); !bytes.Equal(got, want) {
+			t.Fatalf("size %d: got root %x after adding a leaf to restored tree, expected %x", tree.Size(), got, want)
+		}
+	}
+}
+
+func TestCompactTreeFromNodesFailsBadNodes(t *testing.T) {
+	hasher := NewRFC6962TreeHasher(trillian.NewSHA256())
+	tree := getTree()
+
+	for i := 0; i < 6; i++ {
+		tree.AddLeaf([]byte(fmt.Sprintf("Leaf %d", i)), func(int, int64, trillian.Hash) {})
+	}
+
+	// Size 6 has nodes at levels 1 and 2
+	nodes := tree.Nodes()
+
+	if _, err := NewCompactMerkleTreeFromNodes(hasher, 5, nodes, tree.CurrentRoot()); err == nil {
+		t.Error("Restored tree from nodes for a different size")
+	}
+
+	if _, err := NewCompactMerkleTreeFromNodes(hasher, 6, nodes[:2], tree.CurrentRoot()); err == nil {
+		t.Error("Restored tree from too few levels of nodes")
+	}
+
+	missing := append([]trillian.Hash{}, nodes...)
+	missing[1] = nil
+
+	if _, err := NewCompactMerkleTreeFromNodes(hasher, 6, missing, tree.CurrentRoot()); err == nil {
+		t.Error("Restored tree with a missing node")
+	}
+
+	corrupt := append([]trillian.Hash{}, nodes...)
+	corrupt[2] = hasher.HashLeaf([]byte("corrupt"))
+
+	if _, err := NewCompactMerkleTreeFromNodes(hasher, 6, corrupt, tree.CurrentRoot()); err == nil {
+		t.Error("Restored tree from corrupt nodes")
+	} else if _, ok := err.(RootHashMismatchError); !ok {
+		t.Errorf("Got error %v for corrupt nodes, expected a RootHashMismatchError", err)
+	}
+}
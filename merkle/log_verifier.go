@@ -0,0 +1,223 @@
+package merkle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// These functions check the proofs served by a log against its roots, e.g. in clients and in
+// frontends that want to check what the backend gives them before serving it. They only need
+// the hashes in the proofs and use zero based leaf indices, as the log APIs do. The proofs are
+// those of RFC 6962 sections 2.1.1 and 2.1.2, the verification algorithms are the ones later
+// written down in RFC 6962-bis.
+
+// VerifyInclusionProof checks that proof shows the leaf with leafHash is at leafIndex in the
+// tree of size treeSize that has rootHash. The proof's hashes are ordered from the leaf up. A
+// RootHashMismatchError is returned if the proof is well formed but leads to a different root.
+func VerifyInclusionProof(hasher TreeHasher, leafIndex, treeSize int64, proof [][]byte, rootHash, leafHash []byte) error {
+	if leafIndex < 0 || treeSize <= 0 || leafIndex >= treeSize {
+		return fmt.Errorf("leaf index %d is not in a tree of size %d", leafIndex, treeSize)
+	}
+
+	computed, err := rootFromInclusionProof(hasher, leafIndex, treeSize, proof, leafHash)
+
+	if err != nil {
+		return err
+	}
+
+	return checkRootHash(computed, rootHash)
+}
+
+// rootFromInclusionProof returns the root hash that an inclusion proof leads to.
+func rootFromInclusionProof(hasher TreeHasher, leafIndex, treeSize int64, proof [][]byte, leafHash []byte) ([]byte, error) {
+	// fn is the index of the node on the path to the root, sn is the index of the last node
+	// at that level
+	fn, sn := leafIndex, treeSize-1
+	r := leafHash
+
+	for _, p := range proof {
+		if sn == 0 {
+			return nil, fmt.Errorf("inclusion proof has %d hashes, which is too many for leaf %d of a tree of size %d", len(proof), leafIndex, treeSize)
+		}
+
+		if fn&1 == 1 || fn == sn {
+			r = hasher.HashChildren(p, r)
+
+			// A right edge node with no sibling moves up levels without being hashed
+			if fn&1 == 0 {
+				for fn&1 == 0 && fn != 0 {
+					fn >>= 1
+					sn >>= 1
+				}
+			}
+		} else {
+			r = hasher.HashChildren(r, p)
+		}
+
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 {
+		return nil, fmt.Errorf("inclusion proof has %d hashes, which is too few for leaf %d of a tree of size %d", len(proof), leafIndex, treeSize)
+	}
+
+	return r, nil
+}
+
+// VerifyConsistencyProof checks that proof shows the tree of size snapshot2 with root2 is an
+// append only extension of the tree of size snapshot1 with root1. A tree is consistent with an
+// empty tree, or with itself, given an empty proof. A RootHashMismatchError is returned if the
+// proof is well formed but doesn't lead to both roots.
+func VerifyConsistencyProof(hasher TreeHasher, snapshot1, snapshot2 int64, root1, root2 []byte, proof [][]byte) error {
+	if snapshot1 < 0 || snapshot2 < snapshot1 {
+		return fmt.Errorf("invalid tree sizes for a consistency proof: %d and %d", snapshot1, snapshot2)
+	}
+
+	if snapshot1 == snapshot2 || snapshot1 == 0 {
+		if len(proof) > 0 {
+			return fmt.Errorf("consistency proof between sizes %d and %d should be empty but has %d hashes", snapshot1, snapshot2, len(proof))
+		}
+
+		if snapshot1 == 0 {
+			return nil
+		}
+
+		return checkRootHash(root1, root2)
+	}
+
+	if len(proof) == 0 {
+		return errors.New("consistency proof is empty")
+	}
+
+	// If the first tree is a complete subtree of the second its root is left out of the proof
+	if snapshot1&(snapshot1-1) == 0 {
+		proof = append([][]byte{root1}, proof...)
+	}
+
+	// fn is the index of the node on the first tree's right edge, sn is the index of the last
+	// node at that level of the second tree. Levels where fn is a right child are already
+	// covered by the first hash in the proof.
+	fn, sn := snapshot1-1, snapshot2-1
+
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	fr, sr := proof[0], proof[0]
+
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return fmt.Errorf("consistency proof between sizes %d and %d has too many hashes", snapshot1, snapshot2)
+		}
+
+		if fn&1 == 1 || fn == sn {
+			fr = hasher.HashChildren(c, fr)
+			sr = hasher.HashChildren(c, sr)
+
+			if fn&1 == 0 {
+				for fn&1 == 0 && fn != 0 {
+					fn >>= 1
+					sn >>= 1
+				}
+			}
+		} else {
+			sr = hasher.HashChildren(sr, c)
+		}
+
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 {
+		return fmt.Errorf("consistency proof between sizes %d and %d has too few hashes", snapshot1, snapshot2)
+	}
+
+	if err := checkRootHash(fr, root1); err != nil {
+		return err
+	}
+
+	return checkRootHash(sr, root2)
+}
+
+// VerifyRangeProof checks that proof shows the leaves with leafHashes are at the indices
+// starting at start in the tree of size treeSize that has rootHash. The proof's hashes are
+// those of the perfect subtrees that don't overlap the range, in order from the left of the
+// tree, see CalcRangeProofNodeAddresses. This lets a mirror check a whole batch of leaves with
+// one proof. A RootHashMismatchError is returned if the proof is well formed but leads to a
+// different root.
+func VerifyRangeProof(hasher TreeHasher, treeSize, start int64, leafHashes, proof [][]byte, rootHash []byte) error {
+	end := start + int64(len(leafHashes))
+
+	if start < 0 || len(leafHashes) == 0 || end > treeSize {
+		return fmt.Errorf("leaves %d to %d are not in a tree of size %d", start, end, treeSize)
+	}
+
+	v := rangeVerifier{hasher: hasher, start: start, end: end, leafHashes: leafHashes, proof: proof}
+	computed, err := v.root(0, treeSize)
+
+	if err != nil {
+		return err
+	}
+
+	if len(v.proof) > 0 {
+		return fmt.Errorf("range proof has %d hashes too many for leaves %d to %d of a tree of size %d", len(v.proof), start, end, treeSize)
+	}
+
+	return checkRootHash(computed, rootHash)
+}
+
+// rangeVerifier computes the root hash that a range proof leads to, using up the proof's
+// hashes as it goes.
+type rangeVerifier struct {
+	hasher     TreeHasher
+	start, end int64
+	leafHashes [][]byte
+	proof      [][]byte
+}
+
+// root returns the hash of the subtree of the leaves [lo, hi), in the same way that
+// rangeProofWalk visits it.
+func (v *rangeVerifier) root(lo, hi int64) ([]byte, error) {
+	size := hi - lo
+
+	if (hi <= v.start || lo >= v.end) && size&(size-1) == 0 {
+		if len(v.proof) == 0 {
+			return nil, fmt.Errorf("range proof has too few hashes for leaves %d to %d", v.start, v.end)
+		}
+
+		h := v.proof[0]
+		v.proof = v.proof[1:]
+		return h, nil
+	}
+
+	if size == 1 {
+		return v.leafHashes[lo-v.start], nil
+	}
+
+	k := splitPoint(size)
+	left, err := v.root(lo, lo+k)
+
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := v.root(lo+k, hi)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return v.hasher.HashChildren(left, right), nil
+}
+
+// checkRootHash returns a RootHashMismatchError if computed isn't the expected root hash.
+func checkRootHash(computed, expected []byte) error {
+	if !bytes.Equal(computed, expected) {
+		return RootHashMismatchError{ExpectedHash: expected, ActualHash: computed}
+	}
+
+	return nil
+}
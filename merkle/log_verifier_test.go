@@ -0,0 +1,256 @@
+package merkle
+
+import (
+	"testing"
+
+	"github.com/google/trillian"
+)
+
+// The RFC 6962 test vectors are the reference tree in memory_merkle_tree_test.go, which uses
+// one based leaf numbers.
+
+func rfc6962Leaves() [][]byte {
+	var leaves [][]byte
+
+	for _, input := range leafInputs {
+		leaves = append(leaves, decodeHexStringOrPanic(input))
+	}
+
+	return leaves
+}
+
+func decodeProof(hexHashes []string, length int) [][]byte {
+	proof := [][]byte{}
+
+	for _, h := range hexHashes[:length] {
+		proof = append(proof, decodeHexStringOrPanic(h))
+	}
+
+	return proof
+}
+
+// corruptProofs returns copies of a proof with one hash changed, one removed and one added.
+func corruptProofs(proof [][]byte) [][][]byte {
+	var corrupted [][][]byte
+
+	for i := range proof {
+		changed := append([][]byte{}, proof...)
+		changed[i] = append([]byte{changed[i][0] ^ 1}, changed[i][1:]...)
+		corrupted = append(corrupted, changed)
+	}
+
+	if len(proof) > 0 {
+		corrupted = append(corrupted, proof[:len(proof)-1])
+	}
+
+	return append(corrupted, append(append([][]byte{}, proof...), make([]byte, 32)))
+}
+
+func TestVerifyInclusionProofTestVectors(t *testing.T) {
+	hasher := NewRFC6962TreeHasher(trillian.NewSHA256())
+	leaves := rfc6962Leaves()
+
+	for _, test := range testPaths {
+		// Leaf zero is the C++ tree's way of asking for an invalid path
+		if test.leaf == 0 {
+			continue
+		}
+
+		index, size := int64(test.leaf-1), int64(test.snapshot)
+		root := decodeHexStringOrPanic(rootsAtSize[test.snapshot-1])
+		leafHash := hasher.HashLeaf(leaves[test.leaf-1])
+		proof := decodeProof(test.testVector, test.pathLength)
+
+		if err := VerifyInclusionProof(hasher, index, size, proof, root, leafHash); err != nil {
+			t.Errorf("VerifyInclusionProof(%d, %d)=%v", index, size, err)
+		}
+
+		for _, bad := range corruptProofs(proof) {
+			if err := VerifyInclusionProof(hasher, index, size, bad, root, leafHash); err == nil {
+				t.Errorf("VerifyInclusionProof(%d, %d) accepted corrupted proof %x", index, size, bad)
+			}
+		}
+	}
+}
+
+func TestVerifyConsistencyProofTestVectors(t *testing.T) {
+	hasher := NewRFC6962TreeHasher(trillian.NewSHA256())
+
+	for _, test := range testProofs {
+		size1, size2 := int64(test.snapshot1), int64(test.snapshot2)
+		root1 := decodeHexStringOrPanic(rootsAtSize[test.snapshot1-1])
+		root2 := decodeHexStringOrPanic(rootsAtSize[test.snapshot2-1])
+		proof := decodeProof(test.proof, test.proof_length)
+
+		if err := VerifyConsistencyProof(hasher, size1, size2, root1, root2, proof); err != nil {
+			t.Errorf("VerifyConsistencyProof(%d, %d)=%v", size1, size2, err)
+		}
+
+		if size1 == size2 {
+			continue
+		}
+
+		for _, bad := range corruptProofs(proof) {
+			if err := VerifyConsistencyProof(hasher, size1, size2, root1, root2, bad); err == nil {
+				t.Errorf("VerifyConsistencyProof(%d, %d) accepted corrupted proof %x", size1, size2, bad)
+			}
+		}
+
+		if err := VerifyConsistencyProof(hasher, size1, size2, root2, root1, proof); err == nil {
+			t.Errorf("VerifyConsistencyProof(%d, %d) accepted swapped roots", size1, size2)
+		}
+	}
+}
+
+func TestVerifyProofsAllSizes(t *testing.T) {
+	hasher := NewRFC6962TreeHasher(trillian.NewSHA256())
+	inputs := makeFuzzTestData()[:40]
+	var roots [][]byte
+
+	for size := 1; size <= len(inputs); size++ {
+		roots = append(roots, referenceMerkleTreeHash(inputs[:size], hasher))
+	}
+
+	for size := 1; size <= len(inputs); size++ {
+		root := roots[size-1]
+
+		for leaf := 1; leaf <= size; leaf++ {
+			proof := referenceMerklePath(inputs[:size], leaf, hasher)
+			leafHash := hasher.HashLeaf(inputs[leaf-1])
+
+			if err := VerifyInclusionProof(hasher, int64(leaf-1), int64(size), proof, root, leafHash); err != nil {
+				t.Fatalf("VerifyInclusionProof(%d, %d)=%v", leaf-1, size, err)
+			}
+
+			// The proof for one leaf doesn't prove any other leaf is at its index
+			for other := 0; other < size; other++ {
+				if other != leaf-1 {
+					if err := VerifyInclusionProof(hasher, int64(other), int64(size), proof, root, leafHash); err == nil {
+						t.Fatalf("VerifyInclusionProof(%d, %d) accepted the proof for leaf %d", other, size, leaf-1)
+					}
+				}
+			}
+
+			for _, bad := range corruptProofs(proof) {
+				if err := VerifyInclusionProof(hasher, int64(leaf-1), int64(size), bad, root, leafHash); err == nil {
+					t.Fatalf("VerifyInclusionProof(%d, %d) accepted corrupted proof", leaf-1, size)
+				}
+			}
+		}
+
+		for size1 := 1; size1 < size; size1++ {
+			proof := referenceSnapshotConsistency(inputs[:size], size, size1, hasher, true)
+
+			if err := VerifyConsistencyProof(hasher, int64(size1), int64(size), roots[size1-1], root, proof); err != nil {
+				t.Fatalf("VerifyConsistencyProof(%d, %d)=%v", size1, size, err)
+			}
+
+			for _, bad := range corruptProofs(proof) {
+				if err := VerifyConsistencyProof(hasher, int64(size1), int64(size), roots[size1-1], root, bad); err == nil {
+					t.Fatalf("VerifyConsistencyProof(%d, %d) accepted corrupted proof", size1, size)
+				}
+			}
+		}
+	}
+}
+
+func TestVerifyRangeProofAllRanges(t *testing.T) {
+	hasher := NewRFC6962TreeHasher(trillian.NewSHA256())
+	inputs := makeFuzzTestData()[:20]
+	var leafHashes [][]byte
+
+	for _, input := range inputs {
+		leafHashes = append(leafHashes, hasher.HashLeaf(input))
+	}
+
+	for size := 1; size <= len(inputs); size++ {
+		root := referenceMerkleTreeHash(inputs[:size], hasher)
+
+		for start := 0; start < size; start++ {
+			for end := start + 1; end <= size; end++ {
+				// The proof is the hashes of the subtrees the server would fetch from storage
+				var proof [][]byte
+				rangeProofWalk(0, int64(size), int64(start), int64(end), func(level int, index int64) error {
+					proof = append(proof, referenceMerkleTreeHash(inputs[index<<uint(level):(index+1)<<uint(level)], hasher))
+					return nil
+				})
+
+				if err := VerifyRangeProof(hasher, int64(size), int64(start), leafHashes[start:end], proof, root); err != nil {
+					t.Fatalf("VerifyRangeProof(%d, [%d, %d))=%v", size, start, end, err)
+				}
+
+				for _, bad := range corruptProofs(proof) {
+					if err := VerifyRangeProof(hasher, int64(size), int64(start), leafHashes[start:end], bad, root); err == nil {
+						t.Fatalf("VerifyRangeProof(%d, [%d, %d)) accepted corrupted proof", size, start, end)
+					}
+				}
+
+				if start > 0 {
+					if err := VerifyRangeProof(hasher, int64(size), int64(start), leafHashes[start-1:end-1], proof, root); err == nil {
+						t.Fatalf("VerifyRangeProof(%d, [%d, %d)) accepted shifted leaves", size, start, end)
+					}
+				}
+			}
+		}
+	}
+
+	for _, test := range []struct {
+		size, start int64
+		leaves      int
+	}{
+		{8, -1, 2}, {8, 7, 2}, {8, 0, 0}, {0, 0, 1},
+	} {
+		if err := VerifyRangeProof(hasher, test.size, test.start, leafHashes[:test.leaves], nil, nil); err == nil {
+			t.Errorf("VerifyRangeProof(%d, %d, %d leaves) succeeded", test.size, test.start, test.leaves)
+		}
+	}
+}
+
+func TestVerifyProofsBadArguments(t *testing.T) {
+	hasher := NewRFC6962TreeHasher(trillian.NewSHA256())
+	root := decodeHexStringOrPanic(rootsAtSize[7])
+	leafHash := hasher.HashLeaf(rfc6962Leaves()[0])
+
+	for _, test := range []struct {
+		index, size int64
+	}{
+		{-1, 8}, {8, 8}, {0, 0}, {0, -1},
+	} {
+		if err := VerifyInclusionProof(hasher, test.index, test.size, nil, root, leafHash); err == nil {
+			t.Errorf("VerifyInclusionProof(%d, %d) succeeded", test.index, test.size)
+		}
+	}
+
+	// A one leaf tree's root is its leaf hash
+	if err := VerifyInclusionProof(hasher, 0, 1, nil, leafHash, leafHash); err != nil {
+		t.Errorf("VerifyInclusionProof(0, 1)=%v", err)
+	}
+
+	if err := VerifyInclusionProof(hasher, 0, 1, nil, root, leafHash); err == nil {
+		t.Error("VerifyInclusionProof(0, 1) succeeded with the wrong root")
+	} else if _, ok := err.(RootHashMismatchError); !ok {
+		t.Errorf("VerifyInclusionProof(0, 1)=%v, expected a RootHashMismatchError", err)
+	}
+
+	for _, test := range []struct {
+		size1, size2 int64
+		root1, root2 []byte
+		proof        [][]byte
+		ok           bool
+	}{
+		{0, 8, nil, root, nil, true},
+		{0, 8, nil, root, [][]byte{root}, false},
+		{8, 8, root, root, nil, true},
+		{8, 8, root, leafHash, nil, false},
+		{8, 8, root, root, [][]byte{root}, false},
+		{4, 8, root, root, nil, false},
+		{-1, 8, nil, root, nil, false},
+		{8, 4, root, root, [][]byte{root}, false},
+	} {
+		err := VerifyConsistencyProof(hasher, test.size1, test.size2, test.root1, test.root2, test.proof)
+
+		if got := err == nil; got != test.ok {
+			t.Errorf("VerifyConsistencyProof(%d, %d, %d hashes)=%v, expected success %v", test.size1, test.size2, len(test.proof), err, test.ok)
+		}
+	}
+}
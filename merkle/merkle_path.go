@@ -69,6 +69,90 @@ func CalcConsistencyProofNodeAddresses(previousTreeSize, treeSize int64, maxBitL
 	return snapshotConsistency(previousTreeSize, treeSize, maxBitLen)
 }
 
+// CalcCompactRangeNodeAddresses returns the tree node IDs of the perfect subtrees that make up
+// a tree of the specified size, largest first. These nodes are complete so their hashes can
+// never change as the tree grows. Comparing them between two revisions of a tree is a way to
+// find where the trees have diverged.
+func CalcCompactRangeNodeAddresses(treeSize int64, maxBitLen int) ([]storage.NodeID, error) {
+	if treeSize < 0 || maxBitLen <= 0 {
+		return []storage.NodeID{}, fmt.Errorf("invalid params treesize: %d, bitlen:%d", treeSize, maxBitLen)
+	}
+
+	nodes := make([]storage.NodeID, 0, bitLen(treeSize))
+
+	for level := bitLen(treeSize) - 1; level >= 0; level-- {
+		if treeSize&(1<<uint(level)) == 0 {
+			continue
+		}
+
+		// The subtree ends at the leaves covered by the higher bits of the size
+		index := (treeSize >> uint(level)) - 1
+		n, err := storage.NewNodeIDForTreeCoords(int64(level), index, maxBitLen)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+
+	return nodes, nil
+}
+
+// CalcRangeProofNodeAddresses returns the tree node IDs needed to prove that the leaves in the
+// range [start, end) are at those indices in the tree of the specified size. They're the
+// largest perfect subtrees that don't overlap the range, in order from the left of the tree,
+// so they are complete and never change as the tree grows. See VerifyRangeProof.
+func CalcRangeProofNodeAddresses(treeSize, start, end int64, maxBitLen int) ([]storage.NodeID, error) {
+	if start < 0 || end <= start || end > treeSize || maxBitLen <= 0 {
+		return []storage.NodeID{}, fmt.Errorf("invalid params treesize: %d start: %d end: %d, bitlen:%d", treeSize, start, end, maxBitLen)
+	}
+
+	proof := make([]storage.NodeID, 0, 2*bitLen(treeSize))
+	err := rangeProofWalk(0, treeSize, start, end, func(level int, index int64) error {
+		n, err := storage.NewNodeIDForTreeCoords(int64(level), index, maxBitLen)
+		if err != nil {
+			return err
+		}
+		proof = append(proof, n)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return proof, nil
+}
+
+// rangeProofWalk visits the subtree of the leaves [lo, hi) in the way that RFC 6962 splits
+// trees, calling f with the level and index of each perfect subtree that doesn't overlap the
+// range [start, end). Subtrees inside the range are skipped, the verifier hashes their leaves.
+func rangeProofWalk(lo, hi, start, end int64, f func(level int, index int64) error) error {
+	size := hi - lo
+
+	if hi <= start || lo >= end {
+		if size&(size-1) == 0 {
+			level := bitLen(size) - 1
+			return f(level, lo>>uint(level))
+		}
+	} else if start <= lo && hi <= end {
+		return nil
+	}
+
+	k := splitPoint(size)
+
+	if err := rangeProofWalk(lo, lo+k, start, end, f); err != nil {
+		return err
+	}
+
+	return rangeProofWalk(lo+k, hi, start, end, f)
+}
+
+// splitPoint returns the size of the left subtree of a tree of size leaves, the largest power
+// of two smaller than size. size must be at least 2.
+func splitPoint(size int64) int64 {
+	return 1 << uint(bitLen(size-1)-1)
+}
+
 // snapshotConsistency does the calculation of consistency proof node addresses between
 // two snapshots. Based on the C++ code used by CT but adjusted to fit our situation.
 // In particular the code does not need to handle the case where overwritten node hashes
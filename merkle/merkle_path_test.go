@@ -131,6 +131,70 @@ func TestCalcConsistencyProofNodeAddressesBadInputs(t *testing.T) {
 	}
 }
 
+func TestCalcCompactRangeNodeAddresses(t *testing.T) {
+	var tests = []struct {
+		treeSize int64
+		expected []storage.NodeID
+	}{
+		{0, []storage.NodeID{}},
+		{1, []storage.NodeID{testonly.MustCreateNodeIDForTreeCoords(0, 0, 64)}},
+		{4, []storage.NodeID{testonly.MustCreateNodeIDForTreeCoords(2, 0, 64)}},
+		{6, []storage.NodeID{testonly.MustCreateNodeIDForTreeCoords(2, 0, 64), testonly.MustCreateNodeIDForTreeCoords(1, 2, 64)}},
+		{7, []storage.NodeID{testonly.MustCreateNodeIDForTreeCoords(2, 0, 64), testonly.MustCreateNodeIDForTreeCoords(1, 2, 64), testonly.MustCreateNodeIDForTreeCoords(0, 6, 64)}},
+	}
+
+	for _, test := range tests {
+		nodes, err := CalcCompactRangeNodeAddresses(test.treeSize, 64)
+
+		if err != nil {
+			t.Fatalf("failed to calculate compact range for size %d: %v", test.treeSize, err)
+		}
+
+		comparePaths(t, nodes, test.expected)
+	}
+
+	if _, err := CalcCompactRangeNodeAddresses(-1, 64); err == nil {
+		t.Fatal("compact range calculation accepted -ve tree size")
+	}
+
+	if _, err := CalcCompactRangeNodeAddresses(7, 0); err == nil {
+		t.Fatal("compact range calculation accepted bad bitlen")
+	}
+}
+
+func TestCalcRangeProofNodeAddresses(t *testing.T) {
+	var tests = []struct {
+		treeSize, start, end int64
+		expected             []storage.NodeID
+	}{
+		{8, 0, 8, []storage.NodeID{}},
+		{5, 4, 5, []storage.NodeID{testonly.MustCreateNodeIDForTreeCoords(2, 0, 64)}},
+		{7, 2, 4, []storage.NodeID{testonly.MustCreateNodeIDForTreeCoords(1, 0, 64), testonly.MustCreateNodeIDForTreeCoords(1, 2, 64), testonly.MustCreateNodeIDForTreeCoords(0, 6, 64)}},
+		{7, 3, 5, []storage.NodeID{testonly.MustCreateNodeIDForTreeCoords(1, 0, 64), testonly.MustCreateNodeIDForTreeCoords(0, 2, 64), testonly.MustCreateNodeIDForTreeCoords(0, 5, 64), testonly.MustCreateNodeIDForTreeCoords(0, 6, 64)}},
+	}
+
+	for _, test := range tests {
+		nodes, err := CalcRangeProofNodeAddresses(test.treeSize, test.start, test.end, 64)
+
+		if err != nil {
+			t.Fatalf("failed to calculate range proof for [%d, %d) of size %d: %v", test.start, test.end, test.treeSize, err)
+		}
+
+		comparePaths(t, nodes, test.expected)
+	}
+
+	for _, bad := range []struct {
+		treeSize, start, end int64
+		bitLen               int
+	}{
+		{7, -1, 3, 64}, {7, 3, 3, 64}, {7, 4, 3, 64}, {7, 3, 8, 64}, {0, 0, 1, 64}, {7, 3, 5, 0},
+	} {
+		if _, err := CalcRangeProofNodeAddresses(bad.treeSize, bad.start, bad.end, bad.bitLen); err == nil {
+			t.Errorf("range proof calculation accepted [%d, %d) of size %d, bitlen %d", bad.start, bad.end, bad.treeSize, bad.bitLen)
+		}
+	}
+}
+
 func TestCalcConsistencyProofNodeAddressesRejectsBadBitLen(t *testing.T) {
 	_, err := CalcConsistencyProofNodeAddresses(6, 7, -1)
 	_, err2 := CalcConsistencyProofNodeAddresses(6, 7, 0)
@@ -1,6 +1,8 @@
 package merkle
 
 import (
+	"fmt"
+
 	"github.com/google/trillian"
 )
 
@@ -44,13 +46,41 @@ func NewRFC6962TreeHasher(hasher trillian.Hasher) TreeHasher {
 	}
 }
 
+// NewTreeHasher creates a TreeHasher that hashes leaves according to the strategy configured
+// for a tree. Internal nodes and empty hashes are the same as for NewRFC6962TreeHasher.
+func NewTreeHasher(hasher trillian.Hasher, strategy trillian.LeafHashStrategy) (TreeHasher, error) {
+	th := NewRFC6962TreeHasher(hasher)
+
+	switch strategy {
+	case trillian.LeafHashStrategy_RFC6962_LEAF_HASH:
+		return th, nil
+	case trillian.LeafHashStrategy_RAW_LEAF_HASH:
+		th.leafHasher = rawLeafHasher(hasher)
+		return th, nil
+	}
+
+	return TreeHasher{}, fmt.Errorf("unknown leaf hash strategy: %v", strategy)
+}
+
+// NewTreeHasherForAlgorithm is like NewTreeHasher but also creates the hash function, for a
+// tree that was created with hash algorithm alg and leaf hash strategy strategy.
+func NewTreeHasherForAlgorithm(alg trillian.HashAlgorithm, strategy trillian.LeafHashStrategy) (TreeHasher, error) {
+	hasher, err := trillian.NewHasher(alg)
+
+	if err != nil {
+		return TreeHasher{}, err
+	}
+
+	return NewTreeHasher(hasher, strategy)
+}
+
 // HashEmpty returns the hash of an empty element for the tree
 func (t TreeHasher) HashEmpty() trillian.Hash {
 	return t.emptyHasher()
 }
 
 // HashLeaf returns the merkle tree leaf hash of the data passed in through leaf.
-// The data in leaf is prefixed by the LeafHashPrefix.
+// For RFC 6962 hashers the data in leaf is prefixed by the LeafHashPrefix.
 func (t TreeHasher) HashLeaf(leaf []byte) trillian.Hash {
 	return t.leafHasher(leaf)
 }
@@ -78,6 +108,13 @@ func rfc6962LeafHasher(h trillian.Hasher) hashFunc {
 	}
 }
 
+// rawLeafHasher builds a function to calculate leaf hashes as the plain digest of the data.
+func rawLeafHasher(h trillian.Hasher) hashFunc {
+	return func(b []byte) trillian.Hash {
+		return h.Digest(b)
+	}
+}
+
 // rfc6962NodeHasher builds a function to calculate internal node hashes based on the Hasher h for CT.
 func rfc6962NodeHasher(h trillian.Hasher) hashFunc {
 	return func(b []byte) trillian.Hash {
@@ -15,6 +15,8 @@ const (
 	rfc6962LeafL123456HashHex = "395aa064aa4c29f7010acfe3f25db9485bbd4b91897b6ad7ad547639252b4d56"
 	// echo -n 014E3132334E343536 | xxd -r -p | sha256sum
 	rfc6962NodeN123N456HashHex = "aa217fe888e47007fa15edab33c2b492a722cb106c64667fc2b044444de66bbb"
+	// echo -n L123456 | sha256sum
+	rawLeafL123456HashHex = "c95293e88128acf26ed51ff89b789ff6e139674aae80b812e0018e3d540277ef"
 )
 
 func ensureHashMatches(expected, actual []byte, testCase string, t *testing.T) {
@@ -30,3 +32,71 @@ func TestRfc6962Hasher(t *testing.T) {
 	ensureHashMatches(testonly.MustHexDecode(rfc6962LeafL123456HashHex), hasher.HashLeaf([]byte("L123456")), "RFC6962 Leaf", t)
 	ensureHashMatches(testonly.MustHexDecode(rfc6962NodeN123N456HashHex), hasher.HashChildren([]byte("N123"), []byte("N456")), "RFC6962 Node", t)
 }
+
+func TestNewTreeHasherRFC6962Strategy(t *testing.T) {
+	hasher, err := NewTreeHasher(trillian.NewSHA256(), trillian.LeafHashStrategy_RFC6962_LEAF_HASH)
+
+	if err != nil {
+		t.Fatalf("Failed to create tree hasher: %v", err)
+	}
+
+	ensureHashMatches(testonly.MustHexDecode(rfc6962LeafL123456HashHex), hasher.HashLeaf([]byte("L123456")), "RFC6962 Leaf", t)
+	ensureHashMatches(testonly.MustHexDecode(rfc6962NodeN123N456HashHex), hasher.HashChildren([]byte("N123"), []byte("N456")), "RFC6962 Node", t)
+}
+
+func TestNewTreeHasherRawStrategy(t *testing.T) {
+	hasher, err := NewTreeHasher(trillian.NewSHA256(), trillian.LeafHashStrategy_RAW_LEAF_HASH)
+
+	if err != nil {
+		t.Fatalf("Failed to create tree hasher: %v", err)
+	}
+
+	// Only the leaf hashing differs from RFC 6962
+	ensureHashMatches(testonly.MustHexDecode(rfc6962EmptyHashHex), hasher.HashEmpty(), "Raw Empty", t)
+	ensureHashMatches(testonly.MustHexDecode(rawLeafL123456HashHex), hasher.HashLeaf([]byte("L123456")), "Raw Leaf", t)
+	ensureHashMatches(testonly.MustHexDecode(rfc6962NodeN123N456HashHex), hasher.HashChildren([]byte("N123"), []byte("N456")), "Raw Node", t)
+}
+
+func TestNewTreeHasherUnknownStrategy(t *testing.T) {
+	if _, err := NewTreeHasher(trillian.NewSHA256(), trillian.LeafHashStrategy(99)); err == nil {
+		t.Fatal("Created tree hasher with unknown leaf hash strategy")
+	}
+}
+
+func TestNewTreeHasherForAlgorithm(t *testing.T) {
+	for _, test := range []struct {
+		alg                        trillian.HashAlgorithm
+		emptyHex, leafHex, nodeHex string
+	}{
+		{trillian.HashAlgorithm_SHA256, rfc6962EmptyHashHex, rfc6962LeafL123456HashHex, rfc6962NodeN123N456HashHex},
+		// As above but with sha512sum -a 512256 and sha3sum -a 256
+		{trillian.HashAlgorithm_SHA512_256,
+			"c672b8d1ef56ed28ab87c3622c5114069bdd3ad7b8f9737498d0c01ecef0967a",
+			"ddc60d56df2a66360865a5cd33971e54bfb0152be673d3d5dbdacc723bd2f707",
+			"6bb47abbd0e3fbbee3dd02dd54844122c6aae6feccf6461a2488cd171aa9a233"},
+		{trillian.HashAlgorithm_SHA3_256,
+			"a7ffc6f8bf1ed76651c14756a061d662f580ff4de43b49fa82d80a4b80f8434a",
+			"091a7e2331ff57bae64ce796530fc0356b5b6ab4448f3e20b05a99503e19ad73",
+			"1eff624cef338bdba2600ebffc1c2149451993edc82785393d0cf5668d8ae5df"},
+	} {
+		hasher, err := NewTreeHasherForAlgorithm(test.alg, trillian.LeafHashStrategy_RFC6962_LEAF_HASH)
+
+		if err != nil {
+			t.Fatalf("Failed to create tree hasher for %v: %v", test.alg, err)
+		}
+
+		if got := hasher.HashAlgorithm(); got != test.alg {
+			t.Errorf("Got hash algorithm %v, expected %v", got, test.alg)
+		}
+
+		ensureHashMatches(testonly.MustHexDecode(test.emptyHex), hasher.HashEmpty(), test.alg.String()+" Empty", t)
+		ensureHashMatches(testonly.MustHexDecode(test.leafHex), hasher.HashLeaf([]byte("L123456")), test.alg.String()+" Leaf", t)
+		ensureHashMatches(testonly.MustHexDecode(test.nodeHex), hasher.HashChildren([]byte("N123"), []byte("N456")), test.alg.String()+" Node", t)
+	}
+}
+
+func TestNewTreeHasherForUnknownAlgorithm(t *testing.T) {
+	if _, err := NewTreeHasherForAlgorithm(trillian.HashAlgorithm(99), trillian.LeafHashStrategy_RFC6962_LEAF_HASH); err == nil {
+		t.Fatal("Created tree hasher with unknown hash algorithm")
+	}
+}
@@ -110,6 +110,38 @@ func (_mr *_MockTrillianLogClientRecorder) GetLatestSignedLogRoot(arg0, arg1 int
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLatestSignedLogRoot", _s...)
 }
 
+func (_m *MockTrillianLogClient) GetLatestSignedLogRootAndLeaves(_param0 context.Context, _param1 *GetLatestSignedLogRootAndLeavesRequest, _param2 ...grpc.CallOption) (*GetLatestSignedLogRootAndLeavesResponse, error) {
+	_s := []interface{}{_param0, _param1}
+	for _, _x := range _param2 {
+		_s = append(_s, _x)
+	}
+	ret := _m.ctrl.Call(_m, "GetLatestSignedLogRootAndLeaves", _s...)
+	ret0, _ := ret[0].(*GetLatestSignedLogRootAndLeavesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianLogClientRecorder) GetLatestSignedLogRootAndLeaves(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	_s := append([]interface{}{arg0, arg1}, arg2...)
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLatestSignedLogRootAndLeaves", _s...)
+}
+
+func (_m *MockTrillianLogClient) GetLatestSignedLogRoots(_param0 context.Context, _param1 *GetLatestSignedLogRootsRequest, _param2 ...grpc.CallOption) (*GetLatestSignedLogRootsResponse, error) {
+	_s := []interface{}{_param0, _param1}
+	for _, _x := range _param2 {
+		_s = append(_s, _x)
+	}
+	ret := _m.ctrl.Call(_m, "GetLatestSignedLogRoots", _s...)
+	ret0, _ := ret[0].(*GetLatestSignedLogRootsResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianLogClientRecorder) GetLatestSignedLogRoots(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	_s := append([]interface{}{arg0, arg1}, arg2...)
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLatestSignedLogRoots", _s...)
+}
+
 func (_m *MockTrillianLogClient) GetLeavesByHash(_param0 context.Context, _param1 *GetLeavesByHashRequest, _param2 ...grpc.CallOption) (*GetLeavesByHashResponse, error) {
 	_s := []interface{}{_param0, _param1}
 	for _, _x := range _param2 {
@@ -142,6 +174,54 @@ func (_mr *_MockTrillianLogClientRecorder) GetLeavesByIndex(arg0, arg1 interface
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByIndex", _s...)
 }
 
+func (_m *MockTrillianLogClient) GetLeavesByTimestamp(_param0 context.Context, _param1 *GetLeavesByTimestampRequest, _param2 ...grpc.CallOption) (*GetLeavesByTimestampResponse, error) {
+	_s := []interface{}{_param0, _param1}
+	for _, _x := range _param2 {
+		_s = append(_s, _x)
+	}
+	ret := _m.ctrl.Call(_m, "GetLeavesByTimestamp", _s...)
+	ret0, _ := ret[0].(*GetLeavesByTimestampResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianLogClientRecorder) GetLeavesByTimestamp(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	_s := append([]interface{}{arg0, arg1}, arg2...)
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByTimestamp", _s...)
+}
+
+func (_m *MockTrillianLogClient) GetRangeProof(_param0 context.Context, _param1 *GetRangeProofRequest, _param2 ...grpc.CallOption) (*GetRangeProofResponse, error) {
+	_s := []interface{}{_param0, _param1}
+	for _, _x := range _param2 {
+		_s = append(_s, _x)
+	}
+	ret := _m.ctrl.Call(_m, "GetRangeProof", _s...)
+	ret0, _ := ret[0].(*GetRangeProofResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianLogClientRecorder) GetRangeProof(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	_s := append([]interface{}{arg0, arg1}, arg2...)
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetRangeProof", _s...)
+}
+
+func (_m *MockTrillianLogClient) GetRevisionDiff(_param0 context.Context, _param1 *GetRevisionDiffRequest, _param2 ...grpc.CallOption) (*GetRevisionDiffResponse, error) {
+	_s := []interface{}{_param0, _param1}
+	for _, _x := range _param2 {
+		_s = append(_s, _x)
+	}
+	ret := _m.ctrl.Call(_m, "GetRevisionDiff", _s...)
+	ret0, _ := ret[0].(*GetRevisionDiffResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianLogClientRecorder) GetRevisionDiff(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	_s := append([]interface{}{arg0, arg1}, arg2...)
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetRevisionDiff", _s...)
+}
+
 func (_m *MockTrillianLogClient) GetSequencedLeafCount(_param0 context.Context, _param1 *GetSequencedLeafCountRequest, _param2 ...grpc.CallOption) (*GetSequencedLeafCountResponse, error) {
 	_s := []interface{}{_param0, _param1}
 	for _, _x := range _param2 {
@@ -158,6 +238,22 @@ func (_mr *_MockTrillianLogClientRecorder) GetSequencedLeafCount(arg0, arg1 inte
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSequencedLeafCount", _s...)
 }
 
+func (_m *MockTrillianLogClient) GetTreeMetadata(_param0 context.Context, _param1 *GetTreeMetadataRequest, _param2 ...grpc.CallOption) (*GetTreeMetadataResponse, error) {
+	_s := []interface{}{_param0, _param1}
+	for _, _x := range _param2 {
+		_s = append(_s, _x)
+	}
+	ret := _m.ctrl.Call(_m, "GetTreeMetadata", _s...)
+	ret0, _ := ret[0].(*GetTreeMetadataResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianLogClientRecorder) GetTreeMetadata(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	_s := append([]interface{}{arg0, arg1}, arg2...)
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetTreeMetadata", _s...)
+}
+
 func (_m *MockTrillianLogClient) QueueLeaves(_param0 context.Context, _param1 *QueueLeavesRequest, _param2 ...grpc.CallOption) (*QueueLeavesResponse, error) {
 	_s := []interface{}{_param0, _param1}
 	for _, _x := range _param2 {
@@ -174,6 +270,22 @@ func (_mr *_MockTrillianLogClientRecorder) QueueLeaves(arg0, arg1 interface{}, a
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "QueueLeaves", _s...)
 }
 
+func (_m *MockTrillianLogClient) SubscribeTreeEvents(_param0 context.Context, _param1 *SubscribeTreeEventsRequest, _param2 ...grpc.CallOption) (TrillianLog_SubscribeTreeEventsClient, error) {
+	_s := []interface{}{_param0, _param1}
+	for _, _x := range _param2 {
+		_s = append(_s, _x)
+	}
+	ret := _m.ctrl.Call(_m, "SubscribeTreeEvents", _s...)
+	ret0, _ := ret[0].(TrillianLog_SubscribeTreeEventsClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianLogClientRecorder) SubscribeTreeEvents(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	_s := append([]interface{}{arg0, arg1}, arg2...)
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SubscribeTreeEvents", _s...)
+}
+
 // Mock of TrillianLogServer interface
 type MockTrillianLogServer struct {
 	ctrl     *gomock.Controller
@@ -315,6 +427,22 @@ func (_m *MockTrillianMapClient) EXPECT() *_MockTrillianMapClientRecorder {
 	return _m.recorder
 }
 
+func (_m *MockTrillianMapClient) GetLeafHistory(_param0 context.Context, _param1 *GetMapLeafHistoryRequest, _param2 ...grpc.CallOption) (*GetMapLeafHistoryResponse, error) {
+	_s := []interface{}{_param0, _param1}
+	for _, _x := range _param2 {
+		_s = append(_s, _x)
+	}
+	ret := _m.ctrl.Call(_m, "GetLeafHistory", _s...)
+	ret0, _ := ret[0].(*GetMapLeafHistoryResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianMapClientRecorder) GetLeafHistory(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	_s := append([]interface{}{arg0, arg1}, arg2...)
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeafHistory", _s...)
+}
+
 func (_m *MockTrillianMapClient) GetLeaves(_param0 context.Context, _param1 *GetMapLeavesRequest, _param2 ...grpc.CallOption) (*GetMapLeavesResponse, error) {
 	_s := []interface{}{_param0, _param1}
 	for _, _x := range _param2 {
@@ -384,6 +512,17 @@ func (_m *MockTrillianMapServer) EXPECT() *_MockTrillianMapServerRecorder {
 	return _m.recorder
 }
 
+func (_m *MockTrillianMapServer) GetLeafHistory(_param0 context.Context, _param1 *GetMapLeafHistoryRequest) (*GetMapLeafHistoryResponse, error) {
+	ret := _m.ctrl.Call(_m, "GetLeafHistory", _param0, _param1)
+	ret0, _ := ret[0].(*GetMapLeafHistoryResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockTrillianMapServerRecorder) GetLeafHistory(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeafHistory", arg0, arg1)
+}
+
 func (_m *MockTrillianMapServer) GetLeaves(_param0 context.Context, _param1 *GetMapLeavesRequest) (*GetMapLeavesResponse, error) {
 	ret := _m.ctrl.Call(_m, "GetLeaves", _param0, _param1)
 	ret0, _ := ret[0].(*GetMapLeavesResponse)
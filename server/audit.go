@@ -0,0 +1,67 @@
+package server
+
+import (
+	"github.com/google/trillian"
+	"github.com/google/trillian/audit"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/peer"
+)
+
+// sequencerCaller is the caller recorded in the audit journal for roots stored by the sequencer
+const sequencerCaller = "sequencer"
+
+// callerFromContext identifies the client that made an RPC for the audit journal. This is its
+// address, followed by the type of authentication used if there was any.
+func callerFromContext(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+
+	if !ok || p.Addr == nil {
+		return "unknown"
+	}
+
+	if p.AuthInfo != nil {
+		return p.Addr.String() + " (" + p.AuthInfo.AuthType() + ")"
+	}
+
+	return p.Addr.String()
+}
+
+// auditQueueLeaves records a QueueLeaves request in the journal.
+func auditQueueLeaves(ctx context.Context, j *audit.Journal, req *trillian.QueueLeavesRequest) error {
+	checksum, err := audit.ChecksumProto(req)
+
+	if err != nil {
+		return err
+	}
+
+	return j.Append(audit.Record{
+		Operation: audit.OpQueueLeaves,
+		TreeID:    req.LogId,
+		Caller:    callerFromContext(ctx),
+		RequestID: util.RequestIDFromContext(ctx),
+		Checksum:  checksum,
+		LeafCount: len(req.Leaves),
+	})
+}
+
+// auditRoots returns a hook for the sequencer that records the roots it stores for a tree in
+// the journal.
+func auditRoots(j *audit.Journal, treeID int64) log.RootAuditFunc {
+	return func(root trillian.SignedLogRoot) error {
+		checksum, err := audit.ChecksumProto(&root)
+
+		if err != nil {
+			return err
+		}
+
+		return j.Append(audit.Record{
+			Operation: audit.OpStoreSignedLogRoot,
+			TreeID:    treeID,
+			Caller:    sequencerCaller,
+			Checksum:  checksum,
+			TreeSize:  root.TreeSize,
+		})
+	}
+}
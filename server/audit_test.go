@@ -0,0 +1,182 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/audit"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func newTestAuditJournal(t *testing.T) (*audit.Journal, string) {
+	dir, err := ioutil.TempDir("", "audit")
+
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	j, err := audit.NewJournal(dir, 1024*1024, fakeTimeSource)
+
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Failed to create audit journal: %v", err)
+	}
+
+	return j, dir
+}
+
+// readAuditRecords closes the journal and returns the records it wrote.
+func readAuditRecords(t *testing.T, j *audit.Journal, dir string) []audit.Record {
+	if err := j.Close(); err != nil {
+		t.Fatalf("Failed to close audit journal: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one audit journal file but got: %v %v", files, err)
+	}
+
+	f, err := os.Open(files[0])
+
+	if err != nil {
+		t.Fatalf("Failed to open audit journal file: %v", err)
+	}
+
+	defer f.Close()
+
+	records, err := audit.ReadRecords(f)
+
+	if err != nil {
+		t.Fatalf("Failed to read audit records: %v", err)
+	}
+
+	return records
+}
+
+func TestQueueLeavesAudited(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().LeafHashStrategy().Return(trillian.LeafHashStrategy_RFC6962_LEAF_HASH)
+	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockTx.EXPECT().QueueLeaves([]trillian.LogLeaf{leaf1}).Return(nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
+
+	j, dir := newTestAuditJournal(t)
+	defer os.RemoveAll(dir)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	server.SetAuditJournal(j)
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1234}})
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(util.RequestIDMetadataKey, "req-1"))
+
+	if _, err := server.QueueLeaves(ctx, &queueRequest0); err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+
+	records := readAuditRecords(t, j, dir)
+
+	if got, want := len(records), 1; got != want {
+		t.Fatalf("Got %d audit records, expected %d", got, want)
+	}
+
+	checksum, err := audit.ChecksumProto(&queueRequest0)
+
+	if err != nil {
+		t.Fatalf("Failed to checksum request: %v", err)
+	}
+
+	want := audit.Record{
+		TimestampNanos: fakeTime.UnixNano(),
+		Operation:      audit.OpQueueLeaves,
+		TreeID:         queueRequest0.LogId,
+		Caller:         "10.0.0.1:1234",
+		RequestID:      "req-1",
+		Checksum:       checksum,
+		LeafCount:      1,
+		ChainHash:      records[0].ChainHash,
+	}
+
+	if got := records[0]; got != want {
+		t.Fatalf("Got audit record %+v, expected %+v", got, want)
+	}
+}
+
+func TestQueueLeavesAuditFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Nothing should be queued if the request can't be recorded
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().LeafHashStrategy().Return(trillian.LeafHashStrategy_RFC6962_LEAF_HASH)
+
+	j, dir := newTestAuditJournal(t)
+	os.RemoveAll(dir)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	server.SetAuditJournal(j)
+
+	if _, err := server.QueueLeaves(context.Background(), &queueRequest0); err == nil {
+		t.Fatal("Expected QueueLeaves to fail when the audit journal can't be written")
+	}
+}
+
+func TestAuditRoots(t *testing.T) {
+	j, dir := newTestAuditJournal(t)
+	defer os.RemoveAll(dir)
+
+	root := trillian.SignedLogRoot{TreeSize: 27, RootHash: []byte("root"), TreeRevision: 5}
+
+	if err := auditRoots(j, 6)(root); err != nil {
+		t.Fatalf("Failed to audit root: %v", err)
+	}
+
+	records := readAuditRecords(t, j, dir)
+
+	if got, want := len(records), 1; got != want {
+		t.Fatalf("Got %d audit records, expected %d", got, want)
+	}
+
+	checksum, err := audit.ChecksumProto(&root)
+
+	if err != nil {
+		t.Fatalf("Failed to checksum root: %v", err)
+	}
+
+	if got, want := records[0].Operation, audit.OpStoreSignedLogRoot; got != want {
+		t.Errorf("Got operation %s, expected %s", got, want)
+	}
+
+	if got, want := records[0].TreeID, int64(6); got != want {
+		t.Errorf("Got tree ID %d, expected %d", got, want)
+	}
+
+	if got, want := records[0].Caller, sequencerCaller; got != want {
+		t.Errorf("Got caller %s, expected %s", got, want)
+	}
+
+	if got, want := records[0].TreeSize, int64(27); got != want {
+		t.Errorf("Got tree size %d, expected %d", got, want)
+	}
+
+	if got, want := records[0].Checksum, checksum; got != want {
+		t.Errorf("Got checksum %s, expected %s", got, want)
+	}
+}
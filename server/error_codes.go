@@ -0,0 +1,18 @@
+package server
+
+import (
+	terrors "github.com/google/trillian/errors"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// ErrorCodeInterceptor returns a gRPC interceptor that gives errors returned by handlers the
+// gRPC code of their category, so that clients can tell a bad request from a backend failure
+// without matching on the message. It should be the last interceptor in the chain so the
+// others see the errors as the handlers returned them.
+func ErrorCodeInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		return resp, terrors.ToGRPC(err)
+	}
+}
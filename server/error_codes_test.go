@@ -0,0 +1,44 @@
+package server
+
+import (
+	"errors"
+	"testing"
+
+	terrors "github.com/google/trillian/errors"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestErrorCodeInterceptor(t *testing.T) {
+	interceptor := ErrorCodeInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/trillian.TrillianLog/GetLeavesByIndex"}
+
+	for _, test := range []struct {
+		err  error
+		want codes.Code
+	}{
+		{err: nil, want: codes.OK},
+		{err: terrors.Errorf(terrors.InvalidRange, "invalid leaf index: %d", -1), want: codes.OutOfRange},
+		{err: terrors.New(terrors.Backend, "storage is unavailable"), want: codes.Unavailable},
+		{err: terrors.New(terrors.Integrity, "expected 3 nodes in proof but got 2"), want: codes.DataLoss},
+		{err: grpc.Errorf(codes.PermissionDenied, "denied"), want: codes.PermissionDenied},
+		{err: errors.New("TX"), want: codes.Unknown},
+	} {
+		resp, err := interceptor(context.Background(), "req", info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return "resp", test.err
+		})
+
+		if resp != "resp" {
+			t.Errorf("Got response %v for error %v, expected the handler response", resp, test.err)
+		}
+
+		if got := grpc.Code(err); got != test.want {
+			t.Errorf("Got code %v for error %v, expected %v", got, test.err, test.want)
+		}
+
+		if test.err != nil && grpc.ErrorDesc(err) != grpc.ErrorDesc(test.err) {
+			t.Errorf("Got message %q for error %v, expected it to be kept", grpc.ErrorDesc(err), test.err)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package server
+
+// Names of the features that the log server's behaviour can be switched between with a
+// util.Features registry, see SetFeatures on TrillianLogServer and SequencerManager.
+const (
+	// FeatureSingleQueryProofs fetches proof nodes with one query where storage supports it
+	FeatureSingleQueryProofs = "single_query_proofs"
+	// FeatureChunkedBatches sequences batches in chunks that fit the batch memory budget
+	FeatureChunkedBatches = "chunked_batches"
+)
+
+// LogServerFeatures lists all the features known to the log server.
+var LogServerFeatures = []string{FeatureSingleQueryProofs, FeatureChunkedBatches}
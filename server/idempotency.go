@@ -0,0 +1,162 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/google/trillian"
+	terrors "github.com/google/trillian/errors"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+// idempotencyKey identifies a request by its log and the key its client gave it
+type idempotencyKey struct {
+	logID int64
+	key   string
+}
+
+// idempotentRequest is the first request seen with a key
+type idempotentRequest struct {
+	key idempotencyKey
+	// fingerprint identifies the leaves of the request, see leavesFingerprint
+	fingerprint []byte
+	// expires is when the key can be reused for different leaves
+	expires time.Time
+	// done is closed once response and err are set
+	done     chan struct{}
+	response *trillian.QueueLeavesResponse
+	err      error
+}
+
+// IdempotencyCache remembers the results of recent QueueLeaves requests that carried an
+// idempotency key, so that a personality retrying a request whose response was lost doesn't
+// queue the same leaves twice. A retry that arrives while the first request is still being
+// processed waits for its result. Only successful results are kept: a request that failed is
+// forgotten so its retry does the work again. Keys are kept for the TTL, or until more than
+// the maximum number of keys have been seen since. It is safe for concurrent use.
+type IdempotencyCache struct {
+	ttl        time.Duration
+	maxKeys    int
+	timeSource util.TimeSource
+
+	// mu guards the fields below it
+	mu sync.Mutex
+	// requests maps the keys to their first request
+	requests map[idempotencyKey]*idempotentRequest
+	// order holds the requests in the order they were first seen
+	order []*idempotentRequest
+	// replays counts the requests answered with an earlier result
+	replays int64
+}
+
+// NewIdempotencyCache creates an IdempotencyCache that keeps keys for ttl, and no more than
+// maxKeys of them, measuring time with timeSource.
+func NewIdempotencyCache(ttl time.Duration, maxKeys int, timeSource util.TimeSource) *IdempotencyCache {
+	return &IdempotencyCache{ttl: ttl, maxKeys: maxKeys, timeSource: timeSource, requests: make(map[idempotencyKey]*idempotentRequest)}
+}
+
+// IdempotencyStats describes the contents of an IdempotencyCache
+type IdempotencyStats struct {
+	// Keys is the number of keys being remembered
+	Keys int
+	// Replays is the number of requests answered with the result of an earlier request
+	Replays int64
+}
+
+// Stats returns the number of keys in the cache and how many retries it has answered.
+func (c *IdempotencyCache) Stats() IdempotencyStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expire()
+
+	return IdempotencyStats{Keys: len(c.requests), Replays: c.replays}
+}
+
+// do returns the result of the first request with key to log logID if it's still remembered,
+// waiting for it if necessary, and otherwise the result of f. It's an error to reuse a key
+// for different leaves, identified by fingerprint.
+func (c *IdempotencyCache) do(ctx context.Context, logID int64, key string, fingerprint []byte, f func() (*trillian.QueueLeavesResponse, error)) (*trillian.QueueLeavesResponse, error) {
+	id := idempotencyKey{logID: logID, key: key}
+
+	c.mu.Lock()
+	c.expire()
+
+	if r, ok := c.requests[id]; ok {
+		if !bytes.Equal(r.fingerprint, fingerprint) {
+			c.mu.Unlock()
+			return nil, terrors.Errorf(terrors.InvalidRange, "idempotency key %q was already used for different leaves", key)
+		}
+
+		c.replays++
+		c.mu.Unlock()
+
+		select {
+		case <-r.done:
+			return r.response, r.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	r := &idempotentRequest{key: id, fingerprint: fingerprint, expires: c.timeSource.Now().Add(c.ttl), done: make(chan struct{})}
+	c.requests[id] = r
+	c.order = append(c.order, r)
+	c.expire()
+	c.mu.Unlock()
+
+	r.response, r.err = f()
+
+	if r.err != nil || r.response.Status == nil || r.response.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		c.mu.Lock()
+		c.forget(r)
+		c.mu.Unlock()
+	}
+
+	// Requests that were waiting get this result even if it's a failure, they'll retry
+	close(r.done)
+
+	return r.response, r.err
+}
+
+// expire removes the requests that are past their TTL or beyond the maximum number of keys.
+// It must be called with mu held.
+func (c *IdempotencyCache) expire() {
+	now := c.timeSource.Now()
+
+	for len(c.order) > 0 && (len(c.order) > c.maxKeys || !now.Before(c.order[0].expires)) {
+		delete(c.requests, c.order[0].key)
+		c.order[0] = nil
+		c.order = c.order[1:]
+	}
+}
+
+// forget removes r if it hasn't already expired. It must be called with mu held.
+func (c *IdempotencyCache) forget(r *idempotentRequest) {
+	if c.requests[r.key] != r {
+		return
+	}
+
+	delete(c.requests, r.key)
+
+	for i := range c.order {
+		if c.order[i] == r {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// leavesFingerprint identifies the leaves of a request by their hashes, which must be set.
+func leavesFingerprint(leaves []trillian.LogLeaf) []byte {
+	h := sha256.New()
+
+	for _, leaf := range leaves {
+		h.Write(leaf.LeafHash)
+	}
+
+	return h.Sum(nil)
+}
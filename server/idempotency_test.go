@@ -0,0 +1,69 @@
+package server
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/trillian"
+	terrors "github.com/google/trillian/errors"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+func TestIdempotencyCache(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: time.Date(2016, 11, 1, 12, 0, 0, 0, time.UTC)}
+	c := NewIdempotencyCache(time.Minute, 2, ts)
+	ctx := context.Background()
+	calls := 0
+
+	ok := func() (*trillian.QueueLeavesResponse, error) {
+		calls++
+		return &trillian.QueueLeavesResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK)}, nil
+	}
+
+	fail := func() (*trillian.QueueLeavesResponse, error) {
+		calls++
+		return nil, errors.New("storage failed")
+	}
+
+	for _, test := range []struct {
+		desc        string
+		advance     time.Duration
+		logID       int64
+		key         string
+		fingerprint string
+		f           func() (*trillian.QueueLeavesResponse, error)
+		wantCalls   int
+		wantErr     bool
+	}{
+		{desc: "first", logID: 1, key: "a", fingerprint: "1", f: ok, wantCalls: 1},
+		{desc: "retry", logID: 1, key: "a", fingerprint: "1", f: ok, wantCalls: 1},
+		{desc: "other log", logID: 2, key: "a", fingerprint: "1", f: ok, wantCalls: 2},
+		{desc: "reused key", logID: 1, key: "a", fingerprint: "2", f: ok, wantCalls: 2, wantErr: true},
+		{desc: "failed", logID: 1, key: "b", fingerprint: "1", f: fail, wantCalls: 3, wantErr: true},
+		{desc: "failure forgotten", logID: 1, key: "b", fingerprint: "1", f: ok, wantCalls: 4},
+		{desc: "evicted", logID: 1, key: "a", fingerprint: "1", f: ok, wantCalls: 5},
+		{desc: "expired", advance: time.Minute, logID: 1, key: "b", fingerprint: "2", f: ok, wantCalls: 6},
+	} {
+		ts.FakeTime = ts.FakeTime.Add(test.advance)
+
+		resp, err := c.do(ctx, test.logID, test.key, []byte(test.fingerprint), test.f)
+
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: do()=%v %v, expected error: %v", test.desc, resp, err, test.wantErr)
+		}
+
+		if calls != test.wantCalls {
+			t.Errorf("%s: %d calls after do(), expected %d", test.desc, calls, test.wantCalls)
+		}
+	}
+
+	if _, err := c.do(ctx, 1, "b", []byte("1"), ok); terrors.CodeOf(err) != terrors.InvalidRange {
+		t.Errorf("Reusing a key for different leaves returned %v, expected an InvalidRange error", err)
+	}
+
+	if got, want := c.Stats(), (IdempotencyStats{Keys: 1, Replays: 1}); got != want {
+		t.Errorf("Stats()=%+v, expected %+v", got, want)
+	}
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// ChainUnaryInterceptors combines interceptors into one, as a server can only be given a
+// single interceptor. The first one is outermost and sees each RPC first.
+func ChainUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		// Wrap the handler from the innermost interceptor outwards
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			handler = bindInterceptor(interceptors[i], info, handler)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func bindInterceptor(interceptor grpc.UnaryServerInterceptor, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) grpc.UnaryHandler {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		return interceptor(ctx, req, info, handler)
+	}
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+func TestChainUnaryInterceptors(t *testing.T) {
+	var calls []string
+
+	recorder := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			calls = append(calls, name)
+			return handler(ctx, req)
+		}
+	}
+
+	chain := ChainUnaryInterceptors(recorder("first"), recorder("second"))
+
+	resp, err := chain(context.Background(), "req", queueLeavesInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls = append(calls, "handler")
+		return "resp", nil
+	})
+
+	if err != nil || resp != "resp" {
+		t.Fatalf("Got %v, %v from chain, expected the handler response", resp, err)
+	}
+
+	if got, want := calls, []string{"first", "second", "handler"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got calls %v, expected %v", got, want)
+	}
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+)
+
+// LeafValidator checks leaves in the log server before QueueLeaves stores them, so that a
+// log's guarantees hold whichever personality or client submits to it. Validators see the
+// leaves after their hashes have been computed from their data, and checked against any
+// hash the client sent.
+type LeafValidator interface {
+	// ValidateLeaves returns an error if any of the leaves of a QueueLeaves request for logID
+	// must not be queued. The whole request is rejected with the error as its description.
+	ValidateLeaves(ctx context.Context, logID int64, leaves []trillian.LogLeaf) error
+}
+
+// LeafSizeValidator rejects leaves whose data or extra data is too large. A limit of zero
+// means there's no limit.
+type LeafSizeValidator struct {
+	MaxLeafValueBytes int
+	MaxExtraDataBytes int
+}
+
+// ValidateLeaves implements LeafValidator.
+func (v LeafSizeValidator) ValidateLeaves(ctx context.Context, logID int64, leaves []trillian.LogLeaf) error {
+	for i, leaf := range leaves {
+		if v.MaxLeafValueBytes > 0 && len(leaf.LeafValue) > v.MaxLeafValueBytes {
+			return fmt.Errorf("leaf %d has %d bytes of data, the limit is %d", i, len(leaf.LeafValue), v.MaxLeafValueBytes)
+		}
+
+		if v.MaxExtraDataBytes > 0 && len(leaf.ExtraData) > v.MaxExtraDataBytes {
+			return fmt.Errorf("leaf %d has %d bytes of extra data, the limit is %d", i, len(leaf.ExtraData), v.MaxExtraDataBytes)
+		}
+	}
+
+	return nil
+}
+
+// LeafIdentityFunc returns what identifies a leaf to a personality, e.g. the certificate in a
+// CT entry whatever its timestamp. Leaves with the same identity are duplicates.
+type LeafIdentityFunc func(leaf trillian.LogLeaf) ([]byte, error)
+
+// LeafHashIdentity identifies leaves by their Merkle leaf hash.
+func LeafHashIdentity(leaf trillian.LogLeaf) ([]byte, error) {
+	return leaf.LeafHash, nil
+}
+
+// DuplicateLeafValidator rejects requests that contain more than one leaf with the same
+// identity. Storage doesn't guarantee which of them would be kept, or if the log allows
+// duplicates that they'd be integrated next to each other.
+type DuplicateLeafValidator struct {
+	// Identity identifies leaves, if it's nil LeafHashIdentity is used
+	Identity LeafIdentityFunc
+}
+
+// ValidateLeaves implements LeafValidator.
+func (v DuplicateLeafValidator) ValidateLeaves(ctx context.Context, logID int64, leaves []trillian.LogLeaf) error {
+	identity := v.Identity
+
+	if identity == nil {
+		identity = LeafHashIdentity
+	}
+
+	// The index of the first leaf with each identity
+	seen := make(map[string]int, len(leaves))
+
+	for i, leaf := range leaves {
+		id, err := identity(leaf)
+
+		if err != nil {
+			return fmt.Errorf("leaf %d has no identity: %v", i, err)
+		}
+
+		if first, ok := seen[string(id)]; ok {
+			return fmt.Errorf("leaf %d is a duplicate of leaf %d", i, first)
+		}
+
+		seen[string(id)] = i
+	}
+
+	return nil
+}
+
+// validateLeaves runs the validators in order, stopping at the first that rejects the leaves.
+func validateLeaves(ctx context.Context, validators []LeafValidator, logID int64, leaves []trillian.LogLeaf) error {
+	for _, v := range validators {
+		if err := v.ValidateLeaves(ctx, logID, leaves); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+func TestLeafSizeValidator(t *testing.T) {
+	leaf := func(value, extra int) trillian.LogLeaf {
+		return trillian.LogLeaf{Leaf: trillian.Leaf{LeafValue: make([]byte, value), ExtraData: make([]byte, extra)}}
+	}
+
+	for _, test := range []struct {
+		desc      string
+		validator LeafSizeValidator
+		leaves    []trillian.LogLeaf
+		wantErr   bool
+	}{
+		{"no limits", LeafSizeValidator{}, []trillian.LogLeaf{leaf(1000, 1000)}, false},
+		{"within limits", LeafSizeValidator{MaxLeafValueBytes: 10, MaxExtraDataBytes: 20}, []trillian.LogLeaf{leaf(10, 20), leaf(1, 1)}, false},
+		{"data too large", LeafSizeValidator{MaxLeafValueBytes: 10}, []trillian.LogLeaf{leaf(10, 1000), leaf(11, 0)}, true},
+		{"extra data too large", LeafSizeValidator{MaxExtraDataBytes: 20}, []trillian.LogLeaf{leaf(1000, 21)}, true},
+	} {
+		err := test.validator.ValidateLeaves(context.Background(), logId1, test.leaves)
+
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%s: ValidateLeaves()=%v, expected error: %v", test.desc, err, test.wantErr)
+		}
+	}
+}
+
+func TestDuplicateLeafValidator(t *testing.T) {
+	leaf := func(hash, value string) trillian.LogLeaf {
+		return trillian.LogLeaf{Leaf: trillian.Leaf{LeafHash: []byte(hash), LeafValue: []byte(value)}}
+	}
+
+	// Identifies leaves by the first byte of their data, and has no identity for empty data
+	firstByte := func(leaf trillian.LogLeaf) ([]byte, error) {
+		if len(leaf.LeafValue) == 0 {
+			return nil, errors.New("no data")
+		}
+		return leaf.LeafValue[:1], nil
+	}
+
+	for _, test := range []struct {
+		desc      string
+		validator DuplicateLeafValidator
+		leaves    []trillian.LogLeaf
+		wantErr   bool
+	}{
+		{"distinct hashes", DuplicateLeafValidator{}, []trillian.LogLeaf{leaf("a", "x1"), leaf("b", "x2")}, false},
+		{"same hash", DuplicateLeafValidator{}, []trillian.LogLeaf{leaf("a", "x1"), leaf("b", "y"), leaf("a", "x1")}, true},
+		{"distinct identities", DuplicateLeafValidator{Identity: firstByte}, []trillian.LogLeaf{leaf("a", "x"), leaf("b", "y")}, false},
+		{"same identity", DuplicateLeafValidator{Identity: firstByte}, []trillian.LogLeaf{leaf("a", "x1"), leaf("b", "x2")}, true},
+		{"no identity", DuplicateLeafValidator{Identity: firstByte}, []trillian.LogLeaf{leaf("a", "")}, true},
+	} {
+		err := test.validator.ValidateLeaves(context.Background(), logId1, test.leaves)
+
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%s: ValidateLeaves()=%v, expected error: %v", test.desc, err, test.wantErr)
+		}
+	}
+}
+
+// recordingValidator remembers the leaves it was asked to check and returns err.
+type recordingValidator struct {
+	logID  int64
+	leaves []trillian.LogLeaf
+	err    error
+}
+
+func (v *recordingValidator) ValidateLeaves(ctx context.Context, logID int64, leaves []trillian.LogLeaf) error {
+	v.logID = logID
+	v.leaves = leaves
+	return v.err
+}
+
+func TestQueueLeavesValidated(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().LeafHashStrategy().Return(trillian.LeafHashStrategy_RFC6962_LEAF_HASH)
+	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockTx.EXPECT().QueueLeaves([]trillian.LogLeaf{leaf1}).Return(nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
+
+	validator := &recordingValidator{}
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	server.SetLeafValidators(LeafSizeValidator{MaxLeafValueBytes: 100}, validator)
+
+	// The validators should see the leaf after its hash has been checked
+	request := trillian.QueueLeavesRequest{LogId: logId1, Leaves: []*trillian.LeafProto{{LeafIndex: 1, LeafData: []byte("value"), ExtraData: []byte("extra")}}}
+	resp, err := server.QueueLeaves(context.Background(), &request)
+
+	if err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		t.Fatalf("Failed to queue valid leaf: %v %v", resp, err)
+	}
+
+	if got, want := validator.logID, logId1; got != want {
+		t.Errorf("Validated leaves for log %d, expected %d", got, want)
+	}
+
+	if len(validator.leaves) != 1 || !bytes.Equal(validator.leaves[0].LeafHash, leaf1Hash) {
+		t.Errorf("Validated leaves %v, expected leaf with hash %v", validator.leaves, leaf1Hash)
+	}
+}
+
+func TestQueueLeavesValidationRejected(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+
+	// No transaction should be started for rejected leaves, and later validators aren't run
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().LeafHashStrategy().Return(trillian.LeafHashStrategy_RFC6962_LEAF_HASH)
+
+	validator := &recordingValidator{}
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	server.SetLeafValidators(LeafSizeValidator{MaxExtraDataBytes: 2}, validator)
+
+	resp, err := server.QueueLeaves(context.Background(), &queueRequest0)
+
+	if err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_ERROR {
+		t.Fatalf("Allowed leaf rejected by validator to be queued: %v %v", resp, err)
+	}
+
+	if validator.leaves != nil {
+		t.Error("Ran validator after the leaves were rejected")
+	}
+}
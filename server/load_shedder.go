@@ -0,0 +1,121 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// defaultRPCPriorities is the priority given to each RPC if the client doesn't send one.
+// Anything not listed is treated as a bulk request.
+var defaultRPCPriorities = map[string]util.RequestPriority{
+	"/trillian.TrillianLog/QueueLeaves":             util.PrioritySCT,
+	"/trillian.TrillianLog/GetInclusionProof":       util.PriorityProof,
+	"/trillian.TrillianLog/GetInclusionProofByHash": util.PriorityProof,
+	"/trillian.TrillianLog/GetConsistencyProof":     util.PriorityProof,
+	"/trillian.TrillianLog/GetLatestSignedLogRoot":  util.PriorityProof,
+	"/trillian.TrillianLog/GetEntryAndProof":        util.PriorityProof,
+}
+
+// shedFactors scale the thresholds for each priority, so that as load rises bulk requests
+// are rejected first, then proofs and only then submissions.
+var shedFactors = map[util.RequestPriority]int64{
+	util.PriorityBulk:  1,
+	util.PriorityProof: 2,
+	util.PrioritySCT:   4,
+}
+
+// latencyStaleAfter is how long the latency estimate is trusted for without any RPCs
+// completing. Otherwise if only low priority RPCs arrive and they are all rejected the
+// estimate would never come down again.
+const latencyStaleAfter = time.Second * 30
+
+// LoadShedder rejects RPCs when the server is overloaded, lowest priority first. Load is
+// judged by the number of RPCs in progress and by a moving average of how long they take.
+// Most of the time taken by an RPC is spent in storage, so the latter tracks storage latency.
+// It is safe for concurrent use.
+type LoadShedder struct {
+	// maxLatency is the average RPC latency above which bulk RPCs are rejected, zero disables
+	maxLatency time.Duration
+	// maxInFlight is the number of RPCs in progress above which bulk RPCs are rejected, zero
+	// disables
+	maxInFlight int64
+	timeSource  util.TimeSource
+
+	// mu guards the fields below it
+	mu       sync.Mutex
+	inFlight int64
+	// latency is an exponentially weighted moving average of RPC latency
+	latency time.Duration
+	// lastSample is when latency was last updated
+	lastSample time.Time
+}
+
+// NewLoadShedder creates a LoadShedder. Bulk RPCs are rejected once the average RPC latency
+// exceeds maxLatency or there are more than maxInFlight RPCs in progress. Higher priority
+// RPCs are allowed proportionally more before they are rejected. Either threshold can be set
+// to zero to disable it.
+func NewLoadShedder(maxLatency time.Duration, maxInFlight int, timeSource util.TimeSource) *LoadShedder {
+	return &LoadShedder{maxLatency: maxLatency, maxInFlight: int64(maxInFlight), timeSource: timeSource}
+}
+
+// Interceptor returns a gRPC interceptor that rejects RPCs with codes.Unavailable when the
+// server is too loaded to handle them. The priority sent by the client is used if there is
+// one, otherwise it is decided by the method.
+func (s *LoadShedder) Interceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		priority, ok := util.RequestPriorityFromContext(ctx)
+
+		if !ok {
+			priority = defaultRPCPriorities[info.FullMethod]
+		}
+
+		if !s.admit(priority) {
+			return nil, grpc.Errorf(codes.Unavailable, "server overloaded, rejected %v priority request", priority)
+		}
+
+		start := s.timeSource.Now()
+		resp, err := handler(ctx, req)
+		s.done(s.timeSource.Now().Sub(start))
+
+		return resp, err
+	}
+}
+
+// admit decides whether an RPC can go ahead and if so counts it as in progress.
+func (s *LoadShedder) admit(priority util.RequestPriority) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	factor := shedFactors[priority]
+
+	if s.maxInFlight > 0 && s.inFlight >= s.maxInFlight*factor {
+		return false
+	}
+
+	if s.timeSource.Now().Sub(s.lastSample) > latencyStaleAfter {
+		s.latency = 0
+	}
+
+	if s.maxLatency > 0 && s.latency > s.maxLatency*time.Duration(factor) {
+		return false
+	}
+
+	s.inFlight++
+	return true
+}
+
+// done records that an admitted RPC has finished.
+func (s *LoadShedder) done(elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.inFlight--
+	// Each sample has a weight of 1/8
+	s.latency += (elapsed - s.latency) / 8
+	s.lastSample = s.timeSource.Now()
+}
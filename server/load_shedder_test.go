@@ -0,0 +1,115 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+var queueLeavesInfo = &grpc.UnaryServerInfo{FullMethod: "/trillian.TrillianLog/QueueLeaves"}
+var getLeavesByIndexInfo = &grpc.UnaryServerInfo{FullMethod: "/trillian.TrillianLog/GetLeavesByIndex"}
+
+func TestLoadShedderQueueDepth(t *testing.T) {
+	s := NewLoadShedder(0, 1, util.SystemTimeSource{})
+
+	// Each priority is allowed proportionally more RPCs in progress
+	var tests = []struct {
+		priority util.RequestPriority
+		want     bool
+	}{
+		{util.PriorityBulk, true},
+		{util.PriorityBulk, false},
+		{util.PriorityProof, true},
+		{util.PriorityProof, false},
+		{util.PrioritySCT, true},
+		{util.PrioritySCT, true},
+		{util.PrioritySCT, false},
+	}
+
+	for i, test := range tests {
+		if got := s.admit(test.priority); got != test.want {
+			t.Fatalf("%d: admit(%v)=%v, want %v", i, test.priority, got, test.want)
+		}
+	}
+
+	// Once RPCs finish lower priorities are allowed again
+	for i := 0; i < 4; i++ {
+		s.done(0)
+	}
+
+	if !s.admit(util.PriorityBulk) {
+		t.Fatal("Bulk RPC rejected after load dropped")
+	}
+}
+
+func TestLoadShedderLatency(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: time.Date(2016, 6, 28, 13, 40, 12, 45, time.UTC)}
+	s := NewLoadShedder(time.Second, 0, ts)
+	interceptor := s.Interceptor()
+	slowHandler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		ts.FakeTime = ts.FakeTime.Add(3 * time.Second)
+		return "resp", nil
+	}
+
+	// Make submissions slow enough to push the average above the proof threshold
+	for i := 0; i < 20; i++ {
+		if _, err := interceptor(context.Background(), "req", queueLeavesInfo, slowHandler); err != nil {
+			t.Fatalf("Submission rejected: %v", err)
+		}
+	}
+
+	for _, priority := range []util.RequestPriority{util.PriorityBulk, util.PriorityProof} {
+		if s.admit(priority) {
+			t.Fatalf("%v RPC admitted with high latency", priority)
+		}
+	}
+
+	if !s.admit(util.PrioritySCT) {
+		t.Fatal("Submission rejected before latency reached its threshold")
+	}
+
+	s.done(0)
+
+	// If nothing completes for a while the estimate is discarded
+	ts.FakeTime = ts.FakeTime.Add(latencyStaleAfter + time.Second)
+
+	if !s.admit(util.PriorityBulk) {
+		t.Fatal("Bulk RPC rejected after latency estimate went stale")
+	}
+}
+
+func TestLoadShedderInterceptorPriorities(t *testing.T) {
+	s := NewLoadShedder(0, 1, util.SystemTimeSource{})
+	interceptor := s.Interceptor()
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "resp", nil
+	}
+
+	// Fill the bulk allowance
+	if !s.admit(util.PriorityBulk) {
+		t.Fatal("First RPC rejected")
+	}
+
+	// A bulk method is rejected with an error that clients know to retry
+	if _, err := interceptor(context.Background(), "req", getLeavesByIndexInfo, handler); grpc.Code(err) != codes.Unavailable {
+		t.Fatalf("Got error %v for bulk RPC, expected Unavailable", err)
+	}
+
+	// Submissions have a higher priority by default
+	if resp, err := interceptor(context.Background(), "req", queueLeavesInfo, handler); err != nil || resp != "resp" {
+		t.Fatalf("Got %v, %v for submission, expected the handler response", resp, err)
+	}
+
+	// The priority sent by the client takes precedence
+	md := metadata.Pairs(util.RequestPriorityMetadataKey, util.PrioritySCT.String())
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+
+	if _, err := interceptor(ctx, "req", getLeavesByIndexInfo, handler); err != nil {
+		t.Fatalf("Got error %v for bulk method sent with SCT priority", err)
+	}
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"errors"
+	"expvar"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -12,26 +16,86 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/golang/glog"
 	"github.com/google/trillian"
+	"github.com/google/trillian/audit"
 	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/log"
 	"github.com/google/trillian/server"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/mysql"
+	"github.com/google/trillian/storage/sqlite"
 	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"sync"
 )
 
 var mysqlUriFlag = flag.String("mysql_uri", "test:zaphod@tcp(127.0.0.1:3306)/test",
-	"uri to use with mysql storage")
+	"uri to use with mysql storage. Can be a comma separated list of uris, the primary followed by replicas to fail over to in order when it can't be used, the first writable one is used")
+var storageSystemFlag = flag.String("storage_system", "mysql", "Storage to use: mysql, or sqlite for a single node with no database server")
+var sqliteFileFlag = flag.String("sqlite_file", "trillian.db", "SQLite database file to use with sqlite storage, it's created if it doesn't exist")
+var sqliteBusyTimeoutFlag = flag.Duration("sqlite_busy_timeout", sqlite.DefaultBusyTimeout, "Max time a sqlite storage transaction waits for another one to release the database")
+var checkSchemaFlag = flag.Bool("check_schema", true, "If true and using mysql storage, check at startup that the database has the tables and columns this version expects and exit if not")
 var serverPortFlag = flag.Int("port", 8090, "Port to serve log requests on")
 var sequencerSleepBetweenRunsFlag = flag.Duration("sequencer_sleep_between_runs", time.Second * 10, "Time to pause after each sequencing pass through all logs")
-var signerSleepBetweenRunsFlag = flag.Duration("signer_sleep_between_runs", time.Second * 120, "Time to pause after each signing pass through all logs")
+var signerSleepBetweenRunsFlag = flag.Duration("signer_sleep_between_runs", time.Second * 120, "Max age of a log's newest root, a new root is signed when it's reached even if no leaves have been added")
 var batchSizeFlag = flag.Int("batch_size", 50, "Max number of leaves to process per batch")
+var signEveryNLeavesFlag = flag.Int64("sign_every_n_leaves", 0, "If set, a signed root is always stored at tree sizes that are a multiple of this, for monitors")
+var nodeFlushSizeFlag = flag.Int("node_flush_size", 0, "If set, the sequencer writes updated tree nodes whenever this many are pending rather than holding all of a batch's nodes in memory")
+var batchMemoryBudgetFlag = flag.Int64("batch_memory_budget", 0, "If set, batches that might need more than this many bytes are sequenced in chunks within one transaction so memory use doesn't grow with batch_size")
+var verifyStoredRootsFlag = flag.Bool("verify_stored_roots", true, "If true, the sequencer checks the signature on the latest root of each log and that it matches the tree before building on it, and stops sequencing the log if it doesn't. Disable this after changing the log's key")
+var slowSequencerBatchThresholdFlag = flag.Duration("slow_sequencer_batch_threshold", time.Second*5, "Sequencer batches that take longer than this are logged with the time spent in each phase. Zero disables. The phase timings of all batches are on /debug/vars as sequencer_timings")
+var slowRPCThresholdFlag = flag.Duration("slow_rpc_threshold", time.Second, "RPCs that take longer than this are logged along with their request ID")
+var slowStorageThresholdFlag = flag.Duration("slow_storage_threshold", time.Second, "Storage transactions that take longer than this are logged along with the request ID of their RPC. Zero disables")
+var shedLatencyThresholdFlag = flag.Duration("shed_latency_threshold", 0, "Reject low priority RPCs when the average RPC latency exceeds this, higher priorities are allowed more. Zero disables")
+var shedQueueDepthThresholdFlag = flag.Int("shed_queue_depth_threshold", 0, "Reject low priority RPCs when more than this many are in progress, higher priorities are allowed more. Zero disables")
+var drainTimeoutFlag = flag.Duration("drain_timeout", time.Second*10, "Max time to wait at shutdown for in-flight RPCs and sequencing to finish")
 
 // TODO(Martin2112): Single private key doesn't really work for multi tenant and we can't use
 // an HSM interface in this way. Deferring these issues for later.
 var privateKeyFile = flag.String("private_key_file", "", "File containing a PEM encoded private key")
 var privateKeyPassword = flag.String("private_key_password", "", "Password for server private key")
+var signingServerFlag = flag.String("signing_server", "", "If set, the address of a signing service, such as one run by cmd/signing_server, that holds the private key and signs roots for this server. The private key flags are then ignored")
+var signingKeyIDFlag = flag.String("signing_key_id", "default", "ID of the key held by --signing_server to sign roots with")
+var signingTimeoutFlag = flag.Duration("signing_timeout", time.Second*5, "Max time to wait for --signing_server to answer a request")
+var deterministicSignaturesFlag = flag.Bool("deterministic_signatures", false, "If true and the private key is an ECDSA key, roots are signed with RFC 6979 deterministic nonces rather than ones from the random number source")
+var leafDataMasterKeyFile = flag.String("leaf_data_master_key_file", "", "File containing a 32 byte master key used to encrypt leaf data at rest. If not set leaf data is stored unencrypted")
+var extraDataBlobDirFlag = flag.String("extra_data_blob_dir", "", "If set, leaf ExtraData larger than extra_data_blob_threshold is stored in files under this directory rather than in the database")
+var extraDataBlobThresholdFlag = flag.Int("extra_data_blob_threshold", 4096, "Leaf ExtraData larger than this many bytes is stored in the blob store, if one is configured")
+var auditJournalDirFlag = flag.String("audit_journal_dir", "", "If set, every QueueLeaves request and stored root is recorded in an audit journal in this directory")
+var auditJournalMaxFileBytesFlag = flag.Int64("audit_journal_max_file_bytes", 64*1024*1024, "Size at which the audit journal starts a new file")
+var auditJournalShipDirFlag = flag.String("audit_journal_ship_dir", "", "If set, completed audit journal files are moved to this directory, e.g. one synced to external storage")
+var partitionSizeFlag = flag.Int64("partition_size", 0, "If set, the storage tables must have been partitioned with partition_storage.sql and range partitions of this many sequence numbers or revisions are added as the trees grow")
+var partitionSpareFlag = flag.Int("partition_spare", 2, "Number of empty partitions to keep ahead of the data in each partitioned table")
+var enableAdminRPCsFlag = flag.Bool("enable_admin_rpcs", false, "If true the TrillianLogAdmin service is also served on the RPC port. Access to it is not restricted so only enable this where the port is not reachable by clients")
+var partitionRolloverIntervalFlag = flag.Duration("partition_rollover_interval", time.Hour, "How often to check whether partitions need to be added")
+var featuresFileFlag = flag.String("features_file", "", "If set, a JSON file of feature flags deciding which logs use risky new behaviour, reloaded when it changes. If not set all features are used")
+var featuresReloadIntervalFlag = flag.Duration("features_reload_interval", time.Minute, "How often to check whether the features file has changed")
+var maxLeafValueBytesFlag = flag.Int("max_leaf_value_bytes", 0, "If non zero, QueueLeaves rejects requests with a leaf whose data is larger than this")
+var maxLeafExtraDataBytesFlag = flag.Int("max_leaf_extra_data_bytes", 0, "If non zero, QueueLeaves rejects requests with a leaf whose extra data is larger than this")
+var rejectDuplicateLeavesFlag = flag.Bool("reject_duplicate_leaves", false, "If true, QueueLeaves rejects requests that contain the same leaf more than once, even in logs that allow duplicates")
+var idempotencyWindowFlag = flag.Duration("idempotency_window", 10*time.Minute, "How long the result of a QueueLeaves request with an idempotency key is returned for retries with the same key rather than queueing the leaves again. Zero disables this and keys are ignored")
+var idempotencyMaxKeysFlag = flag.Int("idempotency_max_keys", 100000, "Max number of idempotency keys remembered, the oldest are forgotten first")
+var rootCacheMaxAgeFlag = flag.Duration("root_cache_max_age", 0, "If non zero, GetLatestSignedLogRoot answers from a cache of each log's latest root rather than reading storage every time. Roots signed by this server replace the cached ones as soon as they're stored, ones signed by other servers sharing the storage are seen once the cached root is this old")
+var proofSampleIntervalFlag = flag.Duration("proof_sample_interval", 0, "If non zero, how often random recent leaves of every log are read back with their inclusion proofs, which are verified against the latest root to catch proof generation bugs before clients do. Zero disables")
+var proofSamplesPerLogFlag = flag.Int("proof_samples_per_log", 10, "Number of leaves of each log whose proofs are checked every --proof_sample_interval")
+var proofSampleWindowFlag = flag.Int64("proof_sample_window", 10000, "Proofs are checked for leaves among this many of the most recent leaves of each log")
+var metricsEndpointFlag = flag.String("metrics_endpoint", "", "If set, an address such as localhost:8091 to serve the server's metrics on at /debug/vars, including the proof_sampler results. Empty to not serve them")
+var validateConfigFlag = flag.Bool("validate_config", false, "If true, check the flags, storage, keys and files and that the latest root of every log was signed by the private key, write a JSON report to stdout and exit with status 0 if everything is OK or 1 if not, without serving")
+var rpcCompressionFlag = flag.String("rpc_compression", util.RPCCompressionNone, "Compression of RPC responses: none, gzip, gzip-fast or snappy. Clients must be configured with a setting that uses the same encoding, gzip-fast is compatible with gzip")
+
+// leafDataKeyWrapper wraps the data keys used to encrypt leaf data, it's nil if encryption
+// is not enabled
+var leafDataKeyWrapper crypto.KeyWrapper
+
+// extraDataBlobStore holds large leaf ExtraData, it's nil if all ExtraData is kept in the database
+var extraDataBlobStore storage.BlobStore
+
+// auditJournal records write operations, it's nil if auditing is not enabled
+var auditJournal *audit.Journal
+
+// features decides which logs use the server's feature flagged behaviour, it's nil if there
+// is no features file and everything is enabled
+var features *util.Features
 
 // Must hold this lock before accessing the storage map
 var storageMapGuard sync.Mutex
@@ -40,11 +104,63 @@ var storageMap = make(map[int64]storage.LogStorage)
 
 // TODO(Martin2112): Needs to be able to swap out for different storage type
 func simpleMySqlStorageProvider(treeID int64) (storage.LogStorage, error) {
-	return mysql.NewLogStorage(trillian.LogID{[]byte("TODO"), treeID}, *mysqlUriFlag)
+	logID := trillian.LogID{LogID: []byte("TODO"), TreeID: treeID}
+
+	var s storage.LogStorage
+	var err error
+
+	if leafDataKeyWrapper != nil {
+		s, err = mysql.NewEncryptedLogStorage(logID, *mysqlUriFlag, leafDataKeyWrapper)
+	} else {
+		s, err = mysql.NewLogStorage(logID, *mysqlUriFlag)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if extraDataBlobStore != nil {
+		if err := mysql.EnableExtraDataBlobStore(s, extraDataBlobStore, *extraDataBlobThresholdFlag); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// simpleSQLiteStorageProvider opens storage for a log in the SQLite database file. Leaf data
+// encryption and the ExtraData blob store aren't supported.
+func simpleSQLiteStorageProvider(treeID int64) (storage.LogStorage, error) {
+	return sqlite.NewLogStorage(trillian.LogID{LogID: []byte("TODO"), TreeID: treeID}, *sqliteFileFlag, *sqliteBusyTimeoutFlag)
+}
+
+// storageProvider returns the provider for the storage system selected by the flags.
+func storageProvider() (func(treeID int64) (storage.LogStorage, error), error) {
+	switch *storageSystemFlag {
+	case "mysql":
+		return simpleMySqlStorageProvider, nil
+	case "sqlite":
+		// These need features only the MySQL storage has
+		if len(*leafDataMasterKeyFile) > 0 || len(*extraDataBlobDirFlag) > 0 || *partitionSizeFlag > 0 {
+			return nil, errors.New("leaf_data_master_key_file, extra_data_blob_dir and partition_size can't be used with sqlite storage")
+		}
+
+		return simpleSQLiteStorageProvider, nil
+	default:
+		return nil, fmt.Errorf("unknown storage_system: %s", *storageSystemFlag)
+	}
 }
 
 // TODO(Martin2112): Could pull this out as a wrapper so it can be used elsewhere
 func getStorageForLog(logId int64) (storage.LogStorage, error) {
+	provider, err := storageProvider()
+
+	if err != nil {
+		return nil, err
+	}
+
+
 	storageMapGuard.Lock()
 	defer storageMapGuard.Unlock()
 
@@ -53,8 +169,7 @@ func getStorageForLog(logId int64) (storage.LogStorage, error) {
 	if !ok {
 		glog.Infof("Creating new storage for log: %d", logId)
 
-		var err error
-		s, err = simpleMySqlStorageProvider(logId)
+		s, err = provider(logId)
 
 		if err != nil {
 			return s, err
@@ -66,15 +181,32 @@ func getStorageForLog(logId int64) (storage.LogStorage, error) {
 	return s, nil
 }
 
-func checkDatabaseAccessible(dbUri string) error {
+// closeAllStorage closes the storage for every log that has been used. It should only be
+// called once everything using the storage has stopped.
+func closeAllStorage() {
+	storageMapGuard.Lock()
+	defer storageMapGuard.Unlock()
+
+	for logId, s := range storageMap {
+		if err := s.Close(); err != nil {
+			glog.Warningf("Failed to close storage for log: %d: %v", logId, err)
+		}
+
+		delete(storageMap, logId)
+	}
+}
+
+func checkDatabaseAccessible(provider func(treeID int64) (storage.LogStorage, error)) error {
 	// TODO(Martin2112): Have to pass a tree ID when we just want metadata. API mismatch
-	storage, err := mysql.NewLogStorage(trillian.LogID{[]byte("TODO"), int64(0)}, dbUri)
+	storage, err := provider(0)
 
 	if err != nil {
 		// This is probably something fundamentally wrong
 		return err
 	}
 
+	defer storage.Close()
+
 	tx, err := storage.Begin()
 
 	if err != nil {
@@ -90,49 +222,283 @@ func checkDatabaseAccessible(dbUri string) error {
 	return err
 }
 
-func startRpcServer(listener net.Listener, port int, provider server.LogStorageProviderFunc) *grpc.Server {
-	grpcServer := grpc.NewServer()
+// rolloverPartitions adds partitions ahead of the data every interval until done is closed.
+func rolloverPartitions(pm *mysql.PartitionManager, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			// Errors are logged by the partition manager, we'll try again next time
+			pm.Rollover()
+		}
+	}
+}
+
+func startRpcServer(listener net.Listener, port int, provider server.LogStorageProviderFunc, flush server.LogFlushFunc, treeEvents *server.TreeEvents) (*grpc.Server, *server.TrillianLogServer) {
+	loadShedder := server.NewLoadShedder(*shedLatencyThresholdFlag, *shedQueueDepthThresholdFlag, util.SystemTimeSource{})
+	// Requests that are shed are logged as failures along with their request ID
+	opts := []grpc.ServerOption{grpc.UnaryInterceptor(server.ChainUnaryInterceptors(
+		server.NewRequestLoggingInterceptor(*slowRPCThresholdFlag, util.SystemTimeSource{}),
+		loadShedder.Interceptor(),
+		server.ErrorCodeInterceptor()))}
+
+	// The flag was checked at startup
+	compressor, decompressor, _ := util.RPCCompression(*rpcCompressionFlag)
+
+	if compressor != nil {
+		opts = append(opts, grpc.RPCCompressor(compressor), grpc.RPCDecompressor(decompressor))
+	}
+
+	grpcServer := grpc.NewServer(opts...)
 	logServer := server.NewTrillianLogServer(provider)
+	logServer.SetAuditJournal(auditJournal)
+	logServer.SetTreeEvents(treeEvents)
+	logServer.SetFeatures(features)
+	logServer.SetLeafValidators(leafValidators()...)
+	logServer.SetSlowStorageLogging(*slowStorageThresholdFlag, util.SystemTimeSource{})
+
+	if *rootCacheMaxAgeFlag > 0 {
+		rootCache := server.NewRootCache(*rootCacheMaxAgeFlag, util.SystemTimeSource{})
+		// Stops when treeEvents is closed at shutdown
+		go rootCache.Watch(treeEvents, nil)
+		logServer.SetRootCache(rootCache)
+	}
+
+	if *idempotencyWindowFlag > 0 {
+		logServer.SetIdempotencyCache(server.NewIdempotencyCache(*idempotencyWindowFlag, *idempotencyMaxKeysFlag, util.SystemTimeSource{}))
+	}
+
 	trillian.RegisterTrillianLogServer(grpcServer, logServer)
+	// The V2 API is served by translating to the original one
+	trillian.RegisterTrillianLogV2Server(grpcServer, server.NewTrillianLogV2Server(logServer, provider))
+
+	if *enableAdminRPCsFlag {
+		glog.Warningf("Admin RPCs are enabled and can be called by anyone that can reach port: %d", port)
+		adminServer := server.NewTrillianLogAdminServer(provider, flush)
+		adminServer.SetFeatures(features)
+		trillian.RegisterTrillianLogAdminServer(grpcServer, adminServer)
+	}
+
+	return grpcServer, logServer
+}
+
+// startProofSampler checks the proofs logServer serves every --proof_sample_interval until
+// done is closed, publishing the results on /debug/vars.
+func startProofSampler(done chan struct{}, logServer trillian.TrillianLogServer) {
+	sampler := server.NewProofSampler(logServer, *proofSamplesPerLogFlag, *proofSampleWindowFlag)
+	expvar.Publish("proof_sampler", expvar.Func(func() interface{} {
+		return sampler.Stats()
+	}))
+
+	samplerManager := server.NewLogOperationManager(done, getStorageForLog, *batchSizeFlag, *proofSampleIntervalFlag, *signerSleepBetweenRunsFlag, util.SystemTimeSource{}, sampler)
+	go samplerManager.OperationLoop()
+}
+
+// leafValidators returns the checks QueueLeaves makes on leaves, as configured by flags.
+func leafValidators() []server.LeafValidator {
+	var validators []server.LeafValidator
 
-	return grpcServer
+	if *maxLeafValueBytesFlag > 0 || *maxLeafExtraDataBytesFlag > 0 {
+		validators = append(validators, server.LeafSizeValidator{MaxLeafValueBytes: *maxLeafValueBytesFlag, MaxExtraDataBytes: *maxLeafExtraDataBytesFlag})
+	}
+
+	if *rejectDuplicateLeavesFlag {
+		validators = append(validators, server.DuplicateLeafValidator{})
+	}
+
+	return validators
 }
 
-func awaitSignal(rpcServer *grpc.Server) {
+// awaitSignal waits for a signal to terminate and then stops the RPC server, which will
+// unblock main. RPCs that are already in progress are given up to drainTimeout to finish
+// before they're cut off, tree event streams are ended straight away as they never finish on
+// their own. The drained channel is closed when the RPC server has stopped.
+func awaitSignal(rpcServer *grpc.Server, treeEvents *server.TreeEvents, drainTimeout time.Duration, drained chan<- struct{}) {
+	defer close(drained)
+
 	// Arrange notification for the standard set of signals used to terminate a server
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 
 	// Now block main and wait for a signal
 	sig := <-sigs
-	glog.Infof("Signal received: %v", sig)
+	glog.Infof("Signal received: %v, draining for up to %v", sig, drainTimeout)
+
+	// Stop accepting new RPCs, this unblocks main straight away
+	treeEvents.Close()
+	stopped := make(chan struct{})
+	go func() {
+		rpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(drainTimeout):
+		glog.Warningf("RPCs still in progress after %v, stopping anyway", drainTimeout)
+		rpcServer.Stop()
+	}
+}
+
+// loadKeyManager returns the key manager that roots are signed with, for the key held by
+// --signing_server if it's set and otherwise for the private key file.
+func loadKeyManager() (crypto.KeyManager, error) {
+	if len(*signingServerFlag) > 0 {
+		conn, err := grpc.Dial(*signingServerFlag, grpc.WithInsecure())
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to signing server %s: %v", *signingServerFlag, err)
+		}
+
+		return crypto.NewRemoteKeyManager(crypto.NewSigningServiceClient(conn), *signingKeyIDFlag, *signingTimeoutFlag)
+	}
+
+	keyManager, err := crypto.LoadPasswordProtectedPrivateKey(*privateKeyFile, *privateKeyPassword)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if *deterministicSignaturesFlag {
+		keyManager = crypto.NewDeterministicKeyManager(keyManager)
+	}
 
-	// Bring down the RPC server, which will unblock main
-	rpcServer.Stop()
+	return keyManager, nil
 }
 
 func main() {
 	flag.Parse()
 
+	if *validateConfigFlag {
+		report := validateConfig()
+
+		if err := report.Write(os.Stdout); err != nil {
+			glog.Errorf("Failed to write config report: %v", err)
+		}
+
+		os.Exit(report.ExitCode())
+	}
+
 	done := make(chan struct{})
 
 	glog.Info("**** Log Server Starting ****")
 
+	if _, err := util.RPCCompressionEncoding(*rpcCompressionFlag); err != nil {
+		glog.Fatalf("Invalid --rpc_compression: %v", err)
+	}
+
+	provider, err := storageProvider()
+
+	if err != nil {
+		glog.Fatalf("Invalid storage configuration: %v", err)
+	}
+
 	// First make sure we can access the database, quit if not
-	if err := checkDatabaseAccessible(*mysqlUriFlag); err != nil {
+	if err := checkDatabaseAccessible(provider); err != nil {
 		glog.Errorf("Could not access storage, check db configuration and flags")
 		os.Exit(1)
 	}
 
+	if *storageSystemFlag == "mysql" && *checkSchemaFlag {
+		if err := mysql.CheckSchema(*mysqlUriFlag); err != nil {
+			glog.Fatalf("Storage schema check failed: %v", err)
+		}
+	}
+
+	if *storageSystemFlag == "mysql" {
+		// Served on /debug/vars by expvar along with the other metrics
+		expvar.Publish("mysql_failover", expvar.Func(func() interface{} {
+			return mysql.GetFailoverStats()
+		}))
+	}
+
 	// Load up our private key, exit if this fails to work
 	// TODO(Martin2112): This will need to be changed for multi tenant as we'll need at
 	// least one key per tenant, possibly more.
-	keyManager, err := crypto.LoadPasswordProtectedPrivateKey(*privateKeyFile, *privateKeyPassword)
+	keyManager, err := loadKeyManager()
 
 	if err != nil {
 		glog.Fatalf("Failed to load server key: %v", err)
 	}
 
+	if len(*leafDataMasterKeyFile) > 0 {
+		masterKey, err := ioutil.ReadFile(*leafDataMasterKeyFile)
+
+		if err != nil {
+			glog.Fatalf("Failed to read leaf data master key: %v", err)
+		}
+
+		if leafDataKeyWrapper, err = crypto.NewAESKeyWrapper(masterKey); err != nil {
+			glog.Fatalf("Failed to create leaf data key wrapper: %v", err)
+		}
+	}
+
+	if len(*extraDataBlobDirFlag) > 0 {
+		if extraDataBlobStore, err = storage.NewFileBlobStore(*extraDataBlobDirFlag); err != nil {
+			glog.Fatalf("Failed to create ExtraData blob store: %v", err)
+		}
+	}
+
+	if len(*auditJournalDirFlag) > 0 {
+		if auditJournal, err = audit.NewJournal(*auditJournalDirFlag, *auditJournalMaxFileBytesFlag, util.SystemTimeSource{}); err != nil {
+			glog.Fatalf("Failed to create audit journal: %v", err)
+		}
+
+		if len(*auditJournalShipDirFlag) > 0 {
+			shipper, err := storage.NewFileBlobStore(*auditJournalShipDirFlag)
+
+			if err != nil {
+				glog.Fatalf("Failed to create audit journal ship directory: %v", err)
+			}
+
+			auditJournal.SetShipper(shipper)
+
+			// Ship anything left over from a previous run
+			go func() {
+				if err := auditJournal.ShipCompleted(); err != nil {
+					glog.Warningf("Failed to ship audit journal files: %v", err)
+				}
+			}()
+		}
+	}
+
+	if *partitionSizeFlag > 0 {
+		pm, err := mysql.NewPartitionManager(*mysqlUriFlag, *partitionSizeFlag, *partitionSpareFlag)
+
+		if err != nil {
+			glog.Fatalf("Failed to create partition manager: %v", err)
+		}
+
+		defer pm.Close()
+
+		// Make sure there is room for the data before we start writing it
+		if err := pm.Rollover(); err != nil {
+			glog.Fatalf("Failed to add partitions: %v", err)
+		}
+
+		go rolloverPartitions(pm, *partitionRolloverIntervalFlag, done)
+	}
+
+	if len(*featuresFileFlag) > 0 {
+		configs, err := util.LoadFeatureConfigs(*featuresFileFlag)
+
+		if err != nil {
+			glog.Fatalf("Failed to load features: %v", err)
+		}
+
+		features = util.NewFeatures(server.LogServerFeatures...)
+
+		if err := features.Apply(configs); err != nil {
+			glog.Fatalf("Failed to apply features: %v", err)
+		}
+
+		go features.ReloadOnChange(done, *featuresFileFlag, *featuresReloadIntervalFlag)
+	}
+
 	// Set up the listener for the server
 	glog.Infof("Creating RPC server starting on port: %d", *serverPortFlag)
 	// TODO(Martin2112): More flexible listen address configuration
@@ -143,15 +509,66 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Start the sequencing loop, which will run until we terminate the process. This controls
-	// both sequencing and signing.
+	// Start the sequencing loop, which will run until we terminate the process. Roots are also
+	// signed by a goroutine per log that keeps them fresh when no leaves are being added.
 	// TODO(Martin2112): Should respect read only mode and the flags in tree control etc
-	sequencerManager := server.NewLogOperationManager(done, getStorageForLog, *batchSizeFlag, *sequencerSleepBetweenRunsFlag, *signerSleepBetweenRunsFlag, util.SystemTimeSource{}, server.NewSequencerManager(keyManager))
-	go sequencerManager.OperationLoop()
+	sequencerTask := server.NewSequencerManager(keyManager)
+	sequencerTask.SetSignEveryNLeaves(*signEveryNLeavesFlag)
+	sequencerTask.SetAuditJournal(auditJournal)
+	sequencerTask.SetVerifyRoots(*verifyStoredRootsFlag)
+	sequencerTask.SetNodeFlushSize(*nodeFlushSizeFlag)
+	sequencerTask.SetBatchMemoryBudget(*batchMemoryBudgetFlag)
+	sequencerTask.SetFeatures(features)
+	// Served on /debug/vars by expvar along with the other metrics
+	sequencerTimings := log.NewSequencerTimings(*slowSequencerBatchThresholdFlag)
+	sequencerTask.SetTimings(sequencerTimings)
+	expvar.Publish("sequencer_timings", expvar.Func(func() interface{} {
+		return sequencerTimings.Stats()
+	}))
+	// New roots and sequencing errors are streamed to clients that subscribe to them
+	treeEvents := server.NewTreeEvents(util.SystemTimeSource{})
+	sequencerTask.SetTreeEvents(treeEvents)
+	// Cancelled if sequencing hasn't stopped by the end of the drain timeout, so that the batch
+	// or root in progress is rolled back
+	sequencingCtx, cancelSequencing := context.WithCancel(context.Background())
+	defer cancelSequencing()
+	sequencerManager := server.NewLogOperationManager(done, getStorageForLog, *batchSizeFlag, *sequencerSleepBetweenRunsFlag, *signerSleepBetweenRunsFlag, util.SystemTimeSource{}, sequencerTask)
+	sequencerManager.SetContext(sequencingCtx)
+	sequencerStopped := make(chan struct{})
+	go func() {
+		sequencerManager.OperationLoop()
+		close(sequencerStopped)
+	}()
+
+	// New logs are picked up by the freshness maintainer as often as the sequencer looks for them
+	freshnessTask := server.NewRootFreshnessMaintainer(sequencerTask)
+	freshnessManager := server.NewLogOperationManager(done, getStorageForLog, *batchSizeFlag, *sequencerSleepBetweenRunsFlag, *signerSleepBetweenRunsFlag, util.SystemTimeSource{}, freshnessTask)
+	freshnessManager.SetContext(sequencingCtx)
+	freshnessStopped := make(chan struct{})
+	go func() {
+		freshnessManager.OperationLoop()
+		freshnessTask.Wait()
+		close(freshnessStopped)
+	}()
 
 	// Bring up the RPC server and then block until we get a signal to stop
-	rpcServer := startRpcServer(lis, *serverPortFlag, getStorageForLog)
-	go awaitSignal(rpcServer)
+	rpcServer, logServer := startRpcServer(lis, *serverPortFlag, getStorageForLog, sequencerManager.FlushLog, treeEvents)
+
+	// Proofs are checked through the same server that answers clients, it only reads so it
+	// isn't waited for at shutdown
+	if *proofSampleIntervalFlag > 0 {
+		startProofSampler(done, logServer)
+	}
+
+	// Served on /debug/vars by expvar
+	if len(*metricsEndpointFlag) > 0 {
+		go func() {
+			glog.Warningf("Metrics server exited: %v", http.ListenAndServe(*metricsEndpointFlag, nil))
+		}()
+	}
+
+	rpcDrained := make(chan struct{})
+	go awaitSignal(rpcServer, treeEvents, *drainTimeoutFlag, rpcDrained)
 	err = rpcServer.Serve(lis)
 
 	if err != nil {
@@ -159,10 +576,48 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Shut down everything we previously started, rpc server is already down
+	// The RPC server is no longer accepting requests. Tell the sequencer and the freshness
+	// maintainer to stop, they will finish the batch or root they're working on, if any, which
+	// either commits or rolls back. If they take longer than the drain timeout the work is
+	// cancelled.
 	close(done)
 
-	// Give things a few seconds to tidy up
-	glog.Infof("Stopping server, about to exit")
-	time.Sleep(time.Second * 5)
+	drainDeadline := time.After(*drainTimeoutFlag)
+	cancelled := false
+
+drain:
+	for _, stopped := range []chan struct{}{sequencerStopped, freshnessStopped} {
+		for {
+			select {
+			case <-stopped:
+				continue drain
+			case <-drainDeadline:
+			}
+
+			if cancelled {
+				glog.Warningf("Sequencer still running after cancellation, stopping anyway")
+				break drain
+			}
+
+			// Cancel the batch or root in progress, it's rolled back at the next phase boundary.
+			// Allow the same time again for that to happen.
+			glog.Warningf("Sequencer still running after %v, cancelling in progress work", *drainTimeoutFlag)
+			cancelSequencing()
+			cancelled = true
+			drainDeadline = time.After(*drainTimeoutFlag)
+		}
+	}
+
+	// In-flight RPCs were drained in parallel with the sequencer and are subject to their
+	// own timeout
+	<-rpcDrained
+
+	glog.Infof("Stopping server, closing storage")
+	closeAllStorage()
+
+	if auditJournal != nil {
+		if err := auditJournal.Close(); err != nil {
+			glog.Warningf("Failed to close audit journal: %v", err)
+		}
+	}
 }
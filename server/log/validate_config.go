@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/server"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/mysql"
+	"github.com/google/trillian/util"
+)
+
+// validateConfig checks the flags and everything they refer to without starting the server:
+// the storage can be reached and has the expected schema, the keys and files can be loaded
+// and the latest root of every log was signed by the configured key.
+func validateConfig() *util.ConfigReport {
+	report := util.NewConfigReport("trillian_log_server")
+
+	report.Check("rpc_compression", func() error {
+		_, err := util.RPCCompressionEncoding(*rpcCompressionFlag)
+		return err
+	})
+
+	if *idempotencyWindowFlag > 0 {
+		report.Check("idempotency", func() error {
+			if *idempotencyMaxKeysFlag <= 0 {
+				return fmt.Errorf("--idempotency_max_keys must be positive if --idempotency_window is set: %d", *idempotencyMaxKeysFlag)
+			}
+
+			return nil
+		})
+	}
+
+	if *proofSampleIntervalFlag > 0 {
+		report.Check("proof_sampler", func() error {
+			if *proofSamplesPerLogFlag <= 0 || *proofSampleWindowFlag <= 0 {
+				return fmt.Errorf("--proof_samples_per_log and --proof_sample_window must be positive if --proof_sample_interval is set: %d, %d", *proofSamplesPerLogFlag, *proofSampleWindowFlag)
+			}
+
+			return nil
+		})
+	}
+
+	var provider func(treeID int64) (storage.LogStorage, error)
+	storageOK := report.Check("storage_config", func() error {
+		var err error
+		provider, err = storageProvider()
+		return err
+	}) && report.Check("storage_access", func() error {
+		return checkDatabaseAccessible(provider)
+	})
+
+	if *storageSystemFlag == "mysql" && *checkSchemaFlag {
+		if !storageOK {
+			report.Skip("storage_schema", "storage isn't accessible")
+		} else {
+			report.Check("storage_schema", func() error {
+				return mysql.CheckSchema(*mysqlUriFlag)
+			})
+		}
+	}
+
+	var keyManager crypto.KeyManager
+	keyOK := report.Check("private_key", func() error {
+		var err error
+		keyManager, err = loadKeyManager()
+		return err
+	})
+
+	if *verifyStoredRootsFlag {
+		if !storageOK || !keyOK {
+			report.Skip("log_root_signatures", "storage or private key isn't available")
+		} else {
+			report.Check("log_root_signatures", func() error {
+				return checkLogRootSignatures(provider, keyManager)
+			})
+		}
+	}
+
+	if len(*leafDataMasterKeyFile) > 0 {
+		report.Check("leaf_data_master_key", func() error {
+			masterKey, err := ioutil.ReadFile(*leafDataMasterKeyFile)
+
+			if err != nil {
+				return err
+			}
+
+			_, err = crypto.NewAESKeyWrapper(masterKey)
+			return err
+		})
+	}
+
+	for _, dir := range []struct{ name, path string }{
+		{"extra_data_blob_dir", *extraDataBlobDirFlag},
+		{"audit_journal_dir", *auditJournalDirFlag},
+		{"audit_journal_ship_dir", *auditJournalShipDirFlag},
+	} {
+		if len(dir.path) > 0 {
+			report.Check(dir.name, func() error {
+				return util.CheckWritableDir(dir.path)
+			})
+		}
+	}
+
+	if *partitionSizeFlag > 0 {
+		report.Check("partitions", func() error {
+			pm, err := mysql.NewPartitionManager(*mysqlUriFlag, *partitionSizeFlag, *partitionSpareFlag)
+
+			if err != nil {
+				return err
+			}
+
+			return pm.Close()
+		})
+	}
+
+	if len(*featuresFileFlag) > 0 {
+		report.Check("features_file", func() error {
+			configs, err := util.LoadFeatureConfigs(*featuresFileFlag)
+
+			if err != nil {
+				return err
+			}
+
+			return util.NewFeatures(server.LogServerFeatures...).Apply(configs)
+		})
+	}
+
+	return report
+}
+
+// checkLogRootSignatures checks that the latest root of every log in storage was signed by the
+// key the server will sign new roots with, as the sequencer won't build on one that wasn't.
+// A new log's empty root has never been signed and is accepted.
+func checkLogRootSignatures(provider func(treeID int64) (storage.LogStorage, error), keyManager crypto.KeyManager) error {
+	signer, err := keyManager.Signer()
+
+	if err != nil {
+		return err
+	}
+
+	logIDs, err := activeLogIDs(provider)
+
+	if err != nil {
+		return err
+	}
+
+	for _, logID := range logIDs {
+		root, hasher, err := latestRoot(provider, logID.TreeID)
+
+		if err != nil {
+			return fmt.Errorf("failed to read the latest root of log %d: %v", logID.TreeID, err)
+		}
+
+		if root.Signature == nil && root.TreeSize == 0 && root.TreeRevision == 0 {
+			continue
+		}
+
+		if err := crypto.VerifyLogRoot(hasher, signer.Public(), root); err != nil {
+			return fmt.Errorf("latest root of log %d wasn't signed by the private key: %v", logID.TreeID, err)
+		}
+	}
+
+	return nil
+}
+
+func activeLogIDs(provider func(treeID int64) (storage.LogStorage, error)) ([]trillian.LogID, error) {
+	s, err := provider(0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.Close()
+
+	tx, err := s.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Commit()
+
+	return tx.GetActiveLogIDs()
+}
+
+// latestRoot returns the latest root of a log and the hasher of its tree, which its roots are
+// signed with.
+func latestRoot(provider func(treeID int64) (storage.LogStorage, error), treeID int64) (trillian.SignedLogRoot, trillian.Hasher, error) {
+	s, err := provider(treeID)
+
+	if err != nil {
+		return trillian.SignedLogRoot{}, trillian.Hasher{}, err
+	}
+
+	defer s.Close()
+
+	hasher, err := trillian.NewHasher(s.HashAlgorithm())
+
+	if err != nil {
+		return trillian.SignedLogRoot{}, trillian.Hasher{}, err
+	}
+
+	tx, err := s.Begin()
+
+	if err != nil {
+		return trillian.SignedLogRoot{}, trillian.Hasher{}, err
+	}
+
+	defer tx.Commit()
+
+	root, err := tx.LatestSignedLogRoot()
+	return root, hasher, err
+}
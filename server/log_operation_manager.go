@@ -1,11 +1,13 @@
 package server
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/golang/glog"
 	"github.com/google/trillian"
 	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
 )
 
 // LogOperation defines a task that operates on logs. Examples are scheduling, signing,
@@ -15,6 +17,12 @@ type LogOperation interface {
 	ExecutePass([]trillian.LogID, LogOperationManagerContext) bool
 }
 
+// LogFlusher is implemented by log operations that can be run for a single log on demand,
+// outside of the operation loop.
+type LogFlusher interface {
+	FlushLog(ctx context.Context, logID int64, forceNewRoot bool, context LogOperationManagerContext) (int, error)
+}
+
 // LogOperationManagerContext bundles up the values so testing can be made easier
 type LogOperationManagerContext struct {
 	// done is a channel that provides an exit signal
@@ -25,12 +33,25 @@ type LogOperationManagerContext struct {
 	batchSize int
 	// sleepBetweenRuns is the time to pause after all active logs have processed a batch
 	sleepBetweenRuns time.Duration
-	// signInterval is the interval when we will create new STHs if no new leaves added
+	// signInterval is the max age of a log's newest root before RootFreshnessMaintainer signs
+	// a new one
 	signInterval time.Duration
 	// oneShot is for use by tests only, it exits after one pass
 	oneShot bool
 	// timeSource allows us to mock this in tests
 	timeSource util.TimeSource
+	// ctx is cancelled to abandon the batches and roots that are in progress, they're rolled
+	// back. If it's nil they can't be cancelled.
+	ctx context.Context
+}
+
+// operationCtx returns the context that operations run by the manager should use
+func (c LogOperationManagerContext) operationCtx() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+
+	return c.ctx
 }
 
 // LogOperationManager controls scheduling activities for logs. At the moment it's very simple
@@ -93,15 +114,43 @@ func (l LogOperationManager) getLogsAndExecutePass() bool {
 	return quit
 }
 
-// OperationLoop starts the manager working. It continues until told to exit.
+// SetContext gives the manager a context that can be cancelled to interrupt its operation,
+// e.g. when shutting down takes too long. Work in progress is rolled back. Closing the done
+// channel only stops new passes from starting.
+func (l *LogOperationManager) SetContext(ctx context.Context) {
+	l.context.ctx = ctx
+}
+
+// FlushLog runs the manager's operation for a single log immediately, with the same settings
+// as the operation loop uses. It fails if the operation doesn't support this. Cancelling ctx
+// rolls back the operation, as does cancelling the manager's context.
+func (l LogOperationManager) FlushLog(ctx context.Context, logID int64, forceNewRoot bool) (int, error) {
+	flusher, ok := l.logOperation.(LogFlusher)
+
+	if !ok {
+		return 0, fmt.Errorf("log operation %s can't be run for a single log", l.logOperation.Name())
+	}
+
+	return flusher.FlushLog(ctx, logID, forceNewRoot, l.context)
+}
+
+// OperationLoop starts the manager working. It continues until told to exit by closing
+// the done channel.
 // TODO(Martin2112): No mechanism for error reporting etc., this is OK for v1 but needs work
 func (l LogOperationManager) OperationLoop() {
 	glog.Infof("Log operation manager starting")
 
 	// Outer loop, runs until terminated
 	for {
-		// Wait for the configured time before going for another pass
-		time.Sleep(l.context.sleepBetweenRuns)
+		// Wait for the configured time before going for another pass, unless told to exit.
+		// A pass that has already started runs to the end of the current batch so that it
+		// commits or rolls back cleanly.
+		select {
+		case <-l.context.done:
+			glog.Infof("Log operation manager shutting down")
+			return
+		case <-time.After(l.context.sleepBetweenRuns):
+		}
 
 		quit := l.getLogsAndExecutePass()
 
@@ -9,6 +9,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/google/trillian"
 	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
 )
 
 func TestLogOperationManagerBeginFails(t *testing.T) {
@@ -27,6 +28,22 @@ func TestLogOperationManagerBeginFails(t *testing.T) {
 	lom.OperationLoop()
 }
 
+func TestLogOperationManagerExitsWhenDone(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Nothing should be called on either of these
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockLogOp := NewMockLogOperation(ctrl)
+
+	done := make(chan struct{})
+	close(done)
+	lom := NewLogOperationManager(done, mockStorageProviderForSequencer(mockStorage), 50, time.Hour, time.Second, fakeTimeSource, mockLogOp)
+
+	// This must return promptly rather than waiting for the next pass
+	lom.OperationLoop()
+}
+
 func TestLogOperationManagerGetLogsFails(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -100,3 +117,20 @@ func TestLogOperationManagerPassesIDs(t *testing.T) {
 
 	lom.OperationLoop()
 }
+
+func TestLogOperationManagerFlushLogNotSupported(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The mock operation can't be run for a single log
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockLogOp := NewMockLogOperation(ctrl)
+	mockLogOp.EXPECT().Name().AnyTimes().Return("mock")
+
+	done := make(chan struct{})
+	lom := NewLogOperationManager(done, mockStorageProviderForSequencer(mockStorage), 50, time.Second, time.Second, fakeTimeSource, mockLogOp)
+
+	if _, err := lom.FlushLog(context.Background(), 1, false); err == nil {
+		t.Fatal("Expected FlushLog to fail for an operation that doesn't support it")
+	}
+}
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+)
+
+// ProofSamplerStats describes the proofs a ProofSampler has checked.
+type ProofSamplerStats struct {
+	// Correctness is the fraction of the proofs checked in the last pass that verified, 1 if
+	// none were checked. Anything less means clients are being served bad proofs.
+	Correctness float64
+	// Checked is the number of proofs that have been checked
+	Checked int64
+	// Failed is the number of proofs, or leaves, that didn't verify
+	Failed int64
+	// Errors is the number of samples that couldn't be checked because a request failed
+	Errors int64
+	// FailedLogs counts the failures of each log that has had one
+	FailedLogs map[int64]int64
+}
+
+// ProofSampler checks the inclusion proofs the log server generates, so that a regression in
+// proof generation or a corrupted tree is noticed before clients notice it. Each pass picks
+// random leaves among the most recent ones of each active log and asks the log server for them
+// and their inclusion proofs in the latest root, through the same code that serves clients,
+// then verifies the proofs against the root. Leaf 0 is never sampled as GetInclusionProof
+// doesn't serve proofs for it. It's run by a LogOperationManager, whose sleep between runs
+// sets how often it samples.
+type ProofSampler struct {
+	logServer trillian.TrillianLogServer
+	// samples is the number of leaves of each log checked per pass
+	samples int
+	// window is the number of the most recent leaves that samples are picked from
+	window int64
+	rng    *rand.Rand
+
+	// mu guards the fields below it
+	mu sync.Mutex
+	// lastChecked and lastFailed count the proofs of the last pass
+	lastChecked int64
+	lastFailed  int64
+	checked     int64
+	failed      int64
+	errors      int64
+	failedLogs  map[int64]int64
+}
+
+// NewProofSampler creates a ProofSampler that checks samples leaves of each log per pass,
+// picked from its window most recent leaves, using the proofs served by logServer.
+func NewProofSampler(logServer trillian.TrillianLogServer, samples int, window int64) *ProofSampler {
+	return &ProofSampler{logServer: logServer, samples: samples, window: window, rng: rand.New(rand.NewSource(time.Now().UnixNano())), failedLogs: make(map[int64]int64)}
+}
+
+func (p *ProofSampler) Name() string {
+	return "ProofSampler"
+}
+
+// Stats returns what the sampler has found so far.
+func (p *ProofSampler) Stats() ProofSamplerStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := ProofSamplerStats{Correctness: 1, Checked: p.checked, Failed: p.failed, Errors: p.errors, FailedLogs: make(map[int64]int64)}
+
+	if p.lastChecked > 0 {
+		stats.Correctness = float64(p.lastChecked-p.lastFailed) / float64(p.lastChecked)
+	}
+
+	for logID, failed := range p.failedLogs {
+		stats.FailedLogs[logID] = failed
+	}
+
+	return stats
+}
+
+func (p *ProofSampler) ExecutePass(logIDs []trillian.LogID, context LogOperationManagerContext) bool {
+	var checked, failed, errors int64
+
+	for _, logID := range logIDs {
+		select {
+		case <-context.done:
+			return true
+		default:
+		}
+
+		c, f, err := p.sampleLog(context.operationCtx(), logID.TreeID, context)
+
+		if err != nil {
+			glog.Warningf("Failed to sample the proofs of log %d: %v", logID.TreeID, err)
+			errors++
+		}
+
+		checked += c
+		failed += f
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.lastChecked, p.lastFailed = checked, failed
+	p.checked += checked
+	p.failed += failed
+	p.errors += errors
+
+	return false
+}
+
+// sampleLog checks the proofs of randomly picked recent leaves of a log against its latest root,
+// returning the number checked and the number that didn't verify. It stops at the first
+// request that fails.
+func (p *ProofSampler) sampleLog(ctx context.Context, logID int64, context LogOperationManagerContext) (checked, failed int64, err error) {
+	s, err := context.storageProvider(logID)
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	hasher, err := merkle.NewTreeHasherForAlgorithm(s.HashAlgorithm(), s.LeafHashStrategy())
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	rootResp, err := p.logServer.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: logID})
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	root := rootResp.GetSignedLogRoot()
+
+	// Leaf 0 can't be sampled so there's nothing to check until there are two leaves
+	if root == nil || root.TreeSize < 2 {
+		return 0, 0, nil
+	}
+
+	first := root.TreeSize - p.window
+
+	if first < 1 {
+		first = 1
+	}
+
+	for i := 0; i < p.samples; i++ {
+		index := first + p.rng.Int63n(root.TreeSize-first)
+
+		if err := p.checkLeaf(ctx, hasher, logID, index, root); err != nil {
+			if _, ok := err.(proofSampleError); !ok {
+				return checked, failed, err
+			}
+
+			glog.Errorf("Proof self check failed for log %d: %v", logID, err)
+			failed++
+
+			p.mu.Lock()
+			p.failedLogs[logID]++
+			p.mu.Unlock()
+		}
+
+		checked++
+	}
+
+	return checked, failed, nil
+}
+
+// proofSampleError is returned by checkLeaf when the log server's response is wrong, rather
+// than the request failing
+type proofSampleError struct {
+	error
+}
+
+// checkLeaf verifies the leaf at index of a log and its inclusion proof in root.
+func (p *ProofSampler) checkLeaf(ctx context.Context, hasher merkle.TreeHasher, logID, index int64, root *trillian.SignedLogRoot) error {
+	leavesResp, err := p.logServer.GetLeavesByIndex(ctx, &trillian.GetLeavesByIndexRequest{LogId: logID, LeafIndex: []int64{index}, OmitExtraData: true})
+
+	if err != nil {
+		return err
+	}
+
+	if !apiStatusOK(leavesResp.GetStatus()) {
+		return fmt.Errorf("GetLeavesByIndex(%d) failed: %v", index, leavesResp.GetStatus())
+	}
+
+	if len(leavesResp.Leaves) != 1 {
+		return proofSampleError{fmt.Errorf("got %d leaves at index %d of a tree of size %d", len(leavesResp.Leaves), index, root.TreeSize)}
+	}
+
+	leaf := leavesResp.Leaves[0]
+
+	if !bytes.Equal(leaf.LeafHash, hasher.HashLeaf(leaf.LeafData)) {
+		return proofSampleError{fmt.Errorf("leaf %d has hash %x but its data hashes to %x", index, leaf.LeafHash, hasher.HashLeaf(leaf.LeafData))}
+	}
+
+	proofResp, err := p.logServer.GetInclusionProof(ctx, &trillian.GetInclusionProofRequest{LogId: logID, LeafIndex: index, TreeSize: root.TreeSize})
+
+	if err != nil {
+		return err
+	}
+
+	if !apiStatusOK(proofResp.GetStatus()) {
+		return fmt.Errorf("GetInclusionProof(%d, %d) failed: %v", index, root.TreeSize, proofResp.GetStatus())
+	}
+
+	var proof [][]byte
+
+	for _, node := range proofResp.GetProof().GetProofNode() {
+		proof = append(proof, node.NodeHash)
+	}
+
+	if err := merkle.VerifyInclusionProof(hasher, index, root.TreeSize, proof, root.RootHash, leaf.LeafHash); err != nil {
+		return proofSampleError{fmt.Errorf("inclusion proof of leaf %d in the tree of size %d doesn't verify: %v", index, root.TreeSize, err)}
+	}
+
+	return nil
+}
+
+func apiStatusOK(status *trillian.TrillianApiStatus) bool {
+	return status != nil && status.StatusCode == trillian.TrillianApiStatusCode_OK
+}
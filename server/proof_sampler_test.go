@@ -0,0 +1,171 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+// proofSamplerTestServer serves leaves and proofs from an in memory tree. Methods the sampler
+// doesn't use aren't implemented.
+type proofSamplerTestServer struct {
+	trillian.TrillianLogServer
+	tree   *merkle.InMemoryMerkleTree
+	leaves [][]byte
+	// corrupt makes every proof lead to the wrong root
+	corrupt bool
+	// err makes GetInclusionProof fail
+	err error
+	// indices records the leaf indices that were requested
+	indices []int64
+}
+
+func newProofSamplerTestServer(size int) *proofSamplerTestServer {
+	s := &proofSamplerTestServer{tree: merkle.NewInMemoryMerkleTree(merkle.NewRFC6962TreeHasher(trillian.NewSHA256()))}
+
+	for i := 0; i < size; i++ {
+		leaf := []byte(fmt.Sprintf("leaf %d", i))
+		s.tree.AddLeaf(leaf)
+		s.leaves = append(s.leaves, leaf)
+	}
+
+	return s
+}
+
+func (s *proofSamplerTestServer) GetLatestSignedLogRoot(ctx context.Context, req *trillian.GetLatestSignedLogRootRequest) (*trillian.GetLatestSignedLogRootResponse, error) {
+	root := &trillian.SignedLogRoot{TreeSize: int64(len(s.leaves)), RootHash: s.tree.CurrentRoot().Hash()}
+	return &trillian.GetLatestSignedLogRootResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), SignedLogRoot: root}, nil
+}
+
+func (s *proofSamplerTestServer) GetLeavesByIndex(ctx context.Context, req *trillian.GetLeavesByIndexRequest) (*trillian.GetLeavesByIndexResponse, error) {
+	var leaves []*trillian.LeafProto
+
+	for _, index := range req.LeafIndex {
+		s.indices = append(s.indices, index)
+		hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+		leaves = append(leaves, &trillian.LeafProto{LeafIndex: index, LeafData: s.leaves[index], LeafHash: hasher.HashLeaf(s.leaves[index])})
+	}
+
+	return &trillian.GetLeavesByIndexResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Leaves: leaves}, nil
+}
+
+func (s *proofSamplerTestServer) GetInclusionProof(ctx context.Context, req *trillian.GetInclusionProofRequest) (*trillian.GetInclusionProofResponse, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	proof := &trillian.ProofProto{LeafIndex: req.LeafIndex}
+
+	// The in memory tree numbers leaves from 1
+	for _, node := range s.tree.PathToRootAtSnapshot(int(req.LeafIndex)+1, int(req.TreeSize)) {
+		hash := node.Value.Hash()
+
+		if s.corrupt {
+			hash = append([]byte{}, hash...)
+			hash[0] ^= 1
+		}
+
+		proof.ProofNode = append(proof.ProofNode, &trillian.NodeProto{NodeHash: hash})
+	}
+
+	return &trillian.GetInclusionProofResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Proof: proof}, nil
+}
+
+func runProofSamplerPass(t *testing.T, sampler *ProofSampler) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockStorage.EXPECT().HashAlgorithm().AnyTimes().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().LeafHashStrategy().AnyTimes().Return(trillian.LeafHashStrategy_RFC6962_LEAF_HASH)
+
+	sampler.ExecutePass([]trillian.LogID{logID1}, createTestContext(mockStorageProviderForSequencer(mockStorage)))
+}
+
+func TestProofSamplerVerifiesRecentLeaves(t *testing.T) {
+	logServer := newProofSamplerTestServer(9)
+	sampler := NewProofSampler(logServer, 20, 4)
+
+	runProofSamplerPass(t, sampler)
+
+	stats := sampler.Stats()
+
+	if stats.Checked != 20 || stats.Failed != 0 || stats.Errors != 0 || stats.Correctness != 1 {
+		t.Fatalf("Got stats %+v after checking good proofs, expected 20 checked and none failed", stats)
+	}
+
+	for _, index := range logServer.indices {
+		if index < 5 || index >= 9 {
+			t.Errorf("Sampled leaf %d, which isn't one of the 4 most recent of 9", index)
+		}
+	}
+}
+
+func TestProofSamplerNeverSamplesLeafZero(t *testing.T) {
+	logServer := newProofSamplerTestServer(2)
+	sampler := NewProofSampler(logServer, 10, 100)
+
+	runProofSamplerPass(t, sampler)
+
+	for _, index := range logServer.indices {
+		if index != 1 {
+			t.Errorf("Sampled leaf %d of a tree of size 2, expected only leaf 1", index)
+		}
+	}
+
+	// A tree with only leaf 0 has nothing to check
+	sampler = NewProofSampler(newProofSamplerTestServer(1), 10, 100)
+	runProofSamplerPass(t, sampler)
+
+	if stats := sampler.Stats(); stats.Checked != 0 || stats.Correctness != 1 {
+		t.Errorf("Got stats %+v for a tree of size 1, expected nothing checked", stats)
+	}
+}
+
+func TestProofSamplerDetectsBadProofs(t *testing.T) {
+	logServer := newProofSamplerTestServer(16)
+	logServer.corrupt = true
+	sampler := NewProofSampler(logServer, 5, 100)
+
+	runProofSamplerPass(t, sampler)
+
+	stats := sampler.Stats()
+
+	if stats.Checked != 5 || stats.Failed != 5 || stats.Correctness != 0 {
+		t.Fatalf("Got stats %+v after checking bad proofs, expected all 5 to fail", stats)
+	}
+
+	if got, want := stats.FailedLogs[logID1.TreeID], int64(5); got != want {
+		t.Errorf("Got %d failures for the log, expected %d", got, want)
+	}
+
+	// The gauge reflects the last pass, the counts are cumulative
+	logServer.corrupt = false
+	runProofSamplerPass(t, sampler)
+
+	stats = sampler.Stats()
+
+	if stats.Checked != 10 || stats.Failed != 5 || stats.Correctness != 1 {
+		t.Errorf("Got stats %+v after the proofs were fixed, expected correctness 1", stats)
+	}
+}
+
+func TestProofSamplerCountsRequestErrorsSeparately(t *testing.T) {
+	logServer := newProofSamplerTestServer(16)
+	logServer.err = errors.New("storage unavailable")
+	sampler := NewProofSampler(logServer, 5, 100)
+
+	runProofSamplerPass(t, sampler)
+
+	stats := sampler.Stats()
+
+	if stats.Errors != 1 || stats.Checked != 0 || stats.Failed != 0 || stats.Correctness != 1 {
+		t.Errorf("Got stats %+v when requests fail, expected one error and no failed proofs", stats)
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// NewRequestLoggingInterceptor returns a gRPC interceptor that logs RPCs that fail or take
+// longer than slowThreshold. The log includes the request ID passed by the client, if any,
+// so that failures reported to a frontend can be traced through to the backend. Most of the
+// time taken by an RPC is spent in storage so slow RPCs usually point at slow queries, which
+// are logged with the same request ID, see TrillianLogServer.SetSlowStorageLogging.
+func NewRequestLoggingInterceptor(slowThreshold time.Duration, timeSource util.TimeSource) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := timeSource.Now()
+		resp, err := handler(ctx, req)
+		elapsed := timeSource.Now().Sub(start)
+
+		if err != nil {
+			glog.Warningf("RPC %s failed after %v, request ID: %q: %v", info.FullMethod, elapsed, util.RequestIDFromContext(ctx), err)
+		} else if elapsed > slowThreshold {
+			glog.Warningf("RPC %s was slow, took %v, request ID: %q", info.FullMethod, elapsed, util.RequestIDFromContext(ctx))
+		}
+
+		return resp, err
+	}
+}
+
+// slowLoggingLogTX is a storage transaction that's logged when it ends if it took longer than
+// threshold. Nearly all of a transaction's time is spent in its queries so this is the storage
+// slow query log, and it includes the request ID of the RPC the transaction was for.
+type slowLoggingLogTX struct {
+	storage.LogTX
+	requestID  string
+	threshold  time.Duration
+	timeSource util.TimeSource
+	start      time.Time
+}
+
+func newSlowLoggingLogTX(tx storage.LogTX, requestID string, threshold time.Duration, timeSource util.TimeSource) *slowLoggingLogTX {
+	return &slowLoggingLogTX{LogTX: tx, requestID: requestID, threshold: threshold, timeSource: timeSource, start: timeSource.Now()}
+}
+
+func (s *slowLoggingLogTX) Commit() error {
+	err := s.LogTX.Commit()
+	s.logIfSlow("committed")
+	return err
+}
+
+func (s *slowLoggingLogTX) Rollback() error {
+	err := s.LogTX.Rollback()
+	s.logIfSlow("rolled back")
+	return err
+}
+
+func (s *slowLoggingLogTX) logIfSlow(outcome string) {
+	if elapsed := s.timeSource.Now().Sub(s.start); elapsed > s.threshold {
+		glog.Warningf("Storage transaction was slow, %s after %v, request ID: %q", outcome, elapsed, s.requestID)
+	}
+}
+
+// unwrapLogTX returns the storage transaction underneath any logging, so that the optional
+// interfaces it implements can be found.
+func unwrapLogTX(tx storage.LogTX) storage.LogTX {
+	if s, ok := tx.(*slowLoggingLogTX); ok {
+		return s.LogTX
+	}
+
+	return tx
+}
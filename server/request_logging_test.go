@@ -0,0 +1,90 @@
+package server
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRequestLoggingInterceptorPassesThrough(t *testing.T) {
+	interceptor := NewRequestLoggingInterceptor(time.Second, util.SystemTimeSource{})
+	info := &grpc.UnaryServerInfo{FullMethod: "/trillian.TrillianLog/QueueLeaves"}
+	ctx := util.WithRequestID(context.Background(), "abcd")
+
+	resp, err := interceptor(ctx, "req", info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "resp", nil
+	})
+
+	if err != nil || resp != "resp" {
+		t.Fatalf("Got %v, %v from interceptor, expected the handler response", resp, err)
+	}
+
+	_, err = interceptor(ctx, "req", info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, errors.New("RPCFAIL")
+	})
+
+	if err == nil || err.Error() != "RPCFAIL" {
+		t.Fatalf("Got error %v from interceptor, expected the handler error", err)
+	}
+}
+
+func TestSlowLoggingLogTXPassesThrough(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockTx.EXPECT().Commit().Return(errors.New("COMMIT"))
+	mockTx.EXPECT().Rollback().Return(nil)
+
+	timeSource := &util.FakeTimeSource{FakeTime: time.Unix(1000, 0)}
+	tx := newSlowLoggingLogTX(mockTx, "abcd", time.Second, timeSource)
+
+	// Slow enough to be logged
+	timeSource.FakeTime = timeSource.FakeTime.Add(time.Minute)
+
+	if err := tx.Commit(); err == nil || err.Error() != "COMMIT" {
+		t.Fatalf("Got error %v from Commit(), expected the storage error", err)
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Got error %v from Rollback(), expected none", err)
+	}
+
+	if got := unwrapLogTX(tx); got != mockTx {
+		t.Fatalf("unwrapLogTX()=%v, expected the storage transaction", got)
+	}
+}
+
+func TestBeginTxLogsSlowTransactions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().Begin().Return(mockTx, nil).Times(2)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(util.RequestIDMetadataKey, "abcd"))
+
+	if tx, err := server.beginTx(ctx, mockStorage); err != nil || tx != mockTx {
+		t.Fatalf("beginTx()=%v, %v without slow logging, expected the storage transaction", tx, err)
+	}
+
+	server.SetSlowStorageLogging(time.Second, util.SystemTimeSource{})
+	tx, err := server.beginTx(ctx, mockStorage)
+
+	if err != nil {
+		t.Fatalf("beginTx()=%v", err)
+	}
+
+	if logged, ok := tx.(*slowLoggingLogTX); !ok || logged.requestID != "abcd" || logged.LogTX != mockTx {
+		t.Fatalf("beginTx()=%v with slow logging, expected the transaction to be logged with its request ID", tx)
+	}
+}
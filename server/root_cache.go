@@ -0,0 +1,134 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/util"
+)
+
+// cachedRoot is a log's latest root and when it was read
+type cachedRoot struct {
+	root    trillian.SignedLogRoot
+	fetched time.Time
+}
+
+// RootCache holds the latest signed root of each log so that GetLatestSignedLogRoot doesn't
+// need a storage round trip for every request. When Watch is running the roots the sequencer
+// publishes replace the cached ones as soon as they're committed. Roots signed by another
+// server sharing the storage aren't published here so every root is read again once it's
+// older than the maximum age. A root never replaces one with a later revision, so a slow
+// read can't make the cache go backwards. It is safe for concurrent use.
+type RootCache struct {
+	maxAge     time.Duration
+	timeSource util.TimeSource
+
+	// mu guards the fields below it
+	mu sync.Mutex
+	// roots maps log IDs to their latest root
+	roots map[int64]cachedRoot
+	// hits and misses count lookups for monitoring
+	hits   int64
+	misses int64
+}
+
+// NewRootCache creates an empty RootCache that reads roots again once they're older than
+// maxAge, measured with timeSource.
+func NewRootCache(maxAge time.Duration, timeSource util.TimeSource) *RootCache {
+	return &RootCache{maxAge: maxAge, timeSource: timeSource, roots: make(map[int64]cachedRoot)}
+}
+
+// get returns the cached root of a log, if there is one that hasn't expired.
+func (c *RootCache) get(logID int64) (trillian.SignedLogRoot, bool) {
+	now := c.timeSource.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.roots[logID]
+
+	if !ok || now.Sub(cached.fetched) >= c.maxAge {
+		c.misses++
+		return trillian.SignedLogRoot{}, false
+	}
+
+	c.hits++
+
+	return cached.root, true
+}
+
+// put caches root as the latest root of a log unless a later revision is already cached.
+func (c *RootCache) put(logID int64, root trillian.SignedLogRoot) {
+	now := c.timeSource.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.roots[logID]; ok && cached.root.TreeRevision > root.TreeRevision {
+		return
+	}
+
+	c.roots[logID] = cachedRoot{root: root, fetched: now}
+}
+
+// Invalidate forgets the cached roots of every log, so they're read from storage again.
+func (c *RootCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.roots = make(map[int64]cachedRoot)
+}
+
+// Stats returns the number of lookups that were answered from the cache and that needed
+// storage.
+func (c *RootCache) Stats() (hits, misses int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.hits, c.misses
+}
+
+// Watch caches the roots published to events, normally by the sequencer, until done is
+// closed or events is, done may be nil if events will be. If it falls behind and is dropped
+// by events, it may have missed roots so the cache is invalidated and it subscribes again.
+func (c *RootCache) Watch(events *TreeEvents, done <-chan struct{}) {
+	for {
+		sub := events.subscribe(0)
+
+		c.cacheEvents(sub.events, done)
+		events.unsubscribe(sub)
+
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		if events.isClosed() {
+			return
+		}
+
+		glog.Warningf("Root cache fell behind the tree events, invalidating it")
+		c.Invalidate()
+	}
+}
+
+// cacheEvents caches the roots sent on events until it's closed or done is.
+func (c *RootCache) cacheEvents(events <-chan *trillian.TreeEvent, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			if event.SignedLogRoot != nil {
+				c.put(event.LogId, *event.SignedLogRoot)
+			}
+		}
+	}
+}
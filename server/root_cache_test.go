@@ -0,0 +1,104 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+)
+
+func TestRootCache(t *testing.T) {
+	ts := &util.FakeTimeSource{FakeTime: time.Date(2016, 11, 1, 12, 0, 0, 0, time.UTC)}
+	c := NewRootCache(time.Minute, ts)
+
+	if _, ok := c.get(1); ok {
+		t.Fatal("Empty cache returned a root")
+	}
+
+	c.put(1, trillian.SignedLogRoot{TreeSize: 10, TreeRevision: 5})
+
+	// A slow read of an older root doesn't replace a newer one
+	c.put(1, trillian.SignedLogRoot{TreeSize: 8, TreeRevision: 4})
+
+	if root, ok := c.get(1); !ok || root.TreeRevision != 5 {
+		t.Fatalf("Got root %v, %v, expected revision 5", root, ok)
+	}
+
+	if _, ok := c.get(2); ok {
+		t.Fatal("Cache returned a root for another log")
+	}
+
+	ts.FakeTime = ts.FakeTime.Add(time.Minute)
+
+	if _, ok := c.get(1); ok {
+		t.Fatal("Cache returned an expired root")
+	}
+
+	if hits, misses := c.Stats(); hits != 1 || misses != 3 {
+		t.Errorf("Got %d hits and %d misses, expected 1 and 3", hits, misses)
+	}
+}
+
+func TestRootCacheWatch(t *testing.T) {
+	c := NewRootCache(time.Hour, util.SystemTimeSource{})
+	events := NewTreeEvents(util.SystemTimeSource{})
+	stopped := make(chan struct{})
+
+	go func() {
+		c.Watch(events, nil)
+		close(stopped)
+	}()
+
+	// Wait for Watch to subscribe before publishing
+	for {
+		events.mu.Lock()
+		subscribed := len(events.subscribers) > 0
+		events.mu.Unlock()
+
+		if subscribed {
+			break
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	publishRoots(events, 1)(trillian.SignedLogRoot{TreeSize: 3, TreeRevision: 2})
+	publishSequencingError(events, 1, context.Canceled)
+
+	// The events are handled in order so closing waits for the root to be cached
+	events.Close()
+	<-stopped
+
+	if root, ok := c.get(1); !ok || root.TreeSize != 3 {
+		t.Errorf("Got root %v, %v after it was published, expected tree size 3", root, ok)
+	}
+}
+
+func TestGetLatestSignedLogRootCached(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+
+	// Storage is only read for the first request
+	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedLogRoot().Return(signedRoot1, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	server.SetRootCache(NewRootCache(time.Hour, util.SystemTimeSource{}))
+
+	for i := 0; i < 2; i++ {
+		resp, err := server.GetLatestSignedLogRoot(context.Background(), &getLogRootRequest1)
+
+		if err != nil || resp.SignedLogRoot.TreeSize != signedRoot1.TreeSize {
+			t.Fatalf("Request %d got %v, %v, expected tree size %d", i, resp, err, signedRoot1.TreeSize)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/log"
+)
+
+// RootFreshnessMaintainer makes sure every active log has a root that's no older than the
+// sign interval, whether or not leaves are being sequenced. Each pass starts a goroutine for
+// every log that doesn't have one yet, which sleeps until the log's newest root is due to
+// expire and then signs a new one if sequencing hasn't already stored a newer root. Goroutines
+// of logs that are no longer active are stopped. It's run by a LogOperationManager, whose
+// sleep between runs sets how often new logs are picked up.
+type RootFreshnessMaintainer struct {
+	sequencers *SequencerManager
+	// mu guards logs
+	mu sync.Mutex
+	// logs has a channel for each log with a goroutine, closed to stop it
+	logs map[int64]chan struct{}
+	// running counts the goroutines that haven't exited
+	running sync.WaitGroup
+}
+
+// NewRootFreshnessMaintainer creates a RootFreshnessMaintainer that signs roots with the
+// sequencers, and their settings, that sm runs.
+func NewRootFreshnessMaintainer(sm *SequencerManager) *RootFreshnessMaintainer {
+	return &RootFreshnessMaintainer{sequencers: sm, logs: make(map[int64]chan struct{})}
+}
+
+func (m *RootFreshnessMaintainer) Name() string {
+	return "RootFreshness"
+}
+
+func (m *RootFreshnessMaintainer) ExecutePass(logIDs []trillian.LogID, context LogOperationManagerContext) bool {
+	select {
+	case <-context.done:
+		return true
+	default:
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	active := make(map[int64]bool)
+
+	for _, logID := range logIDs {
+		active[logID.TreeID] = true
+
+		if _, ok := m.logs[logID.TreeID]; ok {
+			continue
+		}
+
+		stop := make(chan struct{})
+		m.logs[logID.TreeID] = stop
+		m.running.Add(1)
+		go m.keepRootFresh(logID.TreeID, stop, context)
+	}
+
+	for treeID, stop := range m.logs {
+		if !active[treeID] {
+			close(stop)
+			delete(m.logs, treeID)
+		}
+	}
+
+	return false
+}
+
+// keepRootFresh signs a new root for a log whenever its newest root expires, until stop or
+// the context's done channel is closed. Failures are retried after the context's sleep
+// between runs.
+func (m *RootFreshnessMaintainer) keepRootFresh(logID int64, stop <-chan struct{}, context LogOperationManagerContext) {
+	defer m.running.Done()
+
+	for {
+		wait, err := m.sequencers.signRootIfExpired(logID, context)
+
+		if err == log.ErrSequencingPaused {
+			// Check again soon so the root is kept fresh as soon as it's resumed
+			wait = context.sleepBetweenRuns
+		} else if err != nil {
+			glog.Warningf("Failed to keep the root of log %d fresh: %v", logID, err)
+			wait = context.sleepBetweenRuns
+		}
+
+		select {
+		case <-context.done:
+			return
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Wait blocks until the goroutines for all the logs have exited, after the done channel of
+// the manager running the maintainer has been closed. A root that's being signed is committed
+// or rolled back first.
+func (m *RootFreshnessMaintainer) Wait() {
+	m.running.Wait()
+}
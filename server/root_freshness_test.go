@@ -0,0 +1,112 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/storage"
+)
+
+// Tests that a new root is signed when the latest one has expired even though there's no work
+// to sequence. The failure cases of SignRootIfOlderThan() are tested in the sequencer tests.
+func TestRootFreshnessSignsExpiredRoot(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockTx := storage.NewMockLogTX(mockCtrl)
+	mockKeyManager := crypto.NewMockKeyManager(mockCtrl)
+	hasher := trillian.NewSHA256()
+
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().LeafHashStrategy().Return(trillian.LeafHashStrategy_RFC6962_LEAF_HASH)
+	mockStorage.EXPECT().TreeDepth().Return(storage.DefaultLogTreeDepth)
+	mockStorage.EXPECT().Begin().AnyTimes().Return(mockTx, nil)
+	mockTx.EXPECT().WriteRevision().AnyTimes().Return(testRoot0.TreeRevision + 1)
+	mockTx.EXPECT().Commit().AnyTimes().Return(nil)
+	mockTx.EXPECT().LatestSignedLogRoot().AnyTimes().Return(testRoot0, nil)
+	mockTx.EXPECT().StoreCompactTree(storage.CompactTreeProto{}).Return(nil)
+	mockTx.EXPECT().StoreSignedLogRoot(updatedRootSignOnly).Return(nil)
+
+	mockSigner := crypto.NewMockSigner(mockCtrl)
+	mockSigner.EXPECT().Sign(gomock.Any(), []byte{0xeb, 0x7d, 0xa1, 0x4f, 0x1e, 0x60, 0x91, 0x24, 0xa, 0xf7, 0x1c, 0xcd, 0xdb, 0xd4, 0xca, 0x38, 0x4b, 0x12, 0xe4, 0xa3, 0xcf, 0x80, 0x5, 0x55, 0x17, 0x71, 0x35, 0xaf, 0x80, 0x11, 0xa, 0x87}, hasher).Return([]byte("signed"), nil)
+	mockKeyManager.EXPECT().Signer().Return(mockSigner, nil)
+
+	events := NewTreeEvents(fakeTimeSource)
+	sub := events.subscribe(logID1.TreeID)
+	sm := NewSequencerManager(mockKeyManager)
+	sm.SetTreeEvents(events)
+	m := NewRootFreshnessMaintainer(sm)
+
+	tc := createTestContext(mockStorageProviderForSequencer(mockStorage))
+	// Lower the expiry so testRoot0 has expired, the next check is then far enough away that
+	// it won't happen during the test
+	tc.signInterval = time.Hour
+
+	if quit := m.ExecutePass([]trillian.LogID{logID1}, tc); quit {
+		t.Fatal("ExecutePass() quit before done was closed")
+	}
+
+	var event *trillian.TreeEvent
+
+	select {
+	case event = <-sub.events:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the root to be published")
+	}
+
+	if got, want := *event.SignedLogRoot, updatedRootSignOnly; !reflect.DeepEqual(got, want) {
+		t.Errorf("Got root %v, expected %v", got, want)
+	}
+
+	close(tc.done)
+	m.Wait()
+
+	if quit := m.ExecutePass([]trillian.LogID{logID1}, tc); !quit {
+		t.Error("ExecutePass() didn't quit after done was closed")
+	}
+}
+
+func TestRootFreshnessWaitsForExpiry(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// The root is a minute old so nothing must be signed
+	freshRoot := testRoot0
+	freshRoot.TimestampNanos = fakeTime.Add(-time.Minute).UnixNano()
+
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockTx := storage.NewMockLogTX(mockCtrl)
+
+	mockStorage.EXPECT().HashAlgorithm().AnyTimes().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().LeafHashStrategy().AnyTimes().Return(trillian.LeafHashStrategy_RFC6962_LEAF_HASH)
+	mockStorage.EXPECT().TreeDepth().AnyTimes().Return(storage.DefaultLogTreeDepth)
+	mockStorage.EXPECT().Begin().AnyTimes().Return(mockTx, nil)
+	mockTx.EXPECT().Commit().AnyTimes().Return(nil)
+	mockTx.EXPECT().LatestSignedLogRoot().AnyTimes().Return(freshRoot, nil)
+
+	sm := NewSequencerManager(crypto.NewMockKeyManager(mockCtrl))
+	tc := createTestContext(mockStorageProviderForSequencer(mockStorage))
+	tc.signInterval = time.Hour
+
+	expiresIn, err := sm.signRootIfExpired(logID1.TreeID, tc)
+
+	if err != nil {
+		t.Fatalf("signRootIfExpired()=%v", err)
+	}
+
+	if got, want := expiresIn, time.Hour-time.Minute; got != want {
+		t.Errorf("Got root expiring in %v, expected %v", got, want)
+	}
+
+	m := NewRootFreshnessMaintainer(sm)
+	m.ExecutePass([]trillian.LogID{logID1}, tc)
+
+	// The log is no longer active so its goroutine must exit without done being closed
+	m.ExecutePass([]trillian.LogID{}, tc)
+	m.Wait()
+}
@@ -1,41 +1,112 @@
 package server
 
 import (
+	"fmt"
+	"sync"
+	"time"
+
 	"github.com/golang/glog"
 	"github.com/google/trillian"
+	"github.com/google/trillian/audit"
 	"github.com/google/trillian/crypto"
 	"github.com/google/trillian/log"
 	"github.com/google/trillian/merkle"
 	"github.com/google/trillian/util"
-	"time"
+	"golang.org/x/net/context"
 )
 
 type SequencerManager struct {
-	keyManager crypto.KeyManager
-}
-
-func isRootTooOld(ts util.TimeSource, maxAge time.Duration) log.CurrentRootExpiredFunc {
-	return func(root trillian.SignedLogRoot) bool {
-		rootTime := time.Unix(0, root.TimestampNanos)
-		rootAge := ts.Now().Sub(rootTime)
+	keyManager       crypto.KeyManager
+	rootMetadata     log.RootMetadataFunc
+	signEveryNLeaves int64
+	auditJournal     *audit.Journal
+	verifyRoots      bool
+	nodeFlushSize    int
+	batchMemBudget   int64
+	// treeEvents is optional, if set new roots and sequencing errors are published to it
+	treeEvents *TreeEvents
+	// features is optional, if set it decides which of LogServerFeatures are used
+	features *util.Features
+	// timings is optional, if set the phases of every batch are timed into it
+	timings *log.SequencerTimings
 
-		return rootAge > maxAge
-	}
+	// mu guards the fields below it
+	mu sync.Mutex
+	// logLocks holds a lock for each log that's held while a batch is sequenced or a root is
+	// signed, so that a flush, the operation loop and RootFreshnessMaintainer don't work on a
+	// log at the same time. Different logs don't wait for each other.
+	logLocks map[int64]*sync.Mutex
 }
 
 func NewSequencerManager(km crypto.KeyManager) *SequencerManager {
 	return &SequencerManager{keyManager: km}
 }
 
-func (s SequencerManager) Name() string {
+// SetRootMetadata installs a hook that supplies metadata for the roots created by the
+// sequencers that this manager runs. See log.Sequencer.SetRootMetadata.
+func (s *SequencerManager) SetRootMetadata(f log.RootMetadataFunc) {
+	s.rootMetadata = f
+}
+
+// SetSignEveryNLeaves makes the sequencers that this manager runs store a root at every
+// tree size that's a multiple of n. See log.Sequencer.SetSignEveryNLeaves.
+func (s *SequencerManager) SetSignEveryNLeaves(n int64) {
+	s.signEveryNLeaves = n
+}
+
+// SetAuditJournal makes the sequencers that this manager runs record every root they store in
+// j. Passing nil disables this.
+func (s *SequencerManager) SetAuditJournal(j *audit.Journal) {
+	s.auditJournal = j
+}
+
+// SetVerifyRoots makes the sequencers that this manager runs refuse to build on a stored root
+// that has a bad signature or doesn't match the tree. See log.Sequencer.SetVerifyRoots.
+func (s *SequencerManager) SetVerifyRoots(verify bool) {
+	s.verifyRoots = verify
+}
+
+// SetNodeFlushSize makes the sequencers that this manager runs write updated nodes whenever n
+// are pending. See log.Sequencer.SetNodeFlushSize.
+func (s *SequencerManager) SetNodeFlushSize(n int) {
+	s.nodeFlushSize = n
+}
+
+// SetBatchMemoryBudget makes the sequencers that this manager runs sequence batches in chunks
+// that fit in roughly this many bytes. See log.Sequencer.SetBatchMemoryBudget.
+func (s *SequencerManager) SetBatchMemoryBudget(bytes int64) {
+	s.batchMemBudget = bytes
+}
+
+// SetTreeEvents makes the sequencers that this manager runs publish every root they commit
+// to e, along with any errors sequencing a log. Passing nil disables this.
+func (s *SequencerManager) SetTreeEvents(e *TreeEvents) {
+	s.treeEvents = e
+}
+
+// SetFeatures makes the sequencers that this manager runs check features before using
+// FeatureChunkedBatches for a log. If it's not called, or nil is passed, batches are chunked
+// whenever a memory budget is set.
+func (s *SequencerManager) SetFeatures(features *util.Features) {
+	s.features = features
+}
+
+// SetTimings makes the sequencers that this manager runs record how long each phase of their
+// batches takes in t. Passing nil disables this. See log.Sequencer.SetTimings.
+func (s *SequencerManager) SetTimings(t *log.SequencerTimings) {
+	s.timings = t
+}
+
+func (s *SequencerManager) Name() string {
 	return "Sequencer"
 }
 
-func (s SequencerManager) ExecutePass(logIDs []trillian.LogID, context LogOperationManagerContext) bool {
+func (s *SequencerManager) ExecutePass(logIDs []trillian.LogID, context LogOperationManagerContext) bool {
 	// TODO(Martin2112): Demote logging to verbose level
 	glog.Infof("Beginning sequencing run for %d active log(s)", len(logIDs))
 
 	successCount := 0
+	pausedCount := 0
 	leavesAdded := 0
 
 	for _, logID := range logIDs {
@@ -46,22 +117,14 @@ func (s SequencerManager) ExecutePass(logIDs []trillian.LogID, context LogOperat
 		default:
 		}
 
-		// TODO(Martin2112): Probably want to make the sequencer objects longer lived to
-		// avoid the cost of initializing their state each time but this works for now
-		storage, err := context.storageProvider(logID.TreeID)
+		leaves, err := s.sequenceLog(context.operationCtx(), logID.TreeID, context, false)
 
-		// TODO(Martin2112): Honour the sequencing enabled in log parameters, needs an API change
-		// so deferring it
-		if err != nil {
-			glog.Warningf("Storage provider failed for id: %v because: %v", logID, err)
+		if err == log.ErrSequencingPaused {
+			glog.V(1).Infof("Not sequencing %v, sequencing is paused", logID)
+			pausedCount++
 			continue
 		}
 
-		// TODO(Martin2112): Allow for different tree hashers to be used by different logs
-		sequencer := log.NewSequencer(merkle.NewRFC6962TreeHasher(trillian.NewSHA256()), context.timeSource, storage, s.keyManager)
-
-		leaves, err := sequencer.SequenceBatch(context.batchSize, isRootTooOld(context.timeSource, context.signInterval))
-
 		if err != nil {
 			glog.Warningf("Error trying to sequence batch for: %v: %v", logID, err)
 			continue
@@ -71,7 +134,167 @@ func (s SequencerManager) ExecutePass(logIDs []trillian.LogID, context LogOperat
 		leavesAdded += leaves
 	}
 
-	glog.Infof("Sequencing run completed %d succeeded %d failed %d leaves integrated", successCount, len(logIDs)-successCount, leavesAdded)
+	glog.Infof("Sequencing run completed %d succeeded %d failed %d paused %d leaves integrated", successCount, len(logIDs)-successCount-pausedCount, pausedCount, leavesAdded)
 
 	return false
 }
+
+// FlushLog sequences a batch for a log straight away rather than waiting for the next pass.
+// If forceNewRoot is set a new root is signed even if there are no leaves to integrate.
+// Returns the number of leaves integrated. The flush is rolled back if either ctx or the
+// manager's context is cancelled.
+func (s *SequencerManager) FlushLog(ctx context.Context, logID int64, forceNewRoot bool, context LogOperationManagerContext) (int, error) {
+	ctx, cancel := mergeCancellation(ctx, context.operationCtx())
+	defer cancel()
+
+	return s.sequenceLog(ctx, logID, context, forceNewRoot)
+}
+
+// mergeCancellation returns a context derived from ctx that is also cancelled when other is.
+// The returned function must be called to release its resources.
+func mergeCancellation(ctx, other context.Context) (context.Context, context.CancelFunc) {
+	merged, cancel := context.WithCancel(ctx)
+
+	go func() {
+		select {
+		case <-other.Done():
+			cancel()
+		case <-merged.Done():
+		}
+	}()
+
+	return merged, cancel
+}
+
+// sequenceLog sequences one batch of leaves for a log, signing a new root if there are no
+// leaves and forceNewRoot is set. Failures are published as tree events. If sequencing of the
+// log is paused log.ErrSequencingPaused is returned, which isn't a failure.
+func (s *SequencerManager) sequenceLog(ctx context.Context, logID int64, context LogOperationManagerContext, forceNewRoot bool) (int, error) {
+	leaves, err := s.sequenceLogBatch(ctx, logID, context, forceNewRoot)
+
+	if err != nil && err != log.ErrSequencingPaused && s.treeEvents != nil {
+		publishSequencingError(s.treeEvents, logID, err)
+	}
+
+	return leaves, err
+}
+
+func (s *SequencerManager) sequenceLogBatch(ctx context.Context, logID int64, context LogOperationManagerContext, forceNewRoot bool) (int, error) {
+	sequencer, err := s.newSequencer(logID, context)
+
+	if err != nil {
+		return 0, err
+	}
+
+	lock := s.logLock(logID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	leaves, err := sequencer.SequenceBatch(ctx, context.batchSize)
+
+	if err == nil && leaves == 0 && forceNewRoot {
+		err = sequencer.SignRoot(ctx)
+	}
+
+	return leaves, err
+}
+
+// signRootIfExpired signs a new root for a log if its latest root is older than the context's
+// sign interval. It returns how long it will be before the latest root expires. Failures are
+// published as tree events. Roots aren't signed while sequencing of the log is paused.
+func (s *SequencerManager) signRootIfExpired(logID int64, context LogOperationManagerContext) (time.Duration, error) {
+	rootTime, err := s.signRootIfOlderThan(logID, context)
+
+	if err != nil {
+		if err != log.ErrSequencingPaused && s.treeEvents != nil {
+			publishSequencingError(s.treeEvents, logID, err)
+		}
+
+		return 0, err
+	}
+
+	expiresIn := context.signInterval - context.timeSource.Now().Sub(rootTime)
+
+	// A root from the future, e.g. after the clock was stepped back, is still checked again
+	// within the interval
+	if expiresIn > context.signInterval {
+		expiresIn = context.signInterval
+	}
+
+	return expiresIn, nil
+}
+
+func (s *SequencerManager) signRootIfOlderThan(logID int64, context LogOperationManagerContext) (time.Time, error) {
+	sequencer, err := s.newSequencer(logID, context)
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// The root is read and signed while holding the lock so that sequencing can't store a
+	// root in between
+	lock := s.logLock(logID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	return sequencer.SignRootIfOlderThan(context.operationCtx(), context.signInterval)
+}
+
+// logLock returns the lock that's held while a log is sequenced or has its root signed,
+// creating it if this is the first time the log has been seen.
+func (s *SequencerManager) logLock(logID int64) *sync.Mutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.logLocks == nil {
+		s.logLocks = make(map[int64]*sync.Mutex)
+	}
+
+	lock, ok := s.logLocks[logID]
+
+	if !ok {
+		lock = new(sync.Mutex)
+		s.logLocks[logID] = lock
+	}
+
+	return lock
+}
+
+// newSequencer creates a sequencer for a log configured with the manager's settings.
+func (s *SequencerManager) newSequencer(logID int64, context LogOperationManagerContext) (*log.Sequencer, error) {
+	// TODO(Martin2112): Probably want to make the sequencer objects longer lived to
+	// avoid the cost of initializing their state each time but this works for now
+	storage, err := context.storageProvider(logID)
+
+	if err != nil {
+		return nil, fmt.Errorf("storage provider failed: %v", err)
+	}
+
+	treeHasher, err := merkle.NewTreeHasherForAlgorithm(storage.HashAlgorithm(), storage.LeafHashStrategy())
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tree hasher: %v", err)
+	}
+
+	sequencer := log.NewSequencer(treeHasher, context.timeSource, storage, s.keyManager)
+	sequencer.SetTreeDepth(storage.TreeDepth())
+	sequencer.SetRootMetadata(s.rootMetadata)
+	sequencer.SetSignEveryNLeaves(s.signEveryNLeaves)
+	sequencer.SetVerifyRoots(s.verifyRoots)
+	sequencer.SetNodeFlushSize(s.nodeFlushSize)
+	sequencer.SetTimings(s.timings, logID)
+
+	if s.features == nil || s.features.Enabled(FeatureChunkedBatches, logID) {
+		sequencer.SetBatchMemoryBudget(s.batchMemBudget)
+	}
+
+	if s.auditJournal != nil {
+		sequencer.SetRootAudit(auditRoots(s.auditJournal, logID))
+	}
+
+	if s.treeEvents != nil {
+		sequencer.SetRootStored(publishRoots(s.treeEvents, logID))
+	}
+
+	return sequencer, nil
+}
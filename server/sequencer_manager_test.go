@@ -1,7 +1,10 @@
 package server
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -10,6 +13,7 @@ import (
 	"github.com/google/trillian/crypto"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
 )
 
 // Arbitrary time for use in tests
@@ -20,6 +24,7 @@ var fakeTimeSource = util.FakeTimeSource{fakeTime}
 var logID1 = trillian.LogID{TreeID: 1, LogID: []byte("testroot")}
 var testLeaf0Hash = trillian.Hash{0, 1, 2, 3, 4, 5}
 var testLeaf0 = trillian.LogLeaf{Leaf: trillian.Leaf{LeafHash: testLeaf0Hash, LeafValue: nil, ExtraData: nil}, SequenceNumber: 0}
+var testLeaf0Updated = trillian.LogLeaf{Leaf: trillian.Leaf{LeafHash: testLeaf0Hash, LeafValue: nil, ExtraData: nil}, SequenceNumber: 0, IntegrateTimestampNanos: fakeTime.UnixNano()}
 var testRoot0 = trillian.SignedLogRoot{TreeSize: 0, TreeRevision: 0, LogId: logID1.LogID, RootHash: []byte{}}
 var updatedNodes0 = []storage.Node{{NodeID: storage.NodeID{Path: []uint8{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, PrefixLenBits: 64, PathLenBits: 64}, Hash: trillian.Hash{0x0, 0x1, 0x2, 0x3, 0x4, 0x5}, NodeRevision: 1}}
 var updatedRoot = trillian.SignedLogRoot{LogId: logID1.LogID, TimestampNanos: fakeTime.UnixNano(), RootHash: []uint8{0x0, 0x1, 0x2, 0x3, 0x4, 0x5}, TreeSize: 1, Signature: &trillian.DigitallySigned{Signature: []byte("signed")}, TreeRevision: 1}
@@ -49,6 +54,9 @@ func TestSequencerManagerSingleLogNoLeaves(t *testing.T) {
 	mockTx := storage.NewMockLogTX(mockCtrl)
 	logID := trillian.LogID{TreeID: 1, LogID: []byte("Test")}
 
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().LeafHashStrategy().Return(trillian.LeafHashStrategy_RFC6962_LEAF_HASH)
+	mockStorage.EXPECT().TreeDepth().Return(storage.DefaultLogTreeDepth)
 	mockStorage.EXPECT().Begin().Return(mockTx, nil)
 	mockTx.EXPECT().Commit().Return(nil)
 	mockTx.EXPECT().WriteRevision().AnyTimes().Return(writeRev)
@@ -61,6 +69,31 @@ func TestSequencerManagerSingleLogNoLeaves(t *testing.T) {
 	sm.ExecutePass([]trillian.LogID{logID}, createTestContext(mockStorageProviderForSequencer(mockStorage)))
 }
 
+func TestSequencerManagerSignEveryNLeaves(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockTx := storage.NewMockLogTX(mockCtrl)
+	logID := trillian.LogID{TreeID: 1, LogID: []byte("Test")}
+
+	// The batch must stop at the first tree size that needs a root
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().LeafHashStrategy().Return(trillian.LeafHashStrategy_RFC6962_LEAF_HASH)
+	mockStorage.EXPECT().TreeDepth().Return(storage.DefaultLogTreeDepth)
+	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().WriteRevision().AnyTimes().Return(writeRev)
+	mockTx.EXPECT().LatestSignedLogRoot().Return(testRoot0, nil)
+	mockTx.EXPECT().DequeueLeaves(10).Return([]trillian.LogLeaf{}, nil)
+	mockKeyManager := crypto.NewMockKeyManager(mockCtrl)
+
+	sm := NewSequencerManager(mockKeyManager)
+	sm.SetSignEveryNLeaves(10)
+
+	sm.ExecutePass([]trillian.LogID{logID}, createTestContext(mockStorageProviderForSequencer(mockStorage)))
+}
+
 func TestSequencerManagerSingleLogOneLeaf(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
@@ -78,9 +111,13 @@ func TestSequencerManagerSingleLogOneLeaf(t *testing.T) {
 	mockTx.EXPECT().WriteRevision().AnyTimes().Return(testRoot0.TreeRevision + 1)
 	mockTx.EXPECT().DequeueLeaves(50).Return([]trillian.LogLeaf{testLeaf0}, nil)
 	mockTx.EXPECT().LatestSignedLogRoot().Return(testRoot0, nil)
-	mockTx.EXPECT().UpdateSequencedLeaves([]trillian.LogLeaf{testLeaf0}).Return(nil)
+	mockTx.EXPECT().UpdateSequencedLeaves([]trillian.LogLeaf{testLeaf0Updated}).Return(nil)
 	mockTx.EXPECT().SetMerkleNodes(updatedNodes0).Return(nil)
+	mockTx.EXPECT().StoreCompactTree(storage.CompactTreeProto{TreeSize: 1, Nodes: [][]byte{testLeaf0.LeafHash}}).Return(nil)
 	mockTx.EXPECT().StoreSignedLogRoot(updatedRoot).Return(nil)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().LeafHashStrategy().Return(trillian.LeafHashStrategy_RFC6962_LEAF_HASH)
+	mockStorage.EXPECT().TreeDepth().Return(storage.DefaultLogTreeDepth)
 	mockStorage.EXPECT().Begin().Return(mockTx, nil)
 
 	mockSigner := crypto.NewMockSigner(mockCtrl)
@@ -92,36 +129,134 @@ func TestSequencerManagerSingleLogOneLeaf(t *testing.T) {
 	sm.ExecutePass([]trillian.LogID{logID}, createTestContext(mockStorageProviderForSequencer(mockStorage)))
 }
 
-// Tests that a new root is signed if it's due even when there is no work to sequence.
-// The various failure cases of SignRoot() are tested in the sequencer tests. This is
-// an interaction test.
-func TestSignsIfNoWorkAndRootExpired(t *testing.T) {
+func TestSequencerManagerSkipsLogWithBadLeafHashStrategy(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
 
 	mockStorage := storage.NewMockLogStorage(mockCtrl)
-	mockTx := storage.NewMockLogTX(mockCtrl)
 	mockKeyManager := crypto.NewMockKeyManager(mockCtrl)
 	logID := trillian.LogID{TreeID: 1, LogID: []byte("Test")}
+
+	// No transaction should be started for the log
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().LeafHashStrategy().Return(trillian.LeafHashStrategy(99))
+
+	sm := NewSequencerManager(mockKeyManager)
+
+	sm.ExecutePass([]trillian.LogID{logID}, createTestContext(mockStorageProviderForSequencer(mockStorage)))
+}
+
+func TestFlushLogForcesNewRoot(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockTx := storage.NewMockLogTX(mockCtrl)
+	mockKeyManager := crypto.NewMockKeyManager(mockCtrl)
 	hasher := trillian.NewSHA256()
 
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().LeafHashStrategy().Return(trillian.LeafHashStrategy_RFC6962_LEAF_HASH)
+	mockStorage.EXPECT().TreeDepth().Return(storage.DefaultLogTreeDepth)
 	mockStorage.EXPECT().Begin().AnyTimes().Return(mockTx, nil)
-	mockTx.EXPECT().WriteRevision().AnyTimes().Return(writeRev)
+	mockTx.EXPECT().WriteRevision().AnyTimes().Return(testRoot0.TreeRevision + 1)
+	mockTx.EXPECT().Commit().AnyTimes().Return(nil)
+	mockTx.EXPECT().LatestSignedLogRoot().AnyTimes().Return(testRoot0, nil)
+	mockTx.EXPECT().DequeueLeaves(50).Return([]trillian.LogLeaf{}, nil)
+	mockTx.EXPECT().StoreCompactTree(storage.CompactTreeProto{}).Return(nil)
+	mockTx.EXPECT().StoreSignedLogRoot(updatedRootSignOnly).Return(nil)
+
+	mockSigner := crypto.NewMockSigner(mockCtrl)
+	mockSigner.EXPECT().Sign(gomock.Any(), []byte{0xeb, 0x7d, 0xa1, 0x4f, 0x1e, 0x60, 0x91, 0x24, 0xa, 0xf7, 0x1c, 0xcd, 0xdb, 0xd4, 0xca, 0x38, 0x4b, 0x12, 0xe4, 0xa3, 0xcf, 0x80, 0x5, 0x55, 0x17, 0x71, 0x35, 0xaf, 0x80, 0x11, 0xa, 0x87}, hasher).Return([]byte("signed"), nil)
+	mockKeyManager.EXPECT().Signer().Return(mockSigner, nil)
+
+	sm := NewSequencerManager(mockKeyManager)
+
+	// The root hasn't expired under the default sign interval so it's only signed because
+	// it was forced
+	leaves, err := sm.FlushLog(context.Background(), logID1.TreeID, true, createTestContext(mockStorageProviderForSequencer(mockStorage)))
+
+	if err != nil {
+		t.Fatalf("Failed to flush log: %v", err)
+	}
+
+	if got, want := leaves, 0; got != want {
+		t.Errorf("Got %d leaves integrated, expected %d", got, want)
+	}
+}
+
+func TestFlushLogPublishesTreeEvents(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	mockTx := storage.NewMockLogTX(mockCtrl)
+	mockKeyManager := crypto.NewMockKeyManager(mockCtrl)
+	hasher := trillian.NewSHA256()
+
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().LeafHashStrategy().Return(trillian.LeafHashStrategy_RFC6962_LEAF_HASH)
+	mockStorage.EXPECT().TreeDepth().Return(storage.DefaultLogTreeDepth)
+	mockStorage.EXPECT().Begin().AnyTimes().Return(mockTx, nil)
+	mockTx.EXPECT().WriteRevision().AnyTimes().Return(testRoot0.TreeRevision + 1)
 	mockTx.EXPECT().Commit().AnyTimes().Return(nil)
 	mockTx.EXPECT().LatestSignedLogRoot().AnyTimes().Return(testRoot0, nil)
 	mockTx.EXPECT().DequeueLeaves(50).Return([]trillian.LogLeaf{}, nil)
-	mockTx.EXPECT().StoreSignedLogRoot(updatedRootSignOnly).AnyTimes().Return(nil)
+	mockTx.EXPECT().StoreCompactTree(storage.CompactTreeProto{}).Return(nil)
+	mockTx.EXPECT().StoreSignedLogRoot(updatedRootSignOnly).Return(nil)
 
 	mockSigner := crypto.NewMockSigner(mockCtrl)
 	mockSigner.EXPECT().Sign(gomock.Any(), []byte{0xeb, 0x7d, 0xa1, 0x4f, 0x1e, 0x60, 0x91, 0x24, 0xa, 0xf7, 0x1c, 0xcd, 0xdb, 0xd4, 0xca, 0x38, 0x4b, 0x12, 0xe4, 0xa3, 0xcf, 0x80, 0x5, 0x55, 0x17, 0x71, 0x35, 0xaf, 0x80, 0x11, 0xa, 0x87}, hasher).Return([]byte("signed"), nil)
 	mockKeyManager.EXPECT().Signer().Return(mockSigner, nil)
 
+	events := NewTreeEvents(fakeTimeSource)
+	sub := events.subscribe(logID1.TreeID)
 	sm := NewSequencerManager(mockKeyManager)
+	sm.SetTreeEvents(events)
 
-	tc := createTestContext(mockStorageProviderForSequencer(mockStorage))
-	// Lower the expiry so we can trigger a signing for a root older than 5 seconds
-	tc.signInterval = time.Second * 5
-	sm.ExecutePass([]trillian.LogID{logID}, tc)
+	if _, err := sm.FlushLog(context.Background(), logID1.TreeID, true, createTestContext(mockStorageProviderForSequencer(mockStorage))); err != nil {
+		t.Fatalf("Failed to flush log: %v", err)
+	}
+
+	// A failed flush is published as well
+	if _, err := sm.FlushLog(context.Background(), logID1.TreeID, true, createTestContext(func(int64) (storage.LogStorage, error) {
+		return nil, errors.New("no storage")
+	})); err == nil {
+		t.Fatal("Expected flushing a log without storage to fail")
+	}
+
+	event := <-sub.events
+
+	if got, want := event.EventType, trillian.TreeEventType_NEW_SIGNED_ROOT; got != want {
+		t.Errorf("Got event type %v, expected %v", got, want)
+	}
+
+	if got, want := *event.SignedLogRoot, updatedRootSignOnly; !reflect.DeepEqual(got, want) {
+		t.Errorf("Got root %v, expected %v", got, want)
+	}
+
+	event = <-sub.events
+
+	if got, want := event.EventType, trillian.TreeEventType_SEQUENCING_ERROR; got != want {
+		t.Errorf("Got event type %v, expected %v", got, want)
+	}
+
+	if !strings.Contains(event.Error, "no storage") {
+		t.Errorf("Got error %q, expected it to contain the storage error", event.Error)
+	}
+}
+
+func TestFlushLogBadLogID(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// Nothing should be called on storage for an unknown log
+	mockStorage := storage.NewMockLogStorage(mockCtrl)
+	sm := NewSequencerManager(crypto.NewMockKeyManager(mockCtrl))
+
+	if _, err := sm.FlushLog(context.Background(), 2, true, createTestContext(mockStorageProviderForSequencer(mockStorage))); err == nil {
+		t.Fatal("Expected flushing an unknown log to fail")
+	}
 }
 
 func mockStorageProviderForSequencer(mockStorage storage.LogStorage) LogStorageProviderFunc {
@@ -140,3 +275,29 @@ func createTestContext(sp LogStorageProviderFunc) LogOperationManagerContext {
 	// Set sign interval to 100 years so it won't trigger a root expiry signing unless overridden
 	return LogOperationManagerContext{done: done, storageProvider: sp, batchSize: 50, sleepBetweenRuns: time.Second, oneShot: true, timeSource: fakeTimeSource, signInterval: time.Hour * 24 * 365 * 100}
 }
+
+func TestLogLocksArePerLog(t *testing.T) {
+	sm := NewSequencerManager(nil)
+
+	if sm.logLock(1) != sm.logLock(1) {
+		t.Fatal("logLock() returned different locks for the same log")
+	}
+
+	// Holding one log's lock mustn't stop another log from being sequenced
+	sm.logLock(1).Lock()
+	defer sm.logLock(1).Unlock()
+
+	locked := make(chan struct{})
+
+	go func() {
+		sm.logLock(2).Lock()
+		sm.logLock(2).Unlock()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a different log's lock")
+	}
+}
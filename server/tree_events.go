@@ -0,0 +1,126 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/util"
+)
+
+// treeEventBufferSize is how many events can be waiting to be sent to a subscriber. A
+// subscriber that falls further behind than this is disconnected.
+const treeEventBufferSize = 64
+
+// treeEventSubscriber receives the events of one log, or of every log if logID is zero
+type treeEventSubscriber struct {
+	logID  int64
+	events chan *trillian.TreeEvent
+}
+
+// TreeEvents passes the events of logs, e.g. new roots, to the clients that have subscribed to
+// them. Events are not stored or retried, a subscriber only sees those published while it's
+// subscribed. A subscriber that doesn't keep up is dropped rather than slowing down the
+// publisher, which will usually be the sequencer. It is safe for concurrent use.
+type TreeEvents struct {
+	timeSource util.TimeSource
+
+	// mu guards the fields below it
+	mu          sync.Mutex
+	subscribers map[*treeEventSubscriber]bool
+	closed      bool
+}
+
+// NewTreeEvents creates a TreeEvents with no subscribers. Events are timestamped using
+// timeSource.
+func NewTreeEvents(timeSource util.TimeSource) *TreeEvents {
+	return &TreeEvents{timeSource: timeSource, subscribers: make(map[*treeEventSubscriber]bool)}
+}
+
+// subscribe returns a subscriber for the events of a log, or every log if logID is zero. Its
+// channel is closed if it's unsubscribed, falls behind or the TreeEvents is closed.
+func (e *TreeEvents) subscribe(logID int64) *treeEventSubscriber {
+	sub := &treeEventSubscriber{logID: logID, events: make(chan *trillian.TreeEvent, treeEventBufferSize)}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		close(sub.events)
+	} else {
+		e.subscribers[sub] = true
+	}
+
+	return sub
+}
+
+// unsubscribe stops events being sent to sub. It's safe to call more than once.
+func (e *TreeEvents) unsubscribe(sub *treeEventSubscriber) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.subscribers[sub] {
+		delete(e.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// Publish sends an event to the subscribers of its log. The event's timestamp is set if it
+// doesn't have one. Publish never blocks.
+func (e *TreeEvents) Publish(event *trillian.TreeEvent) {
+	if event.TimestampNanos == 0 {
+		event.TimestampNanos = e.timeSource.Now().UnixNano()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for sub := range e.subscribers {
+		if sub.logID != 0 && sub.logID != event.LogId {
+			continue
+		}
+
+		select {
+		case sub.events <- event:
+		default:
+			glog.Warningf("Dropping tree event subscriber for log %d that has fallen behind", sub.logID)
+			delete(e.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// isClosed returns true if Close has been called.
+func (e *TreeEvents) isClosed() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.closed
+}
+
+// Close disconnects all the subscribers and refuses new ones, e.g. so that the RPC server can
+// stop without waiting for streams that would otherwise never end.
+func (e *TreeEvents) Close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for sub := range e.subscribers {
+		delete(e.subscribers, sub)
+		close(sub.events)
+	}
+
+	e.closed = true
+}
+
+// publishRoots returns a hook for the sequencer that publishes the roots it commits for a log.
+func publishRoots(e *TreeEvents, logID int64) log.RootStoredFunc {
+	return func(root trillian.SignedLogRoot) {
+		e.Publish(&trillian.TreeEvent{EventType: trillian.TreeEventType_NEW_SIGNED_ROOT, LogId: logID, SignedLogRoot: &root})
+	}
+}
+
+// publishSequencingError tells the subscribers of a log that it couldn't be sequenced.
+func publishSequencingError(e *TreeEvents, logID int64, err error) {
+	e.Publish(&trillian.TreeEvent{EventType: trillian.TreeEventType_SEQUENCING_ERROR, LogId: logID, Error: err.Error()})
+}
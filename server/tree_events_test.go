@@ -0,0 +1,81 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/google/trillian"
+)
+
+func TestTreeEventsPublishToSubscribers(t *testing.T) {
+	events := NewTreeEvents(fakeTimeSource)
+	log1 := events.subscribe(1)
+	log2 := events.subscribe(2)
+	all := events.subscribe(0)
+
+	events.Publish(&trillian.TreeEvent{EventType: trillian.TreeEventType_NEW_SIGNED_ROOT, LogId: 1})
+	events.Publish(&trillian.TreeEvent{EventType: trillian.TreeEventType_SEQUENCING_ERROR, LogId: 2, TimestampNanos: 5})
+
+	if got, want := len(log1.events), 1; got != want {
+		t.Fatalf("Log 1 subscriber got %d events, expected %d", got, want)
+	}
+
+	if event := <-log1.events; event.EventType != trillian.TreeEventType_NEW_SIGNED_ROOT || event.TimestampNanos != fakeTime.UnixNano() {
+		t.Errorf("Log 1 subscriber got %v, expected a new root timestamped now", event)
+	}
+
+	if event := <-log2.events; event.LogId != 2 || event.TimestampNanos != 5 {
+		t.Errorf("Log 2 subscriber got %v, expected the log 2 event with its timestamp kept", event)
+	}
+
+	if got, want := len(all.events), 2; got != want {
+		t.Errorf("Subscriber to all logs got %d events, expected %d", got, want)
+	}
+
+	events.unsubscribe(log1)
+	events.unsubscribe(log1)
+
+	if _, ok := <-log1.events; ok {
+		t.Error("Unsubscribed channel is still open")
+	}
+
+	// Publishing to a log with no subscribers is fine
+	events.Publish(&trillian.TreeEvent{LogId: 1})
+}
+
+func TestTreeEventsDropSlowSubscriber(t *testing.T) {
+	events := NewTreeEvents(fakeTimeSource)
+	slow := events.subscribe(1)
+
+	for i := 0; i <= treeEventBufferSize; i++ {
+		events.Publish(&trillian.TreeEvent{LogId: 1})
+	}
+
+	// The buffered events can still be read before the channel reports it's closed
+	received := 0
+	for range slow.events {
+		received++
+	}
+
+	if got, want := received, treeEventBufferSize; got != want {
+		t.Errorf("Slow subscriber got %d events, expected %d", got, want)
+	}
+
+	// Unsubscribing after being dropped mustn't close the channel again
+	events.unsubscribe(slow)
+}
+
+func TestTreeEventsClose(t *testing.T) {
+	events := NewTreeEvents(fakeTimeSource)
+	before := events.subscribe(0)
+	events.Close()
+	after := events.subscribe(0)
+
+	for _, sub := range []*treeEventSubscriber{before, after} {
+		if _, ok := <-sub.events; ok {
+			t.Error("Subscriber channel is open after Close()")
+		}
+	}
+
+	events.unsubscribe(after)
+	events.Publish(&trillian.TreeEvent{LogId: 1})
+}
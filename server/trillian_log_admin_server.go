@@ -0,0 +1,310 @@
+package server
+
+import (
+	"sort"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// These are the sizes of the storage columns for tree metadata.
+const (
+	maxTreeDisplayNameLength  = 255
+	maxTreeDescriptionLength  = 1024
+	maxTreeOwnerContactLength = 255
+)
+
+// LogFlushFunc runs a sequencing pass for a single log and returns the number of leaves
+// integrated, see LogOperationManager.FlushLog.
+type LogFlushFunc func(ctx context.Context, logID int64, forceNewRoot bool) (int, error)
+
+// TrillianLogAdminServer implements the RPCs that operators use to manage logs. It should not
+// be exposed to clients.
+type TrillianLogAdminServer struct {
+	storageProvider LogStorageProviderFunc
+	flush           LogFlushFunc
+	features        *util.Features
+}
+
+// NewTrillianLogAdminServer creates a new admin server. Logs are flushed by calling flush,
+// which will normally be the FlushLog method of the manager running the sequencer.
+func NewTrillianLogAdminServer(p LogStorageProviderFunc, flush LogFlushFunc) *TrillianLogAdminServer {
+	return &TrillianLogAdminServer{storageProvider: p, flush: flush}
+}
+
+// SetFeatures sets the feature flags that can be changed with SetFeature. If it's not called
+// the feature RPCs are not available.
+func (t *TrillianLogAdminServer) SetFeatures(features *util.Features) {
+	t.features = features
+}
+
+// FlushLog sequences a batch of leaves for a log without waiting for the sequencer to reach it
+// and returns the latest root afterwards. This is useful in tests and when something needs to
+// be published quickly.
+func (t *TrillianLogAdminServer) FlushLog(ctx context.Context, req *trillian.FlushLogRequest) (*trillian.FlushLogResponse, error) {
+	leaves, err := t.flush(ctx, req.LogId, req.ForceNewRoot)
+
+	if err == log.ErrSequencingPaused {
+		return nil, grpc.Errorf(codes.FailedPrecondition, "sequencing of log %d is paused", req.LogId)
+	}
+
+	if err != nil {
+		glog.Warningf("Failed to flush log %d: %v", req.LogId, err)
+		return nil, err
+	}
+
+	s, err := t.storageProvider(req.LogId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	signedRoot, err := tx.LatestSignedLogRoot()
+
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		glog.Warningf("Commit failed for FlushLog: %v", err)
+		return nil, err
+	}
+
+	return &trillian.FlushLogResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), LeavesIntegrated: int64(leaves), SignedLogRoot: &signedRoot}, nil
+}
+
+// SetFeature turns a feature on or off for a log, or for all logs, until the features file is
+// next changed or the server restarts.
+func (t *TrillianLogAdminServer) SetFeature(ctx context.Context, req *trillian.SetFeatureRequest) (*trillian.SetFeatureResponse, error) {
+	if t.features == nil {
+		return nil, grpc.Errorf(codes.Unimplemented, "feature flags are not enabled on this server")
+	}
+
+	var err error
+
+	if req.Reset_ {
+		err = t.features.Reset(req.Name, req.LogId)
+	} else {
+		err = t.features.Set(req.Name, req.LogId, req.Enabled)
+	}
+
+	if err != nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	glog.Infof("Feature %s set to %v for log %d (reset=%v)", req.Name, req.Enabled, req.LogId, req.Reset_)
+
+	for _, config := range t.features.Configs() {
+		if config.Name == req.Name {
+			return &trillian.SetFeatureResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Feature: featureToProto(config)}, nil
+		}
+	}
+
+	return nil, grpc.Errorf(codes.Internal, "feature %s missing after it was set", req.Name)
+}
+
+// ListFeatures returns the current state of every feature.
+func (t *TrillianLogAdminServer) ListFeatures(ctx context.Context, req *trillian.ListFeaturesRequest) (*trillian.ListFeaturesResponse, error) {
+	if t.features == nil {
+		return nil, grpc.Errorf(codes.Unimplemented, "feature flags are not enabled on this server")
+	}
+
+	resp := &trillian.ListFeaturesResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK)}
+
+	for _, config := range t.features.Configs() {
+		resp.Features = append(resp.Features, featureToProto(config))
+	}
+
+	return resp, nil
+}
+
+// SetTreeMetadata replaces the human readable metadata of a log and returns it as stored,
+// which includes when the log was created.
+func (t *TrillianLogAdminServer) SetTreeMetadata(ctx context.Context, req *trillian.SetTreeMetadataRequest) (*trillian.SetTreeMetadataResponse, error) {
+	if req.Metadata == nil {
+		return nil, grpc.Errorf(codes.InvalidArgument, "no metadata for log %d", req.LogId)
+	}
+
+	if err := validateTreeMetadata(*req.Metadata); err != nil {
+		return nil, err
+	}
+
+	s, err := t.storageProvider(req.LogId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.SetTreeMetadata(*req.Metadata); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	metadata, err := tx.GetTreeMetadata()
+
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		glog.Warningf("Commit failed for SetTreeMetadata: %v", err)
+		return nil, err
+	}
+
+	glog.Infof("Metadata of log %d set to %+v", req.LogId, metadata)
+
+	return &trillian.SetTreeMetadataResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Metadata: &metadata}, nil
+}
+
+// GetTreeStorageStats returns the number of rows and bytes a log has in each of its leaf, node
+// and root tables and how fast they're growing. Storage keeps the stats up to date as of the
+// latest signed root, reading only what's been written since they were last fetched.
+func (t *TrillianLogAdminServer) GetTreeStorageStats(ctx context.Context, req *trillian.GetTreeStorageStatsRequest) (*trillian.GetTreeStorageStatsResponse, error) {
+	s, err := t.storageProvider(req.LogId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	sr, ok := tx.(storage.TreeStatsReader)
+
+	if !ok {
+		tx.Rollback()
+		return nil, grpc.Errorf(codes.Unimplemented, "storage stats are not supported by this server's storage")
+	}
+
+	stats, err := sr.GetTreeStorageStats()
+
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		glog.Warningf("Commit failed for GetTreeStorageStats: %v", err)
+		return nil, err
+	}
+
+	return &trillian.GetTreeStorageStatsResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Tables: stats}, nil
+}
+
+// PauseSequencing stops a log's leaves being sequenced and its roots being signed until
+// ResumeSequencing is called for it, so that storage maintenance can be done without stopping
+// the server. Leaves can still be queued. The state is kept in storage so every server sharing
+// it respects it and it survives restarts. A batch already being sequenced is completed.
+func (t *TrillianLogAdminServer) PauseSequencing(ctx context.Context, req *trillian.PauseSequencingRequest) (*trillian.PauseSequencingResponse, error) {
+	if err := t.setSequencingEnabled(req.LogId, false); err != nil {
+		return nil, err
+	}
+
+	glog.Infof("Sequencing of log %d paused", req.LogId)
+
+	return &trillian.PauseSequencingResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK)}, nil
+}
+
+// ResumeSequencing undoes PauseSequencing. Leaves queued while the log was paused are
+// sequenced by the next pass.
+func (t *TrillianLogAdminServer) ResumeSequencing(ctx context.Context, req *trillian.ResumeSequencingRequest) (*trillian.ResumeSequencingResponse, error) {
+	if err := t.setSequencingEnabled(req.LogId, true); err != nil {
+		return nil, err
+	}
+
+	glog.Infof("Sequencing of log %d resumed", req.LogId)
+
+	return &trillian.ResumeSequencingResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK)}, nil
+}
+
+func (t *TrillianLogAdminServer) setSequencingEnabled(logID int64, enabled bool) error {
+	s, err := t.storageProvider(logID)
+
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	controller, ok := tx.(storage.SequencingController)
+
+	if !ok {
+		tx.Rollback()
+		return grpc.Errorf(codes.Unimplemented, "pausing sequencing is not supported by this server's storage")
+	}
+
+	if err := controller.SetSequencingEnabled(enabled); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		glog.Warningf("Commit failed for SetSequencingEnabled: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func validateTreeMetadata(metadata trillian.TreeMetadata) error {
+	for _, field := range []struct {
+		name   string
+		value  string
+		maxLen int
+	}{
+		{"display name", metadata.DisplayName, maxTreeDisplayNameLength},
+		{"description", metadata.Description, maxTreeDescriptionLength},
+		{"owner contact", metadata.OwnerContact, maxTreeOwnerContactLength},
+	} {
+		if len(field.value) > field.maxLen {
+			return grpc.Errorf(codes.InvalidArgument, "tree %s is %d bytes, the limit is %d", field.name, len(field.value), field.maxLen)
+		}
+	}
+
+	return nil
+}
+
+func featureToProto(config util.FeatureConfig) *trillian.FeatureProto {
+	feature := &trillian.FeatureProto{Name: config.Name, Enabled: config.Enabled}
+
+	for logID, enabled := range config.Logs {
+		feature.Logs = append(feature.Logs, &trillian.LogFeatureProto{LogId: logID, Enabled: enabled})
+	}
+
+	// Keep the output stable, map iteration order isn't
+	sort.Sort(logFeaturesByID(feature.Logs))
+	return feature
+}
+
+type logFeaturesByID []*trillian.LogFeatureProto
+
+func (l logFeaturesByID) Len() int           { return len(l) }
+func (l logFeaturesByID) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l logFeaturesByID) Less(i, j int) bool { return l[i].LogId < l[j].LogId }
@@ -0,0 +1,397 @@
+package server
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/log"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/testonly"
+	"github.com/google/trillian/util"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+var flushRequest = trillian.FlushLogRequest{LogId: 1, ForceNewRoot: true}
+
+func fakeFlush(t *testing.T, leaves int, err error) LogFlushFunc {
+	return func(ctx context.Context, logID int64, forceNewRoot bool) (int, error) {
+		if got, want := logID, flushRequest.LogId; got != want {
+			t.Errorf("Flushed log %d, expected %d", got, want)
+		}
+
+		if got, want := forceNewRoot, flushRequest.ForceNewRoot; got != want {
+			t.Errorf("Flushed with forceNewRoot=%v, expected %v", got, want)
+		}
+
+		return leaves, err
+	}
+}
+
+func TestFlushLog(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedLogRoot().Return(signedRoot1, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogAdminServer(mockStorageProviderfunc(mockStorage), fakeFlush(t, 3, nil))
+
+	resp, err := server.FlushLog(context.Background(), &flushRequest)
+
+	if err != nil {
+		t.Fatalf("Failed to flush log: %v", err)
+	}
+
+	if got, want := resp.Status.StatusCode, trillian.TrillianApiStatusCode_OK; got != want {
+		t.Errorf("Got status %v, expected %v", got, want)
+	}
+
+	if got, want := resp.LeavesIntegrated, int64(3); got != want {
+		t.Errorf("Got %d leaves integrated, expected %d", got, want)
+	}
+
+	if !proto.Equal(resp.SignedLogRoot, &signedRoot1) {
+		t.Errorf("Got root %v, expected %v", resp.SignedLogRoot, signedRoot1)
+	}
+}
+
+func TestFlushLogFlushFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Storage must not be touched if the flush failed
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	server := NewTrillianLogAdminServer(mockStorageProviderfunc(mockStorage), fakeFlush(t, 0, errors.New("SEQUENCE")))
+
+	_, err := server.FlushLog(context.Background(), &flushRequest)
+	testonly.EnsureErrorContains(t, err, "SEQUENCE")
+}
+
+func TestFlushLogLatestRootFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedLogRoot().Return(trillian.SignedLogRoot{}, errors.New("STORAGE"))
+	mockTx.EXPECT().Rollback().Return(nil)
+
+	server := NewTrillianLogAdminServer(mockStorageProviderfunc(mockStorage), fakeFlush(t, 1, nil))
+
+	_, err := server.FlushLog(context.Background(), &flushRequest)
+	testonly.EnsureErrorContains(t, err, "STORAGE")
+}
+
+func TestFlushLogCommitFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedLogRoot().Return(signedRoot1, nil)
+	mockTx.EXPECT().Commit().Return(errors.New("COMMIT"))
+
+	server := NewTrillianLogAdminServer(mockStorageProviderfunc(mockStorage), fakeFlush(t, 1, nil))
+
+	_, err := server.FlushLog(context.Background(), &flushRequest)
+	testonly.EnsureErrorContains(t, err, "COMMIT")
+}
+
+func TestFeaturesNotEnabled(t *testing.T) {
+	server := NewTrillianLogAdminServer(nil, nil)
+
+	if _, err := server.SetFeature(context.Background(), &trillian.SetFeatureRequest{Name: FeatureChunkedBatches}); grpc.Code(err) != codes.Unimplemented {
+		t.Errorf("SetFeature()=%v, expected Unimplemented", err)
+	}
+
+	if _, err := server.ListFeatures(context.Background(), &trillian.ListFeaturesRequest{}); grpc.Code(err) != codes.Unimplemented {
+		t.Errorf("ListFeatures()=%v, expected Unimplemented", err)
+	}
+}
+
+func TestSetFeature(t *testing.T) {
+	features := util.NewFeatures(LogServerFeatures...)
+	server := NewTrillianLogAdminServer(nil, nil)
+	server.SetFeatures(features)
+
+	for _, req := range []*trillian.SetFeatureRequest{
+		{Name: FeatureChunkedBatches, Enabled: true},
+		{Name: FeatureChunkedBatches, LogId: 9, Enabled: false},
+		{Name: FeatureChunkedBatches, LogId: 3, Enabled: false},
+	} {
+		if _, err := server.SetFeature(context.Background(), req); err != nil {
+			t.Fatalf("SetFeature(%v)=%v", req, err)
+		}
+	}
+
+	resp, err := server.SetFeature(context.Background(), &trillian.SetFeatureRequest{Name: FeatureChunkedBatches, LogId: 9, Reset_: true})
+
+	if err != nil {
+		t.Fatalf("SetFeature() reset=%v", err)
+	}
+
+	want := &trillian.FeatureProto{Name: FeatureChunkedBatches, Enabled: true, Logs: []*trillian.LogFeatureProto{{LogId: 3}}}
+
+	if !proto.Equal(resp.Feature, want) {
+		t.Errorf("SetFeature()=%v, expected %v", resp.Feature, want)
+	}
+
+	if !features.Enabled(FeatureChunkedBatches, 9) || features.Enabled(FeatureChunkedBatches, 3) {
+		t.Errorf("SetFeature() didn't change the registry, got %+v", features.Configs())
+	}
+
+	if _, err := server.SetFeature(context.Background(), &trillian.SetFeatureRequest{Name: "unknown", Enabled: true}); grpc.Code(err) != codes.InvalidArgument {
+		t.Errorf("SetFeature() of an unknown feature=%v, expected InvalidArgument", err)
+	}
+
+	list, err := server.ListFeatures(context.Background(), &trillian.ListFeaturesRequest{})
+
+	if err != nil {
+		t.Fatalf("ListFeatures()=%v", err)
+	}
+
+	if got, want := len(list.Features), len(LogServerFeatures); got != want {
+		t.Fatalf("ListFeatures() returned %d features, expected %d", got, want)
+	}
+
+	for _, feature := range list.Features {
+		if feature.Name == FeatureChunkedBatches && !proto.Equal(feature, want) {
+			t.Errorf("ListFeatures() returned %v, expected %v", feature, want)
+		}
+	}
+}
+
+func TestSetTreeMetadata(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metadata := trillian.TreeMetadata{DisplayName: "log", Description: "a log", OwnerContact: "owner@example.com"}
+	stored := metadata
+	stored.CreateTimeNanos = 1000
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockTx.EXPECT().SetTreeMetadata(metadata).Return(nil)
+	mockTx.EXPECT().GetTreeMetadata().Return(stored, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogAdminServer(mockStorageProviderfunc(mockStorage), nil)
+
+	resp, err := server.SetTreeMetadata(context.Background(), &trillian.SetTreeMetadataRequest{LogId: 1, Metadata: &metadata})
+
+	if err != nil {
+		t.Fatalf("SetTreeMetadata()=%v", err)
+	}
+
+	if !proto.Equal(resp.Metadata, &stored) {
+		t.Errorf("SetTreeMetadata()=%v, expected %v", resp.Metadata, stored)
+	}
+}
+
+func TestSetTreeMetadataRejectsBadRequests(t *testing.T) {
+	// Storage must not be touched for a bad request
+	server := NewTrillianLogAdminServer(nil, nil)
+
+	for _, req := range []*trillian.SetTreeMetadataRequest{
+		{LogId: 1},
+		{LogId: 1, Metadata: &trillian.TreeMetadata{DisplayName: strings.Repeat("x", maxTreeDisplayNameLength+1)}},
+		{LogId: 1, Metadata: &trillian.TreeMetadata{Description: strings.Repeat("x", maxTreeDescriptionLength+1)}},
+		{LogId: 1, Metadata: &trillian.TreeMetadata{OwnerContact: strings.Repeat("x", maxTreeOwnerContactLength+1)}},
+	} {
+		if _, err := server.SetTreeMetadata(context.Background(), req); grpc.Code(err) != codes.InvalidArgument {
+			t.Errorf("SetTreeMetadata(%v)=%v, expected InvalidArgument", req, err)
+		}
+	}
+}
+
+func TestSetTreeMetadataStorageFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metadata := trillian.TreeMetadata{DisplayName: "log"}
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockTx.EXPECT().SetTreeMetadata(metadata).Return(errors.New("STORAGE"))
+	mockTx.EXPECT().Rollback().Return(nil)
+
+	server := NewTrillianLogAdminServer(mockStorageProviderfunc(mockStorage), nil)
+
+	_, err := server.SetTreeMetadata(context.Background(), &trillian.SetTreeMetadataRequest{LogId: 1, Metadata: &metadata})
+	testonly.EnsureErrorContains(t, err, "STORAGE")
+}
+
+// statsReaderTX adds storage.TreeStatsReader to a mock transaction
+type statsReaderTX struct {
+	*storage.MockLogTX
+	stats []*trillian.TableStorageStats
+	err   error
+}
+
+func (s statsReaderTX) GetTreeStorageStats() ([]*trillian.TableStorageStats, error) {
+	return s.stats, s.err
+}
+
+func TestGetTreeStorageStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	stats := []*trillian.TableStorageStats{
+		{Table: storage.LeafTableStats, RowCount: 10, ByteSize: 1000, RowsPerDay: 2, BytesPerDay: 200},
+		{Table: storage.NodeTableStats, RowCount: 4, ByteSize: 400},
+		{Table: storage.RootTableStats, RowCount: 3, ByteSize: 300},
+	}
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().Begin().Return(statsReaderTX{MockLogTX: mockTx, stats: stats}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogAdminServer(mockStorageProviderfunc(mockStorage), nil)
+
+	resp, err := server.GetTreeStorageStats(context.Background(), &trillian.GetTreeStorageStatsRequest{LogId: 1})
+
+	if err != nil {
+		t.Fatalf("GetTreeStorageStats()=%v", err)
+	}
+
+	if got, want := resp, (&trillian.GetTreeStorageStatsResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Tables: stats}); !proto.Equal(got, want) {
+		t.Errorf("GetTreeStorageStats()=%v, expected %v", got, want)
+	}
+}
+
+func TestGetTreeStorageStatsStorageFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().Begin().Return(statsReaderTX{MockLogTX: mockTx, err: errors.New("STORAGE")}, nil)
+	mockTx.EXPECT().Rollback().Return(nil)
+
+	server := NewTrillianLogAdminServer(mockStorageProviderfunc(mockStorage), nil)
+
+	_, err := server.GetTreeStorageStats(context.Background(), &trillian.GetTreeStorageStatsRequest{LogId: 1})
+	testonly.EnsureErrorContains(t, err, "STORAGE")
+}
+
+func TestGetTreeStorageStatsNotSupported(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockTx.EXPECT().Rollback().Return(nil)
+
+	server := NewTrillianLogAdminServer(mockStorageProviderfunc(mockStorage), nil)
+
+	if _, err := server.GetTreeStorageStats(context.Background(), &trillian.GetTreeStorageStatsRequest{LogId: 1}); grpc.Code(err) != codes.Unimplemented {
+		t.Errorf("GetTreeStorageStats()=%v, expected Unimplemented", err)
+	}
+}
+
+// sequencingControlTX adds storage.SequencingController to a mock transaction
+type sequencingControlTX struct {
+	*storage.MockLogTX
+	enabled *bool
+	err     error
+}
+
+func (s sequencingControlTX) SequencingEnabled() (bool, error) {
+	return *s.enabled, s.err
+}
+
+func (s sequencingControlTX) SetSequencingEnabled(enabled bool) error {
+	if s.err == nil {
+		*s.enabled = enabled
+	}
+
+	return s.err
+}
+
+func TestPauseAndResumeSequencing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	enabled := true
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().Begin().Times(2).Return(sequencingControlTX{MockLogTX: mockTx, enabled: &enabled}, nil)
+	mockTx.EXPECT().Commit().Times(2).Return(nil)
+
+	server := NewTrillianLogAdminServer(mockStorageProviderfunc(mockStorage), nil)
+
+	if _, err := server.PauseSequencing(context.Background(), &trillian.PauseSequencingRequest{LogId: 1}); err != nil {
+		t.Fatalf("PauseSequencing()=%v", err)
+	}
+
+	if enabled {
+		t.Fatalf("Sequencing still enabled after PauseSequencing()")
+	}
+
+	if _, err := server.ResumeSequencing(context.Background(), &trillian.ResumeSequencingRequest{LogId: 1}); err != nil {
+		t.Fatalf("ResumeSequencing()=%v", err)
+	}
+
+	if !enabled {
+		t.Fatalf("Sequencing still paused after ResumeSequencing()")
+	}
+}
+
+func TestPauseSequencingStorageFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	enabled := true
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().Begin().Return(sequencingControlTX{MockLogTX: mockTx, enabled: &enabled, err: errors.New("STORAGE")}, nil)
+	mockTx.EXPECT().Rollback().Return(nil)
+
+	server := NewTrillianLogAdminServer(mockStorageProviderfunc(mockStorage), nil)
+
+	_, err := server.PauseSequencing(context.Background(), &trillian.PauseSequencingRequest{LogId: 1})
+	testonly.EnsureErrorContains(t, err, "STORAGE")
+}
+
+func TestPauseSequencingNotSupported(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockTx.EXPECT().Rollback().Return(nil)
+
+	server := NewTrillianLogAdminServer(mockStorageProviderfunc(mockStorage), nil)
+
+	if _, err := server.PauseSequencing(context.Background(), &trillian.PauseSequencingRequest{LogId: 1}); grpc.Code(err) != codes.Unimplemented {
+		t.Errorf("PauseSequencing()=%v, expected Unimplemented", err)
+	}
+}
+
+func TestFlushLogSequencingPaused(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	server := NewTrillianLogAdminServer(mockStorageProviderfunc(mockStorage), fakeFlush(t, 0, log.ErrSequencingPaused))
+
+	if _, err := server.FlushLog(context.Background(), &flushRequest); grpc.Code(err) != codes.FailedPrecondition {
+		t.Errorf("FlushLog()=%v for a paused log, expected FailedPrecondition", err)
+	}
+}
@@ -1,27 +1,47 @@
 package server
 
 import (
+	"bytes"
 	"fmt"
+	"time"
+
 	"github.com/golang/glog"
 	"github.com/google/trillian"
+	"github.com/google/trillian/audit"
+	terrors "github.com/google/trillian/errors"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/util"
 	"github.com/golang/protobuf/proto"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
 // TODO: There is no access control in the server yet and clients could easily modify
 // any tree.
 
-// Pass this as a fixed value to proof calculations. It's used as the max depth of the tree
-const proofMaxBitLen = 64
-
 // LogStorageProviderFunc decouples the server from storage implementations
 type LogStorageProviderFunc func(int64) (storage.LogStorage, error)
 
 // TrillianLogServer implements the RPC API defined in the proto
 type TrillianLogServer struct {
 	storageProvider LogStorageProviderFunc
+	// auditJournal is optional, if set write requests are recorded in it
+	auditJournal *audit.Journal
+	// treeEvents is optional, if set clients can subscribe to the events published to it
+	treeEvents *TreeEvents
+	// features is optional, if set it decides which of LogServerFeatures are used
+	features *util.Features
+	// leafValidators are run on the leaves of every QueueLeaves request, in order
+	leafValidators []LeafValidator
+	// idempotency is optional, if set it answers retried QueueLeaves requests
+	idempotency *IdempotencyCache
+	// rootCache is optional, if set GetLatestSignedLogRoot is answered from it when it can be
+	rootCache *RootCache
+	// slowTxThreshold is optional, if set storage transactions that take longer are logged
+	slowTxThreshold time.Duration
+	timeSource      util.TimeSource
 }
 
 // NewTrillianLogServer creates a new RPC server backed by a LogStorageProvider.
@@ -29,6 +49,50 @@ func NewTrillianLogServer(p LogStorageProviderFunc) *TrillianLogServer {
 	return &TrillianLogServer{storageProvider: p}
 }
 
+// SetAuditJournal makes the server record every QueueLeaves request in j before it's
+// processed. Requests that can't be recorded are rejected. Passing nil disables this.
+func (t *TrillianLogServer) SetAuditJournal(j *audit.Journal) {
+	t.auditJournal = j
+}
+
+// SetTreeEvents makes SubscribeTreeEvents stream the events published to e, which will
+// normally be shared with the SequencerManager. Passing nil disables SubscribeTreeEvents.
+func (t *TrillianLogServer) SetTreeEvents(e *TreeEvents) {
+	t.treeEvents = e
+}
+
+// SetFeatures makes the server check features before using FeatureSingleQueryProofs for a log.
+// If it's not called, or nil is passed, the feature is always used where storage supports it.
+func (t *TrillianLogServer) SetFeatures(features *util.Features) {
+	t.features = features
+}
+
+// SetLeafValidators makes QueueLeaves reject requests with leaves that any of validators
+// rejects, checking them in order. Calling it again replaces the validators.
+func (t *TrillianLogServer) SetLeafValidators(validators ...LeafValidator) {
+	t.leafValidators = validators
+}
+
+// SetIdempotencyCache makes QueueLeaves answer a request with the same idempotency key as a
+// recent one with the earlier result rather than queueing its leaves again. Passing nil
+// disables this, and keys are then ignored.
+func (t *TrillianLogServer) SetIdempotencyCache(c *IdempotencyCache) {
+	t.idempotency = c
+}
+
+// SetRootCache makes GetLatestSignedLogRoot answer from c rather than storage when it has a
+// fresh root for the log, see RootCache. Passing nil disables this.
+func (t *TrillianLogServer) SetRootCache(c *RootCache) {
+	t.rootCache = c
+}
+
+// SetSlowStorageLogging makes the server log storage transactions that take longer than
+// threshold, along with the request ID of the RPC they were for. Zero disables this.
+func (t *TrillianLogServer) SetSlowStorageLogging(threshold time.Duration, timeSource util.TimeSource) {
+	t.slowTxThreshold = threshold
+	t.timeSource = timeSource
+}
+
 // QueueLeaves submits a batch of leaves to the log for later integration into the underlying tree.
 func (t *TrillianLogServer) QueueLeaves(ctx context.Context, req *trillian.QueueLeavesRequest) (*trillian.QueueLeavesResponse, error) {
 	leaves := protosToLeaves(req.Leaves)
@@ -37,7 +101,47 @@ func (t *TrillianLogServer) QueueLeaves(ctx context.Context, req *trillian.Queue
 		return &trillian.QueueLeavesResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, "Must queue at least one leaf")}, nil
 	}
 
-	tx, err := t.prepareStorageTx(req.LogId)
+	s, err := t.storageProvider(req.LogId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	treeHasher, err := merkle.NewTreeHasherForAlgorithm(s.HashAlgorithm(), s.LeafHashStrategy())
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setLeafHashes(treeHasher, leaves); err != nil {
+		return &trillian.QueueLeavesResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, err.Error())}, nil
+	}
+
+	if t.idempotency != nil && len(req.IdempotencyKey) > 0 {
+		// Replayed requests aren't recorded in the audit journal again
+		return t.idempotency.do(ctx, req.LogId, req.IdempotencyKey, leavesFingerprint(leaves), func() (*trillian.QueueLeavesResponse, error) {
+			return t.queueLeaves(ctx, s, req, leaves)
+		})
+	}
+
+	return t.queueLeaves(ctx, s, req, leaves)
+}
+
+// queueLeaves validates, records and queues leaves, whose hashes have been set
+func (t *TrillianLogServer) queueLeaves(ctx context.Context, s storage.LogStorage, req *trillian.QueueLeavesRequest, leaves []trillian.LogLeaf) (*trillian.QueueLeavesResponse, error) {
+	// Rejected requests aren't recorded in the audit journal, as with bad hashes
+	if err := validateLeaves(ctx, t.leafValidators, req.LogId, leaves); err != nil {
+		return &trillian.QueueLeavesResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, err.Error())}, nil
+	}
+
+	if t.auditJournal != nil {
+		if err := auditQueueLeaves(ctx, t.auditJournal, req); err != nil {
+			glog.Warningf("Failed to record QueueLeaves in audit journal: %v", err)
+			return nil, err
+		}
+	}
+
+	tx, err := t.beginTx(ctx, s)
 
 	if err != nil {
 		return nil, err
@@ -62,20 +166,20 @@ func (t *TrillianLogServer) QueueLeaves(ctx context.Context, req *trillian.Queue
 func (t *TrillianLogServer) GetInclusionProof(ctx context.Context, req *trillian.GetInclusionProofRequest) (*trillian.GetInclusionProofResponse, error) {
 	// Reject obviously invalid tree sizes and leaf indices
 	if req.TreeSize <= 0 {
-		return nil, fmt.Errorf("invalid tree size for proof by hash: %d", req.TreeSize)
+		return nil, terrors.Errorf(terrors.InvalidRange, "invalid tree size for proof by hash: %d", req.TreeSize)
 	}
 
 	if req.LeafIndex <= 0 {
-		return nil, fmt.Errorf("invalid leaf index: %d", req.LeafIndex)
+		return nil, terrors.Errorf(terrors.InvalidRange, "invalid leaf index: %d", req.LeafIndex)
 	}
 
 	if req.LeafIndex >= req.TreeSize {
-		return nil, fmt.Errorf("leaf index %d does not exist in tree of size %d", req.LeafIndex, req.TreeSize)
+		return nil, terrors.Errorf(terrors.InvalidRange, "leaf index %d does not exist in tree of size %d", req.LeafIndex, req.TreeSize)
 	}
 
 	// Next we need to make sure the requested tree size corresponds to an STH, so that we
 	// have a usable tree revision
-	tx, err := t.prepareStorageTx(req.LogId)
+	tx, treeDepth, err := t.prepareProofStorageTx(ctx, req.LogId)
 
 	if err != nil {
 		return nil, err
@@ -88,7 +192,7 @@ func (t *TrillianLogServer) GetInclusionProof(ctx context.Context, req *trillian
 		return nil, err
 	}
 
-	proof, err := getInclusionProofForLeafIndexAtRevision(tx, treeRevision, req.TreeSize, req.LeafIndex)
+	proof, err := getInclusionProofForLeafIndexAtRevision(t.proofTX(tx, req.LogId), treeDepth, treeRevision, req.TreeSize, req.LeafIndex)
 
 	if err != nil {
 		tx.Rollback()
@@ -112,16 +216,16 @@ func (t *TrillianLogServer) GetInclusionProof(ctx context.Context, req *trillian
 func (t *TrillianLogServer) GetInclusionProofByHash(ctx context.Context, req *trillian.GetInclusionProofByHashRequest) (*trillian.GetInclusionProofByHashResponse, error) {
 	// Reject obviously invalid tree sizes
 	if req.TreeSize <= 0 {
-		return nil, fmt.Errorf("invalid tree size for proof by hash: %d", req.TreeSize)
+		return nil, terrors.Errorf(terrors.InvalidRange, "invalid tree size for proof by hash: %d", req.TreeSize)
 	}
 
 	if len(req.LeafHash) == 0 {
-		return nil, fmt.Errorf("invalid leaf hash: %v", req.LeafHash)
+		return nil, terrors.Errorf(terrors.InvalidRange, "invalid leaf hash: %v", req.LeafHash)
 	}
 
 	// Next we need to make sure the requested tree size corresponds to an STH, so that we
 	// have a usable tree revision
-	tx, err := t.prepareStorageTx(req.LogId)
+	tx, treeDepth, err := t.prepareProofStorageTx(ctx, req.LogId)
 
 	if err != nil {
 		return nil, err
@@ -147,7 +251,12 @@ func (t *TrillianLogServer) GetInclusionProofByHash(ctx context.Context, req *tr
 	proofs := make([]*trillian.ProofProto, 0, len(leaves))
 
 	for _, leaf := range leaves {
-		proof, err := getInclusionProofForLeafIndexAtRevision(tx, treeRevision, req.TreeSize, leaf.SequenceNumber)
+		// A duplicate of the leaf may have been added after the requested tree size
+		if leaf.SequenceNumber >= req.TreeSize {
+			continue
+		}
+
+		proof, err := getInclusionProofForLeafIndexAtRevision(t.proofTX(tx, req.LogId), treeDepth, treeRevision, req.TreeSize, leaf.SequenceNumber)
 
 		if err != nil {
 			tx.Rollback()
@@ -175,26 +284,27 @@ func (t *TrillianLogServer) GetInclusionProofByHash(ctx context.Context, req *tr
 func (t *TrillianLogServer) GetConsistencyProof(ctx context.Context, req *trillian.GetConsistencyProofRequest) (*trillian.GetConsistencyProofResponse, error) {
 	// Reject requests where the parameters don't make sense
 	if req.FirstTreeSize <= 0 {
-		return nil, fmt.Errorf("first tree size must be > 0 but was %d", req.FirstTreeSize)
+		return nil, terrors.Errorf(terrors.InvalidRange, "first tree size must be > 0 but was %d", req.FirstTreeSize)
 	}
 
 	if req.SecondTreeSize <= 0 {
-		return nil, fmt.Errorf("second tree size must be > 0 but was %d", req.SecondTreeSize)
+		return nil, terrors.Errorf(terrors.InvalidRange, "second tree size must be > 0 but was %d", req.SecondTreeSize)
 	}
 
 	if req.SecondTreeSize <= req.FirstTreeSize {
-		return nil, fmt.Errorf("second tree size (%d) must be > first tree size (%d)", req.SecondTreeSize, req.FirstTreeSize)
+		return nil, terrors.Errorf(terrors.InvalidRange, "second tree size (%d) must be > first tree size (%d)", req.SecondTreeSize, req.FirstTreeSize)
 	}
 
-	nodeIDs, err := merkle.CalcConsistencyProofNodeAddresses(req.FirstTreeSize, req.SecondTreeSize, proofMaxBitLen)
+	tx, treeDepth, err := t.prepareProofStorageTx(ctx, req.LogId)
 
 	if err != nil {
 		return nil, err
 	}
 
-	tx, err := t.prepareStorageTx(req.LogId)
+	nodeIDs, err := merkle.CalcConsistencyProofNodeAddresses(req.FirstTreeSize, req.SecondTreeSize, treeDepth)
 
 	if err != nil {
+		tx.Rollback()
 		return nil, err
 	}
 
@@ -216,7 +326,7 @@ func (t *TrillianLogServer) GetConsistencyProof(ctx context.Context, req *trilli
 
 	// Do all the node fetches at the second tree revision, which is what the node ids were calculated
 	// against.
-	proof, err := fetchNodesAndBuildProof(tx, secondTreeRevision, 0, nodeIDs)
+	proof, err := getConsistencyProofAtRevision(t.proofTX(tx, req.LogId), secondTreeRevision, req.FirstTreeSize, req.SecondTreeSize, nodeIDs)
 
 	if err != nil {
 		tx.Rollback()
@@ -233,33 +343,203 @@ func (t *TrillianLogServer) GetConsistencyProof(ctx context.Context, req *trilli
 	return &trillian.GetConsistencyProofResponse{Status:buildStatus(trillian.TrillianApiStatusCode_OK), Proof:&proof}, nil
 }
 
+// GetRangeProof obtains a proof that the leaves [start, end) are at those indices in the tree of
+// the given size. Checking it needs the hashes of all the leaves in the range, see
+// merkle.VerifyRangeProof, but it's much smaller than an inclusion proof for each of them.
+func (t *TrillianLogServer) GetRangeProof(ctx context.Context, req *trillian.GetRangeProofRequest) (*trillian.GetRangeProofResponse, error) {
+	// Reject requests where the parameters don't make sense
+	if req.TreeSize <= 0 {
+		return nil, terrors.Errorf(terrors.InvalidRange, "tree size must be > 0 but was %d", req.TreeSize)
+	}
+
+	if req.StartIndex < 0 || req.EndIndex <= req.StartIndex || req.EndIndex > req.TreeSize {
+		return nil, terrors.Errorf(terrors.InvalidRange, "range [%d, %d) must be non empty and within the tree size (%d)", req.StartIndex, req.EndIndex, req.TreeSize)
+	}
+
+	tx, treeDepth, err := t.prepareProofStorageTx(ctx, req.LogId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	nodeIDs, err := merkle.CalcRangeProofNodeAddresses(req.TreeSize, req.StartIndex, req.EndIndex, treeDepth)
+
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	treeRevision, err := tx.GetTreeRevisionAtSize(req.TreeSize)
+
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	proof, err := fetchNodesAndBuildProof(tx, treeRevision, req.StartIndex, nodeIDs)
+
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	err = tx.Commit()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &trillian.GetRangeProofResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Proof: &proof}, nil
+}
+
 // GetLatestSignedLogRoot obtains the latest published tree root for the Merkle Tree that
 // underlies the log.
 func (t *TrillianLogServer) GetLatestSignedLogRoot(ctx context.Context, req *trillian.GetLatestSignedLogRootRequest) (*trillian.GetLatestSignedLogRootResponse, error) {
-	tx, err := t.prepareStorageTx(req.LogId)
+	signedRoot, err := t.latestSignedLogRoot(ctx, req.LogId)
 
 	if err != nil {
 		return nil, err
 	}
 
+	return &trillian.GetLatestSignedLogRootResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), SignedLogRoot: &signedRoot}, nil
+}
+
+// maxLogRootsPerRequest limits the number of logs in a GetLatestSignedLogRoots request, each
+// of which may need its own storage transaction
+const maxLogRootsPerRequest = 1000
+
+// GetLatestSignedLogRoots obtains the latest published tree roots of several logs, for clients
+// such as frontends serving many logs that would otherwise make a request for each. Logs whose
+// root can't be read, e.g. because they don't exist, get an error status in their result and
+// don't affect the others.
+func (t *TrillianLogServer) GetLatestSignedLogRoots(ctx context.Context, req *trillian.GetLatestSignedLogRootsRequest) (*trillian.GetLatestSignedLogRootsResponse, error) {
+	if len(req.LogIds) == 0 || len(req.LogIds) > maxLogRootsPerRequest {
+		return nil, terrors.Errorf(terrors.InvalidRange, "number of log IDs must be between 1 and %d but was %d", maxLogRootsPerRequest, len(req.LogIds))
+	}
+
+	results := make([]*trillian.LogRootResult, 0, len(req.LogIds))
+
+	for _, logID := range req.LogIds {
+		signedRoot, err := t.latestSignedLogRoot(ctx, logID)
+
+		if err != nil {
+			results = append(results, &trillian.LogRootResult{LogId: logID, Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, err.Error())})
+			continue
+		}
+
+		results = append(results, &trillian.LogRootResult{LogId: logID, Status: buildStatus(trillian.TrillianApiStatusCode_OK), SignedLogRoot: &signedRoot})
+	}
+
+	return &trillian.GetLatestSignedLogRootsResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Results: results}, nil
+}
+
+// latestSignedLogRoot returns the latest root of a log, from the root cache if it has a fresh
+// one.
+func (t *TrillianLogServer) latestSignedLogRoot(ctx context.Context, logID int64) (trillian.SignedLogRoot, error) {
+	// Only logs that have been read from storage are cached, so unknown log IDs still fail
+	if t.rootCache != nil {
+		if signedRoot, ok := t.rootCache.get(logID); ok {
+			return signedRoot, nil
+		}
+	}
+
+	tx, err := t.prepareStorageTx(ctx, logID)
+
+	if err != nil {
+		return trillian.SignedLogRoot{}, err
+	}
+
 	signedRoot, err := tx.LatestSignedLogRoot()
 
 	if err != nil {
 		tx.Rollback()
-		return nil, err
+		return trillian.SignedLogRoot{}, err
 	}
 
 	if err := t.commitAndLog(tx, "GetLatestSignedLogRoot"); err != nil {
+		return trillian.SignedLogRoot{}, err
+	}
+
+	if t.rootCache != nil {
+		t.rootCache.put(logID, signedRoot)
+	}
+
+	return signedRoot, nil
+}
+
+// maxLatestLeaves limits the number of leaves in a GetLatestSignedLogRootAndLeaves response
+const maxLatestLeaves = 1000
+
+// GetLatestSignedLogRootAndLeaves obtains the latest published tree root and the leaves at the
+// end of the tree it commits to. They're read from one storage snapshot, so unlike separate
+// GetLatestSignedLogRoot and GetLeavesByIndex requests the leaves are always the ones the root
+// includes, however fast the log is growing. The root cache isn't used for the same reason.
+func (t *TrillianLogServer) GetLatestSignedLogRootAndLeaves(ctx context.Context, req *trillian.GetLatestSignedLogRootAndLeavesRequest) (*trillian.GetLatestSignedLogRootAndLeavesResponse, error) {
+	if req.MaxLeaves <= 0 || req.MaxLeaves > maxLatestLeaves {
+		return nil, terrors.Errorf(terrors.InvalidRange, "max leaves must be between 1 and %d but was %d", maxLatestLeaves, req.MaxLeaves)
+	}
+
+	s, err := t.storageProvider(req.LogId)
+
+	if err != nil {
 		return nil, err
 	}
 
-	return &trillian.GetLatestSignedLogRootResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), SignedLogRoot: &signedRoot}, nil
+	tx, err := s.Snapshot()
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	signedRoot, err := tx.LatestSignedLogRoot()
+
+	if err != nil {
+		tx.Commit()
+		return nil, err
+	}
+
+	start := signedRoot.TreeSize - req.MaxLeaves
+
+	if start < 0 {
+		start = 0
+	}
+
+	var leaves []trillian.LogLeaf
+
+	if start < signedRoot.TreeSize {
+		leafIndices := make([]int64, 0, signedRoot.TreeSize-start)
+
+		for index := start; index < signedRoot.TreeSize; index++ {
+			leafIndices = append(leafIndices, index)
+		}
+
+		if leaves, err = tx.GetLeavesByIndex(leafIndices); err != nil {
+			tx.Commit()
+			return nil, err
+		}
+
+		// Everything below the tree size has been sequenced so all of them must be there
+		if len(leaves) != len(leafIndices) {
+			tx.Commit()
+			return nil, terrors.Errorf(terrors.Integrity, "expected %d leaves from storage but got: %d", len(leafIndices), len(leaves))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		glog.Warningf("Commit failed for GetLatestSignedLogRootAndLeaves: %v", err)
+		return nil, err
+	}
+
+	return &trillian.GetLatestSignedLogRootAndLeavesResponse{
+		Status:        buildStatus(trillian.TrillianApiStatusCode_OK),
+		SignedLogRoot: &signedRoot,
+		Leaves:        leavesToProtos(leaves)}, nil
 }
 
 // GetSequencedLeafCount returns the number of leaves that have been integrated into the Merkle
 // Tree. This can be zero for a log containing no entries.
 func (t *TrillianLogServer) GetSequencedLeafCount(ctx context.Context, req *trillian.GetSequencedLeafCountRequest) (*trillian.GetSequencedLeafCountResponse, error) {
-	tx, err := t.prepareStorageTx(req.LogId)
+	tx, err := t.prepareStorageTx(ctx, req.LogId)
 
 	if err != nil {
 		return nil, err
@@ -288,13 +568,13 @@ func (t *TrillianLogServer) GetLeavesByIndex(ctx context.Context, req *trillian.
 		return &trillian.GetLeavesByIndexResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, "Invalid -ve leaf index in request")}, nil
 	}
 
-	tx, err := t.prepareStorageTx(req.LogId)
+	tx, err := t.prepareStorageTx(ctx, req.LogId)
 
 	if err != nil {
 		return nil, err
 	}
 
-	leaves, err := tx.GetLeavesByIndex(req.LeafIndex)
+	leaves, err := getLeavesByIndex(tx, req.LeafIndex, req.OmitExtraData)
 
 	if err != nil {
 		tx.Rollback()
@@ -310,6 +590,30 @@ func (t *TrillianLogServer) GetLeavesByIndex(ctx context.Context, req *trillian.
 	return &trillian.GetLeavesByIndexResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Leaves: leafProtos}, nil
 }
 
+// getLeavesByIndex reads leaves from storage, without their ExtraData if omitExtraData is
+// set. Storage that can skip reading the ExtraData is asked not to read it.
+func getLeavesByIndex(tx storage.LogTX, leafIndices []int64, omitExtraData bool) ([]trillian.LogLeaf, error) {
+	if !omitExtraData {
+		return tx.GetLeavesByIndex(leafIndices)
+	}
+
+	if es, ok := unwrapLogTX(tx).(storage.ExtraDataSkipper); ok {
+		return es.GetLeavesByIndexWithoutExtraData(leafIndices)
+	}
+
+	leaves, err := tx.GetLeavesByIndex(leafIndices)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range leaves {
+		leaves[i].ExtraData = nil
+	}
+
+	return leaves, nil
+}
+
 // GetLeavesByIndex obtains one or more leaves based on their tree hash. It is not possible
 // to fetch leaves that have been queued but not yet integrated. Logs may accept duplicate
 // entries so this may return more results than the number of hashes in the request.
@@ -318,7 +622,7 @@ func (t *TrillianLogServer) GetLeavesByHash(ctx context.Context, req *trillian.G
 		return &trillian.GetLeavesByHashResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, "Must supply at least one hash and none must be empty")}, nil
 	}
 
-	tx, err := t.prepareStorageTx(req.LogId)
+	tx, err := t.prepareStorageTx(ctx, req.LogId)
 
 	if err != nil {
 		return nil, err
@@ -340,25 +644,59 @@ func (t *TrillianLogServer) GetLeavesByHash(ctx context.Context, req *trillian.G
 	return &trillian.GetLeavesByHashResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Leaves: leafProtos}, nil
 }
 
+// GetLeavesByTimestamp obtains leaves that were integrated into the tree in the half open
+// range [start_timestamp_nanos, end_timestamp_nanos), in order of leaf index. At most
+// max_leaves are returned, so large ranges must be fetched in pages using start_leaf_index.
+func (t *TrillianLogServer) GetLeavesByTimestamp(ctx context.Context, req *trillian.GetLeavesByTimestampRequest) (*trillian.GetLeavesByTimestampResponse, error) {
+	if req.StartTimestampNanos < 0 || req.EndTimestampNanos <= req.StartTimestampNanos {
+		return &trillian.GetLeavesByTimestampResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, "Invalid timestamp range in request")}, nil
+	}
+
+	if req.StartLeafIndex < 0 || req.MaxLeaves <= 0 {
+		return &trillian.GetLeavesByTimestampResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, "Invalid start index or max leaves in request")}, nil
+	}
+
+	tx, err := t.prepareStorageTx(ctx, req.LogId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	leaves, err := tx.GetLeavesByTimestamp(req.StartTimestampNanos, req.EndTimestampNanos, req.StartLeafIndex, int(req.MaxLeaves))
+
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	leafProtos := leavesToProtos(leaves)
+
+	if err := t.commitAndLog(tx, "GetLeavesByTimestamp"); err != nil {
+		return nil, err
+	}
+
+	return &trillian.GetLeavesByTimestampResponse{Status: buildStatus(trillian.TrillianApiStatusCode_OK), Leaves: leafProtos}, nil
+}
+
 // GetEntryAndProof returns both a Merkle Leaf entry and an inclusion proof for a given index
 // and tree size.
 func (t *TrillianLogServer) GetEntryAndProof(ctx context.Context, req *trillian.GetEntryAndProofRequest) (*trillian.GetEntryAndProofResponse, error) {
 	// Reject parameters that are obviously not valid
 	if req.TreeSize <= 0 {
-		return nil, fmt.Errorf("invalid tree size for GetEntryAndProof: %d", req.TreeSize)
+		return nil, terrors.Errorf(terrors.InvalidRange, "invalid tree size for GetEntryAndProof: %d", req.TreeSize)
 	}
 
 	if req.LeafIndex < 0 {
-		return nil, fmt.Errorf("invalid params for GetEntryAndProof index: %d", req.LeafIndex)
+		return nil, terrors.Errorf(terrors.InvalidRange, "invalid params for GetEntryAndProof index: %d", req.LeafIndex)
 	}
 
 	if req.LeafIndex >= req.TreeSize {
-		return nil, fmt.Errorf("invalid params for GetEntryAndProof index: %d exceeds tree size: %d", req.LeafIndex, req.TreeSize)
+		return nil, terrors.Errorf(terrors.InvalidRange, "invalid params for GetEntryAndProof index: %d exceeds tree size: %d", req.LeafIndex, req.TreeSize)
 	}
 
 	// Next we need to make sure the requested tree size corresponds to an STH, so that we
 	// have a usable tree revision
-	tx, err := t.prepareStorageTx(req.LogId)
+	tx, treeDepth, err := t.prepareProofStorageTx(ctx, req.LogId)
 
 	if err != nil {
 		return nil, err
@@ -371,7 +709,7 @@ func (t *TrillianLogServer) GetEntryAndProof(ctx context.Context, req *trillian.
 		return nil, err
 	}
 
-	proof, err := getInclusionProofForLeafIndexAtRevision(tx, treeRevision, req.TreeSize, req.LeafIndex)
+	proof, err := getInclusionProofForLeafIndexAtRevision(t.proofTX(tx, req.LogId), treeDepth, treeRevision, req.TreeSize, req.LeafIndex)
 
 	if err != nil {
 		tx.Rollback()
@@ -388,7 +726,7 @@ func (t *TrillianLogServer) GetEntryAndProof(ctx context.Context, req *trillian.
 
 	if len(leaves) != 1 {
 		tx.Rollback()
-		return nil, fmt.Errorf("expected one leaf from storage but got: %d", len(leaves))
+		return nil, terrors.Errorf(terrors.Integrity, "expected one leaf from storage but got: %d", len(leaves))
 	}
 
 	leafProtos := leavesToProtos(leaves)
@@ -406,22 +744,206 @@ func (t *TrillianLogServer) GetEntryAndProof(ctx context.Context, req *trillian.
 		Leaf: leafProtos[0]}, nil
 }
 
-func (t *TrillianLogServer) prepareStorageTx(treeID int64) (storage.LogTX, error) {
-	s, err := t.storageProvider(treeID)
+// GetRevisionDiff is a debugging aid for operators. It compares the tree stored at two
+// revisions that have tree heads by checking that the perfect subtrees making up the smaller
+// tree have the same hashes at both, and also returns a consistency proof between them.
+func (t *TrillianLogServer) GetRevisionDiff(ctx context.Context, req *trillian.GetRevisionDiffRequest) (*trillian.GetRevisionDiffResponse, error) {
+	if req.FirstTreeRevision < 0 || req.SecondTreeRevision <= req.FirstTreeRevision {
+		return &trillian.GetRevisionDiffResponse{Status: buildStatusWithDesc(trillian.TrillianApiStatusCode_ERROR, "Second tree revision must be after the first")}, nil
+	}
+
+	tx, treeDepth, err := t.prepareProofStorageTx(ctx, req.LogId)
 
 	if err != nil {
 		return nil, err
 	}
 
-	tx, err := s.Begin()
+	firstRoot, err := tx.GetSignedLogRootAtRevision(req.FirstTreeRevision)
+
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	secondRoot, err := tx.GetSignedLogRootAtRevision(req.SecondTreeRevision)
 
 	if err != nil {
+		tx.Rollback()
 		return nil, err
 	}
 
+	// If the tree shrank that's already a problem but the nodes it still has can be compared
+	compareSize := firstRoot.TreeSize
+
+	if secondRoot.TreeSize < compareSize {
+		compareSize = secondRoot.TreeSize
+	}
+
+	nodeIDs, err := merkle.CalcCompactRangeNodeAddresses(compareSize, treeDepth)
+
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	nodeDiffs, err := diffNodesAtRevisions(tx, req.FirstTreeRevision, req.SecondTreeRevision, nodeIDs)
+
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	resp := &trillian.GetRevisionDiffResponse{
+		Status:              buildStatus(trillian.TrillianApiStatusCode_OK),
+		FirstSignedLogRoot:  &firstRoot,
+		SecondSignedLogRoot: &secondRoot,
+		NodesCompared:       int64(len(nodeIDs)),
+		NodeDiff:            nodeDiffs,
+	}
+
+	if firstRoot.TreeSize > 0 && secondRoot.TreeSize > firstRoot.TreeSize {
+		proofNodeIDs, err := merkle.CalcConsistencyProofNodeAddresses(firstRoot.TreeSize, secondRoot.TreeSize, treeDepth)
+
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		proof, err := getConsistencyProofAtRevision(t.proofTX(tx, req.LogId), req.SecondTreeRevision, firstRoot.TreeSize, secondRoot.TreeSize, proofNodeIDs)
+
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		resp.Proof = &proof
+	}
+
+	if err := t.commitAndLog(tx, "GetRevisionDiff"); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// GetTreeMetadata returns the human readable metadata of a log, e.g. so that frontends can
+// show which log they're serving.
+func (t *TrillianLogServer) GetTreeMetadata(ctx context.Context, req *trillian.GetTreeMetadataRequest) (*trillian.GetTreeMetadataResponse, error) {
+	s, err := t.storageProvider(req.LogId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := t.beginTx(ctx, s)
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	metadata, err := tx.GetTreeMetadata()
+
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := t.commitAndLog(tx, "GetTreeMetadata"); err != nil {
+		return nil, err
+	}
+
+	return &trillian.GetTreeMetadataResponse{
+		Status:           buildStatus(trillian.TrillianApiStatusCode_OK),
+		Metadata:         &metadata,
+		HashAlgorithm:    s.HashAlgorithm(),
+		LeafHashStrategy: s.LeafHashStrategy(),
+	}, nil
+}
+
+// SubscribeTreeEvents streams the events of a log, or of all logs if the log ID is zero, until
+// the client goes away. The stream ends with an error if the client falls too far behind, in
+// which case it should check the latest root before subscribing again.
+func (t *TrillianLogServer) SubscribeTreeEvents(req *trillian.SubscribeTreeEventsRequest, stream trillian.TrillianLog_SubscribeTreeEventsServer) error {
+	if t.treeEvents == nil {
+		return grpc.Errorf(codes.Unimplemented, "tree events are not enabled on this server")
+	}
+
+	sub := t.treeEvents.subscribe(req.LogId)
+	defer t.treeEvents.unsubscribe(sub)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case event, ok := <-sub.events:
+			if !ok {
+				return grpc.Errorf(codes.Unavailable, "tree event subscription for log %d ended", req.LogId)
+			}
+
+			if err := stream.Send(event); err != nil {
+				glog.Warningf("Failed to send tree event for log %d: %v", event.LogId, err)
+				return err
+			}
+		}
+	}
+}
+
+func (t *TrillianLogServer) prepareStorageTx(ctx context.Context, treeID int64) (storage.LogTX, error) {
+	s, err := t.storageProvider(treeID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := t.beginTx(ctx, s)
+
+	if err != nil {
+		return nil, storageError(err)
+	}
+
 	return tx, err
 }
 
+// prepareProofStorageTx is like prepareStorageTx but also returns the depth of the tree, which
+// is needed to work out the IDs of the nodes in proofs.
+func (t *TrillianLogServer) prepareProofStorageTx(ctx context.Context, treeID int64) (storage.LogTX, int, error) {
+	s, err := t.storageProvider(treeID)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tx, err := t.beginTx(ctx, s)
+
+	if err != nil {
+		return nil, 0, storageError(err)
+	}
+
+	return tx, s.TreeDepth(), nil
+}
+
+// beginTx starts a transaction for the RPC with ctx, which is logged with the RPC's request ID
+// if it's slow and SetSlowStorageLogging has been called.
+func (t *TrillianLogServer) beginTx(ctx context.Context, s storage.LogStorage) (storage.LogTX, error) {
+	tx, err := s.Begin()
+
+	if err != nil || t.slowTxThreshold <= 0 {
+		return tx, err
+	}
+
+	return newSlowLoggingLogTX(tx, util.RequestIDFromContext(ctx), t.slowTxThreshold, t.timeSource), nil
+}
+
+// storageError returns the error for a failure to start a transaction. It's a Backend failure
+// unless storage gave it another category.
+func storageError(err error) error {
+	if terrors.CodeOf(err) != terrors.Unknown {
+		return err
+	}
+
+	return terrors.Errorf(terrors.Backend, "storage is unavailable: %v", err)
+}
+
 func buildStatus(code trillian.TrillianApiStatusCode) *trillian.TrillianApiStatus {
 	return &trillian.TrillianApiStatus{StatusCode: code}
 }
@@ -443,8 +965,26 @@ func (t *TrillianLogServer) commitAndLog(tx storage.LogTX, op string) error {
 	return err
 }
 
+// setLeafHashes sets the hash of each leaf using the leaf hash strategy of the tree. Clients need
+// not supply leaf hashes but if they do they must match, otherwise the client and the log would
+// disagree about which hash to use when asking for proofs.
+func setLeafHashes(treeHasher merkle.TreeHasher, leaves []trillian.LogLeaf) error {
+	for i := range leaves {
+		hash := treeHasher.HashLeaf(leaves[i].LeafValue)
+
+		if len(leaves[i].LeafHash) > 0 && !bytes.Equal(leaves[i].LeafHash, hash) {
+			return fmt.Errorf("leaf %d has hash %v but its data hashes to %v", i, leaves[i].LeafHash, hash)
+		}
+
+		leaves[i].LeafHash = hash
+	}
+
+	return nil
+}
+
 func protoToLeaf(proto *trillian.LeafProto) trillian.LogLeaf {
-	return trillian.LogLeaf{SequenceNumber: proto.LeafIndex, Leaf: trillian.Leaf{LeafHash: proto.LeafHash, LeafValue: proto.LeafData, ExtraData: proto.ExtraData}}
+	return trillian.LogLeaf{SequenceNumber: proto.LeafIndex, Leaf: trillian.Leaf{LeafHash: proto.LeafHash, LeafValue: proto.LeafData, ExtraData: proto.ExtraData},
+		Priority: proto.Priority}
 }
 
 func protosToLeaves(protos []*trillian.LeafProto) []trillian.LogLeaf {
@@ -459,7 +999,8 @@ func protosToLeaves(protos []*trillian.LeafProto) []trillian.LogLeaf {
 
 // TODO: Fill in the log leaf specific fields when we've implemented signed timestamps
 func leafToProto(leaf trillian.LogLeaf) *trillian.LeafProto {
-	return &trillian.LeafProto{LeafIndex: leaf.SequenceNumber, LeafHash: leaf.LeafHash, LeafData: leaf.LeafValue, ExtraData: leaf.ExtraData}
+	return &trillian.LeafProto{LeafIndex: leaf.SequenceNumber, LeafHash: leaf.LeafHash, LeafData: leaf.LeafValue, ExtraData: leaf.ExtraData,
+		IntegrateTimestampNanos: leaf.IntegrateTimestampNanos}
 }
 
 func leavesToProtos(leaves []trillian.LogLeaf) []*trillian.LeafProto {
@@ -504,21 +1045,64 @@ func validateLeafHashes(leafHashes [][]byte) bool {
 	return true
 }
 
+// nodeOnlyTX hides any optional interfaces, e.g. storage.ProofReader, of the LogTX it wraps
+type nodeOnlyTX struct {
+	storage.LogTX
+}
+
+// proofTX returns the transaction that proofs for a log should be read from. If single query
+// proofs are turned off for the log they're built from individual node reads instead.
+func (t *TrillianLogServer) proofTX(tx storage.LogTX, logID int64) storage.LogTX {
+	if t.features == nil || t.features.Enabled(FeatureSingleQueryProofs, logID) {
+		return tx
+	}
+
+	return nodeOnlyTX{tx}
+}
+
 // getInclusionProofForLeafIndexAtRevision is used by multiple handlers. It does the storage fetching
 // and makes additional checks on the returned proof. Returns a ProofProto suitable for inclusion in
 // an RPC response
-func getInclusionProofForLeafIndexAtRevision(tx storage.LogTX, treeRevision, treeSize, leafIndex int64) (trillian.ProofProto, error) {
+func getInclusionProofForLeafIndexAtRevision(tx storage.LogTX, treeDepth int, treeRevision, treeSize, leafIndex int64) (trillian.ProofProto, error) {
 	// We have the tree size and leaf index so we know the nodes that we need to serve the proof
-	// TODO(Martin2112): Not sure about hardcoding maxBitLen here
-	proofNodeIDs, err := merkle.CalcInclusionProofNodeAddresses(treeSize, leafIndex, proofMaxBitLen)
+	proofNodeIDs, err := merkle.CalcInclusionProofNodeAddresses(treeSize, leafIndex, treeDepth)
 
 	if err != nil {
 		return trillian.ProofProto{}, err
 	}
 
+	// Storage that can fetch a whole proof at once does so, the node IDs are still needed to
+	// check what it returns
+	if pr, ok := unwrapLogTX(tx).(storage.ProofReader); ok {
+		proofNodes, err := pr.GetInclusionProofNodes(treeRevision, treeSize, leafIndex)
+
+		if err != nil {
+			return trillian.ProofProto{}, err
+		}
+
+		return buildProof(leafIndex, proofNodeIDs, proofNodes)
+	}
+
 	return fetchNodesAndBuildProof(tx, treeRevision, leafIndex, proofNodeIDs)
 }
 
+// getConsistencyProofAtRevision fetches the consistency proof between two tree sizes from
+// storage at treeRevision. proofNodeIDs must be the node IDs of the proof, which are used to
+// check what storage returns.
+func getConsistencyProofAtRevision(tx storage.LogTX, treeRevision, firstTreeSize, secondTreeSize int64, proofNodeIDs []storage.NodeID) (trillian.ProofProto, error) {
+	if pr, ok := unwrapLogTX(tx).(storage.ProofReader); ok {
+		proofNodes, err := pr.GetConsistencyProofNodes(treeRevision, firstTreeSize, secondTreeSize)
+
+		if err != nil {
+			return trillian.ProofProto{}, err
+		}
+
+		return buildProof(0, proofNodeIDs, proofNodes)
+	}
+
+	return fetchNodesAndBuildProof(tx, treeRevision, 0, proofNodeIDs)
+}
+
 // fetchNodesAndBuildProof is used by both inclusion and consistency proofs. It fetches the nodes
 // from storage and converts them into the proof proto that will be returned to the client.
 func fetchNodesAndBuildProof(tx storage.LogTX, treeRevision, leafIndex int64, proofNodeIDs []storage.NodeID) (trillian.ProofProto, error) {
@@ -528,8 +1112,14 @@ func fetchNodesAndBuildProof(tx storage.LogTX, treeRevision, leafIndex int64, pr
 		return trillian.ProofProto{}, err
 	}
 
+	return buildProof(leafIndex, proofNodeIDs, proofNodes)
+}
+
+// buildProof checks that the nodes fetched from storage are the ones with proofNodeIDs, in the
+// same order, and converts them into a proof proto.
+func buildProof(leafIndex int64, proofNodeIDs []storage.NodeID, proofNodes []storage.Node) (trillian.ProofProto, error) {
 	if len(proofNodes) != len(proofNodeIDs) {
-		return trillian.ProofProto{}, fmt.Errorf("expected %d nodes in proof but got %d", len(proofNodeIDs), len(proofNodes))
+		return trillian.ProofProto{}, terrors.Errorf(terrors.Integrity, "expected %d nodes in proof but got %d", len(proofNodeIDs), len(proofNodes))
 	}
 
 	proof := make([]*trillian.NodeProto, 0, len(proofNodeIDs))
@@ -537,7 +1127,7 @@ func fetchNodesAndBuildProof(tx storage.LogTX, treeRevision, leafIndex int64, pr
 	for i, node := range proofNodes {
 		// additional check that the correct node was returned
 		if !node.NodeID.Equivalent(proofNodeIDs[i]) {
-			return trillian.ProofProto{}, fmt.Errorf("expected node %v at proof pos %d but got %v", proofNodeIDs[i], i, node.NodeID)
+			return trillian.ProofProto{}, terrors.Errorf(terrors.Integrity, "expected node %v at proof pos %d but got %v", proofNodeIDs[i], i, node.NodeID)
 		}
 
 		idBytes, err := proto.Marshal(node.NodeID.AsProto())
@@ -550,4 +1140,51 @@ func fetchNodesAndBuildProof(tx storage.LogTX, treeRevision, leafIndex int64, pr
 	}
 
 	return trillian.ProofProto{LeafIndex:leafIndex, ProofNode:proof}, nil
-}
\ No newline at end of file
+}
+
+// diffNodesAtRevisions fetches nodes at two tree revisions and returns the ones whose hashes
+// differ, including any that are missing at one of them.
+func diffNodesAtRevisions(tx storage.LogTX, firstRevision, secondRevision int64, nodeIDs []storage.NodeID) ([]*trillian.NodeDiffProto, error) {
+	firstNodes, err := tx.GetMerkleNodes(firstRevision, nodeIDs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	secondNodes, err := tx.GetMerkleNodes(secondRevision, nodeIDs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	diffs := make([]*trillian.NodeDiffProto, 0)
+
+	for _, nodeID := range nodeIDs {
+		firstHash, secondHash := findNodeHash(firstNodes, nodeID), findNodeHash(secondNodes, nodeID)
+
+		if firstHash != nil && bytes.Equal(firstHash, secondHash) {
+			continue
+		}
+
+		idBytes, err := proto.Marshal(nodeID.AsProto())
+
+		if err != nil {
+			return nil, err
+		}
+
+		diffs = append(diffs, &trillian.NodeDiffProto{NodeId: idBytes, FirstNodeHash: firstHash, SecondNodeHash: secondHash})
+	}
+
+	return diffs, nil
+}
+
+// findNodeHash returns the hash of the node with the given ID, or nil if it isn't present
+func findNodeHash(nodes []storage.Node, nodeID storage.NodeID) []byte {
+	for _, node := range nodes {
+		if node.NodeID.Equivalent(nodeID) {
+			return node.Hash
+		}
+	}
+
+	return nil
+}
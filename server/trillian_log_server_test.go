@@ -3,15 +3,22 @@ package server
 import (
 	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
+	terrors "github.com/google/trillian/errors"
+	"github.com/google/trillian/merkle"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/testonly"
+	"github.com/google/trillian/util"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
 var logId1 = int64(1)
@@ -21,9 +28,10 @@ var leaf0Minus2Request = trillian.GetLeavesByIndexRequest{LogId: logId1, LeafInd
 var leaf03Request = trillian.GetLeavesByIndexRequest{LogId: logId1, LeafIndex: []int64{0, 3}}
 var leaf0Log2Request = trillian.GetLeavesByIndexRequest{LogId: logId2, LeafIndex: []int64{0}}
 
-var leaf1 = trillian.LogLeaf{SequenceNumber: 1, Leaf: trillian.Leaf{LeafHash: []byte("hash"), LeafValue: []byte("value"), ExtraData: []byte("extra")}}
+var leaf1Hash = merkle.NewRFC6962TreeHasher(trillian.NewSHA256()).HashLeaf([]byte("value"))
+var leaf1 = trillian.LogLeaf{SequenceNumber: 1, Leaf: trillian.Leaf{LeafHash: leaf1Hash, LeafValue: []byte("value"), ExtraData: []byte("extra")}}
 var leaf3 = trillian.LogLeaf{SequenceNumber: 3, Leaf: trillian.Leaf{LeafHash: []byte("hash3"), LeafValue: []byte("value3"), ExtraData: []byte("extra3")}}
-var expectedLeaf1 = trillian.LeafProto{LeafIndex: 1, LeafHash: []byte("hash"), LeafData: []byte("value"), ExtraData: []byte("extra")}
+var expectedLeaf1 = trillian.LeafProto{LeafIndex: 1, LeafHash: leaf1Hash, LeafData: []byte("value"), ExtraData: []byte("extra")}
 var expectedLeaf3 = trillian.LeafProto{LeafIndex: 3, LeafHash: []byte("hash3"), LeafData: []byte("value3"), ExtraData: []byte("extra3")}
 
 var queueRequest0 = trillian.QueueLeavesRequest{LogId: logId1, Leaves: []*trillian.LeafProto{&expectedLeaf1}}
@@ -38,6 +46,11 @@ var getByHashRequest1 = trillian.GetLeavesByHashRequest{LogId: logId1, LeafHash:
 var getByHashRequestBadHash = trillian.GetLeavesByHashRequest{LogId: logId1, LeafHash: [][]byte{[]byte(""), []byte("data")}}
 var getByHashRequest2 = trillian.GetLeavesByHashRequest{LogId: logId2, LeafHash: [][]byte{[]byte("test"), []byte("data")}}
 
+var getByTimestampRequest1 = trillian.GetLeavesByTimestampRequest{LogId: logId1, StartTimestampNanos: 1000, EndTimestampNanos: 2000, StartLeafIndex: 1, MaxLeaves: 10}
+var getByTimestampRequestBadRange = trillian.GetLeavesByTimestampRequest{LogId: logId1, StartTimestampNanos: 2000, EndTimestampNanos: 1000, MaxLeaves: 10}
+var getByTimestampRequestBadMaxLeaves = trillian.GetLeavesByTimestampRequest{LogId: logId1, StartTimestampNanos: 1000, EndTimestampNanos: 2000, MaxLeaves: 0}
+var getByTimestampRequest2 = trillian.GetLeavesByTimestampRequest{LogId: logId2, StartTimestampNanos: 1000, EndTimestampNanos: 2000, StartLeafIndex: 1, MaxLeaves: 10}
+
 var getInclusionProofByHashRequestBadTreeSize = trillian.GetInclusionProofByHashRequest{LogId: logId1, TreeSize: -50, LeafHash: []byte("data")}
 var getInclusionProofByHashRequestBadHash = trillian.GetInclusionProofByHashRequest{LogId: logId1, TreeSize: 50, LeafHash: []byte{}}
 var getInclusionProofByHashRequest7 = trillian.GetInclusionProofByHashRequest{LogId: logId1, TreeSize: 7, LeafHash: []byte("ahash")}
@@ -68,7 +81,26 @@ var nodeIdsInclusionSize7Index2 = []storage.NodeID{
 
 var nodeIdsConsistencySize4ToSize7 = []storage.NodeID{testonly.MustCreateNodeIDForTreeCoords(2, 1, 64)}
 
+var getRangeProofRequest7 = trillian.GetRangeProofRequest{LogId: logId1, StartIndex: 2, EndIndex: 4, TreeSize: 7}
+
+var nodeIdsRangeSize7Index2To4 = []storage.NodeID{
+	testonly.MustCreateNodeIDForTreeCoords(1, 0, 64),
+	testonly.MustCreateNodeIDForTreeCoords(1, 2, 64),
+	testonly.MustCreateNodeIDForTreeCoords(0, 6, 64)}
+
+var getRevisionDiffRequest = trillian.GetRevisionDiffRequest{LogId: logId1, FirstTreeRevision: 3, SecondTreeRevision: 5}
+var getRevisionDiffRequestBadRevisions = trillian.GetRevisionDiffRequest{LogId: logId1, FirstTreeRevision: 5, SecondTreeRevision: 3}
+var getRevisionDiffRequest2 = trillian.GetRevisionDiffRequest{LogId: logId2, FirstTreeRevision: 3, SecondTreeRevision: 5}
+var rootAtRevision3 = trillian.SignedLogRoot{TreeRevision: 3, TreeSize: 4, RootHash: []byte("root3")}
+var rootAtRevision5 = trillian.SignedLogRoot{TreeRevision: 5, TreeSize: 7, RootHash: []byte("root5")}
+var nodeIdsCompactRangeSize4 = []storage.NodeID{testonly.MustCreateNodeIDForTreeCoords(2, 0, 64)}
+
 func mockStorageProviderfunc(mockStorage storage.LogStorage) LogStorageProviderFunc {
+	// The node IDs expected by the proof tests are for trees of the default depth
+	if m, ok := mockStorage.(*storage.MockLogStorage); ok {
+		m.EXPECT().TreeDepth().AnyTimes().Return(storage.DefaultLogTreeDepth)
+	}
+
 	return func(id int64) (storage.LogStorage, error) {
 		if id == 1 {
 			return mockStorage, nil
@@ -106,7 +138,7 @@ func TestGetLeavesByIndexBeginFailsCausesError(t *testing.T) {
 
 	_, err := server.GetLeavesByIndex(context.Background(), &leaf0Request)
 
-	if err == nil || !strings.Contains(err.Error(), "TX") {
+	if terrors.CodeOf(err) != terrors.Backend || !strings.Contains(err.Error(), "TX") {
 		t.Fatalf("Returned wrong error response when begin failed: %v", err)
 	}
 }
@@ -223,6 +255,45 @@ func TestGetLeavesByIndexMultiple(t *testing.T) {
 	}
 }
 
+func TestGetLeavesByIndexOmitExtraData(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+
+	// The mock storage can't skip extra data so the server must remove it
+	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockTx.EXPECT().GetLeavesByIndex([]int64{0}).Return([]trillian.LogLeaf{leaf1}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	request := leaf0Request
+	request.OmitExtraData = true
+	resp, err := server.GetLeavesByIndex(context.Background(), &request)
+
+	if err != nil {
+		t.Fatalf("Failed to get leaf by index: %v", err)
+	}
+
+	if expected, got := trillian.TrillianApiStatusCode_OK, resp.Status.StatusCode; expected != got {
+		t.Fatalf("Expected app level ok status but got: %v", resp.Status.StatusCode)
+	}
+
+	expectedLeaf := expectedLeaf1
+	expectedLeaf.ExtraData = nil
+
+	if len(resp.Leaves) != 1 || !proto.Equal(resp.Leaves[0], &expectedLeaf) {
+		t.Fatalf("Expected leaf: %v but got: %v", expectedLeaf, resp.Leaves)
+	}
+
+	if leaf1.ExtraData == nil {
+		t.Fatalf("Leaf returned by storage was modified")
+	}
+}
+
 func TestQueueLeavesStorageError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -276,6 +347,8 @@ func TestQueueLeaves(t *testing.T) {
 	mockStorage := storage.NewMockLogStorage(ctrl)
 	mockTx := storage.NewMockLogTX(ctrl)
 
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().LeafHashStrategy().Return(trillian.LeafHashStrategy_RFC6962_LEAF_HASH)
 	mockStorage.EXPECT().Begin().Return(mockTx, nil)
 	mockTx.EXPECT().QueueLeaves([]trillian.LogLeaf{leaf1}).Return(nil)
 	mockTx.EXPECT().Commit().Return(nil)
@@ -294,6 +367,58 @@ func TestQueueLeaves(t *testing.T) {
 	}
 }
 
+func TestQueueLeavesComputesMissingHash(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+
+	// The hash should be computed without the RFC 6962 prefix for this tree
+	rawHash := trillian.NewSHA256().Digest([]byte("value"))
+	leaf := trillian.LogLeaf{SequenceNumber: 1, Leaf: trillian.Leaf{LeafHash: rawHash, LeafValue: []byte("value"), ExtraData: []byte("extra")}}
+
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().LeafHashStrategy().Return(trillian.LeafHashStrategy_RAW_LEAF_HASH)
+	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockTx.EXPECT().QueueLeaves([]trillian.LogLeaf{leaf}).Return(nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	request := trillian.QueueLeavesRequest{LogId: logId1, Leaves: []*trillian.LeafProto{{LeafIndex: 1, LeafData: []byte("value"), ExtraData: []byte("extra")}}}
+	resp, err := server.QueueLeaves(context.Background(), &request)
+
+	if err != nil {
+		t.Fatalf("Failed to queue leaf: %v", err)
+	}
+
+	if got, want := resp.Status.StatusCode, trillian.TrillianApiStatusCode_OK; got != want {
+		t.Fatalf("Got status %v, expected %v", got, want)
+	}
+}
+
+func TestQueueLeavesWrongHashRejected(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+
+	// leaf1 has an RFC 6962 hash so it doesn't match in a raw tree. No transaction should be
+	// started for it.
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().LeafHashStrategy().Return(trillian.LeafHashStrategy_RAW_LEAF_HASH)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	resp, err := server.QueueLeaves(context.Background(), &queueRequest0)
+
+	if err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_ERROR {
+		t.Fatalf("Allowed leaf with wrong hash to be queued: %v %v", resp, err)
+	}
+}
+
 func TestQueueLeavesNoLeavesRejected(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -413,6 +538,159 @@ func TestGetLatestSignedLogRoot(t *testing.T) {
 	}
 }
 
+func TestGetLatestSignedLogRootsRejectsBadRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	for _, logIDs := range [][]int64{nil, make([]int64, maxLogRootsPerRequest+1)} {
+		_, err := server.GetLatestSignedLogRoots(context.Background(), &trillian.GetLatestSignedLogRootsRequest{LogIds: logIDs})
+
+		if terrors.CodeOf(err) != terrors.InvalidRange {
+			t.Fatalf("get latest roots accepted %d log IDs: %v", len(logIDs), err)
+		}
+	}
+}
+
+func TestGetLatestSignedLogRoots(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+
+	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedLogRoot().Return(signedRoot1, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	// Log 2 doesn't exist, which shouldn't stop log 1's root being returned
+	resp, err := server.GetLatestSignedLogRoots(context.Background(), &trillian.GetLatestSignedLogRootsRequest{LogIds: []int64{logId2, logId1}})
+
+	if err != nil {
+		t.Fatalf("Failed to get log roots: %v", err)
+	}
+
+	if expected, got := trillian.TrillianApiStatusCode_OK, resp.Status.StatusCode; expected != got {
+		t.Fatalf("Expected app level ok status but got: %v", resp.Status.StatusCode)
+	}
+
+	if len(resp.Results) != 2 {
+		t.Fatalf("Got %d results, expected 2", len(resp.Results))
+	}
+
+	if got := resp.Results[0]; got.LogId != logId2 || got.Status.StatusCode != trillian.TrillianApiStatusCode_ERROR || got.SignedLogRoot != nil {
+		t.Errorf("Got result %v for an unknown log, expected an error", got)
+	}
+
+	if got := resp.Results[1]; got.LogId != logId1 || got.Status.StatusCode != trillian.TrillianApiStatusCode_OK || !proto.Equal(&signedRoot1, got.SignedLogRoot) {
+		t.Errorf("Got result %v, expected log root %v", got, signedRoot1)
+	}
+}
+
+func TestGetLatestSignedLogRootAndLeavesRejectsBadRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	for _, maxLeaves := range []int64{-1, 0, maxLatestLeaves + 1} {
+		_, err := server.GetLatestSignedLogRootAndLeaves(context.Background(), &trillian.GetLatestSignedLogRootAndLeavesRequest{LogId: logId1, MaxLeaves: maxLeaves})
+
+		if terrors.CodeOf(err) != terrors.InvalidRange {
+			t.Fatalf("get latest root and leaves accepted max leaves %d: %v", maxLeaves, err)
+		}
+	}
+}
+
+func TestGetLatestSignedLogRootAndLeaves(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	leaf5 := trillian.LogLeaf{SequenceNumber: 5, Leaf: trillian.Leaf{LeafHash: []byte("hash5"), LeafValue: []byte("value5"), ExtraData: []byte("extra5")}}
+	leaf6 := trillian.LogLeaf{SequenceNumber: 6, Leaf: trillian.Leaf{LeafHash: []byte("hash6"), LeafValue: []byte("value6"), ExtraData: []byte("extra6")}}
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyLogTX(ctrl)
+
+	// Both must be read from the same snapshot, the tree size of signedRoot1 is 7
+	mockStorage.EXPECT().Snapshot().Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedLogRoot().Return(signedRoot1, nil)
+	mockTx.EXPECT().GetLeavesByIndex([]int64{5, 6}).Return([]trillian.LogLeaf{leaf5, leaf6}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	resp, err := server.GetLatestSignedLogRootAndLeaves(context.Background(), &trillian.GetLatestSignedLogRootAndLeavesRequest{LogId: logId1, MaxLeaves: 2})
+
+	if err != nil {
+		t.Fatalf("Failed to get log root and leaves: %v", err)
+	}
+
+	if expected, got := trillian.TrillianApiStatusCode_OK, resp.Status.StatusCode; expected != got {
+		t.Fatalf("Expected app level ok status but got: %v", resp.Status.StatusCode)
+	}
+
+	if !proto.Equal(&signedRoot1, resp.SignedLogRoot) {
+		t.Errorf("Log root proto mismatch:\n%v\n%v", signedRoot1, resp.SignedLogRoot)
+	}
+
+	if len(resp.Leaves) != 2 || !proto.Equal(leafToProto(leaf5), resp.Leaves[0]) || !proto.Equal(leafToProto(leaf6), resp.Leaves[1]) {
+		t.Errorf("Got leaves %v, expected %v and %v", resp.Leaves, leaf5, leaf6)
+	}
+}
+
+func TestGetLatestSignedLogRootAndLeavesEmptyTree(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyLogTX(ctrl)
+
+	// There are no leaves to read so storage shouldn't be asked for any
+	mockStorage.EXPECT().Snapshot().Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedLogRoot().Return(trillian.SignedLogRoot{}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	resp, err := server.GetLatestSignedLogRootAndLeaves(context.Background(), &trillian.GetLatestSignedLogRootAndLeavesRequest{LogId: logId1, MaxLeaves: 10})
+
+	if err != nil {
+		t.Fatalf("Failed to get log root and leaves: %v", err)
+	}
+
+	if len(resp.Leaves) != 0 {
+		t.Errorf("Got %d leaves for an empty tree", len(resp.Leaves))
+	}
+}
+
+func TestGetLatestSignedLogRootAndLeavesMissingLeaves(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyLogTX(ctrl)
+
+	mockStorage.EXPECT().Snapshot().Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedLogRoot().Return(signedRoot1, nil)
+	mockTx.EXPECT().GetLeavesByIndex([]int64{0, 1, 2, 3, 4, 5, 6}).Return([]trillian.LogLeaf{leaf1, leaf3}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	// The tree is smaller than max leaves so all of it is requested
+	_, err := server.GetLatestSignedLogRootAndLeaves(context.Background(), &trillian.GetLatestSignedLogRootAndLeavesRequest{LogId: logId1, MaxLeaves: 10})
+
+	if terrors.CodeOf(err) != terrors.Integrity {
+		t.Fatalf("Expected an integrity error for missing leaves but got: %v", err)
+	}
+}
+
 func TestGetLeavesByHashInvalidHash(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -527,6 +805,108 @@ func TestGetLeavesByHash(t *testing.T) {
 	}
 }
 
+func TestGetLeavesByTimestampInvalidRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Requests should fail validation before any storage operations
+	mockStorage := storage.NewMockLogStorage(ctrl)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	for _, req := range []trillian.GetLeavesByTimestampRequest{getByTimestampRequestBadRange, getByTimestampRequestBadMaxLeaves} {
+		resp, err := server.GetLeavesByTimestamp(context.Background(), &req)
+
+		if err != nil {
+			t.Fatalf("Request failed with unexpected error: %v", err)
+		}
+
+		if expected, got := trillian.TrillianApiStatusCode_ERROR, resp.Status.StatusCode; expected != got {
+			t.Fatalf("Expected app level error status for %v but got: %v", req, resp.Status.StatusCode)
+		}
+	}
+}
+
+func TestGetLeavesByTimestampStorageFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newParameterizedTest(ctrl, "GetLeavesByTimestamp",
+		func(t *storage.MockLogTX) {
+			t.EXPECT().GetLeavesByTimestamp(int64(1000), int64(2000), int64(1), 10).Return([]trillian.LogLeaf{}, errors.New("STORAGE"))
+		},
+		func(s *TrillianLogServer) error {
+			_, err := s.GetLeavesByTimestamp(context.Background(), &getByTimestampRequest1)
+			return err
+		})
+
+	test.executeStorageFailureTest(t)
+}
+
+func TestGetLeavesByTimestampCommitFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newParameterizedTest(ctrl, "GetLeavesByTimestamp",
+		func(t *storage.MockLogTX) {
+			t.EXPECT().GetLeavesByTimestamp(int64(1000), int64(2000), int64(1), 10).Return([]trillian.LogLeaf{}, nil)
+		},
+		func(s *TrillianLogServer) error {
+			_, err := s.GetLeavesByTimestamp(context.Background(), &getByTimestampRequest1)
+			return err
+		})
+
+	test.executeCommitFailsTest(t)
+}
+
+func TestGetLeavesByTimestampInvalidLogId(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newParameterizedTest(ctrl, "GetLeavesByTimestamp",
+		func(t *storage.MockLogTX) {},
+		func(s *TrillianLogServer) error {
+			_, err := s.GetLeavesByTimestamp(context.Background(), &getByTimestampRequest2)
+			return err
+		})
+
+	test.executeInvalidLogIDTest(t)
+}
+
+func TestGetLeavesByTimestamp(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+
+	integratedLeaf := leaf3
+	integratedLeaf.IntegrateTimestampNanos = 1500
+
+	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockTx.EXPECT().GetLeavesByTimestamp(int64(1000), int64(2000), int64(1), 10).Return([]trillian.LogLeaf{integratedLeaf}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	resp, err := server.GetLeavesByTimestamp(context.Background(), &getByTimestampRequest1)
+
+	if err != nil {
+		t.Fatalf("Got error trying to get leaves by timestamp: %v", err)
+	}
+
+	if expected, got := trillian.TrillianApiStatusCode_OK, resp.Status.StatusCode; expected != got {
+		t.Fatalf("Expected app level ok status but got: %v", resp.Status.StatusCode)
+	}
+
+	expectedLeaf := expectedLeaf3
+	expectedLeaf.IntegrateTimestampNanos = 1500
+
+	if len(resp.Leaves) != 1 || !proto.Equal(resp.Leaves[0], &expectedLeaf) {
+		t.Fatalf("Expected leaf %v but got: %v", expectedLeaf, resp.Leaves)
+	}
+}
+
 func TestGetProofByHashBadTreeSize(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -538,7 +918,7 @@ func TestGetProofByHashBadTreeSize(t *testing.T) {
 
 	_, err := server.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequestBadTreeSize)
 
-	if err == nil {
+	if terrors.CodeOf(err) != terrors.InvalidRange {
 		t.Fatalf("get inclusion proof by hash accepted invalid tree size: %v", getInclusionProofByHashRequestBadTreeSize)
 	}
 }
@@ -554,7 +934,7 @@ func TestGetProofByHashBadHash(t *testing.T) {
 
 	_, err := server.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequestBadHash)
 
-	if err == nil {
+	if terrors.CodeOf(err) != terrors.InvalidRange {
 		t.Fatalf("get inclusion proof by hash accepted invalid leaf hash: %v", getInclusionProofByHashRequestBadHash)
 	}
 }
@@ -642,7 +1022,7 @@ func TestGetProofByHashWrongNodeCountFetched(t *testing.T) {
 
 	_, err := server.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequest7)
 
-	if err == nil || !strings.Contains(err.Error(), "expected 3 nodes") {
+	if terrors.CodeOf(err) != terrors.Integrity || !strings.Contains(err.Error(), "expected 3 nodes") {
 		t.Fatalf("get inclusion proof by hash returned no or wrong error when get nodes returns wrong count: %v", err)
 	}
 }
@@ -665,7 +1045,7 @@ func TestGetProofByHashWrongNodeReturned(t *testing.T) {
 
 	_, err := server.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequest7)
 
-	if err == nil || !strings.Contains(err.Error(), "expected node") || !strings.Contains(err.Error(), "at proof pos 1") {
+	if terrors.CodeOf(err) != terrors.Integrity || !strings.Contains(err.Error(), "expected node") || !strings.Contains(err.Error(), "at proof pos 1") {
 		t.Fatalf("get inclusion proof by hash returned no or wrong error when get nodes returns wrong count: %v", err)
 	}
 }
@@ -688,7 +1068,7 @@ func TestGetProofByHashCommitFails(t *testing.T) {
 	test.executeCommitFailsTest(t)
 }
 
-func TestGetProofByHash(t *testing.T) {
+func TestGetProofByHashSkipsLeavesBeyondTreeSize(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
@@ -696,8 +1076,9 @@ func TestGetProofByHash(t *testing.T) {
 	mockTx := storage.NewMockLogTX(ctrl)
 	mockStorage.EXPECT().Begin().Return(mockTx, nil)
 
+	// The duplicate at index 9 is not in the tree of size 7 so has no proof
 	mockTx.EXPECT().GetTreeRevisionAtSize(getInclusionProofByHashRequest7.TreeSize).Return(int64(3), nil)
-	mockTx.EXPECT().GetLeavesByHash([]trillian.Hash{[]byte("ahash")}, false).Return([]trillian.LogLeaf{{SequenceNumber: 2}}, nil)
+	mockTx.EXPECT().GetLeavesByHash([]trillian.Hash{[]byte("ahash")}, false).Return([]trillian.LogLeaf{{SequenceNumber: 2}, {SequenceNumber: 9}}, nil)
 	mockTx.EXPECT().GetMerkleNodes(int64(3), nodeIdsInclusionSize7Index2).Return([]storage.Node{
 		{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")},
 		{NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2, Hash: []byte("nodehash1")},
@@ -712,13 +1093,46 @@ func TestGetProofByHash(t *testing.T) {
 		t.Fatalf("get inclusion proof by hash should have succeeded but we got: %v", err)
 	}
 
-	if proofResponse == nil || proofResponse.Status == nil || proofResponse.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
-		t.Fatalf("server response was not successful: %v", proofResponse)
+	if got, want := len(proofResponse.Proof), 1; got != want {
+		t.Fatalf("got %d proofs, expected %d", got, want)
 	}
 
-	nodeIDBytes1, err1 := proto.Marshal(nodeIdsInclusionSize7Index2[0].AsProto())
-	nodeIDBytes2, err2 := proto.Marshal(nodeIdsInclusionSize7Index2[1].AsProto())
-	nodeIDBytes3, err3 := proto.Marshal(nodeIdsInclusionSize7Index2[2].AsProto())
+	if got, want := proofResponse.Proof[0].LeafIndex, int64(2); got != want {
+		t.Fatalf("got proof for leaf %d, expected %d", got, want)
+	}
+}
+
+func TestGetProofByHash(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+
+	mockTx.EXPECT().GetTreeRevisionAtSize(getInclusionProofByHashRequest7.TreeSize).Return(int64(3), nil)
+	mockTx.EXPECT().GetLeavesByHash([]trillian.Hash{[]byte("ahash")}, false).Return([]trillian.LogLeaf{{SequenceNumber: 2}}, nil)
+	mockTx.EXPECT().GetMerkleNodes(int64(3), nodeIdsInclusionSize7Index2).Return([]storage.Node{
+		{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")},
+		{NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2, Hash: []byte("nodehash1")},
+		{NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3, Hash: []byte("nodehash2")}}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	proofResponse, err := server.GetInclusionProofByHash(context.Background(), &getInclusionProofByHashRequest7)
+
+	if err != nil {
+		t.Fatalf("get inclusion proof by hash should have succeeded but we got: %v", err)
+	}
+
+	if proofResponse == nil || proofResponse.Status == nil || proofResponse.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		t.Fatalf("server response was not successful: %v", proofResponse)
+	}
+
+	nodeIDBytes1, err1 := proto.Marshal(nodeIdsInclusionSize7Index2[0].AsProto())
+	nodeIDBytes2, err2 := proto.Marshal(nodeIdsInclusionSize7Index2[1].AsProto())
+	nodeIDBytes3, err3 := proto.Marshal(nodeIdsInclusionSize7Index2[2].AsProto())
 
 	if err1 != nil || err2 != nil || err3 != nil {
 		t.Fatalf("failed to marshall test protos - should not happen: %v %v %v", err1, err2, err3)
@@ -745,7 +1159,7 @@ func TestGetProofByIndexBadTreeSize(t *testing.T) {
 
 	_, err := server.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequestBadTreeSize)
 
-	if err == nil {
+	if terrors.CodeOf(err) != terrors.InvalidRange {
 		t.Fatalf("get inclusion proof by index accepted invalid tree size: %v", getInclusionProofByHashRequestBadTreeSize)
 	}
 }
@@ -760,7 +1174,7 @@ func TestGetProofByIndexBadIndex(t *testing.T) {
 
 	_, err := server.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequestBadLeafIndex)
 
-	if err == nil {
+	if terrors.CodeOf(err) != terrors.InvalidRange {
 		t.Fatalf("get inclusion proof by index accepted invalid leaf index: %v", getInclusionProofByIndexRequestBadLeafIndex)
 	}
 }
@@ -776,7 +1190,7 @@ func TestGetProofByIndexBadIndexRange(t *testing.T) {
 
 	_, err := server.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequestBadLeafIndexRange)
 
-	if err == nil {
+	if terrors.CodeOf(err) != terrors.InvalidRange {
 		t.Fatalf("get inclusion proof by index accepted invalid leaf index (outside tree size): %v", getInclusionProofByIndexRequestBadLeafIndexRange)
 	}
 }
@@ -845,7 +1259,7 @@ func TestGetProofByIndexWrongNodeCountFetched(t *testing.T) {
 
 	_, err := server.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequest7)
 
-	if err == nil || !strings.Contains(err.Error(), "expected 3 nodes") {
+	if terrors.CodeOf(err) != terrors.Integrity || !strings.Contains(err.Error(), "expected 3 nodes") {
 		t.Fatalf("get inclusion proof by index returned no or wrong error when get nodes returns wrong count: %v", err)
 	}
 }
@@ -867,7 +1281,7 @@ func TestGetProofByIndexWrongNodeReturned(t *testing.T) {
 
 	_, err := server.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequest7)
 
-	if err == nil || !strings.Contains(err.Error(), "expected node") || !strings.Contains(err.Error(), "at proof pos 1") {
+	if terrors.CodeOf(err) != terrors.Integrity || !strings.Contains(err.Error(), "expected node") || !strings.Contains(err.Error(), "at proof pos 1") {
 		t.Fatalf("get inclusion proof by index returned no or wrong error when get nodes returns wrong count: %v", err)
 	}
 }
@@ -934,6 +1348,132 @@ func TestGetProofByIndex(t *testing.T) {
 	}
 }
 
+// proofReaderTX is a LogTX that also implements storage.ProofReader with canned results
+type proofReaderTX struct {
+	*storage.MockLogTX
+	// nodes is returned for any proof
+	nodes []storage.Node
+	// calls records the arguments of each proof request
+	calls [][]int64
+}
+
+func (p *proofReaderTX) GetInclusionProofNodes(treeRevision, treeSize, leafIndex int64) ([]storage.Node, error) {
+	p.calls = append(p.calls, []int64{treeRevision, treeSize, leafIndex})
+	return p.nodes, nil
+}
+
+func (p *proofReaderTX) GetConsistencyProofNodes(treeRevision, previousTreeSize, treeSize int64) ([]storage.Node, error) {
+	p.calls = append(p.calls, []int64{treeRevision, previousTreeSize, treeSize})
+	return p.nodes, nil
+}
+
+func TestGetProofByIndexUsesProofReader(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	tx := &proofReaderTX{MockLogTX: mockTx, nodes: []storage.Node{
+		{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")},
+		{NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2, Hash: []byte("nodehash1")},
+		{NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3, Hash: []byte("nodehash2")}}}
+	mockStorage.EXPECT().Begin().Return(tx, nil)
+
+	// GetMerkleNodes must not be called
+	mockTx.EXPECT().GetTreeRevisionAtSize(getInclusionProofByIndexRequest7.TreeSize).Return(int64(3), nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	proofResponse, err := server.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequest7)
+
+	if err != nil {
+		t.Fatalf("get inclusion proof by index should have succeeded but we got: %v", err)
+	}
+
+	if got, want := tx.calls, [][]int64{{3, 7, 2}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got proof requests %v, expected %v", got, want)
+	}
+
+	if got, want := len(proofResponse.Proof.ProofNode), 3; got != want {
+		t.Fatalf("Got %d proof nodes, expected %d", got, want)
+	}
+
+	// The nodes are still checked against the ones the proof needs
+	tx.nodes[0], tx.nodes[1] = tx.nodes[1], tx.nodes[0]
+	mockStorage.EXPECT().Begin().Return(tx, nil)
+	mockTx.EXPECT().GetTreeRevisionAtSize(getInclusionProofByIndexRequest7.TreeSize).Return(int64(3), nil)
+	mockTx.EXPECT().Rollback().Return(nil)
+
+	if _, err := server.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequest7); err == nil {
+		t.Fatal("get inclusion proof by index succeeded with nodes in the wrong order")
+	}
+}
+
+func TestGetConsistencyProofUsesProofReader(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	tx := &proofReaderTX{MockLogTX: mockTx, nodes: []storage.Node{{NodeID: nodeIdsConsistencySize4ToSize7[0], NodeRevision: 3, Hash: []byte("nodehash")}}}
+	mockStorage.EXPECT().Begin().Return(tx, nil)
+
+	mockTx.EXPECT().GetTreeRevisionAtSize(getConsistencyProofRequest7.FirstTreeSize).Return(int64(3), nil)
+	mockTx.EXPECT().GetTreeRevisionAtSize(getConsistencyProofRequest7.SecondTreeSize).Return(int64(5), nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	response, err := server.GetConsistencyProof(context.Background(), &getConsistencyProofRequest7)
+
+	if err != nil {
+		t.Fatalf("failed to get consistency proof: %v", err)
+	}
+
+	if got, want := tx.calls, [][]int64{{5, 4, 7}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got proof requests %v, expected %v", got, want)
+	}
+
+	if got, want := len(response.Proof.ProofNode), 1; got != want {
+		t.Fatalf("Got %d proof nodes, expected %d", got, want)
+	}
+}
+
+func TestGetProofByIndexSingleQueryProofsOff(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	nodes := []storage.Node{
+		{NodeID: nodeIdsInclusionSize7Index2[0], NodeRevision: 3, Hash: []byte("nodehash0")},
+		{NodeID: nodeIdsInclusionSize7Index2[1], NodeRevision: 2, Hash: []byte("nodehash1")},
+		{NodeID: nodeIdsInclusionSize7Index2[2], NodeRevision: 3, Hash: []byte("nodehash2")}}
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	tx := &proofReaderTX{MockLogTX: mockTx, nodes: nodes}
+	mockStorage.EXPECT().Begin().Return(tx, nil)
+
+	// With the feature off for this log the nodes are read one by one
+	mockTx.EXPECT().GetTreeRevisionAtSize(getInclusionProofByIndexRequest7.TreeSize).Return(int64(3), nil)
+	mockTx.EXPECT().GetMerkleNodes(int64(3), nodeIdsInclusionSize7Index2).Return(nodes, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	features := util.NewFeatures(LogServerFeatures...)
+	features.Set(FeatureSingleQueryProofs, 0, true)
+	features.Set(FeatureSingleQueryProofs, getInclusionProofByIndexRequest7.LogId, false)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	server.SetFeatures(features)
+
+	if _, err := server.GetInclusionProof(context.Background(), &getInclusionProofByIndexRequest7); err != nil {
+		t.Fatalf("get inclusion proof by index should have succeeded but we got: %v", err)
+	}
+
+	if len(tx.calls) != 0 {
+		t.Errorf("Got proof requests %v with single query proofs off", tx.calls)
+	}
+}
+
 func TestGetEntryAndProofBadTreeSize(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -945,7 +1485,7 @@ func TestGetEntryAndProofBadTreeSize(t *testing.T) {
 
 	_, err := server.GetEntryAndProof(context.Background(), &getEntryAndProofRequestBadTreeSize)
 
-	if err == nil {
+	if terrors.CodeOf(err) != terrors.InvalidRange {
 		t.Fatalf("get entry and proof accepted invalid tree size")
 	}
 }
@@ -961,7 +1501,7 @@ func TestGetEntryAndProofBadLeafIndex(t *testing.T) {
 
 	_, err := server.GetEntryAndProof(context.Background(), &getEntryAndProofRequestBadLeafIndex)
 
-	if err == nil {
+	if terrors.CodeOf(err) != terrors.InvalidRange {
 		t.Fatalf("get entry and proof accepted invalid leaf index")
 	}
 }
@@ -977,7 +1517,7 @@ func TestGetEntryAndProofBadLeafIndexRange(t *testing.T) {
 
 	_, err := server.GetEntryAndProof(context.Background(), &getEntryAndProofRequestBadLeafIndexRange)
 
-	if err == nil {
+	if terrors.CodeOf(err) != terrors.InvalidRange {
 		t.Fatalf("get entry and proof accepted invalid leaf index (out of range)")
 	}
 }
@@ -1085,7 +1625,7 @@ func TestGetEntryAndProofGetLeavesReturnsMultiple(t *testing.T) {
 
 	_, err := server.GetEntryAndProof(context.Background(), &getEntryAndProofRequest7)
 
-	if err == nil || !strings.Contains(err.Error(), "expected one leaf") {
+	if terrors.CodeOf(err) != terrors.Integrity || !strings.Contains(err.Error(), "expected one leaf") {
 		t.Fatalf("get entry and proof returned no or wrong error when storage returns multiple leaves: %v", err)
 	}
 }
@@ -1237,6 +1777,100 @@ func TestGetSequencedLeafCount(t *testing.T) {
 	}
 }
 
+func TestGetTreeMetadataStorageFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newParameterizedTest(ctrl, "GetTreeMetadata",
+		func(t *storage.MockLogTX) {
+			t.EXPECT().GetTreeMetadata().Return(trillian.TreeMetadata{}, errors.New("STORAGE"))
+		},
+		func(s *TrillianLogServer) error {
+			_, err := s.GetTreeMetadata(context.Background(), &trillian.GetTreeMetadataRequest{LogId: logId1})
+			return err
+		})
+
+	test.executeStorageFailureTest(t)
+}
+
+func TestGetTreeMetadataCommitFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newParameterizedTest(ctrl, "GetTreeMetadata",
+		func(t *storage.MockLogTX) {
+			t.EXPECT().GetTreeMetadata().Return(trillian.TreeMetadata{DisplayName: "log"}, nil)
+		},
+		func(s *TrillianLogServer) error {
+			_, err := s.GetTreeMetadata(context.Background(), &trillian.GetTreeMetadataRequest{LogId: logId1})
+			return err
+		})
+
+	test.executeCommitFailsTest(t)
+}
+
+func TestGetTreeMetadata(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metadata := trillian.TreeMetadata{DisplayName: "log", Description: "a log", OwnerContact: "owner@example.com", CreateTimeNanos: 1000}
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+
+	mockTx.EXPECT().GetTreeMetadata().Return(metadata, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockStorage.EXPECT().HashAlgorithm().Return(trillian.HashAlgorithm_SHA512_256)
+	mockStorage.EXPECT().LeafHashStrategy().Return(trillian.LeafHashStrategy_RAW_LEAF_HASH)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	response, err := server.GetTreeMetadata(context.Background(), &trillian.GetTreeMetadataRequest{LogId: logId1})
+
+	if err != nil {
+		t.Fatalf("expected no error getting tree metadata but got: %v", err)
+	}
+
+	if response.Status == nil || response.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		t.Fatalf("server response was not successful: %v", response)
+	}
+
+	if !proto.Equal(response.Metadata, &metadata) {
+		t.Fatalf("expected tree metadata: %v but got: %v", metadata, response.Metadata)
+	}
+
+	if response.HashAlgorithm != trillian.HashAlgorithm_SHA512_256 || response.LeafHashStrategy != trillian.LeafHashStrategy_RAW_LEAF_HASH {
+		t.Fatalf("expected the tree's hashing to be returned but got: %v", response)
+	}
+}
+
+func TestGetTreeMetadataResponseRoundTrip(t *testing.T) {
+	response := trillian.GetTreeMetadataResponse{
+		Status:           buildStatus(trillian.TrillianApiStatusCode_OK),
+		Metadata:         &trillian.TreeMetadata{DisplayName: "log"},
+		HashAlgorithm:    trillian.HashAlgorithm_SHA3_256,
+		LeafHashStrategy: trillian.LeafHashStrategy_RAW_LEAF_HASH,
+	}
+
+	data, err := proto.Marshal(&response)
+
+	if err != nil {
+		t.Fatalf("failed to marshal response: %v", err)
+	}
+
+	var got trillian.GetTreeMetadataResponse
+
+	if err := proto.Unmarshal(data, &got); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+
+	// proto.Equal only compares the fields the descriptor knows about, so check these directly
+	if got.HashAlgorithm != response.HashAlgorithm || got.LeafHashStrategy != response.LeafHashStrategy || !proto.Equal(&got, &response) {
+		t.Fatalf("response changed on the wire, sent %v but got %v", response, got)
+	}
+}
+
 func TestGetConsistencyProofRejectsBadRequests(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -1247,7 +1881,7 @@ func TestGetConsistencyProofRejectsBadRequests(t *testing.T) {
 	for _, request := range []trillian.GetConsistencyProofRequest{getConsistencyProofRequestBadFirstTreeSize, getConsistencyProofRequestBadSecondTreeSize, getConsistencyProofRequestBadRange} {
 		_, err := server.GetConsistencyProof(context.Background(), &request)
 
-		if err == nil {
+		if terrors.CodeOf(err) != terrors.InvalidRange {
 			t.Fatalf("get consistency proof accepted invalid request: %v", request)
 		}
 	}
@@ -1336,7 +1970,7 @@ func TestGetConsistencyProofGetNodesReturnsWrongCount(t *testing.T) {
 
 	_, err := server.GetConsistencyProof(context.Background(), &getConsistencyProofRequest7)
 
-	if err == nil || !strings.Contains(err.Error(), "expected 1 nodes") {
+	if terrors.CodeOf(err) != terrors.Integrity || !strings.Contains(err.Error(), "expected 1 nodes") {
 		t.Fatalf("get consistency proof returned no or wrong error when get nodes returns wrong count: %v", err)
 	}
 }
@@ -1422,6 +2056,245 @@ func TestGetConsistencyProof(t *testing.T) {
 	}
 }
 
+func TestGetRangeProofRejectsBadRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	for _, request := range []trillian.GetRangeProofRequest{
+		{LogId: logId1, StartIndex: 0, EndIndex: 1, TreeSize: 0},
+		{LogId: logId1, StartIndex: -1, EndIndex: 4, TreeSize: 7},
+		{LogId: logId1, StartIndex: 4, EndIndex: 4, TreeSize: 7},
+		{LogId: logId1, StartIndex: 4, EndIndex: 2, TreeSize: 7},
+		{LogId: logId1, StartIndex: 2, EndIndex: 8, TreeSize: 7},
+	} {
+		_, err := server.GetRangeProof(context.Background(), &request)
+
+		if terrors.CodeOf(err) != terrors.InvalidRange {
+			t.Fatalf("get range proof accepted invalid request: %v", request)
+		}
+	}
+}
+
+func TestGetRangeProofGetNodesReturnsWrongCount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+
+	mockTx.EXPECT().GetTreeRevisionAtSize(getRangeProofRequest7.TreeSize).Return(int64(5), nil)
+	mockTx.EXPECT().GetMerkleNodes(int64(5), nodeIdsRangeSize7Index2To4).Return([]storage.Node{{NodeID: nodeIdsRangeSize7Index2To4[0], NodeRevision: 3, Hash: []byte("nodehash")}}, nil)
+	mockTx.EXPECT().Rollback().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	if _, err := server.GetRangeProof(context.Background(), &getRangeProofRequest7); terrors.CodeOf(err) != terrors.Integrity {
+		t.Fatalf("get range proof returned %v for a proof with missing nodes, expected an Integrity error", err)
+	}
+}
+
+func TestGetRangeProof(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+
+	var nodes []storage.Node
+	var expectedNodes []*trillian.NodeProto
+
+	for i, id := range nodeIdsRangeSize7Index2To4 {
+		hash := []byte(fmt.Sprintf("nodehash%d", i))
+		nodes = append(nodes, storage.Node{NodeID: id, NodeRevision: 3, Hash: hash})
+
+		nodeIDBytes, err := proto.Marshal(id.AsProto())
+
+		if err != nil {
+			t.Fatalf("failed to marshall test proto - should not happen: %v ", err)
+		}
+
+		expectedNodes = append(expectedNodes, &trillian.NodeProto{NodeId: nodeIDBytes, NodeHash: hash, NodeRevision: 3})
+	}
+
+	mockTx.EXPECT().GetTreeRevisionAtSize(getRangeProofRequest7.TreeSize).Return(int64(5), nil)
+	mockTx.EXPECT().GetMerkleNodes(int64(5), nodeIdsRangeSize7Index2To4).Return(nodes, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	response, err := server.GetRangeProof(context.Background(), &getRangeProofRequest7)
+
+	if err != nil {
+		t.Fatalf("failed to get range proof: %v", err)
+	}
+
+	if expected, got := trillian.TrillianApiStatusCode_OK, response.Status.StatusCode; expected != got {
+		t.Fatalf("Expected app level ok status but got: %v", response.Status.StatusCode)
+	}
+
+	// The proof's leaf index is the start of the range
+	expectedProof := trillian.ProofProto{LeafIndex: 2, ProofNode: expectedNodes}
+
+	if !proto.Equal(response.Proof, &expectedProof) {
+		t.Fatalf("expected proof: %v but got: %v", expectedProof, response.Proof)
+	}
+}
+
+func TestGetRevisionDiffBadRevisions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Request should fail validation before any storage operations
+	mockStorage := storage.NewMockLogStorage(ctrl)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	resp, err := server.GetRevisionDiff(context.Background(), &getRevisionDiffRequestBadRevisions)
+
+	if err != nil {
+		t.Fatalf("Request failed with unexpected error: %v", err)
+	}
+
+	if expected, got := trillian.TrillianApiStatusCode_ERROR, resp.Status.StatusCode; expected != got {
+		t.Fatalf("Expected app level error status but got: %v", resp.Status.StatusCode)
+	}
+}
+
+func TestGetRevisionDiffStorageFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newParameterizedTest(ctrl, "GetRevisionDiff",
+		func(t *storage.MockLogTX) {
+			t.EXPECT().GetSignedLogRootAtRevision(int64(3)).Return(trillian.SignedLogRoot{}, errors.New("STORAGE"))
+		},
+		func(s *TrillianLogServer) error {
+			_, err := s.GetRevisionDiff(context.Background(), &getRevisionDiffRequest)
+			return err
+		})
+
+	test.executeStorageFailureTest(t)
+}
+
+func TestGetRevisionDiffCommitFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newParameterizedTest(ctrl, "GetRevisionDiff",
+		func(t *storage.MockLogTX) {
+			t.EXPECT().GetSignedLogRootAtRevision(int64(3)).Return(rootAtRevision3, nil)
+			t.EXPECT().GetSignedLogRootAtRevision(int64(5)).Return(rootAtRevision3, nil)
+			t.EXPECT().GetMerkleNodes(gomock.Any(), nodeIdsCompactRangeSize4).Times(2).Return([]storage.Node{}, nil)
+		},
+		func(s *TrillianLogServer) error {
+			_, err := s.GetRevisionDiff(context.Background(), &getRevisionDiffRequest)
+			return err
+		})
+
+	test.executeCommitFailsTest(t)
+}
+
+func TestGetRevisionDiffInvalidLogId(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	test := newParameterizedTest(ctrl, "GetRevisionDiff",
+		func(t *storage.MockLogTX) {},
+		func(s *TrillianLogServer) error {
+			_, err := s.GetRevisionDiff(context.Background(), &getRevisionDiffRequest2)
+			return err
+		})
+
+	test.executeInvalidLogIDTest(t)
+}
+
+func TestGetRevisionDiff(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+
+	// The subtree covering the first 4 leaves has changed, which should never happen
+	mockTx.EXPECT().GetSignedLogRootAtRevision(int64(3)).Return(rootAtRevision3, nil)
+	mockTx.EXPECT().GetSignedLogRootAtRevision(int64(5)).Return(rootAtRevision5, nil)
+	mockTx.EXPECT().GetMerkleNodes(int64(3), nodeIdsCompactRangeSize4).Return([]storage.Node{{NodeID: nodeIdsCompactRangeSize4[0], NodeRevision: 2, Hash: []byte("hash3")}}, nil)
+	mockTx.EXPECT().GetMerkleNodes(int64(5), nodeIdsCompactRangeSize4).Return([]storage.Node{{NodeID: nodeIdsCompactRangeSize4[0], NodeRevision: 4, Hash: []byte("hash5")}}, nil)
+	mockTx.EXPECT().GetMerkleNodes(int64(5), nodeIdsConsistencySize4ToSize7).Return([]storage.Node{{NodeID: nodeIdsConsistencySize4ToSize7[0], NodeRevision: 5, Hash: []byte("nodehash")}}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	resp, err := server.GetRevisionDiff(context.Background(), &getRevisionDiffRequest)
+
+	if err != nil {
+		t.Fatalf("failed to get revision diff: %v", err)
+	}
+
+	if expected, got := trillian.TrillianApiStatusCode_OK, resp.Status.StatusCode; expected != got {
+		t.Fatalf("Expected app level ok status but got: %v", resp.Status.StatusCode)
+	}
+
+	if !proto.Equal(resp.FirstSignedLogRoot, &rootAtRevision3) || !proto.Equal(resp.SecondSignedLogRoot, &rootAtRevision5) {
+		t.Fatalf("Got roots %v and %v, expected %v and %v", resp.FirstSignedLogRoot, resp.SecondSignedLogRoot, rootAtRevision3, rootAtRevision5)
+	}
+
+	nodeIDBytes, err := proto.Marshal(nodeIdsCompactRangeSize4[0].AsProto())
+
+	if err != nil {
+		t.Fatalf("failed to marshall test proto - should not happen: %v ", err)
+	}
+
+	expectedDiff := trillian.NodeDiffProto{NodeId: nodeIDBytes, FirstNodeHash: []byte("hash3"), SecondNodeHash: []byte("hash5")}
+
+	if got, want := resp.NodesCompared, int64(1); got != want {
+		t.Fatalf("Got %d nodes compared, expected %d", got, want)
+	}
+
+	if len(resp.NodeDiff) != 1 || !proto.Equal(resp.NodeDiff[0], &expectedDiff) {
+		t.Fatalf("Expected node diff %v but got: %v", expectedDiff, resp.NodeDiff)
+	}
+
+	if resp.Proof == nil || len(resp.Proof.ProofNode) != 1 {
+		t.Fatalf("Expected a consistency proof with one node but got: %v", resp.Proof)
+	}
+}
+
+func TestGetRevisionDiffNoDifferences(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+
+	// The tree did not grow so there's no consistency proof to fetch
+	node := storage.Node{NodeID: nodeIdsCompactRangeSize4[0], NodeRevision: 2, Hash: []byte("hash")}
+	mockTx.EXPECT().GetSignedLogRootAtRevision(int64(3)).Return(rootAtRevision3, nil)
+	mockTx.EXPECT().GetSignedLogRootAtRevision(int64(5)).Return(rootAtRevision3, nil)
+	mockTx.EXPECT().GetMerkleNodes(int64(3), nodeIdsCompactRangeSize4).Return([]storage.Node{node}, nil)
+	mockTx.EXPECT().GetMerkleNodes(int64(5), nodeIdsCompactRangeSize4).Return([]storage.Node{node}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+
+	resp, err := server.GetRevisionDiff(context.Background(), &getRevisionDiffRequest)
+
+	if err != nil {
+		t.Fatalf("failed to get revision diff: %v", err)
+	}
+
+	if len(resp.NodeDiff) != 0 || resp.Proof != nil {
+		t.Fatalf("Expected no node diffs or proof but got: %v", resp)
+	}
+}
+
 type prepareMockTXFunc func(*storage.MockLogTX)
 type makeRpcFunc func(*TrillianLogServer) error
 
@@ -1440,6 +2313,9 @@ func (p *parameterizedTest) executeCommitFailsTest(t *testing.T) {
 	mockStorage := storage.NewMockLogStorage(p.ctrl)
 	mockTx := storage.NewMockLogTX(p.ctrl)
 
+	// Only the operations that hash leaves need this
+	mockStorage.EXPECT().HashAlgorithm().AnyTimes().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().LeafHashStrategy().AnyTimes().Return(trillian.LeafHashStrategy_RFC6962_LEAF_HASH)
 	mockStorage.EXPECT().Begin().Return(mockTx, nil)
 	p.prepareTx(mockTx)
 	mockTx.EXPECT().Commit().Return(errors.New("Bang!"))
@@ -1471,6 +2347,9 @@ func (p *parameterizedTest) executeStorageFailureTest(t *testing.T) {
 	mockStorage := storage.NewMockLogStorage(p.ctrl)
 	mockTx := storage.NewMockLogTX(p.ctrl)
 
+	// Only the operations that hash leaves need this
+	mockStorage.EXPECT().HashAlgorithm().AnyTimes().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().LeafHashStrategy().AnyTimes().Return(trillian.LeafHashStrategy_RFC6962_LEAF_HASH)
 	mockStorage.EXPECT().Begin().Return(mockTx, nil)
 	p.prepareTx(mockTx)
 	mockTx.EXPECT().Rollback().Return(nil)
@@ -1488,6 +2367,9 @@ func (p *parameterizedTest) executeBeginFailsTest(t *testing.T) {
 	mockStorage := storage.NewMockLogStorage(p.ctrl)
 	mockTx := storage.NewMockLogTX(p.ctrl)
 
+	// Only the operations that hash leaves need this
+	mockStorage.EXPECT().HashAlgorithm().AnyTimes().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().LeafHashStrategy().AnyTimes().Return(trillian.LeafHashStrategy_RFC6962_LEAF_HASH)
 	mockStorage.EXPECT().Begin().Return(mockTx, errors.New("TX"))
 
 	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
@@ -1498,3 +2380,108 @@ func (p *parameterizedTest) executeBeginFailsTest(t *testing.T) {
 		t.Fatalf("Returned wrong error response when begin failed")
 	}
 }
+
+// fakeTreeEventStream is the server side of a SubscribeTreeEvents stream that passes on the
+// events sent to it
+type fakeTreeEventStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan *trillian.TreeEvent
+}
+
+func (f *fakeTreeEventStream) Context() context.Context {
+	return f.ctx
+}
+
+func (f *fakeTreeEventStream) Send(event *trillian.TreeEvent) error {
+	select {
+	case f.sent <- event:
+		return nil
+	case <-f.ctx.Done():
+		return f.ctx.Err()
+	}
+}
+
+func TestSubscribeTreeEventsNotEnabled(t *testing.T) {
+	server := NewTrillianLogServer(nil)
+	stream := &fakeTreeEventStream{ctx: context.Background()}
+
+	if err := server.SubscribeTreeEvents(&trillian.SubscribeTreeEventsRequest{LogId: logId1}, stream); grpc.Code(err) != codes.Unimplemented {
+		t.Fatalf("SubscribeTreeEvents()=%v, expected Unimplemented", err)
+	}
+}
+
+func TestSubscribeTreeEvents(t *testing.T) {
+	events := NewTreeEvents(fakeTimeSource)
+	server := NewTrillianLogServer(nil)
+	server.SetTreeEvents(events)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &fakeTreeEventStream{ctx: ctx, sent: make(chan *trillian.TreeEvent, 1)}
+	result := make(chan error)
+
+	go func() {
+		result <- server.SubscribeTreeEvents(&trillian.SubscribeTreeEventsRequest{LogId: logId1}, stream)
+	}()
+
+	// Keep publishing until the subscription has started and the event comes through
+	want := &trillian.TreeEvent{EventType: trillian.TreeEventType_NEW_SIGNED_ROOT, LogId: logId1, SignedLogRoot: &signedRoot1}
+	var got *trillian.TreeEvent
+
+	for got == nil {
+		events.Publish(&trillian.TreeEvent{EventType: trillian.TreeEventType_NEW_SIGNED_ROOT, LogId: logId2})
+		events.Publish(want)
+
+		select {
+		case got = <-stream.sent:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if !proto.Equal(got, want) {
+		t.Errorf("Got event %v, expected %v", got, want)
+	}
+
+	cancel()
+
+	if err := <-result; err != context.Canceled {
+		t.Errorf("SubscribeTreeEvents()=%v after the client went away, expected %v", err, context.Canceled)
+	}
+
+	// Subscriptions also end when the server closes the events
+	stream = &fakeTreeEventStream{ctx: context.Background()}
+	events.Close()
+
+	if err := server.SubscribeTreeEvents(&trillian.SubscribeTreeEventsRequest{LogId: logId1}, stream); grpc.Code(err) != codes.Unavailable {
+		t.Errorf("SubscribeTreeEvents()=%v after Close(), expected Unavailable", err)
+	}
+}
+
+func TestQueueLeavesIdempotent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+
+	// The leaves are only queued for the first request
+	mockStorage.EXPECT().HashAlgorithm().Times(2).Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().LeafHashStrategy().Times(2).Return(trillian.LeafHashStrategy_RFC6962_LEAF_HASH)
+	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockTx.EXPECT().QueueLeaves([]trillian.LogLeaf{leaf1}).Return(nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
+
+	server := NewTrillianLogServer(mockStorageProviderfunc(mockStorage))
+	server.SetIdempotencyCache(NewIdempotencyCache(time.Minute, 10, util.SystemTimeSource{}))
+
+	request := trillian.QueueLeavesRequest{LogId: logId1, Leaves: []*trillian.LeafProto{&expectedLeaf1}, IdempotencyKey: "key"}
+
+	for i := 0; i < 2; i++ {
+		resp, err := server.QueueLeaves(context.Background(), &request)
+
+		if err != nil || resp.Status.StatusCode != trillian.TrillianApiStatusCode_OK {
+			t.Fatalf("Request %d failed: %v %v", i, resp, err)
+		}
+	}
+}
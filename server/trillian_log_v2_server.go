@@ -0,0 +1,302 @@
+package server
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/google/trillian"
+	terrors "github.com/google/trillian/errors"
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+)
+
+// maxLeavesByRange limits the number of leaves returned by one GetLeavesByRange call, clients
+// page through larger ranges
+const maxLeavesByRange = 1000
+
+// TrillianLogV2Server implements the TrillianLogV2 API by translating its calls into calls to
+// the original TrillianLog API, so that both versions behave the same while personalities
+// migrate. V2 calls that need several V1 calls make them in separate transactions, which is
+// safe because everything they return is for the root they fetch first.
+type TrillianLogV2Server struct {
+	v1              trillian.TrillianLogServer
+	storageProvider LogStorageProviderFunc
+}
+
+// NewTrillianLogV2Server creates a V2 server that passes calls on to v1, which will normally be
+// the TrillianLogServer using the same storage provider.
+func NewTrillianLogV2Server(v1 trillian.TrillianLogServer, p LogStorageProviderFunc) *TrillianLogV2Server {
+	return &TrillianLogV2Server{v1: v1, storageProvider: p}
+}
+
+// QueueLeaves queues the leaves of a request that aren't already in the tree and says what
+// happened to each of them. A leaf whose hash doesn't match its data is rejected without
+// affecting the others, but as with the V1 call, if a leaf validator rejects the request all
+// the leaves that would have been queued are rejected. Leaves that are queued but not yet
+// sequenced aren't detected as already integrated.
+func (t *TrillianLogV2Server) QueueLeaves(ctx context.Context, req *trillian.QueueLeavesV2Request) (*trillian.QueueLeavesV2Response, error) {
+	if len(req.Leaves) == 0 {
+		return nil, terrors.New(terrors.InvalidRange, "must queue at least one leaf")
+	}
+
+	s, err := t.storageProvider(req.LogId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	treeHasher, err := merkle.NewTreeHasherForAlgorithm(s.HashAlgorithm(), s.LeafHashStrategy())
+
+	if err != nil {
+		return nil, err
+	}
+
+	queuedLeaves := make([]*trillian.QueuedLeaf, 0, len(req.Leaves))
+	var hashes [][]byte
+
+	for _, leafProto := range req.Leaves {
+		leaf := *leafProto
+		hash := treeHasher.HashLeaf(leaf.LeafData)
+
+		if len(leaf.LeafHash) > 0 && !bytes.Equal(leaf.LeafHash, hash) {
+			queuedLeaves = append(queuedLeaves, &trillian.QueuedLeaf{Leaf: &leaf, Status: trillian.QueuedLeafStatus_LEAF_REJECTED, Description: "leaf hash doesn't match its data"})
+			continue
+		}
+
+		leaf.LeafHash = hash
+		queuedLeaves = append(queuedLeaves, &trillian.QueuedLeaf{Leaf: &leaf})
+		hashes = append(hashes, hash)
+	}
+
+	if len(hashes) > 0 {
+		integrated, err := t.integratedLeaves(ctx, req.LogId, hashes)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := t.queueNewLeaves(ctx, req.LogId, queuedLeaves, integrated); err != nil {
+			return nil, err
+		}
+	}
+
+	return &trillian.QueueLeavesV2Response{QueuedLeaves: queuedLeaves}, nil
+}
+
+// integratedLeaves returns the first leaf in the tree with each of hashes, keyed by hash.
+func (t *TrillianLogV2Server) integratedLeaves(ctx context.Context, logID int64, hashes [][]byte) (map[string]*trillian.LeafProto, error) {
+	resp, err := t.v1.GetLeavesByHash(ctx, &trillian.GetLeavesByHashRequest{LogId: logID, LeafHash: hashes, OrderBySequence: true})
+
+	if err := v1Error(resp.GetStatus(), err); err != nil {
+		return nil, err
+	}
+
+	integrated := make(map[string]*trillian.LeafProto, len(resp.Leaves))
+
+	for _, leaf := range resp.Leaves {
+		if _, ok := integrated[string(leaf.LeafHash)]; !ok {
+			integrated[string(leaf.LeafHash)] = leaf
+		}
+	}
+
+	return integrated, nil
+}
+
+// queueNewLeaves queues the leaves of queuedLeaves that haven't been given a status and aren't
+// in integrated, and sets the status of all of them.
+func (t *TrillianLogV2Server) queueNewLeaves(ctx context.Context, logID int64, queuedLeaves []*trillian.QueuedLeaf, integrated map[string]*trillian.LeafProto) error {
+	var toQueue []*trillian.QueuedLeaf
+	var leaves []*trillian.LeafProto
+
+	for _, queued := range queuedLeaves {
+		if queued.Status != trillian.QueuedLeafStatus_UNKNOWN_QUEUED_LEAF_STATUS {
+			continue
+		}
+
+		if leaf, ok := integrated[string(queued.Leaf.LeafHash)]; ok {
+			queued.Leaf = leaf
+			queued.Status = trillian.QueuedLeafStatus_LEAF_ALREADY_INTEGRATED
+			continue
+		}
+
+		toQueue = append(toQueue, queued)
+		leaves = append(leaves, queued.Leaf)
+	}
+
+	if len(leaves) == 0 {
+		return nil
+	}
+
+	resp, err := t.v1.QueueLeaves(ctx, &trillian.QueueLeavesRequest{LogId: logID, Leaves: leaves})
+
+	if err != nil {
+		return err
+	}
+
+	status, description := trillian.QueuedLeafStatus_LEAF_QUEUED, ""
+
+	if err := v1Error(resp.GetStatus(), nil); err != nil {
+		status, description = trillian.QueuedLeafStatus_LEAF_REJECTED, resp.Status.Description
+	}
+
+	for _, queued := range toQueue {
+		queued.Status = status
+		queued.Description = description
+	}
+
+	return nil
+}
+
+// GetLeavesByRange returns up to req.Count leaves starting at req.StartIndex, in index order,
+// along with the latest root. The range is limited to the tree size of the root and to
+// maxLeavesByRange leaves.
+func (t *TrillianLogV2Server) GetLeavesByRange(ctx context.Context, req *trillian.GetLeavesByRangeRequest) (*trillian.GetLeavesByRangeResponse, error) {
+	if req.StartIndex < 0 || req.Count <= 0 {
+		return nil, terrors.Errorf(terrors.InvalidRange, "invalid range start: %d count: %d", req.StartIndex, req.Count)
+	}
+
+	root, err := t.latestRoot(ctx, req.LogId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	count := req.Count
+
+	if count > maxLeavesByRange {
+		count = maxLeavesByRange
+	}
+
+	end := req.StartIndex + count
+
+	if end > root.TreeSize {
+		end = root.TreeSize
+	}
+
+	response := &trillian.GetLeavesByRangeResponse{SignedLogRoot: root}
+
+	if end <= req.StartIndex {
+		return response, nil
+	}
+
+	indices := make([]int64, 0, end-req.StartIndex)
+
+	for index := req.StartIndex; index < end; index++ {
+		indices = append(indices, index)
+	}
+
+	resp, err := t.v1.GetLeavesByIndex(ctx, &trillian.GetLeavesByIndexRequest{LogId: req.LogId, LeafIndex: indices, OmitExtraData: req.OmitExtraData})
+
+	if err := v1Error(resp.GetStatus(), err); err != nil {
+		return nil, err
+	}
+
+	if len(resp.Leaves) != len(indices) {
+		return nil, terrors.Errorf(terrors.Integrity, "expected %d leaves from storage but got: %d", len(indices), len(resp.Leaves))
+	}
+
+	sort.Sort(leafProtosByIndex(resp.Leaves))
+	response.Leaves = resp.Leaves
+
+	return response, nil
+}
+
+// GetLatestSignedLogRoot returns the latest root and, if the request has a first tree size
+// smaller than the root's, a consistency proof from that size to the root.
+func (t *TrillianLogV2Server) GetLatestSignedLogRoot(ctx context.Context, req *trillian.GetLatestSignedLogRootV2Request) (*trillian.GetLatestSignedLogRootV2Response, error) {
+	if req.FirstTreeSize < 0 {
+		return nil, terrors.Errorf(terrors.InvalidRange, "first tree size must be >= 0 but was %d", req.FirstTreeSize)
+	}
+
+	root, err := t.latestRoot(ctx, req.LogId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if req.FirstTreeSize > root.TreeSize {
+		return nil, terrors.Errorf(terrors.InvalidRange, "first tree size (%d) is larger than the latest tree size (%d)", req.FirstTreeSize, root.TreeSize)
+	}
+
+	response := &trillian.GetLatestSignedLogRootV2Response{SignedLogRoot: root}
+
+	if req.FirstTreeSize == 0 || req.FirstTreeSize == root.TreeSize {
+		return response, nil
+	}
+
+	resp, err := t.v1.GetConsistencyProof(ctx, &trillian.GetConsistencyProofRequest{LogId: req.LogId, FirstTreeSize: req.FirstTreeSize, SecondTreeSize: root.TreeSize})
+
+	if err := v1Error(resp.GetStatus(), err); err != nil {
+		return nil, err
+	}
+
+	response.Proof = resp.Proof
+
+	return response, nil
+}
+
+// GetInclusionProofByHash returns the latest root and proofs that the leaves with a hash are
+// included in its tree. It's a NotFound error if there are no such leaves.
+func (t *TrillianLogV2Server) GetInclusionProofByHash(ctx context.Context, req *trillian.GetInclusionProofByHashV2Request) (*trillian.GetInclusionProofByHashV2Response, error) {
+	if len(req.LeafHash) == 0 {
+		return nil, terrors.Errorf(terrors.InvalidRange, "invalid leaf hash: %v", req.LeafHash)
+	}
+
+	root, err := t.latestRoot(ctx, req.LogId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if root.TreeSize == 0 {
+		return nil, terrors.Errorf(terrors.NotFound, "log %d is empty", req.LogId)
+	}
+
+	resp, err := t.v1.GetInclusionProofByHash(ctx, &trillian.GetInclusionProofByHashRequest{LogId: req.LogId, LeafHash: req.LeafHash, TreeSize: root.TreeSize, OrderBySequence: true})
+
+	if err := v1Error(resp.GetStatus(), err); err != nil {
+		return nil, err
+	}
+
+	if len(resp.Proof) == 0 {
+		return nil, terrors.Errorf(terrors.NotFound, "no leaf with hash %x in tree of size %d", req.LeafHash, root.TreeSize)
+	}
+
+	return &trillian.GetInclusionProofByHashV2Response{SignedLogRoot: root, Proof: resp.Proof}, nil
+}
+
+// latestRoot returns the latest signed root of a log.
+func (t *TrillianLogV2Server) latestRoot(ctx context.Context, logID int64) (*trillian.SignedLogRoot, error) {
+	resp, err := t.v1.GetLatestSignedLogRoot(ctx, &trillian.GetLatestSignedLogRootRequest{LogId: logID})
+
+	if err := v1Error(resp.GetStatus(), err); err != nil {
+		return nil, err
+	}
+
+	if resp.SignedLogRoot == nil {
+		return nil, terrors.Errorf(terrors.NotFound, "log %d has no signed root", logID)
+	}
+
+	return resp.SignedLogRoot, nil
+}
+
+// v1Error returns err if it's set, otherwise an error for a V1 response status that isn't OK.
+// V1 reports some bad requests in the response status, V2 always returns an error.
+func v1Error(status *trillian.TrillianApiStatus, err error) error {
+	if err != nil {
+		return err
+	}
+
+	if status != nil && status.StatusCode != trillian.TrillianApiStatusCode_OK {
+		return terrors.New(terrors.InvalidRange, status.Description)
+	}
+
+	return nil
+}
+
+// leafProtosByIndex sorts leaves by their index
+type leafProtosByIndex []*trillian.LeafProto
+
+func (l leafProtosByIndex) Len() int           { return len(l) }
+func (l leafProtosByIndex) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l leafProtosByIndex) Less(i, j int) bool { return l[i].LeafIndex < l[j].LeafIndex }
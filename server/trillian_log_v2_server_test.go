@@ -0,0 +1,255 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	terrors "github.com/google/trillian/errors"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+var leaf2Hash = merkle.NewRFC6962TreeHasher(trillian.NewSHA256()).HashLeaf([]byte("value2"))
+var integratedLeaf2 = trillian.LogLeaf{SequenceNumber: 5, Leaf: trillian.Leaf{LeafHash: leaf2Hash, LeafValue: []byte("value2")}}
+
+// newV2TestServer returns a V2 server that translates to a V1 server using mockStorage
+func newV2TestServer(mockStorage *storage.MockLogStorage) (*TrillianLogV2Server, *TrillianLogServer) {
+	p := mockStorageProviderfunc(mockStorage)
+	v1 := NewTrillianLogServer(p)
+	return NewTrillianLogV2Server(v1, p), v1
+}
+
+func TestQueueLeavesV2(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+
+	mockStorage.EXPECT().HashAlgorithm().AnyTimes().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().LeafHashStrategy().AnyTimes().Return(trillian.LeafHashStrategy_RFC6962_LEAF_HASH)
+	mockStorage.EXPECT().Begin().Times(2).Return(mockTx, nil)
+	mockTx.EXPECT().GetLeavesByHash([]trillian.Hash{leaf1Hash, leaf2Hash}, true).Return([]trillian.LogLeaf{integratedLeaf2}, nil)
+	// Only the leaf that's valid and not already integrated is queued
+	mockTx.EXPECT().QueueLeaves([]trillian.LogLeaf{leaf1}).Return(nil)
+	mockTx.EXPECT().Commit().Times(2).Return(nil)
+	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
+
+	server, _ := newV2TestServer(mockStorage)
+	badHashLeaf := trillian.LeafProto{LeafHash: []byte("wrong"), LeafData: []byte("value3")}
+	req := trillian.QueueLeavesV2Request{LogId: logId1, Leaves: []*trillian.LeafProto{&expectedLeaf1, &badHashLeaf, {LeafData: []byte("value2")}}}
+
+	resp, err := server.QueueLeaves(context.Background(), &req)
+
+	if err != nil {
+		t.Fatalf("QueueLeaves()=%v", err)
+	}
+
+	want := []*trillian.QueuedLeaf{
+		{Leaf: &expectedLeaf1, Status: trillian.QueuedLeafStatus_LEAF_QUEUED},
+		{Leaf: &badHashLeaf, Status: trillian.QueuedLeafStatus_LEAF_REJECTED, Description: "leaf hash doesn't match its data"},
+		{Leaf: &trillian.LeafProto{LeafIndex: 5, LeafHash: leaf2Hash, LeafData: []byte("value2")}, Status: trillian.QueuedLeafStatus_LEAF_ALREADY_INTEGRATED},
+	}
+
+	if got, want := resp, (&trillian.QueueLeavesV2Response{QueuedLeaves: want}); !proto.Equal(got, want) {
+		t.Errorf("QueueLeaves()=%v, expected %v", got, want)
+	}
+}
+
+func TestQueueLeavesV2RejectedByValidator(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+
+	mockStorage.EXPECT().HashAlgorithm().AnyTimes().Return(trillian.HashAlgorithm_SHA256)
+	mockStorage.EXPECT().LeafHashStrategy().AnyTimes().Return(trillian.LeafHashStrategy_RFC6962_LEAF_HASH)
+	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockTx.EXPECT().GetLeavesByHash([]trillian.Hash{leaf1Hash}, true).Return([]trillian.LogLeaf{}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+	mockTx.EXPECT().IsOpen().AnyTimes().Return(false)
+
+	server, v1 := newV2TestServer(mockStorage)
+	v1.SetLeafValidators(LeafSizeValidator{MaxLeafValueBytes: 1})
+
+	resp, err := server.QueueLeaves(context.Background(), &trillian.QueueLeavesV2Request{LogId: logId1, Leaves: []*trillian.LeafProto{&expectedLeaf1}})
+
+	if err != nil {
+		t.Fatalf("QueueLeaves()=%v", err)
+	}
+
+	if len(resp.QueuedLeaves) != 1 || resp.QueuedLeaves[0].Status != trillian.QueuedLeafStatus_LEAF_REJECTED || len(resp.QueuedLeaves[0].Description) == 0 {
+		t.Errorf("QueueLeaves()=%v, expected the leaf to be rejected with a description", resp)
+	}
+}
+
+func TestQueueLeavesV2Empty(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server, _ := newV2TestServer(storage.NewMockLogStorage(ctrl))
+
+	if _, err := server.QueueLeaves(context.Background(), &trillian.QueueLeavesV2Request{LogId: logId1}); terrors.CodeOf(err) != terrors.InvalidRange {
+		t.Errorf("QueueLeaves()=%v, expected InvalidRange", err)
+	}
+}
+
+func TestGetLeavesByRange(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+
+	mockStorage.EXPECT().Begin().Times(2).Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedLogRoot().Return(signedRoot1, nil)
+	// The range is limited to the tree size of 7, and storage needn't return leaves in order
+	mockTx.EXPECT().GetLeavesByIndex([]int64{5, 6}).Return([]trillian.LogLeaf{{SequenceNumber: 6}, {SequenceNumber: 5}}, nil)
+	mockTx.EXPECT().Commit().Times(2).Return(nil)
+
+	server, _ := newV2TestServer(mockStorage)
+
+	resp, err := server.GetLeavesByRange(context.Background(), &trillian.GetLeavesByRangeRequest{LogId: logId1, StartIndex: 5, Count: 10})
+
+	if err != nil {
+		t.Fatalf("GetLeavesByRange()=%v", err)
+	}
+
+	want := trillian.GetLeavesByRangeResponse{Leaves: []*trillian.LeafProto{{LeafIndex: 5}, {LeafIndex: 6}}, SignedLogRoot: &signedRoot1}
+
+	if !proto.Equal(resp, &want) {
+		t.Errorf("GetLeavesByRange()=%v, expected %v", resp, want)
+	}
+}
+
+func TestGetLeavesByRangePastTreeSize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+
+	mockStorage.EXPECT().Begin().Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedLogRoot().Return(signedRoot1, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server, _ := newV2TestServer(mockStorage)
+
+	resp, err := server.GetLeavesByRange(context.Background(), &trillian.GetLeavesByRangeRequest{LogId: logId1, StartIndex: 7, Count: 10})
+
+	if err != nil {
+		t.Fatalf("GetLeavesByRange()=%v", err)
+	}
+
+	if len(resp.Leaves) != 0 || !proto.Equal(resp.SignedLogRoot, &signedRoot1) {
+		t.Errorf("GetLeavesByRange()=%v, expected no leaves and the root", resp)
+	}
+}
+
+func TestGetLeavesByRangeInvalid(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Requests should fail validation before any storage operations
+	server, _ := newV2TestServer(storage.NewMockLogStorage(ctrl))
+
+	for _, req := range []trillian.GetLeavesByRangeRequest{
+		{LogId: logId1, StartIndex: -1, Count: 10},
+		{LogId: logId1, StartIndex: 0, Count: 0},
+	} {
+		if _, err := server.GetLeavesByRange(context.Background(), &req); terrors.CodeOf(err) != terrors.InvalidRange {
+			t.Errorf("GetLeavesByRange(%v)=%v, expected InvalidRange", req, err)
+		}
+	}
+}
+
+func TestGetLatestSignedLogRootV2WithProof(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+
+	mockStorage.EXPECT().Begin().Times(2).Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedLogRoot().Return(signedRoot1, nil)
+	mockTx.EXPECT().GetTreeRevisionAtSize(int64(4)).Return(int64(3), nil)
+	mockTx.EXPECT().GetTreeRevisionAtSize(int64(7)).Return(int64(5), nil)
+	mockTx.EXPECT().GetMerkleNodes(int64(5), nodeIdsConsistencySize4ToSize7).Return([]storage.Node{{NodeID: nodeIdsConsistencySize4ToSize7[0], NodeRevision: 3, Hash: []byte("nodehash")}}, nil)
+	mockTx.EXPECT().Commit().Times(2).Return(nil)
+
+	server, _ := newV2TestServer(mockStorage)
+
+	resp, err := server.GetLatestSignedLogRoot(context.Background(), &trillian.GetLatestSignedLogRootV2Request{LogId: logId1, FirstTreeSize: 4})
+
+	if err != nil {
+		t.Fatalf("GetLatestSignedLogRoot()=%v", err)
+	}
+
+	if !proto.Equal(resp.SignedLogRoot, &signedRoot1) {
+		t.Errorf("GetLatestSignedLogRoot().SignedLogRoot=%v, expected %v", resp.SignedLogRoot, signedRoot1)
+	}
+
+	if resp.Proof == nil || len(resp.Proof.ProofNode) != 1 || string(resp.Proof.ProofNode[0].NodeHash) != "nodehash" {
+		t.Errorf("GetLatestSignedLogRoot().Proof=%v, expected the proof from size 4 to 7", resp.Proof)
+	}
+}
+
+func TestGetLatestSignedLogRootV2WithoutProof(t *testing.T) {
+	for _, test := range []struct {
+		firstTreeSize int64
+		wantErr       terrors.Code
+	}{
+		{firstTreeSize: 0},
+		{firstTreeSize: 7},
+		{firstTreeSize: 8, wantErr: terrors.InvalidRange},
+	} {
+		ctrl := gomock.NewController(t)
+
+		mockStorage := storage.NewMockLogStorage(ctrl)
+		mockTx := storage.NewMockLogTX(ctrl)
+
+		mockStorage.EXPECT().Begin().Return(mockTx, nil)
+		mockTx.EXPECT().LatestSignedLogRoot().Return(signedRoot1, nil)
+		mockTx.EXPECT().Commit().Return(nil)
+
+		server, _ := newV2TestServer(mockStorage)
+
+		resp, err := server.GetLatestSignedLogRoot(context.Background(), &trillian.GetLatestSignedLogRootV2Request{LogId: logId1, FirstTreeSize: test.firstTreeSize})
+
+		if test.wantErr != terrors.Unknown {
+			if terrors.CodeOf(err) != test.wantErr {
+				t.Errorf("GetLatestSignedLogRoot(%d)=%v, expected %v", test.firstTreeSize, err, test.wantErr)
+			}
+		} else if err != nil {
+			t.Errorf("GetLatestSignedLogRoot(%d)=%v", test.firstTreeSize, err)
+		} else if resp.Proof != nil || !proto.Equal(resp.SignedLogRoot, &signedRoot1) {
+			t.Errorf("GetLatestSignedLogRoot(%d)=%v, expected the root without a proof", test.firstTreeSize, resp)
+		}
+
+		ctrl.Finish()
+	}
+}
+
+func TestGetInclusionProofByHashV2NotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockLogStorage(ctrl)
+	mockTx := storage.NewMockLogTX(ctrl)
+
+	mockStorage.EXPECT().Begin().Times(2).Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedLogRoot().Return(signedRoot1, nil)
+	mockTx.EXPECT().GetTreeRevisionAtSize(int64(7)).Return(int64(5), nil)
+	mockTx.EXPECT().GetLeavesByHash([]trillian.Hash{[]byte("ahash")}, true).Return([]trillian.LogLeaf{}, nil)
+	mockTx.EXPECT().Commit().Times(2).Return(nil)
+
+	server, _ := newV2TestServer(mockStorage)
+
+	if _, err := server.GetInclusionProofByHash(context.Background(), &trillian.GetInclusionProofByHashV2Request{LogId: logId1, LeafHash: []byte("ahash")}); terrors.CodeOf(err) != terrors.NotFound {
+		t.Errorf("GetInclusionProofByHash()=%v, expected NotFound", err)
+	}
+}
@@ -0,0 +1,238 @@
+package vmap
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"golang.org/x/net/context"
+)
+
+// MapRootLeafData returns the data of the log leaf that a map root is published as, the
+// serialized root as read from storage.
+func MapRootLeafData(root trillian.SignedMapRoot) ([]byte, error) {
+	return proto.Marshal(&root)
+}
+
+// MapRootPublisher appends every signed root of a map, in revision order, to a companion log
+// so that auditors have an append-only history of the map's states and can detect a map
+// server showing different clients different roots. Revisions are published in batches, each
+// queued with an idempotency key so a retry doesn't queue them twice. On startup the first
+// revision that isn't in the log yet is found by searching the log for roots by leaf hash, so
+// the log must use RFC 6962 leaf hashing. Roots that were queued but not yet sequenced when
+// the publisher stopped are queued again, which the log deduplicates unless it allows
+// duplicate leaves.
+type MapRootPublisher struct {
+	mapID           int64
+	logID           int64
+	storageProvider MapStorageProviderFunc
+	client          trillian.TrillianLogClient
+	hasher          merkle.TreeHasher
+
+	// mu guards the fields below it
+	mu sync.Mutex
+	// next is the revision that will be published next, or 0 until it's been found
+	next int64
+	// published counts the roots this publisher has queued
+	published int64
+}
+
+// NewMapRootPublisher creates a MapRootPublisher that publishes the roots of the map mapID,
+// read from storage, to the log logID through client.
+func NewMapRootPublisher(mapID, logID int64, storageProvider MapStorageProviderFunc, client trillian.TrillianLogClient) *MapRootPublisher {
+	return &MapRootPublisher{mapID: mapID, logID: logID, storageProvider: storageProvider, client: client, hasher: merkle.NewRFC6962TreeHasher(trillian.NewSHA256())}
+}
+
+// Stats returns the revision that will be published next, 0 if it's not known yet, and the
+// number of roots that have been queued.
+func (p *MapRootPublisher) Stats() (next, published int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.next, p.published
+}
+
+// PublishBatch queues up to batchSize of the map's roots that haven't been published in one
+// request to the log and returns how many it queued. The next revision is only advanced once
+// the log has accepted them, so a failed batch can simply be retried. PublishBatch must not
+// be called concurrently with itself, normally only Run calls it.
+func (p *MapRootPublisher) PublishBatch(ctx context.Context, batchSize int) (int, error) {
+	latest, err := p.readRoot(-1)
+
+	if err != nil {
+		return 0, err
+	}
+
+	next, _ := p.Stats()
+
+	if next == 0 {
+		if next, err = p.findNext(ctx, latest.MapRevision); err != nil {
+			return 0, err
+		}
+
+		glog.Infof("Publishing the roots of map %d to log %d from revision %d", p.mapID, p.logID, next)
+
+		p.mu.Lock()
+		p.next = next
+		p.mu.Unlock()
+	}
+
+	var leaves []*trillian.LeafProto
+
+	for revision := next; revision <= latest.MapRevision && len(leaves) < batchSize; revision++ {
+		root, err := p.readRoot(revision)
+
+		if err != nil {
+			return 0, err
+		}
+
+		data, err := MapRootLeafData(root)
+
+		if err != nil {
+			return 0, err
+		}
+
+		leaves = append(leaves, &trillian.LeafProto{LeafData: data})
+	}
+
+	if len(leaves) == 0 {
+		return 0, nil
+	}
+
+	last := next + int64(len(leaves)) - 1
+	key := fmt.Sprintf("map-root-%d-%d-%d", p.mapID, next, last)
+	resp, err := p.client.QueueLeaves(ctx, &trillian.QueueLeavesRequest{LogId: p.logID, Leaves: leaves, IdempotencyKey: key})
+
+	if err != nil {
+		return 0, err
+	}
+
+	if !statusOK(resp.GetStatus()) {
+		return 0, fmt.Errorf("log %d rejected map roots %d to %d: %v", p.logID, next, last, resp.GetStatus())
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.next = last + 1
+	p.published += int64(len(leaves))
+
+	return len(leaves), nil
+}
+
+// Run publishes new roots every interval until done is closed. A failed batch is retried on
+// the next pass. Any backlog is drained in consecutive batches.
+func (p *MapRootPublisher) Run(done <-chan struct{}, interval, rpcDeadline time.Duration, batchSize int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), rpcDeadline)
+			published, err := p.PublishBatch(ctx, batchSize)
+			cancel()
+
+			if err != nil {
+				glog.Warningf("Failed to publish the roots of map %d to log %d: %v", p.mapID, p.logID, err)
+				break
+			}
+
+			if published < batchSize {
+				break
+			}
+		}
+	}
+}
+
+// findNext returns the first revision up to latest + 1 whose root isn't in the log. Roots
+// are published in order so the revisions in the log are always a prefix of the map's.
+// Revision 0 is the empty map, which has no root.
+func (p *MapRootPublisher) findNext(ctx context.Context, latest int64) (int64, error) {
+	low, high := int64(1), latest+1
+
+	for low < high {
+		mid := low + (high-low)/2
+		published, err := p.inLog(ctx, mid)
+
+		if err != nil {
+			return 0, err
+		}
+
+		if published {
+			low = mid + 1
+		} else {
+			high = mid
+		}
+	}
+
+	return low, nil
+}
+
+// inLog returns true if the root of revision has been sequenced in the log.
+func (p *MapRootPublisher) inLog(ctx context.Context, revision int64) (bool, error) {
+	root, err := p.readRoot(revision)
+
+	if err != nil {
+		return false, err
+	}
+
+	data, err := MapRootLeafData(root)
+
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := p.client.GetLeavesByHash(ctx, &trillian.GetLeavesByHashRequest{LogId: p.logID, LeafHash: [][]byte{p.hasher.HashLeaf(data)}})
+
+	if err != nil {
+		return false, err
+	}
+
+	if !statusOK(resp.GetStatus()) {
+		return false, fmt.Errorf("log %d failed to look up map root %d: %v", p.logID, revision, resp.GetStatus())
+	}
+
+	return len(resp.Leaves) > 0, nil
+}
+
+func statusOK(status *trillian.TrillianApiStatus) bool {
+	return status != nil && status.StatusCode == trillian.TrillianApiStatusCode_OK
+}
+
+// readRoot reads the root of the map at revision, or the latest root if revision is negative.
+func (p *MapRootPublisher) readRoot(revision int64) (root trillian.SignedMapRoot, err error) {
+	s, err := p.storageProvider(p.mapID)
+
+	if err != nil {
+		return trillian.SignedMapRoot{}, err
+	}
+
+	tx, err := s.Snapshot()
+
+	if err != nil {
+		return trillian.SignedMapRoot{}, err
+	}
+
+	defer func() {
+		e := tx.Commit()
+		if e != nil && err == nil {
+			root, err = trillian.SignedMapRoot{}, e
+		}
+	}()
+
+	if revision < 0 {
+		return tx.LatestSignedMapRoot()
+	}
+
+	return tx.GetSignedMapRootAtRevision(revision)
+}
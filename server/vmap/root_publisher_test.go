@@ -0,0 +1,63 @@
+package vmap
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+func mapRootLeafDataOrDie(t *testing.T, root trillian.SignedMapRoot) []byte {
+	data, err := MapRootLeafData(root)
+
+	if err != nil {
+		t.Fatalf("Failed to serialize map root: %v", err)
+	}
+
+	return data
+}
+
+func TestMapRootPublisher(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	roots := []trillian.SignedMapRoot{{}, {MapRevision: 1, RootHash: []byte("root1")}, {MapRevision: 2, RootHash: []byte("root2")}, {MapRevision: 3, RootHash: []byte("root3")}}
+
+	mockStorage := storage.NewMockMapStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyMapTX(ctrl)
+	mockStorage.EXPECT().Snapshot().AnyTimes().Return(mockTx, nil)
+	mockTx.EXPECT().Commit().AnyTimes().Return(nil)
+	mockTx.EXPECT().LatestSignedMapRoot().AnyTimes().Return(roots[3], nil)
+
+	for _, root := range roots[1:] {
+		mockTx.EXPECT().GetSignedMapRootAtRevision(root.MapRevision).AnyTimes().Return(root, nil)
+	}
+
+	// Revision 1 was published before a restart, so 2 and 3 are published in one batch
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+	data := [][]byte{nil, mapRootLeafDataOrDie(t, roots[1]), mapRootLeafDataOrDie(t, roots[2]), mapRootLeafDataOrDie(t, roots[3])}
+	ok := &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}
+
+	client := trillian.NewMockTrillianLogClient(ctrl)
+	client.EXPECT().GetLeavesByHash(gomock.Any(), &trillian.GetLeavesByHashRequest{LogId: 7, LeafHash: [][]byte{hasher.HashLeaf(data[1])}}).Return(&trillian.GetLeavesByHashResponse{Status: ok, Leaves: []*trillian.LeafProto{{LeafData: data[1]}}}, nil)
+	client.EXPECT().GetLeavesByHash(gomock.Any(), &trillian.GetLeavesByHashRequest{LogId: 7, LeafHash: [][]byte{hasher.HashLeaf(data[2])}}).Return(&trillian.GetLeavesByHashResponse{Status: ok}, nil)
+	client.EXPECT().QueueLeaves(gomock.Any(), &trillian.QueueLeavesRequest{LogId: 7, Leaves: []*trillian.LeafProto{{LeafData: data[2]}, {LeafData: data[3]}}, IdempotencyKey: "map-root-1-2-3"}).Return(&trillian.QueueLeavesResponse{Status: ok}, nil)
+
+	publisher := NewMapRootPublisher(mapID1, 7, mockStorageProviderForMap(mockStorage), client)
+
+	if published, err := publisher.PublishBatch(context.Background(), 10); err != nil || published != 2 {
+		t.Fatalf("First PublishBatch()=%d, %v, expected 2, nil", published, err)
+	}
+
+	// Nothing new so there should be no log requests
+	if published, err := publisher.PublishBatch(context.Background(), 10); err != nil || published != 0 {
+		t.Fatalf("Second PublishBatch()=%d, %v, expected 0, nil", published, err)
+	}
+
+	if next, published := publisher.Stats(); next != 4 || published != 2 {
+		t.Errorf("Stats()=%d, %d, expected 4, 2", next, published)
+	}
+}
@@ -96,18 +96,19 @@ func (t *TrillianMapServer) GetLeaves(ctx context.Context, req *trillian.GetMapL
 		req.Revision = root.MapRevision
 	}
 
-	smtReader := merkle.NewSparseMerkleTreeReader(req.Revision, kh, tx)
-
 	resp = &trillian.GetMapLeavesResponse{
 		KeyValue: make([]*trillian.KeyValueInclusion, 0, len(req.Key)),
+		MapRoot:  root,
+	}
+
+	// Callers that only want values can skip the cost of building proofs
+	var smtReader *merkle.SparseMerkleTreeReader
+	if !req.OmitInclusion {
+		smtReader = merkle.NewSparseMerkleTreeReader(req.Revision, kh, tx)
 	}
 
 	for _, key := range req.Key {
 		kHash := kh.HashKey(key)
-		proof, err := smtReader.InclusionProof(req.Revision, key)
-		if err != nil {
-			return nil, err
-		}
 
 		leaf, err := tx.Get(req.Revision, kHash)
 		// No key is ok, we'll just return a null value
@@ -120,10 +121,18 @@ func (t *TrillianMapServer) GetLeaves(ctx context.Context, req *trillian.GetMapL
 				Key:   key,
 				Value: &leaf,
 			},
-			Inclusion: make([][]byte, 0, len(proof)),
 		}
-		for j := 0; j < len(proof); j++ {
-			kvi.Inclusion = append(kvi.Inclusion, []byte(proof[j]))
+
+		if smtReader != nil {
+			proof, err := smtReader.InclusionProof(req.Revision, key)
+			if err != nil {
+				return nil, err
+			}
+
+			kvi.Inclusion = make([][]byte, 0, len(proof))
+			for j := 0; j < len(proof); j++ {
+				kvi.Inclusion = append(kvi.Inclusion, []byte(proof[j]))
+			}
 		}
 
 		resp.KeyValue = append(resp.KeyValue, &kvi)
@@ -238,6 +247,79 @@ func (t *TrillianMapServer) GetSignedMapRoot(ctx context.Context, req *trillian.
 	return resp, err
 }
 
+// GetLeafHistory implements the GetLeafHistory RPC method.
+func (t *TrillianMapServer) GetLeafHistory(ctx context.Context, req *trillian.GetMapLeafHistoryRequest) (resp *trillian.GetMapLeafHistoryResponse, err error) {
+	s, err := t.getStorageForMap(req.MapId)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		e := tx.Commit()
+		if e != nil && err == nil {
+			resp, err = nil, e
+		}
+	}()
+
+	kh, err := t.getHasherForMap(req.MapId)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Revision < 0 {
+		// need to know the newest published revision
+		r, err := tx.LatestSignedMapRoot()
+		if err != nil {
+			return nil, err
+		}
+		req.Revision = r.MapRevision
+	}
+
+	history, err := tx.GetHistory(req.Revision, kh.HashKey(req.Key))
+	if err != nil {
+		return nil, err
+	}
+
+	resp = &trillian.GetMapLeafHistoryResponse{
+		Key:     req.Key,
+		History: make([]*trillian.MapLeafHistoryEntry, 0, len(history)),
+	}
+
+	// Each value is proved against the root it was published in, so a client can check that
+	// every change to the key was one it made
+	for _, h := range history {
+		root, err := tx.GetSignedMapRootAtRevision(h.Revision)
+		if err != nil {
+			return nil, err
+		}
+
+		smtReader := merkle.NewSparseMerkleTreeReader(h.Revision, kh, tx)
+		proof, err := smtReader.InclusionProof(h.Revision, req.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		leaf := h.Leaf
+		entry := trillian.MapLeafHistoryEntry{
+			MapRevision: h.Revision,
+			Value:       &leaf,
+			Inclusion:   make([][]byte, 0, len(proof)),
+			MapRoot:     &root,
+		}
+		for j := 0; j < len(proof); j++ {
+			entry.Inclusion = append(entry.Inclusion, []byte(proof[j]))
+		}
+
+		resp.History = append(resp.History, &entry)
+	}
+
+	return resp, nil
+}
+
 func buildStatus(code trillian.TrillianApiStatusCode) *trillian.TrillianApiStatus {
 	return &trillian.TrillianApiStatus{StatusCode: code}
 }
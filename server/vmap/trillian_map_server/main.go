@@ -6,6 +6,8 @@ import (
 	"net"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -17,12 +19,22 @@ import (
 	"github.com/google/trillian/server/vmap"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/mysql"
+	"github.com/google/trillian/storage/sqlite"
 	"google.golang.org/grpc"
 )
 
 var mysqlUriFlag = flag.String("mysql_uri", "test:zaphod@tcp(127.0.0.1:3306)/test",
-	"uri to use with mysql storage")
+	"uri to use with mysql storage. Can be a comma separated list of uris, the primary followed by replicas to fail over to in order when it can't be used, the first writable one is used")
+var storageSystemFlag = flag.String("storage_system", "mysql", "Storage to use: mysql, or sqlite for a single node with no database server")
+var sqliteFileFlag = flag.String("sqlite_file", "trillian.db", "SQLite database file to use with sqlite storage, it's created if it doesn't exist")
+var sqliteBusyTimeoutFlag = flag.Duration("sqlite_busy_timeout", sqlite.DefaultBusyTimeout, "Max time a sqlite storage transaction waits for another one to release the database")
+var checkSchemaFlag = flag.Bool("check_schema", true, "If true and using mysql storage, check at startup that the database has the tables and columns this version expects and exit if not")
 var serverPortFlag = flag.Int("port", 8091, "Port to serve map requests on")
+var mapRootLogsFlag = flag.String("map_root_logs", "", "If set, a comma separated list of map_id:log_id pairs. Every signed root of each map is appended to its log, giving auditors an append-only history of the map's states. The logs must use RFC 6962 leaf hashing")
+var logServerFlag = flag.String("log_server", "localhost:8090", "Address of the log server that the logs in --map_root_logs are served by")
+var mapRootPublishIntervalFlag = flag.Duration("map_root_publish_interval", time.Second*10, "How often new map roots are appended to the logs in --map_root_logs")
+var mapRootPublishBatchSizeFlag = flag.Int("map_root_publish_batch_size", 100, "Max number of map roots appended to a log in one request")
+var rpcDeadlineFlag = flag.Duration("rpc_deadline", time.Second*10, "Deadline for requests to the log server")
 
 // TODO(Martin2112): Single private key doesn't really work for multi tenant and we can't use
 // an HSM interface in this way. Deferring these issues for later.
@@ -41,7 +53,7 @@ func simpleMySqlStorageProvider(treeID int64) (storage.MapStorage, error) {
 	s := mapStorage[treeID]
 	if s == nil {
 		var err error
-		s, err = mysql.NewMapStorage(trillian.MapID{[]byte("TODO"), treeID}, *mysqlUriFlag)
+		s, err = newMapStorage(trillian.MapID{[]byte("TODO"), treeID})
 		if err != nil {
 			return nil, err
 		}
@@ -50,9 +62,21 @@ func simpleMySqlStorageProvider(treeID int64) (storage.MapStorage, error) {
 	return s, nil
 }
 
-func checkDatabaseAccessible(dbUri string) error {
+// newMapStorage opens storage for a map with the storage system selected by the flags.
+func newMapStorage(id trillian.MapID) (storage.MapStorage, error) {
+	switch *storageSystemFlag {
+	case "mysql":
+		return mysql.NewMapStorage(id, *mysqlUriFlag)
+	case "sqlite":
+		return sqlite.NewMapStorage(id, *sqliteFileFlag, *sqliteBusyTimeoutFlag)
+	default:
+		return nil, fmt.Errorf("unknown storage_system: %s", *storageSystemFlag)
+	}
+}
+
+func checkDatabaseAccessible() error {
 	// TODO(Martin2112): Have to pass a tree ID when we just want metadata. API mismatch
-	storage, err := mysql.NewMapStorage(trillian.MapID{[]byte("TODO"), int64(0)}, dbUri)
+	storage, err := newMapStorage(trillian.MapID{[]byte("TODO"), int64(0)})
 
 	if err != nil {
 		// This is probably something fundamentally wrong
@@ -79,6 +103,65 @@ func startRpcServer(listener net.Listener, port int, provider vmap.MapStoragePro
 	return grpcServer
 }
 
+// parseMapRootLogs parses the value of --map_root_logs into a map from map IDs to the IDs of
+// the logs their roots are appended to.
+func parseMapRootLogs(value string) (map[int64]int64, error) {
+	links := make(map[int64]int64)
+
+	for _, pair := range strings.Split(value, ",") {
+		ids := strings.Split(strings.TrimSpace(pair), ":")
+
+		if len(ids) != 2 {
+			return nil, fmt.Errorf("invalid map_id:log_id pair: %s", pair)
+		}
+
+		mapID, err := strconv.ParseInt(ids[0], 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid map ID in %s: %v", pair, err)
+		}
+
+		logID, err := strconv.ParseInt(ids[1], 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid log ID in %s: %v", pair, err)
+		}
+
+		if _, ok := links[mapID]; ok {
+			return nil, fmt.Errorf("map %d is linked to more than one log", mapID)
+		}
+
+		links[mapID] = logID
+	}
+
+	return links, nil
+}
+
+// startMapRootPublishers starts appending the roots of the maps in --map_root_logs to their
+// logs until done is closed.
+func startMapRootPublishers(done <-chan struct{}) error {
+	links, err := parseMapRootLogs(*mapRootLogsFlag)
+
+	if err != nil {
+		return err
+	}
+
+	conn, err := grpc.Dial(*logServerFlag, grpc.WithInsecure())
+
+	if err != nil {
+		return err
+	}
+
+	client := trillian.NewTrillianLogClient(conn)
+
+	for mapID, logID := range links {
+		publisher := vmap.NewMapRootPublisher(mapID, logID, simpleMySqlStorageProvider, client)
+		go publisher.Run(done, *mapRootPublishIntervalFlag, *rpcDeadlineFlag, *mapRootPublishBatchSizeFlag)
+	}
+
+	return nil
+}
+
 func awaitSignal(rpcServer *grpc.Server) {
 	// Arrange notification for the standard set of signals used to terminate a server
 	sigs := make(chan os.Signal, 1)
@@ -100,11 +183,17 @@ func main() {
 	glog.Info("**** Map Server Starting ****")
 
 	// First make sure we can access the database, quit if not
-	if err := checkDatabaseAccessible(*mysqlUriFlag); err != nil {
-		glog.Errorf("Could not access storage, check db configuration and flags")
+	if err := checkDatabaseAccessible(); err != nil {
+		glog.Errorf("Could not access storage, check db configuration and flags: %v", err)
 		os.Exit(1)
 	}
 
+	if *storageSystemFlag == "mysql" && *checkSchemaFlag {
+		if err := mysql.CheckSchema(*mysqlUriFlag); err != nil {
+			glog.Fatalf("Storage schema check failed: %v", err)
+		}
+	}
+
 	// Load up our private key, exit if this fails to work
 	// TODO(Martin2112): This will need to be changed for multi tenant as we'll need at
 	// least one key per tenant, possibly more.
@@ -124,6 +213,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	if len(*mapRootLogsFlag) > 0 {
+		if err := startMapRootPublishers(done); err != nil {
+			glog.Fatalf("Failed to start publishing map roots: %v", err)
+		}
+	}
+
 	// Bring up the RPC server and then block until we get a signal to stop
 	rpcServer := startRpcServer(lis, *serverPortFlag, simpleMySqlStorageProvider)
 	go awaitSignal(rpcServer)
@@ -0,0 +1,190 @@
+package vmap
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
+)
+
+var mapID1 = int64(1)
+var mapLeaf1 = trillian.MapLeaf{LeafHash: []byte("hash1"), LeafValue: []byte("value1"), ExtraData: []byte("extra1")}
+var mapRoot1 = trillian.SignedMapRoot{MapId: []byte("map1"), MapRevision: 5, RootHash: []byte("A NICE HASH")}
+
+func mockStorageProviderForMap(mockStorage storage.MapStorage) MapStorageProviderFunc {
+	return func(id int64) (storage.MapStorage, error) {
+		if id != mapID1 {
+			return nil, errors.New("unknown map")
+		}
+		return mockStorage, nil
+	}
+}
+
+func TestGetLeavesOmitInclusion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockMapStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyMapTX(ctrl)
+	mockStorage.EXPECT().Snapshot().Return(mockTx, nil)
+	// No GetMerkleNodes call is expected because no proofs should be built
+	mockTx.EXPECT().Get(int64(3), gomock.Any()).Return(mapLeaf1, nil)
+	mockTx.EXPECT().Get(int64(3), gomock.Any()).Return(trillian.MapLeaf{}, storage.ErrNoSuchKey)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianMapServer(mockStorageProviderForMap(mockStorage))
+	resp, err := server.GetLeaves(context.Background(), &trillian.GetMapLeavesRequest{MapId: mapID1, Key: [][]byte{[]byte("key1"), []byte("key2")}, Revision: 3, OmitInclusion: true})
+
+	if err != nil {
+		t.Fatalf("GetLeaves failed: %v", err)
+	}
+
+	if got, want := len(resp.KeyValue), 2; got != want {
+		t.Fatalf("Got %d values, expected %d", got, want)
+	}
+	if got, want := *resp.KeyValue[0].KeyValue.Value, mapLeaf1; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got value %v, expected %v", got, want)
+	}
+	if got, want := *resp.KeyValue[1].KeyValue.Value, (trillian.MapLeaf{}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got value %v for missing key, expected %v", got, want)
+	}
+	for _, kv := range resp.KeyValue {
+		if len(kv.Inclusion) != 0 {
+			t.Fatalf("Got inclusion proof for %s when proofs were omitted", kv.KeyValue.Key)
+		}
+	}
+}
+
+func TestGetLeavesWithInclusion(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockMapStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyMapTX(ctrl)
+	mockStorage.EXPECT().Snapshot().Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedMapRoot().Return(mapRoot1, nil)
+	mockTx.EXPECT().Get(mapRoot1.MapRevision, gomock.Any()).Return(mapLeaf1, nil)
+	// An empty tree, so the proof will be entirely made of null hashes
+	mockTx.EXPECT().GetMerkleNodes(mapRoot1.MapRevision, gomock.Any()).Return([]storage.Node{}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianMapServer(mockStorageProviderForMap(mockStorage))
+	resp, err := server.GetLeaves(context.Background(), &trillian.GetMapLeavesRequest{MapId: mapID1, Key: [][]byte{[]byte("key1")}, Revision: -1})
+
+	if err != nil {
+		t.Fatalf("GetLeaves failed: %v", err)
+	}
+
+	if got, want := len(resp.KeyValue), 1; got != want {
+		t.Fatalf("Got %d values, expected %d", got, want)
+	}
+	if got, want := *resp.KeyValue[0].KeyValue.Value, mapLeaf1; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got value %v, expected %v", got, want)
+	}
+	// One proof entry for each level of the sparse tree
+	if got, want := len(resp.KeyValue[0].Inclusion), 256; got != want {
+		t.Fatalf("Got inclusion proof of length %d, expected %d", got, want)
+	}
+	// Auditors need the root the proofs are against
+	if got, want := resp.MapRoot, &mapRoot1; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got map root %v, expected %v", got, want)
+	}
+}
+
+func TestGetLeavesInclusionFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockMapStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyMapTX(ctrl)
+	mockStorage.EXPECT().Snapshot().Return(mockTx, nil)
+	mockTx.EXPECT().Get(int64(3), gomock.Any()).Return(mapLeaf1, nil)
+	mockTx.EXPECT().GetMerkleNodes(int64(3), gomock.Any()).Return(nil, errors.New("getmerklenodes"))
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianMapServer(mockStorageProviderForMap(mockStorage))
+	_, err := server.GetLeaves(context.Background(), &trillian.GetMapLeavesRequest{MapId: mapID1, Key: [][]byte{[]byte("key1")}, Revision: 3})
+
+	if err == nil {
+		t.Fatal("GetLeaves ignored proof error")
+	}
+}
+
+func TestGetLeafHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mapLeaf2 := trillian.MapLeaf{LeafHash: []byte("hash2"), LeafValue: []byte("value2")}
+	mapRoot0 := trillian.SignedMapRoot{MapId: []byte("map1"), MapRevision: 2, RootHash: []byte("AN OLDER HASH")}
+
+	mockStorage := storage.NewMockMapStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyMapTX(ctrl)
+	mockStorage.EXPECT().Snapshot().Return(mockTx, nil)
+	mockTx.EXPECT().LatestSignedMapRoot().Return(mapRoot1, nil)
+	mockTx.EXPECT().GetHistory(mapRoot1.MapRevision, gomock.Any()).Return([]storage.MapLeafRevision{
+		{Revision: 2, Leaf: mapLeaf2},
+		{Revision: 5, Leaf: mapLeaf1},
+	}, nil)
+	// Each value is proved against the root at the revision it was set
+	mockTx.EXPECT().GetSignedMapRootAtRevision(int64(2)).Return(mapRoot0, nil)
+	mockTx.EXPECT().GetMerkleNodes(int64(2), gomock.Any()).Return([]storage.Node{}, nil)
+	mockTx.EXPECT().GetSignedMapRootAtRevision(int64(5)).Return(mapRoot1, nil)
+	mockTx.EXPECT().GetMerkleNodes(int64(5), gomock.Any()).Return([]storage.Node{}, nil)
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianMapServer(mockStorageProviderForMap(mockStorage))
+	resp, err := server.GetLeafHistory(context.Background(), &trillian.GetMapLeafHistoryRequest{MapId: mapID1, Key: []byte("key1"), Revision: -1})
+
+	if err != nil {
+		t.Fatalf("GetLeafHistory failed: %v", err)
+	}
+
+	if got, want := len(resp.History), 2; got != want {
+		t.Fatalf("Got %d history entries, expected %d", got, want)
+	}
+	for i, want := range []struct {
+		revision int64
+		value    trillian.MapLeaf
+		root     trillian.SignedMapRoot
+	}{
+		{2, mapLeaf2, mapRoot0},
+		{5, mapLeaf1, mapRoot1},
+	} {
+		h := resp.History[i]
+		if got := h.MapRevision; got != want.revision {
+			t.Errorf("Got entry %d at revision %d, expected %d", i, got, want.revision)
+		}
+		if got := *h.Value; !reflect.DeepEqual(got, want.value) {
+			t.Errorf("Got entry %d value %v, expected %v", i, got, want.value)
+		}
+		if got := *h.MapRoot; !reflect.DeepEqual(got, want.root) {
+			t.Errorf("Got entry %d map root %v, expected %v", i, got, want.root)
+		}
+		if got, want := len(h.Inclusion), 256; got != want {
+			t.Errorf("Got entry %d inclusion proof of length %d, expected %d", i, got, want)
+		}
+	}
+}
+
+func TestGetLeafHistoryMissingRoot(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := storage.NewMockMapStorage(ctrl)
+	mockTx := storage.NewMockReadOnlyMapTX(ctrl)
+	mockStorage.EXPECT().Snapshot().Return(mockTx, nil)
+	mockTx.EXPECT().GetHistory(int64(3), gomock.Any()).Return([]storage.MapLeafRevision{{Revision: 3, Leaf: mapLeaf1}}, nil)
+	mockTx.EXPECT().GetSignedMapRootAtRevision(int64(3)).Return(trillian.SignedMapRoot{}, errors.New("no root"))
+	mockTx.EXPECT().Commit().Return(nil)
+
+	server := NewTrillianMapServer(mockStorageProviderForMap(mockStorage))
+	_, err := server.GetLeafHistory(context.Background(), &trillian.GetMapLeafHistoryRequest{MapId: mapID1, Key: []byte("key1"), Revision: 3})
+
+	if err == nil {
+		t.Fatal("GetLeafHistory returned a value that couldn't be proved")
+	}
+}
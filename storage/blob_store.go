@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BlobStore holds opaque blobs outside the main storage, addressed by key. It's used for
+// data that is too large to keep inline in database rows.
+type BlobStore interface {
+	// Put stores data under key, replacing anything previously stored there.
+	Put(key string, data []byte) error
+	// Get returns the data stored under key. It's an error if there is no such blob.
+	Get(key string) ([]byte, error)
+}
+
+// FileBlobStore is a BlobStore that keeps each blob in a file under a root directory.
+type FileBlobStore struct {
+	dir string
+}
+
+// NewFileBlobStore creates a FileBlobStore rooted at dir, which is created if necessary.
+func NewFileBlobStore(dir string) (*FileBlobStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &FileBlobStore{dir: dir}, nil
+}
+
+// pathForKey maps a key to a file under the root directory. Keys can contain '/' to spread
+// blobs across subdirectories but must not escape the root.
+func (f *FileBlobStore) pathForKey(key string) (string, error) {
+	if len(key) == 0 || strings.HasPrefix(key, "/") {
+		return "", fmt.Errorf("invalid blob key: %q", key)
+	}
+
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", fmt.Errorf("invalid blob key: %q", key)
+		}
+	}
+
+	return filepath.Join(f.dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes the blob to a temporary file, syncs it and renames it into place so readers never
+// see a partially written blob, even after a crash.
+func (f *FileBlobStore) Put(key string, data []byte) error {
+	path, err := f.pathForKey(key)
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-blob-")
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
+
+// Get reads the blob stored under key.
+func (f *FileBlobStore) Get(key string) ([]byte, error) {
+	path, err := f.pathForKey(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		return nil, errors.New("blob not found: " + key)
+	}
+
+	return data, err
+}
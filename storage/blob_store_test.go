@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func newTestFileBlobStore(t *testing.T) (*FileBlobStore, string) {
+	dir, err := ioutil.TempDir("", "blobstore")
+
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	bs, err := NewFileBlobStore(dir)
+
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Failed to create blob store: %v", err)
+	}
+
+	return bs, dir
+}
+
+func TestFileBlobStorePutGet(t *testing.T) {
+	bs, dir := newTestFileBlobStore(t)
+	defer os.RemoveAll(dir)
+
+	if err := bs.Put("1/abcd", []byte("first")); err != nil {
+		t.Fatalf("Put()=%v", err)
+	}
+
+	got, err := bs.Get("1/abcd")
+
+	if err != nil {
+		t.Fatalf("Get()=%v", err)
+	}
+	if want := []byte("first"); !bytes.Equal(got, want) {
+		t.Fatalf("Get()=%v, want %v", got, want)
+	}
+
+	// A second put replaces the blob
+	if err := bs.Put("1/abcd", []byte("second")); err != nil {
+		t.Fatalf("Put()=%v", err)
+	}
+
+	got, err = bs.Get("1/abcd")
+
+	if err != nil {
+		t.Fatalf("Get()=%v", err)
+	}
+	if want := []byte("second"); !bytes.Equal(got, want) {
+		t.Fatalf("Get()=%v, want %v", got, want)
+	}
+}
+
+func TestFileBlobStoreGetMissing(t *testing.T) {
+	bs, dir := newTestFileBlobStore(t)
+	defer os.RemoveAll(dir)
+
+	if _, err := bs.Get("nothere"); err == nil {
+		t.Fatal("Get() for missing blob returned no error")
+	}
+}
+
+func TestFileBlobStoreRejectsBadKeys(t *testing.T) {
+	bs, dir := newTestFileBlobStore(t)
+	defer os.RemoveAll(dir)
+
+	for _, key := range []string{"", "/abs", "../escape", "a/../../b", "a//b", "a/./b"} {
+		if err := bs.Put(key, []byte("data")); err == nil {
+			t.Errorf("Put(%q) returned no error", key)
+		}
+		if _, err := bs.Get(key); err == nil {
+			t.Errorf("Get(%q) returned no error", key)
+		}
+	}
+}
@@ -87,6 +87,15 @@ func splitNodeID(id storage.NodeID) ([]byte, Suffix) {
 	return r, s
 }
 
+// SubtreeID returns the ID of the subtree that holds the node with the given ID. It's the ID
+// that's passed to a GetSubtreeFunc when the subtree isn't already cached.
+func SubtreeID(id storage.NodeID) storage.NodeID {
+	px, _ := splitNodeID(id)
+	subID := id
+	subID.PrefixLenBits = len(px) * 8
+	return subID
+}
+
 // GetNodeHash retrieves the previously written hash and corresponding tree
 // revision for the given node ID.
 func (s *SubtreeCache) GetNodeHash(id storage.NodeID, getSubtree GetSubtreeFunc) (trillian.Hash, error) {
@@ -102,10 +111,8 @@ func (s *SubtreeCache) getNodeHashUnderLock(id storage.NodeID, getSubtree GetSub
 	c := s.subtrees[prefixKey]
 	if c == nil {
 		// Cache miss, so we'll try to fetch from storage.
-		subID := id
-		subID.PrefixLenBits = len(px) * 8
 		var err error
-		c, err = getSubtree(subID)
+		c, err = getSubtree(SubtreeID(id))
 		if err != nil {
 			return nil, err
 		}
@@ -60,6 +60,22 @@ func TestSplitNodeID(t *testing.T) {
 	}
 }
 
+func TestSubtreeID(t *testing.T) {
+	for i, v := range splitTestVector {
+		n := storage.NewNodeIDFromHash(v.inPath)
+		n.PrefixLenBits = v.inPathLenBits
+
+		id := SubtreeID(n)
+		if expected, got := len(v.outPrefix)*8, id.PrefixLenBits; expected != got {
+			t.Fatalf("(test %d) Expected subtree ID of %d bits, got %d", i, expected, got)
+		}
+
+		if expected, got := v.outPrefix, id.Path[:id.PrefixLenBits/8]; !bytes.Equal(expected, got) {
+			t.Fatalf("(test %d) Expected subtree prefix %x, got %x", i, expected, got)
+		}
+	}
+}
+
 func TestCacheFillOnlyReadsSubtrees(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
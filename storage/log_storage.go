@@ -23,6 +23,9 @@ type LogTX interface {
 	LeafQueuer
 	LeafDequeuer
 	LogMetadata
+	CompactTreeStore
+	SequenceRangeReserver
+	TreeMetadataStore
 }
 
 // ReadOnlyLogStorage represents a narrowed read-only view into a LogStorage.
@@ -43,6 +46,22 @@ type LogStorage interface {
 	// the returned object, and values read through it should only be propagated
 	// if Commit returns without error.
 	Begin() (LogTX, error)
+
+	// LeafHashStrategy returns how leaf data is hashed to form leaf hashes in this log. It is
+	// fixed when the tree is created.
+	LeafHashStrategy() trillian.LeafHashStrategy
+
+	// HashAlgorithm returns the hash function of the leaves and nodes of this log. It is fixed
+	// when the tree is created.
+	HashAlgorithm() trillian.HashAlgorithm
+
+	// TreeDepth returns the number of levels below the root of this log, which limits how many
+	// leaves it can hold, see LogTreeCapacity. It is fixed when the tree is created.
+	TreeDepth() int
+
+	// Close releases the resources held by the storage, such as database connections. Any
+	// transactions should be finished first. The storage must not be used afterwards.
+	Close() error
 }
 
 // LeafQueuer provides a write-only interface for the queueing (but not necesarily integration) of leaves.
@@ -71,12 +90,76 @@ type LeafReader interface {
 	// but different sequence numbers. If orderBySequence is true then the returned data
 	// will be in sequence number order.
 	GetLeavesByHash(leafHashes []trillian.Hash, orderBySequence bool) ([]trillian.LogLeaf, error)
+	// GetLeavesByTimestamp returns up to limit sequenced leaves that were integrated into the
+	// tree at or after startNanos and before endNanos, in sequence number order. Leaves
+	// before startIndex are skipped, which allows callers to page through the results.
+	GetLeavesByTimestamp(startNanos, endNanos, startIndex int64, limit int) ([]trillian.LogLeaf, error)
+}
+
+// ProofReader is an optional interface for log transactions that can fetch all the nodes of a
+// proof more efficiently than by passing their IDs to GetMerkleNodes. As with GetMerkleNodes,
+// nodes that aren't stored are left out of the results.
+type ProofReader interface {
+	// GetInclusionProofNodes returns the nodes at treeRevision of the inclusion proof for the
+	// leaf at leafIndex in the tree of treeSize, in the order given by
+	// merkle.CalcInclusionProofNodeAddresses.
+	GetInclusionProofNodes(treeRevision, treeSize, leafIndex int64) ([]Node, error)
+	// GetConsistencyProofNodes returns the nodes at treeRevision of the consistency proof
+	// between the trees of previousTreeSize and treeSize, in the order given by
+	// merkle.CalcConsistencyProofNodeAddresses.
+	GetConsistencyProofNodes(treeRevision, previousTreeSize, treeSize int64) ([]Node, error)
+}
+
+// ExtraDataSkipper is an optional interface for log transactions that can read leaves without
+// reading their ExtraData, which is often much larger than the leaf data and may be held
+// outside the database.
+type ExtraDataSkipper interface {
+	// GetLeavesByIndexWithoutExtraData is the same as GetLeavesByIndex except that the leaves
+	// are returned with no ExtraData.
+	GetLeavesByIndexWithoutExtraData(leaves []int64) ([]trillian.LogLeaf, error)
+}
+
+// TreeStatsReader is an optional interface for log transactions that can report how much
+// storage a log is using. The stats are kept up to date incrementally, only the rows written
+// since the last call are read.
+type TreeStatsReader interface {
+	// GetTreeStorageStats counts the rows covered by the latest signed root that haven't been
+	// counted yet, stores the updated stats and returns them for the leaf, node and root
+	// tables in that order.
+	GetTreeStorageStats() ([]*trillian.TableStorageStats, error)
+}
+
+// SequencingController is an optional interface for log transactions that can pause the
+// sequencing of a log. The state is persisted so that it's respected by every server sharing
+// the storage and survives restarts. Leaves can still be queued while sequencing is paused.
+type SequencingController interface {
+	// SequencingEnabled returns false if sequencing of the log has been paused. Logs are
+	// sequenced unless they've been paused.
+	SequencingEnabled() (bool, error)
+	// SetSequencingEnabled pauses or resumes sequencing of the log.
+	SetSequencingEnabled(enabled bool) error
+}
+
+// CommitTokenStore is an optional interface for log transactions that can record a token
+// along with their other writes. If Commit fails without saying whether the transaction was
+// committed, e.g. because the connection was lost before the database acknowledged it, a later
+// transaction can read the token back to find out. Only the token of the latest transaction
+// that stored one is kept for each log.
+type CommitTokenStore interface {
+	// StoreCommitToken records token as the log's commit token when the transaction commits.
+	StoreCommitToken(token []byte) error
+	// LatestCommitToken returns the token stored by the latest committed transaction of the
+	// log, or nil if none has stored one.
+	LatestCommitToken() ([]byte, error)
 }
 
 // LogRootReader provides an interface for reading SignedLogRoots.
 type LogRootReader interface {
 	// LatestSignedLogRoot returns the most recent SignedLogRoot, if any.
 	LatestSignedLogRoot() (trillian.SignedLogRoot, error)
+	// GetSignedLogRootAtRevision returns the SignedLogRoot that was stored for a tree revision.
+	// It is an error if there isn't one.
+	GetSignedLogRootAtRevision(treeRevision int64) (trillian.SignedLogRoot, error)
 }
 
 // LogRootWriter provides an interface for storing new SignedLogRoots.
@@ -85,6 +168,51 @@ type LogRootWriter interface {
 	StoreSignedLogRoot(root trillian.SignedLogRoot) error
 }
 
+// CompactTreeStore persists the state of a log's compact Merkle tree so that it can be restored
+// without fetching the tree nodes one at a time.
+type CompactTreeStore interface {
+	// LatestCompactTree returns the most recently stored compact tree state. If none has been
+	// stored the result is empty, with a tree size of zero.
+	LatestCompactTree() (CompactTreeProto, error)
+	// StoreCompactTree replaces the stored compact tree state for the log.
+	StoreCompactTree(state CompactTreeProto) error
+}
+
+// TreeMetadataStore holds the human readable metadata that identifies a log to the people
+// running it. The metadata doesn't affect the tree.
+type TreeMetadataStore interface {
+	// GetTreeMetadata returns the log's metadata, including when the log was created.
+	GetTreeMetadata() (trillian.TreeMetadata, error)
+	// SetTreeMetadata replaces the log's metadata. The creation time can't be changed and is
+	// ignored.
+	SetTreeMetadata(metadata trillian.TreeMetadata) error
+}
+
+// SequenceRangeReserver lets several sequencers integrate leaves into a log concurrently by
+// giving each an exclusive range of sequence numbers. Every reservation has a fencing token,
+// which is larger than that of any earlier reservation in the log. A range can be taken over
+// from a sequencer that has failed, which gives it a new token, and from then on writes made
+// with the old token fail with ErrFenced so the two sequencers can't both write to the range.
+type SequenceRangeReserver interface {
+	// ReserveSequenceRange reserves for sequencerID the count sequence numbers that follow the
+	// last one that was reserved or sequenced in the log.
+	ReserveSequenceRange(sequencerID string, count int64) (SequenceRange, error)
+	// TakeOverSequenceRange gives the reserved range r to sequencerID with a new fencing
+	// token. It fails with ErrFenced if r's token isn't current, e.g. because someone else
+	// took it over first.
+	TakeOverSequenceRange(r SequenceRange, sequencerID string) (SequenceRange, error)
+	// UpdateSequencedLeavesInRange is UpdateSequencedLeaves for a sequencer holding r. It fails
+	// with ErrFenced if r's token isn't current, and with a different error if any leaf's
+	// sequence number is outside r.
+	UpdateSequencedLeavesInRange(r SequenceRange, leaves []trillian.LogLeaf) error
+	// ReleaseSequenceRange removes the reservation of r once its sequencer has finished with
+	// it. It fails with ErrFenced if r's token isn't current.
+	ReleaseSequenceRange(r SequenceRange) error
+	// GetSequenceRanges returns the ranges that are currently reserved, in sequence number
+	// order.
+	GetSequenceRanges() ([]SequenceRange, error)
+}
+
 // LogMetadata provides access to information about the logs in storage
 type LogMetadata interface {
 	// GetActiveLogs returns a list of the IDs of all the logs that are configured in storage
@@ -62,12 +62,23 @@ type Getter interface {
 	// Get retrieves the value associates with key, if any, at the specified revision.
 	// Setting revision to -1 will fetch the latest revision.
 	Get(revision int64, keyHash trillian.Hash) (trillian.MapLeaf, error)
+	// GetHistory retrieves every value set for key at or before the specified revision, in
+	// revision order.
+	GetHistory(revision int64, keyHash trillian.Hash) ([]MapLeafRevision, error)
+}
+
+// MapLeafRevision is a value that was set for a map key, and the revision it was set at.
+type MapLeafRevision struct {
+	Revision int64
+	Leaf     trillian.MapLeaf
 }
 
 // MapRootReader provides access to the map roots.
 type MapRootReader interface {
 	// LatestSignedMapRoot returns the most recently created SignedMapRoot.
 	LatestSignedMapRoot() (trillian.SignedMapRoot, error)
+	// GetSignedMapRootAtRevision returns the SignedMapRoot created for revision.
+	GetSignedMapRootAtRevision(revision int64) (trillian.SignedMapRoot, error)
 }
 
 // MapRootWriter allows the storage of new SignedMapRoots
@@ -94,6 +94,17 @@ func (_mr *_MockLogTXRecorder) GetLeavesByIndex(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByIndex", arg0)
 }
 
+func (_m *MockLogTX) GetLeavesByTimestamp(_param0 int64, _param1 int64, _param2 int64, _param3 int) ([]trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "GetLeavesByTimestamp", _param0, _param1, _param2, _param3)
+	ret0, _ := ret[0].([]trillian.LogLeaf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTXRecorder) GetLeavesByTimestamp(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByTimestamp", arg0, arg1, arg2, arg3)
+}
+
 func (_m *MockLogTX) GetMerkleNodes(_param0 int64, _param1 []NodeID) ([]Node, error) {
 	ret := _m.ctrl.Call(_m, "GetMerkleNodes", _param0, _param1)
 	ret0, _ := ret[0].([]Node)
@@ -105,6 +116,17 @@ func (_mr *_MockLogTXRecorder) GetMerkleNodes(arg0, arg1 interface{}) *gomock.Ca
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetMerkleNodes", arg0, arg1)
 }
 
+func (_m *MockLogTX) GetSequenceRanges() ([]SequenceRange, error) {
+	ret := _m.ctrl.Call(_m, "GetSequenceRanges")
+	ret0, _ := ret[0].([]SequenceRange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTXRecorder) GetSequenceRanges() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSequenceRanges")
+}
+
 func (_m *MockLogTX) GetSequencedLeafCount() (int64, error) {
 	ret := _m.ctrl.Call(_m, "GetSequencedLeafCount")
 	ret0, _ := ret[0].(int64)
@@ -116,6 +138,17 @@ func (_mr *_MockLogTXRecorder) GetSequencedLeafCount() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSequencedLeafCount")
 }
 
+func (_m *MockLogTX) GetSignedLogRootAtRevision(_param0 int64) (trillian.SignedLogRoot, error) {
+	ret := _m.ctrl.Call(_m, "GetSignedLogRootAtRevision", _param0)
+	ret0, _ := ret[0].(trillian.SignedLogRoot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTXRecorder) GetSignedLogRootAtRevision(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSignedLogRootAtRevision", arg0)
+}
+
 func (_m *MockLogTX) GetTreeRevisionAtSize(_param0 int64) (int64, error) {
 	ret := _m.ctrl.Call(_m, "GetTreeRevisionAtSize", _param0)
 	ret0, _ := ret[0].(int64)
@@ -127,6 +160,17 @@ func (_mr *_MockLogTXRecorder) GetTreeRevisionAtSize(arg0 interface{}) *gomock.C
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetTreeRevisionAtSize", arg0)
 }
 
+func (_m *MockLogTX) GetTreeMetadata() (trillian.TreeMetadata, error) {
+	ret := _m.ctrl.Call(_m, "GetTreeMetadata")
+	ret0, _ := ret[0].(trillian.TreeMetadata)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTXRecorder) GetTreeMetadata() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetTreeMetadata")
+}
+
 func (_m *MockLogTX) IsOpen() bool {
 	ret := _m.ctrl.Call(_m, "IsOpen")
 	ret0, _ := ret[0].(bool)
@@ -137,6 +181,17 @@ func (_mr *_MockLogTXRecorder) IsOpen() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "IsOpen")
 }
 
+func (_m *MockLogTX) LatestCompactTree() (CompactTreeProto, error) {
+	ret := _m.ctrl.Call(_m, "LatestCompactTree")
+	ret0, _ := ret[0].(CompactTreeProto)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTXRecorder) LatestCompactTree() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "LatestCompactTree")
+}
+
 func (_m *MockLogTX) LatestSignedLogRoot() (trillian.SignedLogRoot, error) {
 	ret := _m.ctrl.Call(_m, "LatestSignedLogRoot")
 	ret0, _ := ret[0].(trillian.SignedLogRoot)
@@ -158,6 +213,27 @@ func (_mr *_MockLogTXRecorder) QueueLeaves(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "QueueLeaves", arg0)
 }
 
+func (_m *MockLogTX) ReleaseSequenceRange(_param0 SequenceRange) error {
+	ret := _m.ctrl.Call(_m, "ReleaseSequenceRange", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockLogTXRecorder) ReleaseSequenceRange(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ReleaseSequenceRange", arg0)
+}
+
+func (_m *MockLogTX) ReserveSequenceRange(_param0 string, _param1 int64) (SequenceRange, error) {
+	ret := _m.ctrl.Call(_m, "ReserveSequenceRange", _param0, _param1)
+	ret0, _ := ret[0].(SequenceRange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTXRecorder) ReserveSequenceRange(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ReserveSequenceRange", arg0, arg1)
+}
+
 func (_m *MockLogTX) Rollback() error {
 	ret := _m.ctrl.Call(_m, "Rollback")
 	ret0, _ := ret[0].(error)
@@ -178,6 +254,26 @@ func (_mr *_MockLogTXRecorder) SetMerkleNodes(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetMerkleNodes", arg0)
 }
 
+func (_m *MockLogTX) SetTreeMetadata(_param0 trillian.TreeMetadata) error {
+	ret := _m.ctrl.Call(_m, "SetTreeMetadata", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockLogTXRecorder) SetTreeMetadata(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetTreeMetadata", arg0)
+}
+
+func (_m *MockLogTX) StoreCompactTree(_param0 CompactTreeProto) error {
+	ret := _m.ctrl.Call(_m, "StoreCompactTree", _param0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockLogTXRecorder) StoreCompactTree(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "StoreCompactTree", arg0)
+}
+
 func (_m *MockLogTX) StoreSignedLogRoot(_param0 trillian.SignedLogRoot) error {
 	ret := _m.ctrl.Call(_m, "StoreSignedLogRoot", _param0)
 	ret0, _ := ret[0].(error)
@@ -188,6 +284,17 @@ func (_mr *_MockLogTXRecorder) StoreSignedLogRoot(arg0 interface{}) *gomock.Call
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "StoreSignedLogRoot", arg0)
 }
 
+func (_m *MockLogTX) TakeOverSequenceRange(_param0 SequenceRange, _param1 string) (SequenceRange, error) {
+	ret := _m.ctrl.Call(_m, "TakeOverSequenceRange", _param0, _param1)
+	ret0, _ := ret[0].(SequenceRange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockLogTXRecorder) TakeOverSequenceRange(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "TakeOverSequenceRange", arg0, arg1)
+}
+
 func (_m *MockLogTX) UpdateSequencedLeaves(_param0 []trillian.LogLeaf) error {
 	ret := _m.ctrl.Call(_m, "UpdateSequencedLeaves", _param0)
 	ret0, _ := ret[0].(error)
@@ -198,6 +305,16 @@ func (_mr *_MockLogTXRecorder) UpdateSequencedLeaves(arg0 interface{}) *gomock.C
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "UpdateSequencedLeaves", arg0)
 }
 
+func (_m *MockLogTX) UpdateSequencedLeavesInRange(_param0 SequenceRange, _param1 []trillian.LogLeaf) error {
+	ret := _m.ctrl.Call(_m, "UpdateSequencedLeavesInRange", _param0, _param1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockLogTXRecorder) UpdateSequencedLeavesInRange(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "UpdateSequencedLeavesInRange", arg0, arg1)
+}
+
 func (_m *MockLogTX) WriteRevision() int64 {
 	ret := _m.ctrl.Call(_m, "WriteRevision")
 	ret0, _ := ret[0].(int64)
@@ -250,6 +367,17 @@ func (_mr *_MockMapTXRecorder) Get(arg0, arg1 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Get", arg0, arg1)
 }
 
+func (_m *MockMapTX) GetHistory(_param0 int64, _param1 trillian.Hash) ([]MapLeafRevision, error) {
+	ret := _m.ctrl.Call(_m, "GetHistory", _param0, _param1)
+	ret0, _ := ret[0].([]MapLeafRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMapTXRecorder) GetHistory(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetHistory", arg0, arg1)
+}
+
 func (_m *MockMapTX) GetMerkleNodes(_param0 int64, _param1 []NodeID) ([]Node, error) {
 	ret := _m.ctrl.Call(_m, "GetMerkleNodes", _param0, _param1)
 	ret0, _ := ret[0].([]Node)
@@ -261,6 +389,17 @@ func (_mr *_MockMapTXRecorder) GetMerkleNodes(arg0, arg1 interface{}) *gomock.Ca
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetMerkleNodes", arg0, arg1)
 }
 
+func (_m *MockMapTX) GetSignedMapRootAtRevision(_param0 int64) (trillian.SignedMapRoot, error) {
+	ret := _m.ctrl.Call(_m, "GetSignedMapRootAtRevision", _param0)
+	ret0, _ := ret[0].(trillian.SignedMapRoot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockMapTXRecorder) GetSignedMapRootAtRevision(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSignedMapRootAtRevision", arg0)
+}
+
 func (_m *MockMapTX) GetTreeRevisionAtSize(_param0 int64) (int64, error) {
 	ret := _m.ctrl.Call(_m, "GetTreeRevisionAtSize", _param0)
 	ret0, _ := ret[0].(int64)
@@ -396,6 +535,17 @@ func (_mr *_MockReadOnlyLogTXRecorder) GetLeavesByIndex(arg0 interface{}) *gomoc
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByIndex", arg0)
 }
 
+func (_m *MockReadOnlyLogTX) GetLeavesByTimestamp(_param0 int64, _param1 int64, _param2 int64, _param3 int) ([]trillian.LogLeaf, error) {
+	ret := _m.ctrl.Call(_m, "GetLeavesByTimestamp", _param0, _param1, _param2, _param3)
+	ret0, _ := ret[0].([]trillian.LogLeaf)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyLogTXRecorder) GetLeavesByTimestamp(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetLeavesByTimestamp", arg0, arg1, arg2, arg3)
+}
+
 func (_m *MockReadOnlyLogTX) GetMerkleNodes(_param0 int64, _param1 []NodeID) ([]Node, error) {
 	ret := _m.ctrl.Call(_m, "GetMerkleNodes", _param0, _param1)
 	ret0, _ := ret[0].([]Node)
@@ -418,6 +568,17 @@ func (_mr *_MockReadOnlyLogTXRecorder) GetSequencedLeafCount() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSequencedLeafCount")
 }
 
+func (_m *MockReadOnlyLogTX) GetSignedLogRootAtRevision(_param0 int64) (trillian.SignedLogRoot, error) {
+	ret := _m.ctrl.Call(_m, "GetSignedLogRootAtRevision", _param0)
+	ret0, _ := ret[0].(trillian.SignedLogRoot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyLogTXRecorder) GetSignedLogRootAtRevision(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSignedLogRootAtRevision", arg0)
+}
+
 func (_m *MockReadOnlyLogTX) GetTreeRevisionAtSize(_param0 int64) (int64, error) {
 	ret := _m.ctrl.Call(_m, "GetTreeRevisionAtSize", _param0)
 	ret0, _ := ret[0].(int64)
@@ -482,6 +643,17 @@ func (_mr *_MockReadOnlyMapTXRecorder) Get(arg0, arg1 interface{}) *gomock.Call
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Get", arg0, arg1)
 }
 
+func (_m *MockReadOnlyMapTX) GetHistory(_param0 int64, _param1 trillian.Hash) ([]MapLeafRevision, error) {
+	ret := _m.ctrl.Call(_m, "GetHistory", _param0, _param1)
+	ret0, _ := ret[0].([]MapLeafRevision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyMapTXRecorder) GetHistory(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetHistory", arg0, arg1)
+}
+
 func (_m *MockReadOnlyMapTX) GetMerkleNodes(_param0 int64, _param1 []NodeID) ([]Node, error) {
 	ret := _m.ctrl.Call(_m, "GetMerkleNodes", _param0, _param1)
 	ret0, _ := ret[0].([]Node)
@@ -493,6 +665,17 @@ func (_mr *_MockReadOnlyMapTXRecorder) GetMerkleNodes(arg0, arg1 interface{}) *g
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetMerkleNodes", arg0, arg1)
 }
 
+func (_m *MockReadOnlyMapTX) GetSignedMapRootAtRevision(_param0 int64) (trillian.SignedMapRoot, error) {
+	ret := _m.ctrl.Call(_m, "GetSignedMapRootAtRevision", _param0)
+	ret0, _ := ret[0].(trillian.SignedMapRoot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockReadOnlyMapTXRecorder) GetSignedMapRootAtRevision(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetSignedMapRootAtRevision", arg0)
+}
+
 func (_m *MockReadOnlyMapTX) GetTreeRevisionAtSize(_param0 int64) (int64, error) {
 	ret := _m.ctrl.Call(_m, "GetTreeRevisionAtSize", _param0)
 	ret0, _ := ret[0].(int64)
@@ -600,6 +783,46 @@ func (_mr *_MockLogStorageRecorder) Begin() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Begin")
 }
 
+func (_m *MockLogStorage) Close() error {
+	ret := _m.ctrl.Call(_m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockLogStorageRecorder) Close() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Close")
+}
+
+func (_m *MockLogStorage) LeafHashStrategy() trillian.LeafHashStrategy {
+	ret := _m.ctrl.Call(_m, "LeafHashStrategy")
+	ret0, _ := ret[0].(trillian.LeafHashStrategy)
+	return ret0
+}
+
+func (_mr *_MockLogStorageRecorder) LeafHashStrategy() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "LeafHashStrategy")
+}
+
+func (_m *MockLogStorage) HashAlgorithm() trillian.HashAlgorithm {
+	ret := _m.ctrl.Call(_m, "HashAlgorithm")
+	ret0, _ := ret[0].(trillian.HashAlgorithm)
+	return ret0
+}
+
+func (_mr *_MockLogStorageRecorder) HashAlgorithm() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "HashAlgorithm")
+}
+
+func (_m *MockLogStorage) TreeDepth() int {
+	ret := _m.ctrl.Call(_m, "TreeDepth")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+func (_mr *_MockLogStorageRecorder) TreeDepth() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "TreeDepth")
+}
+
 func (_m *MockLogStorage) Snapshot() (ReadOnlyLogTX, error) {
 	ret := _m.ctrl.Call(_m, "Snapshot")
 	ret0, _ := ret[0].(ReadOnlyLogTX)
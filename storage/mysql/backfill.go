@@ -0,0 +1,291 @@
+package mysql
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian/storage"
+)
+
+const selectUnsetIntegrateTimestampsSql string = `SELECT SequenceNumber FROM SequencedLeafData
+		 WHERE TreeId=? AND SequenceNumber>=? AND IntegrateTimestampNanos=0
+		 ORDER BY SequenceNumber LIMIT ?`
+const selectTreeHeadsCoveringSql string = `SELECT TreeHeadTimestamp,TreeSize FROM TreeHead
+		 WHERE TreeId=? AND TreeSize>? ORDER BY TreeHeadTimestamp`
+const setIntegrateTimestampSql string = `UPDATE SequencedLeafData SET IntegrateTimestampNanos=?
+		 WHERE TreeId=? AND SequenceNumber=? AND IntegrateTimestampNanos=0`
+const selectInlineExtraDataSql string = `SELECT LeafHash,ExtraData FROM LeafData
+		 WHERE TreeId=? AND LeafHash>? AND ExtraDataBlobKey IS NULL AND LENGTH(ExtraData)>?
+		 ORDER BY LeafHash LIMIT ?`
+const setExtraDataBlobKeySql string = `UPDATE LeafData SET ExtraData=NULL,ExtraDataBlobKey=?
+		 WHERE TreeId=? AND LeafHash=? AND ExtraDataBlobKey IS NULL`
+
+// BackfillBatch is the outcome of backfilling one batch of rows.
+type BackfillBatch struct {
+	// Cursor is where the next batch starts, it should be treated as opaque
+	Cursor string
+	// Examined is the number of rows that needed backfilling
+	Examined int
+	// Updated is the number of rows that were changed
+	Updated int
+	// Done is set if there were no rows left to backfill
+	Done bool
+}
+
+// Backfiller fills in columns that were added to the schema after some of a log's leaves had
+// been stored. Each batch is committed on its own and returns a cursor, so a backfill can be
+// stopped between batches and resumed later from the last cursor. An empty cursor starts from
+// the beginning. The log can be serving while it's backfilled.
+type Backfiller struct {
+	db     *sql.DB
+	treeID int64
+}
+
+// NewBackfiller creates a Backfiller for a log in the database at dbURL.
+func NewBackfiller(dbURL string, treeID int64) (*Backfiller, error) {
+	db, err := openDB(dbURL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Backfiller{db: db, treeID: treeID}, nil
+}
+
+// Close releases the database connection.
+func (b *Backfiller) Close() error {
+	return b.db.Close()
+}
+
+// BackfillIntegrateTimestamps sets IntegrateTimestampNanos for up to limit leaves that were
+// sequenced before it was recorded. The exact time isn't known so the timestamp of the first
+// root that included the leaf is used, which is as late as the leaf could have been
+// integrated. Leaves that no root includes yet are left alone.
+func (b *Backfiller) BackfillIntegrateTimestamps(cursor string, limit int) (BackfillBatch, error) {
+	start := int64(0)
+
+	if len(cursor) > 0 {
+		var err error
+		if start, err = strconv.ParseInt(cursor, 10, 64); err != nil {
+			return BackfillBatch{}, fmt.Errorf("invalid integrate timestamp cursor %q: %v", cursor, err)
+		}
+	}
+
+	tx, err := b.db.Begin()
+
+	if err != nil {
+		return BackfillBatch{}, err
+	}
+
+	batch, err := b.backfillIntegrateTimestamps(tx, start, limit)
+
+	if err != nil {
+		tx.Rollback()
+		return BackfillBatch{}, err
+	}
+
+	return batch, tx.Commit()
+}
+
+func (b *Backfiller) backfillIntegrateTimestamps(tx *sql.Tx, start int64, limit int) (BackfillBatch, error) {
+	seqs, err := b.unsetIntegrateTimestamps(tx, start, limit)
+
+	if err != nil {
+		return BackfillBatch{}, err
+	}
+
+	if len(seqs) == 0 {
+		return BackfillBatch{Cursor: strconv.FormatInt(start, 10), Done: true}, nil
+	}
+
+	heads, err := b.treeHeadsCovering(tx, seqs[0], seqs[len(seqs)-1])
+
+	if err != nil {
+		return BackfillBatch{}, err
+	}
+
+	times := integrationTimes(seqs, heads)
+	batch := BackfillBatch{Cursor: strconv.FormatInt(seqs[len(seqs)-1]+1, 10), Examined: len(seqs)}
+
+	for i, seq := range seqs {
+		if times[i] == 0 {
+			glog.Warningf("Leaf %d of tree %d is not in any root, not backfilling its integrate timestamp", seq, b.treeID)
+			continue
+		}
+
+		res, err := tx.Exec(setIntegrateTimestampSql, times[i], b.treeID, seq)
+
+		if err != nil {
+			return BackfillBatch{}, err
+		}
+
+		if rows, err := res.RowsAffected(); err == nil {
+			batch.Updated += int(rows)
+		}
+	}
+
+	return batch, nil
+}
+
+// unsetIntegrateTimestamps returns the sequence numbers, in order, of up to limit leaves from
+// start that don't have an integrate timestamp.
+func (b *Backfiller) unsetIntegrateTimestamps(tx *sql.Tx, start int64, limit int) ([]int64, error) {
+	rows, err := tx.Query(selectUnsetIntegrateTimestampsSql, b.treeID, start, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var seqs []int64
+	for rows.Next() {
+		var seq int64
+		if err := rows.Scan(&seq); err != nil {
+			return nil, err
+		}
+
+		seqs = append(seqs, seq)
+	}
+
+	return seqs, rows.Err()
+}
+
+// treeHead is the timestamp and size of a stored root.
+type treeHead struct {
+	timestampNanos int64
+	treeSize       int64
+}
+
+// treeHeadsCovering returns the roots in timestamp order from the first that includes leaf
+// first up to the first that includes leaf last.
+func (b *Backfiller) treeHeadsCovering(tx *sql.Tx, first, last int64) ([]treeHead, error) {
+	rows, err := tx.Query(selectTreeHeadsCoveringSql, b.treeID, first)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var heads []treeHead
+	for rows.Next() {
+		var head treeHead
+		if err := rows.Scan(&head.timestampNanos, &head.treeSize); err != nil {
+			return nil, err
+		}
+
+		heads = append(heads, head)
+
+		// Later roots aren't needed for this batch
+		if head.treeSize > last {
+			break
+		}
+	}
+
+	return heads, rows.Err()
+}
+
+// integrationTimes returns the timestamp of the first root in heads that includes each of the
+// sequence numbers, or zero if none does. Both must be in ascending order, roots don't shrink
+// so ordering them by timestamp also orders them by size.
+func integrationTimes(seqs []int64, heads []treeHead) []int64 {
+	times := make([]int64, len(seqs))
+	h := 0
+
+	for i, seq := range seqs {
+		for h < len(heads) && heads[h].treeSize <= seq {
+			h++
+		}
+
+		if h < len(heads) {
+			times[i] = heads[h].timestampNanos
+		}
+	}
+
+	return times
+}
+
+// BackfillExtraDataBlobs moves the ExtraData of up to limit leaves that is longer than
+// threshold bytes out of the database and into blobs, as EnableExtraDataBlobStore does for
+// new leaves. Each blob is written before the row is changed to refer to it. The ExtraData of
+// an encrypted log is moved as it's stored, so its blobs are encrypted too.
+func (b *Backfiller) BackfillExtraDataBlobs(cursor string, limit int, blobs storage.BlobStore, threshold int) (BackfillBatch, error) {
+	after, err := hex.DecodeString(cursor)
+
+	if err != nil {
+		return BackfillBatch{}, fmt.Errorf("invalid ExtraData cursor %q: %v", cursor, err)
+	}
+
+	tx, err := b.db.Begin()
+
+	if err != nil {
+		return BackfillBatch{}, err
+	}
+
+	batch, err := b.backfillExtraDataBlobs(tx, after, limit, blobs, threshold)
+
+	if err != nil {
+		tx.Rollback()
+		return BackfillBatch{}, err
+	}
+
+	return batch, tx.Commit()
+}
+
+func (b *Backfiller) backfillExtraDataBlobs(tx *sql.Tx, after []byte, limit int, blobs storage.BlobStore, threshold int) (BackfillBatch, error) {
+	rows, err := tx.Query(selectInlineExtraDataSql, b.treeID, after, threshold, limit)
+
+	if err != nil {
+		return BackfillBatch{}, err
+	}
+
+	type inlineLeaf struct {
+		leafHash  []byte
+		extraData []byte
+	}
+
+	var leaves []inlineLeaf
+	for rows.Next() {
+		var leaf inlineLeaf
+		if err := rows.Scan(&leaf.leafHash, &leaf.extraData); err != nil {
+			rows.Close()
+			return BackfillBatch{}, err
+		}
+
+		leaves = append(leaves, leaf)
+	}
+
+	if err := rows.Close(); err != nil {
+		return BackfillBatch{}, err
+	}
+
+	if len(leaves) == 0 {
+		return BackfillBatch{Cursor: hex.EncodeToString(after), Done: true}, nil
+	}
+
+	batch := BackfillBatch{Cursor: hex.EncodeToString(leaves[len(leaves)-1].leafHash), Examined: len(leaves)}
+
+	for _, leaf := range leaves {
+		key := ExtraDataBlobKey(b.treeID, leaf.extraData)
+
+		if err := blobs.Put(key, leaf.extraData); err != nil {
+			return BackfillBatch{}, err
+		}
+
+		res, err := tx.Exec(setExtraDataBlobKeySql, key, b.treeID, leaf.leafHash)
+
+		if err != nil {
+			return BackfillBatch{}, err
+		}
+
+		if rows, err := res.RowsAffected(); err == nil {
+			batch.Updated += int(rows)
+		}
+	}
+
+	return batch, nil
+}
@@ -0,0 +1,28 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIntegrationTimes(t *testing.T) {
+	heads := []treeHead{{timestampNanos: 100, treeSize: 3}, {timestampNanos: 200, treeSize: 3}, {timestampNanos: 300, treeSize: 7}}
+
+	for _, test := range []struct {
+		seqs  []int64
+		heads []treeHead
+		want  []int64
+	}{
+		{seqs: []int64{0, 2}, heads: heads, want: []int64{100, 100}},
+		// The first root at a size wins over a later one at the same size
+		{seqs: []int64{2, 3, 6}, heads: heads, want: []int64{100, 300, 300}},
+		// Leaves beyond the latest root aren't integrated yet
+		{seqs: []int64{5, 7, 9}, heads: heads, want: []int64{300, 0, 0}},
+		{seqs: []int64{1}, heads: nil, want: []int64{0}},
+		{seqs: nil, heads: heads, want: []int64{}},
+	} {
+		if got := integrationTimes(test.seqs, test.heads); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("integrationTimes(%v)=%v, expected %v", test.seqs, got, test.want)
+		}
+	}
+}
@@ -1,9 +1,14 @@
 -- Caution - this removes all tables in our schema
 
 DROP TABLE IF EXISTS Unsequenced;
+DROP TABLE IF EXISTS SequenceRange;
+DROP TABLE IF EXISTS SequenceRangeCounter;
+DROP TABLE IF EXISTS CommitToken;
+DROP TABLE IF EXISTS CompactTree;
 DROP TABLE IF EXISTS Subtree;
 DROP TABLE IF EXISTS SequencedLeafData;
 DROP TABLE IF EXISTS TreeHead;
+DROP TABLE IF EXISTS TreeStats;
 DROP TABLE IF EXISTS LeafData;
 DROP TABLE IF EXISTS MapLeaf;
 DROP TABLE IF EXISTS MapHead;
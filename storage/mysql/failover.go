@@ -0,0 +1,341 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+	terrors "github.com/google/trillian/errors"
+)
+
+const selectReadOnlySql string = "SELECT @@global.read_only"
+
+// probeTimeout bounds how long a failover check waits for each database to respond
+const probeTimeout = 5 * time.Second
+
+// ErrFailedOver is returned by Commit for a transaction that began on a database the storage
+// has since failed over from. Nothing the transaction wrote is committed, so it can be retried.
+var ErrFailedOver = terrors.New(terrors.Backend, "storage failed over to another database during the transaction, it was rolled back")
+
+// Counts of failover events across all storage in the process, see GetFailoverStats
+var failovers, failedFailovers, abortedTransactions int64
+
+// FailoverStats describes the failovers of the MySQL storage in this process
+type FailoverStats struct {
+	// Failovers is the number of times storage moved to another database in its DSN list
+	Failovers int64
+	// FailedFailovers is the number of times the current database was unusable but none of
+	// the others in the list were writable, so the storage stayed where it was
+	FailedFailovers int64
+	// AbortedTransactions is the number of transactions rolled back at commit because they
+	// began before a failover
+	AbortedTransactions int64
+}
+
+// GetFailoverStats returns the failover events of the MySQL storage in this process.
+func GetFailoverStats() FailoverStats {
+	return FailoverStats{
+		Failovers:           atomic.LoadInt64(&failovers),
+		FailedFailovers:     atomic.LoadInt64(&failedFailovers),
+		AbortedTransactions: atomic.LoadInt64(&abortedTransactions),
+	}
+}
+
+// splitDSNs splits a comma separated list of data source names, the primary first and then
+// the candidates to fail over to in order of preference.
+func splitDSNs(dbURL string) []string {
+	var dsns []string
+
+	for _, dsn := range strings.Split(dbURL, ",") {
+		if dsn = strings.TrimSpace(dsn); len(dsn) > 0 {
+			dsns = append(dsns, dsn)
+		}
+	}
+
+	return dsns
+}
+
+// candidateOrder returns the indexes of the databases to try when the one at current has
+// failed: those after it in the list, then those before it, then current itself in case it
+// has recovered by the time the others have been tried.
+func candidateOrder(count, current int) []int {
+	order := make([]int, 0, count)
+
+	for i := 1; i <= count; i++ {
+		order = append(order, (current+i)%count)
+	}
+
+	return order
+}
+
+// openDSN opens a single database and puts it in strict mode.
+func openDSN(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		// Don't log uri as it could contain credentials
+		glog.Warningf("Could not open MySQL database, check config: %s", err)
+		return nil, err
+	}
+
+	if _, err := db.Exec("SET sql_mode = 'STRICT_ALL_TABLES'"); err != nil {
+		glog.Warningf("Failed to set strict mode on mysql db: %s", err)
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// checkWritable returns an error if db can't be reached or is a read only replica.
+func checkWritable(db *sql.DB) error {
+	var readOnly bool
+
+	if err := db.QueryRow(selectReadOnlySql).Scan(&readOnly); err != nil {
+		return err
+	}
+
+	if readOnly {
+		return errors.New("database is read only")
+	}
+
+	return nil
+}
+
+// checkWritableWithin is checkWritable but gives up after timeout, leaving the check to
+// finish in the background, so an unreachable database can't hold up a failover.
+func checkWritableWithin(db *sql.DB, timeout time.Duration) error {
+	result := make(chan error, 1)
+	go func() { result <- checkWritable(db) }()
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("database did not respond within %v", timeout)
+	}
+}
+
+// openWritable opens a database and checks that it's writable within timeout. If the check
+// times out the database is closed once it finishes.
+func openWritable(dsn string, timeout time.Duration) (*sql.DB, error) {
+	type opened struct {
+		db  *sql.DB
+		err error
+	}
+
+	result := make(chan opened, 1)
+
+	go func() {
+		db, err := openDSN(dsn)
+
+		if err == nil {
+			if err = checkWritable(db); err != nil {
+				db.Close()
+			}
+		}
+
+		result <- opened{db, err}
+	}()
+
+	select {
+	case o := <-result:
+		return o.db, o.err
+	case <-time.After(timeout):
+		go func() {
+			if o := <-result; o.err == nil {
+				o.db.Close()
+			}
+		}()
+
+		return nil, fmt.Errorf("database did not respond within %v", timeout)
+	}
+}
+
+// openFirstWritable opens the first database in order that is writable. If there is only
+// one candidate it is opened without checking, as there's nothing else to choose.
+func openFirstWritable(dsns []string, order []int) (*sql.DB, int, error) {
+	if len(order) == 1 {
+		db, err := openDSN(dsns[order[0]])
+		return db, order[0], err
+	}
+
+	var lastErr error
+
+	for _, i := range order {
+		db, err := openWritable(dsns[i], probeTimeout)
+
+		if err == nil {
+			return db, i, nil
+		}
+
+		// The position in the list identifies the database without logging credentials
+		glog.Warningf("MySQL database %d of %d is not usable: %s", i+1, len(dsns), err)
+		lastErr = err
+	}
+
+	return nil, -1, fmt.Errorf("none of the %d MySQL databases is writable, last error: %v", len(dsns), lastErr)
+}
+
+// failoverDB is a connection to the writable database of a DSN list, the primary and then
+// candidates that are replicas until one of them is promoted. When a transaction can't be
+// started or committed it checks whether the current database is still reachable and
+// writable, and if not moves to the first of the others that is. Transactions begun before
+// a failover are rolled back when they're committed, see ErrFailedOver.
+type failoverDB struct {
+	dsns []string
+
+	// probeMu serializes failover checks so only one probes the databases at a time, mu isn't
+	// held while they're probed
+	probeMu sync.Mutex
+
+	// mu guards the fields below it. It's never held across a call to a database, so one that
+	// hangs can't hold up a failover.
+	mu sync.RWMutex
+	db *sql.DB
+	// current is the index in dsns of db
+	current int
+	// generation counts the failovers of this connection
+	generation int64
+}
+
+// openFailoverDB connects to the first writable database in dbURL, a comma separated DSN
+// list. A single DSN is opened as it always has been, without failover.
+func openFailoverDB(dbURL string) (*failoverDB, error) {
+	dsns := splitDSNs(dbURL)
+
+	if len(dsns) == 0 {
+		return nil, errors.New("no MySQL data source name configured")
+	}
+
+	db, current, err := openFirstWritable(dsns, candidateOrder(len(dsns), len(dsns)-1))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &failoverDB{dsns: dsns, db: db, current: current}, nil
+}
+
+// get returns the current database and the generation it belongs to.
+func (f *failoverDB) get() (*sql.DB, int64) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.db, f.generation
+}
+
+// begin starts a transaction on the current database, failing over and trying once more if
+// that fails. It returns the generation the transaction belongs to.
+func (f *failoverDB) begin() (*sql.Tx, int64, error) {
+	db, generation := f.get()
+	tx, err := db.Begin()
+
+	if err == nil {
+		return tx, generation, nil
+	}
+
+	if !f.checkFailover(generation) {
+		return nil, 0, err
+	}
+
+	db, generation = f.get()
+	tx, err = db.Begin()
+
+	return tx, generation, err
+}
+
+// commit commits tx if no failover has happened since generation, otherwise it rolls it back
+// and returns ErrFailedOver. A failover that starts while tx is committing doesn't wait for
+// it, tx then commits or fails on the database it began on.
+func (f *failoverDB) commit(tx *sql.Tx, generation int64) error {
+	if _, latest := f.get(); latest != generation {
+		tx.Rollback()
+		atomic.AddInt64(&abortedTransactions, 1)
+		return ErrFailedOver
+	}
+
+	// Commit has no timeout, so mu mustn't be held while the database is waited for
+	err := tx.Commit()
+
+	if err != nil {
+		f.checkFailover(generation)
+	}
+
+	return err
+}
+
+// checkFailover fails over if the database of generation is no longer reachable and
+// writable. It returns true if there's a newer generation to use, whether this call or a
+// concurrent one failed over. The databases are probed without holding mu, so transactions
+// on the current database carry on while it's checked, and mu is only locked to switch.
+func (f *failoverDB) checkFailover(generation int64) bool {
+	if len(f.dsns) == 1 {
+		return false
+	}
+
+	f.probeMu.Lock()
+	defer f.probeMu.Unlock()
+
+	f.mu.RLock()
+	oldDB, oldCurrent, latest := f.db, f.current, f.generation
+	f.mu.RUnlock()
+
+	if latest != generation {
+		return true
+	}
+
+	err := checkWritableWithin(oldDB, probeTimeout)
+
+	if err == nil {
+		return false
+	}
+
+	glog.Warningf("MySQL database %d of %d is not usable, failing over: %s", oldCurrent+1, len(f.dsns), err)
+	db, current, err := openFirstWritable(f.dsns, candidateOrder(len(f.dsns), oldCurrent))
+
+	if err != nil {
+		glog.Warningf("Failed to fail over: %s", err)
+		atomic.AddInt64(&failedFailovers, 1)
+		return false
+	}
+
+	// Only checkFailover changes the generation and it's serialized by probeMu, so nothing
+	// has switched database since it was read above
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Transactions in progress on the old database are aborted when they commit, it's closed
+	// once they've finished
+	old := f.db
+	f.db, f.current = db, current
+	f.generation++
+	atomic.AddInt64(&failovers, 1)
+	glog.Infof("Failed over to MySQL database %d of %d", current+1, len(f.dsns))
+
+	go old.Close()
+
+	return true
+}
+
+// Exec runs a statement outside a transaction on the current database.
+func (f *failoverDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	db, _ := f.get()
+	return db.Exec(query, args...)
+}
+
+// QueryRow runs a query outside a transaction on the current database.
+func (f *failoverDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	db, _ := f.get()
+	return db.QueryRow(query, args...)
+}
+
+// Close closes the current database.
+func (f *failoverDB) Close() error {
+	db, _ := f.get()
+	return db.Close()
+}
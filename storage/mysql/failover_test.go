@@ -0,0 +1,155 @@
+package mysql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// hangingDriver is a database whose commits hang until release is closed
+type hangingDriver struct {
+	commitStarted chan struct{}
+	release       chan struct{}
+}
+
+// hangingPrimary is registered once, tests set its channels before they open it
+var hangingPrimary = &hangingDriver{}
+
+func init() {
+	sql.Register("hangingmysql", hangingPrimary)
+}
+
+func (d *hangingDriver) Open(name string) (driver.Conn, error) { return hangingConn{d}, nil }
+
+type hangingConn struct {
+	d *hangingDriver
+}
+
+func (c hangingConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (c hangingConn) Close() error              { return nil }
+func (c hangingConn) Begin() (driver.Tx, error) { return hangingTx{c.d}, nil }
+
+type hangingTx struct {
+	d *hangingDriver
+}
+
+func (t hangingTx) Commit() error {
+	t.d.commitStarted <- struct{}{}
+	<-t.d.release
+	return nil
+}
+
+func (t hangingTx) Rollback() error { return nil }
+
+func TestSplitDSNs(t *testing.T) {
+	for _, test := range []struct {
+		dbURL string
+		want  []string
+	}{
+		{"test:zaphod@tcp(127.0.0.1:3306)/test", []string{"test:zaphod@tcp(127.0.0.1:3306)/test"}},
+		{"a@tcp(primary:3306)/test, a@tcp(replica:3306)/test", []string{"a@tcp(primary:3306)/test", "a@tcp(replica:3306)/test"}},
+		{"a@tcp(primary:3306)/test,,", []string{"a@tcp(primary:3306)/test"}},
+		{"", nil},
+	} {
+		if got := splitDSNs(test.dbURL); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitDSNs(%q)=%v, expected %v", test.dbURL, got, test.want)
+		}
+	}
+}
+
+func TestCandidateOrder(t *testing.T) {
+	for _, test := range []struct {
+		count, current int
+		want           []int
+	}{
+		{1, 0, []int{0}},
+		{3, 2, []int{0, 1, 2}},
+		{3, 0, []int{1, 2, 0}},
+		{4, 1, []int{2, 3, 0, 1}},
+	} {
+		if got := candidateOrder(test.count, test.current); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("candidateOrder(%d, %d)=%v, expected %v", test.count, test.current, got, test.want)
+		}
+	}
+}
+
+func TestOpenFailoverDBNoDSN(t *testing.T) {
+	if _, err := openFailoverDB(" , "); err == nil {
+		t.Error("openFailoverDB() of an empty DSN list succeeded")
+	}
+}
+
+func TestCheckFailoverWithoutDatabase(t *testing.T) {
+	// Neither case needs to look at the database
+	if (&failoverDB{dsns: []string{"primary"}}).checkFailover(0) {
+		t.Error("checkFailover() with a single DSN reported a failover")
+	}
+
+	if !(&failoverDB{dsns: []string{"primary", "replica"}, generation: 1}).checkFailover(0) {
+		t.Error("checkFailover() of an earlier generation didn't report the later one")
+	}
+}
+
+func TestOpenWritableTimesOut(t *testing.T) {
+	// Nothing answers at this address, whether the connection is refused or hangs the probe
+	// must give up in about the timeout
+	start := time.Now()
+
+	if db, err := openWritable("test:zaphod@tcp(192.0.2.1:3306)/test", 100*time.Millisecond); err == nil {
+		db.Close()
+		t.Fatal("openWritable() of an unreachable database succeeded")
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("openWritable() took %v with a 100ms timeout", elapsed)
+	}
+}
+
+func TestFailoverNotBlockedByHangingCommit(t *testing.T) {
+	hangingPrimary.commitStarted = make(chan struct{}, 1)
+	hangingPrimary.release = make(chan struct{})
+	db, err := sql.Open("hangingmysql", "primary")
+
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	defer db.Close()
+
+	f := &failoverDB{dsns: []string{"primary", "replica"}, db: db}
+	tx, generation, err := f.begin()
+
+	if err != nil {
+		t.Fatalf("begin() failed: %v", err)
+	}
+
+	committed := make(chan error, 1)
+	go func() { committed <- f.commit(tx, generation) }()
+	<-hangingPrimary.commitStarted
+
+	// Switching database takes mu, which must not be waiting on the hung commit
+	switched := make(chan struct{})
+	go func() {
+		f.mu.Lock()
+		f.generation++
+		f.mu.Unlock()
+		close(switched)
+	}()
+
+	select {
+	case <-switched:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Failover blocked by a commit to a hung database")
+	}
+
+	close(hangingPrimary.release)
+
+	if err := <-committed; err != nil {
+		t.Errorf("commit() failed: %v", err)
+	}
+}
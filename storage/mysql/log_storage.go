@@ -4,42 +4,80 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"database/sql"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	terrors "github.com/google/trillian/errors"
 	"github.com/google/trillian/merkle"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/cache"
 )
 
-const getTreePropertiesSql string = "SELECT AllowsDuplicateLeaves FROM Trees WHERE TreeId=?"
+const getTreePropertiesSql string = "SELECT AllowsDuplicateLeaves,LeafHashStrategy,LeafHasherType,TreeHasherType,TreeDepth,WrappedDataKey FROM Trees WHERE TreeId=?"
+const selectWrappedDataKeySql string = "SELECT WrappedDataKey FROM Trees WHERE TreeId=?"
+const setWrappedDataKeySql string = "UPDATE Trees SET WrappedDataKey=? WHERE TreeId=? AND WrappedDataKey IS NULL"
+const selectTreeMetadataSql string = `SELECT DisplayName,Description,OwnerContact,UNIX_TIMESTAMP(CreateTime)
+		 FROM Trees WHERE TreeId=?`
+const updateTreeMetadataSql string = "UPDATE Trees SET DisplayName=?,Description=?,OwnerContact=? WHERE TreeId=?"
 const getTreeParametersSql string = "SELECT ReadOnlyRequests From TreeControl WHERE TreeID=?"
-const selectQueuedLeavesSql string = `SELECT LeafHash,Payload,SignedEntryTimestamp
+const selectSequencingEnabledSql string = "SELECT SequencingEnabled FROM TreeControl WHERE TreeId=?"
+const upsertSequencingEnabledSql string = `INSERT INTO TreeControl(TreeId,ReadOnlyRequests,SequencingEnabled) VALUES(?,0,?)
+		 ON DUPLICATE KEY UPDATE SequencingEnabled=VALUES(SequencingEnabled)`
+const selectQueuedLeavesSql string = `SELECT LeafHash,Payload,SignedEntryTimestamp,Priority
 		 FROM Unsequenced
 		 WHERE TreeID=?
-		 ORDER BY QueueTimestamp DESC LIMIT ?`
-const insertUnsequencedLeafSql string = `INSERT INTO LeafData(TreeId,LeafHash,TheData)
-		 VALUES(?,?,?) ON DUPLICATE KEY UPDATE LeafHash=LeafHash`
-const insertUnsequencedEntrySql string = `INSERT INTO Unsequenced(TreeId,LeafHash,MessageId,SignedEntryTimestamp,Payload)
-     VALUES(?,?,?,?,?)`
-const insertSequencedLeafSql string = `INSERT INTO SequencedLeafData(TreeId,LeafHash,SequenceNumber,SignedEntryTimestamp)
-		 VALUES(?,?,?,?)`
+		 ORDER BY Priority DESC,QueueTimestamp DESC LIMIT ?`
+const insertUnsequencedLeafSql string = `INSERT INTO LeafData(TreeId,LeafHash,TheData,ExtraData,ExtraDataBlobKey)
+		 VALUES(?,?,?,?,?) ON DUPLICATE KEY UPDATE LeafHash=LeafHash`
+const insertUnsequencedEntrySql string = `INSERT INTO Unsequenced(TreeId,LeafHash,MessageId,SignedEntryTimestamp,Payload,Priority)
+     VALUES(?,?,?,?,?,?)`
+const insertSequencedLeafSql string = `INSERT INTO SequencedLeafData(TreeId,LeafHash,SequenceNumber,SignedEntryTimestamp,IntegrateTimestampNanos)
+		 VALUES(?,?,?,?,?)`
 const selectSequencedLeafCountSql string = "SELECT COUNT(*) FROM SequencedLeafData"
-const selectLatestSignedLogRootSql string = `SELECT TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature
+const selectLatestSignedLogRootSql string = `SELECT TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature,RootMetadata
 		 FROM TreeHead WHERE TreeId=?
 		 ORDER BY TreeHeadTimestamp DESC LIMIT 1`
+const selectSignedLogRootAtRevisionSql string = `SELECT TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature,RootMetadata
+		 FROM TreeHead WHERE TreeId=? AND TreeRevision=?`
+const selectCompactTreeSql string = "SELECT State FROM CompactTree WHERE TreeId=?"
+const replaceCompactTreeSql string = "REPLACE INTO CompactTree(TreeId,TreeSize,State) VALUES(?,?,?)"
+const selectCommitTokenSql string = "SELECT Token FROM CommitToken WHERE TreeId=?"
+const replaceCommitTokenSql string = "REPLACE INTO CommitToken(TreeId,Token) VALUES(?,?)"
+const initSequenceRangeCounterSql string = `INSERT IGNORE INTO SequenceRangeCounter(TreeId,NextSequenceNumber,NextFencingToken)
+		 VALUES(?,0,1)`
+const selectSequenceRangeCounterSql string = `SELECT NextSequenceNumber,NextFencingToken FROM SequenceRangeCounter
+		 WHERE TreeId=? FOR UPDATE`
+const updateSequenceRangeCounterSql string = `UPDATE SequenceRangeCounter SET NextSequenceNumber=?,NextFencingToken=?
+		 WHERE TreeId=?`
+const selectNextUnusedSequenceNumberSql string = "SELECT COALESCE(MAX(SequenceNumber)+1,0) FROM SequencedLeafData WHERE TreeId=?"
+const insertSequenceRangeSql string = `INSERT INTO SequenceRange(TreeId,FirstSequenceNumber,EndSequenceNumber,SequencerId,FencingToken)
+		 VALUES(?,?,?,?,?)`
+const selectSequenceRangeTokenSql string = `SELECT FencingToken FROM SequenceRange
+		 WHERE TreeId=? AND FirstSequenceNumber=? FOR UPDATE`
+const updateSequenceRangeSql string = `UPDATE SequenceRange SET SequencerId=?,FencingToken=?
+		 WHERE TreeId=? AND FirstSequenceNumber=? AND FencingToken=?`
+const deleteSequenceRangeSql string = "DELETE FROM SequenceRange WHERE TreeId=? AND FirstSequenceNumber=? AND FencingToken=?"
+const selectSequenceRangesSql string = `SELECT FirstSequenceNumber,EndSequenceNumber,SequencerId,FencingToken
+		 FROM SequenceRange WHERE TreeId=? ORDER BY FirstSequenceNumber`
 
 // These statements need to be expanded to provide the correct number of parameter placeholders
 // for a particular case
 const deleteUnsequencedSql string = "DELETE FROM Unsequenced WHERE LeafHash IN (<placeholder>) AND TreeId = ?"
-const selectLeavesByIndexSql string = `SELECT l.LeafHash,l.TheData,s.SequenceNumber,s.SignedEntryTimestamp
+const selectLeavesByIndexSql string = `SELECT l.LeafHash,l.TheData,l.ExtraData,l.ExtraDataBlobKey,s.SequenceNumber,s.SignedEntryTimestamp,s.IntegrateTimestampNanos
 		     FROM LeafData l,SequencedLeafData s
 		     WHERE l.LeafHash = s.LeafHash
 		     AND s.SequenceNumber IN (` + placeholderSql + `) AND l.TreeId = ? AND s.TreeId = l.TreeId`
-const selectLeavesByHashSql string = `SELECT l.LeafHash,l.TheData,s.SequenceNumber,s.SignedEntryTimestamp
+const selectLeavesByIndexWithoutExtraDataSql string = `SELECT l.LeafHash,l.TheData,s.SequenceNumber,s.SignedEntryTimestamp,s.IntegrateTimestampNanos
+		     FROM LeafData l,SequencedLeafData s
+		     WHERE l.LeafHash = s.LeafHash
+		     AND s.SequenceNumber IN (` + placeholderSql + `) AND l.TreeId = ? AND s.TreeId = l.TreeId`
+const selectLeavesByHashSql string = `SELECT l.LeafHash,l.TheData,l.ExtraData,l.ExtraDataBlobKey,s.SequenceNumber,s.SignedEntryTimestamp,s.IntegrateTimestampNanos
 		     FROM LeafData l,SequencedLeafData s
 		     WHERE l.LeafHash = s.LeafHash
 		     AND l.LeafHash IN (` + placeholderSql + `) AND l.TreeId = ? AND s.TreeId = l.TreeId`
@@ -47,16 +85,88 @@ const selectLeavesByHashSql string = `SELECT l.LeafHash,l.TheData,s.SequenceNumb
 // Same as above except with leaves ordered by sequence so we only incur this cost when necessary
 const selectLeavesByHashOrderedBySequenceSQL string = selectLeavesByHashSql + " ORDER BY s.SequenceNumber"
 
+// This uses the IntegrateTimestampIdx index
+const selectLeavesByTimestampSql string = `SELECT l.LeafHash,l.TheData,l.ExtraData,l.ExtraDataBlobKey,s.SequenceNumber,s.SignedEntryTimestamp,s.IntegrateTimestampNanos
+		     FROM LeafData l,SequencedLeafData s
+		     WHERE l.LeafHash = s.LeafHash
+		     AND s.TreeId = ? AND l.TreeId = s.TreeId
+		     AND s.IntegrateTimestampNanos >= ? AND s.IntegrateTimestampNanos < ?
+		     AND s.SequenceNumber >= ?
+		     ORDER BY s.SequenceNumber LIMIT ?`
+
 type mySQLLogStorage struct {
 	mySQLTreeStorage
 
-	logID           trillian.LogID
-	allowDuplicates bool
-	readOnly        bool
+	logID            trillian.LogID
+	allowDuplicates  bool
+	readOnly         bool
+	leafHashStrategy trillian.LeafHashStrategy
+	hashAlgorithm    trillian.HashAlgorithm
+	treeDepth        int
+	// wrappedDataKey is set if the leaf data for the tree is encrypted
+	wrappedDataKey []byte
+	// dataCipher encrypts leaf data, it's nil if encryption is not in use
+	dataCipher *crypto.DataCipher
+	// blobStore holds ExtraData larger than blobThreshold bytes, it's nil if all ExtraData is
+	// stored inline
+	blobStore     storage.BlobStore
+	blobThreshold int
 }
 
+// leafHashStrategies maps the values of the LeafHashStrategy column to the API enum
+var leafHashStrategies = map[string]trillian.LeafHashStrategy{
+	"RFC6962": trillian.LeafHashStrategy_RFC6962_LEAF_HASH,
+	"RAW":     trillian.LeafHashStrategy_RAW_LEAF_HASH,
+}
+
+// hashAlgorithms maps the values of the LeafHasherType and TreeHasherType columns to the API
+// enum
+var hashAlgorithms = map[string]trillian.HashAlgorithm{
+	"SHA256":     trillian.HashAlgorithm_SHA256,
+	"SHA512_256": trillian.HashAlgorithm_SHA512_256,
+	"SHA3_256":   trillian.HashAlgorithm_SHA3_256,
+}
+
+// NewLogStorage creates storage for a log whose leaf data is not encrypted.
 func NewLogStorage(id trillian.LogID, dbURL string) (storage.LogStorage, error) {
-	// TODO(al): pass this through/configure from DB
+	s, err := newLogStorage(id, dbURL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't hand out ciphertext as if it was leaf data
+	if s.wrappedDataKey != nil {
+		s.Close()
+		return nil, fmt.Errorf("leaf data for log %v is encrypted but no key wrapper was provided", id)
+	}
+
+	return s, nil
+}
+
+// NewEncryptedLogStorage creates storage for a log whose leaf data is encrypted at rest with a
+// data key for the tree. The data key is created the first time this is called for a tree and
+// is only ever stored wrapped by keyWrapper. Encryption should be enabled before any leaves are
+// added to the tree, as leaf data stored without it cannot be read afterwards.
+func NewEncryptedLogStorage(id trillian.LogID, dbURL string, keyWrapper crypto.KeyWrapper) (storage.LogStorage, error) {
+	s, err := newLogStorage(id, dbURL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.setUpEncryption(keyWrapper); err != nil {
+		glog.Warningf("Failed to set up encryption for log %v: %v", id, err)
+		s.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func newLogStorage(id trillian.LogID, dbURL string) (*mySQLLogStorage, error) {
+	// The tree's hasher isn't known until the tree properties have been read, the hash size
+	// and subtree population are set up once it is
 	th := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
 	ts, err := newTreeStorage(id.TreeID, dbURL, th.Size(), cache.PopulateLogSubtreeNodes(th))
 	if err != nil {
@@ -71,13 +181,47 @@ func NewLogStorage(id trillian.LogID, dbURL string) (storage.LogStorage, error)
 
 	// TODO: This should not default but it would currently complicate testing and can be
 	// implemented later when the create tree API has been defined.
-	if err := s.db.QueryRow(getTreePropertiesSql, id.TreeID).Scan(&s.allowDuplicates); err == sql.ErrNoRows {
+	var strategy, leafHasher, treeHasher string
+	if err := s.db.QueryRow(getTreePropertiesSql, id.TreeID).Scan(&s.allowDuplicates, &strategy, &leafHasher, &treeHasher, &s.treeDepth, &s.wrappedDataKey); err == sql.ErrNoRows {
 		s.allowDuplicates = false
+		s.leafHashStrategy = trillian.LeafHashStrategy_RFC6962_LEAF_HASH
+		s.hashAlgorithm = trillian.HashAlgorithm_SHA256
+		s.treeDepth = storage.DefaultLogTreeDepth
 	} else if err != nil {
 		glog.Warningf("Failed to get trees row for id %v: %s", id, err)
 		return nil, err
+	} else {
+		var ok bool
+		if s.leafHashStrategy, ok = leafHashStrategies[strategy]; !ok {
+			return nil, fmt.Errorf("unknown leaf hash strategy for log %v: %s", id, strategy)
+		}
+
+		if s.hashAlgorithm, ok = hashAlgorithms[treeHasher]; !ok {
+			return nil, fmt.Errorf("unknown tree hasher type for log %v: %s", id, treeHasher)
+		}
+
+		// Leaves and nodes are hashed by the same TreeHasher
+		if leafHasher != treeHasher {
+			return nil, fmt.Errorf("log %v has leaf hasher type %s but tree hasher type %s, they must be the same", id, leafHasher, treeHasher)
+		}
+
+		// Trees can be created directly in the database, so the depth might never have been
+		// checked
+		if err := storage.ValidateLogTreeDepth(s.treeDepth); err != nil {
+			return nil, fmt.Errorf("log %v: %v", id, err)
+		}
 	}
 
+	// Subtrees must be populated with the same hasher that the sequencer uses for this tree
+	th, err = merkle.NewTreeHasherForAlgorithm(s.hashAlgorithm, s.leafHashStrategy)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.hashSizeBytes = th.Size()
+	s.populateSubtree = cache.PopulateLogSubtreeNodes(th)
+
 	err = s.db.QueryRow(getTreeParametersSql, id.TreeID).Scan(&s.readOnly)
 
 	// TODO(Martin2112): It's probably not ok for the log to have no parameters set. Enforce this when
@@ -89,10 +233,171 @@ func NewLogStorage(id trillian.LogID, dbURL string) (storage.LogStorage, error)
 	return &s, nil
 }
 
+// setUpEncryption gets the data key for the tree, creating it if there isn't one yet, and
+// prepares to encrypt and decrypt leaf data with it.
+func (m *mySQLLogStorage) setUpEncryption(keyWrapper crypto.KeyWrapper) error {
+	if m.wrappedDataKey == nil {
+		key, err := crypto.NewDataKey()
+
+		if err != nil {
+			return err
+		}
+
+		wrapped, err := keyWrapper.WrapKey(m.logID.TreeID, key)
+
+		if err != nil {
+			return err
+		}
+
+		// This does nothing if another server set the key first, in which case we use theirs
+		if _, err := m.db.Exec(setWrappedDataKeySql, wrapped, m.logID.TreeID); err != nil {
+			return err
+		}
+
+		if err := m.db.QueryRow(selectWrappedDataKeySql, m.logID.TreeID).Scan(&m.wrappedDataKey); err != nil {
+			return err
+		}
+
+		if m.wrappedDataKey == nil {
+			return errors.New("data key was not stored")
+		}
+	}
+
+	key, err := keyWrapper.UnwrapKey(m.logID.TreeID, m.wrappedDataKey)
+
+	if err != nil {
+		return err
+	}
+
+	m.dataCipher, err = crypto.NewDataCipher(key)
+
+	return err
+}
+
+// leafDataAD returns the additional data used when encrypting leaf data. This binds the
+// ciphertext to the tree and leaf hash so it can't be substituted for other data.
+func (m *mySQLLogStorage) leafDataAD(leafHash []byte) []byte {
+	ad := make([]byte, 8, 8+len(leafHash))
+	binary.BigEndian.PutUint64(ad, uint64(m.logID.TreeID))
+	return append(ad, leafHash...)
+}
+
+func (m *mySQLLogStorage) encryptLeafValue(leafHash, value []byte) ([]byte, error) {
+	if m.dataCipher == nil {
+		return value, nil
+	}
+
+	return m.dataCipher.Encrypt(value, m.leafDataAD(leafHash))
+}
+
+func (m *mySQLLogStorage) decryptLeafValue(leafHash, value []byte) ([]byte, error) {
+	if m.dataCipher == nil {
+		return value, nil
+	}
+
+	return m.dataCipher.Decrypt(value, m.leafDataAD(leafHash))
+}
+
+// extraDataAD returns the additional data used when encrypting leaf ExtraData. It differs
+// from the leaf data's so the two ciphertexts of a leaf can't be swapped for each other.
+func (m *mySQLLogStorage) extraDataAD(leafHash []byte) []byte {
+	return append(m.leafDataAD(leafHash), "ExtraData"...)
+}
+
+// EnableExtraDataBlobStore arranges for leaf ExtraData longer than threshold bytes to be written
+// to blobStore, with only its key kept in the database. The storage must have been created by
+// this package. Leaves stored this way can only be read while a store holding their blobs is set.
+func EnableExtraDataBlobStore(ls storage.LogStorage, blobStore storage.BlobStore, threshold int) error {
+	m, ok := ls.(*mySQLLogStorage)
+
+	if !ok {
+		return fmt.Errorf("log storage %T does not support blob storage", ls)
+	}
+
+	if threshold < 0 {
+		return fmt.Errorf("invalid ExtraData blob threshold: %d", threshold)
+	}
+
+	m.blobStore = blobStore
+	m.blobThreshold = threshold
+	return nil
+}
+
+// storeExtraData returns the values to store in the ExtraData and ExtraDataBlobKey columns
+// for a leaf. If encryption is in use the ExtraData is encrypted first, wherever it's stored.
+// Large ExtraData is written to the blob store under a key derived from the stored bytes so
+// identical unencrypted data queued more than once is only stored once.
+func (m *mySQLLogStorage) storeExtraData(leafHash, extraData []byte) ([]byte, sql.NullString, error) {
+	if m.dataCipher != nil && len(extraData) > 0 {
+		var err error
+
+		if extraData, err = m.dataCipher.Encrypt(extraData, m.extraDataAD(leafHash)); err != nil {
+			return nil, sql.NullString{}, err
+		}
+	}
+
+	if m.blobStore == nil || len(extraData) <= m.blobThreshold {
+		return extraData, sql.NullString{}, nil
+	}
+
+	key := ExtraDataBlobKey(m.logID.TreeID, extraData)
+
+	if err := m.blobStore.Put(key, extraData); err != nil {
+		return nil, sql.NullString{}, err
+	}
+
+	return nil, sql.NullString{String: key, Valid: true}, nil
+}
+
+// ExtraDataBlobKey returns the key that a leaf's ExtraData is stored under in the blob store.
+// stored is the ExtraData as it's written, so for an encrypted log the key is derived from the
+// ciphertext and reveals nothing about the plaintext.
+func ExtraDataBlobKey(treeID int64, stored []byte) string {
+	return fmt.Sprintf("%d/%x", treeID, sha256.Sum256(stored))
+}
+
+// loadExtraData returns the ExtraData for a leaf read from the database, fetching it from the
+// blob store if it was stored there and decrypting it if encryption is in use.
+func (m *mySQLLogStorage) loadExtraData(leafHash, extraData []byte, blobKey sql.NullString) ([]byte, error) {
+	if blobKey.Valid {
+		if m.blobStore == nil {
+			return nil, fmt.Errorf("leaf ExtraData is in blob %s but no blob store is configured", blobKey.String)
+		}
+
+		var err error
+
+		if extraData, err = m.blobStore.Get(blobKey.String); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.dataCipher == nil || len(extraData) == 0 {
+		return extraData, nil
+	}
+
+	return m.dataCipher.Decrypt(extraData, m.extraDataAD(leafHash))
+}
+
+func (m *mySQLLogStorage) LeafHashStrategy() trillian.LeafHashStrategy {
+	return m.leafHashStrategy
+}
+
+func (m *mySQLLogStorage) HashAlgorithm() trillian.HashAlgorithm {
+	return m.hashAlgorithm
+}
+
+func (m *mySQLLogStorage) TreeDepth() int {
+	return m.treeDepth
+}
+
 func (m *mySQLLogStorage) getLeavesByIndexStmt(num int) (*sql.Stmt, error) {
 	return m.getStmt(selectLeavesByIndexSql, num, "?", "?")
 }
 
+func (m *mySQLLogStorage) getLeavesByIndexWithoutExtraDataStmt(num int) (*sql.Stmt, error) {
+	return m.getStmt(selectLeavesByIndexWithoutExtraDataSql, num, "?", "?")
+}
+
 func (m *mySQLLogStorage) getLeavesByHashStmt(num int, orderBySequence bool) (*sql.Stmt, error) {
 	if orderBySequence {
 		return m.getStmt(selectLeavesByHashOrderedBySequenceSQL, num, "?", "?")
@@ -191,6 +496,30 @@ type logTX struct {
 	ls *mySQLLogStorage
 }
 
+// GetInclusionProofNodes implements storage.ProofReader. All the subtrees the proof passes
+// through are read with one query.
+func (t *logTX) GetInclusionProofNodes(treeRevision, treeSize, leafIndex int64) ([]storage.Node, error) {
+	nodeIDs, err := merkle.CalcInclusionProofNodeAddresses(treeSize, leafIndex, t.ls.treeDepth)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return t.getMerkleNodesInOneQuery(treeRevision, nodeIDs)
+}
+
+// GetConsistencyProofNodes implements storage.ProofReader. All the subtrees the proof passes
+// through are read with one query.
+func (t *logTX) GetConsistencyProofNodes(treeRevision, previousTreeSize, treeSize int64) ([]storage.Node, error) {
+	nodeIDs, err := merkle.CalcConsistencyProofNodeAddresses(previousTreeSize, treeSize, t.ls.treeDepth)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return t.getMerkleNodesInOneQuery(treeRevision, nodeIDs)
+}
+
 func (t *logTX) WriteRevision() int64 {
 	return t.treeTX.writeRevision
 }
@@ -217,8 +546,9 @@ func (t *logTX) DequeueLeaves(limit int) ([]trillian.LogLeaf, error) {
 		var leafHash []byte
 		var payload []byte
 		var signedEntryTimestampBytes []byte
+		var priority int32
 
-		err := rows.Scan(&leafHash, &payload, &signedEntryTimestampBytes)
+		err := rows.Scan(&leafHash, &payload, &signedEntryTimestampBytes, &priority)
 
 		if err != nil {
 			glog.Warningf("Error scanning work rows: %s", err)
@@ -235,6 +565,11 @@ func (t *logTX) DequeueLeaves(limit int) ([]trillian.LogLeaf, error) {
 			return nil, err
 		}
 
+		if payload, err = t.ls.decryptLeafValue(leafHash, payload); err != nil {
+			glog.Warningf("Failed to decrypt queued leaf data: %s", err)
+			return nil, err
+		}
+
 		leaf := trillian.LogLeaf{
 			Leaf: trillian.Leaf{
 				LeafHash:  leafHash,
@@ -243,6 +578,7 @@ func (t *logTX) DequeueLeaves(limit int) ([]trillian.LogLeaf, error) {
 			},
 			SignedEntryTimestamp: signedEntryTimestamp,
 			SequenceNumber:       0,
+			Priority:             priority,
 		}
 		leaves = append(leaves, leaf)
 	}
@@ -281,8 +617,22 @@ func (t *logTX) QueueLeaves(leaves []trillian.LogLeaf) error {
 		// can suppress errors unrelated to key collisions. We don't use REPLACE because
 		// if there's ever a hash collision it will do the wrong thing and it also
 		// causes a DELETE / INSERT, which is undesirable.
-		_, err := t.tx.Exec(insertUnsequencedLeafSql, t.ls.logID.TreeID,
-			[]byte(leaf.LeafHash), leaf.LeafValue)
+		leafValue, err := t.ls.encryptLeafValue(leaf.LeafHash, leaf.LeafValue)
+
+		if err != nil {
+			glog.Warningf("Failed to encrypt leaf data: %s", err)
+			return err
+		}
+
+		extraData, blobKey, err := t.ls.storeExtraData(leaf.LeafHash, leaf.ExtraData)
+
+		if err != nil {
+			glog.Warningf("Failed to store leaf ExtraData blob: %s", err)
+			return err
+		}
+
+		_, err = t.tx.Exec(insertUnsequencedLeafSql, t.ls.logID.TreeID,
+			[]byte(leaf.LeafHash), leafValue, extraData, blobKey)
 
 		if err != nil {
 			glog.Warningf("Error inserting into LeafData: %s", err)
@@ -320,10 +670,9 @@ func (t *logTX) QueueLeaves(leaves []trillian.LogLeaf) error {
 			return err
 		}
 
-		// TODO: We shouldn't really need both payload and signed timestamp fields in unsequenced
-		// I think payload is currently unused
+		// The payload is the leaf value as stored in LeafData, so it's encrypted if that is
 		_, err = t.tx.Exec(insertUnsequencedEntrySql,
-			t.ls.logID.TreeID, []byte(leaf.LeafHash), messageId, signedTimestampBytes, signedTimestampBytes)
+			t.ls.logID.TreeID, []byte(leaf.LeafHash), messageId, signedTimestampBytes, leafValue, leaf.Priority)
 
 		if err != nil {
 			glog.Warningf("Error inserting into Unsequenced: %s", err)
@@ -346,7 +695,25 @@ func (t *logTX) GetSequencedLeafCount() (int64, error) {
 }
 
 func (t *logTX) GetLeavesByIndex(leaves []int64) ([]trillian.LogLeaf, error) {
-	tmpl, err := t.ls.getLeavesByIndexStmt(len(leaves))
+	return t.getLeavesByIndex(leaves, true)
+}
+
+// GetLeavesByIndexWithoutExtraData implements storage.ExtraDataSkipper. The ExtraData column
+// isn't selected and blobs aren't fetched.
+func (t *logTX) GetLeavesByIndexWithoutExtraData(leaves []int64) ([]trillian.LogLeaf, error) {
+	return t.getLeavesByIndex(leaves, false)
+}
+
+func (t *logTX) getLeavesByIndex(leaves []int64, withExtraData bool) ([]trillian.LogLeaf, error) {
+	var tmpl *sql.Stmt
+	var err error
+
+	if withExtraData {
+		tmpl, err = t.ls.getLeavesByIndexStmt(len(leaves))
+	} else {
+		tmpl, err = t.ls.getLeavesByIndexWithoutExtraDataStmt(len(leaves))
+	}
+
 	if err != nil {
 		return nil, err
 	}
@@ -366,11 +733,21 @@ func (t *logTX) GetLeavesByIndex(leaves []int64) ([]trillian.LogLeaf, error) {
 	num := 0
 
 	var signedTimestampBytes []byte
+	var blobKey sql.NullString
 
 	defer rows.Close()
 	for rows.Next() {
-		if err := rows.Scan(&ret[num].LeafHash, &ret[num].LeafValue, &ret[num].SequenceNumber,
-			&signedTimestampBytes); err != nil {
+		var dest []interface{}
+
+		if withExtraData {
+			dest = []interface{}{&ret[num].LeafHash, &ret[num].LeafValue, &ret[num].ExtraData, &blobKey,
+				&ret[num].SequenceNumber, &signedTimestampBytes, &ret[num].IntegrateTimestampNanos}
+		} else {
+			dest = []interface{}{&ret[num].LeafHash, &ret[num].LeafValue,
+				&ret[num].SequenceNumber, &signedTimestampBytes, &ret[num].IntegrateTimestampNanos}
+		}
+
+		if err := rows.Scan(dest...); err != nil {
 			glog.Warningf("Failed to scan merkle leaves: %s", err)
 			return nil, err
 		}
@@ -387,6 +764,16 @@ func (t *logTX) GetLeavesByIndex(leaves []int64) ([]trillian.LogLeaf, error) {
 			return nil, fmt.Errorf("Scanned leaf does not have hash length %d, got %d", want, got)
 		}
 
+		if ret[num].LeafValue, err = t.ls.decryptLeafValue(ret[num].LeafHash, ret[num].LeafValue); err != nil {
+			glog.Warningf("Failed to decrypt leaf data: %s", err)
+			return nil, err
+		}
+
+		if ret[num].ExtraData, err = t.ls.loadExtraData(ret[num].LeafHash, ret[num].ExtraData, blobKey); err != nil {
+			glog.Warningf("Failed to load leaf ExtraData: %s", err)
+			return nil, err
+		}
+
 		num++
 	}
 
@@ -418,12 +805,14 @@ func (t *logTX) GetLeavesByHash(leafHashes []trillian.Hash, orderBySequence bool
 	ret := make([]trillian.LogLeaf, 0)
 
 	var signedTimestampBytes []byte
+	var blobKey sql.NullString
 
 	defer rows.Close()
 	for rows.Next() {
 		leaf := trillian.LogLeaf{}
 
-		if err := rows.Scan(&leaf.LeafHash, &leaf.LeafValue, &leaf.SequenceNumber, &signedTimestampBytes); err != nil {
+		if err := rows.Scan(&leaf.LeafHash, &leaf.LeafValue, &leaf.ExtraData, &blobKey, &leaf.SequenceNumber,
+			&signedTimestampBytes, &leaf.IntegrateTimestampNanos); err != nil {
 			glog.Warningf("Failed to scan merkle leaves: %s", err)
 			return nil, err
 		}
@@ -440,27 +829,100 @@ func (t *logTX) GetLeavesByHash(leafHashes []trillian.Hash, orderBySequence bool
 			return nil, fmt.Errorf("Scanned leaf does not have hash length %d, got %d", want, got)
 		}
 
+		if leaf.LeafValue, err = t.ls.decryptLeafValue(leaf.LeafHash, leaf.LeafValue); err != nil {
+			glog.Warningf("Failed to decrypt leaf data: %s", err)
+			return nil, err
+		}
+
+		if leaf.ExtraData, err = t.ls.loadExtraData(leaf.LeafHash, leaf.ExtraData, blobKey); err != nil {
+			glog.Warningf("Failed to load leaf ExtraData: %s", err)
+			return nil, err
+		}
+
 		ret = append(ret, leaf)
 	}
 
 	return ret, nil
 }
 
-func (t *logTX) LatestSignedLogRoot() (trillian.SignedLogRoot, error) {
-	var timestamp, treeSize, treeRevision int64
-	var rootHash, rootSignatureBytes []byte
-	var rootSignature trillian.DigitallySigned
+func (t *logTX) GetLeavesByTimestamp(startNanos, endNanos, startIndex int64, limit int) ([]trillian.LogLeaf, error) {
+	rows, err := t.tx.Query(selectLeavesByTimestampSql, t.ls.logID.TreeID, startNanos, endNanos, startIndex, limit)
+
+	if err != nil {
+		glog.Warningf("Failed to get leaves by timestamp: %s", err)
+		return nil, err
+	}
+
+	ret := make([]trillian.LogLeaf, 0)
 
-	err := t.tx.QueryRow(
-		selectLatestSignedLogRootSql, t.ls.logID.TreeID).Scan(
-		&timestamp, &treeSize, &rootHash, &treeRevision, &rootSignatureBytes)
+	var signedTimestampBytes []byte
+	var blobKey sql.NullString
+
+	defer rows.Close()
+	for rows.Next() {
+		leaf := trillian.LogLeaf{}
+
+		if err := rows.Scan(&leaf.LeafHash, &leaf.LeafValue, &leaf.ExtraData, &blobKey, &leaf.SequenceNumber,
+			&signedTimestampBytes, &leaf.IntegrateTimestampNanos); err != nil {
+			glog.Warningf("Failed to scan merkle leaves: %s", err)
+			return nil, err
+		}
+
+		signedEntryTimestamp, err := decodeSignedTimestamp(signedTimestampBytes)
+
+		if err != nil {
+			return nil, err
+		}
+
+		leaf.SignedEntryTimestamp = signedEntryTimestamp
+
+		if leaf.LeafValue, err = t.ls.decryptLeafValue(leaf.LeafHash, leaf.LeafValue); err != nil {
+			glog.Warningf("Failed to decrypt leaf data: %s", err)
+			return nil, err
+		}
+
+		if leaf.ExtraData, err = t.ls.loadExtraData(leaf.LeafHash, leaf.ExtraData, blobKey); err != nil {
+			glog.Warningf("Failed to load leaf ExtraData: %s", err)
+			return nil, err
+		}
+
+		ret = append(ret, leaf)
+	}
+
+	return ret, rows.Err()
+}
+
+func (t *logTX) LatestSignedLogRoot() (trillian.SignedLogRoot, error) {
+	root, err := t.scanSignedLogRoot(t.tx.QueryRow(selectLatestSignedLogRootSql, t.ls.logID.TreeID))
 
 	// It's possible there are no roots for this tree yet
 	if err == sql.ErrNoRows {
 		return trillian.SignedLogRoot{}, nil
 	}
 
-	err = proto.Unmarshal(rootSignatureBytes, &rootSignature)
+	return root, err
+}
+
+func (t *logTX) GetSignedLogRootAtRevision(treeRevision int64) (trillian.SignedLogRoot, error) {
+	root, err := t.scanSignedLogRoot(t.tx.QueryRow(selectSignedLogRootAtRevisionSql, t.ls.logID.TreeID, treeRevision))
+
+	if err == sql.ErrNoRows {
+		return trillian.SignedLogRoot{}, terrors.Errorf(terrors.NotFound, "no tree head stored at revision: %d", treeRevision)
+	}
+
+	return root, err
+}
+
+func (t *logTX) scanSignedLogRoot(row *sql.Row) (trillian.SignedLogRoot, error) {
+	var timestamp, treeSize, treeRevision int64
+	var rootHash, rootSignatureBytes, rootMetadata []byte
+	var rootSignature trillian.DigitallySigned
+
+	if err := row.Scan(&timestamp, &treeSize, &rootHash, &treeRevision, &rootSignatureBytes, &rootMetadata); err != nil {
+		return trillian.SignedLogRoot{}, err
+	}
+
+	err := proto.Unmarshal(rootSignatureBytes, &rootSignature)
 
 	if err != nil {
 		glog.Warningf("Failed to unmarshall root signature: %v", err)
@@ -474,6 +936,7 @@ func (t *logTX) LatestSignedLogRoot() (trillian.SignedLogRoot, error) {
 		Signature:      &rootSignature,
 		LogId:          t.ls.logID.LogID,
 		TreeSize:       treeSize,
+		Metadata:       rootMetadata,
 	}, nil
 }
 
@@ -486,7 +949,7 @@ func (t *logTX) StoreSignedLogRoot(root trillian.SignedLogRoot) error {
 	}
 
 	res, err := t.tx.Exec(insertTreeHeadSql, t.ls.logID.TreeID, root.TimestampNanos, root.TreeSize,
-		root.RootHash, root.TreeRevision, signatureBytes)
+		root.RootHash, root.TreeRevision, signatureBytes, root.Metadata)
 
 	if err != nil {
 		glog.Warningf("Failed to store signed root: %s", err)
@@ -495,6 +958,147 @@ func (t *logTX) StoreSignedLogRoot(root trillian.SignedLogRoot) error {
 	return checkResultOkAndRowCountIs(res, err, 1)
 }
 
+func (t *logTX) LatestCompactTree() (storage.CompactTreeProto, error) {
+	var stateBytes []byte
+	err := t.tx.QueryRow(selectCompactTreeSql, t.ls.logID.TreeID).Scan(&stateBytes)
+
+	// Nothing is stored until the first batch has been sequenced
+	if err == sql.ErrNoRows {
+		return storage.CompactTreeProto{}, nil
+	}
+
+	if err != nil {
+		glog.Warningf("Failed to read compact tree: %s", err)
+		return storage.CompactTreeProto{}, err
+	}
+
+	var state storage.CompactTreeProto
+
+	if err := proto.Unmarshal(stateBytes, &state); err != nil {
+		glog.Warningf("Failed to unmarshal compact tree: %v", err)
+		return storage.CompactTreeProto{}, err
+	}
+
+	return state, nil
+}
+
+func (t *logTX) StoreCompactTree(state storage.CompactTreeProto) error {
+	stateBytes, err := proto.Marshal(&state)
+
+	if err != nil {
+		glog.Warningf("Failed to marshal compact tree: %v", err)
+		return err
+	}
+
+	if _, err := t.tx.Exec(replaceCompactTreeSql, t.ls.logID.TreeID, state.TreeSize, stateBytes); err != nil {
+		glog.Warningf("Failed to store compact tree: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+func (t *logTX) GetTreeMetadata() (trillian.TreeMetadata, error) {
+	var metadata trillian.TreeMetadata
+	var createTime int64
+
+	err := t.tx.QueryRow(selectTreeMetadataSql, t.ls.logID.TreeID).Scan(
+		&metadata.DisplayName, &metadata.Description, &metadata.OwnerContact, &createTime)
+
+	if err == sql.ErrNoRows {
+		return trillian.TreeMetadata{}, terrors.Errorf(terrors.NotFound, "no trees row for log %v", t.ls.logID)
+	}
+
+	if err != nil {
+		glog.Warningf("Failed to read tree metadata: %s", err)
+		return trillian.TreeMetadata{}, err
+	}
+
+	metadata.CreateTimeNanos = createTime * int64(time.Second)
+
+	return metadata, nil
+}
+
+func (t *logTX) SetTreeMetadata(metadata trillian.TreeMetadata) error {
+	res, err := t.tx.Exec(updateTreeMetadataSql, metadata.DisplayName, metadata.Description, metadata.OwnerContact, t.ls.logID.TreeID)
+
+	if err != nil {
+		glog.Warningf("Failed to update tree metadata: %s", err)
+		return err
+	}
+
+	// Unchanged rows aren't counted as affected so check the tree exists separately
+	if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+		if _, err := t.GetTreeMetadata(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SequencingEnabled returns false if sequencing of the log has been paused. Logs without a
+// TreeControl row, or without SequencingEnabled set in it, are sequenced.
+func (t *logTX) SequencingEnabled() (bool, error) {
+	var enabled sql.NullBool
+
+	err := t.tx.QueryRow(selectSequencingEnabledSql, t.ls.logID.TreeID).Scan(&enabled)
+
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+
+	if err != nil {
+		glog.Warningf("Failed to read sequencing enabled: %s", err)
+		return false, err
+	}
+
+	return !enabled.Valid || enabled.Bool, nil
+}
+
+// SetSequencingEnabled pauses or resumes sequencing of the log, creating its TreeControl row
+// if it doesn't have one.
+func (t *logTX) SetSequencingEnabled(enabled bool) error {
+	// The tree must exist, TreeControl rows aren't created for unknown trees
+	if _, err := t.GetTreeMetadata(); err != nil {
+		return err
+	}
+
+	if _, err := t.tx.Exec(upsertSequencingEnabledSql, t.ls.logID.TreeID, enabled); err != nil {
+		glog.Warningf("Failed to set sequencing enabled: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+// StoreCommitToken replaces the log's commit token with token when the transaction commits.
+func (t *logTX) StoreCommitToken(token []byte) error {
+	if _, err := t.tx.Exec(replaceCommitTokenSql, t.ls.logID.TreeID, token); err != nil {
+		glog.Warningf("Failed to store commit token: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+// LatestCommitToken returns the log's commit token, or nil if no transaction has stored one.
+func (t *logTX) LatestCommitToken() ([]byte, error) {
+	var token []byte
+	err := t.tx.QueryRow(selectCommitTokenSql, t.ls.logID.TreeID).Scan(&token)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		glog.Warningf("Failed to read commit token: %s", err)
+		return nil, err
+	}
+
+	return token, nil
+}
+
 func (t *logTX) UpdateSequencedLeaves(leaves []trillian.LogLeaf) error {
 	// TODO: In theory we can do this with CASE / WHEN in one SQL statement but it's more fiddly
 	// and can be implemented later if necessary
@@ -511,7 +1115,7 @@ func (t *logTX) UpdateSequencedLeaves(leaves []trillian.LogLeaf) error {
 		}
 
 		_, err = t.tx.Exec(insertSequencedLeafSql, t.ls.logID.TreeID, []byte(leaf.LeafHash),
-			leaf.SequenceNumber, signedTimestampBytes)
+			leaf.SequenceNumber, signedTimestampBytes, leaf.IntegrateTimestampNanos)
 
 		if err != nil {
 			glog.Warningf("Failed to update sequenced leaves: %s", err)
@@ -522,6 +1126,186 @@ func (t *logTX) UpdateSequencedLeaves(leaves []trillian.LogLeaf) error {
 	return nil
 }
 
+// lockSequenceRangeCounter returns the next sequence number and fencing token to hand out for
+// the log, and locks them until the transaction ends.
+func (t *logTX) lockSequenceRangeCounter() (int64, int64, error) {
+	if _, err := t.tx.Exec(initSequenceRangeCounterSql, t.ls.logID.TreeID); err != nil {
+		glog.Warningf("Failed to create sequence range counter: %s", err)
+		return 0, 0, err
+	}
+
+	var nextSequenceNumber, nextToken int64
+	err := t.tx.QueryRow(selectSequenceRangeCounterSql, t.ls.logID.TreeID).Scan(&nextSequenceNumber, &nextToken)
+
+	if err != nil {
+		glog.Warningf("Failed to read sequence range counter: %s", err)
+		return 0, 0, err
+	}
+
+	return nextSequenceNumber, nextToken, nil
+}
+
+// checkFence returns ErrFenced unless r is still reserved with its fencing token, and locks the
+// reservation until the transaction ends so that it can't be taken over in the meantime.
+func (t *logTX) checkFence(r storage.SequenceRange) error {
+	var token int64
+	err := t.tx.QueryRow(selectSequenceRangeTokenSql, t.ls.logID.TreeID, r.FirstSequenceNumber).Scan(&token)
+
+	if err == sql.ErrNoRows {
+		return storage.ErrFenced
+	}
+
+	if err != nil {
+		glog.Warningf("Failed to read sequence range: %s", err)
+		return err
+	}
+
+	if token != r.FencingToken {
+		return storage.ErrFenced
+	}
+
+	return nil
+}
+
+func (t *logTX) ReserveSequenceRange(sequencerID string, count int64) (storage.SequenceRange, error) {
+	if count <= 0 {
+		return storage.SequenceRange{}, fmt.Errorf("invalid sequence range size: %d", count)
+	}
+
+	first, token, err := t.lockSequenceRangeCounter()
+
+	if err != nil {
+		return storage.SequenceRange{}, err
+	}
+
+	// Leaves may have been sequenced without a reservation, so start after those too
+	var nextUnused int64
+	if err := t.tx.QueryRow(selectNextUnusedSequenceNumberSql, t.ls.logID.TreeID).Scan(&nextUnused); err != nil {
+		glog.Warningf("Failed to read highest sequence number: %s", err)
+		return storage.SequenceRange{}, err
+	}
+
+	if nextUnused > first {
+		first = nextUnused
+	}
+
+	r := storage.SequenceRange{
+		FirstSequenceNumber: first,
+		EndSequenceNumber:   first + count,
+		SequencerID:         sequencerID,
+		FencingToken:        token,
+	}
+
+	res, err := t.tx.Exec(insertSequenceRangeSql, t.ls.logID.TreeID, r.FirstSequenceNumber,
+		r.EndSequenceNumber, r.SequencerID, r.FencingToken)
+
+	if err := checkResultOkAndRowCountIs(res, err, 1); err != nil {
+		glog.Warningf("Failed to store sequence range: %s", err)
+		return storage.SequenceRange{}, err
+	}
+
+	res, err = t.tx.Exec(updateSequenceRangeCounterSql, r.EndSequenceNumber, token+1, t.ls.logID.TreeID)
+
+	if err := checkResultOkAndRowCountIs(res, err, 1); err != nil {
+		glog.Warningf("Failed to update sequence range counter: %s", err)
+		return storage.SequenceRange{}, err
+	}
+
+	return r, nil
+}
+
+func (t *logTX) TakeOverSequenceRange(r storage.SequenceRange, sequencerID string) (storage.SequenceRange, error) {
+	nextSequenceNumber, token, err := t.lockSequenceRangeCounter()
+
+	if err != nil {
+		return storage.SequenceRange{}, err
+	}
+
+	res, err := t.tx.Exec(updateSequenceRangeSql, sequencerID, token, t.ls.logID.TreeID,
+		r.FirstSequenceNumber, r.FencingToken)
+
+	if err != nil {
+		glog.Warningf("Failed to take over sequence range: %s", err)
+		return storage.SequenceRange{}, err
+	}
+
+	if err := checkResultOkAndRowCountIs(res, err, 1); err != nil {
+		return storage.SequenceRange{}, storage.ErrFenced
+	}
+
+	res, err = t.tx.Exec(updateSequenceRangeCounterSql, nextSequenceNumber, token+1, t.ls.logID.TreeID)
+
+	if err := checkResultOkAndRowCountIs(res, err, 1); err != nil {
+		glog.Warningf("Failed to update sequence range counter: %s", err)
+		return storage.SequenceRange{}, err
+	}
+
+	r.SequencerID = sequencerID
+	r.FencingToken = token
+
+	return r, nil
+}
+
+func (t *logTX) UpdateSequencedLeavesInRange(r storage.SequenceRange, leaves []trillian.LogLeaf) error {
+	for _, leaf := range leaves {
+		if !r.Contains(leaf.SequenceNumber) {
+			return fmt.Errorf("leaf sequence number %d is outside reserved range [%d, %d)",
+				leaf.SequenceNumber, r.FirstSequenceNumber, r.EndSequenceNumber)
+		}
+	}
+
+	if err := t.checkFence(r); err != nil {
+		return err
+	}
+
+	return t.UpdateSequencedLeaves(leaves)
+}
+
+func (t *logTX) ReleaseSequenceRange(r storage.SequenceRange) error {
+	res, err := t.tx.Exec(deleteSequenceRangeSql, t.ls.logID.TreeID, r.FirstSequenceNumber, r.FencingToken)
+
+	if err != nil {
+		glog.Warningf("Failed to release sequence range: %s", err)
+		return err
+	}
+
+	if err := checkResultOkAndRowCountIs(res, err, 1); err != nil {
+		return storage.ErrFenced
+	}
+
+	return nil
+}
+
+func (t *logTX) GetSequenceRanges() ([]storage.SequenceRange, error) {
+	rows, err := t.tx.Query(selectSequenceRangesSql, t.ls.logID.TreeID)
+
+	if err != nil {
+		glog.Warningf("Failed to read sequence ranges: %s", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ranges := make([]storage.SequenceRange, 0)
+
+	for rows.Next() {
+		var r storage.SequenceRange
+
+		if err := rows.Scan(&r.FirstSequenceNumber, &r.EndSequenceNumber, &r.SequencerID, &r.FencingToken); err != nil {
+			glog.Warningf("Failed to scan sequence range: %s", err)
+			return nil, err
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ranges, nil
+}
+
 func (t *logTX) removeSequencedLeaves(leaves []trillian.LogLeaf) error {
 	tmpl, err := t.ls.getDeleteUnsequencedStmt(len(leaves))
 	if err != nil {
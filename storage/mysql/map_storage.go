@@ -2,6 +2,7 @@ package mysql
 
 import (
 	"database/sql"
+	"fmt"
 
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/proto"
@@ -18,6 +19,9 @@ const selectLatestSignedMapRootSql string = `SELECT MapHeadTimestamp, RootHash,
 		 FROM MapHead WHERE TreeId=?
 		 ORDER BY MapHeadTimestamp DESC LIMIT 1`
 
+const selectSignedMapRootAtRevisionSQL string = `SELECT MapHeadTimestamp, RootHash, MapRevision, RootSignature, MapperData
+		 FROM MapHead WHERE TreeId=? AND MapRevision=?`
+
 const insertMapLeafSQL string = `INSERT INTO MapLeaf(TreeId, KeyHash, MapRevision, TheData) VALUES (?, ?, ?, ?)`
 const selectMapLeafSQL string = `SELECT KeyHash, MapRevision, TheData
 	 FROM MapLeaf
@@ -26,6 +30,13 @@ const selectMapLeafSQL string = `SELECT KeyHash, MapRevision, TheData
 				 MapRevision <= ?
 	 ORDER BY MapRevision DESC LIMIT 1`
 
+const selectMapLeafHistorySQL string = `SELECT MapRevision, TheData
+	 FROM MapLeaf
+	 WHERE TreeId = ? AND
+	 			 KeyHash = ? AND
+				 MapRevision <= ?
+	 ORDER BY MapRevision`
+
 type mySQLMapStorage struct {
 	mySQLTreeStorage
 
@@ -142,27 +153,82 @@ func (m *mapTX) Get(revision int64, keyHash trillian.Hash) (trillian.MapLeaf, er
 	return mapLeaf, err
 }
 
-func (m *mapTX) LatestSignedMapRoot() (trillian.SignedMapRoot, error) {
-	var timestamp, mapRevision int64
-	var rootHash, rootSignatureBytes []byte
-	var rootSignature trillian.DigitallySigned
-	var mapperMetaBytes []byte
-	var mapperMeta *trillian.MapperMetadata
+func (m *mapTX) GetHistory(revision int64, keyHash trillian.Hash) ([]storage.MapLeafRevision, error) {
+	stmt, err := m.tx.Prepare(selectMapLeafHistorySQL)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(m.ms.mapID.TreeID, []byte(keyHash), revision)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := make([]storage.MapLeafRevision, 0)
+	for rows.Next() {
+		var mapRevision int64
+		var flatData []byte
+
+		if err := rows.Scan(&mapRevision, &flatData); err != nil {
+			return nil, err
+		}
+
+		h := storage.MapLeafRevision{Revision: mapRevision}
+		if err := proto.Unmarshal(flatData, &h.Leaf); err != nil {
+			glog.Warningf("Failed to unmarshal map leaf: %v", err)
+			return nil, err
+		}
+		history = append(history, h)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
+	return history, nil
+}
+
+func (m *mapTX) LatestSignedMapRoot() (trillian.SignedMapRoot, error) {
 	stmt, err := m.tx.Prepare(selectLatestSignedMapRootSql)
 	if err != nil {
 		return trillian.SignedMapRoot{}, err
 	}
 	defer stmt.Close()
 
-	err = stmt.QueryRow(m.ms.mapID.TreeID).Scan(
-		&timestamp, &rootHash, &mapRevision, &rootSignatureBytes, &mapperMetaBytes)
+	root, err := m.scanSignedMapRoot(stmt.QueryRow(m.ms.mapID.TreeID))
 
 	// It's possible there are no roots for this tree yet
 	if err == sql.ErrNoRows {
 		return trillian.SignedMapRoot{}, nil
 	}
 
+	return root, err
+}
+
+func (m *mapTX) GetSignedMapRootAtRevision(revision int64) (trillian.SignedMapRoot, error) {
+	root, err := m.scanSignedMapRoot(m.tx.QueryRow(selectSignedMapRootAtRevisionSQL, m.ms.mapID.TreeID, revision))
+
+	if err == sql.ErrNoRows {
+		return trillian.SignedMapRoot{}, fmt.Errorf("no map head stored at revision: %d", revision)
+	}
+
+	return root, err
+}
+
+func (m *mapTX) scanSignedMapRoot(row *sql.Row) (trillian.SignedMapRoot, error) {
+	var timestamp, mapRevision int64
+	var rootHash, rootSignatureBytes []byte
+	var rootSignature trillian.DigitallySigned
+	var mapperMetaBytes []byte
+	var mapperMeta *trillian.MapperMetadata
+
+	err := row.Scan(&timestamp, &rootHash, &mapRevision, &rootSignatureBytes, &mapperMetaBytes)
+	if err != nil {
+		return trillian.SignedMapRoot{}, err
+	}
+
 	err = proto.Unmarshal(rootSignatureBytes, &rootSignature)
 	if err != nil {
 		glog.Warningf("Failed to unmarshal root signature: %v", err)
@@ -0,0 +1,20 @@
+# Converts the tables that grow with tree size to range partitioned tables, for very large
+# logs. Run this after storage.sql, preferably before any trees are created as partitioning
+# a table copies all of its rows.
+#
+# MySQL doesn't support foreign keys on partitioned tables so they're removed. The names are
+# the ones InnoDB generates for the unnamed constraints in storage.sql.
+#
+# Every table starts with only a catch all partition. Range partitions are added ahead of the
+# data by mysql.PartitionManager, see the --partition_size flag of the log server.
+
+ALTER TABLE SequencedLeafData
+  DROP FOREIGN KEY SequencedLeafData_ibfk_1,
+  DROP FOREIGN KEY SequencedLeafData_ibfk_2;
+ALTER TABLE SequencedLeafData
+  PARTITION BY RANGE(SequenceNumber) (PARTITION pmax VALUES LESS THAN (MAXVALUE));
+
+ALTER TABLE Subtree
+  DROP FOREIGN KEY Subtree_ibfk_1;
+ALTER TABLE Subtree
+  PARTITION BY RANGE(SubtreeRevision) (PARTITION pmax VALUES LESS THAN (MAXVALUE));
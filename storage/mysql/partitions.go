@@ -0,0 +1,199 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+const selectPartitionsSql string = `SELECT PARTITION_NAME,PARTITION_DESCRIPTION
+		 FROM information_schema.PARTITIONS
+		 WHERE TABLE_SCHEMA=DATABASE() AND TABLE_NAME=?
+		 ORDER BY PARTITION_ORDINAL_POSITION`
+
+// maxValuePartition is the PARTITION_DESCRIPTION of the catch all partition
+const maxValuePartition = "MAXVALUE"
+
+// partitionedTable is a table that can be range partitioned on a column that grows with the
+// size of the trees it holds.
+type partitionedTable struct {
+	name   string
+	column string
+}
+
+// partitionedTables are the tables set up for partitioning by partition_storage.sql. Leaves
+// are partitioned by sequence number and subtrees by revision, which increases with every
+// batch of leaves sequenced. Queries that filter on these columns only touch the partitions
+// they need.
+var partitionedTables = []partitionedTable{
+	{name: "SequencedLeafData", column: "SequenceNumber"},
+	{name: "Subtree", column: "SubtreeRevision"},
+}
+
+// PartitionManager adds range partitions to the partitioned tables ahead of the data that
+// will be written to them. Each partition covers partitionSize values of the partitioning
+// column for all trees. Rows beyond the last partition fall into a catch all partition, which
+// is kept empty so adding partitions doesn't have to move data.
+type PartitionManager struct {
+	db *sql.DB
+	// partitionSize is the range of column values covered by each partition
+	partitionSize int64
+	// spare is the number of empty partitions to keep beyond the largest value in each table
+	spare int
+}
+
+// NewPartitionManager creates a PartitionManager for the database at dbURL. The tables must
+// already have been partitioned by running partition_storage.sql.
+func NewPartitionManager(dbURL string, partitionSize int64, spare int) (*PartitionManager, error) {
+	if partitionSize <= 0 {
+		return nil, fmt.Errorf("partition size must be positive, got: %d", partitionSize)
+	}
+
+	if spare < 1 {
+		return nil, fmt.Errorf("must keep at least one spare partition, got: %d", spare)
+	}
+
+	db, err := openDB(dbURL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &PartitionManager{db: db, partitionSize: partitionSize, spare: spare}, nil
+}
+
+// Close releases the database connection.
+func (p *PartitionManager) Close() error {
+	return p.db.Close()
+}
+
+// Rollover adds partitions to each partitioned table so that there are enough spare ones
+// beyond its largest value. It should be run often enough that the spare partitions are not
+// used up between runs.
+func (p *PartitionManager) Rollover() error {
+	for _, table := range partitionedTables {
+		if err := p.rolloverTable(table); err != nil {
+			glog.Warningf("Failed to add partitions to %s: %v", table.name, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *PartitionManager) rolloverTable(table partitionedTable) error {
+	bounds, maxName, err := p.getPartitions(table.name)
+
+	if err != nil {
+		return err
+	}
+
+	var maxValue int64
+	if err := p.db.QueryRow(fmt.Sprintf("SELECT COALESCE(MAX(%s),0) FROM %s", table.column, table.name)).Scan(&maxValue); err != nil {
+		return err
+	}
+
+	newBounds := partitionsToAdd(bounds, maxValue, p.partitionSize, p.spare)
+
+	if len(newBounds) == 0 {
+		return nil
+	}
+
+	glog.Infof("Adding %d partitions to %s, up to %s < %d", len(newBounds), table.name, table.column, newBounds[len(newBounds)-1])
+
+	_, err = p.db.Exec(reorganizePartitionSql(table.name, maxName, newBounds))
+	return err
+}
+
+// getPartitions returns the upper bounds of the range partitions of a table in ascending
+// order and the name of its catch all partition.
+func (p *PartitionManager) getPartitions(tableName string) ([]int64, string, error) {
+	rows, err := p.db.Query(selectPartitionsSql, tableName)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer rows.Close()
+
+	bounds := make([]int64, 0)
+	maxName := ""
+
+	for rows.Next() {
+		var name, description sql.NullString
+
+		if err := rows.Scan(&name, &description); err != nil {
+			return nil, "", err
+		}
+
+		if !name.Valid {
+			return nil, "", fmt.Errorf("table %s is not partitioned, see partition_storage.sql", tableName)
+		}
+
+		if len(maxName) > 0 {
+			return nil, "", fmt.Errorf("table %s has partition %s after the %s partition", tableName, name.String, maxValuePartition)
+		}
+
+		if description.String == maxValuePartition {
+			maxName = name.String
+			continue
+		}
+
+		bound, err := strconv.ParseInt(description.String, 10, 64)
+
+		if err != nil {
+			return nil, "", fmt.Errorf("table %s partition %s has unexpected bound: %s", tableName, name.String, description.String)
+		}
+
+		bounds = append(bounds, bound)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if len(maxName) == 0 {
+		return nil, "", fmt.Errorf("table %s has no %s partition", tableName, maxValuePartition)
+	}
+
+	return bounds, maxName, nil
+}
+
+// partitionsToAdd returns the upper bounds of the partitions needed after the existing ones
+// so that at least spare partitions lie wholly beyond maxValue. Bounds are always multiples
+// of partitionSize.
+func partitionsToAdd(bounds []int64, maxValue, partitionSize int64, spare int) []int64 {
+	// The first partition that can hold values above maxValue, plus the spares
+	want := (maxValue/partitionSize + 1 + int64(spare)) * partitionSize
+	next := int64(0)
+
+	if len(bounds) > 0 {
+		next = bounds[len(bounds)-1]
+	}
+
+	next = (next/partitionSize + 1) * partitionSize
+	newBounds := make([]int64, 0)
+
+	for ; next <= want; next += partitionSize {
+		newBounds = append(newBounds, next)
+	}
+
+	return newBounds
+}
+
+// reorganizePartitionSql builds the statement that splits the catch all partition into new
+// range partitions followed by a new catch all partition.
+func reorganizePartitionSql(tableName, maxName string, newBounds []int64) string {
+	partitions := make([]string, 0, len(newBounds)+1)
+
+	for _, bound := range newBounds {
+		partitions = append(partitions, fmt.Sprintf("PARTITION p%d VALUES LESS THAN (%d)", bound, bound))
+	}
+
+	partitions = append(partitions, fmt.Sprintf("PARTITION %s VALUES LESS THAN (%s)", maxName, maxValuePartition))
+
+	return fmt.Sprintf("ALTER TABLE %s REORGANIZE PARTITION %s INTO (%s)", tableName, maxName, strings.Join(partitions, ", "))
+}
@@ -0,0 +1,59 @@
+package mysql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPartitionsToAdd(t *testing.T) {
+	for _, test := range []struct {
+		bounds   []int64
+		maxValue int64
+		spare    int
+		want     []int64
+	}{
+		// Only the catch all partition
+		{bounds: nil, maxValue: 0, spare: 1, want: []int64{100, 200}},
+		{bounds: nil, maxValue: 250, spare: 2, want: []int64{100, 200, 300, 400, 500}},
+		// Enough spares already
+		{bounds: []int64{100, 200, 300}, maxValue: 150, spare: 1, want: []int64{}},
+		{bounds: []int64{100, 200, 300}, maxValue: 199, spare: 1, want: []int64{}},
+		// Data has reached the last spare
+		{bounds: []int64{100, 200, 300}, maxValue: 200, spare: 1, want: []int64{400}},
+		{bounds: []int64{100, 200, 300}, maxValue: 200, spare: 3, want: []int64{400, 500, 600}},
+		// Data has gone beyond the partitions
+		{bounds: []int64{100}, maxValue: 350, spare: 1, want: []int64{200, 300, 400, 500}},
+		// Existing bounds that aren't multiples of the size are rounded up from
+		{bounds: []int64{150}, maxValue: 10, spare: 1, want: []int64{200}},
+	} {
+		if got := partitionsToAdd(test.bounds, test.maxValue, 100, test.spare); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("partitionsToAdd(%v, %d, 100, %d)=%v, want %v", test.bounds, test.maxValue, test.spare, got, test.want)
+		}
+	}
+}
+
+func TestReorganizePartitionSql(t *testing.T) {
+	got := reorganizePartitionSql("Subtree", "pmax", []int64{100, 200})
+	want := "ALTER TABLE Subtree REORGANIZE PARTITION pmax INTO (PARTITION p100 VALUES LESS THAN (100), " +
+		"PARTITION p200 VALUES LESS THAN (200), PARTITION pmax VALUES LESS THAN (MAXVALUE))"
+
+	if got != want {
+		t.Fatalf("reorganizePartitionSql()=%s, want %s", got, want)
+	}
+}
+
+func TestRolloverUnpartitionedTables(t *testing.T) {
+	pm, err := NewPartitionManager("test:zaphod@tcp(127.0.0.1:3306)/test", 100, 1)
+
+	if err != nil {
+		t.Fatalf("Failed to create partition manager: %v", err)
+	}
+
+	defer pm.Close()
+
+	// The test schema isn't partitioned
+	if err := pm.Rollover(); err == nil || !strings.Contains(err.Error(), "not partitioned") {
+		t.Fatalf("Rollover() on unpartitioned tables=%v", err)
+	}
+}
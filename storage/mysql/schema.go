@@ -0,0 +1,294 @@
+package mysql
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const selectColumnsSql string = `SELECT TABLE_NAME,COLUMN_NAME,COLUMN_TYPE,IS_NULLABLE
+		 FROM information_schema.COLUMNS
+		 WHERE TABLE_SCHEMA=DATABASE()
+		 ORDER BY TABLE_NAME,ORDINAL_POSITION`
+
+// SchemaColumn is a column of a table in the storage schema.
+type SchemaColumn struct {
+	Name string
+	// Type is the column type as reported by INFORMATION_SCHEMA, without the display width
+	// of integer types, which varies between servers, e.g. varbinary(255) or bigint unsigned
+	Type     string
+	Nullable bool
+}
+
+// SchemaTable is a table in the storage schema.
+type SchemaTable struct {
+	Name    string
+	Columns []SchemaColumn
+}
+
+// expectedSchema is the schema this version of the storage code works with, as created by
+// storage.sql. It must be kept up to date with storage.sql. Columns that are part of a
+// primary key are NOT NULL even if storage.sql doesn't say so.
+var expectedSchema = []SchemaTable{
+	{Name: "Trees", Columns: []SchemaColumn{
+		{Name: "TreeId", Type: "int"},
+		{Name: "KeyId", Type: "varbinary(255)"},
+		{Name: "TreeType", Type: "enum('LOG','MAP')"},
+		{Name: "LeafHasherType", Type: "enum('SHA256','SHA512_256','SHA3_256')"},
+		{Name: "TreeHasherType", Type: "enum('SHA256','SHA512_256','SHA3_256')"},
+		{Name: "AllowsDuplicateLeaves", Type: "tinyint"},
+		{Name: "LeafHashStrategy", Type: "enum('RFC6962','RAW')"},
+		{Name: "TreeDepth", Type: "int"},
+		{Name: "WrappedDataKey", Type: "varbinary(1024)", Nullable: true},
+		{Name: "DisplayName", Type: "varchar(255)"},
+		{Name: "Description", Type: "varchar(1024)"},
+		{Name: "OwnerContact", Type: "varchar(255)"},
+		{Name: "CreateTime", Type: "timestamp"},
+	}},
+	{Name: "TreeControl", Columns: []SchemaColumn{
+		{Name: "TreeId", Type: "int"},
+		{Name: "ReadOnlyRequests", Type: "tinyint", Nullable: true},
+		{Name: "SigningEnabled", Type: "tinyint", Nullable: true},
+		{Name: "SequencingEnabled", Type: "tinyint", Nullable: true},
+		{Name: "SequenceIntervalSeconds", Type: "int", Nullable: true},
+		{Name: "SignIntervalSeconds", Type: "int", Nullable: true},
+	}},
+	{Name: "Subtree", Columns: []SchemaColumn{
+		{Name: "TreeId", Type: "int"},
+		{Name: "SubtreeId", Type: "varbinary(255)"},
+		{Name: "Nodes", Type: "varbinary(32768)"},
+		{Name: "SubtreeRevision", Type: "int"},
+	}},
+	{Name: "TreeHead", Columns: []SchemaColumn{
+		{Name: "TreeId", Type: "int"},
+		{Name: "TreeHeadTimestamp", Type: "bigint"},
+		{Name: "TreeSize", Type: "bigint", Nullable: true},
+		{Name: "RootHash", Type: "varbinary(255)"},
+		{Name: "RootSignature", Type: "varbinary(255)"},
+		{Name: "TreeRevision", Type: "bigint", Nullable: true},
+		{Name: "RootMetadata", Type: "blob", Nullable: true},
+	}},
+	{Name: "TreeStats", Columns: []SchemaColumn{
+		{Name: "TreeId", Type: "int"},
+		{Name: "TableName", Type: "varchar(32)"},
+		{Name: "RowCount", Type: "bigint"},
+		{Name: "ByteSize", Type: "bigint"},
+		{Name: "Watermark", Type: "bigint"},
+		{Name: "SampleTimeNanos", Type: "bigint"},
+		{Name: "BaseRowCount", Type: "bigint"},
+		{Name: "BaseByteSize", Type: "bigint"},
+		{Name: "BaseTimeNanos", Type: "bigint"},
+		{Name: "NextBaseRowCount", Type: "bigint"},
+		{Name: "NextBaseByteSize", Type: "bigint"},
+		{Name: "NextBaseTimeNanos", Type: "bigint"},
+	}},
+	{Name: "CompactTree", Columns: []SchemaColumn{
+		{Name: "TreeId", Type: "int"},
+		{Name: "TreeSize", Type: "bigint"},
+		{Name: "State", Type: "blob"},
+	}},
+	{Name: "LeafData", Columns: []SchemaColumn{
+		{Name: "TreeId", Type: "int"},
+		{Name: "LeafHash", Type: "varbinary(255)"},
+		{Name: "TheData", Type: "blob"},
+		{Name: "ExtraData", Type: "blob", Nullable: true},
+		{Name: "ExtraDataBlobKey", Type: "varchar(255)", Nullable: true},
+	}},
+	{Name: "SequencedLeafData", Columns: []SchemaColumn{
+		{Name: "TreeId", Type: "int"},
+		{Name: "SequenceNumber", Type: "bigint unsigned"},
+		{Name: "LeafHash", Type: "varbinary(255)"},
+		{Name: "SignedEntryTimestamp", Type: "blob"},
+		{Name: "IntegrateTimestampNanos", Type: "bigint"},
+	}},
+	{Name: "Unsequenced", Columns: []SchemaColumn{
+		{Name: "TreeId", Type: "int"},
+		{Name: "LeafHash", Type: "varbinary(255)"},
+		{Name: "MessageId", Type: "binary(32)"},
+		{Name: "Payload", Type: "blob"},
+		{Name: "QueueTimestamp", Type: "timestamp"},
+		{Name: "SignedEntryTimestamp", Type: "blob", Nullable: true},
+		{Name: "Priority", Type: "int"},
+	}},
+	{Name: "SequenceRange", Columns: []SchemaColumn{
+		{Name: "TreeId", Type: "int"},
+		{Name: "FirstSequenceNumber", Type: "bigint"},
+		{Name: "EndSequenceNumber", Type: "bigint"},
+		{Name: "SequencerId", Type: "varchar(255)"},
+		{Name: "FencingToken", Type: "bigint"},
+	}},
+	{Name: "SequenceRangeCounter", Columns: []SchemaColumn{
+		{Name: "TreeId", Type: "int"},
+		{Name: "NextSequenceNumber", Type: "bigint"},
+		{Name: "NextFencingToken", Type: "bigint"},
+	}},
+	{Name: "CommitToken", Columns: []SchemaColumn{
+		{Name: "TreeId", Type: "int"},
+		{Name: "Token", Type: "varbinary(32)"},
+	}},
+	{Name: "MapLeaf", Columns: []SchemaColumn{
+		{Name: "TreeId", Type: "int"},
+		{Name: "KeyHash", Type: "varbinary(255)"},
+		{Name: "MapRevision", Type: "bigint"},
+		{Name: "TheData", Type: "blob"},
+	}},
+	{Name: "MapHead", Columns: []SchemaColumn{
+		{Name: "TreeId", Type: "int"},
+		{Name: "MapHeadTimestamp", Type: "bigint"},
+		{Name: "RootHash", Type: "varbinary(255)"},
+		{Name: "MapRevision", Type: "bigint", Nullable: true},
+		{Name: "RootSignature", Type: "varbinary(255)"},
+		{Name: "MapperData", Type: "blob", Nullable: true},
+	}},
+}
+
+// ExpectedSchema returns the schema that this version of the storage code works with. It
+// must not be modified.
+func ExpectedSchema() []SchemaTable {
+	return expectedSchema
+}
+
+// integerWidth matches the display width of integer column types. Servers differ in whether
+// they report it and it doesn't affect the values that can be stored.
+var integerWidth = regexp.MustCompile(`^(tinyint|smallint|mediumint|int|bigint)\(\d+\)`)
+
+// normalizeColumnType returns a column type in the form used by SchemaColumn. It isn't
+// lower cased as that would change the values of enums, INFORMATION_SCHEMA already gives the
+// type names in lower case.
+func normalizeColumnType(columnType string) string {
+	return integerWidth.ReplaceAllString(columnType, "$1")
+}
+
+// ReadSchema returns the tables of the database at dbURL, in name order.
+func ReadSchema(dbURL string) ([]SchemaTable, error) {
+	db, err := openDB(dbURL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	rows, err := db.Query(selectColumnsSql)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tables := make([]SchemaTable, 0)
+
+	for rows.Next() {
+		var tableName, isNullable string
+		var column SchemaColumn
+
+		if err := rows.Scan(&tableName, &column.Name, &column.Type, &isNullable); err != nil {
+			return nil, err
+		}
+
+		column.Type = normalizeColumnType(column.Type)
+		column.Nullable = isNullable == "YES"
+
+		if len(tables) == 0 || tables[len(tables)-1].Name != tableName {
+			tables = append(tables, SchemaTable{Name: tableName})
+		}
+
+		last := &tables[len(tables)-1]
+		last.Columns = append(last.Columns, column)
+	}
+
+	return tables, rows.Err()
+}
+
+// SchemaProblem is a difference between a database's schema and the expected one that would
+// make queries fail. Column is empty if the problem is with the whole table.
+type SchemaProblem struct {
+	Table   string
+	Column  string
+	Problem string
+}
+
+func (p SchemaProblem) String() string {
+	if len(p.Column) == 0 {
+		return fmt.Sprintf("%s: %s", p.Table, p.Problem)
+	}
+
+	return fmt.Sprintf("%s.%s: %s", p.Table, p.Column, p.Problem)
+}
+
+// SchemaError is returned by CheckSchema if the database doesn't have the expected schema.
+type SchemaError struct {
+	Problems []SchemaProblem
+}
+
+func (e SchemaError) Error() string {
+	problems := make([]string, 0, len(e.Problems))
+
+	for _, p := range e.Problems {
+		problems = append(problems, p.String())
+	}
+
+	return fmt.Sprintf("database schema doesn't match storage.sql, it may need to be upgraded: %s", strings.Join(problems, "; "))
+}
+
+// CompareSchema returns the problems with the live schema of a database compared to the
+// expected one. Table and column names are compared case insensitively like MySQL does.
+// Tables and columns that aren't expected are ignored as the code doesn't use them, as are
+// nullable columns that are expected to be NOT NULL.
+func CompareSchema(expected, live []SchemaTable) []SchemaProblem {
+	liveTables := make(map[string]SchemaTable)
+
+	for _, table := range live {
+		liveTables[strings.ToLower(table.Name)] = table
+	}
+
+	problems := make([]SchemaProblem, 0)
+
+	for _, table := range expected {
+		liveTable, ok := liveTables[strings.ToLower(table.Name)]
+
+		if !ok {
+			problems = append(problems, SchemaProblem{Table: table.Name, Problem: "table is missing"})
+			continue
+		}
+
+		liveColumns := make(map[string]SchemaColumn)
+
+		for _, column := range liveTable.Columns {
+			liveColumns[strings.ToLower(column.Name)] = column
+		}
+
+		for _, column := range table.Columns {
+			liveColumn, ok := liveColumns[strings.ToLower(column.Name)]
+
+			switch {
+			case !ok:
+				problems = append(problems, SchemaProblem{Table: table.Name, Column: column.Name, Problem: fmt.Sprintf("column is missing, expected %s", column.Type)})
+			case liveColumn.Type != column.Type:
+				problems = append(problems, SchemaProblem{Table: table.Name, Column: column.Name, Problem: fmt.Sprintf("column type is %s, expected %s", liveColumn.Type, column.Type)})
+			case column.Nullable && !liveColumn.Nullable:
+				problems = append(problems, SchemaProblem{Table: table.Name, Column: column.Name, Problem: "column is NOT NULL, expected it to allow NULL"})
+			}
+		}
+	}
+
+	return problems
+}
+
+// CheckSchema checks that the database at dbURL has the tables and columns that the storage
+// code expects, so that a server with an out of date database fails at startup rather than
+// on its first query of a missing column. A SchemaError is returned if it doesn't.
+func CheckSchema(dbURL string) error {
+	live, err := ReadSchema(dbURL)
+
+	if err != nil {
+		return err
+	}
+
+	if problems := CompareSchema(expectedSchema, live); len(problems) > 0 {
+		return SchemaError{Problems: problems}
+	}
+
+	return nil
+}
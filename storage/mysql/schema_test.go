@@ -0,0 +1,109 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeColumnType(t *testing.T) {
+	for _, test := range []struct {
+		columnType string
+		want       string
+	}{
+		{columnType: "int(11)", want: "int"},
+		{columnType: "int", want: "int"},
+		{columnType: "tinyint(1)", want: "tinyint"},
+		{columnType: "bigint(20) unsigned", want: "bigint unsigned"},
+		{columnType: "varbinary(255)", want: "varbinary(255)"},
+		{columnType: "binary(32)", want: "binary(32)"},
+		{columnType: "enum('LOG','MAP')", want: "enum('LOG','MAP')"},
+	} {
+		if got := normalizeColumnType(test.columnType); got != test.want {
+			t.Errorf("normalizeColumnType(%q)=%q, want %q", test.columnType, got, test.want)
+		}
+	}
+}
+
+func TestCompareSchema(t *testing.T) {
+	expected := []SchemaTable{
+		{Name: "Trees", Columns: []SchemaColumn{
+			{Name: "TreeId", Type: "int"},
+			{Name: "KeyId", Type: "varbinary(255)"},
+			{Name: "WrappedDataKey", Type: "varbinary(1024)", Nullable: true},
+		}},
+		{Name: "LeafData", Columns: []SchemaColumn{
+			{Name: "TreeId", Type: "int"},
+		}},
+	}
+
+	for _, test := range []struct {
+		desc string
+		live []SchemaTable
+		want []SchemaProblem
+	}{
+		{
+			desc: "same",
+			live: expected,
+			want: []SchemaProblem{},
+		},
+		{
+			desc: "extra tables and columns and different case",
+			live: []SchemaTable{
+				{Name: "trees", Columns: []SchemaColumn{
+					{Name: "treeid", Type: "int"},
+					{Name: "KeyId", Type: "varbinary(255)", Nullable: true},
+					{Name: "WrappedDataKey", Type: "varbinary(1024)", Nullable: true},
+					{Name: "Unused", Type: "blob"},
+				}},
+				{Name: "LeafData", Columns: []SchemaColumn{{Name: "TreeId", Type: "int"}}},
+				{Name: "Unused", Columns: []SchemaColumn{{Name: "TreeId", Type: "int"}}},
+			},
+			want: []SchemaProblem{},
+		},
+		{
+			desc: "missing table",
+			live: expected[:1],
+			want: []SchemaProblem{{Table: "LeafData", Problem: "table is missing"}},
+		},
+		{
+			desc: "column problems",
+			live: []SchemaTable{
+				{Name: "Trees", Columns: []SchemaColumn{
+					{Name: "TreeId", Type: "bigint"},
+					{Name: "WrappedDataKey", Type: "varbinary(1024)"},
+				}},
+				expected[1],
+			},
+			want: []SchemaProblem{
+				{Table: "Trees", Column: "TreeId", Problem: "column type is bigint, expected int"},
+				{Table: "Trees", Column: "KeyId", Problem: "column is missing, expected varbinary(255)"},
+				{Table: "Trees", Column: "WrappedDataKey", Problem: "column is NOT NULL, expected it to allow NULL"},
+			},
+		},
+	} {
+		if got := CompareSchema(expected, test.live); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: CompareSchema()=%v, want %v", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestSchemaErrorString(t *testing.T) {
+	err := SchemaError{Problems: []SchemaProblem{
+		{Table: "LeafData", Problem: "table is missing"},
+		{Table: "Trees", Column: "KeyId", Problem: "column is missing, expected varbinary(255)"},
+	}}
+	want := "database schema doesn't match storage.sql, it may need to be upgraded: " +
+		"LeafData: table is missing; Trees.KeyId: column is missing, expected varbinary(255)"
+
+	if got := err.Error(); got != want {
+		t.Errorf("Error()=%q, want %q", got, want)
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	// The test database is created from storage.sql so this fails if expectedSchema is out
+	// of date
+	if err := CheckSchema("test:zaphod@tcp(127.0.0.1:3306)/test"); err != nil {
+		t.Errorf("CheckSchema()=%v, want nil", err)
+	}
+}
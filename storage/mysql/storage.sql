@@ -11,9 +11,22 @@ CREATE TABLE IF NOT EXISTS Trees(
   TreeId                INTEGER NOT NULL,
   KeyId                 VARBINARY(255) NOT NULL,
   TreeType              ENUM('LOG', 'MAP')  NOT NULL,
-  LeafHasherType        ENUM('SHA256') NOT NULL,
-  TreeHasherType        ENUM('SHA256') NOT NULL,
+  -- The hash function of the tree's leaves and nodes, both columns must be the same
+  LeafHasherType        ENUM('SHA256', 'SHA512_256', 'SHA3_256') NOT NULL,
+  TreeHasherType        ENUM('SHA256', 'SHA512_256', 'SHA3_256') NOT NULL,
   AllowsDuplicateLeaves BOOLEAN NOT NULL DEFAULT 0,
+  LeafHashStrategy      ENUM('RFC6962', 'RAW') NOT NULL DEFAULT 'RFC6962',
+  -- The number of levels below the root of a log tree, a multiple of 8 up to 64. Maps have a
+  -- level for each bit of their key hashes and don't use this.
+  TreeDepth             INTEGER NOT NULL DEFAULT 64,
+  -- Set if leaf data is encrypted, this is the tree's data key wrapped by a key manager
+  WrappedDataKey        VARBINARY(1024),
+  -- Human readable metadata that identifies the tree. Unlike the columns above it can be
+  -- changed at any time, it doesn't affect the tree.
+  DisplayName           VARCHAR(255) NOT NULL DEFAULT '',
+  Description           VARCHAR(1024) NOT NULL DEFAULT '',
+  OwnerContact          VARCHAR(255) NOT NULL DEFAULT '',
+  CreateTime            TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
   PRIMARY KEY(TreeId)
 );
 
@@ -48,16 +61,50 @@ CREATE TABLE IF NOT EXISTS TreeHead(
   RootHash             VARBINARY(255) NOT NULL,
   RootSignature        VARBINARY(255) NOT NULL,
   TreeRevision         BIGINT,
+  RootMetadata         BLOB,
   PRIMARY KEY(TreeId, TreeHeadTimestamp),
   UNIQUE INDEX TreeRevisionIdx(TreeId, TreeRevision),
   FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
 );
 
+-- The storage used by each tree, kept up to date incrementally by reading the rows
+-- written since the last update, see storage.TreeStatsReader. There is a row for each
+-- of the leaf, node and root tables. Rows are counted up to Watermark, which is a
+-- sequence number or tree revision depending on the table.
+CREATE TABLE IF NOT EXISTS TreeStats(
+  TreeId               INTEGER NOT NULL,
+  TableName            VARCHAR(32) NOT NULL,
+  RowCount             BIGINT NOT NULL,
+  ByteSize             BIGINT NOT NULL,
+  Watermark            BIGINT NOT NULL,
+  SampleTimeNanos      BIGINT NOT NULL,
+  -- Growth rates are measured from the base sample
+  BaseRowCount         BIGINT NOT NULL,
+  BaseByteSize         BIGINT NOT NULL,
+  BaseTimeNanos        BIGINT NOT NULL,
+  NextBaseRowCount     BIGINT NOT NULL,
+  NextBaseByteSize     BIGINT NOT NULL,
+  NextBaseTimeNanos    BIGINT NOT NULL,
+  PRIMARY KEY(TreeId, TableName),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
 
 -- ---------------------------------------------
 -- Log specific stuff here
 -- ---------------------------------------------
 
+-- The compact Merkle tree state of each log as of its latest sequenced batch, a
+-- serialized CompactTreeProto. The sequencer restores the tree from this row
+-- rather than fetching the nodes it needs from Subtree.
+CREATE TABLE IF NOT EXISTS CompactTree(
+  TreeId               INTEGER NOT NULL,
+  TreeSize             BIGINT NOT NULL,
+  State                BLOB NOT NULL,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
 -- Creating index at same time as table allows some storage engines to better
 -- optimize physical storage layout. Most engines allow multiple nulls in a
 -- unique index but some may not.
@@ -68,6 +115,10 @@ CREATE TABLE IF NOT EXISTS LeafData(
   TreeId               INTEGER NOT NULL,
   LeafHash             VARBINARY(255) NOT NULL,
   TheData              BLOB NOT NULL,
+  -- ExtraData is stored inline unless it's been moved to a blob store, in which case
+  -- ExtraDataBlobKey holds the key of the blob instead
+  ExtraData            BLOB,
+  ExtraDataBlobKey     VARCHAR(255),
   PRIMARY KEY(TreeId, LeafHash),
   INDEX LeafHashIdx(LeafHash),
   FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
@@ -83,7 +134,9 @@ CREATE TABLE IF NOT EXISTS SequencedLeafData(
   SequenceNumber       BIGINT UNSIGNED NOT NULL,
   LeafHash             VARBINARY(255) NOT NULL,
   SignedEntryTimestamp BLOB NOT NULL,
+  IntegrateTimestampNanos BIGINT NOT NULL DEFAULT 0,
   PRIMARY KEY(TreeId, SequenceNumber),
+  INDEX IntegrateTimestampIdx(TreeId, IntegrateTimestampNanos),
   FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE,
   FOREIGN KEY(LeafHash) REFERENCES LeafData(LeafHash)
 );
@@ -98,10 +151,48 @@ CREATE TABLE IF NOT EXISTS Unsequenced(
   Payload              BLOB NOT NULL,
   QueueTimestamp       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
   SignedEntryTimestamp BLOB,
-  PRIMARY KEY (TreeId, LeafHash, MessageId)
+  -- Leaves with a higher priority are dequeued first, see LeafProto.priority
+  Priority             INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (TreeId, LeafHash, MessageId),
+  INDEX (TreeId, Priority, QueueTimestamp)
 );
 
 
+-- The sequence numbers reserved by each sequencer when several sequence a log
+-- concurrently, see storage.SequenceRangeReserver. A row is deleted when its
+-- sequencer releases the range. FencingToken changes when another sequencer takes
+-- the range over and writes must present the current value.
+CREATE TABLE IF NOT EXISTS SequenceRange(
+  TreeId               INTEGER NOT NULL,
+  FirstSequenceNumber  BIGINT NOT NULL,
+  EndSequenceNumber    BIGINT NOT NULL,
+  SequencerId          VARCHAR(255) NOT NULL,
+  FencingToken         BIGINT NOT NULL,
+  PRIMARY KEY(TreeId, FirstSequenceNumber),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- The next sequence number and fencing token to hand out for each log. It's kept
+-- separately from SequenceRange so that neither goes backwards when ranges are
+-- released.
+CREATE TABLE IF NOT EXISTS SequenceRangeCounter(
+  TreeId               INTEGER NOT NULL,
+  NextSequenceNumber   BIGINT NOT NULL,
+  NextFencingToken     BIGINT NOT NULL,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- The token stored by the latest transaction of each log that recorded one, see
+-- storage.CommitTokenStore. It tells the sequencer whether a transaction whose
+-- commit failed was committed anyway.
+CREATE TABLE IF NOT EXISTS CommitToken(
+  TreeId               INTEGER NOT NULL,
+  Token                VARBINARY(32) NOT NULL,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
 -- ---------------------------------------------
 -- Map specific stuff here
 -- ---------------------------------------------
@@ -6,7 +6,9 @@ import (
 	"database/sql"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"reflect"
 	"runtime/debug"
 	"strings"
 	"sync"
@@ -16,12 +18,15 @@ import (
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
+	"github.com/google/trillian/crypto"
+	"github.com/google/trillian/merkle"
 	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/testsuite"
 )
 
 // TODO(al): add checking to all the Commit() calls in here.
 
-var allTables = []string{"Unsequenced", "TreeHead", "SequencedLeafData", "LeafData", "Subtree", "TreeControl", "Trees", "MapLeaf", "MapHead"}
+var allTables = []string{"Unsequenced", "CompactTree", "CommitToken", "SequenceRange", "SequenceRangeCounter", "TreeHead", "SequencedLeafData", "LeafData", "Subtree", "TreeControl", "Trees", "MapLeaf", "MapHead"}
 
 // Must be 32 bytes to match sha256 length if it was a real hash
 var dummyHash = []byte("hashxxxxhashxxxxhashxxxxhashxxxx")
@@ -224,6 +229,139 @@ func TestNodeRoundTrip(t *testing.T) {
 	}
 }
 
+// storeTestTree writes the nodes of a tree of treeSize leaves at revision, the way the
+// sequencer would, and returns their IDs.
+func storeTestTree(t testing.TB, s storage.LogStorage, treeSize, revision int64) []storage.NodeID {
+	hasher := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+	mt := merkle.NewCompactMerkleTree(hasher)
+	nodes := make(map[string]storage.Node)
+
+	for i := int64(0); i < treeSize; i++ {
+		mt.AddLeaf([]byte(fmt.Sprintf("Leaf %d", i)), func(depth int, index int64, hash trillian.Hash) {
+			nodeID, err := storage.NewNodeIDForTreeCoords(int64(depth), index, storage.DefaultLogTreeDepth)
+			if err != nil {
+				t.Fatalf("Failed to create node ID: %v", err)
+			}
+			nodes[nodeID.String()] = storage.Node{NodeID: nodeID, Hash: hash, NodeRevision: revision}
+		})
+	}
+
+	toStore := make([]storage.Node, 0, len(nodes))
+	ids := make([]storage.NodeID, 0, len(nodes))
+	for _, node := range nodes {
+		toStore = append(toStore, node)
+		ids = append(ids, node.NodeID)
+	}
+
+	tx := beginLogTx(s, t)
+	forceWriteRevision(revision, tx)
+	if err := tx.SetMerkleNodes(toStore); err != nil {
+		t.Fatalf("Failed to store nodes: %v", err)
+	}
+	commit(tx, t)
+
+	return ids
+}
+
+func TestGetProofNodes(t *testing.T) {
+	logID := createLogID("TestGetProofNodes")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, t)
+
+	// Big enough for proofs to pass through several subtrees
+	const treeSize, revision = int64(600), int64(3)
+	storeTestTree(t, s, treeSize, revision)
+
+	// getNodes runs f in its own transaction so that no subtrees are already cached
+	getNodes := func(f func(tx storage.LogTX) ([]storage.Node, error)) []storage.Node {
+		tx := beginLogTx(s, t)
+		defer commit(tx, t)
+
+		nodes, err := f(tx)
+		if err != nil {
+			t.Fatalf("Failed to get proof nodes: %v", err)
+		}
+		return nodes
+	}
+
+	for _, test := range []struct{ size, index int64 }{{600, 0}, {600, 599}, {600, 255}, {600, 256}, {600, 300}} {
+		ids, err := merkle.CalcInclusionProofNodeAddresses(test.size, test.index, storage.DefaultLogTreeDepth)
+		if err != nil {
+			t.Fatalf("Failed to calculate proof nodes: %v", err)
+		}
+
+		want := getNodes(func(tx storage.LogTX) ([]storage.Node, error) { return tx.GetMerkleNodes(revision, ids) })
+		got := getNodes(func(tx storage.LogTX) ([]storage.Node, error) {
+			return tx.(storage.ProofReader).GetInclusionProofNodes(revision, test.size, test.index)
+		})
+
+		if len(want) != len(ids) {
+			t.Fatalf("Inclusion proof for %d at size %d: got %d nodes from GetMerkleNodes, expected %d", test.index, test.size, len(want), len(ids))
+		}
+
+		if err := nodesAreEqual(got, want); err != nil {
+			t.Errorf("Inclusion proof for %d at size %d: %v", test.index, test.size, err)
+		}
+	}
+
+	for _, test := range []struct{ first, second int64 }{{1, 600}, {256, 600}, {300, 600}, {599, 600}} {
+		ids, err := merkle.CalcConsistencyProofNodeAddresses(test.first, test.second, storage.DefaultLogTreeDepth)
+		if err != nil {
+			t.Fatalf("Failed to calculate proof nodes: %v", err)
+		}
+
+		want := getNodes(func(tx storage.LogTX) ([]storage.Node, error) { return tx.GetMerkleNodes(revision, ids) })
+		got := getNodes(func(tx storage.LogTX) ([]storage.Node, error) {
+			return tx.(storage.ProofReader).GetConsistencyProofNodes(revision, test.first, test.second)
+		})
+
+		if err := nodesAreEqual(got, want); err != nil {
+			t.Errorf("Consistency proof from %d to %d: %v", test.first, test.second, err)
+		}
+	}
+}
+
+// benchmarkInclusionProofs fetches inclusion proofs for every leaf in turn, each in its own
+// transaction, using get.
+func benchmarkInclusionProofs(b *testing.B, name string, get func(tx storage.LogTX, treeSize, index int64) ([]storage.Node, error)) {
+	logID := createLogID(name)
+	db := prepareTestLogDB(logID, b)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, b)
+
+	const treeSize, revision = int64(1 << 16), int64(1)
+	storeTestTree(b, s, treeSize, revision)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		tx := beginLogTx(s, b)
+		if _, err := get(tx, treeSize, int64(i)%treeSize); err != nil {
+			b.Fatalf("Failed to get proof: %v", err)
+		}
+		commit(tx, b)
+	}
+}
+
+// BenchmarkInclusionProofByNodeIDs fetches proofs the way the log server does for storage
+// that isn't a ProofReader, one subtree query at a time.
+func BenchmarkInclusionProofByNodeIDs(b *testing.B) {
+	benchmarkInclusionProofs(b, "BenchmarkInclusionProofByNodeIDs", func(tx storage.LogTX, treeSize, index int64) ([]storage.Node, error) {
+		ids, err := merkle.CalcInclusionProofNodeAddresses(treeSize, index, storage.DefaultLogTreeDepth)
+		if err != nil {
+			return nil, err
+		}
+		return tx.GetMerkleNodes(1, ids)
+	})
+}
+
+// BenchmarkInclusionProofInOneQuery fetches proofs with storage.ProofReader.
+func BenchmarkInclusionProofInOneQuery(b *testing.B) {
+	benchmarkInclusionProofs(b, "BenchmarkInclusionProofInOneQuery", func(tx storage.LogTX, treeSize, index int64) ([]storage.Node, error) {
+		return tx.(storage.ProofReader).GetInclusionProofNodes(1, treeSize, index)
+	})
+}
+
 // Explicit test for node id conversion to / from protos.
 func TestNodeIDSerialization(t *testing.T) {
 	nodeID := storage.NodeID{[]byte("hello"), 3, 40}
@@ -246,6 +384,93 @@ func TestNodeIDSerialization(t *testing.T) {
 	}
 }
 
+func TestLeafHashStrategy(t *testing.T) {
+	logID := createLogID("TestLeafHashStrategy")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+
+	// Trees default to RFC 6962 leaf hashing
+	s := prepareTestLogStorage(logID, t)
+
+	if got, want := s.LeafHashStrategy(), trillian.LeafHashStrategy_RFC6962_LEAF_HASH; got != want {
+		t.Fatalf("Got leaf hash strategy %v, expected %v", got, want)
+	}
+
+	if _, err := db.Exec("UPDATE Trees SET LeafHashStrategy='RAW' WHERE TreeId=?", logID.logID.TreeID); err != nil {
+		t.Fatalf("Failed to update leaf hash strategy: %v", err)
+	}
+
+	s = prepareTestLogStorage(logID, t)
+
+	if got, want := s.LeafHashStrategy(), trillian.LeafHashStrategy_RAW_LEAF_HASH; got != want {
+		t.Fatalf("Got leaf hash strategy %v, expected %v", got, want)
+	}
+}
+
+func TestHashAlgorithm(t *testing.T) {
+	logID := createLogID("TestHashAlgorithm")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+
+	// The test trees use SHA-256
+	s := prepareTestLogStorage(logID, t)
+
+	if got, want := s.HashAlgorithm(), trillian.HashAlgorithm_SHA256; got != want {
+		t.Fatalf("Got hash algorithm %v, expected %v", got, want)
+	}
+
+	if _, err := db.Exec("UPDATE Trees SET LeafHasherType='SHA3_256',TreeHasherType='SHA3_256' WHERE TreeId=?", logID.logID.TreeID); err != nil {
+		t.Fatalf("Failed to update hasher types: %v", err)
+	}
+
+	s = prepareTestLogStorage(logID, t)
+
+	if got, want := s.HashAlgorithm(), trillian.HashAlgorithm_SHA3_256; got != want {
+		t.Fatalf("Got hash algorithm %v, expected %v", got, want)
+	}
+
+	// Leaves and nodes can't be hashed differently
+	if _, err := db.Exec("UPDATE Trees SET LeafHasherType='SHA256' WHERE TreeId=?", logID.logID.TreeID); err != nil {
+		t.Fatalf("Failed to update hasher types: %v", err)
+	}
+
+	if _, err := NewLogStorage(logID.logID, "test:zaphod@tcp(127.0.0.1:3306)/test"); err == nil {
+		t.Fatal("Opened storage for a log with different leaf and tree hasher types")
+	}
+}
+
+func TestTreeDepth(t *testing.T) {
+	logID := createLogID("TestTreeDepth")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+
+	// Trees default to the maximum depth for a log
+	s := prepareTestLogStorage(logID, t)
+
+	if got, want := s.TreeDepth(), storage.DefaultLogTreeDepth; got != want {
+		t.Fatalf("Got tree depth %d, expected %d", got, want)
+	}
+
+	if _, err := db.Exec("UPDATE Trees SET TreeDepth=32 WHERE TreeId=?", logID.logID.TreeID); err != nil {
+		t.Fatalf("Failed to update tree depth: %v", err)
+	}
+
+	s = prepareTestLogStorage(logID, t)
+
+	if got, want := s.TreeDepth(), 32; got != want {
+		t.Fatalf("Got tree depth %d, expected %d", got, want)
+	}
+
+	// Trees created with a depth that isn't a multiple of 8 can't be used
+	if _, err := db.Exec("UPDATE Trees SET TreeDepth=30 WHERE TreeId=?", logID.logID.TreeID); err != nil {
+		t.Fatalf("Failed to update tree depth: %v", err)
+	}
+
+	if _, err := NewLogStorage(logID.logID, "test:zaphod@tcp(127.0.0.1:3306)/test"); err == nil {
+		t.Fatal("Opened storage for a log with an invalid tree depth")
+	}
+}
+
 func TestQueueDuplicateLeafFails(t *testing.T) {
 	logID := createLogID("TestQueueDuplicateLeafFails")
 	db := prepareTestLogDB(logID, t)
@@ -318,6 +543,171 @@ func TestQueueLeaves(t *testing.T) {
 	}
 }
 
+func TestEncryptedLeafData(t *testing.T) {
+	logID := createLogID("TestEncryptedLeafData")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+
+	keyWrapper, err := crypto.NewAESKeyWrapper(bytes.Repeat([]byte{0x11}, crypto.DataKeySize))
+
+	if err != nil {
+		t.Fatalf("Failed to create key wrapper: %v", err)
+	}
+
+	s, err := NewEncryptedLogStorage(logID.logID, "test:zaphod@tcp(127.0.0.1:3306)/test", keyWrapper)
+
+	if err != nil {
+		t.Fatalf("Failed to open encrypted log storage: %v", err)
+	}
+
+	leaves := createTestLeaves(1, 0)
+	tx := beginLogTx(s, t)
+
+	if err := tx.QueueLeaves(leaves); err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+
+	// Queued leaves are decrypted when they're dequeued
+	dequeued, err := tx.DequeueLeaves(99)
+
+	if err != nil {
+		t.Fatalf("Failed to dequeue leaves: %v", err)
+	}
+
+	if len(dequeued) != 1 || !bytes.Equal(dequeued[0].LeafValue, leaves[0].LeafValue) {
+		t.Fatalf("Dequeued %v, want leaf value %v", dequeued, leaves[0].LeafValue)
+	}
+
+	if err := tx.UpdateSequencedLeaves(leaves); err != nil {
+		t.Fatalf("Failed to sequence leaves: %v", err)
+	}
+
+	commit(tx, t)
+
+	// The stored data must not be the plaintext
+	var stored, storedExtraData []byte
+
+	if err := db.QueryRow("SELECT TheData,ExtraData FROM LeafData WHERE TreeId=?", logID.logID.TreeID).Scan(&stored, &storedExtraData); err != nil {
+		t.Fatalf("Could not query leaf data: %v", err)
+	}
+
+	if bytes.Contains(stored, leaves[0].LeafValue) {
+		t.Fatalf("Leaf data was stored unencrypted: %v", stored)
+	}
+
+	if bytes.Contains(storedExtraData, leaves[0].ExtraData) {
+		t.Fatalf("Leaf ExtraData was stored unencrypted: %v", storedExtraData)
+	}
+
+	// But it should be decrypted when read back
+	tx = beginLogTx(s, t)
+	defer tx.Commit()
+
+	got, err := tx.GetLeavesByHash([]trillian.Hash{leaves[0].LeafHash}, false)
+
+	if err != nil {
+		t.Fatalf("Failed to get leaves by hash: %v", err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("Got %d leaves but expected one", len(got))
+	}
+
+	checkLeafContents(got[0], 0, leaves[0].LeafHash, leaves[0].LeafValue, t)
+
+	if got, want := got[0].ExtraData, leaves[0].ExtraData; !bytes.Equal(got, want) {
+		t.Fatalf("Got ExtraData %v, want %v", got, want)
+	}
+
+	// Opening the tree without a key wrapper must fail rather than return ciphertext
+	if _, err := NewLogStorage(logID.logID, "test:zaphod@tcp(127.0.0.1:3306)/test"); err == nil {
+		t.Fatal("Opened encrypted log without a key wrapper")
+	}
+}
+
+func TestExtraDataBlobStore(t *testing.T) {
+	logID := createLogID("TestExtraDataBlobStore")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, t)
+
+	dir, err := ioutil.TempDir("", "extradata")
+
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	blobStore, err := storage.NewFileBlobStore(dir)
+
+	if err != nil {
+		t.Fatalf("Failed to create blob store: %v", err)
+	}
+
+	if err := EnableExtraDataBlobStore(s, blobStore, 16); err != nil {
+		t.Fatalf("Failed to enable blob store: %v", err)
+	}
+
+	// The first leaf's ExtraData is under the threshold, the second one's is over it
+	leaves := createTestLeaves(2, 0)
+	leaves[1].ExtraData = bytes.Repeat([]byte("large"), 20)
+	tx := beginLogTx(s, t)
+
+	if err := tx.QueueLeaves(leaves); err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+
+	if err := tx.UpdateSequencedLeaves(leaves); err != nil {
+		t.Fatalf("Failed to sequence leaves: %v", err)
+	}
+
+	commit(tx, t)
+
+	for i, wantBlob := range []bool{false, true} {
+		var extraData []byte
+		var blobKey sql.NullString
+
+		if err := db.QueryRow("SELECT ExtraData,ExtraDataBlobKey FROM LeafData WHERE TreeId=? AND LeafHash=?",
+			logID.logID.TreeID, []byte(leaves[i].LeafHash)).Scan(&extraData, &blobKey); err != nil {
+			t.Fatalf("Could not query leaf data: %v", err)
+		}
+
+		if got, want := blobKey.Valid, wantBlob; got != want {
+			t.Fatalf("leaf %d: stored blob key=%v, want %v", i, got, want)
+		}
+
+		if got, want := extraData != nil, !wantBlob; got != want {
+			t.Fatalf("leaf %d: stored inline ExtraData=%v, want %v", i, got, want)
+		}
+	}
+
+	// Both leaves should read back with their ExtraData whichever way it was stored
+	tx = beginLogTx(s, t)
+	got, err := tx.GetLeavesByIndex([]int64{0, 1})
+
+	if err != nil {
+		t.Fatalf("Failed to get leaves by index: %v", err)
+	}
+
+	commit(tx, t)
+
+	for _, leaf := range got {
+		if want := leaves[leaf.SequenceNumber].ExtraData; !bytes.Equal(leaf.ExtraData, want) {
+			t.Fatalf("leaf %d: got ExtraData %v, want %v", leaf.SequenceNumber, leaf.ExtraData, want)
+		}
+	}
+
+	// Without the blob store the large ExtraData can't be read
+	s2 := prepareTestLogStorage(logID, t)
+	tx = beginLogTx(s2, t)
+	defer tx.Rollback()
+
+	if _, err := tx.GetLeavesByIndex([]int64{1}); err == nil {
+		t.Fatal("Read leaf with blob ExtraData but no blob store")
+	}
+}
+
 func TestDequeueLeavesNoneQueued(t *testing.T) {
 	logID := createLogID("TestDequeueLeavesNoneQueued")
 	db := prepareTestLogDB(logID, t)
@@ -570,6 +960,67 @@ func TestGetLeavesByIndex(t *testing.T) {
 	checkLeafContents(leaves[0], sequenceNumber, dummyHash, data, t)
 }
 
+func TestGetLeavesByTimestamp(t *testing.T) {
+	logID := createLogID("TestGetLeavesByTimestamp")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+
+	signedTimestampBytes, err := EncodeSignedTimestamp(signedTimestamp)
+
+	if err != nil {
+		t.Fatalf("Failed to encode timestamp")
+	}
+
+	// Leaves 0-4 integrated at 1000, 1100, ... 1400
+	for l := int64(0); l < 5; l++ {
+		hash := []byte(fmt.Sprintf("hash %d", l))
+
+		_, err := db.Exec("INSERT INTO LeafData(TreeId, LeafHash, TheData) VALUES(?,?,?)", logID.logID.TreeID, hash, []byte(fmt.Sprintf("data %d", l)))
+		_, err2 := db.Exec("INSERT INTO SequencedLeafData(TreeId, SequenceNumber, LeafHash, SignedEntryTimestamp, IntegrateTimestampNanos) VALUES(?,?,?,?,?)",
+			logID.logID.TreeID, l, hash, signedTimestampBytes, 1000+l*100)
+
+		if err != nil || err2 != nil {
+			t.Fatalf("Failed to create test leaves: %v %v", err, err2)
+		}
+	}
+
+	s := prepareTestLogStorage(logID, t)
+	tx := beginLogTx(s, t)
+	defer tx.Commit()
+
+	var tests = []struct {
+		start, end, startIndex int64
+		limit                  int
+		want                   []int64
+	}{
+		{1000, 1500, 0, 10, []int64{0, 1, 2, 3, 4}},
+		{1100, 1300, 0, 10, []int64{1, 2}},
+		{1000, 1500, 0, 2, []int64{0, 1}},
+		{1000, 1500, 2, 2, []int64{2, 3}},
+		{1500, 2000, 0, 10, []int64{}},
+	}
+
+	for _, test := range tests {
+		leaves, err := tx.GetLeavesByTimestamp(test.start, test.end, test.startIndex, test.limit)
+
+		if err != nil {
+			t.Fatalf("Unexpected error getting leaves by timestamp: %v", err)
+		}
+
+		if got, want := len(leaves), len(test.want); got != want {
+			t.Fatalf("Got %d leaves for %v but expected %d", got, test, want)
+		}
+
+		for i, seq := range test.want {
+			checkLeafContents(leaves[i], seq, []byte(fmt.Sprintf("hash %d", seq)), []byte(fmt.Sprintf("data %d", seq)), t)
+
+			if got, want := leaves[i].IntegrateTimestampNanos, 1000+seq*100; got != want {
+				t.Fatalf("Got integrate timestamp %d for leaf %d but expected %d", got, seq, want)
+			}
+		}
+	}
+}
+
 func openTestDBOrDie() *sql.DB {
 	db, err := sql.Open("mysql", "test:zaphod@tcp(127.0.0.1:3306)/test")
 	if err != nil {
@@ -632,6 +1083,341 @@ func TestLatestSignedLogRoot(t *testing.T) {
 	}
 }
 
+func TestGetSignedLogRootAtRevision(t *testing.T) {
+	logID := createLogID("TestGetSignedLogRootAtRevision")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, t)
+	tx := beginLogTx(s, t)
+	defer tx.Rollback()
+
+	root5 := trillian.SignedLogRoot{LogId: logID.logID.LogID, TimestampNanos: 98765, TreeSize: 16, TreeRevision: 5, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
+	root6 := trillian.SignedLogRoot{LogId: logID.logID.LogID, TimestampNanos: 98766, TreeSize: 17, TreeRevision: 6, RootHash: []byte("otherhashotherhashotherhashother"), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}}
+
+	for _, root := range []trillian.SignedLogRoot{root5, root6} {
+		if err := tx.StoreSignedLogRoot(root); err != nil {
+			t.Fatalf("Failed to store signed root: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit new log roots: %v", err)
+	}
+
+	tx2 := beginLogTx(s, t)
+	defer tx2.Rollback()
+
+	for _, root := range []trillian.SignedLogRoot{root5, root6} {
+		got, err := tx2.GetSignedLogRootAtRevision(root.TreeRevision)
+
+		if err != nil {
+			t.Fatalf("Failed to read log root at revision %d: %v", root.TreeRevision, err)
+		}
+
+		if !proto.Equal(&root, &got) {
+			t.Fatalf("Root round trip failed: <%v> and: <%v>", root, got)
+		}
+	}
+
+	if _, err := tx2.GetSignedLogRootAtRevision(7); err == nil {
+		t.Fatal("Got log root for a revision that wasn't stored")
+	}
+}
+
+func TestCompactTreeRoundTrip(t *testing.T) {
+	logID := createLogID("TestCompactTreeRoundTrip")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, t)
+	tx := beginLogTx(s, t)
+	defer tx.Rollback()
+
+	// Nothing is stored for a new log
+	state, err := tx.LatestCompactTree()
+
+	if err != nil {
+		t.Fatalf("Failed to read compact tree: %v", err)
+	}
+
+	if got, want := state.TreeSize, int64(0); got != want {
+		t.Fatalf("Got compact tree of size %d for new log, expected %d", got, want)
+	}
+
+	// A later state replaces an earlier one
+	for _, state := range []storage.CompactTreeProto{
+		{TreeSize: 1, Nodes: [][]byte{dummyHash}},
+		{TreeSize: 5, Nodes: [][]byte{dummyHash, nil, []byte("otherhashotherhashotherhashother")}},
+	} {
+		if err := tx.StoreCompactTree(state); err != nil {
+			t.Fatalf("Failed to store compact tree: %v", err)
+		}
+	}
+
+	commit(tx, t)
+
+	tx2 := beginLogTx(s, t)
+	defer tx2.Rollback()
+
+	state, err = tx2.LatestCompactTree()
+
+	if err != nil {
+		t.Fatalf("Failed to read compact tree: %v", err)
+	}
+
+	want := storage.CompactTreeProto{TreeSize: 5, Nodes: [][]byte{dummyHash, nil, []byte("otherhashotherhashotherhashother")}}
+
+	if !proto.Equal(&state, &want) {
+		t.Fatalf("Compact tree round trip failed: <%v> and: <%v>", state, want)
+	}
+}
+
+func TestTreeMetadataRoundTrip(t *testing.T) {
+	logID := createLogID("TestTreeMetadataRoundTrip")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, t)
+	tx := beginLogTx(s, t)
+	defer tx.Rollback()
+
+	// A new tree has no metadata apart from when it was created
+	metadata, err := tx.GetTreeMetadata()
+
+	if err != nil {
+		t.Fatalf("Failed to read tree metadata: %v", err)
+	}
+
+	createTime := metadata.CreateTimeNanos
+
+	if createTime <= 0 || len(metadata.DisplayName) > 0 || len(metadata.Description) > 0 || len(metadata.OwnerContact) > 0 {
+		t.Fatalf("Got metadata %+v for new tree, expected only a creation time", metadata)
+	}
+
+	// The creation time can't be changed
+	want := trillian.TreeMetadata{DisplayName: "Test log", Description: "A log for testing", OwnerContact: "owner@example.com"}
+	set := want
+	set.CreateTimeNanos = 1
+
+	if err := tx.SetTreeMetadata(set); err != nil {
+		t.Fatalf("Failed to set tree metadata: %v", err)
+	}
+
+	// Setting the same metadata again changes no rows but isn't an error
+	if err := tx.SetTreeMetadata(set); err != nil {
+		t.Fatalf("Failed to set unchanged tree metadata: %v", err)
+	}
+
+	commit(tx, t)
+
+	tx2 := beginLogTx(s, t)
+	defer tx2.Rollback()
+
+	metadata, err = tx2.GetTreeMetadata()
+
+	if err != nil {
+		t.Fatalf("Failed to read tree metadata: %v", err)
+	}
+
+	want.CreateTimeNanos = createTime
+
+	if !proto.Equal(&metadata, &want) {
+		t.Fatalf("Tree metadata round trip failed: <%v> and: <%v>", metadata, want)
+	}
+}
+
+func TestSequencingEnabledRoundTrip(t *testing.T) {
+	logID := createLogID("TestSequencingEnabledRoundTrip")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, t)
+	tx := beginLogTx(s, t)
+	defer tx.Rollback()
+
+	controller, ok := tx.(storage.SequencingController)
+
+	if !ok {
+		t.Fatalf("Log tx doesn't implement SequencingController")
+	}
+
+	// A new tree has no TreeControl row and is sequenced
+	if enabled, err := controller.SequencingEnabled(); err != nil || !enabled {
+		t.Fatalf("Got sequencing enabled %v, %v for new tree, expected true", enabled, err)
+	}
+
+	// Pausing twice leaves it paused
+	for i := 0; i < 2; i++ {
+		if err := controller.SetSequencingEnabled(false); err != nil {
+			t.Fatalf("Failed to pause sequencing: %v", err)
+		}
+	}
+
+	commit(tx, t)
+
+	tx2 := beginLogTx(s, t)
+	defer tx2.Rollback()
+	controller = tx2.(storage.SequencingController)
+
+	if enabled, err := controller.SequencingEnabled(); err != nil || enabled {
+		t.Fatalf("Got sequencing enabled %v, %v after pausing, expected false", enabled, err)
+	}
+
+	if err := controller.SetSequencingEnabled(true); err != nil {
+		t.Fatalf("Failed to resume sequencing: %v", err)
+	}
+
+	if enabled, err := controller.SequencingEnabled(); err != nil || !enabled {
+		t.Fatalf("Got sequencing enabled %v, %v after resuming, expected true", enabled, err)
+	}
+}
+
+func TestCommitTokenRoundTrip(t *testing.T) {
+	logID := createLogID("TestCommitTokenRoundTrip")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, t)
+	tx := beginLogTx(s, t)
+	defer tx.Rollback()
+
+	tokenStore, ok := tx.(storage.CommitTokenStore)
+
+	if !ok {
+		t.Fatalf("Log tx doesn't implement CommitTokenStore")
+	}
+
+	if token, err := tokenStore.LatestCommitToken(); err != nil || token != nil {
+		t.Fatalf("Got commit token %x, %v for new tree, expected none", token, err)
+	}
+
+	for _, token := range [][]byte{[]byte("token1"), []byte("token2")} {
+		if err := tokenStore.StoreCommitToken(token); err != nil {
+			t.Fatalf("Failed to store commit token: %v", err)
+		}
+	}
+
+	commit(tx, t)
+
+	// A token stored by a transaction that's rolled back isn't seen
+	tx2 := beginLogTx(s, t)
+	if err := tx2.(storage.CommitTokenStore).StoreCommitToken([]byte("token3")); err != nil {
+		t.Fatalf("Failed to store commit token: %v", err)
+	}
+	tx2.Rollback()
+
+	tx3 := beginLogTx(s, t)
+	defer tx3.Rollback()
+
+	if token, err := tx3.(storage.CommitTokenStore).LatestCommitToken(); err != nil || !bytes.Equal(token, []byte("token2")) {
+		t.Fatalf("Got commit token %q, %v, expected token2", token, err)
+	}
+}
+
+func TestSequenceRangeReservation(t *testing.T) {
+	logID := createLogID("TestSequenceRangeReservation")
+	db := prepareTestLogDB(logID, t)
+	defer db.Close()
+	s := prepareTestLogStorage(logID, t)
+
+	// Leaves sequenced without a reservation aren't handed out again
+	tx := beginLogTx(s, t)
+	defer tx.Rollback()
+	leaves := createTestLeaves(3, 0)
+
+	if err := tx.QueueLeaves(leaves); err != nil {
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+
+	if err := tx.UpdateSequencedLeaves(leaves[:2]); err != nil {
+		t.Fatalf("Failed to sequence leaves: %v", err)
+	}
+
+	r1, err := tx.ReserveSequenceRange("seq1", 10)
+
+	if err != nil {
+		t.Fatalf("Failed to reserve range: %v", err)
+	}
+
+	r2, err := tx.ReserveSequenceRange("seq2", 5)
+
+	if err != nil {
+		t.Fatalf("Failed to reserve range: %v", err)
+	}
+
+	commit(tx, t)
+
+	if got, want := r1, (storage.SequenceRange{FirstSequenceNumber: 2, EndSequenceNumber: 12, SequencerID: "seq1", FencingToken: 1}); got != want {
+		t.Fatalf("Got first range %v, expected %v", got, want)
+	}
+
+	if got, want := r2, (storage.SequenceRange{FirstSequenceNumber: 12, EndSequenceNumber: 17, SequencerID: "seq2", FencingToken: 2}); got != want {
+		t.Fatalf("Got second range %v, expected %v", got, want)
+	}
+
+	// Once seq2 takes over r1 writes made by seq1 are fenced off
+	tx = beginLogTx(s, t)
+	defer tx.Rollback()
+	r1b, err := tx.TakeOverSequenceRange(r1, "seq2")
+
+	if err != nil {
+		t.Fatalf("Failed to take over range: %v", err)
+	}
+
+	if got, want := r1b.FencingToken, int64(3); got != want {
+		t.Fatalf("Got fencing token %d after take over, expected %d", got, want)
+	}
+
+	if _, err := tx.TakeOverSequenceRange(r1, "seq3"); err != storage.ErrFenced {
+		t.Fatalf("Got %v taking over a range with a stale token, expected ErrFenced", err)
+	}
+
+	leaves = leaves[2:]
+
+	if err := tx.UpdateSequencedLeavesInRange(r1, leaves); err != storage.ErrFenced {
+		t.Fatalf("Got %v sequencing with a stale token, expected ErrFenced", err)
+	}
+
+	if err := tx.UpdateSequencedLeavesInRange(r2, leaves); err == nil || err == storage.ErrFenced {
+		t.Fatalf("Got %v sequencing outside the range, expected an out of range error", err)
+	}
+
+	if err := tx.UpdateSequencedLeavesInRange(r1b, leaves); err != nil {
+		t.Fatalf("Failed to sequence leaves in range: %v", err)
+	}
+
+	if err := tx.ReleaseSequenceRange(r1); err != storage.ErrFenced {
+		t.Fatalf("Got %v releasing with a stale token, expected ErrFenced", err)
+	}
+
+	if err := tx.ReleaseSequenceRange(r1b); err != nil {
+		t.Fatalf("Failed to release range: %v", err)
+	}
+
+	commit(tx, t)
+
+	// Releasing a range doesn't make its sequence numbers or token available again
+	tx = beginLogTx(s, t)
+	defer tx.Rollback()
+	ranges, err := tx.GetSequenceRanges()
+
+	if err != nil {
+		t.Fatalf("Failed to get ranges: %v", err)
+	}
+
+	if got, want := ranges, []storage.SequenceRange{r2}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Got ranges %v, expected %v", got, want)
+	}
+
+	r3, err := tx.ReserveSequenceRange("seq1", 1)
+
+	if err != nil {
+		t.Fatalf("Failed to reserve range: %v", err)
+	}
+
+	if got, want := r3, (storage.SequenceRange{FirstSequenceNumber: 17, EndSequenceNumber: 18, SequencerID: "seq1", FencingToken: 4}); got != want {
+		t.Fatalf("Got range %v, expected %v", got, want)
+	}
+
+	commit(tx, t)
+}
+
 func TestGetTreeRevisionAtNonExistentSizeError(t *testing.T) {
 	// Have to set all this up though we won't actually write anything
 	logID := createLogID("TestGetTreeRevisionAtSize")
@@ -862,6 +1648,49 @@ func TestLatestSignedMapRoot(t *testing.T) {
 	}
 }
 
+func TestGetSignedMapRootAtRevision(t *testing.T) {
+	mapID := createMapID("TestGetSignedMapRootAtRevision")
+	db := prepareTestMapDB(mapID, t)
+	defer db.Close()
+	s := prepareTestMapStorage(mapID, t)
+	tx := beginMapTx(s, t)
+	defer tx.Rollback()
+
+	roots := []trillian.SignedMapRoot{
+		{MapId: mapID.mapID.MapID, TimestampNanos: 98765, MapRevision: 5, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty")}},
+		{MapId: mapID.mapID.MapID, TimestampNanos: 98766, MapRevision: 6, RootHash: []byte(dummyHash), Signature: &trillian.DigitallySigned{Signature: []byte("notempty2")}},
+	}
+
+	for _, root := range roots {
+		if err := tx.StoreSignedMapRoot(root); err != nil {
+			t.Fatalf("Failed to store signed root: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit new map roots: %v", err)
+	}
+
+	tx2 := beginMapTx(s, t)
+	defer tx2.Rollback()
+
+	for _, root := range roots {
+		root2, err := tx2.GetSignedMapRootAtRevision(root.MapRevision)
+
+		if err != nil {
+			t.Fatalf("Failed to read back map root at revision %d: %v", root.MapRevision, err)
+		}
+
+		if !proto.Equal(&root, &root2) {
+			t.Fatalf("Root round trip failed: <%#v> and: <%#v>", root, root2)
+		}
+	}
+
+	if _, err := tx2.GetSignedMapRootAtRevision(7); err == nil {
+		t.Fatal("Read map root at a revision that was never stored")
+	}
+}
+
 func TestDuplicateSignedMapRoot(t *testing.T) {
 	mapID := createMapID("TestDuplicateSignedMapRoot")
 	db := prepareTestMapDB(mapID, t)
@@ -1071,6 +1900,60 @@ func TestMapSetGetMultipleRevisions(t *testing.T) {
 	}
 }
 
+func TestMapGetHistory(t *testing.T) {
+	mapID := createMapID("TestMapGetHistory")
+	db := prepareTestMapDB(mapID, t)
+	defer db.Close()
+	s := prepareTestMapStorage(mapID, t)
+
+	keyHash := []byte("A Key Hash")
+	values := make(map[int64]trillian.MapLeaf)
+
+	// Another key is set in between to check its values are left out
+	for _, rev := range []int64{1, 3, 4, 7} {
+		tx := beginMapTx(s, t)
+		tx.(*mapTX).treeTX.writeRevision = rev
+		values[rev] = trillian.MapLeaf{LeafValue: []byte(fmt.Sprintf("A Value %d", rev))}
+		if err := tx.Set(keyHash, values[rev]); err != nil {
+			t.Fatalf("Failed to set %v to %v: %v", keyHash, values[rev], err)
+		}
+		if err := tx.Set([]byte("Another Key Hash"), values[rev]); err != nil {
+			t.Fatalf("Failed to set other key: %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+	}
+
+	tx := beginMapTx(s, t)
+	defer tx.Commit()
+
+	history, err := tx.GetHistory(5, keyHash)
+	if err != nil {
+		t.Fatalf("Failed to get history of %v: %v", keyHash, err)
+	}
+
+	if got, want := len(history), 3; got != want {
+		t.Fatalf("Got %d values up to revision 5, expected %d", got, want)
+	}
+	for i, rev := range []int64{1, 3, 4} {
+		if got, want := history[i].Revision, rev; got != want {
+			t.Fatalf("Got value %d at revision %d, expected %d", i, got, want)
+		}
+		if got, want := history[i].Leaf, values[rev]; !proto.Equal(&got, &want) {
+			t.Fatalf("Read back %v at revision %d, but expected %v", got, rev, want)
+		}
+	}
+
+	history, err = tx.GetHistory(5, []byte("Unknown Key Hash"))
+	if err != nil {
+		t.Fatalf("Failed to get history of unknown key: %v", err)
+	}
+	if len(history) != 0 {
+		t.Fatalf("Got history %v for unknown key, expected none", history)
+	}
+}
+
 func TestGetActiveLogIDs(t *testing.T) {
 	// Have to wipe everything to ensure we start with zero log trees configured
 	cleanTestDB()
@@ -1140,6 +2023,26 @@ func TestGetActiveLogIDsWithPendingWork(t *testing.T) {
 	}
 }
 
+// ---- Storage conformance tests below:
+
+func TestLogStorageConformance(t *testing.T) {
+	testsuite.RunLogStorageTests(t, func(t *testing.T) storage.LogStorage {
+		logID := createLogID("TestLogStorageConformance")
+		db := prepareTestLogDB(logID, t)
+		db.Close()
+		return prepareTestLogStorage(logID, t)
+	})
+}
+
+func TestMapStorageConformance(t *testing.T) {
+	testsuite.RunMapStorageTests(t, func(t *testing.T) storage.MapStorage {
+		mapID := createMapID("TestMapStorageConformance")
+		db := prepareTestMapDB(mapID, t)
+		db.Close()
+		return prepareTestMapStorage(mapID, t)
+	})
+}
+
 func ensureAllLeafHashesDistinct(leaves []trillian.LogLeaf, t *testing.T) {
 	// All the hashes should be distinct. If only we had maps with slices as keys or sets
 	// or pretty much any kind of usable data structures we could do this properly.
@@ -1162,7 +2065,7 @@ func createTestDB() {
 	}
 }
 
-func prepareTestLogStorage(logID logIDAndTest, t *testing.T) storage.LogStorage {
+func prepareTestLogStorage(logID logIDAndTest, t testing.TB) storage.LogStorage {
 	s, err := NewLogStorage(logID.logID, "test:zaphod@tcp(127.0.0.1:3306)/test")
 	if err != nil {
 		t.Fatalf("Failed to open log storage: %s", err)
@@ -1184,7 +2087,7 @@ func prepareTestMapStorage(mapID mapIDAndTest, t *testing.T) storage.MapStorage
 // predictable environment. For obvious reasons this should only be allowed to run
 // against test databases. This method panics if any of the deletions fails to make
 // sure tests can't inadvertently succeed.
-func prepareTestTreeDB(treeID int64, t *testing.T) *sql.DB {
+func prepareTestTreeDB(treeID int64, t testing.TB) *sql.DB {
 	db := openTestDBOrDie()
 
 	// Wipe out anything that was there for this tree id
@@ -1202,7 +2105,7 @@ func prepareTestTreeDB(treeID int64, t *testing.T) *sql.DB {
 // predictable environment. For obvious reasons this should only be allowed to run
 // against test databases. This method panics if any of the deletions fails to make
 // sure tests can't inadvertently succeed.
-func prepareTestLogDB(logID logIDAndTest, t *testing.T) *sql.DB {
+func prepareTestLogDB(logID logIDAndTest, t testing.TB) *sql.DB {
 	db := prepareTestTreeDB(logID.logID.TreeID, t)
 
 	// Now put back the tree row for this log id
@@ -1257,7 +2160,7 @@ func createTestLeaves(n, startSeq int64) []trillian.LogLeaf {
 	for l := int64(0); l < n; l++ {
 		lv := fmt.Sprintf("Leaf %d", l)
 		leaf := trillian.LogLeaf{trillian.Leaf{
-			hasher.Digest([]byte(lv)), []byte(lv), []byte(fmt.Sprintf("Extra %d", l))}, signedTimestamp, int64(startSeq + l)}
+			hasher.Digest([]byte(lv)), []byte(lv), []byte(fmt.Sprintf("Extra %d", l))}, signedTimestamp, int64(startSeq + l), 0, 0}
 		leaves = append(leaves, leaf)
 	}
 
@@ -1265,13 +2168,13 @@ func createTestLeaves(n, startSeq int64) []trillian.LogLeaf {
 }
 
 // Convenience methods to avoid copying out "if err != nil { blah }" all over the place
-func commit(tx storage.LogTX, t *testing.T) {
+func commit(tx storage.LogTX, t testing.TB) {
 	if err := tx.Commit(); err != nil {
 		t.Fatalf("Failed to commit tx: %v", err)
 	}
 }
 
-func beginLogTx(s storage.LogStorage, t *testing.T) storage.LogTX {
+func beginLogTx(s storage.LogStorage, t testing.TB) storage.LogTX {
 	tx, err := s.Begin()
 
 	if err != nil {
@@ -10,14 +10,15 @@ import (
 	"github.com/golang/glog"
 	"github.com/golang/protobuf/proto"
 	"github.com/google/trillian"
+	terrors "github.com/google/trillian/errors"
 	"github.com/google/trillian/storage"
 	"github.com/google/trillian/storage/cache"
 )
 
 // These statements are fixed
 const insertSubtreeMultiSql string = `INSERT INTO Subtree(TreeId, SubtreeId, Nodes, SubtreeRevision) ` + placeholderSql
-const insertTreeHeadSql string = `INSERT INTO TreeHead(TreeId,TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature)
-		 VALUES(?,?,?,?,?,?)`
+const insertTreeHeadSql string = `INSERT INTO TreeHead(TreeId,TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature,RootMetadata)
+		 VALUES(?,?,?,?,?,?,?)`
 const selectTreeRevisionAtSizeSql string = "SELECT TreeRevision FROM TreeHead WHERE TreeId=? AND TreeSize=? ORDER BY TreeRevision DESC LIMIT 1"
 const selectActiveLogsSql string = "select TreeId, KeyId from Trees where TreeType='LOG'"
 const selectActiveLogsWithUnsequencedSql string = "SELECT DISTINCT t.TreeId, t.KeyId from Trees t INNER JOIN Unsequenced u WHERE TreeType='LOG' AND t.TreeId=u.TreeId"
@@ -39,7 +40,7 @@ const placeholderSql string = "<placeholder>"
 // Storage implementations, and contains functionality which is common to both,
 type mySQLTreeStorage struct {
 	treeID          int64
-	db              *sql.DB
+	db              *failoverDB
 	hashSizeBytes   int
 	populateSubtree storage.PopulateSubtreeFunc
 
@@ -49,26 +50,24 @@ type mySQLTreeStorage struct {
 	// in the query to the statement that should be used.
 	statementMutex sync.Mutex
 	statements     map[string]map[int]*sql.Stmt
+	// statementGeneration is the failover generation of the database the statements were
+	// prepared on, they're dropped when it changes
+	statementGeneration int64
 }
 
+// openDB opens the first writable database in dbURL, which can be a comma separated list of
+// DSNs. Unlike tree storage, the connection doesn't fail over if the database goes away.
 func openDB(dbURL string) (*sql.DB, error) {
-	db, err := sql.Open("mysql", dbURL)
+	f, err := openFailoverDB(dbURL)
 	if err != nil {
-		// Don't log uri as it could contain credentials
-		glog.Warningf("Could not open MySQL database, check config: %s", err)
 		return nil, err
 	}
 
-	if _, err := db.Exec("SET sql_mode = 'STRICT_ALL_TABLES'"); err != nil {
-		glog.Warningf("Failed to set strict mode on mysql db: %s", err)
-		return nil, err
-	}
-
-	return db, nil
+	return f.db, nil
 }
 
 func newTreeStorage(treeID int64, dbURL string, hashSizeBytes int, populateSubtree storage.PopulateSubtreeFunc) (mySQLTreeStorage, error) {
-	db, err := openDB(dbURL)
+	db, err := openFailoverDB(dbURL)
 	if err != nil {
 		return mySQLTreeStorage{}, err
 	}
@@ -144,6 +143,11 @@ func encodeNodeID(n storage.NodeID) ([]byte, error) {
 	return marshalledBytes, nil
 }
 
+// Close closes the database, which also closes the prepared statements.
+func (m *mySQLTreeStorage) Close() error {
+	return m.db.Close()
+}
+
 // getStmt creates and caches sql.Stmt structs based on the passed in statement
 // and number of bound arguments.
 // TODO(al,martin): consider pulling this all out as a separate unit for reuse
@@ -152,6 +156,14 @@ func (m *mySQLTreeStorage) getStmt(statement string, num int, first, rest string
 	m.statementMutex.Lock()
 	defer m.statementMutex.Unlock()
 
+	// Statements prepared on a database that has been failed over from can't be used
+	db, generation := m.db.get()
+
+	if generation != m.statementGeneration {
+		m.statements = make(map[string]map[int]*sql.Stmt)
+		m.statementGeneration = generation
+	}
+
 	if m.statements[statement] != nil {
 		if m.statements[statement][num] != nil {
 			// TODO(al,martin): we'll possibly need to expire Stmts from the cache,
@@ -162,7 +174,7 @@ func (m *mySQLTreeStorage) getStmt(statement string, num int, first, rest string
 		m.statements[statement] = make(map[int]*sql.Stmt)
 	}
 
-	s, err := m.db.Prepare(expandPlaceholderSql(statement, num, first, rest))
+	s, err := db.Prepare(expandPlaceholderSql(statement, num, first, rest))
 
 	if err != nil {
 		glog.Warningf("Failed to prepare statement %d: %s", num, err)
@@ -183,7 +195,7 @@ func (m *mySQLTreeStorage) setSubtreeStmt(num int) (*sql.Stmt, error) {
 }
 
 func (m *mySQLTreeStorage) beginTreeTx() (treeTX, error) {
-	t, err := m.db.Begin()
+	t, generation, err := m.db.begin()
 	if err != nil {
 		glog.Warningf("Could not start tree TX: %s", err)
 		return treeTX{}, err
@@ -193,6 +205,7 @@ func (m *mySQLTreeStorage) beginTreeTx() (treeTX, error) {
 		ts:            m,
 		subtreeCache:  cache.NewSubtreeCache(m.populateSubtree),
 		writeRevision: -1,
+		generation:    generation,
 	}, nil
 }
 
@@ -202,6 +215,9 @@ type treeTX struct {
 	ts            *mySQLTreeStorage
 	subtreeCache  cache.SubtreeCache
 	writeRevision int64
+	// generation is the failover generation of the database the transaction is on. It's
+	// rolled back rather than committed if the storage has failed over since.
+	generation int64
 }
 
 func (t *treeTX) getSubtree(treeRevision int64, nodeID storage.NodeID) (*storage.SubtreeProto, error) {
@@ -261,6 +277,107 @@ func (t *treeTX) getSubtree(treeRevision int64, nodeID storage.NodeID) (*storage
 	return &subtree, nil
 }
 
+// getSubtrees fetches the subtrees with the given IDs, each at the latest revision no later
+// than treeRevision, with one query. The results are keyed by subtree prefix and subtrees
+// that aren't stored are missing.
+func (t *treeTX) getSubtrees(treeRevision int64, nodeIDs []storage.NodeID) (map[string]*storage.SubtreeProto, error) {
+	subtrees := make(map[string]*storage.SubtreeProto)
+
+	if len(nodeIDs) == 0 {
+		return subtrees, nil
+	}
+
+	tmpl, err := t.ts.getSubtreeStmt(len(nodeIDs))
+	if err != nil {
+		return nil, err
+	}
+	stx := t.tx.Stmt(tmpl)
+	defer stx.Close()
+
+	args := make([]interface{}, 0, len(nodeIDs)+3)
+	for _, nodeID := range nodeIDs {
+		if nodeID.PrefixLenBits%8 != 0 {
+			return nil, fmt.Errorf("invalid subtree ID - not multiple of 8: %d", nodeID.PrefixLenBits)
+		}
+		args = append(args, interface{}(nodeID.Path[:nodeID.PrefixLenBits/8]))
+	}
+	args = append(args, interface{}(t.ts.treeID))
+	args = append(args, interface{}(treeRevision))
+	args = append(args, interface{}(t.ts.treeID))
+
+	rows, err := stx.Query(args...)
+	if err != nil {
+		glog.Warningf("Failed to get merkle subtrees: %s", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var subtreeIDBytes []byte
+		var subtreeRev int64
+		var nodesRaw []byte
+		if err := rows.Scan(&subtreeIDBytes, &subtreeRev, &nodesRaw); err != nil {
+			glog.Warningf("Failed to scan merkle subtree: %s", err)
+			return nil, err
+		}
+		var subtree storage.SubtreeProto
+		if err := proto.Unmarshal(nodesRaw, &subtree); err != nil {
+			return nil, err
+		}
+		if subtree.Prefix == nil {
+			subtree.Prefix = []byte{}
+		}
+		subtrees[string(subtreeIDBytes)] = &subtree
+	}
+
+	return subtrees, rows.Err()
+}
+
+// getMerkleNodesInOneQuery returns the same nodes as GetMerkleNodes but reads all the
+// subtrees that hold them with a single query, rather than a query for each subtree that
+// isn't already cached.
+func (t *treeTX) getMerkleNodesInOneQuery(treeRevision int64, nodeIDs []storage.NodeID) ([]storage.Node, error) {
+	subtreeIDs := make([]storage.NodeID, 0, len(nodeIDs))
+	seen := make(map[string]bool)
+
+	for _, nodeID := range nodeIDs {
+		subtreeID := cache.SubtreeID(nodeID)
+		prefix := string(subtreeID.Path[:subtreeID.PrefixLenBits/8])
+
+		if !seen[prefix] {
+			seen[prefix] = true
+			subtreeIDs = append(subtreeIDs, subtreeID)
+		}
+	}
+
+	subtrees, err := t.getSubtrees(treeRevision, subtreeIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]storage.Node, 0, len(nodeIDs))
+
+	// The cache populates the internal nodes of the subtrees as it takes them
+	for _, nodeID := range nodeIDs {
+		h, err := t.subtreeCache.GetNodeHash(
+			nodeID,
+			func(n storage.NodeID) (*storage.SubtreeProto, error) {
+				return subtrees[string(n.Path[:n.PrefixLenBits/8])], nil
+			})
+		if err != nil {
+			return nil, err
+		}
+		if h != nil {
+			ret = append(ret, storage.Node{
+				NodeID: nodeID,
+				Hash:   h,
+			})
+		}
+	}
+
+	return ret, nil
+}
+
 func (t *treeTX) storeSubtrees(subtrees []*storage.SubtreeProto) error {
 	if len(subtrees) == 0 {
 		glog.Warning("attempted to store 0 subtrees...")
@@ -331,12 +448,16 @@ func checkResultOkAndRowCountIs(res sql.Result, err error, count int64) error {
 func (t *treeTX) GetTreeRevisionAtSize(treeSize int64) (int64, error) {
 	// Negative size is not sensible and a zero sized tree has no nodes so no revisions
 	if treeSize <= 0 {
-		return 0, fmt.Errorf("Invalid tree size: %d", treeSize)
+		return 0, terrors.Errorf(terrors.InvalidRange, "Invalid tree size: %d", treeSize)
 	}
 
 	var treeRevision int64
 	err := t.tx.QueryRow(selectTreeRevisionAtSizeSql, t.ts.treeID, treeSize).Scan(&treeRevision)
 
+	if err == sql.ErrNoRows {
+		return 0, terrors.Errorf(terrors.NotFound, "no tree head stored at size: %d", treeSize)
+	}
+
 	return treeRevision, err
 }
 
@@ -381,7 +502,7 @@ func (t *treeTX) Commit() error {
 		t.subtreeCache.Flush(t.storeSubtrees)
 	}
 	t.closed = true
-	err := t.tx.Commit()
+	err := t.ts.db.commit(t.tx, t.generation)
 
 	if err != nil {
 		glog.Warningf("TX commit error: %$s", err)
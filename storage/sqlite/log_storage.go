@@ -0,0 +1,1032 @@
+package sqlite
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	terrors "github.com/google/trillian/errors"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
+)
+
+const getTreePropertiesSql string = "SELECT AllowsDuplicateLeaves,LeafHashStrategy,LeafHasherType,TreeHasherType,TreeDepth FROM Trees WHERE TreeId=?"
+const selectTreeMetadataSql string = `SELECT DisplayName,Description,OwnerContact,CAST(strftime('%s',CreateTime) AS INTEGER)
+		 FROM Trees WHERE TreeId=?`
+const updateTreeMetadataSql string = "UPDATE Trees SET DisplayName=?,Description=?,OwnerContact=? WHERE TreeId=?"
+const getTreeParametersSql string = "SELECT ReadOnlyRequests From TreeControl WHERE TreeID=?"
+const selectSequencingEnabledSql string = "SELECT SequencingEnabled FROM TreeControl WHERE TreeId=?"
+const insertTreeControlSql string = "INSERT OR IGNORE INTO TreeControl(TreeId,ReadOnlyRequests) VALUES(?,0)"
+const updateSequencingEnabledSql string = "UPDATE TreeControl SET SequencingEnabled=? WHERE TreeId=?"
+const selectQueuedLeavesSql string = `SELECT LeafHash,Payload,SignedEntryTimestamp,Priority
+		 FROM Unsequenced
+		 WHERE TreeID=?
+		 ORDER BY Priority DESC,QueueTimestamp DESC LIMIT ?`
+const selectLeafDataExistsSql string = "SELECT 1 FROM LeafData WHERE TreeId=? AND LeafHash=?"
+const insertUnsequencedLeafSql string = `INSERT INTO LeafData(TreeId,LeafHash,TheData,ExtraData)
+		 VALUES(?,?,?,?)`
+const insertUnsequencedEntrySql string = `INSERT INTO Unsequenced(TreeId,LeafHash,MessageId,SignedEntryTimestamp,Payload,Priority)
+		 VALUES(?,?,?,?,?,?)`
+const insertSequencedLeafSql string = `INSERT INTO SequencedLeafData(TreeId,LeafHash,SequenceNumber,SignedEntryTimestamp,IntegrateTimestampNanos)
+		 VALUES(?,?,?,?,?)`
+const selectSequencedLeafCountSql string = "SELECT COUNT(*) FROM SequencedLeafData WHERE TreeId=?"
+const selectLatestSignedLogRootSql string = `SELECT TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature,RootMetadata
+		 FROM TreeHead WHERE TreeId=?
+		 ORDER BY TreeHeadTimestamp DESC LIMIT 1`
+const selectSignedLogRootAtRevisionSql string = `SELECT TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature,RootMetadata
+		 FROM TreeHead WHERE TreeId=? AND TreeRevision=?`
+const selectCompactTreeSql string = "SELECT State FROM CompactTree WHERE TreeId=?"
+const replaceCompactTreeSql string = "REPLACE INTO CompactTree(TreeId,TreeSize,State) VALUES(?,?,?)"
+const selectCommitTokenSql string = "SELECT Token FROM CommitToken WHERE TreeId=?"
+const replaceCommitTokenSql string = "REPLACE INTO CommitToken(TreeId,Token) VALUES(?,?)"
+const initSequenceRangeCounterSql string = `INSERT OR IGNORE INTO SequenceRangeCounter(TreeId,NextSequenceNumber,NextFencingToken)
+		 VALUES(?,0,1)`
+const selectSequenceRangeCounterSql string = `SELECT NextSequenceNumber,NextFencingToken FROM SequenceRangeCounter
+		 WHERE TreeId=?`
+const updateSequenceRangeCounterSql string = `UPDATE SequenceRangeCounter SET NextSequenceNumber=?,NextFencingToken=?
+		 WHERE TreeId=?`
+const selectNextUnusedSequenceNumberSql string = "SELECT COALESCE(MAX(SequenceNumber)+1,0) FROM SequencedLeafData WHERE TreeId=?"
+const insertSequenceRangeSql string = `INSERT INTO SequenceRange(TreeId,FirstSequenceNumber,EndSequenceNumber,SequencerId,FencingToken)
+		 VALUES(?,?,?,?,?)`
+const selectSequenceRangeTokenSql string = `SELECT FencingToken FROM SequenceRange
+		 WHERE TreeId=? AND FirstSequenceNumber=?`
+const updateSequenceRangeSql string = `UPDATE SequenceRange SET SequencerId=?,FencingToken=?
+		 WHERE TreeId=? AND FirstSequenceNumber=? AND FencingToken=?`
+const deleteSequenceRangeSql string = "DELETE FROM SequenceRange WHERE TreeId=? AND FirstSequenceNumber=? AND FencingToken=?"
+const selectSequenceRangesSql string = `SELECT FirstSequenceNumber,EndSequenceNumber,SequencerId,FencingToken
+		 FROM SequenceRange WHERE TreeId=? ORDER BY FirstSequenceNumber`
+
+// These statements need to be expanded to provide the correct number of parameter placeholders
+// for a particular case
+const deleteUnsequencedSql string = "DELETE FROM Unsequenced WHERE LeafHash IN (<placeholder>) AND TreeId = ?"
+const selectLeavesByIndexSql string = `SELECT l.LeafHash,l.TheData,l.ExtraData,s.SequenceNumber,s.SignedEntryTimestamp,s.IntegrateTimestampNanos
+		     FROM LeafData l,SequencedLeafData s
+		     WHERE l.LeafHash = s.LeafHash
+		     AND s.SequenceNumber IN (` + placeholderSql + `) AND l.TreeId = ? AND s.TreeId = l.TreeId`
+
+// ExtraData is selected as NULL so the leaves can be scanned in the same way, SQLite doesn't
+// read overflow pages that only hold columns which aren't used
+const selectLeavesByIndexWithoutExtraDataSql string = `SELECT l.LeafHash,l.TheData,NULL,s.SequenceNumber,s.SignedEntryTimestamp,s.IntegrateTimestampNanos
+		     FROM LeafData l,SequencedLeafData s
+		     WHERE l.LeafHash = s.LeafHash
+		     AND s.SequenceNumber IN (` + placeholderSql + `) AND l.TreeId = ? AND s.TreeId = l.TreeId`
+
+const selectLeavesByHashSql string = `SELECT l.LeafHash,l.TheData,l.ExtraData,s.SequenceNumber,s.SignedEntryTimestamp,s.IntegrateTimestampNanos
+		     FROM LeafData l,SequencedLeafData s
+		     WHERE l.LeafHash = s.LeafHash
+		     AND l.LeafHash IN (` + placeholderSql + `) AND l.TreeId = ? AND s.TreeId = l.TreeId`
+
+// Same as above except with leaves ordered by sequence so we only incur this cost when necessary
+const selectLeavesByHashOrderedBySequenceSQL string = selectLeavesByHashSql + " ORDER BY s.SequenceNumber"
+
+// This uses the IntegrateTimestampIdx index
+const selectLeavesByTimestampSql string = `SELECT l.LeafHash,l.TheData,l.ExtraData,s.SequenceNumber,s.SignedEntryTimestamp,s.IntegrateTimestampNanos
+		     FROM LeafData l,SequencedLeafData s
+		     WHERE l.LeafHash = s.LeafHash
+		     AND s.TreeId = ? AND l.TreeId = s.TreeId
+		     AND s.IntegrateTimestampNanos >= ? AND s.IntegrateTimestampNanos < ?
+		     AND s.SequenceNumber >= ?
+		     ORDER BY s.SequenceNumber LIMIT ?`
+
+type sqliteLogStorage struct {
+	sqliteTreeStorage
+
+	logID            trillian.LogID
+	allowDuplicates  bool
+	readOnly         bool
+	leafHashStrategy trillian.LeafHashStrategy
+	hashAlgorithm    trillian.HashAlgorithm
+	treeDepth        int
+}
+
+// leafHashStrategies maps the values of the LeafHashStrategy column to the API enum
+var leafHashStrategies = map[string]trillian.LeafHashStrategy{
+	"RFC6962": trillian.LeafHashStrategy_RFC6962_LEAF_HASH,
+	"RAW":     trillian.LeafHashStrategy_RAW_LEAF_HASH,
+}
+
+// hashAlgorithms maps the values of the LeafHasherType and TreeHasherType columns to the API
+// enum
+var hashAlgorithms = map[string]trillian.HashAlgorithm{
+	"SHA256":     trillian.HashAlgorithm_SHA256,
+	"SHA512_256": trillian.HashAlgorithm_SHA512_256,
+	"SHA3_256":   trillian.HashAlgorithm_SHA3_256,
+}
+
+// NewLogStorage creates storage for a log in the SQLite database at dbPath, which is created
+// if it doesn't exist. Transactions wait up to busyTimeout for other transactions using the
+// database to finish.
+func NewLogStorage(id trillian.LogID, dbPath string, busyTimeout time.Duration) (storage.LogStorage, error) {
+	// The tree's hasher isn't known until the tree properties have been read, the hash size
+	// and subtree population are set up once it is
+	th := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+	ts, err := newTreeStorage(id.TreeID, dbPath, busyTimeout, th.Size(), cache.PopulateLogSubtreeNodes(th))
+	if err != nil {
+		glog.Warningf("Couldn't create a new treeStorage: %s", err)
+		return nil, err
+	}
+
+	s := sqliteLogStorage{
+		sqliteTreeStorage: ts,
+		logID:             id,
+	}
+
+	if err := s.readTreeProperties(); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func (m *sqliteLogStorage) readTreeProperties() error {
+	// TODO: This should not default but it would currently complicate testing and can be
+	// implemented later when the create tree API has been defined.
+	var strategy, leafHasher, treeHasher string
+	if err := m.db.QueryRow(getTreePropertiesSql, m.logID.TreeID).Scan(&m.allowDuplicates, &strategy, &leafHasher, &treeHasher, &m.treeDepth); err == sql.ErrNoRows {
+		m.allowDuplicates = false
+		m.leafHashStrategy = trillian.LeafHashStrategy_RFC6962_LEAF_HASH
+		m.hashAlgorithm = trillian.HashAlgorithm_SHA256
+		m.treeDepth = storage.DefaultLogTreeDepth
+	} else if err != nil {
+		glog.Warningf("Failed to get trees row for id %v: %s", m.logID, err)
+		return err
+	} else {
+		var ok bool
+		if m.leafHashStrategy, ok = leafHashStrategies[strategy]; !ok {
+			return fmt.Errorf("unknown leaf hash strategy for log %v: %s", m.logID, strategy)
+		}
+
+		if m.hashAlgorithm, ok = hashAlgorithms[treeHasher]; !ok {
+			return fmt.Errorf("unknown tree hasher type for log %v: %s", m.logID, treeHasher)
+		}
+
+		// Leaves and nodes are hashed by the same TreeHasher
+		if leafHasher != treeHasher {
+			return fmt.Errorf("log %v has leaf hasher type %s but tree hasher type %s, they must be the same", m.logID, leafHasher, treeHasher)
+		}
+
+		if err := storage.ValidateLogTreeDepth(m.treeDepth); err != nil {
+			return fmt.Errorf("log %v: %v", m.logID, err)
+		}
+	}
+
+	// Subtrees must be populated with the same hasher that the sequencer uses for this tree
+	th, err := merkle.NewTreeHasherForAlgorithm(m.hashAlgorithm, m.leafHashStrategy)
+
+	if err != nil {
+		return err
+	}
+
+	m.hashSizeBytes = th.Size()
+	m.populateSubtree = cache.PopulateLogSubtreeNodes(th)
+
+	if err := m.db.QueryRow(getTreeParametersSql, m.logID.TreeID).Scan(&m.readOnly); err == sql.ErrNoRows {
+		glog.Warningf("*** Opening storage for log: %v but it has no params configured ***", m.logID)
+	}
+
+	return nil
+}
+
+func (m *sqliteLogStorage) LeafHashStrategy() trillian.LeafHashStrategy {
+	return m.leafHashStrategy
+}
+
+func (m *sqliteLogStorage) HashAlgorithm() trillian.HashAlgorithm {
+	return m.hashAlgorithm
+}
+
+func (m *sqliteLogStorage) TreeDepth() int {
+	return m.treeDepth
+}
+
+func (m *sqliteLogStorage) getLeavesByIndexStmt(num int) (*sql.Stmt, error) {
+	return m.getStmt(selectLeavesByIndexSql, num, "?", "?")
+}
+
+func (m *sqliteLogStorage) getLeavesByIndexWithoutExtraDataStmt(num int) (*sql.Stmt, error) {
+	return m.getStmt(selectLeavesByIndexWithoutExtraDataSql, num, "?", "?")
+}
+
+func (m *sqliteLogStorage) getLeavesByHashStmt(num int, orderBySequence bool) (*sql.Stmt, error) {
+	if orderBySequence {
+		return m.getStmt(selectLeavesByHashOrderedBySequenceSQL, num, "?", "?")
+	}
+
+	return m.getStmt(selectLeavesByHashSql, num, "?", "?")
+}
+
+func (m *sqliteLogStorage) getDeleteUnsequencedStmt(num int) (*sql.Stmt, error) {
+	return m.getStmt(deleteUnsequencedSql, num, "?", "?")
+}
+
+func (m *sqliteLogStorage) beginInternal() (storage.LogTX, error) {
+	ttx, err := m.beginTreeTx()
+	if err != nil {
+		return nil, err
+	}
+	ret := &logTX{
+		treeTX: ttx,
+		ls:     m,
+	}
+
+	root, err := ret.LatestSignedLogRoot()
+	if err != nil {
+		ttx.Rollback()
+		return nil, err
+	}
+
+	ret.treeTX.writeRevision = root.TreeRevision + 1
+
+	return ret, nil
+}
+
+func (m *sqliteLogStorage) Begin() (storage.LogTX, error) {
+	// Reject attempts to start a writable transaction in read only mode. Anything that
+	// doesn't write is a part of Snapshot so is still available via that API.
+	if m.readOnly {
+		return nil, storage.ErrReadOnly
+	}
+
+	return m.beginInternal()
+}
+
+func (m *sqliteLogStorage) Snapshot() (storage.ReadOnlyLogTX, error) {
+	tx, err := m.beginInternal()
+	if err != nil {
+		return nil, err
+	}
+	return tx.(storage.ReadOnlyLogTX), err
+}
+
+type logTX struct {
+	treeTX
+	ls *sqliteLogStorage
+}
+
+// GetInclusionProofNodes implements storage.ProofReader. All the subtrees the proof passes
+// through are read with one query.
+func (t *logTX) GetInclusionProofNodes(treeRevision, treeSize, leafIndex int64) ([]storage.Node, error) {
+	nodeIDs, err := merkle.CalcInclusionProofNodeAddresses(treeSize, leafIndex, t.ls.treeDepth)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return t.getMerkleNodesInOneQuery(treeRevision, nodeIDs)
+}
+
+// GetConsistencyProofNodes implements storage.ProofReader. All the subtrees the proof passes
+// through are read with one query.
+func (t *logTX) GetConsistencyProofNodes(treeRevision, previousTreeSize, treeSize int64) ([]storage.Node, error) {
+	nodeIDs, err := merkle.CalcConsistencyProofNodeAddresses(previousTreeSize, treeSize, t.ls.treeDepth)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return t.getMerkleNodesInOneQuery(treeRevision, nodeIDs)
+}
+
+func (t *logTX) WriteRevision() int64 {
+	return t.treeTX.writeRevision
+}
+
+func (t *logTX) DequeueLeaves(limit int) ([]trillian.LogLeaf, error) {
+	rows, err := t.tx.Query(selectQueuedLeavesSql, t.ls.logID.TreeID, limit)
+
+	if err != nil {
+		glog.Warningf("Failed to select rows for work: %s", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	leaves := make([]trillian.LogLeaf, 0, limit)
+
+	for rows.Next() {
+		var leafHash []byte
+		var payload []byte
+		var signedEntryTimestampBytes []byte
+		var priority int32
+
+		if err := rows.Scan(&leafHash, &payload, &signedEntryTimestampBytes, &priority); err != nil {
+			glog.Warningf("Error scanning work rows: %s", err)
+			return nil, err
+		}
+
+		if len(leafHash) != t.ts.hashSizeBytes {
+			return nil, errors.New("Dequeued a leaf with incorrect hash size")
+		}
+
+		signedEntryTimestamp, err := decodeSignedTimestamp(signedEntryTimestampBytes)
+
+		if err != nil {
+			return nil, err
+		}
+
+		leaf := trillian.LogLeaf{
+			Leaf: trillian.Leaf{
+				LeafHash:  leafHash,
+				LeafValue: payload,
+				ExtraData: nil,
+			},
+			SignedEntryTimestamp: signedEntryTimestamp,
+			SequenceNumber:       0,
+			Priority:             priority,
+		}
+		leaves = append(leaves, leaf)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	// The convention is that if leaf processing succeeds (by committing this tx)
+	// then the unsequenced entries for them are removed
+	if len(leaves) > 0 {
+		if err := t.removeSequencedLeaves(leaves); err != nil {
+			return nil, err
+		}
+	}
+
+	return leaves, nil
+}
+
+func (t *logTX) QueueLeaves(leaves []trillian.LogLeaf) error {
+	// Don't accept batches if any of the leaves are invalid.
+	for _, leaf := range leaves {
+		if len(leaf.LeafHash) != t.ts.hashSizeBytes {
+			return fmt.Errorf("Queued leaf must have a hash of length %d", t.ts.hashSizeBytes)
+		}
+
+		if leaf.SignedEntryTimestamp.Signature == nil || len(leaf.SignedEntryTimestamp.Signature.Signature) == 0 {
+			return errors.New("Queued leaf cannot have an empty signature")
+		}
+	}
+
+	for _, leaf := range leaves {
+		// Create the leaf data entry if it's not already there. INSERT OR IGNORE would also
+		// hide errors that aren't key collisions and REPLACE would do the wrong thing if there
+		// was ever a hash collision. Nothing else can write between the two statements as the
+		// transaction holds the database write lock.
+		var exists int
+		err := t.tx.QueryRow(selectLeafDataExistsSql, t.ls.logID.TreeID, []byte(leaf.LeafHash)).Scan(&exists)
+
+		if err == sql.ErrNoRows {
+			_, err = t.tx.Exec(insertUnsequencedLeafSql, t.ls.logID.TreeID,
+				[]byte(leaf.LeafHash), leaf.LeafValue, leaf.ExtraData)
+		}
+
+		if err != nil {
+			glog.Warningf("Error inserting into LeafData: %s", err)
+			return err
+		}
+
+		// Create the work queue entry
+		// Message ids only need to guard against duplicates for the time that entries are
+		// in the unsequenced queue, which should be short, but we'll still use a strong hash.
+		hasher := sha256.New()
+
+		// We use a fixed zero message id if the log disallows duplicates otherwise a random one.
+		// the fixed id will collide if dups submitted when not allowed so the insert won't succeed
+		// and everything will get rolled back
+		messageIdBytes := make([]byte, 8)
+
+		if t.ls.allowDuplicates {
+			if _, err := rand.Read(messageIdBytes); err != nil {
+				glog.Warningf("Failed to get a random message id: %s", err)
+				return err
+			}
+		}
+
+		hasher.Write(messageIdBytes)
+		hasher.Write(t.ls.logID.LogID)
+		hasher.Write(leaf.LeafHash)
+		messageId := hasher.Sum(nil)
+
+		signedTimestampBytes, err := encodeSignedTimestamp(leaf.SignedEntryTimestamp)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = t.tx.Exec(insertUnsequencedEntrySql,
+			t.ls.logID.TreeID, []byte(leaf.LeafHash), messageId, signedTimestampBytes, []byte(leaf.LeafValue), leaf.Priority)
+
+		if err != nil {
+			glog.Warningf("Error inserting into Unsequenced: %s", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *logTX) GetSequencedLeafCount() (int64, error) {
+	var sequencedLeafCount int64
+	err := t.tx.QueryRow(selectSequencedLeafCountSql, t.ls.logID.TreeID).Scan(&sequencedLeafCount)
+
+	if err != nil {
+		glog.Warningf("Error getting sequenced leaf count: %s", err)
+	}
+
+	return sequencedLeafCount, err
+}
+
+// scanLeaves reads the leaves returned by one of the leaf select statements.
+func (t *logTX) scanLeaves(rows *sql.Rows) ([]trillian.LogLeaf, error) {
+	defer rows.Close()
+
+	ret := make([]trillian.LogLeaf, 0)
+
+	for rows.Next() {
+		leaf := trillian.LogLeaf{}
+		var signedTimestampBytes []byte
+
+		if err := rows.Scan(&leaf.LeafHash, &leaf.LeafValue, &leaf.ExtraData, &leaf.SequenceNumber,
+			&signedTimestampBytes, &leaf.IntegrateTimestampNanos); err != nil {
+			glog.Warningf("Failed to scan merkle leaves: %s", err)
+			return nil, err
+		}
+
+		signedEntryTimestamp, err := decodeSignedTimestamp(signedTimestampBytes)
+
+		if err != nil {
+			return nil, err
+		}
+
+		leaf.SignedEntryTimestamp = signedEntryTimestamp
+
+		if got, want := len(leaf.LeafHash), t.ts.hashSizeBytes; got != want {
+			return nil, fmt.Errorf("Scanned leaf does not have hash length %d, got %d", want, got)
+		}
+
+		ret = append(ret, leaf)
+	}
+
+	return ret, rows.Err()
+}
+
+func (t *logTX) GetLeavesByIndex(leaves []int64) ([]trillian.LogLeaf, error) {
+	tmpl, err := t.ls.getLeavesByIndexStmt(len(leaves))
+	if err != nil {
+		return nil, err
+	}
+
+	return t.getLeavesByIndex(tmpl, leaves)
+}
+
+// GetLeavesByIndexWithoutExtraData implements storage.ExtraDataSkipper.
+func (t *logTX) GetLeavesByIndexWithoutExtraData(leaves []int64) ([]trillian.LogLeaf, error) {
+	tmpl, err := t.ls.getLeavesByIndexWithoutExtraDataStmt(len(leaves))
+	if err != nil {
+		return nil, err
+	}
+
+	return t.getLeavesByIndex(tmpl, leaves)
+}
+
+func (t *logTX) getLeavesByIndex(tmpl *sql.Stmt, leaves []int64) ([]trillian.LogLeaf, error) {
+	stx := t.tx.Stmt(tmpl)
+	defer stx.Close()
+
+	args := make([]interface{}, 0, len(leaves)+1)
+	for _, index := range leaves {
+		args = append(args, interface{}(index))
+	}
+	args = append(args, interface{}(t.ls.logID.TreeID))
+	rows, err := stx.Query(args...)
+	if err != nil {
+		glog.Warningf("Failed to get leaves by idx: %s", err)
+		return nil, err
+	}
+
+	ret, err := t.scanLeaves(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ret) != len(leaves) {
+		return nil, fmt.Errorf("expected %d leaves, but saw %d", len(leaves), len(ret))
+	}
+	return ret, nil
+}
+
+func (t *logTX) GetLeavesByHash(leafHashes []trillian.Hash, orderBySequence bool) ([]trillian.LogLeaf, error) {
+	tmpl, err := t.ls.getLeavesByHashStmt(len(leafHashes), orderBySequence)
+	if err != nil {
+		return nil, err
+	}
+	stx := t.tx.Stmt(tmpl)
+	defer stx.Close()
+
+	args := make([]interface{}, 0, len(leafHashes)+1)
+	for _, hash := range leafHashes {
+		args = append(args, interface{}([]byte(hash)))
+	}
+	args = append(args, interface{}(t.ls.logID.TreeID))
+	rows, err := stx.Query(args...)
+	if err != nil {
+		glog.Warningf("Failed to get leaves by hash: %s", err)
+		return nil, err
+	}
+
+	// The tree could include duplicates so we don't know how many results will be returned
+	return t.scanLeaves(rows)
+}
+
+func (t *logTX) GetLeavesByTimestamp(startNanos, endNanos, startIndex int64, limit int) ([]trillian.LogLeaf, error) {
+	rows, err := t.tx.Query(selectLeavesByTimestampSql, t.ls.logID.TreeID, startNanos, endNanos, startIndex, limit)
+
+	if err != nil {
+		glog.Warningf("Failed to get leaves by timestamp: %s", err)
+		return nil, err
+	}
+
+	return t.scanLeaves(rows)
+}
+
+func (t *logTX) LatestSignedLogRoot() (trillian.SignedLogRoot, error) {
+	root, err := t.scanSignedLogRoot(t.tx.QueryRow(selectLatestSignedLogRootSql, t.ls.logID.TreeID))
+
+	// It's possible there are no roots for this tree yet
+	if err == sql.ErrNoRows {
+		return trillian.SignedLogRoot{}, nil
+	}
+
+	return root, err
+}
+
+func (t *logTX) GetSignedLogRootAtRevision(treeRevision int64) (trillian.SignedLogRoot, error) {
+	root, err := t.scanSignedLogRoot(t.tx.QueryRow(selectSignedLogRootAtRevisionSql, t.ls.logID.TreeID, treeRevision))
+
+	if err == sql.ErrNoRows {
+		return trillian.SignedLogRoot{}, terrors.Errorf(terrors.NotFound, "no tree head stored at revision: %d", treeRevision)
+	}
+
+	return root, err
+}
+
+func (t *logTX) scanSignedLogRoot(row *sql.Row) (trillian.SignedLogRoot, error) {
+	var timestamp, treeSize, treeRevision int64
+	var rootHash, rootSignatureBytes, rootMetadata []byte
+	var rootSignature trillian.DigitallySigned
+
+	if err := row.Scan(&timestamp, &treeSize, &rootHash, &treeRevision, &rootSignatureBytes, &rootMetadata); err != nil {
+		return trillian.SignedLogRoot{}, err
+	}
+
+	if err := proto.Unmarshal(rootSignatureBytes, &rootSignature); err != nil {
+		glog.Warningf("Failed to unmarshall root signature: %v", err)
+		return trillian.SignedLogRoot{}, err
+	}
+
+	return trillian.SignedLogRoot{
+		RootHash:       rootHash,
+		TimestampNanos: timestamp,
+		TreeRevision:   treeRevision,
+		Signature:      &rootSignature,
+		LogId:          t.ls.logID.LogID,
+		TreeSize:       treeSize,
+		Metadata:       rootMetadata,
+	}, nil
+}
+
+func (t *logTX) StoreSignedLogRoot(root trillian.SignedLogRoot) error {
+	signatureBytes, err := proto.Marshal(root.Signature)
+
+	if err != nil {
+		glog.Warningf("Failed to marshal root signature: %v %v", root.Signature, err)
+		return err
+	}
+
+	res, err := t.tx.Exec(insertTreeHeadSql, t.ls.logID.TreeID, root.TimestampNanos, root.TreeSize,
+		root.RootHash, root.TreeRevision, signatureBytes, root.Metadata)
+
+	if err != nil {
+		glog.Warningf("Failed to store signed root: %s", err)
+	}
+
+	return checkResultOkAndRowCountIs(res, err, 1)
+}
+
+func (t *logTX) LatestCompactTree() (storage.CompactTreeProto, error) {
+	var stateBytes []byte
+	err := t.tx.QueryRow(selectCompactTreeSql, t.ls.logID.TreeID).Scan(&stateBytes)
+
+	// Nothing is stored until the first batch has been sequenced
+	if err == sql.ErrNoRows {
+		return storage.CompactTreeProto{}, nil
+	}
+
+	if err != nil {
+		glog.Warningf("Failed to read compact tree: %s", err)
+		return storage.CompactTreeProto{}, err
+	}
+
+	var state storage.CompactTreeProto
+
+	if err := proto.Unmarshal(stateBytes, &state); err != nil {
+		glog.Warningf("Failed to unmarshal compact tree: %v", err)
+		return storage.CompactTreeProto{}, err
+	}
+
+	return state, nil
+}
+
+func (t *logTX) StoreCompactTree(state storage.CompactTreeProto) error {
+	stateBytes, err := proto.Marshal(&state)
+
+	if err != nil {
+		glog.Warningf("Failed to marshal compact tree: %v", err)
+		return err
+	}
+
+	if _, err := t.tx.Exec(replaceCompactTreeSql, t.ls.logID.TreeID, state.TreeSize, stateBytes); err != nil {
+		glog.Warningf("Failed to store compact tree: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+func (t *logTX) GetTreeMetadata() (trillian.TreeMetadata, error) {
+	var metadata trillian.TreeMetadata
+	var createTime int64
+
+	err := t.tx.QueryRow(selectTreeMetadataSql, t.ls.logID.TreeID).Scan(
+		&metadata.DisplayName, &metadata.Description, &metadata.OwnerContact, &createTime)
+
+	if err == sql.ErrNoRows {
+		return trillian.TreeMetadata{}, terrors.Errorf(terrors.NotFound, "no trees row for log %v", t.ls.logID)
+	}
+
+	if err != nil {
+		glog.Warningf("Failed to read tree metadata: %s", err)
+		return trillian.TreeMetadata{}, err
+	}
+
+	metadata.CreateTimeNanos = createTime * int64(time.Second)
+
+	return metadata, nil
+}
+
+func (t *logTX) SetTreeMetadata(metadata trillian.TreeMetadata) error {
+	res, err := t.tx.Exec(updateTreeMetadataSql, metadata.DisplayName, metadata.Description, metadata.OwnerContact, t.ls.logID.TreeID)
+
+	if err != nil {
+		glog.Warningf("Failed to update tree metadata: %s", err)
+		return err
+	}
+
+	// SQLite counts matched rows, even if they were unchanged, so no rows means no tree
+	if err := checkResultOkAndRowCountIs(res, err, 1); err != nil {
+		return fmt.Errorf("no trees row for log %v", t.ls.logID)
+	}
+
+	return nil
+}
+
+// SequencingEnabled returns false if sequencing of the log has been paused. Logs without a
+// TreeControl row, or without SequencingEnabled set in it, are sequenced.
+func (t *logTX) SequencingEnabled() (bool, error) {
+	var enabled sql.NullBool
+
+	err := t.tx.QueryRow(selectSequencingEnabledSql, t.ls.logID.TreeID).Scan(&enabled)
+
+	if err == sql.ErrNoRows {
+		return true, nil
+	}
+
+	if err != nil {
+		glog.Warningf("Failed to read sequencing enabled: %s", err)
+		return false, err
+	}
+
+	return !enabled.Valid || enabled.Bool, nil
+}
+
+// SetSequencingEnabled pauses or resumes sequencing of the log, creating its TreeControl row
+// if it doesn't have one.
+func (t *logTX) SetSequencingEnabled(enabled bool) error {
+	// The tree must exist, TreeControl rows aren't created for unknown trees
+	if _, err := t.GetTreeMetadata(); err != nil {
+		return err
+	}
+
+	if _, err := t.tx.Exec(insertTreeControlSql, t.ls.logID.TreeID); err != nil {
+		glog.Warningf("Failed to create tree control row: %s", err)
+		return err
+	}
+
+	if _, err := t.tx.Exec(updateSequencingEnabledSql, enabled, t.ls.logID.TreeID); err != nil {
+		glog.Warningf("Failed to set sequencing enabled: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+// StoreCommitToken replaces the log's commit token with token when the transaction commits.
+func (t *logTX) StoreCommitToken(token []byte) error {
+	if _, err := t.tx.Exec(replaceCommitTokenSql, t.ls.logID.TreeID, token); err != nil {
+		glog.Warningf("Failed to store commit token: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+// LatestCommitToken returns the log's commit token, or nil if no transaction has stored one.
+func (t *logTX) LatestCommitToken() ([]byte, error) {
+	var token []byte
+	err := t.tx.QueryRow(selectCommitTokenSql, t.ls.logID.TreeID).Scan(&token)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		glog.Warningf("Failed to read commit token: %s", err)
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (t *logTX) UpdateSequencedLeaves(leaves []trillian.LogLeaf) error {
+	for _, leaf := range leaves {
+		// This should fail on insert but catch it early
+		if len(leaf.LeafHash) != t.ts.hashSizeBytes {
+			return errors.New("Sequenced leaf has incorrect hash size")
+		}
+
+		signedTimestampBytes, err := encodeSignedTimestamp(leaf.SignedEntryTimestamp)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = t.tx.Exec(insertSequencedLeafSql, t.ls.logID.TreeID, []byte(leaf.LeafHash),
+			leaf.SequenceNumber, signedTimestampBytes, leaf.IntegrateTimestampNanos)
+
+		if err != nil {
+			glog.Warningf("Failed to update sequenced leaves: %s", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lockSequenceRangeCounter returns the next sequence number and fencing token to hand out for
+// the log. There's no row locking in SQLite, the transaction already holds the database write
+// lock so nothing else can change them until it ends.
+func (t *logTX) lockSequenceRangeCounter() (int64, int64, error) {
+	if _, err := t.tx.Exec(initSequenceRangeCounterSql, t.ls.logID.TreeID); err != nil {
+		glog.Warningf("Failed to create sequence range counter: %s", err)
+		return 0, 0, err
+	}
+
+	var nextSequenceNumber, nextToken int64
+	err := t.tx.QueryRow(selectSequenceRangeCounterSql, t.ls.logID.TreeID).Scan(&nextSequenceNumber, &nextToken)
+
+	if err != nil {
+		glog.Warningf("Failed to read sequence range counter: %s", err)
+		return 0, 0, err
+	}
+
+	return nextSequenceNumber, nextToken, nil
+}
+
+// checkFence returns ErrFenced unless r is still reserved with its fencing token.
+func (t *logTX) checkFence(r storage.SequenceRange) error {
+	var token int64
+	err := t.tx.QueryRow(selectSequenceRangeTokenSql, t.ls.logID.TreeID, r.FirstSequenceNumber).Scan(&token)
+
+	if err == sql.ErrNoRows {
+		return storage.ErrFenced
+	}
+
+	if err != nil {
+		glog.Warningf("Failed to read sequence range: %s", err)
+		return err
+	}
+
+	if token != r.FencingToken {
+		return storage.ErrFenced
+	}
+
+	return nil
+}
+
+func (t *logTX) ReserveSequenceRange(sequencerID string, count int64) (storage.SequenceRange, error) {
+	if count <= 0 {
+		return storage.SequenceRange{}, fmt.Errorf("invalid sequence range size: %d", count)
+	}
+
+	first, token, err := t.lockSequenceRangeCounter()
+
+	if err != nil {
+		return storage.SequenceRange{}, err
+	}
+
+	// Leaves may have been sequenced without a reservation, so start after those too
+	var nextUnused int64
+	if err := t.tx.QueryRow(selectNextUnusedSequenceNumberSql, t.ls.logID.TreeID).Scan(&nextUnused); err != nil {
+		glog.Warningf("Failed to read highest sequence number: %s", err)
+		return storage.SequenceRange{}, err
+	}
+
+	if nextUnused > first {
+		first = nextUnused
+	}
+
+	r := storage.SequenceRange{
+		FirstSequenceNumber: first,
+		EndSequenceNumber:   first + count,
+		SequencerID:         sequencerID,
+		FencingToken:        token,
+	}
+
+	res, err := t.tx.Exec(insertSequenceRangeSql, t.ls.logID.TreeID, r.FirstSequenceNumber,
+		r.EndSequenceNumber, r.SequencerID, r.FencingToken)
+
+	if err := checkResultOkAndRowCountIs(res, err, 1); err != nil {
+		glog.Warningf("Failed to store sequence range: %s", err)
+		return storage.SequenceRange{}, err
+	}
+
+	res, err = t.tx.Exec(updateSequenceRangeCounterSql, r.EndSequenceNumber, token+1, t.ls.logID.TreeID)
+
+	if err := checkResultOkAndRowCountIs(res, err, 1); err != nil {
+		glog.Warningf("Failed to update sequence range counter: %s", err)
+		return storage.SequenceRange{}, err
+	}
+
+	return r, nil
+}
+
+func (t *logTX) TakeOverSequenceRange(r storage.SequenceRange, sequencerID string) (storage.SequenceRange, error) {
+	nextSequenceNumber, token, err := t.lockSequenceRangeCounter()
+
+	if err != nil {
+		return storage.SequenceRange{}, err
+	}
+
+	res, err := t.tx.Exec(updateSequenceRangeSql, sequencerID, token, t.ls.logID.TreeID,
+		r.FirstSequenceNumber, r.FencingToken)
+
+	if err != nil {
+		glog.Warningf("Failed to take over sequence range: %s", err)
+		return storage.SequenceRange{}, err
+	}
+
+	if err := checkResultOkAndRowCountIs(res, err, 1); err != nil {
+		return storage.SequenceRange{}, storage.ErrFenced
+	}
+
+	res, err = t.tx.Exec(updateSequenceRangeCounterSql, nextSequenceNumber, token+1, t.ls.logID.TreeID)
+
+	if err := checkResultOkAndRowCountIs(res, err, 1); err != nil {
+		glog.Warningf("Failed to update sequence range counter: %s", err)
+		return storage.SequenceRange{}, err
+	}
+
+	r.SequencerID = sequencerID
+	r.FencingToken = token
+
+	return r, nil
+}
+
+func (t *logTX) UpdateSequencedLeavesInRange(r storage.SequenceRange, leaves []trillian.LogLeaf) error {
+	for _, leaf := range leaves {
+		if !r.Contains(leaf.SequenceNumber) {
+			return fmt.Errorf("leaf sequence number %d is outside reserved range [%d, %d)",
+				leaf.SequenceNumber, r.FirstSequenceNumber, r.EndSequenceNumber)
+		}
+	}
+
+	if err := t.checkFence(r); err != nil {
+		return err
+	}
+
+	return t.UpdateSequencedLeaves(leaves)
+}
+
+func (t *logTX) ReleaseSequenceRange(r storage.SequenceRange) error {
+	res, err := t.tx.Exec(deleteSequenceRangeSql, t.ls.logID.TreeID, r.FirstSequenceNumber, r.FencingToken)
+
+	if err != nil {
+		glog.Warningf("Failed to release sequence range: %s", err)
+		return err
+	}
+
+	if err := checkResultOkAndRowCountIs(res, err, 1); err != nil {
+		return storage.ErrFenced
+	}
+
+	return nil
+}
+
+func (t *logTX) GetSequenceRanges() ([]storage.SequenceRange, error) {
+	rows, err := t.tx.Query(selectSequenceRangesSql, t.ls.logID.TreeID)
+
+	if err != nil {
+		glog.Warningf("Failed to read sequence ranges: %s", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ranges := make([]storage.SequenceRange, 0)
+
+	for rows.Next() {
+		var r storage.SequenceRange
+
+		if err := rows.Scan(&r.FirstSequenceNumber, &r.EndSequenceNumber, &r.SequencerID, &r.FencingToken); err != nil {
+			glog.Warningf("Failed to scan sequence range: %s", err)
+			return nil, err
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ranges, nil
+}
+
+func (t *logTX) removeSequencedLeaves(leaves []trillian.LogLeaf) error {
+	tmpl, err := t.ls.getDeleteUnsequencedStmt(len(leaves))
+	if err != nil {
+		glog.Warningf("Failed to get delete statement for sequenced work: %s", err)
+		return err
+	}
+	stx := t.tx.Stmt(tmpl)
+	defer stx.Close()
+
+	args := make([]interface{}, 0, len(leaves)+1)
+	for _, leaf := range leaves {
+		args = append(args, interface{}([]byte(leaf.LeafHash)))
+	}
+	args = append(args, interface{}(t.ls.logID.TreeID))
+	result, err := stx.Exec(args...)
+
+	if err != nil {
+		// Error is handled by checkResultOkAndRowCountIs() below
+		glog.Warningf("Failed to delete sequenced work: %s", err)
+	}
+
+	return checkResultOkAndRowCountIs(result, err, int64(len(leaves)))
+}
+
+func (t *logTX) getActiveLogIDsInternal(sql string) ([]trillian.LogID, error) {
+	rows, err := t.tx.Query(sql)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	logIDs := make([]trillian.LogID, 0)
+
+	for rows.Next() {
+		var logID []byte
+		var treeID int64
+
+		if err := rows.Scan(&treeID, &logID); err != nil {
+			return []trillian.LogID{}, err
+		}
+
+		logIDs = append(logIDs, trillian.LogID{LogID: logID, TreeID: treeID})
+	}
+
+	if rows.Err() != nil {
+		return []trillian.LogID{}, rows.Err()
+	}
+
+	return logIDs, nil
+}
+
+// GetActiveLogIDs returns a list of the IDs of all configured logs
+func (t *logTX) GetActiveLogIDs() ([]trillian.LogID, error) {
+	return t.getActiveLogIDsInternal(selectActiveLogsSql)
+}
+
+// GetActiveLogIDsWithPendingWork returns a list of the IDs of all configured logs
+// that have queued unsequenced leaves that need to be integrated
+func (t *logTX) GetActiveLogIDsWithPendingWork() ([]trillian.LogID, error) {
+	return t.getActiveLogIDsInternal(selectActiveLogsWithUnsequencedSql)
+}
@@ -0,0 +1,246 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/merkle"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
+)
+
+const insertMapHeadSQL string = `INSERT INTO MapHead(TreeId, MapHeadTimestamp, RootHash, MapRevision, RootSignature, MapperData)
+	VALUES(?, ?, ?, ?, ?, ?)`
+
+const selectLatestSignedMapRootSql string = `SELECT MapHeadTimestamp, RootHash, MapRevision, RootSignature, MapperData
+		 FROM MapHead WHERE TreeId=?
+		 ORDER BY MapHeadTimestamp DESC LIMIT 1`
+
+const selectSignedMapRootAtRevisionSQL string = `SELECT MapHeadTimestamp, RootHash, MapRevision, RootSignature, MapperData
+		 FROM MapHead WHERE TreeId=? AND MapRevision=?`
+
+const insertMapLeafSQL string = `INSERT INTO MapLeaf(TreeId, KeyHash, MapRevision, TheData) VALUES (?, ?, ?, ?)`
+const selectMapLeafSQL string = `SELECT KeyHash, MapRevision, TheData
+	 FROM MapLeaf
+	 WHERE TreeId = ? AND
+	 			 KeyHash = ? AND
+				 MapRevision <= ?
+	 ORDER BY MapRevision DESC LIMIT 1`
+
+const selectMapLeafHistorySQL string = `SELECT MapRevision, TheData
+	 FROM MapLeaf
+	 WHERE TreeId = ? AND
+	 			 KeyHash = ? AND
+				 MapRevision <= ?
+	 ORDER BY MapRevision`
+
+type sqliteMapStorage struct {
+	sqliteTreeStorage
+
+	mapID trillian.MapID
+}
+
+func (m *sqliteMapStorage) MapID() trillian.MapID {
+	return m.mapID
+}
+
+// NewMapStorage creates storage for a map in the SQLite database at dbPath, which is created
+// if it doesn't exist. Transactions wait up to busyTimeout for other transactions using the
+// database to finish.
+func NewMapStorage(id trillian.MapID, dbPath string, busyTimeout time.Duration) (storage.MapStorage, error) {
+	th := merkle.NewRFC6962TreeHasher(trillian.NewSHA256())
+	ts, err := newTreeStorage(id.TreeID, dbPath, busyTimeout, th.Size(), cache.PopulateMapSubtreeNodes(th))
+	if err != nil {
+		glog.Warningf("Couldn't create a new treeStorage: %s", err)
+		return nil, err
+	}
+
+	return &sqliteMapStorage{
+		sqliteTreeStorage: ts,
+		mapID:             id,
+	}, nil
+}
+
+func (m *sqliteMapStorage) Begin() (storage.MapTX, error) {
+	ttx, err := m.beginTreeTx()
+	if err != nil {
+		return nil, err
+	}
+	ret := &mapTX{
+		treeTX: ttx,
+		ms:     m,
+	}
+
+	root, err := ret.LatestSignedMapRoot()
+	if err != nil {
+		ttx.Rollback()
+		return nil, err
+	}
+
+	ret.treeTX.writeRevision = root.MapRevision + 1
+
+	return ret, nil
+}
+
+func (m *sqliteMapStorage) Snapshot() (storage.ReadOnlyMapTX, error) {
+	tx, err := m.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return tx.(storage.ReadOnlyMapTX), err
+}
+
+type mapTX struct {
+	treeTX
+	ms *sqliteMapStorage
+}
+
+func (t *mapTX) WriteRevision() int64 {
+	return t.treeTX.writeRevision
+}
+
+func (m *mapTX) Set(keyHash trillian.Hash, value trillian.MapLeaf) error {
+	flatValue, err := proto.Marshal(&value)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.tx.Exec(insertMapLeafSQL, m.ms.mapID.TreeID, []byte(keyHash), m.writeRevision, flatValue)
+	return err
+}
+
+func (m *mapTX) Get(revision int64, keyHash trillian.Hash) (trillian.MapLeaf, error) {
+	var mapKeyHash []byte
+	var mapRevision int64
+	var flatData []byte
+
+	err := m.tx.QueryRow(selectMapLeafSQL, m.ms.mapID.TreeID, []byte(keyHash), revision).Scan(
+		&mapKeyHash, &mapRevision, &flatData)
+
+	// It's possible there is no value for this value yet
+	if err == sql.ErrNoRows {
+		return trillian.MapLeaf{}, storage.ErrNoSuchKey
+	} else if err != nil {
+		return trillian.MapLeaf{}, err
+	}
+
+	var mapLeaf trillian.MapLeaf
+	err = proto.Unmarshal(flatData, &mapLeaf)
+	return mapLeaf, err
+}
+
+func (m *mapTX) GetHistory(revision int64, keyHash trillian.Hash) ([]storage.MapLeafRevision, error) {
+	rows, err := m.tx.Query(selectMapLeafHistorySQL, m.ms.mapID.TreeID, []byte(keyHash), revision)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := make([]storage.MapLeafRevision, 0)
+	for rows.Next() {
+		var mapRevision int64
+		var flatData []byte
+
+		if err := rows.Scan(&mapRevision, &flatData); err != nil {
+			return nil, err
+		}
+
+		h := storage.MapLeafRevision{Revision: mapRevision}
+		if err := proto.Unmarshal(flatData, &h.Leaf); err != nil {
+			glog.Warningf("Failed to unmarshal map leaf: %v", err)
+			return nil, err
+		}
+		history = append(history, h)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+func (m *mapTX) LatestSignedMapRoot() (trillian.SignedMapRoot, error) {
+	root, err := m.scanSignedMapRoot(m.tx.QueryRow(selectLatestSignedMapRootSql, m.ms.mapID.TreeID))
+
+	// It's possible there are no roots for this tree yet
+	if err == sql.ErrNoRows {
+		return trillian.SignedMapRoot{}, nil
+	}
+
+	return root, err
+}
+
+func (m *mapTX) GetSignedMapRootAtRevision(revision int64) (trillian.SignedMapRoot, error) {
+	root, err := m.scanSignedMapRoot(m.tx.QueryRow(selectSignedMapRootAtRevisionSQL, m.ms.mapID.TreeID, revision))
+
+	if err == sql.ErrNoRows {
+		return trillian.SignedMapRoot{}, fmt.Errorf("no map head stored at revision: %d", revision)
+	}
+
+	return root, err
+}
+
+func (m *mapTX) scanSignedMapRoot(row *sql.Row) (trillian.SignedMapRoot, error) {
+	var timestamp, mapRevision int64
+	var rootHash, rootSignatureBytes []byte
+	var rootSignature trillian.DigitallySigned
+	var mapperMetaBytes []byte
+	var mapperMeta *trillian.MapperMetadata
+
+	if err := row.Scan(&timestamp, &rootHash, &mapRevision, &rootSignatureBytes, &mapperMetaBytes); err != nil {
+		return trillian.SignedMapRoot{}, err
+	}
+
+	if err := proto.Unmarshal(rootSignatureBytes, &rootSignature); err != nil {
+		glog.Warningf("Failed to unmarshal root signature: %v", err)
+		return trillian.SignedMapRoot{}, err
+	}
+
+	if len(mapperMetaBytes) != 0 {
+		mapperMeta = &trillian.MapperMetadata{}
+		if err := proto.Unmarshal(mapperMetaBytes, mapperMeta); err != nil {
+			glog.Warningf("Failed to unmarshal Metadata; %v", err)
+			return trillian.SignedMapRoot{}, err
+		}
+	}
+
+	return trillian.SignedMapRoot{
+		RootHash:       rootHash,
+		TimestampNanos: timestamp,
+		MapRevision:    mapRevision,
+		Signature:      &rootSignature,
+		MapId:          m.ms.mapID.MapID,
+		Metadata:       mapperMeta,
+	}, nil
+}
+
+func (m *mapTX) StoreSignedMapRoot(root trillian.SignedMapRoot) error {
+	signatureBytes, err := proto.Marshal(root.Signature)
+	if err != nil {
+		glog.Warningf("Failed to marshal root signature: %v %v", root.Signature, err)
+		return err
+	}
+
+	var mapperMetaBytes []byte
+
+	if root.Metadata != nil {
+		mapperMetaBytes, err = proto.Marshal(root.Metadata)
+		if err != nil {
+			glog.Warningf("Failed to marshal MetaData: %v %v", root.Metadata, err)
+			return err
+		}
+	}
+
+	res, err := m.tx.Exec(insertMapHeadSQL, m.ms.mapID.TreeID, root.TimestampNanos, root.RootHash, root.MapRevision, signatureBytes, mapperMetaBytes)
+
+	if err != nil {
+		glog.Warningf("Failed to store signed map root: %s", err)
+	}
+
+	return checkResultOkAndRowCountIs(res, err, 1)
+}
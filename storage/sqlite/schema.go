@@ -0,0 +1,173 @@
+package sqlite
+
+// schemaSql is the SQLite version of the tree schema in storage/mysql/storage.sql. It's
+// applied whenever a database is opened so a new file is ready to use straight away. SQLite
+// has no ENUM type so the allowed values are checked instead, and indexes are created
+// separately from their tables.
+const schemaSql string = `
+-- Tree parameters should not be changed after creation. Doing so can
+-- render the data in the tree unusable or inconsistent.
+CREATE TABLE IF NOT EXISTS Trees(
+  TreeId                INTEGER NOT NULL,
+  KeyId                 BLOB NOT NULL,
+  TreeType              TEXT NOT NULL CHECK(TreeType IN ('LOG', 'MAP')),
+  LeafHasherType        TEXT NOT NULL CHECK(LeafHasherType IN ('SHA256', 'SHA512_256', 'SHA3_256')),
+  TreeHasherType        TEXT NOT NULL CHECK(TreeHasherType IN ('SHA256', 'SHA512_256', 'SHA3_256')),
+  AllowsDuplicateLeaves BOOLEAN NOT NULL DEFAULT 0,
+  LeafHashStrategy      TEXT NOT NULL DEFAULT 'RFC6962' CHECK(LeafHashStrategy IN ('RFC6962', 'RAW')),
+  TreeDepth             INTEGER NOT NULL DEFAULT 64 CHECK(TreeDepth IN (8, 16, 24, 32, 40, 48, 56, 64)),
+  -- Human readable metadata that identifies the tree. Unlike the columns above it can be
+  -- changed at any time, it doesn't affect the tree.
+  DisplayName           TEXT NOT NULL DEFAULT '',
+  Description           TEXT NOT NULL DEFAULT '',
+  OwnerContact          TEXT NOT NULL DEFAULT '',
+  CreateTime            TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY(TreeId)
+);
+
+CREATE TABLE IF NOT EXISTS TreeControl(
+  TreeId                  INTEGER NOT NULL,
+  ReadOnlyRequests        BOOLEAN,
+  SigningEnabled          BOOLEAN,
+  SequencingEnabled       BOOLEAN,
+  SequenceIntervalSeconds INTEGER,
+  SignIntervalSeconds     INTEGER,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId)
+);
+
+CREATE TABLE IF NOT EXISTS Subtree(
+  TreeId               INTEGER NOT NULL,
+  SubtreeId            BLOB NOT NULL,
+  Nodes                BLOB NOT NULL,
+  SubtreeRevision      INTEGER NOT NULL,
+  PRIMARY KEY(TreeId, SubtreeId, SubtreeRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- The TreeRevisionIdx is used to enforce that there is only one STH at any
+-- tree revision
+CREATE TABLE IF NOT EXISTS TreeHead(
+  TreeId               INTEGER NOT NULL,
+  TreeHeadTimestamp    INTEGER,
+  TreeSize             INTEGER,
+  RootHash             BLOB NOT NULL,
+  RootSignature        BLOB NOT NULL,
+  TreeRevision         INTEGER,
+  RootMetadata         BLOB,
+  PRIMARY KEY(TreeId, TreeHeadTimestamp),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS TreeRevisionIdx ON TreeHead(TreeId, TreeRevision);
+
+-- See storage.TreeStatsReader
+CREATE TABLE IF NOT EXISTS TreeStats(
+  TreeId               INTEGER NOT NULL,
+  TableName            TEXT NOT NULL,
+  RowCount             INTEGER NOT NULL,
+  ByteSize             INTEGER NOT NULL,
+  Watermark            INTEGER NOT NULL,
+  SampleTimeNanos      INTEGER NOT NULL,
+  BaseRowCount         INTEGER NOT NULL,
+  BaseByteSize         INTEGER NOT NULL,
+  BaseTimeNanos        INTEGER NOT NULL,
+  NextBaseRowCount     INTEGER NOT NULL,
+  NextBaseByteSize     INTEGER NOT NULL,
+  NextBaseTimeNanos    INTEGER NOT NULL,
+  PRIMARY KEY(TreeId, TableName),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS CompactTree(
+  TreeId               INTEGER NOT NULL,
+  TreeSize             INTEGER NOT NULL,
+  State                BLOB NOT NULL,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS LeafData(
+  TreeId               INTEGER NOT NULL,
+  LeafHash             BLOB NOT NULL,
+  TheData              BLOB NOT NULL,
+  ExtraData            BLOB,
+  PRIMARY KEY(TreeId, LeafHash),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+-- A foreign key must refer to a unique key in SQLite, so unlike MySQL the reference
+-- to LeafData includes the tree
+CREATE TABLE IF NOT EXISTS SequencedLeafData(
+  TreeId               INTEGER NOT NULL,
+  SequenceNumber       INTEGER NOT NULL,
+  LeafHash             BLOB NOT NULL,
+  SignedEntryTimestamp BLOB NOT NULL,
+  IntegrateTimestampNanos INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY(TreeId, SequenceNumber),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE,
+  FOREIGN KEY(TreeId, LeafHash) REFERENCES LeafData(TreeId, LeafHash)
+);
+
+CREATE INDEX IF NOT EXISTS IntegrateTimestampIdx ON SequencedLeafData(TreeId, IntegrateTimestampNanos);
+
+CREATE TABLE IF NOT EXISTS Unsequenced(
+  TreeId               INTEGER NOT NULL,
+  LeafHash             BLOB NOT NULL,
+  MessageId            BLOB NOT NULL,
+  Payload              BLOB NOT NULL,
+  QueueTimestamp       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  SignedEntryTimestamp BLOB,
+  Priority             INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (TreeId, LeafHash, MessageId)
+);
+
+CREATE INDEX IF NOT EXISTS UnsequencedPriorityIdx ON Unsequenced(TreeId, Priority, QueueTimestamp);
+
+CREATE TABLE IF NOT EXISTS SequenceRange(
+  TreeId               INTEGER NOT NULL,
+  FirstSequenceNumber  INTEGER NOT NULL,
+  EndSequenceNumber    INTEGER NOT NULL,
+  SequencerId          TEXT NOT NULL,
+  FencingToken         INTEGER NOT NULL,
+  PRIMARY KEY(TreeId, FirstSequenceNumber),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS SequenceRangeCounter(
+  TreeId               INTEGER NOT NULL,
+  NextSequenceNumber   INTEGER NOT NULL,
+  NextFencingToken     INTEGER NOT NULL,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS CommitToken(
+  TreeId               INTEGER NOT NULL,
+  Token                BLOB NOT NULL,
+  PRIMARY KEY(TreeId),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS MapLeaf(
+  TreeId                INTEGER NOT NULL,
+  KeyHash               BLOB NOT NULL,
+  MapRevision           INTEGER NOT NULL,
+  TheData               BLOB NOT NULL,
+  PRIMARY KEY(TreeId, KeyHash, MapRevision),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS MapHead(
+  TreeId               INTEGER NOT NULL,
+  MapHeadTimestamp     INTEGER,
+  RootHash             BLOB NOT NULL,
+  MapRevision          INTEGER,
+  RootSignature        BLOB NOT NULL,
+  MapperData           BLOB,
+  PRIMARY KEY(TreeId, MapHeadTimestamp),
+  FOREIGN KEY(TreeId) REFERENCES Trees(TreeId) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS MapRevisionIdx ON MapHead(TreeId, MapRevision);
+`
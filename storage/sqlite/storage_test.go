@@ -0,0 +1,494 @@
+package sqlite
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/testsuite"
+)
+
+// Tests get a database file in a new temporary directory, which they should remove when
+// they finish. Trees in the same file must have distinct IDs.
+var idMutex sync.Mutex
+var testTreeID int64
+
+func createTestDB(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "sqlite_storage_test")
+
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	return filepath.Join(dir, "trillian.db"), func() { os.RemoveAll(dir) }
+}
+
+func nextTreeID() int64 {
+	idMutex.Lock()
+	defer idMutex.Unlock()
+	testTreeID++
+	return testTreeID
+}
+
+func createTestLogStorage(dbPath string, busyTimeout time.Duration, t *testing.T) (trillian.LogID, storage.LogStorage) {
+	treeID := nextTreeID()
+	logID := trillian.LogID{LogID: []byte(fmt.Sprintf("log%d", treeID)), TreeID: treeID}
+
+	if err := CreateLogTree(dbPath, logID); err != nil {
+		t.Fatalf("Failed to create log: %v", err)
+	}
+
+	s, err := NewLogStorage(logID, dbPath, busyTimeout)
+
+	if err != nil {
+		t.Fatalf("Failed to open log storage: %v", err)
+	}
+
+	return logID, s
+}
+
+func createTestLeaves(n int, prefix string) []trillian.LogLeaf {
+	hasher := trillian.NewSHA256()
+	leaves := make([]trillian.LogLeaf, 0, n)
+
+	for i := 0; i < n; i++ {
+		value := []byte(fmt.Sprintf("%s leaf %d", prefix, i))
+		leaves = append(leaves, trillian.LogLeaf{
+			Leaf: trillian.Leaf{LeafHash: hasher.Digest(value), LeafValue: value},
+			SignedEntryTimestamp: trillian.SignedEntryTimestamp{
+				TimestampNanos: int64(i),
+				Signature:      &trillian.DigitallySigned{Signature: []byte("notempty")}},
+		})
+	}
+
+	return leaves
+}
+
+func TestLogStorageConformance(t *testing.T) {
+	dbPath, cleanup := createTestDB(t)
+	defer cleanup()
+
+	testsuite.RunLogStorageTests(t, func(t *testing.T) storage.LogStorage {
+		_, s := createTestLogStorage(dbPath, DefaultBusyTimeout, t)
+		return s
+	})
+}
+
+func TestMapStorageConformance(t *testing.T) {
+	dbPath, cleanup := createTestDB(t)
+	defer cleanup()
+
+	testsuite.RunMapStorageTests(t, func(t *testing.T) storage.MapStorage {
+		treeID := nextTreeID()
+		mapID := trillian.MapID{MapID: []byte(fmt.Sprintf("map%d", treeID)), TreeID: treeID}
+
+		if err := CreateMapTree(dbPath, mapID); err != nil {
+			t.Fatalf("Failed to create map: %v", err)
+		}
+
+		s, err := NewMapStorage(mapID, dbPath, DefaultBusyTimeout)
+
+		if err != nil {
+			t.Fatalf("Failed to open map storage: %v", err)
+		}
+
+		return s
+	})
+}
+
+func TestInvalidDatabasePath(t *testing.T) {
+	for _, dbPath := range []string{"", "trillian.db?mode=memory", "trillian.db#x"} {
+		if _, err := NewLogStorage(trillian.LogID{TreeID: 1}, dbPath, DefaultBusyTimeout); err == nil {
+			t.Errorf("NewLogStorage(%q) succeeded, expected an error", dbPath)
+		}
+	}
+}
+
+func TestWALMode(t *testing.T) {
+	dbPath, cleanup := createTestDB(t)
+	defer cleanup()
+	_, s := createTestLogStorage(dbPath, DefaultBusyTimeout, t)
+	defer s.Close()
+
+	var mode string
+	if err := s.(*sqliteLogStorage).db.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil {
+		t.Fatalf("Failed to read journal mode: %v", err)
+	}
+
+	if got, want := mode, "wal"; got != want {
+		t.Fatalf("Got journal mode %s, expected %s", got, want)
+	}
+}
+
+func TestConcurrentWritersWaitForLock(t *testing.T) {
+	dbPath, cleanup := createTestDB(t)
+	defer cleanup()
+
+	// Each writer has its own connections to the file, like separate processes would
+	const writers = 4
+	const batches = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*batches)
+	logIDs := make([]trillian.LogID, writers)
+
+	for w := 0; w < writers; w++ {
+		var s storage.LogStorage
+		logIDs[w], s = createTestLogStorage(dbPath, 10*time.Second, t)
+		defer s.Close()
+
+		wg.Add(1)
+		go func(w int, s storage.LogStorage) {
+			defer wg.Done()
+
+			for b := 0; b < batches; b++ {
+				tx, err := s.Begin()
+
+				if err != nil {
+					errs <- err
+					return
+				}
+
+				if err := tx.QueueLeaves(createTestLeaves(5, fmt.Sprintf("writer %d batch %d", w, b))); err != nil {
+					tx.Rollback()
+					errs <- err
+					return
+				}
+
+				if err := tx.Commit(); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(w, s)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Concurrent write failed: %v", err)
+	}
+
+	// Every log has queued work
+	s, err := NewLogStorage(logIDs[0], dbPath, DefaultBusyTimeout)
+
+	if err != nil {
+		t.Fatalf("Failed to open log storage: %v", err)
+	}
+
+	defer s.Close()
+	tx, err := s.Begin()
+
+	if err != nil {
+		t.Fatalf("Failed to begin tx: %v", err)
+	}
+
+	defer tx.Rollback()
+	pending, err := tx.GetActiveLogIDsWithPendingWork()
+
+	if err != nil {
+		t.Fatalf("Failed to get logs with pending work: %v", err)
+	}
+
+	if got, want := len(pending), writers; got != want {
+		t.Fatalf("Got %d logs with pending work, expected %d: %v", got, want, pending)
+	}
+}
+
+func TestBusyTimeoutExpires(t *testing.T) {
+	dbPath, cleanup := createTestDB(t)
+	defer cleanup()
+	logID, s1 := createTestLogStorage(dbPath, DefaultBusyTimeout, t)
+	defer s1.Close()
+
+	s2, err := NewLogStorage(logID, dbPath, 100*time.Millisecond)
+
+	if err != nil {
+		t.Fatalf("Failed to open log storage: %v", err)
+	}
+
+	defer s2.Close()
+
+	tx1, err := s1.Begin()
+
+	if err != nil {
+		t.Fatalf("Failed to begin tx: %v", err)
+	}
+
+	start := time.Now()
+
+	if tx2, err := s2.Begin(); err == nil {
+		tx2.Rollback()
+		t.Fatalf("Began a second write transaction while the first was open")
+	}
+
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Gave up after %v, expected to wait for the busy timeout", elapsed)
+	}
+
+	if err := tx1.Commit(); err != nil {
+		t.Fatalf("Failed to commit tx: %v", err)
+	}
+
+	// Now the lock is free
+	tx2, err := s2.Begin()
+
+	if err != nil {
+		t.Fatalf("Failed to begin tx after the first finished: %v", err)
+	}
+
+	tx2.Rollback()
+}
+
+func TestTreeMetadataRoundTrip(t *testing.T) {
+	dbPath, cleanup := createTestDB(t)
+	defer cleanup()
+	_, s := createTestLogStorage(dbPath, DefaultBusyTimeout, t)
+	defer s.Close()
+
+	tx, err := s.Begin()
+
+	if err != nil {
+		t.Fatalf("Failed to begin tx: %v", err)
+	}
+
+	defer tx.Rollback()
+	metadata, err := tx.GetTreeMetadata()
+
+	if err != nil {
+		t.Fatalf("Failed to read tree metadata: %v", err)
+	}
+
+	if createTime := time.Unix(0, metadata.CreateTimeNanos); time.Since(createTime) > time.Hour || createTime.After(time.Now()) {
+		t.Fatalf("Got creation time %v for new tree, expected about now", createTime)
+	}
+
+	want := trillian.TreeMetadata{DisplayName: "Test log", Description: "A log for testing", OwnerContact: "owner@example.com", CreateTimeNanos: metadata.CreateTimeNanos}
+
+	// Setting the same metadata twice isn't an error, and the creation time is ignored
+	for i := 0; i < 2; i++ {
+		set := want
+		set.CreateTimeNanos = 1
+
+		if err := tx.SetTreeMetadata(set); err != nil {
+			t.Fatalf("Failed to set tree metadata: %v", err)
+		}
+	}
+
+	if metadata, err = tx.GetTreeMetadata(); err != nil {
+		t.Fatalf("Failed to read tree metadata: %v", err)
+	}
+
+	if !proto.Equal(&metadata, &want) {
+		t.Fatalf("Tree metadata round trip failed: <%v> and: <%v>", metadata, want)
+	}
+}
+
+func TestTreeMetadataUnknownTree(t *testing.T) {
+	dbPath, cleanup := createTestDB(t)
+	defer cleanup()
+
+	s, err := NewLogStorage(trillian.LogID{LogID: []byte("unknown"), TreeID: nextTreeID()}, dbPath, DefaultBusyTimeout)
+
+	if err != nil {
+		t.Fatalf("Failed to open log storage: %v", err)
+	}
+
+	defer s.Close()
+	tx, err := s.Begin()
+
+	if err != nil {
+		t.Fatalf("Failed to begin tx: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.GetTreeMetadata(); err == nil {
+		t.Errorf("Read metadata for a tree that doesn't exist")
+	}
+
+	if err := tx.SetTreeMetadata(trillian.TreeMetadata{DisplayName: "x"}); err == nil {
+		t.Errorf("Set metadata for a tree that doesn't exist")
+	}
+}
+
+func TestSequencingEnabledRoundTrip(t *testing.T) {
+	dbPath, cleanup := createTestDB(t)
+	defer cleanup()
+	_, s := createTestLogStorage(dbPath, DefaultBusyTimeout, t)
+	defer s.Close()
+
+	tx, err := s.Begin()
+
+	if err != nil {
+		t.Fatalf("Failed to begin tx: %v", err)
+	}
+
+	defer tx.Rollback()
+	controller, ok := tx.(storage.SequencingController)
+
+	if !ok {
+		t.Fatalf("Log tx doesn't implement SequencingController")
+	}
+
+	// A new tree has no TreeControl row and is sequenced
+	if enabled, err := controller.SequencingEnabled(); err != nil || !enabled {
+		t.Fatalf("Got sequencing enabled %v, %v for new tree, expected true", enabled, err)
+	}
+
+	for _, enabled := range []bool{false, false, true} {
+		if err := controller.SetSequencingEnabled(enabled); err != nil {
+			t.Fatalf("Failed to set sequencing enabled to %v: %v", enabled, err)
+		}
+
+		if got, err := controller.SequencingEnabled(); err != nil || got != enabled {
+			t.Fatalf("Got sequencing enabled %v, %v, expected %v", got, err, enabled)
+		}
+	}
+}
+
+func TestCommitTokenRoundTrip(t *testing.T) {
+	dbPath, cleanup := createTestDB(t)
+	defer cleanup()
+	_, s := createTestLogStorage(dbPath, DefaultBusyTimeout, t)
+	defer s.Close()
+
+	for i, token := range [][]byte{nil, []byte("token1"), []byte("token2")} {
+		tx, err := s.Begin()
+
+		if err != nil {
+			t.Fatalf("Failed to begin tx: %v", err)
+		}
+
+		tokenStore, ok := tx.(storage.CommitTokenStore)
+
+		if !ok {
+			t.Fatalf("Log tx doesn't implement CommitTokenStore")
+		}
+
+		// Each transaction sees the token committed by the one before it
+		if got, err := tokenStore.LatestCommitToken(); err != nil || !bytes.Equal(got, token) {
+			t.Fatalf("Got commit token %q, %v, expected %q", got, err, token)
+		}
+
+		if err := tokenStore.StoreCommitToken([]byte(fmt.Sprintf("token%d", i+1))); err != nil {
+			t.Fatalf("Failed to store commit token: %v", err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Failed to commit tx: %v", err)
+		}
+	}
+}
+
+func TestSequencingEnabledUnknownTree(t *testing.T) {
+	dbPath, cleanup := createTestDB(t)
+	defer cleanup()
+
+	s, err := NewLogStorage(trillian.LogID{LogID: []byte("unknown"), TreeID: nextTreeID()}, dbPath, DefaultBusyTimeout)
+
+	if err != nil {
+		t.Fatalf("Failed to open log storage: %v", err)
+	}
+
+	defer s.Close()
+	tx, err := s.Begin()
+
+	if err != nil {
+		t.Fatalf("Failed to begin tx: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	if err := tx.(storage.SequencingController).SetSequencingEnabled(false); err == nil {
+		t.Errorf("Paused sequencing of a tree that doesn't exist")
+	}
+}
+
+func TestCreateLogTreeWithDepth(t *testing.T) {
+	dbPath, cleanup := createTestDB(t)
+	defer cleanup()
+
+	_, s := createTestLogStorage(dbPath, DefaultBusyTimeout, t)
+	defer s.Close()
+
+	if got, want := s.TreeDepth(), storage.DefaultLogTreeDepth; got != want {
+		t.Errorf("Default log has depth %d, expected %d", got, want)
+	}
+
+	treeID := nextTreeID()
+	logID := trillian.LogID{LogID: []byte(fmt.Sprintf("log%d", treeID)), TreeID: treeID}
+
+	if err := CreateLogTreeWithDepth(dbPath, logID, 32); err != nil {
+		t.Fatalf("Failed to create log: %v", err)
+	}
+
+	s32, err := NewLogStorage(logID, dbPath, DefaultBusyTimeout)
+
+	if err != nil {
+		t.Fatalf("Failed to open log storage: %v", err)
+	}
+
+	defer s32.Close()
+
+	if got, want := s32.TreeDepth(), 32; got != want {
+		t.Errorf("Log has depth %d, expected %d", got, want)
+	}
+
+	for _, depth := range []int{0, 12, 72} {
+		treeID := nextTreeID()
+		logID := trillian.LogID{LogID: []byte(fmt.Sprintf("log%d", treeID)), TreeID: treeID}
+
+		if err := CreateLogTreeWithDepth(dbPath, logID, depth); err == nil {
+			t.Errorf("Created log with invalid depth %d", depth)
+		}
+	}
+}
+
+func TestCreateLogTreeWithHashAlgorithm(t *testing.T) {
+	dbPath, cleanup := createTestDB(t)
+	defer cleanup()
+
+	_, s := createTestLogStorage(dbPath, DefaultBusyTimeout, t)
+	defer s.Close()
+
+	if got, want := s.HashAlgorithm(), trillian.HashAlgorithm_SHA256; got != want {
+		t.Errorf("Default log has hash algorithm %v, expected %v", got, want)
+	}
+
+	for _, alg := range []trillian.HashAlgorithm{trillian.HashAlgorithm_SHA512_256, trillian.HashAlgorithm_SHA3_256} {
+		treeID := nextTreeID()
+		logID := trillian.LogID{LogID: []byte(fmt.Sprintf("log%d", treeID)), TreeID: treeID}
+
+		if err := CreateLogTreeWithHashAlgorithm(dbPath, logID, storage.DefaultLogTreeDepth, alg); err != nil {
+			t.Fatalf("Failed to create log: %v", err)
+		}
+
+		algStorage, err := NewLogStorage(logID, dbPath, DefaultBusyTimeout)
+
+		if err != nil {
+			t.Fatalf("Failed to open log storage: %v", err)
+		}
+
+		if got := algStorage.HashAlgorithm(); got != alg {
+			t.Errorf("Log has hash algorithm %v, expected %v", got, alg)
+		}
+
+		algStorage.Close()
+	}
+
+	treeID := nextTreeID()
+	logID := trillian.LogID{LogID: []byte(fmt.Sprintf("log%d", treeID)), TreeID: treeID}
+
+	if err := CreateLogTreeWithHashAlgorithm(dbPath, logID, storage.DefaultLogTreeDepth, trillian.HashAlgorithm(99)); err == nil {
+		t.Error("Created log with unknown hash algorithm")
+	}
+}
@@ -0,0 +1,124 @@
+package sqlite
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+)
+
+const selectTreeStatsSql string = `SELECT TableName,RowCount,ByteSize,Watermark,SampleTimeNanos,
+		 BaseRowCount,BaseByteSize,BaseTimeNanos,NextBaseRowCount,NextBaseByteSize,NextBaseTimeNanos
+		 FROM TreeStats WHERE TreeId=?`
+const replaceTreeStatsSql string = `REPLACE INTO TreeStats(TreeId,TableName,RowCount,ByteSize,Watermark,SampleTimeNanos,
+		 BaseRowCount,BaseByteSize,BaseTimeNanos,NextBaseRowCount,NextBaseByteSize,NextBaseTimeNanos)
+		 VALUES(?,?,?,?,?,?,?,?,?,?,?,?)`
+
+const countLeafStatsSql string = `SELECT COUNT(*),
+		 COALESCE(SUM(LENGTH(s.LeafHash)+LENGTH(s.SignedEntryTimestamp)+LENGTH(l.TheData)+COALESCE(LENGTH(l.ExtraData),0)),0)
+		 FROM SequencedLeafData s,LeafData l
+		 WHERE s.TreeId=? AND s.SequenceNumber>=? AND s.SequenceNumber<? AND l.TreeId=s.TreeId AND l.LeafHash=s.LeafHash`
+const countNodeStatsSql string = `SELECT COUNT(*),COALESCE(SUM(LENGTH(SubtreeId)+LENGTH(Nodes)),0)
+		 FROM Subtree WHERE TreeId=? AND SubtreeRevision>=? AND SubtreeRevision<?`
+const countRootStatsSql string = `SELECT COUNT(*),COALESCE(SUM(LENGTH(RootHash)+LENGTH(RootSignature)+COALESCE(LENGTH(RootMetadata),0)),0)
+		 FROM TreeHead WHERE TreeId=? AND TreeRevision>=? AND TreeRevision<?`
+
+// statsTables are the tables reported by GetTreeStorageStats in the order they're returned.
+// Leaves are counted by sequence number and nodes and roots by tree revision.
+var statsTables = []struct {
+	name     string
+	countSql string
+}{
+	{name: storage.LeafTableStats, countSql: countLeafStatsSql},
+	{name: storage.NodeTableStats, countSql: countNodeStatsSql},
+	{name: storage.RootTableStats, countSql: countRootStatsSql},
+}
+
+// GetTreeStorageStats implements storage.TreeStatsReader. Only rows covered by the latest
+// signed root are counted, so leaves and subtrees being written by concurrent sequencers are
+// picked up by a later call once they've been integrated.
+func (t *logTX) GetTreeStorageStats() ([]*trillian.TableStorageStats, error) {
+	states, err := t.readTreeStats()
+
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := t.LatestSignedLogRoot()
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixNano()
+	ret := make([]*trillian.TableStorageStats, 0, len(statsTables))
+
+	for _, table := range statsTables {
+		state := states[table.name]
+		end := state.Watermark
+
+		// If there's no root yet there's nothing to count
+		if len(root.RootHash) > 0 {
+			if table.name == storage.LeafTableStats {
+				end = root.TreeSize
+			} else {
+				end = root.TreeRevision + 1
+			}
+		}
+
+		var rows, bytes int64
+
+		if end > state.Watermark {
+			if err := t.tx.QueryRow(table.countSql, t.ls.logID.TreeID, state.Watermark, end).Scan(&rows, &bytes); err != nil {
+				glog.Warningf("Failed to count %s stats: %s", table.name, err)
+				return nil, err
+			}
+		}
+
+		state.Update(rows, bytes, end, now)
+
+		if _, err := t.tx.Exec(replaceTreeStatsSql, t.ls.logID.TreeID, table.name,
+			state.Current.RowCount, state.Current.ByteSize, state.Watermark, state.Current.TimeNanos,
+			state.Base.RowCount, state.Base.ByteSize, state.Base.TimeNanos,
+			state.NextBase.RowCount, state.NextBase.ByteSize, state.NextBase.TimeNanos); err != nil {
+			glog.Warningf("Failed to store %s stats: %s", table.name, err)
+			return nil, err
+		}
+
+		ret = append(ret, state.Proto(table.name))
+	}
+
+	return ret, nil
+}
+
+// readTreeStats returns the stored stats of the log's tables keyed by table name. Tables that
+// have never been counted are left out. SQLite only allows one writer at a time so the rows
+// don't need to be locked.
+func (t *logTX) readTreeStats() (map[string]storage.TableStatsState, error) {
+	rows, err := t.tx.Query(selectTreeStatsSql, t.ls.logID.TreeID)
+
+	if err != nil {
+		glog.Warningf("Failed to read tree stats: %s", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+	states := make(map[string]storage.TableStatsState)
+
+	for rows.Next() {
+		var name string
+		var s storage.TableStatsState
+
+		if err := rows.Scan(&name, &s.Current.RowCount, &s.Current.ByteSize, &s.Watermark, &s.Current.TimeNanos,
+			&s.Base.RowCount, &s.Base.ByteSize, &s.Base.TimeNanos,
+			&s.NextBase.RowCount, &s.NextBase.ByteSize, &s.NextBase.TimeNanos); err != nil {
+			glog.Warningf("Failed to scan tree stats: %s", err)
+			return nil, err
+		}
+
+		states[name] = s
+	}
+
+	return states, rows.Err()
+}
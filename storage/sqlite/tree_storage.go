@@ -0,0 +1,551 @@
+// Package sqlite is a storage implementation backed by a single SQLite database file. It
+// needs no separate database server so it's meant for single node deployments, demos and
+// tests. The database is opened in WAL mode, so reads don't block the writer, and writers
+// wait for each other for up to a busy timeout rather than failing straight away.
+//
+// Leaf data encryption, ExtraData blob storage and table partitioning are only available
+// with MySQL storage.
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	terrors "github.com/google/trillian/errors"
+	"github.com/google/trillian/storage"
+	"github.com/google/trillian/storage/cache"
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// driverName is registered with settings that must be applied to every connection, the pool
+// opens new connections whenever it needs them
+const driverName = "sqlite3_trillian"
+
+// DefaultBusyTimeout is how long a transaction waits for another one holding the database
+// lock before it fails, if no other timeout is given.
+const DefaultBusyTimeout = 5 * time.Second
+
+// These statements are fixed
+const insertSubtreeMultiSql string = `INSERT INTO Subtree(TreeId, SubtreeId, Nodes, SubtreeRevision) ` + placeholderSql
+const insertTreeHeadSql string = `INSERT INTO TreeHead(TreeId,TreeHeadTimestamp,TreeSize,RootHash,TreeRevision,RootSignature,RootMetadata)
+		 VALUES(?,?,?,?,?,?,?)`
+const selectTreeRevisionAtSizeSql string = "SELECT TreeRevision FROM TreeHead WHERE TreeId=? AND TreeSize=? ORDER BY TreeRevision DESC LIMIT 1"
+const selectActiveLogsSql string = "SELECT TreeId, KeyId FROM Trees WHERE TreeType='LOG'"
+const selectActiveLogsWithUnsequencedSql string = `SELECT DISTINCT t.TreeId, t.KeyId FROM Trees t
+		 INNER JOIN Unsequenced u ON t.TreeId=u.TreeId WHERE t.TreeType='LOG'`
+const insertTreeSql string = `INSERT INTO Trees(TreeId, KeyId, TreeType, LeafHasherType, TreeHasherType, TreeDepth)
+		 VALUES(?, ?, ?, ?, ?, ?)`
+
+const selectSubtreeSql string = `SELECT x.SubtreeId, x.MaxRevision, Subtree.Nodes
+				 FROM (SELECT n.SubtreeId, max(n.SubtreeRevision) AS MaxRevision
+							 FROM Subtree n
+							 WHERE n.SubtreeId IN (` + placeholderSql + `) AND
+										 n.TreeId = ? AND
+										 n.SubtreeRevision <= ?
+							 GROUP BY n.SubtreeId) AS x
+				 INNER JOIN Subtree ON Subtree.SubtreeId = x.SubtreeId AND
+														Subtree.SubtreeRevision = x.MaxRevision AND
+														Subtree.TreeId = ?`
+
+const placeholderSql string = "<placeholder>"
+
+// maxSubtreesPerInsert keeps the number of bound parameters in a subtree insert within the
+// limit of older SQLite versions, which is 999
+const maxSubtreesPerInsert = 200
+
+var registerDriver sync.Once
+
+// sqliteTreeStorage is shared between the log and map storage implementations, and contains
+// functionality which is common to both.
+type sqliteTreeStorage struct {
+	treeID          int64
+	db              *sql.DB
+	hashSizeBytes   int
+	populateSubtree storage.PopulateSubtreeFunc
+
+	// Must hold the mutex before manipulating the statement map. These maps are from the
+	// number of placeholder '?' in the query to the statement that should be used.
+	statementMutex sync.Mutex
+	statements     map[string]map[int]*sql.Stmt
+}
+
+// openDB opens the database at dbPath, creating it and the tables if they don't exist.
+// Transactions take the write lock when they begin rather than when they first write, so
+// two transactions can't both read and then deadlock trying to write. Waiting for the lock
+// is bounded by busyTimeout.
+func openDB(dbPath string, busyTimeout time.Duration) (*sql.DB, error) {
+	if len(dbPath) == 0 || strings.ContainsAny(dbPath, "?#") {
+		return nil, fmt.Errorf("invalid SQLite database path: %q", dbPath)
+	}
+
+	registerDriver.Do(func() {
+		sql.Register(driverName, &sqlite3.SQLiteDriver{
+			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+				// Foreign keys are off by default. WAL mode is stored in the file but is
+				// set here so that new databases get it.
+				for _, pragma := range []string{"PRAGMA foreign_keys = ON", "PRAGMA journal_mode = WAL"} {
+					if _, err := conn.Exec(pragma, nil); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+		})
+	})
+
+	dsn := fmt.Sprintf("file:%s?_busy_timeout=%d&_txlock=immediate", dbPath, busyTimeout/time.Millisecond)
+	db, err := sql.Open(driverName, dsn)
+
+	if err != nil {
+		glog.Warningf("Could not open SQLite database %s: %s", dbPath, err)
+		return nil, err
+	}
+
+	if _, err := db.Exec(schemaSql); err != nil {
+		glog.Warningf("Failed to create tables in SQLite database %s: %s", dbPath, err)
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func newTreeStorage(treeID int64, dbPath string, busyTimeout time.Duration, hashSizeBytes int, populateSubtree storage.PopulateSubtreeFunc) (sqliteTreeStorage, error) {
+	db, err := openDB(dbPath, busyTimeout)
+	if err != nil {
+		return sqliteTreeStorage{}, err
+	}
+
+	s := sqliteTreeStorage{
+		treeID:          treeID,
+		db:              db,
+		hashSizeBytes:   hashSizeBytes,
+		populateSubtree: populateSubtree,
+		statements:      make(map[string]map[int]*sql.Stmt),
+	}
+
+	return s, nil
+}
+
+// CreateLogTree adds a log to the database at dbPath, which is created if it doesn't exist.
+// The log uses the default tree properties.
+func CreateLogTree(dbPath string, id trillian.LogID) error {
+	return CreateLogTreeWithDepth(dbPath, id, storage.DefaultLogTreeDepth)
+}
+
+// CreateLogTreeWithDepth is like CreateLogTree but the log's tree has the given depth, which
+// limits how many leaves it can hold. The depth must be valid for a log, see
+// storage.ValidateLogTreeDepth.
+func CreateLogTreeWithDepth(dbPath string, id trillian.LogID, treeDepth int) error {
+	return CreateLogTreeWithHashAlgorithm(dbPath, id, treeDepth, trillian.HashAlgorithm_SHA256)
+}
+
+// CreateLogTreeWithHashAlgorithm is like CreateLogTreeWithDepth but the log's leaves and nodes
+// are hashed with alg rather than SHA-256.
+func CreateLogTreeWithHashAlgorithm(dbPath string, id trillian.LogID, treeDepth int, alg trillian.HashAlgorithm) error {
+	if err := storage.ValidateLogTreeDepth(treeDepth); err != nil {
+		return err
+	}
+
+	// The hasher type columns hold the names of the algorithms
+	if _, ok := hashAlgorithms[alg.String()]; !ok {
+		return fmt.Errorf("unsupported hash algorithm for a tree: %v", alg)
+	}
+
+	return createTree(dbPath, id.TreeID, id.LogID, "LOG", treeDepth, alg.String())
+}
+
+// CreateMapTree adds a map to the database at dbPath, which is created if it doesn't exist.
+func CreateMapTree(dbPath string, id trillian.MapID) error {
+	// Maps don't use the tree depth, their depth is the size of their key hashes
+	return createTree(dbPath, id.TreeID, id.MapID, "MAP", storage.DefaultLogTreeDepth, "SHA256")
+}
+
+func createTree(dbPath string, treeID int64, keyID []byte, treeType string, treeDepth int, hasherType string) error {
+	db, err := openDB(dbPath, DefaultBusyTimeout)
+
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	if _, err := db.Exec(insertTreeSql, treeID, keyID, treeType, hasherType, hasherType, treeDepth); err != nil {
+		glog.Warningf("Failed to create tree %d: %s", treeID, err)
+		return err
+	}
+
+	return nil
+}
+
+// expandPlaceholderSql expands an sql statement by adding a specified number of '?'
+// placeholder slots. At most one placeholder will be expanded.
+func expandPlaceholderSql(sql string, num int, first, rest string) string {
+	if num <= 0 {
+		panic(fmt.Errorf("Trying to expand SQL placeholder with <= 0 parameters: %s", sql))
+	}
+
+	parameters := first + strings.Repeat(","+rest, num-1)
+
+	return strings.Replace(sql, placeholderSql, parameters, 1)
+}
+
+func decodeSignedTimestamp(signedEntryTimestampBytes []byte) (trillian.SignedEntryTimestamp, error) {
+	var signedEntryTimestamp trillian.SignedEntryTimestamp
+
+	if err := proto.Unmarshal(signedEntryTimestampBytes, &signedEntryTimestamp); err != nil {
+		glog.Warningf("Failed to decode SignedTimestamp: %s", err)
+		return trillian.SignedEntryTimestamp{}, err
+	}
+
+	return signedEntryTimestamp, nil
+}
+
+func encodeSignedTimestamp(signedEntryTimestamp trillian.SignedEntryTimestamp) ([]byte, error) {
+	marshalled, err := proto.Marshal(&signedEntryTimestamp)
+
+	if err != nil {
+		glog.Warningf("Failed to encode SignedTimestamp: %s", err)
+		return nil, err
+	}
+
+	return marshalled, err
+}
+
+// Close closes the database, which also closes the prepared statements.
+func (m *sqliteTreeStorage) Close() error {
+	return m.db.Close()
+}
+
+// getStmt creates and caches sql.Stmt structs based on the passed in statement
+// and number of bound arguments.
+func (m *sqliteTreeStorage) getStmt(statement string, num int, first, rest string) (*sql.Stmt, error) {
+	m.statementMutex.Lock()
+	defer m.statementMutex.Unlock()
+
+	if m.statements[statement] != nil {
+		if m.statements[statement][num] != nil {
+			return m.statements[statement][num], nil
+		}
+	} else {
+		m.statements[statement] = make(map[int]*sql.Stmt)
+	}
+
+	s, err := m.db.Prepare(expandPlaceholderSql(statement, num, first, rest))
+
+	if err != nil {
+		glog.Warningf("Failed to prepare statement %d: %s", num, err)
+		return nil, err
+	}
+
+	m.statements[statement][num] = s
+
+	return s, nil
+}
+
+func (m *sqliteTreeStorage) getSubtreeStmt(num int) (*sql.Stmt, error) {
+	return m.getStmt(selectSubtreeSql, num, "?", "?")
+}
+
+func (m *sqliteTreeStorage) setSubtreeStmt(num int) (*sql.Stmt, error) {
+	return m.getStmt(insertSubtreeMultiSql, num, "VALUES(?, ?, ?, ?)", "(?, ?, ?, ?)")
+}
+
+func (m *sqliteTreeStorage) beginTreeTx() (treeTX, error) {
+	t, err := m.db.Begin()
+	if err != nil {
+		glog.Warningf("Could not start tree TX: %s", err)
+		return treeTX{}, err
+	}
+	return treeTX{
+		tx:            t,
+		ts:            m,
+		subtreeCache:  cache.NewSubtreeCache(m.populateSubtree),
+		writeRevision: -1,
+	}, nil
+}
+
+type treeTX struct {
+	closed        bool
+	tx            *sql.Tx
+	ts            *sqliteTreeStorage
+	subtreeCache  cache.SubtreeCache
+	writeRevision int64
+}
+
+func (t *treeTX) getSubtree(treeRevision int64, nodeID storage.NodeID) (*storage.SubtreeProto, error) {
+	subtrees, err := t.getSubtrees(treeRevision, []storage.NodeID{nodeID})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The InternalNodes cache is nil here, but the SubtreeCache (which called
+	// this method) will re-populate it.
+	return subtrees[string(nodeID.Path[:nodeID.PrefixLenBits/8])], nil
+}
+
+// getSubtrees fetches the subtrees with the given IDs, each at the latest revision no later
+// than treeRevision, with one query. The results are keyed by subtree prefix and subtrees
+// that aren't stored are missing.
+func (t *treeTX) getSubtrees(treeRevision int64, nodeIDs []storage.NodeID) (map[string]*storage.SubtreeProto, error) {
+	subtrees := make(map[string]*storage.SubtreeProto)
+
+	if len(nodeIDs) == 0 {
+		return subtrees, nil
+	}
+
+	tmpl, err := t.ts.getSubtreeStmt(len(nodeIDs))
+	if err != nil {
+		return nil, err
+	}
+	stx := t.tx.Stmt(tmpl)
+	defer stx.Close()
+
+	args := make([]interface{}, 0, len(nodeIDs)+3)
+	for _, nodeID := range nodeIDs {
+		if nodeID.PrefixLenBits%8 != 0 {
+			return nil, fmt.Errorf("invalid subtree ID - not multiple of 8: %d", nodeID.PrefixLenBits)
+		}
+		args = append(args, interface{}(nodeID.Path[:nodeID.PrefixLenBits/8]))
+	}
+	args = append(args, interface{}(t.ts.treeID))
+	args = append(args, interface{}(treeRevision))
+	args = append(args, interface{}(t.ts.treeID))
+
+	rows, err := stx.Query(args...)
+	if err != nil {
+		glog.Warningf("Failed to get merkle subtrees: %s", err)
+		return nil, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var subtreeIDBytes []byte
+		var subtreeRev int64
+		var nodesRaw []byte
+		if err := rows.Scan(&subtreeIDBytes, &subtreeRev, &nodesRaw); err != nil {
+			glog.Warningf("Failed to scan merkle subtree: %s", err)
+			return nil, err
+		}
+		var subtree storage.SubtreeProto
+		if err := proto.Unmarshal(nodesRaw, &subtree); err != nil {
+			return nil, err
+		}
+		if subtree.Prefix == nil {
+			subtree.Prefix = []byte{}
+		}
+		subtrees[string(subtreeIDBytes)] = &subtree
+	}
+
+	return subtrees, rows.Err()
+}
+
+// getMerkleNodesInOneQuery returns the same nodes as GetMerkleNodes but reads all the
+// subtrees that hold them with a single query, rather than a query for each subtree that
+// isn't already cached.
+func (t *treeTX) getMerkleNodesInOneQuery(treeRevision int64, nodeIDs []storage.NodeID) ([]storage.Node, error) {
+	subtreeIDs := make([]storage.NodeID, 0, len(nodeIDs))
+	seen := make(map[string]bool)
+
+	for _, nodeID := range nodeIDs {
+		subtreeID := cache.SubtreeID(nodeID)
+		prefix := string(subtreeID.Path[:subtreeID.PrefixLenBits/8])
+
+		if !seen[prefix] {
+			seen[prefix] = true
+			subtreeIDs = append(subtreeIDs, subtreeID)
+		}
+	}
+
+	subtrees, err := t.getSubtrees(treeRevision, subtreeIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]storage.Node, 0, len(nodeIDs))
+
+	// The cache populates the internal nodes of the subtrees as it takes them
+	for _, nodeID := range nodeIDs {
+		h, err := t.subtreeCache.GetNodeHash(
+			nodeID,
+			func(n storage.NodeID) (*storage.SubtreeProto, error) {
+				return subtrees[string(n.Path[:n.PrefixLenBits/8])], nil
+			})
+		if err != nil {
+			return nil, err
+		}
+		if h != nil {
+			ret = append(ret, storage.Node{
+				NodeID: nodeID,
+				Hash:   h,
+			})
+		}
+	}
+
+	return ret, nil
+}
+
+func (t *treeTX) storeSubtrees(subtrees []*storage.SubtreeProto) error {
+	if len(subtrees) == 0 {
+		glog.Warning("attempted to store 0 subtrees...")
+		return nil
+	}
+
+	for len(subtrees) > maxSubtreesPerInsert {
+		if err := t.storeSubtrees(subtrees[:maxSubtreesPerInsert]); err != nil {
+			return err
+		}
+
+		subtrees = subtrees[maxSubtreesPerInsert:]
+	}
+
+	args := make([]interface{}, 0, len(subtrees)*4)
+	for _, s := range subtrees {
+		if s.Prefix == nil {
+			panic(fmt.Errorf("nil prefix on %v", s))
+		}
+		// Ensure we're not storing the internal nodes, since we'll just recalculate
+		// them when we read this subtree back.
+		s.InternalNodes = nil
+		subtreeBytes, err := proto.Marshal(s)
+		if err != nil {
+			return err
+		}
+		args = append(args, t.ts.treeID)
+		args = append(args, s.Prefix)
+		args = append(args, subtreeBytes)
+		args = append(args, t.writeRevision)
+	}
+
+	tmpl, err := t.ts.setSubtreeStmt(len(subtrees))
+	if err != nil {
+		return err
+	}
+	stx := t.tx.Stmt(tmpl)
+	defer stx.Close()
+
+	if _, err := stx.Exec(args...); err != nil {
+		glog.Warningf("Failed to set merkle subtrees: %s", err)
+		return err
+	}
+
+	return nil
+}
+
+func checkResultOkAndRowCountIs(res sql.Result, err error, count int64) error {
+	// The Exec() might have just failed
+	if err != nil {
+		return err
+	}
+
+	// Otherwise we have to look at the result of the operation
+	rowsAffected, rowsError := res.RowsAffected()
+
+	if rowsError != nil {
+		return rowsError
+	}
+
+	if rowsAffected != count {
+		return errors.New(fmt.Sprintf("Expected %d row(s) to be affected but saw: %d", count,
+			rowsAffected))
+	}
+
+	return nil
+}
+
+// GetTreeRevisionAtSize returns the max node version for a tree at a particular size.
+// It is an error to request tree sizes larger than the currently published tree size.
+// This only works for sizes where there is a stored tree head.
+func (t *treeTX) GetTreeRevisionAtSize(treeSize int64) (int64, error) {
+	// Negative size is not sensible and a zero sized tree has no nodes so no revisions
+	if treeSize <= 0 {
+		return 0, terrors.Errorf(terrors.InvalidRange, "Invalid tree size: %d", treeSize)
+	}
+
+	var treeRevision int64
+	err := t.tx.QueryRow(selectTreeRevisionAtSizeSql, t.ts.treeID, treeSize).Scan(&treeRevision)
+
+	if err == sql.ErrNoRows {
+		return 0, terrors.Errorf(terrors.NotFound, "no tree head stored at size: %d", treeSize)
+	}
+
+	return treeRevision, err
+}
+
+func (t *treeTX) GetMerkleNodes(treeRevision int64, nodeIDs []storage.NodeID) ([]storage.Node, error) {
+	ret := make([]storage.Node, 0, len(nodeIDs))
+
+	for _, nodeID := range nodeIDs {
+		h, err := t.subtreeCache.GetNodeHash(
+			nodeID,
+			func(n storage.NodeID) (*storage.SubtreeProto, error) {
+				return t.getSubtree(treeRevision, n)
+			})
+		if err != nil {
+			return nil, err
+		}
+		if h != nil {
+			ret = append(ret, storage.Node{
+				NodeID: nodeID,
+				Hash:   h,
+			})
+		}
+	}
+
+	return ret, nil
+}
+
+func (t *treeTX) SetMerkleNodes(nodes []storage.Node) error {
+	for _, n := range nodes {
+		err := t.subtreeCache.SetNodeHash(n.NodeID, n.Hash,
+			func(nID storage.NodeID) (*storage.SubtreeProto, error) {
+				return t.getSubtree(t.writeRevision, nID)
+			})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *treeTX) Commit() error {
+	if t.writeRevision > -1 {
+		if err := t.subtreeCache.Flush(t.storeSubtrees); err != nil {
+			glog.Warningf("TX commit flush error: %s", err)
+			t.Rollback()
+			return err
+		}
+	}
+	t.closed = true
+	err := t.tx.Commit()
+
+	if err != nil {
+		glog.Warningf("TX commit error: %s", err)
+	}
+
+	return err
+}
+
+func (t *treeTX) Rollback() error {
+	t.closed = true
+	err := t.tx.Rollback()
+
+	if err != nil {
+		glog.Warningf("TX rollback error: %s", err)
+	}
+
+	return err
+}
+
+func (t *treeTX) IsOpen() bool {
+	return !t.closed
+}
@@ -11,6 +11,7 @@ It is generated from these files:
 It has these top-level messages:
 	NodeIDProto
 	SubtreeProto
+	CompactTreeProto
 */
 package storage
 
@@ -75,32 +76,51 @@ func (m *SubtreeProto) GetInternalNodes() map[string][]byte {
 	return nil
 }
 
+// CompactTreeProto is the state of a log's compact Merkle tree at a tree size. It's stored
+// after each sequenced batch so the tree can be restored without fetching its nodes.
+type CompactTreeProto struct {
+	// The number of leaves in the tree
+	TreeSize int64 `protobuf:"varint,1,opt,name=tree_size,json=treeSize" json:"tree_size,omitempty"`
+	// The hashes of the dangling left hand nodes, indexed by level with the leaf level first.
+	// Levels that don't have a node at this tree size are empty.
+	Nodes [][]byte `protobuf:"bytes,2,rep,name=nodes,proto3" json:"nodes,omitempty"`
+}
+
+func (m *CompactTreeProto) Reset()                    { *m = CompactTreeProto{} }
+func (m *CompactTreeProto) String() string            { return proto.CompactTextString(m) }
+func (*CompactTreeProto) ProtoMessage()               {}
+func (*CompactTreeProto) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
 func init() {
 	proto.RegisterType((*NodeIDProto)(nil), "storage.NodeIDProto")
 	proto.RegisterType((*SubtreeProto)(nil), "storage.SubtreeProto")
+	proto.RegisterType((*CompactTreeProto)(nil), "storage.CompactTreeProto")
 }
 
 func init() { proto.RegisterFile("github.com/google/trillian/storage/storage.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 304 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x94, 0x91, 0xdf, 0x4a, 0xc3, 0x30,
-	0x14, 0xc6, 0xd9, 0x5f, 0xdd, 0xd9, 0xa6, 0x12, 0x44, 0xca, 0xbc, 0x99, 0xbb, 0x90, 0x5e, 0xb5,
-	0xa2, 0x37, 0xce, 0x2b, 0x11, 0x05, 0x0b, 0x43, 0xa5, 0x3e, 0x40, 0x49, 0xdd, 0xb1, 0x0d, 0xc6,
-	0xa4, 0xa4, 0xe9, 0x70, 0xaf, 0xeb, 0x93, 0xd8, 0x26, 0x19, 0x14, 0xf4, 0xc6, 0xab, 0x9c, 0xef,
-	0xcb, 0x39, 0xbf, 0xc3, 0x97, 0xc0, 0x45, 0xc6, 0x74, 0x5e, 0xa5, 0xc1, 0x9b, 0xfc, 0x0c, 0x33,
-	0x29, 0x33, 0x8e, 0xa1, 0x56, 0x8c, 0x73, 0x46, 0x45, 0x58, 0x6a, 0xa9, 0x68, 0x86, 0xbb, 0x33,
-	0x28, 0x94, 0xd4, 0x92, 0xec, 0x39, 0xb9, 0x88, 0x60, 0xfc, 0x24, 0xd7, 0x18, 0xdd, 0xbf, 0x18,
-	0x9f, 0x40, 0xbf, 0xa0, 0x3a, 0xf7, 0x3a, 0xf3, 0x8e, 0x3f, 0x89, 0x4d, 0x4d, 0xce, 0xe1, 0xb0,
-	0x50, 0xf8, 0xce, 0xbe, 0x12, 0x8e, 0x22, 0x49, 0x99, 0x2e, 0xbd, 0x6e, 0x7d, 0x3d, 0x88, 0xa7,
-	0xd6, 0x5e, 0xa1, 0xb8, 0xab, 0xcd, 0xc5, 0x77, 0x17, 0x26, 0xaf, 0x55, 0xaa, 0x15, 0xa2, 0x85,
-	0x9d, 0xc0, 0xd0, 0x76, 0x38, 0x9c, 0x53, 0xe4, 0x18, 0x06, 0x6b, 0x2c, 0xea, 0x2d, 0x16, 0x63,
-	0x05, 0x39, 0x85, 0x91, 0x92, 0x52, 0x27, 0x39, 0x2d, 0x73, 0xaf, 0x67, 0x06, 0xf6, 0x1b, 0xe3,
-	0xb1, 0xd6, 0x64, 0x09, 0x43, 0x8e, 0x74, 0x83, 0xa5, 0xd7, 0x9f, 0xf7, 0xfc, 0xf1, 0xe5, 0x59,
-	0xb0, 0xcb, 0xd3, 0xde, 0x18, 0xac, 0x4c, 0xcf, 0x83, 0xd0, 0x6a, 0x1b, 0xbb, 0x01, 0xf2, 0x0c,
-	0x07, 0x4c, 0x68, 0x54, 0x82, 0xf2, 0x44, 0xd4, 0x51, 0x4b, 0x6f, 0x60, 0x10, 0xfe, 0xdf, 0x88,
-	0xc8, 0xf5, 0x36, 0xaf, 0xe2, 0x48, 0x53, 0xd6, 0xf6, 0x66, 0x4b, 0x18, 0xb7, 0xf6, 0x90, 0x23,
-	0xe8, 0x7d, 0xe0, 0xd6, 0x44, 0x1c, 0xc5, 0x4d, 0xd9, 0xe4, 0xdb, 0x50, 0x5e, 0xa1, 0xc9, 0x37,
-	0x89, 0xad, 0xb8, 0xe9, 0x5e, 0x77, 0x66, 0xb7, 0x40, 0x7e, 0xf3, 0xff, 0x43, 0x48, 0x87, 0xe6,
-	0xff, 0xae, 0x7e, 0x02, 0x00, 0x00, 0xff, 0xff, 0xce, 0x67, 0x36, 0x28, 0xf3, 0x01, 0x00, 0x00,
+	// 338 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x95, 0x92, 0x4f, 0x4f, 0xc2, 0x30,
+	0x18, 0xc6, 0xc3, 0x06, 0x08, 0x05, 0x94, 0x34, 0xc6, 0x2c, 0x78, 0x41, 0x0e, 0x86, 0xd3, 0x30,
+	0x7a, 0x11, 0x4f, 0x46, 0x25, 0x91, 0x84, 0xa8, 0x19, 0xde, 0x97, 0x0e, 0x5e, 0xb7, 0xc6, 0xd2,
+	0x2e, 0x5d, 0x21, 0xe2, 0xc7, 0xf5, 0x93, 0xd8, 0x3f, 0xc3, 0x90, 0xe8, 0xc5, 0x53, 0xfb, 0x3c,
+	0x7d, 0xfb, 0x7b, 0xfb, 0xbc, 0x29, 0xba, 0x48, 0xa9, 0xca, 0xd6, 0x49, 0xb8, 0x10, 0xab, 0x51,
+	0x2a, 0x44, 0xca, 0x60, 0xa4, 0x24, 0x65, 0x8c, 0x12, 0x3e, 0x2a, 0x94, 0x90, 0x24, 0x85, 0xdd,
+	0x1a, 0xe6, 0x52, 0x28, 0x81, 0x0f, 0x4a, 0x39, 0x98, 0xa2, 0xd6, 0x93, 0x58, 0xc2, 0xf4, 0xe1,
+	0xc5, 0xfa, 0x18, 0x55, 0x73, 0xa2, 0xb2, 0xa0, 0xd2, 0xaf, 0x0c, 0xdb, 0x91, 0xdd, 0xe3, 0x73,
+	0x74, 0x94, 0x4b, 0x78, 0xa3, 0x1f, 0x31, 0x03, 0x1e, 0x27, 0x54, 0x15, 0x81, 0xa7, 0x8f, 0x6b,
+	0x51, 0xc7, 0xd9, 0x33, 0xe0, 0x77, 0xda, 0x1c, 0x7c, 0x79, 0xa8, 0x3d, 0x5f, 0x27, 0x4a, 0x02,
+	0x38, 0xd8, 0x09, 0xaa, 0xbb, 0x8a, 0x12, 0x57, 0x2a, 0x7c, 0x8c, 0x6a, 0x4b, 0xc8, 0x75, 0x17,
+	0x87, 0x71, 0x02, 0x9f, 0xa2, 0xa6, 0x14, 0x42, 0xc5, 0x19, 0x29, 0xb2, 0xc0, 0xb7, 0x17, 0x1a,
+	0xc6, 0x78, 0xd4, 0x1a, 0x8f, 0x51, 0x9d, 0x01, 0xd9, 0x40, 0x11, 0x54, 0xfb, 0xfe, 0xb0, 0x75,
+	0x79, 0x16, 0xee, 0xf2, 0xec, 0x77, 0x0c, 0x67, 0xb6, 0x66, 0xc2, 0x95, 0xdc, 0x46, 0xe5, 0x05,
+	0xfc, 0x8c, 0x0e, 0x29, 0x57, 0x20, 0x39, 0x61, 0x31, 0xd7, 0x51, 0x8b, 0xa0, 0x66, 0x11, 0xc3,
+	0xbf, 0x11, 0xd3, 0xb2, 0xd6, 0x4c, 0xa5, 0x24, 0x75, 0xe8, 0xbe, 0xd7, 0x1b, 0xa3, 0xd6, 0x5e,
+	0x1f, 0xdc, 0x45, 0xfe, 0x3b, 0x6c, 0x6d, 0xc4, 0x66, 0x64, 0xb6, 0x26, 0xdf, 0x86, 0xb0, 0x35,
+	0xd8, 0x7c, 0xed, 0xc8, 0x89, 0x1b, 0xef, 0xba, 0xd2, 0xbb, 0x45, 0xf8, 0x37, 0xff, 0x3f, 0x84,
+	0xc1, 0x04, 0x75, 0xef, 0xc5, 0x2a, 0x27, 0x0b, 0xf5, 0xfa, 0x33, 0x67, 0x3d, 0x39, 0xf3, 0xfe,
+	0xb8, 0xa0, 0x9f, 0x60, 0x29, 0x7e, 0xd4, 0x30, 0xc6, 0x5c, 0x6b, 0x83, 0x72, 0xa9, 0x3d, 0x9d,
+	0x5a, 0xa3, 0xac, 0x48, 0xea, 0xf6, 0x1b, 0x5c, 0x7d, 0x03, 0x26, 0xd5, 0x14, 0x9d, 0x3a, 0x02,
+	0x00, 0x00,
 }
@@ -0,0 +1,845 @@
+package testsuite
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	terrors "github.com/google/trillian/errors"
+	"github.com/google/trillian/storage"
+)
+
+type logStorageTest struct {
+	name string
+	fn   func(t *testing.T, s storage.LogStorage)
+}
+
+var logStorageTests = []logStorageTest{
+	{"OpenStateCommit", testLogOpenStateCommit},
+	{"OpenStateRollback", testLogOpenStateRollback},
+	{"LatestSignedLogRootNoneWritten", testLatestSignedLogRootNoneWritten},
+	{"LatestSignedLogRoot", testLatestSignedLogRoot},
+	{"LatestSignedLogRootWithMetadata", testLatestSignedLogRootWithMetadata},
+	{"DuplicateSignedLogRoot", testDuplicateSignedLogRoot},
+	{"LogRootUpdate", testLogRootUpdate},
+	{"RollbackDiscardsLogRoot", testRollbackDiscardsLogRoot},
+	{"WriteRevisionFollowsRoot", testLogWriteRevisionFollowsRoot},
+	{"GetTreeRevisionAtSize", testGetTreeRevisionAtSize},
+	{"NodeRoundTrip", testLogNodeRoundTrip},
+	{"QueueLeavesMissingSignatureRejected", testQueueLeavesMissingSignatureRejected},
+	{"DequeueLeavesNoneQueued", testDequeueLeavesNoneQueued},
+	{"DequeueLeaves", testDequeueLeaves},
+	{"DequeueLeavesRespectsLimit", testDequeueLeavesRespectsLimit},
+	{"DequeueLeavesRollback", testDequeueLeavesRollback},
+	{"DequeueLeavesByPriority", testDequeueLeavesByPriority},
+	{"SequencedLeavesRoundTrip", testSequencedLeavesRoundTrip},
+	{"GetLeavesByHashNotPresent", testGetLeavesByHashNotPresent},
+	{"GetLeavesByIndexNotPresent", testGetLeavesByIndexNotPresent},
+	{"SnapshotSeesCommittedRoot", testLogSnapshotSeesCommittedRoot},
+	{"TreeStorageStats", testTreeStorageStats},
+}
+
+// RunLogStorageTests runs all the LogStorage conformance tests. The factory is called
+// once per test so each test starts with an empty log.
+func RunLogStorageTests(t *testing.T, f LogStorageFactory) {
+	// TODO(Martin2112): Use subtests when we can depend on Go 1.7.
+	for _, test := range logStorageTests {
+		t.Logf("Running log storage conformance test: %s", test.name)
+		test.fn(t, f(t))
+	}
+}
+
+func testLogOpenStateCommit(t *testing.T, s storage.LogStorage) {
+	tx := beginLogTx(s, t)
+
+	if !tx.IsOpen() {
+		t.Fatalf("Transaction should be open on creation")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	if tx.IsOpen() {
+		t.Fatalf("Transaction should be closed after commit")
+	}
+}
+
+func testLogOpenStateRollback(t *testing.T, s storage.LogStorage) {
+	tx := beginLogTx(s, t)
+
+	if !tx.IsOpen() {
+		t.Fatalf("Transaction should be open on creation")
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Failed to rollback: %v", err)
+	}
+	if tx.IsOpen() {
+		t.Fatalf("Transaction should be closed after rollback")
+	}
+}
+
+func testLatestSignedLogRootNoneWritten(t *testing.T, s storage.LogStorage) {
+	tx := beginLogTx(s, t)
+	defer tx.Rollback()
+
+	root, err := tx.LatestSignedLogRoot()
+
+	if err != nil {
+		t.Fatalf("Failed to read an empty log root: %v", err)
+	}
+
+	if len(root.LogId) != 0 || len(root.RootHash) != 0 || root.Signature != nil {
+		t.Fatalf("Read a root with contents when it should be empty: %v", root)
+	}
+}
+
+func testLatestSignedLogRoot(t *testing.T, s storage.LogStorage) {
+	tx := beginLogTx(s, t)
+	root := createLogRoot(tx, 98765, 16)
+
+	if err := tx.StoreSignedLogRoot(root); err != nil {
+		t.Fatalf("Failed to store signed root: %v", err)
+	}
+
+	commitLogTx(tx, t)
+
+	tx2 := beginLogTx(s, t)
+	defer tx2.Rollback()
+	root2, err := tx2.LatestSignedLogRoot()
+
+	if err != nil {
+		t.Fatalf("Failed to read back new log root: %v", err)
+	}
+
+	if !logRootsEqual(root, root2) {
+		t.Fatalf("Root round trip failed: <%v> and: <%v>", root, root2)
+	}
+}
+
+func testLatestSignedLogRootWithMetadata(t *testing.T, s storage.LogStorage) {
+	tx := beginLogTx(s, t)
+	root := createLogRoot(tx, 98765, 16)
+	root.Metadata = []byte("epoch 7")
+
+	if err := tx.StoreSignedLogRoot(root); err != nil {
+		t.Fatalf("Failed to store signed root: %v", err)
+	}
+
+	commitLogTx(tx, t)
+
+	tx2 := beginLogTx(s, t)
+	defer tx2.Rollback()
+	root2, err := tx2.LatestSignedLogRoot()
+
+	if err != nil {
+		t.Fatalf("Failed to read back new log root: %v", err)
+	}
+
+	if !logRootsEqual(root, root2) {
+		t.Fatalf("Root round trip with metadata failed: <%v> and: <%v>", root, root2)
+	}
+}
+
+func testDuplicateSignedLogRoot(t *testing.T, s storage.LogStorage) {
+	tx := beginLogTx(s, t)
+	defer tx.Rollback()
+	root := createLogRoot(tx, 98765, 16)
+
+	if err := tx.StoreSignedLogRoot(root); err != nil {
+		t.Fatalf("Failed to store signed root: %v", err)
+	}
+
+	// Shouldn't be able to do it again
+	if err := tx.StoreSignedLogRoot(root); err == nil {
+		t.Fatalf("Allowed duplicate signed root")
+	}
+}
+
+func testLogRootUpdate(t *testing.T, s storage.LogStorage) {
+	// Write two roots for a log and make sure the one with the newest timestamp supersedes
+	tx := beginLogTx(s, t)
+	root := createLogRoot(tx, 98765, 16)
+	root2 := createLogRoot(tx, 98766, 16)
+	root2.TreeRevision++
+
+	if err := tx.StoreSignedLogRoot(root); err != nil {
+		t.Fatalf("Failed to store signed root: %v", err)
+	}
+
+	if err := tx.StoreSignedLogRoot(root2); err != nil {
+		t.Fatalf("Failed to store signed root2: %v", err)
+	}
+
+	commitLogTx(tx, t)
+
+	tx = beginLogTx(s, t)
+	defer tx.Rollback()
+	root3, err := tx.LatestSignedLogRoot()
+
+	if err != nil {
+		t.Fatalf("Failed to read back new log root: %v", err)
+	}
+
+	if !logRootsEqual(root2, root3) {
+		t.Fatalf("Expected latest root: <%v> but got: <%v>", root2, root3)
+	}
+}
+
+func testRollbackDiscardsLogRoot(t *testing.T, s storage.LogStorage) {
+	tx := beginLogTx(s, t)
+	root := createLogRoot(tx, 98765, 16)
+
+	if err := tx.StoreSignedLogRoot(root); err != nil {
+		t.Fatalf("Failed to store signed root: %v", err)
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Failed to rollback: %v", err)
+	}
+
+	tx2 := beginLogTx(s, t)
+	defer tx2.Rollback()
+	root2, err := tx2.LatestSignedLogRoot()
+
+	if err != nil {
+		t.Fatalf("Failed to read log root: %v", err)
+	}
+
+	if len(root2.RootHash) != 0 || root2.Signature != nil {
+		t.Fatalf("Root from rolled back transaction is visible: %v", root2)
+	}
+}
+
+func testLogWriteRevisionFollowsRoot(t *testing.T, s storage.LogStorage) {
+	// Writes must always happen in the future relative to the latest root
+	tx := beginLogTx(s, t)
+	root := createLogRoot(tx, 98765, 16)
+	root.TreeRevision = tx.WriteRevision() + 10
+
+	if err := tx.StoreSignedLogRoot(root); err != nil {
+		t.Fatalf("Failed to store signed root: %v", err)
+	}
+
+	commitLogTx(tx, t)
+
+	tx2 := beginLogTx(s, t)
+	defer tx2.Rollback()
+
+	if got, want := tx2.WriteRevision(), root.TreeRevision+1; got != want {
+		t.Fatalf("Got write revision %d but expected %d", got, want)
+	}
+}
+
+func testGetTreeRevisionAtSize(t *testing.T, s storage.LogStorage) {
+	tx := beginLogTx(s, t)
+
+	if _, err := tx.GetTreeRevisionAtSize(0); terrors.CodeOf(err) != terrors.InvalidRange {
+		t.Fatalf("Got %v for 0 sized tree, expected an InvalidRange error", err)
+	}
+
+	if _, err := tx.GetTreeRevisionAtSize(-427); terrors.CodeOf(err) != terrors.InvalidRange {
+		t.Fatalf("Got %v for -ve sized tree, expected an InvalidRange error", err)
+	}
+
+	root := createLogRoot(tx, 98765, 16)
+	root.TreeRevision = 5
+	root2 := createLogRoot(tx, 198765, 27)
+	root2.TreeRevision = 11
+
+	if err := tx.StoreSignedLogRoot(root); err != nil {
+		t.Fatalf("Failed to store signed root: %v", err)
+	}
+
+	if err := tx.StoreSignedLogRoot(root2); err != nil {
+		t.Fatalf("Failed to store signed root2: %v", err)
+	}
+
+	commitLogTx(tx, t)
+
+	tx = beginLogTx(s, t)
+	defer tx.Rollback()
+
+	for _, r := range []trillian.SignedLogRoot{root, root2} {
+		rev, err := tx.GetTreeRevisionAtSize(r.TreeSize)
+
+		if err != nil {
+			t.Fatalf("Failed to get tree revision at size %d: %v", r.TreeSize, err)
+		}
+
+		if rev != r.TreeRevision {
+			t.Fatalf("Expected tree revision %d at size %d but got %d", r.TreeRevision, r.TreeSize, rev)
+		}
+	}
+
+	// But an intermediate value shouldn't work
+	if rev, err := tx.GetTreeRevisionAtSize(21); err == nil {
+		t.Fatalf("Unexpectedly returned revision for nonexistent tree size: %d", rev)
+	} else if terrors.CodeOf(err) != terrors.NotFound {
+		t.Fatalf("Got %v for nonexistent tree size, expected a NotFound error", err)
+	}
+}
+
+func testLogNodeRoundTrip(t *testing.T, s storage.LogStorage) {
+	nodesToStore := createSomeNodes(4)
+	nodeIDsToRead := make([]storage.NodeID, len(nodesToStore))
+	for i := range nodesToStore {
+		nodeIDsToRead[i] = nodesToStore[i].NodeID
+	}
+
+	var writeRevision int64
+
+	{
+		tx := beginLogTx(s, t)
+		writeRevision = tx.WriteRevision()
+
+		// Need to read nodes before attempting to write
+		if _, err := tx.GetMerkleNodes(writeRevision-1, nodeIDsToRead); err != nil {
+			tx.Rollback()
+			t.Fatalf("Failed to read nodes: %v", err)
+		}
+
+		if err := tx.SetMerkleNodes(nodesToStore); err != nil {
+			tx.Rollback()
+			t.Fatalf("Failed to store nodes: %v", err)
+		}
+
+		commitLogTx(tx, t)
+	}
+
+	{
+		tx := beginLogTx(s, t)
+		defer tx.Rollback()
+
+		readNodes, err := tx.GetMerkleNodes(writeRevision, nodeIDsToRead)
+
+		if err != nil {
+			t.Fatalf("Failed to retrieve nodes: %v", err)
+		}
+
+		if err := nodesAreEqual(readNodes, nodesToStore); err != nil {
+			t.Fatalf("Read back different nodes from the ones stored: %v", err)
+		}
+	}
+}
+
+func testQueueLeavesMissingSignatureRejected(t *testing.T, s storage.LogStorage) {
+	tx := beginLogTx(s, t)
+	defer tx.Rollback()
+
+	leaves := createTestLeaves(5, "MissingSignature")
+	leaves[0].SignedEntryTimestamp.Signature = nil
+
+	if err := tx.QueueLeaves(leaves); err == nil {
+		t.Fatalf("Accepted a leaf with nil signature")
+	}
+}
+
+func testDequeueLeavesNoneQueued(t *testing.T, s storage.LogStorage) {
+	tx := beginLogTx(s, t)
+	defer tx.Rollback()
+
+	leaves, err := tx.DequeueLeaves(999)
+
+	if err != nil {
+		t.Fatalf("Didn't expect an error on dequeue with no work to be done: %v", err)
+	}
+
+	if len(leaves) > 0 {
+		t.Fatalf("Expected nothing to be dequeued but we got %d leaves", len(leaves))
+	}
+}
+
+func testDequeueLeaves(t *testing.T, s storage.LogStorage) {
+	leaves := createTestLeaves(5, "Dequeue")
+	queueLeaves(s, leaves, t)
+
+	{
+		// Now try to dequeue them
+		tx := beginLogTx(s, t)
+		leaves2, err := tx.DequeueLeaves(99)
+
+		if err != nil {
+			tx.Rollback()
+			t.Fatalf("Failed to dequeue leaves: %v", err)
+		}
+
+		commitLogTx(tx, t)
+
+		if err := leavesHaveSameHashes(leaves, leaves2); err != nil {
+			t.Fatalf("Dequeued unexpected leaves: %v", err)
+		}
+	}
+
+	{
+		// If we dequeue again then we should now get nothing
+		tx := beginLogTx(s, t)
+		defer tx.Rollback()
+		leaves3, err := tx.DequeueLeaves(99)
+
+		if err != nil {
+			t.Fatalf("Failed to dequeue leaves (second time): %v", err)
+		}
+
+		if len(leaves3) != 0 {
+			t.Fatalf("Dequeued %d leaves but expected to get none", len(leaves3))
+		}
+	}
+}
+
+func testDequeueLeavesRespectsLimit(t *testing.T, s storage.LogStorage) {
+	leaves := createTestLeaves(5, "DequeueLimit")
+	queueLeaves(s, leaves, t)
+
+	seen := make([]trillian.LogLeaf, 0, len(leaves))
+
+	for _, limit := range []int{3, 3} {
+		tx := beginLogTx(s, t)
+		dequeued, err := tx.DequeueLeaves(limit)
+
+		if err != nil {
+			tx.Rollback()
+			t.Fatalf("Failed to dequeue leaves: %v", err)
+		}
+
+		commitLogTx(tx, t)
+
+		if len(dequeued) > limit {
+			t.Fatalf("Dequeued %d leaves but limit was %d", len(dequeued), limit)
+		}
+
+		seen = append(seen, dequeued...)
+	}
+
+	// Between them the two batches must have returned each leaf exactly once
+	if err := leavesHaveSameHashes(leaves, seen); err != nil {
+		t.Fatalf("Dequeued unexpected leaves across batches: %v", err)
+	}
+}
+
+func testDequeueLeavesByPriority(t *testing.T, s storage.LogStorage) {
+	low := createTestLeaves(3, "DequeueLow")
+	normal := createTestLeaves(2, "DequeueNormal")
+
+	for i := range low {
+		low[i].Priority = trillian.LeafPriorityLow
+	}
+
+	// Queue the low priority leaves first, they must still be dequeued last
+	queueLeaves(s, low, t)
+	queueLeaves(s, normal, t)
+
+	tx := beginLogTx(s, t)
+	defer tx.Rollback()
+	dequeued, err := tx.DequeueLeaves(len(normal))
+
+	if err != nil {
+		t.Fatalf("Failed to dequeue leaves: %v", err)
+	}
+
+	if err := leavesHaveSameHashes(normal, dequeued); err != nil {
+		t.Fatalf("Dequeued unexpected leaves: %v", err)
+	}
+
+	for _, leaf := range dequeued {
+		if got, want := leaf.Priority, trillian.LeafPriorityNormal; got != want {
+			t.Errorf("Dequeued leaf with priority %d, expected %d", got, want)
+		}
+	}
+}
+
+func testDequeueLeavesRollback(t *testing.T, s storage.LogStorage) {
+	leaves := createTestLeaves(5, "DequeueRollback")
+	queueLeaves(s, leaves, t)
+
+	{
+		tx := beginLogTx(s, t)
+		leaves2, err := tx.DequeueLeaves(99)
+
+		if err != nil {
+			tx.Rollback()
+			t.Fatalf("Failed to dequeue leaves: %v", err)
+		}
+
+		if len(leaves2) != len(leaves) {
+			tx.Rollback()
+			t.Fatalf("Dequeued %d leaves but expected to get %d", len(leaves2), len(leaves))
+		}
+
+		if err := tx.Rollback(); err != nil {
+			t.Fatalf("Failed to rollback: %v", err)
+		}
+	}
+
+	{
+		// The leaves must be available again as the dequeue was rolled back
+		tx := beginLogTx(s, t)
+		defer tx.Rollback()
+		leaves3, err := tx.DequeueLeaves(99)
+
+		if err != nil {
+			t.Fatalf("Failed to dequeue leaves (second time): %v", err)
+		}
+
+		if err := leavesHaveSameHashes(leaves, leaves3); err != nil {
+			t.Fatalf("Leaves not available after rollback: %v", err)
+		}
+	}
+}
+
+func testSequencedLeavesRoundTrip(t *testing.T, s storage.LogStorage) {
+	leaves := createTestLeaves(3, "Sequenced")
+	queueLeaves(s, leaves, t)
+
+	{
+		tx := beginLogTx(s, t)
+		dequeued, err := tx.DequeueLeaves(99)
+
+		if err != nil {
+			tx.Rollback()
+			t.Fatalf("Failed to dequeue leaves: %v", err)
+		}
+
+		for i := range dequeued {
+			dequeued[i].SequenceNumber = int64(i)
+		}
+
+		if err := tx.UpdateSequencedLeaves(dequeued); err != nil {
+			tx.Rollback()
+			t.Fatalf("Failed to update sequenced leaves: %v", err)
+		}
+
+		commitLogTx(tx, t)
+
+		// Use the dequeued versions as they've got the sequence numbers assigned
+		leaves = dequeued
+	}
+
+	tx := beginLogTx(s, t)
+	defer tx.Rollback()
+
+	for _, leaf := range leaves {
+		byIndex, err := tx.GetLeavesByIndex([]int64{leaf.SequenceNumber})
+
+		if err != nil {
+			t.Fatalf("Failed to get leaf by index %d: %v", leaf.SequenceNumber, err)
+		}
+
+		if len(byIndex) != 1 {
+			t.Fatalf("Got %d leaves by index but expected one", len(byIndex))
+		}
+
+		checkLeafContents(byIndex[0], leaf, t)
+
+		if es, ok := tx.(storage.ExtraDataSkipper); ok {
+			withoutExtraData, err := es.GetLeavesByIndexWithoutExtraData([]int64{leaf.SequenceNumber})
+
+			if err != nil {
+				t.Fatalf("Failed to get leaf by index without extra data %d: %v", leaf.SequenceNumber, err)
+			}
+
+			if len(withoutExtraData) != 1 {
+				t.Fatalf("Got %d leaves by index without extra data but expected one", len(withoutExtraData))
+			}
+
+			checkLeafContents(withoutExtraData[0], leaf, t)
+
+			if len(withoutExtraData[0].ExtraData) > 0 {
+				t.Fatalf("Got extra data when reading leaf without it: %v", withoutExtraData[0].ExtraData)
+			}
+		}
+
+		byHash, err := tx.GetLeavesByHash([]trillian.Hash{leaf.LeafHash}, false)
+
+		if err != nil {
+			t.Fatalf("Failed to get leaf by hash: %v", err)
+		}
+
+		if len(byHash) != 1 {
+			t.Fatalf("Got %d leaves by hash but expected one", len(byHash))
+		}
+
+		checkLeafContents(byHash[0], leaf, t)
+	}
+}
+
+func testGetLeavesByHashNotPresent(t *testing.T, s storage.LogStorage) {
+	tx := beginLogTx(s, t)
+	defer tx.Rollback()
+
+	leaves, err := tx.GetLeavesByHash([]trillian.Hash{dummyHash}, false)
+
+	if err != nil {
+		t.Fatalf("Error getting leaves by hash: %v", err)
+	}
+
+	if len(leaves) != 0 {
+		t.Fatalf("Expected no leaves returned but got %d", len(leaves))
+	}
+}
+
+func testGetLeavesByIndexNotPresent(t *testing.T, s storage.LogStorage) {
+	tx := beginLogTx(s, t)
+	defer tx.Rollback()
+
+	if _, err := tx.GetLeavesByIndex([]int64{99999}); err == nil {
+		t.Fatalf("Returned ok for leaf index when nothing inserted")
+	}
+}
+
+func testLogSnapshotSeesCommittedRoot(t *testing.T, s storage.LogStorage) {
+	tx := beginLogTx(s, t)
+	root := createLogRoot(tx, 98765, 16)
+
+	if err := tx.StoreSignedLogRoot(root); err != nil {
+		t.Fatalf("Failed to store signed root: %v", err)
+	}
+
+	commitLogTx(tx, t)
+
+	snapshot, err := s.Snapshot()
+
+	if err != nil {
+		t.Fatalf("Failed to start snapshot: %v", err)
+	}
+
+	root2, err := snapshot.LatestSignedLogRoot()
+
+	if err != nil {
+		t.Fatalf("Failed to read log root from snapshot: %v", err)
+	}
+
+	if err := snapshot.Commit(); err != nil {
+		t.Fatalf("Failed to commit snapshot: %v", err)
+	}
+
+	if !logRootsEqual(root, root2) {
+		t.Fatalf("Snapshot root mismatch: <%v> and: <%v>", root, root2)
+	}
+}
+
+func testTreeStorageStats(t *testing.T, s storage.LogStorage) {
+	const someRows = -1
+
+	// checkStats returns the number of node rows so later checks can expect the same
+	checkStats := func(desc string, leaves, nodes, roots int64) int64 {
+		tx := beginLogTx(s, t)
+		sr, ok := tx.(storage.TreeStatsReader)
+
+		if !ok {
+			tx.Rollback()
+			return nodes
+		}
+
+		stats, err := sr.GetTreeStorageStats()
+
+		if err != nil {
+			tx.Rollback()
+			t.Fatalf("%s: failed to get tree storage stats: %v", desc, err)
+		}
+
+		commitLogTx(tx, t)
+
+		want := []struct {
+			table string
+			rows  int64
+		}{
+			{storage.LeafTableStats, leaves},
+			{storage.NodeTableStats, nodes},
+			{storage.RootTableStats, roots},
+		}
+
+		if len(stats) != len(want) {
+			t.Fatalf("%s: got stats for %d tables, expected %d: %v", desc, len(stats), len(want), stats)
+		}
+
+		for i, w := range want {
+			if stats[i].Table != w.table {
+				t.Errorf("%s: got %s stats, expected %s", desc, stats[i].Table, w.table)
+			}
+
+			// How nodes are stored depends on the implementation, it's enough that some are
+			if w.rows == someRows && stats[i].RowCount > 0 {
+				w.rows = stats[i].RowCount
+			}
+
+			if stats[i].RowCount != w.rows {
+				t.Errorf("%s: got %s stats of %d rows, expected %d", desc, w.table, stats[i].RowCount, w.rows)
+			}
+
+			if (stats[i].ByteSize > 0) != (stats[i].RowCount > 0) {
+				t.Errorf("%s: got %s stats of %d bytes for %d rows", desc, stats[i].Table, stats[i].ByteSize, stats[i].RowCount)
+			}
+		}
+
+		return stats[1].RowCount
+	}
+
+	checkStats("empty log", 0, 0, 0)
+
+	leaves := createTestLeaves(3, "Stats")
+	queueLeaves(s, leaves, t)
+
+	{
+		tx := beginLogTx(s, t)
+		dequeued, err := tx.DequeueLeaves(99)
+
+		if err != nil {
+			tx.Rollback()
+			t.Fatalf("Failed to dequeue leaves: %v", err)
+		}
+
+		for i := range dequeued {
+			dequeued[i].SequenceNumber = int64(i)
+		}
+
+		if err := tx.UpdateSequencedLeaves(dequeued); err != nil {
+			tx.Rollback()
+			t.Fatalf("Failed to update sequenced leaves: %v", err)
+		}
+
+		nodes := createSomeNodes(4)
+		nodeIDs := make([]storage.NodeID, len(nodes))
+		for i := range nodes {
+			nodeIDs[i] = nodes[i].NodeID
+		}
+
+		// Need to read nodes before attempting to write
+		if _, err := tx.GetMerkleNodes(tx.WriteRevision()-1, nodeIDs); err != nil {
+			tx.Rollback()
+			t.Fatalf("Failed to read nodes: %v", err)
+		}
+
+		if err := tx.SetMerkleNodes(nodes); err != nil {
+			tx.Rollback()
+			t.Fatalf("Failed to store nodes: %v", err)
+		}
+
+		// The root doesn't cover the last leaf yet so it isn't counted
+		if err := tx.StoreSignedLogRoot(createLogRoot(tx, 98765, 2)); err != nil {
+			tx.Rollback()
+			t.Fatalf("Failed to store signed root: %v", err)
+		}
+
+		commitLogTx(tx, t)
+	}
+
+	nodes := checkStats("first root", 2, someRows, 1)
+	// Rows that have already been counted aren't counted again
+	checkStats("first root again", 2, nodes, 1)
+
+	{
+		tx := beginLogTx(s, t)
+
+		if err := tx.StoreSignedLogRoot(createLogRoot(tx, 98766, 3)); err != nil {
+			tx.Rollback()
+			t.Fatalf("Failed to store signed root: %v", err)
+		}
+
+		commitLogTx(tx, t)
+	}
+
+	checkStats("second root", 3, nodes, 2)
+}
+
+// Convenience methods to avoid copying out "if err != nil { blah }" all over the place
+
+func beginLogTx(s storage.LogStorage, t *testing.T) storage.LogTX {
+	tx, err := s.Begin()
+
+	if err != nil {
+		t.Fatalf("Failed to begin log tx: %v", err)
+	}
+
+	return tx
+}
+
+func commitLogTx(tx storage.LogTX, t *testing.T) {
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit log tx: %v", err)
+	}
+}
+
+func queueLeaves(s storage.LogStorage, leaves []trillian.LogLeaf, t *testing.T) {
+	tx := beginLogTx(s, t)
+
+	if err := tx.QueueLeaves(leaves); err != nil {
+		tx.Rollback()
+		t.Fatalf("Failed to queue leaves: %v", err)
+	}
+
+	commitLogTx(tx, t)
+}
+
+// createLogRoot returns a root that will be written at the transaction's write revision.
+func createLogRoot(tx storage.LogTX, timestamp, treeSize int64) trillian.SignedLogRoot {
+	return trillian.SignedLogRoot{
+		TimestampNanos: timestamp,
+		TreeSize:       treeSize,
+		TreeRevision:   tx.WriteRevision(),
+		RootHash:       dummyHash,
+		Signature:      dummySignature}
+}
+
+// logRootsEqual compares the fields of a root that storage is responsible for. LogId is
+// ignored as implementations fill it in from their own configuration.
+func logRootsEqual(lhs, rhs trillian.SignedLogRoot) bool {
+	return lhs.TimestampNanos == rhs.TimestampNanos &&
+		lhs.TreeSize == rhs.TreeSize &&
+		lhs.TreeRevision == rhs.TreeRevision &&
+		bytes.Equal(lhs.RootHash, rhs.RootHash) &&
+		bytes.Equal(lhs.Metadata, rhs.Metadata) &&
+		proto.Equal(lhs.Signature, rhs.Signature)
+}
+
+// leavesHaveSameHashes checks that both sets contain exactly the same leaf hashes,
+// ignoring ordering.
+func leavesHaveSameHashes(want, got []trillian.LogLeaf) error {
+	if len(want) != len(got) {
+		return fmt.Errorf("got %d leaves but expected %d", len(got), len(want))
+	}
+
+	counts := make(map[string]int)
+	for _, leaf := range want {
+		counts[string(leaf.LeafHash)]++
+	}
+	for _, leaf := range got {
+		counts[string(leaf.LeafHash)]--
+	}
+	for hash, count := range counts {
+		if count != 0 {
+			return fmt.Errorf("leaf hash %x returned unexpected number of times", hash)
+		}
+	}
+
+	return nil
+}
+
+func checkLeafContents(leaf, want trillian.LogLeaf, t *testing.T) {
+	if expected, got := want.LeafHash, leaf.LeafHash; !bytes.Equal(expected, got) {
+		t.Fatalf("Unexpected leaf hash in returned leaf. Expected:\n%v\nGot:\n%v", expected, got)
+	}
+
+	if expected, got := want.SequenceNumber, leaf.SequenceNumber; expected != got {
+		t.Fatalf("Unexpected sequence number in returned leaf. Expected: %d Got: %d", expected, got)
+	}
+
+	if expected, got := want.LeafValue, leaf.LeafValue; !bytes.Equal(expected, got) {
+		t.Fatalf("Unexpected data in returned leaf. Expected:\n%v\nGot:\n%v", expected, got)
+	}
+}
+
+func nodesAreEqual(lhs []storage.Node, rhs []storage.Node) error {
+	if ls, rs := len(lhs), len(rhs); ls != rs {
+		return fmt.Errorf("different number of nodes, %d vs %d", ls, rs)
+	}
+	for i := range lhs {
+		if l, r := lhs[i].NodeID.String(), rhs[i].NodeID.String(); l != r {
+			return fmt.Errorf("NodeIDs are not the same,\nlhs = %v,\nrhs = %v", l, r)
+		}
+		if l, r := lhs[i].Hash, rhs[i].Hash; !bytes.Equal(l, r) {
+			return fmt.Errorf("Hashes are not the same,\nlhs = %v,\nrhs = %v", l, r)
+		}
+	}
+	return nil
+}
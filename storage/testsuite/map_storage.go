@@ -0,0 +1,382 @@
+package testsuite
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+)
+
+type mapStorageTest struct {
+	name string
+	fn   func(t *testing.T, s storage.MapStorage)
+}
+
+var mapStorageTests = []mapStorageTest{
+	{"OpenStateCommit", testMapOpenStateCommit},
+	{"OpenStateRollback", testMapOpenStateRollback},
+	{"LatestSignedMapRootNoneWritten", testLatestSignedMapRootNoneWritten},
+	{"LatestSignedMapRoot", testLatestSignedMapRoot},
+	{"DuplicateSignedMapRoot", testDuplicateSignedMapRoot},
+	{"MapRootUpdate", testMapRootUpdate},
+	{"WriteRevisionFollowsRoot", testMapWriteRevisionFollowsRoot},
+	{"SetGetRoundTrip", testMapSetGetRoundTrip},
+	{"SetSameKeyInSameRevisionFails", testMapSetSameKeyInSameRevisionFails},
+	{"GetUnknownKey", testMapGetUnknownKey},
+	{"SetGetMultipleRevisions", testMapSetGetMultipleRevisions},
+	{"RollbackDiscardsSet", testMapRollbackDiscardsSet},
+	{"SnapshotSeesCommittedRoot", testMapSnapshotSeesCommittedRoot},
+}
+
+// RunMapStorageTests runs all the MapStorage conformance tests. The factory is called
+// once per test so each test starts with an empty map.
+func RunMapStorageTests(t *testing.T, f MapStorageFactory) {
+	// TODO(Martin2112): Use subtests when we can depend on Go 1.7.
+	for _, test := range mapStorageTests {
+		t.Logf("Running map storage conformance test: %s", test.name)
+		test.fn(t, f(t))
+	}
+}
+
+var mapLeaf = trillian.MapLeaf{
+	LeafHash:  []byte("A Hash"),
+	LeafValue: []byte("A Value"),
+	ExtraData: []byte("Some Extra Data"),
+}
+
+var keyHash = trillian.Hash("A Key Hash")
+
+func testMapOpenStateCommit(t *testing.T, s storage.MapStorage) {
+	tx := beginMapTx(s, t)
+
+	if !tx.IsOpen() {
+		t.Fatalf("Transaction should be open on creation")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	if tx.IsOpen() {
+		t.Fatalf("Transaction should be closed after commit")
+	}
+}
+
+func testMapOpenStateRollback(t *testing.T, s storage.MapStorage) {
+	tx := beginMapTx(s, t)
+
+	if !tx.IsOpen() {
+		t.Fatalf("Transaction should be open on creation")
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Failed to rollback: %v", err)
+	}
+	if tx.IsOpen() {
+		t.Fatalf("Transaction should be closed after rollback")
+	}
+}
+
+func testLatestSignedMapRootNoneWritten(t *testing.T, s storage.MapStorage) {
+	tx := beginMapTx(s, t)
+	defer tx.Rollback()
+
+	root, err := tx.LatestSignedMapRoot()
+
+	if err != nil {
+		t.Fatalf("Failed to read an empty map root: %v", err)
+	}
+
+	if len(root.MapId) != 0 || len(root.RootHash) != 0 || root.Signature != nil {
+		t.Fatalf("Read a root with contents when it should be empty: %v", root)
+	}
+}
+
+func testLatestSignedMapRoot(t *testing.T, s storage.MapStorage) {
+	tx := beginMapTx(s, t)
+	root := createMapRoot(s, tx, 98765)
+
+	if err := tx.StoreSignedMapRoot(root); err != nil {
+		t.Fatalf("Failed to store signed map root: %v", err)
+	}
+
+	commitMapTx(tx, t)
+
+	tx2 := beginMapTx(s, t)
+	defer tx2.Rollback()
+	root2, err := tx2.LatestSignedMapRoot()
+
+	if err != nil {
+		t.Fatalf("Failed to read back new map root: %v", err)
+	}
+
+	if !proto.Equal(&root, &root2) {
+		t.Fatalf("Root round trip failed: <%v> and: <%v>", root, root2)
+	}
+}
+
+func testDuplicateSignedMapRoot(t *testing.T, s storage.MapStorage) {
+	tx := beginMapTx(s, t)
+	defer tx.Rollback()
+	root := createMapRoot(s, tx, 98765)
+
+	if err := tx.StoreSignedMapRoot(root); err != nil {
+		t.Fatalf("Failed to store signed map root: %v", err)
+	}
+
+	// Shouldn't be able to do it again
+	if err := tx.StoreSignedMapRoot(root); err == nil {
+		t.Fatalf("Allowed duplicate signed map root")
+	}
+}
+
+func testMapRootUpdate(t *testing.T, s storage.MapStorage) {
+	// Write two roots for a map and make sure the one with the newest timestamp supersedes
+	tx := beginMapTx(s, t)
+	root := createMapRoot(s, tx, 98765)
+	root2 := createMapRoot(s, tx, 98766)
+	root2.MapRevision++
+
+	if err := tx.StoreSignedMapRoot(root); err != nil {
+		t.Fatalf("Failed to store signed map root: %v", err)
+	}
+
+	if err := tx.StoreSignedMapRoot(root2); err != nil {
+		t.Fatalf("Failed to store signed map root2: %v", err)
+	}
+
+	commitMapTx(tx, t)
+
+	tx = beginMapTx(s, t)
+	defer tx.Rollback()
+	root3, err := tx.LatestSignedMapRoot()
+
+	if err != nil {
+		t.Fatalf("Failed to read back new map root: %v", err)
+	}
+
+	if !proto.Equal(&root2, &root3) {
+		t.Fatalf("Expected latest root: <%v> but got: <%v>", root2, root3)
+	}
+}
+
+func testMapWriteRevisionFollowsRoot(t *testing.T, s storage.MapStorage) {
+	// Writes must always happen in the future relative to the latest root
+	tx := beginMapTx(s, t)
+	root := createMapRoot(s, tx, 98765)
+	root.MapRevision = tx.WriteRevision() + 10
+
+	if err := tx.StoreSignedMapRoot(root); err != nil {
+		t.Fatalf("Failed to store signed map root: %v", err)
+	}
+
+	commitMapTx(tx, t)
+
+	tx2 := beginMapTx(s, t)
+	defer tx2.Rollback()
+
+	if got, want := tx2.WriteRevision(), root.MapRevision+1; got != want {
+		t.Fatalf("Got write revision %d but expected %d", got, want)
+	}
+}
+
+func testMapSetGetRoundTrip(t *testing.T, s storage.MapStorage) {
+	var readRev int64
+
+	{
+		tx := beginMapTx(s, t)
+		readRev = tx.WriteRevision()
+
+		if err := tx.Set(keyHash, mapLeaf); err != nil {
+			tx.Rollback()
+			t.Fatalf("Failed to set %v to %v: %v", keyHash, mapLeaf, err)
+		}
+
+		commitMapTx(tx, t)
+	}
+
+	{
+		tx := beginMapTx(s, t)
+		defer tx.Rollback()
+
+		readValue, err := tx.Get(readRev, keyHash)
+
+		if err != nil {
+			t.Fatalf("Failed to get %v: %v", keyHash, err)
+		}
+
+		if got, want := &readValue, &mapLeaf; !proto.Equal(got, want) {
+			t.Fatalf("Read back %v, but expected %v", got, want)
+		}
+	}
+}
+
+func testMapSetSameKeyInSameRevisionFails(t *testing.T, s storage.MapStorage) {
+	{
+		tx := beginMapTx(s, t)
+
+		if err := tx.Set(keyHash, mapLeaf); err != nil {
+			tx.Rollback()
+			t.Fatalf("Failed to set %v to %v: %v", keyHash, mapLeaf, err)
+		}
+
+		commitMapTx(tx, t)
+	}
+
+	{
+		// No root was written so this will be at the same revision as above
+		tx := beginMapTx(s, t)
+		defer tx.Rollback()
+
+		if err := tx.Set(keyHash, mapLeaf); err == nil {
+			t.Fatalf("Unexpectedly succeeded in setting %v to %v", keyHash, mapLeaf)
+		}
+	}
+}
+
+func testMapGetUnknownKey(t *testing.T, s storage.MapStorage) {
+	tx := beginMapTx(s, t)
+	defer tx.Rollback()
+
+	readValue, err := tx.Get(tx.WriteRevision(), []byte("This doesn't exist."))
+
+	if got, want := err, storage.ErrNoSuchKey; got != want {
+		t.Fatalf("Read %v with error %v, but expected error %v", readValue, got, want)
+	}
+}
+
+func testMapSetGetMultipleRevisions(t *testing.T, s storage.MapStorage) {
+	const numRevs = 3
+	values := make([]trillian.MapLeaf, numRevs)
+	revisions := make([]int64, numRevs)
+	for i := 0; i < numRevs; i++ {
+		values[i] = trillian.MapLeaf{
+			LeafHash:  []byte(fmt.Sprintf("A Hash %d", i)),
+			LeafValue: []byte(fmt.Sprintf("A Value %d", i)),
+			ExtraData: []byte(fmt.Sprintf("Some Extra Data %d", i)),
+		}
+	}
+
+	// Each value is written in its own revision, which is advanced by storing a root
+	for i := 0; i < numRevs; i++ {
+		tx := beginMapTx(s, t)
+		revisions[i] = tx.WriteRevision()
+
+		if err := tx.Set(keyHash, values[i]); err != nil {
+			tx.Rollback()
+			t.Fatalf("Failed to set %v to %v: %v", keyHash, values[i], err)
+		}
+
+		if err := tx.StoreSignedMapRoot(createMapRoot(s, tx, int64(98765+i))); err != nil {
+			tx.Rollback()
+			t.Fatalf("Failed to store signed map root: %v", err)
+		}
+
+		commitMapTx(tx, t)
+	}
+
+	tx := beginMapTx(s, t)
+	defer tx.Rollback()
+
+	for i := 0; i < numRevs; i++ {
+		readValue, err := tx.Get(revisions[i], keyHash)
+
+		if err != nil {
+			t.Fatalf("Failed to get %v at revision %d: %v", keyHash, revisions[i], err)
+		}
+
+		if got, want := &readValue, &values[i]; !proto.Equal(got, want) {
+			t.Fatalf("Read back %v at revision %d, but expected %v", got, revisions[i], want)
+		}
+	}
+
+	// Nothing was set before the first revision
+	if readValue, err := tx.Get(revisions[0]-1, keyHash); err != storage.ErrNoSuchKey {
+		t.Fatalf("Read %v with error %v before first revision, but expected error %v", readValue, err, storage.ErrNoSuchKey)
+	}
+}
+
+func testMapRollbackDiscardsSet(t *testing.T, s storage.MapStorage) {
+	var rev int64
+
+	{
+		tx := beginMapTx(s, t)
+		rev = tx.WriteRevision()
+
+		if err := tx.Set(keyHash, mapLeaf); err != nil {
+			tx.Rollback()
+			t.Fatalf("Failed to set %v to %v: %v", keyHash, mapLeaf, err)
+		}
+
+		if err := tx.Rollback(); err != nil {
+			t.Fatalf("Failed to rollback: %v", err)
+		}
+	}
+
+	{
+		tx := beginMapTx(s, t)
+		defer tx.Rollback()
+
+		readValue, err := tx.Get(rev, keyHash)
+
+		if got, want := err, storage.ErrNoSuchKey; got != want {
+			t.Fatalf("Read %v with error %v after rollback, but expected error %v", readValue, got, want)
+		}
+	}
+}
+
+func testMapSnapshotSeesCommittedRoot(t *testing.T, s storage.MapStorage) {
+	tx := beginMapTx(s, t)
+	root := createMapRoot(s, tx, 98765)
+
+	if err := tx.StoreSignedMapRoot(root); err != nil {
+		t.Fatalf("Failed to store signed map root: %v", err)
+	}
+
+	commitMapTx(tx, t)
+
+	snapshot, err := s.Snapshot()
+
+	if err != nil {
+		t.Fatalf("Failed to start snapshot: %v", err)
+	}
+
+	root2, err := snapshot.LatestSignedMapRoot()
+
+	if err != nil {
+		t.Fatalf("Failed to read map root from snapshot: %v", err)
+	}
+
+	if err := snapshot.Commit(); err != nil {
+		t.Fatalf("Failed to commit snapshot: %v", err)
+	}
+
+	if !proto.Equal(&root, &root2) {
+		t.Fatalf("Snapshot root mismatch: <%v> and: <%v>", root, root2)
+	}
+}
+
+func beginMapTx(s storage.MapStorage, t *testing.T) storage.MapTX {
+	tx, err := s.Begin()
+
+	if err != nil {
+		t.Fatalf("Failed to begin map tx: %v", err)
+	}
+
+	return tx
+}
+
+func commitMapTx(tx storage.MapTX, t *testing.T) {
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Failed to commit map tx: %v", err)
+	}
+}
+
+// createMapRoot returns a root that will be written at the transaction's write revision.
+func createMapRoot(s storage.MapStorage, tx storage.MapTX, timestamp int64) trillian.SignedMapRoot {
+	return trillian.SignedMapRoot{
+		MapId:          s.MapID().MapID,
+		TimestampNanos: timestamp,
+		MapRevision:    tx.WriteRevision(),
+		RootHash:       dummyHash,
+		Signature:      dummySignature}
+}
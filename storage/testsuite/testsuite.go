@@ -0,0 +1,65 @@
+/*
+Package testsuite contains a set of storage conformance tests that exercise the
+documented contracts of the LogStorage and MapStorage interfaces. Every storage
+implementation should run these from its own tests so that backends behave
+consistently regardless of how they are implemented.
+
+Only the public storage interfaces are used here. Anything that needs to peek at
+implementation details (e.g. raw database rows) belongs in the backend's own tests.
+
+Production code MUST NOT depend on anything in this package.
+*/
+package testsuite
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/trillian"
+	"github.com/google/trillian/storage"
+)
+
+// LogStorageFactory must return a LogStorage for a newly created, empty log. Each call
+// must return storage for a distinct tree so tests do not interfere with each other.
+type LogStorageFactory func(t *testing.T) storage.LogStorage
+
+// MapStorageFactory must return a MapStorage for a newly created, empty map. Each call
+// must return storage for a distinct tree so tests do not interfere with each other.
+type MapStorageFactory func(t *testing.T) storage.MapStorage
+
+// Must be 32 bytes to match sha256 length if it was a real hash
+var dummyHash = []byte("hashxxxxhashxxxxhashxxxxhashxxxx")
+
+var dummySignature = &trillian.DigitallySigned{Signature: []byte("notempty")}
+
+// Creates some test leaves with predictable data. The leaves are not sequenced.
+func createTestLeaves(n int64, prefix string) []trillian.LogLeaf {
+	leaves := make([]trillian.LogLeaf, 0, n)
+	hasher := trillian.NewSHA256()
+
+	for l := int64(0); l < n; l++ {
+		lv := fmt.Sprintf("%s Leaf %d", prefix, l)
+		leaf := trillian.LogLeaf{
+			Leaf: trillian.Leaf{
+				LeafHash:  hasher.Digest([]byte(lv)),
+				LeafValue: []byte(lv),
+				ExtraData: []byte(fmt.Sprintf("%s Extra %d", prefix, l))},
+			SignedEntryTimestamp: trillian.SignedEntryTimestamp{
+				TimestampNanos: 1234567890 + l,
+				Signature:      dummySignature}}
+		leaves = append(leaves, leaf)
+	}
+
+	return leaves
+}
+
+// Creates some nodes for a single subtree with predictable hashes
+func createSomeNodes(n int) []storage.Node {
+	hasher := trillian.NewSHA256()
+	r := make([]storage.Node, n)
+	for i := range r {
+		r[i].NodeID = storage.NewNodeIDWithPrefix(uint64(i), 8, 8, 8)
+		r[i].Hash = hasher.Digest([]byte{byte(i)})
+	}
+	return r
+}
@@ -15,6 +15,7 @@ import (
 var numInsertionsFlag = flag.Int("num_insertions", 10, "Number of entries to insert in the tree")
 var startInsertFromFlag = flag.Int("start_from", 0, "The sequence number of the first inserted item")
 var queueBatchSizeFlag = flag.Int("queue_batch_size", 50, "Queue leaves batch size")
+var lowPriorityFlag = flag.Bool("low_priority", false, "If true, the leaves are queued at low priority so they're sequenced after live submissions, e.g. when backfilling")
 
 func validateFlagsOrDie() {
 	if *numInsertionsFlag <= 0 {
@@ -46,6 +47,11 @@ func main() {
 	}
 
 	leaves := []trillian.LogLeaf{}
+	priority := trillian.LeafPriorityNormal
+
+	if *lowPriorityFlag {
+		priority = trillian.LeafPriorityLow
+	}
 
 	for l := 0; l < *numInsertionsFlag; l++ {
 		// Leaf data based in the sequence number so we can check the hashes
@@ -71,7 +77,8 @@ func main() {
 				ExtraData: nil,
 			},
 			SignedEntryTimestamp: entryTimestamp,
-			SequenceNumber:       0}
+			SequenceNumber:       0,
+			Priority:             priority}
 		leaves = append(leaves, leaf)
 
 		if len(leaves) >= *queueBatchSizeFlag {
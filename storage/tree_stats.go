@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/google/trillian"
+)
+
+// The tables reported by TreeStatsReader. Each may be made up of more than one storage table,
+// e.g. leaves are held in both LeafData and SequencedLeafData.
+const (
+	LeafTableStats = "leaf"
+	NodeTableStats = "node"
+	RootTableStats = "root"
+)
+
+// TreeStatsGrowthWindow is the shortest period that growth rates are averaged over once stats
+// have been kept for that long. Averaging over a day stops rates jumping around with the time
+// of day or with how often stats are fetched.
+const TreeStatsGrowthWindow = 24 * time.Hour
+
+// TableStatsSample is the size of a tree's table at a point in time.
+type TableStatsSample struct {
+	RowCount  int64
+	ByteSize  int64
+	TimeNanos int64
+}
+
+// TableStatsState is what storage keeps for each table of each tree so that TreeStatsReader
+// only has to read the rows written since it was last called.
+type TableStatsState struct {
+	// Current is the size as of the latest update
+	Current TableStatsSample
+	// Watermark is where the next update should start reading from, e.g. the first sequence
+	// number or revision that hasn't been counted yet
+	Watermark int64
+	// Base is the sample that growth is measured from
+	Base TableStatsSample
+	// NextBase replaces Base once it's at least TreeStatsGrowthWindow old, so growth is
+	// always measured over between one and two windows
+	NextBase TableStatsSample
+}
+
+// Update adds rows and bytes counted up to watermark to the state at nowNanos. A zero state
+// is the state of a table with no rows counted.
+func (s *TableStatsState) Update(rows, bytes, watermark, nowNanos int64) {
+	s.Current = TableStatsSample{RowCount: s.Current.RowCount + rows, ByteSize: s.Current.ByteSize + bytes, TimeNanos: nowNanos}
+	s.Watermark = watermark
+
+	switch {
+	case s.Base.TimeNanos == 0:
+		// This is the first update, growth can't be measured until the next
+		s.Base = s.Current
+		s.NextBase = s.Current
+	case time.Duration(nowNanos-s.NextBase.TimeNanos) >= TreeStatsGrowthWindow:
+		s.Base = s.NextBase
+		s.NextBase = s.Current
+	}
+}
+
+// Proto returns the stats for table, with growth per day measured from Base to Current.
+func (s TableStatsState) Proto(table string) *trillian.TableStorageStats {
+	stats := &trillian.TableStorageStats{Table: table, RowCount: s.Current.RowCount, ByteSize: s.Current.ByteSize}
+
+	if elapsed := s.Current.TimeNanos - s.Base.TimeNanos; elapsed > 0 {
+		days := float64(elapsed) / float64(24*time.Hour)
+		stats.RowsPerDay = float64(s.Current.RowCount-s.Base.RowCount) / days
+		stats.BytesPerDay = float64(s.Current.ByteSize-s.Base.ByteSize) / days
+	}
+
+	return stats
+}
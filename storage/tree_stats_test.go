@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+)
+
+func TestTableStatsState(t *testing.T) {
+	day := int64(24 * time.Hour)
+	start := int64(1000 * time.Hour)
+	var s TableStatsState
+
+	for _, test := range []struct {
+		desc                   string
+		rows, bytes, watermark int64
+		now                    int64
+		want                   trillian.TableStorageStats
+	}{
+		// Growth can't be measured from a single sample
+		{"first", 10, 1000, 10, start, trillian.TableStorageStats{Table: "leaf", RowCount: 10, ByteSize: 1000}},
+		{"half a day", 5, 500, 15, start + day/2, trillian.TableStorageStats{Table: "leaf", RowCount: 15, ByteSize: 1500, RowsPerDay: 10, BytesPerDay: 1000}},
+		{"no growth", 0, 0, 15, start + day, trillian.TableStorageStats{Table: "leaf", RowCount: 15, ByteSize: 1500, RowsPerDay: 5, BytesPerDay: 500}},
+		// The base moves on to the sample at start + day, growth before that no longer counts
+		{"two days", 20, 2000, 35, start + 2*day, trillian.TableStorageStats{Table: "leaf", RowCount: 35, ByteSize: 3500, RowsPerDay: 20, BytesPerDay: 2000}},
+		{"two and a half days", 0, 0, 35, start + 5*day/2, trillian.TableStorageStats{Table: "leaf", RowCount: 35, ByteSize: 3500, RowsPerDay: 40.0 / 3, BytesPerDay: 4000.0 / 3}},
+		{"three days", 10, 1000, 45, start + 3*day, trillian.TableStorageStats{Table: "leaf", RowCount: 45, ByteSize: 4500, RowsPerDay: 10, BytesPerDay: 1000}},
+	} {
+		s.Update(test.rows, test.bytes, test.watermark, test.now)
+
+		if got := s.Proto("leaf"); !proto.Equal(got, &test.want) {
+			t.Errorf("%s: Proto()=%v, expected %v", test.desc, got, test.want)
+		}
+
+		if got, want := s.Watermark, test.watermark; got != want {
+			t.Errorf("%s: Watermark=%d, expected %d", test.desc, got, want)
+		}
+	}
+}
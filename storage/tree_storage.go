@@ -33,6 +33,8 @@ type TreeTX interface {
 type NodeReader interface {
 	// GetTreeRevisionAtSize returns the max node version for a tree at a particular size.
 	// It is an error to request tree sizes larger than the currently published tree size.
+	// Sizes that can't be valid give an InvalidRange error and sizes that no tree head
+	// was stored for give a NotFound error, see the errors package.
 	GetTreeRevisionAtSize(treeSize int64) (int64, error)
 	// GetMerkleNodes looks up the set of nodes identified by ids, at treeRevision, and returns them.
 	GetMerkleNodes(treeRevision int64, ids []NodeID) ([]Node, error)
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"math"
 
 	"github.com/google/trillian"
 )
@@ -11,6 +12,56 @@ import (
 // ErrReadOnly is returned when storage operations are not allowed because a resource is read only
 var ErrReadOnly = errors.New("storage: Operation not allowed because resource is read only")
 
+// ErrFenced is returned when a write is made with a sequence range reservation that has since
+// been taken over or released, see SequenceRangeReserver
+var ErrFenced = errors.New("storage: sequence range reservation is no longer current")
+
+// SequenceRange is a contiguous range of sequence numbers reserved by a sequencer, see
+// SequenceRangeReserver.
+type SequenceRange struct {
+	// FirstSequenceNumber is the first sequence number in the range
+	FirstSequenceNumber int64
+	// EndSequenceNumber is one past the last sequence number in the range
+	EndSequenceNumber int64
+	// SequencerID identifies the sequencer holding the range
+	SequencerID string
+	// FencingToken identifies this reservation of the range, writes are only accepted with the
+	// range's current token
+	FencingToken int64
+}
+
+// Contains returns whether sequenceNumber is in the range.
+func (r SequenceRange) Contains(sequenceNumber int64) bool {
+	return sequenceNumber >= r.FirstSequenceNumber && sequenceNumber < r.EndSequenceNumber
+}
+
+// DefaultLogTreeDepth is the depth of log trees that are created without one being given,
+// which is also the depth of all log trees created before it could be chosen.
+const DefaultLogTreeDepth = 64
+
+// MaxLogTreeDepth is the largest depth a log tree can have. Leaf indices are int64s so even
+// the deepest log can hold at most 2^63-1 leaves, see LogTreeCapacity.
+const MaxLogTreeDepth = 64
+
+// ValidateLogTreeDepth returns an error if depth can't be used for a log tree. Nodes are
+// stored in subtrees of 8 levels so the depth must be a multiple of 8.
+func ValidateLogTreeDepth(depth int) error {
+	if depth <= 0 || depth > MaxLogTreeDepth || depth%8 != 0 {
+		return fmt.Errorf("invalid log tree depth %d: must be a multiple of 8 no greater than %d", depth, MaxLogTreeDepth)
+	}
+
+	return nil
+}
+
+// LogTreeCapacity returns the maximum number of leaves in a log tree of the given depth.
+func LogTreeCapacity(depth int) int64 {
+	if depth >= 63 {
+		return math.MaxInt64
+	}
+
+	return int64(1) << uint(depth)
+}
+
 // Node represents a single node in a Merkle tree.
 type Node struct {
 	NodeID       NodeID
@@ -104,12 +155,14 @@ func NewNodeIDForTreeCoords(depth int64, index int64, maxPathBits int) (NodeID,
 		return NodeID{}, fmt.Errorf("depth/index combination out of range: depth=%d index=%d", depth, index)
 	}
 	// This node is effectively a prefix of the subtree underneath (for non-leaf
-	// depths), so we shift the index accordingly.
-	uidx := uint64(index) << uint(depth)
+	// depths), so the index is shifted up by depth bits. The bits are set one at a
+	// time rather than shifting a uint64 so that trees deeper than 64 levels work.
 	r := NewEmptyNodeID(maxPathBits)
-	for i := len(r.Path) - 1; uidx > 0 && i >= 0; i-- {
-		r.Path[i] = byte(uidx & 0xff)
-		uidx >>= 8
+	for bit := int(depth); index > 0; bit++ {
+		if index&1 != 0 {
+			r.SetBit(bit, 1)
+		}
+		index >>= 1
 	}
 	// In the storage model nodes closer to the leaves have longer nodeIDs, so
 	// we "reverse" depth here:
@@ -117,11 +170,18 @@ func NewNodeIDForTreeCoords(depth int64, index int64, maxPathBits int) (NodeID,
 	return r, nil
 }
 
+// byteIndex returns the index of the byte in Path holding the ith bit. Bits are numbered from
+// the LSB of the last byte, so when PathLenBits isn't a multiple of 8 the unused bits are the
+// MSBs of the first byte.
+func (n *NodeID) byteIndex(i int) int {
+	return len(n.Path) - 1 - i/8
+}
+
 // SetBit sets the ith bit to true if b is non-zero, and false otherwise.
 func (n *NodeID) SetBit(i int, b uint) {
 	// TODO(al): investigate whether having lookup tables for these might be
 	// faster.
-	bIndex := (n.PathLenBits - i - 1) / 8
+	bIndex := n.byteIndex(i)
 	if b == 0 {
 		n.Path[bIndex] &= ^(1 << uint(i%8))
 	} else {
@@ -131,7 +191,7 @@ func (n *NodeID) SetBit(i int, b uint) {
 
 // Bit returns 1 if the ith bit is true, and false otherwise.
 func (n *NodeID) Bit(i int) uint {
-	bIndex := (n.PathLenBits - i - 1) / 8
+	bIndex := n.byteIndex(i)
 	return uint((n.Path[bIndex] >> uint(i%8)) & 0x01)
 }
 
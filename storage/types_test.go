@@ -3,6 +3,8 @@ package storage
 import (
 	"bytes"
 	"fmt"
+	"math"
+	"strings"
 	"testing"
 )
 
@@ -50,6 +52,16 @@ var nodeIDForTreeCoordsVec = []struct {
 	{0, 0x01, 64, false, "0000000000000000000000000000000000000000000000000000000000000001"},
 	{63, 0x01, 64, false, "1"},
 	{63, 0x02, 64, true, "index of 0x02 is too large for given depth"},
+	// Depths that aren't a multiple of 8
+	{0, 0x80, 15, false, "000000010000000"},
+	{3, 0x05, 12, false, "000000101"},
+	{4, 0x100, 12, true, "index of 0x100 is too large for given depth"},
+	// Sparse map trees are as deep as their key hashes
+	{0, 0x01, 256, false, strings.Repeat("0", 255) + "1"},
+	{250, 0x01, 256, false, "000001"},
+	{200, 0x7f, 256, false, strings.Repeat("0", 49) + "1111111"},
+	{192, 1 << 62, 256, false, "01" + strings.Repeat("0", 62)},
+	{194, 1 << 62, 256, true, "index of 1<<62 is too large for given depth"},
 }
 
 func TestNewNodeIDForTreeCoords(t *testing.T) {
@@ -88,6 +100,20 @@ func TestSetBit(t *testing.T) {
 	}
 }
 
+func TestSetBitNotByteAligned(t *testing.T) {
+	// The unused bits are at the top of the first byte
+	n := NewEmptyNodeID(12)
+	n.PrefixLenBits = 12
+	n.SetBit(7, 1)
+	n.SetBit(11, 1)
+	if got, want := n.Path, []byte{0x08, 0x80}; !bytes.Equal(got, want) {
+		t.Fatalf("Expected Path of %v, but got %v", want, got)
+	}
+	if got, want := n.String(), "100010000000"; got != want {
+		t.Fatalf("Expected '%s', got '%s'", want, got)
+	}
+}
+
 func TestBit(t *testing.T) {
 	// every 3rd bit set
 	n := NewNodeIDWithPrefix(0x9249, 16, 16, 16)
@@ -147,6 +173,65 @@ func TestSiblings(t *testing.T) {
 	}
 }
 
+func TestSiblingsDeepTree(t *testing.T) {
+	// Siblings of a leaf in a tree the depth of a SHA-256 map
+	n, err := NewNodeIDForTreeCoords(0, 1, 256)
+	if err != nil {
+		t.Fatalf("Failed to create nodeID: %v", err)
+	}
+
+	sibs := n.Siblings()
+	if got, want := len(sibs), 256; got != want {
+		t.Fatalf("Expected %d siblings, got %d", want, got)
+	}
+
+	// The first sibling is the other leaf under the same parent, and the last is the other
+	// child of the root
+	if got, want := sibs[0].String(), strings.Repeat("0", 256); got != want {
+		t.Errorf("Expected sib 0 to be %v, got %v", want, got)
+	}
+	if got, want := sibs[255].String(), "1"; got != want {
+		t.Errorf("Expected sib 255 to be %v, got %v", want, got)
+	}
+}
+
+func TestValidateLogTreeDepth(t *testing.T) {
+	for _, test := range []struct {
+		depth int
+		valid bool
+	}{
+		{-8, false},
+		{0, false},
+		{7, false},
+		{8, true},
+		{12, false},
+		{32, true},
+		{64, true},
+		{72, false},
+		{256, false},
+	} {
+		if err := ValidateLogTreeDepth(test.depth); (err == nil) != test.valid {
+			t.Errorf("ValidateLogTreeDepth(%d)=%v, expected valid=%v", test.depth, err, test.valid)
+		}
+	}
+}
+
+func TestLogTreeCapacity(t *testing.T) {
+	for _, test := range []struct {
+		depth int
+		want  int64
+	}{
+		{8, 256},
+		{32, 1 << 32},
+		{56, 1 << 56},
+		{64, math.MaxInt64},
+	} {
+		if got := LogTreeCapacity(test.depth); got != test.want {
+			t.Errorf("LogTreeCapacity(%d)=%d, expected %d", test.depth, got, test.want)
+		}
+	}
+}
+
 func TestNodeSelfEquivalent(t *testing.T) {
 	l := 16
 	n1 := NewNodeIDWithPrefix(0x1234, l, l, l)
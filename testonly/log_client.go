@@ -0,0 +1,303 @@
+package testonly
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// OKStatus returns the status of a successful trillian API response.
+func OKStatus() *trillian.TrillianApiStatus {
+	return &trillian.TrillianApiStatus{StatusCode: trillian.TrillianApiStatusCode_OK}
+}
+
+// GetRootResponse returns a successful GetLatestSignedLogRoot response for a root with the
+// given timestamp, tree size and root hash.
+func GetRootResponse(timestampNanos, treeSize int64, rootHash []byte) *trillian.GetLatestSignedLogRootResponse {
+	return &trillian.GetLatestSignedLogRootResponse{
+		Status: OKStatus(),
+		SignedLogRoot: &trillian.SignedLogRoot{
+			TimestampNanos: timestampNanos,
+			TreeSize:       treeSize,
+			RootHash:       rootHash}}
+}
+
+// FakeLogCall is a request received by a FakeLogClient.
+type FakeLogCall struct {
+	// Method is the name of the RPC, for example "QueueLeaves"
+	Method string
+	// Request is the request that was sent
+	Request proto.Message
+	// Deadline is the deadline of the request's context, zero if it had none
+	Deadline time.Time
+}
+
+// FakeLogHandler computes the response to a request, returning the response type of the RPC.
+type FakeLogHandler func(ctx context.Context, req proto.Message) (proto.Message, error)
+
+// scriptedResponse is a response queued with FakeLogClient.Script
+type scriptedResponse struct {
+	resp proto.Message
+	err  error
+}
+
+// FakeLogClient is a trillian.TrillianLogClient whose responses are scripted by the test, an
+// alternative to a gomock mock for tests that care about what a handler does with responses
+// more than the exact sequence of calls it makes. Each RPC returns the responses queued for it
+// with Script in order, then falls back to the handler set with Handle. An RPC with neither
+// fails with codes.Unimplemented. Every request is recorded so it can be checked afterwards.
+// It is safe for concurrent use.
+type FakeLogClient struct {
+	// mu guards the fields below it
+	mu sync.Mutex
+	// scripts maps RPC names to the responses that are still queued for them
+	scripts map[string][]scriptedResponse
+	// handlers maps RPC names to the handlers used once their scripts are exhausted
+	handlers map[string]FakeLogHandler
+	// calls holds every request received, in order
+	calls []FakeLogCall
+}
+
+// NewFakeLogClient creates a FakeLogClient with nothing scripted.
+func NewFakeLogClient() *FakeLogClient {
+	return &FakeLogClient{scripts: make(map[string][]scriptedResponse), handlers: make(map[string]FakeLogHandler)}
+}
+
+// Script queues a response for the next call of method that doesn't already have one. If err
+// is not nil the call fails with it and resp is ignored, otherwise resp must be the response
+// type of method.
+func (f *FakeLogClient) Script(method string, resp proto.Message, err error) *FakeLogClient {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.scripts[method] = append(f.scripts[method], scriptedResponse{resp: resp, err: err})
+
+	return f
+}
+
+// Handle sets the handler that answers calls of method once its scripted responses are used up.
+func (f *FakeLogClient) Handle(method string, handler FakeLogHandler) *FakeLogClient {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.handlers[method] = handler
+
+	return f
+}
+
+// Calls returns the requests received so far, in order.
+func (f *FakeLogClient) Calls() []FakeLogCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]FakeLogCall(nil), f.calls...)
+}
+
+// CallsTo returns the requests of method received so far, in order.
+func (f *FakeLogClient) CallsTo(method string) []FakeLogCall {
+	var calls []FakeLogCall
+
+	for _, call := range f.Calls() {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+// Unused returns an error naming the RPCs that still have scripted responses queued, or nil if
+// they've all been used. Tests normally check it at the end, like gomock's Finish.
+func (f *FakeLogClient) Unused() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var unused []string
+
+	for method, script := range f.scripts {
+		if len(script) > 0 {
+			unused = append(unused, fmt.Sprintf("%s (%d)", method, len(script)))
+		}
+	}
+
+	if len(unused) > 0 {
+		return fmt.Errorf("scripted responses were not used: %v", unused)
+	}
+
+	return nil
+}
+
+// call records a request and returns the response to it.
+func (f *FakeLogClient) call(ctx context.Context, method string, req proto.Message) (proto.Message, error) {
+	deadline, _ := ctx.Deadline()
+
+	f.mu.Lock()
+	f.calls = append(f.calls, FakeLogCall{Method: method, Request: req, Deadline: deadline})
+
+	if script := f.scripts[method]; len(script) > 0 {
+		f.scripts[method] = script[1:]
+		f.mu.Unlock()
+
+		return script[0].resp, script[0].err
+	}
+
+	handler := f.handlers[method]
+	f.mu.Unlock()
+
+	if handler == nil {
+		return nil, grpc.Errorf(codes.Unimplemented, "no response scripted for %s", method)
+	}
+
+	return handler(ctx, req)
+}
+
+// GetConsistencyProof implements trillian.TrillianLogClient.
+func (f *FakeLogClient) GetConsistencyProof(ctx context.Context, req *trillian.GetConsistencyProofRequest, opts ...grpc.CallOption) (*trillian.GetConsistencyProofResponse, error) {
+	resp, err := f.call(ctx, "GetConsistencyProof", req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetConsistencyProofResponse), nil
+}
+
+// GetEntryAndProof implements trillian.TrillianLogClient.
+func (f *FakeLogClient) GetEntryAndProof(ctx context.Context, req *trillian.GetEntryAndProofRequest, opts ...grpc.CallOption) (*trillian.GetEntryAndProofResponse, error) {
+	resp, err := f.call(ctx, "GetEntryAndProof", req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetEntryAndProofResponse), nil
+}
+
+// GetInclusionProof implements trillian.TrillianLogClient.
+func (f *FakeLogClient) GetInclusionProof(ctx context.Context, req *trillian.GetInclusionProofRequest, opts ...grpc.CallOption) (*trillian.GetInclusionProofResponse, error) {
+	resp, err := f.call(ctx, "GetInclusionProof", req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetInclusionProofResponse), nil
+}
+
+// GetInclusionProofByHash implements trillian.TrillianLogClient.
+func (f *FakeLogClient) GetInclusionProofByHash(ctx context.Context, req *trillian.GetInclusionProofByHashRequest, opts ...grpc.CallOption) (*trillian.GetInclusionProofByHashResponse, error) {
+	resp, err := f.call(ctx, "GetInclusionProofByHash", req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetInclusionProofByHashResponse), nil
+}
+
+// GetLatestSignedLogRoot implements trillian.TrillianLogClient.
+func (f *FakeLogClient) GetLatestSignedLogRoot(ctx context.Context, req *trillian.GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*trillian.GetLatestSignedLogRootResponse, error) {
+	resp, err := f.call(ctx, "GetLatestSignedLogRoot", req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetLatestSignedLogRootResponse), nil
+}
+
+// GetLatestSignedLogRootAndLeaves implements trillian.TrillianLogClient.
+func (f *FakeLogClient) GetLatestSignedLogRootAndLeaves(ctx context.Context, req *trillian.GetLatestSignedLogRootAndLeavesRequest, opts ...grpc.CallOption) (*trillian.GetLatestSignedLogRootAndLeavesResponse, error) {
+	resp, err := f.call(ctx, "GetLatestSignedLogRootAndLeaves", req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetLatestSignedLogRootAndLeavesResponse), nil
+}
+
+// GetLatestSignedLogRoots implements trillian.TrillianLogClient.
+func (f *FakeLogClient) GetLatestSignedLogRoots(ctx context.Context, req *trillian.GetLatestSignedLogRootsRequest, opts ...grpc.CallOption) (*trillian.GetLatestSignedLogRootsResponse, error) {
+	resp, err := f.call(ctx, "GetLatestSignedLogRoots", req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetLatestSignedLogRootsResponse), nil
+}
+
+// GetLeavesByHash implements trillian.TrillianLogClient.
+func (f *FakeLogClient) GetLeavesByHash(ctx context.Context, req *trillian.GetLeavesByHashRequest, opts ...grpc.CallOption) (*trillian.GetLeavesByHashResponse, error) {
+	resp, err := f.call(ctx, "GetLeavesByHash", req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetLeavesByHashResponse), nil
+}
+
+// GetLeavesByIndex implements trillian.TrillianLogClient.
+func (f *FakeLogClient) GetLeavesByIndex(ctx context.Context, req *trillian.GetLeavesByIndexRequest, opts ...grpc.CallOption) (*trillian.GetLeavesByIndexResponse, error) {
+	resp, err := f.call(ctx, "GetLeavesByIndex", req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetLeavesByIndexResponse), nil
+}
+
+// GetLeavesByTimestamp implements trillian.TrillianLogClient.
+func (f *FakeLogClient) GetLeavesByTimestamp(ctx context.Context, req *trillian.GetLeavesByTimestampRequest, opts ...grpc.CallOption) (*trillian.GetLeavesByTimestampResponse, error) {
+	resp, err := f.call(ctx, "GetLeavesByTimestamp", req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetLeavesByTimestampResponse), nil
+}
+
+// GetRangeProof implements trillian.TrillianLogClient.
+func (f *FakeLogClient) GetRangeProof(ctx context.Context, req *trillian.GetRangeProofRequest, opts ...grpc.CallOption) (*trillian.GetRangeProofResponse, error) {
+	resp, err := f.call(ctx, "GetRangeProof", req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetRangeProofResponse), nil
+}
+
+// GetRevisionDiff implements trillian.TrillianLogClient.
+func (f *FakeLogClient) GetRevisionDiff(ctx context.Context, req *trillian.GetRevisionDiffRequest, opts ...grpc.CallOption) (*trillian.GetRevisionDiffResponse, error) {
+	resp, err := f.call(ctx, "GetRevisionDiff", req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetRevisionDiffResponse), nil
+}
+
+// GetSequencedLeafCount implements trillian.TrillianLogClient.
+func (f *FakeLogClient) GetSequencedLeafCount(ctx context.Context, req *trillian.GetSequencedLeafCountRequest, opts ...grpc.CallOption) (*trillian.GetSequencedLeafCountResponse, error) {
+	resp, err := f.call(ctx, "GetSequencedLeafCount", req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetSequencedLeafCountResponse), nil
+}
+
+// GetTreeMetadata implements trillian.TrillianLogClient.
+func (f *FakeLogClient) GetTreeMetadata(ctx context.Context, req *trillian.GetTreeMetadataRequest, opts ...grpc.CallOption) (*trillian.GetTreeMetadataResponse, error) {
+	resp, err := f.call(ctx, "GetTreeMetadata", req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.GetTreeMetadataResponse), nil
+}
+
+// QueueLeaves implements trillian.TrillianLogClient.
+func (f *FakeLogClient) QueueLeaves(ctx context.Context, req *trillian.QueueLeavesRequest, opts ...grpc.CallOption) (*trillian.QueueLeavesResponse, error) {
+	resp, err := f.call(ctx, "QueueLeaves", req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(*trillian.QueueLeavesResponse), nil
+}
+
+// SubscribeTreeEvents implements trillian.TrillianLogClient. Streams can't be scripted, the
+// request is recorded and the scripted error, if any, returned. Otherwise it fails with
+// codes.Unimplemented.
+func (f *FakeLogClient) SubscribeTreeEvents(ctx context.Context, req *trillian.SubscribeTreeEventsRequest, opts ...grpc.CallOption) (trillian.TrillianLog_SubscribeTreeEventsClient, error) {
+	if _, err := f.call(ctx, "SubscribeTreeEvents", req); err != nil {
+		return nil, err
+	}
+	return nil, grpc.Errorf(codes.Unimplemented, "SubscribeTreeEvents can't be faked")
+}
@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/google/trillian/storage"
+	"golang.org/x/net/context"
 )
 
 type subtreeHasPrefix struct {
@@ -108,3 +110,33 @@ func (n *nodeSorter) Less(i, j int) bool {
 }
 
 // End sorting boilerplate.
+
+type deadlineMatcher struct {
+	deadline time.Time
+}
+
+func (d deadlineMatcher) Matches(x interface{}) bool {
+	ctx, ok := x.(context.Context)
+	if !ok {
+		return false
+	}
+
+	deadline, ok := ctx.Deadline()
+
+	if !ok {
+		return false
+	}
+
+	return deadline.Equal(d.deadline)
+}
+
+func (d deadlineMatcher) String() string {
+	return fmt.Sprintf("deadline is %v", d.deadline)
+}
+
+// DeadlineMatcher returns a gomock matcher which returns true when it finds a
+// context whose deadline is the one passed in. Contexts without a deadline
+// never match.
+func DeadlineMatcher(deadline time.Time) gomock.Matcher {
+	return deadlineMatcher{deadline}
+}
@@ -33,7 +33,33 @@ var TreeHasherPreimageType_value = map[string]int32{
 func (x TreeHasherPreimageType) String() string {
 	return proto.EnumName(TreeHasherPreimageType_name, int32(x))
 }
-func (TreeHasherPreimageType) EnumDescriptor() ([]byte, []int) { return fileDescriptor1, []int{0} }
+func (TreeHasherPreimageType) EnumDescriptor() ([]byte, []int) { return fileDescriptor2, []int{0} }
+
+// Defines how leaf data is hashed to form the leaf hash that is stored in the Merkle tree.
+// This is a property of each tree and must not be changed after leaves have been added.
+type LeafHashStrategy int32
+
+const (
+	// The leaf hash is digest(0x00 || data) as defined in RFC 6962. Use this for CT.
+	LeafHashStrategy_RFC6962_LEAF_HASH LeafHashStrategy = 0
+	// The leaf hash is digest(data) with no domain separation prefix. Only use this for
+	// formats where leaf data can never be mistaken for an internal node.
+	LeafHashStrategy_RAW_LEAF_HASH LeafHashStrategy = 1
+)
+
+var LeafHashStrategy_name = map[int32]string{
+	0: "RFC6962_LEAF_HASH",
+	1: "RAW_LEAF_HASH",
+}
+var LeafHashStrategy_value = map[string]int32{
+	"RFC6962_LEAF_HASH": 0,
+	"RAW_LEAF_HASH":     1,
+}
+
+func (x LeafHashStrategy) String() string {
+	return proto.EnumName(LeafHashStrategy_name, int32(x))
+}
+func (LeafHashStrategy) EnumDescriptor() ([]byte, []int) { return fileDescriptor2, []int{1} }
 
 type SignatureAlgorithm int32
 
@@ -54,25 +80,31 @@ var SignatureAlgorithm_value = map[string]int32{
 func (x SignatureAlgorithm) String() string {
 	return proto.EnumName(SignatureAlgorithm_name, int32(x))
 }
-func (SignatureAlgorithm) EnumDescriptor() ([]byte, []int) { return fileDescriptor1, []int{1} }
+func (SignatureAlgorithm) EnumDescriptor() ([]byte, []int) { return fileDescriptor2, []int{2} }
 
 type HashAlgorithm int32
 
 const (
-	HashAlgorithm_SHA256 HashAlgorithm = 0
+	HashAlgorithm_SHA256     HashAlgorithm = 0
+	HashAlgorithm_SHA512_256 HashAlgorithm = 1
+	HashAlgorithm_SHA3_256   HashAlgorithm = 2
 )
 
 var HashAlgorithm_name = map[int32]string{
 	0: "SHA256",
+	1: "SHA512_256",
+	2: "SHA3_256",
 }
 var HashAlgorithm_value = map[string]int32{
-	"SHA256": 0,
+	"SHA256":     0,
+	"SHA512_256": 1,
+	"SHA3_256":   2,
 }
 
 func (x HashAlgorithm) String() string {
 	return proto.EnumName(HashAlgorithm_name, int32(x))
 }
-func (HashAlgorithm) EnumDescriptor() ([]byte, []int) { return fileDescriptor1, []int{2} }
+func (HashAlgorithm) EnumDescriptor() ([]byte, []int) { return fileDescriptor2, []int{3} }
 
 type DigitallySigned struct {
 	SignatureAlgorithm SignatureAlgorithm `protobuf:"varint,1,opt,name=signature_algorithm,json=signatureAlgorithm,enum=trillian.SignatureAlgorithm" json:"signature_algorithm,omitempty"`
@@ -83,7 +115,7 @@ type DigitallySigned struct {
 func (m *DigitallySigned) Reset()                    { *m = DigitallySigned{} }
 func (m *DigitallySigned) String() string            { return proto.CompactTextString(m) }
 func (*DigitallySigned) ProtoMessage()               {}
-func (*DigitallySigned) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{0} }
+func (*DigitallySigned) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{0} }
 
 type SignedEntryTimestamp struct {
 	TimestampNanos int64            `protobuf:"varint,1,opt,name=timestamp_nanos,json=timestampNanos" json:"timestamp_nanos,omitempty"`
@@ -94,7 +126,7 @@ type SignedEntryTimestamp struct {
 func (m *SignedEntryTimestamp) Reset()                    { *m = SignedEntryTimestamp{} }
 func (m *SignedEntryTimestamp) String() string            { return proto.CompactTextString(m) }
 func (*SignedEntryTimestamp) ProtoMessage()               {}
-func (*SignedEntryTimestamp) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{1} }
+func (*SignedEntryTimestamp) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{1} }
 
 func (m *SignedEntryTimestamp) GetSignature() *DigitallySigned {
 	if m != nil {
@@ -114,12 +146,15 @@ type SignedLogRoot struct {
 	Signature    *DigitallySigned `protobuf:"bytes,4,opt,name=signature" json:"signature,omitempty"`
 	LogId        []byte           `protobuf:"bytes,5,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
 	TreeRevision int64            `protobuf:"varint,6,opt,name=tree_revision,json=treeRevision" json:"tree_revision,omitempty"`
+	// Metadata is opaque application data supplied by the personality. It is covered
+	// by the root signature when present.
+	Metadata []byte `protobuf:"bytes,7,opt,name=metadata,proto3" json:"metadata,omitempty"`
 }
 
 func (m *SignedLogRoot) Reset()                    { *m = SignedLogRoot{} }
 func (m *SignedLogRoot) String() string            { return proto.CompactTextString(m) }
 func (*SignedLogRoot) ProtoMessage()               {}
-func (*SignedLogRoot) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{2} }
+func (*SignedLogRoot) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{2} }
 
 func (m *SignedLogRoot) GetSignature() *DigitallySigned {
 	if m != nil {
@@ -137,7 +172,7 @@ type MapperMetadata struct {
 func (m *MapperMetadata) Reset()                    { *m = MapperMetadata{} }
 func (m *MapperMetadata) String() string            { return proto.CompactTextString(m) }
 func (*MapperMetadata) ProtoMessage()               {}
-func (*MapperMetadata) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{3} }
+func (*MapperMetadata) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{3} }
 
 // SignedMapRoot represents a commitment by a Map to a particular tree.
 type SignedMapRoot struct {
@@ -153,7 +188,7 @@ type SignedMapRoot struct {
 func (m *SignedMapRoot) Reset()                    { *m = SignedMapRoot{} }
 func (m *SignedMapRoot) String() string            { return proto.CompactTextString(m) }
 func (*SignedMapRoot) ProtoMessage()               {}
-func (*SignedMapRoot) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{4} }
+func (*SignedMapRoot) Descriptor() ([]byte, []int) { return fileDescriptor2, []int{4} }
 
 func (m *SignedMapRoot) GetMetadata() *MapperMetadata {
 	if m != nil {
@@ -176,47 +211,51 @@ func init() {
 	proto.RegisterType((*MapperMetadata)(nil), "trillian.MapperMetadata")
 	proto.RegisterType((*SignedMapRoot)(nil), "trillian.SignedMapRoot")
 	proto.RegisterEnum("trillian.TreeHasherPreimageType", TreeHasherPreimageType_name, TreeHasherPreimageType_value)
+	proto.RegisterEnum("trillian.LeafHashStrategy", LeafHashStrategy_name, LeafHashStrategy_value)
 	proto.RegisterEnum("trillian.SignatureAlgorithm", SignatureAlgorithm_name, SignatureAlgorithm_value)
 	proto.RegisterEnum("trillian.HashAlgorithm", HashAlgorithm_name, HashAlgorithm_value)
 }
 
-func init() { proto.RegisterFile("github.com/google/trillian/trillian.proto", fileDescriptor1) }
-
-var fileDescriptor1 = []byte{
-	// 549 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xac, 0x54, 0x5d, 0x8f, 0xd2, 0x40,
-	0x14, 0xdd, 0x8a, 0x20, 0x5c, 0x3e, 0x16, 0xc7, 0x2f, 0x0c, 0x24, 0x2a, 0x3e, 0xb8, 0xf2, 0x00,
-	0x09, 0x2a, 0xc6, 0x07, 0x4d, 0x08, 0xcb, 0xba, 0x9b, 0x2c, 0x86, 0x4c, 0x79, 0x9f, 0xcc, 0x2e,
-	0x63, 0x3b, 0x49, 0xdb, 0xe9, 0x4e, 0x07, 0x13, 0xfc, 0x13, 0xfe, 0x1f, 0x1f, 0xfc, 0x3b, 0xfe,
-	0x0b, 0xe3, 0x4c, 0x69, 0x4b, 0x81, 0x97, 0x4d, 0xf4, 0x6d, 0xee, 0xe9, 0xb9, 0x67, 0xce, 0xbd,
-	0xa7, 0x2d, 0xbc, 0x76, 0xb8, 0x72, 0x57, 0x57, 0xfd, 0x6b, 0xe1, 0x0f, 0x1c, 0x21, 0x1c, 0x8f,
-	0x0d, 0x94, 0xe4, 0x9e, 0xc7, 0x69, 0x90, 0x1d, 0xfa, 0xa1, 0x14, 0x4a, 0xa0, 0x72, 0x5a, 0x77,
-	0x7f, 0x59, 0x70, 0x7c, 0xca, 0x75, 0x27, 0xf5, 0xbc, 0xb5, 0xcd, 0x9d, 0x80, 0x2d, 0xd1, 0x0c,
-	0x1e, 0x44, 0xfa, 0x44, 0xd5, 0x4a, 0x32, 0x42, 0x3d, 0x47, 0x48, 0x2d, 0xec, 0xb7, 0xac, 0xe7,
-	0xd6, 0x49, 0x63, 0xd8, 0xe9, 0x67, 0x5a, 0x76, 0x4a, 0x1a, 0xa7, 0x1c, 0x8c, 0xa2, 0x03, 0x0c,
-	0x7d, 0x82, 0x86, 0x4b, 0x23, 0x37, 0xa7, 0x74, 0x27, 0x56, 0x7a, 0xb2, 0x55, 0x3a, 0xd7, 0xcf,
-	0xb7, 0x22, 0x75, 0x37, 0x5f, 0xa2, 0x0e, 0x54, 0x32, 0xd5, 0x56, 0x41, 0xb7, 0xd6, 0xf0, 0x16,
-	0xe8, 0xfe, 0xb0, 0xe0, 0xe1, 0xc6, 0xf7, 0x34, 0x50, 0x72, 0xbd, 0xe0, 0x3e, 0x8b, 0x14, 0xf5,
-	0x43, 0xf4, 0x0a, 0x8e, 0x55, 0x5a, 0x90, 0x80, 0x06, 0x22, 0x8a, 0x27, 0x28, 0xe0, 0x46, 0x06,
-	0x7f, 0x31, 0x28, 0x7a, 0x04, 0x25, 0x4f, 0x38, 0x84, 0x2f, 0x63, 0x5f, 0x35, 0x5c, 0xd4, 0xd5,
-	0xc5, 0x12, 0xbd, 0xdf, 0xbf, 0xb6, 0x3a, 0x7c, 0xba, 0x75, 0xbc, 0xb7, 0xb3, 0xbc, 0xa3, 0xdf,
-	0x16, 0xd4, 0x37, 0xe8, 0xa5, 0x70, 0xb0, 0x10, 0xea, 0xf6, 0x56, 0xda, 0x50, 0x91, 0xba, 0x81,
-	0x98, 0x05, 0x24, 0x6e, 0xca, 0x06, 0x30, 0xfb, 0x31, 0x0f, 0x95, 0x64, 0x8c, 0x44, 0xfc, 0xfb,
-	0xc6, 0x50, 0x01, 0x97, 0x0d, 0x60, 0xeb, 0x7a, 0xd7, 0xed, 0xdd, 0xdb, 0xbb, 0xcd, 0x4d, 0x5f,
-	0xcc, 0x4f, 0xff, 0x12, 0xea, 0xf1, 0x65, 0x92, 0x7d, 0xe3, 0x11, 0x17, 0x41, 0xab, 0x14, 0x5f,
-	0x58, 0x33, 0x20, 0x4e, 0xb0, 0xee, 0x4f, 0x0b, 0x1a, 0x33, 0x1a, 0x86, 0x4c, 0xce, 0x98, 0xa2,
-	0x4b, 0xaa, 0x28, 0xea, 0x42, 0x3d, 0x12, 0x2b, 0x79, 0xcd, 0x48, 0xa2, 0x6a, 0xc5, 0xaa, 0xd5,
-	0x0d, 0x78, 0x19, 0x6b, 0x7f, 0x84, 0xb6, 0xcb, 0x1d, 0x57, 0x0f, 0x4e, 0xbe, 0xae, 0xb4, 0x29,
-	0xa2, 0xdf, 0xd8, 0xd0, 0x63, 0x8a, 0x2d, 0x49, 0xc4, 0x6e, 0xe2, 0xb9, 0x0b, 0xb8, 0x95, 0x50,
-	0xce, 0x0c, 0x63, 0x92, 0x12, 0x6c, 0x76, 0x83, 0xa6, 0xf0, 0x2c, 0x6d, 0x0f, 0xa9, 0x54, 0x9c,
-	0x1e, 0x4a, 0x6c, 0xb6, 0xd3, 0x49, 0x68, 0xf3, 0x94, 0x95, 0x97, 0xe9, 0xfe, 0xc9, 0x62, 0xd2,
-	0x23, 0xfc, 0xc7, 0x98, 0xde, 0x42, 0xd9, 0x4f, 0xb6, 0x91, 0xbc, 0x36, 0xad, 0x6d, 0x10, 0xbb,
-	0xdb, 0xc2, 0x19, 0xf3, 0x9f, 0xf2, 0xf3, 0x69, 0x98, 0xcb, 0x4f, 0x57, 0x7a, 0xc7, 0x2f, 0xa0,
-	0x66, 0xe0, 0xbd, 0xf8, 0xaa, 0x1a, 0x4b, 0xd3, 0xeb, 0x0d, 0xe0, 0xf1, 0x42, 0xa7, 0x69, 0x4c,
-	0x33, 0x39, 0x97, 0x8c, 0xfb, 0xd4, 0x61, 0x8b, 0x75, 0x68, 0x34, 0xef, 0xe3, 0xb3, 0x09, 0x19,
-	0x7d, 0x18, 0x0d, 0xc9, 0x1c, 0x4f, 0x2f, 0x66, 0xe3, 0xcf, 0xd3, 0xe6, 0x51, 0xef, 0x04, 0xd0,
-	0xe1, 0x27, 0x8f, 0x2a, 0x50, 0x9c, 0x4e, 0x4e, 0xed, 0x71, 0xf3, 0x08, 0xdd, 0x83, 0x02, 0xd6,
-	0x07, 0xab, 0xd7, 0x86, 0xfa, 0xce, 0x27, 0x8d, 0x00, 0x4a, 0xf6, 0xf9, 0x78, 0xf8, 0x6e, 0xd4,
-	0x3c, 0xba, 0x2a, 0xc5, 0xff, 0xa0, 0x37, 0x7f, 0x03, 0x00, 0x00, 0xff, 0xff, 0x69, 0x4c, 0x6d,
-	0xe8, 0xb0, 0x04, 0x00, 0x00,
+func init() { proto.RegisterFile("github.com/google/trillian/trillian.proto", fileDescriptor2) }
+
+var fileDescriptor2 = []byte{
+	// 607 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xad, 0x54, 0x5d, 0x6f, 0x12, 0x41,
+	0x14, 0xed, 0x16, 0xa1, 0x70, 0xf9, 0x28, 0x1d, 0xbf, 0x56, 0xdb, 0x44, 0x8b, 0x0f, 0x56, 0x1e,
+	0x20, 0x52, 0x5b, 0xd3, 0x44, 0x4d, 0x36, 0x94, 0x4a, 0x13, 0x30, 0xcd, 0x2e, 0x89, 0x8f, 0x93,
+	0x69, 0x99, 0x2e, 0x93, 0xec, 0x32, 0xdb, 0xd9, 0xc1, 0x04, 0x5f, 0xfd, 0x01, 0xfe, 0x1f, 0x1f,
+	0xfc, 0x69, 0xc6, 0x99, 0xfd, 0x80, 0x05, 0x5e, 0x9a, 0xe8, 0xdb, 0x9c, 0xb3, 0xf7, 0x9e, 0x7b,
+	0xe6, 0xdc, 0xcd, 0xc0, 0x1b, 0x97, 0xc9, 0xc9, 0xec, 0xba, 0x75, 0xc3, 0xfd, 0xb6, 0xcb, 0xb9,
+	0xeb, 0xd1, 0xb6, 0x14, 0xcc, 0xf3, 0x18, 0x99, 0x2e, 0x0e, 0xad, 0x40, 0x70, 0xc9, 0x51, 0x31,
+	0xc5, 0x8d, 0xdf, 0x06, 0xec, 0x9e, 0x33, 0xd5, 0x49, 0x3c, 0x6f, 0xee, 0x30, 0x77, 0x4a, 0xc7,
+	0x68, 0x08, 0x0f, 0x43, 0x75, 0x22, 0x72, 0x26, 0x28, 0x26, 0x9e, 0xcb, 0x85, 0x12, 0xf6, 0x4d,
+	0xe3, 0xa5, 0x71, 0x54, 0xeb, 0x1c, 0xb4, 0x16, 0x5a, 0x4e, 0x5a, 0x64, 0xa5, 0x35, 0x36, 0x0a,
+	0x37, 0x38, 0xf4, 0x09, 0x6a, 0x13, 0x12, 0x4e, 0x32, 0x4a, 0xdb, 0x91, 0xd2, 0xd3, 0xa5, 0x52,
+	0x5f, 0x7d, 0x5f, 0x8a, 0x54, 0x27, 0x59, 0x88, 0x0e, 0xa0, 0xb4, 0x50, 0x35, 0x73, 0xaa, 0xb5,
+	0x62, 0x2f, 0x89, 0xc6, 0x4f, 0x03, 0x1e, 0xc5, 0xbe, 0x7b, 0x53, 0x29, 0xe6, 0x23, 0xe6, 0xd3,
+	0x50, 0x12, 0x3f, 0x40, 0xaf, 0x61, 0x57, 0xa6, 0x00, 0x4f, 0xc9, 0x94, 0x87, 0xd1, 0x0d, 0x72,
+	0x76, 0x6d, 0x41, 0x7f, 0xd1, 0x2c, 0x7a, 0x0c, 0x05, 0x8f, 0xbb, 0x98, 0x8d, 0x23, 0x5f, 0x15,
+	0x3b, 0xaf, 0xd0, 0xe5, 0x18, 0xbd, 0x5f, 0x1f, 0x5b, 0xee, 0x3c, 0x5b, 0x3a, 0x5e, 0xcb, 0x2c,
+	0xeb, 0xe8, 0xc7, 0x36, 0x54, 0x63, 0x76, 0xc0, 0x5d, 0x9b, 0x73, 0x79, 0x7f, 0x2b, 0xfb, 0x50,
+	0x12, 0xaa, 0x01, 0xeb, 0x00, 0x12, 0x37, 0x45, 0x4d, 0xe8, 0x7c, 0xf4, 0x47, 0x29, 0x28, 0xc5,
+	0x21, 0xfb, 0x1e, 0x1b, 0xca, 0xd9, 0x45, 0x4d, 0x38, 0x0a, 0xaf, 0xba, 0x7d, 0x70, 0x7f, 0xb7,
+	0x99, 0xdb, 0xe7, 0xb3, 0xb7, 0x7f, 0x05, 0xd5, 0x68, 0x98, 0xa0, 0xdf, 0x58, 0xc8, 0xf8, 0xd4,
+	0x2c, 0x44, 0x03, 0x2b, 0x9a, 0xb4, 0x13, 0x0e, 0x3d, 0x87, 0xa2, 0x4f, 0x25, 0x19, 0x13, 0x49,
+	0xcc, 0x9d, 0xd8, 0x6d, 0x8a, 0x1b, 0xbf, 0x0c, 0xa8, 0x0d, 0x49, 0x10, 0x50, 0x31, 0x4c, 0x28,
+	0xd4, 0x80, 0x6a, 0xc8, 0x67, 0xe2, 0x86, 0xe2, 0x64, 0xa2, 0x11, 0xf5, 0x94, 0x63, 0x72, 0x10,
+	0xcd, 0xfd, 0x08, 0xfb, 0x13, 0xe6, 0x4e, 0x54, 0x28, 0xf8, 0x76, 0xa6, 0x0c, 0x63, 0xf5, 0x37,
+	0x07, 0x1e, 0x95, 0x74, 0x8c, 0x43, 0x7a, 0x17, 0x65, 0x92, 0xb3, 0xcd, 0xa4, 0xe4, 0x42, 0x57,
+	0x74, 0xd3, 0x02, 0x87, 0xde, 0xa1, 0x1e, 0xbc, 0x48, 0xdb, 0x03, 0x22, 0x24, 0x23, 0x9b, 0x12,
+	0x71, 0x72, 0x07, 0x49, 0xd9, 0x55, 0x5a, 0x95, 0x95, 0x69, 0xfc, 0x31, 0xd2, 0x15, 0xaa, 0x2b,
+	0xfc, 0xc7, 0x15, 0xbe, 0xcb, 0x04, 0x16, 0xff, 0x52, 0xe6, 0x72, 0x49, 0xab, 0x69, 0x2d, 0xa3,
+	0xfc, 0xa7, 0xdd, 0xfa, 0x24, 0xc8, 0xec, 0x56, 0x21, 0x95, 0xf1, 0x21, 0x54, 0x34, 0xbd, 0xb6,
+	0xda, 0xb2, 0xe2, 0xd2, 0xcd, 0x36, 0xdb, 0xf0, 0x64, 0xa4, 0x36, 0xad, 0x4d, 0x53, 0x71, 0x25,
+	0x28, 0xf3, 0x89, 0x4b, 0x47, 0xf3, 0x40, 0x6b, 0xee, 0xd9, 0x17, 0x5d, 0x7c, 0x7a, 0x76, 0xda,
+	0xc1, 0x57, 0x76, 0xef, 0x72, 0x68, 0x7d, 0xee, 0xd5, 0xb7, 0x9a, 0x1f, 0xa0, 0x3e, 0xa0, 0xe4,
+	0x56, 0x37, 0x38, 0x52, 0x10, 0x49, 0xdd, 0x79, 0x52, 0x1a, 0x55, 0x0e, 0x7a, 0xd6, 0x05, 0xee,
+	0x5b, 0x4e, 0xbf, 0xbe, 0x85, 0xf6, 0xa0, 0x6a, 0x5b, 0x5f, 0x33, 0x94, 0xd1, 0x3c, 0x02, 0xb4,
+	0xf9, 0x98, 0xa0, 0x12, 0xe4, 0x7b, 0xdd, 0x73, 0xc7, 0x52, 0x3d, 0x3b, 0x90, 0xb3, 0xd5, 0xc1,
+	0x68, 0x9e, 0x41, 0x75, 0xe5, 0xb1, 0x40, 0x00, 0x05, 0xa7, 0x6f, 0x75, 0x4e, 0x4e, 0x55, 0x55,
+	0x0d, 0x40, 0x9d, 0x4f, 0xde, 0x76, 0xb0, 0xc6, 0x06, 0xaa, 0x40, 0x51, 0xe1, 0xe3, 0x08, 0x6d,
+	0x5f, 0x17, 0xa2, 0xb7, 0xef, 0xf8, 0x2f, 0x5f, 0x5f, 0xf3, 0xf1, 0x28, 0x05, 0x00, 0x00,
 }
@@ -7,6 +7,7 @@ Package trillian is a generated protocol buffer package.
 
 It is generated from these files:
 	github.com/google/trillian/trillian_api.proto
+	github.com/google/trillian/trillian_log_v2.proto
 	github.com/google/trillian/trillian.proto
 
 It has these top-level messages:
@@ -22,16 +23,50 @@ It has these top-level messages:
 	GetInclusionProofByHashResponse
 	GetConsistencyProofRequest
 	GetConsistencyProofResponse
+	GetRangeProofRequest
+	GetRangeProofResponse
 	GetLeavesByHashRequest
 	GetLeavesByHashResponse
 	GetLeavesByIndexRequest
 	GetLeavesByIndexResponse
+	GetLeavesByTimestampRequest
+	GetLeavesByTimestampResponse
 	GetSequencedLeafCountRequest
 	GetSequencedLeafCountResponse
 	GetLatestSignedLogRootRequest
 	GetLatestSignedLogRootResponse
+	GetLatestSignedLogRootsRequest
+	LogRootResult
+	GetLatestSignedLogRootsResponse
+	GetLatestSignedLogRootAndLeavesRequest
+	GetLatestSignedLogRootAndLeavesResponse
 	GetEntryAndProofRequest
 	GetEntryAndProofResponse
+	GetRevisionDiffRequest
+	NodeDiffProto
+	GetRevisionDiffResponse
+	FlushLogRequest
+	FlushLogResponse
+	SubscribeTreeEventsRequest
+	TreeEvent
+	LogFeatureProto
+	FeatureProto
+	SetFeatureRequest
+	SetFeatureResponse
+	ListFeaturesRequest
+	ListFeaturesResponse
+	TreeMetadata
+	GetTreeMetadataRequest
+	GetTreeMetadataResponse
+	SetTreeMetadataRequest
+	SetTreeMetadataResponse
+	TableStorageStats
+	GetTreeStorageStatsRequest
+	GetTreeStorageStatsResponse
+	PauseSequencingRequest
+	PauseSequencingResponse
+	ResumeSequencingRequest
+	ResumeSequencingResponse
 	MapLeaf
 	KeyValue
 	KeyValueInclusion
@@ -41,6 +76,18 @@ It has these top-level messages:
 	SetMapLeavesResponse
 	GetSignedMapRootRequest
 	GetSignedMapRootResponse
+	GetMapLeafHistoryRequest
+	MapLeafHistoryEntry
+	GetMapLeafHistoryResponse
+	QueueLeavesV2Request
+	QueuedLeaf
+	QueueLeavesV2Response
+	GetLeavesByRangeRequest
+	GetLeavesByRangeResponse
+	GetLatestSignedLogRootV2Request
+	GetLatestSignedLogRootV2Response
+	GetInclusionProofByHashV2Request
+	GetInclusionProofByHashV2Response
 	DigitallySigned
 	SignedEntryTimestamp
 	SignedLogRoot
@@ -91,6 +138,37 @@ func (x TrillianApiStatusCode) String() string {
 }
 func (TrillianApiStatusCode) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
 
+// TreeEventType says what happened to a log in a TreeEvent
+type TreeEventType int32
+
+const (
+	TreeEventType_UNKNOWN_TREE_EVENT TreeEventType = 0
+	// A new root has been signed and stored
+	TreeEventType_NEW_SIGNED_ROOT TreeEventType = 1
+	// The log will not accept any more leaves, its latest root is final
+	TreeEventType_TREE_FROZEN TreeEventType = 2
+	// The sequencer failed to integrate queued leaves or sign a root
+	TreeEventType_SEQUENCING_ERROR TreeEventType = 3
+)
+
+var TreeEventType_name = map[int32]string{
+	0: "UNKNOWN_TREE_EVENT",
+	1: "NEW_SIGNED_ROOT",
+	2: "TREE_FROZEN",
+	3: "SEQUENCING_ERROR",
+}
+var TreeEventType_value = map[string]int32{
+	"UNKNOWN_TREE_EVENT": 0,
+	"NEW_SIGNED_ROOT":    1,
+	"TREE_FROZEN":        2,
+	"SEQUENCING_ERROR":   3,
+}
+
+func (x TreeEventType) String() string {
+	return proto.EnumName(TreeEventType_name, int32(x))
+}
+func (TreeEventType) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
 // All operations return a TrillianApiStatus.
 // TODO(Martin2112): Most of the operations are not fully defined yet. They will be implemented soon
 type TrillianApiStatus struct {
@@ -111,6 +189,14 @@ type LeafProto struct {
 	LeafData  []byte `protobuf:"bytes,2,opt,name=leaf_data,json=leafData,proto3" json:"leaf_data,omitempty"`
 	ExtraData []byte `protobuf:"bytes,3,opt,name=extra_data,json=extraData,proto3" json:"extra_data,omitempty"`
 	LeafIndex int64  `protobuf:"varint,4,opt,name=leaf_index,json=leafIndex" json:"leaf_index,omitempty"`
+	// The time the leaf was integrated into the tree. Set by the log, it is ignored in
+	// requests to queue leaves.
+	IntegrateTimestampNanos int64 `protobuf:"varint,5,opt,name=integrate_timestamp_nanos,json=integrateTimestampNanos" json:"integrate_timestamp_nanos,omitempty"`
+	// The priority of the leaf in the queue of leaves waiting to be sequenced. Leaves with a
+	// higher priority are sequenced first, so bulk traffic such as mirroring or backfilling
+	// should use a negative priority to avoid delaying live submissions. Zero is the normal
+	// priority. Ignored in responses.
+	Priority int32 `protobuf:"varint,6,opt,name=priority" json:"priority,omitempty"`
 }
 
 func (m *LeafProto) Reset()                    { *m = LeafProto{} }
@@ -149,6 +235,10 @@ func (m *ProofProto) GetProofNode() []*NodeProto {
 type QueueLeavesRequest struct {
 	LogId  int64        `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
 	Leaves []*LeafProto `protobuf:"bytes,2,rep,name=leaves" json:"leaves,omitempty"`
+	// If set, a retry of a request with the same key within the server's idempotency window
+	// returns the result of the first request rather than queueing the leaves again. The key
+	// must only be reused for the same leaves.
+	IdempotencyKey string `protobuf:"bytes,3,opt,name=idempotency_key,json=idempotencyKey" json:"idempotency_key,omitempty"`
 }
 
 func (m *QueueLeavesRequest) Reset()                    { *m = QueueLeavesRequest{} }
@@ -289,6 +379,46 @@ func (m *GetConsistencyProofResponse) GetProof() *ProofProto {
 	return nil
 }
 
+// GetRangeProofRequest asks for a proof that the leaves [start_index, end_index) are at those
+// indices in the tree of tree_size, so that a batch of leaves can be checked at once.
+type GetRangeProofRequest struct {
+	LogId      int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	StartIndex int64 `protobuf:"varint,2,opt,name=start_index,json=startIndex" json:"start_index,omitempty"`
+	EndIndex   int64 `protobuf:"varint,3,opt,name=end_index,json=endIndex" json:"end_index,omitempty"`
+	TreeSize   int64 `protobuf:"varint,4,opt,name=tree_size,json=treeSize" json:"tree_size,omitempty"`
+}
+
+func (m *GetRangeProofRequest) Reset()                    { *m = GetRangeProofRequest{} }
+func (m *GetRangeProofRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetRangeProofRequest) ProtoMessage()               {}
+func (*GetRangeProofRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+type GetRangeProofResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	// The hashes of the perfect subtrees that don't overlap the range, from the left of the
+	// tree. The leaf index is the start of the range.
+	Proof *ProofProto `protobuf:"bytes,2,opt,name=proof" json:"proof,omitempty"`
+}
+
+func (m *GetRangeProofResponse) Reset()                    { *m = GetRangeProofResponse{} }
+func (m *GetRangeProofResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetRangeProofResponse) ProtoMessage()               {}
+func (*GetRangeProofResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *GetRangeProofResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *GetRangeProofResponse) GetProof() *ProofProto {
+	if m != nil {
+		return m.Proof
+	}
+	return nil
+}
+
 type GetLeavesByHashRequest struct {
 	LogId           int64    `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
 	LeafHash        [][]byte `protobuf:"bytes,2,rep,name=leaf_hash,json=leafHash,proto3" json:"leaf_hash,omitempty"`
@@ -298,7 +428,7 @@ type GetLeavesByHashRequest struct {
 func (m *GetLeavesByHashRequest) Reset()                    { *m = GetLeavesByHashRequest{} }
 func (m *GetLeavesByHashRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetLeavesByHashRequest) ProtoMessage()               {}
-func (*GetLeavesByHashRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+func (*GetLeavesByHashRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
 
 type GetLeavesByHashResponse struct {
 	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
@@ -308,7 +438,7 @@ type GetLeavesByHashResponse struct {
 func (m *GetLeavesByHashResponse) Reset()                    { *m = GetLeavesByHashResponse{} }
 func (m *GetLeavesByHashResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetLeavesByHashResponse) ProtoMessage()               {}
-func (*GetLeavesByHashResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+func (*GetLeavesByHashResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
 
 func (m *GetLeavesByHashResponse) GetStatus() *TrillianApiStatus {
 	if m != nil {
@@ -325,14 +455,15 @@ func (m *GetLeavesByHashResponse) GetLeaves() []*LeafProto {
 }
 
 type GetLeavesByIndexRequest struct {
-	LogId     int64   `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
-	LeafIndex []int64 `protobuf:"varint,2,rep,name=leaf_index,json=leafIndex" json:"leaf_index,omitempty"`
+	LogId         int64   `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	LeafIndex     []int64 `protobuf:"varint,2,rep,name=leaf_index,json=leafIndex" json:"leaf_index,omitempty"`
+	OmitExtraData bool    `protobuf:"varint,3,opt,name=omit_extra_data,json=omitExtraData" json:"omit_extra_data,omitempty"`
 }
 
 func (m *GetLeavesByIndexRequest) Reset()                    { *m = GetLeavesByIndexRequest{} }
 func (m *GetLeavesByIndexRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetLeavesByIndexRequest) ProtoMessage()               {}
-func (*GetLeavesByIndexRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+func (*GetLeavesByIndexRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
 
 type GetLeavesByIndexResponse struct {
 	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
@@ -342,7 +473,7 @@ type GetLeavesByIndexResponse struct {
 func (m *GetLeavesByIndexResponse) Reset()                    { *m = GetLeavesByIndexResponse{} }
 func (m *GetLeavesByIndexResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetLeavesByIndexResponse) ProtoMessage()               {}
-func (*GetLeavesByIndexResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+func (*GetLeavesByIndexResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
 
 func (m *GetLeavesByIndexResponse) GetStatus() *TrillianApiStatus {
 	if m != nil {
@@ -358,6 +489,48 @@ func (m *GetLeavesByIndexResponse) GetLeaves() []*LeafProto {
 	return nil
 }
 
+// Requests the sequenced leaves that were integrated into the tree in the half open time
+// range [start_timestamp_nanos, end_timestamp_nanos), in leaf index order.
+type GetLeavesByTimestampRequest struct {
+	LogId               int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	StartTimestampNanos int64 `protobuf:"varint,2,opt,name=start_timestamp_nanos,json=startTimestampNanos" json:"start_timestamp_nanos,omitempty"`
+	EndTimestampNanos   int64 `protobuf:"varint,3,opt,name=end_timestamp_nanos,json=endTimestampNanos" json:"end_timestamp_nanos,omitempty"`
+	// Only leaves with at least this index are returned. To page through a large range
+	// pass one more than the last index received.
+	StartLeafIndex int64 `protobuf:"varint,4,opt,name=start_leaf_index,json=startLeafIndex" json:"start_leaf_index,omitempty"`
+	// The maximum number of leaves to return, must be positive
+	MaxLeaves int64 `protobuf:"varint,5,opt,name=max_leaves,json=maxLeaves" json:"max_leaves,omitempty"`
+}
+
+func (m *GetLeavesByTimestampRequest) Reset()                    { *m = GetLeavesByTimestampRequest{} }
+func (m *GetLeavesByTimestampRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetLeavesByTimestampRequest) ProtoMessage()               {}
+func (*GetLeavesByTimestampRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+
+type GetLeavesByTimestampResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Leaves []*LeafProto       `protobuf:"bytes,2,rep,name=leaves" json:"leaves,omitempty"`
+}
+
+func (m *GetLeavesByTimestampResponse) Reset()                    { *m = GetLeavesByTimestampResponse{} }
+func (m *GetLeavesByTimestampResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetLeavesByTimestampResponse) ProtoMessage()               {}
+func (*GetLeavesByTimestampResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+
+func (m *GetLeavesByTimestampResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *GetLeavesByTimestampResponse) GetLeaves() []*LeafProto {
+	if m != nil {
+		return m.Leaves
+	}
+	return nil
+}
+
 type GetSequencedLeafCountRequest struct {
 	LogId int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
 }
@@ -365,7 +538,7 @@ type GetSequencedLeafCountRequest struct {
 func (m *GetSequencedLeafCountRequest) Reset()                    { *m = GetSequencedLeafCountRequest{} }
 func (m *GetSequencedLeafCountRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetSequencedLeafCountRequest) ProtoMessage()               {}
-func (*GetSequencedLeafCountRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+func (*GetSequencedLeafCountRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
 
 type GetSequencedLeafCountResponse struct {
 	Status    *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
@@ -375,7 +548,7 @@ type GetSequencedLeafCountResponse struct {
 func (m *GetSequencedLeafCountResponse) Reset()                    { *m = GetSequencedLeafCountResponse{} }
 func (m *GetSequencedLeafCountResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetSequencedLeafCountResponse) ProtoMessage()               {}
-func (*GetSequencedLeafCountResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+func (*GetSequencedLeafCountResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
 
 func (m *GetSequencedLeafCountResponse) GetStatus() *TrillianApiStatus {
 	if m != nil {
@@ -391,7 +564,7 @@ type GetLatestSignedLogRootRequest struct {
 func (m *GetLatestSignedLogRootRequest) Reset()                    { *m = GetLatestSignedLogRootRequest{} }
 func (m *GetLatestSignedLogRootRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetLatestSignedLogRootRequest) ProtoMessage()               {}
-func (*GetLatestSignedLogRootRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+func (*GetLatestSignedLogRootRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
 
 type GetLatestSignedLogRootResponse struct {
 	Status        *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
@@ -401,7 +574,7 @@ type GetLatestSignedLogRootResponse struct {
 func (m *GetLatestSignedLogRootResponse) Reset()                    { *m = GetLatestSignedLogRootResponse{} }
 func (m *GetLatestSignedLogRootResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetLatestSignedLogRootResponse) ProtoMessage()               {}
-func (*GetLatestSignedLogRootResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+func (*GetLatestSignedLogRootResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
 
 func (m *GetLatestSignedLogRootResponse) GetStatus() *TrillianApiStatus {
 	if m != nil {
@@ -417,6 +590,130 @@ func (m *GetLatestSignedLogRootResponse) GetSignedLogRoot() *SignedLogRoot {
 	return nil
 }
 
+// GetLatestSignedLogRootsRequest asks for the latest roots of several logs in one call, e.g.
+// for frontends that serve many logs.
+type GetLatestSignedLogRootsRequest struct {
+	LogIds []int64 `protobuf:"varint,1,rep,name=log_ids,json=logIds" json:"log_ids,omitempty"`
+}
+
+func (m *GetLatestSignedLogRootsRequest) Reset()         { *m = GetLatestSignedLogRootsRequest{} }
+func (m *GetLatestSignedLogRootsRequest) String() string { return proto.CompactTextString(m) }
+func (*GetLatestSignedLogRootsRequest) ProtoMessage()    {}
+func (*GetLatestSignedLogRootsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{24}
+}
+
+// LogRootResult is the latest root of one of the logs in a GetLatestSignedLogRootsRequest, or
+// why it couldn't be read.
+type LogRootResult struct {
+	LogId int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	// The status of reading this log's root. A failure doesn't affect the other logs.
+	Status *TrillianApiStatus `protobuf:"bytes,2,opt,name=status" json:"status,omitempty"`
+	// Not set unless the status is OK
+	SignedLogRoot *SignedLogRoot `protobuf:"bytes,3,opt,name=signed_log_root,json=signedLogRoot" json:"signed_log_root,omitempty"`
+}
+
+func (m *LogRootResult) Reset()                    { *m = LogRootResult{} }
+func (m *LogRootResult) String() string            { return proto.CompactTextString(m) }
+func (*LogRootResult) ProtoMessage()               {}
+func (*LogRootResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+
+func (m *LogRootResult) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *LogRootResult) GetSignedLogRoot() *SignedLogRoot {
+	if m != nil {
+		return m.SignedLogRoot
+	}
+	return nil
+}
+
+type GetLatestSignedLogRootsResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	// One result for each log ID in the request, in the same order
+	Results []*LogRootResult `protobuf:"bytes,2,rep,name=results" json:"results,omitempty"`
+}
+
+func (m *GetLatestSignedLogRootsResponse) Reset()         { *m = GetLatestSignedLogRootsResponse{} }
+func (m *GetLatestSignedLogRootsResponse) String() string { return proto.CompactTextString(m) }
+func (*GetLatestSignedLogRootsResponse) ProtoMessage()    {}
+func (*GetLatestSignedLogRootsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{26}
+}
+
+func (m *GetLatestSignedLogRootsResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *GetLatestSignedLogRootsResponse) GetResults() []*LogRootResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+// GetLatestSignedLogRootAndLeavesRequest asks for the latest root of a log along with the last
+// leaves of the tree it commits to, read from the same snapshot so that they're consistent.
+type GetLatestSignedLogRootAndLeavesRequest struct {
+	LogId int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	// The max number of leaves to return, counting back from the end of the tree
+	MaxLeaves int64 `protobuf:"varint,2,opt,name=max_leaves,json=maxLeaves" json:"max_leaves,omitempty"`
+}
+
+func (m *GetLatestSignedLogRootAndLeavesRequest) Reset() {
+	*m = GetLatestSignedLogRootAndLeavesRequest{}
+}
+func (m *GetLatestSignedLogRootAndLeavesRequest) String() string { return proto.CompactTextString(m) }
+func (*GetLatestSignedLogRootAndLeavesRequest) ProtoMessage()    {}
+func (*GetLatestSignedLogRootAndLeavesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{27}
+}
+
+type GetLatestSignedLogRootAndLeavesResponse struct {
+	Status        *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	SignedLogRoot *SignedLogRoot     `protobuf:"bytes,2,opt,name=signed_log_root,json=signedLogRoot" json:"signed_log_root,omitempty"`
+	// The leaves at the end of the tree the root commits to, in leaf index order. There are
+	// fewer than max_leaves if the tree is smaller.
+	Leaves []*LeafProto `protobuf:"bytes,3,rep,name=leaves" json:"leaves,omitempty"`
+}
+
+func (m *GetLatestSignedLogRootAndLeavesResponse) Reset() {
+	*m = GetLatestSignedLogRootAndLeavesResponse{}
+}
+func (m *GetLatestSignedLogRootAndLeavesResponse) String() string { return proto.CompactTextString(m) }
+func (*GetLatestSignedLogRootAndLeavesResponse) ProtoMessage()    {}
+func (*GetLatestSignedLogRootAndLeavesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{28}
+}
+
+func (m *GetLatestSignedLogRootAndLeavesResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *GetLatestSignedLogRootAndLeavesResponse) GetSignedLogRoot() *SignedLogRoot {
+	if m != nil {
+		return m.SignedLogRoot
+	}
+	return nil
+}
+
+func (m *GetLatestSignedLogRootAndLeavesResponse) GetLeaves() []*LeafProto {
+	if m != nil {
+		return m.Leaves
+	}
+	return nil
+}
+
 type GetEntryAndProofRequest struct {
 	LogId     int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
 	LeafIndex int64 `protobuf:"varint,2,opt,name=leaf_index,json=leafIndex" json:"leaf_index,omitempty"`
@@ -426,7 +723,7 @@ type GetEntryAndProofRequest struct {
 func (m *GetEntryAndProofRequest) Reset()                    { *m = GetEntryAndProofRequest{} }
 func (m *GetEntryAndProofRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetEntryAndProofRequest) ProtoMessage()               {}
-func (*GetEntryAndProofRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+func (*GetEntryAndProofRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
 
 type GetEntryAndProofResponse struct {
 	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
@@ -437,7 +734,7 @@ type GetEntryAndProofResponse struct {
 func (m *GetEntryAndProofResponse) Reset()                    { *m = GetEntryAndProofResponse{} }
 func (m *GetEntryAndProofResponse) String() string            { return proto.CompactTextString(m) }
 func (*GetEntryAndProofResponse) ProtoMessage()               {}
-func (*GetEntryAndProofResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+func (*GetEntryAndProofResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
 
 func (m *GetEntryAndProofResponse) GetStatus() *TrillianApiStatus {
 	if m != nil {
@@ -460,203 +757,767 @@ func (m *GetEntryAndProofResponse) GetLeaf() *LeafProto {
 	return nil
 }
 
-// MapLeaf represents the data behind Map leaves.
-type MapLeaf struct {
-	// leaf_hash is the tree hash of leaf_value.
-	LeafHash []byte `protobuf:"bytes,1,opt,name=leaf_hash,json=leafHash,proto3" json:"leaf_hash,omitempty"`
-	// leaf_value is the data the tree commits to.
-	LeafValue []byte `protobuf:"bytes,2,opt,name=leaf_value,json=leafValue,proto3" json:"leaf_value,omitempty"`
-	// extra_data holds related contextual data, but is not covered by any hash.
-	ExtraData []byte `protobuf:"bytes,3,opt,name=extra_data,json=extraData,proto3" json:"extra_data,omitempty"`
+// GetRevisionDiffRequest asks for a comparison of the tree at two revisions that each have a
+// stored tree head. It's intended for operators investigating suspected divergence.
+type GetRevisionDiffRequest struct {
+	LogId             int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	FirstTreeRevision int64 `protobuf:"varint,2,opt,name=first_tree_revision,json=firstTreeRevision" json:"first_tree_revision,omitempty"`
+	// Must be greater than first_tree_revision
+	SecondTreeRevision int64 `protobuf:"varint,3,opt,name=second_tree_revision,json=secondTreeRevision" json:"second_tree_revision,omitempty"`
+}
+
+func (m *GetRevisionDiffRequest) Reset()                    { *m = GetRevisionDiffRequest{} }
+func (m *GetRevisionDiffRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetRevisionDiffRequest) ProtoMessage()               {}
+func (*GetRevisionDiffRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{31} }
+
+// NodeDiffProto describes a tree node that has different hashes at the two revisions. A
+// missing hash means the node was not present in storage at that revision.
+type NodeDiffProto struct {
+	NodeId         []byte `protobuf:"bytes,1,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	FirstNodeHash  []byte `protobuf:"bytes,2,opt,name=first_node_hash,json=firstNodeHash,proto3" json:"first_node_hash,omitempty"`
+	SecondNodeHash []byte `protobuf:"bytes,3,opt,name=second_node_hash,json=secondNodeHash,proto3" json:"second_node_hash,omitempty"`
+}
+
+func (m *NodeDiffProto) Reset()                    { *m = NodeDiffProto{} }
+func (m *NodeDiffProto) String() string            { return proto.CompactTextString(m) }
+func (*NodeDiffProto) ProtoMessage()               {}
+func (*NodeDiffProto) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{32} }
+
+type GetRevisionDiffResponse struct {
+	Status              *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	FirstSignedLogRoot  *SignedLogRoot     `protobuf:"bytes,2,opt,name=first_signed_log_root,json=firstSignedLogRoot" json:"first_signed_log_root,omitempty"`
+	SecondSignedLogRoot *SignedLogRoot     `protobuf:"bytes,3,opt,name=second_signed_log_root,json=secondSignedLogRoot" json:"second_signed_log_root,omitempty"`
+	// The consistency proof from the first tree size to the second, as stored at the second
+	// revision. Not set if the tree did not grow.
+	Proof *ProofProto `protobuf:"bytes,4,opt,name=proof" json:"proof,omitempty"`
+	// The number of nodes compared. These are the perfect subtrees making up the smaller of
+	// the two trees, which must not change as a log grows.
+	NodesCompared int64 `protobuf:"varint,5,opt,name=nodes_compared,json=nodesCompared" json:"nodes_compared,omitempty"`
+	// The compared nodes that differ. This should be empty for a healthy log.
+	NodeDiff []*NodeDiffProto `protobuf:"bytes,6,rep,name=node_diff,json=nodeDiff" json:"node_diff,omitempty"`
+}
+
+func (m *GetRevisionDiffResponse) Reset()                    { *m = GetRevisionDiffResponse{} }
+func (m *GetRevisionDiffResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetRevisionDiffResponse) ProtoMessage()               {}
+func (*GetRevisionDiffResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{33} }
+
+func (m *GetRevisionDiffResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
 }
 
-func (m *MapLeaf) Reset()                    { *m = MapLeaf{} }
-func (m *MapLeaf) String() string            { return proto.CompactTextString(m) }
-func (*MapLeaf) ProtoMessage()               {}
-func (*MapLeaf) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
-
-type KeyValue struct {
-	Key   []byte   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
-	Value *MapLeaf `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+func (m *GetRevisionDiffResponse) GetFirstSignedLogRoot() *SignedLogRoot {
+	if m != nil {
+		return m.FirstSignedLogRoot
+	}
+	return nil
 }
 
-func (m *KeyValue) Reset()                    { *m = KeyValue{} }
-func (m *KeyValue) String() string            { return proto.CompactTextString(m) }
-func (*KeyValue) ProtoMessage()               {}
-func (*KeyValue) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
-
-func (m *KeyValue) GetValue() *MapLeaf {
+func (m *GetRevisionDiffResponse) GetSecondSignedLogRoot() *SignedLogRoot {
 	if m != nil {
-		return m.Value
+		return m.SecondSignedLogRoot
 	}
 	return nil
 }
 
-type KeyValueInclusion struct {
-	KeyValue  *KeyValue `protobuf:"bytes,1,opt,name=key_value,json=keyValue" json:"key_value,omitempty"`
-	Inclusion [][]byte  `protobuf:"bytes,2,rep,name=inclusion,proto3" json:"inclusion,omitempty"`
+func (m *GetRevisionDiffResponse) GetProof() *ProofProto {
+	if m != nil {
+		return m.Proof
+	}
+	return nil
 }
 
-func (m *KeyValueInclusion) Reset()                    { *m = KeyValueInclusion{} }
-func (m *KeyValueInclusion) String() string            { return proto.CompactTextString(m) }
-func (*KeyValueInclusion) ProtoMessage()               {}
-func (*KeyValueInclusion) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
-
-func (m *KeyValueInclusion) GetKeyValue() *KeyValue {
+func (m *GetRevisionDiffResponse) GetNodeDiff() []*NodeDiffProto {
 	if m != nil {
-		return m.KeyValue
+		return m.NodeDiff
 	}
 	return nil
 }
 
-type GetMapLeavesRequest struct {
-	MapId    int64    `protobuf:"varint,1,opt,name=map_id,json=mapId" json:"map_id,omitempty"`
-	Key      [][]byte `protobuf:"bytes,2,rep,name=key,proto3" json:"key,omitempty"`
-	Revision int64    `protobuf:"varint,3,opt,name=revision" json:"revision,omitempty"`
+// FlushLogRequest asks for the log to be sequenced straight away rather than when the
+// sequencer next reaches it.
+type FlushLogRequest struct {
+	LogId int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	// If set a new root is signed even if there are no leaves to integrate
+	ForceNewRoot bool `protobuf:"varint,2,opt,name=force_new_root,json=forceNewRoot" json:"force_new_root,omitempty"`
 }
 
-func (m *GetMapLeavesRequest) Reset()                    { *m = GetMapLeavesRequest{} }
-func (m *GetMapLeavesRequest) String() string            { return proto.CompactTextString(m) }
-func (*GetMapLeavesRequest) ProtoMessage()               {}
-func (*GetMapLeavesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+func (m *FlushLogRequest) Reset()                    { *m = FlushLogRequest{} }
+func (m *FlushLogRequest) String() string            { return proto.CompactTextString(m) }
+func (*FlushLogRequest) ProtoMessage()               {}
+func (*FlushLogRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{34} }
 
-type GetMapLeavesResponse struct {
-	Status   *TrillianApiStatus   `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
-	KeyValue []*KeyValueInclusion `protobuf:"bytes,2,rep,name=key_value,json=keyValue" json:"key_value,omitempty"`
-	MapRoot  *SignedMapRoot       `protobuf:"bytes,3,opt,name=map_root,json=mapRoot" json:"map_root,omitempty"`
+type FlushLogResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	// The number of leaves integrated by the flush
+	LeavesIntegrated int64 `protobuf:"varint,2,opt,name=leaves_integrated,json=leavesIntegrated" json:"leaves_integrated,omitempty"`
+	// The latest root after the flush
+	SignedLogRoot *SignedLogRoot `protobuf:"bytes,3,opt,name=signed_log_root,json=signedLogRoot" json:"signed_log_root,omitempty"`
 }
 
-func (m *GetMapLeavesResponse) Reset()                    { *m = GetMapLeavesResponse{} }
-func (m *GetMapLeavesResponse) String() string            { return proto.CompactTextString(m) }
-func (*GetMapLeavesResponse) ProtoMessage()               {}
-func (*GetMapLeavesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
+func (m *FlushLogResponse) Reset()                    { *m = FlushLogResponse{} }
+func (m *FlushLogResponse) String() string            { return proto.CompactTextString(m) }
+func (*FlushLogResponse) ProtoMessage()               {}
+func (*FlushLogResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{35} }
 
-func (m *GetMapLeavesResponse) GetStatus() *TrillianApiStatus {
+func (m *FlushLogResponse) GetStatus() *TrillianApiStatus {
 	if m != nil {
 		return m.Status
 	}
 	return nil
 }
 
-func (m *GetMapLeavesResponse) GetKeyValue() []*KeyValueInclusion {
+func (m *FlushLogResponse) GetSignedLogRoot() *SignedLogRoot {
 	if m != nil {
-		return m.KeyValue
+		return m.SignedLogRoot
 	}
 	return nil
 }
 
-func (m *GetMapLeavesResponse) GetMapRoot() *SignedMapRoot {
+// SubscribeTreeEventsRequest asks for the events of a log as they happen. If log_id is zero
+// the events of every log handled by the server are sent.
+type SubscribeTreeEventsRequest struct {
+	LogId int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+}
+
+func (m *SubscribeTreeEventsRequest) Reset()                    { *m = SubscribeTreeEventsRequest{} }
+func (m *SubscribeTreeEventsRequest) String() string            { return proto.CompactTextString(m) }
+func (*SubscribeTreeEventsRequest) ProtoMessage()               {}
+func (*SubscribeTreeEventsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{36} }
+
+// TreeEvent is something that happened to a log. Events are not stored, a subscriber only
+// sees those that happen while it's connected.
+type TreeEvent struct {
+	EventType TreeEventType `protobuf:"varint,1,opt,name=event_type,json=eventType,enum=trillian.TreeEventType" json:"event_type,omitempty"`
+	LogId     int64         `protobuf:"varint,2,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	// The new root for NEW_SIGNED_ROOT, the final root for TREE_FROZEN
+	SignedLogRoot *SignedLogRoot `protobuf:"bytes,3,opt,name=signed_log_root,json=signedLogRoot" json:"signed_log_root,omitempty"`
+	// What went wrong for SEQUENCING_ERROR
+	Error string `protobuf:"bytes,4,opt,name=error" json:"error,omitempty"`
+	// When the event happened, in nanoseconds since the epoch
+	TimestampNanos int64 `protobuf:"varint,5,opt,name=timestamp_nanos,json=timestampNanos" json:"timestamp_nanos,omitempty"`
+}
+
+func (m *TreeEvent) Reset()                    { *m = TreeEvent{} }
+func (m *TreeEvent) String() string            { return proto.CompactTextString(m) }
+func (*TreeEvent) ProtoMessage()               {}
+func (*TreeEvent) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{37} }
+
+func (m *TreeEvent) GetSignedLogRoot() *SignedLogRoot {
 	if m != nil {
-		return m.MapRoot
+		return m.SignedLogRoot
 	}
 	return nil
 }
 
-type SetMapLeavesRequest struct {
-	MapId      int64           `protobuf:"varint,1,opt,name=map_id,json=mapId" json:"map_id,omitempty"`
-	KeyValue   []*KeyValue     `protobuf:"bytes,2,rep,name=key_value,json=keyValue" json:"key_value,omitempty"`
-	MapperData *MapperMetadata `protobuf:"bytes,3,opt,name=mapper_data,json=mapperData" json:"mapper_data,omitempty"`
+// LogFeatureProto is a log's own setting for a feature flag
+type LogFeatureProto struct {
+	LogId   int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	Enabled bool  `protobuf:"varint,2,opt,name=enabled" json:"enabled,omitempty"`
 }
 
-func (m *SetMapLeavesRequest) Reset()                    { *m = SetMapLeavesRequest{} }
-func (m *SetMapLeavesRequest) String() string            { return proto.CompactTextString(m) }
-func (*SetMapLeavesRequest) ProtoMessage()               {}
-func (*SetMapLeavesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
+func (m *LogFeatureProto) Reset()                    { *m = LogFeatureProto{} }
+func (m *LogFeatureProto) String() string            { return proto.CompactTextString(m) }
+func (*LogFeatureProto) ProtoMessage()               {}
+func (*LogFeatureProto) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{38} }
 
-func (m *SetMapLeavesRequest) GetKeyValue() []*KeyValue {
+// FeatureProto is the state of a feature flag
+type FeatureProto struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// Whether the feature is on for logs that don't have their own setting
+	Enabled bool               `protobuf:"varint,2,opt,name=enabled" json:"enabled,omitempty"`
+	Logs    []*LogFeatureProto `protobuf:"bytes,3,rep,name=logs" json:"logs,omitempty"`
+}
+
+func (m *FeatureProto) Reset()                    { *m = FeatureProto{} }
+func (m *FeatureProto) String() string            { return proto.CompactTextString(m) }
+func (*FeatureProto) ProtoMessage()               {}
+func (*FeatureProto) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{39} }
+
+func (m *FeatureProto) GetLogs() []*LogFeatureProto {
 	if m != nil {
-		return m.KeyValue
+		return m.Logs
 	}
 	return nil
 }
 
-func (m *SetMapLeavesRequest) GetMapperData() *MapperMetadata {
+// SetFeatureRequest turns a feature on or off for a log, or for all logs that don't have their
+// own setting if log_id is zero.
+type SetFeatureRequest struct {
+	Name    string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	LogId   int64  `protobuf:"varint,2,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	Enabled bool   `protobuf:"varint,3,opt,name=enabled" json:"enabled,omitempty"`
+	// If set the log's own setting is removed instead, or if log_id is zero the feature is
+	// turned off for all logs
+	Reset_ bool `protobuf:"varint,4,opt,name=reset" json:"reset,omitempty"`
+}
+
+func (m *SetFeatureRequest) Reset()                    { *m = SetFeatureRequest{} }
+func (m *SetFeatureRequest) String() string            { return proto.CompactTextString(m) }
+func (*SetFeatureRequest) ProtoMessage()               {}
+func (*SetFeatureRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{40} }
+
+type SetFeatureResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	// The state of the feature after the change
+	Feature *FeatureProto `protobuf:"bytes,2,opt,name=feature" json:"feature,omitempty"`
+}
+
+func (m *SetFeatureResponse) Reset()                    { *m = SetFeatureResponse{} }
+func (m *SetFeatureResponse) String() string            { return proto.CompactTextString(m) }
+func (*SetFeatureResponse) ProtoMessage()               {}
+func (*SetFeatureResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{41} }
+
+func (m *SetFeatureResponse) GetStatus() *TrillianApiStatus {
 	if m != nil {
-		return m.MapperData
+		return m.Status
 	}
 	return nil
 }
 
-type SetMapLeavesResponse struct {
-	Status  *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
-	MapRoot *SignedMapRoot     `protobuf:"bytes,2,opt,name=map_root,json=mapRoot" json:"map_root,omitempty"`
+func (m *SetFeatureResponse) GetFeature() *FeatureProto {
+	if m != nil {
+		return m.Feature
+	}
+	return nil
 }
 
-func (m *SetMapLeavesResponse) Reset()                    { *m = SetMapLeavesResponse{} }
-func (m *SetMapLeavesResponse) String() string            { return proto.CompactTextString(m) }
-func (*SetMapLeavesResponse) ProtoMessage()               {}
-func (*SetMapLeavesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
+type ListFeaturesRequest struct {
+}
 
-func (m *SetMapLeavesResponse) GetStatus() *TrillianApiStatus {
+func (m *ListFeaturesRequest) Reset()                    { *m = ListFeaturesRequest{} }
+func (m *ListFeaturesRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListFeaturesRequest) ProtoMessage()               {}
+func (*ListFeaturesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{42} }
+
+type ListFeaturesResponse struct {
+	Status   *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Features []*FeatureProto    `protobuf:"bytes,2,rep,name=features" json:"features,omitempty"`
+}
+
+func (m *ListFeaturesResponse) Reset()                    { *m = ListFeaturesResponse{} }
+func (m *ListFeaturesResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListFeaturesResponse) ProtoMessage()               {}
+func (*ListFeaturesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{43} }
+
+func (m *ListFeaturesResponse) GetStatus() *TrillianApiStatus {
 	if m != nil {
 		return m.Status
 	}
 	return nil
 }
 
-func (m *SetMapLeavesResponse) GetMapRoot() *SignedMapRoot {
+func (m *ListFeaturesResponse) GetFeatures() []*FeatureProto {
 	if m != nil {
-		return m.MapRoot
+		return m.Features
 	}
 	return nil
 }
 
-type GetSignedMapRootRequest struct {
-	MapId int64 `protobuf:"varint,1,opt,name=map_id,json=mapId" json:"map_id,omitempty"`
+// TreeMetadata is human readable information that identifies a tree to the people running it
+// and its users. None of it affects how the tree works.
+type TreeMetadata struct {
+	DisplayName string `protobuf:"bytes,1,opt,name=display_name,json=displayName" json:"display_name,omitempty"`
+	Description string `protobuf:"bytes,2,opt,name=description" json:"description,omitempty"`
+	// How to reach the tree's owner, e.g. an email address
+	OwnerContact string `protobuf:"bytes,3,opt,name=owner_contact,json=ownerContact" json:"owner_contact,omitempty"`
+	// When the tree was created, epoch nanoseconds. It's set by storage.
+	CreateTimeNanos int64 `protobuf:"varint,4,opt,name=create_time_nanos,json=createTimeNanos" json:"create_time_nanos,omitempty"`
 }
 
-func (m *GetSignedMapRootRequest) Reset()                    { *m = GetSignedMapRootRequest{} }
-func (m *GetSignedMapRootRequest) String() string            { return proto.CompactTextString(m) }
-func (*GetSignedMapRootRequest) ProtoMessage()               {}
-func (*GetSignedMapRootRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
+func (m *TreeMetadata) Reset()                    { *m = TreeMetadata{} }
+func (m *TreeMetadata) String() string            { return proto.CompactTextString(m) }
+func (*TreeMetadata) ProtoMessage()               {}
+func (*TreeMetadata) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{44} }
 
-type GetSignedMapRootResponse struct {
-	Status  *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
-	MapRoot *SignedMapRoot     `protobuf:"bytes,2,opt,name=map_root,json=mapRoot" json:"map_root,omitempty"`
+type GetTreeMetadataRequest struct {
+	LogId int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
 }
 
-func (m *GetSignedMapRootResponse) Reset()                    { *m = GetSignedMapRootResponse{} }
-func (m *GetSignedMapRootResponse) String() string            { return proto.CompactTextString(m) }
-func (*GetSignedMapRootResponse) ProtoMessage()               {}
-func (*GetSignedMapRootResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
+func (m *GetTreeMetadataRequest) Reset()                    { *m = GetTreeMetadataRequest{} }
+func (m *GetTreeMetadataRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetTreeMetadataRequest) ProtoMessage()               {}
+func (*GetTreeMetadataRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{45} }
 
-func (m *GetSignedMapRootResponse) GetStatus() *TrillianApiStatus {
+type GetTreeMetadataResponse struct {
+	Status   *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Metadata *TreeMetadata      `protobuf:"bytes,2,opt,name=metadata" json:"metadata,omitempty"`
+	// How the tree hashes its leaves and nodes, fixed when it was created. Tools that
+	// recompute the tree from its leaves need these.
+	HashAlgorithm    HashAlgorithm    `protobuf:"varint,3,opt,name=hash_algorithm,json=hashAlgorithm,enum=trillian.HashAlgorithm" json:"hash_algorithm,omitempty"`
+	LeafHashStrategy LeafHashStrategy `protobuf:"varint,4,opt,name=leaf_hash_strategy,json=leafHashStrategy,enum=trillian.LeafHashStrategy" json:"leaf_hash_strategy,omitempty"`
+}
+
+func (m *GetTreeMetadataResponse) Reset()                    { *m = GetTreeMetadataResponse{} }
+func (m *GetTreeMetadataResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetTreeMetadataResponse) ProtoMessage()               {}
+func (*GetTreeMetadataResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{46} }
+
+func (m *GetTreeMetadataResponse) GetStatus() *TrillianApiStatus {
 	if m != nil {
 		return m.Status
 	}
 	return nil
 }
 
-func (m *GetSignedMapRootResponse) GetMapRoot() *SignedMapRoot {
+func (m *GetTreeMetadataResponse) GetMetadata() *TreeMetadata {
 	if m != nil {
-		return m.MapRoot
+		return m.Metadata
 	}
 	return nil
 }
 
-func init() {
-	proto.RegisterType((*TrillianApiStatus)(nil), "trillian.TrillianApiStatus")
-	proto.RegisterType((*LeafProto)(nil), "trillian.LeafProto")
-	proto.RegisterType((*NodeProto)(nil), "trillian.NodeProto")
-	proto.RegisterType((*ProofProto)(nil), "trillian.ProofProto")
-	proto.RegisterType((*QueueLeavesRequest)(nil), "trillian.QueueLeavesRequest")
-	proto.RegisterType((*QueueLeavesResponse)(nil), "trillian.QueueLeavesResponse")
-	proto.RegisterType((*GetInclusionProofRequest)(nil), "trillian.GetInclusionProofRequest")
-	proto.RegisterType((*GetInclusionProofResponse)(nil), "trillian.GetInclusionProofResponse")
-	proto.RegisterType((*GetInclusionProofByHashRequest)(nil), "trillian.GetInclusionProofByHashRequest")
-	proto.RegisterType((*GetInclusionProofByHashResponse)(nil), "trillian.GetInclusionProofByHashResponse")
-	proto.RegisterType((*GetConsistencyProofRequest)(nil), "trillian.GetConsistencyProofRequest")
+// SetTreeMetadataRequest replaces the metadata of a log. The creation time can't be changed
+// and is ignored.
+type SetTreeMetadataRequest struct {
+	LogId    int64         `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	Metadata *TreeMetadata `protobuf:"bytes,2,opt,name=metadata" json:"metadata,omitempty"`
+}
+
+func (m *SetTreeMetadataRequest) Reset()                    { *m = SetTreeMetadataRequest{} }
+func (m *SetTreeMetadataRequest) String() string            { return proto.CompactTextString(m) }
+func (*SetTreeMetadataRequest) ProtoMessage()               {}
+func (*SetTreeMetadataRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{47} }
+
+func (m *SetTreeMetadataRequest) GetMetadata() *TreeMetadata {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+type SetTreeMetadataResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	// The metadata after the change
+	Metadata *TreeMetadata `protobuf:"bytes,2,opt,name=metadata" json:"metadata,omitempty"`
+}
+
+func (m *SetTreeMetadataResponse) Reset()                    { *m = SetTreeMetadataResponse{} }
+func (m *SetTreeMetadataResponse) String() string            { return proto.CompactTextString(m) }
+func (*SetTreeMetadataResponse) ProtoMessage()               {}
+func (*SetTreeMetadataResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{48} }
+
+func (m *SetTreeMetadataResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *SetTreeMetadataResponse) GetMetadata() *TreeMetadata {
+	if m != nil {
+		return m.Metadata
+	}
+	return nil
+}
+
+// TableStorageStats is how much storage a tree is using in one of its tables.
+type TableStorageStats struct {
+	// The table, "leaf", "node" or "root"
+	Table    string `protobuf:"bytes,1,opt,name=table" json:"table,omitempty"`
+	RowCount int64  `protobuf:"varint,2,opt,name=row_count,json=rowCount" json:"row_count,omitempty"`
+	// The size of the data in the rows. Indexes and per row overheads aren't included.
+	ByteSize int64 `protobuf:"varint,3,opt,name=byte_size,json=byteSize" json:"byte_size,omitempty"`
+	// Average growth per day. Once stats have been kept for long enough it's measured over
+	// at least the last day.
+	RowsPerDay  float64 `protobuf:"fixed64,4,opt,name=rows_per_day,json=rowsPerDay" json:"rows_per_day,omitempty"`
+	BytesPerDay float64 `protobuf:"fixed64,5,opt,name=bytes_per_day,json=bytesPerDay" json:"bytes_per_day,omitempty"`
+}
+
+func (m *TableStorageStats) Reset()                    { *m = TableStorageStats{} }
+func (m *TableStorageStats) String() string            { return proto.CompactTextString(m) }
+func (*TableStorageStats) ProtoMessage()               {}
+func (*TableStorageStats) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{49} }
+
+type GetTreeStorageStatsRequest struct {
+	LogId int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+}
+
+func (m *GetTreeStorageStatsRequest) Reset()                    { *m = GetTreeStorageStatsRequest{} }
+func (m *GetTreeStorageStatsRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetTreeStorageStatsRequest) ProtoMessage()               {}
+func (*GetTreeStorageStatsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{50} }
+
+type GetTreeStorageStatsResponse struct {
+	Status *TrillianApiStatus   `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Tables []*TableStorageStats `protobuf:"bytes,2,rep,name=tables" json:"tables,omitempty"`
+}
+
+func (m *GetTreeStorageStatsResponse) Reset()                    { *m = GetTreeStorageStatsResponse{} }
+func (m *GetTreeStorageStatsResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetTreeStorageStatsResponse) ProtoMessage()               {}
+func (*GetTreeStorageStatsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{51} }
+
+func (m *GetTreeStorageStatsResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *GetTreeStorageStatsResponse) GetTables() []*TableStorageStats {
+	if m != nil {
+		return m.Tables
+	}
+	return nil
+}
+
+// PauseSequencingRequest stops a log's leaves being sequenced and its roots being signed until
+// it's resumed. Leaves can still be queued.
+type PauseSequencingRequest struct {
+	LogId int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+}
+
+func (m *PauseSequencingRequest) Reset()                    { *m = PauseSequencingRequest{} }
+func (m *PauseSequencingRequest) String() string            { return proto.CompactTextString(m) }
+func (*PauseSequencingRequest) ProtoMessage()               {}
+func (*PauseSequencingRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{52} }
+
+type PauseSequencingResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+}
+
+func (m *PauseSequencingResponse) Reset()                    { *m = PauseSequencingResponse{} }
+func (m *PauseSequencingResponse) String() string            { return proto.CompactTextString(m) }
+func (*PauseSequencingResponse) ProtoMessage()               {}
+func (*PauseSequencingResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{53} }
+
+func (m *PauseSequencingResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+type ResumeSequencingRequest struct {
+	LogId int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+}
+
+func (m *ResumeSequencingRequest) Reset()                    { *m = ResumeSequencingRequest{} }
+func (m *ResumeSequencingRequest) String() string            { return proto.CompactTextString(m) }
+func (*ResumeSequencingRequest) ProtoMessage()               {}
+func (*ResumeSequencingRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{54} }
+
+type ResumeSequencingResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+}
+
+func (m *ResumeSequencingResponse) Reset()                    { *m = ResumeSequencingResponse{} }
+func (m *ResumeSequencingResponse) String() string            { return proto.CompactTextString(m) }
+func (*ResumeSequencingResponse) ProtoMessage()               {}
+func (*ResumeSequencingResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{55} }
+
+func (m *ResumeSequencingResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+// MapLeaf represents the data behind Map leaves.
+type MapLeaf struct {
+	// leaf_hash is the tree hash of leaf_value.
+	LeafHash []byte `protobuf:"bytes,1,opt,name=leaf_hash,json=leafHash,proto3" json:"leaf_hash,omitempty"`
+	// leaf_value is the data the tree commits to.
+	LeafValue []byte `protobuf:"bytes,2,opt,name=leaf_value,json=leafValue,proto3" json:"leaf_value,omitempty"`
+	// extra_data holds related contextual data, but is not covered by any hash.
+	ExtraData []byte `protobuf:"bytes,3,opt,name=extra_data,json=extraData,proto3" json:"extra_data,omitempty"`
+}
+
+func (m *MapLeaf) Reset()                    { *m = MapLeaf{} }
+func (m *MapLeaf) String() string            { return proto.CompactTextString(m) }
+func (*MapLeaf) ProtoMessage()               {}
+func (*MapLeaf) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{56} }
+
+type KeyValue struct {
+	Key   []byte   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value *MapLeaf `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+}
+
+func (m *KeyValue) Reset()                    { *m = KeyValue{} }
+func (m *KeyValue) String() string            { return proto.CompactTextString(m) }
+func (*KeyValue) ProtoMessage()               {}
+func (*KeyValue) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{57} }
+
+func (m *KeyValue) GetValue() *MapLeaf {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+type KeyValueInclusion struct {
+	KeyValue  *KeyValue `protobuf:"bytes,1,opt,name=key_value,json=keyValue" json:"key_value,omitempty"`
+	Inclusion [][]byte  `protobuf:"bytes,2,rep,name=inclusion,proto3" json:"inclusion,omitempty"`
+}
+
+func (m *KeyValueInclusion) Reset()                    { *m = KeyValueInclusion{} }
+func (m *KeyValueInclusion) String() string            { return proto.CompactTextString(m) }
+func (*KeyValueInclusion) ProtoMessage()               {}
+func (*KeyValueInclusion) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{58} }
+
+func (m *KeyValueInclusion) GetKeyValue() *KeyValue {
+	if m != nil {
+		return m.KeyValue
+	}
+	return nil
+}
+
+type GetMapLeavesRequest struct {
+	MapId    int64    `protobuf:"varint,1,opt,name=map_id,json=mapId" json:"map_id,omitempty"`
+	Key      [][]byte `protobuf:"bytes,2,rep,name=key,proto3" json:"key,omitempty"`
+	Revision int64    `protobuf:"varint,3,opt,name=revision" json:"revision,omitempty"`
+	// If omit_inclusion is set no inclusion proofs are generated and only the leaf values are
+	// returned. This is much cheaper but the values cannot be verified against the map root,
+	// so it is only for trusted callers. Auditors should leave it unset.
+	OmitInclusion bool `protobuf:"varint,4,opt,name=omit_inclusion,json=omitInclusion" json:"omit_inclusion,omitempty"`
+}
+
+func (m *GetMapLeavesRequest) Reset()                    { *m = GetMapLeavesRequest{} }
+func (m *GetMapLeavesRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetMapLeavesRequest) ProtoMessage()               {}
+func (*GetMapLeavesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{59} }
+
+type GetMapLeavesResponse struct {
+	Status   *TrillianApiStatus   `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	KeyValue []*KeyValueInclusion `protobuf:"bytes,2,rep,name=key_value,json=keyValue" json:"key_value,omitempty"`
+	MapRoot  *SignedMapRoot       `protobuf:"bytes,3,opt,name=map_root,json=mapRoot" json:"map_root,omitempty"`
+}
+
+func (m *GetMapLeavesResponse) Reset()                    { *m = GetMapLeavesResponse{} }
+func (m *GetMapLeavesResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetMapLeavesResponse) ProtoMessage()               {}
+func (*GetMapLeavesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{60} }
+
+func (m *GetMapLeavesResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *GetMapLeavesResponse) GetKeyValue() []*KeyValueInclusion {
+	if m != nil {
+		return m.KeyValue
+	}
+	return nil
+}
+
+func (m *GetMapLeavesResponse) GetMapRoot() *SignedMapRoot {
+	if m != nil {
+		return m.MapRoot
+	}
+	return nil
+}
+
+type SetMapLeavesRequest struct {
+	MapId      int64           `protobuf:"varint,1,opt,name=map_id,json=mapId" json:"map_id,omitempty"`
+	KeyValue   []*KeyValue     `protobuf:"bytes,2,rep,name=key_value,json=keyValue" json:"key_value,omitempty"`
+	MapperData *MapperMetadata `protobuf:"bytes,3,opt,name=mapper_data,json=mapperData" json:"mapper_data,omitempty"`
+}
+
+func (m *SetMapLeavesRequest) Reset()                    { *m = SetMapLeavesRequest{} }
+func (m *SetMapLeavesRequest) String() string            { return proto.CompactTextString(m) }
+func (*SetMapLeavesRequest) ProtoMessage()               {}
+func (*SetMapLeavesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{61} }
+
+func (m *SetMapLeavesRequest) GetKeyValue() []*KeyValue {
+	if m != nil {
+		return m.KeyValue
+	}
+	return nil
+}
+
+func (m *SetMapLeavesRequest) GetMapperData() *MapperMetadata {
+	if m != nil {
+		return m.MapperData
+	}
+	return nil
+}
+
+type SetMapLeavesResponse struct {
+	Status  *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	MapRoot *SignedMapRoot     `protobuf:"bytes,2,opt,name=map_root,json=mapRoot" json:"map_root,omitempty"`
+}
+
+func (m *SetMapLeavesResponse) Reset()                    { *m = SetMapLeavesResponse{} }
+func (m *SetMapLeavesResponse) String() string            { return proto.CompactTextString(m) }
+func (*SetMapLeavesResponse) ProtoMessage()               {}
+func (*SetMapLeavesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{62} }
+
+func (m *SetMapLeavesResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *SetMapLeavesResponse) GetMapRoot() *SignedMapRoot {
+	if m != nil {
+		return m.MapRoot
+	}
+	return nil
+}
+
+type GetSignedMapRootRequest struct {
+	MapId int64 `protobuf:"varint,1,opt,name=map_id,json=mapId" json:"map_id,omitempty"`
+}
+
+func (m *GetSignedMapRootRequest) Reset()                    { *m = GetSignedMapRootRequest{} }
+func (m *GetSignedMapRootRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetSignedMapRootRequest) ProtoMessage()               {}
+func (*GetSignedMapRootRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{63} }
+
+type GetSignedMapRootResponse struct {
+	Status  *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	MapRoot *SignedMapRoot     `protobuf:"bytes,2,opt,name=map_root,json=mapRoot" json:"map_root,omitempty"`
+}
+
+func (m *GetSignedMapRootResponse) Reset()                    { *m = GetSignedMapRootResponse{} }
+func (m *GetSignedMapRootResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetSignedMapRootResponse) ProtoMessage()               {}
+func (*GetSignedMapRootResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{64} }
+
+func (m *GetSignedMapRootResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *GetSignedMapRootResponse) GetMapRoot() *SignedMapRoot {
+	if m != nil {
+		return m.MapRoot
+	}
+	return nil
+}
+
+type GetMapLeafHistoryRequest struct {
+	MapId int64  `protobuf:"varint,1,opt,name=map_id,json=mapId" json:"map_id,omitempty"`
+	Key   []byte `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	// The latest revision to include in the history, or -1 for the latest published revision.
+	Revision int64 `protobuf:"varint,3,opt,name=revision" json:"revision,omitempty"`
+}
+
+func (m *GetMapLeafHistoryRequest) Reset()                    { *m = GetMapLeafHistoryRequest{} }
+func (m *GetMapLeafHistoryRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetMapLeafHistoryRequest) ProtoMessage()               {}
+func (*GetMapLeafHistoryRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{65} }
+
+// MapLeafHistoryEntry is a value that was set for a key, with an inclusion proof for the value
+// against the root of the map at the revision it was set.
+type MapLeafHistoryEntry struct {
+	MapRevision int64          `protobuf:"varint,1,opt,name=map_revision,json=mapRevision" json:"map_revision,omitempty"`
+	Value       *MapLeaf       `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+	Inclusion   [][]byte       `protobuf:"bytes,3,rep,name=inclusion,proto3" json:"inclusion,omitempty"`
+	MapRoot     *SignedMapRoot `protobuf:"bytes,4,opt,name=map_root,json=mapRoot" json:"map_root,omitempty"`
+}
+
+func (m *MapLeafHistoryEntry) Reset()                    { *m = MapLeafHistoryEntry{} }
+func (m *MapLeafHistoryEntry) String() string            { return proto.CompactTextString(m) }
+func (*MapLeafHistoryEntry) ProtoMessage()               {}
+func (*MapLeafHistoryEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{66} }
+
+func (m *MapLeafHistoryEntry) GetValue() *MapLeaf {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (m *MapLeafHistoryEntry) GetMapRoot() *SignedMapRoot {
+	if m != nil {
+		return m.MapRoot
+	}
+	return nil
+}
+
+type GetMapLeafHistoryResponse struct {
+	Status *TrillianApiStatus `protobuf:"bytes,1,opt,name=status" json:"status,omitempty"`
+	Key    []byte             `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	// Every value set for the key, oldest first.
+	History []*MapLeafHistoryEntry `protobuf:"bytes,3,rep,name=history" json:"history,omitempty"`
+}
+
+func (m *GetMapLeafHistoryResponse) Reset()                    { *m = GetMapLeafHistoryResponse{} }
+func (m *GetMapLeafHistoryResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetMapLeafHistoryResponse) ProtoMessage()               {}
+func (*GetMapLeafHistoryResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{67} }
+
+func (m *GetMapLeafHistoryResponse) GetStatus() *TrillianApiStatus {
+	if m != nil {
+		return m.Status
+	}
+	return nil
+}
+
+func (m *GetMapLeafHistoryResponse) GetHistory() []*MapLeafHistoryEntry {
+	if m != nil {
+		return m.History
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*TrillianApiStatus)(nil), "trillian.TrillianApiStatus")
+	proto.RegisterType((*LeafProto)(nil), "trillian.LeafProto")
+	proto.RegisterType((*NodeProto)(nil), "trillian.NodeProto")
+	proto.RegisterType((*ProofProto)(nil), "trillian.ProofProto")
+	proto.RegisterType((*QueueLeavesRequest)(nil), "trillian.QueueLeavesRequest")
+	proto.RegisterType((*QueueLeavesResponse)(nil), "trillian.QueueLeavesResponse")
+	proto.RegisterType((*GetInclusionProofRequest)(nil), "trillian.GetInclusionProofRequest")
+	proto.RegisterType((*GetInclusionProofResponse)(nil), "trillian.GetInclusionProofResponse")
+	proto.RegisterType((*GetInclusionProofByHashRequest)(nil), "trillian.GetInclusionProofByHashRequest")
+	proto.RegisterType((*GetInclusionProofByHashResponse)(nil), "trillian.GetInclusionProofByHashResponse")
+	proto.RegisterType((*GetConsistencyProofRequest)(nil), "trillian.GetConsistencyProofRequest")
 	proto.RegisterType((*GetConsistencyProofResponse)(nil), "trillian.GetConsistencyProofResponse")
+	proto.RegisterType((*GetRangeProofRequest)(nil), "trillian.GetRangeProofRequest")
+	proto.RegisterType((*GetRangeProofResponse)(nil), "trillian.GetRangeProofResponse")
 	proto.RegisterType((*GetLeavesByHashRequest)(nil), "trillian.GetLeavesByHashRequest")
 	proto.RegisterType((*GetLeavesByHashResponse)(nil), "trillian.GetLeavesByHashResponse")
 	proto.RegisterType((*GetLeavesByIndexRequest)(nil), "trillian.GetLeavesByIndexRequest")
 	proto.RegisterType((*GetLeavesByIndexResponse)(nil), "trillian.GetLeavesByIndexResponse")
+	proto.RegisterType((*GetLeavesByTimestampRequest)(nil), "trillian.GetLeavesByTimestampRequest")
+	proto.RegisterType((*GetLeavesByTimestampResponse)(nil), "trillian.GetLeavesByTimestampResponse")
 	proto.RegisterType((*GetSequencedLeafCountRequest)(nil), "trillian.GetSequencedLeafCountRequest")
 	proto.RegisterType((*GetSequencedLeafCountResponse)(nil), "trillian.GetSequencedLeafCountResponse")
 	proto.RegisterType((*GetLatestSignedLogRootRequest)(nil), "trillian.GetLatestSignedLogRootRequest")
 	proto.RegisterType((*GetLatestSignedLogRootResponse)(nil), "trillian.GetLatestSignedLogRootResponse")
+	proto.RegisterType((*GetLatestSignedLogRootsRequest)(nil), "trillian.GetLatestSignedLogRootsRequest")
+	proto.RegisterType((*LogRootResult)(nil), "trillian.LogRootResult")
+	proto.RegisterType((*GetLatestSignedLogRootsResponse)(nil), "trillian.GetLatestSignedLogRootsResponse")
+	proto.RegisterType((*GetLatestSignedLogRootAndLeavesRequest)(nil), "trillian.GetLatestSignedLogRootAndLeavesRequest")
+	proto.RegisterType((*GetLatestSignedLogRootAndLeavesResponse)(nil), "trillian.GetLatestSignedLogRootAndLeavesResponse")
 	proto.RegisterType((*GetEntryAndProofRequest)(nil), "trillian.GetEntryAndProofRequest")
 	proto.RegisterType((*GetEntryAndProofResponse)(nil), "trillian.GetEntryAndProofResponse")
+	proto.RegisterType((*GetRevisionDiffRequest)(nil), "trillian.GetRevisionDiffRequest")
+	proto.RegisterType((*NodeDiffProto)(nil), "trillian.NodeDiffProto")
+	proto.RegisterType((*GetRevisionDiffResponse)(nil), "trillian.GetRevisionDiffResponse")
+	proto.RegisterType((*FlushLogRequest)(nil), "trillian.FlushLogRequest")
+	proto.RegisterType((*FlushLogResponse)(nil), "trillian.FlushLogResponse")
+	proto.RegisterType((*SubscribeTreeEventsRequest)(nil), "trillian.SubscribeTreeEventsRequest")
+	proto.RegisterType((*TreeEvent)(nil), "trillian.TreeEvent")
+	proto.RegisterType((*LogFeatureProto)(nil), "trillian.LogFeatureProto")
+	proto.RegisterType((*FeatureProto)(nil), "trillian.FeatureProto")
+	proto.RegisterType((*SetFeatureRequest)(nil), "trillian.SetFeatureRequest")
+	proto.RegisterType((*SetFeatureResponse)(nil), "trillian.SetFeatureResponse")
+	proto.RegisterType((*ListFeaturesRequest)(nil), "trillian.ListFeaturesRequest")
+	proto.RegisterType((*ListFeaturesResponse)(nil), "trillian.ListFeaturesResponse")
+	proto.RegisterType((*TreeMetadata)(nil), "trillian.TreeMetadata")
+	proto.RegisterType((*GetTreeMetadataRequest)(nil), "trillian.GetTreeMetadataRequest")
+	proto.RegisterType((*GetTreeMetadataResponse)(nil), "trillian.GetTreeMetadataResponse")
+	proto.RegisterType((*SetTreeMetadataRequest)(nil), "trillian.SetTreeMetadataRequest")
+	proto.RegisterType((*SetTreeMetadataResponse)(nil), "trillian.SetTreeMetadataResponse")
+	proto.RegisterType((*TableStorageStats)(nil), "trillian.TableStorageStats")
+	proto.RegisterType((*GetTreeStorageStatsRequest)(nil), "trillian.GetTreeStorageStatsRequest")
+	proto.RegisterType((*GetTreeStorageStatsResponse)(nil), "trillian.GetTreeStorageStatsResponse")
+	proto.RegisterType((*PauseSequencingRequest)(nil), "trillian.PauseSequencingRequest")
+	proto.RegisterType((*PauseSequencingResponse)(nil), "trillian.PauseSequencingResponse")
+	proto.RegisterType((*ResumeSequencingRequest)(nil), "trillian.ResumeSequencingRequest")
+	proto.RegisterType((*ResumeSequencingResponse)(nil), "trillian.ResumeSequencingResponse")
 	proto.RegisterType((*MapLeaf)(nil), "trillian.MapLeaf")
 	proto.RegisterType((*KeyValue)(nil), "trillian.KeyValue")
 	proto.RegisterType((*KeyValueInclusion)(nil), "trillian.KeyValueInclusion")
@@ -666,347 +1527,900 @@ func init() {
 	proto.RegisterType((*SetMapLeavesResponse)(nil), "trillian.SetMapLeavesResponse")
 	proto.RegisterType((*GetSignedMapRootRequest)(nil), "trillian.GetSignedMapRootRequest")
 	proto.RegisterType((*GetSignedMapRootResponse)(nil), "trillian.GetSignedMapRootResponse")
+	proto.RegisterType((*GetMapLeafHistoryRequest)(nil), "trillian.GetMapLeafHistoryRequest")
+	proto.RegisterType((*MapLeafHistoryEntry)(nil), "trillian.MapLeafHistoryEntry")
+	proto.RegisterType((*GetMapLeafHistoryResponse)(nil), "trillian.GetMapLeafHistoryResponse")
 	proto.RegisterEnum("trillian.TrillianApiStatusCode", TrillianApiStatusCode_name, TrillianApiStatusCode_value)
+	proto.RegisterEnum("trillian.TreeEventType", TreeEventType_name, TreeEventType_value)
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion3
+
+// Client API for TrillianLog service
+
+type TrillianLogClient interface {
+	// Corresponds to the LeafQueuer API
+	QueueLeaves(ctx context.Context, in *QueueLeavesRequest, opts ...grpc.CallOption) (*QueueLeavesResponse, error)
+	// No direct equivalent at the storage level
+	GetInclusionProof(ctx context.Context, in *GetInclusionProofRequest, opts ...grpc.CallOption) (*GetInclusionProofResponse, error)
+	GetInclusionProofByHash(ctx context.Context, in *GetInclusionProofByHashRequest, opts ...grpc.CallOption) (*GetInclusionProofByHashResponse, error)
+	GetConsistencyProof(ctx context.Context, in *GetConsistencyProofRequest, opts ...grpc.CallOption) (*GetConsistencyProofResponse, error)
+	// Corresponds to the LogRootReader API
+	GetLatestSignedLogRoot(ctx context.Context, in *GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*GetLatestSignedLogRootResponse, error)
+	// Corresponds to the LeafReader API
+	GetSequencedLeafCount(ctx context.Context, in *GetSequencedLeafCountRequest, opts ...grpc.CallOption) (*GetSequencedLeafCountResponse, error)
+	GetLeavesByIndex(ctx context.Context, in *GetLeavesByIndexRequest, opts ...grpc.CallOption) (*GetLeavesByIndexResponse, error)
+	GetLeavesByHash(ctx context.Context, in *GetLeavesByHashRequest, opts ...grpc.CallOption) (*GetLeavesByHashResponse, error)
+	GetLeavesByTimestamp(ctx context.Context, in *GetLeavesByTimestampRequest, opts ...grpc.CallOption) (*GetLeavesByTimestampResponse, error)
+	GetEntryAndProof(ctx context.Context, in *GetEntryAndProofRequest, opts ...grpc.CallOption) (*GetEntryAndProofResponse, error)
+	// For debugging, compares the stored tree at two revisions
+	GetRevisionDiff(ctx context.Context, in *GetRevisionDiffRequest, opts ...grpc.CallOption) (*GetRevisionDiffResponse, error)
+	// Returns the human readable metadata of a log, which is changed with the admin API
+	GetTreeMetadata(ctx context.Context, in *GetTreeMetadataRequest, opts ...grpc.CallOption) (*GetTreeMetadataResponse, error)
+	// Streams the events of a log as they happen so that personalities and monitors don't
+	// have to poll for new roots
+	SubscribeTreeEvents(ctx context.Context, in *SubscribeTreeEventsRequest, opts ...grpc.CallOption) (TrillianLog_SubscribeTreeEventsClient, error)
+	// Proves that a range of leaves is in the tree, e.g. for mirrors checking leaves in bulk
+	GetRangeProof(ctx context.Context, in *GetRangeProofRequest, opts ...grpc.CallOption) (*GetRangeProofResponse, error)
+	// Returns the latest roots of several logs at once, saving a round trip for each
+	GetLatestSignedLogRoots(ctx context.Context, in *GetLatestSignedLogRootsRequest, opts ...grpc.CallOption) (*GetLatestSignedLogRootsResponse, error)
+	// Returns the latest root of a log and the leaves at the end of its tree, read from the
+	// same snapshot, e.g. for monitors polling for new entries
+	GetLatestSignedLogRootAndLeaves(ctx context.Context, in *GetLatestSignedLogRootAndLeavesRequest, opts ...grpc.CallOption) (*GetLatestSignedLogRootAndLeavesResponse, error)
+}
+
+type trillianLogClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewTrillianLogClient(cc *grpc.ClientConn) TrillianLogClient {
+	return &trillianLogClient{cc}
+}
+
+func (c *trillianLogClient) QueueLeaves(ctx context.Context, in *QueueLeavesRequest, opts ...grpc.CallOption) (*QueueLeavesResponse, error) {
+	out := new(QueueLeavesResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/QueueLeaves", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetInclusionProof(ctx context.Context, in *GetInclusionProofRequest, opts ...grpc.CallOption) (*GetInclusionProofResponse, error) {
+	out := new(GetInclusionProofResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetInclusionProof", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetInclusionProofByHash(ctx context.Context, in *GetInclusionProofByHashRequest, opts ...grpc.CallOption) (*GetInclusionProofByHashResponse, error) {
+	out := new(GetInclusionProofByHashResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetInclusionProofByHash", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetConsistencyProof(ctx context.Context, in *GetConsistencyProofRequest, opts ...grpc.CallOption) (*GetConsistencyProofResponse, error) {
+	out := new(GetConsistencyProofResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetConsistencyProof", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetLatestSignedLogRoot(ctx context.Context, in *GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*GetLatestSignedLogRootResponse, error) {
+	out := new(GetLatestSignedLogRootResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetLatestSignedLogRoot", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetSequencedLeafCount(ctx context.Context, in *GetSequencedLeafCountRequest, opts ...grpc.CallOption) (*GetSequencedLeafCountResponse, error) {
+	out := new(GetSequencedLeafCountResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetSequencedLeafCount", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetLeavesByIndex(ctx context.Context, in *GetLeavesByIndexRequest, opts ...grpc.CallOption) (*GetLeavesByIndexResponse, error) {
+	out := new(GetLeavesByIndexResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetLeavesByIndex", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetLeavesByHash(ctx context.Context, in *GetLeavesByHashRequest, opts ...grpc.CallOption) (*GetLeavesByHashResponse, error) {
+	out := new(GetLeavesByHashResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetLeavesByHash", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetLeavesByTimestamp(ctx context.Context, in *GetLeavesByTimestampRequest, opts ...grpc.CallOption) (*GetLeavesByTimestampResponse, error) {
+	out := new(GetLeavesByTimestampResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetLeavesByTimestamp", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetEntryAndProof(ctx context.Context, in *GetEntryAndProofRequest, opts ...grpc.CallOption) (*GetEntryAndProofResponse, error) {
+	out := new(GetEntryAndProofResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetEntryAndProof", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetRevisionDiff(ctx context.Context, in *GetRevisionDiffRequest, opts ...grpc.CallOption) (*GetRevisionDiffResponse, error) {
+	out := new(GetRevisionDiffResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetRevisionDiff", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetTreeMetadata(ctx context.Context, in *GetTreeMetadataRequest, opts ...grpc.CallOption) (*GetTreeMetadataResponse, error) {
+	out := new(GetTreeMetadataResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetTreeMetadata", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) SubscribeTreeEvents(ctx context.Context, in *SubscribeTreeEventsRequest, opts ...grpc.CallOption) (TrillianLog_SubscribeTreeEventsClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_TrillianLog_serviceDesc.Streams[0], c.cc, "/trillian.TrillianLog/SubscribeTreeEvents", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &trillianLogSubscribeTreeEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TrillianLog_SubscribeTreeEventsClient interface {
+	Recv() (*TreeEvent, error)
+	grpc.ClientStream
+}
+
+type trillianLogSubscribeTreeEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *trillianLogSubscribeTreeEventsClient) Recv() (*TreeEvent, error) {
+	m := new(TreeEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *trillianLogClient) GetRangeProof(ctx context.Context, in *GetRangeProofRequest, opts ...grpc.CallOption) (*GetRangeProofResponse, error) {
+	out := new(GetRangeProofResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetRangeProof", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetLatestSignedLogRoots(ctx context.Context, in *GetLatestSignedLogRootsRequest, opts ...grpc.CallOption) (*GetLatestSignedLogRootsResponse, error) {
+	out := new(GetLatestSignedLogRootsResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetLatestSignedLogRoots", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogClient) GetLatestSignedLogRootAndLeaves(ctx context.Context, in *GetLatestSignedLogRootAndLeavesRequest, opts ...grpc.CallOption) (*GetLatestSignedLogRootAndLeavesResponse, error) {
+	out := new(GetLatestSignedLogRootAndLeavesResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetLatestSignedLogRootAndLeaves", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for TrillianLog service
+
+type TrillianLogServer interface {
+	// Corresponds to the LeafQueuer API
+	QueueLeaves(context.Context, *QueueLeavesRequest) (*QueueLeavesResponse, error)
+	// No direct equivalent at the storage level
+	GetInclusionProof(context.Context, *GetInclusionProofRequest) (*GetInclusionProofResponse, error)
+	GetInclusionProofByHash(context.Context, *GetInclusionProofByHashRequest) (*GetInclusionProofByHashResponse, error)
+	GetConsistencyProof(context.Context, *GetConsistencyProofRequest) (*GetConsistencyProofResponse, error)
+	// Corresponds to the LogRootReader API
+	GetLatestSignedLogRoot(context.Context, *GetLatestSignedLogRootRequest) (*GetLatestSignedLogRootResponse, error)
+	// Corresponds to the LeafReader API
+	GetSequencedLeafCount(context.Context, *GetSequencedLeafCountRequest) (*GetSequencedLeafCountResponse, error)
+	GetLeavesByIndex(context.Context, *GetLeavesByIndexRequest) (*GetLeavesByIndexResponse, error)
+	GetLeavesByHash(context.Context, *GetLeavesByHashRequest) (*GetLeavesByHashResponse, error)
+	GetLeavesByTimestamp(context.Context, *GetLeavesByTimestampRequest) (*GetLeavesByTimestampResponse, error)
+	GetEntryAndProof(context.Context, *GetEntryAndProofRequest) (*GetEntryAndProofResponse, error)
+	// For debugging, compares the stored tree at two revisions
+	GetRevisionDiff(context.Context, *GetRevisionDiffRequest) (*GetRevisionDiffResponse, error)
+	// Returns the human readable metadata of a log, which is changed with the admin API
+	GetTreeMetadata(context.Context, *GetTreeMetadataRequest) (*GetTreeMetadataResponse, error)
+	// Streams the events of a log as they happen so that personalities and monitors don't
+	// have to poll for new roots
+	SubscribeTreeEvents(*SubscribeTreeEventsRequest, TrillianLog_SubscribeTreeEventsServer) error
+	// Proves that a range of leaves is in the tree, e.g. for mirrors checking leaves in bulk
+	GetRangeProof(context.Context, *GetRangeProofRequest) (*GetRangeProofResponse, error)
+	// Returns the latest roots of several logs at once, saving a round trip for each
+	GetLatestSignedLogRoots(context.Context, *GetLatestSignedLogRootsRequest) (*GetLatestSignedLogRootsResponse, error)
+	// Returns the latest root of a log and the leaves at the end of its tree, read from the
+	// same snapshot, e.g. for monitors polling for new entries
+	GetLatestSignedLogRootAndLeaves(context.Context, *GetLatestSignedLogRootAndLeavesRequest) (*GetLatestSignedLogRootAndLeavesResponse, error)
+}
+
+func RegisterTrillianLogServer(s *grpc.Server, srv TrillianLogServer) {
+	s.RegisterService(&_TrillianLog_serviceDesc, srv)
+}
+
+func _TrillianLog_QueueLeaves_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueueLeavesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).QueueLeaves(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/QueueLeaves",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).QueueLeaves(ctx, req.(*QueueLeavesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetInclusionProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetInclusionProofRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetInclusionProof(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetInclusionProof",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetInclusionProof(ctx, req.(*GetInclusionProofRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetInclusionProofByHash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetInclusionProofByHashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetInclusionProofByHash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetInclusionProofByHash",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetInclusionProofByHash(ctx, req.(*GetInclusionProofByHashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetConsistencyProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetConsistencyProofRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetConsistencyProof(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetConsistencyProof",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetConsistencyProof(ctx, req.(*GetConsistencyProofRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetLatestSignedLogRoot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLatestSignedLogRootRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetLatestSignedLogRoot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetLatestSignedLogRoot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetLatestSignedLogRoot(ctx, req.(*GetLatestSignedLogRootRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetSequencedLeafCount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSequencedLeafCountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetSequencedLeafCount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetSequencedLeafCount",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetSequencedLeafCount(ctx, req.(*GetSequencedLeafCountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetLeavesByIndex_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLeavesByIndexRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetLeavesByIndex(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetLeavesByIndex",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetLeavesByIndex(ctx, req.(*GetLeavesByIndexRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetLeavesByHash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLeavesByHashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetLeavesByHash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetLeavesByHash",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetLeavesByHash(ctx, req.(*GetLeavesByHashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetLeavesByTimestamp_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLeavesByTimestampRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetLeavesByTimestamp(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetLeavesByTimestamp",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetLeavesByTimestamp(ctx, req.(*GetLeavesByTimestampRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetEntryAndProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetEntryAndProofRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetEntryAndProof(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetEntryAndProof",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetEntryAndProof(ctx, req.(*GetEntryAndProofRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetRevisionDiff_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRevisionDiffRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetRevisionDiff(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetRevisionDiff",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetRevisionDiff(ctx, req.(*GetRevisionDiffRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetTreeMetadata_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTreeMetadataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetTreeMetadata(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetTreeMetadata",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetTreeMetadata(ctx, req.(*GetTreeMetadataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_SubscribeTreeEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeTreeEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TrillianLogServer).SubscribeTreeEvents(m, &trillianLogSubscribeTreeEventsServer{stream})
+}
+
+type TrillianLog_SubscribeTreeEventsServer interface {
+	Send(*TreeEvent) error
+	grpc.ServerStream
+}
+
+type trillianLogSubscribeTreeEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *trillianLogSubscribeTreeEventsServer) Send(m *TreeEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TrillianLog_GetRangeProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRangeProofRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetRangeProof(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetRangeProof",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetRangeProof(ctx, req.(*GetRangeProofRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetLatestSignedLogRoots_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLatestSignedLogRootsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetLatestSignedLogRoots(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetLatestSignedLogRoots",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetLatestSignedLogRoots(ctx, req.(*GetLatestSignedLogRootsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLog_GetLatestSignedLogRootAndLeaves_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLatestSignedLogRootAndLeavesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogServer).GetLatestSignedLogRootAndLeaves(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLog/GetLatestSignedLogRootAndLeaves",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogServer).GetLatestSignedLogRootAndLeaves(ctx, req.(*GetLatestSignedLogRootAndLeavesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
-
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion3
-
-// Client API for TrillianLog service
-
-type TrillianLogClient interface {
-	// Corresponds to the LeafQueuer API
-	QueueLeaves(ctx context.Context, in *QueueLeavesRequest, opts ...grpc.CallOption) (*QueueLeavesResponse, error)
-	// No direct equivalent at the storage level
-	GetInclusionProof(ctx context.Context, in *GetInclusionProofRequest, opts ...grpc.CallOption) (*GetInclusionProofResponse, error)
-	GetInclusionProofByHash(ctx context.Context, in *GetInclusionProofByHashRequest, opts ...grpc.CallOption) (*GetInclusionProofByHashResponse, error)
-	GetConsistencyProof(ctx context.Context, in *GetConsistencyProofRequest, opts ...grpc.CallOption) (*GetConsistencyProofResponse, error)
-	// Corresponds to the LogRootReader API
-	GetLatestSignedLogRoot(ctx context.Context, in *GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*GetLatestSignedLogRootResponse, error)
-	// Corresponds to the LeafReader API
-	GetSequencedLeafCount(ctx context.Context, in *GetSequencedLeafCountRequest, opts ...grpc.CallOption) (*GetSequencedLeafCountResponse, error)
-	GetLeavesByIndex(ctx context.Context, in *GetLeavesByIndexRequest, opts ...grpc.CallOption) (*GetLeavesByIndexResponse, error)
-	GetLeavesByHash(ctx context.Context, in *GetLeavesByHashRequest, opts ...grpc.CallOption) (*GetLeavesByHashResponse, error)
-	GetEntryAndProof(ctx context.Context, in *GetEntryAndProofRequest, opts ...grpc.CallOption) (*GetEntryAndProofResponse, error)
+var _TrillianLog_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "trillian.TrillianLog",
+	HandlerType: (*TrillianLogServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "QueueLeaves",
+			Handler:    _TrillianLog_QueueLeaves_Handler,
+		},
+		{
+			MethodName: "GetInclusionProof",
+			Handler:    _TrillianLog_GetInclusionProof_Handler,
+		},
+		{
+			MethodName: "GetInclusionProofByHash",
+			Handler:    _TrillianLog_GetInclusionProofByHash_Handler,
+		},
+		{
+			MethodName: "GetConsistencyProof",
+			Handler:    _TrillianLog_GetConsistencyProof_Handler,
+		},
+		{
+			MethodName: "GetLatestSignedLogRoot",
+			Handler:    _TrillianLog_GetLatestSignedLogRoot_Handler,
+		},
+		{
+			MethodName: "GetSequencedLeafCount",
+			Handler:    _TrillianLog_GetSequencedLeafCount_Handler,
+		},
+		{
+			MethodName: "GetLeavesByIndex",
+			Handler:    _TrillianLog_GetLeavesByIndex_Handler,
+		},
+		{
+			MethodName: "GetLeavesByHash",
+			Handler:    _TrillianLog_GetLeavesByHash_Handler,
+		},
+		{
+			MethodName: "GetLeavesByTimestamp",
+			Handler:    _TrillianLog_GetLeavesByTimestamp_Handler,
+		},
+		{
+			MethodName: "GetEntryAndProof",
+			Handler:    _TrillianLog_GetEntryAndProof_Handler,
+		},
+		{
+			MethodName: "GetRevisionDiff",
+			Handler:    _TrillianLog_GetRevisionDiff_Handler,
+		},
+		{
+			MethodName: "GetTreeMetadata",
+			Handler:    _TrillianLog_GetTreeMetadata_Handler,
+		},
+		{
+			MethodName: "GetRangeProof",
+			Handler:    _TrillianLog_GetRangeProof_Handler,
+		},
+		{
+			MethodName: "GetLatestSignedLogRoots",
+			Handler:    _TrillianLog_GetLatestSignedLogRoots_Handler,
+		},
+		{
+			MethodName: "GetLatestSignedLogRootAndLeaves",
+			Handler:    _TrillianLog_GetLatestSignedLogRootAndLeaves_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribeTreeEvents",
+			Handler:       _TrillianLog_SubscribeTreeEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: fileDescriptor0,
 }
 
-type trillianLogClient struct {
-	cc *grpc.ClientConn
-}
+// Client API for TrillianLogAdmin service
 
-func NewTrillianLogClient(cc *grpc.ClientConn) TrillianLogClient {
-	return &trillianLogClient{cc}
+type TrillianLogAdminClient interface {
+	// Runs a sequencing pass for a log immediately
+	FlushLog(ctx context.Context, in *FlushLogRequest, opts ...grpc.CallOption) (*FlushLogResponse, error)
+	// Changes a feature flag without restarting the server
+	SetFeature(ctx context.Context, in *SetFeatureRequest, opts ...grpc.CallOption) (*SetFeatureResponse, error)
+	ListFeatures(ctx context.Context, in *ListFeaturesRequest, opts ...grpc.CallOption) (*ListFeaturesResponse, error)
+	// Replaces the human readable metadata of a log
+	SetTreeMetadata(ctx context.Context, in *SetTreeMetadataRequest, opts ...grpc.CallOption) (*SetTreeMetadataResponse, error)
+	// Returns how much storage a log is using and how fast it's growing, for capacity planning
+	GetTreeStorageStats(ctx context.Context, in *GetTreeStorageStatsRequest, opts ...grpc.CallOption) (*GetTreeStorageStatsResponse, error)
+	// Stops sequencing a log, e.g. during storage maintenance, until it's resumed. The state
+	// is kept in storage so it survives restarts and applies to every server.
+	PauseSequencing(ctx context.Context, in *PauseSequencingRequest, opts ...grpc.CallOption) (*PauseSequencingResponse, error)
+	ResumeSequencing(ctx context.Context, in *ResumeSequencingRequest, opts ...grpc.CallOption) (*ResumeSequencingResponse, error)
 }
 
-func (c *trillianLogClient) QueueLeaves(ctx context.Context, in *QueueLeavesRequest, opts ...grpc.CallOption) (*QueueLeavesResponse, error) {
-	out := new(QueueLeavesResponse)
-	err := grpc.Invoke(ctx, "/trillian.TrillianLog/QueueLeaves", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
+type trillianLogAdminClient struct {
+	cc *grpc.ClientConn
 }
 
-func (c *trillianLogClient) GetInclusionProof(ctx context.Context, in *GetInclusionProofRequest, opts ...grpc.CallOption) (*GetInclusionProofResponse, error) {
-	out := new(GetInclusionProofResponse)
-	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetInclusionProof", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
+func NewTrillianLogAdminClient(cc *grpc.ClientConn) TrillianLogAdminClient {
+	return &trillianLogAdminClient{cc}
 }
 
-func (c *trillianLogClient) GetInclusionProofByHash(ctx context.Context, in *GetInclusionProofByHashRequest, opts ...grpc.CallOption) (*GetInclusionProofByHashResponse, error) {
-	out := new(GetInclusionProofByHashResponse)
-	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetInclusionProofByHash", in, out, c.cc, opts...)
+func (c *trillianLogAdminClient) FlushLog(ctx context.Context, in *FlushLogRequest, opts ...grpc.CallOption) (*FlushLogResponse, error) {
+	out := new(FlushLogResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLogAdmin/FlushLog", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *trillianLogClient) GetConsistencyProof(ctx context.Context, in *GetConsistencyProofRequest, opts ...grpc.CallOption) (*GetConsistencyProofResponse, error) {
-	out := new(GetConsistencyProofResponse)
-	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetConsistencyProof", in, out, c.cc, opts...)
+func (c *trillianLogAdminClient) SetFeature(ctx context.Context, in *SetFeatureRequest, opts ...grpc.CallOption) (*SetFeatureResponse, error) {
+	out := new(SetFeatureResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLogAdmin/SetFeature", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *trillianLogClient) GetLatestSignedLogRoot(ctx context.Context, in *GetLatestSignedLogRootRequest, opts ...grpc.CallOption) (*GetLatestSignedLogRootResponse, error) {
-	out := new(GetLatestSignedLogRootResponse)
-	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetLatestSignedLogRoot", in, out, c.cc, opts...)
+func (c *trillianLogAdminClient) ListFeatures(ctx context.Context, in *ListFeaturesRequest, opts ...grpc.CallOption) (*ListFeaturesResponse, error) {
+	out := new(ListFeaturesResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLogAdmin/ListFeatures", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *trillianLogClient) GetSequencedLeafCount(ctx context.Context, in *GetSequencedLeafCountRequest, opts ...grpc.CallOption) (*GetSequencedLeafCountResponse, error) {
-	out := new(GetSequencedLeafCountResponse)
-	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetSequencedLeafCount", in, out, c.cc, opts...)
+func (c *trillianLogAdminClient) SetTreeMetadata(ctx context.Context, in *SetTreeMetadataRequest, opts ...grpc.CallOption) (*SetTreeMetadataResponse, error) {
+	out := new(SetTreeMetadataResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLogAdmin/SetTreeMetadata", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *trillianLogClient) GetLeavesByIndex(ctx context.Context, in *GetLeavesByIndexRequest, opts ...grpc.CallOption) (*GetLeavesByIndexResponse, error) {
-	out := new(GetLeavesByIndexResponse)
-	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetLeavesByIndex", in, out, c.cc, opts...)
+func (c *trillianLogAdminClient) GetTreeStorageStats(ctx context.Context, in *GetTreeStorageStatsRequest, opts ...grpc.CallOption) (*GetTreeStorageStatsResponse, error) {
+	out := new(GetTreeStorageStatsResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLogAdmin/GetTreeStorageStats", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *trillianLogClient) GetLeavesByHash(ctx context.Context, in *GetLeavesByHashRequest, opts ...grpc.CallOption) (*GetLeavesByHashResponse, error) {
-	out := new(GetLeavesByHashResponse)
-	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetLeavesByHash", in, out, c.cc, opts...)
+func (c *trillianLogAdminClient) PauseSequencing(ctx context.Context, in *PauseSequencingRequest, opts ...grpc.CallOption) (*PauseSequencingResponse, error) {
+	out := new(PauseSequencingResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLogAdmin/PauseSequencing", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *trillianLogClient) GetEntryAndProof(ctx context.Context, in *GetEntryAndProofRequest, opts ...grpc.CallOption) (*GetEntryAndProofResponse, error) {
-	out := new(GetEntryAndProofResponse)
-	err := grpc.Invoke(ctx, "/trillian.TrillianLog/GetEntryAndProof", in, out, c.cc, opts...)
+func (c *trillianLogAdminClient) ResumeSequencing(ctx context.Context, in *ResumeSequencingRequest, opts ...grpc.CallOption) (*ResumeSequencingResponse, error) {
+	out := new(ResumeSequencingResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLogAdmin/ResumeSequencing", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-// Server API for TrillianLog service
-
-type TrillianLogServer interface {
-	// Corresponds to the LeafQueuer API
-	QueueLeaves(context.Context, *QueueLeavesRequest) (*QueueLeavesResponse, error)
-	// No direct equivalent at the storage level
-	GetInclusionProof(context.Context, *GetInclusionProofRequest) (*GetInclusionProofResponse, error)
-	GetInclusionProofByHash(context.Context, *GetInclusionProofByHashRequest) (*GetInclusionProofByHashResponse, error)
-	GetConsistencyProof(context.Context, *GetConsistencyProofRequest) (*GetConsistencyProofResponse, error)
-	// Corresponds to the LogRootReader API
-	GetLatestSignedLogRoot(context.Context, *GetLatestSignedLogRootRequest) (*GetLatestSignedLogRootResponse, error)
-	// Corresponds to the LeafReader API
-	GetSequencedLeafCount(context.Context, *GetSequencedLeafCountRequest) (*GetSequencedLeafCountResponse, error)
-	GetLeavesByIndex(context.Context, *GetLeavesByIndexRequest) (*GetLeavesByIndexResponse, error)
-	GetLeavesByHash(context.Context, *GetLeavesByHashRequest) (*GetLeavesByHashResponse, error)
-	GetEntryAndProof(context.Context, *GetEntryAndProofRequest) (*GetEntryAndProofResponse, error)
-}
-
-func RegisterTrillianLogServer(s *grpc.Server, srv TrillianLogServer) {
-	s.RegisterService(&_TrillianLog_serviceDesc, srv)
-}
+// Server API for TrillianLogAdmin service
 
-func _TrillianLog_QueueLeaves_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(QueueLeavesRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(TrillianLogServer).QueueLeaves(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/trillian.TrillianLog/QueueLeaves",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TrillianLogServer).QueueLeaves(ctx, req.(*QueueLeavesRequest))
-	}
-	return interceptor(ctx, in, info, handler)
+type TrillianLogAdminServer interface {
+	// Runs a sequencing pass for a log immediately
+	FlushLog(context.Context, *FlushLogRequest) (*FlushLogResponse, error)
+	// Changes a feature flag without restarting the server
+	SetFeature(context.Context, *SetFeatureRequest) (*SetFeatureResponse, error)
+	ListFeatures(context.Context, *ListFeaturesRequest) (*ListFeaturesResponse, error)
+	// Replaces the human readable metadata of a log
+	SetTreeMetadata(context.Context, *SetTreeMetadataRequest) (*SetTreeMetadataResponse, error)
+	// Returns how much storage a log is using and how fast it's growing, for capacity planning
+	GetTreeStorageStats(context.Context, *GetTreeStorageStatsRequest) (*GetTreeStorageStatsResponse, error)
+	// Stops sequencing a log, e.g. during storage maintenance, until it's resumed. The state
+	// is kept in storage so it survives restarts and applies to every server.
+	PauseSequencing(context.Context, *PauseSequencingRequest) (*PauseSequencingResponse, error)
+	ResumeSequencing(context.Context, *ResumeSequencingRequest) (*ResumeSequencingResponse, error)
 }
 
-func _TrillianLog_GetInclusionProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetInclusionProofRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(TrillianLogServer).GetInclusionProof(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/trillian.TrillianLog/GetInclusionProof",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TrillianLogServer).GetInclusionProof(ctx, req.(*GetInclusionProofRequest))
-	}
-	return interceptor(ctx, in, info, handler)
+func RegisterTrillianLogAdminServer(s *grpc.Server, srv TrillianLogAdminServer) {
+	s.RegisterService(&_TrillianLogAdmin_serviceDesc, srv)
 }
 
-func _TrillianLog_GetInclusionProofByHash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetInclusionProofByHashRequest)
+func _TrillianLogAdmin_FlushLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FlushLogRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(TrillianLogServer).GetInclusionProofByHash(ctx, in)
+		return srv.(TrillianLogAdminServer).FlushLog(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/trillian.TrillianLog/GetInclusionProofByHash",
+		FullMethod: "/trillian.TrillianLogAdmin/FlushLog",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TrillianLogServer).GetInclusionProofByHash(ctx, req.(*GetInclusionProofByHashRequest))
+		return srv.(TrillianLogAdminServer).FlushLog(ctx, req.(*FlushLogRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _TrillianLog_GetConsistencyProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetConsistencyProofRequest)
+func _TrillianLogAdmin_SetFeature_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetFeatureRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(TrillianLogServer).GetConsistencyProof(ctx, in)
+		return srv.(TrillianLogAdminServer).SetFeature(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/trillian.TrillianLog/GetConsistencyProof",
+		FullMethod: "/trillian.TrillianLogAdmin/SetFeature",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TrillianLogServer).GetConsistencyProof(ctx, req.(*GetConsistencyProofRequest))
+		return srv.(TrillianLogAdminServer).SetFeature(ctx, req.(*SetFeatureRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _TrillianLog_GetLatestSignedLogRoot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetLatestSignedLogRootRequest)
+func _TrillianLogAdmin_ListFeatures_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFeaturesRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(TrillianLogServer).GetLatestSignedLogRoot(ctx, in)
+		return srv.(TrillianLogAdminServer).ListFeatures(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/trillian.TrillianLog/GetLatestSignedLogRoot",
+		FullMethod: "/trillian.TrillianLogAdmin/ListFeatures",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TrillianLogServer).GetLatestSignedLogRoot(ctx, req.(*GetLatestSignedLogRootRequest))
+		return srv.(TrillianLogAdminServer).ListFeatures(ctx, req.(*ListFeaturesRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _TrillianLog_GetSequencedLeafCount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetSequencedLeafCountRequest)
+func _TrillianLogAdmin_SetTreeMetadata_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetTreeMetadataRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(TrillianLogServer).GetSequencedLeafCount(ctx, in)
+		return srv.(TrillianLogAdminServer).SetTreeMetadata(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/trillian.TrillianLog/GetSequencedLeafCount",
+		FullMethod: "/trillian.TrillianLogAdmin/SetTreeMetadata",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TrillianLogServer).GetSequencedLeafCount(ctx, req.(*GetSequencedLeafCountRequest))
+		return srv.(TrillianLogAdminServer).SetTreeMetadata(ctx, req.(*SetTreeMetadataRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _TrillianLog_GetLeavesByIndex_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetLeavesByIndexRequest)
+func _TrillianLogAdmin_GetTreeStorageStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTreeStorageStatsRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(TrillianLogServer).GetLeavesByIndex(ctx, in)
+		return srv.(TrillianLogAdminServer).GetTreeStorageStats(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/trillian.TrillianLog/GetLeavesByIndex",
+		FullMethod: "/trillian.TrillianLogAdmin/GetTreeStorageStats",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TrillianLogServer).GetLeavesByIndex(ctx, req.(*GetLeavesByIndexRequest))
+		return srv.(TrillianLogAdminServer).GetTreeStorageStats(ctx, req.(*GetTreeStorageStatsRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _TrillianLog_GetLeavesByHash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetLeavesByHashRequest)
+func _TrillianLogAdmin_PauseSequencing_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PauseSequencingRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(TrillianLogServer).GetLeavesByHash(ctx, in)
+		return srv.(TrillianLogAdminServer).PauseSequencing(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/trillian.TrillianLog/GetLeavesByHash",
+		FullMethod: "/trillian.TrillianLogAdmin/PauseSequencing",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TrillianLogServer).GetLeavesByHash(ctx, req.(*GetLeavesByHashRequest))
+		return srv.(TrillianLogAdminServer).PauseSequencing(ctx, req.(*PauseSequencingRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _TrillianLog_GetEntryAndProof_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetEntryAndProofRequest)
+func _TrillianLogAdmin_ResumeSequencing_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResumeSequencingRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(TrillianLogServer).GetEntryAndProof(ctx, in)
+		return srv.(TrillianLogAdminServer).ResumeSequencing(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/trillian.TrillianLog/GetEntryAndProof",
+		FullMethod: "/trillian.TrillianLogAdmin/ResumeSequencing",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(TrillianLogServer).GetEntryAndProof(ctx, req.(*GetEntryAndProofRequest))
+		return srv.(TrillianLogAdminServer).ResumeSequencing(ctx, req.(*ResumeSequencingRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-var _TrillianLog_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "trillian.TrillianLog",
-	HandlerType: (*TrillianLogServer)(nil),
+var _TrillianLogAdmin_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "trillian.TrillianLogAdmin",
+	HandlerType: (*TrillianLogAdminServer)(nil),
 	Methods: []grpc.MethodDesc{
 		{
-			MethodName: "QueueLeaves",
-			Handler:    _TrillianLog_QueueLeaves_Handler,
-		},
-		{
-			MethodName: "GetInclusionProof",
-			Handler:    _TrillianLog_GetInclusionProof_Handler,
-		},
-		{
-			MethodName: "GetInclusionProofByHash",
-			Handler:    _TrillianLog_GetInclusionProofByHash_Handler,
+			MethodName: "FlushLog",
+			Handler:    _TrillianLogAdmin_FlushLog_Handler,
 		},
 		{
-			MethodName: "GetConsistencyProof",
-			Handler:    _TrillianLog_GetConsistencyProof_Handler,
+			MethodName: "SetFeature",
+			Handler:    _TrillianLogAdmin_SetFeature_Handler,
 		},
 		{
-			MethodName: "GetLatestSignedLogRoot",
-			Handler:    _TrillianLog_GetLatestSignedLogRoot_Handler,
+			MethodName: "ListFeatures",
+			Handler:    _TrillianLogAdmin_ListFeatures_Handler,
 		},
 		{
-			MethodName: "GetSequencedLeafCount",
-			Handler:    _TrillianLog_GetSequencedLeafCount_Handler,
+			MethodName: "SetTreeMetadata",
+			Handler:    _TrillianLogAdmin_SetTreeMetadata_Handler,
 		},
 		{
-			MethodName: "GetLeavesByIndex",
-			Handler:    _TrillianLog_GetLeavesByIndex_Handler,
+			MethodName: "GetTreeStorageStats",
+			Handler:    _TrillianLogAdmin_GetTreeStorageStats_Handler,
 		},
 		{
-			MethodName: "GetLeavesByHash",
-			Handler:    _TrillianLog_GetLeavesByHash_Handler,
+			MethodName: "PauseSequencing",
+			Handler:    _TrillianLogAdmin_PauseSequencing_Handler,
 		},
 		{
-			MethodName: "GetEntryAndProof",
-			Handler:    _TrillianLog_GetEntryAndProof_Handler,
+			MethodName: "ResumeSequencing",
+			Handler:    _TrillianLogAdmin_ResumeSequencing_Handler,
 		},
 	},
 	Streams:  []grpc.StreamDesc{},
@@ -1019,6 +2433,9 @@ type TrillianMapClient interface {
 	GetLeaves(ctx context.Context, in *GetMapLeavesRequest, opts ...grpc.CallOption) (*GetMapLeavesResponse, error)
 	SetLeaves(ctx context.Context, in *SetMapLeavesRequest, opts ...grpc.CallOption) (*SetMapLeavesResponse, error)
 	GetSignedMapRoot(ctx context.Context, in *GetSignedMapRootRequest, opts ...grpc.CallOption) (*GetSignedMapRootResponse, error)
+	// GetLeafHistory returns every value that has been set for a key, so that clients can audit
+	// that it has only been changed when they expected.
+	GetLeafHistory(ctx context.Context, in *GetMapLeafHistoryRequest, opts ...grpc.CallOption) (*GetMapLeafHistoryResponse, error)
 }
 
 type trillianMapClient struct {
@@ -1056,12 +2473,24 @@ func (c *trillianMapClient) GetSignedMapRoot(ctx context.Context, in *GetSignedM
 	return out, nil
 }
 
+func (c *trillianMapClient) GetLeafHistory(ctx context.Context, in *GetMapLeafHistoryRequest, opts ...grpc.CallOption) (*GetMapLeafHistoryResponse, error) {
+	out := new(GetMapLeafHistoryResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianMap/GetLeafHistory", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for TrillianMap service
 
 type TrillianMapServer interface {
 	GetLeaves(context.Context, *GetMapLeavesRequest) (*GetMapLeavesResponse, error)
 	SetLeaves(context.Context, *SetMapLeavesRequest) (*SetMapLeavesResponse, error)
 	GetSignedMapRoot(context.Context, *GetSignedMapRootRequest) (*GetSignedMapRootResponse, error)
+	// GetLeafHistory returns every value that has been set for a key, so that clients can audit
+	// that it has only been changed when they expected.
+	GetLeafHistory(context.Context, *GetMapLeafHistoryRequest) (*GetMapLeafHistoryResponse, error)
 }
 
 func RegisterTrillianMapServer(s *grpc.Server, srv TrillianMapServer) {
@@ -1122,6 +2551,24 @@ func _TrillianMap_GetSignedMapRoot_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TrillianMap_GetLeafHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMapLeafHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianMapServer).GetLeafHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianMap/GetLeafHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianMapServer).GetLeafHistory(ctx, req.(*GetMapLeafHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _TrillianMap_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "trillian.TrillianMap",
 	HandlerType: (*TrillianMapServer)(nil),
@@ -1138,6 +2585,10 @@ var _TrillianMap_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetSignedMapRoot",
 			Handler:    _TrillianMap_GetSignedMapRoot_Handler,
 		},
+		{
+			MethodName: "GetLeafHistory",
+			Handler:    _TrillianMap_GetLeafHistory_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: fileDescriptor0,
@@ -1146,84 +2597,179 @@ var _TrillianMap_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("github.com/google/trillian/trillian_api.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 1254 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xbc, 0x58, 0x5f, 0x73, 0xdb, 0x44,
-	0x10, 0x8f, 0xac, 0x26, 0xb1, 0xd7, 0x4d, 0xe3, 0x5c, 0x12, 0xe2, 0x2a, 0x49, 0x9b, 0x5e, 0xa1,
-	0x49, 0xc3, 0x90, 0x30, 0xee, 0xc0, 0xc0, 0x13, 0x34, 0x21, 0x03, 0xa1, 0x29, 0x29, 0x72, 0x87,
-	0xe9, 0xc0, 0x0c, 0x1a, 0xc5, 0xba, 0x38, 0xa2, 0xb6, 0x64, 0x2c, 0x39, 0xc4, 0xd0, 0x81, 0x99,
-	0x32, 0xf0, 0x11, 0x18, 0x5e, 0x78, 0xe3, 0x4b, 0xf0, 0xed, 0xb8, 0x5b, 0xfd, 0xf3, 0x49, 0xb2,
-	0x9d, 0xe2, 0x92, 0x37, 0xdd, 0xee, 0xde, 0x6f, 0x7f, 0xbb, 0xb7, 0xb7, 0xb7, 0x36, 0xbc, 0xd3,
-	0xb4, 0xfd, 0xb3, 0xde, 0xc9, 0x4e, 0xc3, 0x6d, 0xef, 0x36, 0x5d, 0xb7, 0xd9, 0x62, 0xbb, 0x7e,
-	0xd7, 0x6e, 0xb5, 0x6c, 0xd3, 0x89, 0x3f, 0x0c, 0xb3, 0x63, 0xef, 0x74, 0xba, 0xae, 0xef, 0x92,
-	0x62, 0x24, 0xd3, 0xee, 0x5f, 0x62, 0x63, 0xb0, 0x89, 0xfe, 0x00, 0x0b, 0x4f, 0x43, 0xc9, 0xc3,
-	0x8e, 0x5d, 0xf7, 0x4d, 0xbf, 0xe7, 0x91, 0x8f, 0xa1, 0xec, 0xe1, 0x97, 0xd1, 0x70, 0x2d, 0x56,
-	0x55, 0x36, 0x94, 0xad, 0x1b, 0xb5, 0xdb, 0x3b, 0xf1, 0xd6, 0xcc, 0x8e, 0x7d, 0x6e, 0xa6, 0x83,
-	0x17, 0x7f, 0x93, 0x0d, 0x28, 0x5b, 0xcc, 0x6b, 0x74, 0xed, 0x8e, 0x6f, 0xbb, 0x4e, 0xb5, 0xc0,
-	0x11, 0x4a, 0xfa, 0xa0, 0x88, 0xfe, 0xaa, 0x40, 0xe9, 0x88, 0x99, 0xa7, 0x4f, 0x90, 0xfb, 0x2a,
-	0x94, 0x5a, 0x7c, 0x61, 0x9c, 0x99, 0xde, 0x19, 0xfa, 0xbb, 0xae, 0x17, 0x85, 0xe0, 0x33, 0xbe,
-	0x8e, 0x95, 0x96, 0xe9, 0x9b, 0x08, 0x15, 0x2a, 0x3f, 0xe1, 0x6b, 0xb2, 0x0e, 0xc0, 0x2e, 0xfc,
-	0xae, 0x19, 0x68, 0x55, 0xd4, 0x96, 0x50, 0x12, 0xa9, 0x71, 0xaf, 0xed, 0x58, 0xec, 0xa2, 0x7a,
-	0x8d, 0xab, 0x55, 0x1d, 0xd1, 0x0e, 0x85, 0x80, 0x9e, 0x42, 0xe9, 0x0b, 0xce, 0x37, 0x20, 0xb1,
-	0x02, 0xb3, 0x0e, 0x5f, 0x18, 0xb6, 0x15, 0x52, 0x98, 0x11, 0xcb, 0x43, 0x4b, 0x10, 0x40, 0x05,
-	0xb2, 0x0b, 0x09, 0x08, 0x01, 0xb2, 0xbb, 0x0b, 0x73, 0xa8, 0xec, 0xb2, 0x73, 0xdb, 0x13, 0xc1,
-	0xaa, 0xe8, 0xe4, 0xba, 0x10, 0xea, 0xa1, 0x8c, 0x1a, 0x00, 0xdc, 0x87, 0x1b, 0x46, 0x2b, 0x93,
-	0x52, 0x52, 0xa4, 0x48, 0x0d, 0xa0, 0x23, 0x8c, 0x0d, 0x01, 0xc1, 0xfd, 0xa9, 0x5b, 0xe5, 0xda,
-	0x62, 0x92, 0xfd, 0x98, 0xb0, 0x5e, 0x42, 0x33, 0xb1, 0xa6, 0xcf, 0x80, 0x7c, 0xd9, 0x63, 0x3d,
-	0xc6, 0x53, 0x7a, 0xce, 0x3c, 0x9d, 0x7d, 0xdf, 0x63, 0x9e, 0x4f, 0x96, 0x61, 0xa6, 0xe5, 0x36,
-	0xa3, 0x80, 0x54, 0x7d, 0x9a, 0xaf, 0x78, 0x3c, 0x6f, 0x73, 0x31, 0xda, 0x65, 0xc1, 0xe3, 0x23,
-	0xd1, 0x43, 0x13, 0xfa, 0x39, 0x2c, 0x4a, 0xc8, 0x5e, 0xc7, 0x75, 0x3c, 0x46, 0x1e, 0xc0, 0x4c,
-	0x70, 0xde, 0x08, 0x5d, 0xae, 0xad, 0x8e, 0x28, 0x0f, 0x3d, 0x34, 0xa5, 0x6d, 0xa8, 0x7e, 0xca,
-	0xfc, 0x43, 0xa7, 0xd1, 0xea, 0x89, 0xb4, 0x60, 0x4a, 0xc6, 0x70, 0x95, 0x73, 0x55, 0x48, 0xe7,
-	0x8a, 0x1f, 0x8d, 0xdf, 0x65, 0xcc, 0xf0, 0xec, 0x1f, 0x59, 0x98, 0xf9, 0xa2, 0x10, 0xd4, 0xf9,
-	0x9a, 0xbe, 0x80, 0x9b, 0x39, 0xee, 0x26, 0x08, 0x80, 0x6c, 0xc3, 0x34, 0xe6, 0x1c, 0x89, 0x94,
-	0x6b, 0x4b, 0xc9, 0x9e, 0xe4, 0x78, 0xf5, 0xc0, 0x84, 0xfe, 0xa5, 0xc0, 0xad, 0x8c, 0xfb, 0xbd,
-	0xbe, 0x28, 0x9a, 0x31, 0x31, 0x4b, 0xb7, 0xa1, 0x90, 0xbd, 0x0d, 0x43, 0x23, 0xe6, 0xfc, 0x16,
-	0xdc, 0xae, 0xc5, 0xba, 0xc6, 0x49, 0xdf, 0xf0, 0x84, 0x13, 0xa7, 0xc1, 0xb0, 0xea, 0x8b, 0xfa,
-	0x3c, 0x2a, 0xf6, 0xfa, 0xf5, 0x50, 0x4c, 0x5f, 0x2a, 0x70, 0x7b, 0x28, 0xbf, 0xd7, 0x94, 0x24,
-	0x75, 0x5c, 0x92, 0x7e, 0x53, 0x40, 0xe3, 0x24, 0xf6, 0xb9, 0x37, 0xdb, 0xf3, 0x39, 0xaf, 0xfe,
-	0x65, 0x8a, 0xe2, 0x1e, 0xcc, 0x9f, 0xda, 0x5d, 0xcf, 0x37, 0x92, 0x4c, 0x04, 0x95, 0x31, 0x87,
-	0xe2, 0xa7, 0x51, 0x3a, 0xb6, 0xa0, 0xe2, 0xb1, 0x86, 0xeb, 0x58, 0x46, 0x3a, 0x65, 0x37, 0x02,
-	0x79, 0x64, 0x49, 0x7f, 0x86, 0xd5, 0x5c, 0x1a, 0x57, 0x55, 0x2c, 0x17, 0xf0, 0x06, 0xf7, 0x1f,
-	0xdc, 0xb1, 0xff, 0x52, 0x23, 0xaa, 0x54, 0x23, 0xb9, 0x65, 0xa0, 0xe6, 0x97, 0xc1, 0x4f, 0xb0,
-	0x92, 0xf1, 0x3c, 0x49, 0xd4, 0xaf, 0xd4, 0x5c, 0x8e, 0x25, 0xe7, 0x78, 0xa5, 0x5f, 0xb1, 0x1f,
-	0xa8, 0x72, 0x43, 0x7f, 0x81, 0x1d, 0x26, 0x05, 0x78, 0x65, 0xe1, 0xbc, 0x07, 0x6b, 0xdc, 0x7b,
-	0x94, 0x5a, 0x4b, 0x18, 0xec, 0xbb, 0x3d, 0xc7, 0x1f, 0x1d, 0x13, 0xf5, 0x60, 0x7d, 0xc8, 0xb6,
-	0x49, 0x98, 0x47, 0x99, 0x6a, 0x08, 0xa8, 0xc1, 0xce, 0x89, 0xd8, 0xf4, 0x7d, 0x74, 0x7a, 0x64,
-	0xfa, 0x9c, 0x58, 0xdd, 0x6e, 0x3a, 0xdc, 0xaf, 0xdb, 0xd4, 0x5d, 0x77, 0x1c, 0xd9, 0x3f, 0x82,
-	0xb6, 0x96, 0xbb, 0x71, 0x12, 0xba, 0x1f, 0xc1, 0xbc, 0x87, 0x68, 0x86, 0xf0, 0xca, 0x2f, 0x85,
-	0x1f, 0xde, 0x9b, 0x95, 0x64, 0xb7, 0xec, 0x6e, 0xce, 0x1b, 0x5c, 0xd2, 0x16, 0xd6, 0xd2, 0x81,
-	0xe3, 0x77, 0xfb, 0x0f, 0x1d, 0xeb, 0xff, 0x7e, 0x5b, 0xfe, 0x56, 0xb0, 0xd2, 0x52, 0xee, 0xae,
-	0xa8, 0x5d, 0x90, 0x4d, 0xb8, 0x26, 0x78, 0x22, 0xab, 0x21, 0x35, 0x89, 0x06, 0xd4, 0x82, 0xd9,
-	0xc7, 0x66, 0x47, 0x48, 0x47, 0xcf, 0x58, 0x51, 0x2a, 0xce, 0xcd, 0x56, 0x8f, 0x85, 0x6f, 0x0e,
-	0x9a, 0x7f, 0x25, 0x04, 0x63, 0xa6, 0x2c, 0x7a, 0x00, 0xc5, 0x47, 0xac, 0x1f, 0x98, 0x56, 0x40,
-	0x7d, 0xce, 0xfa, 0xa1, 0x03, 0xf1, 0xc9, 0xc9, 0x4e, 0x27, 0xb0, 0xe5, 0xda, 0x42, 0xc2, 0x36,
-	0xa4, 0xa6, 0x07, 0x7a, 0x7a, 0x02, 0x0b, 0x11, 0x4c, 0xfc, 0x2a, 0x91, 0x5d, 0x28, 0x71, 0x90,
-	0x90, 0x58, 0x90, 0x4e, 0x92, 0x20, 0x44, 0xf6, 0x7a, 0xf1, 0x79, 0x44, 0x60, 0x0d, 0x4a, 0x76,
-	0xb4, 0x3b, 0xec, 0x8c, 0x89, 0x80, 0x7e, 0x0d, 0x8b, 0xfc, 0xd8, 0x02, 0xc7, 0xf2, 0xa4, 0xd4,
-	0x36, 0x3b, 0x03, 0x15, 0xc2, 0x57, 0xbc, 0x42, 0xc2, 0x60, 0x02, 0x14, 0x0c, 0x46, 0x83, 0x62,
-	0x6a, 0xd2, 0x8b, 0xd7, 0xf4, 0x1f, 0x05, 0x96, 0x64, 0xf0, 0x49, 0xea, 0xe1, 0x83, 0xc1, 0xc0,
-	0x83, 0xe6, 0xb3, 0x9a, 0x0d, 0x3c, 0x4e, 0xd4, 0x40, 0x06, 0x6a, 0x50, 0x14, 0xc1, 0xe0, 0x1d,
-	0x52, 0xf3, 0xef, 0x10, 0xe7, 0x88, 0x77, 0x68, 0xb6, 0x1d, 0x7c, 0xd0, 0x3f, 0x15, 0x58, 0xac,
-	0x5f, 0x3e, 0x31, 0xbb, 0x59, 0x72, 0xa3, 0x4f, 0xe5, 0x43, 0x28, 0xf3, 0x9d, 0x1d, 0xfe, 0x26,
-	0xc5, 0x25, 0x54, 0xae, 0x55, 0xa5, 0x52, 0xe0, 0xca, 0xc7, 0xcc, 0x37, 0x85, 0x5e, 0x87, 0xc0,
-	0x18, 0xab, 0xeb, 0x17, 0x58, 0xaa, 0xbf, 0xb6, 0xac, 0x0e, 0xe6, 0xa6, 0x70, 0xc9, 0xdc, 0xbc,
-	0x8b, 0x9d, 0x45, 0x56, 0x8e, 0x4c, 0x8f, 0xf8, 0x75, 0x53, 0xcd, 0x6e, 0xb9, 0x62, 0xde, 0xdb,
-	0xdb, 0xb0, 0x9c, 0xfb, 0x53, 0x8d, 0xcc, 0x40, 0xe1, 0xf8, 0x51, 0x65, 0x8a, 0x94, 0x60, 0xfa,
-	0x40, 0xd7, 0x8f, 0xf5, 0x8a, 0x52, 0x7b, 0x39, 0x0b, 0xe5, 0xc8, 0x98, 0x77, 0x54, 0x72, 0x04,
-	0xe5, 0x81, 0xb1, 0x9f, 0xac, 0x25, 0xce, 0xb2, 0xbf, 0x33, 0xb4, 0xf5, 0x21, 0xda, 0x20, 0x60,
-	0x3a, 0x45, 0xbe, 0x85, 0x85, 0xcc, 0xa8, 0x49, 0x68, 0xb2, 0x6b, 0xd8, 0xaf, 0x02, 0xed, 0xee,
-	0x48, 0x9b, 0x18, 0xbf, 0x83, 0x27, 0x94, 0x37, 0xca, 0x92, 0xad, 0x11, 0x08, 0xd2, 0xa4, 0xa5,
-	0xdd, 0xbf, 0x84, 0x65, 0xec, 0xd1, 0xc2, 0x3e, 0x92, 0x1e, 0x18, 0xc9, 0x9b, 0x12, 0xc6, 0x90,
-	0xb1, 0x56, 0x7b, 0x6b, 0x8c, 0x55, 0xec, 0xa5, 0x1d, 0x8c, 0x85, 0xd9, 0xb7, 0x96, 0x6c, 0x4a,
-	0x10, 0xc3, 0x9f, 0x71, 0x6d, 0x6b, 0xbc, 0x61, 0xec, 0xee, 0x3b, 0x58, 0xce, 0x1d, 0x44, 0xc8,
-	0x3d, 0x09, 0x64, 0xe8, 0x80, 0xa3, 0x6d, 0x8e, 0xb5, 0x8b, 0x7d, 0x7d, 0x03, 0x95, 0xf4, 0xa4,
-	0x46, 0xee, 0xc8, 0x5c, 0x73, 0xc6, 0x42, 0x8d, 0x8e, 0x32, 0x89, 0xc1, 0x9f, 0xc1, 0x7c, 0x6a,
-	0xa8, 0x25, 0x1b, 0xb9, 0x1b, 0x07, 0xcf, 0xff, 0xce, 0x08, 0x8b, 0x14, 0x6d, 0xe9, 0xd9, 0x4f,
-	0xd1, 0xce, 0x9b, 0x40, 0x52, 0xb4, 0x73, 0xa7, 0x06, 0x3a, 0x55, 0xfb, 0xbd, 0x90, 0x5c, 0x42,
-	0x7e, 0x9b, 0xf9, 0x25, 0x2c, 0xc5, 0x4c, 0xc8, 0xba, 0x04, 0x91, 0x6e, 0xd4, 0xda, 0xad, 0x61,
-	0xea, 0x98, 0x3a, 0x47, 0xab, 0xe7, 0xa1, 0xd5, 0x47, 0xa3, 0xd5, 0xf3, 0xd1, 0x82, 0x44, 0x48,
-	0x9d, 0x27, 0x95, 0x88, 0xbc, 0x86, 0x99, 0x4a, 0x44, 0x6e, 0x83, 0xa4, 0x53, 0x7b, 0xbb, 0x70,
-	0xb3, 0xe1, 0xb6, 0x77, 0x82, 0x3f, 0xaf, 0x76, 0xe4, 0xff, 0xac, 0xf6, 0x2a, 0x03, 0x4d, 0x0d,
-	0x67, 0x9d, 0x27, 0xca, 0xc9, 0x0c, 0xaa, 0x1e, 0xfc, 0x1b, 0x00, 0x00, 0xff, 0xff, 0x08, 0x6b,
-	0x0e, 0x2e, 0x34, 0x13, 0x00, 0x00,
+	// 2773 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xc4, 0x1a, 0x4d, 0x73, 0x23, 0x47,
+	0x75, 0x47, 0xf2, 0x87, 0xf4, 0xe4, 0x0f, 0xb9, 0xe5, 0x5d, 0x6b, 0x67, 0xbf, 0xec, 0x4e, 0x76,
+	0xed, 0x2c, 0x15, 0xdb, 0xd1, 0x42, 0x20, 0x1c, 0x80, 0x5d, 0xaf, 0xe2, 0x38, 0xab, 0x95, 0x37,
+	0x23, 0x27, 0xa1, 0xa0, 0x2a, 0x53, 0x63, 0x4d, 0x5b, 0x1e, 0x56, 0x9a, 0x11, 0x33, 0xa3, 0xb5,
+	0xb5, 0x49, 0x11, 0x3e, 0x8a, 0x22, 0x54, 0x71, 0xe1, 0x42, 0x51, 0x05, 0xdc, 0xb8, 0x70, 0xa6,
+	0x38, 0xf0, 0x07, 0x38, 0xf1, 0x0b, 0xe0, 0x44, 0x71, 0xe3, 0xc6, 0x3f, 0xa0, 0xba, 0x7b, 0xbe,
+	0x7a, 0x34, 0xa3, 0x91, 0xa3, 0xe0, 0xdc, 0x34, 0xaf, 0x5f, 0xbf, 0xaf, 0x7e, 0xef, 0xf5, 0x7b,
+	0xaf, 0x05, 0xaf, 0x77, 0x0c, 0xf7, 0x74, 0x70, 0xbc, 0xdd, 0xb6, 0x7a, 0x3b, 0x1d, 0xcb, 0xea,
+	0x74, 0xc9, 0x8e, 0x6b, 0x1b, 0xdd, 0xae, 0xa1, 0x99, 0xc1, 0x0f, 0x55, 0xeb, 0x1b, 0xdb, 0x7d,
+	0xdb, 0x72, 0x2d, 0x54, 0xf0, 0x61, 0xf2, 0x6b, 0x13, 0x6c, 0xe4, 0x9b, 0xf0, 0x19, 0xac, 0x1c,
+	0x79, 0x90, 0x87, 0x7d, 0xa3, 0xe5, 0x6a, 0xee, 0xc0, 0x41, 0xdf, 0x81, 0x92, 0xc3, 0x7e, 0xa9,
+	0x6d, 0x4b, 0x27, 0x55, 0x69, 0x5d, 0xda, 0x5a, 0xaa, 0xdd, 0xd9, 0x0e, 0xb6, 0x8e, 0xec, 0xd8,
+	0xb3, 0x74, 0xa2, 0x80, 0x13, 0xfc, 0x46, 0xeb, 0x50, 0xd2, 0x89, 0xd3, 0xb6, 0x8d, 0xbe, 0x6b,
+	0x58, 0x66, 0x35, 0xb7, 0x2e, 0x6d, 0x15, 0x95, 0x28, 0x08, 0xff, 0x53, 0x82, 0x62, 0x83, 0x68,
+	0x27, 0xcf, 0x98, 0xec, 0x37, 0xa0, 0xd8, 0x25, 0xda, 0x89, 0x7a, 0xaa, 0x39, 0xa7, 0x8c, 0xdf,
+	0x82, 0x52, 0xa0, 0x80, 0x77, 0x34, 0xe7, 0x34, 0x58, 0xd4, 0x35, 0x57, 0xab, 0xe6, 0xc2, 0xc5,
+	0xc7, 0x9a, 0xab, 0xa1, 0x5b, 0x00, 0xe4, 0xdc, 0xb5, 0x35, 0xbe, 0x9a, 0x67, 0xab, 0x45, 0x06,
+	0xf1, 0x97, 0xd9, 0x5e, 0xc3, 0xd4, 0xc9, 0x79, 0x75, 0x66, 0x5d, 0xda, 0xca, 0x2b, 0x8c, 0xda,
+	0x01, 0x05, 0xa0, 0x6f, 0xc2, 0x75, 0xc3, 0x74, 0x49, 0xc7, 0xd6, 0x5c, 0xa2, 0xba, 0x46, 0x8f,
+	0x38, 0xae, 0xd6, 0xeb, 0xab, 0xa6, 0x66, 0x5a, 0x4e, 0x75, 0x96, 0x61, 0xaf, 0x05, 0x08, 0x47,
+	0xfe, 0x7a, 0x93, 0x2e, 0x23, 0x19, 0x0a, 0x7d, 0xdb, 0xb0, 0x6c, 0xc3, 0x1d, 0x56, 0xe7, 0xd6,
+	0xa5, 0xad, 0x59, 0x25, 0xf8, 0xc6, 0x27, 0x50, 0x6c, 0x5a, 0x3a, 0xe1, 0xca, 0xad, 0xc1, 0xbc,
+	0x69, 0xe9, 0x44, 0x35, 0x74, 0x4f, 0xb5, 0x39, 0xfa, 0x79, 0xa0, 0x53, 0xc5, 0xd8, 0x02, 0xd3,
+	0xda, 0x53, 0x8c, 0x02, 0x98, 0xd6, 0xaf, 0xc0, 0x22, 0x5b, 0xb4, 0xc9, 0x0b, 0xc3, 0xa1, 0x46,
+	0xcc, 0x33, 0x71, 0x16, 0x28, 0x50, 0xf1, 0x60, 0x58, 0x05, 0x78, 0x66, 0x5b, 0x96, 0x67, 0x45,
+	0x51, 0x59, 0x29, 0xae, 0x6c, 0x0d, 0xa0, 0x4f, 0x91, 0x55, 0x4a, 0xa2, 0x9a, 0x5b, 0xcf, 0x6f,
+	0x95, 0x6a, 0x95, 0xf0, 0x54, 0x03, 0x81, 0x95, 0x22, 0x43, 0xa3, 0xdf, 0xf8, 0x27, 0x12, 0xa0,
+	0xf7, 0x06, 0x64, 0x40, 0x1a, 0x44, 0x7b, 0x41, 0x1c, 0x85, 0xfc, 0x70, 0x40, 0x1c, 0x17, 0x5d,
+	0x85, 0xb9, 0xae, 0xd5, 0xf1, 0x35, 0xca, 0x2b, 0xb3, 0x5d, 0xab, 0x73, 0xa0, 0xa3, 0xaf, 0xc0,
+	0x5c, 0x97, 0xe1, 0x8d, 0x52, 0x0f, 0xce, 0x5a, 0xf1, 0x50, 0xd0, 0x26, 0x2c, 0x1b, 0x3a, 0xe9,
+	0xf5, 0x2d, 0x97, 0x98, 0xed, 0xa1, 0xfa, 0x9c, 0x0c, 0x99, 0x8a, 0x45, 0x65, 0x29, 0x02, 0x7e,
+	0x42, 0x86, 0xf8, 0x5d, 0xa8, 0x08, 0x22, 0x38, 0x7d, 0xcb, 0x74, 0x08, 0x7a, 0x00, 0x73, 0xdc,
+	0xe3, 0x98, 0x0c, 0xa5, 0xda, 0x8d, 0x31, 0x0e, 0xaa, 0x78, 0xa8, 0xb8, 0x07, 0xd5, 0x7d, 0xe2,
+	0x1e, 0x98, 0xed, 0xee, 0x80, 0x1a, 0x90, 0x19, 0x2f, 0x43, 0x29, 0xd1, 0xaa, 0xb9, 0xb8, 0x55,
+	0x6f, 0x40, 0xd1, 0xb5, 0x09, 0x51, 0x1d, 0xe3, 0x25, 0xf1, 0xce, 0xa8, 0x40, 0x01, 0x2d, 0xe3,
+	0x25, 0xc1, 0x9f, 0xc0, 0xf5, 0x04, 0x76, 0x53, 0x28, 0x80, 0xee, 0xc3, 0x2c, 0x3b, 0x1d, 0x26,
+	0x48, 0xa9, 0xb6, 0x1a, 0xee, 0x09, 0x1d, 0x41, 0xe1, 0x28, 0xf8, 0x0f, 0x12, 0xdc, 0x1e, 0x61,
+	0xff, 0x68, 0x48, 0xdd, 0x2b, 0x43, 0x67, 0x21, 0x1e, 0x73, 0xa3, 0xf1, 0x98, 0xaa, 0x31, 0xba,
+	0x0f, 0x2b, 0x96, 0xad, 0x13, 0x5b, 0x3d, 0x1e, 0xaa, 0x0e, 0x65, 0x62, 0xb6, 0x09, 0x8b, 0xbb,
+	0x82, 0xb2, 0xcc, 0x16, 0x1e, 0x0d, 0x5b, 0x1e, 0x18, 0xff, 0x54, 0x82, 0x3b, 0xa9, 0xf2, 0x7d,
+	0x41, 0x46, 0xca, 0x67, 0x19, 0xe9, 0xe7, 0x12, 0xc8, 0xfb, 0xc4, 0xdd, 0xb3, 0x4c, 0xc7, 0x70,
+	0x98, 0xcf, 0x4d, 0xe2, 0x14, 0xf7, 0x60, 0xf9, 0xc4, 0xb0, 0x1d, 0x57, 0x0d, 0x2d, 0xc1, 0x3d,
+	0x63, 0x91, 0x81, 0x8f, 0x7c, 0x73, 0x6c, 0x41, 0xd9, 0x21, 0x6d, 0xcb, 0xd4, 0xd5, 0xb8, 0xc9,
+	0x96, 0x38, 0xdc, 0xc7, 0xc4, 0x3f, 0x82, 0x1b, 0x89, 0x62, 0x5c, 0x96, 0xb3, 0x7c, 0x26, 0xc1,
+	0xea, 0x3e, 0x71, 0x15, 0xcd, 0xec, 0x90, 0x49, 0x2c, 0x70, 0x87, 0x5d, 0x12, 0xb6, 0x2b, 0xc4,
+	0x05, 0x30, 0x50, 0x10, 0x18, 0xc4, 0xd4, 0xbd, 0x65, 0xcf, 0x4d, 0x88, 0xa9, 0x27, 0x44, 0xcd,
+	0x4c, 0x2c, 0x6a, 0xce, 0xe1, 0x6a, 0x4c, 0x92, 0xcb, 0x32, 0xc2, 0x39, 0x5c, 0xdb, 0x27, 0x2e,
+	0x4f, 0x34, 0x9f, 0x27, 0x50, 0xf2, 0x42, 0xa0, 0x24, 0xc6, 0x42, 0x3e, 0x39, 0x16, 0x3e, 0x86,
+	0xb5, 0x11, 0xce, 0xd3, 0x68, 0x7d, 0x91, 0x54, 0x8c, 0xcf, 0x04, 0xe6, 0xec, 0x84, 0x2e, 0x98,
+	0x14, 0xf3, 0x62, 0x52, 0xbc, 0x07, 0xcb, 0x56, 0xcf, 0x70, 0xd5, 0xd8, 0xd5, 0x5c, 0x50, 0x16,
+	0x29, 0xb8, 0xee, 0x5f, 0xcf, 0xf8, 0x13, 0xa8, 0x8e, 0x32, 0xbe, 0x34, 0xb5, 0xff, 0x25, 0xc1,
+	0x8d, 0x08, 0xfb, 0xe0, 0x7e, 0xcf, 0xd0, 0xbd, 0x06, 0x57, 0xb9, 0xe7, 0xc7, 0x0b, 0x06, 0x1e,
+	0x03, 0x15, 0xb6, 0x18, 0x2b, 0x16, 0xb6, 0xa1, 0x42, 0x83, 0x21, 0xbe, 0x83, 0x87, 0xc5, 0x0a,
+	0x31, 0xf5, 0x18, 0x3e, 0xcd, 0x1b, 0x8c, 0xc7, 0x48, 0xf5, 0xb2, 0xc4, 0xe0, 0x8d, 0xc0, 0xd4,
+	0xb7, 0x00, 0x7a, 0xda, 0xb9, 0xea, 0x69, 0xcd, 0x6b, 0x96, 0x62, 0x4f, 0x3b, 0xe7, 0x5a, 0xe1,
+	0x1f, 0x4b, 0x70, 0x33, 0x59, 0xc7, 0x4b, 0x33, 0xf3, 0xd7, 0x98, 0x04, 0xbe, 0xa7, 0xeb, 0x14,
+	0x61, 0xcf, 0x1a, 0x98, 0xee, 0x78, 0x33, 0x63, 0x07, 0x6e, 0xa5, 0x6c, 0x9b, 0x46, 0x72, 0xdf,
+	0x71, 0xdb, 0x94, 0x54, 0xf4, 0x36, 0x67, 0xb4, 0xf1, 0x9b, 0x8c, 0x69, 0x43, 0x73, 0x89, 0xe3,
+	0xb6, 0x8c, 0x8e, 0x49, 0xf4, 0x86, 0xd5, 0x51, 0x2c, 0x2b, 0x4b, 0xd8, 0xdf, 0xf0, 0xab, 0x36,
+	0x71, 0xe3, 0x34, 0xe2, 0x7e, 0x1b, 0x96, 0x1d, 0x46, 0x4d, 0xa5, 0x5c, 0x6d, 0xcb, 0x72, 0xbd,
+	0x34, 0xb6, 0x16, 0xee, 0x16, 0xd9, 0x2d, 0x3a, 0xd1, 0x4f, 0xfc, 0x56, 0x9a, 0x5c, 0x41, 0x2d,
+	0xb7, 0x06, 0xf3, 0x5c, 0x23, 0x2a, 0x18, 0x8d, 0xe3, 0x39, 0xa6, 0x92, 0x83, 0x7f, 0x2f, 0xc1,
+	0x62, 0xa8, 0xc4, 0xa0, 0x9b, 0x1a, 0x10, 0xa1, 0x66, 0xb9, 0xa9, 0x34, 0xcb, 0x5f, 0x48, 0xb3,
+	0x5f, 0xf2, 0xea, 0x21, 0x59, 0xb5, 0x69, 0x6c, 0xfe, 0x06, 0xcc, 0xdb, 0x4c, 0x5f, 0xdf, 0xbb,
+	0x23, 0x12, 0x09, 0xf6, 0x50, 0x7c, 0x3c, 0xfc, 0x11, 0xdc, 0x4b, 0x16, 0xe5, 0xa1, 0xa9, 0x4f,
+	0x54, 0x39, 0x8b, 0x51, 0x9c, 0x8b, 0x47, 0xf1, 0xdf, 0x25, 0xd8, 0xcc, 0x64, 0xf0, 0x65, 0xfa,
+	0x59, 0x24, 0x23, 0xe4, 0xb3, 0x33, 0x42, 0x97, 0xdd, 0x37, 0x75, 0xd3, 0xb5, 0x87, 0x0f, 0x4d,
+	0xfd, 0xff, 0x5d, 0x84, 0xff, 0x51, 0x82, 0xea, 0x28, 0xbb, 0x4b, 0x2a, 0x29, 0xd0, 0x26, 0xcc,
+	0x50, 0x39, 0x3d, 0xe7, 0x4e, 0x34, 0x0b, 0x43, 0xc0, 0xbf, 0x96, 0x58, 0xf1, 0xe1, 0xf7, 0x76,
+	0x8f, 0x8d, 0x93, 0x2c, 0xa3, 0x6c, 0x43, 0x25, 0x52, 0x84, 0x06, 0x8d, 0x22, 0xb7, 0xce, 0x4a,
+	0x50, 0x88, 0xfa, 0x14, 0xd1, 0x2e, 0xac, 0x46, 0x8b, 0xd1, 0x58, 0x67, 0x89, 0xc2, 0x82, 0x34,
+	0xe8, 0x2f, 0x5f, 0xc2, 0x22, 0x6d, 0x03, 0xa9, 0x2c, 0x19, 0xbd, 0x6c, 0x50, 0x10, 0xc7, 0x3b,
+	0x5a, 0x5e, 0x10, 0x37, 0xfd, 0xb6, 0x36, 0x2c, 0x88, 0x43, 0x44, 0xde, 0xb5, 0x7b, 0x05, 0xb1,
+	0x8f, 0x89, 0xff, 0x9b, 0x63, 0x5e, 0x22, 0xda, 0x63, 0x9a, 0x53, 0x7b, 0x17, 0xae, 0x72, 0x11,
+	0x2f, 0xe8, 0xe9, 0x88, 0xed, 0x12, 0x60, 0xa8, 0x01, 0xd7, 0x3c, 0x35, 0x2e, 0x98, 0xc4, 0x2a,
+	0x7c, 0x9b, 0x48, 0x2d, 0xf0, 0xa7, 0x99, 0x6c, 0x7f, 0xba, 0x0b, 0x4b, 0xd4, 0x72, 0x74, 0x36,
+	0xd3, 0xeb, 0x6b, 0x36, 0xd1, 0xbd, 0x3b, 0x9f, 0x4d, 0x0b, 0x9c, 0x3d, 0x0f, 0x88, 0xbe, 0xea,
+	0xcd, 0x16, 0x74, 0xe3, 0xe4, 0xa4, 0x3a, 0x17, 0x4f, 0x63, 0xc2, 0xa1, 0xf2, 0xa1, 0x03, 0xfd,
+	0xc4, 0x4d, 0x58, 0x7e, 0xbb, 0x3b, 0x70, 0x4e, 0xa9, 0x60, 0xe3, 0x7d, 0xef, 0x55, 0x58, 0x3a,
+	0xb1, 0xec, 0x36, 0x51, 0x4d, 0x72, 0x16, 0x5a, 0xb1, 0xa0, 0x2c, 0x30, 0x68, 0x93, 0x9c, 0xb1,
+	0x1c, 0xfd, 0x17, 0x09, 0xca, 0x21, 0xc1, 0xe9, 0x2a, 0x8e, 0x15, 0x9e, 0x3c, 0xd4, 0x60, 0x1e,
+	0xa3, 0x7b, 0x9e, 0x5e, 0xe6, 0x0b, 0x07, 0x01, 0x7c, 0xfa, 0xbb, 0xe5, 0x01, 0xc8, 0xad, 0xc1,
+	0x31, 0x9d, 0x56, 0x1d, 0x13, 0x1a, 0x10, 0xf5, 0x17, 0xc4, 0x74, 0x33, 0x72, 0x38, 0xfe, 0x87,
+	0x04, 0xc5, 0x00, 0x19, 0xbd, 0x09, 0x40, 0xe8, 0x0f, 0xd5, 0x1d, 0xf6, 0xfd, 0x19, 0xda, 0x5a,
+	0x54, 0x53, 0x0f, 0xf1, 0x68, 0xd8, 0x27, 0x4a, 0x91, 0xf8, 0x3f, 0x23, 0xc4, 0x73, 0x51, 0x7b,
+	0x4f, 0xab, 0x12, 0x5a, 0x85, 0x59, 0x62, 0xdb, 0x96, 0xcd, 0x7c, 0xac, 0xa8, 0xf0, 0x0f, 0x3a,
+	0x84, 0x49, 0x1e, 0x7b, 0x2d, 0xb9, 0x42, 0x41, 0x8a, 0x1f, 0xc1, 0x72, 0xc3, 0xea, 0xbc, 0x4d,
+	0x34, 0x77, 0x60, 0x7b, 0x73, 0xad, 0x14, 0xcf, 0xa8, 0xc2, 0x3c, 0x31, 0xb5, 0xe3, 0xae, 0x77,
+	0x3e, 0x05, 0xc5, 0xff, 0xc4, 0xcf, 0x61, 0x41, 0x20, 0x80, 0x60, 0xc6, 0xd4, 0x7a, 0xdc, 0x38,
+	0x45, 0x85, 0xfd, 0x4e, 0xdf, 0x8d, 0x5e, 0x87, 0x99, 0xae, 0xd5, 0xf1, 0xef, 0x97, 0xeb, 0xc2,
+	0x9d, 0x1c, 0x25, 0xab, 0x30, 0x34, 0x6c, 0xc2, 0x4a, 0x8b, 0xb8, 0xde, 0x82, 0x7f, 0x72, 0x49,
+	0x1c, 0x53, 0x0c, 0x1e, 0x11, 0x24, 0x2f, 0x0a, 0xb2, 0x0a, 0xb3, 0x36, 0x71, 0x88, 0xeb, 0x8d,
+	0x35, 0xf8, 0x07, 0xfe, 0x18, 0x50, 0x94, 0xdf, 0x34, 0xbe, 0xbe, 0x0b, 0xf3, 0x27, 0x9c, 0x8e,
+	0x97, 0x9a, 0xae, 0x85, 0xbb, 0x04, 0x4d, 0x7d, 0x34, 0x7c, 0x15, 0x2a, 0x0d, 0xc3, 0xf1, 0xb9,
+	0xfb, 0x8e, 0x8a, 0x3f, 0x85, 0x55, 0x11, 0x3c, 0x8d, 0x54, 0x35, 0x28, 0x78, 0xec, 0xfc, 0xba,
+	0x28, 0x4d, 0xac, 0x00, 0x8f, 0x5e, 0xbd, 0x0b, 0xd4, 0xd3, 0x9f, 0x12, 0x57, 0xa3, 0x5d, 0x20,
+	0xda, 0x80, 0x05, 0xdd, 0x70, 0xfa, 0x5d, 0x6d, 0xa8, 0x46, 0x0e, 0xa2, 0xe4, 0xc1, 0x9a, 0x5a,
+	0x6f, 0x82, 0xd9, 0x31, 0x1d, 0x8d, 0x5a, 0x67, 0x26, 0xb1, 0xd5, 0xb6, 0x65, 0xba, 0x5a, 0xdb,
+	0xf5, 0xe6, 0x86, 0x0b, 0x0c, 0xb8, 0xc7, 0x61, 0xb4, 0xf9, 0x6e, 0xdb, 0xc4, 0x9f, 0xeb, 0x7a,
+	0xbe, 0xcd, 0x5b, 0xa8, 0x65, 0xbe, 0x40, 0x7b, 0x21, 0xee, 0xdc, 0x3b, 0xec, 0xe6, 0x8d, 0x0a,
+	0x9a, 0x11, 0xea, 0xbf, 0xe2, 0x77, 0x93, 0xb8, 0x63, 0x4a, 0xe3, 0xf6, 0x3c, 0x42, 0xa3, 0x67,
+	0x2e, 0xb0, 0x09, 0xf0, 0xd0, 0xb7, 0x60, 0x89, 0x5e, 0x9f, 0xaa, 0xd6, 0xed, 0x58, 0xb6, 0xe1,
+	0x9e, 0xf6, 0xaa, 0xf9, 0x78, 0x96, 0xa1, 0x17, 0xe9, 0x43, 0x7f, 0x59, 0x59, 0x3c, 0x8d, 0x7e,
+	0xa2, 0x77, 0x00, 0x05, 0xb3, 0x0b, 0xd5, 0x71, 0x69, 0xea, 0xec, 0x0c, 0x99, 0x89, 0x96, 0x6a,
+	0xb2, 0x58, 0xa7, 0x50, 0x3a, 0x2d, 0x0f, 0x83, 0xe5, 0x5b, 0x01, 0x82, 0xdb, 0x70, 0xad, 0x75,
+	0x11, 0xfb, 0x7d, 0x1e, 0x75, 0xe9, 0xb4, 0x70, 0xad, 0xf5, 0x25, 0xdb, 0x1c, 0xff, 0x49, 0x82,
+	0x95, 0x23, 0x9a, 0x06, 0x5a, 0xae, 0x65, 0x6b, 0x1d, 0x42, 0x49, 0x3a, 0x34, 0x23, 0xb8, 0x14,
+	0xe8, 0xb9, 0x33, 0xff, 0xa0, 0x45, 0xa9, 0x6d, 0x9d, 0x09, 0x9d, 0x66, 0xc1, 0xb6, 0xce, 0x58,
+	0xa3, 0x49, 0x17, 0x8f, 0x87, 0xae, 0x58, 0xb1, 0x52, 0x00, 0x9b, 0x1a, 0xae, 0xc3, 0x82, 0x6d,
+	0x9d, 0x39, 0x6a, 0x9f, 0xd8, 0xaa, 0xae, 0xf1, 0x33, 0x91, 0x14, 0xa0, 0xb0, 0x67, 0xc4, 0x7e,
+	0xac, 0x0d, 0x11, 0x86, 0x45, 0x8a, 0x1d, 0xa2, 0xcc, 0x32, 0x94, 0x12, 0x03, 0x72, 0x1c, 0x7a,
+	0x89, 0x79, 0x3e, 0x1a, 0x15, 0x36, 0xc3, 0xb3, 0x7f, 0xc1, 0x67, 0x22, 0xa3, 0xbb, 0xa6, 0xb1,
+	0xf4, 0x03, 0x98, 0x63, 0x26, 0xf1, 0x13, 0x47, 0x74, 0x53, 0xdc, 0x98, 0x8a, 0x87, 0x4a, 0x83,
+	0xf2, 0x99, 0x36, 0x70, 0x88, 0x37, 0x01, 0x30, 0xcc, 0x8c, 0x92, 0x04, 0x37, 0x61, 0x6d, 0x64,
+	0xc3, 0x34, 0x6f, 0x05, 0xbb, 0xb0, 0x46, 0xfb, 0xbc, 0xde, 0xe4, 0x12, 0x1c, 0x42, 0x75, 0x74,
+	0xc7, 0x34, 0x22, 0xe8, 0x30, 0xff, 0x54, 0xeb, 0xd3, 0x08, 0x1c, 0xff, 0x44, 0xe6, 0xb7, 0x47,
+	0x2f, 0xb4, 0xee, 0x80, 0x78, 0x85, 0x37, 0x43, 0xff, 0x80, 0x02, 0x32, 0x1e, 0xc9, 0x70, 0x1d,
+	0x0a, 0x4f, 0xc8, 0x90, 0xa3, 0x96, 0x21, 0x4f, 0x5f, 0x62, 0x38, 0x03, 0xfa, 0x13, 0x6d, 0xc2,
+	0x6c, 0x48, 0xb6, 0x54, 0x5b, 0x09, 0xe5, 0xf6, 0x44, 0x53, 0xf8, 0x3a, 0x3e, 0x86, 0x15, 0x9f,
+	0x4c, 0x30, 0xd2, 0x47, 0x3b, 0x50, 0x7c, 0x4e, 0x86, 0x9e, 0x60, 0x5c, 0x73, 0x14, 0x52, 0xf0,
+	0xf1, 0x95, 0xc2, 0x73, 0x5f, 0x80, 0x9b, 0x50, 0x34, 0xfc, 0xdd, 0xde, 0x44, 0x35, 0x04, 0xd0,
+	0xf7, 0xa8, 0xca, 0x3e, 0x71, 0x39, 0x67, 0xb1, 0xad, 0xee, 0x69, 0xfd, 0xc8, 0x81, 0xf4, 0xb4,
+	0xfe, 0x81, 0xee, 0x6b, 0xc3, 0xc9, 0x30, 0x6d, 0x64, 0x28, 0xc4, 0xfa, 0x9e, 0xe0, 0x9b, 0x96,
+	0xd6, 0x6c, 0x6a, 0x19, 0xf2, 0x9f, 0x09, 0x87, 0x96, 0x81, 0x4a, 0xf8, 0xaf, 0x7c, 0x52, 0x1e,
+	0x91, 0x61, 0x9a, 0xd8, 0xf8, 0x46, 0xd4, 0x40, 0x23, 0xe1, 0x31, 0x62, 0xd0, 0x88, 0xa5, 0x68,
+	0xfe, 0xd2, 0xfa, 0x63, 0x6b, 0xc1, 0xa7, 0x5a, 0x9f, 0xd5, 0x82, 0xf3, 0x3d, 0xfe, 0x03, 0xff,
+	0x56, 0x82, 0x4a, 0x6b, 0x72, 0xfb, 0xed, 0x8c, 0x0a, 0x37, 0xfe, 0xf4, 0xde, 0x82, 0x52, 0x4f,
+	0xeb, 0xf3, 0xa4, 0xe4, 0xb9, 0x5a, 0xa9, 0x56, 0x15, 0x5c, 0xa6, 0x4f, 0xec, 0x20, 0xb1, 0x02,
+	0x47, 0x66, 0x5e, 0xf8, 0x29, 0xac, 0xb6, 0xbe, 0x30, 0xab, 0x46, 0x6d, 0x93, 0x9b, 0xd0, 0x36,
+	0xbb, 0xec, 0x4e, 0x17, 0x17, 0xc7, 0x9a, 0x07, 0xff, 0x8c, 0x4f, 0x16, 0x62, 0x5b, 0x2e, 0x5b,
+	0x6e, 0x95, 0x09, 0xe1, 0x05, 0xe3, 0x3b, 0x86, 0xe3, 0x5a, 0xf6, 0x70, 0xd2, 0xb8, 0x90, 0x26,
+	0x88, 0x0b, 0xfc, 0x67, 0x09, 0x2a, 0x22, 0x79, 0x36, 0x4b, 0xa1, 0xc5, 0x1c, 0x13, 0xd6, 0xdf,
+	0xc7, 0x59, 0x50, 0x07, 0x08, 0x46, 0x0e, 0x93, 0x26, 0x0f, 0x31, 0xec, 0xf3, 0xb1, 0xb0, 0x17,
+	0xcc, 0x32, 0x33, 0xa1, 0x59, 0x7e, 0x27, 0xb1, 0xc7, 0xd7, 0xb8, 0x5d, 0xa6, 0x39, 0x9d, 0x51,
+	0xb3, 0x7d, 0x1d, 0xe6, 0x4f, 0x39, 0x65, 0xaf, 0x2f, 0xb9, 0x35, 0xa2, 0x61, 0xd4, 0x64, 0x8a,
+	0x8f, 0x7d, 0xff, 0x3e, 0x5c, 0x4d, 0xfc, 0x1b, 0x05, 0x9a, 0x83, 0xdc, 0xe1, 0x93, 0xf2, 0x15,
+	0x54, 0x84, 0xd9, 0xba, 0xa2, 0x1c, 0x2a, 0x65, 0xe9, 0x7e, 0x1b, 0x16, 0x85, 0x76, 0x11, 0x5d,
+	0x03, 0xf4, 0x7e, 0xf3, 0x49, 0xf3, 0xf0, 0xc3, 0xa6, 0x7a, 0xa4, 0xd4, 0xeb, 0x6a, 0xfd, 0x83,
+	0x7a, 0xf3, 0xa8, 0x7c, 0x05, 0x55, 0x60, 0xb9, 0x59, 0xff, 0x50, 0x6d, 0x1d, 0xec, 0x37, 0xeb,
+	0x8f, 0x55, 0xe5, 0xf0, 0xf0, 0xa8, 0x2c, 0xa1, 0x65, 0x28, 0x31, 0xa4, 0xb7, 0x95, 0xc3, 0xef,
+	0xd5, 0x9b, 0xe5, 0x1c, 0x5a, 0x85, 0x72, 0xab, 0xfe, 0xde, 0xfb, 0xf5, 0xe6, 0xde, 0x41, 0x73,
+	0x5f, 0xe5, 0x4c, 0xf2, 0xb5, 0xbf, 0x2d, 0x40, 0xc9, 0x97, 0xa8, 0x61, 0x75, 0x50, 0x03, 0x4a,
+	0x91, 0x57, 0x77, 0x74, 0x33, 0xd4, 0x6b, 0xf4, 0xff, 0x00, 0xf2, 0xad, 0x94, 0x55, 0x6e, 0x6c,
+	0x7c, 0x05, 0x7d, 0x04, 0x2b, 0x23, 0x2f, 0xbd, 0x08, 0x87, 0xbb, 0xd2, 0x1e, 0xe5, 0xe5, 0x57,
+	0xc6, 0xe2, 0x04, 0xf4, 0xfb, 0xb0, 0x36, 0xb2, 0xcc, 0x9f, 0xd1, 0xd0, 0xd6, 0x18, 0x0a, 0xc2,
+	0x1b, 0x9f, 0xfc, 0xda, 0x04, 0x98, 0x01, 0x47, 0x1d, 0x2a, 0x09, 0xef, 0xb5, 0xe8, 0x55, 0x81,
+	0x46, 0xca, 0xab, 0xb2, 0x7c, 0x37, 0x03, 0x2b, 0xe0, 0xd2, 0x83, 0x6b, 0xc9, 0x73, 0x5f, 0xb4,
+	0x29, 0x90, 0x48, 0x7f, 0xb1, 0x90, 0xb7, 0xb2, 0x11, 0x03, 0x76, 0x3f, 0x60, 0x2f, 0xaf, 0xa3,
+	0x6f, 0x2e, 0xe8, 0x9e, 0x40, 0x24, 0xf5, 0x2d, 0x47, 0xde, 0xcc, 0xc4, 0x0b, 0x78, 0x7d, 0x1f,
+	0xca, 0xf1, 0xb7, 0x3f, 0xb4, 0x21, 0xca, 0x9a, 0xf0, 0x20, 0x29, 0xe3, 0x71, 0x28, 0x01, 0xf1,
+	0xef, 0xc2, 0x72, 0xec, 0x39, 0x15, 0xad, 0x27, 0x6e, 0x8c, 0x9e, 0xff, 0xc6, 0x18, 0x8c, 0x80,
+	0x72, 0x07, 0x56, 0x23, 0x8b, 0xc1, 0x7b, 0x1a, 0xba, 0x9b, 0xb8, 0x39, 0xfe, 0xa6, 0x28, 0xdf,
+	0xcb, 0x42, 0x8b, 0xd9, 0x47, 0x98, 0x5a, 0xc7, 0xec, 0x93, 0x34, 0x40, 0x97, 0xf1, 0x38, 0x94,
+	0x98, 0x7d, 0xa2, 0xb3, 0xd5, 0x98, 0x7d, 0x12, 0xc6, 0xd0, 0xf2, 0xc6, 0x18, 0x8c, 0x18, 0x65,
+	0xa1, 0xe9, 0x17, 0x29, 0x27, 0xb4, 0x89, 0xf2, 0xc6, 0x18, 0x8c, 0x80, 0xf2, 0x11, 0x54, 0x12,
+	0x86, 0x72, 0xd1, 0x88, 0x4b, 0x9f, 0xd9, 0xc9, 0x95, 0x84, 0xd1, 0x1b, 0xbe, 0xb2, 0x2b, 0x21,
+	0x05, 0x16, 0x85, 0x3f, 0x1b, 0xa0, 0xdb, 0xa2, 0x96, 0xf1, 0xff, 0x43, 0xc8, 0x77, 0x52, 0xd7,
+	0x63, 0xd9, 0x28, 0xe9, 0x65, 0x0a, 0x65, 0x46, 0xa3, 0x93, 0x9c, 0x8d, 0xc6, 0x3d, 0x73, 0xe1,
+	0x2b, 0xe8, 0xb3, 0xd4, 0xc7, 0xb0, 0xe0, 0x81, 0x08, 0xed, 0x66, 0x11, 0x8c, 0x3f, 0x56, 0xc9,
+	0x6f, 0x5c, 0x60, 0x87, 0x2f, 0x4a, 0xed, 0x3f, 0x33, 0x50, 0x8e, 0x5c, 0x24, 0x0f, 0xf5, 0x9e,
+	0x61, 0xa2, 0x3d, 0x28, 0xf8, 0x73, 0x60, 0x14, 0x19, 0xdd, 0xc5, 0x86, 0xcd, 0xb2, 0x9c, 0xb4,
+	0x14, 0x28, 0x79, 0x00, 0x10, 0x8e, 0xd8, 0x50, 0xe4, 0xc6, 0x1e, 0x19, 0xf4, 0xc9, 0x37, 0x93,
+	0x17, 0x03, 0x52, 0x87, 0xb0, 0x10, 0x9d, 0x8c, 0xa1, 0xc8, 0x05, 0x96, 0x30, 0x48, 0x93, 0x6f,
+	0xa7, 0x2d, 0x47, 0xdd, 0xbe, 0x95, 0xee, 0xf6, 0xad, 0x4c, 0xb7, 0x6f, 0xa5, 0xba, 0x3d, 0xbf,
+	0x68, 0xe2, 0x0d, 0x79, 0xec, 0xa2, 0x49, 0xe9, 0xf2, 0xe5, 0xbb, 0x19, 0x58, 0x51, 0xf9, 0x63,
+	0xcd, 0x73, 0x54, 0xfe, 0xe4, 0x46, 0x5c, 0xde, 0x18, 0x83, 0x11, 0xcd, 0x63, 0xf1, 0xa6, 0x38,
+	0x9a, 0xc7, 0x52, 0x5a, 0x6c, 0x19, 0x8f, 0x43, 0x09, 0x9c, 0xed, 0xdf, 0xb9, 0xb0, 0x6a, 0x79,
+	0xaa, 0xf5, 0x51, 0x03, 0x8a, 0x41, 0x5a, 0x8d, 0x1e, 0x6a, 0x42, 0xcf, 0x28, 0xdf, 0x4e, 0x5b,
+	0x0e, 0x44, 0x6f, 0x40, 0xb1, 0x95, 0x44, 0xad, 0x35, 0x9e, 0x5a, 0x2b, 0x99, 0x1a, 0x4f, 0xe8,
+	0x42, 0xb5, 0x1a, 0x4b, 0xe8, 0x49, 0xbd, 0x87, 0x8c, 0xc7, 0xa1, 0x44, 0x88, 0x2f, 0x71, 0xc5,
+	0xfd, 0x7a, 0x33, 0x56, 0x5d, 0x25, 0xb6, 0x07, 0xf2, 0x2b, 0x63, 0x71, 0x7c, 0xe2, 0x8f, 0x76,
+	0xe0, 0x7a, 0xdb, 0xea, 0x6d, 0xf3, 0xff, 0x12, 0x6f, 0x8b, 0x7f, 0x21, 0x7e, 0x54, 0x8e, 0xd4,
+	0xb1, 0x6c, 0xfe, 0xfb, 0x4c, 0x3a, 0x9e, 0x63, 0x4b, 0x0f, 0xfe, 0x37, 0x00, 0x25, 0x36, 0x84,
+	0x69, 0xc3, 0x2c, 0x00, 0x00,
 }
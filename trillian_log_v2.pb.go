@@ -0,0 +1,474 @@
+// Code generated by protoc-gen-go.
+// source: github.com/google/trillian/trillian_log_v2.proto
+// DO NOT EDIT!
+
+package trillian
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// QueuedLeafStatus says what happened to a leaf submitted with QueueLeavesV2
+type QueuedLeafStatus int32
+
+const (
+	QueuedLeafStatus_UNKNOWN_QUEUED_LEAF_STATUS QueuedLeafStatus = 0
+	// The leaf was queued and will be integrated into the tree by the sequencer
+	QueuedLeafStatus_LEAF_QUEUED QueuedLeafStatus = 1
+	// A leaf with the same hash is already in the tree, it's returned instead and the
+	// submitted leaf was not queued again
+	QueuedLeafStatus_LEAF_ALREADY_INTEGRATED QueuedLeafStatus = 2
+	// The leaf was not queued, the description says why
+	QueuedLeafStatus_LEAF_REJECTED QueuedLeafStatus = 3
+)
+
+var QueuedLeafStatus_name = map[int32]string{
+	0: "UNKNOWN_QUEUED_LEAF_STATUS",
+	1: "LEAF_QUEUED",
+	2: "LEAF_ALREADY_INTEGRATED",
+	3: "LEAF_REJECTED",
+}
+var QueuedLeafStatus_value = map[string]int32{
+	"UNKNOWN_QUEUED_LEAF_STATUS": 0,
+	"LEAF_QUEUED":                1,
+	"LEAF_ALREADY_INTEGRATED":    2,
+	"LEAF_REJECTED":              3,
+}
+
+func (x QueuedLeafStatus) String() string {
+	return proto.EnumName(QueuedLeafStatus_name, int32(x))
+}
+func (QueuedLeafStatus) EnumDescriptor() ([]byte, []int) { return fileDescriptor1, []int{0} }
+
+type QueueLeavesV2Request struct {
+	LogId  int64        `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	Leaves []*LeafProto `protobuf:"bytes,2,rep,name=leaves" json:"leaves,omitempty"`
+}
+
+func (m *QueueLeavesV2Request) Reset()                    { *m = QueueLeavesV2Request{} }
+func (m *QueueLeavesV2Request) String() string            { return proto.CompactTextString(m) }
+func (*QueueLeavesV2Request) ProtoMessage()               {}
+func (*QueueLeavesV2Request) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{0} }
+
+func (m *QueueLeavesV2Request) GetLeaves() []*LeafProto {
+	if m != nil {
+		return m.Leaves
+	}
+	return nil
+}
+
+// QueuedLeaf is the outcome of queuing one of the leaves of a QueueLeavesV2Request.
+type QueuedLeaf struct {
+	// The leaf as submitted with its hash set, or for LEAF_ALREADY_INTEGRATED the leaf in the
+	// tree with its index
+	Leaf        *LeafProto       `protobuf:"bytes,1,opt,name=leaf" json:"leaf,omitempty"`
+	Status      QueuedLeafStatus `protobuf:"varint,2,opt,name=status,enum=trillian.QueuedLeafStatus" json:"status,omitempty"`
+	Description string           `protobuf:"bytes,3,opt,name=description" json:"description,omitempty"`
+}
+
+func (m *QueuedLeaf) Reset()                    { *m = QueuedLeaf{} }
+func (m *QueuedLeaf) String() string            { return proto.CompactTextString(m) }
+func (*QueuedLeaf) ProtoMessage()               {}
+func (*QueuedLeaf) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{1} }
+
+func (m *QueuedLeaf) GetLeaf() *LeafProto {
+	if m != nil {
+		return m.Leaf
+	}
+	return nil
+}
+
+type QueueLeavesV2Response struct {
+	// One for each leaf of the request, in the same order
+	QueuedLeaves []*QueuedLeaf `protobuf:"bytes,1,rep,name=queued_leaves,json=queuedLeaves" json:"queued_leaves,omitempty"`
+}
+
+func (m *QueueLeavesV2Response) Reset()                    { *m = QueueLeavesV2Response{} }
+func (m *QueueLeavesV2Response) String() string            { return proto.CompactTextString(m) }
+func (*QueueLeavesV2Response) ProtoMessage()               {}
+func (*QueueLeavesV2Response) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{2} }
+
+func (m *QueueLeavesV2Response) GetQueuedLeaves() []*QueuedLeaf {
+	if m != nil {
+		return m.QueuedLeaves
+	}
+	return nil
+}
+
+// GetLeavesByRangeRequest asks for up to count leaves starting at start_index. Leaves beyond
+// the latest signed root are not returned.
+type GetLeavesByRangeRequest struct {
+	LogId      int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	StartIndex int64 `protobuf:"varint,2,opt,name=start_index,json=startIndex" json:"start_index,omitempty"`
+	Count      int64 `protobuf:"varint,3,opt,name=count" json:"count,omitempty"`
+	// If set the leaves are returned without their extra_data
+	OmitExtraData bool `protobuf:"varint,4,opt,name=omit_extra_data,json=omitExtraData" json:"omit_extra_data,omitempty"`
+}
+
+func (m *GetLeavesByRangeRequest) Reset()                    { *m = GetLeavesByRangeRequest{} }
+func (m *GetLeavesByRangeRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetLeavesByRangeRequest) ProtoMessage()               {}
+func (*GetLeavesByRangeRequest) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{3} }
+
+type GetLeavesByRangeResponse struct {
+	// The leaves in index order. There are fewer than asked for if the range goes past the
+	// tree size of signed_log_root, none if it starts there.
+	Leaves []*LeafProto `protobuf:"bytes,1,rep,name=leaves" json:"leaves,omitempty"`
+	// The root that the range was limited to
+	SignedLogRoot *SignedLogRoot `protobuf:"bytes,2,opt,name=signed_log_root,json=signedLogRoot" json:"signed_log_root,omitempty"`
+}
+
+func (m *GetLeavesByRangeResponse) Reset()                    { *m = GetLeavesByRangeResponse{} }
+func (m *GetLeavesByRangeResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetLeavesByRangeResponse) ProtoMessage()               {}
+func (*GetLeavesByRangeResponse) Descriptor() ([]byte, []int) { return fileDescriptor1, []int{4} }
+
+func (m *GetLeavesByRangeResponse) GetLeaves() []*LeafProto {
+	if m != nil {
+		return m.Leaves
+	}
+	return nil
+}
+
+func (m *GetLeavesByRangeResponse) GetSignedLogRoot() *SignedLogRoot {
+	if m != nil {
+		return m.SignedLogRoot
+	}
+	return nil
+}
+
+// GetLatestSignedLogRootV2Request asks for the latest root and, if first_tree_size is set,
+// proof that it's consistent with the root of that size the client already has.
+type GetLatestSignedLogRootV2Request struct {
+	LogId         int64 `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	FirstTreeSize int64 `protobuf:"varint,2,opt,name=first_tree_size,json=firstTreeSize" json:"first_tree_size,omitempty"`
+}
+
+func (m *GetLatestSignedLogRootV2Request) Reset()         { *m = GetLatestSignedLogRootV2Request{} }
+func (m *GetLatestSignedLogRootV2Request) String() string { return proto.CompactTextString(m) }
+func (*GetLatestSignedLogRootV2Request) ProtoMessage()    {}
+func (*GetLatestSignedLogRootV2Request) Descriptor() ([]byte, []int) {
+	return fileDescriptor1, []int{5}
+}
+
+type GetLatestSignedLogRootV2Response struct {
+	SignedLogRoot *SignedLogRoot `protobuf:"bytes,1,opt,name=signed_log_root,json=signedLogRoot" json:"signed_log_root,omitempty"`
+	// The consistency proof from first_tree_size to the tree size of signed_log_root. Not set
+	// if first_tree_size was zero or the tree hasn't grown since.
+	Proof *ProofProto `protobuf:"bytes,2,opt,name=proof" json:"proof,omitempty"`
+}
+
+func (m *GetLatestSignedLogRootV2Response) Reset()         { *m = GetLatestSignedLogRootV2Response{} }
+func (m *GetLatestSignedLogRootV2Response) String() string { return proto.CompactTextString(m) }
+func (*GetLatestSignedLogRootV2Response) ProtoMessage()    {}
+func (*GetLatestSignedLogRootV2Response) Descriptor() ([]byte, []int) {
+	return fileDescriptor1, []int{6}
+}
+
+func (m *GetLatestSignedLogRootV2Response) GetSignedLogRoot() *SignedLogRoot {
+	if m != nil {
+		return m.SignedLogRoot
+	}
+	return nil
+}
+
+func (m *GetLatestSignedLogRootV2Response) GetProof() *ProofProto {
+	if m != nil {
+		return m.Proof
+	}
+	return nil
+}
+
+// GetInclusionProofByHashV2Request asks for proof that a leaf is included in the tree of the
+// latest root.
+type GetInclusionProofByHashV2Request struct {
+	LogId    int64  `protobuf:"varint,1,opt,name=log_id,json=logId" json:"log_id,omitempty"`
+	LeafHash []byte `protobuf:"bytes,2,opt,name=leaf_hash,json=leafHash,proto3" json:"leaf_hash,omitempty"`
+}
+
+func (m *GetInclusionProofByHashV2Request) Reset()         { *m = GetInclusionProofByHashV2Request{} }
+func (m *GetInclusionProofByHashV2Request) String() string { return proto.CompactTextString(m) }
+func (*GetInclusionProofByHashV2Request) ProtoMessage()    {}
+func (*GetInclusionProofByHashV2Request) Descriptor() ([]byte, []int) {
+	return fileDescriptor1, []int{7}
+}
+
+type GetInclusionProofByHashV2Response struct {
+	// The root the proofs are for
+	SignedLogRoot *SignedLogRoot `protobuf:"bytes,1,opt,name=signed_log_root,json=signedLogRoot" json:"signed_log_root,omitempty"`
+	// Logs can contain leaves with duplicate hashes so there can be several proofs
+	Proof []*ProofProto `protobuf:"bytes,2,rep,name=proof" json:"proof,omitempty"`
+}
+
+func (m *GetInclusionProofByHashV2Response) Reset()         { *m = GetInclusionProofByHashV2Response{} }
+func (m *GetInclusionProofByHashV2Response) String() string { return proto.CompactTextString(m) }
+func (*GetInclusionProofByHashV2Response) ProtoMessage()    {}
+func (*GetInclusionProofByHashV2Response) Descriptor() ([]byte, []int) {
+	return fileDescriptor1, []int{8}
+}
+
+func (m *GetInclusionProofByHashV2Response) GetSignedLogRoot() *SignedLogRoot {
+	if m != nil {
+		return m.SignedLogRoot
+	}
+	return nil
+}
+
+func (m *GetInclusionProofByHashV2Response) GetProof() []*ProofProto {
+	if m != nil {
+		return m.Proof
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*QueueLeavesV2Request)(nil), "trillian.QueueLeavesV2Request")
+	proto.RegisterType((*QueuedLeaf)(nil), "trillian.QueuedLeaf")
+	proto.RegisterType((*QueueLeavesV2Response)(nil), "trillian.QueueLeavesV2Response")
+	proto.RegisterType((*GetLeavesByRangeRequest)(nil), "trillian.GetLeavesByRangeRequest")
+	proto.RegisterType((*GetLeavesByRangeResponse)(nil), "trillian.GetLeavesByRangeResponse")
+	proto.RegisterType((*GetLatestSignedLogRootV2Request)(nil), "trillian.GetLatestSignedLogRootV2Request")
+	proto.RegisterType((*GetLatestSignedLogRootV2Response)(nil), "trillian.GetLatestSignedLogRootV2Response")
+	proto.RegisterType((*GetInclusionProofByHashV2Request)(nil), "trillian.GetInclusionProofByHashV2Request")
+	proto.RegisterType((*GetInclusionProofByHashV2Response)(nil), "trillian.GetInclusionProofByHashV2Response")
+	proto.RegisterEnum("trillian.QueuedLeafStatus", QueuedLeafStatus_name, QueuedLeafStatus_value)
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion3
+
+// Client API for TrillianLogV2 service
+
+type TrillianLogV2Client interface {
+	// Queues leaves and says what happened to each of them
+	QueueLeaves(ctx context.Context, in *QueueLeavesV2Request, opts ...grpc.CallOption) (*QueueLeavesV2Response, error)
+	// Returns a contiguous range of leaves
+	GetLeavesByRange(ctx context.Context, in *GetLeavesByRangeRequest, opts ...grpc.CallOption) (*GetLeavesByRangeResponse, error)
+	// Returns the latest root along with a consistency proof to it
+	GetLatestSignedLogRoot(ctx context.Context, in *GetLatestSignedLogRootV2Request, opts ...grpc.CallOption) (*GetLatestSignedLogRootV2Response, error)
+	// Returns the latest root along with inclusion proofs in it
+	GetInclusionProofByHash(ctx context.Context, in *GetInclusionProofByHashV2Request, opts ...grpc.CallOption) (*GetInclusionProofByHashV2Response, error)
+}
+
+type trillianLogV2Client struct {
+	cc *grpc.ClientConn
+}
+
+func NewTrillianLogV2Client(cc *grpc.ClientConn) TrillianLogV2Client {
+	return &trillianLogV2Client{cc}
+}
+
+func (c *trillianLogV2Client) QueueLeaves(ctx context.Context, in *QueueLeavesV2Request, opts ...grpc.CallOption) (*QueueLeavesV2Response, error) {
+	out := new(QueueLeavesV2Response)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLogV2/QueueLeaves", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogV2Client) GetLeavesByRange(ctx context.Context, in *GetLeavesByRangeRequest, opts ...grpc.CallOption) (*GetLeavesByRangeResponse, error) {
+	out := new(GetLeavesByRangeResponse)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLogV2/GetLeavesByRange", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogV2Client) GetLatestSignedLogRoot(ctx context.Context, in *GetLatestSignedLogRootV2Request, opts ...grpc.CallOption) (*GetLatestSignedLogRootV2Response, error) {
+	out := new(GetLatestSignedLogRootV2Response)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLogV2/GetLatestSignedLogRoot", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *trillianLogV2Client) GetInclusionProofByHash(ctx context.Context, in *GetInclusionProofByHashV2Request, opts ...grpc.CallOption) (*GetInclusionProofByHashV2Response, error) {
+	out := new(GetInclusionProofByHashV2Response)
+	err := grpc.Invoke(ctx, "/trillian.TrillianLogV2/GetInclusionProofByHash", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for TrillianLogV2 service
+
+type TrillianLogV2Server interface {
+	// Queues leaves and says what happened to each of them
+	QueueLeaves(context.Context, *QueueLeavesV2Request) (*QueueLeavesV2Response, error)
+	// Returns a contiguous range of leaves
+	GetLeavesByRange(context.Context, *GetLeavesByRangeRequest) (*GetLeavesByRangeResponse, error)
+	// Returns the latest root along with a consistency proof to it
+	GetLatestSignedLogRoot(context.Context, *GetLatestSignedLogRootV2Request) (*GetLatestSignedLogRootV2Response, error)
+	// Returns the latest root along with inclusion proofs in it
+	GetInclusionProofByHash(context.Context, *GetInclusionProofByHashV2Request) (*GetInclusionProofByHashV2Response, error)
+}
+
+func RegisterTrillianLogV2Server(s *grpc.Server, srv TrillianLogV2Server) {
+	s.RegisterService(&_TrillianLogV2_serviceDesc, srv)
+}
+
+func _TrillianLogV2_QueueLeaves_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueueLeavesV2Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogV2Server).QueueLeaves(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLogV2/QueueLeaves",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogV2Server).QueueLeaves(ctx, req.(*QueueLeavesV2Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLogV2_GetLeavesByRange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLeavesByRangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogV2Server).GetLeavesByRange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLogV2/GetLeavesByRange",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogV2Server).GetLeavesByRange(ctx, req.(*GetLeavesByRangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLogV2_GetLatestSignedLogRoot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLatestSignedLogRootV2Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogV2Server).GetLatestSignedLogRoot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLogV2/GetLatestSignedLogRoot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogV2Server).GetLatestSignedLogRoot(ctx, req.(*GetLatestSignedLogRootV2Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TrillianLogV2_GetInclusionProofByHash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetInclusionProofByHashV2Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TrillianLogV2Server).GetInclusionProofByHash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/trillian.TrillianLogV2/GetInclusionProofByHash",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TrillianLogV2Server).GetInclusionProofByHash(ctx, req.(*GetInclusionProofByHashV2Request))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _TrillianLogV2_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "trillian.TrillianLogV2",
+	HandlerType: (*TrillianLogV2Server)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "QueueLeaves",
+			Handler:    _TrillianLogV2_QueueLeaves_Handler,
+		},
+		{
+			MethodName: "GetLeavesByRange",
+			Handler:    _TrillianLogV2_GetLeavesByRange_Handler,
+		},
+		{
+			MethodName: "GetLatestSignedLogRoot",
+			Handler:    _TrillianLogV2_GetLatestSignedLogRoot_Handler,
+		},
+		{
+			MethodName: "GetInclusionProofByHash",
+			Handler:    _TrillianLogV2_GetInclusionProofByHash_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: fileDescriptor1,
+}
+
+func init() { proto.RegisterFile("github.com/google/trillian/trillian_log_v2.proto", fileDescriptor1) }
+
+var fileDescriptor1 = []byte{
+	// 693 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb5, 0x55, 0x5b, 0x53, 0xd3, 0x40,
+	0x14, 0x26, 0x04, 0x18, 0x38, 0xa5, 0x52, 0x57, 0x90, 0x1a, 0x67, 0xa0, 0xe4, 0x01, 0xb9, 0x8c,
+	0x45, 0xe3, 0x93, 0x4f, 0x4e, 0x2b, 0x11, 0xab, 0x9d, 0x5a, 0xb6, 0x17, 0x47, 0x7d, 0x88, 0xa1,
+	0xdd, 0x86, 0xcc, 0x94, 0x6c, 0x9b, 0x6c, 0x18, 0xf0, 0x17, 0x38, 0xe3, 0x83, 0xfe, 0x0b, 0xff,
+	0xa6, 0xbb, 0x9b, 0xd0, 0x9b, 0xbd, 0xf9, 0xe0, 0x5b, 0xf6, 0x3b, 0xdf, 0xb9, 0x7c, 0x67, 0xcf,
+	0xc9, 0xc2, 0x33, 0xc7, 0x65, 0x97, 0xe1, 0x45, 0xb6, 0x41, 0xaf, 0x4e, 0x1c, 0x4a, 0x9d, 0x36,
+	0x39, 0x61, 0xbe, 0xdb, 0x6e, 0xbb, 0xb6, 0xd7, 0xfb, 0xb0, 0xda, 0xd4, 0xb1, 0xae, 0x8d, 0x6c,
+	0xc7, 0xa7, 0x8c, 0xa2, 0xd5, 0x3b, 0x58, 0x3b, 0x9c, 0xc3, 0x37, 0x72, 0xd2, 0x9e, 0xce, 0x93,
+	0xc6, 0xee, 0xb8, 0x11, 0x5d, 0xff, 0x0c, 0x9b, 0xe7, 0x21, 0x09, 0x49, 0x91, 0xd8, 0xd7, 0x24,
+	0xa8, 0x1b, 0x98, 0x74, 0x43, 0x12, 0x30, 0xb4, 0x05, 0x2b, 0xa2, 0x16, 0xb7, 0x99, 0x56, 0x32,
+	0xca, 0x81, 0x8a, 0x97, 0xf9, 0xa9, 0xd0, 0x44, 0xc7, 0x1c, 0x96, 0xcc, 0xf4, 0x62, 0x46, 0x3d,
+	0x48, 0x18, 0x0f, 0xb2, 0xbd, 0xf4, 0x3c, 0x42, 0xab, 0x2c, 0x62, 0xe2, 0x98, 0xa2, 0xff, 0x50,
+	0x00, 0x64, 0xf0, 0xa6, 0xb0, 0xa1, 0x27, 0xb0, 0xc4, 0x0d, 0x2d, 0x19, 0x70, 0x82, 0xa7, 0x24,
+	0x20, 0x03, 0x56, 0x02, 0x66, 0xb3, 0x50, 0x24, 0x51, 0x0e, 0xee, 0x19, 0x5a, 0x9f, 0xda, 0x0f,
+	0x57, 0x91, 0x0c, 0x1c, 0x33, 0x51, 0x06, 0x12, 0x4d, 0x12, 0x34, 0x7c, 0xb7, 0xc3, 0x5c, 0xea,
+	0xa5, 0x55, 0xee, 0xb8, 0x86, 0x07, 0x21, 0x1d, 0xc3, 0xd6, 0x88, 0xd2, 0xa0, 0x43, 0xbd, 0x80,
+	0xa0, 0x97, 0x90, 0xec, 0xca, 0xb0, 0x56, 0x2c, 0x4d, 0x91, 0xd2, 0x36, 0xc7, 0x65, 0xc5, 0xeb,
+	0xdd, 0xbb, 0x6f, 0xa1, 0xf0, 0xa7, 0x02, 0xdb, 0x67, 0x84, 0x45, 0xa7, 0xfc, 0x2d, 0xb6, 0x3d,
+	0x87, 0xcc, 0xe8, 0xe0, 0x2e, 0x24, 0x78, 0xc9, 0x3e, 0xb3, 0x5c, 0xaf, 0x49, 0x6e, 0xa4, 0x42,
+	0x15, 0x83, 0x84, 0x0a, 0x02, 0x41, 0x9b, 0xb0, 0xdc, 0xa0, 0xa1, 0xc7, 0xa4, 0x06, 0xee, 0x26,
+	0x0f, 0x68, 0x1f, 0x36, 0xe8, 0x95, 0xcb, 0x2c, 0x72, 0xc3, 0x7c, 0xdb, 0x6a, 0xda, 0xcc, 0x4e,
+	0x2f, 0x71, 0xfb, 0x2a, 0x4e, 0x0a, 0xd8, 0x14, 0xe8, 0x29, 0x07, 0xf5, 0xef, 0x0a, 0xa4, 0xff,
+	0xae, 0x28, 0x56, 0xda, 0xbf, 0x3d, 0x65, 0xe6, 0xed, 0xa1, 0x57, 0xb0, 0x11, 0xb8, 0x8e, 0x27,
+	0xda, 0xc2, 0x65, 0xf8, 0x94, 0x32, 0x59, 0x6c, 0xc2, 0xd8, 0xee, 0x7b, 0x55, 0x24, 0xa1, 0x48,
+	0x1d, 0xcc, 0xcd, 0x38, 0x19, 0x0c, 0x1e, 0xf5, 0xaf, 0xb0, 0x2b, 0x2a, 0xb1, 0x19, 0xef, 0xc6,
+	0x10, 0x71, 0xe6, 0x94, 0x71, 0xb1, 0x2d, 0xd7, 0x0f, 0x98, 0xc5, 0x7c, 0x42, 0xac, 0xc0, 0xfd,
+	0x46, 0xe2, 0x3e, 0x25, 0x25, 0x5c, 0xe5, 0x68, 0x85, 0x83, 0xa2, 0xfd, 0x99, 0xc9, 0x29, 0x62,
+	0xd1, 0x63, 0x74, 0x28, 0xff, 0xa2, 0x03, 0x1d, 0xc1, 0x32, 0xdf, 0x15, 0xda, 0x8a, 0xe5, 0x0f,
+	0xcc, 0x45, 0x59, 0xc0, 0x51, 0xd7, 0x22, 0x8a, 0x5e, 0x97, 0x05, 0x15, 0xbc, 0x46, 0x3b, 0x0c,
+	0xf8, 0xd0, 0x49, 0x42, 0xfe, 0xf6, 0xad, 0x1d, 0x5c, 0xce, 0x14, 0xfd, 0x18, 0xd6, 0xc4, 0xf4,
+	0x5b, 0x97, 0x9c, 0x2c, 0x53, 0xad, 0xe3, 0x55, 0x01, 0x08, 0x67, 0xfd, 0x97, 0x02, 0x7b, 0x53,
+	0x02, 0xff, 0x07, 0xa9, 0xea, 0x0c, 0xa9, 0x47, 0x01, 0xa4, 0x46, 0xb7, 0x11, 0xed, 0x80, 0x56,
+	0x2b, 0xbd, 0x2f, 0x7d, 0xf8, 0x58, 0xb2, 0xce, 0x6b, 0x66, 0xcd, 0x3c, 0xb5, 0x8a, 0x66, 0xee,
+	0x8d, 0x55, 0xa9, 0xe6, 0xaa, 0xb5, 0x4a, 0x6a, 0x01, 0x6d, 0x40, 0x42, 0x02, 0x91, 0x31, 0xa5,
+	0x70, 0xd1, 0xdb, 0x12, 0xc8, 0x15, 0xb1, 0x99, 0x3b, 0xfd, 0x64, 0x15, 0x4a, 0x55, 0xf3, 0x0c,
+	0xe7, 0xaa, 0xdc, 0xb8, 0x88, 0xee, 0x43, 0x52, 0x1a, 0xb1, 0xf9, 0xce, 0x7c, 0x2d, 0x20, 0xd5,
+	0xf8, 0xad, 0x42, 0xb2, 0x1a, 0xd7, 0xc4, 0x8b, 0xae, 0x1b, 0xa8, 0x0c, 0x89, 0x81, 0xb5, 0x46,
+	0x3b, 0x23, 0x5b, 0x3b, 0xf2, 0x5f, 0xd3, 0x76, 0x27, 0xda, 0xa3, 0x1e, 0xea, 0x0b, 0xe8, 0x0b,
+	0xa4, 0x46, 0x37, 0x08, 0xed, 0xf5, 0xdd, 0x26, 0xec, 0xbb, 0xa6, 0x4f, 0xa3, 0xf4, 0x82, 0x77,
+	0xe1, 0xe1, 0xf8, 0x89, 0x45, 0x87, 0xc3, 0xfe, 0x53, 0xd6, 0x46, 0x3b, 0x9a, 0x87, 0xda, 0x4b,
+	0xc9, 0xe4, 0x3f, 0x6a, 0xdc, 0xe8, 0xa0, 0xe1, 0x40, 0x53, 0xc7, 0x56, 0x3b, 0x9e, 0x8b, 0x7b,
+	0x97, 0x35, 0xff, 0x1c, 0x1e, 0xf1, 0x27, 0x28, 0x1b, 0x3d, 0x41, 0xd9, 0xe1, 0x47, 0x2a, 0x8f,
+	0x86, 0xee, 0x50, 0x8e, 0x55, 0x59, 0xb9, 0x58, 0x91, 0xc6, 0x17, 0x7f, 0x00, 0x6c, 0x40, 0xbf,
+	0xc6, 0x2a, 0x07, 0x00, 0x00,
+}
@@ -58,7 +58,21 @@ type LogLeaf struct {
 	SignedEntryTimestamp SignedEntryTimestamp
 	// Sequencenumber holds the position in the log this leaf has been assigned to.
 	SequenceNumber int64
+	// IntegrateTimestampNanos is when the leaf was integrated into the tree. It's zero until
+	// the leaf has been sequenced.
+	IntegrateTimestampNanos int64
+	// Priority orders leaves waiting to be sequenced, higher priorities are sequenced first.
+	// It's only meaningful while the leaf is queued.
+	Priority int32
 }
 
+// Queue priorities for leaves. Live submissions use LeafPriorityNormal. Bulk traffic such as
+// mirroring or backfilling a log should use LeafPriorityLow so it doesn't hold up the
+// merge delay of live submissions.
+const (
+	LeafPriorityLow    int32 = -1
+	LeafPriorityNormal int32 = 0
+)
+
 // Key is a map key.
 type Key []byte
@@ -0,0 +1,109 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// ConfigCheck is the outcome of one check made by a server's --validate_config mode.
+type ConfigCheck struct {
+	Name string `json:"name"`
+	OK   bool   `json:"ok"`
+	// Skipped is set if the check wasn't made because a check it needs failed
+	Skipped bool `json:"skipped,omitempty"`
+	// Error says why the check failed or was skipped
+	Error string `json:"error,omitempty"`
+}
+
+// ConfigReport collects the checks a server makes of its configuration and dependencies
+// before it's deployed, so that a bad flag, an unreachable database or a mismatched key is
+// found without starting it. It's written as JSON for deployment tooling to read.
+type ConfigReport struct {
+	Server string `json:"server"`
+	// OK is set if every check passed
+	OK     bool          `json:"ok"`
+	Checks []ConfigCheck `json:"checks"`
+}
+
+// NewConfigReport creates an empty report for a server, which is OK until a check fails.
+func NewConfigReport(server string) *ConfigReport {
+	return &ConfigReport{Server: server, OK: true, Checks: []ConfigCheck{}}
+}
+
+// Check runs check and records its outcome under name. It returns whether it passed, so
+// that checks that need it can be skipped if it didn't.
+func (r *ConfigReport) Check(name string, check func() error) bool {
+	if err := check(); err != nil {
+		r.OK = false
+		r.Checks = append(r.Checks, ConfigCheck{Name: name, Error: err.Error()})
+		return false
+	}
+
+	r.Checks = append(r.Checks, ConfigCheck{Name: name, OK: true})
+	return true
+}
+
+// Skip records that the check name wasn't made because of reason. A skipped check doesn't
+// fail the report, whatever it depends on already has.
+func (r *ConfigReport) Skip(name, reason string) {
+	r.Checks = append(r.Checks, ConfigCheck{Name: name, Skipped: true, Error: reason})
+}
+
+// Write writes the report to w as indented JSON.
+func (r *ConfigReport) Write(w io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// ExitCode returns the status a validating server should exit with, 0 if every check passed
+// and 1 otherwise.
+func (r *ConfigReport) ExitCode() int {
+	if r.OK {
+		return 0
+	}
+
+	return 1
+}
+
+// CheckWritableDir checks that files can be created in dir. Servers create their
+// directories when they start, so if dir doesn't exist the nearest directory above it that
+// does is checked instead. Nothing is left behind.
+func CheckWritableDir(dir string) error {
+	for {
+		info, err := os.Stat(dir)
+
+		if os.IsNotExist(err) && filepath.Dir(dir) != dir {
+			dir = filepath.Dir(dir)
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+
+		break
+	}
+
+	f, err := ioutil.TempFile(dir, ".validate")
+
+	if err != nil {
+		return fmt.Errorf("can't create files in %s: %v", dir, err)
+	}
+
+	f.Close()
+	return os.Remove(f.Name())
+}
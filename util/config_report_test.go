@@ -0,0 +1,84 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConfigReport(t *testing.T) {
+	r := NewConfigReport("test_server")
+
+	if !r.Check("good", func() error { return nil }) || r.ExitCode() != 0 {
+		t.Fatalf("Passing check failed the report: %+v", r)
+	}
+
+	if r.Check("bad", func() error { return errors.New("bang") }) || r.ExitCode() != 1 {
+		t.Fatalf("Failing check didn't fail the report: %+v", r)
+	}
+
+	r.Skip("dependent", "bad failed")
+
+	var buf bytes.Buffer
+
+	if err := r.Write(&buf); err != nil {
+		t.Fatalf("Write()=%v", err)
+	}
+
+	var got ConfigReport
+
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to read written report: %v", err)
+	}
+
+	want := ConfigReport{Server: "test_server", Checks: []ConfigCheck{
+		{Name: "good", OK: true},
+		{Name: "bad", Error: "bang"},
+		{Name: "dependent", Skipped: true, Error: "bad failed"},
+	}}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Wrote report %+v, expected %+v", got, want)
+	}
+}
+
+func TestCheckWritableDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkwritable")
+
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "file")
+
+	if err := ioutil.WriteFile(file, nil, 0600); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	for _, test := range []struct {
+		dir     string
+		wantErr bool
+	}{
+		{dir: dir},
+		// Created at startup
+		{dir: filepath.Join(dir, "a", "b")},
+		{dir: file, wantErr: true},
+		{dir: filepath.Join(file, "a"), wantErr: true},
+	} {
+		if err := CheckWritableDir(test.dir); (err != nil) != test.wantErr {
+			t.Errorf("CheckWritableDir(%s)=%v, expected error: %v", test.dir, err, test.wantErr)
+		}
+	}
+
+	// Nothing is left behind
+	if files, err := ioutil.ReadDir(dir); err != nil || len(files) != 1 {
+		t.Errorf("Directory has %d files after checks, expected 1: %v", len(files), err)
+	}
+}
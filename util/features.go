@@ -0,0 +1,267 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// FeatureConfig is the state of a feature flag, as configured or reported.
+type FeatureConfig struct {
+	// Name identifies the feature, e.g. "fast_sct"
+	Name string `json:"name"`
+	// Enabled is whether the feature is on for logs that aren't listed in Logs
+	Enabled bool `json:"enabled"`
+	// Logs overrides Enabled for individual logs by ID
+	Logs map[int64]bool `json:"logs,omitempty"`
+}
+
+// LoadFeatureConfigs reads and parses a JSON array of FeatureConfig from a file.
+func LoadFeatureConfigs(path string) ([]FeatureConfig, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseFeatureConfigs(data)
+}
+
+// ParseFeatureConfigs parses a JSON array of FeatureConfig. The names are checked when the
+// configs are applied.
+func ParseFeatureConfigs(data []byte) ([]FeatureConfig, error) {
+	var configs []FeatureConfig
+
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse features: %v", err)
+	}
+
+	return configs, nil
+}
+
+// Features is a registry of feature flags that guard risky behaviour, so that it can be
+// turned on for some logs and off again without a restart if it causes problems. Only the
+// features named when the registry is created can be configured. Features are off unless
+// they've been enabled. It is safe for concurrent use.
+type Features struct {
+	// mu guards features
+	mu       sync.RWMutex
+	features map[string]*FeatureConfig
+}
+
+// NewFeatures creates a registry of the named features, all of them off.
+func NewFeatures(names ...string) *Features {
+	f := &Features{features: make(map[string]*FeatureConfig)}
+
+	for _, name := range names {
+		f.features[name] = &FeatureConfig{Name: name}
+	}
+
+	return f
+}
+
+// feature returns the state of a feature, mu must be held
+func (f *Features) feature(name string) (*FeatureConfig, error) {
+	feature, ok := f.features[name]
+
+	if !ok {
+		return nil, fmt.Errorf("unknown feature: %q", name)
+	}
+
+	return feature, nil
+}
+
+// Apply replaces the state of every feature with configs, e.g. after the config file has been
+// changed. Features that aren't in configs are turned off. Nothing is changed if any of the
+// configs name an unknown feature or name a feature more than once.
+func (f *Features) Apply(configs []FeatureConfig) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	applied := make(map[string]*FeatureConfig)
+
+	for _, config := range configs {
+		if _, err := f.feature(config.Name); err != nil {
+			return err
+		}
+
+		if _, ok := applied[config.Name]; ok {
+			return fmt.Errorf("feature %q is configured more than once", config.Name)
+		}
+
+		feature := &FeatureConfig{Name: config.Name, Enabled: config.Enabled}
+
+		for logID, enabled := range config.Logs {
+			if logID == 0 {
+				return fmt.Errorf("feature %q has an override for log 0", config.Name)
+			}
+
+			if feature.Logs == nil {
+				feature.Logs = make(map[int64]bool)
+			}
+
+			feature.Logs[logID] = enabled
+		}
+
+		applied[config.Name] = feature
+	}
+
+	for name := range f.features {
+		if feature, ok := applied[name]; ok {
+			f.features[name] = feature
+		} else {
+			f.features[name] = &FeatureConfig{Name: name}
+		}
+	}
+
+	return nil
+}
+
+// Set turns a feature on or off for a log. If logID is zero it's set for every log that
+// doesn't have its own setting.
+func (f *Features) Set(name string, logID int64, enabled bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	feature, err := f.feature(name)
+
+	if err != nil {
+		return err
+	}
+
+	if logID == 0 {
+		feature.Enabled = enabled
+		return nil
+	}
+
+	if feature.Logs == nil {
+		feature.Logs = make(map[int64]bool)
+	}
+
+	feature.Logs[logID] = enabled
+	return nil
+}
+
+// Reset removes a log's own setting for a feature so that it follows the setting for every
+// log. If logID is zero the feature is turned off and all the logs' settings are removed.
+func (f *Features) Reset(name string, logID int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	feature, err := f.feature(name)
+
+	if err != nil {
+		return err
+	}
+
+	if logID == 0 {
+		f.features[name] = &FeatureConfig{Name: name}
+		return nil
+	}
+
+	delete(feature.Logs, logID)
+	return nil
+}
+
+// Enabled returns whether a feature is on for a log. Unknown features are always off.
+func (f *Features) Enabled(name string, logID int64) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	feature, ok := f.features[name]
+
+	if !ok {
+		return false
+	}
+
+	if enabled, ok := feature.Logs[logID]; ok {
+		return enabled
+	}
+
+	return feature.Enabled
+}
+
+// Configs returns the current state of every feature sorted by name, in the form that Apply
+// accepts.
+func (f *Features) Configs() []FeatureConfig {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	configs := make([]FeatureConfig, 0, len(f.features))
+
+	for _, feature := range f.features {
+		config := FeatureConfig{Name: feature.Name, Enabled: feature.Enabled}
+
+		if len(feature.Logs) > 0 {
+			config.Logs = make(map[int64]bool)
+
+			for logID, enabled := range feature.Logs {
+				config.Logs[logID] = enabled
+			}
+		}
+
+		configs = append(configs, config)
+	}
+
+	sort.Sort(featuresByName(configs))
+	return configs
+}
+
+// ReloadOnChange applies the features in a config file whenever its modification time
+// changes, checking every interval, until done is closed. Changes made with Set and Reset are
+// kept until the file is next changed. Failures are logged and the current state is kept.
+func (f *Features) ReloadOnChange(done <-chan struct{}, path string, interval time.Duration) {
+	var modified time.Time
+
+	if info, err := os.Stat(path); err == nil {
+		modified = info.ModTime()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+
+		if err != nil {
+			glog.Warningf("Failed to check features file %s: %v", path, err)
+			continue
+		}
+
+		if info.ModTime().Equal(modified) {
+			continue
+		}
+
+		modified = info.ModTime()
+		configs, err := LoadFeatureConfigs(path)
+
+		if err == nil {
+			err = f.Apply(configs)
+		}
+
+		if err != nil {
+			glog.Warningf("Failed to reload features from %s, keeping the current state: %v", path, err)
+			continue
+		}
+
+		glog.Infof("Reloaded features from %s", path)
+	}
+}
+
+type featuresByName []FeatureConfig
+
+func (f featuresByName) Len() int           { return len(f) }
+func (f featuresByName) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+func (f featuresByName) Less(i, j int) bool { return f[i].Name < f[j].Name }
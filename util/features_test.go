@@ -0,0 +1,112 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFeatures(t *testing.T) {
+	f := NewFeatures("a", "b")
+
+	if f.Enabled("a", 1) || f.Enabled("unknown", 1) {
+		t.Fatal("Feature enabled before it was configured")
+	}
+
+	if err := f.Set("a", 0, true); err != nil {
+		t.Fatalf("Set(a, 0)=%v", err)
+	}
+
+	if err := f.Set("a", 2, false); err != nil {
+		t.Fatalf("Set(a, 2)=%v", err)
+	}
+
+	if err := f.Set("unknown", 0, true); err == nil {
+		t.Error("Set() of an unknown feature succeeded")
+	}
+
+	for _, test := range []struct {
+		name  string
+		logID int64
+		want  bool
+	}{
+		{"a", 1, true},
+		{"a", 2, false},
+		{"b", 1, false},
+		{"unknown", 1, false},
+	} {
+		if got := f.Enabled(test.name, test.logID); got != test.want {
+			t.Errorf("Enabled(%s, %d)=%v, expected %v", test.name, test.logID, got, test.want)
+		}
+	}
+
+	if err := f.Reset("a", 2); err != nil {
+		t.Fatalf("Reset(a, 2)=%v", err)
+	}
+
+	if !f.Enabled("a", 2) {
+		t.Error("Log 2 doesn't follow the setting for all logs after Reset()")
+	}
+
+	if err := f.Set("b", 3, true); err != nil {
+		t.Fatalf("Set(b, 3)=%v", err)
+	}
+
+	want := []FeatureConfig{{Name: "a", Enabled: true}, {Name: "b", Logs: map[int64]bool{3: true}}}
+
+	if got := f.Configs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Configs()=%+v, expected %+v", got, want)
+	}
+
+	if err := f.Reset("b", 0); err != nil {
+		t.Fatalf("Reset(b, 0)=%v", err)
+	}
+
+	if f.Enabled("b", 3) {
+		t.Error("Feature still enabled for log 3 after resetting it for all logs")
+	}
+}
+
+func TestFeaturesApply(t *testing.T) {
+	f := NewFeatures("a", "b")
+	f.Set("b", 0, true)
+
+	configs, err := ParseFeatureConfigs([]byte(`[{"name": "a", "enabled": false, "logs": {"7": true}}]`))
+
+	if err != nil {
+		t.Fatalf("ParseFeatureConfigs()=%v", err)
+	}
+
+	if err := f.Apply(configs); err != nil {
+		t.Fatalf("Apply()=%v", err)
+	}
+
+	// Features that aren't configured are turned off
+	if !f.Enabled("a", 7) || f.Enabled("a", 8) || f.Enabled("b", 7) {
+		t.Errorf("Got %+v after Apply(), expected only a for log 7", f.Configs())
+	}
+
+	for _, bad := range []string{
+		`[{"name": "c", "enabled": true}]`,
+		`[{"name": "a"}, {"name": "a"}]`,
+		`[{"name": "a", "logs": {"0": true}}]`,
+	} {
+		configs, err := ParseFeatureConfigs([]byte(bad))
+
+		if err != nil {
+			t.Fatalf("ParseFeatureConfigs(%s)=%v", bad, err)
+		}
+
+		if err := f.Apply(configs); err == nil {
+			t.Errorf("Apply(%s) succeeded", bad)
+		}
+
+		// A bad config mustn't change anything
+		if !f.Enabled("a", 7) {
+			t.Errorf("Apply(%s) changed the features", bad)
+		}
+	}
+
+	if _, err := ParseFeatureConfigs([]byte(`not json`)); err == nil {
+		t.Error("ParseFeatureConfigs() of bad JSON succeeded")
+	}
+}
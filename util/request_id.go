@@ -0,0 +1,63 @@
+package util
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDMetadataKey is the gRPC metadata key used to pass the ID of the request that
+// caused an RPC to the server, so that log messages on both sides can be matched up.
+const RequestIDMetadataKey = "x-request-id"
+
+// NewRequestID returns a new random request ID.
+func NewRequestID() string {
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		// The ID is only used for tracing so this is not worth failing a request over
+		return "unknown"
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// WithRequestID returns a context that sends the request ID to the server in the metadata of
+// any RPC that is made with it. It returns ctx unchanged if the ID is empty.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	if len(requestID) == 0 {
+		return ctx
+	}
+
+	return withOutgoingMetadata(ctx, RequestIDMetadataKey, requestID)
+}
+
+// withOutgoingMetadata adds a key and value to the metadata that will be sent with RPCs made
+// using the returned context, keeping any that was already added.
+func withOutgoingMetadata(ctx context.Context, key, value string) context.Context {
+	md := metadata.Pairs(key, value)
+
+	if existing, ok := metadata.FromOutgoingContext(ctx); ok {
+		md = metadata.Join(existing, md)
+	}
+
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// RequestIDFromContext returns the request ID the client sent with an incoming RPC, or an
+// empty string if there isn't one.
+func RequestIDFromContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+
+	if !ok {
+		return ""
+	}
+
+	if ids := md[RequestIDMetadataKey]; len(ids) > 0 {
+		return ids[0]
+	}
+
+	return ""
+}
@@ -0,0 +1,46 @@
+package util
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestNewRequestID(t *testing.T) {
+	id1, id2 := NewRequestID(), NewRequestID()
+
+	if got, want := len(id1), 32; got != want {
+		t.Fatalf("Got request ID length %d, expected %d", got, want)
+	}
+
+	if id1 == id2 {
+		t.Fatalf("Got the same request ID twice: %s", id1)
+	}
+}
+
+func TestRequestIDRoundTrip(t *testing.T) {
+	ctx := WithRequestID(context.Background(), "abcd")
+
+	// Simulate the metadata arriving at the server
+	md, ok := metadata.FromOutgoingContext(ctx)
+
+	if !ok {
+		t.Fatal("Request ID was not added to outgoing metadata")
+	}
+
+	if got, want := RequestIDFromContext(metadata.NewIncomingContext(context.Background(), md)), "abcd"; got != want {
+		t.Fatalf("Got request ID %s, expected %s", got, want)
+	}
+}
+
+func TestRequestIDMissing(t *testing.T) {
+	if got := RequestIDFromContext(context.Background()); got != "" {
+		t.Fatalf("Got request ID %s from context without one", got)
+	}
+
+	// An empty ID shouldn't be sent
+	if _, ok := metadata.FromOutgoingContext(WithRequestID(context.Background(), "")); ok {
+		t.Fatal("Empty request ID was added to metadata")
+	}
+}
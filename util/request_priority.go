@@ -0,0 +1,63 @@
+package util
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestPriorityMetadataKey is the gRPC metadata key used to tell the server how important an
+// RPC is, so that it can choose what to reject when it is overloaded.
+const RequestPriorityMetadataKey = "x-request-priority"
+
+// RequestPriority classifies RPCs by how much it matters if they are rejected. Higher values
+// are more important.
+type RequestPriority int
+
+const (
+	// PriorityBulk is for bulk reads, e.g. get-entries, that clients can retry later
+	PriorityBulk RequestPriority = iota
+	// PriorityProof is for serving proofs and tree heads to clients verifying the log
+	PriorityProof
+	// PrioritySCT is for submissions, where the log has promised to issue SCTs promptly
+	PrioritySCT
+)
+
+var requestPriorityNames = map[RequestPriority]string{
+	PriorityBulk:  "bulk",
+	PriorityProof: "proof",
+	PrioritySCT:   "sct",
+}
+
+func (p RequestPriority) String() string {
+	if name, ok := requestPriorityNames[p]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+// WithRequestPriority returns a context that sends the priority to the server in the metadata
+// of any RPC that is made with it.
+func WithRequestPriority(ctx context.Context, priority RequestPriority) context.Context {
+	return withOutgoingMetadata(ctx, RequestPriorityMetadataKey, priority.String())
+}
+
+// RequestPriorityFromContext returns the priority the client sent with an incoming RPC. The
+// second result is false if there wasn't one or it wasn't recognized.
+func RequestPriorityFromContext(ctx context.Context) (RequestPriority, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+
+	if !ok {
+		return PriorityBulk, false
+	}
+
+	if names := md[RequestPriorityMetadataKey]; len(names) > 0 {
+		for priority, name := range requestPriorityNames {
+			if name == names[0] {
+				return priority, true
+			}
+		}
+	}
+
+	return PriorityBulk, false
+}
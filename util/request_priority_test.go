@@ -0,0 +1,45 @@
+package util
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRequestPriorityRoundTrip(t *testing.T) {
+	for _, priority := range []RequestPriority{PriorityBulk, PriorityProof, PrioritySCT} {
+		// The request ID must survive adding the priority
+		ctx := WithRequestPriority(WithRequestID(context.Background(), "abcd"), priority)
+
+		// Simulate the metadata arriving at the server
+		md, ok := metadata.FromOutgoingContext(ctx)
+
+		if !ok {
+			t.Fatal("Priority was not added to outgoing metadata")
+		}
+
+		ctx = metadata.NewIncomingContext(context.Background(), md)
+		got, ok := RequestPriorityFromContext(ctx)
+
+		if !ok || got != priority {
+			t.Fatalf("Got priority %v (%v), expected %v", got, ok, priority)
+		}
+
+		if got, want := RequestIDFromContext(ctx), "abcd"; got != want {
+			t.Fatalf("Got request ID %s, expected %s", got, want)
+		}
+	}
+}
+
+func TestRequestPriorityMissing(t *testing.T) {
+	if _, ok := RequestPriorityFromContext(context.Background()); ok {
+		t.Fatal("Got priority from context without one")
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestPriorityMetadataKey, "urgent"))
+
+	if _, ok := RequestPriorityFromContext(ctx); ok {
+		t.Fatal("Got priority from context with an unknown one")
+	}
+}
@@ -0,0 +1,164 @@
+package util
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"sync"
+
+	"github.com/golang/snappy"
+	"google.golang.org/grpc"
+)
+
+// The RPC compression settings accepted by RPCCompression. gzip and gzip-fast are the same
+// encoding on the wire, gzip-fast trades size for less CPU when compressing, so a client and
+// server using either can talk to each other.
+const (
+	RPCCompressionNone     = "none"
+	RPCCompressionGzip     = "gzip"
+	RPCCompressionGzipFast = "gzip-fast"
+	RPCCompressionSnappy   = "snappy"
+)
+
+// gRPC can only decompress the encoding that the client or server has a decompressor for, and
+// a server compresses every response it sends with its compressor. So the clients and the
+// server they talk to must use settings with the same encoding.
+var rpcCompressionEncodings = map[string]string{
+	RPCCompressionNone:     "",
+	RPCCompressionGzip:     "gzip",
+	RPCCompressionGzipFast: "gzip",
+	RPCCompressionSnappy:   "snappy",
+}
+
+// RPCCompressionNames returns the settings accepted by RPCCompression, sorted.
+func RPCCompressionNames() []string {
+	names := make([]string, 0, len(rpcCompressionEncodings))
+
+	for name := range rpcCompressionEncodings {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// RPCCompressionEncoding returns the encoding that a compression setting uses on the wire,
+// which is empty for none. Settings are compatible if their encodings are the same.
+func RPCCompressionEncoding(name string) (string, error) {
+	if len(name) == 0 {
+		name = RPCCompressionNone
+	}
+
+	encoding, ok := rpcCompressionEncodings[name]
+
+	if !ok {
+		return "", fmt.Errorf("unknown RPC compression: %q, expected one of %v", name, RPCCompressionNames())
+	}
+
+	return encoding, nil
+}
+
+// RPCCompression returns the compressor and decompressor to use for a compression setting,
+// for passing to grpc.WithCompressor and grpc.WithDecompressor on a client or
+// grpc.RPCCompressor and grpc.RPCDecompressor on a server. An empty setting is the same as
+// none, for which both are nil. Compression mostly helps with large responses, e.g. leaves
+// with their certificate chains, where the same intermediates appear over and over.
+func RPCCompression(name string) (grpc.Compressor, grpc.Decompressor, error) {
+	encoding, err := RPCCompressionEncoding(name)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case name == RPCCompressionGzipFast:
+		return newGzipCompressor(gzip.BestSpeed), gzipDecompressor{}, nil
+	case encoding == "gzip":
+		return newGzipCompressor(gzip.DefaultCompression), gzipDecompressor{}, nil
+	case encoding == "snappy":
+		return snappyCompressor{}, snappyDecompressor{}, nil
+	}
+
+	return nil, nil, nil
+}
+
+// gzipCompressor is a grpc.Compressor with a configurable level. Writers are reused as
+// setting one up allocates a lot.
+type gzipCompressor struct {
+	pool sync.Pool
+}
+
+func newGzipCompressor(level int) *gzipCompressor {
+	c := &gzipCompressor{}
+	c.pool.New = func() interface{} {
+		// The level is one of the constants so this can't fail
+		w, _ := gzip.NewWriterLevel(ioutil.Discard, level)
+		return w
+	}
+
+	return c
+}
+
+func (c *gzipCompressor) Do(w io.Writer, p []byte) error {
+	z := c.pool.Get().(*gzip.Writer)
+	defer c.pool.Put(z)
+
+	z.Reset(w)
+
+	if _, err := z.Write(p); err != nil {
+		return err
+	}
+
+	return z.Close()
+}
+
+func (c *gzipCompressor) Type() string {
+	return "gzip"
+}
+
+type gzipDecompressor struct{}
+
+func (gzipDecompressor) Do(r io.Reader) ([]byte, error) {
+	z, err := gzip.NewReader(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer z.Close()
+	return ioutil.ReadAll(z)
+}
+
+func (gzipDecompressor) Type() string {
+	return "gzip"
+}
+
+// snappyCompressor uses the snappy block format, each message is compressed on its own.
+type snappyCompressor struct{}
+
+func (snappyCompressor) Do(w io.Writer, p []byte) error {
+	_, err := w.Write(snappy.Encode(nil, p))
+	return err
+}
+
+func (snappyCompressor) Type() string {
+	return "snappy"
+}
+
+type snappyDecompressor struct{}
+
+func (snappyDecompressor) Do(r io.Reader) ([]byte, error) {
+	compressed, err := ioutil.ReadAll(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return snappy.Decode(nil, compressed)
+}
+
+func (snappyDecompressor) Type() string {
+	return "snappy"
+}
@@ -0,0 +1,183 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/trillian"
+)
+
+func TestRPCCompressionRoundTrip(t *testing.T) {
+	data := testLeavesResponse(t, 10)
+
+	for _, name := range RPCCompressionNames() {
+		c, d, err := RPCCompression(name)
+
+		if err != nil {
+			t.Fatalf("RPCCompression(%s)=%v", name, err)
+		}
+
+		if name == RPCCompressionNone {
+			if c != nil || d != nil {
+				t.Errorf("RPCCompression(none)=%v, %v, expected nil codecs", c, d)
+			}
+			continue
+		}
+
+		encoding, err := RPCCompressionEncoding(name)
+
+		if err != nil {
+			t.Fatalf("RPCCompressionEncoding(%s)=%v", name, err)
+		}
+
+		if c.Type() != encoding || d.Type() != encoding {
+			t.Errorf("%s: types are %s and %s, expected %s", name, c.Type(), d.Type(), encoding)
+		}
+
+		// Do it twice to check that reused writers are reset properly
+		for i := 0; i < 2; i++ {
+			var buf bytes.Buffer
+
+			if err := c.Do(&buf, data); err != nil {
+				t.Fatalf("%s: Compress()=%v", name, err)
+			}
+
+			if buf.Len() >= len(data) {
+				t.Errorf("%s: compressed %d bytes to %d", name, len(data), buf.Len())
+			}
+
+			got, err := d.Do(&buf)
+
+			if err != nil {
+				t.Fatalf("%s: Decompress()=%v", name, err)
+			}
+
+			if !bytes.Equal(got, data) {
+				t.Errorf("%s: data changed by the round trip", name)
+			}
+		}
+	}
+}
+
+func TestRPCCompressionEncoding(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"none", "", false},
+		{"gzip", "gzip", false},
+		{"gzip-fast", "gzip", false},
+		{"snappy", "snappy", false},
+		{"zstd", "", true},
+	} {
+		got, err := RPCCompressionEncoding(test.name)
+
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("RPCCompressionEncoding(%q)=%v, expected error: %v", test.name, err, test.wantErr)
+			continue
+		}
+
+		if got != test.want {
+			t.Errorf("RPCCompressionEncoding(%q)=%q, expected %q", test.name, got, test.want)
+		}
+	}
+
+	if _, _, err := RPCCompression("zstd"); err == nil {
+		t.Error("RPCCompression() of an unknown setting succeeded")
+	}
+}
+
+// testLeavesResponse returns a marshalled GetLeavesByIndexResponse that looks like a batch of
+// CT entries. Like real certificates, each leaf has a random key and signature but shares its
+// issuer name, extensions and policy URLs with the leaves from the same CA, and the chains of
+// intermediates are shared between the leaves too.
+func testLeavesResponse(tb testing.TB, count int) []byte {
+	r := rand.New(rand.NewSource(1))
+	randBytes := func(n int) []byte {
+		b := make([]byte, n)
+		r.Read(b)
+		return b
+	}
+
+	// A few issuers, as a batch of entries usually comes from a handful of CAs
+	var templates, chains [][]byte
+
+	for i := 0; i < 4; i++ {
+		issuer := fmt.Sprintf("C=US, O=Example Trust Services %d, CN=Example Issuing CA %d", i, i)
+		extensions := fmt.Sprintf("Key Usage: Digital Signature, Key Encipherment; Extended Key Usage: TLS Web Server Authentication; CA Issuers - URI:http://ca%d.example.com/issuer.crt; OCSP - URI:http://ocsp%d.example.com; Policy: 2.23.140.1.2.1, CPS: https://ca%d.example.com/cps", i, i, i)
+		templates = append(templates, []byte(issuer+extensions))
+		chains = append(chains, append([]byte(issuer), randBytes(1200+i*300)...))
+	}
+
+	resp := trillian.GetLeavesByIndexResponse{Status: &trillian.TrillianApiStatus{}}
+
+	for i := 0; i < count; i++ {
+		ca := r.Intn(len(templates))
+		leaf := append([]byte(fmt.Sprintf("CN=www%d.example.org, DNS:www%d.example.org, DNS:example%d.org;", i, i, i)), templates[ca]...)
+
+		resp.Leaves = append(resp.Leaves, &trillian.LeafProto{
+			LeafHash:  randBytes(32),
+			LeafData:  append(leaf, randBytes(330)...),
+			ExtraData: chains[ca],
+			LeafIndex: int64(i),
+		})
+	}
+
+	data, err := proto.Marshal(&resp)
+
+	if err != nil {
+		tb.Fatalf("Failed to marshal response: %v", err)
+	}
+
+	return data
+}
+
+// benchmarkRPCCompression measures the CPU cost of compressing and decompressing a typical
+// get-entries batch of 100 leaves, and logs how much bandwidth it saves.
+func benchmarkRPCCompression(b *testing.B, name string) {
+	data := testLeavesResponse(b, 100)
+	c, d, err := RPCCompression(name)
+
+	if err != nil {
+		b.Fatalf("RPCCompression(%s)=%v", name, err)
+	}
+
+	var buf bytes.Buffer
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+
+		if err := c.Do(&buf, data); err != nil {
+			b.Fatalf("Compress()=%v", err)
+		}
+
+		compressed := buf.Len()
+
+		if _, err := d.Do(&buf); err != nil {
+			b.Fatalf("Decompress()=%v", err)
+		}
+
+		if i == 0 {
+			b.Logf("%s: %d bytes compressed to %d (%.1f%%)", name, len(data), compressed, 100*float64(compressed)/float64(len(data)))
+		}
+	}
+}
+
+func BenchmarkRPCCompressionGzip(b *testing.B) {
+	benchmarkRPCCompression(b, RPCCompressionGzip)
+}
+
+func BenchmarkRPCCompressionGzipFast(b *testing.B) {
+	benchmarkRPCCompression(b, RPCCompressionGzipFast)
+}
+
+func BenchmarkRPCCompressionSnappy(b *testing.B) {
+	benchmarkRPCCompression(b, RPCCompressionSnappy)
+}
@@ -1,6 +1,9 @@
 package util
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 // TimeSource can provide the current time, or be replaced by a mock in tests to return
 // specific values.
@@ -29,3 +32,45 @@ type FakeTimeSource struct {
 func (f FakeTimeSource) Now() time.Time {
 	return f.FakeTime
 }
+
+// MonotonicTimeSource is a TimeSource that never goes backwards. It returns the time from
+// another source, usually the system clock, unless that is earlier than a time already
+// returned, e.g. because NTP stepped the clock back, in which case time stands still until
+// the other source catches up. A floor can also be set with NotBefore so that times aren't
+// earlier than ones seen elsewhere. It is safe for concurrent use.
+type MonotonicTimeSource struct {
+	wall TimeSource
+
+	// mu guards last
+	mu sync.Mutex
+	// last is the latest time returned by Now or set by NotBefore
+	last time.Time
+}
+
+// NewMonotonicTimeSource creates a MonotonicTimeSource that follows wall.
+func NewMonotonicTimeSource(wall TimeSource) *MonotonicTimeSource {
+	return &MonotonicTimeSource{wall: wall}
+}
+
+// Now returns the time from the wall source, or the latest time returned so far if that would
+// go backwards.
+func (m *MonotonicTimeSource) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now := m.wall.Now(); now.After(m.last) {
+		m.last = now
+	}
+
+	return m.last
+}
+
+// NotBefore makes sure Now doesn't return anything earlier than t.
+func (m *MonotonicTimeSource) NotBefore(t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if t.After(m.last) {
+		m.last = t
+	}
+}
@@ -0,0 +1,55 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+// steppingTimeSource returns each of its times in turn, to simulate a wall clock being stepped
+type steppingTimeSource struct {
+	times []time.Time
+}
+
+func (s *steppingTimeSource) Now() time.Time {
+	t := s.times[0]
+	s.times = s.times[1:]
+	return t
+}
+
+func TestMonotonicTimeSource(t *testing.T) {
+	base := time.Date(2016, 11, 1, 12, 0, 0, 0, time.UTC)
+	wall := &steppingTimeSource{times: []time.Time{
+		base,
+		base.Add(time.Second),
+		base.Add(-time.Minute), // NTP steps the clock back
+		base.Add(time.Second / 2),
+		base.Add(2 * time.Second),
+		base.Add(3 * time.Second),
+		base.Add(4 * time.Second),
+	}}
+	m := NewMonotonicTimeSource(wall)
+
+	for _, test := range []struct {
+		notBefore time.Time
+		want      time.Time
+	}{
+		{want: base},
+		{want: base.Add(time.Second)},
+		// Time stands still while the wall clock is behind
+		{want: base.Add(time.Second)},
+		{want: base.Add(time.Second)},
+		{want: base.Add(2 * time.Second)},
+		// A floor ahead of the wall clock holds time back too
+		{notBefore: base.Add(5 * time.Second), want: base.Add(5 * time.Second)},
+		// But an earlier one has no effect
+		{notBefore: base, want: base.Add(5 * time.Second)},
+	} {
+		if !test.notBefore.IsZero() {
+			m.NotBefore(test.notBefore)
+		}
+
+		if got := m.Now(); !got.Equal(test.want) {
+			t.Errorf("Now()=%v, want %v", got, test.want)
+		}
+	}
+}